@@ -2,34 +2,48 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"remnawave-tg-shop-bot/internal/activity"
+	"remnawave-tg-shop-bot/internal/alert"
+	"remnawave-tg-shop-bot/internal/botmode"
 	"remnawave-tg-shop-bot/internal/broadcast"
 	"remnawave-tg-shop-bot/internal/cache"
+	"remnawave-tg-shop-bot/internal/clock"
 	"remnawave-tg-shop-bot/internal/config"
 	"remnawave-tg-shop-bot/internal/cryptopay"
 	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/events"
+	"remnawave-tg-shop-bot/internal/fieldcrypt"
 	"remnawave-tg-shop-bot/internal/handler"
+	"remnawave-tg-shop-bot/internal/logging"
+	"remnawave-tg-shop-bot/internal/miniapp"
 	"remnawave-tg-shop-bot/internal/notification"
 	"remnawave-tg-shop-bot/internal/payment"
+	"remnawave-tg-shop-bot/internal/privacy"
 	"remnawave-tg-shop-bot/internal/promo"
 	"remnawave-tg-shop-bot/internal/remnawave"
+	"remnawave-tg-shop-bot/internal/scheduler"
+	"remnawave-tg-shop-bot/internal/shutdown"
 	"remnawave-tg-shop-bot/internal/sync"
+	"remnawave-tg-shop-bot/internal/team"
 	"remnawave-tg-shop-bot/internal/translation"
 	"remnawave-tg-shop-bot/internal/tribute"
 	"remnawave-tg-shop-bot/internal/yookasa"
-	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"github.com/robfig/cron/v3"
 )
 
 var (
@@ -39,10 +53,28 @@ var (
 )
 
 func main() {
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	// app migrate up|down|status|force — ручное управление миграциями без запуска бота
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrateCommand(os.Args[2:]))
+	}
+
+	checkConfig := flag.Bool("check-config", false, "validate configuration and exit without starting the bot")
+	flag.Parse()
+	if *checkConfig {
+		if err := config.Load(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("configuration is valid")
+		return
+	}
+
+	// docker stop присылает SIGTERM, Ctrl+C — SIGINT
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	config.InitConfig()
+	logging.Setup(os.Stderr, config.GetLogFormat(), logging.ParseLevel(config.GetLogLevel()))
 	slog.Info("Application starting", "version", Version, "commit", Commit, "buildDate", BuildDate)
 
 	tm := translation.GetInstance()
@@ -50,27 +82,52 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	tm.SetFallbacks(config.LanguageFallbacks())
 
-	pool, err := initDatabase(ctx, config.DadaBaseUrl())
+	queryMetrics := database.NewQueryMetrics(time.Duration(config.GetDBSlowQueryMs()) * time.Millisecond)
+	pool, err := initDatabase(ctx, config.DadaBaseUrl(), queryMetrics)
 	if err != nil {
 		panic(err)
 	}
 
-	err = database.RunMigrations(ctx, &database.MigrationConfig{Direction: "up", MigrationsPath: "./db/migrations", Steps: 0}, pool)
+	err = database.RunMigrations(ctx, &database.MigrationConfig{Direction: database.MigrateUp, MigrationsPath: "./db/migrations", Steps: 0})
 	if err != nil {
-		panic(err)
+		slog.Error("Database migrations failed, the bot is not started", "error", err)
+		os.Exit(1)
 	}
+	readPool := initReadReplica(ctx, queryMetrics)
+	fieldKeyring := initFieldEncryption()
 	cache := cache.NewCache(30 * time.Minute)
+
+	// Staging: админ может сдвинуть часы бота командой /time_travel
+	var timeTravelClock *clock.Offset
+	if config.IsTimeTravelEnabled() {
+		timeTravelClock = clock.NewOffset()
+		clock.SetDefault(timeTravelClock)
+	}
+
 	customerRepository := database.NewCustomerRepository(pool)
+	customerRepository.SetReadPool(readPool)
 	purchaseRepository := database.NewPurchaseRepository(pool)
 	referralRepository := database.NewReferralRepository(pool)
 	promoRepository := database.NewPromoRepository(pool)
+	statsRepository := database.NewStatsRepository(pool)
+	statsRepository.SetReadPool(readPool)
 
 	cryptoPayClient := cryptopay.NewCryptoPayClient(config.CryptoPayUrl(), config.CryptoPayToken())
 	remnawaveClient := remnawave.NewClient(config.RemnawaveUrl(), config.RemnawaveToken(), config.RemnawaveMode())
 	yookasaClient := yookasa.NewClient(config.YookasaUrl(), config.YookasaShopId(), config.YookasaSecretKey())
-	botOpts := []bot.Option{bot.WithWorkers(3)}
-	if config.IsWebhookEnabled() && config.WebhookSecretToken() != "" {
+	// Время последней активности пользователей и события воронки продаж
+	activityTracker := activity.NewTracker(database.NewActivityRepository(pool), time.Duration(config.GetActivityUpdateIntervalMinutes())*time.Minute)
+	activity.SetDefault(activityTracker)
+
+	// История экранов для кнопки "Назад"
+	navigation := handler.NewNavigation(cache)
+	// Главное меню с фото или видео заменяется текстом при переходе на другие экраны
+	mediaMenu := handler.NewMediaMenu(cache)
+
+	botOpts := []bot.Option{bot.WithWorkers(3), bot.WithMiddlewares(shutdown.BotMiddleware, logging.BotMiddleware, activityTracker.Middleware, mediaMenu.Middleware, navigation.Middleware)}
+	if config.WebhookSecretToken() != "" {
 		botOpts = append(botOpts, bot.WithWebhookSecretToken(config.WebhookSecretToken()))
 	}
 	b, err := bot.New(config.TelegramToken(), botOpts...)
@@ -78,12 +135,57 @@ func main() {
 		panic(err)
 	}
 
+	// Фоновые задачи по расписанию из конфигурации, состояние видно в админке
+	jobScheduler := scheduler.New()
+	handler.SetJobScheduler(jobScheduler)
+
+	// Алерты админу о критических сбоях
+	if config.IsAlertsEnabled() {
+		alerter := alert.NewAlerter(b, time.Duration(config.GetAlertCooldownMinutes())*time.Minute,
+			config.GetAlertErrorSpikeThreshold(), config.GetAdminTelegramId(), config.GetAlertChatID())
+		alert.SetDefault(alerter)
+		healthMonitor(jobScheduler, alert.NewHealthMonitor(alerter, config.GetAlertHealthFailureThreshold(),
+			&alert.HealthCheck{Key: alert.KeyDatabaseUnavailable, Name: "База данных", Check: pool.Ping},
+			&alert.HealthCheck{Key: alert.KeyRemnawaveUnavailable, Name: "Remnawave", Check: remnawaveClient.Ping},
+		))
+	}
+	if warnings := config.PaymentAmountWarnings(); len(warnings) > 0 {
+		alert.Notify(ctx, alert.SeverityWarning, alert.KeyInvalidPaymentAmount,
+			"Цены вне лимитов платёжных систем, кнопки оплаты для них скрыты:\n"+strings.Join(warnings, "\n"))
+	}
+
+	// События для внешних интеграций: оплаты, триалы, сбои автопродления и т.п. уходят в webhook и/или лог-канал
+	eventBus := newEventBus(ctx, b)
+	if eventBus != nil {
+		eventBus.Start()
+		events.SetDefault(eventBus)
+	}
+
+	// Фичи, переключённые в админке, перекрывают значения из env
+	featureFlagRepository := database.NewFeatureFlagRepository(pool)
+	if err := loadFeatureFlags(ctx, featureFlagRepository); err != nil {
+		slog.Error("Error loading feature flags, using env values", "error", err)
+	}
+	featureFlagsRefresher(jobScheduler, featureFlagRepository)
+
 	paymentService := payment.NewPaymentService(tm, purchaseRepository, remnawaveClient, customerRepository, b, cryptoPayClient, yookasaClient, referralRepository, cache)
+	paymentMethodRepository := database.NewPaymentMethodRepository(pool)
+	paymentService.SetPaymentMethodRepository(paymentMethodRepository)
+	// Ошибки выдачи оплаченных покупок: временные повторяются задачей fulfillment_retry, остальные — из /admin → Ошибки
+	failedFulfillmentRepository := database.NewFailedFulfillmentRepository(pool)
+	paymentService.SetFailedFulfillments(failedFulfillmentRepository)
+	fulfillmentRetrier(jobScheduler, paymentService)
+	var yookasaTestClient *yookasa.Client
+	// Клиент создаётся при заданных ключах: тестовые оплаты можно включить из админки без перезапуска
+	if config.YookasaTestShopId() != "" && config.YookasaTestSecretKey() != "" {
+		yookasaTestClient = yookasa.NewClient(config.YookasaUrl(), config.YookasaTestShopId(), config.YookasaTestSecretKey())
+		paymentService.SetYookasaTestClient(yookasaTestClient)
+	}
 
-	cronScheduler := setupInvoiceChecker(purchaseRepository, cryptoPayClient, paymentService, yookasaClient, customerRepository)
-	if cronScheduler != nil {
-		cronScheduler.Start()
-		defer cronScheduler.Stop()
+	setupInvoiceChecker(jobScheduler, paymentService)
+
+	if config.GetInvoiceTTLMinutes() > 0 && (config.IsYookasaEnabled() || config.IsCryptoPayEnabled()) {
+		invoiceExpirer(jobScheduler, paymentService)
 	}
 
 	subService := notification.NewSubscriptionService(customerRepository, purchaseRepository, paymentService, b, tm)
@@ -92,15 +194,41 @@ func main() {
 
 	// Устанавливаем сервис для тестирования уведомлений из админки
 	handler.SetNotificationTester(subService)
+	handler.RegisterNotificationPreviews(notification.Previews(tm)...)
+
+	subscriptionChecker(jobScheduler, subService)
 
-	subscriptionNotificationCronScheduler := subscriptionChecker(subService)
-	subscriptionNotificationCronScheduler.Start()
-	defer subscriptionNotificationCronScheduler.Stop()
+	// Задача регистрируется всегда: сводку можно включить из админки, выключенная сводка не отправляется
+	dailyReporter(jobScheduler, notification.NewDailyReportService(statsRepository, b))
+
+	reconciliationService := notification.NewReconciliationService(purchaseRepository, yookasaClient, cryptoPayClient, b)
+
+	// Автопродления платят зафиксированную цену; об изменившихся ценах тарифов админ узнаёт при запуске
+	recurringPriceService := notification.NewRecurringPriceService(customerRepository, b, tm)
+	if config.IsRecurringPaymentsEnabled() {
+		if changes, err := recurringPriceService.FindChanges(ctx); err != nil {
+			slog.Error("Error checking recurring prices", "error", err)
+		} else if len(changes) > 0 {
+			alert.Notify(ctx, alert.SeverityWarning, alert.KeyRecurringPriceChanged,
+				fmt.Sprintf("У %d автопродлений цена тарифа отличается от зафиксированной. Проверьте /recurring_prices", len(changes)))
+		}
+	}
+	if config.IsReconciliationEnabled() {
+		reconciler(jobScheduler, reconciliationService)
+	}
+
+	if config.GetPurchaseArchiveAfterMonths() > 0 {
+		purchaseArchiver(jobScheduler, database.NewPurchaseArchiveRepository(pool))
+	}
 
 	syncService := sync.NewSyncService(remnawaveClient, customerRepository)
+	if config.IsTrialCleanupEnabled() {
+		trialCleaner(jobScheduler, sync.NewTrialCleanupService(database.NewTrialCleanupRepository(pool), remnawaveClient))
+	}
 
 	broadcastRepo := database.NewBroadcastRepository(pool)
-	broadcastService := broadcast.NewBroadcastService(b, customerRepository, broadcastRepo)
+	broadcastRepo.SetReadPool(readPool)
+	broadcastService := broadcast.NewBroadcastService(b, customerRepository, broadcastRepo, purchaseRepository, remnawaveClient)
 
 	promoService := promo.NewService(promoRepository, customerRepository, remnawaveClient)
 
@@ -108,7 +236,57 @@ func main() {
 	promoTariffRepo := database.NewPromoTariffRepository(pool)
 	promoTariffService := promo.NewTariffService(promoTariffRepo, customerRepository)
 
-	h := handler.NewHandler(syncService, paymentService, tm, customerRepository, purchaseRepository, cryptoPayClient, yookasaClient, referralRepository, cache, broadcastService, promoService, promoTariffService, remnawaveClient)
+	// Командные подписки: команда создаётся при оплате, места активируются по ссылкам-приглашениям
+	teamService := team.NewService(database.NewTeamRepository(pool), customerRepository, remnawaveClient)
+	paymentService.SetTeamService(teamService)
+
+	// Напоминания о неоплаченных счетах: одно напоминание на счёт, статистика — в /admin
+	checkoutReminderRepository := database.NewCheckoutReminderRepository(pool)
+	paymentService.SetCheckoutReminderRepository(checkoutReminderRepository)
+	checkoutReminder(jobScheduler, paymentService)
+
+	winbackRepository := database.NewWinbackRepository(pool)
+	paymentService.SetWinbackRepository(winbackRepository)
+
+	// Опрос удовлетворённости после продления и обращения в поддержку (/survey), итоги — в /admin
+	surveyRepository := database.NewSurveyRepository(pool)
+	surveyService := notification.NewSurveyService(surveyRepository, b, tm)
+	paymentService.SetSurvey(surveyService)
+
+	// Напоминания и winback предложения приходят клиенту в часы NOTIFY_LOCAL_HOURS по его местному времени,
+	// отложенные уведомления отправляет задача outbox_delivery
+	outboxService := notification.NewOutboxService(database.NewOutboxRepository(pool), b)
+	outboxDelivery(jobScheduler, outboxService)
+
+	// Задача регистрируется всегда: кампанию можно включить из админки
+	paidWinbackService := notification.NewPaidWinbackService(winbackRepository, b, tm)
+	paidWinbackService.SetOutbox(outboxService)
+	paidWinbacker(jobScheduler, paidWinbackService)
+	offerReminder(jobScheduler, notification.NewOfferReminderService(database.NewOfferReminderRepository(pool), b, tm))
+	trialUpsellService := notification.NewTrialUpsellService(database.NewTrialUpsellRepository(pool), winbackRepository, b, tm)
+	trialUpsellService.SetOutbox(outboxService)
+	trialUpseller(jobScheduler, trialUpsellService)
+	recurringBulkRepository := database.NewRecurringBulkRepository(pool)
+	recurringBulkRepository.SetReadPool(readPool)
+	recurringBulkNotifier := notification.NewRecurringBulkNotifier(recurringBulkRepository, b, tm)
+	recurringBulkNotifier.SetOutbox(outboxService)
+	recurringBulkNotify(jobScheduler, recurringBulkNotifier)
+	if fieldKeyring != nil {
+		fieldEncrypter(jobScheduler, database.NewFieldEncryptionRepository(pool), fieldKeyring)
+	}
+
+	privacyService := privacy.NewService(database.NewPrivacyRepository(pool), remnawaveClient)
+	linkRotator := handler.NewLinkRotator(remnawaveClient, customerRepository, database.NewSubscriptionRotationRepository(pool), purchaseRepository)
+	promos := handler.NewPromoHandlers(tm, cache, customerRepository, promoService, promoTariffService, broadcastService)
+	payments := handler.NewPaymentHandlers(tm, cache, customerRepository, purchaseRepository, paymentService, database.NewReceiptRepository(pool), winbackRepository, paymentMethodRepository)
+	profile := handler.NewProfileHandlers(tm, cache, customerRepository, purchaseRepository, paymentService, referralRepository, teamService, remnawaveClient, linkRotator, promos, privacyService, remnawaveClient, payments)
+	faq := handler.NewFaqHandlers(tm, cache, database.NewFaqRepository(pool))
+	surveys := handler.NewSurveyHandlers(tm, cache, surveyRepository, customerRepository, surveyService)
+	notificationFeedback := handler.NewNotificationFeedbackHandlers(tm, cache, database.NewNotificationFeedbackRepository(pool))
+	fulfillmentErrors := handler.NewFulfillmentErrorHandlers(tm, cache, failedFulfillmentRepository, paymentService)
+	customCommandRepository := database.NewCustomCommandRepository(pool)
+	customCommands := handler.NewCustomCommands(customCommandRepository)
+	admin := handler.NewAdminHandlers(tm, cache, customerRepository, syncService, broadcastService, database.NewCustomerNoteRepository(pool), database.NewAuditLogRepository(pool), featureFlagRepository, winbackRepository, checkoutReminderRepository, linkRotator, promos, privacyService, customCommandRepository, customCommands, faq, recurringBulkRepository, profile, remnawaveClient, notification.NewQuickStatsService(statsRepository))
 
 	me, err := b.GetMe(ctx)
 	if err != nil {
@@ -124,80 +302,151 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
-	_, err = b.SetMyCommands(ctx, &bot.SetMyCommandsParams{
-		Commands: []models.BotCommand{
-			{Command: "start", Description: "Начать работу с ботом"},
-		},
-		LanguageCode: "ru",
-	})
-
-	_, err = b.SetMyCommands(ctx, &bot.SetMyCommandsParams{
-		Commands: []models.BotCommand{
-			{Command: "start", Description: "Start using the bot"},
-		},
-		LanguageCode: "en",
-	})
+	// Меню команд бота: встроенные команды и команды, добавленные админом, в чате админа — ещё и админские
+	if err := customCommands.Reload(ctx, b); err != nil {
+		slog.Error("Error loading custom commands", "error", err)
+		if err := handler.SetBotCommands(ctx, b, nil); err != nil {
+			slog.Error("Error setting bot commands", "error", err)
+		}
+	}
+	customCommandsRefresher(jobScheduler, customCommands, b)
+	translationsRefresher(jobScheduler, tm)
 
 	config.SetBotURL(fmt.Sprintf("https://t.me/%s", me.Username))
 
-	b.RegisterHandler(bot.HandlerTypeMessageText, "/start", bot.MatchTypePrefix, h.StartCommandHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeMessageText, "/connect", bot.MatchTypeExact, h.ConnectCommandHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeMessageText, "/sync", bot.MatchTypeExact, h.SyncUsersCommandHandler, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeMessageText, "/admin", bot.MatchTypeExact, h.AdminCommandHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/start", bot.MatchTypePrefix, profile.StartCommandHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/connect", bot.MatchTypeExact, profile.ConnectCommandHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/receipts", bot.MatchTypeExact, payments.ReceiptsCommandHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/privacy", bot.MatchTypeExact, profile.PrivacyCommandHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/notifications", bot.MatchTypeExact, profile.NotificationsCommandHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/timezone", bot.MatchTypeExact, profile.TimezoneCommandHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/sync", bot.MatchTypeExact, admin.SyncUsersCommandHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_duplicates", bot.MatchTypeExact, admin.AdminDuplicatesCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_duplicates_merge", bot.MatchTypePrefix, admin.AdminDuplicatesMergeCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_deleted_customers", bot.MatchTypeExact, admin.AdminDeletedCustomersCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_deleted_customers_purge", bot.MatchTypePrefix, admin.AdminDeletedCustomersPurgeCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_server_status", bot.MatchTypePrefix, admin.AdminServerStatusCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/admin", bot.MatchTypeExact, admin.AdminCommandHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/db_stats", bot.MatchTypeExact, handler.DBStatsCommandHandler(queryMetrics), isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/language", bot.MatchTypeExact, handler.LanguageReportCommandHandler(tm), isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/add_balance", bot.MatchTypePrefix, admin.AddBalanceCommandHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/reconcile", bot.MatchTypePrefix, reconciliationService.CommandHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/recurring_prices", bot.MatchTypePrefix, recurringPriceService.CommandHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/user", bot.MatchTypePrefix, admin.UserLookupCommandHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/survey", bot.MatchTypePrefix, surveys.SurveyCommandHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/tag", bot.MatchTypePrefix, admin.TagCommandHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/untag", bot.MatchTypePrefix, admin.TagCommandHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/note", bot.MatchTypePrefix, admin.NoteCommandHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/rotate", bot.MatchTypePrefix, admin.RotateLinkCommandHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/export", bot.MatchTypePrefix, admin.ExportCommandHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/erase", bot.MatchTypePrefix, admin.EraseCommandHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/grant_trial", bot.MatchTypePrefix, admin.GrantTrialCommandHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/reload_config", bot.MatchTypeExact, handler.ReloadConfigCommandHandler, isAdminMiddleware)
+	if timeTravelClock != nil {
+		b.RegisterHandler(bot.HandlerTypeMessageText, "/time_travel", bot.MatchTypePrefix, handler.TimeTravelCommandHandler(timeTravelClock), isAdminMiddleware)
+	}
 
 	// Promo code handlers
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromo, bot.MatchTypeExact, h.PromoCodeCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "bc_promo", bot.MatchTypeExact, h.BroadcastPromoCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "bc_buy", bot.MatchTypeExact, h.BroadcastBuyCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo", bot.MatchTypeExact, h.AdminPromoCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_create", bot.MatchTypeExact, h.AdminPromoCreateCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_list", bot.MatchTypeExact, h.AdminPromoListCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_view_", bot.MatchTypePrefix, h.AdminPromoViewCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_delete_", bot.MatchTypePrefix, h.AdminPromoDeleteCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_activate_", bot.MatchTypePrefix, h.AdminPromoToggleCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_deactivate_", bot.MatchTypePrefix, h.AdminPromoToggleCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromo, bot.MatchTypeExact, promos.PromoCodeCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "bc_promo", bot.MatchTypeExact, promos.BroadcastPromoCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "bc_buy", bot.MatchTypeExact, payments.BroadcastBuyCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo", bot.MatchTypeExact, promos.AdminPromoCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_create", bot.MatchTypeExact, promos.AdminPromoCreateCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_list", bot.MatchTypePrefix, promos.AdminPromoListCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_view_", bot.MatchTypePrefix, promos.AdminPromoViewCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_delete_", bot.MatchTypePrefix, promos.AdminPromoDeleteCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_activate_", bot.MatchTypePrefix, promos.AdminPromoToggleCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_deactivate_", bot.MatchTypePrefix, promos.AdminPromoToggleCallback, isAdminMiddleware)
 
 	// Promo tariff handlers (admin)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_tariff", bot.MatchTypeExact, h.AdminPromoTariffCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_tariff_create", bot.MatchTypeExact, h.AdminPromoTariffCreateCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_tariff_list", bot.MatchTypeExact, h.AdminPromoTariffListCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_tariff_view_", bot.MatchTypePrefix, h.AdminPromoTariffViewCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_tariff_delete_", bot.MatchTypePrefix, h.AdminPromoTariffDeleteCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_tariff_activate_", bot.MatchTypePrefix, h.AdminPromoTariffToggleCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_tariff_deactivate_", bot.MatchTypePrefix, h.AdminPromoTariffToggleCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_tariff", bot.MatchTypeExact, promos.AdminPromoTariffCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_tariff_create", bot.MatchTypeExact, promos.AdminPromoTariffCreateCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_tariff_list", bot.MatchTypePrefix, promos.AdminPromoTariffListCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_tariff_view_", bot.MatchTypePrefix, promos.AdminPromoTariffViewCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_tariff_delete_", bot.MatchTypePrefix, promos.AdminPromoTariffDeleteCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_tariff_activate_", bot.MatchTypePrefix, promos.AdminPromoTariffToggleCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_tariff_deactivate_", bot.MatchTypePrefix, promos.AdminPromoTariffToggleCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_offer", bot.MatchTypeExact, promos.AdminPromoOfferCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_offer_seg_", bot.MatchTypePrefix, promos.AdminPromoOfferSegmentCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_promo_offer_confirm?", bot.MatchTypePrefix, promos.AdminPromoOfferConfirmCallback, isAdminMiddleware)
 
 	// Promo tariff user handler - Requirements: 5.3
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoTariff, bot.MatchTypeExact, h.PromoTariffCallbackHandler, h.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPromoTariff, bot.MatchTypeExact, promos.PromoTariffCallbackHandler, profile.SuspiciousUserFilterMiddleware)
 
 	// Broadcast handlers
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_broadcast", bot.MatchTypeExact, h.AdminBroadcastCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_target_", bot.MatchTypePrefix, h.AdminBroadcastTargetCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_btn_", bot.MatchTypePrefix, h.AdminBroadcastButtonCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_confirm_", bot.MatchTypePrefix, h.AdminBroadcastConfirmCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_broadcast_history", bot.MatchTypeExact, h.AdminBroadcastHistoryCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_view_", bot.MatchTypePrefix, h.AdminBroadcastViewCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_delete_", bot.MatchTypePrefix, h.AdminBroadcastDeleteCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_back", bot.MatchTypeExact, h.AdminBackCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_close", bot.MatchTypeExact, h.AdminCloseCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_broadcast", bot.MatchTypeExact, admin.AdminBroadcastCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_broadcast_tags", bot.MatchTypeExact, admin.AdminBroadcastTagsCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_broadcast_rwtags", bot.MatchTypeExact, admin.AdminBroadcastPanelTagsCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_target_", bot.MatchTypePrefix, admin.AdminBroadcastTargetCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_btn_", bot.MatchTypePrefix, admin.AdminBroadcastButtonCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_confirm_", bot.MatchTypePrefix, admin.AdminBroadcastConfirmCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_test_", bot.MatchTypePrefix, admin.AdminBroadcastTestCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_recent_", bot.MatchTypePrefix, admin.AdminBroadcastRecentCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_cap_", bot.MatchTypePrefix, admin.AdminBroadcastCapCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_exclude_tags_", bot.MatchTypePrefix, admin.AdminBroadcastExcludeTagsCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_exclude_tag_", bot.MatchTypePrefix, admin.AdminBroadcastExcludeTagCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_exclusions_", bot.MatchTypePrefix, admin.AdminBroadcastExclusionsCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_broadcast_history", bot.MatchTypePrefix, admin.AdminBroadcastHistoryCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_view_", bot.MatchTypePrefix, admin.AdminBroadcastViewCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "broadcast_delete_", bot.MatchTypePrefix, admin.AdminBroadcastDeleteCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_back", bot.MatchTypeExact, admin.AdminBackCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_close", bot.MatchTypeExact, admin.AdminCloseCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_erase_", bot.MatchTypePrefix, admin.AdminEraseCallback, isAdminMiddleware)
 
 	// Test notifications handlers
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_test_notifications", bot.MatchTypeExact, h.AdminTestNotificationsCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_test_inactive_trial", bot.MatchTypeExact, h.AdminTestInactiveTrialCallback, isAdminMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_test_winback", bot.MatchTypeExact, h.AdminTestWinbackCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_test_notifications", bot.MatchTypeExact, admin.AdminTestNotificationsCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_test_inactive_trial", bot.MatchTypeExact, admin.AdminTestInactiveTrialCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_test_previews", bot.MatchTypeExact, admin.AdminTestPreviewsCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_test_winback", bot.MatchTypeExact, admin.AdminTestWinbackCallback, isAdminMiddleware)
+
+	// Фоновые задачи
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_jobs", bot.MatchTypeExact, admin.AdminJobsCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_job_run_", bot.MatchTypePrefix, admin.AdminJobRunCallback, isAdminMiddleware)
+
+	// Фичи
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_features", bot.MatchTypeExact, admin.AdminFeaturesCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_winback", bot.MatchTypeExact, admin.AdminWinbackCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_checkout_reminders", bot.MatchTypeExact, admin.AdminCheckoutRemindersCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_survey", bot.MatchTypeExact, surveys.AdminSurveyCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_notification_feedback", bot.MatchTypeExact, notificationFeedback.AdminNotificationFeedbackCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_fulfillment_errors", bot.MatchTypeExact, fulfillmentErrors.AdminFulfillmentErrorsCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_fulfillment_retry?", bot.MatchTypePrefix, fulfillmentErrors.AdminFulfillmentRetryCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_recurring", bot.MatchTypeExact, admin.AdminRecurringCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_recurring_seg?", bot.MatchTypePrefix, admin.AdminRecurringSegmentCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_recurring_disable?", bot.MatchTypePrefix, admin.AdminRecurringDisableCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_recurring_undo?", bot.MatchTypePrefix, admin.AdminRecurringUndoCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_feature_toggle_", bot.MatchTypePrefix, admin.AdminFeatureToggleCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_feature_reset_", bot.MatchTypePrefix, admin.AdminFeatureResetCallback, isAdminMiddleware)
+
+	// Команды с постоянным ответом, добавленные админом
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_commands", bot.MatchTypeExact, admin.AdminCustomCommandsCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_command_create", bot.MatchTypeExact, admin.AdminCustomCommandCreateCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_command_view_", bot.MatchTypePrefix, admin.AdminCustomCommandViewCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_command_toggle_", bot.MatchTypePrefix, admin.AdminCustomCommandToggleCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_command_delete_", bot.MatchTypePrefix, admin.AdminCustomCommandDeleteCallback, isAdminMiddleware)
+	b.RegisterHandlerMatchFunc(customCommands.Match, customCommands.CommandHandler, profile.SuspiciousUserFilterMiddleware)
+
+	// FAQ (admin)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_faq_list", bot.MatchTypePrefix, faq.AdminFaqCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_faq_create", bot.MatchTypeExact, faq.AdminFaqCreateCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_faq_view_", bot.MatchTypePrefix, faq.AdminFaqViewCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_faq_toggle_", bot.MatchTypePrefix, faq.AdminFaqToggleCallback, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "admin_faq_delete_", bot.MatchTypePrefix, faq.AdminFaqDeleteCallback, isAdminMiddleware)
 	
 	// Обработчик текста и медиа для рассылки и создания промокодов (только для админа)
 	b.RegisterHandlerMatchFunc(func(update *models.Update) bool {
 		if update.Message == nil || update.Message.From.ID != config.GetAdminTelegramId() {
 			return false
 		}
-		// Текст (не команда), фото, GIF, видео или кружок
-		hasText := update.Message.Text != "" && !strings.HasPrefix(update.Message.Text, "/")
+		// Текст (не команда), фото, GIF, видео или кружок. Новая команда для админки начинается с "/"
+		commandState, _ := cache.GetString(fmt.Sprintf("admin_command_state_%d", update.Message.From.ID))
+		hasText := update.Message.Text != "" && (!strings.HasPrefix(update.Message.Text, "/") || commandState == "waiting_command")
 		hasPhoto := update.Message.Photo != nil && len(update.Message.Photo) > 0
 		hasAnimation := update.Message.Animation != nil
 		hasVideo := update.Message.Video != nil
 		hasVideoNote := update.Message.VideoNote != nil
 		return hasText || hasPhoto || hasAnimation || hasVideo || hasVideoNote
-	}, h.AdminTextInputHandler)
+	}, admin.AdminTextInputHandler)
 
 	// Обработчик ввода промокода от пользователя (только если есть состояние ожидания)
 	b.RegisterHandlerMatchFunc(func(update *models.Update) bool {
@@ -211,157 +460,349 @@ func main() {
 		stateKey := fmt.Sprintf("promo_state_%d", update.Message.From.ID)
 		state, found := cache.GetString(stateKey)
 		return found && state == "waiting_code"
-	}, h.PromoCodeInputHandler, h.SuspiciousUserFilterMiddleware)
-
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackReferral, bot.MatchTypeExact, h.ReferralCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackBuy, bot.MatchTypeExact, h.BuyCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTariff, bot.MatchTypePrefix, h.TariffCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTrial, bot.MatchTypeExact, h.TrialCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackActivateTrial, bot.MatchTypeExact, h.ActivateTrialCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackWinbackActivate, bot.MatchTypeExact, h.WinbackCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackStart, bot.MatchTypeExact, h.StartCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackSell, bot.MatchTypePrefix, h.SellCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackConnect, bot.MatchTypeExact, h.ConnectCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPayment, bot.MatchTypePrefix, h.PaymentCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackRecurringToggle, bot.MatchTypePrefix, h.RecurringToggleCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackRecurringDisable, bot.MatchTypeExact, h.RecurringDisableCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackDeletePaymentMethod, bot.MatchTypeExact, h.DeletePaymentMethodCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackSavedPaymentMethods, bot.MatchTypePrefix, h.SavedPaymentMethodsCallbackHandler, h.SuspiciousUserFilterMiddleware)
-	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackCloseMessage, bot.MatchTypeExact, h.CloseMessageCallbackHandler, h.SuspiciousUserFilterMiddleware)
+	}, promos.PromoCodeInputHandler, profile.SuspiciousUserFilterMiddleware)
+
+	// Email для чеков (только если клиент нажал кнопку email в профиле)
+	b.RegisterHandlerMatchFunc(func(update *models.Update) bool {
+		if update.Message == nil || update.Message.Text == "" || strings.HasPrefix(update.Message.Text, "/") {
+			return false
+		}
+		state, found := cache.GetString(fmt.Sprintf("email_state_%d", update.Message.From.ID))
+		return found && state == "waiting_email"
+	}, profile.ReceiptEmailInputHandler, profile.SuspiciousUserFilterMiddleware)
+
+	// Текст для поиска по FAQ (только если клиент нажал «Поиск»)
+	b.RegisterHandlerMatchFunc(func(update *models.Update) bool {
+		if update.Message == nil || update.Message.Text == "" || strings.HasPrefix(update.Message.Text, "/") {
+			return false
+		}
+		state, found := cache.GetString(fmt.Sprintf("faq_search_%d", update.Message.From.ID))
+		return found && state == "waiting_query"
+	}, faq.FaqSearchInputHandler, profile.SuspiciousUserFilterMiddleware)
+
+	// Комментарий к оценке в опросе (только если клиент нажал «Оставить комментарий»)
+	b.RegisterHandlerMatchFunc(func(update *models.Update) bool {
+		if update.Message == nil || update.Message.Text == "" || strings.HasPrefix(update.Message.Text, "/") {
+			return false
+		}
+		_, found := cache.GetString(fmt.Sprintf("survey_comment_%d", update.Message.From.ID))
+		return found
+	}, surveys.SurveyCommentInputHandler, profile.SuspiciousUserFilterMiddleware)
+
+	// @username получателя подарка (только если клиент нажал «Подарить»)
+	b.RegisterHandlerMatchFunc(func(update *models.Update) bool {
+		if update.Message == nil || update.Message.Text == "" || strings.HasPrefix(update.Message.Text, "/") {
+			return false
+		}
+		state, found := cache.GetString(fmt.Sprintf("gift_state_%d", update.Message.From.ID))
+		return found && state == "waiting_username"
+	}, profile.GiftRecipientInputHandler, profile.SuspiciousUserFilterMiddleware)
+
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackReferral, bot.MatchTypeExact, profile.ReferralCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackBuy, bot.MatchTypeExact, payments.BuyCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTariff, bot.MatchTypePrefix, payments.TariffCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTrial, bot.MatchTypeExact, profile.TrialCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackActivateTrial, bot.MatchTypeExact, profile.ActivateTrialCallbackHandler, profile.SuspiciousUserFilterMiddleware, profile.TosAcceptanceMiddleware, profile.TrialPhoneVerificationMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackWinbackActivate, bot.MatchTypeExact, payments.WinbackCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackWinbackOptOut, bot.MatchTypeExact, payments.WinbackOptOutCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackReceipt, bot.MatchTypePrefix, payments.ReceiptCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackStart, bot.MatchTypeExact, profile.StartCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackSell, bot.MatchTypePrefix, payments.SellCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackConnect, bot.MatchTypeExact, profile.ConnectCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackRotateLink, bot.MatchTypeExact, profile.RotateLinkCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTimezone, bot.MatchTypeExact, profile.TimezoneCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTimezoneSet, bot.MatchTypePrefix, profile.TimezoneSetCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackReceiptEmail, bot.MatchTypeExact, profile.ReceiptEmailCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackReceiptEmailClear, bot.MatchTypeExact, profile.ReceiptEmailClearCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackNotifications, bot.MatchTypeExact, profile.NotificationsCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackNotificationsToggle, bot.MatchTypePrefix, profile.NotificationsToggleCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackRotateLinkConfirm, bot.MatchTypeExact, profile.RotateLinkConfirmCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPrivacyExport, bot.MatchTypeExact, profile.PrivacyExportCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPrivacyErase, bot.MatchTypeExact, profile.PrivacyEraseCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPrivacyEraseConfirm, bot.MatchTypeExact, profile.PrivacyEraseConfirmCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPayment, bot.MatchTypePrefix, payments.PaymentCallbackHandler, profile.SuspiciousUserFilterMiddleware, profile.TosAcceptanceMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackSandboxPayment, bot.MatchTypePrefix, payments.SandboxPaymentCallbackHandler, isAdminMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackRecurringToggle, bot.MatchTypePrefix, payments.RecurringToggleCallbackHandler, profile.SuspiciousUserFilterMiddleware, profile.TosAcceptanceMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackRecurringDisable, bot.MatchTypeExact, payments.RecurringDisableCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackDeletePaymentMethod, bot.MatchTypePrefix, payments.DeletePaymentMethodCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackSelectPaymentMethod, bot.MatchTypePrefix, payments.SelectPaymentMethodCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackRecurringPriceAccept, bot.MatchTypeExact, payments.RecurringPriceAcceptCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackRecurringConfirm, bot.MatchTypeExact, payments.RecurringConfirmCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackSavedPaymentMethods, bot.MatchTypePrefix, payments.SavedPaymentMethodsCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackCloseMessage, bot.MatchTypeExact, payments.CloseMessageCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackBack, bot.MatchTypeExact, navigation.BackCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTeam, bot.MatchTypeExact, profile.TeamCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTeamSeats, bot.MatchTypePrefix, profile.TeamSeatsCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTeamMonths, bot.MatchTypePrefix, profile.TeamMonthsCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTeamPayment, bot.MatchTypePrefix, profile.TeamPaymentCallbackHandler, profile.SuspiciousUserFilterMiddleware, profile.TosAcceptanceMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTeamView, bot.MatchTypePrefix, profile.TeamViewCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTeamAskRevoke, bot.MatchTypePrefix, profile.TeamAskRevokeCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTeamDoRevoke, bot.MatchTypePrefix, profile.TeamDoRevokeCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackGift, bot.MatchTypeExact, profile.GiftCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackGiftTariff, bot.MatchTypePrefix, profile.GiftTariffCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackGiftPayment, bot.MatchTypePrefix, profile.GiftPaymentCallbackHandler, profile.SuspiciousUserFilterMiddleware, profile.TosAcceptanceMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackFaq, bot.MatchTypeExact, faq.FaqCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackFaqCategory, bot.MatchTypePrefix, faq.FaqCategoryCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackFaqEntry, bot.MatchTypePrefix, faq.FaqEntryCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackFaqSearch, bot.MatchTypeExact, faq.FaqSearchCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackSurveyRate, bot.MatchTypePrefix, surveys.SurveyRateCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackSurveyComment, bot.MatchTypePrefix, surveys.SurveyCommentCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackNotificationFeedback, bot.MatchTypePrefix, notificationFeedback.NotificationFeedbackCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackServerStatus, bot.MatchTypeExact, profile.ServerStatusCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTosAccept, bot.MatchTypeExact, profile.TosAcceptCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTosDecline, bot.MatchTypeExact, profile.TosDeclineCallbackHandler, profile.SuspiciousUserFilterMiddleware)
 	b.RegisterHandlerMatchFunc(func(update *models.Update) bool {
 		return update.PreCheckoutQuery != nil
-	}, h.PreCheckoutCallbackHandler, h.SuspiciousUserFilterMiddleware)
+	}, payments.PreCheckoutCallbackHandler, profile.SuspiciousUserFilterMiddleware)
 
 	b.RegisterHandlerMatchFunc(func(update *models.Update) bool {
 		return update.Message != nil && update.Message.SuccessfulPayment != nil
-	}, h.SuccessPaymentHandler, h.SuspiciousUserFilterMiddleware)
+	}, payments.SuccessPaymentHandler, profile.SuspiciousUserFilterMiddleware)
+
+	// Inline режим: карточки с реферальной ссылкой, промокодами и тарифами для отправки друзьям
+	b.RegisterHandlerMatchFunc(func(update *models.Update) bool {
+		return update.InlineQuery != nil
+	}, promos.InlineQueryHandler)
+
+	// Контакт, отправленный кнопкой подтверждения номера перед триалом
+	b.RegisterHandlerMatchFunc(func(update *models.Update) bool {
+		return update.Message != nil && update.Message.Contact != nil
+	}, profile.TrialPhoneContactHandler, profile.SuspiciousUserFilterMiddleware)
 
 	mux := http.NewServeMux()
-	mux.Handle("/healthcheck", fullHealthHandler(pool, remnawaveClient))
+	// Панель проверяется в фоне: частые запросы балансировщика к /healthcheck не доходят до Remnawave
+	remnawaveProber := remnawave.NewProber(remnawaveClient.Ping,
+		time.Duration(config.HealthCheckProbeSeconds())*time.Second, 10*time.Second)
+	go remnawaveProber.Run(ctx)
+	mux.Handle("/healthcheck", fullHealthHandler(pool, remnawaveClient, remnawaveProber))
 	if config.GetTributeWebHookUrl() != "" {
 		tributeHandler := tribute.NewClient(paymentService, customerRepository)
 		mux.Handle(config.GetTributeWebHookUrl(), tributeHandler.WebHookHandler())
 	}
 
+	// Уведомления ЮKassa о возвратах: сокращаем оплаченный период подписки
+	if config.IsYookasaEnabled() && config.GetYookasaWebhookPath() != "" {
+		yookasaWebhookHandler := handler.NewYookasaWebhookHandler(yookasaClient, paymentService)
+		if yookasaTestClient != nil {
+			yookasaWebhookHandler.SetTestShopClient(yookasaTestClient)
+		}
+		mux.HandleFunc(config.GetYookasaWebhookPath(), yookasaWebhookHandler.HandleWebhook)
+		slog.Info("YooKassa webhook handler registered", "path", config.GetYookasaWebhookPath())
+	}
+
+	// JSON API для Telegram Mini App (статус подписки, тарифы, покупки)
+	if config.IsMiniAppAPIEnabled() {
+		miniAppAPI := miniapp.NewAPI(customerRepository, purchaseRepository, paymentService)
+		mux.Handle(config.GetMiniAppAPIPath()+"/", miniAppAPI.Handler(config.GetMiniAppAPIPath()))
+		slog.Info("Mini App API registered", "path", config.GetMiniAppAPIPath())
+	}
+
 	// Remnawave webhook handler для уведомлений об истечении подписки, winback и автопродления
 	// Requirements: 3.2, 2.1, 2.2, 2.3, 2.4, 2.5
 	if config.GetRemnawaveWebhookSecret() != "" {
 		remnawaveWebhookHandler := handler.NewRemnawaveWebhookHandler(tm, b, customerRepository, purchaseRepository)
 		// Устанавливаем клиенты для рекуррентных платежей
-		if config.IsRecurringPaymentsEnabled() && config.IsYookasaEnabled() {
+		// Клиенты нужны при включённом автопродлении, которое можно включить из админки без перезапуска
+		if config.IsYookasaEnabled() {
 			remnawaveWebhookHandler.SetYookasaClient(yookasaClient)
 			remnawaveWebhookHandler.SetRemnawaveClient(remnawaveClient)
-			slog.Info("Recurring payments enabled for webhook handler")
+			slog.Info("Recurring payments available for webhook handler", "enabled", config.IsRecurringPaymentsEnabled())
 		}
+		// Сохранение событий для дедупликации и повторной обработки при ошибках
+		remnawaveWebhookHandler.SetEventStore(database.NewWebhookEventRepository(pool))
+		remnawaveWebhookHandler.SetRecurringChargeLog(statsRepository)
+		remnawaveWebhookHandler.SetBalanceStore(customerRepository)
+		remnawaveWebhookHandler.SetPaymentMethodStore(paymentMethodRepository)
+		remnawaveWebhookHandler.SetLocalTimeSender(outboxService)
+		remnawaveWebhookHandler.SetSurvey(surveyService)
+		remnawaveWebhookHandler.SetRecurringFulfillment(paymentService)
+		webhookEventRetrier(jobScheduler, remnawaveWebhookHandler)
+		b.RegisterHandler(bot.HandlerTypeMessageText, "/webhook_retry", bot.MatchTypeExact, remnawaveWebhookHandler.ReprocessFailedEventsCommandHandler, isAdminMiddleware)
+
 		mux.HandleFunc(config.GetRemnawaveWebhookPath(), remnawaveWebhookHandler.HandleWebhook)
 		slog.Info("Remnawave webhook handler registered", "path", config.GetRemnawaveWebhookPath())
 	}
 
-	// Webhook mode
-	if config.IsWebhookEnabled() {
-		mux.Handle("/webhook", b.WebhookHandler())
-		
-		srv := &http.Server{
-			Addr:    fmt.Sprintf(":%d", config.GetHealthCheckPort()),
-			Handler: mux,
-		}
+	// Режим получения обновлений: при старте согласуем webhook в Telegram с конфигурацией,
+	// админ может переключить режим командой /bot_mode
+	modeManager := botmode.NewManager(b, config.WebhookURL(), config.WebhookSecretToken(), config.IsWebhookSelfCheckEnabled())
+	mux.Handle("/webhook", modeManager.WebhookHandler(b.WebhookHandler()))
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/bot_mode", bot.MatchTypePrefix, modeManager.CommandHandler, isAdminMiddleware)
 
-		// Set webhook
-		_, err = b.SetWebhook(ctx, &bot.SetWebhookParams{
-			URL:            config.WebhookURL(),
-			SecretToken:    config.WebhookSecretToken(),
-			AllowedUpdates: []string{"message", "callback_query", "pre_checkout_query"},
-		})
-		if err != nil {
-			panic(fmt.Sprintf("Failed to set webhook: %v", err))
+	jobScheduler.Start()
+	go watchConfigReload(ctx, b)
+
+	// Рассылки, прерванные прошлой остановкой, продолжаются с сохранённой позиции
+	if err := broadcastService.ResumeInterrupted(ctx); err != nil {
+		slog.Error("Failed to resume interrupted broadcasts", "error", err)
+	}
+
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", config.GetHealthCheckPort()),
+		Handler: logging.HTTPMiddleware(mux),
+	}
+	go func() {
+		slog.Info("Server listening", "addr", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server error: %v", err)
 		}
-		slog.Info("Webhook set", "url", config.WebhookURL())
+	}()
 
-		go b.StartWebhook(ctx)
+	desiredMode := botmode.ModePolling
+	if config.IsWebhookEnabled() {
+		desiredMode = botmode.ModeWebhook
+	}
+	mode, err := modeManager.Reconcile(ctx, desiredMode)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to reconcile updates mode: %v", err))
+	}
 
-		go func() {
-			log.Printf("Server listening on %s (webhook mode)", srv.Addr)
-			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				log.Fatalf("Server error: %v", err)
-			}
-		}()
+	slog.Info("Bot is starting...", "mode", mode)
+	modeManager.Run(ctx, mode)
 
-		<-ctx.Done()
+	// Новые обновления больше не принимаются, начатые обработки доводятся до конца
+	slog.Info("Shutting down, waiting for in-flight work", "timeout", time.Duration(config.GetShutdownTimeoutSeconds())*time.Second)
+	shutdown.Begin()
+	modeManager.Shutdown(context.Background())
 
-		// Delete webhook on shutdown
-		_, _ = b.DeleteWebhook(context.Background(), &bot.DeleteWebhookParams{})
-		slog.Info("Webhook deleted")
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), time.Duration(config.GetShutdownTimeoutSeconds())*time.Second)
+	defer drainCancel()
 
-		log.Println("Shutting down server…")
-		shutdownCtx, shutCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer shutCancel()
-		if err := srv.Shutdown(shutdownCtx); err != nil {
-			log.Printf("Server shutdown error: %v", err)
-		}
-	} else {
-		// Polling mode (original)
-		srv := &http.Server{
-			Addr:    fmt.Sprintf(":%d", config.GetHealthCheckPort()),
-			Handler: mux,
+	slog.Info("Shutting down server…")
+	shutdownCtx, shutCancel := context.WithTimeout(drainCtx, 5*time.Second)
+	defer shutCancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server shutdown error", "error", err)
+	}
+
+	if err := jobScheduler.Shutdown(drainCtx); err != nil {
+		slog.Error("Background jobs did not finish before shutdown timeout", "error", err)
+	}
+	if err := shutdown.Wait(drainCtx); err != nil {
+		slog.Error("In-flight work did not finish before shutdown timeout", "error", err)
+	}
+	if eventBus != nil {
+		if err := eventBus.Close(drainCtx); err != nil {
+			slog.Error("Events were not delivered before shutdown timeout", "error", err)
 		}
-		go func() {
-			log.Printf("Server listening on %s (polling mode)", srv.Addr)
-			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				log.Fatalf("Server error: %v", err)
-			}
-		}()
+	}
 
-		slog.Info("Bot is starting...")
-		b.Start(ctx)
+	pool.Close()
+	if readPool != nil {
+		readPool.Close()
+	}
+	slog.Info("Shutdown complete")
+}
 
-		log.Println("Shutting down health server…")
-		shutdownCtx, shutCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer shutCancel()
-		if err := srv.Shutdown(shutdownCtx); err != nil {
-			log.Printf("Health server shutdown error: %v", err)
-		}
+// newEventBus создаёт шину событий с настроенными приёмниками. nil — интеграции не настроены
+func newEventBus(ctx context.Context, b *bot.Bot) *events.Bus {
+	if !config.IsEventsEnabled() {
+		return nil
 	}
 
+	var sinks []events.Sink
+	if url := config.GetEventsWebhookURL(); url != "" {
+		sink := events.NewWebhookSink(url, config.GetEventsWebhookSecret(),
+			time.Duration(config.GetEventsWebhookTimeoutSeconds())*time.Second)
+		sinks = append(sinks, events.Filter(sink, eventTypes(config.EventTypes())))
+		slog.Info("Events webhook enabled", "types", config.EventTypes())
+	}
+	if chatID := config.GetLogChannelID(); chatID != 0 {
+		sink := events.NewTelegramSink(b, chatID, config.LogChannelMaxPerMinute())
+		sinks = append(sinks, events.Filter(sink, eventTypes(config.LogChannelEventTypes())))
+		slog.Info("Log channel enabled", "types", config.LogChannelEventTypes(), "maxPerMinute", config.LogChannelMaxPerMinute())
+	}
+	if provider := config.AnalyticsProvider(); provider != "" {
+		sink := events.NewAnalyticsSink(provider, config.AnalyticsURL(), config.AnalyticsAPIKey(), config.AnalyticsSalt(),
+			config.AnalyticsBatchSize(), config.IsAnalyticsEnabled)
+		go sink.Run(ctx, time.Duration(config.AnalyticsFlushSeconds())*time.Second)
+		sinks = append(sinks, sink)
+		slog.Info("Analytics enabled", "provider", provider, "batchSize", config.AnalyticsBatchSize())
+	}
+	return events.NewBus(1000, sinks...)
+}
+
+// eventTypes переводит типы из конфига в типы событий. Пустой список — все публичные события:
+// продуктовые события для аналитики в webhook и лог-канал не попадают
+func eventTypes(names []string) []events.Type {
+	if len(names) == 0 {
+		return events.Types()
+	}
+	var types []events.Type
+	for _, name := range names {
+		types = append(types, events.Type(name))
+	}
+	return types
 }
 
-func fullHealthHandler(pool *pgxpool.Pool, rw *remnawave.Client) http.Handler {
+func fullHealthHandler(pool *pgxpool.Pool, rw *remnawave.Client, prober *remnawave.Prober) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		status := map[string]string{
-			"status":    "ok",
-			"db":        "ok",
-			"rw":        "ok",
-			"time":      time.Now().Format(time.RFC3339),
-			"version":   Version,
-			"commit":    Commit,
-			"buildDate": BuildDate,
+		status := healthStatus{
+			Status:    "ok",
+			DB:        "ok",
+			Remnawave: "ok",
+			Time:      time.Now().Format(time.RFC3339),
+			Version:   Version,
+			Commit:    Commit,
+			BuildDate: BuildDate,
 		}
 
 		dbCtx, dbCancel := context.WithTimeout(r.Context(), 5*time.Second)
 		defer dbCancel()
 		if err := pool.Ping(dbCtx); err != nil {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			status["status"] = "fail"
-			status["db"] = "error: " + err.Error()
+			status.Status = "fail"
+			status.DB = "error: " + err.Error()
 		}
 
-		rwCtx, rwCancel := context.WithTimeout(r.Context(), 5*time.Second)
-		defer rwCancel()
-		if err := rw.Ping(rwCtx); err != nil {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			status["status"] = "fail"
-			status["rw"] = "error: " + err.Error()
+		// Состояние Remnawave берётся из circuit breaker клиента: healthcheck не нагружает панель
+		// и отвечает сразу, даже когда панель зависла
+		health := rw.Health()
+		status.RemnawaveState = string(health.State)
+		if health.State == remnawave.BreakerOpen {
+			status.Status = "fail"
+			status.Remnawave = fmt.Sprintf("error: unavailable since %s: %s", health.OpenedAt.Format(time.RFC3339), health.LastError)
 		}
-
-		if status["status"] == "ok" {
-			w.WriteHeader(http.StatusOK)
+		if probe := prober.Result(); !probe.CheckedAt.IsZero() {
+			latency := probe.Latency.Milliseconds()
+			status.RemnawaveCheckedAt = probe.CheckedAt.Format(time.RFC3339)
+			status.RemnawaveLatencyMs = &latency
+			status.RemnawaveProbeError = probe.LastError
+			if !probe.LastSuccessAt.IsZero() {
+				status.RemnawaveLastSuccessAt = probe.LastSuccessAt.Format(time.RFC3339)
+			}
 		}
 
+		body, err := json.Marshal(status)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
-		fmt.Fprintf(w, `{"status":"%s","db":"%s","remnawave":"%s","time":"%s","version":"%s","commit":"%s","buildDate":"%s"}`,
-			status["status"], status["db"], status["rw"], status["time"], Version, Commit, BuildDate)
+		if status.Status == "ok" {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write(body)
 	})
 }
 
+type healthStatus struct {
+	Status         string `json:"status"`
+	DB             string `json:"db"`
+	Remnawave      string `json:"remnawave"`
+	RemnawaveState string `json:"remnawaveState"`
+	// Итог фоновой проверки панели (HEALTH_CHECK_PROBE_SECONDS), пусто до первой проверки
+	RemnawaveCheckedAt     string `json:"remnawaveCheckedAt,omitempty"`
+	RemnawaveLastSuccessAt string `json:"remnawaveLastSuccessAt,omitempty"`
+	RemnawaveLatencyMs     *int64 `json:"remnawaveLatencyMs,omitempty"`
+	RemnawaveProbeError    string `json:"remnawaveProbeError,omitempty"`
+	Time                   string `json:"time"`
+	Version                string `json:"version"`
+	Commit                 string `json:"commit"`
+	BuildDate              string `json:"buildDate"`
+}
+
 func isAdminMiddleware(next bot.HandlerFunc) bot.HandlerFunc {
 	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
 		adminID := config.GetAdminTelegramId()
@@ -378,311 +819,390 @@ func isAdminMiddleware(next bot.HandlerFunc) bot.HandlerFunc {
 	}
 }
 
-func subscriptionChecker(subService *notification.SubscriptionService) *cron.Cron {
-	c := cron.New()
-
-	// Проверка неактивных триальных пользователей каждый час
-	// Requirements: 2.1, 3.1
-	_, err := c.AddFunc("0 * * * *", func() {
-		defer func() {
-			if r := recover(); r != nil {
-				slog.Error("Panic in ProcessTrialInactiveNotifications", "panic", r)
-			}
-		}()
-		err := subService.ProcessTrialInactiveNotifications()
-		if err != nil {
-			slog.Error("Error processing trial inactive notifications", "error", err)
-		}
-	})
-	if err != nil {
+// addJob регистрирует фоновую задачу с расписанием из конфигурации CRON_<ИМЯ>
+func addJob(jobScheduler *scheduler.Scheduler, job scheduler.Job) {
+	job.Schedule = config.CronSchedule(job.Name)
+	if err := jobScheduler.Add(job); err != nil {
 		panic(err)
 	}
+}
+
+// subscriptionChecker проверяет неактивных триальных пользователей (по умолчанию каждый час)
+// Requirements: 2.1, 3.1
+func subscriptionChecker(jobScheduler *scheduler.Scheduler, subService *notification.SubscriptionService) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:   config.JobTrialInactive,
+		Title:  "Неактивные триалы",
+		Jitter: 30 * time.Second,
+		Run: func(ctx context.Context) error {
+			return subService.ProcessTrialInactiveNotifications(ctx)
+		},
+	})
 
 	// Winback теперь обрабатывается через вебхук user.expired_24_hours_ago от Remnawave
+}
 
-	return c
+// paidWinbacker отправляет ушедшим платящим клиентам предложения продлить прежний тариф со скидкой
+func paidWinbacker(jobScheduler *scheduler.Scheduler, winbackService *notification.PaidWinbackService) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobWinbackPaid,
+		Title:   "Winback ушедших клиентов",
+		Timeout: 30 * time.Minute,
+		Jitter:  time.Minute,
+		Run: func(ctx context.Context) error {
+			return winbackService.Run(ctx)
+		},
+	})
 }
 
-func initDatabase(ctx context.Context, connString string) (*pgxpool.Pool, error) {
-	config, err := pgxpool.ParseConfig(connString)
-	if err != nil {
-		return nil, err
-	}
+// outboxDelivery отправляет уведомления, отложенные до разрешённых часов по местному времени клиента
+func outboxDelivery(jobScheduler *scheduler.Scheduler, outboxService *notification.OutboxService) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobOutboxDelivery,
+		Title:   "Отложенные уведомления",
+		Timeout: 5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			return outboxService.Run(ctx)
+		},
+	})
+}
 
-	config.MaxConns = 20
-	config.MinConns = 5
+// offerReminder напоминает о промо-тарифах и winback предложениях, которые скоро закончатся
+func offerReminder(jobScheduler *scheduler.Scheduler, reminderService *notification.OfferReminderService) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobOfferReminder,
+		Title:   "Напоминания об окончании предложений",
+		Timeout: 10 * time.Minute,
+		Run: func(ctx context.Context) error {
+			return reminderService.Run(ctx)
+		},
+	})
+}
 
-	return pgxpool.ConnectConfig(ctx, config)
+// trialUpseller отправляет клиентам на триале цепочку сообщений с предложением оформить подписку
+func trialUpseller(jobScheduler *scheduler.Scheduler, upsellService *notification.TrialUpsellService) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobTrialUpsell,
+		Title:   "Цепочка после активации триала",
+		Timeout: 10 * time.Minute,
+		Run: func(ctx context.Context) error {
+			return upsellService.Run(ctx)
+		},
+	})
 }
 
-func setupInvoiceChecker(
-	purchaseRepository *database.PurchaseRepository,
-	cryptoPayClient *cryptopay.Client,
-	paymentService *payment.PaymentService,
-	yookasaClient *yookasa.Client,
-	customerRepository *database.CustomerRepository) *cron.Cron {
-	if !config.IsYookasaEnabled() && !config.IsCryptoPayEnabled() {
-		return nil
-	}
-	c := cron.New(cron.WithSeconds())
+// trialCleaner отключает или удаляет в панели пользователей с давно истёкшим триалом и без оплат
+func trialCleaner(jobScheduler *scheduler.Scheduler, cleanupService *sync.TrialCleanupService) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobTrialCleanup,
+		Title:   "Очистка истёкших триалов",
+		Timeout: 30 * time.Minute,
+		Jitter:  time.Minute,
+		Run: func(ctx context.Context) error {
+			return cleanupService.Run(ctx)
+		},
+	})
+}
 
-	if config.IsCryptoPayEnabled() {
-		_, err := c.AddFunc("*/5 * * * * *", func() {
-			ctx := context.Background()
-			checkCryptoPayInvoice(ctx, purchaseRepository, cryptoPayClient, paymentService)
-		})
+// healthMonitor проверяет доступность БД и Remnawave (по умолчанию каждую минуту)
+func healthMonitor(jobScheduler *scheduler.Scheduler, monitor *alert.HealthMonitor) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobHealthMonitor,
+		Title:   "Проверка БД и Remnawave",
+		Timeout: 30 * time.Second,
+		Run: func(ctx context.Context) error {
+			monitor.Run(ctx)
+			return nil
+		},
+	})
+}
 
-		if err != nil {
-			panic(err)
-		}
-	}
+// dailyReporter отправляет админу ежедневную сводку
+func dailyReporter(jobScheduler *scheduler.Scheduler, reportService *notification.DailyReportService) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobDailyReport,
+		Title:   "Ежедневная сводка",
+		Timeout: 10 * time.Minute,
+		Run: func(ctx context.Context) error {
+			return reportService.SendDailyReport(ctx)
+		},
+	})
+}
 
-	if config.IsYookasaEnabled() {
-		// Проверяем каждые 10 секунд (было 5) чтобы не перегружать API
-		_, err := c.AddFunc("*/10 * * * * *", func() {
-			ctx := context.Background()
-			checkYookasaInvoice(ctx, purchaseRepository, yookasaClient, paymentService, customerRepository)
-		})
+// reconciler сверяет платежи провайдеров за прошедшие сутки и сообщает админу о расхождениях
+func reconciler(jobScheduler *scheduler.Scheduler, reconciliationService *notification.ReconciliationService) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobReconciliation,
+		Title:   "Сверка платежей",
+		Timeout: 30 * time.Minute,
+		Jitter:  2 * time.Minute,
+		Run: func(ctx context.Context) error {
+			return reconciliationService.RunDailyReconciliation(ctx)
+		},
+	})
+}
 
-		if err != nil {
-			panic(err)
-		}
-	}
+// checkoutReminder напоминает о счетах, которые не оплачены дольше CHECKOUT_REMINDER_DELAY_MINUTES
+func checkoutReminder(jobScheduler *scheduler.Scheduler, paymentService *payment.PaymentService) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobCheckoutReminder,
+		Title:   "Напоминания о неоплаченных счетах",
+		Timeout: 5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			delay := time.Duration(config.CheckoutReminderDelayMinutes()) * time.Minute
+			sent, err := paymentService.RemindAbandonedCheckouts(ctx, delay)
+			if err != nil {
+				return fmt.Errorf("remind abandoned checkouts: %w", err)
+			}
+			if sent > 0 {
+				slog.InfoContext(ctx, "Checkout reminders sent", "sent", sent)
+			}
+			return nil
+		},
+	})
+}
 
-	return c
+// fulfillmentRetrier повторяет выдачу оплаченных покупок после временных ошибок
+func fulfillmentRetrier(jobScheduler *scheduler.Scheduler, paymentService *payment.PaymentService) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobFulfillmentRetry,
+		Title:   "Повтор выдачи покупок",
+		Timeout: 5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			return paymentService.RetryDueFulfillments(ctx)
+		},
+	})
 }
 
-func checkYookasaInvoice(
-	ctx context.Context,
-	purchaseRepository *database.PurchaseRepository,
-	yookasaClient *yookasa.Client,
-	paymentService *payment.PaymentService,
-	customerRepository *database.CustomerRepository,
-) {
-	pendingPurchases, err := purchaseRepository.FindByInvoiceTypeAndStatus(
-		ctx,
-		database.InvoiceTypeYookasa,
-		database.PurchaseStatusPending,
-	)
-	if err != nil {
-		log.Printf("Error finding pending purchases: %v", err)
-		return
-	}
-	if len(*pendingPurchases) == 0 {
-		return
-	}
+// invoiceExpirer переводит неоплаченные дольше INVOICE_TTL_MINUTES счета в статус expired
+func invoiceExpirer(jobScheduler *scheduler.Scheduler, paymentService *payment.PaymentService) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobInvoiceExpirer,
+		Title:   "Истечение счетов",
+		Timeout: 5 * time.Minute,
+		Run: func(ctx context.Context) error {
+			ttl := time.Duration(config.GetInvoiceTTLMinutes()) * time.Minute
+			expired, err := paymentService.ExpireStalePurchases(ctx, ttl)
+			if err != nil {
+				return fmt.Errorf("expire stale purchases: %w", err)
+			}
+			if expired > 0 {
+				slog.InfoContext(ctx, "Stale purchases expired", "expired", expired)
+			}
+			return nil
+		},
+	})
+}
 
-	for i, purchase := range *pendingPurchases {
-		// Задержка между запросами чтобы не перегружать API ЮКассы
-		if i > 0 {
-			time.Sleep(200 * time.Millisecond)
-		}
+// purchaseArchiver переносит старые покупки в архив, чтобы таблица purchase оставалась небольшой
+func purchaseArchiver(jobScheduler *scheduler.Scheduler, archiveRepository *database.PurchaseArchiveRepository) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobPurchaseArchive,
+		Title:   "Архивация покупок",
+		Timeout: 30 * time.Minute,
+		Jitter:  2 * time.Minute,
+		Run: func(ctx context.Context) error {
+			cutoff := time.Now().AddDate(0, -config.GetPurchaseArchiveAfterMonths(), 0)
+			archived, err := archiveRepository.ArchiveOlderThan(ctx, cutoff, 1000)
+			if err != nil {
+				return fmt.Errorf("archive purchases (archived %d): %w", archived, err)
+			}
+			slog.InfoContext(ctx, "Purchases archived", "archived", archived, "cutoff", cutoff)
+			return nil
+		},
+	})
+}
 
-		invoice, err := yookasaClient.GetPayment(ctx, *purchase.YookasaID)
+// webhookEventRetrier повторно обрабатывает Remnawave webhook события, обработка которых завершилась ошибкой
+func webhookEventRetrier(jobScheduler *scheduler.Scheduler, webhookHandler *handler.RemnawaveWebhookHandler) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobWebhookRetry,
+		Title:   "Повтор webhook событий",
+		Timeout: 5 * time.Minute,
+		Jitter:  10 * time.Second,
+		Run: func(ctx context.Context) error {
+			return webhookHandler.ProcessPendingEvents(ctx)
+		},
+	})
+}
 
-		if err != nil {
-			slog.Error("Error getting invoice", "invoiceId", purchase.YookasaID, "error", err)
-			continue
-		}
+// featureFlagsRefresher перечитывает фичи из БД, чтобы переключение в админке доходило до всех экземпляров бота
+func featureFlagsRefresher(jobScheduler *scheduler.Scheduler, repository *database.FeatureFlagRepository) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobFeatureFlagsRefresh,
+		Title:   "Обновление фич",
+		Timeout: 30 * time.Second,
+		Run: func(ctx context.Context) error {
+			return loadFeatureFlags(ctx, repository)
+		},
+	})
+}
 
-		if invoice.IsCancelled() {
-			err := paymentService.CancelYookassaPayment(purchase.ID)
-			if err != nil {
-				slog.Error("Error canceling invoice", "invoiceId", invoice.ID, "purchaseId", purchase.ID, "error", err)
+// translationsRefresher подхватывает новые и изменённые файлы переводов, чтобы язык добавлялся без перезапуска
+func translationsRefresher(jobScheduler *scheduler.Scheduler, tm *translation.Manager) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobTranslationsRefresh,
+		Title:   "Обновление переводов",
+		Timeout: 30 * time.Second,
+		Run: func(ctx context.Context) error {
+			loaded, err := tm.Refresh()
+			if len(loaded) > 0 {
+				slog.InfoContext(ctx, "Translations reloaded", "languages", loaded)
 			}
-			continue
-		}
+			return err
+		},
+	})
+}
 
-		if !invoice.Paid {
-			continue
-		}
+// customCommandsRefresher перечитывает команды, добавленные в админке, чтобы изменения доходили до всех экземпляров бота
+func customCommandsRefresher(jobScheduler *scheduler.Scheduler, customCommands *handler.CustomCommands, b *bot.Bot) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobCustomCommandsRefresh,
+		Title:   "Обновление команд",
+		Timeout: 30 * time.Second,
+		Run: func(ctx context.Context) error {
+			return customCommands.Reload(ctx, b)
+		},
+	})
+}
 
-		purchaseId, err := strconv.Atoi(invoice.Metadata["purchaseId"])
-		if err != nil {
-			slog.Error("Error parsing purchaseId", "invoiceId", invoice.ID, "error", err)
-		}
-		ctxWithValue := context.WithValue(ctx, "username", invoice.Metadata["username"])
-		err = paymentService.ProcessPurchaseById(ctxWithValue, int64(purchaseId))
-		if err != nil {
-			slog.Error("Error processing invoice", "invoiceId", invoice.ID, "purchaseId", purchaseId, "error", err)
-		} else {
-			slog.Info("Invoice processed", "invoiceId", invoice.ID, "purchaseId", purchaseId)
-		}
+// recurringBulkNotify уведомляет клиентов о массовом отключении автопродления, когда срок отмены операции истёк
+func recurringBulkNotify(jobScheduler *scheduler.Scheduler, notifier *notification.RecurringBulkNotifier) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobRecurringBulkNotify,
+		Title:   "Уведомления об отключении автопродления",
+		Timeout: 10 * time.Minute,
+		Run: func(ctx context.Context) error {
+			return notifier.Run(ctx)
+		},
+	})
+}
 
-		// Управление recurring после успешной оплаты YooKassa
-		// ВАЖНО: ProcessPurchaseById уже мог удалить payment method для promo/winback покупок
-		// когда соответствующий recurring отключён.
-		// Проверяем текущее состояние customer — если payment_method_id уже NULL,
-		// значит ProcessPurchaseById его удалил и не нужно восстанавливать.
-		if invoice.IsPaymentMethodSaved() {
-			// Перечитываем customer чтобы увидеть изменения от ProcessPurchaseById
-			updatedCustomer, err := customerRepository.FindById(ctx, purchase.CustomerID)
+// fieldEncrypter шифрует текущим ключом ссылки подписки и способы оплаты, записанные открытым текстом или прежним ключом
+func fieldEncrypter(jobScheduler *scheduler.Scheduler, repository *database.FieldEncryptionRepository, keyring *fieldcrypt.Keyring) {
+	addJob(jobScheduler, scheduler.Job{
+		Name:    config.JobFieldEncryption,
+		Title:   "Шифрование данных",
+		Timeout: 30 * time.Minute,
+		Jitter:  time.Minute,
+		Run: func(ctx context.Context) error {
+			encrypted, err := repository.EncryptAll(ctx, keyring, 500)
 			if err != nil {
-				slog.Error("Error finding customer after purchase", "customerID", purchase.CustomerID, "error", err)
-			} else if updatedCustomer != nil && updatedCustomer.PaymentMethodID == nil {
-				// ProcessPurchaseById удалил payment method (promo/winback с отключённым recurring)
-				// Не восстанавливаем его
-				slog.Info("Payment method was deleted by ProcessPurchaseById, not restoring", "customerID", purchase.CustomerID)
-			} else {
-				// Пользователь включил автопродление — сохраняем payment_method_id
-				// Передаём purchase для fallback данных (если пользователь не включил recurring в боте,
-				// но разрешил автосписания на форме ЮКассы)
-				saveRecurringPaymentMethod(ctx, invoice, purchase.CustomerID, customerRepository, &purchase)
+				return fmt.Errorf("encrypt fields (encrypted %d): %w", encrypted, err)
 			}
-		} else {
-			// Пользователь НЕ включил автопродление для этой покупки — отключаем recurring
-			// Но карту не удаляем — она может пригодиться для будущих покупок
-			if err := customerRepository.DisableRecurring(ctx, purchase.CustomerID); err != nil {
-				slog.Error("Error disabling recurring after purchase without save", "customerID", purchase.CustomerID, "error", err)
-			} else {
-				slog.Info("Disabled recurring after purchase without payment method save", "customerID", purchase.CustomerID)
+			if encrypted > 0 {
+				slog.InfoContext(ctx, "Fields encrypted", "encrypted", encrypted)
 			}
-		}
-
-	}
+			return nil
+		},
+	})
 }
 
-// saveRecurringPaymentMethod сохраняет payment_method_id и настройки рекуррентных платежей
-// Requirements: 1.3
-// purchase передаётся для fallback данных, если метаданные отсутствуют
-// (случай когда пользователь не включил recurring в боте, но разрешил автосписания на форме ЮКассы)
-func saveRecurringPaymentMethod(
-	ctx context.Context,
-	invoice *yookasa.Payment,
-	customerID int64,
-	customerRepository *database.CustomerRepository,
-	purchase *database.Purchase,
-) {
-	paymentMethodID := invoice.GetPaymentMethodID().String()
-
-	// Получаем настройки recurring из метаданных платежа
-	var tariffName *string
-	var months *int
-	var amount *int
-
-	if tn, ok := invoice.Metadata["recurring_tariff_name"]; ok && tn != "" {
-		tariffName = &tn
+// initFieldEncryption включает шифрование колонок ключами FIELD_ENCRYPTION_KEYS. nil — шифрование выключено
+func initFieldEncryption() *fieldcrypt.Keyring {
+	if config.FieldEncryptionKeys() == "" {
+		return nil
 	}
-
-	if m, ok := invoice.Metadata["recurring_months"]; ok {
-		if monthsInt, err := strconv.Atoi(m); err == nil {
-			months = &monthsInt
-		}
+	keyring, err := fieldcrypt.ParseKeys(config.FieldEncryptionKeys())
+	if err != nil {
+		panic(fmt.Errorf("invalid field encryption keys: %w", err))
 	}
+	fieldcrypt.SetDefault(keyring)
+	return keyring
+}
 
-	if a, ok := invoice.Metadata["recurring_amount"]; ok {
-		if amountInt, err := strconv.Atoi(a); err == nil {
-			amount = &amountInt
-		}
+func loadFeatureFlags(ctx context.Context, repository *database.FeatureFlagRepository) error {
+	flags, err := repository.GetAll(ctx)
+	if err != nil {
+		return fmt.Errorf("load feature flags: %w", err)
 	}
+	config.SetFeatureOverrides(flags)
+	return nil
+}
 
-	// Fallback: если метаданные отсутствуют (пользователь не включил recurring в боте,
-	// но разрешил автосписания на форме ЮКассы), берём данные из purchase
-	if purchase != nil {
-		if tariffName == nil && purchase.TariffName != nil {
-			tariffName = purchase.TariffName
-			slog.Info("Using tariff name from purchase (fallback)", "tariffName", *tariffName)
-		}
-		if months == nil && purchase.Month > 0 {
-			m := purchase.Month
-			months = &m
-			slog.Info("Using months from purchase (fallback)", "months", m)
-		}
-		if amount == nil && purchase.Amount > 0 {
-			a := int(purchase.Amount)
-			amount = &a
-			slog.Info("Using amount from purchase (fallback)", "amount", a)
-		}
+func initDatabase(ctx context.Context, connString string, queryMetrics *database.QueryMetrics) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, err
 	}
 
-	err := customerRepository.UpdateRecurringSettings(
-		ctx,
-		customerID,
-		true, // recurring_enabled
-		&paymentMethodID,
-		tariffName,
-		months,
-		amount,
-	)
+	config.MaxConns = 20
+	config.MinConns = 5
+	// Длительность запросов собирается через pgx Logger (уровень Info — все выполненные запросы)
+	config.ConnConfig.Logger = queryMetrics
+	config.ConnConfig.LogLevel = pgx.LogLevelInfo
 
-	if err != nil {
-		slog.Error("Error saving recurring payment method",
-			"customerID", customerID,
-			"paymentMethodID", paymentMethodID,
-			"error", err)
-	} else {
-		// Разыменовываем указатели для логирования
-		var tariffNameVal string
-		var monthsVal, amountVal int
-		if tariffName != nil {
-			tariffNameVal = *tariffName
-		}
-		if months != nil {
-			monthsVal = *months
-		}
-		if amount != nil {
-			amountVal = *amount
-		}
-		slog.Info("Recurring payment method saved",
-			"customerID", customerID,
-			"paymentMethodID", paymentMethodID,
-			"tariffName", tariffNameVal,
-			"months", monthsVal,
-			"amount", amountVal)
-	}
-}
-
-func checkCryptoPayInvoice(
-	ctx context.Context,
-	purchaseRepository *database.PurchaseRepository,
-	cryptoPayClient *cryptopay.Client,
-	paymentService *payment.PaymentService,
-) {
-	pendingPurchases, err := purchaseRepository.FindByInvoiceTypeAndStatus(
-		ctx,
-		database.InvoiceTypeCrypto,
-		database.PurchaseStatusPending,
-	)
-	if err != nil {
-		log.Printf("Error finding pending purchases: %v", err)
-		return
+	return pgxpool.ConnectConfig(ctx, config)
+}
+
+// initReadReplica подключается к реплике DATABASE_READ_URL. Без реплики или при ошибке подключения
+// возвращает nil, и аналитические запросы идут в основную БД
+func initReadReplica(ctx context.Context, queryMetrics *database.QueryMetrics) *pgxpool.Pool {
+	if config.DatabaseReadURL() == "" {
+		return nil
 	}
-	if len(*pendingPurchases) == 0 {
-		return
+	readPool, err := initDatabase(ctx, config.DatabaseReadURL(), queryMetrics)
+	if err != nil {
+		slog.Error("Read replica unavailable, analytical queries will use the primary database", "error", err)
+		return nil
 	}
+	slog.Info("Read replica enabled for analytical queries")
+	return readPool
+}
 
-	var invoiceIDs []string
-
-	for _, purchase := range *pendingPurchases {
-		if purchase.CryptoInvoiceID != nil {
-			invoiceIDs = append(invoiceIDs, fmt.Sprintf("%d", *purchase.CryptoInvoiceID))
-		}
+func setupInvoiceChecker(jobScheduler *scheduler.Scheduler, paymentService *payment.PaymentService) {
+	if config.IsCryptoPayEnabled() {
+		addJob(jobScheduler, scheduler.Job{
+			Name:    config.JobCryptoPayInvoiceCheck,
+			Title:   "Проверка счетов CryptoPay",
+			Timeout: time.Minute,
+			Run: func(ctx context.Context) error {
+				paymentService.CheckCryptoPayInvoices(ctx)
+				return nil
+			},
+		})
 	}
 
-	if len(invoiceIDs) == 0 {
-		return
+	if config.IsYookasaEnabled() {
+		// По умолчанию каждые 10 секунд (было 5) чтобы не перегружать API.
+		// Запуск пропускается, пока предыдущая проверка не завершилась
+		addJob(jobScheduler, scheduler.Job{
+			Name:    config.JobYookasaInvoiceCheck,
+			Title:   "Проверка счетов ЮKassa",
+			Timeout: 5 * time.Minute,
+			Run: func(ctx context.Context) error {
+				paymentService.CheckYookasaInvoices(ctx)
+				return nil
+			},
+		})
 	}
+}
 
-	stringInvoiceIDs := strings.Join(invoiceIDs, ",")
-	invoices, err := cryptoPayClient.GetInvoices("", "", "", stringInvoiceIDs, 0, 0)
-	if err != nil {
-		log.Printf("Error getting invoices: %v", err)
-		return
-	}
+// watchConfigReload по SIGHUP перечитывает .env и присылает админу отчёт о применённых настройках
+func watchConfigReload(ctx context.Context, b *bot.Bot) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
 
-	for _, invoice := range *invoices {
-		if invoice.InvoiceID != nil && invoice.IsPaid() {
-			payload := strings.Split(invoice.Payload, "&")
-			purchaseID, err := strconv.Atoi(strings.Split(payload[0], "=")[1])
-			username := strings.Split(payload[1], "=")[1]
-			ctxWithUsername := context.WithValue(ctx, "username", username)
-			err = paymentService.ProcessPurchaseById(ctxWithUsername, int64(purchaseID))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			result, err := config.Reload()
 			if err != nil {
-				slog.Error("Error processing invoice", "invoiceId", invoice.InvoiceID, "error", err)
+				slog.Warn("Config reload on SIGHUP rejected", "error", err)
 			} else {
-				slog.Info("Invoice processed", "invoiceId", invoice.InvoiceID, "purchaseId", purchaseID)
+				slog.Info("Config reloaded on SIGHUP", "applied", result.Applied, "restartRequired", result.RestartRequired)
+			}
+			_, sendErr := b.SendMessage(ctx, &bot.SendMessageParams{
+				ChatID:    config.GetAdminTelegramId(),
+				Text:      handler.FormatConfigReload(result, err),
+				ParseMode: models.ParseModeHTML,
+			})
+			if sendErr != nil {
+				slog.Error("Error sending config reload report", "error", sendErr)
 			}
-
 		}
 	}
 }
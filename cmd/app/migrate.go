@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+const migrateUsage = `usage: app migrate [-path dir] [-lock-timeout 5m] <command>
+
+commands:
+  up [N]       apply all pending migrations or the next N
+  down [N|all] roll back the last N migrations (1 by default) or all of them
+  status       show the current version, pending and modified migrations
+  force V      mark version V as applied and clean without running it (-1: none applied)`
+
+const migrationStatus = "status"
+
+// parseMigrateArgs разбирает аргументы после "migrate". Для status возвращает Direction "status"
+func parseMigrateArgs(args []string) (*database.MigrationConfig, error) {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	path := fs.String("path", "./db/migrations", "migrations directory")
+	lockTimeout := fs.Duration("lock-timeout", 5*time.Minute, "how long to wait for another instance applying migrations")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	rest := fs.Args()
+	if len(rest) == 0 {
+		return nil, errors.New("missing command")
+	}
+	cfg := &database.MigrationConfig{MigrationsPath: *path, Direction: rest[0], LockTimeout: *lockTimeout}
+	arg := ""
+	if len(rest) > 1 {
+		arg = rest[1]
+	}
+	if len(rest) > 2 {
+		return nil, fmt.Errorf("unexpected arguments: %v", rest[2:])
+	}
+
+	switch cfg.Direction {
+	case database.MigrateUp:
+		if arg != "" {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("up: invalid number of migrations %q", arg)
+			}
+			cfg.Steps = n
+		}
+	case database.MigrateDown:
+		// Откат всех миграций удаляет все данные, поэтому только явным "all"
+		switch arg {
+		case "":
+			cfg.Steps = 1
+		case "all":
+			cfg.Steps = 0
+		default:
+			n, err := strconv.Atoi(arg)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("down: invalid number of migrations %q", arg)
+			}
+			cfg.Steps = n
+		}
+	case database.MigrateForce:
+		v, err := strconv.Atoi(arg)
+		if err != nil || v < -1 {
+			return nil, fmt.Errorf("force: invalid version %q", arg)
+		}
+		cfg.Steps = v
+	case migrationStatus:
+		if arg != "" {
+			return nil, fmt.Errorf("status: unexpected argument %q", arg)
+		}
+	default:
+		return nil, fmt.Errorf("unknown command %q", cfg.Direction)
+	}
+	return cfg, nil
+}
+
+// runMigrateCommand выполняет app migrate и возвращает код выхода. Нужна та же конфигурация, что и боту
+func runMigrateCommand(args []string) int {
+	cfg, err := parseMigrateArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n\n%s\n", err, migrateUsage)
+		return 2
+	}
+	if err := config.Load(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if cfg.Direction == migrationStatus {
+		state, err := database.MigrationStatus(ctx, cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate status: %v\n", err)
+			return 1
+		}
+		printMigrationState(os.Stdout, state)
+		if state.Dirty || len(state.Modified) > 0 {
+			return 1
+		}
+		return 0
+	}
+
+	if err := database.RunMigrations(ctx, cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "migrate %s: %v\n", cfg.Direction, err)
+		return 1
+	}
+	return 0
+}
+
+func printMigrationState(w io.Writer, state *database.MigrationState) {
+	if state.Version == 0 {
+		fmt.Fprintln(w, "version: none applied")
+	} else {
+		fmt.Fprintf(w, "version: %d\n", state.Version)
+	}
+	if state.Dirty {
+		fmt.Fprintln(w, "dirty: yes, the last migration failed halfway; check the schema and run migrate force")
+	}
+	for _, group := range []struct {
+		title string
+		files []string
+	}{
+		{"pending", state.Pending},
+		{"modified after apply", state.Modified},
+		{"no checksum recorded yet", state.Unverified},
+	} {
+		fmt.Fprintf(w, "%s: %d\n", group.title, len(group.files))
+		for _, file := range group.files {
+			fmt.Fprintf(w, "  %s\n", file)
+		}
+	}
+}
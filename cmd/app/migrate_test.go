@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+func TestParseMigrateArgs(t *testing.T) {
+	tests := []struct {
+		args      []string
+		direction string
+		steps     int
+	}{
+		{args: []string{"up"}, direction: database.MigrateUp, steps: 0},
+		{args: []string{"up", "2"}, direction: database.MigrateUp, steps: 2},
+		{args: []string{"down"}, direction: database.MigrateDown, steps: 1},
+		{args: []string{"down", "3"}, direction: database.MigrateDown, steps: 3},
+		{args: []string{"down", "all"}, direction: database.MigrateDown, steps: 0},
+		{args: []string{"force", "12"}, direction: database.MigrateForce, steps: 12},
+		{args: []string{"force", "-1"}, direction: database.MigrateForce, steps: -1},
+		{args: []string{"status"}, direction: migrationStatus, steps: 0},
+	}
+	for _, tt := range tests {
+		cfg, err := parseMigrateArgs(tt.args)
+		if err != nil {
+			t.Errorf("parseMigrateArgs(%v) returned error: %v", tt.args, err)
+			continue
+		}
+		if cfg.Direction != tt.direction || cfg.Steps != tt.steps || cfg.MigrationsPath != "./db/migrations" {
+			t.Errorf("parseMigrateArgs(%v) = %+v, want direction %s and steps %d", tt.args, cfg, tt.direction, tt.steps)
+		}
+	}
+}
+
+func TestParseMigrateArgsFlags(t *testing.T) {
+	cfg, err := parseMigrateArgs([]string{"-path", "/srv/migrations", "-lock-timeout", "30s", "up"})
+	if err != nil {
+		t.Fatalf("parseMigrateArgs() returned error: %v", err)
+	}
+	if cfg.MigrationsPath != "/srv/migrations" || cfg.LockTimeout != 30*time.Second {
+		t.Errorf("Expected flags to be applied, got %+v", cfg)
+	}
+}
+
+func TestParseMigrateArgsRejectsInvalidInput(t *testing.T) {
+	for _, args := range [][]string{
+		nil,
+		{"sideways"},
+		{"up", "0"},
+		{"down", "-2"},
+		{"force"},
+		{"force", "-2"},
+		{"status", "now"},
+		{"up", "1", "2"},
+	} {
+		if cfg, err := parseMigrateArgs(args); err == nil {
+			t.Errorf("Expected parseMigrateArgs(%v) to fail, got %+v", args, cfg)
+		}
+	}
+}
+
+func TestPrintMigrationState(t *testing.T) {
+	var out strings.Builder
+	printMigrationState(&out, &database.MigrationState{
+		Version:  50,
+		Pending:  []string{"000051_next.up.sql"},
+		Modified: []string{"000010_old.up.sql"},
+	})
+
+	for _, want := range []string{"version: 50", "pending: 1", "  000051_next.up.sql", "modified after apply: 1", "  000010_old.up.sql"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected status to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/payment"
+	"remnawave-tg-shop-bot/internal/remnawave"
+)
+
+// latency задержка фейкового провайдера: имитирует сетевой запрос к базе, Telegram или платёжной системе
+type latency time.Duration
+
+func (l latency) wait(ctx context.Context) {
+	if l <= 0 {
+		return
+	}
+	timer := time.NewTimer(time.Duration(l))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// newFakeTelegram Bot API, который через delay отвечает успехом на любой метод
+func newFakeTelegram(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveTelegram(latency(delay), w, r)
+	}))
+}
+
+func serveTelegram(delay latency, w http.ResponseWriter, r *http.Request) {
+	delay.wait(r.Context())
+
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	var result any = true
+	if method != "answerCallbackQuery" && method != "deleteMessage" && method != "setMyCommands" {
+		result = models.Message{ID: 1, Chat: models.Chat{ID: 1}}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+// memoryStore клиенты и покупки в памяти вместо Postgres. Реализует репозитории, которые нужны
+// обработчикам старта, цен и оплаты
+type memoryStore struct {
+	delay latency
+
+	mu           sync.RWMutex
+	customers    map[int64]*database.Customer
+	byTelegramID map[int64]int64
+	purchases    map[int64]*database.Purchase
+	nextID       int64
+}
+
+func newMemoryStore(delay time.Duration) *memoryStore {
+	return &memoryStore{
+		delay:        latency(delay),
+		customers:    make(map[int64]*database.Customer),
+		byTelegramID: make(map[int64]int64),
+		purchases:    make(map[int64]*database.Purchase),
+	}
+}
+
+func (s *memoryStore) FindByTelegramId(ctx context.Context, telegramId int64) (*database.Customer, error) {
+	s.delay.wait(ctx)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id, ok := s.byTelegramID[telegramId]
+	if !ok {
+		return nil, nil
+	}
+	customer := *s.customers[id]
+	return &customer, nil
+}
+
+func (s *memoryStore) FindById(ctx context.Context, id int64) (*database.Customer, error) {
+	s.delay.wait(ctx)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	customer, ok := s.customers[id]
+	if !ok {
+		return nil, nil
+	}
+	c := *customer
+	return &c, nil
+}
+
+func (s *memoryStore) Create(ctx context.Context, customer *database.Customer) (*database.Customer, error) {
+	s.delay.wait(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id, ok := s.byTelegramID[customer.TelegramID]; ok {
+		return nil, fmt.Errorf("customer with telegram id %d already exists (id %d)", customer.TelegramID, id)
+	}
+	s.nextID++
+	created := *customer
+	created.ID = s.nextID
+	created.CreatedAt = time.Now()
+	s.customers[created.ID] = &created
+	s.byTelegramID[created.TelegramID] = created.ID
+	result := created
+	return &result, nil
+}
+
+func (s *memoryStore) UpdateFields(ctx context.Context, id int64, updates map[string]interface{}) error {
+	s.delay.wait(ctx)
+	return nil
+}
+
+func (s *memoryStore) AcceptTos(ctx context.Context, id int64, version string, acceptedAt time.Time) error {
+	s.delay.wait(ctx)
+	return nil
+}
+
+func (s *memoryStore) IsPhoneVerified(ctx context.Context, id int64) (bool, error) {
+	return false, nil
+}
+
+func (s *memoryStore) SavePhoneHash(ctx context.Context, id int64, phoneHash string, verifiedAt time.Time) error {
+	return nil
+}
+
+func (s *memoryStore) DisableRecurring(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (s *memoryStore) DeletePaymentMethod(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (s *memoryStore) AcceptRecurringPriceChange(ctx context.Context, id int64, at time.Time) (bool, error) {
+	return false, nil
+}
+
+func (s *memoryStore) ConfirmRecurringCharge(ctx context.Context, id int64, at time.Time) (bool, error) {
+	return false, nil
+}
+
+func (s *memoryStore) FindLastPaidPurchaseByCustomer(ctx context.Context, customerID int64) (*database.Purchase, error) {
+	s.delay.wait(ctx)
+	return nil, nil
+}
+
+func (s *memoryStore) FindPaidByCustomer(ctx context.Context, customerID int64, limit int) ([]database.Purchase, error) {
+	s.delay.wait(ctx)
+	return nil, nil
+}
+
+func (s *memoryStore) FindSuccessfulPaidPurchaseByCustomer(ctx context.Context, customerID int64) (*database.Purchase, error) {
+	s.delay.wait(ctx)
+	return nil, nil
+}
+
+// addPurchase сохраняет новую покупку и возвращает её ID
+func (s *memoryStore) addPurchase(purchase database.Purchase) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	purchase.ID = s.nextID
+	s.purchases[purchase.ID] = &purchase
+	return purchase.ID
+}
+
+// purchasesFor ищет покупки по ID для paymentPurchases
+type purchasesFor struct{ *memoryStore }
+
+func (p purchasesFor) FindById(ctx context.Context, id int64) (*database.Purchase, error) {
+	p.delay.wait(ctx)
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	purchase, ok := p.purchases[id]
+	if !ok {
+		return nil, nil
+	}
+	result := *purchase
+	return &result, nil
+}
+
+// fakePayments платёжный сервис без обращений к провайдерам: счёт создаётся в памяти за время delay
+type fakePayments struct {
+	store *memoryStore
+	delay latency
+}
+
+func (p *fakePayments) CreatePeriodPurchase(ctx context.Context, amount float64, period config.Period, customer *database.Customer, invoiceType database.InvoiceType, tariffName *string, deviceLimit *int, savePaymentMethod bool) (string, int64, error) {
+	p.delay.wait(ctx)
+	id := p.store.addPurchase(database.Purchase{
+		Amount:      amount,
+		CustomerID:  customer.ID,
+		CreatedAt:   time.Now(),
+		Month:       period.Months,
+		Status:      database.PurchaseStatusNew,
+		InvoiceType: invoiceType,
+		TariffName:  tariffName,
+		DeviceLimit: deviceLimit,
+	})
+	return fmt.Sprintf("https://pay.example.com/invoice/%d", id), id, nil
+}
+
+func (p *fakePayments) CreateSandboxPurchase(ctx context.Context, period config.Period, customer *database.Customer, tariffName *string, deviceLimit *int) (int64, error) {
+	_, id, err := p.CreatePeriodPurchase(ctx, 0, period, customer, database.InvoiceTypeSandbox, tariffName, deviceLimit, false)
+	return id, err
+}
+
+func (p *fakePayments) CreateTestYookasaPurchase(ctx context.Context, amount float64, period config.Period, customer *database.Customer, tariffName *string, deviceLimit *int) (string, int64, error) {
+	return p.CreatePeriodPurchase(ctx, amount, period, customer, database.InvoiceTypeYookasa, tariffName, deviceLimit, false)
+}
+
+func (p *fakePayments) CreateTeamPurchase(ctx context.Context, amount float64, months, seats int, customer *database.Customer, invoiceType database.InvoiceType) (string, int64, error) {
+	return p.CreatePeriodPurchase(ctx, amount, config.Period{Months: months}, customer, invoiceType, nil, nil, false)
+}
+
+func (p *fakePayments) CreateGiftPurchase(ctx context.Context, amount float64, period config.Period, payer, recipient *database.Customer, tariffName *string) (string, int64, error) {
+	return p.CreatePeriodPurchase(ctx, amount, period, recipient, database.InvoiceTypeTelegram, tariffName, nil, false)
+}
+
+func (p *fakePayments) ProcessPurchaseById(ctx context.Context, purchaseId int64) error {
+	p.delay.wait(ctx)
+	return nil
+}
+
+func (p *fakePayments) FulfillPurchase(ctx context.Context, purchaseID int64, source database.InvoiceType, payload []byte) error {
+	return p.ProcessPurchaseById(ctx, purchaseID)
+}
+
+func (p *fakePayments) SavePurchaseMessage(ctx context.Context, purchaseID int64, messageID int) {}
+
+func (p *fakePayments) SaveProviderPayment(ctx context.Context, purchaseID int64, providerPaymentID, methodType string) {
+}
+
+func (p *fakePayments) ActivateTrial(ctx context.Context, telegramId int64) (string, error) {
+	p.delay.wait(ctx)
+	return "https://sub.example.com/trial", nil
+}
+
+func (p *fakePayments) CheckTrialEligibility(ctx context.Context, customer *database.Customer) (payment.TrialEligibility, error) {
+	return payment.TrialEligibility{Allowed: customer.SubscriptionLink == nil}, nil
+}
+
+// fakeRepositories остальные зависимости обработчиков, которые в сценариях нагрузки не участвуют
+type fakeRepositories struct{}
+
+func (fakeRepositories) Create(ctx context.Context, referrerID, refereeID int64) (*database.Referral, error) {
+	return &database.Referral{ReferrerID: referrerID, RefereeID: refereeID}, nil
+}
+
+func (fakeRepositories) CountByReferrer(ctx context.Context, referrerID int64) (int, error) {
+	return 0, nil
+}
+
+func (fakeRepositories) GetUserByTelegramID(ctx context.Context, telegramID int64) (*remnawave.UserInfo, error) {
+	return nil, nil
+}
+
+func (fakeRepositories) ServerStatus(ctx context.Context) (*remnawave.ServerStatus, error) {
+	return &remnawave.ServerStatus{FetchedAt: time.Now()}, nil
+}
+
+func (fakeRepositories) FindByPurchaseID(ctx context.Context, purchaseID int64) (*database.Receipt, error) {
+	return nil, nil
+}
+
+func (fakeRepositories) Issue(ctx context.Context, purchaseID, customerID int64, prefix string) (*database.Receipt, error) {
+	return nil, fmt.Errorf("receipts are not supported in load test")
+}
+
+func (fakeRepositories) SetFileID(ctx context.Context, purchaseID int64, fileID string) error {
+	return nil
+}
+
+func (fakeRepositories) OptOut(ctx context.Context, customerID int64, at time.Time) error {
+	return nil
+}
+
+func (fakeRepositories) FindByCustomer(ctx context.Context, customerID int64) ([]database.PaymentMethod, error) {
+	return nil, nil
+}
+
+func (fakeRepositories) SelectForRecurring(ctx context.Context, customerID, id int64) (bool, error) {
+	return false, nil
+}
+
+func (fakeRepositories) Delete(ctx context.Context, customerID, id int64) (*database.PaymentMethod, bool, error) {
+	return nil, false, nil
+}
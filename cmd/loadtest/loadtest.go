@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/cache"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/handler"
+	"remnawave-tg-shop-bot/internal/logging"
+	"remnawave-tg-shop-bot/internal/translation"
+)
+
+const (
+	scenarioStart   = "start"
+	scenarioPricing = "pricing"
+	scenarioPayment = "payment"
+
+	// firstUserID Telegram ID первого синтетического пользователя, дальше подряд
+	firstUserID = int64(7_000_000_000)
+)
+
+type options struct {
+	requests        int
+	workers         int
+	users           int
+	dbLatency       time.Duration
+	telegramLatency time.Duration
+	providerLatency time.Duration
+	translations    string
+	budgets         map[string]time.Duration
+}
+
+// defaultEnv конфигурация бота для нагрузки: два тарифа и оплата криптой и ЮKassa. Переменные,
+// заданные в окружении, не перезаписываются — так можно проверить свою раскладку тарифов
+var defaultEnv = map[string]string{
+	"DISABLE_ENV_FILE":     "true",
+	"TELEGRAM_TOKEN":       "loadtest-token",
+	"ADMIN_TELEGRAM_ID":    "1",
+	"DATABASE_URL":         "postgres://unused",
+	"REMNAWAVE_URL":        "http://127.0.0.1:1",
+	"REMNAWAVE_TOKEN":      "loadtest-token",
+	"TRIAL_TRAFFIC_LIMIT":  "10",
+	"TRIAL_DAYS":           "3",
+	"TRAFFIC_LIMIT":        "100",
+	"REFERRAL_DAYS":        "7",
+	"DAYS_IN_MONTH":        "30",
+	"PRICE_1":              "150",
+	"PRICE_3":              "400",
+	"PRICE_6":              "750",
+	"PRICE_12":             "1400",
+	"YOOKASA_ENABLED":      "true",
+	"YOOKASA_URL":          "http://127.0.0.1:1",
+	"YOOKASA_SHOP_ID":      "shop",
+	"YOOKASA_SECRET_KEY":   "secret",
+	"YOOKASA_EMAIL":        "shop@example.com",
+	"CRYPTO_PAY_ENABLED":   "true",
+	"CRYPTO_PAY_URL":       "http://127.0.0.1:1",
+	"CRYPTO_PAY_TOKEN":     "token",
+	"TARIFF_BASE_ENABLED":  "true",
+	"TARIFF_BASE_DEVICES":  "1",
+	"TARIFF_BASE_PRICE_1":  "99",
+	"TARIFF_BASE_PRICE_3":  "249",
+	"TARIFF_BASE_PRICE_6":  "449",
+	"TARIFF_BASE_PRICE_12": "799",
+	"TARIFF_PRO_ENABLED":   "true",
+	"TARIFF_PRO_DEVICES":   "3",
+	"TARIFF_PRO_PRICE_1":   "199",
+	"TARIFF_PRO_PRICE_3":   "549",
+	"TARIFF_PRO_PRICE_6":   "999",
+	"TARIFF_PRO_PRICE_12":  "1799",
+}
+
+// harness бот с настоящими обработчиками покупки поверх фейковых зависимостей
+type harness struct {
+	bot      *bot.Bot
+	store    *memoryStore
+	tariff   string
+	updateID atomic.Int64
+}
+
+func newHarness(opts options) (*harness, func(), error) {
+	for key, value := range defaultEnv {
+		if _, ok := os.LookupEnv(key); !ok {
+			_ = os.Setenv(key, value)
+		}
+	}
+	if err := config.Load(); err != nil {
+		return nil, nil, err
+	}
+
+	tm := translation.GetInstance()
+	if err := tm.InitTranslations(opts.translations, config.DefaultLanguage()); err != nil {
+		return nil, nil, fmt.Errorf("load translations: %w", err)
+	}
+
+	srv := newFakeTelegram(opts.telegramLatency)
+
+	stateCache := cache.NewCache(time.Minute)
+	navigation := handler.NewNavigation(stateCache)
+	b, err := bot.New(config.TelegramToken(), bot.WithServerURL(srv.URL), bot.WithSkipGetMe(), bot.WithNotAsyncHandlers(),
+		bot.WithMiddlewares(logging.BotMiddleware, navigation.Middleware))
+	if err != nil {
+		srv.Close()
+		return nil, nil, err
+	}
+
+	store := newMemoryStore(opts.dbLatency)
+	payments := &fakePayments{store: store, delay: latency(opts.providerLatency)}
+	repos := fakeRepositories{}
+	paymentHandlers := handler.NewPaymentHandlers(tm, stateCache, store, purchasesFor{store}, payments, repos, repos, repos)
+	profile := handler.NewProfileHandlers(tm, stateCache, store, store, payments, repos, nil, repos, nil, nil, nil, repos, paymentHandlers)
+
+	// Маршруты покупки — так же, как они зарегистрированы в main
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/start", bot.MatchTypePrefix, profile.StartCommandHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackBuy, bot.MatchTypeExact, paymentHandlers.BuyCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTariff, bot.MatchTypePrefix, paymentHandlers.TariffCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackSell, bot.MatchTypePrefix, paymentHandlers.SellCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPayment, bot.MatchTypePrefix, paymentHandlers.PaymentCallbackHandler, profile.SuspiciousUserFilterMiddleware, profile.TosAcceptanceMiddleware)
+
+	h := &harness{bot: b, store: store}
+	if tariffs := config.GetTariffs(); len(tariffs) > 0 {
+		h.tariff = tariffs[len(tariffs)-1].Name
+	}
+	return h, srv.Close, nil
+}
+
+// send отправляет боту сообщение пользователя и ждёт окончания обработки
+func (h *harness) send(ctx context.Context, userID int64, text string) {
+	id := h.updateID.Add(1)
+	h.bot.ProcessUpdate(ctx, &models.Update{ID: id, Message: &models.Message{
+		ID:   int(id),
+		Text: text,
+		From: &models.User{ID: userID, LanguageCode: "ru"},
+		Chat: models.Chat{ID: userID},
+	}})
+}
+
+// click нажимает кнопку с callback data и ждёт окончания обработки
+func (h *harness) click(ctx context.Context, userID int64, data string) {
+	id := h.updateID.Add(1)
+	h.bot.ProcessUpdate(ctx, &models.Update{ID: id, CallbackQuery: &models.CallbackQuery{
+		ID:   strconv.FormatInt(id, 10),
+		Data: data,
+		From: models.User{ID: userID, LanguageCode: "ru"},
+		Message: models.MaybeInaccessibleMessage{
+			Type:    models.MaybeInaccessibleMessageTypeMessage,
+			Message: &models.Message{ID: int(userID % 100000), Chat: models.Chat{ID: userID}},
+		},
+	}})
+}
+
+// scenarios шаги сценариев в порядке прогона: к ценам и оплате пользователи приходят уже созданными через /start
+func (h *harness) scenarios() []scenario {
+	pricing := []string{handler.CallbackBuy}
+	sell := fmt.Sprintf("%s?month=1", handler.CallbackSell)
+	pay := fmt.Sprintf("%s?m=1&t=%s", handler.CallbackPayment, database.InvoiceTypeCrypto)
+	if h.tariff != "" {
+		pricing = append(pricing, fmt.Sprintf("%s?name=%s", handler.CallbackTariff, h.tariff))
+		sell += "&tariff=" + h.tariff
+		pay += "&n=" + h.tariff
+	}
+	pricing = append(pricing, sell)
+
+	return []scenario{
+		{name: scenarioStart, step: func(ctx context.Context, userID int64, i int) {
+			h.send(ctx, userID, "/start")
+		}},
+		{name: scenarioPricing, step: func(ctx context.Context, userID int64, i int) {
+			h.click(ctx, userID, pricing[i%len(pricing)])
+		}},
+		{name: scenarioPayment, step: func(ctx context.Context, userID int64, i int) {
+			h.click(ctx, userID, pay)
+		}},
+	}
+}
+
+type scenario struct {
+	name string
+	step func(ctx context.Context, userID int64, i int)
+}
+
+// result задержки обработки апдейтов одного сценария
+type result struct {
+	name      string
+	latencies []time.Duration
+	elapsed   time.Duration
+}
+
+// percentile задержка, которую не превышают p процентов апдейтов (nearest rank). latencies должны быть отсортированы
+func (r result) percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(r.latencies))+0.999999) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(r.latencies) {
+		rank = len(r.latencies) - 1
+	}
+	return r.latencies[rank]
+}
+
+// run прогоняет все сценарии по очереди, каждый — opts.requests апдейтов в opts.workers потоков
+func run(ctx context.Context, opts options) ([]result, error) {
+	if opts.requests < 1 || opts.workers < 1 || opts.users < 1 {
+		return nil, fmt.Errorf("requests, workers and users must be positive")
+	}
+	h, closeHarness, err := newHarness(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer closeHarness()
+
+	var results []result
+	for _, s := range h.scenarios() {
+		results = append(results, runScenario(ctx, s, opts))
+	}
+	return results, nil
+}
+
+func runScenario(ctx context.Context, s scenario, opts options) result {
+	latencies := make([]time.Duration, opts.requests)
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	started := time.Now()
+	for w := 0; w < opts.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= opts.requests {
+					return
+				}
+				userID := firstUserID + int64(i%opts.users)
+				stepStarted := time.Now()
+				s.step(ctx, userID, i)
+				latencies[i] = time.Since(stepStarted)
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return result{name: s.name, latencies: latencies, elapsed: time.Since(started)}
+}
+
+// report печатает таблицу задержек по сценариям и отметку о превышении бюджета p95
+func report(w io.Writer, results []result, budgets map[string]time.Duration) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "scenario\tupdates\trps\tp50\tp95\tp99\tmax\tbudget p95\t")
+	for _, r := range results {
+		rps := float64(len(r.latencies)) / r.elapsed.Seconds()
+		status := "-"
+		if budget := budgets[r.name]; budget > 0 {
+			status = "ok (" + budget.String() + ")"
+			if r.percentile(95) > budget {
+				status = "EXCEEDED (" + budget.String() + ")"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.0f\t%s\t%s\t%s\t%s\t%s\t\n", r.name, len(r.latencies), rps,
+			round(r.percentile(50)), round(r.percentile(95)), round(r.percentile(99)), round(r.percentile(100)), status)
+	}
+	_ = tw.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
+
+// errorCounter считает ошибки, которые обработчики пишут в лог: фейковые зависимости не должны их вызывать,
+// поэтому любая ошибка означает сломанный сценарий
+type errorCounter struct {
+	verbose bool
+	next    slog.Handler
+	errors  *atomic.Int64
+}
+
+func newErrorCounter(verbose bool, next slog.Handler) *errorCounter {
+	return &errorCounter{verbose: verbose, next: next, errors: &atomic.Int64{}}
+}
+
+func (c *errorCounter) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= slog.LevelError || (c.verbose && c.next.Enabled(ctx, level))
+}
+
+func (c *errorCounter) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelError {
+		c.errors.Add(1)
+	}
+	if c.verbose {
+		return c.next.Handle(ctx, r)
+	}
+	return nil
+}
+
+func (c *errorCounter) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &errorCounter{verbose: c.verbose, next: c.next.WithAttrs(attrs), errors: c.errors}
+}
+
+func (c *errorCounter) WithGroup(name string) slog.Handler {
+	return &errorCounter{verbose: c.verbose, next: c.next.WithGroup(name), errors: c.errors}
+}
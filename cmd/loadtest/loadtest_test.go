@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	r := result{}
+	for i := 1; i <= 100; i++ {
+		r.latencies = append(r.latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	for p, want := range map[float64]time.Duration{50: 50 * time.Millisecond, 95: 95 * time.Millisecond, 100: 100 * time.Millisecond, 0: time.Millisecond} {
+		if got := r.percentile(p); got != want {
+			t.Errorf("percentile(%v) = %v, want %v", p, got, want)
+		}
+	}
+	if got := (result{}).percentile(95); got != 0 {
+		t.Errorf("Expected zero percentile without samples, got %v", got)
+	}
+}
+
+// Прогон без задержек: каждый сценарий проходит без ошибок в логе, а каждое нажатие оплаты создаёт счёт
+func TestRunCreatesInvoices(t *testing.T) {
+	logs := newErrorCounter(true, slog.NewTextHandler(os.Stderr, nil))
+	previous := slog.Default()
+	slog.SetDefault(slog.New(logs))
+	defer slog.SetDefault(previous)
+
+	opts := options{requests: 60, workers: 4, users: 20, translations: "../../translations"}
+	h, closeHarness, err := newHarness(opts)
+	if err != nil {
+		t.Fatalf("newHarness() returned error: %v", err)
+	}
+	defer closeHarness()
+
+	var results []result
+	for _, s := range h.scenarios() {
+		results = append(results, runScenario(context.Background(), s, opts))
+	}
+
+	if n := logs.errors.Load(); n != 0 {
+		t.Errorf("Expected no handler errors, got %d", n)
+	}
+	if len(h.store.customers) != opts.users {
+		t.Errorf("Expected /start to create %d customers, got %d", opts.users, len(h.store.customers))
+	}
+	if len(h.store.purchases) != opts.requests {
+		t.Errorf("Expected %d invoices, got %d", opts.requests, len(h.store.purchases))
+	}
+
+	var out strings.Builder
+	report(&out, results, map[string]time.Duration{scenarioPayment: time.Nanosecond})
+	for _, name := range []string{scenarioStart, scenarioPricing, scenarioPayment} {
+		if !strings.Contains(out.String(), name) {
+			t.Errorf("Expected report to list scenario %q, got:\n%s", name, out.String())
+		}
+	}
+	if !strings.Contains(out.String(), "EXCEEDED") {
+		t.Errorf("Expected payment scenario to exceed a 1ns budget, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,64 @@
+// Команда loadtest прогоняет синтетические апдейты Telegram через настоящие обработчики бота
+// с фейковыми Telegram, базой и платёжными провайдерами и проверяет p95 задержки
+// стартового меню, экранов цен и создания платежа.
+//
+//	go run ./cmd/loadtest -requests 5000 -workers 50 -budget-payment 300ms
+//
+// Задержки фейков задаются флагами -db-latency, -telegram-latency и -provider-latency.
+// Если p95 какого-то сценария выше бюджета или обработчики писали ошибки в лог, команда завершается с кодом 1
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+func main() {
+	opts := options{}
+	flag.IntVar(&opts.requests, "requests", 2000, "updates per scenario")
+	flag.IntVar(&opts.workers, "workers", 20, "concurrent senders")
+	flag.IntVar(&opts.users, "users", 500, "distinct Telegram users; the first /start of each one creates the customer")
+	flag.DurationVar(&opts.dbLatency, "db-latency", time.Millisecond, "simulated latency of every repository call")
+	flag.DurationVar(&opts.telegramLatency, "telegram-latency", 10*time.Millisecond, "simulated latency of every Bot API call")
+	flag.DurationVar(&opts.providerLatency, "provider-latency", 50*time.Millisecond, "simulated latency of invoice creation at the payment provider")
+	flag.StringVar(&opts.translations, "translations", "translations", "path to the translations directory")
+	budgetStart := flag.Duration("budget-start", 250*time.Millisecond, "p95 budget of /start, 0 disables the check")
+	budgetPricing := flag.Duration("budget-pricing", 250*time.Millisecond, "p95 budget of the tariff and period screens, 0 disables the check")
+	budgetPayment := flag.Duration("budget-payment", 500*time.Millisecond, "p95 budget of invoice creation, 0 disables the check")
+	verbose := flag.Bool("v", false, "print handler logs")
+	flag.Parse()
+
+	opts.budgets = map[string]time.Duration{
+		scenarioStart:   *budgetStart,
+		scenarioPricing: *budgetPricing,
+		scenarioPayment: *budgetPayment,
+	}
+
+	logs := newErrorCounter(*verbose, slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	slog.SetDefault(slog.New(logs))
+
+	results, err := run(context.Background(), opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "loadtest:", err)
+		os.Exit(1)
+	}
+
+	report(os.Stdout, results, opts.budgets)
+	failed := false
+	for _, r := range results {
+		if budget := opts.budgets[r.name]; budget > 0 && r.percentile(95) > budget {
+			failed = true
+		}
+	}
+	if n := logs.errors.Load(); n > 0 {
+		fmt.Fprintf(os.Stdout, "\nhandlers logged %d errors, rerun with -v to see them\n", n)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_remnawave_webhook_event_status;
+DROP TABLE IF EXISTS remnawave_webhook_event;
@@ -0,0 +1,16 @@
+-- Журнал входящих Remnawave webhook событий для дедупликации и повторной обработки
+CREATE TABLE remnawave_webhook_event
+(
+    id              BIGSERIAL PRIMARY KEY,
+    event_id        VARCHAR(64) NOT NULL UNIQUE,   -- ключ дедупликации
+    event           VARCHAR(100) NOT NULL,         -- тип события (user.expired, ...)
+    payload         JSONB NOT NULL,                -- исходное тело запроса
+    status          VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    next_attempt_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    processed_at    TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_remnawave_webhook_event_status ON remnawave_webhook_event (status, next_attempt_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_recurring_charge_log_created_at;
+DROP TABLE IF EXISTS recurring_charge_log;
@@ -0,0 +1,13 @@
+-- Журнал попыток автосписания для статистики (ежедневная сводка админа)
+CREATE TABLE recurring_charge_log
+(
+    id          BIGSERIAL PRIMARY KEY,
+    customer_id BIGINT NOT NULL REFERENCES customer (id) ON DELETE CASCADE,
+    amount      INTEGER NOT NULL,
+    months      INTEGER NOT NULL,
+    status      VARCHAR(20) NOT NULL,   -- succeeded / failed
+    error       TEXT,
+    created_at  TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_recurring_charge_log_created_at ON recurring_charge_log (created_at);
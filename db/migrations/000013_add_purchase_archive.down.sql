@@ -0,0 +1,18 @@
+DROP INDEX IF EXISTS idx_purchase_customer_id;
+DROP INDEX IF EXISTS idx_purchase_created_at;
+DROP VIEW IF EXISTS purchase_history;
+
+-- Возвращаем архивные покупки в основную таблицу
+INSERT INTO purchase (id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+                      crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit)
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit
+FROM purchase_archive
+ON CONFLICT (id) DO NOTHING;
+
+DROP INDEX IF EXISTS idx_purchase_archive_paid_at;
+DROP INDEX IF EXISTS idx_purchase_archive_customer_id;
+DROP TABLE IF EXISTS purchase_archive;
+
+ALTER SEQUENCE broadcast_history_id_seq AS INTEGER;
+ALTER TABLE broadcast_history ALTER COLUMN id TYPE INTEGER;
@@ -0,0 +1,42 @@
+-- broadcast_history создавалась с SERIAL — переводим id на BIGINT, как в остальных таблицах
+ALTER TABLE broadcast_history ALTER COLUMN id TYPE BIGINT;
+ALTER SEQUENCE broadcast_history_id_seq AS BIGINT;
+
+-- Архив старых покупок: переносятся из purchase фоновой задачей, чтобы горячая таблица
+-- оставалась маленькой для частых запросов проверки инвойсов
+CREATE TABLE purchase_archive
+(
+    id                 BIGINT PRIMARY KEY,
+    amount             DECIMAL(20, 8) NOT NULL,
+    customer_id        BIGINT REFERENCES customer (id) ON DELETE CASCADE,
+    created_at         TIMESTAMP WITH TIME ZONE,
+    month              INTEGER        NOT NULL,
+    paid_at            TIMESTAMP WITH TIME ZONE,
+    currency           VARCHAR(10),
+    expire_at          TIMESTAMP WITH TIME ZONE,
+    status             VARCHAR(20),
+    invoice_type       VARCHAR(20),
+    crypto_invoice_id  BIGINT,
+    crypto_invoice_url TEXT,
+    yookasa_url        TEXT,
+    yookasa_id         uuid,
+    tariff_name        VARCHAR(50),
+    device_limit       INTEGER,
+    archived_at        TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_purchase_archive_customer_id ON purchase_archive (customer_id);
+CREATE INDEX idx_purchase_archive_paid_at ON purchase_archive (paid_at);
+
+-- Все покупки (актуальные и архивные) для статистики и проверок "была ли оплата"
+CREATE VIEW purchase_history AS
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit
+FROM purchase
+UNION ALL
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit
+FROM purchase_archive;
+
+CREATE INDEX idx_purchase_created_at ON purchase (created_at);
+CREATE INDEX idx_purchase_customer_id ON purchase (customer_id);
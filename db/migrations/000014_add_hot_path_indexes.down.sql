@@ -0,0 +1,5 @@
+create index IF NOT EXISTS idx_customer_telegram_id on customer using hash (telegram_id);
+DROP INDEX IF EXISTS idx_customer_expire_at;
+CREATE INDEX IF NOT EXISTS idx_purchase_customer_id ON purchase (customer_id);
+DROP INDEX IF EXISTS idx_purchase_customer_invoice_type_created_at;
+DROP INDEX IF EXISTS idx_purchase_invoice_type_status;
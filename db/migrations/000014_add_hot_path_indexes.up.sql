@@ -0,0 +1,13 @@
+-- Проверка инвойсов каждые 5 секунд: WHERE invoice_type = $1 AND status = $2
+CREATE INDEX IF NOT EXISTS idx_purchase_invoice_type_status ON purchase (invoice_type, status);
+
+-- Последняя покупка клиента по типу инвойса (Tribute, повторная оплата).
+-- Покрывает и поиск по одному customer_id, поэтому отдельный индекс больше не нужен
+CREATE INDEX IF NOT EXISTS idx_purchase_customer_invoice_type_created_at ON purchase (customer_id, invoice_type, created_at DESC);
+DROP INDEX IF EXISTS idx_purchase_customer_id;
+
+-- Уведомления об окончании подписки выбирают клиентов по диапазону expire_at
+CREATE INDEX IF NOT EXISTS idx_customer_expire_at ON customer (expire_at);
+
+-- Поиск по telegram_id покрыт уникальным индексом customer_telegram_id_unique, hash индекс лишний
+DROP INDEX IF EXISTS idx_customer_telegram_id;
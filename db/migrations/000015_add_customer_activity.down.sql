@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_funnel_event_created_at;
+DROP TABLE IF EXISTS funnel_event;
+DROP INDEX IF EXISTS idx_customer_last_active_at;
+ALTER TABLE customer DROP COLUMN IF EXISTS last_active_at;
@@ -0,0 +1,14 @@
+-- Время последнего взаимодействия пользователя с ботом (обновляется не чаще раза в несколько минут)
+ALTER TABLE customer ADD COLUMN last_active_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX idx_customer_last_active_at ON customer (last_active_at);
+
+-- События воронки продаж: start → viewed_prices → selected_payment → paid
+CREATE TABLE funnel_event
+(
+    id          BIGSERIAL PRIMARY KEY,
+    telegram_id BIGINT      NOT NULL,
+    event       VARCHAR(32) NOT NULL,
+    created_at  TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_funnel_event_created_at ON funnel_event (created_at, event);
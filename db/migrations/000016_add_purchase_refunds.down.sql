@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_purchase_yookasa_id;
+ALTER TABLE purchase_archive DROP COLUMN IF EXISTS refunded_at;
+ALTER TABLE purchase_archive DROP COLUMN IF EXISTS refunded_amount;
+ALTER TABLE purchase DROP COLUMN IF EXISTS refunded_at;
+ALTER TABLE purchase DROP COLUMN IF EXISTS refunded_amount;
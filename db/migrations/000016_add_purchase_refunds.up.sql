@@ -0,0 +1,9 @@
+-- Возвраты по покупкам (в том числе частичные и chargeback): общая сумма возвратов и время последнего
+ALTER TABLE purchase ADD COLUMN refunded_amount DECIMAL(20, 8) NOT NULL DEFAULT 0;
+ALTER TABLE purchase ADD COLUMN refunded_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE purchase_archive ADD COLUMN refunded_amount DECIMAL(20, 8) NOT NULL DEFAULT 0;
+ALTER TABLE purchase_archive ADD COLUMN refunded_at TIMESTAMP WITH TIME ZONE;
+
+-- Поиск покупки по платежу ЮKassa при получении уведомления о возврате
+CREATE INDEX idx_purchase_yookasa_id ON purchase (yookasa_id);
@@ -0,0 +1,2 @@
+ALTER TABLE purchase_archive DROP COLUMN IF EXISTS message_id;
+ALTER TABLE purchase DROP COLUMN IF EXISTS message_id;
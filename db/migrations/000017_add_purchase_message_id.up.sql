@@ -0,0 +1,4 @@
+-- Сообщение со ссылкой на оплату: при истечении счёта оно заменяется на "счёт истёк".
+-- Раньше ID хранился только в in-memory кеше и терялся при перезапуске
+ALTER TABLE purchase ADD COLUMN message_id INTEGER;
+ALTER TABLE purchase_archive ADD COLUMN message_id INTEGER;
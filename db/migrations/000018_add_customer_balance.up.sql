@@ -0,0 +1,2 @@
+-- Внутренний баланс клиента в рублях: расходуется на автопродление до списания с карты
+ALTER TABLE customer ADD COLUMN balance INTEGER NOT NULL DEFAULT 0 CHECK (balance >= 0);
@@ -0,0 +1,15 @@
+DROP VIEW IF EXISTS purchase_history;
+CREATE VIEW purchase_history AS
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit
+FROM purchase
+UNION ALL
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit
+FROM purchase_archive;
+
+DROP INDEX IF EXISTS idx_customer_source;
+
+ALTER TABLE purchase_archive DROP COLUMN IF EXISTS source;
+ALTER TABLE purchase DROP COLUMN IF EXISTS source;
+ALTER TABLE customer DROP COLUMN IF EXISTS source;
@@ -0,0 +1,18 @@
+-- Рекламный источник (UTM), с которым пользователь впервые пришёл в бота через /start utm_<source>.
+-- Для приглашённых по реферальной ссылке — 'referral'. Покупки наследуют источник клиента на момент создания
+ALTER TABLE customer ADD COLUMN source VARCHAR(64);
+ALTER TABLE purchase ADD COLUMN source VARCHAR(64);
+ALTER TABLE purchase_archive ADD COLUMN source VARCHAR(64);
+
+CREATE INDEX idx_customer_source ON customer (source) WHERE source IS NOT NULL;
+
+-- Пересоздаём представление, чтобы статистика по источникам учитывала архивные покупки
+DROP VIEW purchase_history;
+CREATE VIEW purchase_history AS
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit, source
+FROM purchase
+UNION ALL
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit, source
+FROM purchase_archive;
@@ -0,0 +1,2 @@
+ALTER TABLE customer DROP COLUMN IF EXISTS tos_accepted_at;
+ALTER TABLE customer DROP COLUMN IF EXISTS tos_accepted_version;
@@ -0,0 +1,3 @@
+-- Принятие условий использования: версия TOS_VERSION и время, когда пользователь её принял
+ALTER TABLE customer ADD COLUMN tos_accepted_version VARCHAR(32);
+ALTER TABLE customer ADD COLUMN tos_accepted_at TIMESTAMP WITH TIME ZONE;
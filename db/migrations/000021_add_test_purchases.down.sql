@@ -0,0 +1,12 @@
+DROP VIEW IF EXISTS purchase_history;
+CREATE VIEW purchase_history AS
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit, source
+FROM purchase
+UNION ALL
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit, source
+FROM purchase_archive;
+
+ALTER TABLE purchase_archive DROP COLUMN IF EXISTS is_test;
+ALTER TABLE purchase DROP COLUMN IF EXISTS is_test;
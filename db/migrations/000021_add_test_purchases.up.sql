@@ -0,0 +1,13 @@
+-- Тестовые покупки админа (SANDBOX_PAYMENTS_ENABLED): выдаются как обычные, но не попадают в статистику и сверку
+ALTER TABLE purchase ADD COLUMN is_test BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE purchase_archive ADD COLUMN is_test BOOLEAN NOT NULL DEFAULT FALSE;
+
+DROP VIEW purchase_history;
+CREATE VIEW purchase_history AS
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit, source, is_test
+FROM purchase
+UNION ALL
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit, source, is_test
+FROM purchase_archive;
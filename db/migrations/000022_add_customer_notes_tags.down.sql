@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_admin_audit_log_customer_id;
+DROP TABLE IF EXISTS admin_audit_log;
+DROP TABLE IF EXISTS customer_note;
+DROP INDEX IF EXISTS idx_customer_tag_tag;
+DROP TABLE IF EXISTS customer_tag;
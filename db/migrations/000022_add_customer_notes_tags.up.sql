@@ -0,0 +1,31 @@
+-- Заметки и теги поддержки по клиенту (VIP, chargeback_risk, свободная заметка)
+CREATE TABLE customer_tag
+(
+    customer_id BIGINT      NOT NULL REFERENCES customer (id) ON DELETE CASCADE,
+    tag         VARCHAR(32) NOT NULL,
+    created_at  TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (customer_id, tag)
+);
+
+CREATE INDEX idx_customer_tag_tag ON customer_tag (tag);
+
+CREATE TABLE customer_note
+(
+    customer_id BIGINT PRIMARY KEY REFERENCES customer (id) ON DELETE CASCADE,
+    note        TEXT   NOT NULL,
+    updated_by  BIGINT NOT NULL,
+    updated_at  TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Журнал действий админа над клиентами
+CREATE TABLE admin_audit_log
+(
+    id          BIGSERIAL PRIMARY KEY,
+    admin_id    BIGINT      NOT NULL,
+    customer_id BIGINT REFERENCES customer (id) ON DELETE SET NULL,
+    action      VARCHAR(50) NOT NULL,
+    details     TEXT,
+    created_at  TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_admin_audit_log_customer_id ON admin_audit_log (customer_id, created_at);
@@ -0,0 +1,8 @@
+-- Переопределения фич из админки. Если записи нет, действует значение из переменной окружения
+CREATE TABLE feature_flag
+(
+    name       VARCHAR(64) PRIMARY KEY,
+    enabled    BOOLEAN     NOT NULL,
+    updated_by BIGINT      NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,8 @@
+-- Подтверждённые номера телефонов для триала. Хранится только HMAC номера, уникальность
+-- не даёт активировать пробный период с одного номера на разных аккаунтах
+CREATE TABLE customer_phone
+(
+    customer_id BIGINT PRIMARY KEY REFERENCES customer (id) ON DELETE CASCADE,
+    phone_hash  VARCHAR(64) NOT NULL UNIQUE,
+    verified_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
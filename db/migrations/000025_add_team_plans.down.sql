@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS team_seat;
+DROP TABLE IF EXISTS team;
+ALTER TABLE purchase_archive DROP COLUMN IF EXISTS team_seats;
+ALTER TABLE purchase DROP COLUMN IF EXISTS team_seats;
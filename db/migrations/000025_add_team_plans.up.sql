@@ -0,0 +1,29 @@
+-- Командные подписки: плательщик покупает несколько мест, каждое место активируется по своей ссылке-приглашению
+ALTER TABLE purchase ADD COLUMN team_seats INTEGER;
+ALTER TABLE purchase_archive ADD COLUMN team_seats INTEGER;
+
+CREATE TABLE team
+(
+    id                BIGSERIAL PRIMARY KEY,
+    owner_customer_id BIGINT                   NOT NULL REFERENCES customer (id) ON DELETE CASCADE,
+    purchase_id       BIGINT                   NOT NULL UNIQUE,
+    seats             INTEGER                  NOT NULL,
+    months            INTEGER                  NOT NULL,
+    expires_at        TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at        TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_team_owner_customer_id ON team (owner_customer_id);
+
+-- Место в команде. Код приглашения меняется при отзыве места, чтобы старая ссылка перестала работать
+CREATE TABLE team_seat
+(
+    id                 BIGSERIAL PRIMARY KEY,
+    team_id            BIGINT      NOT NULL REFERENCES team (id) ON DELETE CASCADE,
+    code               VARCHAR(32) NOT NULL UNIQUE,
+    member_customer_id BIGINT REFERENCES customer (id) ON DELETE SET NULL,
+    redeemed_at        TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_team_seat_team_id ON team_seat (team_id);
+CREATE UNIQUE INDEX idx_team_seat_member ON team_seat (team_id, member_customer_id) WHERE member_customer_id IS NOT NULL;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS winback_send;
+
+ALTER TABLE customer DROP COLUMN IF EXISTS winback_opted_out_at;
+ALTER TABLE customer DROP COLUMN IF EXISTS winback_offer_campaign;
+ALTER TABLE customer DROP COLUMN IF EXISTS winback_offer_tariff;
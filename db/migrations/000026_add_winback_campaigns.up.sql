@@ -0,0 +1,20 @@
+-- Winback для ушедших платящих клиентов: предложение продлить прежний тариф со скидкой
+ALTER TABLE customer ADD COLUMN winback_offer_tariff VARCHAR(64);
+ALTER TABLE customer ADD COLUMN winback_offer_campaign VARCHAR(32);
+ALTER TABLE customer ADD COLUMN winback_opted_out_at TIMESTAMP WITH TIME ZONE;
+
+-- Отправленные предложения. Одно предложение кампании на один уход клиента (expire_at на момент отправки)
+CREATE TABLE winback_send
+(
+    id           BIGSERIAL PRIMARY KEY,
+    campaign     VARCHAR(32)              NOT NULL,
+    customer_id  BIGINT                   NOT NULL REFERENCES customer (id) ON DELETE CASCADE,
+    expire_at    TIMESTAMP WITH TIME ZONE NOT NULL,
+    sent_at      TIMESTAMP WITH TIME ZONE NOT NULL,
+    purchase_id  BIGINT,
+    converted_at TIMESTAMP WITH TIME ZONE,
+    opted_out_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (campaign, customer_id, expire_at)
+);
+
+CREATE INDEX idx_winback_send_customer_id ON winback_send (customer_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS purchase_receipt;
+DROP SEQUENCE IF EXISTS purchase_receipt_number_seq;
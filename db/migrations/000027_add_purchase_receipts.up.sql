@@ -0,0 +1,14 @@
+-- Квитанции об оплате. Номер выдаётся один раз при первом запросе, file_id документа в Telegram
+-- сохраняется, чтобы повторно отправлять тот же документ без генерации
+CREATE SEQUENCE purchase_receipt_number_seq;
+
+CREATE TABLE purchase_receipt
+(
+    purchase_id BIGINT PRIMARY KEY,
+    customer_id BIGINT                   NOT NULL REFERENCES customer (id) ON DELETE CASCADE,
+    number      VARCHAR(32)              NOT NULL UNIQUE,
+    file_id     VARCHAR(255),
+    issued_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_purchase_receipt_customer_id ON purchase_receipt (customer_id);
@@ -0,0 +1,10 @@
+-- Напоминания о неоплаченных счетах. Одно напоминание на покупку, по таблице считается,
+-- сколько напоминаний закончилось оплатой
+CREATE TABLE checkout_reminder
+(
+    purchase_id BIGINT PRIMARY KEY,
+    customer_id BIGINT                   NOT NULL REFERENCES customer (id) ON DELETE CASCADE,
+    sent_at     TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_checkout_reminder_sent_at ON checkout_reminder (sent_at);
@@ -0,0 +1,11 @@
+-- Перевыпуски ссылки подписки. Самостоятельные смены (admin_id IS NULL) ограничены лимитом на 30 дней,
+-- принудительные смены админом в лимит не входят
+CREATE TABLE subscription_rotation
+(
+    id          BIGSERIAL PRIMARY KEY,
+    customer_id BIGINT                   NOT NULL REFERENCES customer (id) ON DELETE CASCADE,
+    admin_id    BIGINT,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_subscription_rotation_customer ON subscription_rotation (customer_id, created_at);
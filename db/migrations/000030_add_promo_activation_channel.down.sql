@@ -0,0 +1,2 @@
+ALTER TABLE promo_tariff_activation DROP COLUMN IF EXISTS channel;
+ALTER TABLE promo_code_activation DROP COLUMN IF EXISTS channel;
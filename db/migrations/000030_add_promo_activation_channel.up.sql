@@ -0,0 +1,4 @@
+-- Канал, через который распространялась ссылка с промокодом (/start promo_<код>__<канал>).
+-- NULL — промокод введён вручную
+ALTER TABLE promo_code_activation ADD COLUMN channel VARCHAR(64);
+ALTER TABLE promo_tariff_activation ADD COLUMN channel VARCHAR(64);
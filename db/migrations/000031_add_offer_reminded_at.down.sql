@@ -0,0 +1,3 @@
+ALTER TABLE customer
+    DROP COLUMN IF EXISTS promo_offer_reminded_at,
+    DROP COLUMN IF EXISTS winback_offer_reminded_at;
@@ -0,0 +1,5 @@
+-- Напоминания о скором окончании предложений. Отметка сбрасывается при выдаче нового предложения,
+-- поэтому по каждому предложению приходит не больше одного напоминания
+ALTER TABLE customer
+    ADD COLUMN promo_offer_reminded_at   TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN winback_offer_reminded_at TIMESTAMP WITH TIME ZONE;
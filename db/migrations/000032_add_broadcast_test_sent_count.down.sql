@@ -0,0 +1 @@
+ALTER TABLE broadcast_history DROP COLUMN IF EXISTS test_sent_count;
@@ -0,0 +1,2 @@
+-- Тестовые отправки рассылки админу самому себе. Считаются отдельно и не входят в sent_count
+ALTER TABLE broadcast_history ADD COLUMN test_sent_count INT NOT NULL DEFAULT 0;
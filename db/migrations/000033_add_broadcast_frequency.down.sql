@@ -0,0 +1,6 @@
+ALTER TABLE broadcast_history DROP COLUMN IF EXISTS excluded_count;
+
+ALTER TABLE customer
+    DROP COLUMN IF EXISTS last_broadcast_at,
+    DROP COLUMN IF EXISTS broadcast_window_started_at,
+    DROP COLUMN IF EXISTS broadcast_window_count;
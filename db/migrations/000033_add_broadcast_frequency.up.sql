@@ -0,0 +1,9 @@
+-- Защита от усталости от рассылок: когда клиент последний раз получил рассылку и сколько рассылок
+-- он получил в текущем окне лимита частоты (BROADCAST_FREQUENCY_CAP за BROADCAST_FREQUENCY_WINDOW_DAYS дней)
+ALTER TABLE customer
+    ADD COLUMN last_broadcast_at           TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN broadcast_window_started_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN broadcast_window_count      INT NOT NULL DEFAULT 0;
+
+-- Сколько клиентов аудитории не получили рассылку из-за исключений и лимита частоты
+ALTER TABLE broadcast_history ADD COLUMN excluded_count INT NOT NULL DEFAULT 0;
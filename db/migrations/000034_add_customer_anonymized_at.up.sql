@@ -0,0 +1,3 @@
+-- Обезличивание по запросу клиента: персональные данные удаляются, покупки остаются для отчётности.
+-- telegram_id обезличенного клиента заменяется на -id, чтобы при повторном /start создавался новый клиент
+ALTER TABLE customer ADD COLUMN anonymized_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,2 @@
+ALTER TABLE customer DROP COLUMN IF EXISTS trial_regranted_at;
+ALTER TABLE customer DROP COLUMN IF EXISTS trial_activated_at;
@@ -0,0 +1,7 @@
+-- Политика повторного триала: когда клиент последний раз брал триал и разрешил ли админ выдать его повторно.
+-- Раньше триал считался использованным, если у клиента есть ссылка подписки, поэтому для старых клиентов
+-- с подпиской время триала берётся из даты регистрации
+ALTER TABLE customer ADD COLUMN trial_activated_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE customer ADD COLUMN trial_regranted_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE customer SET trial_activated_at = created_at WHERE subscription_link IS NOT NULL;
@@ -0,0 +1,20 @@
+-- Сохранённые способы оплаты ЮKassa. У клиента может быть несколько карт;
+-- customer.payment_method_id указывает на ту, с которой списывается автопродление
+CREATE TABLE payment_method
+(
+    id          BIGSERIAL PRIMARY KEY,
+    customer_id BIGINT                   NOT NULL REFERENCES customer (id) ON DELETE CASCADE,
+    method_id   VARCHAR(64)              NOT NULL,
+    card_type   VARCHAR(32),
+    card_last4  VARCHAR(4),
+    title       VARCHAR(255),
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (customer_id, method_id)
+);
+
+-- Переносим единственную карту из customer. Данные карты для них неизвестны
+-- и заполнятся при следующей оплате этой картой
+INSERT INTO payment_method (customer_id, method_id)
+SELECT id, payment_method_id
+FROM customer
+WHERE payment_method_id IS NOT NULL;
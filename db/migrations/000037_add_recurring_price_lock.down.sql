@@ -0,0 +1,6 @@
+ALTER TABLE customer DROP COLUMN IF EXISTS recurring_pending_consented_at;
+ALTER TABLE customer DROP COLUMN IF EXISTS recurring_pending_effective_at;
+ALTER TABLE customer DROP COLUMN IF EXISTS recurring_pending_amount;
+ALTER TABLE customer DROP COLUMN IF EXISTS recurring_locked_at;
+ALTER TABLE customer DROP COLUMN IF EXISTS recurring_device_limit;
+ALTER TABLE customer DROP COLUMN IF EXISTS recurring_list_price;
@@ -0,0 +1,16 @@
+-- Фиксация цены автопродления. recurring_amount — зафиксированная сумма списания,
+-- recurring_list_price и recurring_device_limit — цена и лимит устройств тарифа на момент подключения.
+-- Изменение цены админом планируется в recurring_pending_*: уменьшение применяется автоматически,
+-- повышение — только после согласия клиента
+ALTER TABLE customer ADD COLUMN recurring_list_price INT;
+ALTER TABLE customer ADD COLUMN recurring_device_limit INT;
+ALTER TABLE customer ADD COLUMN recurring_locked_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE customer ADD COLUMN recurring_pending_amount INT;
+ALTER TABLE customer ADD COLUMN recurring_pending_effective_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE customer ADD COLUMN recurring_pending_consented_at TIMESTAMP WITH TIME ZONE;
+
+-- Для подключённых раньше цена тарифа на момент подключения неизвестна, считаем ей сумму списания
+UPDATE customer
+SET recurring_list_price = recurring_amount,
+    recurring_locked_at  = CURRENT_TIMESTAMP
+WHERE recurring_amount IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS notification_outbox;
+
+ALTER TABLE customer DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,21 @@
+-- Часовой пояс клиента (IANA, например Europe/Moscow). NULL — часовой пояс бота (TIMEZONE)
+ALTER TABLE customer ADD COLUMN timezone TEXT;
+
+-- Очередь уведомлений, отложенных до разрешённых часов по местному времени клиента (NOTIFY_LOCAL_HOURS)
+CREATE TABLE notification_outbox
+(
+    id           BIGSERIAL PRIMARY KEY,
+    customer_id  BIGINT                   NOT NULL REFERENCES customer (id) ON DELETE CASCADE,
+    kind         TEXT                     NOT NULL,
+    chat_id      BIGINT                   NOT NULL,
+    text         TEXT                     NOT NULL,
+    parse_mode   TEXT                     NOT NULL DEFAULT '',
+    reply_markup JSONB,
+    send_after   TIMESTAMP WITH TIME ZONE NOT NULL,
+    sent_at      TIMESTAMP WITH TIME ZONE,
+    attempts     INT                      NOT NULL DEFAULT 0,
+    last_error   TEXT,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_notification_outbox_pending ON notification_outbox (send_after) WHERE sent_at IS NULL;
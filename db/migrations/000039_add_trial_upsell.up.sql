@@ -0,0 +1,11 @@
+-- Сообщения цепочки после активации триала. Каждый шаг отправляется один раз за триал
+CREATE TABLE trial_upsell_send
+(
+    step               TEXT                     NOT NULL,
+    customer_id        BIGINT                   NOT NULL REFERENCES customer (id) ON DELETE CASCADE,
+    trial_activated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    sent_at            TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (step, customer_id, trial_activated_at)
+);
+
+CREATE INDEX idx_trial_upsell_send_customer_id ON trial_upsell_send (customer_id);
@@ -0,0 +1,16 @@
+DROP VIEW IF EXISTS purchase_history;
+CREATE VIEW purchase_history AS
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit, source, is_test
+FROM purchase
+UNION ALL
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit, source, is_test
+FROM purchase_archive;
+
+ALTER TABLE purchase_archive DROP COLUMN IF EXISTS payment_method_type;
+ALTER TABLE purchase_archive DROP COLUMN IF EXISTS provider_payment_id;
+ALTER TABLE purchase_archive DROP COLUMN IF EXISTS provisioned_device_limit;
+ALTER TABLE purchase DROP COLUMN IF EXISTS payment_method_type;
+ALTER TABLE purchase DROP COLUMN IF EXISTS provider_payment_id;
+ALTER TABLE purchase DROP COLUMN IF EXISTS provisioned_device_limit;
@@ -0,0 +1,31 @@
+-- Атрибуция покупок для отчётов, не зависящих от текущих настроек тарифов:
+-- provisioned_device_limit — лимит устройств тарифа или предложения на момент создания счёта,
+-- provider_payment_id — идентификатор платежа у провайдера (ЮKassa, CryptoPay, Telegram, Tribute),
+-- payment_method_type — способ оплаты по данным провайдера (bank_card, sbp, ...)
+ALTER TABLE purchase ADD COLUMN provisioned_device_limit INTEGER;
+ALTER TABLE purchase ADD COLUMN provider_payment_id TEXT;
+ALTER TABLE purchase ADD COLUMN payment_method_type TEXT;
+ALTER TABLE purchase_archive ADD COLUMN provisioned_device_limit INTEGER;
+ALTER TABLE purchase_archive ADD COLUMN provider_payment_id TEXT;
+ALTER TABLE purchase_archive ADD COLUMN payment_method_type TEXT;
+
+-- Заполняем то, что можно восстановить из самих покупок. Лимит тарифа без явного device_limit
+-- хранится только в настройках бота и для старых покупок остаётся пустым
+UPDATE purchase SET provisioned_device_limit = device_limit WHERE device_limit IS NOT NULL;
+UPDATE purchase SET provider_payment_id = COALESCE(yookasa_id::TEXT, crypto_invoice_id::TEXT)
+WHERE yookasa_id IS NOT NULL OR crypto_invoice_id IS NOT NULL;
+UPDATE purchase_archive SET provisioned_device_limit = device_limit WHERE device_limit IS NOT NULL;
+UPDATE purchase_archive SET provider_payment_id = COALESCE(yookasa_id::TEXT, crypto_invoice_id::TEXT)
+WHERE yookasa_id IS NOT NULL OR crypto_invoice_id IS NOT NULL;
+
+DROP VIEW purchase_history;
+CREATE VIEW purchase_history AS
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit, source, is_test,
+       provisioned_device_limit, provider_payment_id, payment_method_type
+FROM purchase
+UNION ALL
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit, source, is_test,
+       provisioned_device_limit, provider_payment_id, payment_method_type
+FROM purchase_archive;
@@ -0,0 +1,18 @@
+-- Команды с постоянным ответом, которые админ добавляет в панели (/speed, /apps, /faq)
+CREATE TABLE custom_command
+(
+    id             BIGSERIAL PRIMARY KEY,
+    command        TEXT                     NOT NULL UNIQUE,
+    description_ru TEXT                     NOT NULL DEFAULT '',
+    description_en TEXT                     NOT NULL DEFAULT '',
+    text_ru        TEXT                     NOT NULL DEFAULT '',
+    text_en        TEXT                     NOT NULL DEFAULT '',
+    media_type     TEXT                     NOT NULL DEFAULT '',
+    media_file_id  TEXT                     NOT NULL DEFAULT '',
+    -- Кнопки-ссылки, по одной на строку: "Текст | https://..."
+    buttons        TEXT                     NOT NULL DEFAULT '',
+    is_active      BOOLEAN                  NOT NULL DEFAULT TRUE,
+    created_by     BIGINT,
+    created_at     TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at     TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,15 @@
+-- Вопросы и ответы раздела FAQ. Вопросы заводятся отдельно для каждого языка
+CREATE TABLE faq_entry
+(
+    id         BIGSERIAL PRIMARY KEY,
+    language   TEXT                     NOT NULL,
+    category   TEXT                     NOT NULL,
+    question   TEXT                     NOT NULL,
+    answer     TEXT                     NOT NULL,
+    is_active  BOOLEAN                  NOT NULL DEFAULT TRUE,
+    created_by BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_faq_entry_language_category ON faq_entry (language, category) WHERE is_active;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_customer_remnawave_tag;
+ALTER TABLE customer DROP COLUMN IF EXISTS remnawave_tag;
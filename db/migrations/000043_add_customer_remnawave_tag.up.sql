@@ -0,0 +1,4 @@
+-- Тег пользователя в панели, выставленный ботом при синхронизации тегов: по нему сегментируются рассылки
+ALTER TABLE customer ADD COLUMN remnawave_tag VARCHAR(16);
+
+CREATE INDEX idx_customer_remnawave_tag ON customer (remnawave_tag) WHERE remnawave_tag IS NOT NULL;
@@ -0,0 +1,16 @@
+-- Массовое отключение автопродления из админки. Пока не истёк undo_until, операцию можно отменить,
+-- клиенты получают уведомление только после этого срока
+CREATE TABLE recurring_bulk_disable
+(
+    id           BIGSERIAL PRIMARY KEY,
+    tariff_name  TEXT,
+    customer_ids BIGINT[]                 NOT NULL,
+    created_by   BIGINT,
+    created_at   TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    undo_until   TIMESTAMP WITH TIME ZONE NOT NULL,
+    undone_at    TIMESTAMP WITH TIME ZONE,
+    notified_at  TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_recurring_bulk_disable_pending ON recurring_bulk_disable (undo_until)
+    WHERE undone_at IS NULL AND notified_at IS NULL;
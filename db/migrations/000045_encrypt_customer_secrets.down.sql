@@ -0,0 +1,3 @@
+-- Откат возможен, только пока значения не зашифрованы (FIELD_ENCRYPTION_KEYS не задавался)
+ALTER TABLE payment_method ALTER COLUMN method_id TYPE VARCHAR(64);
+ALTER TABLE customer ALTER COLUMN payment_method_id TYPE UUID USING payment_method_id::uuid;
@@ -0,0 +1,4 @@
+-- Ссылки подписки и способы оплаты шифруются приложением (FIELD_ENCRYPTION_KEYS): значения вида
+-- enc:v1:<ключ>:<base64> не помещаются в UUID и VARCHAR(64). Существующие строки шифрует задача field_encryption
+ALTER TABLE customer ALTER COLUMN payment_method_id TYPE TEXT USING payment_method_id::text;
+ALTER TABLE payment_method ALTER COLUMN method_id TYPE TEXT;
@@ -0,0 +1,2 @@
+-- Email клиента для чеков ЮKassa (хранится зашифрованным, если задан FIELD_ENCRYPTION_KEYS). NULL — чек уходит на YOOKASA_EMAIL
+ALTER TABLE customer ADD COLUMN email TEXT;
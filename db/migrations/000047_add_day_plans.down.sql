@@ -0,0 +1,15 @@
+DROP VIEW IF EXISTS purchase_history;
+CREATE VIEW purchase_history AS
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit, source, is_test,
+       provisioned_device_limit, provider_payment_id, payment_method_type
+FROM purchase
+UNION ALL
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit, source, is_test,
+       provisioned_device_limit, provider_payment_id, payment_method_type
+FROM purchase_archive;
+
+ALTER TABLE customer DROP COLUMN IF EXISTS recurring_days;
+ALTER TABLE purchase_archive DROP COLUMN IF EXISTS period_days;
+ALTER TABLE purchase DROP COLUMN IF EXISTS period_days;
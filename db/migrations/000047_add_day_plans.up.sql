@@ -0,0 +1,19 @@
+-- Посуточные планы тарифов (например, недельная подписка): срок покупки в днях.
+-- У такой покупки month = 0, у покупки на месяцы period_days пустой
+ALTER TABLE purchase ADD COLUMN period_days INTEGER;
+ALTER TABLE purchase_archive ADD COLUMN period_days INTEGER;
+
+-- Автопродление посуточного плана: срок продления в днях вместо recurring_months
+ALTER TABLE customer ADD COLUMN recurring_days INTEGER;
+
+DROP VIEW purchase_history;
+CREATE VIEW purchase_history AS
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit, source, is_test,
+       provisioned_device_limit, provider_payment_id, payment_method_type, period_days
+FROM purchase
+UNION ALL
+SELECT id, amount, customer_id, created_at, month, paid_at, currency, expire_at, status, invoice_type,
+       crypto_invoice_id, crypto_invoice_url, yookasa_url, yookasa_id, tariff_name, device_limit, source, is_test,
+       provisioned_device_limit, provider_payment_id, payment_method_type, period_days
+FROM purchase_archive;
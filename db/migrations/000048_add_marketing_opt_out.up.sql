@@ -0,0 +1,3 @@
+-- Отказ клиента от маркетинговых сообщений: рассылок и предложений в триале. NULL — сообщения получает.
+-- Транзакционные уведомления (оплата, окончание подписки) приходят всегда
+ALTER TABLE customer ADD COLUMN marketing_opted_out_at TIMESTAMP WITH TIME ZONE;
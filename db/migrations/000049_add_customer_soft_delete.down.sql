@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_customer_deleted_at;
+ALTER TABLE customer DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,4 @@
+-- Клиенты, пропавшие из панели, помечаются удалёнными вместо удаления: история покупок сохраняется,
+-- а при появлении в панели клиент восстанавливается. Окончательно удаляются админом после срока хранения
+ALTER TABLE customer ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX idx_customer_deleted_at ON customer (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE purchase_archive DROP COLUMN IF EXISTS gift_from_customer_id;
+ALTER TABLE purchase DROP COLUMN IF EXISTS gift_from_customer_id;
@@ -0,0 +1,3 @@
+-- Подарочные покупки: подписка оформляется на получателя, а здесь сохраняется клиент, который её оплатил
+ALTER TABLE purchase ADD COLUMN gift_from_customer_id BIGINT REFERENCES customer (id) ON DELETE SET NULL;
+ALTER TABLE purchase_archive ADD COLUMN gift_from_customer_id BIGINT;
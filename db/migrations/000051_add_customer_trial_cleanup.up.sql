@@ -0,0 +1,2 @@
+-- Момент, когда пользователь панели с истёкшим триалом был отключён или удалён задачей trial_cleanup
+ALTER TABLE customer ADD COLUMN trial_cleaned_at TIMESTAMP WITH TIME ZONE;
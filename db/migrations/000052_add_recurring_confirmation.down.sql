@@ -0,0 +1,2 @@
+ALTER TABLE customer DROP COLUMN IF EXISTS recurring_confirmed_at;
+ALTER TABLE customer DROP COLUMN IF EXISTS recurring_confirm_requested_at;
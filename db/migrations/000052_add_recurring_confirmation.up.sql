@@ -0,0 +1,4 @@
+-- Подтверждение дорогих автосписаний (RECURRING_CONFIRM_THRESHOLD): когда клиента попросили подтвердить
+-- ближайшее списание и когда он нажал «Подтвердить продление»
+ALTER TABLE customer ADD COLUMN recurring_confirm_requested_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE customer ADD COLUMN recurring_confirmed_at TIMESTAMP WITH TIME ZONE;
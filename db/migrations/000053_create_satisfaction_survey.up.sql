@@ -0,0 +1,15 @@
+-- Опросы удовлетворённости: строка создаётся при отправке опроса, оценка и комментарий заполняются ответом
+CREATE TABLE satisfaction_survey
+(
+    id          BIGSERIAL PRIMARY KEY,
+    customer_id BIGINT                   NOT NULL REFERENCES customer (id) ON DELETE CASCADE,
+    -- Повод опроса: renewal — продление подписки, support — обращение в поддержку
+    trigger     TEXT                     NOT NULL,
+    sent_at     TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    rating      SMALLINT CHECK (rating BETWEEN 1 AND 5),
+    comment     TEXT,
+    answered_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_satisfaction_survey_customer ON satisfaction_survey (customer_id, sent_at);
+CREATE INDEX idx_satisfaction_survey_sent_at ON satisfaction_survey (sent_at);
@@ -0,0 +1,15 @@
+-- Оценки 👍/👎 под уведомлениями. Клиент может передумать: повторное нажатие под тем же сообщением меняет оценку
+CREATE TABLE notification_feedback
+(
+    id          BIGSERIAL PRIMARY KEY,
+    customer_id BIGINT                   NOT NULL REFERENCES customer (id) ON DELETE CASCADE,
+    message_id  BIGINT                   NOT NULL,
+    -- Шаблон уведомления: ключ перевода текста, например winback_offer
+    template    TEXT                     NOT NULL,
+    useful      BOOLEAN                  NOT NULL,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (customer_id, message_id)
+);
+
+CREATE INDEX idx_notification_feedback_created_at ON notification_feedback (created_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_failed_fulfillment_status;
+DROP INDEX IF EXISTS idx_failed_fulfillment_open;
+DROP TABLE IF EXISTS failed_fulfillment;
@@ -0,0 +1,21 @@
+-- Оплаченные покупки, выдача которых завершилась ошибкой. Временные ошибки повторяются автоматически,
+-- остальные ждут повтора из /admin → Ошибки. На покупку — одна открытая запись
+CREATE TABLE failed_fulfillment
+(
+    id              BIGSERIAL PRIMARY KEY,
+    purchase_id     BIGINT                   NOT NULL REFERENCES purchase (id) ON DELETE CASCADE,
+    -- Откуда пришла оплата (тип счёта): yookasa, crypto, telegram, tribute
+    source          VARCHAR(20)              NOT NULL,
+    -- Данные провайдера об оплате (уведомление или ответ API при опросе)
+    payload         JSONB,
+    status          VARCHAR(20)              NOT NULL DEFAULT 'pending',
+    attempts        INTEGER                  NOT NULL DEFAULT 1,
+    last_error      TEXT                     NOT NULL,
+    next_attempt_at TIMESTAMP WITH TIME ZONE,
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    resolved_at     TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX idx_failed_fulfillment_open ON failed_fulfillment (purchase_id) WHERE status <> 'resolved';
+CREATE INDEX idx_failed_fulfillment_status ON failed_fulfillment (status, next_attempt_at);
@@ -0,0 +1,2 @@
+ALTER TABLE purchase_archive DROP COLUMN IF EXISTS external_squad_uuid;
+ALTER TABLE purchase DROP COLUMN IF EXISTS external_squad_uuid;
@@ -0,0 +1,4 @@
+-- Внешний сквад панели для покупки по спецпредложению (winback, промо-тариф) вместо EXTERNAL_SQUAD_UUID.
+-- Пустой — используется сквад по умолчанию
+ALTER TABLE purchase ADD COLUMN external_squad_uuid UUID;
+ALTER TABLE purchase_archive ADD COLUMN external_squad_uuid UUID;
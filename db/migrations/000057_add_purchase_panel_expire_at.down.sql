@@ -0,0 +1,2 @@
+ALTER TABLE purchase_archive DROP COLUMN IF EXISTS panel_expire_at;
+ALTER TABLE purchase DROP COLUMN IF EXISTS panel_expire_at;
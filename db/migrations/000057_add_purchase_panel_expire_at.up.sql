@@ -0,0 +1,4 @@
+-- Срок подписки, рассчитанный при выдаче покупки до запроса в панель. Повторная выдача после сбоя
+-- выставляет этот срок вместо повторного продления
+ALTER TABLE purchase ADD COLUMN panel_expire_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE purchase_archive ADD COLUMN panel_expire_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,3 @@
+ALTER TABLE broadcast_history
+    DROP COLUMN IF EXISTS last_customer_id,
+    DROP COLUMN IF EXISTS options;
@@ -0,0 +1,5 @@
+-- Позиция рассылки для продолжения после перезапуска бота: получатели обходятся по возрастанию id,
+-- last_customer_id — последний обработанный. Опции (медиа, кнопки, исключения) нужны, чтобы продолжить с теми же настройками
+ALTER TABLE broadcast_history
+    ADD COLUMN last_customer_id BIGINT,
+    ADD COLUMN options          JSONB;
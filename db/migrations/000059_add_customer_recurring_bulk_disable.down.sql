@@ -0,0 +1,2 @@
+ALTER TABLE customer
+    DROP COLUMN IF EXISTS recurring_bulk_disable_id;
@@ -0,0 +1,4 @@
+-- Операция массового отключения, которая выключила автопродление клиента. Сбрасывается, когда клиент
+-- сам включает или отключает автопродление: отмена операции не включает его таким клиентам обратно
+ALTER TABLE customer
+    ADD COLUMN recurring_bulk_disable_id BIGINT;
@@ -0,0 +1,130 @@
+package activity
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+// pruneThreshold размер карты последних обновлений, после которого из неё удаляются устаревшие записи
+const pruneThreshold = 10000
+
+type activityRepository interface {
+	UpdateLastActive(ctx context.Context, telegramID int64, at time.Time) error
+	RecordFunnelEvent(ctx context.Context, telegramID int64, event database.FunnelEvent) error
+}
+
+// Tracker отслеживает активность пользователей: обновляет customer.last_active_at
+// не чаще interval и записывает события воронки продаж
+type Tracker struct {
+	repo     activityRepository
+	interval time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	lastSeen map[int64]time.Time
+}
+
+func NewTracker(repo activityRepository, interval time.Duration) *Tracker {
+	return &Tracker{
+		repo:     repo,
+		interval: interval,
+		now:      time.Now,
+		lastSeen: make(map[int64]time.Time),
+	}
+}
+
+// Middleware обновляет время последней активности отправителя update
+func (t *Tracker) Middleware(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		if telegramID := senderID(update); telegramID != 0 {
+			t.Touch(ctx, telegramID)
+		}
+		next(ctx, b, update)
+	}
+}
+
+// Touch обновляет время последней активности пользователя, если с прошлого обновления прошло больше interval.
+// Возвращает true если обновление было записано в БД
+func (t *Tracker) Touch(ctx context.Context, telegramID int64) bool {
+	now := t.now()
+	if !t.acquire(telegramID, now) {
+		return false
+	}
+
+	if err := t.repo.UpdateLastActive(ctx, telegramID, now); err != nil {
+		slog.ErrorContext(ctx, "Failed to update last active time", "error", err)
+		t.mu.Lock()
+		delete(t.lastSeen, telegramID)
+		t.mu.Unlock()
+		return false
+	}
+	return true
+}
+
+// Record записывает событие воронки продаж. Ошибки только логируются
+func (t *Tracker) Record(ctx context.Context, telegramID int64, event database.FunnelEvent) {
+	if err := t.repo.RecordFunnelEvent(ctx, telegramID, event); err != nil {
+		slog.ErrorContext(ctx, "Failed to record funnel event", "event", event, "error", err)
+	}
+}
+
+func (t *Tracker) acquire(telegramID int64, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if last, ok := t.lastSeen[telegramID]; ok && now.Sub(last) < t.interval {
+		return false
+	}
+	t.lastSeen[telegramID] = now
+
+	if len(t.lastSeen) > pruneThreshold {
+		for id, last := range t.lastSeen {
+			if now.Sub(last) >= t.interval {
+				delete(t.lastSeen, id)
+			}
+		}
+	}
+	return true
+}
+
+func senderID(update *models.Update) int64 {
+	switch {
+	case update.Message != nil && update.Message.From != nil:
+		return update.Message.From.ID
+	case update.CallbackQuery != nil:
+		return update.CallbackQuery.From.ID
+	case update.PreCheckoutQuery != nil && update.PreCheckoutQuery.From != nil:
+		return update.PreCheckoutQuery.From.ID
+	}
+	return 0
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultTracker *Tracker
+)
+
+// SetDefault устанавливает глобальный Tracker, используемый функциями пакета
+func SetDefault(t *Tracker) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultTracker = t
+}
+
+// Record записывает событие воронки через глобальный Tracker (no-op если он не установлен)
+func Record(ctx context.Context, telegramID int64, event database.FunnelEvent) {
+	defaultMu.RLock()
+	t := defaultTracker
+	defaultMu.RUnlock()
+
+	if t != nil {
+		t.Record(ctx, telegramID, event)
+	}
+}
@@ -0,0 +1,112 @@
+package activity
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+type activityRepoMock struct {
+	updates   map[int64]int
+	events    []database.FunnelEvent
+	updateErr error
+}
+
+func (m *activityRepoMock) UpdateLastActive(ctx context.Context, telegramID int64, at time.Time) error {
+	if m.updateErr != nil {
+		return m.updateErr
+	}
+	if m.updates == nil {
+		m.updates = make(map[int64]int)
+	}
+	m.updates[telegramID]++
+	return nil
+}
+
+func (m *activityRepoMock) RecordFunnelEvent(ctx context.Context, telegramID int64, event database.FunnelEvent) error {
+	m.events = append(m.events, event)
+	return nil
+}
+
+func TestTouchIsThrottled(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := &activityRepoMock{}
+	tracker := NewTracker(repo, 5*time.Minute)
+	tracker.now = func() time.Time { return now }
+
+	if !tracker.Touch(context.Background(), 1) {
+		t.Fatal("Expected first touch to be written")
+	}
+	now = now.Add(time.Minute)
+	if tracker.Touch(context.Background(), 1) {
+		t.Error("Expected touch within interval to be skipped")
+	}
+	if !tracker.Touch(context.Background(), 2) {
+		t.Error("Expected touch of another user to be written")
+	}
+	now = now.Add(5 * time.Minute)
+	if !tracker.Touch(context.Background(), 1) {
+		t.Error("Expected touch after interval to be written")
+	}
+
+	if repo.updates[1] != 2 || repo.updates[2] != 1 {
+		t.Errorf("Unexpected updates: %v", repo.updates)
+	}
+}
+
+func TestTouchRetriesAfterError(t *testing.T) {
+	repo := &activityRepoMock{updateErr: errors.New("db down")}
+	tracker := NewTracker(repo, time.Hour)
+
+	if tracker.Touch(context.Background(), 1) {
+		t.Fatal("Expected failed touch to return false")
+	}
+
+	repo.updateErr = nil
+	if !tracker.Touch(context.Background(), 1) {
+		t.Error("Expected touch to be retried after failed update")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	repo := &activityRepoMock{}
+	tracker := NewTracker(repo, time.Hour)
+
+	called := 0
+	handler := tracker.Middleware(func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		called++
+	})
+
+	handler(context.Background(), nil, &models.Update{Message: &models.Message{From: &models.User{ID: 10}}})
+	handler(context.Background(), nil, &models.Update{CallbackQuery: &models.CallbackQuery{From: models.User{ID: 20}}})
+	// Обновления без отправителя пропускаются, но передаются дальше
+	handler(context.Background(), nil, &models.Update{Message: &models.Message{}})
+
+	if called != 3 {
+		t.Errorf("Expected next handler to be called for every update, got %d", called)
+	}
+	if repo.updates[10] != 1 || repo.updates[20] != 1 || len(repo.updates) != 2 {
+		t.Errorf("Unexpected updates: %v", repo.updates)
+	}
+}
+
+func TestRecordUsesDefaultTracker(t *testing.T) {
+	t.Cleanup(func() { SetDefault(nil) })
+
+	// Без трекера запись событий — no-op
+	Record(context.Background(), 1, database.FunnelEventStart)
+
+	repo := &activityRepoMock{}
+	SetDefault(NewTracker(repo, time.Hour))
+	Record(context.Background(), 1, database.FunnelEventPaid)
+
+	if len(repo.events) != 1 || repo.events[0] != database.FunnelEventPaid {
+		t.Errorf("Expected paid event to be recorded, got %v", repo.events)
+	}
+}
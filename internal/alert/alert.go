@@ -0,0 +1,205 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/utils"
+)
+
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityWarning  Severity = "warning"
+	SeverityCritical Severity = "critical"
+)
+
+// Ключи алертов (используются для cooldown и подсчёта всплесков ошибок)
+const (
+	KeyRemnawaveUnavailable  = "remnawave_unavailable"
+	KeyDatabaseUnavailable   = "database_unavailable"
+	KeyRecurringPaymentErr   = "recurring_payment_error"
+	KeyWebhookSignature      = "webhook_signature"
+	KeyPanic                 = "panic"
+	KeyPurchaseVelocity      = "purchase_velocity"
+	KeyRecurringSpendingCap  = "recurring_spending_cap"
+	KeyPaymentRefund         = "payment_refund"
+	KeyBalanceRefund         = "balance_refund"
+	KeyInvalidPaymentAmount  = "invalid_payment_amount"
+	KeyRecurringPriceChanged = "recurring_price_changed"
+	KeyFulfillmentFailed     = "fulfillment_failed"
+)
+
+// spikeWindow окно подсчёта ошибок для RecordError
+const spikeWindow = time.Hour
+
+type telegramSender interface {
+	SendMessage(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error)
+}
+
+// Alerter отправляет админу алерты о критических сбоях с ограничением частоты по ключу
+type Alerter struct {
+	sender         telegramSender
+	chatIDs        []int64
+	cooldown       time.Duration
+	spikeThreshold int
+	now            func() time.Time
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time
+	errors   map[string][]time.Time
+}
+
+// NewAlerter создаёт Alerter. Нулевые chat id пропускаются
+func NewAlerter(sender telegramSender, cooldown time.Duration, spikeThreshold int, chatIDs ...int64) *Alerter {
+	ids := make([]int64, 0, len(chatIDs))
+	for _, id := range chatIDs {
+		if id != 0 {
+			ids = append(ids, id)
+		}
+	}
+	return &Alerter{
+		sender:         sender,
+		chatIDs:        ids,
+		cooldown:       cooldown,
+		spikeThreshold: spikeThreshold,
+		now:            time.Now,
+		lastSent:       make(map[string]time.Time),
+		errors:         make(map[string][]time.Time),
+	}
+}
+
+// Notify отправляет алерт, если алерт с тем же ключом не отправлялся в течение cooldown.
+// Возвращает true если алерт был отправлен
+func (a *Alerter) Notify(ctx context.Context, severity Severity, key, message string) bool {
+	if !a.acquire(key) {
+		slog.Debug("Alert suppressed by cooldown", "key", key)
+		return false
+	}
+
+	text := FormatAlert(severity, message)
+	for _, chatID := range a.chatIDs {
+		_, err := a.sender.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      text,
+			ParseMode: "HTML",
+		})
+		if err != nil {
+			slog.Error("Failed to send alert", "key", key, "chatId", chatID, "error", err)
+		}
+	}
+	return true
+}
+
+// RecordError учитывает ошибку по ключу и отправляет алерт, когда количество ошибок
+// за последний час достигает порога
+func (a *Alerter) RecordError(ctx context.Context, severity Severity, key, message string) bool {
+	now := a.now()
+
+	a.mu.Lock()
+	recent := a.errors[key][:0]
+	for _, t := range a.errors[key] {
+		if now.Sub(t) < spikeWindow {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+	a.errors[key] = recent
+	count := len(recent)
+	a.mu.Unlock()
+
+	if count < a.spikeThreshold {
+		return false
+	}
+	return a.Notify(ctx, severity, key, fmt.Sprintf("%s\nОшибок за последний час: %d", message, count))
+}
+
+// Resolve сбрасывает cooldown по ключу и, если по нему был алерт, сообщает о восстановлении
+func (a *Alerter) Resolve(ctx context.Context, key, message string) {
+	a.mu.Lock()
+	_, alerted := a.lastSent[key]
+	delete(a.lastSent, key)
+	delete(a.errors, key)
+	a.mu.Unlock()
+
+	if alerted {
+		a.Notify(ctx, SeverityInfo, key+"_resolved", message)
+	}
+}
+
+func (a *Alerter) acquire(key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	if last, ok := a.lastSent[key]; ok && now.Sub(last) < a.cooldown {
+		return false
+	}
+	a.lastSent[key] = now
+	return true
+}
+
+// FormatAlert форматирует текст алерта с учётом уровня важности
+func FormatAlert(severity Severity, message string) string {
+	var prefix string
+	switch severity {
+	case SeverityCritical:
+		prefix = "🚨 <b>CRITICAL</b>"
+	case SeverityWarning:
+		prefix = "⚠️ <b>WARNING</b>"
+	default:
+		prefix = "ℹ️ <b>INFO</b>"
+	}
+	return fmt.Sprintf("%s\n\n%s", prefix, utils.EscapeHTML(message))
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultAlerter *Alerter
+)
+
+// SetDefault устанавливает глобальный Alerter, используемый функциями пакета
+func SetDefault(a *Alerter) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultAlerter = a
+}
+
+func getDefault() *Alerter {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultAlerter
+}
+
+// Notify отправляет алерт через глобальный Alerter (no-op если алерты выключены)
+func Notify(ctx context.Context, severity Severity, key, message string) {
+	if a := getDefault(); a != nil {
+		a.Notify(ctx, severity, key, message)
+	}
+}
+
+// RecordError учитывает ошибку через глобальный Alerter (no-op если алерты выключены)
+func RecordError(ctx context.Context, severity Severity, key, message string) {
+	if a := getDefault(); a != nil {
+		a.RecordError(ctx, severity, key, message)
+	}
+}
+
+// Resolve сообщает о восстановлении через глобальный Alerter (no-op если алерты выключены)
+func Resolve(ctx context.Context, key, message string) {
+	if a := getDefault(); a != nil {
+		a.Resolve(ctx, key, message)
+	}
+}
+
+// NotifyPanic сообщает о восстановленной панике в фоновой задаче where
+func NotifyPanic(where string, recovered interface{}) {
+	Notify(context.Background(), SeverityCritical, KeyPanic+":"+where, fmt.Sprintf("Panic в %s: %v", where, recovered))
+}
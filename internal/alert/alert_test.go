@@ -0,0 +1,134 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+type senderMock struct {
+	messages []*bot.SendMessageParams
+}
+
+func (m *senderMock) SendMessage(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+	m.messages = append(m.messages, params)
+	return &models.Message{}, nil
+}
+
+func newTestAlerter(sender *senderMock, now *time.Time, spikeThreshold int, chatIDs ...int64) *Alerter {
+	a := NewAlerter(sender, 30*time.Minute, spikeThreshold, chatIDs...)
+	a.now = func() time.Time { return *now }
+	return a
+}
+
+func TestNotifyCooldown(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	sender := &senderMock{}
+	a := newTestAlerter(sender, &now, 1, 100, 0, 200)
+
+	if !a.Notify(context.Background(), SeverityCritical, "db", "down") {
+		t.Fatal("Expected first alert to be sent")
+	}
+	if len(sender.messages) != 2 {
+		t.Fatalf("Expected alert to be sent to 2 chats (zero id skipped), got %d", len(sender.messages))
+	}
+
+	now = now.Add(10 * time.Minute)
+	if a.Notify(context.Background(), SeverityCritical, "db", "down") {
+		t.Error("Expected repeated alert within cooldown to be suppressed")
+	}
+	if !a.Notify(context.Background(), SeverityCritical, "rw", "down") {
+		t.Error("Expected alert with another key to be sent")
+	}
+
+	now = now.Add(21 * time.Minute)
+	if !a.Notify(context.Background(), SeverityCritical, "db", "down") {
+		t.Error("Expected alert to be sent after cooldown")
+	}
+}
+
+func TestRecordErrorSpike(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	sender := &senderMock{}
+	a := newTestAlerter(sender, &now, 3, 100)
+
+	for i := 0; i < 2; i++ {
+		if a.RecordError(context.Background(), SeverityWarning, "sig", "bad signature") {
+			t.Fatalf("Expected no alert below threshold (error %d)", i+1)
+		}
+		now = now.Add(time.Minute)
+	}
+
+	// Ошибки старше часа не учитываются
+	now = now.Add(2 * time.Hour)
+	if a.RecordError(context.Background(), SeverityWarning, "sig", "bad signature") {
+		t.Fatal("Expected old errors to fall out of the window")
+	}
+	a.RecordError(context.Background(), SeverityWarning, "sig", "bad signature")
+	if !a.RecordError(context.Background(), SeverityWarning, "sig", "bad signature") {
+		t.Fatal("Expected alert when threshold is reached")
+	}
+	if !strings.Contains(sender.messages[0].Text, "Ошибок за последний час: 3") {
+		t.Errorf("Expected error count in alert, got %q", sender.messages[0].Text)
+	}
+}
+
+func TestFormatAlert(t *testing.T) {
+	tests := []struct {
+		severity Severity
+		want     string
+	}{
+		{SeverityCritical, "CRITICAL"},
+		{SeverityWarning, "WARNING"},
+		{SeverityInfo, "INFO"},
+	}
+
+	for _, tt := range tests {
+		text := FormatAlert(tt.severity, "<b>x</b>")
+		if !strings.Contains(text, tt.want) {
+			t.Errorf("Expected %q in alert for %s, got %q", tt.want, tt.severity, text)
+		}
+		if !strings.Contains(text, "&lt;b&gt;x&lt;/b&gt;") {
+			t.Errorf("Expected message to be HTML escaped, got %q", text)
+		}
+	}
+}
+
+func TestHealthMonitorAlertsAndRecovers(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	sender := &senderMock{}
+	a := newTestAlerter(sender, &now, 1, 100)
+
+	var checkErr error = errors.New("connection refused")
+	monitor := NewHealthMonitor(a, 2, &HealthCheck{
+		Key:   KeyDatabaseUnavailable,
+		Name:  "База данных",
+		Check: func(ctx context.Context) error { return checkErr },
+	})
+
+	monitor.Run(context.Background())
+	if len(sender.messages) != 0 {
+		t.Fatalf("Expected no alert after single failure, got %d", len(sender.messages))
+	}
+
+	monitor.Run(context.Background())
+	if len(sender.messages) != 1 || !strings.Contains(sender.messages[0].Text, "CRITICAL") {
+		t.Fatalf("Expected critical alert after threshold, got %d messages", len(sender.messages))
+	}
+
+	checkErr = nil
+	monitor.Run(context.Background())
+	if len(sender.messages) != 2 || !strings.Contains(sender.messages[1].Text, "снова доступен") {
+		t.Fatalf("Expected recovery message, got %d messages", len(sender.messages))
+	}
+
+	monitor.Run(context.Background())
+	if len(sender.messages) != 2 {
+		t.Errorf("Expected no extra messages while healthy, got %d", len(sender.messages))
+	}
+}
@@ -0,0 +1,52 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// HealthCheck проверка доступности внешней зависимости
+type HealthCheck struct {
+	Key   string
+	Name  string
+	Check func(ctx context.Context) error
+
+	failures int
+}
+
+// HealthMonitor периодически выполняет проверки и отправляет алерт после нескольких неудач подряд
+type HealthMonitor struct {
+	alerter   *Alerter
+	threshold int
+	checks    []*HealthCheck
+}
+
+func NewHealthMonitor(alerter *Alerter, threshold int, checks ...*HealthCheck) *HealthMonitor {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &HealthMonitor{alerter: alerter, threshold: threshold, checks: checks}
+}
+
+// Run выполняет все проверки один раз
+func (m *HealthMonitor) Run(ctx context.Context) {
+	for _, c := range m.checks {
+		err := c.Check(ctx)
+		if err == nil {
+			if c.failures >= m.threshold {
+				slog.Info("Health check recovered", "check", c.Key)
+				m.alerter.Resolve(ctx, c.Key, fmt.Sprintf("%s снова доступен", c.Name))
+			}
+			c.failures = 0
+			continue
+		}
+
+		c.failures++
+		slog.Warn("Health check failed", "check", c.Key, "failures", c.failures, "error", err)
+		if c.failures >= m.threshold {
+			m.alerter.Notify(ctx, SeverityCritical, c.Key,
+				fmt.Sprintf("%s недоступен (%d проверок подряд): %v", c.Name, c.failures, err))
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package botmode
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/utils"
+)
+
+// Mode способ получения обновлений от Telegram
+type Mode string
+
+const (
+	ModePolling Mode = "polling"
+	ModeWebhook Mode = "webhook"
+)
+
+// probeHeader заголовок запроса самопроверки webhook
+const probeHeader = "X-Bot-Webhook-Probe"
+
+// AllowedUpdates типы обновлений, которые бот получает через webhook
+var AllowedUpdates = []string{"message", "callback_query", "pre_checkout_query", "inline_query"}
+
+var ErrWebhookURLNotSet = errors.New("webhook url is not configured")
+
+type telegramAPI interface {
+	GetWebhookInfo(ctx context.Context) (*models.WebhookInfo, error)
+	SetWebhook(ctx context.Context, params *bot.SetWebhookParams) (bool, error)
+	DeleteWebhook(ctx context.Context, params *bot.DeleteWebhookParams) (bool, error)
+}
+
+type updatesRunner interface {
+	Start(ctx context.Context)
+	StartWebhook(ctx context.Context)
+}
+
+// Manager согласует режим получения обновлений с состоянием webhook в Telegram
+// и позволяет переключать polling/webhook без перезапуска
+type Manager struct {
+	api         telegramAPI
+	runner      updatesRunner
+	webhookURL  string
+	secretToken string
+	selfCheck   bool
+	httpClient  *http.Client
+	probeToken  string
+
+	mu      sync.Mutex
+	mode    Mode
+	baseCtx context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func NewManager(b *bot.Bot, webhookURL, secretToken string, selfCheck bool) *Manager {
+	return newManager(b, b, webhookURL, secretToken, selfCheck)
+}
+
+func newManager(api telegramAPI, runner updatesRunner, webhookURL, secretToken string, selfCheck bool) *Manager {
+	return &Manager{
+		api:         api,
+		runner:      runner,
+		webhookURL:  webhookURL,
+		secretToken: secretToken,
+		selfCheck:   selfCheck,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		probeToken:  newProbeToken(),
+	}
+}
+
+// WebhookHandler оборачивает обработчик обновлений: отвечает на запросы самопроверки,
+// остальные запросы передаёт дальше
+func (m *Manager) WebhookHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.Header.Get(probeHeader); token != "" {
+			if token != m.probeToken {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set(probeHeader, m.probeToken)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Reconcile приводит webhook в Telegram к желаемому режиму и возвращает фактический режим.
+// Для polling удаляет оставшийся webhook, для webhook проверяет доступность URL и
+// при неудаче остаётся в polling
+func (m *Manager) Reconcile(ctx context.Context, desired Mode) (Mode, error) {
+	info, err := m.api.GetWebhookInfo(ctx)
+	if err != nil {
+		return "", fmt.Errorf("get webhook info: %w", err)
+	}
+	logWebhookInfo(ctx, info)
+
+	if desired == ModeWebhook {
+		if err := m.enableWebhook(ctx); err != nil {
+			slog.WarnContext(ctx, "Webhook mode is not available, falling back to polling",
+				"url", m.webhookURL, "error", err,
+				"hint", "check that WEBHOOK_URL is public, uses HTTPS and is routed to /webhook of this instance, or set WEBHOOK_ENABLED=false")
+			return m.reconcilePolling(ctx, info)
+		}
+		return ModeWebhook, nil
+	}
+
+	return m.reconcilePolling(ctx, info)
+}
+
+func (m *Manager) reconcilePolling(ctx context.Context, info *models.WebhookInfo) (Mode, error) {
+	if info.URL == "" {
+		return ModePolling, nil
+	}
+
+	slog.WarnContext(ctx, "Stale webhook found while using polling, deleting it",
+		"url", info.URL, "pendingUpdates", info.PendingUpdateCount,
+		"hint", "getUpdates does not work while a webhook is set; pending updates will be received by polling")
+	if _, err := m.api.DeleteWebhook(ctx, &bot.DeleteWebhookParams{}); err != nil {
+		return "", fmt.Errorf("delete webhook: %w", err)
+	}
+	return ModePolling, nil
+}
+
+func (m *Manager) enableWebhook(ctx context.Context) error {
+	if m.webhookURL == "" {
+		return ErrWebhookURLNotSet
+	}
+	if m.selfCheck {
+		if err := m.checkReachable(ctx); err != nil {
+			return fmt.Errorf("self check failed: %w", err)
+		}
+	}
+
+	_, err := m.api.SetWebhook(ctx, &bot.SetWebhookParams{
+		URL:            m.webhookURL,
+		SecretToken:    m.secretToken,
+		AllowedUpdates: AllowedUpdates,
+	})
+	if err != nil {
+		return fmt.Errorf("set webhook: %w", err)
+	}
+	slog.InfoContext(ctx, "Webhook set", "url", m.webhookURL)
+	return nil
+}
+
+// checkReachable отправляет запрос самопроверки на WEBHOOK_URL и убеждается,
+// что он дошёл именно до этого экземпляра бота
+func (m *Manager) checkReachable(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.webhookURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(probeHeader, m.probeToken)
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get(probeHeader) != m.probeToken {
+		return fmt.Errorf("unexpected response from %s: status %d", m.webhookURL, resp.StatusCode)
+	}
+	return nil
+}
+
+// Run запускает получение обновлений в режиме mode и блокируется до завершения ctx
+func (m *Manager) Run(ctx context.Context, mode Mode) {
+	m.mu.Lock()
+	m.baseCtx = ctx
+	m.startLocked(mode)
+	m.mu.Unlock()
+
+	<-ctx.Done()
+
+	m.mu.Lock()
+	done := m.done
+	m.mu.Unlock()
+	<-done
+}
+
+// Switch переключает режим получения обновлений во время работы
+func (m *Manager) Switch(ctx context.Context, desired Mode) (Mode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.baseCtx == nil {
+		return "", errors.New("updates runner is not started")
+	}
+
+	mode, err := m.Reconcile(ctx, desired)
+	if err != nil {
+		return m.mode, err
+	}
+	if mode == m.mode {
+		return mode, nil
+	}
+
+	m.cancel()
+	<-m.done
+	m.startLocked(mode)
+	slog.InfoContext(ctx, "Updates mode switched", "mode", mode)
+	return mode, nil
+}
+
+// Mode возвращает текущий режим получения обновлений
+func (m *Manager) Mode() Mode {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mode
+}
+
+// Shutdown удаляет webhook при остановке бота, если он был установлен
+func (m *Manager) Shutdown(ctx context.Context) {
+	if m.Mode() != ModeWebhook {
+		return
+	}
+	if _, err := m.api.DeleteWebhook(ctx, &bot.DeleteWebhookParams{}); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete webhook on shutdown", "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "Webhook deleted")
+}
+
+func (m *Manager) startLocked(mode Mode) {
+	runCtx, cancel := context.WithCancel(m.baseCtx)
+	done := make(chan struct{})
+	m.mode = mode
+	m.cancel = cancel
+	m.done = done
+
+	go func() {
+		defer close(done)
+		if mode == ModeWebhook {
+			m.runner.StartWebhook(runCtx)
+		} else {
+			m.runner.Start(runCtx)
+		}
+	}()
+	slog.Info("Bot is receiving updates", "mode", mode)
+}
+
+func logWebhookInfo(ctx context.Context, info *models.WebhookInfo) {
+	if info.URL == "" {
+		slog.InfoContext(ctx, "No webhook is set in Telegram")
+		return
+	}
+	args := []any{"url", info.URL, "pendingUpdates", info.PendingUpdateCount}
+	if info.LastErrorMessage != "" {
+		args = append(args, "lastError", info.LastErrorMessage,
+			"lastErrorAt", time.Unix(int64(info.LastErrorDate), 0).Format(time.RFC3339))
+	}
+	slog.InfoContext(ctx, "Current Telegram webhook", args...)
+}
+
+func newProbeToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// CommandHandler обрабатывает команду админа /bot_mode [polling|webhook]:
+// без аргумента показывает текущий режим и состояние webhook, с аргументом — переключает режим
+func (m *Manager) CommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+	fields := strings.Fields(update.Message.Text)
+
+	if len(fields) < 2 {
+		m.reply(ctx, b, chatID, m.statusText(ctx))
+		return
+	}
+
+	desired := Mode(strings.ToLower(fields[1]))
+	if desired != ModePolling && desired != ModeWebhook {
+		m.reply(ctx, b, chatID, "Использование: <code>/bot_mode [polling|webhook]</code>")
+		return
+	}
+
+	// Переключение останавливает воркеры бота, в одном из которых выполняется эта команда,
+	// поэтому дожидаться его здесь нельзя
+	switchCtx := context.WithoutCancel(ctx)
+	go func() {
+		ctxWithTimeout, cancel := context.WithTimeout(switchCtx, time.Minute)
+		defer cancel()
+
+		var text string
+		mode, err := m.Switch(ctxWithTimeout, desired)
+		switch {
+		case err != nil:
+			slog.ErrorContext(switchCtx, "Failed to switch updates mode", "mode", desired, "error", err)
+			text = fmt.Sprintf("❌ Не удалось переключить режим: %s", utils.EscapeHTML(err.Error()))
+		case mode != desired:
+			text = fmt.Sprintf("⚠️ Режим %s недоступен (проверьте WEBHOOK_URL и логи), бот работает в режиме <b>%s</b>", desired, mode)
+		default:
+			text = fmt.Sprintf("✅ Бот работает в режиме <b>%s</b>", mode)
+		}
+		m.reply(ctxWithTimeout, b, chatID, text)
+	}()
+}
+
+func (m *Manager) statusText(ctx context.Context) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🤖 <b>Режим получения обновлений:</b> %s\n", m.Mode()))
+
+	info, err := m.api.GetWebhookInfo(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get webhook info", "error", err)
+		sb.WriteString("\n❌ Не удалось получить информацию о webhook")
+		return sb.String()
+	}
+
+	if info.URL == "" {
+		sb.WriteString("Webhook: не установлен\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("Webhook: <code>%s</code>\n", utils.EscapeHTML(info.URL)))
+	}
+	sb.WriteString(fmt.Sprintf("Ожидающих обновлений: %d\n", info.PendingUpdateCount))
+	if info.LastErrorMessage != "" {
+		sb.WriteString(fmt.Sprintf("Последняя ошибка: %s (%s)\n", utils.EscapeHTML(info.LastErrorMessage),
+			time.Unix(int64(info.LastErrorDate), 0).Format("02.01.2006 15:04")))
+	}
+	sb.WriteString("\nПереключить: <code>/bot_mode polling</code> или <code>/bot_mode webhook</code>")
+	return sb.String()
+}
+
+func (m *Manager) reply(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending bot mode message", "error", err)
+	}
+}
@@ -0,0 +1,200 @@
+package botmode
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+type telegramAPIMock struct {
+	info       models.WebhookInfo
+	setErr     error
+	setCalls   int
+	deleteCall int
+}
+
+func (m *telegramAPIMock) GetWebhookInfo(ctx context.Context) (*models.WebhookInfo, error) {
+	info := m.info
+	return &info, nil
+}
+
+func (m *telegramAPIMock) SetWebhook(ctx context.Context, params *bot.SetWebhookParams) (bool, error) {
+	m.setCalls++
+	if m.setErr != nil {
+		return false, m.setErr
+	}
+	m.info.URL = params.URL
+	return true, nil
+}
+
+func (m *telegramAPIMock) DeleteWebhook(ctx context.Context, params *bot.DeleteWebhookParams) (bool, error) {
+	m.deleteCall++
+	m.info.URL = ""
+	return true, nil
+}
+
+type runnerMock struct {
+	mu    sync.Mutex
+	modes []Mode
+}
+
+func (r *runnerMock) Start(ctx context.Context) {
+	r.record(ModePolling)
+	<-ctx.Done()
+}
+
+func (r *runnerMock) StartWebhook(ctx context.Context) {
+	r.record(ModeWebhook)
+	<-ctx.Done()
+}
+
+func (r *runnerMock) record(mode Mode) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.modes = append(r.modes, mode)
+}
+
+func (r *runnerMock) started() []Mode {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Mode(nil), r.modes...)
+}
+
+func TestReconcilePollingDeletesStaleWebhook(t *testing.T) {
+	api := &telegramAPIMock{info: models.WebhookInfo{URL: "https://old.example.com/webhook", PendingUpdateCount: 5}}
+	m := newManager(api, &runnerMock{}, "", "", true)
+
+	mode, err := m.Reconcile(context.Background(), ModePolling)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if mode != ModePolling {
+		t.Errorf("Expected polling mode, got %s", mode)
+	}
+	if api.deleteCall != 1 {
+		t.Errorf("Expected stale webhook to be deleted once, got %d", api.deleteCall)
+	}
+
+	api.deleteCall = 0
+	if _, err := m.Reconcile(context.Background(), ModePolling); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if api.deleteCall != 0 {
+		t.Errorf("Expected no delete without webhook, got %d", api.deleteCall)
+	}
+}
+
+func TestReconcileWebhookSelfCheck(t *testing.T) {
+	api := &telegramAPIMock{}
+	m := newManager(api, &runnerMock{}, "", "secret", true)
+	srv := httptest.NewServer(m.WebhookHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Probe request must not reach the updates handler")
+	})))
+	defer srv.Close()
+	m.webhookURL = srv.URL
+
+	mode, err := m.Reconcile(context.Background(), ModeWebhook)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if mode != ModeWebhook || api.setCalls != 1 || api.info.URL != srv.URL {
+		t.Errorf("Expected webhook to be set, got mode %s, setCalls %d, url %q", mode, api.setCalls, api.info.URL)
+	}
+}
+
+func TestReconcileWebhookFallsBackToPolling(t *testing.T) {
+	// URL отвечает, но это не наш экземпляр бота
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer foreign.Close()
+
+	tests := []struct {
+		name       string
+		webhookURL string
+		selfCheck  bool
+		setErr     error
+	}{
+		{"url not set", "", true, nil},
+		{"self check failed", foreign.URL, true, nil},
+		{"set webhook failed", foreign.URL, false, errors.New("bad webhook: HTTPS url must be provided")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &telegramAPIMock{info: models.WebhookInfo{URL: "https://old.example.com/webhook"}, setErr: tt.setErr}
+			m := newManager(api, &runnerMock{}, tt.webhookURL, "", tt.selfCheck)
+
+			mode, err := m.Reconcile(context.Background(), ModeWebhook)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if mode != ModePolling {
+				t.Errorf("Expected fallback to polling, got %s", mode)
+			}
+			if api.info.URL != "" {
+				t.Errorf("Expected stale webhook to be deleted, got %q", api.info.URL)
+			}
+		})
+	}
+}
+
+func TestSwitchRestartsRunner(t *testing.T) {
+	api := &telegramAPIMock{}
+	runner := &runnerMock{}
+	m := newManager(api, runner, "https://bot.example.com/webhook", "", false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		m.Run(ctx, ModePolling)
+		close(stopped)
+	}()
+	waitFor(t, func() bool { return len(runner.started()) == 1 })
+
+	mode, err := m.Switch(context.Background(), ModeWebhook)
+	if err != nil || mode != ModeWebhook {
+		t.Fatalf("Expected switch to webhook, got %s, %v", mode, err)
+	}
+	waitFor(t, func() bool { return len(runner.started()) == 2 })
+	if got := runner.started(); got[0] != ModePolling || got[1] != ModeWebhook {
+		t.Errorf("Expected polling then webhook runner, got %v", got)
+	}
+
+	if _, err := m.Switch(context.Background(), ModeWebhook); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(runner.started()) != 2 {
+		t.Errorf("Expected no restart when mode is unchanged")
+	}
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after context cancel")
+	}
+
+	m.Shutdown(context.Background())
+	if api.info.URL != "" {
+		t.Errorf("Expected webhook to be deleted on shutdown, got %q", api.info.URL)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,81 @@
+package broadcast
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// RecentDaysOptions варианты паузы после предыдущей рассылки, которые админ перебирает при подтверждении
+var RecentDaysOptions = []int{0, 1, 3, 7}
+
+// Exclusions исключения получателей, выбранные админом при подтверждении рассылки
+type Exclusions struct {
+	RecentDays         int      // не отправлять получившим любую рассылку за последние RecentDays дней, 0 — не исключать
+	Tags               []string // не отправлять клиентам с любым из тегов поддержки
+	IgnoreFrequencyCap bool     // срочная рассылка: лимит BROADCAST_FREQUENCY_CAP не применяется
+}
+
+// exclusion переводит выбор админа и глобальный лимит частоты в условия выборки исключённых клиентов
+func (e Exclusions) exclusion(now time.Time, frequencyCap, windowDays int) database.BroadcastExclusion {
+	var result database.BroadcastExclusion
+	if e.RecentDays > 0 {
+		since := now.AddDate(0, 0, -e.RecentDays)
+		result.ReceivedSince = &since
+	}
+	result.Tags = e.Tags
+	if frequencyCap > 0 && !e.IgnoreFrequencyCap {
+		result.FrequencyCap = frequencyCap
+		result.FrequencyWindowSince = now.AddDate(0, 0, -windowDays)
+	}
+	return result
+}
+
+// excludeRecipients убирает из аудитории исключённых клиентов и возвращает оставшихся получателей
+// и количество исключённых
+func (s *BroadcastService) excludeRecipients(ctx context.Context, customers []database.Customer, e Exclusions) ([]database.Customer, int, error) {
+	excluded, err := s.broadcastRepo.FindExcludedCustomerIDs(ctx,
+		e.exclusion(clock.Now(), config.BroadcastFrequencyCap(), config.BroadcastFrequencyWindowDays()))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find excluded customers: %w", err)
+	}
+	if len(excluded) == 0 {
+		return customers, 0, nil
+	}
+
+	recipients := make([]database.Customer, 0, len(customers))
+	for _, customer := range customers {
+		if !excluded[customer.ID] {
+			recipients = append(recipients, customer)
+		}
+	}
+	return recipients, len(customers) - len(recipients), nil
+}
+
+// CountRecipients возвращает количество получателей рассылки с учётом исключений и сколько клиентов аудитории исключено
+func (s *BroadcastService) CountRecipients(ctx context.Context, targetType string, e Exclusions) (recipients, excluded int, err error) {
+	customers, err := s.getTargetCustomers(ctx, targetType)
+	if err != nil {
+		return 0, 0, err
+	}
+	filtered, excluded, err := s.excludeRecipients(ctx, customers, e)
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(filtered), excluded, nil
+}
+
+// recordDelivery учитывает доставленную рассылку в лимите частоты клиента
+func (s *BroadcastService) recordDelivery(ctx context.Context, customerID int64) {
+	now := clock.Now()
+	windowSince := now.AddDate(0, 0, -config.BroadcastFrequencyWindowDays())
+	if err := s.broadcastRepo.RecordDelivery(ctx, customerID, now, windowSince); err != nil {
+		slog.WarnContext(ctx, "Failed to record broadcast delivery", "error", err, "customerId", utils.MaskHalfInt64(customerID))
+	}
+}
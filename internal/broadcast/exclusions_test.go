@@ -0,0 +1,41 @@
+package broadcast
+
+import (
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+func TestExclusionsExclusion(t *testing.T) {
+	now := time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)
+
+	e := Exclusions{RecentDays: 3, Tags: []string{"vip"}}.exclusion(now, 2, 7)
+	if e.ReceivedSince == nil || !e.ReceivedSince.Equal(now.AddDate(0, 0, -3)) {
+		t.Errorf("ReceivedSince = %v, want 3 days ago", e.ReceivedSince)
+	}
+	if len(e.Tags) != 1 || e.Tags[0] != "vip" {
+		t.Errorf("Tags = %v", e.Tags)
+	}
+	if e.FrequencyCap != 2 || !e.FrequencyWindowSince.Equal(now.AddDate(0, 0, -7)) {
+		t.Errorf("Unexpected frequency cap %d since %v", e.FrequencyCap, e.FrequencyWindowSince)
+	}
+
+	// Срочная рассылка: лимит частоты не применяется
+	if e := (Exclusions{IgnoreFrequencyCap: true}).exclusion(now, 2, 7); !e.IsEmpty() {
+		t.Errorf("Expected no exclusions for urgent broadcast, got %+v", e)
+	}
+	// Лимит не настроен
+	if e := (Exclusions{}).exclusion(now, 0, 7); !e.IsEmpty() {
+		t.Errorf("Expected no exclusions without cap, got %+v", e)
+	}
+}
+
+func TestWithoutMarketingOptOuts(t *testing.T) {
+	optedOut := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	customers := []database.Customer{{ID: 1}, {ID: 2, MarketingOptedOutAt: &optedOut}, {ID: 3}}
+	got := withoutMarketingOptOuts(customers)
+	if len(got) != 2 || got[0].ID != 1 || got[1].ID != 3 {
+		t.Errorf("Expected customers 1 and 3, got %+v", got)
+	}
+}
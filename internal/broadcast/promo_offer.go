@@ -0,0 +1,42 @@
+package broadcast
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ButtonPromoTariff кнопка рассылки, открывающая оплату promo tariff предложения получателя
+const ButtonPromoTariff = "promo_tariff"
+
+// PromoOffer promo tariff предложение, которое админ выставляет сегменту без промокода
+type PromoOffer struct {
+	Price     int
+	Devices   int
+	Months    int
+	ExpiresAt time.Time
+}
+
+// AudienceSize возвращает количество клиентов аудитории targetType. В отличие от CountRecipients учитывает
+// и отказавшихся от рассылок: предложение выставляется им без уведомления
+func (s *BroadcastService) AudienceSize(ctx context.Context, targetType string) (int, error) {
+	customers, err := s.getAudience(ctx, targetType)
+	if err != nil {
+		return 0, err
+	}
+	return len(customers), nil
+}
+
+// AssignPromoOffer выставляет предложение всем клиентам аудитории targetType, заменяя их текущие предложения.
+// Возвращает число клиентов, которым выставлено предложение
+func (s *BroadcastService) AssignPromoOffer(ctx context.Context, targetType string, offer PromoOffer) (int64, error) {
+	customers, err := s.getAudience(ctx, targetType)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get customers: %w", err)
+	}
+	ids := make([]int64, 0, len(customers))
+	for _, customer := range customers {
+		ids = append(ids, customer.ID)
+	}
+	return s.customerRepository.AssignPromoOffer(ctx, ids, offer.Price, offer.Devices, offer.Months, offer.ExpiresAt)
+}
@@ -2,8 +2,10 @@ package broadcast
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -11,7 +13,9 @@ import (
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 
+	"remnawave-tg-shop-bot/internal/alert"
 	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/shutdown"
 	"remnawave-tg-shop-bot/utils"
 )
 
@@ -27,27 +31,72 @@ const (
 type BroadcastOptions struct {
 	MediaType   string   // тип медиа: "photo", "gif", "video", "video_note"
 	MediaFileID string   // file_id медиа (опционально)
-	Buttons     []string // список кнопок: "promo", "subscription", "buy"
+	Buttons     []string // список кнопок: "promo", "subscription", "buy", "promo_tariff"
 	MiniAppURL  string   // URL mini app для кнопки "Ваша подписка"
+	Exclusions  Exclusions
+}
+
+// audienceRepository клиенты, из которых выбираются получатели рассылки
+type audienceRepository interface {
+	FindAll(ctx context.Context) ([]database.Customer, error)
+	FindByTag(ctx context.Context, tag string) ([]database.Customer, error)
+	FindByRemnawaveTag(ctx context.Context, tag string) ([]database.Customer, error)
+	FindStartOnlyCustomers(ctx context.Context) ([]database.Customer, error)
+	FindByExpirationRange(ctx context.Context, startDate, endDate time.Time) (*[]database.Customer, error)
+	FindByTelegramId(ctx context.Context, telegramId int64) (*database.Customer, error)
+	AssignPromoOffer(ctx context.Context, ids []int64, price, devices, months int, expiresAt time.Time) (int64, error)
+}
+
+// broadcastStore история рассылок, их прогресс и учёт доставок для лимита частоты
+type broadcastStore interface {
+	Create(ctx context.Context, targetType, messageText string) (int64, error)
+	UpdateStatus(ctx context.Context, id int64, status string, sentCount, failedCount int) error
+	UpdateProgress(ctx context.Context, id, lastCustomerID int64, sentCount, failedCount int) error
+	Interrupt(ctx context.Context, id, lastCustomerID int64, sentCount, failedCount int) error
+	ClaimInterrupted(ctx context.Context) ([]database.BroadcastResume, error)
+	SetOptions(ctx context.Context, id int64, options []byte) error
+	SetTotalCount(ctx context.Context, id int64, total int) error
+	SetExcludedCount(ctx context.Context, id int64, excluded int) error
+	FindExcludedCustomerIDs(ctx context.Context, e database.BroadcastExclusion) (map[int64]bool, error)
+	RecordDelivery(ctx context.Context, customerID int64, at, windowSince time.Time) error
+	IncrementTestSent(ctx context.Context, id int64) error
+	List(ctx context.Context, limit, offset int) ([]database.BroadcastHistory, error)
+	FindByID(ctx context.Context, id int64) (*database.BroadcastHistory, error)
+	Delete(ctx context.Context, id int64) error
 }
 
 type BroadcastService struct {
 	bot                *bot.Bot
-	customerRepository *database.CustomerRepository
-	broadcastRepo      *database.BroadcastRepository
-	mu                 sync.Mutex
-	runningBroadcasts  map[int64]bool
+	customerRepository audienceRepository
+	broadcastRepo      broadcastStore
+	purchaseRepository lastPurchaseFinder
+	remnawaveClient    panelUserGetter
+	// stopping закрывается при выключении бота: рассылка сохраняет позицию и выходит
+	stopping          <-chan struct{}
+	mu                sync.Mutex
+	runningBroadcasts map[int64]bool
+}
+
+// resumePoint позиция, с которой продолжается прерванная рассылка
+type resumePoint struct {
+	lastCustomerID int64
+	sent, failed   int
 }
 
 func NewBroadcastService(
 	b *bot.Bot,
 	customerRepository *database.CustomerRepository,
 	broadcastRepo *database.BroadcastRepository,
+	purchaseRepository lastPurchaseFinder,
+	remnawaveClient panelUserGetter,
 ) *BroadcastService {
 	return &BroadcastService{
 		bot:                b,
 		customerRepository: customerRepository,
 		broadcastRepo:      broadcastRepo,
+		purchaseRepository: purchaseRepository,
+		remnawaveClient:    remnawaveClient,
+		stopping:           shutdown.Stopping(),
 		runningBroadcasts:  make(map[int64]bool),
 	}
 }
@@ -56,65 +105,121 @@ func (s *BroadcastService) CreateBroadcast(ctx context.Context, targetType, mess
 	return s.broadcastRepo.Create(ctx, targetType, messageText)
 }
 
-// GetTargetCustomersCount возвращает количество получателей для указанного типа рассылки
-func (s *BroadcastService) GetTargetCustomersCount(ctx context.Context, targetType string) (int, error) {
-	customers, err := s.getTargetCustomers(ctx, targetType)
-	if err != nil {
-		return 0, err
-	}
-	return len(customers), nil
-}
-
 func (s *BroadcastService) StartBroadcast(ctx context.Context, broadcastID int64, targetType, messageText string) {
 	s.StartBroadcastWithOptions(ctx, broadcastID, targetType, messageText, nil)
 }
 
 func (s *BroadcastService) StartBroadcastWithOptions(ctx context.Context, broadcastID int64, targetType, messageText string, opts *BroadcastOptions) {
+	s.start(ctx, broadcastID, targetType, messageText, opts, nil)
+}
+
+// ResumeInterrupted продолжает рассылки, прерванные выключением бота, с сохранённой позиции
+func (s *BroadcastService) ResumeInterrupted(ctx context.Context) error {
+	broadcasts, err := s.broadcastRepo.ClaimInterrupted(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to claim interrupted broadcasts: %w", err)
+	}
+	for _, b := range broadcasts {
+		var opts *BroadcastOptions
+		if len(b.Options) > 0 {
+			opts = &BroadcastOptions{}
+			if err := json.Unmarshal(b.Options, opts); err != nil {
+				slog.ErrorContext(ctx, "Failed to decode options of interrupted broadcast", "error", err, "id", b.ID)
+				_ = s.broadcastRepo.UpdateStatus(ctx, b.ID, string(database.BroadcastStatusFailed), b.SentCount, b.FailedCount)
+				continue
+			}
+		}
+		slog.InfoContext(ctx, "Resuming interrupted broadcast", "id", utils.MaskHalfInt64(b.ID), "sent", b.SentCount, "failed", b.FailedCount)
+		s.start(ctx, b.ID, b.TargetType, b.MessageText, opts, &resumePoint{lastCustomerID: b.LastCustomerID, sent: b.SentCount, failed: b.FailedCount})
+	}
+	return nil
+}
+
+// start запускает рассылку в фоне. from — позиция прерванной рассылки, nil для новой
+func (s *BroadcastService) start(ctx context.Context, broadcastID int64, targetType, messageText string, opts *BroadcastOptions, from *resumePoint) {
 	s.mu.Lock()
 	if s.runningBroadcasts[broadcastID] {
 		s.mu.Unlock()
-		slog.Warn("Broadcast already running", "id", broadcastID)
+		slog.WarnContext(ctx, "Broadcast already running", "id", broadcastID)
 		return
 	}
 	s.runningBroadcasts[broadcastID] = true
 	s.mu.Unlock()
 
+	done := shutdown.Track(shutdown.KindBroadcast)
 	go func() {
+		defer done()
 		defer func() {
 			if r := recover(); r != nil {
-				slog.Error("Panic in broadcast", r, "id", broadcastID)
-				bgCtx := context.Background()
-				_ = s.broadcastRepo.UpdateStatus(bgCtx, broadcastID, string(database.BroadcastStatusFailed), 0, 0)
+				slog.ErrorContext(ctx, "Panic in broadcast", r, "id", broadcastID)
+				alert.NotifyPanic("broadcast", r)
+				_ = s.broadcastRepo.UpdateStatus(context.WithoutCancel(ctx), broadcastID, string(database.BroadcastStatusFailed), 0, 0)
 			}
 			s.mu.Lock()
 			delete(s.runningBroadcasts, broadcastID)
 			s.mu.Unlock()
 		}()
 
-		// Создаем новый контекст для background задачи
-		bgCtx := context.Background()
-		err := s.executeBroadcastWithOptions(bgCtx, broadcastID, targetType, messageText, opts)
+		// Рассылка переживает обработку апдейта, но сохраняет request id для логов.
+		// Останавливается по shutdown.Stopping, каждая отправка ограничена своим таймаутом
+		bgCtx := context.WithoutCancel(ctx)
+		err := s.executeBroadcastWithOptions(bgCtx, broadcastID, targetType, messageText, opts, from)
 		if err != nil {
-			slog.Error("Broadcast execution failed", "error", err, "id", broadcastID)
+			slog.ErrorContext(ctx, "Broadcast execution failed", "error", err, "id", broadcastID)
 		}
 	}()
 }
 
-func (s *BroadcastService) executeBroadcastWithOptions(ctx context.Context, broadcastID int64, targetType, messageText string, opts *BroadcastOptions) error {
+func (s *BroadcastService) executeBroadcastWithOptions(ctx context.Context, broadcastID int64, targetType, messageText string, opts *BroadcastOptions, from *resumePoint) error {
 	customers, err := s.getTargetCustomers(ctx, targetType)
 	if err != nil {
 		_ = s.broadcastRepo.UpdateStatus(ctx, broadcastID, string(database.BroadcastStatusFailed), 0, 0)
 		return fmt.Errorf("failed to get customers: %w", err)
 	}
 
-	totalCount := len(customers)
+	var exclusions Exclusions
+	if opts != nil {
+		exclusions = opts.Exclusions
+	}
+	customers, excludedCount, err := s.excludeRecipients(ctx, customers, exclusions)
+	if err != nil {
+		_ = s.broadcastRepo.UpdateStatus(ctx, broadcastID, string(database.BroadcastStatusFailed), 0, 0)
+		return err
+	}
+
+	// Получатели обходятся по возрастанию id: позиция рассылки — id последнего обработанного
+	sort.Slice(customers, func(i, j int) bool { return customers[i].ID < customers[j].ID })
+
+	sentCount := 0
+	failedCount := 0
+	var lastCustomerID int64
+	if from == nil {
+		if err := s.broadcastRepo.SetExcludedCount(ctx, broadcastID, excludedCount); err != nil {
+			return fmt.Errorf("failed to set excluded count: %w", err)
+		}
+		if opts != nil {
+			options, err := json.Marshal(opts)
+			if err != nil {
+				return fmt.Errorf("failed to encode options: %w", err)
+			}
+			if err := s.broadcastRepo.SetOptions(ctx, broadcastID, options); err != nil {
+				return fmt.Errorf("failed to save options: %w", err)
+			}
+		}
+	} else {
+		// Исключения пересчитаны только для оставшихся получателей, счётчик исключённых остаётся от первого запуска
+		customers = customersAfter(customers, from.lastCustomerID)
+		sentCount, failedCount, lastCustomerID = from.sent, from.failed, from.lastCustomerID
+	}
+
+	totalCount := sentCount + failedCount + len(customers)
 	err = s.broadcastRepo.SetTotalCount(ctx, broadcastID, totalCount)
 	if err != nil {
 		return fmt.Errorf("failed to set total count: %w", err)
 	}
 
-	if totalCount == 0 {
-		_ = s.broadcastRepo.UpdateStatus(ctx, broadcastID, string(database.BroadcastStatusCompleted), 0, 0)
+	if len(customers) == 0 {
+		_ = s.broadcastRepo.UpdateStatus(ctx, broadcastID, finalStatus(failedCount), sentCount, failedCount)
 		return nil
 	}
 
@@ -124,58 +229,52 @@ func (s *BroadcastService) executeBroadcastWithOptions(ctx context.Context, broa
 		keyboard = s.buildKeyboard(opts.Buttons, opts.MiniAppURL)
 	}
 
-	sentCount := 0
-	failedCount := 0
+	// Значения переменных шаблона для каждого получателя; данные запрашиваются, только если переменная есть в тексте
+	vars := s.newRecipientVars(messageText)
 
 	for i, customer := range customers {
-		sendCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-
-		var sendErr error
-		if opts != nil && opts.MediaFileID != "" {
-			// Отправка с медиа
-			sendErr = s.sendMediaMessage(sendCtx, customer.TelegramID, messageText, opts, keyboard)
-		} else {
-			// Отправка только текста
-			params := &bot.SendMessageParams{
-				ChatID:    customer.TelegramID,
-				Text:      messageText,
-				ParseMode: models.ParseModeHTML,
+		// При остановке бота сохраняем позицию: после запуска рассылка продолжится со следующего получателя
+		select {
+		case <-s.stopping:
+			if err := s.broadcastRepo.Interrupt(ctx, broadcastID, lastCustomerID, sentCount, failedCount); err != nil {
+				return fmt.Errorf("failed to save interrupted status: %w", err)
 			}
-			if keyboard != nil {
-				params.ReplyMarkup = keyboard
-			}
-			_, sendErr = s.bot.SendMessage(sendCtx, params)
+			slog.WarnContext(ctx, "Broadcast interrupted by shutdown",
+				"id", utils.MaskHalfInt64(broadcastID), "sent", sentCount, "failed", failedCount, "total", totalCount)
+			return nil
+		default:
 		}
+
+		sendCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		text := RenderTemplate(messageText, vars.resolve(sendCtx, customer))
+		sendErr := s.send(sendCtx, customer.TelegramID, text, opts, keyboard)
 		cancel()
 
 		if sendErr != nil {
 			failedCount++
 		} else {
 			sentCount++
+			s.recordDelivery(ctx, customer.ID)
 		}
+		lastCustomerID = customer.ID
 
 		// Обновляем прогресс каждые 100 сообщений
 		if (i+1)%100 == 0 {
-			_ = s.broadcastRepo.UpdateProgress(ctx, broadcastID, sentCount, failedCount)
-			slog.Info("Broadcast progress", "id", broadcastID, "sent", sentCount, "failed", failedCount, "total", totalCount)
+			_ = s.broadcastRepo.UpdateProgress(ctx, broadcastID, lastCustomerID, sentCount, failedCount)
+			slog.InfoContext(ctx, "Broadcast progress", "id", broadcastID, "sent", sentCount, "failed", failedCount, "total", totalCount)
 		}
 
-		// Задержка 35ms между сообщениями (~28 msg/sec, лимит Telegram ~30 msg/sec)
-		time.Sleep(35 * time.Millisecond)
+		// Контекст рассылки не отменяется: остановка обрабатывается через stopping в начале итерации
+		_ = utils.ThrottleSend(ctx)
 	}
 
 	// Финальное обновление
-	status := string(database.BroadcastStatusCompleted)
-	if failedCount > 0 {
-		status = string(database.BroadcastStatusPartial)
-	}
-
-	err = s.broadcastRepo.UpdateStatus(ctx, broadcastID, status, sentCount, failedCount)
+	err = s.broadcastRepo.UpdateStatus(ctx, broadcastID, finalStatus(failedCount), sentCount, failedCount)
 	if err != nil {
 		return fmt.Errorf("failed to update final status: %w", err)
 	}
 
-	slog.Info("Broadcast completed",
+	slog.InfoContext(ctx, "Broadcast completed",
 		"id", utils.MaskHalfInt64(broadcastID),
 		"sent", sentCount,
 		"failed", failedCount,
@@ -185,6 +284,20 @@ func (s *BroadcastService) executeBroadcastWithOptions(ctx context.Context, broa
 	return nil
 }
 
+// finalStatus статус завершённой рассылки: partial, если часть сообщений не доставлена
+func finalStatus(failedCount int) string {
+	if failedCount > 0 {
+		return string(database.BroadcastStatusPartial)
+	}
+	return string(database.BroadcastStatusCompleted)
+}
+
+// customersAfter возвращает получателей с id больше lastCustomerID. customers отсортированы по id
+func customersAfter(customers []database.Customer, lastCustomerID int64) []database.Customer {
+	i := sort.Search(len(customers), func(i int) bool { return customers[i].ID > lastCustomerID })
+	return customers[i:]
+}
+
 // buildKeyboard создает inline клавиатуру из списка кнопок
 // Используем префикс bc_ для broadcast кнопок чтобы отличать от обычных
 func (s *BroadcastService) buildKeyboard(buttons []string, miniAppURL string) *models.InlineKeyboardMarkup {
@@ -212,6 +325,11 @@ func (s *BroadcastService) buildKeyboard(buttons []string, miniAppURL string) *m
 			rows = append(rows, []models.InlineKeyboardButton{
 				{Text: "🛒 Купить", CallbackData: "bc_buy"},
 			})
+		case ButtonPromoTariff:
+			// Открывает оплату предложения получателя, как кнопка в меню тарифов
+			rows = append(rows, []models.InlineKeyboardButton{
+				{Text: "🎁 Специальный тариф", CallbackData: "promo_tariff"},
+			})
 		}
 	}
 
@@ -222,7 +340,40 @@ func (s *BroadcastService) buildKeyboard(buttons []string, miniAppURL string) *m
 	return &models.InlineKeyboardMarkup{InlineKeyboard: rows}
 }
 
+// TargetTagPrefix префикс типа рассылки по тегу поддержки: "tag:vip" — клиентам с тегом vip
+const TargetTagPrefix = "tag:"
+
+// TargetRemnawaveTagPrefix префикс типа рассылки по тегу панели: "rwtag:PRO" — клиентам, которым бот выставил тег PRO
+const TargetRemnawaveTagPrefix = "rwtag:"
+
+// getTargetCustomers возвращает получателей рассылки: аудиторию targetType без отказавшихся от маркетинговых сообщений
 func (s *BroadcastService) getTargetCustomers(ctx context.Context, targetType string) ([]database.Customer, error) {
+	customers, err := s.getAudience(ctx, targetType)
+	if err != nil {
+		return nil, err
+	}
+	return withoutMarketingOptOuts(customers), nil
+}
+
+// withoutMarketingOptOuts убирает клиентов, отключивших рассылки в настройках уведомлений
+func withoutMarketingOptOuts(customers []database.Customer) []database.Customer {
+	result := make([]database.Customer, 0, len(customers))
+	for _, customer := range customers {
+		if customer.MarketingOptedOutAt == nil {
+			result = append(result, customer)
+		}
+	}
+	return result
+}
+
+func (s *BroadcastService) getAudience(ctx context.Context, targetType string) ([]database.Customer, error) {
+	if tag, ok := strings.CutPrefix(targetType, TargetTagPrefix); ok {
+		return s.customerRepository.FindByTag(ctx, tag)
+	}
+	if tag, ok := strings.CutPrefix(targetType, TargetRemnawaveTagPrefix); ok {
+		return s.customerRepository.FindByRemnawaveTag(ctx, tag)
+	}
+
 	switch targetType {
 	case "all":
 		return s.getAllCustomers(ctx)
@@ -304,6 +455,56 @@ func (s *BroadcastService) DeleteBroadcast(ctx context.Context, id int64) error
 	return s.broadcastRepo.Delete(ctx, id)
 }
 
+// SendPreview отправляет админу сообщение так, как его увидят получатели: с медиа, кнопками
+// и подставленными значениями vars. Ошибка означает, что Telegram не примет такое сообщение
+func (s *BroadcastService) SendPreview(ctx context.Context, chatID int64, messageText string, vars map[string]string, opts *BroadcastOptions) error {
+	var keyboard *models.InlineKeyboardMarkup
+	if opts != nil && len(opts.Buttons) > 0 {
+		keyboard = s.buildKeyboard(opts.Buttons, opts.MiniAppURL)
+	}
+	return s.send(ctx, chatID, RenderTemplate(messageText, vars), opts, keyboard)
+}
+
+// SendTest отправляет админу тестовую копию рассылки broadcastID: переменные заполняются по его собственной записи
+// клиента, а если её нет — примерами. Тестовые отправки учитываются отдельно от статистики рассылки
+func (s *BroadcastService) SendTest(ctx context.Context, broadcastID, chatID int64, messageText, firstName string, opts *BroadcastOptions) error {
+	vars := SampleTemplateVars(firstName)
+	if customer, err := s.customerRepository.FindByTelegramId(ctx, chatID); err != nil {
+		slog.WarnContext(ctx, "Broadcast test: admin customer not loaded", "error", err)
+	} else if customer != nil {
+		if resolved := s.newRecipientVars(messageText).resolve(ctx, *customer); resolved != nil {
+			vars = resolved
+		}
+	}
+
+	if err := s.SendPreview(ctx, chatID, messageText, vars, opts); err != nil {
+		return err
+	}
+	if err := s.broadcastRepo.IncrementTestSent(ctx, broadcastID); err != nil {
+		slog.ErrorContext(ctx, "Failed to record broadcast test send", "error", err, "id", broadcastID)
+	}
+	return nil
+}
+
+// send отправляет сообщение рассылки одному получателю
+func (s *BroadcastService) send(ctx context.Context, chatID int64, text string, opts *BroadcastOptions, keyboard *models.InlineKeyboardMarkup) error {
+	if opts != nil && opts.MediaFileID != "" {
+		// Отправка с медиа
+		return s.sendMediaMessage(ctx, chatID, text, opts, keyboard)
+	}
+	// Отправка только текста
+	params := &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+	}
+	if keyboard != nil {
+		params.ReplyMarkup = keyboard
+	}
+	_, err := s.bot.SendMessage(ctx, params)
+	return err
+}
+
 // sendMediaMessage отправляет сообщение с медиа в зависимости от типа
 func (s *BroadcastService) sendMediaMessage(ctx context.Context, chatID int64, caption string, opts *BroadcastOptions, keyboard *models.InlineKeyboardMarkup) error {
 	switch opts.MediaType {
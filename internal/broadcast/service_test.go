@@ -0,0 +1,200 @@
+package broadcast
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+type audienceMock struct {
+	audienceRepository
+	customers []database.Customer
+}
+
+func (m *audienceMock) FindAll(ctx context.Context) ([]database.Customer, error) {
+	return append([]database.Customer(nil), m.customers...), nil
+}
+
+// broadcastState сохранённое состояние рассылки
+type broadcastState struct {
+	status         string
+	total          int
+	sent, failed   int
+	lastCustomerID int64
+	options        []byte
+}
+
+// broadcastStoreMock хранит одну рассылку так, как её сохранила бы БД
+type broadcastStoreMock struct {
+	broadcastStore
+	mu sync.Mutex
+	broadcastState
+}
+
+func (m *broadcastStoreMock) snapshot() broadcastState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.broadcastState
+}
+
+func (m *broadcastStoreMock) UpdateStatus(ctx context.Context, id int64, status string, sentCount, failedCount int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status, m.sent, m.failed = status, sentCount, failedCount
+	return nil
+}
+
+func (m *broadcastStoreMock) UpdateProgress(ctx context.Context, id, lastCustomerID int64, sentCount, failedCount int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastCustomerID, m.sent, m.failed = lastCustomerID, sentCount, failedCount
+	return nil
+}
+
+func (m *broadcastStoreMock) Interrupt(ctx context.Context, id, lastCustomerID int64, sentCount, failedCount int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.status = string(database.BroadcastStatusInterrupted)
+	m.lastCustomerID, m.sent, m.failed = lastCustomerID, sentCount, failedCount
+	return nil
+}
+
+func (m *broadcastStoreMock) ClaimInterrupted(ctx context.Context) ([]database.BroadcastResume, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.status != string(database.BroadcastStatusInterrupted) {
+		return nil, nil
+	}
+	m.status = string(database.BroadcastStatusInProgress)
+	return []database.BroadcastResume{{
+		ID: 1, TargetType: "all", MessageText: "hello", Options: m.options,
+		LastCustomerID: m.lastCustomerID, SentCount: m.sent, FailedCount: m.failed,
+	}}, nil
+}
+
+func (m *broadcastStoreMock) SetOptions(ctx context.Context, id int64, options []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.options = options
+	return nil
+}
+
+func (m *broadcastStoreMock) SetTotalCount(ctx context.Context, id int64, total int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.total, m.status = total, string(database.BroadcastStatusInProgress)
+	return nil
+}
+
+func (m *broadcastStoreMock) SetExcludedCount(ctx context.Context, id int64, excluded int) error {
+	return nil
+}
+
+func (m *broadcastStoreMock) FindExcludedCustomerIDs(ctx context.Context, e database.BroadcastExclusion) (map[int64]bool, error) {
+	return nil, nil
+}
+
+func (m *broadcastStoreMock) RecordDelivery(ctx context.Context, customerID int64, at, windowSince time.Time) error {
+	return nil
+}
+
+// recipientsServer фейковый Bot API: записывает chat_id отправленных сообщений и вызывает onSend после каждой отправки
+type recipientsServer struct {
+	mu     sync.Mutex
+	chats  []string
+	onSend func()
+}
+
+func (f *recipientsServer) serve(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseMultipartForm(1 << 20)
+	f.mu.Lock()
+	f.chats = append(f.chats, r.FormValue("chat_id"))
+	onSend := f.onSend
+	f.mu.Unlock()
+	if onSend != nil {
+		onSend()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": models.Message{ID: 1, Chat: models.Chat{ID: 1}}})
+}
+
+func (f *recipientsServer) sentTo() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.chats...)
+}
+
+func TestBroadcastResumesAfterInterrupt(t *testing.T) {
+	tg := &recipientsServer{}
+	srv := httptest.NewServer(http.HandlerFunc(tg.serve))
+	t.Cleanup(srv.Close)
+	b, err := bot.New("test-token", bot.WithServerURL(srv.URL), bot.WithSkipGetMe())
+	if err != nil {
+		t.Fatalf("bot.New: %v", err)
+	}
+
+	audience := &audienceMock{customers: []database.Customer{
+		{ID: 3, TelegramID: 30}, {ID: 1, TelegramID: 10}, {ID: 2, TelegramID: 20},
+	}}
+	store := &broadcastStoreMock{}
+	opts := &BroadcastOptions{MiniAppURL: "https://app.example.com", Exclusions: Exclusions{Tags: []string{"support"}}}
+
+	// Бот останавливается сразу после первой отправки
+	stopping := make(chan struct{})
+	var stopOnce sync.Once
+	tg.onSend = func() { stopOnce.Do(func() { close(stopping) }) }
+	first := &BroadcastService{bot: b, customerRepository: audience, broadcastRepo: store, stopping: stopping, runningBroadcasts: map[int64]bool{}}
+	if err := first.executeBroadcastWithOptions(context.Background(), 1, "all", "hello", opts, nil); err != nil {
+		t.Fatalf("executeBroadcastWithOptions() returned error: %v", err)
+	}
+
+	interrupted := store.snapshot()
+	if interrupted.status != string(database.BroadcastStatusInterrupted) || interrupted.lastCustomerID != 1 || interrupted.sent != 1 {
+		t.Fatalf("Expected broadcast interrupted after customer 1, got %+v", interrupted)
+	}
+	if got := tg.sentTo(); !slices.Equal(got, []string{"10"}) {
+		t.Fatalf("Expected only the first recipient before shutdown, got %v", got)
+	}
+	var saved BroadcastOptions
+	if err := json.Unmarshal(interrupted.options, &saved); err != nil || saved.MiniAppURL != opts.MiniAppURL || !slices.Equal(saved.Exclusions.Tags, opts.Exclusions.Tags) {
+		t.Fatalf("Expected options to be saved for resume, got %s (err %v)", interrupted.options, err)
+	}
+
+	// После перезапуска рассылка продолжается со следующего получателя
+	tg.mu.Lock()
+	tg.onSend = nil
+	tg.mu.Unlock()
+	restarted := &BroadcastService{bot: b, customerRepository: audience, broadcastRepo: store, stopping: make(chan struct{}), runningBroadcasts: map[int64]bool{}}
+	if err := restarted.ResumeInterrupted(context.Background()); err != nil {
+		t.Fatalf("ResumeInterrupted() returned error: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for store.snapshot().status != string(database.BroadcastStatusCompleted) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Resumed broadcast did not complete, got %+v", store.snapshot())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	completed := store.snapshot()
+	if completed.sent != 3 || completed.failed != 0 || completed.total != 3 {
+		t.Errorf("Expected 3 of 3 sent in total, got %+v", completed)
+	}
+	if got := tg.sentTo(); !slices.Equal(got, []string{"10", "20", "30"}) {
+		t.Errorf("Expected each recipient to get the message once, got %v", got)
+	}
+	if again, err := store.ClaimInterrupted(context.Background()); err != nil || len(again) != 0 {
+		t.Errorf("Expected nothing left to resume, got %+v (err %v)", again, err)
+	}
+}
@@ -0,0 +1,299 @@
+package broadcast
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// Переменные шаблона рассылки. Подставляются для каждого получателя при отправке
+const (
+	VarFirstName = "first_name" // имя получателя в Telegram
+	VarName      = "name"       // то же, что first_name; оставлено для ранее написанных текстов
+	VarExpireAt  = "expire_at"  // дата окончания подписки
+	VarDaysLeft  = "days_left"  // сколько дней осталось до окончания подписки
+	VarTariff    = "tariff"     // текущий тариф
+	VarPrice     = "price"      // цена подписки на месяц
+)
+
+// TemplateVariable переменная шаблона с описанием для админа
+type TemplateVariable struct {
+	Name        string
+	Description string
+}
+
+// TemplateVariables переменные, доступные в тексте рассылки. Показываются админу при вводе текста
+var TemplateVariables = []TemplateVariable{
+	{VarFirstName, "имя получателя"},
+	{VarExpireAt, "дата окончания подписки"},
+	{VarDaysLeft, "дней до окончания подписки"},
+	{VarTariff, "текущий тариф"},
+	{VarPrice, "цена подписки за месяц"},
+}
+
+// allowedTags HTML-теги, которые поддерживает Telegram
+var allowedTags = map[string]bool{
+	"b": true, "strong": true, "i": true, "em": true, "u": true, "ins": true,
+	"s": true, "strike": true, "del": true, "span": true, "tg-spoiler": true,
+	"a": true, "tg-emoji": true, "code": true, "pre": true, "blockquote": true,
+}
+
+// allowedEntities именованные HTML-сущности, которые поддерживает Telegram
+var allowedEntities = map[string]bool{"lt": true, "gt": true, "amp": true, "quot": true}
+
+// TemplateError ошибка в шаблоне рассылки. Line и Column считаются с 1, Column — в символах.
+// Message и Snippet — простой текст, при выводе в HTML их нужно экранировать
+type TemplateError struct {
+	Line    int
+	Column  int
+	Message string
+	// Snippet строка шаблона с ошибкой, позиция ошибки отмечена символом ⚠
+	Snippet string
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("строка %d, символ %d: %s", e.Line, e.Column, e.Message)
+}
+
+// openTag открытый и ещё не закрытый тег
+type openTag struct {
+	name   string
+	offset int
+}
+
+// ValidateTemplate проверяет HTML-разметку и переменные шаблона: поддерживаемые Telegram теги,
+// парность тегов, HTML-сущности и имена переменных. Возвращает *TemplateError с позицией первой ошибки
+func ValidateTemplate(text string) error {
+	var stack []openTag
+	for i := 0; i < len(text); {
+		switch text[i] {
+		case '<':
+			end, name, closing, msg := parseTag(text, i)
+			if msg != "" {
+				return newTemplateError(text, i, msg)
+			}
+			if closing {
+				if len(stack) == 0 {
+					return newTemplateError(text, i, fmt.Sprintf("закрывающий тег </%s> без открывающего", name))
+				}
+				top := stack[len(stack)-1]
+				if top.name != name {
+					return newTemplateError(text, i, fmt.Sprintf("ожидался </%s>, а найден </%s>", top.name, name))
+				}
+				stack = stack[:len(stack)-1]
+			} else {
+				stack = append(stack, openTag{name: name, offset: i})
+			}
+			i = end
+		case '&':
+			end, msg := parseEntity(text, i)
+			if msg != "" {
+				return newTemplateError(text, i, msg)
+			}
+			i = end
+		case '{':
+			end, name, ok := parseVariable(text, i)
+			if ok && !isTemplateVariable(name) {
+				return newTemplateError(text, i, fmt.Sprintf("неизвестная переменная {%s}, доступны: %s", name, variablesList()))
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+	if len(stack) > 0 {
+		top := stack[len(stack)-1]
+		return newTemplateError(text, top.offset, fmt.Sprintf("тег <%s> не закрыт", top.name))
+	}
+	return nil
+}
+
+// parseTag разбирает тег, начинающийся в text[start] == '<'. Возвращает позицию после тега
+// или текст ошибки
+func parseTag(text string, start int) (end int, name string, closing bool, msg string) {
+	i := start + 1
+	if i < len(text) && text[i] == '/' {
+		closing = true
+		i++
+	}
+	nameStart := i
+	for i < len(text) && (text[i] >= 'a' && text[i] <= 'z' || text[i] >= 'A' && text[i] <= 'Z' || text[i] == '-') {
+		i++
+	}
+	name = strings.ToLower(text[nameStart:i])
+	if name == "" {
+		return 0, "", false, "символ < вне тега нужно заменить на &lt;"
+	}
+
+	// Атрибуты до '>' с учётом кавычек
+	attrStart := i
+	var quote byte
+	for ; i < len(text); i++ {
+		c := text[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if c == '"' || c == '\'' {
+			quote = c
+			continue
+		}
+		if c == '>' {
+			break
+		}
+		if c == '<' {
+			i = len(text)
+			break
+		}
+	}
+	if i >= len(text) {
+		return 0, "", false, fmt.Sprintf("тег <%s> не закрыт символом >", name)
+	}
+	attrs := strings.TrimSpace(text[attrStart:i])
+	end = i + 1
+
+	if !allowedTags[name] {
+		return 0, "", false, fmt.Sprintf("тег <%s> не поддерживается Telegram", name)
+	}
+	if closing {
+		if attrs != "" {
+			return 0, "", false, fmt.Sprintf("у закрывающего тега </%s> не может быть атрибутов", name)
+		}
+		return end, name, true, ""
+	}
+	switch {
+	case name == "a" && !strings.Contains(attrs, "href="):
+		return 0, "", false, "у ссылки <a> нет атрибута href"
+	case name == "span" && !strings.Contains(attrs, "tg-spoiler"):
+		return 0, "", false, "тег <span> поддерживается только с class=\"tg-spoiler\""
+	case name == "tg-emoji" && !strings.Contains(attrs, "emoji-id="):
+		return 0, "", false, "у <tg-emoji> нет атрибута emoji-id"
+	}
+	return end, name, false, ""
+}
+
+// parseEntity разбирает HTML-сущность, начинающуюся в text[start] == '&'
+func parseEntity(text string, start int) (end int, msg string) {
+	semi := strings.IndexByte(text[start:], ';')
+	if semi < 0 || semi > 10 {
+		return 0, "символ & нужно заменить на &amp;"
+	}
+	entity := text[start+1 : start+semi]
+	end = start + semi + 1
+	switch {
+	case allowedEntities[entity]:
+		return end, ""
+	case strings.HasPrefix(entity, "#x") || strings.HasPrefix(entity, "#X"):
+		if len(entity) > 2 && strings.Trim(entity[2:], "0123456789abcdefABCDEF") == "" {
+			return end, ""
+		}
+	case strings.HasPrefix(entity, "#"):
+		if len(entity) > 1 && strings.Trim(entity[1:], "0123456789") == "" {
+			return end, ""
+		}
+	case entity != "" && strings.Trim(entity, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") == "":
+		return 0, fmt.Sprintf("HTML-сущность &%s; не поддерживается Telegram, используйте &lt; &gt; &amp; &quot;", entity)
+	}
+	return 0, "символ & нужно заменить на &amp;"
+}
+
+// parseVariable разбирает {name} в text[start] == '{'. ok=false — фигурная скобка не начинает переменную
+func parseVariable(text string, start int) (end int, name string, ok bool) {
+	i := start + 1
+	for i < len(text) && (text[i] >= 'a' && text[i] <= 'z' || text[i] == '_') {
+		i++
+	}
+	if i == start+1 || i >= len(text) || text[i] != '}' {
+		return start + 1, "", false
+	}
+	return i + 1, text[start+1 : i], true
+}
+
+func isTemplateVariable(name string) bool {
+	if name == VarName {
+		return true
+	}
+	for _, v := range TemplateVariables {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func variablesList() string {
+	names := make([]string, len(TemplateVariables))
+	for i, v := range TemplateVariables {
+		names[i] = "{" + v.Name + "}"
+	}
+	return strings.Join(names, ", ")
+}
+
+// newTemplateError считает строку и символ для байтового смещения offset
+func newTemplateError(text string, offset int, msg string) *TemplateError {
+	lineStart := strings.LastIndexByte(text[:offset], '\n') + 1
+	lineEnd := strings.IndexByte(text[offset:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(text)
+	} else {
+		lineEnd += offset
+	}
+	return &TemplateError{
+		Line:    strings.Count(text[:offset], "\n") + 1,
+		Column:  utf8.RuneCountInString(text[lineStart:offset]) + 1,
+		Message: msg,
+		Snippet: text[lineStart:offset] + "⚠" + text[offset:lineEnd],
+	}
+}
+
+// RenderTemplate подставляет переменные в шаблон. Значения экранируются, чтобы не ломать разметку
+func RenderTemplate(text string, vars map[string]string) string {
+	if !strings.Contains(text, "{") {
+		return text
+	}
+	pairs := make([]string, 0, len(vars)*2)
+	for name, value := range vars {
+		pairs = append(pairs, "{"+name+"}", utils.EscapeHTML(value))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// SampleTemplateVars значения переменных для предпросмотра рассылки админу
+func SampleTemplateVars(name string) map[string]string {
+	if name == "" {
+		name = "Иван"
+	}
+	tariff := "—"
+	if tariffs := config.GetTariffs(); config.IsTariffsEnabled() && len(tariffs) > 0 {
+		tariff = tariffs[0].Name
+	}
+	return map[string]string{
+		VarFirstName: name,
+		VarName:      name,
+		VarExpireAt:  locale.FormatDate("ru", clock.Now().Add(30*24*time.Hour)),
+		VarDaysLeft:  "30",
+		VarTariff:    tariff,
+		VarPrice:     locale.FormatMoney("ru", float64(startingPrice())),
+	}
+}
+
+// startingPrice цена подписки на месяц: минимальная среди тарифов, если они включены
+func startingPrice() int {
+	price := config.Price(1)
+	if config.IsTariffsEnabled() {
+		for _, tariff := range config.GetTariffs() {
+			if p := tariff.Price(1); p > 0 && (price <= 0 || p < price) {
+				price = p
+			}
+		}
+	}
+	return price
+}
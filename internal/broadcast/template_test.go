@@ -0,0 +1,78 @@
+package broadcast
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateTemplate(t *testing.T) {
+	valid := []string{
+		"Привет, {name}!",
+		"{first_name}, осталось {days_left} дн. на тарифе {tariff}",
+		"<b>Скидка</b> до {expire_at}: всего <i>{price}</i> &lt;3 &amp; &#128293; &#x1F525;",
+		`<a href="https://t.me/bot?start=1&amp;x=2">Открыть</a>`,
+		`<span class="tg-spoiler">сюрприз</span> <tg-spoiler>ещё</tg-spoiler>`,
+		"<blockquote expandable>цитата\n<pre><code class=\"language-go\">x := 1</code></pre></blockquote>",
+		"JSON: {\"a\": 1} и {} не переменные, 2 > 1",
+	}
+	for _, text := range valid {
+		if err := ValidateTemplate(text); err != nil {
+			t.Errorf("ValidateTemplate(%q) unexpected error: %v", text, err)
+		}
+	}
+
+	invalid := []struct {
+		text   string
+		line   int
+		column int
+		reason string
+	}{
+		{"Привет <b>друг", 1, 8, "не закрыт"},
+		{"<b>жирный</i>", 1, 10, "ожидался </b>"},
+		{"текст</b>", 1, 6, "без открывающего"},
+		{"первая\nвторая <div>блок</div>", 2, 8, "не поддерживается"},
+		{"Цена < 100", 1, 6, "вне тега"},
+		{"Tom & Jerry", 1, 5, "символ &"},
+		{"&nbsp;пробел", 1, 1, "&nbsp;"},
+		{"Привет, {username}", 1, 9, "неизвестная переменная {username}"},
+		{"<a>ссылка</a>", 1, 1, "href"},
+		{"<b class=\"x>жирный</b>", 1, 1, "не закрыт символом >"},
+	}
+	for _, tt := range invalid {
+		err := ValidateTemplate(tt.text)
+		var tmplErr *TemplateError
+		if !errors.As(err, &tmplErr) {
+			t.Errorf("ValidateTemplate(%q) expected TemplateError, got %v", tt.text, err)
+			continue
+		}
+		if tmplErr.Line != tt.line || tmplErr.Column != tt.column || !strings.Contains(tmplErr.Message, tt.reason) {
+			t.Errorf("ValidateTemplate(%q) = %d:%d %q, want %d:%d containing %q",
+				tt.text, tmplErr.Line, tmplErr.Column, tmplErr.Message, tt.line, tt.column, tt.reason)
+		}
+	}
+}
+
+func TestTemplateErrorSnippet(t *testing.T) {
+	err := ValidateTemplate("ок\nЦена < 100\nконец")
+	var tmplErr *TemplateError
+	if !errors.As(err, &tmplErr) {
+		t.Fatalf("expected TemplateError, got %v", err)
+	}
+	if tmplErr.Snippet != "Цена ⚠< 100" {
+		t.Errorf("Snippet = %q", tmplErr.Snippet)
+	}
+}
+
+func TestRenderTemplate(t *testing.T) {
+	vars := map[string]string{VarName: "<Иван>", VarExpireAt: "01.03.2026", VarPrice: "199 ₽"}
+	got := RenderTemplate("<b>{name}</b>, подписка до {expire_at}, продление {price}. {unknown}", vars)
+	want := "<b>&lt;Иван&gt;</b>, подписка до 01.03.2026, продление 199 ₽. {unknown}"
+	if got != want {
+		t.Errorf("RenderTemplate() = %q, want %q", got, want)
+	}
+
+	if got := RenderTemplate("без переменных", nil); got != "без переменных" {
+		t.Errorf("RenderTemplate() without vars = %q", got)
+	}
+}
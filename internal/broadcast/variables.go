@@ -0,0 +1,177 @@
+package broadcast
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/remnawave"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// lastPurchaseFinder последняя оплаченная покупка клиента, из неё берётся тариф
+type lastPurchaseFinder interface {
+	FindLastPaidPurchaseByCustomer(ctx context.Context, customerID int64) (*database.Purchase, error)
+}
+
+// panelUserGetter пользователь панели Remnawave: запасной источник даты окончания и тарифа
+type panelUserGetter interface {
+	GetUserByTelegramID(ctx context.Context, telegramID int64) (*remnawave.UserInfo, error)
+}
+
+// chatGetter информация о чате получателя, из неё берётся имя
+type chatGetter interface {
+	GetChat(ctx context.Context, params *bot.GetChatParams) (*models.ChatFullInfo, error)
+}
+
+// recipientVars вычисляет значения переменных шаблона для каждого получателя рассылки.
+// Внешние запросы делаются только для переменных, которые есть в тексте
+type recipientVars struct {
+	chats     chatGetter
+	purchases lastPurchaseFinder
+	panel     panelUserGetter
+	used      map[string]bool
+	price     int
+	now       func() time.Time
+}
+
+func (s *BroadcastService) newRecipientVars(messageText string) *recipientVars {
+	r := &recipientVars{
+		purchases: s.purchaseRepository,
+		panel:     s.remnawaveClient,
+		used:      usedVariables(messageText),
+		price:     startingPrice(),
+		now:       clock.Now,
+	}
+	// Без проверки на nil интерфейс хранил бы типизированный nil
+	if s.bot != nil {
+		r.chats = s.bot
+	}
+	return r
+}
+
+// usedVariables возвращает переменные шаблона, которые встречаются в тексте
+func usedVariables(text string) map[string]bool {
+	used := map[string]bool{}
+	for i := 0; i < len(text); {
+		if text[i] != '{' {
+			i++
+			continue
+		}
+		end, name, ok := parseVariable(text, i)
+		if ok && isTemplateVariable(name) {
+			used[name] = true
+		}
+		i = end
+	}
+	return used
+}
+
+// resolve возвращает значения переменных для получателя. Если данных нет, подставляется запасное значение,
+// чтобы в сообщении не оставалось {переменных}
+func (r *recipientVars) resolve(ctx context.Context, customer database.Customer) map[string]string {
+	if len(r.used) == 0 {
+		return nil
+	}
+	lang := customer.Language
+	vars := map[string]string{
+		VarFirstName: defaultRecipientName(lang),
+		VarExpireAt:  "—",
+		VarDaysLeft:  "0",
+		VarTariff:    "—",
+		VarPrice:     locale.FormatMoney(lang, float64(r.price)),
+	}
+
+	var panelUser *remnawave.UserInfo
+	panelLoaded := false
+	loadPanelUser := func() *remnawave.UserInfo {
+		if !panelLoaded && r.panel != nil {
+			panelLoaded = true
+			user, err := r.panel.GetUserByTelegramID(ctx, customer.TelegramID)
+			if err != nil {
+				slog.DebugContext(ctx, "Broadcast variables: panel user not loaded", "error", err, "telegramId", utils.MaskHalfInt64(customer.TelegramID))
+			}
+			panelUser = user
+		}
+		return panelUser
+	}
+
+	if r.used[VarExpireAt] || r.used[VarDaysLeft] {
+		expireAt := customer.ExpireAt
+		if expireAt == nil {
+			if user := loadPanelUser(); user != nil && !user.ExpireAt.IsZero() {
+				expireAt = &user.ExpireAt
+			}
+		}
+		if expireAt != nil {
+			vars[VarExpireAt] = locale.FormatDate(lang, *expireAt)
+			vars[VarDaysLeft] = strconv.Itoa(daysLeft(r.now(), *expireAt))
+		}
+	}
+
+	if (r.used[VarFirstName] || r.used[VarName]) && r.chats != nil {
+		chat, err := r.chats.GetChat(ctx, &bot.GetChatParams{ChatID: customer.TelegramID})
+		if err == nil && strings.TrimSpace(chat.FirstName) != "" {
+			vars[VarFirstName] = chat.FirstName
+		}
+	}
+	vars[VarName] = vars[VarFirstName]
+
+	if r.used[VarTariff] {
+		if tariff := r.tariffName(ctx, customer, loadPanelUser); tariff != "" {
+			vars[VarTariff] = tariff
+		}
+	}
+	return vars
+}
+
+// tariffName тариф получателя: из последней оплаченной покупки, настроек автопродления
+// или по лимиту устройств в панели
+func (r *recipientVars) tariffName(ctx context.Context, customer database.Customer, loadPanelUser func() *remnawave.UserInfo) string {
+	if r.purchases != nil {
+		purchase, err := r.purchases.FindLastPaidPurchaseByCustomer(ctx, customer.ID)
+		if err == nil && purchase != nil && purchase.TariffName != nil && *purchase.TariffName != "" {
+			return *purchase.TariffName
+		}
+	}
+	if customer.RecurringTariffName != nil && *customer.RecurringTariffName != "" {
+		return *customer.RecurringTariffName
+	}
+	if !config.IsTariffsEnabled() {
+		return ""
+	}
+	if user := loadPanelUser(); user != nil && user.DeviceLimit != nil {
+		for _, tariff := range config.GetTariffs() {
+			if tariff.Devices == *user.DeviceLimit {
+				return tariff.Name
+			}
+		}
+	}
+	return ""
+}
+
+// daysLeft количество дней до окончания подписки с округлением вверх, для истёкшей подписки 0
+func daysLeft(now, expireAt time.Time) int {
+	if !expireAt.After(now) {
+		return 0
+	}
+	return int(math.Ceil(expireAt.Sub(now).Hours() / 24))
+}
+
+// defaultRecipientName обращение, если имя получателя узнать не удалось
+func defaultRecipientName(langCode string) string {
+	if strings.HasPrefix(langCode, "en") {
+		return "friend"
+	}
+	return "друг"
+}
@@ -0,0 +1,137 @@
+package broadcast
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/remnawave"
+)
+
+type mockChats struct {
+	firstName string
+	err       error
+	calls     int
+}
+
+func (m *mockChats) GetChat(ctx context.Context, params *bot.GetChatParams) (*models.ChatFullInfo, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &models.ChatFullInfo{FirstName: m.firstName}, nil
+}
+
+type mockLastPurchase struct {
+	purchase *database.Purchase
+}
+
+func (m *mockLastPurchase) FindLastPaidPurchaseByCustomer(ctx context.Context, customerID int64) (*database.Purchase, error) {
+	return m.purchase, nil
+}
+
+type mockPanelUsers struct {
+	user  *remnawave.UserInfo
+	calls int
+}
+
+func (m *mockPanelUsers) GetUserByTelegramID(ctx context.Context, telegramID int64) (*remnawave.UserInfo, error) {
+	m.calls++
+	if m.user == nil {
+		return nil, errors.New("user not found")
+	}
+	return m.user, nil
+}
+
+func TestRecipientVarsResolve(t *testing.T) {
+	now := time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)
+	expire := now.Add(36 * time.Hour)
+	tariff := "PRO"
+	recurringTariff := "START"
+
+	chats := &mockChats{firstName: "Анна"}
+	panel := &mockPanelUsers{user: &remnawave.UserInfo{ExpireAt: now.Add(-time.Hour)}}
+	r := &recipientVars{
+		chats:     chats,
+		purchases: &mockLastPurchase{purchase: &database.Purchase{TariffName: &tariff}},
+		panel:     panel,
+		used:      usedVariables("{first_name}, {expire_at} {days_left} {tariff} {price}"),
+		price:     199,
+		now:       func() time.Time { return now },
+	}
+
+	vars := r.resolve(context.Background(), database.Customer{ID: 1, TelegramID: 42, Language: "ru", ExpireAt: &expire})
+	want := map[string]string{VarFirstName: "Анна", VarName: "Анна", VarExpireAt: "03.02.2026", VarDaysLeft: "2", VarTariff: "PRO"}
+	for name, value := range want {
+		if vars[name] != value {
+			t.Errorf("vars[%s] = %q, want %q", name, vars[name], value)
+		}
+	}
+	if vars[VarPrice] == "" {
+		t.Error("Expected price to be set")
+	}
+	if panel.calls != 0 {
+		t.Errorf("Expected no panel requests when customer record has data, got %d", panel.calls)
+	}
+
+	// Нет данных в записи клиента: дата окончания берётся из панели, тариф — из автопродления
+	r.purchases = &mockLastPurchase{}
+	vars = r.resolve(context.Background(), database.Customer{ID: 1, TelegramID: 42, Language: "ru", RecurringTariffName: &recurringTariff})
+	if vars[VarExpireAt] != "01.02.2026" || vars[VarDaysLeft] != "0" || vars[VarTariff] != "START" {
+		t.Errorf("Unexpected fallback values: %v", vars)
+	}
+	if panel.calls != 1 {
+		t.Errorf("Expected 1 panel request, got %d", panel.calls)
+	}
+
+	// Ничего не известно: запасные значения
+	chats.err = errors.New("chat not found")
+	panel.user = nil
+	vars = r.resolve(context.Background(), database.Customer{ID: 1, TelegramID: 42, Language: "en"})
+	if vars[VarFirstName] != "friend" || vars[VarExpireAt] != "—" || vars[VarDaysLeft] != "0" || vars[VarTariff] != "—" {
+		t.Errorf("Unexpected default values: %v", vars)
+	}
+}
+
+func TestRecipientVarsSkipsUnusedLookups(t *testing.T) {
+	chats := &mockChats{firstName: "Анна"}
+	panel := &mockPanelUsers{}
+	r := &recipientVars{chats: chats, panel: panel, used: usedVariables("Скидка {price}, {unknown}"), now: time.Now}
+
+	vars := r.resolve(context.Background(), database.Customer{TelegramID: 42})
+	if chats.calls != 0 || panel.calls != 0 {
+		t.Errorf("Expected no lookups, got chats=%d panel=%d", chats.calls, panel.calls)
+	}
+	if vars == nil {
+		t.Fatal("Expected vars for text with variables")
+	}
+
+	r.used = usedVariables("Без переменных")
+	if vars := r.resolve(context.Background(), database.Customer{TelegramID: 42}); vars != nil {
+		t.Errorf("Expected nil vars for text without variables, got %v", vars)
+	}
+}
+
+func TestDaysLeft(t *testing.T) {
+	now := time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expire time.Time
+		want   int
+	}{
+		{now.Add(-time.Hour), 0},
+		{now, 0},
+		{now.Add(time.Hour), 1},
+		{now.Add(24 * time.Hour), 1},
+		{now.Add(30 * 24 * time.Hour), 30},
+	}
+	for _, tt := range tests {
+		if got := daysLeft(now, tt.expire); got != tt.want {
+			t.Errorf("daysLeft(%v) = %d, want %d", tt.expire.Sub(now), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock источник текущего времени. Позволяет подменять время в тестах
+// и сдвигать его на staging для проверки истечения предложений
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// System системные часы
+var System Clock = systemClock{}
+
+// Offset часы со сдвигом относительно системного времени (машина времени для staging)
+type Offset struct {
+	mu     sync.RWMutex
+	offset time.Duration
+}
+
+func NewOffset() *Offset {
+	return &Offset{}
+}
+
+func (c *Offset) Now() time.Time {
+	return time.Now().Add(c.Offset())
+}
+
+// Offset возвращает текущий сдвиг
+func (c *Offset) Offset() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.offset
+}
+
+// Set устанавливает сдвиг относительно системного времени. 0 — вернуться в настоящее
+func (c *Offset) Set(offset time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset = offset
+}
+
+// Fake часы с ручным управлением для тестов
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (c *Fake) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Set устанавливает текущее время
+func (c *Fake) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+// Advance сдвигает текущее время на d
+func (c *Fake) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+var (
+	defaultMu    sync.RWMutex
+	defaultClock = System
+)
+
+// SetDefault устанавливает глобальные часы, используемые функциями пакета (nil — системные)
+func SetDefault(c Clock) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if c == nil {
+		c = System
+	}
+	defaultClock = c
+}
+
+// Default возвращает глобальные часы
+func Default() Clock {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultClock
+}
+
+// Now возвращает текущее время глобальных часов
+func Now() time.Time {
+	return Default().Now()
+}
@@ -0,0 +1,46 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOffset(t *testing.T) {
+	c := NewOffset()
+	c.Set(72 * time.Hour)
+
+	diff := c.Now().Sub(time.Now())
+	if diff < 71*time.Hour || diff > 73*time.Hour {
+		t.Errorf("Expected clock to be 72h ahead, got %s", diff)
+	}
+
+	c.Set(0)
+	if diff := time.Since(c.Now()); diff < -time.Second || diff > time.Second {
+		t.Errorf("Expected reset clock to match system time, got diff %s", diff)
+	}
+}
+
+func TestFake(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+
+	c.Advance(time.Hour)
+	if !c.Now().Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected %s, got %s", start.Add(time.Hour), c.Now())
+	}
+}
+
+func TestDefault(t *testing.T) {
+	t.Cleanup(func() { SetDefault(nil) })
+
+	now := time.Date(2030, 6, 1, 0, 0, 0, 0, time.UTC)
+	SetDefault(NewFake(now))
+	if !Now().Equal(now) {
+		t.Errorf("Expected default clock to be replaced, got %s", Now())
+	}
+
+	SetDefault(nil)
+	if Default() != System {
+		t.Error("Expected nil to restore system clock")
+	}
+}
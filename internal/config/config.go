@@ -2,15 +2,20 @@ package config
 
 import (
 	"fmt"
-	"log"
 	"log/slog"
+	"math"
 	"os"
+	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
+	"github.com/robfig/cron/v3"
+
+	"remnawave-tg-shop-bot/internal/fieldcrypt"
 )
 
 // Tariff представляет тарифный план с лимитом устройств и ценами
@@ -27,6 +32,36 @@ type Tariff struct {
 	StarsPrice12 int    // Цена за 12 месяцев (звёзды)
 	TributeURL   string // URL для оплаты через Tribute (опционально)
 	TributeName  string // Название подписки в Tribute для матчинга webhook (опционально)
+	// DayPlans посуточные планы (TARIFF_<NAME>_PRICE_<N>D), например на 7 и 14 дней. Отсортированы по сроку
+	DayPlans []DayPlan
+	// SubscriptionPageTemplate шаблон ссылки на подписку для клиентов тарифа, перекрывает SUBSCRIPTION_PAGE_TEMPLATE
+	SubscriptionPageTemplate string
+	// PaymentMethods способы оплаты тарифа (TARIFF_<NAME>_PAYMENT_METHODS): crypto, yookasa, telegram, tribute.
+	// Пусто — все включённые способы
+	PaymentMethods []string
+}
+
+// AllowsPaymentMethod проверяет, что тариф можно оплатить способом method (тип счёта)
+func (t Tariff) AllowsPaymentMethod(method string) bool {
+	return len(t.PaymentMethods) == 0 || slices.Contains(t.PaymentMethods, method)
+}
+
+// supportedMonths периоды подписки, доступные для покупки
+var supportedMonths = []int{1, 3, 6, 12}
+
+// SupportedMonths возвращает периоды подписки (в месяцах), доступные для покупки
+func SupportedMonths() []int {
+	return supportedMonths
+}
+
+// IsSupportedMonth проверяет что период подписки доступен для покупки
+func IsSupportedMonth(month int) bool {
+	for _, m := range supportedMonths {
+		if m == month {
+			return true
+		}
+	}
+	return false
 }
 
 // Price возвращает цену тарифа за указанное количество месяцев
@@ -45,20 +80,26 @@ func (t Tariff) Price(month int) int {
 	}
 }
 
-// StarsPrice возвращает цену в звёздах за указанное количество месяцев
+// StarsPrice возвращает цену в звёздах за указанное количество месяцев.
+// Если цена в звёздах не задана, она пересчитывается из цены в рублях по STARS_RATE
 func (t Tariff) StarsPrice(month int) int {
+	var price int
 	switch month {
 	case 1:
-		return t.StarsPrice1
+		price = t.StarsPrice1
 	case 3:
-		return t.StarsPrice3
+		price = t.StarsPrice3
 	case 6:
-		return t.StarsPrice6
+		price = t.StarsPrice6
 	case 12:
-		return t.StarsPrice12
+		price = t.StarsPrice12
 	default:
-		return t.StarsPrice1
+		price = t.StarsPrice1
 	}
+	if price == 0 {
+		return starsPriceFromRubles(t.Price(month))
+	}
+	return price
 }
 
 // FormatButtonText форматирует текст кнопки тарифа
@@ -71,9 +112,14 @@ type config struct {
 	telegramToken                                             string
 	price1, price3, price6, price12                           int
 	starsPrice1, starsPrice3, starsPrice6, starsPrice12       int
+	starsRate                                                 float64
 	remnawaveUrl, remnawaveToken, remnawaveMode, remnawaveTag string
 	defaultLanguage                                           string
+	// languageFallbacks язык → язык, перевод которого показывается вместо недостающего (uk → ru)
+	languageFallbacks map[string]string
 	databaseURL                                               string
+	databaseReadURL                                           string
+	fieldEncryptionKeys                                       string
 	cryptoPayURL, cryptoPayToken                              string
 	botURL                                                    string
 	yookasaURL, yookasaShopId, yookasaSecretKey, yookasaEmail string
@@ -83,6 +129,7 @@ type config struct {
 	serverStatusURL                                           string
 	supportURL                                                string
 	tosURL                                                    string
+	startMedia                                                map[string]StartMedia
 	isYookasaEnabled                                          bool
 	isCryptoEnabled                                           bool
 	isTelegramStarsEnabled                                    bool
@@ -94,13 +141,21 @@ type config struct {
 	miniApp                                                   string
 	enableAutoPayment                                         bool
 	healthCheckPort                                           int
+	healthCheckProbeSeconds                                   int
+	deletedCustomerRetentionDays                              int
+	customerCacheTTLMs                                        int
 	tributeWebhookUrl, tributeAPIKey, tributePaymentUrl       string
 	isWebAppLinkEnabled                                       bool
+	subscriptionPageTemplate                                  string
 	webhookEnabled                                            bool
 	webhookURL                                                string
 	webhookSecretToken                                        string
+	webhookSelfCheckEnabled                                   bool
 	daysInMonth                                               int
 	externalSquadUUID                                         uuid.UUID
+	// Внешние сквады для покупок по спецпредложениям, uuid.Nil — EXTERNAL_SQUAD_UUID
+	winbackExternalSquadUUID                                  uuid.UUID
+	promoTariffExternalSquadUUID                              uuid.UUID
 	blockedTelegramIds                                        map[int64]bool
 	whitelistedTelegramIds                                    map[int64]bool
 	requirePaidPurchaseForStars                               bool
@@ -119,18 +174,186 @@ type config struct {
 	winbackValidHours                int
 	winbackRecurringEnabled          bool
 	// Remnawave webhooks
-	remnawaveWebhookSecret string
-	remnawaveWebhookPath   string
+	remnawaveWebhookSecret      string
+	remnawaveWebhookPath        string
+	remnawaveWebhookMaxAttempts int
+	// Повторы выдачи оплаченных покупок после временных ошибок
+	fulfillmentRetryMaxAttempts int
+	// Дополнительные Remnawave webhook события
+	firstConnectedNotificationEnabled   bool
+	trafficThresholdNotificationEnabled bool
+	userStatusSyncEnabled               bool
 	// Recurring payments
 	recurringPaymentsEnabled   bool
 	recurringNotifyHoursBefore int
 	// Promo tariff codes
 	promoTariffCodesEnabled      bool
 	promoTariffRecurringEnabled  bool
+	// Daily admin report
+	dailyReportEnabled bool
+	dailyReportHour    int
+	// Alerts
+	alertsEnabled               bool
+	alertChatID                 int64
+	alertCooldownMinutes        int
+	alertHealthFailureThreshold int
+	alertErrorSpikeThreshold    int
+	// Logging
+	logFormat string
+	logLevel  string
+	// Start menu
+	startMenuLayout [][]string
+	menuButtons     map[string]*MenuButton
+	// Mini App API
+	miniAppAPIEnabled       bool
+	miniAppAPIPath          string
+	miniAppInitDataTTLHours int
+	// Purchase archive
+	purchaseArchiveAfterMonths int
+	purchaseArchiveHour        int
+	// Database
+	dbSlowQueryMs int
+	// Activity tracking
+	activityUpdateIntervalMinutes int
+	// Spending limits
+	maxPurchasesPerDay       int
+	maxRecurringAmountPerDay int
+	// Автосписания дороже порога требуют подтверждения клиента
+	recurringConfirmThreshold int
+	// YooKassa notifications
+	yookasaWebhookPath string
+	// Invoice expiration
+	invoiceTTLMinutes int
+	// Staging
+	timeTravelEnabled bool
+	// Payment reconciliation
+	reconciliationEnabled bool
+	reconciliationHour    int
+	// Terms of service acceptance
+	tosVersion string
+	// Sandbox payments
+	sandboxPaymentsEnabled bool
+	yookasaTestShopId      string
+	yookasaTestSecretKey   string
+	// Start menu status card
+	statusCardCacheSeconds int
+	// Server status screen
+	serverStatusCacheSeconds int
+	serverStatusPublic       bool
+	// Clean chat mode
+	cleanChatMode string
+	// Background job schedules
+	cronSchedules map[string]string
+	// Remnawave client resilience
+	remnawaveTimeoutSeconds         int
+	remnawaveTimeoutBudgetSeconds   int
+	remnawaveRetries                int
+	remnawaveBreakerThreshold       int
+	remnawaveBreakerCooldownSeconds int
+	// Graceful shutdown
+	shutdownTimeoutSeconds int
+	// Trial phone verification
+	trialPhoneVerificationEnabled bool
+	trialPhoneHashSecret          string
+	// Team plans
+	teamPlansEnabled   bool
+	teamSeatOptions    []int
+	teamSeatPrice      int
+	teamSeatStarsPrice int
+	// Подарочные подписки за звёзды
+	giftsEnabled bool
+	// Winback для ушедших платящих клиентов
+	winbackPaidEnabled         bool
+	winbackPaidDays            []int
+	winbackPaidDiscountPercent int
+	winbackPaidValidHours      int
+	// Счета и квитанции об оплате
+	receiptsEnabled     bool
+	receiptOperator     ReceiptOperator
+	receiptNumberPrefix string
+	// Напоминания о неоплаченных счетах
+	checkoutReminderEnabled      bool
+	checkoutReminderDelayMinutes int
+	// Сколько минут можно отменить массовое отключение автопродления из админки
+	recurringBulkUndoMinutes int
+	// Напоминания о скором окончании промо-тарифа и winback предложения
+	offerReminderEnabled     bool
+	offerReminderHoursBefore int
+	// Цепочка сообщений после активации триала
+	trialUpsellEnabled            bool
+	trialUpsellSteps              []TrialUpsellStep
+	trialUpsellDiscountPercent    int
+	trialUpsellDiscountValidHours int
+	// Отключение или удаление в панели пользователей с давно истёкшим триалом
+	trialCleanupEnabled   bool
+	trialCleanupAfterDays int
+	trialCleanupMode      string
+	// События для внешних интеграций
+	eventsWebhookURL            string
+	eventsWebhookSecret         string
+	eventsWebhookTimeoutSeconds int
+	eventTypes                  []string
+	// Лог-канал в Telegram с карточками ключевых событий
+	logChannelID           int64
+	logChannelEventTypes   []string
+	logChannelMaxPerMinute int
+	// Продуктовая аналитика в PostHog или Amplitude
+	analyticsProvider     string
+	analyticsURL          string
+	analyticsAPIKey       string
+	analyticsSalt         string
+	analyticsBatchSize    int
+	analyticsFlushSeconds int
+	analyticsEnabled      bool
+	// Смена ссылки подписки клиентом
+	subscriptionRotationLimit int
+	// Лимит частоты рассылок на клиента
+	broadcastFrequencyCap        int
+	broadcastFrequencyWindowDays int
+	// Выгрузка и удаление данных самим клиентом (/privacy)
+	privacySelfServiceEnabled bool
+	// Inline режим: карточки с реферальной ссылкой, промокодами и тарифами для отправки в любой чат
+	inlineModeEnabled bool
+	inlinePromoCodes  []string
+	// Раздел FAQ в главном меню
+	faqEnabled bool
+	// Опрос удовлетворённости после продления и обращения в поддержку
+	surveyEnabled      bool
+	surveyIntervalDays int
+	// Кнопки 👍/👎 под winback и маркетинговыми уведомлениями
+	notificationFeedbackEnabled bool
+	// Синхронизация тегов пользователей в панели по тарифу, триалу и winback
+	remnawaveTagSyncEnabled bool
+	winbackRemnawaveTag     string
+	// Политика повторного триала
+	trialPolicy         string
+	trialCooldownMonths int
+	// Наценка или скидка способа оплаты в процентах, ключ — тип счёта (crypto, yookasa, telegram)
+	paymentFeePercents map[string]int
+	// Минимальные суммы счёта платёжных систем и цены, которые в них не укладываются
+	paymentMinAmounts     map[string]int
+	paymentAmountWarnings []string
+	// Часовой пояс расписаний и отчётов, часы доставки уведомлений по местному времени клиента
+	timezone                *time.Location
+	notifyLocalHoursEnabled bool
+	notifyLocalFromHour     int
+	notifyLocalToHour       int
+}
+
+// ReceiptOperator реквизиты продавца, которые печатаются в квитанции об оплате
+type ReceiptOperator struct {
+	Name    string // название организации или ФИО ИП/самозанятого
+	TaxID   string // ИНН
+	Address string
+	Contact string // email или телефон для вопросов по оплате
+	VATNote string // строка про НДС, например "НДС не облагается"
 }
 
 var conf config
 
+// remnawaveTagRegex формат тега пользователя, который принимает панель
+var remnawaveTagRegex = regexp.MustCompile(`^[A-Z0-9_]{0,16}$`)
+
 func RemnawaveTag() string {
 	return conf.remnawaveTag
 }
@@ -142,9 +365,25 @@ func TrialRemnawaveTag() string {
 	return conf.remnawaveTag
 }
 
+// IsRemnawaveTagSyncEnabled возвращает true если тег пользователя в панели меняется вместе с тарифом
+func IsRemnawaveTagSyncEnabled() bool {
+	return conf.remnawaveTagSyncEnabled
+}
+
+// WinbackRemnawaveTag тег панели для покупок по winback предложению (пусто — тег тарифа)
+func WinbackRemnawaveTag() string {
+	return conf.winbackRemnawaveTag
+}
+
 func DefaultLanguage() string {
 	return conf.defaultLanguage
 }
+
+// LanguageFallbacks возвращает запасные языки переводов: для ключа — язык клиента, для значения — язык,
+// перевод которого показывается, если в языке клиента нет строки (перед DEFAULT_LANGUAGE)
+func LanguageFallbacks() map[string]string {
+	return conf.languageFallbacks
+}
 func GetTributeWebHookUrl() string {
 	return conf.tributeWebhookUrl
 }
@@ -161,6 +400,8 @@ func GetReferralDays() int {
 }
 
 func GetMiniAppURL() string {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return conf.miniApp
 }
 
@@ -198,22 +439,46 @@ func TrialDays() int {
 	return conf.trialDays
 }
 func FeedbackURL() string {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return conf.feedbackURL
 }
 
 func ChannelURL() string {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return conf.channelURL
 }
 
 func ServerStatusURL() string {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return conf.serverStatusURL
 }
 
+// IsServerStatusPublic возвращает true если кнопка статуса серверов в меню открывает сводку по нодам в боте,
+// а не только ссылку SERVER_STATUS_URL
+func IsServerStatusPublic() bool {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return conf.serverStatusPublic
+}
+
+// ServerStatusCacheSeconds возвращает, сколько секунд кешируется статус серверов из Remnawave.
+// 0 — панель запрашивается при каждом открытии экрана
+func ServerStatusCacheSeconds() int {
+	return conf.serverStatusCacheSeconds
+}
+
 func SupportURL() string {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return conf.supportURL
 }
 
 func TosURL() string {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return conf.tosURL
 }
 
@@ -222,18 +487,26 @@ func YookasaEmail() string {
 }
 
 func Price1() int {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return conf.price1
 }
 
 func Price3() int {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return conf.price3
 }
 
 func Price6() int {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return conf.price6
 }
 
 func Price12() int {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return conf.price12
 }
 
@@ -245,35 +518,148 @@ func ExternalSquadUUID() uuid.UUID {
 	return conf.externalSquadUUID
 }
 
+// WinbackExternalSquadUUID внешний сквад для покупок по winback предложению (uuid.Nil — EXTERNAL_SQUAD_UUID)
+func WinbackExternalSquadUUID() uuid.UUID {
+	return conf.winbackExternalSquadUUID
+}
+
+// PromoTariffExternalSquadUUID внешний сквад для покупок по промо-тарифу (uuid.Nil — EXTERNAL_SQUAD_UUID)
+func PromoTariffExternalSquadUUID() uuid.UUID {
+	return conf.promoTariffExternalSquadUUID
+}
+
 func Price(month int) int {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return conf.price(month)
+}
+
+func (c *config) price(month int) int {
 	switch month {
 	case 1:
-		return conf.price1
+		return c.price1
 	case 3:
-		return conf.price3
+		return c.price3
 	case 6:
-		return conf.price6
+		return c.price6
 	case 12:
-		return conf.price12
+		return c.price12
 	default:
-		return conf.price1
+		return c.price1
 	}
 }
 
 func StarsPrice(month int) int {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return conf.starsPrice(month)
+}
+
+func (c *config) starsPrice(month int) int {
 	switch month {
 	case 1:
-		return conf.starsPrice1
+		return c.starsPrice1
 	case 3:
-		return conf.starsPrice3
+		return c.starsPrice3
 	case 6:
-		return conf.starsPrice6
+		return c.starsPrice6
 	case 12:
-		return conf.starsPrice12
+		return c.starsPrice12
 	default:
-		return conf.starsPrice1
+		return c.starsPrice1
+	}
+}
+// PaymentFeePercent возвращает наценку (положительное число) или скидку (отрицательное) способа оплаты
+// в процентах. method — тип счёта: crypto, yookasa или telegram
+func PaymentFeePercent(method string) int {
+	return conf.paymentFeePercents[method]
+}
+
+// ApplyPaymentFee возвращает цену с наценкой или скидкой способа оплаты, округлённую до целого.
+// Цена не опускается ниже 1
+func ApplyPaymentFee(method string, price int) int {
+	percent := PaymentFeePercent(method)
+	if percent == 0 || price <= 0 {
+		return price
+	}
+	return max(int(math.Round(float64(price)*float64(100+percent)/100)), 1)
+}
+
+// Лимиты суммы счёта в Telegram Stars, задаются Telegram
+const (
+	StarsMinAmount = 1
+	StarsMaxAmount = 10000
+)
+
+// PaymentAmountRange возвращает допустимую сумму счёта способа оплаты: рубли для crypto и yookasa,
+// звёзды для telegram. maxAmount 0 — без ограничения сверху
+func PaymentAmountRange(method string) (minAmount, maxAmount int) {
+	if method == "telegram" {
+		return StarsMinAmount, StarsMaxAmount
+	}
+	return max(conf.paymentMinAmounts[method], 1), 0
+}
+
+// IsValidPaymentAmount возвращает true если платёжная система примет счёт на amount
+func IsValidPaymentAmount(method string, amount int) bool {
+	minAmount, maxAmount := PaymentAmountRange(method)
+	return amount >= minAmount && (maxAmount == 0 || amount <= maxAmount)
+}
+
+// PaymentAmount возвращает сумму счёта для цены периода с наценкой или скидкой способа оплаты.
+// Если цена вне лимитов платёжной системы, ok=false и способ оплаты для этой цены не предлагается.
+// Скидка способа не опускает сумму ниже минимума платёжной системы: сумма округляется до минимума
+func PaymentAmount(method string, price int) (amount int, ok bool) {
+	if price <= 0 || !IsValidPaymentAmount(method, price) {
+		return price, false
+	}
+	minAmount, _ := PaymentAmountRange(method)
+	amount = max(ApplyPaymentFee(method, price), minAmount)
+	return amount, IsValidPaymentAmount(method, amount)
+}
+
+// RecurringListPrice возвращает текущую сумму автопродления картой для тарифа tariffName
+// (пустое имя — цены PRICE_*) на months месяцев. 0 — тариф не найден или цена не задана
+func RecurringListPrice(tariffName string, months int) int {
+	price := Price(months)
+	if tariffName != "" {
+		tariff := GetTariffByName(tariffName)
+		if tariff == nil {
+			return 0
+		}
+		price = tariff.Price(months)
+	}
+	amount, ok := PaymentAmount("yookasa", price)
+	if !ok {
+		return 0
+	}
+	return amount
+}
+
+// Timezone возвращает часовой пояс бота (TIMEZONE, по умолчанию часовой пояс сервера): в нём работают
+// расписания CRON_*, считаются сутки в отчётах и показывается время админу. Он же используется для клиентов,
+// не указавших свой часовой пояс
+func Timezone() *time.Location {
+	if conf.timezone == nil {
+		return time.Local
 	}
+	return conf.timezone
+}
+
+// NotifyLocalHours возвращает часы [from, to) по местному времени клиента, в которые доставляются
+// напоминания об окончании подписки и winback предложения (NOTIFY_LOCAL_HOURS). enabled=false — без ограничения
+func NotifyLocalHours() (from, to int, enabled bool) {
+	return conf.notifyLocalFromHour, conf.notifyLocalToHour, conf.notifyLocalHoursEnabled
 }
+
+// PaymentAmountWarnings возвращает цены, которые не укладываются в лимиты включённых платёжных систем.
+// Кнопки оплаты для них скрываются, админ получает алерт при запуске
+func PaymentAmountWarnings() []string {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return conf.paymentAmountWarnings
+}
+
 func TelegramToken() string {
 	return conf.telegramToken
 }
@@ -283,6 +669,16 @@ func RemnawaveUrl() string {
 func DadaBaseUrl() string {
 	return conf.databaseURL
 }
+
+// FieldEncryptionKeys ключи шифрования колонок БД "id:base64,..." (первый — текущий). Пустая строка — шифрование выключено
+func FieldEncryptionKeys() string {
+	return conf.fieldEncryptionKeys
+}
+
+// DatabaseReadURL DSN реплики только для чтения для отчётов и сегментов рассылок. Пустая строка — всё читается из основной БД
+func DatabaseReadURL() string {
+	return conf.databaseReadURL
+}
 func RemnawaveToken() string {
 	return conf.remnawaveToken
 }
@@ -338,6 +734,22 @@ func GetHealthCheckPort() int {
 	return conf.healthCheckPort
 }
 
+// HealthCheckProbeSeconds возвращает, как часто /healthcheck в фоне проверяет Remnawave, в секундах
+func HealthCheckProbeSeconds() int {
+	return conf.healthCheckProbeSeconds
+}
+
+// CustomerCacheTTL возвращает, сколько клиент хранится в кеше поиска по Telegram ID. 0 — кеш выключен
+func CustomerCacheTTL() time.Duration {
+	return time.Duration(conf.customerCacheTTLMs) * time.Millisecond
+}
+
+// DeletedCustomerRetentionDays возвращает, сколько дней хранятся клиенты, удалённые синхронизацией,
+// прежде чем админ может удалить их окончательно
+func DeletedCustomerRetentionDays() int {
+	return conf.deletedCustomerRetentionDays
+}
+
 func IsWepAppLinkEnabled() bool {
 	return conf.isWebAppLinkEnabled
 }
@@ -354,6 +766,11 @@ func WebhookSecretToken() string {
 	return conf.webhookSecretToken
 }
 
+// IsWebhookSelfCheckEnabled возвращает true если перед установкой webhook нужно проверять его доступность запросом к самому себе
+func IsWebhookSelfCheckEnabled() bool {
+	return conf.webhookSelfCheckEnabled
+}
+
 func RemnawaveHeaders() map[string]string {
 	return conf.remnawaveHeaders
 }
@@ -368,11 +785,15 @@ func TrafficLimitResetStrategy() string {
 
 // GetTariffs возвращает все включённые тарифы
 func GetTariffs() []Tariff {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return conf.tariffs
 }
 
 // GetTariffByName возвращает тариф по имени или nil если не найден
 func GetTariffByName(name string) *Tariff {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	for i := range conf.tariffs {
 		if conf.tariffs[i].Name == name {
 			return &conf.tariffs[i]
@@ -383,6 +804,8 @@ func GetTariffByName(name string) *Tariff {
 
 // GetTariffByTributeName возвращает тариф по названию подписки Tribute или nil если не найден
 func GetTariffByTributeName(tributeName string) *Tariff {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	for i := range conf.tariffs {
 		if conf.tariffs[i].TributeName != "" && conf.tariffs[i].TributeName == tributeName {
 			return &conf.tariffs[i]
@@ -393,12 +816,16 @@ func GetTariffByTributeName(tributeName string) *Tariff {
 
 // IsTariffsEnabled возвращает true если есть хотя бы один включённый тариф
 func IsTariffsEnabled() bool {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return len(conf.tariffs) > 0
 }
 
 // GetAllTariffDeviceLimits возвращает список всех лимитов устройств из тарифов
 // Включает также WINBACK_DEVICES чтобы winback лимит не считался кастомным
 func GetAllTariffDeviceLimits() []int {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	// Используем map для уникальности
 	limitsMap := make(map[int]bool)
 	
@@ -424,37 +851,45 @@ func GetAllTariffDeviceLimits() []int {
 
 // IsTrialInactiveNotificationEnabled возвращает true если уведомления о неактивности триала включены
 func IsTrialInactiveNotificationEnabled() bool {
-	return conf.trialInactiveNotificationEnabled
+	return featureEnabled(FeatureTrialInactiveNotification, conf.trialInactiveNotificationEnabled)
 }
 
 // IsWinbackEnabled возвращает true если winback предложения включены
 func IsWinbackEnabled() bool {
-	return conf.winbackEnabled
+	return featureEnabled(FeatureWinback, conf.winbackEnabled)
 }
 
 // GetWinbackPrice возвращает цену winback предложения в рублях
 func GetWinbackPrice() int {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return conf.winbackPrice
 }
 
 // GetWinbackDevices возвращает лимит устройств для winback предложения
 func GetWinbackDevices() int {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return conf.winbackDevices
 }
 
 // GetWinbackMonths возвращает период подписки для winback предложения в месяцах
 func GetWinbackMonths() int {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return conf.winbackMonths
 }
 
 // GetWinbackValidHours возвращает срок действия winback предложения в часах
 func GetWinbackValidHours() int {
+	confMu.RLock()
+	defer confMu.RUnlock()
 	return conf.winbackValidHours
 }
 
 // IsWinbackRecurringEnabled возвращает true если автопродление для winback включено
 func IsWinbackRecurringEnabled() bool {
-	return conf.winbackRecurringEnabled
+	return featureEnabled(FeatureWinbackRecurring, conf.winbackRecurringEnabled)
 }
 
 // GetRemnawaveWebhookSecret возвращает секрет для валидации подписи Remnawave webhooks
@@ -467,9 +902,36 @@ func GetRemnawaveWebhookPath() string {
 	return conf.remnawaveWebhookPath
 }
 
+// GetRemnawaveWebhookMaxAttempts возвращает максимальное количество попыток обработки webhook события
+func GetRemnawaveWebhookMaxAttempts() int {
+	return conf.remnawaveWebhookMaxAttempts
+}
+
+// GetFulfillmentRetryMaxAttempts возвращает максимальное количество попыток выдачи оплаченной покупки
+// после временной ошибки, включая первую
+func GetFulfillmentRetryMaxAttempts() int {
+	return conf.fulfillmentRetryMaxAttempts
+}
+
+// IsFirstConnectedNotificationEnabled возвращает true если приветствие после первого подключения включено
+func IsFirstConnectedNotificationEnabled() bool {
+	return featureEnabled(FeatureFirstConnectedNotification, conf.firstConnectedNotificationEnabled)
+}
+
+// IsTrafficThresholdNotificationEnabled возвращает true если уведомления о расходе трафика включены
+func IsTrafficThresholdNotificationEnabled() bool {
+	return featureEnabled(FeatureTrafficThresholdNotification, conf.trafficThresholdNotificationEnabled)
+}
+
+// IsUserStatusSyncEnabled возвращает true если синхронизация по событиям user.enabled/user.disabled включена
+func IsUserStatusSyncEnabled() bool {
+	return featureEnabled(FeatureUserStatusSync, conf.userStatusSyncEnabled)
+}
+
 // IsRecurringPaymentsEnabled возвращает true если рекуррентные платежи включены
 func IsRecurringPaymentsEnabled() bool {
-	return conf.recurringPaymentsEnabled
+	// Автосписания работают только через ЮKassa, включение из админки без неё ничего не даёт
+	return featureEnabled(FeatureRecurringPayments, conf.recurringPaymentsEnabled) && conf.isYookasaEnabled
 }
 
 // GetRecurringNotifyHoursBefore возвращает количество часов до списания для уведомления
@@ -479,265 +941,963 @@ func GetRecurringNotifyHoursBefore() int {
 
 // IsPromoTariffCodesEnabled возвращает true если промокоды на тариф включены
 func IsPromoTariffCodesEnabled() bool {
-	return conf.promoTariffCodesEnabled
+	return featureEnabled(FeaturePromoTariffCodes, conf.promoTariffCodesEnabled)
 }
 
 // IsPromoTariffRecurringEnabled возвращает true если автопродление для promo tariff включено
 func IsPromoTariffRecurringEnabled() bool {
-	return conf.promoTariffRecurringEnabled
+	return featureEnabled(FeaturePromoTariffRecurring, conf.promoTariffRecurringEnabled)
 }
 
-const bytesInGigabyte = 1073741824
+// IsDailyReportEnabled возвращает true если ежедневная сводка для админа включена
+func IsDailyReportEnabled() bool {
+	return featureEnabled(FeatureDailyReport, conf.dailyReportEnabled)
+}
 
-func mustEnv(key string) string {
-	v := os.Getenv(key)
-	if v == "" {
-		log.Panicf("env %q not set", key)
-	}
-	return v
+// GetDailyReportHour возвращает час (0-23), в который отправляется ежедневная сводка
+func GetDailyReportHour() int {
+	return conf.dailyReportHour
 }
 
-func mustEnvInt(key string) int {
-	v := mustEnv(key)
-	i, err := strconv.Atoi(v)
-	if err != nil {
-		log.Panicf("invalid int in %q: %v", key, err)
-	}
-	return i
+// GetLogFormat возвращает формат логов: text или json
+func GetLogFormat() string {
+	return conf.logFormat
 }
 
-func envIntDefault(key string, def int) int {
-	v := os.Getenv(key)
-	if v == "" {
-		return def
-	}
-	i, err := strconv.Atoi(v)
-	if err != nil {
-		log.Panicf("invalid int in %q: %v", key, err)
-	}
-	return i
+// GetLogLevel возвращает уровень логирования: debug, info, warn или error
+func GetLogLevel() string {
+	return conf.logLevel
 }
 
-func envStringDefault(key string, def string) string {
-	v := os.Getenv(key)
-	if v == "" {
-		return def
-	}
-	return v
+// IsMiniAppAPIEnabled возвращает true если JSON API для Mini App включено
+func IsMiniAppAPIEnabled() bool {
+	return conf.miniAppAPIEnabled
 }
 
-func envBool(key string) bool {
-	return os.Getenv(key) == "true"
+// GetMiniAppAPIPath возвращает префикс маршрутов JSON API для Mini App
+func GetMiniAppAPIPath() string {
+	return conf.miniAppAPIPath
 }
 
-// parseTariffs парсит тарифы из ENV переменных по паттерну TARIFF_<NAME>_*
-// Поддерживает имена с подчёркиванием: TARIFF_SUPER_PRO_ENABLED → name = "SUPER_PRO"
-func parseTariffs() []Tariff {
-	var tariffs []Tariff
-	seen := make(map[string]bool)
+// GetMiniAppInitDataTTLHours возвращает срок действия initData Mini App в часах
+func GetMiniAppInitDataTTLHours() int {
+	return conf.miniAppInitDataTTLHours
+}
 
-	// Известные суффиксы для определения конца имени тарифа
-	knownSuffixes := []string{"_ENABLED", "_DEVICES", "_PRICE_1", "_PRICE_3", "_PRICE_6", "_PRICE_12",
-		"_STARS_PRICE_1", "_STARS_PRICE_3", "_STARS_PRICE_6", "_STARS_PRICE_12",
-		"_TRIBUTE_URL", "_TRIBUTE_NAME"}
+// GetPurchaseArchiveAfterMonths возвращает возраст покупок (в месяцах), после которого они переносятся в архив (0 — архивация выключена)
+func GetPurchaseArchiveAfterMonths() int {
+	return conf.purchaseArchiveAfterMonths
+}
 
-	// Собираем все уникальные имена тарифов из ENV
-	for _, env := range os.Environ() {
-		if !strings.HasPrefix(env, "TARIFF_") {
-			continue
-		}
-		parts := strings.SplitN(env, "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
-		key := parts[0]
+// GetPurchaseArchiveHour возвращает час (0-23), в который запускается архивация покупок
+func GetPurchaseArchiveHour() int {
+	return conf.purchaseArchiveHour
+}
 
-		// Извлекаем имя тарифа: TARIFF_<NAME>_<SUFFIX> → NAME
-		// Ищем известный суффикс и отрезаем его
-		var name string
-		for _, suffix := range knownSuffixes {
-			if strings.HasSuffix(key, suffix) {
-				// Убираем "TARIFF_" в начале и суффикс в конце
-				name = strings.TrimPrefix(key, "TARIFF_")
-				name = strings.TrimSuffix(name, suffix)
-				break
-			}
-		}
-		if name == "" {
-			continue
-		}
-		seen[name] = true
-	}
+// GetDBSlowQueryMs возвращает порог (в миллисекундах), после которого запрос к БД логируется как медленный (0 — не логировать)
+func GetDBSlowQueryMs() int {
+	return conf.dbSlowQueryMs
+}
 
-	// Парсим каждый найденный тариф
-	for name := range seen {
-		prefix := "TARIFF_" + name + "_"
+// GetActivityUpdateIntervalMinutes возвращает минимальный интервал (в минутах) между обновлениями last_active_at пользователя
+func GetActivityUpdateIntervalMinutes() int {
+	return conf.activityUpdateIntervalMinutes
+}
 
-		// Проверяем включён ли тариф
-		if !envBool(prefix + "ENABLED") {
-			slog.Debug("Tariff disabled or not enabled", "name", name)
-			continue
-		}
+// GetMaxPurchasesPerDay возвращает максимальное количество покупок пользователя за сутки (0 — без ограничения)
+func GetMaxPurchasesPerDay() int {
+	return conf.maxPurchasesPerDay
+}
 
-		// Парсим devices (обязательное поле)
-		devicesStr := os.Getenv(prefix + "DEVICES")
-		if devicesStr == "" {
-			slog.Warn("Tariff missing DEVICES, skipping", "name", name)
-			continue
-		}
-		devices, err := strconv.Atoi(devicesStr)
-		if err != nil {
-			slog.Warn("Tariff invalid DEVICES value, skipping", "name", name, "error", err)
-			continue
-		}
+// GetMaxRecurringAmountPerDay возвращает максимальную сумму автосписаний с одного клиента за сутки (0 — без ограничения)
+func GetMaxRecurringAmountPerDay() int {
+	return conf.maxRecurringAmountPerDay
+}
 
-		tariff := Tariff{
-			Name:    name,
-			Devices: devices,
-		}
+// RecurringConfirmThreshold возвращает сумму автосписания, выше которой нужно подтверждение клиента (0 — не нужно)
+func RecurringConfirmThreshold() int {
+	return conf.recurringConfirmThreshold
+}
 
-		// Парсим цены (обязательные)
-		price1Str := os.Getenv(prefix + "PRICE_1")
-		price3Str := os.Getenv(prefix + "PRICE_3")
-		price6Str := os.Getenv(prefix + "PRICE_6")
-		price12Str := os.Getenv(prefix + "PRICE_12")
+// RecurringChargeNeedsConfirmation возвращает true если автосписание amount нужно подтвердить за сутки до списания
+func RecurringChargeNeedsConfirmation(amount int) bool {
+	return conf.recurringConfirmThreshold > 0 && amount > conf.recurringConfirmThreshold
+}
 
-		if price1Str == "" || price3Str == "" || price6Str == "" || price12Str == "" {
-			slog.Warn("Tariff missing price fields, skipping", "name", name)
-			continue
-		}
+// GetYookasaWebhookPath возвращает путь для HTTP-уведомлений ЮKassa о возвратах (пусто — отключено)
+func GetYookasaWebhookPath() string {
+	return conf.yookasaWebhookPath
+}
 
-		tariff.Price1, err = strconv.Atoi(price1Str)
-		if err != nil {
-			slog.Warn("Tariff invalid PRICE_1, skipping", "name", name, "error", err)
-			continue
-		}
-		tariff.Price3, err = strconv.Atoi(price3Str)
-		if err != nil {
-			slog.Warn("Tariff invalid PRICE_3, skipping", "name", name, "error", err)
-			continue
-		}
-		tariff.Price6, err = strconv.Atoi(price6Str)
-		if err != nil {
-			slog.Warn("Tariff invalid PRICE_6, skipping", "name", name, "error", err)
-			continue
-		}
-		tariff.Price12, err = strconv.Atoi(price12Str)
-		if err != nil {
-			slog.Warn("Tariff invalid PRICE_12, skipping", "name", name, "error", err)
-			continue
-		}
+// GetInvoiceTTLMinutes возвращает время жизни неоплаченного счёта в минутах (0 — счета не истекают)
+func GetInvoiceTTLMinutes() int {
+	return conf.invoiceTTLMinutes
+}
 
-		// Парсим цены в звёздах (опциональные, по умолчанию = обычным ценам)
-		tariff.StarsPrice1 = envIntDefault(prefix+"STARS_PRICE_1", tariff.Price1)
-		tariff.StarsPrice3 = envIntDefault(prefix+"STARS_PRICE_3", tariff.Price3)
-		tariff.StarsPrice6 = envIntDefault(prefix+"STARS_PRICE_6", tariff.Price6)
-		tariff.StarsPrice12 = envIntDefault(prefix+"STARS_PRICE_12", tariff.Price12)
+// IsTimeTravelEnabled возвращает true если включена скрытая команда админа /time_travel (только для staging)
+func IsTimeTravelEnabled() bool {
+	return conf.timeTravelEnabled
+}
 
-		// Парсим Tribute поля (опциональные)
-		tariff.TributeURL = os.Getenv(prefix + "TRIBUTE_URL")
-		tariff.TributeName = os.Getenv(prefix + "TRIBUTE_NAME")
+// IsReconciliationEnabled возвращает true если включена ежедневная сверка платежей с провайдерами
+func IsReconciliationEnabled() bool {
+	return conf.reconciliationEnabled
+}
 
-		tariffs = append(tariffs, tariff)
-		slog.Info("Loaded tariff", "name", name, "devices", devices,
-			"price1", tariff.Price1, "price3", tariff.Price3,
-			"price6", tariff.Price6, "price12", tariff.Price12,
-			"tributeURL", tariff.TributeURL != "", "tributeName", tariff.TributeName)
-	}
+// GetReconciliationHour возвращает час (0-23), в который выполняется сверка платежей за прошедшие сутки
+func GetReconciliationHour() int {
+	return conf.reconciliationHour
+}
 
-	// Сортируем тарифы по количеству устройств (от меньшего к большему)
-	// Это логичный порядок: START (3) → PRO (5) → PREMIUM (10)
-	sort.Slice(tariffs, func(i, j int) bool {
-		if tariffs[i].Devices != tariffs[j].Devices {
-			return tariffs[i].Devices < tariffs[j].Devices
-		}
-		// При равном количестве устройств — по имени
-		return tariffs[i].Name < tariffs[j].Name
-	})
+// IsTosAcceptanceRequired возвращает true если перед первым триалом или покупкой
+// пользователь должен принять условия использования (задан TOS_URL)
+func IsTosAcceptanceRequired() bool {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return conf.tosURL != ""
+}
 
-	return tariffs
+// TosVersion возвращает текущую версию условий использования. При её смене пользователи принимают условия заново
+func TosVersion() string {
+	return conf.tosVersion
 }
 
-func InitConfig() {
-	if os.Getenv("DISABLE_ENV_FILE") != "true" {
-		if err := godotenv.Load(".env"); err != nil {
-			log.Println("No .env loaded:", err)
-		}
-	}
-	var err error
-	conf.adminTelegramId, err = strconv.ParseInt(os.Getenv("ADMIN_TELEGRAM_ID"), 10, 64)
-	if err != nil {
-		panic("ADMIN_TELEGRAM_ID .env variable not set")
-	}
+// IsSandboxPaymentsEnabled возвращает true если админу доступны тестовые оплаты. Тестовые покупки
+// проходят полную выдачу подписки, но не учитываются в статистике и сверке платежей
+func IsSandboxPaymentsEnabled() bool {
+	return featureEnabled(FeatureSandboxPayments, conf.sandboxPaymentsEnabled)
+}
 
-	conf.telegramToken = mustEnv("TELEGRAM_TOKEN")
+// IsYookasaTestShopEnabled возвращает true если для тестовых оплат задан тестовый магазин ЮKassa
+func IsYookasaTestShopEnabled() bool {
+	return IsSandboxPaymentsEnabled() && conf.yookasaTestShopId != "" && conf.yookasaTestSecretKey != ""
+}
 
-	conf.isWebAppLinkEnabled = func() bool {
-		isWebAppLinkEnabled := os.Getenv("IS_WEB_APP_LINK") == "true"
-		return isWebAppLinkEnabled
-	}()
+// YookasaTestShopId возвращает shop id тестового магазина ЮKassa
+func YookasaTestShopId() string {
+	return conf.yookasaTestShopId
+}
 
-	conf.miniApp = envStringDefault("MINI_APP_URL", "")
+// YookasaTestSecretKey возвращает секретный ключ тестового магазина ЮKassa
+func YookasaTestSecretKey() string {
+	return conf.yookasaTestSecretKey
+}
 
-	conf.remnawaveTag = envStringDefault("REMNAWAVE_TAG", "")
+// StatusCardCacheSeconds возвращает, сколько секунд данные пользователя из Remnawave кешируются
+// для карточки подписки в /start. 0 — карточка строится только по данным БД, без запроса в Remnawave
+func StatusCardCacheSeconds() int {
+	return conf.statusCardCacheSeconds
+}
 
-	conf.trialRemnawaveTag = envStringDefault("TRIAL_REMNAWAVE_TAG", "")
+// Режимы чистого чата
+const (
+	CleanChatOff   = "off"
+	CleanChatMenus = "menus"
+	CleanChatFull  = "full"
+)
 
-	conf.trialTrafficLimitResetStrategy = envStringDefault("TRIAL_TRAFFIC_LIMIT_RESET_STRATEGY", "MONTH")
-	conf.trafficLimitResetStrategy = envStringDefault("TRAFFIC_LIMIT_RESET_STRATEGY", "MONTH")
+// CleanChatMode возвращает режим чистого чата: off — ничего не удаляется, menus — предыдущее меню бота
+// и введённый промокод удаляются, full — дополнительно удаляются команды пользователя (/start, /connect)
+func CleanChatMode() string {
+	return conf.cleanChatMode
+}
+
+// Фоновые задачи. Расписание задачи задаётся переменной CRON_<ИМЯ>, например CRON_WEBHOOK_RETRY
+const (
+	JobCryptoPayInvoiceCheck = "cryptopay_invoice_check"
+	JobYookasaInvoiceCheck   = "yookasa_invoice_check"
+	JobTrialInactive         = "trial_inactive"
+	JobHealthMonitor         = "health_monitor"
+	JobInvoiceExpirer        = "invoice_expirer"
+	JobDailyReport           = "daily_report"
+	JobReconciliation        = "reconciliation"
+	JobPurchaseArchive       = "purchase_archive"
+	JobWebhookRetry          = "webhook_retry"
+	JobFeatureFlagsRefresh   = "feature_flags_refresh"
+	JobWinbackPaid           = "winback_paid"
+	JobCheckoutReminder      = "checkout_reminder"
+	JobOfferReminder         = "offer_reminder"
+	JobOutboxDelivery        = "outbox_delivery"
+	JobTrialUpsell           = "trial_upsell"
+	JobCustomCommandsRefresh = "custom_commands_refresh"
+	JobRecurringBulkNotify   = "recurring_bulk_notify"
+	JobFieldEncryption       = "field_encryption"
+	JobTrialCleanup          = "trial_cleanup"
+	JobFulfillmentRetry      = "fulfillment_retry"
+	JobTranslationsRefresh   = "translations_refresh"
+)
 
-	conf.defaultLanguage = envStringDefault("DEFAULT_LANGUAGE", "ru")
+// CronParser разбирает расписания задач: 5 полей, 6 полей с секундами или дескрипторы вида @every 10s
+var CronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
 
-	conf.daysInMonth = envIntDefault("DAYS_IN_MONTH", 30)
+// CronSchedule возвращает cron-расписание фоновой задачи
+func CronSchedule(job string) string {
+	return conf.cronSchedules[job]
+}
 
-	externalSquadUUIDStr := os.Getenv("EXTERNAL_SQUAD_UUID")
-	if externalSquadUUIDStr != "" {
-		parsedUUID, err := uuid.Parse(externalSquadUUIDStr)
-		if err != nil {
-			panic(fmt.Sprintf("invalid EXTERNAL_SQUAD_UUID format: %v", err))
-		}
-		conf.externalSquadUUID = parsedUUID
-	} else {
-		conf.externalSquadUUID = uuid.Nil
-	}
+// GetRemnawaveTimeoutSeconds возвращает таймаут одной попытки запроса к Remnawave (в секундах)
+func GetRemnawaveTimeoutSeconds() int {
+	return conf.remnawaveTimeoutSeconds
+}
 
-	conf.trialTrafficLimit = mustEnvInt("TRIAL_TRAFFIC_LIMIT")
+// GetRemnawaveTimeoutBudgetSeconds возвращает общий лимит времени на вызов Remnawave вместе с повторами (в секундах)
+func GetRemnawaveTimeoutBudgetSeconds() int {
+	return conf.remnawaveTimeoutBudgetSeconds
+}
 
-	conf.healthCheckPort = envIntDefault("HEALTH_CHECK_PORT", 8080)
+// GetRemnawaveRetries возвращает количество повторов запроса к Remnawave при временных ошибках
+func GetRemnawaveRetries() int {
+	return conf.remnawaveRetries
+}
 
-	conf.webhookEnabled = envBool("WEBHOOK_ENABLED")
-	if conf.webhookEnabled {
-		conf.webhookURL = mustEnv("WEBHOOK_URL")
-		conf.webhookSecretToken = envStringDefault("WEBHOOK_SECRET_TOKEN", "")
-	}
+// GetRemnawaveBreakerThreshold возвращает количество ошибок подряд, после которого запросы к Remnawave отклоняются сразу
+func GetRemnawaveBreakerThreshold() int {
+	return conf.remnawaveBreakerThreshold
+}
 
-	conf.trialDays = mustEnvInt("TRIAL_DAYS")
+// GetRemnawaveBreakerCooldownSeconds возвращает время (в секундах) до пробного запроса после отключения Remnawave
+func GetRemnawaveBreakerCooldownSeconds() int {
+	return conf.remnawaveBreakerCooldownSeconds
+}
 
-	conf.enableAutoPayment = envBool("ENABLE_AUTO_PAYMENT")
+// GetShutdownTimeoutSeconds возвращает, сколько секунд при остановке ждать незавершённые платежи, рассылки и задачи
+func GetShutdownTimeoutSeconds() int {
+	return conf.shutdownTimeoutSeconds
+}
 
-	conf.price1 = mustEnvInt("PRICE_1")
-	conf.price3 = mustEnvInt("PRICE_3")
-	conf.price6 = mustEnvInt("PRICE_6")
-	conf.price12 = mustEnvInt("PRICE_12")
+// IsTrialPhoneVerificationEnabled возвращает true если перед активацией триала нужно подтвердить номер телефона
+func IsTrialPhoneVerificationEnabled() bool {
+	return conf.trialPhoneVerificationEnabled
+}
 
-	conf.isTelegramStarsEnabled = envBool("TELEGRAM_STARS_ENABLED")
-	if conf.isTelegramStarsEnabled {
-		conf.starsPrice1 = envIntDefault("STARS_PRICE_1", conf.price1)
-		conf.starsPrice3 = envIntDefault("STARS_PRICE_3", conf.price3)
-		conf.starsPrice6 = envIntDefault("STARS_PRICE_6", conf.price6)
-		conf.starsPrice12 = envIntDefault("STARS_PRICE_12", conf.price12)
+// TrialPhoneHashSecret возвращает ключ HMAC, которым хэшируются номера телефонов
+func TrialPhoneHashSecret() string {
+	return conf.trialPhoneHashSecret
+}
+
+// IsTeamPlansEnabled возвращает true если доступна покупка подписки на команду
+func IsTeamPlansEnabled() bool {
+	return conf.teamPlansEnabled
+}
+
+// IsGiftsEnabled возвращает true если подписку можно оплатить звёздами в подарок другому пользователю
+func IsGiftsEnabled() bool {
+	return conf.giftsEnabled
+}
+
+// TeamSeatOptions возвращает варианты количества мест командной подписки
+func TeamSeatOptions() []int {
+	return conf.teamSeatOptions
+}
+
+// IsTeamSeatOption проверяет что количество мест доступно для покупки
+func IsTeamSeatOption(seats int) bool {
+	for _, s := range conf.teamSeatOptions {
+		if s == seats {
+			return true
+		}
+	}
+	return false
+}
+
+// TeamPrice возвращает цену командной подписки в рублях: цена места за месяц × места × месяцы
+func TeamPrice(seats, months int) int {
+	return conf.teamSeatPrice * seats * months
+}
+
+// TeamStarsPrice возвращает цену командной подписки в звёздах. 0 — оплата звёздами недоступна
+func TeamStarsPrice(seats, months int) int {
+	return conf.teamSeatStarsPrice * seats * months
+}
+
+// IsWinbackPaidEnabled возвращает true если ушедшим платящим клиентам отправляются предложения со скидкой
+func IsWinbackPaidEnabled() bool {
+	return featureEnabled(FeatureWinbackPaid, conf.winbackPaidEnabled)
+}
+
+// WinbackPaidDays возвращает, через сколько дней после окончания подписки отправляются предложения.
+// Каждое значение — отдельная кампания со своей статистикой
+func WinbackPaidDays() []int {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return conf.winbackPaidDays
+}
+
+// WinbackPaidDiscountPercent возвращает скидку на прежний тариф в процентах
+func WinbackPaidDiscountPercent() int {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return conf.winbackPaidDiscountPercent
+}
+
+// WinbackPaidValidHours возвращает срок действия предложения ушедшему клиенту в часах
+func WinbackPaidValidHours() int {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return conf.winbackPaidValidHours
+}
+
+// IsReceiptsEnabled возвращает true если пользователь может получить квитанцию об оплате покупки
+func IsReceiptsEnabled() bool {
+	return conf.receiptsEnabled
+}
+
+// GetReceiptOperator возвращает реквизиты продавца для квитанций
+func GetReceiptOperator() ReceiptOperator {
+	return conf.receiptOperator
+}
+
+// ReceiptNumberPrefix возвращает префикс номера квитанции ("INV" → INV-000042)
+func ReceiptNumberPrefix() string {
+	return conf.receiptNumberPrefix
+}
+
+// IsCheckoutReminderEnabled возвращает true если пользователю напоминают о счёте, который он так и не оплатил
+func IsCheckoutReminderEnabled() bool {
+	return featureEnabled(FeatureCheckoutReminder, conf.checkoutReminderEnabled)
+}
+
+// CheckoutReminderDelayMinutes возвращает, через сколько минут после создания счёта отправляется напоминание
+func CheckoutReminderDelayMinutes() int {
+	return conf.checkoutReminderDelayMinutes
+}
+
+// RecurringBulkUndoMinutes возвращает, сколько минут массовое отключение автопродления можно отменить.
+// Клиенты получают уведомление после этого срока
+func RecurringBulkUndoMinutes() int {
+	return conf.recurringBulkUndoMinutes
+}
+
+// IsOfferReminderEnabled возвращает true если клиенту напоминают о промо-тарифе или winback предложении,
+// которое скоро закончится
+func IsOfferReminderEnabled() bool {
+	return featureEnabled(FeatureOfferReminder, conf.offerReminderEnabled)
+}
+
+// OfferReminderHoursBefore возвращает, за сколько часов до окончания предложения отправляется напоминание
+func OfferReminderHoursBefore() int {
+	return conf.offerReminderHoursBefore
+}
+
+// Шаги цепочки сообщений после активации триала
+const (
+	TrialUpsellStepSetup    = "setup"
+	TrialUpsellStepDiscount = "discount"
+	TrialUpsellStepLastDay  = "last_day"
+)
+
+// TrialUpsellStep шаг цепочки после активации триала. Hours отсчитываются от активации триала,
+// а для шага с BeforeExpiry — назад от окончания триала
+type TrialUpsellStep struct {
+	Name         string
+	Hours        int
+	BeforeExpiry bool
+}
+
+// IsTrialUpsellEnabled возвращает true если после активации триала клиенту приходит цепочка сообщений с предложением купить
+func IsTrialUpsellEnabled() bool {
+	return featureEnabled(FeatureTrialUpsell, conf.trialUpsellEnabled)
+}
+
+// TrialUpsellSteps возвращает включённые шаги цепочки после активации триала
+func TrialUpsellSteps() []TrialUpsellStep {
+	return conf.trialUpsellSteps
+}
+
+// TrialUpsellDiscountPercent возвращает скидку в процентах, которую предлагает шаг discount
+func TrialUpsellDiscountPercent() int {
+	return conf.trialUpsellDiscountPercent
+}
+
+// TrialUpsellDiscountValidHours возвращает, сколько часов действует скидка шага discount
+func TrialUpsellDiscountValidHours() int {
+	return conf.trialUpsellDiscountValidHours
+}
+
+// Что делать с пользователем панели после окончания триала
+const (
+	TrialCleanupDisable = "disable"
+	TrialCleanupDelete  = "delete"
+)
+
+// IsTrialCleanupEnabled возвращает true если пользователи панели с давно истёкшим триалом и без оплат отключаются или удаляются
+func IsTrialCleanupEnabled() bool {
+	return conf.trialCleanupEnabled
+}
+
+// TrialCleanupAfterDays возвращает, через сколько дней после окончания триала пользователь панели очищается
+func TrialCleanupAfterDays() int {
+	return conf.trialCleanupAfterDays
+}
+
+// TrialCleanupMode возвращает режим очистки: disable — пользователь отключается, delete — удаляется из панели
+func TrialCleanupMode() string {
+	return conf.trialCleanupMode
+}
+
+// IsEventsEnabled возвращает true если настроен хотя бы один приёмник событий для внешних интеграций
+func IsEventsEnabled() bool {
+	return conf.eventsWebhookURL != "" || conf.logChannelID != 0 || conf.analyticsProvider != ""
+}
+
+// GetEventsWebhookURL возвращает адрес, на который отправляются события (пусто — webhook выключен)
+func GetEventsWebhookURL() string {
+	return conf.eventsWebhookURL
+}
+
+// GetEventsWebhookSecret возвращает ключ HMAC-подписи исходящих событий
+func GetEventsWebhookSecret() string {
+	return conf.eventsWebhookSecret
+}
+
+// GetEventsWebhookTimeoutSeconds возвращает таймаут одного запроса к webhook в секундах
+func GetEventsWebhookTimeoutSeconds() int {
+	return conf.eventsWebhookTimeoutSeconds
+}
+
+// EventTypes возвращает типы событий, которые отправляются в webhook (пусто — все)
+func EventTypes() []string {
+	return conf.eventTypes
+}
+
+// GetLogChannelID возвращает канал, в который бот пишет карточки ключевых событий (0 — выключен)
+func GetLogChannelID() int64 {
+	return conf.logChannelID
+}
+
+// LogChannelEventTypes возвращает типы событий, которые публикуются в лог-канал (пусто — все)
+func LogChannelEventTypes() []string {
+	return conf.logChannelEventTypes
+}
+
+// LogChannelMaxPerMinute возвращает лимит сообщений в лог-канал в минуту (0 — без ограничения)
+func LogChannelMaxPerMinute() int {
+	return conf.logChannelMaxPerMinute
+}
+
+// AnalyticsProvider возвращает провайдера продуктовой аналитики: posthog или amplitude (пусто — выключена)
+func AnalyticsProvider() string {
+	return conf.analyticsProvider
+}
+
+// AnalyticsURL возвращает адрес приёма событий аналитики
+func AnalyticsURL() string {
+	return conf.analyticsURL
+}
+
+// AnalyticsAPIKey возвращает ключ проекта в системе аналитики
+func AnalyticsAPIKey() string {
+	return conf.analyticsAPIKey
+}
+
+// AnalyticsSalt возвращает соль, с которой хешируется Telegram ID перед отправкой в аналитику
+func AnalyticsSalt() string {
+	return conf.analyticsSalt
+}
+
+// AnalyticsBatchSize возвращает, сколько событий копится перед отправкой
+func AnalyticsBatchSize() int {
+	return conf.analyticsBatchSize
+}
+
+// AnalyticsFlushSeconds возвращает, как часто отправляются накопленные события, в секундах
+func AnalyticsFlushSeconds() int {
+	return conf.analyticsFlushSeconds
+}
+
+// IsAnalyticsEnabled возвращает true если аналитика настроена и не выключена аварийно
+func IsAnalyticsEnabled() bool {
+	return conf.analyticsProvider != "" && featureEnabled(FeatureAnalytics, conf.analyticsEnabled)
+}
+
+// SubscriptionRotationLimit возвращает, сколько раз за 30 дней клиент может сам сменить ссылку подписки
+// (0 — только через поддержку)
+func SubscriptionRotationLimit() int {
+	return conf.subscriptionRotationLimit
+}
+
+// BroadcastFrequencyCap возвращает, сколько рассылок клиент может получить за BroadcastFrequencyWindowDays дней
+// (0 — без лимита). Админ может отключить лимит для отдельной рассылки
+func BroadcastFrequencyCap() int {
+	return conf.broadcastFrequencyCap
+}
+
+// BroadcastFrequencyWindowDays возвращает длину окна лимита частоты рассылок в днях
+func BroadcastFrequencyWindowDays() int {
+	return conf.broadcastFrequencyWindowDays
+}
+
+// IsPrivacySelfServiceEnabled возвращает true если клиент может сам выгрузить и удалить свои данные через /privacy
+func IsPrivacySelfServiceEnabled() bool {
+	return featureEnabled(FeaturePrivacySelfService, conf.privacySelfServiceEnabled)
+}
+
+// IsInlineModeEnabled возвращает true если бот отвечает на inline запросы (@bot в любом чате)
+func IsInlineModeEnabled() bool {
+	return featureEnabled(FeatureInlineMode, conf.inlineModeEnabled)
+}
+
+// IsFaqEnabled возвращает true если в главном меню есть раздел FAQ
+func IsFaqEnabled() bool {
+	return featureEnabled(FeatureFaq, conf.faqEnabled)
+}
+
+// IsSurveyEnabled возвращает true если после продления и обращения в поддержку клиенту предлагается оценить сервис
+func IsSurveyEnabled() bool {
+	return featureEnabled(FeatureSurvey, conf.surveyEnabled)
+}
+
+// SurveyIntervalDays возвращает, через сколько дней клиенту можно снова предложить опрос
+func SurveyIntervalDays() int {
+	return conf.surveyIntervalDays
+}
+
+// IsNotificationFeedbackEnabled возвращает true если под winback и маркетинговыми уведомлениями показываются кнопки 👍/👎
+func IsNotificationFeedbackEnabled() bool {
+	return featureEnabled(FeatureNotificationFeedback, conf.notificationFeedbackEnabled)
+}
+
+// InlinePromoCodes возвращает промокоды, которые клиенты могут отправлять друзьям через inline режим
+func InlinePromoCodes() []string {
+	return conf.inlinePromoCodes
+}
+
+// Политики повторного триала
+const (
+	TrialPolicyOnce       = "once"
+	TrialPolicyCooldown   = "cooldown"
+	TrialPolicyAfterLapse = "after_lapse"
+)
+
+// TrialPolicy возвращает политику повторного триала: once — один триал на telegram_id навсегда,
+// cooldown — повторно через TrialCooldownMonths месяцев после прошлого, after_lapse — повторно
+// после того, как оплаченный после прошлого триала период закончился
+func TrialPolicy() string {
+	return conf.trialPolicy
+}
+
+// TrialCooldownMonths возвращает, через сколько месяцев после прошлого триала доступен следующий (политика cooldown)
+func TrialCooldownMonths() int {
+	return conf.trialCooldownMonths
+}
+
+// IsAlertsEnabled возвращает true если алерты админу о критических сбоях включены
+func IsAlertsEnabled() bool {
+	return conf.alertsEnabled
+}
+
+// GetAlertChatID возвращает дополнительный чат/канал для алертов (0 если не задан)
+func GetAlertChatID() int64 {
+	return conf.alertChatID
+}
+
+// GetAlertCooldownMinutes возвращает минимальный интервал (в минутах) между повторными алертами одного типа
+func GetAlertCooldownMinutes() int {
+	return conf.alertCooldownMinutes
+}
+
+// GetAlertHealthFailureThreshold возвращает количество подряд неудачных health-проверок до алерта
+func GetAlertHealthFailureThreshold() int {
+	return conf.alertHealthFailureThreshold
+}
+
+// GetAlertErrorSpikeThreshold возвращает количество ошибок за час, после которого отправляется алерт
+func GetAlertErrorSpikeThreshold() int {
+	return conf.alertErrorSpikeThreshold
+}
+
+const bytesInGigabyte = 1073741824
+
+func mustEnv(key string) string {
+	v := os.Getenv(key)
+	if v == "" {
+		addIssue("%s is not set", key)
+	}
+	return v
+}
+
+func mustEnvInt(key string) int {
+	v := mustEnv(key)
+	if v == "" {
+		return 0
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		addIssue("%s must be an integer, got %q", key, v)
+	}
+	return i
+}
+
+func envIntDefault(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		addIssue("%s must be an integer, got %q", key, v)
+		return def
+	}
+	return i
+}
+
+func envFloatDefault(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		addIssue("%s must be a number, got %q", key, v)
+		return def
+	}
+	return f
+}
+
+// starsPriceFromRubles пересчитывает цену в рублях в звёзды по курсу STARS_RATE и округляет
+// до "красивой" цены вида 99, 199, 299. Без курса цена в звёздах равна цене в рублях
+func starsPriceFromRubles(rubles int) int {
+	confMu.RLock()
+	rate := conf.starsRate
+	confMu.RUnlock()
+	return starsPriceAt(rate, rubles)
+}
+
+// starsPriceAt пересчитывает цену в рублях в звёзды по курсу rate
+func starsPriceAt(rate float64, rubles int) int {
+	if rate <= 0 || rubles <= 0 {
+		return rubles
+	}
+	return roundStarsPrice(float64(rubles) / rate)
+}
+
+// roundStarsPrice округляет цену до ближайшей вида X99 (от 100 звёзд) или X9 (от 10 звёзд)
+func roundStarsPrice(stars float64) int {
+	switch {
+	case stars >= 100:
+		return int(math.Max(math.Round(stars/100), 1))*100 - 1
+	case stars >= 10:
+		return int(math.Round(stars/10))*10 - 1
+	default:
+		return int(math.Max(math.Round(stars), 1))
+	}
+}
+
+func envStringDefault(key string, def string) string {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+func envBool(key string) bool {
+	return os.Getenv(key) == "true"
+}
+
+// parseTariffs парсит тарифы из ENV переменных по паттерну TARIFF_<NAME>_*
+// Поддерживает имена с подчёркиванием: TARIFF_SUPER_PRO_ENABLED → name = "SUPER_PRO".
+// Цены в звёздах, не заданные явно, пересчитываются по курсу starsRate
+func parseTariffs(starsRate float64) []Tariff {
+	var tariffs []Tariff
+	seen := make(map[string]bool)
+
+	// Известные суффиксы для определения конца имени тарифа
+	knownSuffixes := []string{"_ENABLED", "_DEVICES", "_PRICE_1", "_PRICE_3", "_PRICE_6", "_PRICE_12",
+		"_STARS_PRICE_1", "_STARS_PRICE_3", "_STARS_PRICE_6", "_STARS_PRICE_12",
+		"_TRIBUTE_URL", "_TRIBUTE_NAME", "_SUBSCRIPTION_PAGE_TEMPLATE", "_PAYMENT_METHODS"}
+
+	// Собираем все уникальные имена тарифов из ENV
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, "TARIFF_") {
+			continue
+		}
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := parts[0]
+
+		// Извлекаем имя тарифа: TARIFF_<NAME>_<SUFFIX> → NAME
+		// Ищем известный суффикс и отрезаем его
+		var name string
+		for _, suffix := range knownSuffixes {
+			if strings.HasSuffix(key, suffix) {
+				// Убираем "TARIFF_" в начале и суффикс в конце
+				name = strings.TrimPrefix(key, "TARIFF_")
+				name = strings.TrimSuffix(name, suffix)
+				break
+			}
+		}
+		if name == "" {
+			name = dayPlanTariffName(key)
+		}
+		if name == "" {
+			continue
+		}
+		seen[name] = true
+	}
+
+	// Парсим каждый найденный тариф
+	for name := range seen {
+		prefix := "TARIFF_" + name + "_"
+
+		// Проверяем включён ли тариф
+		if !envBool(prefix + "ENABLED") {
+			slog.Debug("Tariff disabled or not enabled", "name", name)
+			continue
+		}
+
+		// Парсим devices (обязательное поле)
+		devicesStr := os.Getenv(prefix + "DEVICES")
+		if devicesStr == "" {
+			slog.Warn("Tariff missing DEVICES, skipping", "name", name)
+			continue
+		}
+		devices, err := strconv.Atoi(devicesStr)
+		if err != nil {
+			slog.Warn("Tariff invalid DEVICES value, skipping", "name", name, "error", err)
+			continue
+		}
+
+		tariff := Tariff{
+			Name:    name,
+			Devices: devices,
+		}
+
+		// Парсим цены (обязательные)
+		price1Str := os.Getenv(prefix + "PRICE_1")
+		price3Str := os.Getenv(prefix + "PRICE_3")
+		price6Str := os.Getenv(prefix + "PRICE_6")
+		price12Str := os.Getenv(prefix + "PRICE_12")
+
+		if price1Str == "" || price3Str == "" || price6Str == "" || price12Str == "" {
+			slog.Warn("Tariff missing price fields, skipping", "name", name)
+			continue
+		}
+
+		tariff.Price1, err = strconv.Atoi(price1Str)
+		if err != nil {
+			slog.Warn("Tariff invalid PRICE_1, skipping", "name", name, "error", err)
+			continue
+		}
+		tariff.Price3, err = strconv.Atoi(price3Str)
+		if err != nil {
+			slog.Warn("Tariff invalid PRICE_3, skipping", "name", name, "error", err)
+			continue
+		}
+		tariff.Price6, err = strconv.Atoi(price6Str)
+		if err != nil {
+			slog.Warn("Tariff invalid PRICE_6, skipping", "name", name, "error", err)
+			continue
+		}
+		tariff.Price12, err = strconv.Atoi(price12Str)
+		if err != nil {
+			slog.Warn("Tariff invalid PRICE_12, skipping", "name", name, "error", err)
+			continue
+		}
+
+		// Парсим цены в звёздах (опциональные, по умолчанию пересчитываются из рублей по STARS_RATE)
+		tariff.StarsPrice1 = envIntDefault(prefix+"STARS_PRICE_1", starsPriceAt(starsRate, tariff.Price1))
+		tariff.StarsPrice3 = envIntDefault(prefix+"STARS_PRICE_3", starsPriceAt(starsRate, tariff.Price3))
+		tariff.StarsPrice6 = envIntDefault(prefix+"STARS_PRICE_6", starsPriceAt(starsRate, tariff.Price6))
+		tariff.StarsPrice12 = envIntDefault(prefix+"STARS_PRICE_12", starsPriceAt(starsRate, tariff.Price12))
+
+		// Парсим Tribute поля (опциональные)
+		tariff.TributeURL = os.Getenv(prefix + "TRIBUTE_URL")
+		tariff.TributeName = os.Getenv(prefix + "TRIBUTE_NAME")
+		tariff.DayPlans = parseDayPlans(name, starsRate)
+		tariff.SubscriptionPageTemplate = strings.TrimSpace(os.Getenv(prefix + "SUBSCRIPTION_PAGE_TEMPLATE"))
+		tariff.PaymentMethods = parseTariffPaymentMethods(prefix + "PAYMENT_METHODS")
+
+		tariffs = append(tariffs, tariff)
+		slog.Info("Loaded tariff", "name", name, "devices", devices,
+			"price1", tariff.Price1, "price3", tariff.Price3,
+			"price6", tariff.Price6, "price12", tariff.Price12,
+			"dayPlans", len(tariff.DayPlans), "paymentMethods", tariff.PaymentMethods,
+			"tributeURL", tariff.TributeURL != "", "tributeName", tariff.TributeName)
+	}
+
+	// Сортируем тарифы по количеству устройств (от меньшего к большему)
+	// Это логичный порядок: START (3) → PRO (5) → PREMIUM (10)
+	sort.Slice(tariffs, func(i, j int) bool {
+		if tariffs[i].Devices != tariffs[j].Devices {
+			return tariffs[i].Devices < tariffs[j].Devices
+		}
+		// При равном количестве устройств — по имени
+		return tariffs[i].Name < tariffs[j].Name
+	})
+
+	return tariffs
+}
+
+// tariffPaymentMethodNames названия способов оплаты в TARIFF_<NAME>_PAYMENT_METHODS: тип счёта или привычное имя
+var tariffPaymentMethodNames = map[string]string{
+	"crypto":   "crypto",
+	"yookasa":  "yookasa",
+	"card":     "yookasa",
+	"telegram": "telegram",
+	"stars":    "telegram",
+	"tribute":  "tribute",
+}
+
+// parseTariffPaymentMethods читает список способов оплаты тарифа через запятую. Пустое значение — все способы
+func parseTariffPaymentMethods(key string) []string {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return nil
+	}
+	var methods []string
+	for _, item := range strings.Split(value, ",") {
+		method, ok := tariffPaymentMethodNames[strings.ToLower(strings.TrimSpace(item))]
+		if !ok {
+			addIssue("%s: unknown payment method %q, use crypto, yookasa (card), telegram (stars) or tribute", key, strings.TrimSpace(item))
+			continue
+		}
+		if !slices.Contains(methods, method) {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// TariffAllowsPaymentMethod проверяет, что тариф tariffName можно оплатить способом method.
+// Без тарифа (legacy цены) и для неизвестного тарифа ограничений нет
+func TariffAllowsPaymentMethod(tariffName, method string) bool {
+	tariff := GetTariffByName(tariffName)
+	return tariff == nil || tariff.AllowsPaymentMethod(method)
+}
+
+// load читает конфигурацию из окружения в c. Ошибки не прерывают чтение, а копятся в issues
+func load(c *config) {
+	var err error
+	if v := mustEnv("ADMIN_TELEGRAM_ID"); v != "" {
+		c.adminTelegramId, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			addIssue("ADMIN_TELEGRAM_ID must be a telegram user id, got %q", v)
+		}
+	}
+
+	c.logFormat = envStringDefault("LOG_FORMAT", "text")
+	if c.logFormat != "text" && c.logFormat != "json" {
+		addIssue("LOG_FORMAT must be either 'text' or 'json'")
+	}
+	c.logLevel = envStringDefault("LOG_LEVEL", "info")
+
+	c.telegramToken = mustEnv("TELEGRAM_TOKEN")
+
+	c.isWebAppLinkEnabled = func() bool {
+		isWebAppLinkEnabled := os.Getenv("IS_WEB_APP_LINK") == "true"
+		return isWebAppLinkEnabled
+	}()
+
+	c.miniApp = envStringDefault("MINI_APP_URL", "")
+
+	c.subscriptionPageTemplate = strings.TrimSpace(os.Getenv("SUBSCRIPTION_PAGE_TEMPLATE"))
+	validateSubscriptionPageTemplate("SUBSCRIPTION_PAGE_TEMPLATE", c.subscriptionPageTemplate)
+
+	c.remnawaveTag = envStringDefault("REMNAWAVE_TAG", "")
+
+	c.trialRemnawaveTag = envStringDefault("TRIAL_REMNAWAVE_TAG", "")
+
+	c.trialTrafficLimitResetStrategy = envStringDefault("TRIAL_TRAFFIC_LIMIT_RESET_STRATEGY", "MONTH")
+	c.trafficLimitResetStrategy = envStringDefault("TRAFFIC_LIMIT_RESET_STRATEGY", "MONTH")
+
+	c.defaultLanguage = envStringDefault("DEFAULT_LANGUAGE", "ru")
+	c.languageFallbacks = parseLanguageFallbacks(envStringDefault("LANGUAGE_FALLBACKS", "uk:ru,be:ru,kk:ru"))
+
+	c.daysInMonth = envIntDefault("DAYS_IN_MONTH", 30)
 
+	externalSquadUUIDStr := os.Getenv("EXTERNAL_SQUAD_UUID")
+	if externalSquadUUIDStr != "" {
+		parsedUUID, err := uuid.Parse(externalSquadUUIDStr)
+		if err != nil {
+			addIssue("invalid EXTERNAL_SQUAD_UUID format: %v", err)
+		}
+		c.externalSquadUUID = parsedUUID
+	} else {
+		c.externalSquadUUID = uuid.Nil
+	}
+
+	if v := os.Getenv("WINBACK_EXTERNAL_SQUAD_UUID"); v != "" {
+		parsedUUID, err := uuid.Parse(v)
+		if err != nil {
+			addIssue("invalid WINBACK_EXTERNAL_SQUAD_UUID format: %v", err)
+		}
+		c.winbackExternalSquadUUID = parsedUUID
+	}
+	if v := os.Getenv("PROMO_TARIFF_EXTERNAL_SQUAD_UUID"); v != "" {
+		parsedUUID, err := uuid.Parse(v)
+		if err != nil {
+			addIssue("invalid PROMO_TARIFF_EXTERNAL_SQUAD_UUID format: %v", err)
+		}
+		c.promoTariffExternalSquadUUID = parsedUUID
+	}
+
+	c.trialTrafficLimit = mustEnvInt("TRIAL_TRAFFIC_LIMIT")
+
+	c.healthCheckPort = envIntDefault("HEALTH_CHECK_PORT", 8080)
+	c.healthCheckProbeSeconds = envIntDefault("HEALTH_CHECK_PROBE_SECONDS", 30)
+	if c.healthCheckProbeSeconds < 5 {
+		addIssue("HEALTH_CHECK_PROBE_SECONDS must be at least 5")
 	}
+	c.deletedCustomerRetentionDays = envIntDefault("DELETED_CUSTOMER_RETENTION_DAYS", 90)
+	if c.deletedCustomerRetentionDays < 1 {
+		addIssue("DELETED_CUSTOMER_RETENTION_DAYS must be at least 1")
+	}
+	// Кеш сбрасывается при записи только в этом экземпляре бота, поэтому TTL ограничен секундой:
+	// меню других экземпляров не показывают устаревшие предложения и автопродление дольше
+	c.customerCacheTTLMs = envIntDefault("CUSTOMER_CACHE_TTL_MS", 1000)
+	if c.customerCacheTTLMs < 0 || c.customerCacheTTLMs > 1000 {
+		addIssue("CUSTOMER_CACHE_TTL_MS must be between 0 and 1000")
+	}
+
+	c.webhookEnabled = envBool("WEBHOOK_ENABLED")
+	if c.webhookEnabled {
+		c.webhookURL = mustEnv("WEBHOOK_URL")
+	} else {
+		// URL нужен и в polling режиме для переключения на webhook командой /bot_mode
+		c.webhookURL = os.Getenv("WEBHOOK_URL")
+	}
+	c.webhookSecretToken = envStringDefault("WEBHOOK_SECRET_TOKEN", "")
+	c.webhookSelfCheckEnabled = os.Getenv("WEBHOOK_SELF_CHECK_ENABLED") != "false"
+
+	c.trialDays = mustEnvInt("TRIAL_DAYS")
+
+	c.enableAutoPayment = envBool("ENABLE_AUTO_PAYMENT")
+
+	c.price1 = mustEnvInt("PRICE_1")
+	c.price3 = mustEnvInt("PRICE_3")
+	c.price6 = mustEnvInt("PRICE_6")
+	c.price12 = mustEnvInt("PRICE_12")
 
-	conf.requirePaidPurchaseForStars = envBool("REQUIRE_PAID_PURCHASE_FOR_STARS")
+	// Курс: сколько рублей стоит одна звезда. 0 — цены в звёздах равны ценам в рублях
+	c.starsRate = envFloatDefault("STARS_RATE", 0)
+	if c.starsRate < 0 {
+		addIssue("STARS_RATE must be non-negative")
+	}
+
+	c.isTelegramStarsEnabled = envBool("TELEGRAM_STARS_ENABLED")
+	// Сброс нужен при перезагрузке конфига: после выключения звёзд старые цены не должны остаться
+	c.starsPrice1, c.starsPrice3, c.starsPrice6, c.starsPrice12 = 0, 0, 0, 0
+	if c.isTelegramStarsEnabled {
+		c.starsPrice1 = envIntDefault("STARS_PRICE_1", starsPriceAt(c.starsRate, c.price1))
+		c.starsPrice3 = envIntDefault("STARS_PRICE_3", starsPriceAt(c.starsRate, c.price3))
+		c.starsPrice6 = envIntDefault("STARS_PRICE_6", starsPriceAt(c.starsRate, c.price6))
+		c.starsPrice12 = envIntDefault("STARS_PRICE_12", starsPriceAt(c.starsRate, c.price12))
+
+	}
 
-	conf.remnawaveUrl = mustEnv("REMNAWAVE_URL")
+	c.requirePaidPurchaseForStars = envBool("REQUIRE_PAID_PURCHASE_FOR_STARS")
 
-	conf.remnawaveMode = func() string {
+	c.remnawaveUrl = mustEnv("REMNAWAVE_URL")
+
+	c.remnawaveMode = func() string {
 		v := os.Getenv("REMNAWAVE_MODE")
 		if v != "" {
 			if v != "remote" && v != "local" {
-				panic("REMNAWAVE_MODE .env variable must be either 'remote' or 'local'")
+				addIssue("REMNAWAVE_MODE must be either 'remote' or 'local', got %q", v)
+				return "remote"
 			} else {
 				return v
 			}
@@ -746,34 +1906,58 @@ func InitConfig() {
 		}
 	}()
 
-	conf.remnawaveToken = mustEnv("REMNAWAVE_TOKEN")
+	c.remnawaveToken = mustEnv("REMNAWAVE_TOKEN")
+
+	c.databaseURL = mustEnv("DATABASE_URL")
+	c.databaseReadURL = os.Getenv("DATABASE_READ_URL")
 
-	conf.databaseURL = mustEnv("DATABASE_URL")
+	// Ключи можно передать файлом, который монтирует KMS или менеджер секретов
+	c.fieldEncryptionKeys = os.Getenv("FIELD_ENCRYPTION_KEYS")
+	if path := os.Getenv("FIELD_ENCRYPTION_KEYS_FILE"); path != "" {
+		if c.fieldEncryptionKeys != "" {
+			addIssue("FIELD_ENCRYPTION_KEYS and FIELD_ENCRYPTION_KEYS_FILE are mutually exclusive")
+		} else if data, err := os.ReadFile(path); err != nil {
+			addIssue("cannot read FIELD_ENCRYPTION_KEYS_FILE: %v", err)
+		} else {
+			c.fieldEncryptionKeys = strings.TrimSpace(string(data))
+		}
+	}
+	if c.fieldEncryptionKeys != "" {
+		if _, err := fieldcrypt.ParseKeys(c.fieldEncryptionKeys); err != nil {
+			addIssue("invalid FIELD_ENCRYPTION_KEYS: %v", err)
+		}
+	}
 
-	conf.isCryptoEnabled = envBool("CRYPTO_PAY_ENABLED")
-	if conf.isCryptoEnabled {
-		conf.cryptoPayURL = mustEnv("CRYPTO_PAY_URL")
-		conf.cryptoPayToken = mustEnv("CRYPTO_PAY_TOKEN")
+	c.isCryptoEnabled = envBool("CRYPTO_PAY_ENABLED")
+	if c.isCryptoEnabled {
+		c.cryptoPayURL = mustEnv("CRYPTO_PAY_URL")
+		c.cryptoPayToken = mustEnv("CRYPTO_PAY_TOKEN")
 	}
 
-	conf.isYookasaEnabled = envBool("YOOKASA_ENABLED")
-	if conf.isYookasaEnabled {
-		conf.yookasaURL = mustEnv("YOOKASA_URL")
-		conf.yookasaShopId = mustEnv("YOOKASA_SHOP_ID")
-		conf.yookasaSecretKey = mustEnv("YOOKASA_SECRET_KEY")
-		conf.yookasaEmail = mustEnv("YOOKASA_EMAIL")
+	c.isYookasaEnabled = envBool("YOOKASA_ENABLED")
+	if c.isYookasaEnabled {
+		c.yookasaURL = mustEnv("YOOKASA_URL")
+		c.yookasaShopId = mustEnv("YOOKASA_SHOP_ID")
+		c.yookasaSecretKey = mustEnv("YOOKASA_SECRET_KEY")
+		c.yookasaEmail = mustEnv("YOOKASA_EMAIL")
 	}
 
-	conf.trafficLimit = mustEnvInt("TRAFFIC_LIMIT")
-	conf.referralDays = mustEnvInt("REFERRAL_DAYS")
+	c.trafficLimit = mustEnvInt("TRAFFIC_LIMIT")
+	c.referralDays = mustEnvInt("REFERRAL_DAYS")
 
-	conf.serverStatusURL = os.Getenv("SERVER_STATUS_URL")
-	conf.supportURL = os.Getenv("SUPPORT_URL")
-	conf.feedbackURL = os.Getenv("FEEDBACK_URL")
-	conf.channelURL = os.Getenv("CHANNEL_URL")
-	conf.tosURL = os.Getenv("TOS_URL")
+	c.serverStatusURL = os.Getenv("SERVER_STATUS_URL")
+	c.serverStatusPublic = envBool("SERVER_STATUS_PUBLIC")
+	c.serverStatusCacheSeconds = envIntDefault("SERVER_STATUS_CACHE_SECONDS", 30)
+	if c.serverStatusCacheSeconds < 0 {
+		addIssue("SERVER_STATUS_CACHE_SECONDS must be non-negative")
+	}
+	c.supportURL = os.Getenv("SUPPORT_URL")
+	c.feedbackURL = os.Getenv("FEEDBACK_URL")
+	c.channelURL = os.Getenv("CHANNEL_URL")
+	c.tosURL = os.Getenv("TOS_URL")
+	c.startMedia = parseStartMedia()
 
-	conf.squadUUIDs = func() map[uuid.UUID]uuid.UUID {
+	c.squadUUIDs = func() map[uuid.UUID]uuid.UUID {
 		v := os.Getenv("SQUAD_UUIDS")
 		if v != "" {
 			uuids := strings.Split(v, ",")
@@ -781,7 +1965,8 @@ func InitConfig() {
 			for _, value := range uuids {
 				uuid, err := uuid.Parse(value)
 				if err != nil {
-					panic(err)
+					addIssue("invalid UUID %q in SQUAD_UUIDS: %v", value, err)
+					continue
 				}
 				inboundsMap[uuid] = uuid
 			}
@@ -793,13 +1978,13 @@ func InitConfig() {
 		}
 	}()
 
-	conf.tributeWebhookUrl = os.Getenv("TRIBUTE_WEBHOOK_URL")
-	if conf.tributeWebhookUrl != "" {
-		conf.tributeAPIKey = mustEnv("TRIBUTE_API_KEY")
-		conf.tributePaymentUrl = mustEnv("TRIBUTE_PAYMENT_URL")
+	c.tributeWebhookUrl = os.Getenv("TRIBUTE_WEBHOOK_URL")
+	if c.tributeWebhookUrl != "" {
+		c.tributeAPIKey = mustEnv("TRIBUTE_API_KEY")
+		c.tributePaymentUrl = mustEnv("TRIBUTE_PAYMENT_URL")
 	}
 
-	conf.blockedTelegramIds = func() map[int64]bool {
+	c.blockedTelegramIds = func() map[int64]bool {
 		v := os.Getenv("BLOCKED_TELEGRAM_IDS")
 		if v != "" {
 			ids := strings.Split(v, ",")
@@ -807,7 +1992,8 @@ func InitConfig() {
 			for _, idStr := range ids {
 				id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
 				if err != nil {
-					panic(fmt.Sprintf("invalid telegram ID in BLOCKED_TELEGRAM_IDS: %v", err))
+					addIssue("invalid telegram ID %q in BLOCKED_TELEGRAM_IDS", idStr)
+					continue
 				}
 				blockedMap[id] = true
 			}
@@ -819,7 +2005,7 @@ func InitConfig() {
 		}
 	}()
 
-	conf.whitelistedTelegramIds = func() map[int64]bool {
+	c.whitelistedTelegramIds = func() map[int64]bool {
 		v := os.Getenv("WHITELISTED_TELEGRAM_IDS")
 		if v != "" {
 			ids := strings.Split(v, ",")
@@ -827,7 +2013,8 @@ func InitConfig() {
 			for _, idStr := range ids {
 				id, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
 				if err != nil {
-					panic(fmt.Sprintf("invalid telegram ID in WHITELISTED_TELEGRAM_IDS: %v", err))
+					addIssue("invalid telegram ID %q in WHITELISTED_TELEGRAM_IDS", idStr)
+					continue
 				}
 				whitelistedMap[id] = true
 			}
@@ -839,7 +2026,7 @@ func InitConfig() {
 		}
 	}()
 
-	conf.trialInternalSquads = func() map[uuid.UUID]uuid.UUID {
+	c.trialInternalSquads = func() map[uuid.UUID]uuid.UUID {
 		v := os.Getenv("TRIAL_INTERNAL_SQUADS")
 		if v != "" {
 			uuids := strings.Split(v, ",")
@@ -847,7 +2034,8 @@ func InitConfig() {
 			for _, value := range uuids {
 				parsedUUID, err := uuid.Parse(strings.TrimSpace(value))
 				if err != nil {
-					panic(fmt.Sprintf("invalid UUID in TRIAL_INTERNAL_SQUADS: %v", err))
+					addIssue("invalid UUID %q in TRIAL_INTERNAL_SQUADS: %v", value, err)
+					continue
 				}
 				trialSquadsMap[parsedUUID] = parsedUUID
 			}
@@ -863,16 +2051,16 @@ func InitConfig() {
 	if trialExternalSquadUUIDStr != "" {
 		parsedUUID, err := uuid.Parse(trialExternalSquadUUIDStr)
 		if err != nil {
-			panic(fmt.Sprintf("invalid TRIAL_EXTERNAL_SQUAD_UUID format: %v", err))
+			addIssue("invalid TRIAL_EXTERNAL_SQUAD_UUID format: %v", err)
 		}
-		conf.trialExternalSquadUUID = parsedUUID
+		c.trialExternalSquadUUID = parsedUUID
 		slog.Info("Loaded trial external squad UUID", "uuid", trialExternalSquadUUIDStr)
 	} else {
-		conf.trialExternalSquadUUID = uuid.Nil
+		c.trialExternalSquadUUID = uuid.Nil
 		slog.Info("No trial external squad specified, will use regular EXTERNAL_SQUAD_UUID for trial users")
 	}
 
-	conf.remnawaveHeaders = func() map[string]string {
+	c.remnawaveHeaders = func() map[string]string {
 		v := os.Getenv("REMNAWAVE_HEADERS")
 		if v != "" {
 			headers := make(map[string]string)
@@ -896,51 +2084,642 @@ func InitConfig() {
 	}()
 
 	// Парсим тарифы из ENV
-	conf.tariffs = parseTariffs()
-	if len(conf.tariffs) > 0 {
-		slog.Info("Tariffs system enabled", "count", len(conf.tariffs))
+	c.tariffs = parseTariffs(c.starsRate)
+	for _, tariff := range c.tariffs {
+		validateSubscriptionPageTemplate("TARIFF_"+tariff.Name+"_SUBSCRIPTION_PAGE_TEMPLATE", tariff.SubscriptionPageTemplate)
+	}
+	if len(c.tariffs) > 0 {
+		slog.Info("Tariffs system enabled", "count", len(c.tariffs))
 	} else {
 		slog.Info("No tariffs configured, using legacy pricing")
 	}
 
 	// Trial notifications config
-	conf.trialInactiveNotificationEnabled = envBool("TRIAL_INACTIVE_NOTIFICATION_ENABLED")
-	conf.winbackEnabled = envBool("WINBACK_ENABLED")
-	conf.winbackPrice = envIntDefault("WINBACK_PRICE", 100)
-	conf.winbackDevices = envIntDefault("WINBACK_DEVICES", 1)
-	conf.winbackMonths = envIntDefault("WINBACK_MONTHS", 1)
-	conf.winbackValidHours = envIntDefault("WINBACK_VALID_HOURS", 48)
-	conf.winbackRecurringEnabled = envBool("WINBACK_RECURRING_ENABLED")
-
-	if conf.trialInactiveNotificationEnabled {
+	c.trialInactiveNotificationEnabled = envBool("TRIAL_INACTIVE_NOTIFICATION_ENABLED")
+	c.winbackEnabled = envBool("WINBACK_ENABLED")
+	c.winbackPrice = envIntDefault("WINBACK_PRICE", 100)
+	c.winbackDevices = envIntDefault("WINBACK_DEVICES", 1)
+	c.winbackMonths = envIntDefault("WINBACK_MONTHS", 1)
+	c.winbackValidHours = envIntDefault("WINBACK_VALID_HOURS", 48)
+	c.winbackRecurringEnabled = envBool("WINBACK_RECURRING_ENABLED")
+
+	if c.trialInactiveNotificationEnabled {
 		slog.Info("Trial inactive notification enabled")
 	}
-	if conf.winbackEnabled {
+	if c.winbackEnabled {
 		slog.Info("Winback offers enabled",
-			"price", conf.winbackPrice,
-			"devices", conf.winbackDevices,
-			"months", conf.winbackMonths,
-			"validHours", conf.winbackValidHours)
+			"price", c.winbackPrice,
+			"devices", c.winbackDevices,
+			"months", c.winbackMonths,
+			"validHours", c.winbackValidHours)
 	}
 
 	// Remnawave webhooks config
-	conf.remnawaveWebhookSecret = os.Getenv("REMNAWAVE_WEBHOOK_SECRET")
-	conf.remnawaveWebhookPath = envStringDefault("REMNAWAVE_WEBHOOK_PATH", "/remnawave-webhook")
-	if conf.remnawaveWebhookSecret != "" {
-		slog.Info("Remnawave webhooks enabled", "path", conf.remnawaveWebhookPath)
+	c.remnawaveWebhookSecret = os.Getenv("REMNAWAVE_WEBHOOK_SECRET")
+	c.remnawaveWebhookPath = envStringDefault("REMNAWAVE_WEBHOOK_PATH", "/remnawave-webhook")
+	c.remnawaveWebhookMaxAttempts = envIntDefault("REMNAWAVE_WEBHOOK_MAX_ATTEMPTS", 5)
+	c.fulfillmentRetryMaxAttempts = envIntDefault("FULFILLMENT_RETRY_MAX_ATTEMPTS", 5)
+	if c.fulfillmentRetryMaxAttempts < 1 {
+		addIssue("FULFILLMENT_RETRY_MAX_ATTEMPTS must be at least 1")
+	}
+	c.firstConnectedNotificationEnabled = envBool("FIRST_CONNECTED_NOTIFICATION_ENABLED")
+	c.trafficThresholdNotificationEnabled = envBool("TRAFFIC_THRESHOLD_NOTIFICATION_ENABLED")
+	c.userStatusSyncEnabled = envBool("USER_STATUS_SYNC_ENABLED")
+	if c.remnawaveWebhookSecret != "" {
+		slog.Info("Remnawave webhooks enabled", "path", c.remnawaveWebhookPath,
+			"firstConnected", c.firstConnectedNotificationEnabled,
+			"trafficThreshold", c.trafficThresholdNotificationEnabled,
+			"statusSync", c.userStatusSyncEnabled)
 	}
 
 	// Recurring payments config
-	conf.recurringPaymentsEnabled = envBool("RECURRING_PAYMENTS_ENABLED")
-	conf.recurringNotifyHoursBefore = envIntDefault("RECURRING_NOTIFY_HOURS_BEFORE", 48)
-	if conf.recurringPaymentsEnabled {
-		slog.Info("Recurring payments enabled", "notifyHoursBefore", conf.recurringNotifyHoursBefore)
+	c.recurringPaymentsEnabled = envBool("RECURRING_PAYMENTS_ENABLED")
+	c.recurringNotifyHoursBefore = envIntDefault("RECURRING_NOTIFY_HOURS_BEFORE", 48)
+	if c.recurringPaymentsEnabled {
+		slog.Info("Recurring payments enabled", "notifyHoursBefore", c.recurringNotifyHoursBefore)
 	}
 
 	// Promo tariff codes config
-	conf.promoTariffCodesEnabled = envBool("PROMO_TARIFF_CODES_ENABLED")
-	conf.promoTariffRecurringEnabled = envBool("PROMO_TARIFF_RECURRING_ENABLED")
-	if conf.promoTariffCodesEnabled {
-		slog.Info("Promo tariff codes enabled", "recurringEnabled", conf.promoTariffRecurringEnabled)
+	c.promoTariffCodesEnabled = envBool("PROMO_TARIFF_CODES_ENABLED")
+	c.promoTariffRecurringEnabled = envBool("PROMO_TARIFF_RECURRING_ENABLED")
+	if c.promoTariffCodesEnabled {
+		slog.Info("Promo tariff codes enabled", "recurringEnabled", c.promoTariffRecurringEnabled)
+	}
+
+	// Daily admin report config
+	c.dailyReportEnabled = envBool("DAILY_REPORT_ENABLED")
+	c.dailyReportHour = envIntDefault("DAILY_REPORT_HOUR", 9)
+	if c.dailyReportHour < 0 || c.dailyReportHour > 23 {
+		addIssue("DAILY_REPORT_HOUR must be between 0 and 23")
+	}
+	if c.dailyReportEnabled {
+		slog.Info("Daily admin report enabled", "hour", c.dailyReportHour)
+	}
+
+	// Start menu config
+	c.menuButtons = parseMenuButtons()
+	c.startMenuLayout = parseStartMenuLayout(envStringDefault("START_MENU_LAYOUT", DefaultStartMenuLayout), c.menuButtons)
+
+	// Mini App API config
+	c.miniAppAPIEnabled = envBool("MINI_APP_API_ENABLED")
+	c.miniAppAPIPath = envStringDefault("MINI_APP_API_PATH", "/api/miniapp")
+	c.miniAppInitDataTTLHours = envIntDefault("MINI_APP_INIT_DATA_TTL_HOURS", 24)
+	if c.miniAppAPIEnabled {
+		slog.Info("Mini App API enabled", "path", c.miniAppAPIPath)
+	}
+
+	// Purchase archive config
+	c.purchaseArchiveAfterMonths = envIntDefault("PURCHASE_ARCHIVE_AFTER_MONTHS", 0)
+	if c.purchaseArchiveAfterMonths < 0 {
+		addIssue("PURCHASE_ARCHIVE_AFTER_MONTHS must be >= 0")
+	}
+	c.purchaseArchiveHour = envIntDefault("PURCHASE_ARCHIVE_HOUR", 4)
+	if c.purchaseArchiveHour < 0 || c.purchaseArchiveHour > 23 {
+		addIssue("PURCHASE_ARCHIVE_HOUR must be between 0 and 23")
+	}
+	if c.purchaseArchiveAfterMonths > 0 {
+		slog.Info("Purchase archive enabled", "afterMonths", c.purchaseArchiveAfterMonths, "hour", c.purchaseArchiveHour)
+	}
+
+	c.dbSlowQueryMs = envIntDefault("DB_SLOW_QUERY_MS", 200)
+
+	c.activityUpdateIntervalMinutes = envIntDefault("ACTIVITY_UPDATE_INTERVAL_MINUTES", 5)
+
+	// Spending limits config
+	c.maxPurchasesPerDay = envIntDefault("MAX_PURCHASES_PER_DAY", 0)
+	c.maxRecurringAmountPerDay = envIntDefault("MAX_RECURRING_AMOUNT_PER_DAY", 0)
+	if c.maxPurchasesPerDay < 0 || c.maxRecurringAmountPerDay < 0 {
+		addIssue("MAX_PURCHASES_PER_DAY and MAX_RECURRING_AMOUNT_PER_DAY must be >= 0")
+	}
+	c.recurringConfirmThreshold = envIntDefault("RECURRING_CONFIRM_THRESHOLD", 0)
+	if c.recurringConfirmThreshold < 0 {
+		addIssue("RECURRING_CONFIRM_THRESHOLD must be >= 0")
+	}
+
+	// YooKassa notifications config
+	c.yookasaWebhookPath = os.Getenv("YOOKASA_WEBHOOK_PATH")
+
+	// Invoice expiration config
+	c.invoiceTTLMinutes = envIntDefault("INVOICE_TTL_MINUTES", 60)
+	if c.invoiceTTLMinutes < 0 {
+		addIssue("INVOICE_TTL_MINUTES must be >= 0")
+	}
+
+	// Alerts config
+	c.alertsEnabled = envBool("ALERTS_ENABLED")
+	c.alertChatID = 0
+	if v := os.Getenv("ALERT_CHAT_ID"); v != "" {
+		c.alertChatID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			addIssue("ALERT_CHAT_ID must be a valid chat id, got %q", v)
+		}
+	}
+	c.alertCooldownMinutes = envIntDefault("ALERT_COOLDOWN_MINUTES", 30)
+	c.alertHealthFailureThreshold = envIntDefault("ALERT_HEALTH_FAILURE_THRESHOLD", 3)
+	c.alertErrorSpikeThreshold = envIntDefault("ALERT_ERROR_SPIKE_THRESHOLD", 5)
+	if c.alertsEnabled {
+		slog.Info("Admin alerts enabled",
+			"alertChatId", c.alertChatID,
+			"cooldownMinutes", c.alertCooldownMinutes,
+			"healthFailureThreshold", c.alertHealthFailureThreshold,
+			"errorSpikeThreshold", c.alertErrorSpikeThreshold)
+	}
+
+	c.timeTravelEnabled = envBool("TIME_TRAVEL_ENABLED")
+	if c.timeTravelEnabled {
+		slog.Warn("Time travel enabled, do not use in production")
+	}
+
+	c.reconciliationEnabled = envBool("RECONCILIATION_ENABLED")
+	c.reconciliationHour = envIntDefault("RECONCILIATION_HOUR", 5)
+	if c.reconciliationHour < 0 || c.reconciliationHour > 23 {
+		addIssue("RECONCILIATION_HOUR must be between 0 and 23")
+	}
+
+	c.tosVersion = os.Getenv("TOS_VERSION")
+	if c.tosVersion == "" {
+		c.tosVersion = "1"
+	}
+
+	c.sandboxPaymentsEnabled = envBool("SANDBOX_PAYMENTS_ENABLED")
+	c.yookasaTestShopId = os.Getenv("YOOKASA_TEST_SHOP_ID")
+	c.yookasaTestSecretKey = os.Getenv("YOOKASA_TEST_SECRET_KEY")
+	if c.sandboxPaymentsEnabled {
+		slog.Warn("Sandbox payments enabled for admin", "yookasaTestShop", c.yookasaTestShopId != "")
+	}
+
+	c.statusCardCacheSeconds = envIntDefault("STATUS_CARD_CACHE_SECONDS", 60)
+	if c.statusCardCacheSeconds < 0 {
+		addIssue("STATUS_CARD_CACHE_SECONDS must be non-negative")
+	}
+
+	c.cleanChatMode = envStringDefault("CLEAN_CHAT_MODE", CleanChatOff)
+	switch c.cleanChatMode {
+	case CleanChatOff, CleanChatMenus, CleanChatFull:
+	default:
+		addIssue("CLEAN_CHAT_MODE must be one of 'off', 'menus' or 'full'")
+	}
+
+	c.cronSchedules = map[string]string{
+		JobCryptoPayInvoiceCheck: "*/5 * * * * *",
+		JobYookasaInvoiceCheck:   "*/10 * * * * *",
+		JobTrialInactive:         "0 * * * *",
+		JobHealthMonitor:         "* * * * *",
+		JobInvoiceExpirer:        "* * * * *",
+		JobDailyReport:           fmt.Sprintf("0 %d * * *", c.dailyReportHour),
+		JobReconciliation:        fmt.Sprintf("0 %d * * *", c.reconciliationHour),
+		JobPurchaseArchive:       fmt.Sprintf("0 %d * * *", c.purchaseArchiveHour),
+		JobWebhookRetry:          "* * * * *",
+		JobFeatureFlagsRefresh:   "* * * * *",
+		JobWinbackPaid:           "0 12 * * *",
+		JobCheckoutReminder:      "*/5 * * * *",
+		JobOfferReminder:         "*/15 * * * *",
+		JobOutboxDelivery:        "*/5 * * * *",
+		JobTrialUpsell:           "*/15 * * * *",
+		JobCustomCommandsRefresh: "* * * * *",
+		JobRecurringBulkNotify:   "* * * * *",
+		JobFieldEncryption:       "*/10 * * * *",
+		JobTrialCleanup:          "30 3 * * *",
+		JobFulfillmentRetry:      "* * * * *",
+		JobTranslationsRefresh:   "* * * * *",
+	}
+	for job := range c.cronSchedules {
+		key := "CRON_" + strings.ToUpper(job)
+		schedule := os.Getenv(key)
+		if schedule == "" {
+			continue
+		}
+		if _, err := CronParser.Parse(schedule); err != nil {
+			addIssue("%s is not a valid cron schedule: %v", key, err)
+			continue
+		}
+		c.cronSchedules[job] = schedule
+	}
+
+	c.remnawaveTimeoutSeconds = envIntDefault("REMNAWAVE_TIMEOUT_SECONDS", 10)
+	if c.remnawaveTimeoutSeconds < 1 {
+		addIssue("REMNAWAVE_TIMEOUT_SECONDS must be at least 1")
+	}
+	c.remnawaveTimeoutBudgetSeconds = envIntDefault("REMNAWAVE_TIMEOUT_BUDGET_SECONDS", 30)
+	if c.remnawaveTimeoutBudgetSeconds < c.remnawaveTimeoutSeconds {
+		addIssue("REMNAWAVE_TIMEOUT_BUDGET_SECONDS must be at least REMNAWAVE_TIMEOUT_SECONDS (%d)", c.remnawaveTimeoutSeconds)
+	}
+	c.remnawaveRetries = envIntDefault("REMNAWAVE_RETRIES", 2)
+	if c.remnawaveRetries < 0 {
+		addIssue("REMNAWAVE_RETRIES must be non-negative")
+	}
+	c.remnawaveBreakerThreshold = envIntDefault("REMNAWAVE_BREAKER_THRESHOLD", 5)
+	if c.remnawaveBreakerThreshold < 1 {
+		addIssue("REMNAWAVE_BREAKER_THRESHOLD must be at least 1")
+	}
+	c.remnawaveBreakerCooldownSeconds = envIntDefault("REMNAWAVE_BREAKER_COOLDOWN_SECONDS", 30)
+	if c.remnawaveBreakerCooldownSeconds < 1 {
+		addIssue("REMNAWAVE_BREAKER_COOLDOWN_SECONDS must be at least 1")
+	}
+
+	c.shutdownTimeoutSeconds = envIntDefault("SHUTDOWN_TIMEOUT_SECONDS", 25)
+	if c.shutdownTimeoutSeconds < 1 {
+		addIssue("SHUTDOWN_TIMEOUT_SECONDS must be at least 1")
+	}
+
+	c.trialPhoneVerificationEnabled = envBool("TRIAL_PHONE_VERIFICATION_ENABLED")
+	c.trialPhoneHashSecret = os.Getenv("TRIAL_PHONE_HASH_SECRET")
+
+	c.teamPlansEnabled = envBool("TEAM_PLANS_ENABLED")
+	c.teamSeatOptions = parseTeamSeatOptions(os.Getenv("TEAM_SEAT_OPTIONS"))
+	c.teamSeatPrice = envIntDefault("TEAM_SEAT_PRICE", 0)
+	c.teamSeatStarsPrice = envIntDefault("TEAM_SEAT_STARS_PRICE", 0)
+	if c.teamSeatPrice < 0 || c.teamSeatStarsPrice < 0 {
+		addIssue("TEAM_SEAT_PRICE and TEAM_SEAT_STARS_PRICE must be non-negative")
+	}
+
+	c.giftsEnabled = envBool("GIFTS_ENABLED")
+
+	c.winbackPaidEnabled = envBool("WINBACK_PAID_ENABLED")
+	c.winbackPaidDays = parseWinbackPaidDays(os.Getenv("WINBACK_PAID_DAYS"))
+	c.winbackPaidDiscountPercent = envIntDefault("WINBACK_PAID_DISCOUNT_PERCENT", 20)
+	if c.winbackPaidDiscountPercent < 1 || c.winbackPaidDiscountPercent > 90 {
+		addIssue("WINBACK_PAID_DISCOUNT_PERCENT must be between 1 and 90")
+	}
+	c.winbackPaidValidHours = envIntDefault("WINBACK_PAID_VALID_HOURS", 72)
+	if c.winbackPaidValidHours < 1 {
+		addIssue("WINBACK_PAID_VALID_HOURS must be at least 1")
+	}
+
+	c.receiptsEnabled = envBool("RECEIPTS_ENABLED")
+	c.receiptOperator = ReceiptOperator{
+		Name:    strings.TrimSpace(os.Getenv("RECEIPT_OPERATOR_NAME")),
+		TaxID:   strings.TrimSpace(os.Getenv("RECEIPT_OPERATOR_TAX_ID")),
+		Address: strings.TrimSpace(os.Getenv("RECEIPT_OPERATOR_ADDRESS")),
+		Contact: strings.TrimSpace(os.Getenv("RECEIPT_OPERATOR_CONTACT")),
+		VATNote: strings.TrimSpace(os.Getenv("RECEIPT_VAT_NOTE")),
+	}
+	c.receiptNumberPrefix = envStringDefault("RECEIPT_NUMBER_PREFIX", "INV")
+	if len(c.receiptNumberPrefix) > 16 {
+		addIssue("RECEIPT_NUMBER_PREFIX must be at most 16 characters")
+	}
+
+	c.checkoutReminderEnabled = envBool("CHECKOUT_REMINDER_ENABLED")
+	c.checkoutReminderDelayMinutes = envIntDefault("CHECKOUT_REMINDER_DELAY_MINUTES", 30)
+	if c.checkoutReminderDelayMinutes < 5 {
+		addIssue("CHECKOUT_REMINDER_DELAY_MINUTES must be at least 5")
+	}
+
+	c.recurringBulkUndoMinutes = envIntDefault("RECURRING_BULK_UNDO_MINUTES", 30)
+	if c.recurringBulkUndoMinutes < 1 {
+		addIssue("RECURRING_BULK_UNDO_MINUTES must be at least 1")
+	}
+
+	c.offerReminderEnabled = envBool("OFFER_REMINDER_ENABLED")
+	c.offerReminderHoursBefore = envIntDefault("OFFER_REMINDER_HOURS_BEFORE", 6)
+	if c.offerReminderHoursBefore < 1 {
+		addIssue("OFFER_REMINDER_HOURS_BEFORE must be at least 1")
+	}
+
+	c.trialUpsellEnabled = envBool("TRIAL_UPSELL_ENABLED")
+	c.trialUpsellSteps = nil
+	for _, step := range []struct {
+		name, env    string
+		hours        int
+		beforeExpiry bool
+	}{
+		{TrialUpsellStepSetup, "TRIAL_UPSELL_SETUP", 24, false},
+		{TrialUpsellStepDiscount, "TRIAL_UPSELL_DISCOUNT", 48, false},
+		{TrialUpsellStepLastDay, "TRIAL_UPSELL_LAST_DAY", 24, true},
+	} {
+		if os.Getenv(step.env+"_ENABLED") == "false" {
+			continue
+		}
+		hours := envIntDefault(step.env+"_HOURS", step.hours)
+		if hours < 1 {
+			addIssue("%s_HOURS must be at least 1", step.env)
+			continue
+		}
+		c.trialUpsellSteps = append(c.trialUpsellSteps, TrialUpsellStep{Name: step.name, Hours: hours, BeforeExpiry: step.beforeExpiry})
+	}
+	c.trialUpsellDiscountPercent = envIntDefault("TRIAL_UPSELL_DISCOUNT_PERCENT", 20)
+	if c.trialUpsellDiscountPercent < 1 || c.trialUpsellDiscountPercent > 90 {
+		addIssue("TRIAL_UPSELL_DISCOUNT_PERCENT must be between 1 and 90")
+	}
+	c.trialUpsellDiscountValidHours = envIntDefault("TRIAL_UPSELL_DISCOUNT_VALID_HOURS", 48)
+	if c.trialUpsellDiscountValidHours < 1 {
+		addIssue("TRIAL_UPSELL_DISCOUNT_VALID_HOURS must be at least 1")
+	}
+
+	c.trialCleanupEnabled = envBool("TRIAL_CLEANUP_ENABLED")
+	c.trialCleanupAfterDays = envIntDefault("TRIAL_CLEANUP_AFTER_DAYS", 30)
+	if c.trialCleanupAfterDays < 1 {
+		addIssue("TRIAL_CLEANUP_AFTER_DAYS must be at least 1")
+	}
+	c.trialCleanupMode = envStringDefault("TRIAL_CLEANUP_MODE", TrialCleanupDisable)
+	switch c.trialCleanupMode {
+	case TrialCleanupDisable, TrialCleanupDelete:
+	default:
+		addIssue("TRIAL_CLEANUP_MODE must be one of 'disable' or 'delete'")
+	}
+
+	c.eventsWebhookURL = strings.TrimSpace(os.Getenv("EVENTS_WEBHOOK_URL"))
+	if c.eventsWebhookURL != "" && !strings.HasPrefix(c.eventsWebhookURL, "https://") && !strings.HasPrefix(c.eventsWebhookURL, "http://") {
+		addIssue("EVENTS_WEBHOOK_URL must start with http:// or https://, got %q", c.eventsWebhookURL)
+	}
+	c.eventsWebhookSecret = os.Getenv("EVENTS_WEBHOOK_SECRET")
+	c.eventsWebhookTimeoutSeconds = envIntDefault("EVENTS_WEBHOOK_TIMEOUT_SECONDS", 10)
+	if c.eventsWebhookTimeoutSeconds < 1 {
+		addIssue("EVENTS_WEBHOOK_TIMEOUT_SECONDS must be at least 1")
+	}
+	c.eventTypes = parseEventTypes("EVENTS_TYPES", os.Getenv("EVENTS_TYPES"))
+
+	c.logChannelID = 0
+	if v := os.Getenv("LOG_CHANNEL_ID"); v != "" {
+		c.logChannelID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			addIssue("LOG_CHANNEL_ID must be a valid chat id, got %q", v)
+		}
+	}
+	c.logChannelEventTypes = parseEventTypes("LOG_CHANNEL_EVENTS", os.Getenv("LOG_CHANNEL_EVENTS"))
+	c.logChannelMaxPerMinute = envIntDefault("LOG_CHANNEL_MAX_PER_MINUTE", 20)
+	if c.logChannelMaxPerMinute < 0 {
+		addIssue("LOG_CHANNEL_MAX_PER_MINUTE must not be negative")
+	}
+
+	c.analyticsProvider = strings.ToLower(strings.TrimSpace(os.Getenv("ANALYTICS_PROVIDER")))
+	c.analyticsURL = strings.TrimSpace(os.Getenv("ANALYTICS_URL"))
+	c.analyticsAPIKey = os.Getenv("ANALYTICS_API_KEY")
+	c.analyticsSalt = os.Getenv("ANALYTICS_SALT")
+	c.analyticsEnabled = os.Getenv("ANALYTICS_ENABLED") != "false"
+	switch c.analyticsProvider {
+	case "":
+	case "posthog", "amplitude":
+		if c.analyticsURL == "" {
+			c.analyticsURL = defaultAnalyticsURLs[c.analyticsProvider]
+		}
+		if !strings.HasPrefix(c.analyticsURL, "https://") && !strings.HasPrefix(c.analyticsURL, "http://") {
+			addIssue("ANALYTICS_URL must start with http:// or https://, got %q", c.analyticsURL)
+		}
+		if c.analyticsAPIKey == "" {
+			addIssue("ANALYTICS_API_KEY is required when ANALYTICS_PROVIDER is set")
+		}
+		if len(c.analyticsSalt) < 16 {
+			addIssue("ANALYTICS_SALT must be at least 16 characters when ANALYTICS_PROVIDER is set")
+		}
+	default:
+		addIssue("ANALYTICS_PROVIDER must be posthog or amplitude, got %q", c.analyticsProvider)
+	}
+	c.analyticsBatchSize = envIntDefault("ANALYTICS_BATCH_SIZE", 50)
+	if c.analyticsBatchSize < 1 || c.analyticsBatchSize > 1000 {
+		addIssue("ANALYTICS_BATCH_SIZE must be between 1 and 1000")
+	}
+	c.analyticsFlushSeconds = envIntDefault("ANALYTICS_FLUSH_SECONDS", 10)
+	if c.analyticsFlushSeconds < 1 {
+		addIssue("ANALYTICS_FLUSH_SECONDS must be at least 1")
+	}
+
+	c.subscriptionRotationLimit = envIntDefault("SUBSCRIPTION_ROTATION_LIMIT", 3)
+	if c.subscriptionRotationLimit < 0 {
+		addIssue("SUBSCRIPTION_ROTATION_LIMIT must not be negative")
+	}
+
+	c.broadcastFrequencyCap = envIntDefault("BROADCAST_FREQUENCY_CAP", 0)
+	if c.broadcastFrequencyCap < 0 {
+		addIssue("BROADCAST_FREQUENCY_CAP must not be negative")
+	}
+	c.broadcastFrequencyWindowDays = envIntDefault("BROADCAST_FREQUENCY_WINDOW_DAYS", 7)
+	if c.broadcastFrequencyWindowDays < 1 {
+		addIssue("BROADCAST_FREQUENCY_WINDOW_DAYS must be at least 1")
+	}
+
+	c.privacySelfServiceEnabled = envBool("PRIVACY_SELF_SERVICE_ENABLED")
+
+	c.inlineModeEnabled = envBool("INLINE_MODE_ENABLED")
+	c.inlinePromoCodes = parseInlinePromoCodes(os.Getenv("INLINE_PROMO_CODES"))
+
+	c.faqEnabled = envBool("FAQ_ENABLED")
+
+	c.surveyEnabled = envBool("SURVEY_ENABLED")
+	c.surveyIntervalDays = envIntDefault("SURVEY_INTERVAL_DAYS", 90)
+	if c.surveyIntervalDays < 1 {
+		addIssue("SURVEY_INTERVAL_DAYS must be at least 1")
+	}
+
+	c.notificationFeedbackEnabled = envBool("NOTIFICATION_FEEDBACK_ENABLED")
+
+	c.remnawaveTagSyncEnabled = envBool("REMNAWAVE_TAG_SYNC_ENABLED")
+	c.winbackRemnawaveTag = envStringDefault("WINBACK_REMNAWAVE_TAG", "WINBACK")
+	if !remnawaveTagRegex.MatchString(c.winbackRemnawaveTag) {
+		addIssue("WINBACK_REMNAWAVE_TAG must contain only A-Z, 0-9 and _ and be at most 16 characters")
+	}
+
+	c.trialPolicy = envStringDefault("TRIAL_POLICY", TrialPolicyOnce)
+	switch c.trialPolicy {
+	case TrialPolicyOnce, TrialPolicyCooldown, TrialPolicyAfterLapse:
+	default:
+		addIssue("TRIAL_POLICY must be one of 'once', 'cooldown' or 'after_lapse'")
+	}
+	c.trialCooldownMonths = envIntDefault("TRIAL_COOLDOWN_MONTHS", 6)
+	if c.trialCooldownMonths < 1 {
+		addIssue("TRIAL_COOLDOWN_MONTHS must be at least 1")
+	}
+
+	c.paymentFeePercents = make(map[string]int)
+	for method, key := range map[string]string{
+		"crypto":   "PAYMENT_FEE_CRYPTO_PERCENT",
+		"yookasa":  "PAYMENT_FEE_YOOKASA_PERCENT",
+		"telegram": "PAYMENT_FEE_STARS_PERCENT",
+	} {
+		percent := envIntDefault(key, 0)
+		if percent < -90 || percent > 100 {
+			addIssue("%s must be between -90 and 100", key)
+			continue
+		}
+		c.paymentFeePercents[method] = percent
+	}
+
+	c.paymentMinAmounts = map[string]int{
+		"crypto":  envIntDefault("CRYPTO_PAY_MIN_AMOUNT", 1),
+		"yookasa": envIntDefault("YOOKASA_MIN_AMOUNT", 1),
+	}
+	c.paymentAmountWarnings = checkPaymentAmounts(c)
+	for _, warning := range c.paymentAmountWarnings {
+		slog.Warn("Price is outside payment provider limits, payment button will be hidden", "price", warning)
+	}
+
+	c.timezone = time.Local
+	if v := os.Getenv("TIMEZONE"); v != "" {
+		if c.timezone, err = time.LoadLocation(v); err != nil {
+			addIssue("TIMEZONE must be an IANA time zone name like Europe/Moscow, got %q", v)
+			c.timezone = time.Local
+		}
+	}
+	c.notifyLocalFromHour, c.notifyLocalToHour, c.notifyLocalHoursEnabled = parseNotifyLocalHours(os.Getenv("NOTIFY_LOCAL_HOURS"))
+}
+
+// knownEventTypes типы событий для внешних интеграций, должны совпадать с events.Types()
+var knownEventTypes = []string{
+	"payment.succeeded", "payment.refunded", "payment.recurring_failed",
+	"trial.activated", "subscription.expired", "sync.completed",
+}
+
+// defaultAnalyticsURLs адреса приёма событий по умолчанию для ANALYTICS_PROVIDER
+var defaultAnalyticsURLs = map[string]string{
+	"posthog":   "https://us.i.posthog.com/batch/",
+	"amplitude": "https://api2.amplitude.com/2/httpapi",
+}
+
+// KnownEventTypes возвращает типы событий, которые можно указать в EVENTS_TYPES и LOG_CHANNEL_EVENTS
+func KnownEventTypes() []string {
+	return knownEventTypes
+}
+
+// parseEventTypes парсит список типов событий через запятую из переменной name, пустое значение — все события
+func parseEventTypes(name, v string) []string {
+	var types []string
+	for _, part := range strings.Split(v, ",") {
+		t := strings.TrimSpace(part)
+		if t == "" {
+			continue
+		}
+		if !slices.Contains(knownEventTypes, t) {
+			addIssue("unknown event type %q in %s: must be one of %s", t, name, strings.Join(knownEventTypes, ", "))
+			continue
+		}
+		types = append(types, t)
+	}
+	return types
+}
+
+// parseInlinePromoCodes парсит INLINE_PROMO_CODES: промокоды через запятую, регистр не важен
+func parseInlinePromoCodes(v string) []string {
+	var codes []string
+	for _, part := range strings.Split(v, ",") {
+		code := strings.ToUpper(strings.TrimSpace(part))
+		if code == "" || slices.Contains(codes, code) {
+			continue
+		}
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+// parseLanguageFallbacks парсит LANGUAGE_FALLBACKS вида "uk:ru,be:ru": язык клиента и запасной язык через двоеточие
+func parseLanguageFallbacks(v string) map[string]string {
+	fallbacks := make(map[string]string)
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lang, fallback, ok := strings.Cut(strings.ToLower(part), ":")
+		lang, fallback = strings.TrimSpace(lang), strings.TrimSpace(fallback)
+		if !ok || lang == "" || fallback == "" || lang == fallback {
+			addIssue("invalid LANGUAGE_FALLBACKS entry %q: must look like uk:ru", part)
+			continue
+		}
+		fallbacks[lang] = fallback
+	}
+	return fallbacks
+}
+
+// parseWinbackPaidDays парсит WINBACK_PAID_DAYS (по умолчанию "7,30": через неделю и через месяц после ухода)
+func parseWinbackPaidDays(v string) []int {
+	if v == "" {
+		v = "7,30"
+	}
+	var days []int
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(v, ",") {
+		d, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || d < 1 || d > 365 {
+			addIssue("invalid day count %q in WINBACK_PAID_DAYS: must be an integer from 1 to 365", part)
+			continue
+		}
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		days = append(days, d)
+	}
+	sort.Ints(days)
+	return days
+}
+
+// parseNotifyLocalHours парсит NOTIFY_LOCAL_HOURS вида "9-21": уведомления доставляются с 9:00 до 21:00.
+// Пустое значение — без ограничения
+func parseNotifyLocalHours(v string) (from, to int, enabled bool) {
+	if v == "" {
+		return 0, 0, false
+	}
+	fromStr, toStr, ok := strings.Cut(v, "-")
+	from, fromErr := strconv.Atoi(strings.TrimSpace(fromStr))
+	to, toErr := strconv.Atoi(strings.TrimSpace(toStr))
+	if !ok || fromErr != nil || toErr != nil || from < 0 || to > 24 || from >= to {
+		addIssue("NOTIFY_LOCAL_HOURS must look like 9-21 with hours from 0 to 24, got %q", v)
+		return 0, 0, false
+	}
+	return from, to, true
+}
+
+// maxTeamSeats ограничивает размер команды: места выводятся владельцу одним сообщением с кнопками
+const maxTeamSeats = 20
+
+// parseTeamSeatOptions парсит TEAM_SEAT_OPTIONS (по умолчанию "3,5,10")
+func parseTeamSeatOptions(v string) []int {
+	if v == "" {
+		v = "3,5,10"
+	}
+	var options []int
+	for _, part := range strings.Split(v, ",") {
+		seats, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || seats < 2 || seats > maxTeamSeats {
+			addIssue("invalid seat count %q in TEAM_SEAT_OPTIONS: must be an integer from 2 to %d", part, maxTeamSeats)
+			continue
+		}
+		options = append(options, seats)
+	}
+	return options
+}
+
+// checkPaymentAmounts проверяет цены всех тарифов и периодов по лимитам включённых платёжных систем
+func checkPaymentAmounts(c *config) []string {
+	type pricedTariff struct {
+		name   string
+		prices func(month int) int
+		stars  func(month int) int
+	}
+	var tariffs []pricedTariff
+	if len(c.tariffs) > 0 {
+		for _, t := range c.tariffs {
+			tariffs = append(tariffs, pricedTariff{name: "тариф " + t.Name, prices: t.Price, stars: t.StarsPrice})
+		}
+	} else {
+		tariffs = append(tariffs, pricedTariff{name: "цены PRICE_*", prices: c.price, stars: c.starsPrice})
+	}
+
+	var warnings []string
+	check := func(method, label string, tariff pricedTariff, month, price int) {
+		if price <= 0 {
+			return
+		}
+		if amount, ok := PaymentAmount(method, price); !ok {
+			minAmount, maxAmount := PaymentAmountRange(method)
+			limits := fmt.Sprintf("от %d", minAmount)
+			if maxAmount > 0 {
+				limits += fmt.Sprintf(" до %d", maxAmount)
+			}
+			warnings = append(warnings, fmt.Sprintf("%s, %s, %d мес: %d (допустимо %s)", label, tariff.name, month, amount, limits))
+		}
+	}
+	for _, tariff := range tariffs {
+		for _, month := range SupportedMonths() {
+			if c.isCryptoEnabled {
+				check("crypto", "CryptoPay", tariff, month, tariff.prices(month))
+			}
+			if c.isYookasaEnabled {
+				check("yookasa", "ЮKassa", tariff, month, tariff.prices(month))
+			}
+			if c.isTelegramStarsEnabled {
+				check("telegram", "Telegram Stars", tariff, month, tariff.stars(month))
+			}
+		}
 	}
+	return warnings
 }
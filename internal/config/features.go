@@ -0,0 +1,132 @@
+package config
+
+import "sync"
+
+// Фичи, которые админ может переключать без перезапуска. Значение из БД перекрывает переменную окружения
+const (
+	FeatureWinback                      = "winback"
+	FeatureWinbackRecurring             = "winback_recurring"
+	FeatureWinbackPaid                  = "winback_paid"
+	FeatureRecurringPayments            = "recurring_payments"
+	FeaturePromoTariffCodes             = "promo_tariff_codes"
+	FeaturePromoTariffRecurring         = "promo_tariff_recurring"
+	FeatureTrialInactiveNotification    = "trial_inactive_notification"
+	FeatureFirstConnectedNotification   = "first_connected_notification"
+	FeatureTrafficThresholdNotification = "traffic_threshold_notification"
+	FeatureUserStatusSync               = "user_status_sync"
+	FeatureDailyReport                  = "daily_report"
+	FeatureSandboxPayments              = "sandbox_payments"
+	FeatureCheckoutReminder             = "checkout_reminder"
+	FeatureOfferReminder                = "offer_reminder"
+	FeaturePrivacySelfService           = "privacy_self_service"
+	FeatureInlineMode                   = "inline_mode"
+	FeatureTrialUpsell                  = "trial_upsell"
+	FeatureFaq                          = "faq"
+	FeatureAnalytics                    = "analytics"
+	FeatureSurvey                       = "survey"
+	FeatureNotificationFeedback         = "notification_feedback"
+)
+
+// FeatureFlag описание фичи для админки
+type FeatureFlag struct {
+	Name  string
+	Title string
+	Env   string
+	env   func() bool
+}
+
+// EnvValue возвращает значение фичи из переменной окружения
+func (f FeatureFlag) EnvValue() bool {
+	return f.env()
+}
+
+// featureFlags переключаемые фичи. Сюда попадают только флаги, которые читаются во время работы:
+// настройки, от которых зависит сборка сервисов при старте, по-прежнему требуют перезапуска
+var featureFlags = []FeatureFlag{
+	{Name: FeatureWinback, Title: "Winback предложения", Env: "WINBACK_ENABLED", env: func() bool { return conf.winbackEnabled }},
+	{Name: FeatureWinbackRecurring, Title: "Автопродление winback", Env: "WINBACK_RECURRING_ENABLED", env: func() bool { return conf.winbackRecurringEnabled }},
+	{Name: FeatureWinbackPaid, Title: "Winback ушедших клиентов", Env: "WINBACK_PAID_ENABLED", env: func() bool { return conf.winbackPaidEnabled }},
+	{Name: FeatureRecurringPayments, Title: "Автопродление", Env: "RECURRING_PAYMENTS_ENABLED", env: func() bool { return conf.recurringPaymentsEnabled }},
+	{Name: FeaturePromoTariffCodes, Title: "Промокоды на тариф", Env: "PROMO_TARIFF_CODES_ENABLED", env: func() bool { return conf.promoTariffCodesEnabled }},
+	{Name: FeaturePromoTariffRecurring, Title: "Автопродление промо-тарифа", Env: "PROMO_TARIFF_RECURRING_ENABLED", env: func() bool { return conf.promoTariffRecurringEnabled }},
+	{Name: FeatureTrialInactiveNotification, Title: "Напоминание неактивным триалам", Env: "TRIAL_INACTIVE_NOTIFICATION_ENABLED", env: func() bool { return conf.trialInactiveNotificationEnabled }},
+	{Name: FeatureFirstConnectedNotification, Title: "Приветствие после подключения", Env: "FIRST_CONNECTED_NOTIFICATION_ENABLED", env: func() bool { return conf.firstConnectedNotificationEnabled }},
+	{Name: FeatureTrafficThresholdNotification, Title: "Уведомления о трафике", Env: "TRAFFIC_THRESHOLD_NOTIFICATION_ENABLED", env: func() bool { return conf.trafficThresholdNotificationEnabled }},
+	{Name: FeatureUserStatusSync, Title: "Синхронизация статуса", Env: "USER_STATUS_SYNC_ENABLED", env: func() bool { return conf.userStatusSyncEnabled }},
+	{Name: FeatureDailyReport, Title: "Ежедневная сводка", Env: "DAILY_REPORT_ENABLED", env: func() bool { return conf.dailyReportEnabled }},
+	{Name: FeatureSandboxPayments, Title: "Тестовые оплаты админа", Env: "SANDBOX_PAYMENTS_ENABLED", env: func() bool { return conf.sandboxPaymentsEnabled }},
+	{Name: FeatureCheckoutReminder, Title: "Напоминание о неоплаченном счёте", Env: "CHECKOUT_REMINDER_ENABLED", env: func() bool { return conf.checkoutReminderEnabled }},
+	{Name: FeatureOfferReminder, Title: "Напоминание об окончании предложения", Env: "OFFER_REMINDER_ENABLED", env: func() bool { return conf.offerReminderEnabled }},
+	{Name: FeaturePrivacySelfService, Title: "Удаление данных клиентом", Env: "PRIVACY_SELF_SERVICE_ENABLED", env: func() bool { return conf.privacySelfServiceEnabled }},
+	{Name: FeatureInlineMode, Title: "Inline режим", Env: "INLINE_MODE_ENABLED", env: func() bool { return conf.inlineModeEnabled }},
+	{Name: FeatureTrialUpsell, Title: "Цепочка после триала", Env: "TRIAL_UPSELL_ENABLED", env: func() bool { return conf.trialUpsellEnabled }},
+	{Name: FeatureFaq, Title: "FAQ", Env: "FAQ_ENABLED", env: func() bool { return conf.faqEnabled }},
+	{Name: FeatureAnalytics, Title: "Продуктовая аналитика", Env: "ANALYTICS_ENABLED", env: func() bool { return conf.analyticsEnabled }},
+	{Name: FeatureSurvey, Title: "Опрос удовлетворённости", Env: "SURVEY_ENABLED", env: func() bool { return conf.surveyEnabled }},
+	{Name: FeatureNotificationFeedback, Title: "Оценка уведомлений 👍/👎", Env: "NOTIFICATION_FEEDBACK_ENABLED", env: func() bool { return conf.notificationFeedbackEnabled }},
+}
+
+var (
+	featureMu        sync.RWMutex
+	featureOverrides = map[string]bool{}
+)
+
+// FeatureFlags возвращает переключаемые фичи в порядке показа в админке
+func FeatureFlags() []FeatureFlag {
+	return featureFlags
+}
+
+// FindFeatureFlag возвращает фичу по имени
+func FindFeatureFlag(name string) (FeatureFlag, bool) {
+	for _, f := range featureFlags {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return FeatureFlag{}, false
+}
+
+// SetFeatureOverrides заменяет все переопределения фич, например после перечитывания из БД
+func SetFeatureOverrides(overrides map[string]bool) {
+	copied := make(map[string]bool, len(overrides))
+	for name, enabled := range overrides {
+		copied[name] = enabled
+	}
+	featureMu.Lock()
+	featureOverrides = copied
+	featureMu.Unlock()
+}
+
+// SetFeatureOverride переопределяет значение фичи
+func SetFeatureOverride(name string, enabled bool) {
+	featureMu.Lock()
+	featureOverrides[name] = enabled
+	featureMu.Unlock()
+}
+
+// ClearFeatureOverride снимает переопределение, фича снова берётся из переменной окружения
+func ClearFeatureOverride(name string) {
+	featureMu.Lock()
+	delete(featureOverrides, name)
+	featureMu.Unlock()
+}
+
+// FeatureState возвращает текущее значение фичи и признак того, что оно переопределено админом
+func FeatureState(f FeatureFlag) (enabled, overridden bool) {
+	featureMu.RLock()
+	enabled, overridden = featureOverrides[f.Name]
+	featureMu.RUnlock()
+	if !overridden {
+		enabled = f.env()
+	}
+	return enabled, overridden
+}
+
+func featureEnabled(name string, envValue bool) bool {
+	featureMu.RLock()
+	defer featureMu.RUnlock()
+	if enabled, ok := featureOverrides[name]; ok {
+		return enabled
+	}
+	return envValue
+}
@@ -0,0 +1,54 @@
+package config
+
+import "testing"
+
+func TestFeatureOverrides(t *testing.T) {
+	saved := conf
+	t.Cleanup(func() {
+		conf = saved
+		SetFeatureOverrides(nil)
+	})
+
+	conf.winbackEnabled = false
+	conf.dailyReportEnabled = true
+
+	if IsWinbackEnabled() || !IsDailyReportEnabled() {
+		t.Fatal("Expected env values without overrides")
+	}
+
+	SetFeatureOverrides(map[string]bool{FeatureWinback: true, FeatureDailyReport: false})
+	if !IsWinbackEnabled() || IsDailyReportEnabled() {
+		t.Error("Expected overrides to take precedence over env")
+	}
+
+	flag, _ := FindFeatureFlag(FeatureWinback)
+	if enabled, overridden := FeatureState(flag); !enabled || !overridden {
+		t.Errorf("Expected winback enabled by override, got enabled=%v overridden=%v", enabled, overridden)
+	}
+
+	ClearFeatureOverride(FeatureWinback)
+	if IsWinbackEnabled() {
+		t.Error("Expected env value after clearing override")
+	}
+}
+
+func TestRecurringOverrideRequiresYookasa(t *testing.T) {
+	saved := conf
+	t.Cleanup(func() {
+		conf = saved
+		SetFeatureOverrides(nil)
+	})
+
+	conf.recurringPaymentsEnabled = false
+	conf.isYookasaEnabled = false
+	SetFeatureOverride(FeatureRecurringPayments, true)
+
+	if IsRecurringPaymentsEnabled() {
+		t.Error("Expected recurring payments to stay disabled without YooKassa")
+	}
+
+	conf.isYookasaEnabled = true
+	if !IsRecurringPaymentsEnabled() {
+		t.Error("Expected recurring payments enabled by override")
+	}
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Встроенные кнопки стартового меню
+const (
+	MenuButtonTrial        = "trial"
+	MenuButtonBuy          = "buy"
+	MenuButtonConnect      = "connect"
+	MenuButtonPromo        = "promo"
+	MenuButtonReferral     = "referral"
+	MenuButtonTeam         = "team"
+	MenuButtonGift         = "gift"
+	MenuButtonServerStatus = "server_status"
+	MenuButtonSupport      = "support"
+	MenuButtonFeedback     = "feedback"
+	MenuButtonChannel      = "channel"
+	MenuButtonTos          = "tos"
+	MenuButtonFaq          = "faq"
+)
+
+// DefaultStartMenuLayout порядок кнопок стартового меню по умолчанию (по одной кнопке в ряд)
+const DefaultStartMenuLayout = "trial,buy,connect,promo,referral,team,gift,server_status,faq,support,feedback,channel,tos"
+
+var builtinMenuButtons = map[string]bool{
+	MenuButtonTrial:        true,
+	MenuButtonBuy:          true,
+	MenuButtonConnect:      true,
+	MenuButtonPromo:        true,
+	MenuButtonReferral:     true,
+	MenuButtonTeam:         true,
+	MenuButtonGift:         true,
+	MenuButtonServerStatus: true,
+	MenuButtonSupport:      true,
+	MenuButtonFeedback:     true,
+	MenuButtonChannel:      true,
+	MenuButtonTos:          true,
+	MenuButtonFaq:          true,
+}
+
+// IsBuiltinMenuButton возвращает true если id — встроенная кнопка стартового меню
+func IsBuiltinMenuButton(id string) bool {
+	return builtinMenuButtons[id]
+}
+
+// MenuButton настройки кнопки меню: подписи по языкам и URL (для пользовательских кнопок)
+type MenuButton struct {
+	ID     string
+	URL    string
+	Labels map[string]string // язык → подпись, "" — подпись по умолчанию
+}
+
+// Label возвращает подпись для языка, подпись по умолчанию или пустую строку
+func (b *MenuButton) Label(lang string) string {
+	if b == nil {
+		return ""
+	}
+	if label, ok := b.Labels[lang]; ok {
+		return label
+	}
+	return b.Labels[""]
+}
+
+// GetStartMenuLayout возвращает ряды кнопок стартового меню (id кнопок)
+func GetStartMenuLayout() [][]string {
+	return conf.startMenuLayout
+}
+
+// GetMenuButton возвращает настройки кнопки меню или nil если кнопка не настраивалась
+func GetMenuButton(id string) *MenuButton {
+	return conf.menuButtons[id]
+}
+
+// Типы медиа над главным меню
+const (
+	StartMediaPhoto = "photo"
+	StartMediaVideo = "video"
+)
+
+// StartMedia фото или видео над главным меню. File — file_id из Telegram или URL
+type StartMedia struct {
+	Type string
+	File string
+}
+
+// GetStartMedia возвращает медиа главного меню для языка (START_MEDIA_<LANG>, иначе START_MEDIA).
+// false — меню отправляется текстом
+func GetStartMedia(lang string) (StartMedia, bool) {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	if media, ok := conf.startMedia[strings.ToLower(lang)]; ok {
+		return media, true
+	}
+	media, ok := conf.startMedia[""]
+	return media, ok
+}
+
+// parseStartMedia парсит START_MEDIA и START_MEDIA_<LANG> в формате <photo|video>:<file_id или URL>.
+// Ключ результата — язык, "" — медиа для остальных языков
+func parseStartMedia() map[string]StartMedia {
+	media := make(map[string]StartMedia)
+	for _, env := range os.Environ() {
+		key, value, _ := strings.Cut(env, "=")
+		rest, ok := strings.CutPrefix(key, "START_MEDIA")
+		if !ok || value == "" {
+			continue
+		}
+		lang := ""
+		if rest != "" {
+			if lang, ok = strings.CutPrefix(rest, "_"); !ok || lang == "" {
+				continue
+			}
+		}
+		mediaType, file, _ := strings.Cut(value, ":")
+		if mediaType != StartMediaPhoto && mediaType != StartMediaVideo || file == "" {
+			addIssue("invalid %s: expected photo:<file_id or URL> or video:<file_id or URL>", key)
+			continue
+		}
+		media[strings.ToLower(lang)] = StartMedia{Type: mediaType, File: file}
+	}
+	return media
+}
+
+// parseMenuButtons парсит настройки кнопок из ENV переменных по паттерну:
+// MENU_BUTTON_<ID>_URL, MENU_BUTTON_<ID>_TEXT, MENU_BUTTON_<ID>_TEXT_<LANG>
+func parseMenuButtons() map[string]*MenuButton {
+	buttons := make(map[string]*MenuButton)
+	get := func(id string) *MenuButton {
+		if b, ok := buttons[id]; ok {
+			return b
+		}
+		b := &MenuButton{ID: id, Labels: make(map[string]string)}
+		buttons[id] = b
+		return b
+	}
+
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, "MENU_BUTTON_") {
+			continue
+		}
+		parts := strings.SplitN(env, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			continue
+		}
+		rest := strings.TrimPrefix(parts[0], "MENU_BUTTON_")
+
+		if strings.HasSuffix(rest, "_URL") {
+			get(strings.ToLower(strings.TrimSuffix(rest, "_URL"))).URL = parts[1]
+			continue
+		}
+
+		idx := strings.LastIndex(rest, "_TEXT")
+		if idx <= 0 {
+			continue
+		}
+		id := strings.ToLower(rest[:idx])
+		switch suffix := rest[idx+len("_TEXT"):]; {
+		case suffix == "":
+			get(id).Labels[""] = parts[1]
+		case strings.HasPrefix(suffix, "_"):
+			get(id).Labels[strings.ToLower(suffix[1:])] = parts[1]
+		}
+	}
+
+	return buttons
+}
+
+// parseStartMenuLayout парсит раскладку меню: ряды через запятую, кнопки в ряду через "+".
+// Неизвестные кнопки без URL пропускаются
+func parseStartMenuLayout(layout string, buttons map[string]*MenuButton) [][]string {
+	var rows [][]string
+	for _, rowStr := range strings.Split(layout, ",") {
+		var row []string
+		for _, id := range strings.Split(rowStr, "+") {
+			id = strings.ToLower(strings.TrimSpace(id))
+			if id == "" {
+				continue
+			}
+			if !IsBuiltinMenuButton(id) && (buttons[id] == nil || buttons[id].URL == "") {
+				slog.Warn("Unknown start menu button, skipping", "id", id)
+				continue
+			}
+			row = append(row, id)
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
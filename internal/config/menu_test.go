@@ -0,0 +1,99 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMenuButtons(t *testing.T) {
+	t.Setenv("MENU_BUTTON_SITE_URL", "https://example.com")
+	t.Setenv("MENU_BUTTON_SITE_TEXT", "Website")
+	t.Setenv("MENU_BUTTON_SITE_TEXT_RU", "Сайт")
+	t.Setenv("MENU_BUTTON_SERVER_STATUS_TEXT_EN", "Status")
+	t.Setenv("MENU_BUTTON_EMPTY_TEXT", "")
+
+	buttons := parseMenuButtons()
+
+	site := buttons["site"]
+	if site == nil || site.URL != "https://example.com" {
+		t.Fatalf("Expected custom button 'site' with URL, got %+v", site)
+	}
+	if site.Label("ru") != "Сайт" || site.Label("en") != "Website" {
+		t.Errorf("Unexpected labels: ru=%q en=%q", site.Label("ru"), site.Label("en"))
+	}
+
+	status := buttons[MenuButtonServerStatus]
+	if status == nil || status.Label("en") != "Status" || status.Label("ru") != "" {
+		t.Errorf("Expected only en label override for server_status, got %+v", status)
+	}
+
+	if _, ok := buttons["empty"]; ok {
+		t.Error("Expected empty values to be ignored")
+	}
+
+	var missing *MenuButton
+	if missing.Label("en") != "" {
+		t.Error("Expected empty label for nil button")
+	}
+}
+
+func TestParseStartMenuLayout(t *testing.T) {
+	buttons := map[string]*MenuButton{
+		"site":    {ID: "site", URL: "https://example.com", Labels: map[string]string{"": "Site"}},
+		"no_link": {ID: "no_link", Labels: map[string]string{"": "No link"}},
+	}
+
+	tests := []struct {
+		name   string
+		layout string
+		want   [][]string
+	}{
+		{
+			name:   "default layout",
+			layout: DefaultStartMenuLayout,
+			want: [][]string{{"trial"}, {"buy"}, {"connect"}, {"promo"}, {"referral"},
+				{"team"}, {"gift"}, {"server_status"}, {"faq"}, {"support"}, {"feedback"}, {"channel"}, {"tos"}},
+		},
+		{
+			name:   "reordered with rows and custom button",
+			layout: " buy + trial , SITE,promo+referral ",
+			want:   [][]string{{"buy", "trial"}, {"site"}, {"promo", "referral"}},
+		},
+		{
+			name:   "unknown buttons and empty rows are skipped",
+			layout: "buy,,unknown,no_link+tos",
+			want:   [][]string{{"buy"}, {"tos"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseStartMenuLayout(tt.layout, buttons)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseStartMenuLayout(%q) = %v, want %v", tt.layout, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStartMedia(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("START_MEDIA", "photo:AgACAgIAAxkBAAIB")
+	t.Setenv("START_MEDIA_EN", "video:https://cdn.example.com/start.mp4")
+	t.Setenv("START_MEDIA_MENU", "")
+	if err := Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if media, ok := GetStartMedia("en"); !ok || media != (StartMedia{Type: StartMediaVideo, File: "https://cdn.example.com/start.mp4"}) {
+		t.Errorf("GetStartMedia(en) = %+v, %v", media, ok)
+	}
+	if media, ok := GetStartMedia("ru"); !ok || media != (StartMedia{Type: StartMediaPhoto, File: "AgACAgIAAxkBAAIB"}) {
+		t.Errorf("GetStartMedia(ru) must fall back to START_MEDIA, got %+v, %v", media, ok)
+	}
+
+	t.Setenv("START_MEDIA_RU", "gif:AgACAgIAAxkBAAIB")
+	if err := Load(); err == nil {
+		t.Error("Expected error for unsupported media type")
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyPaymentFee(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("PAYMENT_FEE_CRYPTO_PERCENT", "5")
+	t.Setenv("PAYMENT_FEE_STARS_PERCENT", "-15")
+	if err := Load(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+
+	tests := []struct {
+		method string
+		price  int
+		want   int
+	}{
+		{"crypto", 199, 209},
+		{"crypto", 0, 0},
+		{"telegram", 150, 128},
+		{"telegram", 1, 1},
+		{"yookasa", 199, 199},
+		{"tribute", 199, 199},
+	}
+	for _, tt := range tests {
+		if got := ApplyPaymentFee(tt.method, tt.price); got != tt.want {
+			t.Errorf("ApplyPaymentFee(%q, %d) = %d, want %d", tt.method, tt.price, got, tt.want)
+		}
+	}
+}
+
+func TestPaymentFeeValidation(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("PAYMENT_FEE_YOOKASA_PERCENT", "-95")
+
+	err := Load()
+	if err == nil || !strings.Contains(err.Error(), "PAYMENT_FEE_YOOKASA_PERCENT must be between -90 and 100") {
+		t.Fatalf("Expected fee range issue, got %v", err)
+	}
+}
+
+func TestPaymentAmount(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("YOOKASA_ENABLED", "true")
+	t.Setenv("YOOKASA_URL", "http://yookasa.test")
+	t.Setenv("YOOKASA_SHOP_ID", "shop")
+	t.Setenv("YOOKASA_SECRET_KEY", "secret")
+	t.Setenv("YOOKASA_EMAIL", "test@example.com")
+	t.Setenv("YOOKASA_MIN_AMOUNT", "50")
+	t.Setenv("PAYMENT_FEE_YOOKASA_PERCENT", "-50")
+	t.Setenv("TELEGRAM_STARS_ENABLED", "true")
+	t.Setenv("STARS_PRICE_12", "12000")
+	if err := Load(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		price      int
+		wantAmount int
+		wantOK     bool
+	}{
+		{"discount applied", "yookasa", 280, 140, true},
+		{"discount rounded up to provider minimum", "yookasa", 60, 50, true},
+		{"price below provider minimum", "yookasa", 40, 40, false},
+		{"zero price", "yookasa", 0, 0, false},
+		{"stars within limits", "telegram", 500, 500, true},
+		{"stars above Telegram maximum", "telegram", 12000, 12000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, ok := PaymentAmount(tt.method, tt.price)
+			if amount != tt.wantAmount || ok != tt.wantOK {
+				t.Errorf("PaymentAmount(%q, %d) = %d, %v, want %d, %v", tt.method, tt.price, amount, ok, tt.wantAmount, tt.wantOK)
+			}
+		})
+	}
+
+	warnings := PaymentAmountWarnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "Telegram Stars") || !strings.Contains(warnings[0], "12 мес: 12000") {
+		t.Errorf("Expected one warning about the 12 month Stars price, got %v", warnings)
+	}
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxPlanDays наибольший срок посуточного плана тарифа
+const maxPlanDays = 365
+
+// Period срок покупки: Months месяцев или Days дней по посуточному плану тарифа. Заполнено одно из полей
+type Period struct {
+	Months int
+	Days   int
+}
+
+// MonthsPeriod срок покупки в месяцах
+func MonthsPeriod(months int) Period {
+	return Period{Months: months}
+}
+
+// DaysPeriod срок покупки в днях
+func DaysPeriod(days int) Period {
+	return Period{Days: days}
+}
+
+// IsDays возвращает true для посуточного плана
+func (p Period) IsDays() bool {
+	return p.Days > 0
+}
+
+// TotalDays длительность периода в днях. Посуточный план не зависит от DAYS_IN_MONTH
+func (p Period) TotalDays() int {
+	if p.IsDays() {
+		return p.Days
+	}
+	return p.Months * DaysInMonth()
+}
+
+// String период в callback data: "3" — месяцы, "7d" — дни
+func (p Period) String() string {
+	if p.IsDays() {
+		return strconv.Itoa(p.Days) + "d"
+	}
+	return strconv.Itoa(p.Months)
+}
+
+// ParsePeriod разбирает период из callback data (см. String)
+func ParsePeriod(s string) (Period, bool) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return Period{}, false
+		}
+		return DaysPeriod(n), true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return Period{}, false
+	}
+	return MonthsPeriod(n), true
+}
+
+// DayPlan посуточный план тарифа: покупка на Days дней, например недельная подписка
+type DayPlan struct {
+	Days       int
+	Price      int // Цена в рублях
+	StarsPrice int // Цена в звёздах, 0 — пересчитывается из рублей по STARS_RATE
+}
+
+// DayPlan возвращает посуточный план тарифа на days дней
+func (t Tariff) DayPlan(days int) (DayPlan, bool) {
+	for _, plan := range t.DayPlans {
+		if plan.Days == days {
+			return plan, true
+		}
+	}
+	return DayPlan{}, false
+}
+
+// IsSupportedPeriod проверяет что период доступен для покупки тарифа: стандартные месяцы
+// или посуточный план тарифа с заданной ценой
+func (t Tariff) IsSupportedPeriod(p Period) bool {
+	if !p.IsDays() {
+		return IsSupportedMonth(p.Months)
+	}
+	plan, ok := t.DayPlan(p.Days)
+	return ok && plan.Price > 0
+}
+
+// PeriodPrice возвращает цену тарифа в рублях за период
+func (t Tariff) PeriodPrice(p Period) int {
+	if !p.IsDays() {
+		return t.Price(p.Months)
+	}
+	plan, _ := t.DayPlan(p.Days)
+	return plan.Price
+}
+
+// PeriodStarsPrice возвращает цену тарифа в звёздах за период
+func (t Tariff) PeriodStarsPrice(p Period) int {
+	if !p.IsDays() {
+		return t.StarsPrice(p.Months)
+	}
+	plan, _ := t.DayPlan(p.Days)
+	if plan.StarsPrice == 0 {
+		return starsPriceFromRubles(plan.Price)
+	}
+	return plan.StarsPrice
+}
+
+// dayPlanEnv цена посуточного плана: TARIFF_<NAME>_PRICE_<N>D и TARIFF_<NAME>_STARS_PRICE_<N>D
+var dayPlanEnv = regexp.MustCompile(`^TARIFF_(.+?)_(STARS_)?PRICE_(\d+)D$`)
+
+// dayPlanTariffName имя тарифа из переменной цены посуточного плана или пустая строка
+func dayPlanTariffName(key string) string {
+	if m := dayPlanEnv.FindStringSubmatch(key); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// parseDayPlans читает посуточные планы тарифа name. Планы без цены в рублях и с неверным сроком пропускаются
+func parseDayPlans(name string, starsRate float64) []DayPlan {
+	var plans []DayPlan
+	for _, env := range os.Environ() {
+		key, value, _ := strings.Cut(env, "=")
+		m := dayPlanEnv.FindStringSubmatch(key)
+		if m == nil || m[1] != name || m[2] != "" {
+			continue
+		}
+		days, err := strconv.Atoi(m[3])
+		if err != nil || days < 1 || days > maxPlanDays {
+			slog.Warn("Tariff day plan has invalid duration, skipping", "name", name, "key", key)
+			continue
+		}
+		if slices.ContainsFunc(plans, func(p DayPlan) bool { return p.Days == days }) {
+			continue
+		}
+		price, err := strconv.Atoi(value)
+		if err != nil || price <= 0 {
+			slog.Warn("Tariff day plan has invalid price, skipping", "name", name, "key", key)
+			continue
+		}
+		plans = append(plans, DayPlan{
+			Days:       days,
+			Price:      price,
+			StarsPrice: envIntDefault("TARIFF_"+name+"_STARS_PRICE_"+m[3]+"D", starsPriceAt(starsRate, price)),
+		})
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].Days < plans[j].Days })
+	return plans
+}
+
+// RecurringPeriodListPrice как RecurringListPrice, но для периода. Посуточный план есть только у тарифа
+func RecurringPeriodListPrice(tariffName string, p Period) int {
+	if !p.IsDays() {
+		return RecurringListPrice(tariffName, p.Months)
+	}
+	tariff := GetTariffByName(tariffName)
+	if tariff == nil || !tariff.IsSupportedPeriod(p) {
+		return 0
+	}
+	amount, ok := PaymentAmount("yookasa", tariff.PeriodPrice(p))
+	if !ok {
+		return 0
+	}
+	return amount
+}
+
+// IsSupportedTariffPeriod проверяет период покупки тарифа tariffName. Без тарифа доступны только месяцы
+func IsSupportedTariffPeriod(tariffName string, p Period) bool {
+	if !p.IsDays() {
+		return IsSupportedMonth(p.Months)
+	}
+	tariff := GetTariffByName(tariffName)
+	return tariff != nil && tariff.IsSupportedPeriod(p)
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParsePeriod(t *testing.T) {
+	valid := map[string]Period{
+		"1":   MonthsPeriod(1),
+		"12":  MonthsPeriod(12),
+		"7d":  DaysPeriod(7),
+		"14d": DaysPeriod(14),
+	}
+	for input, want := range valid {
+		got, ok := ParsePeriod(input)
+		if !ok || got != want {
+			t.Errorf("ParsePeriod(%q) = %+v, %v; want %+v", input, got, ok, want)
+		}
+		if got.String() != input {
+			t.Errorf("Period %+v String() = %q, want %q", got, got.String(), input)
+		}
+	}
+	for _, input := range []string{"", "d", "0", "-1", "0d", "-7d", "7w", "7 d"} {
+		if got, ok := ParsePeriod(input); ok {
+			t.Errorf("ParsePeriod(%q) = %+v, expected rejection", input, got)
+		}
+	}
+}
+
+// TestPeriodTotalDays проверяет что срок посуточного плана не зависит от DAYS_IN_MONTH
+func TestPeriodTotalDays(t *testing.T) {
+	original := conf.daysInMonth
+	defer func() { conf.daysInMonth = original }()
+	conf.daysInMonth = 31
+
+	if got := DaysPeriod(7).TotalDays(); got != 7 {
+		t.Errorf("Expected 7 days for weekly plan, got %d", got)
+	}
+	if got := MonthsPeriod(3).TotalDays(); got != 93 {
+		t.Errorf("Expected 93 days for 3 months, got %d", got)
+	}
+}
+
+func TestParseDayPlans(t *testing.T) {
+	originalEnv := os.Environ()
+	originalRate := conf.starsRate
+	defer func() {
+		conf.starsRate = originalRate
+		os.Clearenv()
+		for _, e := range originalEnv {
+			parts := splitEnv(e)
+			if len(parts) == 2 {
+				os.Setenv(parts[0], parts[1])
+			}
+		}
+	}()
+
+	clearTariffEnv()
+	conf.starsRate = 1.5
+
+	os.Setenv("TARIFF_BASIC_ENABLED", "true")
+	os.Setenv("TARIFF_BASIC_DEVICES", "3")
+	os.Setenv("TARIFF_BASIC_PRICE_1", "150")
+	os.Setenv("TARIFF_BASIC_PRICE_3", "400")
+	os.Setenv("TARIFF_BASIC_PRICE_6", "750")
+	os.Setenv("TARIFF_BASIC_PRICE_12", "1200")
+	os.Setenv("TARIFF_BASIC_PRICE_14D", "90")
+	os.Setenv("TARIFF_BASIC_PRICE_7D", "60")
+	os.Setenv("TARIFF_BASIC_STARS_PRICE_7D", "35")
+	// Неверный срок и цена пропускаются, цена в звёздах без цены в рублях плана не создаёт
+	os.Setenv("TARIFF_BASIC_PRICE_0D", "10")
+	os.Setenv("TARIFF_BASIC_PRICE_400D", "1000")
+	os.Setenv("TARIFF_BASIC_PRICE_3D", "free")
+	os.Setenv("TARIFF_BASIC_STARS_PRICE_5D", "20")
+
+	tariffs := parseTariffs(conf.starsRate)
+	if len(tariffs) != 1 {
+		t.Fatalf("Expected 1 tariff, got %d", len(tariffs))
+	}
+	tariff := tariffs[0]
+	want := []DayPlan{{Days: 7, Price: 60, StarsPrice: 35}, {Days: 14, Price: 90, StarsPrice: 59}}
+	if len(tariff.DayPlans) != len(want) {
+		t.Fatalf("Expected day plans %+v, got %+v", want, tariff.DayPlans)
+	}
+	for i := range want {
+		if tariff.DayPlans[i] != want[i] {
+			t.Errorf("Expected day plan %+v, got %+v", want[i], tariff.DayPlans[i])
+		}
+	}
+
+	week := DaysPeriod(7)
+	if !tariff.IsSupportedPeriod(week) || tariff.PeriodPrice(week) != 60 || tariff.PeriodStarsPrice(week) != 35 {
+		t.Errorf("Unexpected weekly plan: supported %v, price %d, stars %d",
+			tariff.IsSupportedPeriod(week), tariff.PeriodPrice(week), tariff.PeriodStarsPrice(week))
+	}
+	if tariff.IsSupportedPeriod(DaysPeriod(5)) {
+		t.Error("Expected plan without ruble price to be unsupported")
+	}
+	if tariff.PeriodPrice(MonthsPeriod(3)) != 400 {
+		t.Errorf("Expected monthly price 400, got %d", tariff.PeriodPrice(MonthsPeriod(3)))
+	}
+	// Без тарифа посуточных планов нет
+	if IsSupportedTariffPeriod("", week) {
+		t.Error("Expected day period without tariff to be unsupported")
+	}
+}
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/joho/godotenv"
+)
+
+// confMu защищает настройки, которые меняются при перезагрузке конфигурации (см. reloadableSettings)
+var confMu sync.RWMutex
+
+// reloadMu не даёт двум перезагрузкам идти одновременно
+var reloadMu sync.Mutex
+
+var (
+	// envFileValues значения из .env, применённые к окружению процесса
+	envFileValues map[string]string
+	// processEnv переменные, заданные окружением процесса до чтения .env. Они важнее .env и при перезагрузке не меняются
+	processEnv map[string]bool
+)
+
+// loadEnvFile переносит значения из .env в окружение процесса, не перетирая уже заданные переменные
+func loadEnvFile() {
+	if processEnv == nil {
+		processEnv = make(map[string]bool)
+		for _, kv := range os.Environ() {
+			processEnv[strings.SplitN(kv, "=", 2)[0]] = true
+		}
+	}
+	if os.Getenv("DISABLE_ENV_FILE") == "true" {
+		return
+	}
+	values, err := godotenv.Read(".env")
+	if err != nil {
+		log.Println("No .env loaded:", err)
+		return
+	}
+	for key, value := range values {
+		if processEnv[key] {
+			continue
+		}
+		_ = os.Setenv(key, value)
+	}
+	envFileValues = values
+}
+
+// reloadableSetting настройка, которую можно поменять без перезапуска. apply копирует значение из src в dst
+// и сообщает, изменилось ли оно
+type reloadableSetting struct {
+	env   string
+	apply func(dst, src *config) bool
+}
+
+func set[T comparable](dst *T, src T) bool {
+	if *dst == src {
+		return false
+	}
+	*dst = src
+	return true
+}
+
+func setDeep[T any](dst *T, src T) bool {
+	if reflect.DeepEqual(*dst, src) {
+		return false
+	}
+	*dst = src
+	return true
+}
+
+// reloadableSettings ссылки, медиа главного меню, цены и параметры winback. Остальное (токены, БД, webhook, флаги функций)
+// влияет на подключения и набор обработчиков и применяется только после перезапуска
+var reloadableSettings = []reloadableSetting{
+	{"SERVER_STATUS_URL", func(d, s *config) bool { return set(&d.serverStatusURL, s.serverStatusURL) }},
+	{"SERVER_STATUS_PUBLIC", func(d, s *config) bool { return set(&d.serverStatusPublic, s.serverStatusPublic) }},
+	{"SUPPORT_URL", func(d, s *config) bool { return set(&d.supportURL, s.supportURL) }},
+	{"FEEDBACK_URL", func(d, s *config) bool { return set(&d.feedbackURL, s.feedbackURL) }},
+	{"CHANNEL_URL", func(d, s *config) bool { return set(&d.channelURL, s.channelURL) }},
+	{"TOS_URL", func(d, s *config) bool { return set(&d.tosURL, s.tosURL) }},
+	{"START_MEDIA*", func(d, s *config) bool { return setDeep(&d.startMedia, s.startMedia) }},
+	{"MINI_APP_URL", func(d, s *config) bool { return set(&d.miniApp, s.miniApp) }},
+	{"SUBSCRIPTION_PAGE_TEMPLATE", func(d, s *config) bool {
+		return set(&d.subscriptionPageTemplate, s.subscriptionPageTemplate)
+	}},
+	{"PRICE_1", func(d, s *config) bool { return set(&d.price1, s.price1) }},
+	{"PRICE_3", func(d, s *config) bool { return set(&d.price3, s.price3) }},
+	{"PRICE_6", func(d, s *config) bool { return set(&d.price6, s.price6) }},
+	{"PRICE_12", func(d, s *config) bool { return set(&d.price12, s.price12) }},
+	{"STARS_RATE", func(d, s *config) bool { return set(&d.starsRate, s.starsRate) }},
+	{"STARS_PRICE_1", func(d, s *config) bool { return set(&d.starsPrice1, s.starsPrice1) }},
+	{"STARS_PRICE_3", func(d, s *config) bool { return set(&d.starsPrice3, s.starsPrice3) }},
+	{"STARS_PRICE_6", func(d, s *config) bool { return set(&d.starsPrice6, s.starsPrice6) }},
+	{"STARS_PRICE_12", func(d, s *config) bool { return set(&d.starsPrice12, s.starsPrice12) }},
+	{"TARIFF_*", func(d, s *config) bool { return setDeep(&d.tariffs, s.tariffs) }},
+	{"WINBACK_PRICE", func(d, s *config) bool { return set(&d.winbackPrice, s.winbackPrice) }},
+	{"WINBACK_DEVICES", func(d, s *config) bool { return set(&d.winbackDevices, s.winbackDevices) }},
+	{"WINBACK_MONTHS", func(d, s *config) bool { return set(&d.winbackMonths, s.winbackMonths) }},
+	{"WINBACK_VALID_HOURS", func(d, s *config) bool { return set(&d.winbackValidHours, s.winbackValidHours) }},
+	{"WINBACK_PAID_DAYS", func(d, s *config) bool { return setDeep(&d.winbackPaidDays, s.winbackPaidDays) }},
+	{"WINBACK_PAID_DISCOUNT_PERCENT", func(d, s *config) bool {
+		return set(&d.winbackPaidDiscountPercent, s.winbackPaidDiscountPercent)
+	}},
+	{"WINBACK_PAID_VALID_HOURS", func(d, s *config) bool { return set(&d.winbackPaidValidHours, s.winbackPaidValidHours) }},
+}
+
+// isReloadableEnv можно ли применить переменную без перезапуска
+func isReloadableEnv(key string) bool {
+	if strings.HasPrefix(key, "TARIFF_") || strings.HasPrefix(key, "START_MEDIA") {
+		return true
+	}
+	for _, s := range reloadableSettings {
+		if s.env == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ReloadResult итог перезагрузки: применённые настройки и изменённые переменные, которым нужен перезапуск
+type ReloadResult struct {
+	Applied         []string
+	RestartRequired []string
+}
+
+// Reload перечитывает .env и окружение и применяет ссылки, цены и параметры winback без перезапуска.
+// Переменные окружения процесса важнее .env и не меняются. Если новая конфигурация не проходит проверку,
+// ничего не применяется и возвращается *ValidationError
+func Reload() (*ReloadResult, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	previous := make(map[string]*string)
+	changedEnv := make(map[string]bool)
+	var fileValues map[string]string
+	if os.Getenv("DISABLE_ENV_FILE") != "true" {
+		values, err := godotenv.Read(".env")
+		if err != nil {
+			log.Println("No .env loaded:", err)
+		} else {
+			remember := func(key string) {
+				if _, ok := previous[key]; ok {
+					return
+				}
+				if v, ok := os.LookupEnv(key); ok {
+					previous[key] = &v
+				} else {
+					previous[key] = nil
+				}
+			}
+			for key, value := range values {
+				if processEnv[key] || os.Getenv(key) == value {
+					continue
+				}
+				remember(key)
+				_ = os.Setenv(key, value)
+				changedEnv[key] = true
+			}
+			for key := range envFileValues {
+				if _, ok := values[key]; ok || processEnv[key] {
+					continue
+				}
+				remember(key)
+				_ = os.Unsetenv(key)
+				changedEnv[key] = true
+			}
+			fileValues = values
+		}
+	}
+	restoreEnv := func() {
+		for key, v := range previous {
+			if v == nil {
+				_ = os.Unsetenv(key)
+			} else {
+				_ = os.Setenv(key, *v)
+			}
+		}
+	}
+
+	var fresh config
+	issues = nil
+	load(&fresh)
+	checkConsistency(&fresh)
+	if len(issues) > 0 {
+		restoreEnv()
+		return nil, &ValidationError{Issues: issues}
+	}
+	if fileValues != nil {
+		envFileValues = fileValues
+	}
+
+	result := &ReloadResult{}
+	confMu.Lock()
+	for _, s := range reloadableSettings {
+		if s.apply(&conf, &fresh) {
+			result.Applied = append(result.Applied, s.env)
+		}
+	}
+	conf.paymentAmountWarnings = fresh.paymentAmountWarnings
+	confMu.Unlock()
+
+	for key := range changedEnv {
+		if !isReloadableEnv(key) {
+			result.RestartRequired = append(result.RestartRequired, key)
+		}
+	}
+	sort.Strings(result.RestartRequired)
+	return result, nil
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestReloadAppliesWhitelistedSettings(t *testing.T) {
+	setRequiredEnv(t)
+	if err := Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	t.Setenv("PRICE_1", "150")
+	t.Setenv("SUPPORT_URL", "https://t.me/support")
+	result, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !slices.Contains(result.Applied, "PRICE_1") || !slices.Contains(result.Applied, "SUPPORT_URL") {
+		t.Errorf("Applied = %v, want PRICE_1 and SUPPORT_URL", result.Applied)
+	}
+	if Price1() != 150 {
+		t.Errorf("Price1() = %d, want 150", Price1())
+	}
+	if SupportURL() != "https://t.me/support" {
+		t.Errorf("SupportURL() = %q", SupportURL())
+	}
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	setRequiredEnv(t)
+	if err := Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	t.Setenv("PRICE_1", "abc")
+	_, err := Reload()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected *ValidationError, got %v", err)
+	}
+	if Price1() != 100 {
+		t.Errorf("Price1() = %d, want previous value 100", Price1())
+	}
+}
+
+func TestReloadEnvFile(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("DISABLE_ENV_FILE", "false")
+	t.Chdir(t.TempDir())
+	savedProcessEnv, savedFileValues := processEnv, envFileValues
+	processEnv, envFileValues = nil, nil
+	t.Cleanup(func() {
+		processEnv, envFileValues = savedProcessEnv, savedFileValues
+		_ = os.Unsetenv("CHANNEL_URL")
+		_ = os.Unsetenv("WEBHOOK_SECRET_TOKEN")
+	})
+
+	if err := os.WriteFile(".env", []byte("CHANNEL_URL=https://t.me/old\nPRICE_1=500\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if ChannelURL() != "https://t.me/old" {
+		t.Fatalf("ChannelURL() = %q", ChannelURL())
+	}
+	if Price1() != 100 {
+		t.Fatalf("Price1() = %d, process env must take precedence over .env", Price1())
+	}
+
+	if err := os.WriteFile(".env", []byte("CHANNEL_URL=https://t.me/new\nPRICE_1=500\nWEBHOOK_SECRET_TOKEN=secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	result, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if !slices.Equal(result.Applied, []string{"CHANNEL_URL"}) {
+		t.Errorf("Applied = %v, want [CHANNEL_URL]", result.Applied)
+	}
+	if !slices.Equal(result.RestartRequired, []string{"WEBHOOK_SECRET_TOKEN"}) {
+		t.Errorf("RestartRequired = %v, want [WEBHOOK_SECRET_TOKEN]", result.RestartRequired)
+	}
+	if ChannelURL() != "https://t.me/new" {
+		t.Errorf("ChannelURL() = %q", ChannelURL())
+	}
+	if Price1() != 100 {
+		t.Errorf("Price1() = %d, process env must take precedence over .env", Price1())
+	}
+}
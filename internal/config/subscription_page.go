@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// SubscriptionPageData переменные шаблона ссылки на страницу подписки
+type SubscriptionPageData struct {
+	Link   string // Ссылка из Remnawave как есть
+	Host   string // Домен ссылки из Remnawave
+	Path   string // Путь ссылки из Remnawave, например /sub/AbCd1234
+	Token  string // Последний сегмент пути: короткий идентификатор подписки
+	Lang   string // Язык клиента: ru, en и т.п.
+	Tariff string // Имя тарифа клиента, пусто без тарифа
+}
+
+// subscriptionPageFuncs функции шаблона в дополнение к встроенным (urlquery и др.)
+var subscriptionPageFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+}
+
+// SubscriptionPageURL возвращает ссылку на подписку для показа клиенту: ссылку из Remnawave, переписанную
+// по шаблону тарифа (TARIFF_<NAME>_SUBSCRIPTION_PAGE_TEMPLATE) или общему SUBSCRIPTION_PAGE_TEMPLATE.
+// Без шаблона, при ошибке или пустом результате возвращается исходная ссылка
+func SubscriptionPageURL(link, tariffName, lang string) string {
+	if link == "" {
+		return link
+	}
+	tmpl := subscriptionPageTemplate(tariffName)
+	if tmpl == "" {
+		return link
+	}
+	result, err := renderSubscriptionPage(tmpl, newSubscriptionPageData(link, tariffName, lang))
+	if err != nil {
+		slog.Warn("Error rendering subscription page template, using panel link", "tariff", tariffName, "error", err)
+		return link
+	}
+	if result == "" {
+		return link
+	}
+	return result
+}
+
+// SubscriptionPageUsesTariff возвращает true если ссылка зависит от тарифа клиента: задан шаблон
+// хотя бы для одного тарифа или общий шаблон использует .Tariff. Без этого тариф клиента можно не искать
+func SubscriptionPageUsesTariff() bool {
+	if strings.Contains(subscriptionPageDefaultTemplate(), ".Tariff") {
+		return true
+	}
+	for _, tariff := range GetTariffs() {
+		if tariff.SubscriptionPageTemplate != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func subscriptionPageDefaultTemplate() string {
+	confMu.RLock()
+	defer confMu.RUnlock()
+	return conf.subscriptionPageTemplate
+}
+
+func subscriptionPageTemplate(tariffName string) string {
+	if tariffName != "" {
+		if tariff := GetTariffByName(tariffName); tariff != nil && tariff.SubscriptionPageTemplate != "" {
+			return tariff.SubscriptionPageTemplate
+		}
+	}
+	return subscriptionPageDefaultTemplate()
+}
+
+func newSubscriptionPageData(link, tariffName, lang string) SubscriptionPageData {
+	data := SubscriptionPageData{Link: link, Lang: subscriptionPageLang(lang), Tariff: tariffName}
+	if u, err := url.Parse(link); err == nil {
+		data.Host = u.Host
+		data.Path = u.Path
+		if token := path.Base(strings.TrimSuffix(u.Path, "/")); token != "." && token != "/" {
+			data.Token = token
+		}
+	}
+	return data
+}
+
+// subscriptionPageLang язык без региона: ru-RU → ru. Пустой — DEFAULT_LANGUAGE
+func subscriptionPageLang(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	if lang == "" {
+		return DefaultLanguage()
+	}
+	return lang
+}
+
+// renderSubscriptionPage подставляет data в шаблон. Результат должен быть http(s) ссылкой или пустым
+func renderSubscriptionPage(tmpl string, data SubscriptionPageData) (string, error) {
+	t, err := template.New("subscription_page").Funcs(subscriptionPageFuncs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	result := strings.TrimSpace(sb.String())
+	if result == "" {
+		return "", nil
+	}
+	if u, err := url.Parse(result); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("template result %q is not an http(s) URL", result)
+	}
+	return result, nil
+}
+
+// validateSubscriptionPageTemplate проверяет шаблон на примере ссылки из Remnawave
+func validateSubscriptionPageTemplate(name, tmpl string) {
+	if tmpl == "" {
+		return
+	}
+	sample := newSubscriptionPageData("https://panel.example.com/sub/AbCd1234", "PRO", "ru")
+	if _, err := renderSubscriptionPage(tmpl, sample); err != nil {
+		addIssue("%s is invalid: %v", name, err)
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import "testing"
+
+func TestSubscriptionPageURL(t *testing.T) {
+	originalTemplate, originalTariffs, originalLang := conf.subscriptionPageTemplate, conf.tariffs, conf.defaultLanguage
+	defer func() {
+		conf.subscriptionPageTemplate, conf.tariffs, conf.defaultLanguage = originalTemplate, originalTariffs, originalLang
+	}()
+	conf.defaultLanguage = "ru"
+	conf.tariffs = []Tariff{
+		{Name: "START"},
+		{Name: "PRO", SubscriptionPageTemplate: "https://pro.example.com/{{lower .Tariff}}/{{.Token}}"},
+	}
+	link := "https://panel.example.com/api/sub/AbCd1234"
+
+	conf.subscriptionPageTemplate = ""
+	if got := SubscriptionPageURL(link, "START", "en"); got != link {
+		t.Errorf("Expected panel link without template, got %q", got)
+	}
+	if !SubscriptionPageUsesTariff() {
+		t.Error("Expected tariff lookup when a tariff has its own template")
+	}
+
+	conf.subscriptionPageTemplate = "https://sub.example.com/{{.Token}}?lang={{.Lang}}"
+	cases := []struct {
+		tariff, lang, want string
+	}{
+		{"START", "en-US", "https://sub.example.com/AbCd1234?lang=en"},
+		{"", "", "https://sub.example.com/AbCd1234?lang=ru"},
+		{"PRO", "ru", "https://pro.example.com/pro/AbCd1234"},
+		{"UNKNOWN", "de", "https://sub.example.com/AbCd1234?lang=de"},
+	}
+	for _, c := range cases {
+		if got := SubscriptionPageURL(link, c.tariff, c.lang); got != c.want {
+			t.Errorf("SubscriptionPageURL(tariff %q, lang %q) = %q, want %q", c.tariff, c.lang, got, c.want)
+		}
+	}
+	if got := SubscriptionPageURL("", "START", "ru"); got != "" {
+		t.Errorf("Expected no link for customer without subscription, got %q", got)
+	}
+
+	// Шаблон, дающий не ссылку, не ломает показ: остаётся ссылка из панели
+	conf.subscriptionPageTemplate = "sub.example.com/{{.Token}}"
+	if got := SubscriptionPageURL(link, "START", "ru"); got != link {
+		t.Errorf("Expected panel link for invalid template result, got %q", got)
+	}
+	// Пустой результат — ссылка из панели, например шаблон только для части языков
+	conf.subscriptionPageTemplate = `{{if eq .Lang "en"}}https://en.example.com{{.Path}}{{end}}`
+	if got := SubscriptionPageURL(link, "START", "ru"); got != link {
+		t.Errorf("Expected panel link for empty template result, got %q", got)
+	}
+	if got := SubscriptionPageURL(link, "START", "en"); got != "https://en.example.com/api/sub/AbCd1234" {
+		t.Errorf("Unexpected link for en: %q", got)
+	}
+}
+
+func TestValidateSubscriptionPageTemplate(t *testing.T) {
+	valid := []string{
+		"",
+		"https://sub.example.com/{{.Token}}",
+		"{{.Link}}?lang={{urlquery .Lang}}",
+		"https://{{.Host}}{{.Path}}",
+	}
+	for _, tmpl := range valid {
+		issues = nil
+		validateSubscriptionPageTemplate("SUBSCRIPTION_PAGE_TEMPLATE", tmpl)
+		if len(issues) != 0 {
+			t.Errorf("Template %q: unexpected issues %v", tmpl, issues)
+		}
+	}
+
+	invalid := []string{
+		"https://sub.example.com/{{.Token",
+		"https://sub.example.com/{{.Unknown}}",
+		"sub.example.com/{{.Token}}",
+	}
+	for _, tmpl := range invalid {
+		issues = nil
+		validateSubscriptionPageTemplate("SUBSCRIPTION_PAGE_TEMPLATE", tmpl)
+		if len(issues) != 1 {
+			t.Errorf("Template %q: expected 1 issue, got %v", tmpl, issues)
+		}
+	}
+	issues = nil
+}
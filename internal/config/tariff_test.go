@@ -70,7 +70,7 @@ func TestParseTariffsProperty(t *testing.T) {
 		}
 
 		// Парсим тарифы
-		tariffs := parseTariffs()
+		tariffs := parseTariffs(conf.starsRate)
 
 		// Проверяем количество
 		if len(tariffs) != expectedCount {
@@ -139,7 +139,7 @@ func TestParseTariffsDisabledNotIncluded(t *testing.T) {
 	os.Setenv("TARIFF_TEST_PRICE_6", "450")
 	os.Setenv("TARIFF_TEST_PRICE_12", "800")
 
-	tariffs := parseTariffs()
+	tariffs := parseTariffs(conf.starsRate)
 	if len(tariffs) != 0 {
 		t.Errorf("Expected 0 tariffs for disabled tariff, got %d", len(tariffs))
 	}
@@ -168,7 +168,7 @@ func TestParseTariffsMissingPricesSkipped(t *testing.T) {
 	os.Setenv("TARIFF_INCOMPLETE_PRICE_6", "450")
 	os.Setenv("TARIFF_INCOMPLETE_PRICE_12", "800")
 
-	tariffs := parseTariffs()
+	tariffs := parseTariffs(conf.starsRate)
 	if len(tariffs) != 0 {
 		t.Errorf("Expected 0 tariffs for incomplete tariff, got %d", len(tariffs))
 	}
@@ -197,7 +197,7 @@ func TestStarsPricesDefaultToRegularPrices(t *testing.T) {
 	os.Setenv("TARIFF_BASIC_PRICE_12", "799")
 	// Не устанавливаем STARS_PRICE_*
 
-	tariffs := parseTariffs()
+	tariffs := parseTariffs(conf.starsRate)
 	if len(tariffs) != 1 {
 		t.Fatalf("Expected 1 tariff, got %d", len(tariffs))
 	}
@@ -209,6 +209,72 @@ func TestStarsPricesDefaultToRegularPrices(t *testing.T) {
 	}
 }
 
+// TestStarsPricesFromRate проверяет пересчёт цен в звёзды по STARS_RATE с приоритетом явных цен
+func TestStarsPricesFromRate(t *testing.T) {
+	originalEnv := os.Environ()
+	originalRate := conf.starsRate
+	defer func() {
+		conf.starsRate = originalRate
+		os.Clearenv()
+		for _, e := range originalEnv {
+			parts := splitEnv(e)
+			if len(parts) == 2 {
+				os.Setenv(parts[0], parts[1])
+			}
+		}
+	}()
+
+	clearTariffEnv()
+	conf.starsRate = 1.5
+
+	os.Setenv("TARIFF_BASIC_ENABLED", "true")
+	os.Setenv("TARIFF_BASIC_DEVICES", "3")
+	os.Setenv("TARIFF_BASIC_PRICE_1", "150")
+	os.Setenv("TARIFF_BASIC_PRICE_3", "400")
+	os.Setenv("TARIFF_BASIC_PRICE_6", "750")
+	os.Setenv("TARIFF_BASIC_PRICE_12", "1200")
+	os.Setenv("TARIFF_BASIC_STARS_PRICE_12", "777")
+
+	tariffs := parseTariffs(conf.starsRate)
+	if len(tariffs) != 1 {
+		t.Fatalf("Expected 1 tariff, got %d", len(tariffs))
+	}
+
+	tariff := tariffs[0]
+	if tariff.StarsPrice1 != 99 || tariff.StarsPrice3 != 299 || tariff.StarsPrice6 != 499 {
+		t.Errorf("Unexpected converted stars prices: %d %d %d", tariff.StarsPrice1, tariff.StarsPrice3, tariff.StarsPrice6)
+	}
+	if tariff.StarsPrice12 != 777 {
+		t.Errorf("Explicit stars price should override rate, got %d", tariff.StarsPrice12)
+	}
+
+	// Тариф без заданных цен в звёздах пересчитывает их на лету
+	if got := (Tariff{Price1: 300}).StarsPrice(1); got != 199 {
+		t.Errorf("Expected StarsPrice to convert ruble price, got %d", got)
+	}
+}
+
+func TestRoundStarsPrice(t *testing.T) {
+	tests := []struct {
+		stars float64
+		want  int
+	}{
+		{0.4, 1},
+		{7.2, 7},
+		{54, 49},
+		{56, 59},
+		{97, 99},
+		{133.3, 99},
+		{166.7, 199},
+		{1049, 999},
+	}
+	for _, tt := range tests {
+		if got := roundStarsPrice(tt.stars); got != tt.want {
+			t.Errorf("roundStarsPrice(%v) = %d, want %d", tt.stars, got, tt.want)
+		}
+	}
+}
+
 // **Feature: tariff-system, Property 5: Tariff Button Text Contains Required Info**
 // **Validates: Requirements 2.2**
 // *For any* tariff, the generated button text SHALL contain the tariff name and device count.
@@ -299,6 +365,20 @@ func TestFormatButtonTextExamples(t *testing.T) {
 	}
 }
 
+func TestIsSupportedMonth(t *testing.T) {
+	for _, month := range []int{1, 3, 6, 12} {
+		if !IsSupportedMonth(month) {
+			t.Errorf("IsSupportedMonth(%d) = false, want true", month)
+		}
+	}
+	// Для неподдерживаемых периодов Price() вернул бы цену за 1 месяц
+	for _, month := range []int{-1, 0, 2, 7, 24} {
+		if IsSupportedMonth(month) {
+			t.Errorf("IsSupportedMonth(%d) = true, want false", month)
+		}
+	}
+}
+
 func clearTariffEnv() {
 	for _, e := range os.Environ() {
 		parts := splitEnv(e)
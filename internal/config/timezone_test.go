@@ -0,0 +1,57 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadTimezoneAndNotifyLocalHours(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("TIMEZONE", "Asia/Yekaterinburg")
+	t.Setenv("NOTIFY_LOCAL_HOURS", "9-21")
+
+	if err := Load(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+	if Timezone().String() != "Asia/Yekaterinburg" {
+		t.Errorf("Expected Asia/Yekaterinburg timezone, got %s", Timezone())
+	}
+	if from, to, enabled := NotifyLocalHours(); !enabled || from != 9 || to != 21 {
+		t.Errorf("NotifyLocalHours() = %d, %d, %v, want 9, 21, true", from, to, enabled)
+	}
+}
+
+func TestLoadTimezoneDefaults(t *testing.T) {
+	setRequiredEnv(t)
+
+	if err := Load(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+	if Timezone() != time.Local {
+		t.Errorf("Expected server timezone by default, got %s", Timezone())
+	}
+	if _, _, enabled := NotifyLocalHours(); enabled {
+		t.Error("Expected notification hours to be unrestricted by default")
+	}
+}
+
+func TestLoadInvalidTimezoneAndNotifyLocalHours(t *testing.T) {
+	for _, hours := range []string{"21-9", "9", "9-25", "a-b"} {
+		t.Run(hours, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("TIMEZONE", "Mars/Olympus")
+			t.Setenv("NOTIFY_LOCAL_HOURS", hours)
+
+			err := Load()
+			if err == nil {
+				t.Fatal("Expected validation error")
+			}
+			for _, want := range []string{"TIMEZONE", "NOTIFY_LOCAL_HOURS"} {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected issue about %s, got %v", want, err)
+				}
+			}
+		})
+	}
+}
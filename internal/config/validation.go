@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// issues проблемы конфигурации, найденные при загрузке. Копятся, чтобы оператор увидел их все разом,
+// а не исправлял переменные по одной после каждого падения
+var issues []string
+
+func addIssue(format string, args ...interface{}) {
+	issues = append(issues, fmt.Sprintf(format, args...))
+}
+
+// ValidationError все проблемы конфигурации, найденные при загрузке
+type ValidationError struct {
+	Issues []string
+}
+
+func (e *ValidationError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("invalid configuration, %d problem(s) found:", len(e.Issues)))
+	for _, issue := range e.Issues {
+		sb.WriteString("\n  - " + issue)
+	}
+	return sb.String()
+}
+
+// Load читает конфигурацию из окружения и проверяет её целиком.
+// Возвращает *ValidationError со всеми найденными проблемами
+func Load() error {
+	issues = nil
+	loadEnvFile()
+	load(&conf)
+	checkConsistency(&conf)
+	if len(issues) > 0 {
+		return &ValidationError{Issues: issues}
+	}
+	return nil
+}
+
+// InitConfig загружает конфигурацию. При ошибках печатает сводный отчёт и завершает процесс
+func InitConfig() {
+	if err := Load(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// checkConsistency проверяет сочетания настроек, которые по отдельности корректны,
+// но вместе не работают
+func checkConsistency(c *config) {
+	if c.recurringPaymentsEnabled && !c.isYookasaEnabled {
+		addIssue("RECURRING_PAYMENTS_ENABLED=true requires YOOKASA_ENABLED=true: recurring charges work only through YooKassa")
+	}
+	if c.yookasaWebhookPath != "" && !c.isYookasaEnabled {
+		addIssue("YOOKASA_WEBHOOK_PATH is set but YOOKASA_ENABLED is not true")
+	}
+	if (c.yookasaTestShopId == "") != (c.yookasaTestSecretKey == "") {
+		addIssue("YOOKASA_TEST_SHOP_ID and YOOKASA_TEST_SECRET_KEY must be set together")
+	}
+	if c.trialPhoneVerificationEnabled && c.trialPhoneHashSecret == "" {
+		addIssue("TRIAL_PHONE_VERIFICATION_ENABLED=true requires TRIAL_PHONE_HASH_SECRET: phone numbers are stored only as HMAC")
+	}
+	if c.teamPlansEnabled && c.teamSeatPrice == 0 {
+		addIssue("TEAM_PLANS_ENABLED=true requires TEAM_SEAT_PRICE: price of one seat per month in rubles")
+	}
+	enabledMethods := map[string]bool{
+		"crypto":   c.isCryptoEnabled,
+		"yookasa":  c.isYookasaEnabled,
+		"telegram": c.isTelegramStarsEnabled,
+		"tribute":  c.tributeWebhookUrl != "",
+	}
+	for _, tariff := range c.tariffs {
+		if len(tariff.PaymentMethods) > 0 && !slices.ContainsFunc(tariff.PaymentMethods, func(m string) bool { return enabledMethods[m] }) {
+			addIssue("TARIFF_%s_PAYMENT_METHODS (%s) lists no enabled payment method: the tariff cannot be bought",
+				tariff.Name, strings.Join(tariff.PaymentMethods, ","))
+		}
+	}
+	if c.giftsEnabled && !c.isTelegramStarsEnabled {
+		addIssue("GIFTS_ENABLED=true requires TELEGRAM_STARS_ENABLED=true: gifts are paid with Telegram Stars")
+	}
+	if c.receiptsEnabled && c.receiptOperator.Name == "" {
+		addIssue("RECEIPTS_ENABLED=true requires RECEIPT_OPERATOR_NAME: seller name printed on receipts")
+	}
+	if c.checkoutReminderEnabled && c.invoiceTTLMinutes > 0 && c.checkoutReminderDelayMinutes >= c.invoiceTTLMinutes {
+		addIssue("CHECKOUT_REMINDER_DELAY_MINUTES (%d) must be less than INVOICE_TTL_MINUTES (%d): the invoice expires before the reminder is sent",
+			c.checkoutReminderDelayMinutes, c.invoiceTTLMinutes)
+	}
+	if c.eventsWebhookURL != "" && c.eventsWebhookSecret == "" {
+		addIssue("EVENTS_WEBHOOK_URL is set but EVENTS_WEBHOOK_SECRET is empty: outgoing events must be signed")
+	}
+	if c.webhookEnabled && c.webhookURL != "" && !strings.HasPrefix(c.webhookURL, "https://") {
+		addIssue("WEBHOOK_URL must start with https:// (Telegram accepts only HTTPS webhooks), got %q", c.webhookURL)
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func setRequiredEnv(t *testing.T) {
+	t.Helper()
+	saved := conf
+	t.Cleanup(func() { conf = saved })
+
+	t.Setenv("DISABLE_ENV_FILE", "true")
+	for key, value := range map[string]string{
+		"ADMIN_TELEGRAM_ID":   "123",
+		"TELEGRAM_TOKEN":      "token",
+		"TRIAL_TRAFFIC_LIMIT": "10",
+		"TRIAL_DAYS":          "3",
+		"PRICE_1":             "100",
+		"PRICE_3":             "280",
+		"PRICE_6":             "540",
+		"PRICE_12":            "1000",
+		"REMNAWAVE_URL":       "http://remnawave",
+		"REMNAWAVE_TOKEN":     "token",
+		"DATABASE_URL":        "postgres://localhost/db",
+		"TRAFFIC_LIMIT":       "100",
+		"REFERRAL_DAYS":       "7",
+	} {
+		t.Setenv(key, value)
+	}
+}
+
+func TestLoadValidConfig(t *testing.T) {
+	setRequiredEnv(t)
+
+	if err := Load(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+}
+
+func TestLoadCollectsAllIssues(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("TELEGRAM_TOKEN", "")
+	t.Setenv("PRICE_3", "abc")
+	t.Setenv("EXTERNAL_SQUAD_UUID", "not-a-uuid")
+	t.Setenv("DAILY_REPORT_HOUR", "25")
+	t.Setenv("CRON_WEBHOOK_RETRY", "every minute")
+	t.Setenv("RECURRING_PAYMENTS_ENABLED", "true")
+	t.Setenv("YOOKASA_ENABLED", "false")
+	t.Setenv("REMNAWAVE_TIMEOUT_SECONDS", "20")
+	t.Setenv("REMNAWAVE_TIMEOUT_BUDGET_SECONDS", "15")
+	t.Setenv("TRIAL_PHONE_VERIFICATION_ENABLED", "true")
+	t.Setenv("TEAM_PLANS_ENABLED", "true")
+	t.Setenv("TEAM_SEAT_OPTIONS", "5,1")
+	t.Setenv("GIFTS_ENABLED", "true")
+	t.Setenv("TELEGRAM_STARS_ENABLED", "false")
+	t.Setenv("WINBACK_PAID_DAYS", "7,0")
+	t.Setenv("RECEIPTS_ENABLED", "true")
+	t.Setenv("CHECKOUT_REMINDER_ENABLED", "true")
+	t.Setenv("CHECKOUT_REMINDER_DELAY_MINUTES", "90")
+	t.Setenv("EVENTS_WEBHOOK_URL", "https://crm.example.com/hooks")
+	t.Setenv("EVENTS_TYPES", "payment.succeeded,user.deleted")
+
+	err := Load()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+
+	for _, want := range []string{
+		"TELEGRAM_TOKEN is not set",
+		`PRICE_3 must be an integer, got "abc"`,
+		"invalid EXTERNAL_SQUAD_UUID format",
+		"DAILY_REPORT_HOUR must be between 0 and 23",
+		"CRON_WEBHOOK_RETRY is not a valid cron schedule",
+		"RECURRING_PAYMENTS_ENABLED=true requires YOOKASA_ENABLED=true",
+		"REMNAWAVE_TIMEOUT_BUDGET_SECONDS must be at least REMNAWAVE_TIMEOUT_SECONDS (20)",
+		"TRIAL_PHONE_VERIFICATION_ENABLED=true requires TRIAL_PHONE_HASH_SECRET",
+		`invalid seat count "1" in TEAM_SEAT_OPTIONS`,
+		"TEAM_PLANS_ENABLED=true requires TEAM_SEAT_PRICE",
+		"GIFTS_ENABLED=true requires TELEGRAM_STARS_ENABLED=true",
+		`invalid day count "0" in WINBACK_PAID_DAYS`,
+		"RECEIPTS_ENABLED=true requires RECEIPT_OPERATOR_NAME",
+		"CHECKOUT_REMINDER_DELAY_MINUTES (90) must be less than INVOICE_TTL_MINUTES (60)",
+		`unknown event type "user.deleted" in EVENTS_TYPES`,
+		"EVENTS_WEBHOOK_URL is set but EVENTS_WEBHOOK_SECRET is empty",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected report to contain %q, got:\n%s", want, err)
+		}
+	}
+	if len(validationErr.Issues) != 16 {
+		t.Errorf("Expected 16 issues, got %d:\n%s", len(validationErr.Issues), err)
+	}
+}
+
+func setTariffEnv(t *testing.T, name, paymentMethods string) {
+	t.Helper()
+	for suffix, value := range map[string]string{
+		"ENABLED": "true", "DEVICES": "3", "PRICE_1": "100", "PRICE_3": "280", "PRICE_6": "540", "PRICE_12": "1000",
+		"PAYMENT_METHODS": paymentMethods,
+	} {
+		t.Setenv("TARIFF_"+name+"_"+suffix, value)
+	}
+}
+
+func TestLoadTariffPaymentMethods(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("YOOKASA_ENABLED", "true")
+	t.Setenv("YOOKASA_URL", "https://api.yookassa.ru/v3")
+	t.Setenv("YOOKASA_SHOP_ID", "shop")
+	t.Setenv("YOOKASA_SECRET_KEY", "secret")
+	t.Setenv("YOOKASA_EMAIL", "shop@example.com")
+	setTariffEnv(t, "CARDONLY", " Card, yookasa ")
+
+	if err := Load(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+	tariff := GetTariffByName("CARDONLY")
+	if tariff == nil || len(tariff.PaymentMethods) != 1 || tariff.PaymentMethods[0] != "yookasa" {
+		t.Fatalf("Expected card alias to resolve to a single yookasa method, got %+v", tariff)
+	}
+	if !TariffAllowsPaymentMethod("CARDONLY", "yookasa") || TariffAllowsPaymentMethod("CARDONLY", "telegram") || TariffAllowsPaymentMethod("CARDONLY", "crypto") {
+		t.Error("Expected the tariff to allow only card payments")
+	}
+	if !TariffAllowsPaymentMethod("", "crypto") {
+		t.Error("Expected legacy pricing to allow every payment method")
+	}
+}
+
+func TestLoadRejectsInvalidTariffPaymentMethods(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("YOOKASA_ENABLED", "false")
+	t.Setenv("TELEGRAM_STARS_ENABLED", "false")
+	setTariffEnv(t, "CARDONLY", "card")
+	setTariffEnv(t, "TYPO", "stars,bitcoin")
+
+	err := Load()
+	for _, want := range []string{
+		"TARIFF_CARDONLY_PAYMENT_METHODS (yookasa) lists no enabled payment method",
+		`TARIFF_TYPO_PAYMENT_METHODS: unknown payment method "bitcoin"`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected report to contain %q, got: %v", want, err)
+		}
+	}
+}
@@ -104,3 +104,40 @@ func TestWinbackConfigDefaults(t *testing.T) {
 		t.Error("Default WINBACK_VALID_HOURS should be 48")
 	}
 }
+
+func TestParseLanguageFallbacks(t *testing.T) {
+	got := parseLanguageFallbacks(" uk:ru, BE:ru ,,pt-br:pt")
+	want := map[string]string{"uk": "ru", "be": "ru", "pt-br": "pt"}
+	if len(got) != len(want) {
+		t.Fatalf("parseLanguageFallbacks() = %v, want %v", got, want)
+	}
+	for lang, fallback := range want {
+		if got[lang] != fallback {
+			t.Errorf("parseLanguageFallbacks()[%q] = %q, want %q", lang, got[lang], fallback)
+		}
+	}
+}
+
+func TestParseWinbackPaidDays(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []int
+	}{
+		{"", []int{7, 30}},
+		{"30, 7", []int{7, 30}},
+		{"14,14,3", []int{3, 14}},
+	}
+	for _, c := range cases {
+		got := parseWinbackPaidDays(c.in)
+		if len(got) != len(c.want) {
+			t.Errorf("parseWinbackPaidDays(%q) = %v, want %v", c.in, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("parseWinbackPaidDays(%q) = %v, want %v", c.in, got, c.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type FunnelEvent string
+
+// Шаги воронки продаж в порядке прохождения
+const (
+	FunnelEventStart           FunnelEvent = "start"
+	FunnelEventViewedPrices    FunnelEvent = "viewed_prices"
+	FunnelEventSelectedPayment FunnelEvent = "selected_payment"
+	FunnelEventPaid            FunnelEvent = "paid"
+)
+
+// FunnelEvents шаги воронки в порядке прохождения
+var FunnelEvents = []FunnelEvent{FunnelEventStart, FunnelEventViewedPrices, FunnelEventSelectedPayment, FunnelEventPaid}
+
+type ActivityRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewActivityRepository(pool *pgxpool.Pool) *ActivityRepository {
+	return &ActivityRepository{pool: pool}
+}
+
+// UpdateLastActive обновляет время последнего взаимодействия пользователя с ботом
+func (r *ActivityRepository) UpdateLastActive(ctx context.Context, telegramID int64, at time.Time) error {
+	query := sq.Update("customer").
+		Set("last_active_at", at).
+		Where(sq.Eq{"telegram_id": telegramID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("update last active: %w", err)
+	}
+	return nil
+}
+
+// RecordFunnelEvent сохраняет событие воронки продаж
+func (r *ActivityRepository) RecordFunnelEvent(ctx context.Context, telegramID int64, event FunnelEvent) error {
+	query := sq.Insert("funnel_event").
+		Columns("telegram_id", "event").
+		Values(telegramID, event).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("insert funnel event: %w", err)
+	}
+	return nil
+}
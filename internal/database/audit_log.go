@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Действия админа, которые пишутся в журнал
+const (
+	AuditActionTagAdded       = "tag_added"
+	AuditActionTagRemoved     = "tag_removed"
+	AuditActionNoteSet        = "note_set"
+	AuditActionNoteDeleted    = "note_deleted"
+	AuditActionLinkRotated    = "link_rotated"
+	AuditActionDataExported   = "data_exported"
+	AuditActionDataErased     = "data_erased"
+	AuditActionTrialRegranted = "trial_regranted"
+)
+
+// AuditLogEntry запись журнала действий админа над клиентом
+type AuditLogEntry struct {
+	ID         int64     `db:"id"`
+	AdminID    int64     `db:"admin_id"`
+	CustomerID *int64    `db:"customer_id"`
+	Action     string    `db:"action"`
+	Details    *string   `db:"details"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+type AuditLogRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewAuditLogRepository(pool *pgxpool.Pool) *AuditLogRepository {
+	return &AuditLogRepository{pool: pool}
+}
+
+// Record сохраняет действие админа над клиентом
+func (r *AuditLogRepository) Record(ctx context.Context, adminID, customerID int64, action, details string) error {
+	query := sq.Insert("admin_audit_log").
+		Columns("admin_id", "customer_id", "action", "details").
+		Values(adminID, customerID, action, details).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("insert audit log entry: %w", err)
+	}
+	return nil
+}
+
+// FindByCustomer возвращает последние limit записей журнала по клиенту, новые первыми
+func (r *AuditLogRepository) FindByCustomer(ctx context.Context, customerID int64, limit int) ([]AuditLogEntry, error) {
+	query := sq.Select("id", "admin_id", "customer_id", "action", "details", "created_at").
+		From("admin_audit_log").
+		Where(sq.Eq{"customer_id": customerID}).
+		OrderBy("created_at DESC", "id DESC").
+		Limit(uint64(limit)).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query audit log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []AuditLogEntry
+	for rows.Next() {
+		var e AuditLogEntry
+		if err := rows.Scan(&e.ID, &e.AdminID, &e.CustomerID, &e.Action, &e.Details, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan audit log entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"remnawave-tg-shop-bot/internal/database/dbtest"
+)
+
+// Бенчмарки запросов, которые выполняются на каждый update или на каждую рассылку. Запускаются на тестовой базе:
+//
+//	TEST_DATABASE_URL=... go test -run '^$' -bench . -benchtime 200x ./internal/database/
+
+const (
+	benchmarkCustomers      = 10000
+	benchmarkFirstTelegram  = int64(5_000_000_000)
+	benchmarkBroadcastBatch = 100
+)
+
+// seedBenchmarkCustomers создаёт benchmarkCustomers клиентов с подписками, истекающими в ближайшие 100 дней,
+// и по оплаченной покупке у каждого десятого
+func seedBenchmarkCustomers(b *testing.B) (*pgxpool.Pool, *CustomerRepository) {
+	b.Helper()
+	pool := dbtest.Pool(b)
+	repo := NewCustomerRepository(pool)
+	ctx := context.Background()
+
+	now := time.Now()
+	batch := make([]Customer, 0, 1000)
+	for i := 0; i < benchmarkCustomers; i++ {
+		expireAt := now.Add(time.Duration(i%100) * 24 * time.Hour)
+		batch = append(batch, Customer{TelegramID: benchmarkFirstTelegram + int64(i), ExpireAt: &expireAt, Language: "ru"})
+		if len(batch) == cap(batch) {
+			if err := repo.CreateBatch(ctx, batch); err != nil {
+				b.Fatalf("CreateBatch() returned error: %v", err)
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := repo.CreateBatch(ctx, batch); err != nil {
+		b.Fatalf("CreateBatch() returned error: %v", err)
+	}
+
+	_, err := pool.Exec(ctx, `
+		INSERT INTO purchase (amount, customer_id, month, currency, status, invoice_type, paid_at)
+		SELECT 100, id, 1, 'RUB', $1, $2, NOW() FROM customer WHERE id % 10 = 0`, PurchaseStatusPaid, InvoiceTypeYookasa)
+	if err != nil {
+		b.Fatalf("Failed to insert purchases: %v", err)
+	}
+	return pool, repo
+}
+
+func BenchmarkCustomerFindByTelegramId(b *testing.B) {
+	_, repo := seedBenchmarkCustomers(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.FindByTelegramId(ctx, benchmarkFirstTelegram+int64(i%benchmarkCustomers)); err != nil {
+			b.Fatalf("FindByTelegramId() returned error: %v", err)
+		}
+	}
+}
+
+// Повторные запросы одного клиента в пределах TTL кеша, как при серии нажатий в меню
+func BenchmarkCustomerFindByTelegramIdCached(b *testing.B) {
+	_, repo := seedBenchmarkCustomers(b)
+	ctx := context.Background()
+
+	previous := cachedCustomers
+	cachedCustomers = newCustomerCache(func() time.Duration { return time.Second })
+	b.Cleanup(func() { cachedCustomers = previous })
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.FindByTelegramId(ctx, benchmarkFirstTelegram+int64(i%10)); err != nil {
+			b.Fatalf("FindByTelegramId() returned error: %v", err)
+		}
+	}
+}
+
+// Пачка получателей рассылки
+func BenchmarkCustomerFindByTelegramIds(b *testing.B) {
+	_, repo := seedBenchmarkCustomers(b)
+	ctx := context.Background()
+
+	ids := make([]int64, benchmarkBroadcastBatch)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		offset := (i * benchmarkBroadcastBatch) % benchmarkCustomers
+		for j := range ids {
+			ids[j] = benchmarkFirstTelegram + int64((offset+j)%benchmarkCustomers)
+		}
+		if _, err := repo.FindByTelegramIds(ctx, ids); err != nil {
+			b.Fatalf("FindByTelegramIds() returned error: %v", err)
+		}
+	}
+}
+
+// Аудитория рассылки "всем"
+func BenchmarkCustomerFindAll(b *testing.B) {
+	_, repo := seedBenchmarkCustomers(b)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		customers, err := repo.FindAll(ctx)
+		if err != nil {
+			b.Fatalf("FindAll() returned error: %v", err)
+		}
+		if len(customers) != benchmarkCustomers {
+			b.Fatalf("Expected %d customers, got %d", benchmarkCustomers, len(customers))
+		}
+	}
+}
+
+// Выборка для ежедневных напоминаний об окончании подписки
+func BenchmarkCustomerFindByExpirationRange(b *testing.B) {
+	_, repo := seedBenchmarkCustomers(b)
+	ctx := context.Background()
+
+	start := time.Now().Add(24 * time.Hour)
+	end := start.Add(24 * time.Hour)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.FindByExpirationRange(ctx, start, end); err != nil {
+			b.Fatalf("FindByExpirationRange() returned error: %v", err)
+		}
+	}
+}
+
+// Последняя оплата клиента для карточки подписки
+func BenchmarkPurchaseFindLastPaidPurchaseByCustomer(b *testing.B) {
+	pool, _ := seedBenchmarkCustomers(b)
+	repo := NewPurchaseRepository(pool)
+	ctx := context.Background()
+
+	var firstID int64
+	if err := pool.QueryRow(ctx, `SELECT MIN(id) FROM customer`).Scan(&firstID); err != nil {
+		b.Fatalf("Failed to read customer ids: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.FindLastPaidPurchaseByCustomer(ctx, firstID+int64(i%benchmarkCustomers)); err != nil {
+			b.Fatalf("FindLastPaidPurchaseByCustomer() returned error: %v", err)
+		}
+	}
+}
@@ -16,6 +16,9 @@ const (
 	BroadcastStatusCompleted  BroadcastStatus = "completed"
 	BroadcastStatusPartial    BroadcastStatus = "partial"
 	BroadcastStatusFailed     BroadcastStatus = "failed"
+	// BroadcastStatusInterrupted рассылка остановлена при выключении бота и продолжится после запуска
+	// с сохранённой позиции, счётчики — на момент остановки
+	BroadcastStatusInterrupted BroadcastStatus = "interrupted"
 )
 
 type BroadcastHistory struct {
@@ -28,14 +31,57 @@ type BroadcastHistory struct {
 	Status      string     `db:"status"`
 	CreatedAt   time.Time  `db:"created_at"`
 	CompletedAt *time.Time `db:"completed_at"`
+	// TestSentCount тестовые отправки админу самому себе, в SentCount не входят
+	TestSentCount int `db:"test_sent_count"`
+	// ExcludedCount клиенты аудитории, исключённые при отправке, в TotalCount не входят
+	ExcludedCount int `db:"excluded_count"`
+}
+
+// BroadcastResume прерванная рассылка и позиция, с которой её нужно продолжить
+type BroadcastResume struct {
+	ID          int64
+	TargetType  string
+	MessageText string
+	// Options опции рассылки в JSON, nil — рассылка без опций
+	Options []byte
+	// LastCustomerID получатели с id не больше уже обработаны
+	LastCustomerID int64
+	SentCount      int
+	FailedCount    int
+}
+
+// BroadcastExclusion условия, по которым клиент аудитории не получает рассылку
+type BroadcastExclusion struct {
+	// ReceivedSince исключает получивших любую рассылку после этого момента
+	ReceivedSince *time.Time
+	// Tags исключает клиентов с любым из тегов поддержки
+	Tags []string
+	// FrequencyCap исключает клиентов, получивших столько рассылок в окне, начатом после FrequencyWindowSince.
+	// 0 — без лимита
+	FrequencyCap         int
+	FrequencyWindowSince time.Time
+}
+
+// IsEmpty возвращает true, если исключать некого
+func (e BroadcastExclusion) IsEmpty() bool {
+	return e.ReceivedSince == nil && len(e.Tags) == 0 && e.FrequencyCap == 0
 }
 
 type BroadcastRepository struct {
 	pool *pgxpool.Pool
+	// readPool реплика для подсчёта исключённых из рассылки. Совпадает с pool, если реплика не настроена
+	readPool *pgxpool.Pool
 }
 
 func NewBroadcastRepository(pool *pgxpool.Pool) *BroadcastRepository {
-	return &BroadcastRepository{pool: pool}
+	return &BroadcastRepository{pool: pool, readPool: pool}
+}
+
+// SetReadPool направляет аналитические запросы в реплику только для чтения. nil — в основной пул
+func (br *BroadcastRepository) SetReadPool(pool *pgxpool.Pool) {
+	if pool != nil {
+		br.readPool = pool
+	}
 }
 
 func (br *BroadcastRepository) Create(ctx context.Context, targetType, messageText string) (int64, error) {
@@ -74,10 +120,12 @@ func (br *BroadcastRepository) UpdateStatus(ctx context.Context, id int64, statu
 	return err
 }
 
-func (br *BroadcastRepository) UpdateProgress(ctx context.Context, id int64, sentCount, failedCount int) error {
+// UpdateProgress сохраняет счётчики и позицию рассылки: получатели с id не больше lastCustomerID обработаны
+func (br *BroadcastRepository) UpdateProgress(ctx context.Context, id, lastCustomerID int64, sentCount, failedCount int) error {
 	query := sq.Update("broadcast_history").
 		Set("sent_count", sentCount).
 		Set("failed_count", failedCount).
+		Set("last_customer_id", lastCustomerID).
 		Where(sq.Eq{"id": id}).
 		PlaceholderFormat(sq.Dollar)
 
@@ -106,8 +154,151 @@ func (br *BroadcastRepository) SetTotalCount(ctx context.Context, id int64, tota
 	return err
 }
 
+// Interrupt сохраняет позицию рассылки, остановленной при выключении бота
+func (br *BroadcastRepository) Interrupt(ctx context.Context, id, lastCustomerID int64, sentCount, failedCount int) error {
+	query := sq.Update("broadcast_history").
+		Set("status", string(BroadcastStatusInterrupted)).
+		Set("sent_count", sentCount).
+		Set("failed_count", failedCount).
+		Set("last_customer_id", lastCustomerID).
+		Set("completed_at", time.Now()).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = br.pool.Exec(ctx, sql, args...)
+	return err
+}
+
+// ClaimInterrupted возвращает прерванные рассылки для продолжения и переводит их в in_progress:
+// при нескольких экземплярах бота рассылку продолжает только один
+func (br *BroadcastRepository) ClaimInterrupted(ctx context.Context) ([]BroadcastResume, error) {
+	rows, err := br.pool.Query(ctx, `
+		UPDATE broadcast_history SET status = $1, completed_at = NULL
+		WHERE status = $2
+		RETURNING id, target_type, message_text, options, COALESCE(last_customer_id, 0), COALESCE(sent_count, 0), COALESCE(failed_count, 0)`,
+		string(BroadcastStatusInProgress), string(BroadcastStatusInterrupted))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []BroadcastResume
+	for rows.Next() {
+		var b BroadcastResume
+		if err := rows.Scan(&b.ID, &b.TargetType, &b.MessageText, &b.Options, &b.LastCustomerID, &b.SentCount, &b.FailedCount); err != nil {
+			return nil, err
+		}
+		result = append(result, b)
+	}
+	return result, rows.Err()
+}
+
+// SetOptions сохраняет опции рассылки в JSON, чтобы прерванную рассылку можно было продолжить с теми же настройками
+func (br *BroadcastRepository) SetOptions(ctx context.Context, id int64, options []byte) error {
+	_, err := br.pool.Exec(ctx, "UPDATE broadcast_history SET options = $2 WHERE id = $1", id, options)
+	return err
+}
+
+// SetExcludedCount сохраняет количество клиентов аудитории, не получивших рассылку из-за исключений
+func (br *BroadcastRepository) SetExcludedCount(ctx context.Context, id int64, excluded int) error {
+	query := sq.Update("broadcast_history").
+		Set("excluded_count", excluded).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = br.pool.Exec(ctx, sql, args...)
+	return err
+}
+
+// buildExcludedCustomersQuery выбирает id клиентов, подходящих под любое из условий исключения
+func buildExcludedCustomersQuery(e BroadcastExclusion) sq.SelectBuilder {
+	var conditions sq.Or
+	if e.ReceivedSince != nil {
+		conditions = append(conditions, sq.Gt{"c.last_broadcast_at": *e.ReceivedSince})
+	}
+	if len(e.Tags) > 0 {
+		conditions = append(conditions, sq.Expr("EXISTS (SELECT 1 FROM customer_tag t WHERE t.customer_id = c.id AND t.tag = ANY(?))", e.Tags))
+	}
+	if e.FrequencyCap > 0 {
+		conditions = append(conditions, sq.And{
+			sq.Gt{"c.broadcast_window_started_at": e.FrequencyWindowSince},
+			sq.GtOrEq{"c.broadcast_window_count": e.FrequencyCap},
+		})
+	}
+	return sq.Select("c.id").From("customer c").Where(conditions)
+}
+
+// FindExcludedCustomerIDs возвращает id клиентов, которые не должны получить рассылку
+func (br *BroadcastRepository) FindExcludedCustomerIDs(ctx context.Context, e BroadcastExclusion) (map[int64]bool, error) {
+	if e.IsEmpty() {
+		return nil, nil
+	}
+	sql, args, err := buildExcludedCustomersQuery(e).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := br.readPool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	excluded := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		excluded[id] = true
+	}
+	return excluded, rows.Err()
+}
+
+// RecordDelivery отмечает доставку рассылки клиенту. Окно лимита частоты начинается заново с этой доставки,
+// если предыдущее окно началось не позже windowSince
+func (br *BroadcastRepository) RecordDelivery(ctx context.Context, customerID int64, at, windowSince time.Time) error {
+	_, err := br.pool.Exec(ctx, `
+		UPDATE customer SET
+			last_broadcast_at = $2,
+			broadcast_window_count = CASE
+				WHEN broadcast_window_started_at IS NULL OR broadcast_window_started_at <= $3 THEN 1
+				ELSE broadcast_window_count + 1 END,
+			broadcast_window_started_at = CASE
+				WHEN broadcast_window_started_at IS NULL OR broadcast_window_started_at <= $3 THEN $2
+				ELSE broadcast_window_started_at END
+		WHERE id = $1`, customerID, at, windowSince)
+	return err
+}
+
+// IncrementTestSent учитывает тестовую отправку рассылки админу
+func (br *BroadcastRepository) IncrementTestSent(ctx context.Context, id int64) error {
+	query := sq.Update("broadcast_history").
+		Set("test_sent_count", sq.Expr("test_sent_count + 1")).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = br.pool.Exec(ctx, sql, args...)
+	return err
+}
+
 func (br *BroadcastRepository) List(ctx context.Context, limit, offset int) ([]BroadcastHistory, error) {
-	query := sq.Select("id", "target_type", "message_text", "total_count", "sent_count", "failed_count", "status", "created_at", "completed_at").
+	query := sq.Select("id", "target_type", "message_text", "total_count", "sent_count", "failed_count", "test_sent_count", "excluded_count", "status", "created_at", "completed_at").
 		From("broadcast_history").
 		OrderBy("created_at DESC").
 		Limit(uint64(limit)).
@@ -128,7 +319,7 @@ func (br *BroadcastRepository) List(ctx context.Context, limit, offset int) ([]B
 	var history []BroadcastHistory
 	for rows.Next() {
 		var h BroadcastHistory
-		err := rows.Scan(&h.ID, &h.TargetType, &h.MessageText, &h.TotalCount, &h.SentCount, &h.FailedCount, &h.Status, &h.CreatedAt, &h.CompletedAt)
+		err := rows.Scan(&h.ID, &h.TargetType, &h.MessageText, &h.TotalCount, &h.SentCount, &h.FailedCount, &h.TestSentCount, &h.ExcludedCount, &h.Status, &h.CreatedAt, &h.CompletedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -139,7 +330,7 @@ func (br *BroadcastRepository) List(ctx context.Context, limit, offset int) ([]B
 }
 
 func (br *BroadcastRepository) FindByID(ctx context.Context, id int64) (*BroadcastHistory, error) {
-	query := sq.Select("id", "target_type", "message_text", "total_count", "sent_count", "failed_count", "status", "created_at", "completed_at").
+	query := sq.Select("id", "target_type", "message_text", "total_count", "sent_count", "failed_count", "test_sent_count", "excluded_count", "status", "created_at", "completed_at").
 		From("broadcast_history").
 		Where(sq.Eq{"id": id}).
 		PlaceholderFormat(sq.Dollar)
@@ -150,7 +341,7 @@ func (br *BroadcastRepository) FindByID(ctx context.Context, id int64) (*Broadca
 	}
 
 	var h BroadcastHistory
-	err = br.pool.QueryRow(ctx, sql, args...).Scan(&h.ID, &h.TargetType, &h.MessageText, &h.TotalCount, &h.SentCount, &h.FailedCount, &h.Status, &h.CreatedAt, &h.CompletedAt)
+	err = br.pool.QueryRow(ctx, sql, args...).Scan(&h.ID, &h.TargetType, &h.MessageText, &h.TotalCount, &h.SentCount, &h.FailedCount, &h.TestSentCount, &h.ExcludedCount, &h.Status, &h.CreatedAt, &h.CompletedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,53 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+func TestBuildExcludedCustomersQuery(t *testing.T) {
+	received := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := received.Add(-7 * 24 * time.Hour)
+
+	sql, args, err := buildExcludedCustomersQuery(BroadcastExclusion{
+		ReceivedSince:        &received,
+		Tags:                 []string{"vip", "chargeback_risk"},
+		FrequencyCap:         2,
+		FrequencyWindowSince: window,
+	}).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() returned error: %v", err)
+	}
+
+	want := "SELECT c.id FROM customer c WHERE (c.last_broadcast_at > $1 OR " +
+		"EXISTS (SELECT 1 FROM customer_tag t WHERE t.customer_id = c.id AND t.tag = ANY($2)) OR " +
+		"(c.broadcast_window_started_at > $3 AND c.broadcast_window_count >= $4))"
+	if sql != want {
+		t.Fatalf("unexpected SQL:\n%s\nwant:\n%s", sql, want)
+	}
+	expectedArgs := []interface{}{received, []string{"vip", "chargeback_risk"}, window, 2}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Fatalf("unexpected args, want %v, got %v", expectedArgs, args)
+	}
+
+	sql, _, err = buildExcludedCustomersQuery(BroadcastExclusion{Tags: []string{"vip"}}).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() returned error: %v", err)
+	}
+	if strings.Contains(sql, "last_broadcast_at") || strings.Contains(sql, "broadcast_window") {
+		t.Fatalf("Expected only tag condition, got %s", sql)
+	}
+}
+
+func TestBroadcastExclusionIsEmpty(t *testing.T) {
+	if !(BroadcastExclusion{}).IsEmpty() {
+		t.Error("Expected zero exclusion to be empty")
+	}
+	if (BroadcastExclusion{FrequencyCap: 3}).IsEmpty() {
+		t.Error("Expected frequency cap to exclude customers")
+	}
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// CheckoutReminderStats статистика напоминаний о неоплаченных счетах
+type CheckoutReminderStats struct {
+	Sent int
+	// PaidOriginal оплачен тот самый счёт, о котором напомнили
+	PaidOriginal int
+	// PaidRecreated счёт не оплачен, но клиент оплатил новую покупку в течение суток после напоминания
+	PaidRecreated int
+}
+
+type CheckoutReminderRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewCheckoutReminderRepository(pool *pgxpool.Pool) *CheckoutReminderRepository {
+	return &CheckoutReminderRepository{pool: pool}
+}
+
+// buildAbandonedQuery выбирает неоплаченные счета, созданные в промежутке (from, to], по которым ещё не было
+// напоминания. Счёт пропускается, если после него клиент создал новую покупку: напоминать о старом уже незачем
+func buildAbandonedQuery(invoiceTypes []InvoiceType, from, to time.Time, limit int) sq.SelectBuilder {
+	return sq.Select(purchaseColumns()...).
+		From("purchase").
+		Where(sq.And{
+			sq.Eq{"status": PurchaseStatusPending},
+			sq.Eq{"invoice_type": invoiceTypes},
+			sq.Eq{"is_test": false},
+			// Счёт подарка выставлен плательщику, а записан на получателя: напоминание ушло бы не тому
+			sq.Eq{"gift_from_customer_id": nil},
+			sq.Gt{"created_at": from},
+			sq.LtOrEq{"created_at": to},
+			sq.Expr("NOT EXISTS (SELECT 1 FROM checkout_reminder r WHERE r.purchase_id = purchase.id)"),
+			sq.Expr("NOT EXISTS (SELECT 1 FROM purchase n WHERE n.customer_id = purchase.customer_id AND n.created_at > purchase.created_at)"),
+		}).
+		OrderBy("created_at").
+		Limit(uint64(limit))
+}
+
+// FindAbandoned возвращает до limit брошенных счетов, созданных в промежутке (from, to]
+func (r *CheckoutReminderRepository) FindAbandoned(ctx context.Context, invoiceTypes []InvoiceType, from, to time.Time, limit int) ([]Purchase, error) {
+	sql, args, err := buildAbandonedQuery(invoiceTypes, from, to, limit).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build abandoned checkout query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query abandoned checkouts: %w", err)
+	}
+	defer rows.Close()
+
+	var purchases []Purchase
+	for rows.Next() {
+		purchase, err := scanPurchaseFromRows(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan purchase: %w", err)
+		}
+		purchases = append(purchases, *purchase)
+	}
+	return purchases, rows.Err()
+}
+
+// Claim отмечает, что по покупке отправляется напоминание. Возвращает false, если напоминание уже было:
+// отметка ставится до отправки, поэтому при сбое отправки напоминание не повторяется
+func (r *CheckoutReminderRepository) Claim(ctx context.Context, purchaseID, customerID int64, sentAt time.Time) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		INSERT INTO checkout_reminder (purchase_id, customer_id, sent_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (purchase_id) DO NOTHING`, purchaseID, customerID, sentAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to save checkout reminder: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// Stats возвращает статистику напоминаний, отправленных начиная с since
+func (r *CheckoutReminderRepository) Stats(ctx context.Context, since time.Time) (CheckoutReminderStats, error) {
+	var stats CheckoutReminderStats
+	err := r.pool.QueryRow(ctx, `
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE p.status = $2),
+		       COUNT(*) FILTER (WHERE p.status IS DISTINCT FROM $2 AND EXISTS (
+		           SELECT 1 FROM `+purchaseHistoryView+` n
+		           WHERE n.customer_id = r.customer_id
+		             AND n.id <> r.purchase_id
+		             AND n.status = $2
+		             AND n.paid_at >= r.sent_at
+		             AND n.paid_at < r.sent_at + INTERVAL '24 hours'
+		       ))
+		FROM checkout_reminder r
+		LEFT JOIN `+purchaseHistoryView+` p ON p.id = r.purchase_id
+		WHERE r.sent_at >= $1`, since, PurchaseStatusPaid).
+		Scan(&stats.Sent, &stats.PaidOriginal, &stats.PaidRecreated)
+	if err != nil {
+		return CheckoutReminderStats{}, fmt.Errorf("failed to query checkout reminder stats: %w", err)
+	}
+	return stats, nil
+}
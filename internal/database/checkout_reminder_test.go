@@ -0,0 +1,40 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+func TestBuildAbandonedQuery(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(23 * time.Hour)
+
+	sql, args, err := buildAbandonedQuery([]InvoiceType{InvoiceTypeCrypto, InvoiceTypeTelegram}, from, to, 50).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() returned error: %v", err)
+	}
+
+	for _, want := range []string{
+		"invoice_type IN ($2,$3)",
+		"gift_from_customer_id IS NULL",
+		"created_at > $5",
+		"created_at <= $6",
+		"FROM checkout_reminder r WHERE r.purchase_id = purchase.id",
+		"n.created_at > purchase.created_at",
+		"LIMIT 50",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("SQL does not contain %q: %s", want, sql)
+		}
+	}
+
+	expectedArgs := []interface{}{PurchaseStatusPending, InvoiceTypeCrypto, InvoiceTypeTelegram, false, from, to}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Fatalf("unexpected args, want %v, got %v", expectedArgs, args)
+	}
+}
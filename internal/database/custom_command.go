@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// CustomCommand команда с постоянным ответом, добавленная админом
+type CustomCommand struct {
+	ID            int64
+	Command       string // без "/", например speed
+	DescriptionRu string
+	DescriptionEn string
+	TextRu        string
+	TextEn        string
+	MediaType     string // photo, gif, video или пусто
+	MediaFileID   string
+	Buttons       string // кнопки-ссылки, по одной на строку: "Текст | https://..."
+	IsActive      bool
+	CreatedBy     *int64
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+var customCommandColumns = []string{
+	"id", "command", "description_ru", "description_en", "text_ru", "text_en",
+	"media_type", "media_file_id", "buttons", "is_active", "created_by", "created_at", "updated_at",
+}
+
+type CustomCommandRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewCustomCommandRepository(pool *pgxpool.Pool) *CustomCommandRepository {
+	return &CustomCommandRepository{pool: pool}
+}
+
+// List возвращает все команды, включая выключенные, по алфавиту
+func (r *CustomCommandRepository) List(ctx context.Context) ([]CustomCommand, error) {
+	return r.list(ctx, sq.Select(customCommandColumns...).From("custom_command").OrderBy("command"))
+}
+
+// ListActive возвращает включённые команды по алфавиту
+func (r *CustomCommandRepository) ListActive(ctx context.Context) ([]CustomCommand, error) {
+	return r.list(ctx, sq.Select(customCommandColumns...).From("custom_command").Where(sq.Eq{"is_active": true}).OrderBy("command"))
+}
+
+func (r *CustomCommandRepository) list(ctx context.Context, query sq.SelectBuilder) ([]CustomCommand, error) {
+	sql, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query custom commands: %w", err)
+	}
+	defer rows.Close()
+
+	var commands []CustomCommand
+	for rows.Next() {
+		command, err := scanCustomCommand(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan custom command: %w", err)
+		}
+		commands = append(commands, *command)
+	}
+	return commands, rows.Err()
+}
+
+// FindByID возвращает команду или nil, если её нет
+func (r *CustomCommandRepository) FindByID(ctx context.Context, id int64) (*CustomCommand, error) {
+	sql, args, err := sq.Select(customCommandColumns...).
+		From("custom_command").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	command, err := scanCustomCommand(r.pool.QueryRow(ctx, sql, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query custom command: %w", err)
+	}
+	return command, nil
+}
+
+// Save создаёт команду или заменяет ответ существующей команды с тем же именем. Включённость не меняется
+func (r *CustomCommandRepository) Save(ctx context.Context, command CustomCommand) (*CustomCommand, error) {
+	now := time.Now()
+	sql, args, err := sq.Insert("custom_command").
+		Columns("command", "description_ru", "description_en", "text_ru", "text_en",
+			"media_type", "media_file_id", "buttons", "created_by", "created_at", "updated_at").
+		Values(command.Command, command.DescriptionRu, command.DescriptionEn, command.TextRu, command.TextEn,
+			command.MediaType, command.MediaFileID, command.Buttons, command.CreatedBy, now, now).
+		Suffix(`ON CONFLICT (command) DO UPDATE SET
+			description_ru = EXCLUDED.description_ru, description_en = EXCLUDED.description_en,
+			text_ru = EXCLUDED.text_ru, text_en = EXCLUDED.text_en,
+			media_type = EXCLUDED.media_type, media_file_id = EXCLUDED.media_file_id,
+			buttons = EXCLUDED.buttons, updated_at = EXCLUDED.updated_at
+			RETURNING ` + strings.Join(customCommandColumns, ", ")).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	saved, err := scanCustomCommand(r.pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		return nil, fmt.Errorf("save custom command: %w", err)
+	}
+	return saved, nil
+}
+
+// SetActive включает или выключает команду
+func (r *CustomCommandRepository) SetActive(ctx context.Context, id int64, active bool) error {
+	sql, args, err := sq.Update("custom_command").
+		Set("is_active", active).
+		Set("updated_at", time.Now()).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("update custom command: %w", err)
+	}
+	return nil
+}
+
+// Delete удаляет команду
+func (r *CustomCommandRepository) Delete(ctx context.Context, id int64) error {
+	sql, args, err := sq.Delete("custom_command").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("delete custom command: %w", err)
+	}
+	return nil
+}
+
+func scanCustomCommand(row pgx.Row) (*CustomCommand, error) {
+	var c CustomCommand
+	err := row.Scan(&c.ID, &c.Command, &c.DescriptionRu, &c.DescriptionEn, &c.TextRu, &c.TextEn,
+		&c.MediaType, &c.MediaFileID, &c.Buttons, &c.IsActive, &c.CreatedBy, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -11,15 +11,27 @@ import (
 	sq "github.com/Masterminds/squirrel"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/fieldcrypt"
 	"remnawave-tg-shop-bot/utils"
 )
 
 type CustomerRepository struct {
 	pool *pgxpool.Pool
+	// readPool реплика для выборок аудитории рассылок. Совпадает с pool, если реплика не настроена
+	readPool *pgxpool.Pool
 }
 
 func NewCustomerRepository(poll *pgxpool.Pool) *CustomerRepository {
-	return &CustomerRepository{pool: poll}
+	return &CustomerRepository{pool: poll, readPool: poll}
+}
+
+// SetReadPool направляет аналитические запросы в реплику только для чтения. nil — в основной пул
+func (cr *CustomerRepository) SetReadPool(pool *pgxpool.Pool) {
+	if pool != nil {
+		cr.readPool = pool
+	}
 }
 
 type Customer struct {
@@ -39,6 +51,20 @@ type Customer struct {
 	WinbackOfferPrice     *int       `db:"winback_offer_price"`
 	WinbackOfferDevices   *int       `db:"winback_offer_devices"`
 	WinbackOfferMonths    *int       `db:"winback_offer_months"`
+	// WinbackOfferTariff и WinbackOfferCampaign заполняются только для предложений ушедшим платящим клиентам
+	WinbackOfferTariff   *string    `db:"winback_offer_tariff"`
+	WinbackOfferCampaign *string    `db:"winback_offer_campaign"`
+	WinbackOptedOutAt    *time.Time `db:"winback_opted_out_at"`
+
+	// Отказ от маркетинговых сообщений: рассылок и предложений в триале
+	MarketingOptedOutAt *time.Time `db:"marketing_opted_out_at"`
+
+	// Момент, когда синхронизация не нашла клиента в панели. Такие клиенты не попадают в выборки,
+	// кроме поиска по id, и восстанавливаются при появлении в панели или новом /start
+	DeletedAt *time.Time `db:"deleted_at"`
+
+	// Момент, когда пользователь панели с давно истёкшим триалом был отключён или удалён. Покупка выдаёт подписку заново
+	TrialCleanedAt *time.Time `db:"trial_cleaned_at"`
 
 	// Recurring payments
 	RecurringEnabled    bool       `db:"recurring_enabled"`
@@ -47,6 +73,20 @@ type Customer struct {
 	RecurringMonths     *int       `db:"recurring_months"`
 	RecurringAmount     *int       `db:"recurring_amount"`
 	RecurringNotifiedAt *time.Time `db:"recurring_notified_at"`
+	// Срок продления посуточного плана в днях, задаётся вместо RecurringMonths
+	RecurringDays *int `db:"recurring_days"`
+
+	// Recurring price lock: снимок тарифа на момент подключения и запланированное админом изменение цены
+	RecurringListPrice          *int       `db:"recurring_list_price"`
+	RecurringDeviceLimit        *int       `db:"recurring_device_limit"`
+	RecurringLockedAt           *time.Time `db:"recurring_locked_at"`
+	RecurringPendingAmount      *int       `db:"recurring_pending_amount"`
+	RecurringPendingEffectiveAt *time.Time `db:"recurring_pending_effective_at"`
+	RecurringPendingConsentedAt *time.Time `db:"recurring_pending_consented_at"`
+
+	// Подтверждение дорогого автосписания: запрос за сутки до списания и нажатие клиентом кнопки
+	RecurringConfirmRequestedAt *time.Time `db:"recurring_confirm_requested_at"`
+	RecurringConfirmedAt        *time.Time `db:"recurring_confirmed_at"`
 
 	// Promo tariff offer
 	PromoOfferPrice     *int       `db:"promo_offer_price"`
@@ -54,50 +94,154 @@ type Customer struct {
 	PromoOfferMonths    *int       `db:"promo_offer_months"`
 	PromoOfferExpiresAt *time.Time `db:"promo_offer_expires_at"`
 	PromoOfferCodeID    *int64     `db:"promo_offer_code_id"`
+
+	// Источник привлечения (utm из /start или referral)
+	Source *string `db:"source"`
+
+	// Terms of service acceptance
+	TosAcceptedVersion *string    `db:"tos_accepted_version"`
+	TosAcceptedAt      *time.Time `db:"tos_accepted_at"`
+
+	// Trial policy: последний триал и разрешение админа выдать триал повторно
+	TrialActivatedAt *time.Time `db:"trial_activated_at"`
+	TrialRegrantedAt *time.Time `db:"trial_regranted_at"`
+
+	// Часовой пояс из профиля (IANA), nil — часовой пояс бота
+	Timezone *string `db:"timezone"`
+
+	// Email для чеков ЮKassa, nil — чек уходит на YOOKASA_EMAIL
+	Email *string `db:"email"`
 }
 
+// RecurringPeriod срок автопродления: дни посуточного плана, иначе месяцы (по умолчанию 1)
+func (c *Customer) RecurringPeriod() config.Period {
+	if c.RecurringDays != nil {
+		return config.DaysPeriod(*c.RecurringDays)
+	}
+	if c.RecurringMonths != nil {
+		return config.MonthsPeriod(*c.RecurringMonths)
+	}
+	return config.MonthsPeriod(1)
+}
+
+// SubscriptionPageURL ссылка на подписку для показа клиенту на языке lang, переписанная по шаблону
+// SUBSCRIPTION_PAGE_TEMPLATE. Тариф берётся из последней оплаченной покупки lastPaid, без неё — из автопродления.
+// Пусто — ссылки нет
+func (c *Customer) SubscriptionPageURL(lastPaid *Purchase, lang string) string {
+	if c.SubscriptionLink == nil || *c.SubscriptionLink == "" {
+		return ""
+	}
+	var tariffName string
+	switch {
+	case lastPaid != nil && lastPaid.TariffName != nil:
+		tariffName = *lastPaid.TariffName
+	case c.RecurringTariffName != nil:
+		tariffName = *c.RecurringTariffName
+	}
+	return config.SubscriptionPageURL(*c.SubscriptionLink, tariffName, lang)
+}
+
+// HasAcceptedTos возвращает true если клиент принял условия использования версии version
+func (c *Customer) HasAcceptedTos(version string) bool {
+	return c.TosAcceptedVersion != nil && *c.TosAcceptedVersion == version
+}
+
+// NeedsTosAcceptance возвращает true если перед триалом или покупкой клиент должен принять
+// текущую версию условий использования
+func (c *Customer) NeedsTosAcceptance() bool {
+	return config.IsTosAcceptanceRequired() && !c.HasAcceptedTos(config.TosVersion())
+}
+
+// notDeleted условие выборок клиентов: удалённые синхронизацией клиенты по умолчанию не выбираются
+var notDeleted = sq.Eq{"deleted_at": nil}
+
 // customerColumns returns all customer columns for SELECT queries
 func customerColumns() []string {
 	return []string{
 		"id", "telegram_id", "expire_at", "created_at", "subscription_link", "language",
 		"trial_inactive_notified_at", "winback_offer_sent_at", "winback_offer_expires_at",
 		"winback_offer_price", "winback_offer_devices", "winback_offer_months",
+		"winback_offer_tariff", "winback_offer_campaign", "winback_opted_out_at",
 		"recurring_enabled", "payment_method_id", "recurring_tariff_name",
 		"recurring_months", "recurring_amount", "recurring_notified_at",
 		"promo_offer_price", "promo_offer_devices", "promo_offer_months",
 		"promo_offer_expires_at", "promo_offer_code_id",
+		"source", "tos_accepted_version", "tos_accepted_at",
+		"trial_activated_at", "trial_regranted_at",
+		"recurring_list_price", "recurring_device_limit", "recurring_locked_at",
+		"recurring_pending_amount", "recurring_pending_effective_at", "recurring_pending_consented_at",
+		"timezone", "email", "recurring_days", "marketing_opted_out_at", "deleted_at",
+		"trial_cleaned_at", "recurring_confirm_requested_at", "recurring_confirmed_at",
+	}
+}
+
+// prefixedCustomerColumns returns customer columns qualified with table alias for raw SQL with joins
+func prefixedCustomerColumns(alias string) []string {
+	columns := customerColumns()
+	for i, column := range columns {
+		columns[i] = alias + "." + column
+	}
+	return columns
+}
+
+// customerFields returns scan targets in customerColumns order
+func customerFields(c *Customer) []interface{} {
+	return []interface{}{
+		&c.ID,
+		&c.TelegramID,
+		&c.ExpireAt,
+		&c.CreatedAt,
+		&c.SubscriptionLink,
+		&c.Language,
+		&c.TrialInactiveNotifiedAt,
+		&c.WinbackOfferSentAt,
+		&c.WinbackOfferExpiresAt,
+		&c.WinbackOfferPrice,
+		&c.WinbackOfferDevices,
+		&c.WinbackOfferMonths,
+		&c.WinbackOfferTariff,
+		&c.WinbackOfferCampaign,
+		&c.WinbackOptedOutAt,
+		&c.RecurringEnabled,
+		&c.PaymentMethodID,
+		&c.RecurringTariffName,
+		&c.RecurringMonths,
+		&c.RecurringAmount,
+		&c.RecurringNotifiedAt,
+		&c.PromoOfferPrice,
+		&c.PromoOfferDevices,
+		&c.PromoOfferMonths,
+		&c.PromoOfferExpiresAt,
+		&c.PromoOfferCodeID,
+		&c.Source,
+		&c.TosAcceptedVersion,
+		&c.TosAcceptedAt,
+		&c.TrialActivatedAt,
+		&c.TrialRegrantedAt,
+		&c.RecurringListPrice,
+		&c.RecurringDeviceLimit,
+		&c.RecurringLockedAt,
+		&c.RecurringPendingAmount,
+		&c.RecurringPendingEffectiveAt,
+		&c.RecurringPendingConsentedAt,
+		&c.Timezone,
+		&c.Email,
+		&c.RecurringDays,
+		&c.MarketingOptedOutAt,
+		&c.DeletedAt,
+		&c.TrialCleanedAt,
+		&c.RecurringConfirmRequestedAt,
+		&c.RecurringConfirmedAt,
 	}
 }
 
 // scanCustomer scans a row into a Customer struct
 func scanCustomer(row pgx.Row) (*Customer, error) {
 	var customer Customer
-	err := row.Scan(
-		&customer.ID,
-		&customer.TelegramID,
-		&customer.ExpireAt,
-		&customer.CreatedAt,
-		&customer.SubscriptionLink,
-		&customer.Language,
-		&customer.TrialInactiveNotifiedAt,
-		&customer.WinbackOfferSentAt,
-		&customer.WinbackOfferExpiresAt,
-		&customer.WinbackOfferPrice,
-		&customer.WinbackOfferDevices,
-		&customer.WinbackOfferMonths,
-		&customer.RecurringEnabled,
-		&customer.PaymentMethodID,
-		&customer.RecurringTariffName,
-		&customer.RecurringMonths,
-		&customer.RecurringAmount,
-		&customer.RecurringNotifiedAt,
-		&customer.PromoOfferPrice,
-		&customer.PromoOfferDevices,
-		&customer.PromoOfferMonths,
-		&customer.PromoOfferExpiresAt,
-		&customer.PromoOfferCodeID,
-	)
-	if err != nil {
+	if err := row.Scan(customerFields(&customer)...); err != nil {
+		return nil, err
+	}
+	if err := decryptCustomer(&customer); err != nil {
 		return nil, err
 	}
 	return &customer, nil
@@ -106,32 +250,10 @@ func scanCustomer(row pgx.Row) (*Customer, error) {
 // scanCustomerFromRows scans rows into a Customer struct
 func scanCustomerFromRows(rows pgx.Rows) (*Customer, error) {
 	var customer Customer
-	err := rows.Scan(
-		&customer.ID,
-		&customer.TelegramID,
-		&customer.ExpireAt,
-		&customer.CreatedAt,
-		&customer.SubscriptionLink,
-		&customer.Language,
-		&customer.TrialInactiveNotifiedAt,
-		&customer.WinbackOfferSentAt,
-		&customer.WinbackOfferExpiresAt,
-		&customer.WinbackOfferPrice,
-		&customer.WinbackOfferDevices,
-		&customer.WinbackOfferMonths,
-		&customer.RecurringEnabled,
-		&customer.PaymentMethodID,
-		&customer.RecurringTariffName,
-		&customer.RecurringMonths,
-		&customer.RecurringAmount,
-		&customer.RecurringNotifiedAt,
-		&customer.PromoOfferPrice,
-		&customer.PromoOfferDevices,
-		&customer.PromoOfferMonths,
-		&customer.PromoOfferExpiresAt,
-		&customer.PromoOfferCodeID,
-	)
-	if err != nil {
+	if err := rows.Scan(customerFields(&customer)...); err != nil {
+		return nil, err
+	}
+	if err := decryptCustomer(&customer); err != nil {
 		return nil, err
 	}
 	return &customer, nil
@@ -145,6 +267,7 @@ func (cr *CustomerRepository) FindByExpirationRange(ctx context.Context, startDa
 				sq.NotEq{"expire_at": nil},
 				sq.GtOrEq{"expire_at": startDate},
 				sq.LtOrEq{"expire_at": endDate},
+				notDeleted,
 			},
 		).
 		PlaceholderFormat(sq.Dollar)
@@ -176,6 +299,8 @@ func (cr *CustomerRepository) FindByExpirationRange(ctx context.Context, startDa
 	return &customers, nil
 }
 
+// FindById находит клиента по id, в том числе удалённого синхронизацией: id берётся из покупок и других
+// связанных записей, и оплата удалённого клиента должна выдать подписку
 func (cr *CustomerRepository) FindById(ctx context.Context, id int64) (*Customer, error) {
 	buildSelect := sq.Select(customerColumns()...).
 		From("customer").
@@ -197,24 +322,21 @@ func (cr *CustomerRepository) FindById(ctx context.Context, id int64) (*Customer
 	return customer, nil
 }
 
+// FindByTelegramId вызывается почти на каждый update, поэтому выполняется через prepared statement,
+// а найденный клиент кешируется на CUSTOMER_CACHE_TTL_MS
 func (cr *CustomerRepository) FindByTelegramId(ctx context.Context, telegramId int64) (*Customer, error) {
-	buildSelect := sq.Select(customerColumns()...).
-		From("customer").
-		Where(sq.Eq{"telegram_id": telegramId}).
-		PlaceholderFormat(sq.Dollar)
-
-	sql, args, err := buildSelect.ToSql()
-	if err != nil {
-		return nil, fmt.Errorf("failed to build select query: %w", err)
+	if customer, ok := cachedCustomers.get(telegramId); ok {
+		return customer, nil
 	}
-
-	customer, err := scanCustomer(cr.pool.QueryRow(ctx, sql, args...))
+	generation := cachedCustomers.currentGeneration()
+	customer, err := scanCustomer(queryRowPrepared(ctx, cr.pool, stmtFindCustomerByTelegramID, telegramId))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, fmt.Errorf("failed to query customer: %w", err)
 	}
+	cachedCustomers.put(customer, generation)
 	return customer, nil
 }
 
@@ -222,15 +344,17 @@ func (cr *CustomerRepository) Create(ctx context.Context, customer *Customer) (*
 	return cr.FindOrCreate(ctx, customer)
 }
 
-// FindOrCreate создаёт нового customer или возвращает существующего (защита от duplicate key при параллельных запросах)
+// FindOrCreate создаёт нового customer или возвращает существующего (защита от duplicate key при параллельных запросах).
+// Удалённый синхронизацией клиент восстанавливается вместе с историей покупок
 func (cr *CustomerRepository) FindOrCreate(ctx context.Context, customer *Customer) (*Customer, error) {
+	defer cachedCustomers.invalidateTelegramID(customer.TelegramID)
 	query := `
-		INSERT INTO customer (telegram_id, expire_at, language)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (telegram_id) DO UPDATE SET telegram_id = customer.telegram_id
+		INSERT INTO customer (telegram_id, expire_at, language, source)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (telegram_id) DO UPDATE SET deleted_at = NULL
 		RETURNING ` + strings.Join(customerColumns(), ", ")
 
-	row := cr.pool.QueryRow(ctx, query, customer.TelegramID, customer.ExpireAt, customer.Language)
+	row := cr.pool.QueryRow(ctx, query, customer.TelegramID, customer.ExpireAt, customer.Language, customer.Source)
 	result, err := scanCustomer(row)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find or create customer: %w", err)
@@ -241,6 +365,7 @@ func (cr *CustomerRepository) FindOrCreate(ctx context.Context, customer *Custom
 }
 
 func (cr *CustomerRepository) UpdateFields(ctx context.Context, id int64, updates map[string]interface{}) error {
+	defer cachedCustomers.invalidate(id)
 	if len(updates) == 0 {
 		return nil
 	}
@@ -250,6 +375,13 @@ func (cr *CustomerRepository) UpdateFields(ctx context.Context, id int64, update
 		Where(sq.Eq{"id": id})
 
 	for field, value := range updates {
+		if encryptedCustomerColumns[field] {
+			encrypted, err := encryptColumnValue(value)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt %s: %w", field, err)
+			}
+			value = encrypted
+		}
 		buildUpdate = buildUpdate.Set(field, value)
 	}
 
@@ -270,6 +402,8 @@ func (cr *CustomerRepository) UpdateFields(ctx context.Context, id int64, update
 	return nil
 }
 
+// FindByTelegramIds находит клиентов по Telegram ID, в том числе удалённых: синхронизация восстанавливает их,
+// когда пользователь снова появляется в панели
 func (cr *CustomerRepository) FindByTelegramIds(ctx context.Context, telegramIDs []int64) ([]Customer, error) {
 	buildSelect := sq.Select(customerColumns()...).
 		From("customer").
@@ -310,7 +444,11 @@ func (cr *CustomerRepository) CreateBatch(ctx context.Context, customers []Custo
 		Columns("telegram_id", "expire_at", "language", "subscription_link").
 		PlaceholderFormat(sq.Dollar)
 	for _, cust := range customers {
-		builder = builder.Values(cust.TelegramID, cust.ExpireAt, cust.Language, cust.SubscriptionLink)
+		link, err := fieldcrypt.EncryptPtr(cust.SubscriptionLink)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt subscription link: %w", err)
+		}
+		builder = builder.Values(cust.TelegramID, cust.ExpireAt, cust.Language, link)
 	}
 	sqlStr, args, err := builder.ToSql()
 	if err != nil {
@@ -326,17 +464,22 @@ func (cr *CustomerRepository) CreateBatch(ctx context.Context, customers []Custo
 }
 
 func (cr *CustomerRepository) UpdateBatch(ctx context.Context, customers []Customer) error {
+	defer cachedCustomers.invalidateAll()
 	if len(customers) == 0 {
 		return nil
 	}
-	query := "UPDATE customer SET expire_at = c.expire_at, subscription_link = c.subscription_link FROM (VALUES "
+	query := "UPDATE customer SET expire_at = c.expire_at, subscription_link = c.subscription_link, deleted_at = NULL FROM (VALUES "
 	var args []interface{}
 	for i, cust := range customers {
 		if i > 0 {
 			query += ", "
 		}
+		link, err := fieldcrypt.EncryptPtr(cust.SubscriptionLink)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt subscription link: %w", err)
+		}
 		query += fmt.Sprintf("($%d::bigint, $%d::timestamp, $%d::text)", i*3+1, i*3+2, i*3+3)
-		args = append(args, cust.TelegramID, cust.ExpireAt, cust.SubscriptionLink)
+		args = append(args, cust.TelegramID, cust.ExpireAt, link)
 	}
 	query += ") AS c(telegram_id, expire_at, subscription_link) WHERE customer.telegram_id = c.telegram_id"
 
@@ -348,33 +491,69 @@ func (cr *CustomerRepository) UpdateBatch(ctx context.Context, customers []Custo
 	return nil
 }
 
-func (cr *CustomerRepository) DeleteByNotInTelegramIds(ctx context.Context, telegramIDs []int64) error {
-	var buildDelete sq.DeleteBuilder
-	if len(telegramIDs) == 0 {
-		buildDelete = sq.Delete("customer")
-	} else {
-		buildDelete = sq.Delete("customer").
-			PlaceholderFormat(sq.Dollar).
-			Where(sq.NotEq{"telegram_id": telegramIDs})
+// SoftDeleteByNotInTelegramIds помечает удалёнными клиентов, которых нет в панели. История покупок сохраняется,
+// окончательно клиенты удаляются через PurgeDeleted. Возвращает число помеченных клиентов
+func (cr *CustomerRepository) SoftDeleteByNotInTelegramIds(ctx context.Context, telegramIDs []int64, deletedAt time.Time) (int64, error) {
+	defer cachedCustomers.invalidateAll()
+	// Обезличенных клиентов в панели нет, но их покупки нужны для отчётности
+	buildUpdate := sq.Update("customer").
+		Set("deleted_at", deletedAt).
+		PlaceholderFormat(sq.Dollar).
+		Where(sq.Eq{"anonymized_at": nil}).
+		Where(notDeleted)
+	if len(telegramIDs) > 0 {
+		buildUpdate = buildUpdate.Where(sq.NotEq{"telegram_id": telegramIDs})
+	}
+
+	sqlStr, args, err := buildUpdate.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build soft delete query: %w", err)
 	}
 
-	sqlStr, args, err := buildDelete.ToSql()
+	result, err := cr.pool.Exec(ctx, sqlStr, args...)
 	if err != nil {
-		return fmt.Errorf("failed to build delete query: %w", err)
+		return 0, fmt.Errorf("failed to soft delete customers: %w", err)
 	}
 
-	_, err = cr.pool.Exec(ctx, sqlStr, args...)
+	return result.RowsAffected(), nil
+}
+
+// DeletedStats возвращает число удалённых синхронизацией клиентов и сколько из них удалены раньше before
+func (cr *CustomerRepository) DeletedStats(ctx context.Context, before time.Time) (total, purgeable int, err error) {
+	err = cr.pool.QueryRow(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE deleted_at < $1)
+		FROM customer
+		WHERE deleted_at IS NOT NULL`, before).Scan(&total, &purgeable)
 	if err != nil {
-		return fmt.Errorf("failed to delete customers: %w", err)
+		return 0, 0, fmt.Errorf("failed to count deleted customers: %w", err)
 	}
+	return total, purgeable, nil
+}
 
-	return nil
+// PurgeDeleted окончательно удаляет клиентов, удалённых синхронизацией раньше before, вместе с их покупками
+func (cr *CustomerRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	defer cachedCustomers.invalidateAll()
+	sqlStr, args, err := sq.Delete("customer").
+		Where(sq.Lt{"deleted_at": before}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build purge query: %w", err)
+	}
 
+	result, err := cr.pool.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted customers: %w", err)
+	}
+	return result.RowsAffected(), nil
 }
 
+// FindAll возвращает всех не анонимизированных клиентов (аудитория рассылок). Читает из реплики, если она настроена
 func (cr *CustomerRepository) FindAll(ctx context.Context) ([]Customer, error) {
 	buildSelect := sq.Select(customerColumns()...).
 		From("customer").
+		Where(sq.Eq{"anonymized_at": nil}).
+		Where(notDeleted).
 		PlaceholderFormat(sq.Dollar)
 
 	sql, args, err := buildSelect.ToSql()
@@ -382,7 +561,7 @@ func (cr *CustomerRepository) FindAll(ctx context.Context) ([]Customer, error) {
 		return nil, fmt.Errorf("failed to build select query: %w", err)
 	}
 
-	rows, err := cr.pool.Query(ctx, sql, args...)
+	rows, err := cr.readPool.Query(ctx, sql, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query all customers: %w", err)
 	}
@@ -405,6 +584,7 @@ func (cr *CustomerRepository) FindAll(ctx context.Context) ([]Customer, error) {
 }
 
 func (cr *CustomerRepository) UpdateExpireAt(ctx context.Context, id int64, expireAt time.Time) error {
+	defer cachedCustomers.invalidate(id)
 	buildUpdate := sq.Update("customer").
 		Set("expire_at", expireAt).
 		Where(sq.Eq{"id": id}).
@@ -426,20 +606,14 @@ func (cr *CustomerRepository) UpdateExpireAt(ctx context.Context, id int64, expi
 // FindTrialUsersForInactiveNotification находит ТОЛЬКО триальных пользователей (без оплаченных покупок)
 // Условия: триал начался от 1 до 2 часов назад, уведомление ещё не отправлялось, НЕТ оплаченных покупок
 func (cr *CustomerRepository) FindTrialUsersForInactiveNotification(ctx context.Context) ([]Customer, error) {
-	now := time.Now()
+	now := clock.Now()
 	oneHourAgo := now.Add(-1 * time.Hour)
 	twoHoursAgo := now.Add(-2 * time.Hour)
 
 	// Используем raw SQL для LEFT JOIN — только пользователи БЕЗ оплаченных покупок (триальные)
 	// Окно: созданы от 1 до 2 часов назад (чтобы не спамить старым пользователям)
 	query := `
-		SELECT c.id, c.telegram_id, c.expire_at, c.created_at, c.subscription_link, c.language,
-			   c.trial_inactive_notified_at, c.winback_offer_sent_at, c.winback_offer_expires_at,
-			   c.winback_offer_price, c.winback_offer_devices, c.winback_offer_months,
-			   c.recurring_enabled, c.payment_method_id, c.recurring_tariff_name,
-			   c.recurring_months, c.recurring_amount, c.recurring_notified_at,
-			   c.promo_offer_price, c.promo_offer_devices, c.promo_offer_months,
-			   c.promo_offer_expires_at, c.promo_offer_code_id
+		SELECT ` + strings.Join(prefixedCustomerColumns("c"), ", ") + `
 		FROM customer c
 		LEFT JOIN purchase p ON p.customer_id = c.id AND p.status = 'paid'
 		WHERE c.expire_at IS NOT NULL
@@ -447,6 +621,7 @@ func (cr *CustomerRepository) FindTrialUsersForInactiveNotification(ctx context.
 		  AND c.created_at <= $2
 		  AND c.created_at >= $3
 		  AND c.trial_inactive_notified_at IS NULL
+		  AND c.deleted_at IS NULL
 		GROUP BY c.id
 		HAVING COUNT(p.id) = 0
 	`
@@ -476,25 +651,20 @@ func (cr *CustomerRepository) FindTrialUsersForInactiveNotification(ctx context.
 // FindExpiredTrialUsersForWinback находит ТОЛЬКО триальных пользователей (без оплаченных покупок) для winback
 // Условия: триал истёк от 24 до 48 часов назад, предложение ещё не отправлялось, НЕТ оплаченных покупок
 func (cr *CustomerRepository) FindExpiredTrialUsersForWinback(ctx context.Context) ([]Customer, error) {
-	now := time.Now()
+	now := clock.Now()
 	oneDayAgo := now.Add(-24 * time.Hour)
 	twoDaysAgo := now.Add(-48 * time.Hour)
 
 	// Используем raw SQL для LEFT JOIN — только пользователи БЕЗ оплаченных покупок (триальные)
 	query := `
-		SELECT c.id, c.telegram_id, c.expire_at, c.created_at, c.subscription_link, c.language,
-			   c.trial_inactive_notified_at, c.winback_offer_sent_at, c.winback_offer_expires_at,
-			   c.winback_offer_price, c.winback_offer_devices, c.winback_offer_months,
-			   c.recurring_enabled, c.payment_method_id, c.recurring_tariff_name,
-			   c.recurring_months, c.recurring_amount, c.recurring_notified_at,
-			   c.promo_offer_price, c.promo_offer_devices, c.promo_offer_months,
-			   c.promo_offer_expires_at, c.promo_offer_code_id
+		SELECT ` + strings.Join(prefixedCustomerColumns("c"), ", ") + `
 		FROM customer c
-		LEFT JOIN purchase p ON p.customer_id = c.id AND p.status = 'paid'
+		LEFT JOIN purchase_history p ON p.customer_id = c.id AND p.status = 'paid'
 		WHERE c.expire_at IS NOT NULL
 		  AND c.expire_at <= $1
 		  AND c.expire_at >= $2
 		  AND c.winback_offer_sent_at IS NULL
+		  AND c.deleted_at IS NULL
 		GROUP BY c.id
 		HAVING COUNT(p.id) = 0
 	`
@@ -523,6 +693,7 @@ func (cr *CustomerRepository) FindExpiredTrialUsersForWinback(ctx context.Contex
 
 // UpdateTrialInactiveNotifiedAt обновляет время отправки уведомления о неактивности
 func (cr *CustomerRepository) UpdateTrialInactiveNotifiedAt(ctx context.Context, id int64, notifiedAt time.Time) error {
+	defer cachedCustomers.invalidate(id)
 	buildUpdate := sq.Update("customer").
 		Set("trial_inactive_notified_at", notifiedAt).
 		Where(sq.Eq{"id": id}).
@@ -542,12 +713,16 @@ func (cr *CustomerRepository) UpdateTrialInactiveNotifiedAt(ctx context.Context,
 
 // UpdateWinbackOffer обновляет информацию о winback предложении
 func (cr *CustomerRepository) UpdateWinbackOffer(ctx context.Context, id int64, sentAt, expiresAt time.Time, price, devices, months int) error {
+	defer cachedCustomers.invalidate(id)
 	buildUpdate := sq.Update("customer").
 		Set("winback_offer_sent_at", sentAt).
 		Set("winback_offer_expires_at", expiresAt).
 		Set("winback_offer_price", price).
 		Set("winback_offer_devices", devices).
 		Set("winback_offer_months", months).
+		Set("winback_offer_tariff", nil).
+		Set("winback_offer_campaign", nil).
+		Set("winback_offer_reminded_at", nil).
 		Where(sq.Eq{"id": id}).
 		PlaceholderFormat(sq.Dollar)
 
@@ -569,6 +744,7 @@ func (cr *CustomerRepository) FindCustomersWithRecurringEnabled(ctx context.Cont
 		From("customer").
 		Where(sq.Eq{"recurring_enabled": true}).
 		Where(sq.NotEq{"payment_method_id": nil}).
+		Where(notDeleted).
 		PlaceholderFormat(sq.Dollar)
 
 	sql, args, err := buildSelect.ToSql()
@@ -598,14 +774,27 @@ func (cr *CustomerRepository) FindCustomersWithRecurringEnabled(ctx context.Cont
 	return customers, nil
 }
 
-// UpdateRecurringSettings обновляет настройки автопродления для пользователя
-func (cr *CustomerRepository) UpdateRecurringSettings(ctx context.Context, id int64, enabled bool, paymentMethodID *string, tariffName *string, months *int, amount *int) error {
+// UpdateRecurringSettings обновляет настройки автопродления для пользователя и фиксирует цену:
+// amount списывается при каждом продлении, listPrice и deviceLimit — снимок тарифа на момент подключения.
+// days задаётся для посуточного плана вместо months
+func (cr *CustomerRepository) UpdateRecurringSettings(ctx context.Context, id int64, enabled bool, paymentMethodID *string, tariffName *string, months *int, days *int, amount *int, listPrice *int, deviceLimit *int) error {
+	defer cachedCustomers.invalidate(id)
+	encryptedMethodID, err := fieldcrypt.EncryptPtr(paymentMethodID)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt payment method id: %w", err)
+	}
 	buildUpdate := sq.Update("customer").
 		Set("recurring_enabled", enabled).
-		Set("payment_method_id", paymentMethodID).
+		Set("payment_method_id", encryptedMethodID).
 		Set("recurring_tariff_name", tariffName).
 		Set("recurring_months", months).
+		Set("recurring_days", days).
 		Set("recurring_amount", amount).
+		Set("recurring_list_price", listPrice).
+		Set("recurring_device_limit", deviceLimit).
+		Set("recurring_locked_at", clock.Now()).
+		Set("recurring_bulk_disable_id", nil).
+		SetMap(clearPendingRecurringPrice()).
 		Where(sq.Eq{"id": id}).
 		PlaceholderFormat(sq.Dollar)
 
@@ -622,10 +811,13 @@ func (cr *CustomerRepository) UpdateRecurringSettings(ctx context.Context, id in
 }
 
 // DisableRecurring отключает автопродление, но сохраняет payment_method_id
-// Это позволяет пользователю легко включить автопродление обратно
+// Это позволяет пользователю легко включить автопродление обратно. Отмена массового отключения
+// после этого автопродление клиенту не включает
 func (cr *CustomerRepository) DisableRecurring(ctx context.Context, id int64) error {
+	defer cachedCustomers.invalidate(id)
 	buildUpdate := sq.Update("customer").
 		Set("recurring_enabled", false).
+		Set("recurring_bulk_disable_id", nil).
 		Where(sq.Eq{"id": id}).
 		PlaceholderFormat(sq.Dollar)
 
@@ -643,12 +835,15 @@ func (cr *CustomerRepository) DisableRecurring(ctx context.Context, id int64) er
 
 // DeletePaymentMethod удаляет сохранённый способ оплаты и отключает автопродление
 func (cr *CustomerRepository) DeletePaymentMethod(ctx context.Context, id int64) error {
+	defer cachedCustomers.invalidate(id)
 	buildUpdate := sq.Update("customer").
 		Set("recurring_enabled", false).
 		Set("payment_method_id", nil).
 		Set("recurring_amount", nil).
 		Set("recurring_months", nil).
+		Set("recurring_days", nil).
 		Set("recurring_tariff_name", nil).
+		SetMap(clearRecurringPriceLock).
 		Where(sq.Eq{"id": id}).
 		PlaceholderFormat(sq.Dollar)
 
@@ -666,6 +861,7 @@ func (cr *CustomerRepository) DeletePaymentMethod(ctx context.Context, id int64)
 
 // UpdateRecurringNotifiedAt обновляет время последнего уведомления о предстоящем списании
 func (cr *CustomerRepository) UpdateRecurringNotifiedAt(ctx context.Context, id int64, notifiedAt time.Time) error {
+	defer cachedCustomers.invalidate(id)
 	buildUpdate := sq.Update("customer").
 		Set("recurring_notified_at", notifiedAt).
 		Where(sq.Eq{"id": id}).
@@ -686,12 +882,14 @@ func (cr *CustomerRepository) UpdateRecurringNotifiedAt(ctx context.Context, id
 
 // UpdatePromoOffer обновляет информацию о promo tariff предложении
 func (cr *CustomerRepository) UpdatePromoOffer(ctx context.Context, id int64, price, devices, months int, expiresAt time.Time, codeID int64) error {
+	defer cachedCustomers.invalidate(id)
 	buildUpdate := sq.Update("customer").
 		Set("promo_offer_price", price).
 		Set("promo_offer_devices", devices).
 		Set("promo_offer_months", months).
 		Set("promo_offer_expires_at", expiresAt).
 		Set("promo_offer_code_id", codeID).
+		Set("promo_offer_reminded_at", nil).
 		Where(sq.Eq{"id": id}).
 		PlaceholderFormat(sq.Dollar)
 
@@ -707,14 +905,45 @@ func (cr *CustomerRepository) UpdatePromoOffer(ctx context.Context, id int64, pr
 	return nil
 }
 
+// AssignPromoOffer выставляет promo tariff предложение без промокода сразу нескольким клиентам
+// (предложение сегменту из админки). Возвращает число обновлённых клиентов
+func (cr *CustomerRepository) AssignPromoOffer(ctx context.Context, ids []int64, price, devices, months int, expiresAt time.Time) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	defer cachedCustomers.invalidateAll()
+	buildUpdate := sq.Update("customer").
+		Set("promo_offer_price", price).
+		Set("promo_offer_devices", devices).
+		Set("promo_offer_months", months).
+		Set("promo_offer_expires_at", expiresAt).
+		Set("promo_offer_code_id", nil).
+		Set("promo_offer_reminded_at", nil).
+		Where(sq.Eq{"id": ids}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := buildUpdate.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("failed to build assign promo offer query: %w", err)
+	}
+
+	result, err := cr.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to assign promo offer: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
 // ClearPromoOffer очищает promo tariff предложение после покупки
 func (cr *CustomerRepository) ClearPromoOffer(ctx context.Context, id int64) error {
+	defer cachedCustomers.invalidate(id)
 	buildUpdate := sq.Update("customer").
 		Set("promo_offer_price", nil).
 		Set("promo_offer_devices", nil).
 		Set("promo_offer_months", nil).
 		Set("promo_offer_expires_at", nil).
 		Set("promo_offer_code_id", nil).
+		Set("promo_offer_reminded_at", nil).
 		Where(sq.Eq{"id": id}).
 		PlaceholderFormat(sq.Dollar)
 
@@ -738,7 +967,7 @@ func HasActivePromoOffer(customer *Customer) bool {
 	if customer.PromoOfferPrice == nil || customer.PromoOfferExpiresAt == nil {
 		return false
 	}
-	return customer.PromoOfferExpiresAt.After(time.Now())
+	return customer.PromoOfferExpiresAt.After(clock.Now())
 }
 
 // HasActiveWinbackOffer проверяет, есть ли у пользователя активное winback предложение
@@ -751,7 +980,7 @@ func HasActiveWinbackOffer(customer *Customer) bool {
 	}
 	// Проверяем что предложение не истекло (если есть срок)
 	if customer.WinbackOfferExpiresAt != nil {
-		return customer.WinbackOfferExpiresAt.After(time.Now())
+		return customer.WinbackOfferExpiresAt.After(clock.Now())
 	}
 	return true
 }
@@ -760,22 +989,18 @@ func HasActiveWinbackOffer(customer *Customer) bool {
 // Условия: нет подписки (subscription_link IS NULL), нет expire_at, нет покупок
 func (cr *CustomerRepository) FindStartOnlyCustomers(ctx context.Context) ([]Customer, error) {
 	query := `
-		SELECT c.id, c.telegram_id, c.expire_at, c.created_at, c.subscription_link, c.language,
-			   c.trial_inactive_notified_at, c.winback_offer_sent_at, c.winback_offer_expires_at,
-			   c.winback_offer_price, c.winback_offer_devices, c.winback_offer_months,
-			   c.recurring_enabled, c.payment_method_id, c.recurring_tariff_name,
-			   c.recurring_months, c.recurring_amount, c.recurring_notified_at,
-			   c.promo_offer_price, c.promo_offer_devices, c.promo_offer_months,
-			   c.promo_offer_expires_at, c.promo_offer_code_id
+		SELECT ` + strings.Join(prefixedCustomerColumns("c"), ", ") + `
 		FROM customer c
 		LEFT JOIN purchase p ON p.customer_id = c.id
 		WHERE c.subscription_link IS NULL
 		  AND c.expire_at IS NULL
+		  AND c.anonymized_at IS NULL
+		  AND c.deleted_at IS NULL
 		GROUP BY c.id
 		HAVING COUNT(p.id) = 0
 	`
 
-	rows, err := cr.pool.Query(ctx, query)
+	rows, err := cr.readPool.Query(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query start-only customers: %w", err)
 	}
@@ -797,14 +1022,175 @@ func (cr *CustomerRepository) FindStartOnlyCustomers(ctx context.Context) ([]Cus
 	return customers, nil
 }
 
+// FindByTag возвращает клиентов с тегом поддержки tag (сегмент рассылки)
+func (cr *CustomerRepository) FindByTag(ctx context.Context, tag string) ([]Customer, error) {
+	query := `
+		SELECT ` + strings.Join(prefixedCustomerColumns("c"), ", ") + `
+		FROM customer c
+		JOIN customer_tag t ON t.customer_id = c.id
+		WHERE t.tag = $1
+		  AND c.deleted_at IS NULL
+	`
+
+	rows, err := cr.readPool.Query(ctx, query, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query customers by tag: %w", err)
+	}
+	defer rows.Close()
+
+	var customers []Customer
+	for rows.Next() {
+		customer, err := scanCustomerFromRows(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan customer row: %w", err)
+		}
+		customers = append(customers, *customer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over customer rows: %w", err)
+	}
+
+	return customers, nil
+}
+
+// FindByRemnawaveTag возвращает клиентов, которым бот выставил этот тег в панели
+func (cr *CustomerRepository) FindByRemnawaveTag(ctx context.Context, tag string) ([]Customer, error) {
+	buildSelect := sq.Select(customerColumns()...).
+		From("customer").
+		Where(sq.Eq{"remnawave_tag": tag}).
+		Where(notDeleted).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := buildSelect.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select query: %w", err)
+	}
+
+	rows, err := cr.readPool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query customers by remnawave tag: %w", err)
+	}
+	defer rows.Close()
+
+	var customers []Customer
+	for rows.Next() {
+		customer, err := scanCustomerFromRows(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan customer row: %w", err)
+		}
+		customers = append(customers, *customer)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over customer rows: %w", err)
+	}
+
+	return customers, nil
+}
+
+// ListRemnawaveTags возвращает теги панели, выставленные ботом, с числом клиентов, по убыванию числа
+func (cr *CustomerRepository) ListRemnawaveTags(ctx context.Context) ([]TagCount, error) {
+	sql, args, err := sq.Select("remnawave_tag", "COUNT(*)").
+		From("customer").
+		Where(sq.NotEq{"remnawave_tag": nil}).
+		Where(notDeleted).
+		GroupBy("remnawave_tag").
+		OrderBy("COUNT(*) DESC", "remnawave_tag").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select query: %w", err)
+	}
+
+	rows, err := cr.readPool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query remnawave tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []TagCount
+	for rows.Next() {
+		var t TagCount
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan remnawave tag: %w", err)
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// AcceptTos сохраняет принятую клиентом версию условий использования
+func (cr *CustomerRepository) AcceptTos(ctx context.Context, id int64, version string, acceptedAt time.Time) error {
+	defer cachedCustomers.invalidate(id)
+	buildUpdate := sq.Update("customer").
+		Set("tos_accepted_version", version).
+		Set("tos_accepted_at", acceptedAt).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := buildUpdate.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build accept tos query: %w", err)
+	}
+
+	_, err = cr.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("failed to accept tos: %w", err)
+	}
+	return nil
+}
+
+// ErrPhoneAlreadyUsed номер телефона уже подтверждён другим клиентом
+var ErrPhoneAlreadyUsed = errors.New("phone number already used by another customer")
+
+// IsPhoneVerified возвращает true если клиент подтвердил номер телефона для триала
+func (cr *CustomerRepository) IsPhoneVerified(ctx context.Context, id int64) (bool, error) {
+	var verified bool
+	err := cr.pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM customer_phone WHERE customer_id = $1)", id).Scan(&verified)
+	if err != nil {
+		return false, fmt.Errorf("failed to check phone verification: %w", err)
+	}
+	return verified, nil
+}
+
+// SavePhoneHash сохраняет хэш подтверждённого номера. Возвращает ErrPhoneAlreadyUsed,
+// если номер уже привязан к другому клиенту
+func (cr *CustomerRepository) SavePhoneHash(ctx context.Context, id int64, phoneHash string, verifiedAt time.Time) error {
+	defer cachedCustomers.invalidate(id)
+	var ownerID int64
+	err := cr.pool.QueryRow(ctx, "SELECT customer_id FROM customer_phone WHERE phone_hash = $1", phoneHash).Scan(&ownerID)
+	switch {
+	case err == nil && ownerID != id:
+		return ErrPhoneAlreadyUsed
+	case err == nil:
+		return nil
+	case !errors.Is(err, pgx.ErrNoRows):
+		return fmt.Errorf("failed to find phone owner: %w", err)
+	}
+
+	_, err = cr.pool.Exec(ctx, `
+		INSERT INTO customer_phone (customer_id, phone_hash, verified_at) VALUES ($1, $2, $3)
+		ON CONFLICT (customer_id) DO UPDATE SET phone_hash = EXCLUDED.phone_hash, verified_at = EXCLUDED.verified_at`,
+		id, phoneHash, verifiedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save phone hash: %w", err)
+	}
+	return nil
+}
+
 // ClearWinbackOffer очищает winback предложение после покупки
 func (cr *CustomerRepository) ClearWinbackOffer(ctx context.Context, id int64) error {
+	defer cachedCustomers.invalidate(id)
 	buildUpdate := sq.Update("customer").
 		Set("winback_offer_sent_at", nil).
 		Set("winback_offer_expires_at", nil).
 		Set("winback_offer_price", nil).
 		Set("winback_offer_devices", nil).
 		Set("winback_offer_months", nil).
+		Set("winback_offer_tariff", nil).
+		Set("winback_offer_campaign", nil).
+		Set("winback_offer_reminded_at", nil).
 		Where(sq.Eq{"id": id}).
 		PlaceholderFormat(sq.Dollar)
 
@@ -819,3 +1205,54 @@ func (cr *CustomerRepository) ClearWinbackOffer(ctx context.Context, id int64) e
 	}
 	return nil
 }
+
+// GetBalance возвращает внутренний баланс клиента в рублях
+func (cr *CustomerRepository) GetBalance(ctx context.Context, id int64) (int, error) {
+	var balance int
+	err := cr.pool.QueryRow(ctx, "SELECT balance FROM customer WHERE id = $1", id).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get balance: %w", err)
+	}
+	return balance, nil
+}
+
+// DebitBalance атомарно списывает с баланса клиента до limit рублей и возвращает списанную сумму
+func (cr *CustomerRepository) DebitBalance(ctx context.Context, id int64, limit int) (int, error) {
+	query := `
+		WITH old AS (SELECT id, balance FROM customer WHERE id = $2 FOR UPDATE)
+		UPDATE customer c SET balance = c.balance - LEAST(old.balance, $1)
+		FROM old
+		WHERE c.id = old.id
+		RETURNING LEAST(old.balance, $1)
+	`
+
+	var debited int
+	err := cr.pool.QueryRow(ctx, query, limit, id).Scan(&debited)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to debit balance: %w", err)
+	}
+	return debited, nil
+}
+
+// CreditBalance зачисляет amount рублей на баланс клиента
+func (cr *CustomerRepository) CreditBalance(ctx context.Context, id int64, amount int) error {
+	_, err := cr.pool.Exec(ctx, "UPDATE customer SET balance = balance + $1 WHERE id = $2", amount, id)
+	if err != nil {
+		return fmt.Errorf("failed to credit balance: %w", err)
+	}
+	return nil
+}
+
+// CreditBalanceByTelegramID зачисляет amount рублей на баланс клиента и возвращает новый баланс.
+// Возвращает pgx.ErrNoRows если клиент не найден
+func (cr *CustomerRepository) CreditBalanceByTelegramID(ctx context.Context, telegramID int64, amount int) (int, error) {
+	var balance int
+	err := cr.pool.QueryRow(ctx, "UPDATE customer SET balance = balance + $1 WHERE telegram_id = $2 RETURNING balance", amount, telegramID).Scan(&balance)
+	if err != nil {
+		return 0, fmt.Errorf("failed to credit balance: %w", err)
+	}
+	return balance, nil
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"sync"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+// customerCacheMaxEntries при таком размере кеша из него удаляются устаревшие записи
+const customerCacheMaxEntries = 10000
+
+// customerCache короткий кеш клиентов по Telegram ID для FindByTelegramId, который вызывается почти
+// на каждый update. Записи живут CUSTOMER_CACHE_TTL_MS (не больше секунды) и сбрасываются при каждой
+// записи клиента в этом экземпляре бота, поэтому другие экземпляры видят изменения не позже чем через TTL
+type customerCache struct {
+	mu           sync.Mutex
+	byTelegramID map[int64]customerCacheEntry
+	// telegramIDs id → telegram_id: запись по id сбрасывает кеш по Telegram ID
+	telegramIDs map[int64]int64
+	// generation растёт при каждом сбросе: клиент, прочитанный до сброса, в кеш не попадает
+	generation uint64
+	ttl        func() time.Duration
+}
+
+type customerCacheEntry struct {
+	customer  Customer
+	expiresAt time.Time
+}
+
+var cachedCustomers = newCustomerCache(config.CustomerCacheTTL)
+
+func newCustomerCache(ttl func() time.Duration) *customerCache {
+	return &customerCache{
+		byTelegramID: make(map[int64]customerCacheEntry),
+		telegramIDs:  make(map[int64]int64),
+		ttl:          ttl,
+	}
+}
+
+// get возвращает копию клиента из кеша. Выключенный кеш (TTL 0) всегда пуст
+func (c *customerCache) get(telegramID int64) (*Customer, bool) {
+	if c.ttl() <= 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.byTelegramID[telegramID]
+	if !ok || !clock.Now().Before(entry.expiresAt) {
+		return nil, false
+	}
+	customer := entry.customer
+	return &customer, true
+}
+
+// currentGeneration запоминается перед чтением из БД и передаётся в put
+func (c *customerCache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// put кладёт клиента в кеш, если с начала чтения (generation) кеш не сбрасывался
+func (c *customerCache) put(customer *Customer, generation uint64) {
+	ttl := c.ttl()
+	if ttl <= 0 || customer == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation != c.generation {
+		return
+	}
+	now := clock.Now()
+	if len(c.byTelegramID) >= customerCacheMaxEntries {
+		c.removeExpired(now)
+	}
+	c.byTelegramID[customer.TelegramID] = customerCacheEntry{customer: *customer, expiresAt: now.Add(ttl)}
+	c.telegramIDs[customer.ID] = customer.TelegramID
+}
+
+// invalidate сбрасывает клиента с этим id
+func (c *customerCache) invalidate(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if telegramID, ok := c.telegramIDs[id]; ok {
+		delete(c.byTelegramID, telegramID)
+		delete(c.telegramIDs, id)
+	}
+}
+
+// invalidateTelegramID сбрасывает клиента с этим Telegram ID
+func (c *customerCache) invalidateTelegramID(telegramID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if entry, ok := c.byTelegramID[telegramID]; ok {
+		delete(c.telegramIDs, entry.customer.ID)
+		delete(c.byTelegramID, telegramID)
+	}
+}
+
+// invalidateAll сбрасывает весь кеш: для записей, меняющих сразу много клиентов
+func (c *customerCache) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.byTelegramID = make(map[int64]customerCacheEntry)
+	c.telegramIDs = make(map[int64]int64)
+}
+
+func (c *customerCache) removeExpired(now time.Time) {
+	for telegramID, entry := range c.byTelegramID {
+		if !now.Before(entry.expiresAt) {
+			delete(c.telegramIDs, entry.customer.ID)
+			delete(c.byTelegramID, telegramID)
+		}
+	}
+}
+
+// InvalidateCustomerCache сбрасывает закешированного клиента с этим Telegram ID. Вызывается при событиях
+// Remnawave, после которых клиент должен сразу увидеть актуальное состояние
+func InvalidateCustomerCache(telegramID int64) {
+	cachedCustomers.invalidateTelegramID(telegramID)
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/clock"
+)
+
+func newTestCustomerCache(t *testing.T, ttl time.Duration) (*customerCache, *clock.Fake) {
+	t.Helper()
+	fake := clock.NewFake(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	clock.SetDefault(fake)
+	t.Cleanup(func() { clock.SetDefault(nil) })
+	return newCustomerCache(func() time.Duration { return ttl }), fake
+}
+
+func TestCustomerCacheExpiresAfterTTL(t *testing.T) {
+	cache, fake := newTestCustomerCache(t, time.Second)
+	cache.put(&Customer{ID: 7, TelegramID: 42, Language: "ru"}, cache.currentGeneration())
+
+	got, ok := cache.get(42)
+	if !ok || got.ID != 7 {
+		t.Fatalf("Expected cached customer 7, got %+v (ok %v)", got, ok)
+	}
+	got.Language = "en"
+	if again, _ := cache.get(42); again.Language != "ru" {
+		t.Error("Changing a returned customer must not change the cache")
+	}
+
+	fake.Advance(time.Second)
+	if _, ok := cache.get(42); ok {
+		t.Error("Expected customer to expire after the TTL")
+	}
+}
+
+func TestCustomerCacheInvalidation(t *testing.T) {
+	cache, _ := newTestCustomerCache(t, time.Second)
+	cache.put(&Customer{ID: 7, TelegramID: 42}, cache.currentGeneration())
+	cache.put(&Customer{ID: 8, TelegramID: 43}, cache.currentGeneration())
+
+	cache.invalidate(7)
+	if _, ok := cache.get(42); ok {
+		t.Error("Expected write by id to drop the customer")
+	}
+	if _, ok := cache.get(43); !ok {
+		t.Error("Expected other customers to stay cached")
+	}
+
+	cache.invalidateTelegramID(43)
+	if _, ok := cache.get(43); ok {
+		t.Error("Expected webhook invalidation to drop the customer")
+	}
+
+	cache.put(&Customer{ID: 8, TelegramID: 43}, cache.currentGeneration())
+	cache.invalidateAll()
+	if _, ok := cache.get(43); ok {
+		t.Error("Expected bulk write to drop all customers")
+	}
+}
+
+// Клиент, прочитанный из БД до записи, не должен попасть в кеш после сброса
+func TestCustomerCacheSkipsReadsStartedBeforeInvalidation(t *testing.T) {
+	cache, _ := newTestCustomerCache(t, time.Second)
+	generation := cache.currentGeneration()
+	cache.invalidate(7)
+	cache.put(&Customer{ID: 7, TelegramID: 42}, generation)
+	if _, ok := cache.get(42); ok {
+		t.Error("Expected stale read to be dropped")
+	}
+}
+
+func TestCustomerCacheDisabled(t *testing.T) {
+	cache, _ := newTestCustomerCache(t, 0)
+	cache.put(&Customer{ID: 7, TelegramID: 42}, cache.currentGeneration())
+	if _, ok := cache.get(42); ok {
+		t.Error("Expected no caching with zero TTL")
+	}
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// maxTagLength соответствует размеру колонки customer_tag.tag
+const maxTagLength = 32
+
+// tagInvalidChars теги только латиницей: тег попадает в callback_data рассылки (лимит 64 байта)
+var tagInvalidChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// NormalizeTag приводит тег к единому виду: нижний регистр, пробелы заменяются на "_",
+// остальные символы кроме латиницы, цифр, "_" и "-" отбрасываются. Пустая строка — тег недопустим
+func NormalizeTag(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	tag = strings.Join(strings.Fields(tag), "_")
+	tag = tagInvalidChars.ReplaceAllString(tag, "")
+	if len(tag) > maxTagLength {
+		tag = tag[:maxTagLength]
+	}
+	return tag
+}
+
+// CustomerNote свободная заметка поддержки по клиенту
+type CustomerNote struct {
+	CustomerID int64     `db:"customer_id"`
+	Note       string    `db:"note"`
+	UpdatedBy  int64     `db:"updated_by"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+// TagCount тег и число клиентов с ним
+type TagCount struct {
+	Tag   string
+	Count int
+}
+
+// CustomerNoteRepository хранит заметки и теги поддержки по клиентам
+type CustomerNoteRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewCustomerNoteRepository(pool *pgxpool.Pool) *CustomerNoteRepository {
+	return &CustomerNoteRepository{pool: pool}
+}
+
+// GetTags возвращает теги клиента в алфавитном порядке
+func (r *CustomerNoteRepository) GetTags(ctx context.Context, customerID int64) ([]string, error) {
+	query := sq.Select("tag").
+		From("customer_tag").
+		Where(sq.Eq{"customer_id": customerID}).
+		OrderBy("tag").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query customer tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("scan customer tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// AddTag добавляет тег клиенту. Возвращает false если тег уже был
+func (r *CustomerNoteRepository) AddTag(ctx context.Context, customerID int64, tag string) (bool, error) {
+	query := sq.Insert("customer_tag").
+		Columns("customer_id", "tag").
+		Values(customerID, tag).
+		Suffix("ON CONFLICT DO NOTHING").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return false, fmt.Errorf("build query: %w", err)
+	}
+
+	result, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("insert customer tag: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// RemoveTag снимает тег с клиента. Возвращает false если тега не было
+func (r *CustomerNoteRepository) RemoveTag(ctx context.Context, customerID int64, tag string) (bool, error) {
+	query := sq.Delete("customer_tag").
+		Where(sq.Eq{"customer_id": customerID, "tag": tag}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return false, fmt.Errorf("build query: %w", err)
+	}
+
+	result, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("delete customer tag: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// ListTags возвращает все используемые теги с числом клиентов, самые частые первыми
+func (r *CustomerNoteRepository) ListTags(ctx context.Context) ([]TagCount, error) {
+	query := sq.Select("tag", "COUNT(*)").
+		From("customer_tag").
+		GroupBy("tag").
+		OrderBy("COUNT(*) DESC", "tag").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []TagCount
+	for rows.Next() {
+		var t TagCount
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+			return nil, fmt.Errorf("scan tag: %w", err)
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// GetNote возвращает заметку по клиенту или nil если её нет
+func (r *CustomerNoteRepository) GetNote(ctx context.Context, customerID int64) (*CustomerNote, error) {
+	query := sq.Select("customer_id", "note", "updated_by", "updated_at").
+		From("customer_note").
+		Where(sq.Eq{"customer_id": customerID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	var note CustomerNote
+	err = r.pool.QueryRow(ctx, sql, args...).Scan(&note.CustomerID, &note.Note, &note.UpdatedBy, &note.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query customer note: %w", err)
+	}
+	return &note, nil
+}
+
+// SetNote сохраняет заметку по клиенту, заменяя предыдущую
+func (r *CustomerNoteRepository) SetNote(ctx context.Context, customerID int64, note string, adminID int64) error {
+	query := sq.Insert("customer_note").
+		Columns("customer_id", "note", "updated_by", "updated_at").
+		Values(customerID, note, adminID, time.Now()).
+		Suffix("ON CONFLICT (customer_id) DO UPDATE SET note = EXCLUDED.note, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("upsert customer note: %w", err)
+	}
+	return nil
+}
+
+// DeleteNote удаляет заметку по клиенту. Возвращает false если заметки не было
+func (r *CustomerNoteRepository) DeleteNote(ctx context.Context, customerID int64) (bool, error) {
+	query := sq.Delete("customer_note").
+		Where(sq.Eq{"customer_id": customerID}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return false, fmt.Errorf("build query: %w", err)
+	}
+
+	result, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("delete customer note: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
@@ -0,0 +1,19 @@
+package database
+
+import "testing"
+
+func TestNormalizeTag(t *testing.T) {
+	tests := map[string]string{
+		"VIP":                                  "vip",
+		"  chargeback risk ":                   "chargeback_risk",
+		"refund-2025":                          "refund-2025",
+		"тег!":                                 "",
+		"#promo":                               "promo",
+		"a_very_long_tag_name_that_exceeds_32": "a_very_long_tag_name_that_exceed",
+	}
+	for input, want := range tests {
+		if got := NormalizeTag(input); got != want {
+			t.Errorf("NormalizeTag(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
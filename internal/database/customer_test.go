@@ -6,6 +6,7 @@ import (
 	"testing/quick"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 // **Feature: recurring-payments, Property 2: Payment method persistence**
@@ -366,3 +367,47 @@ func TestManualRecurringDisableIdempotent(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestHasAcceptedTos(t *testing.T) {
+	customer := &Customer{}
+	if customer.HasAcceptedTos("1") {
+		t.Error("Expected customer without acceptance to need tos")
+	}
+
+	version := "1"
+	customer.TosAcceptedVersion = &version
+	if !customer.HasAcceptedTos("1") {
+		t.Error("Expected accepted version to be recognized")
+	}
+	if customer.HasAcceptedTos("2") {
+		t.Error("Expected bumped tos version to require acceptance again")
+	}
+}
+
+func TestPrefixedCustomerColumns(t *testing.T) {
+	columns := customerColumns()
+	prefixed := prefixedCustomerColumns("c")
+	if len(prefixed) != len(columns) {
+		t.Fatalf("Expected %d columns, got %d", len(columns), len(prefixed))
+	}
+	for i, column := range columns {
+		if prefixed[i] != "c."+column {
+			t.Errorf("Expected c.%s, got %s", column, prefixed[i])
+		}
+	}
+}
+
+func TestCustomerRepositorySetReadPool(t *testing.T) {
+	primary, replica := &pgxpool.Pool{}, &pgxpool.Pool{}
+
+	repo := NewCustomerRepository(primary)
+	repo.SetReadPool(nil)
+	if repo.readPool != primary {
+		t.Error("Without replica analytical queries must use the primary pool")
+	}
+
+	repo.SetReadPool(replica)
+	if repo.readPool != replica || repo.pool != primary {
+		t.Error("Replica must be used only for analytical queries")
+	}
+}
@@ -0,0 +1,246 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type FailedFulfillmentStatus string
+
+const (
+	// FailedFulfillmentStatusPending временная ошибка: выдача будет повторена автоматически
+	FailedFulfillmentStatusPending FailedFulfillmentStatus = "pending"
+	// FailedFulfillmentStatusFailed попытки исчерпаны или ошибка не временная: нужен повтор из админки
+	FailedFulfillmentStatusFailed FailedFulfillmentStatus = "failed"
+	// FailedFulfillmentStatusResolved покупка выдана повторной попыткой
+	FailedFulfillmentStatusResolved FailedFulfillmentStatus = "resolved"
+)
+
+// FailedFulfillment оплаченная покупка, выдача которой завершилась ошибкой
+type FailedFulfillment struct {
+	ID            int64                   `db:"id"`
+	PurchaseID    int64                   `db:"purchase_id"`
+	Source        string                  `db:"source"`
+	Payload       []byte                  `db:"payload"`
+	Status        FailedFulfillmentStatus `db:"status"`
+	Attempts      int                     `db:"attempts"`
+	LastError     string                  `db:"last_error"`
+	NextAttemptAt *time.Time              `db:"next_attempt_at"`
+	CreatedAt     time.Time               `db:"created_at"`
+	UpdatedAt     time.Time               `db:"updated_at"`
+	ResolvedAt    *time.Time              `db:"resolved_at"`
+}
+
+func failedFulfillmentColumns() []string {
+	return []string{
+		"id", "purchase_id", "source", "payload", "status", "attempts",
+		"last_error", "next_attempt_at", "created_at", "updated_at", "resolved_at",
+	}
+}
+
+func scanFailedFulfillment(row pgx.Row) (*FailedFulfillment, error) {
+	var f FailedFulfillment
+	err := row.Scan(
+		&f.ID, &f.PurchaseID, &f.Source, &f.Payload, &f.Status, &f.Attempts,
+		&f.LastError, &f.NextAttemptAt, &f.CreatedAt, &f.UpdatedAt, &f.ResolvedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+type FailedFulfillmentRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewFailedFulfillmentRepository(pool *pgxpool.Pool) *FailedFulfillmentRepository {
+	return &FailedFulfillmentRepository{pool: pool}
+}
+
+// Record сохраняет ошибку выдачи покупки. Если по покупке уже есть открытая запись, обновляются только ошибка
+// и данные провайдера: расписанием повторов и счётчиком попыток управляет задача повтора.
+// nextAttemptAt nil — запись сразу попадает в failed. Возвращает created=false, если запись уже была
+func (r *FailedFulfillmentRepository) Record(ctx context.Context, purchaseID int64, source string, payload []byte, lastError string, nextAttemptAt *time.Time, at time.Time) (id int64, created bool, err error) {
+	status := FailedFulfillmentStatusFailed
+	if nextAttemptAt != nil {
+		status = FailedFulfillmentStatusPending
+	}
+	var payloadArg interface{}
+	if len(payload) > 0 {
+		payloadArg = string(payload)
+	}
+
+	err = r.pool.QueryRow(ctx, `
+		INSERT INTO failed_fulfillment (purchase_id, source, payload, status, attempts, last_error, next_attempt_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, 1, $5, $6, $7, $7)
+		ON CONFLICT (purchase_id) WHERE status <> 'resolved' DO UPDATE SET
+			last_error = EXCLUDED.last_error,
+			payload = COALESCE(EXCLUDED.payload, failed_fulfillment.payload),
+			updated_at = EXCLUDED.updated_at
+		RETURNING id, xmax = 0`,
+		purchaseID, source, payloadArg, status, lastError, nextAttemptAt, at).Scan(&id, &created)
+	if err != nil {
+		return 0, false, fmt.Errorf("record failed fulfillment: %w", err)
+	}
+	return id, created, nil
+}
+
+// FindByID возвращает запись по ID или nil
+func (r *FailedFulfillmentRepository) FindByID(ctx context.Context, id int64) (*FailedFulfillment, error) {
+	query := sq.Select(failedFulfillmentColumns()...).
+		From("failed_fulfillment").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	f, err := scanFailedFulfillment(r.pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("query failed fulfillment: %w", err)
+	}
+	return f, nil
+}
+
+// FindDue возвращает записи, время автоматического повтора которых наступило
+func (r *FailedFulfillmentRepository) FindDue(ctx context.Context, now time.Time, limit int) ([]FailedFulfillment, error) {
+	query := sq.Select(failedFulfillmentColumns()...).
+		From("failed_fulfillment").
+		Where(sq.And{
+			sq.Eq{"status": FailedFulfillmentStatusPending},
+			sq.LtOrEq{"next_attempt_at": now},
+		}).
+		OrderBy("next_attempt_at ASC").
+		Limit(uint64(limit)).
+		PlaceholderFormat(sq.Dollar)
+
+	return r.query(ctx, query)
+}
+
+// FindOpen возвращает нерешённые записи, новые первыми
+func (r *FailedFulfillmentRepository) FindOpen(ctx context.Context, limit int) ([]FailedFulfillment, error) {
+	query := sq.Select(failedFulfillmentColumns()...).
+		From("failed_fulfillment").
+		Where(sq.NotEq{"status": FailedFulfillmentStatusResolved}).
+		OrderBy("created_at DESC", "id DESC").
+		Limit(uint64(limit)).
+		PlaceholderFormat(sq.Dollar)
+
+	return r.query(ctx, query)
+}
+
+func (r *FailedFulfillmentRepository) query(ctx context.Context, query sq.SelectBuilder) ([]FailedFulfillment, error) {
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed fulfillments: %w", err)
+	}
+	defer rows.Close()
+
+	var result []FailedFulfillment
+	for rows.Next() {
+		f, err := scanFailedFulfillment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan failed fulfillment: %w", err)
+		}
+		result = append(result, *f)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+	return result, nil
+}
+
+// ScheduleRetry сохраняет ошибку очередной попытки и время следующей
+func (r *FailedFulfillmentRepository) ScheduleRetry(ctx context.Context, id int64, attempts int, lastError string, nextAttemptAt, at time.Time) error {
+	return r.update(ctx, id, map[string]interface{}{
+		"status":          FailedFulfillmentStatusPending,
+		"attempts":        attempts,
+		"last_error":      lastError,
+		"next_attempt_at": nextAttemptAt,
+		"updated_at":      at,
+	})
+}
+
+// MarkFailed останавливает автоматические повторы: дальше запись повторяется только из админки
+func (r *FailedFulfillmentRepository) MarkFailed(ctx context.Context, id int64, attempts int, lastError string, at time.Time) error {
+	return r.update(ctx, id, map[string]interface{}{
+		"status":          FailedFulfillmentStatusFailed,
+		"attempts":        attempts,
+		"last_error":      lastError,
+		"next_attempt_at": nil,
+		"updated_at":      at,
+	})
+}
+
+// MarkResolved помечает запись решённой после успешной выдачи
+func (r *FailedFulfillmentRepository) MarkResolved(ctx context.Context, id int64, attempts int, at time.Time) error {
+	return r.update(ctx, id, map[string]interface{}{
+		"status":          FailedFulfillmentStatusResolved,
+		"attempts":        attempts,
+		"next_attempt_at": nil,
+		"updated_at":      at,
+		"resolved_at":     at,
+	})
+}
+
+// ResolveByPurchase закрывает открытую запись покупки, выданной другим путём (например, опросом провайдера).
+// Возвращает false, если открытой записи не было
+func (r *FailedFulfillmentRepository) ResolveByPurchase(ctx context.Context, purchaseID int64, at time.Time) (bool, error) {
+	query := sq.Update("failed_fulfillment").
+		Set("status", FailedFulfillmentStatusResolved).
+		Set("next_attempt_at", nil).
+		Set("updated_at", at).
+		Set("resolved_at", at).
+		Where(sq.And{
+			sq.Eq{"purchase_id": purchaseID},
+			sq.NotEq{"status": FailedFulfillmentStatusResolved},
+		}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return false, fmt.Errorf("build query: %w", err)
+	}
+
+	result, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("resolve failed fulfillment: %w", err)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func (r *FailedFulfillmentRepository) update(ctx context.Context, id int64, updates map[string]interface{}) error {
+	query := sq.Update("failed_fulfillment").
+		SetMap(updates).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+
+	_, err = r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("update failed fulfillment: %w", err)
+	}
+	return nil
+}
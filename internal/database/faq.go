@@ -0,0 +1,220 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// FaqEntry вопрос и ответ раздела FAQ
+type FaqEntry struct {
+	ID        int64
+	Language  string
+	Category  string
+	Question  string
+	Answer    string
+	IsActive  bool
+	CreatedBy *int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// FaqCategory категория FAQ. EntryID — первый вопрос категории, по нему категория открывается из кнопки:
+// название категории может не поместиться в callback data
+type FaqCategory struct {
+	Name    string
+	EntryID int64
+	Count   int
+}
+
+var faqColumns = []string{"id", "language", "category", "question", "answer", "is_active", "created_by", "created_at", "updated_at"}
+
+type FaqRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewFaqRepository(pool *pgxpool.Pool) *FaqRepository {
+	return &FaqRepository{pool: pool}
+}
+
+// Categories возвращает категории с включёнными вопросами на языке lang по алфавиту
+func (r *FaqRepository) Categories(ctx context.Context, lang string) ([]FaqCategory, error) {
+	sql, args, err := sq.Select("category", "MIN(id)", "COUNT(*)").
+		From("faq_entry").
+		Where(sq.Eq{"language": lang, "is_active": true}).
+		GroupBy("category").
+		OrderBy("category").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query faq categories: %w", err)
+	}
+	defer rows.Close()
+
+	var categories []FaqCategory
+	for rows.Next() {
+		var c FaqCategory
+		if err := rows.Scan(&c.Name, &c.EntryID, &c.Count); err != nil {
+			return nil, fmt.Errorf("scan faq category: %w", err)
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+// ListCategory возвращает включённые вопросы категории в порядке добавления
+func (r *FaqRepository) ListCategory(ctx context.Context, lang, category string) ([]FaqEntry, error) {
+	return r.list(ctx, sq.Select(faqColumns...).
+		From("faq_entry").
+		Where(sq.Eq{"language": lang, "category": category, "is_active": true}).
+		OrderBy("id"))
+}
+
+// Search ищет включённые вопросы на языке lang, в вопросе или ответе которых есть все слова запроса
+func (r *FaqRepository) Search(ctx context.Context, lang, query string, limit int) ([]FaqEntry, error) {
+	return r.list(ctx, buildFaqSearchQuery(lang, query, limit))
+}
+
+func buildFaqSearchQuery(lang, query string, limit int) sq.SelectBuilder {
+	conditions := sq.And{sq.Eq{"language": lang, "is_active": true}}
+	for _, word := range strings.Fields(query) {
+		conditions = append(conditions, sq.Expr("(question || ' ' || answer) ILIKE ?", "%"+escapeLike(word)+"%"))
+	}
+	return sq.Select(faqColumns...).
+		From("faq_entry").
+		Where(conditions).
+		OrderBy("category", "id").
+		Limit(uint64(limit))
+}
+
+// escapeLike экранирует спецсимволы шаблона LIKE, чтобы они искались как обычные символы
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// List возвращает все вопросы, включая выключенные, для админки
+func (r *FaqRepository) List(ctx context.Context, limit, offset int) ([]FaqEntry, error) {
+	return r.list(ctx, sq.Select(faqColumns...).
+		From("faq_entry").
+		OrderBy("language", "category", "id").
+		Limit(uint64(limit)).
+		Offset(uint64(offset)))
+}
+
+func (r *FaqRepository) list(ctx context.Context, query sq.SelectBuilder) ([]FaqEntry, error) {
+	sql, args, err := query.PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query faq entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []FaqEntry
+	for rows.Next() {
+		entry, err := scanFaqEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan faq entry: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// FindByID возвращает вопрос или nil, если его нет
+func (r *FaqRepository) FindByID(ctx context.Context, id int64) (*FaqEntry, error) {
+	sql, args, err := sq.Select(faqColumns...).
+		From("faq_entry").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	entry, err := scanFaqEntry(r.pool.QueryRow(ctx, sql, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query faq entry: %w", err)
+	}
+	return entry, nil
+}
+
+// Create добавляет вопрос
+func (r *FaqRepository) Create(ctx context.Context, entry FaqEntry) (*FaqEntry, error) {
+	now := time.Now()
+	sql, args, err := sq.Insert("faq_entry").
+		Columns("language", "category", "question", "answer", "created_by", "created_at", "updated_at").
+		Values(entry.Language, entry.Category, entry.Question, entry.Answer, entry.CreatedBy, now, now).
+		Suffix("RETURNING " + strings.Join(faqColumns, ", ")).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	created, err := scanFaqEntry(r.pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		return nil, fmt.Errorf("insert faq entry: %w", err)
+	}
+	return created, nil
+}
+
+// SetActive включает или выключает вопрос
+func (r *FaqRepository) SetActive(ctx context.Context, id int64, active bool) error {
+	sql, args, err := sq.Update("faq_entry").
+		Set("is_active", active).
+		Set("updated_at", time.Now()).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("update faq entry: %w", err)
+	}
+	return nil
+}
+
+// Delete удаляет вопрос
+func (r *FaqRepository) Delete(ctx context.Context, id int64) error {
+	sql, args, err := sq.Delete("faq_entry").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("delete faq entry: %w", err)
+	}
+	return nil
+}
+
+func scanFaqEntry(row pgx.Row) (*FaqEntry, error) {
+	var e FaqEntry
+	err := row.Scan(&e.ID, &e.Language, &e.Category, &e.Question, &e.Answer, &e.IsActive, &e.CreatedBy, &e.CreatedAt, &e.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+func TestBuildFaqSearchQuery(t *testing.T) {
+	sql, args, err := buildFaqSearchQuery("ru", "  iPhone 100%_ ", 10).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"FROM faq_entry",
+		"(question || ' ' || answer) ILIKE $3",
+		"(question || ' ' || answer) ILIKE $4",
+		"ORDER BY category, id",
+		"LIMIT 10",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("SQL does not contain %q: %s", want, sql)
+		}
+	}
+	if want := []interface{}{true, "ru", "%iPhone%", `%100\%\_%`}; !reflect.DeepEqual(args, want) {
+		t.Fatalf("unexpected args, want %v, got %v", want, args)
+	}
+}
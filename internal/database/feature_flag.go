@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// FeatureFlagRepository хранит переопределения фич, заданные админом
+type FeatureFlagRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewFeatureFlagRepository(pool *pgxpool.Pool) *FeatureFlagRepository {
+	return &FeatureFlagRepository{pool: pool}
+}
+
+// GetAll возвращает все переопределения: имя фичи → включена ли она
+func (r *FeatureFlagRepository) GetAll(ctx context.Context) (map[string]bool, error) {
+	query := sq.Select("name", "enabled").
+		From("feature_flag").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query feature flags: %w", err)
+	}
+	defer rows.Close()
+
+	flags := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		var enabled bool
+		if err := rows.Scan(&name, &enabled); err != nil {
+			return nil, fmt.Errorf("scan feature flag: %w", err)
+		}
+		flags[name] = enabled
+	}
+	return flags, rows.Err()
+}
+
+// Set сохраняет переопределение фичи
+func (r *FeatureFlagRepository) Set(ctx context.Context, name string, enabled bool, adminID int64) error {
+	query := sq.Insert("feature_flag").
+		Columns("name", "enabled", "updated_by", "updated_at").
+		Values(name, enabled, adminID, time.Now()).
+		Suffix("ON CONFLICT (name) DO UPDATE SET enabled = EXCLUDED.enabled, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("upsert feature flag: %w", err)
+	}
+	return nil
+}
+
+// Delete снимает переопределение фичи
+func (r *FeatureFlagRepository) Delete(ctx context.Context, name string) error {
+	query := sq.Delete("feature_flag").
+		Where(sq.Eq{"name": name}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+
+	if _, err := r.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("delete feature flag: %w", err)
+	}
+	return nil
+}
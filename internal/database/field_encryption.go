@@ -0,0 +1,143 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"remnawave-tg-shop-bot/internal/fieldcrypt"
+)
+
+// encryptedColumn колонка, значения которой хранятся зашифрованными (fieldcrypt)
+type encryptedColumn struct {
+	table  string
+	column string
+}
+
+var encryptedColumns = []encryptedColumn{
+	{table: "customer", column: "subscription_link"},
+	{table: "customer", column: "payment_method_id"},
+	{table: "customer", column: "email"},
+	{table: "payment_method", column: "method_id"},
+}
+
+// encryptedCustomerColumns колонки customer, которые UpdateFields шифрует перед записью
+var encryptedCustomerColumns = map[string]bool{
+	"subscription_link": true,
+	"payment_method_id": true,
+	"email":             true,
+}
+
+// encryptColumnValue шифрует значение колонки из encryptedColumns (string, *string или nil)
+func encryptColumnValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return fieldcrypt.Encrypt(v)
+	case *string:
+		return fieldcrypt.EncryptPtr(v)
+	default:
+		return nil, fmt.Errorf("unsupported type %T for encrypted column", value)
+	}
+}
+
+// decryptCustomer расшифровывает колонки клиента, прочитанные из БД
+func decryptCustomer(c *Customer) error {
+	if err := fieldcrypt.DecryptInPlace(c.SubscriptionLink); err != nil {
+		return fmt.Errorf("decrypt subscription_link: %w", err)
+	}
+	if err := fieldcrypt.DecryptInPlace(c.PaymentMethodID); err != nil {
+		return fmt.Errorf("decrypt payment_method_id: %w", err)
+	}
+	if err := fieldcrypt.DecryptInPlace(c.Email); err != nil {
+		return fmt.Errorf("decrypt email: %w", err)
+	}
+	return nil
+}
+
+type FieldEncryptionRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewFieldEncryptionRepository(pool *pgxpool.Pool) *FieldEncryptionRepository {
+	return &FieldEncryptionRepository{pool: pool}
+}
+
+// EncryptAll шифрует текущим ключом значения, сохранённые открытым текстом или прежним ключом, пачками по batchSize.
+// Возвращает количество перешифрованных значений
+func (r *FieldEncryptionRepository) EncryptAll(ctx context.Context, keyring *fieldcrypt.Keyring, batchSize int) (int64, error) {
+	var total int64
+	for _, col := range encryptedColumns {
+		for {
+			n, err := r.encryptBatch(ctx, keyring, col, batchSize)
+			total += int64(n)
+			if err != nil {
+				return total, fmt.Errorf("encrypt %s.%s: %w", col.table, col.column, err)
+			}
+			if n < batchSize {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+func (r *FieldEncryptionRepository) encryptBatch(ctx context.Context, keyring *fieldcrypt.Keyring, col encryptedColumn, batchSize int) (int, error) {
+	updated := 0
+	err := r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, buildPendingEncryptionQuery(col), keyring.CurrentPrefix(), batchSize)
+		if err != nil {
+			return err
+		}
+		type pending struct {
+			id    int64
+			value string
+		}
+		var batch []pending
+		for rows.Next() {
+			var p pending
+			if err := rows.Scan(&p.id, &p.value); err != nil {
+				rows.Close()
+				return err
+			}
+			batch = append(batch, p)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, p := range batch {
+			plain, err := keyring.Decrypt(p.value)
+			if err != nil {
+				return fmt.Errorf("row %d: %w", p.id, err)
+			}
+			encrypted, err := keyring.Encrypt(plain)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, fmt.Sprintf("UPDATE %s SET %s = $2 WHERE id = $1", col.table, col.column), p.id, encrypted); err != nil {
+				return err
+			}
+		}
+		updated = len(batch)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+// buildPendingEncryptionQuery выбирает значения, не зашифрованные текущим ключом ($1 — его префикс).
+// Сравнение через left, а не LIKE: в id ключа может быть "_"
+func buildPendingEncryptionQuery(col encryptedColumn) string {
+	return fmt.Sprintf(`SELECT id, %[2]s FROM %[1]s
+		WHERE %[2]s IS NOT NULL AND left(%[2]s, length($1)) <> $1
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`, col.table, col.column)
+}
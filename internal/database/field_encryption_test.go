@@ -0,0 +1,60 @@
+package database
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/fieldcrypt"
+)
+
+func testFieldKeyring(t *testing.T, spec string) *fieldcrypt.Keyring {
+	t.Helper()
+	keyring, err := fieldcrypt.ParseKeys(spec)
+	if err != nil {
+		t.Fatalf("ParseKeys() returned error: %v", err)
+	}
+	fieldcrypt.SetDefault(keyring)
+	t.Cleanup(func() { fieldcrypt.SetDefault(nil) })
+	return keyring
+}
+
+func testFieldKey(id string, b byte) string {
+	return id + ":" + base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune(b)), 32)))
+}
+
+func TestEncryptColumnValueAndDecryptCustomer(t *testing.T) {
+	testFieldKeyring(t, testFieldKey("k1", 'a'))
+
+	link := "https://sub.example.com/abc"
+	encryptedLink, err := encryptColumnValue(link)
+	if err != nil || !strings.HasPrefix(encryptedLink.(string), fieldcrypt.Prefix) {
+		t.Fatalf("encryptColumnValue(string) = %v, %v", encryptedLink, err)
+	}
+	methodID := "2d1f1c3e-0000-4000-8000-000000000000"
+	encryptedMethod, err := encryptColumnValue(&methodID)
+	if err != nil {
+		t.Fatalf("encryptColumnValue(*string) returned error: %v", err)
+	}
+	if nilValue, err := encryptColumnValue((*string)(nil)); err != nil || nilValue.(*string) != nil {
+		t.Errorf("encryptColumnValue(nil *string) = %v, %v", nilValue, err)
+	}
+	if _, err := encryptColumnValue(42); err == nil {
+		t.Error("Expected error for unsupported type")
+	}
+
+	storedLink := encryptedLink.(string)
+	customer := Customer{SubscriptionLink: &storedLink, PaymentMethodID: encryptedMethod.(*string)}
+	if err := decryptCustomer(&customer); err != nil {
+		t.Fatalf("decryptCustomer() returned error: %v", err)
+	}
+	if *customer.SubscriptionLink != link || *customer.PaymentMethodID != methodID {
+		t.Errorf("Unexpected decrypted customer: %q, %q", *customer.SubscriptionLink, *customer.PaymentMethodID)
+	}
+
+	// Значения, записанные до включения шифрования, читаются как есть
+	legacy := Customer{SubscriptionLink: &link}
+	if err := decryptCustomer(&legacy); err != nil || *legacy.SubscriptionLink != link {
+		t.Errorf("Legacy plaintext = %q, %v", *legacy.SubscriptionLink, err)
+	}
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// maxQueryLabelLen максимальная длина SQL в метке запроса
+const maxQueryLabelLen = 120
+
+// QueryStat накопленная статистика длительности запроса
+type QueryStat struct {
+	Query  string
+	Count  int64
+	Errors int64
+	Total  time.Duration
+	Max    time.Duration
+}
+
+// Avg возвращает среднюю длительность запроса
+func (s QueryStat) Avg() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// QueryMetrics собирает длительность SQL запросов и пишет в лог медленные.
+// Подключается к pgx как Logger, поэтому учитывает все запросы пула
+type QueryMetrics struct {
+	slowThreshold time.Duration
+
+	mu    sync.Mutex
+	stats map[string]*QueryStat
+}
+
+func NewQueryMetrics(slowThreshold time.Duration) *QueryMetrics {
+	return &QueryMetrics{
+		slowThreshold: slowThreshold,
+		stats:         make(map[string]*QueryStat),
+	}
+}
+
+// Log реализует pgx.Logger. Аргументы запросов не сохраняются и не логируются
+func (m *QueryMetrics) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	duration, ok := data["time"].(time.Duration)
+	if !ok {
+		return
+	}
+	sql, _ := data["sql"].(string)
+	if sql == "" {
+		sql = msg
+	}
+	label := queryLabel(sql)
+	failed := level <= pgx.LogLevelError
+
+	m.Observe(label, duration, failed)
+
+	if m.slowThreshold > 0 && duration >= m.slowThreshold {
+		slog.WarnContext(ctx, "Slow database query", "query", label, "duration", duration, "failed", failed)
+	}
+}
+
+// Observe учитывает выполнение запроса
+func (m *QueryMetrics) Observe(label string, duration time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.stats[label]
+	if !ok {
+		s = &QueryStat{Query: label}
+		m.stats[label] = s
+	}
+	s.Count++
+	s.Total += duration
+	if duration > s.Max {
+		s.Max = duration
+	}
+	if failed {
+		s.Errors++
+	}
+}
+
+// Snapshot возвращает статистику запросов, отсортированную по суммарному времени
+func (m *QueryMetrics) Snapshot() []QueryStat {
+	m.mu.Lock()
+	stats := make([]QueryStat, 0, len(m.stats))
+	for _, s := range m.stats {
+		stats = append(stats, *s)
+	}
+	m.mu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Total > stats[j].Total
+	})
+	return stats
+}
+
+// queryLabel нормализует SQL в короткую метку: схлопывает пробелы и обрезает длинные запросы.
+// Для prepared statements pgx передаёт имя запроса, оно остаётся как есть
+func queryLabel(sql string) string {
+	label := []rune(strings.Join(strings.Fields(sql), " "))
+	if len(label) > maxQueryLabelLen {
+		return string(label[:maxQueryLabelLen]) + "…"
+	}
+	return string(label)
+}
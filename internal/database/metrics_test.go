@@ -0,0 +1,59 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func TestQueryMetricsLog(t *testing.T) {
+	m := NewQueryMetrics(time.Second)
+
+	m.Log(context.Background(), pgx.LogLevelInfo, "Query", map[string]interface{}{
+		"sql": stmtFindCustomerByTelegramID.name, "time": 10 * time.Millisecond,
+	})
+	m.Log(context.Background(), pgx.LogLevelInfo, "Query", map[string]interface{}{
+		"sql": stmtFindCustomerByTelegramID.name, "time": 30 * time.Millisecond,
+	})
+	m.Log(context.Background(), pgx.LogLevelError, "Exec", map[string]interface{}{
+		"sql": "UPDATE   customer\n\t SET language = $1", "time": 100 * time.Millisecond,
+	})
+	// Записи без длительности (например, ошибки подключения) не учитываются
+	m.Log(context.Background(), pgx.LogLevelError, "connect failed", map[string]interface{}{"err": "refused"})
+
+	stats := m.Snapshot()
+	if len(stats) != 2 {
+		t.Fatalf("Expected 2 queries, got %d", len(stats))
+	}
+
+	if stats[0].Query != "UPDATE customer SET language = $1" || stats[0].Errors != 1 {
+		t.Errorf("Expected normalized failed update first, got %+v", stats[0])
+	}
+
+	s := stats[1]
+	if s.Query != stmtFindCustomerByTelegramID.name || s.Count != 2 || s.Max != 30*time.Millisecond || s.Avg() != 20*time.Millisecond {
+		t.Errorf("Unexpected stats for prepared statement: %+v", s)
+	}
+}
+
+func TestQueryLabelTruncatesLongQueries(t *testing.T) {
+	label := queryLabel("SELECT " + strings.Repeat("колонка, ", 50) + "id FROM t")
+
+	if got := len([]rune(label)); got != maxQueryLabelLen+1 {
+		t.Errorf("Expected label of %d runes, got %d", maxQueryLabelLen+1, got)
+	}
+	if !strings.HasSuffix(label, "…") {
+		t.Errorf("Expected truncated label to end with ellipsis, got %q", label)
+	}
+}
+
+func TestHotPathStatementsUsePlaceholders(t *testing.T) {
+	for _, stmt := range []preparedStatement{stmtFindCustomerByTelegramID, stmtFindPurchasesByInvoiceTypeAndStatus} {
+		if stmt.name == "" || !strings.Contains(stmt.sql, "$1") {
+			t.Errorf("Expected named statement with placeholders, got %+v", stmt)
+		}
+	}
+}
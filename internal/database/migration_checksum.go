@@ -0,0 +1,166 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrationChecksumTable контрольные суммы применённых up-файлов. Таблицу создаёт сам раннер,
+// как golang-migrate создаёт schema_migrations
+const migrationChecksumTable = "schema_migration_checksums"
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(.+)\.up\.sql$`)
+
+// migrationFile up-файл миграции и sha256 его содержимого
+type migrationFile struct {
+	Version  uint
+	Name     string
+	File     string
+	Checksum string
+}
+
+// readMigrationFiles читает up-файлы каталога по возрастанию версии. Переводы строк CRLF не влияют
+// на сумму: один и тот же файл после checkout на Windows не считается изменённым
+func readMigrationFiles(dir string) ([]migrationFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations directory: %w", err)
+	}
+
+	var files []migrationFile
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+		sum := sha256.Sum256([]byte(strings.ReplaceAll(string(content), "\r\n", "\n")))
+		files = append(files, migrationFile{Version: uint(version), Name: match[2], File: entry.Name(), Checksum: hex.EncodeToString(sum[:])})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Version < files[j].Version })
+	return files, nil
+}
+
+// diffChecksums сравнивает файлы с версией не выше применённой с записанными суммами:
+// missing ещё не записаны (миграции применены до появления проверки), modified изменены после применения
+func diffChecksums(files []migrationFile, recorded map[uint]string, version uint) (missing, modified []migrationFile) {
+	for _, f := range files {
+		if f.Version > version {
+			break
+		}
+		sum, ok := recorded[f.Version]
+		switch {
+		case !ok:
+			missing = append(missing, f)
+		case sum != f.Checksum:
+			modified = append(modified, f)
+		}
+	}
+	return missing, modified
+}
+
+// ModifiedMigrationsError применённые миграции, файлы которых изменились после применения.
+// База уже не соответствует файлам, поэтому новые миграции не запускаются
+type ModifiedMigrationsError struct {
+	Files []string
+}
+
+func (e *ModifiedMigrationsError) Error() string {
+	return fmt.Sprintf("applied migrations were modified after they were applied: %s; restore the original files "+
+		"or, if the schema is known to match them, run `migrate force <current version>` to accept the new checksums",
+		strings.Join(e.Files, ", "))
+}
+
+func modifiedMigrationsError(files []migrationFile) error {
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.File
+	}
+	return &ModifiedMigrationsError{Files: names}
+}
+
+func ensureChecksumTable(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+migrationChecksumTable+` (
+		version    BIGINT PRIMARY KEY,
+		name       TEXT        NOT NULL,
+		checksum   TEXT        NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	)`)
+	if err != nil {
+		return fmt.Errorf("create %s: %w", migrationChecksumTable, err)
+	}
+	return nil
+}
+
+func recordedChecksums(ctx context.Context, db *sql.DB) (map[uint]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT version, checksum FROM `+migrationChecksumTable)
+	if err != nil {
+		return nil, fmt.Errorf("read migration checksums: %w", err)
+	}
+	defer rows.Close()
+
+	recorded := make(map[uint]string)
+	for rows.Next() {
+		var version int64
+		var checksum string
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, fmt.Errorf("read migration checksums: %w", err)
+		}
+		recorded[uint(version)] = checksum
+	}
+	return recorded, rows.Err()
+}
+
+// syncChecksums приводит таблицу сумм к применённой версии: удаляет суммы откатанных миграций
+// и записывает недостающие. С overwrite перезаписывает и существующие — так force принимает изменённые файлы
+func syncChecksums(ctx context.Context, db *sql.DB, files []migrationFile, version uint, applied, overwrite bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("record migration checksums: %w", err)
+	}
+	defer tx.Rollback()
+
+	if applied {
+		_, err = tx.ExecContext(ctx, `DELETE FROM `+migrationChecksumTable+` WHERE version > $1`, int64(version))
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM `+migrationChecksumTable)
+	}
+	if err != nil {
+		return fmt.Errorf("record migration checksums: %w", err)
+	}
+
+	conflict := `ON CONFLICT (version) DO NOTHING`
+	if overwrite {
+		conflict = `ON CONFLICT (version) DO UPDATE SET name = EXCLUDED.name, checksum = EXCLUDED.checksum, applied_at = NOW()`
+	}
+	for _, f := range files {
+		if !applied || f.Version > version {
+			break
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO `+migrationChecksumTable+` (version, name, checksum) VALUES ($1, $2, $3) `+conflict,
+			int64(f.Version), f.Name, f.Checksum)
+		if err != nil {
+			return fmt.Errorf("record checksum of %s: %w", f.File, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("record migration checksums: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"remnawave-tg-shop-bot/internal/database/dbtest"
+)
+
+func writeMigration(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+}
+
+func TestReadMigrationFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeMigration(t, dir, "000002_add_column.up.sql", "ALTER TABLE a ADD COLUMN b INT;\r\n")
+	writeMigration(t, dir, "000002_add_column.down.sql", "ALTER TABLE a DROP COLUMN b;\n")
+	writeMigration(t, dir, "000001_init.up.sql", "CREATE TABLE a (id INT);\n")
+	writeMigration(t, dir, "README", "not a migration")
+
+	files, err := readMigrationFiles(dir)
+	if err != nil {
+		t.Fatalf("readMigrationFiles() returned error: %v", err)
+	}
+	if len(files) != 2 || files[0].Version != 1 || files[1].Version != 2 || files[1].Name != "add_column" || files[1].File != "000002_add_column.up.sql" {
+		t.Fatalf("Expected up files in version order, got %+v", files)
+	}
+
+	writeMigration(t, dir, "000002_add_column.up.sql", "ALTER TABLE a ADD COLUMN b INT;\n")
+	again, err := readMigrationFiles(dir)
+	if err != nil {
+		t.Fatalf("readMigrationFiles() returned error: %v", err)
+	}
+	if again[1].Checksum != files[1].Checksum {
+		t.Error("Expected CRLF line endings not to change the checksum")
+	}
+}
+
+func TestDiffChecksums(t *testing.T) {
+	files := []migrationFile{
+		{Version: 1, File: "000001_init.up.sql", Checksum: "a"},
+		{Version: 2, File: "000002_b.up.sql", Checksum: "b"},
+		{Version: 3, File: "000003_c.up.sql", Checksum: "c"},
+		{Version: 4, File: "000004_d.up.sql", Checksum: "d"},
+	}
+
+	missing, modified := diffChecksums(files, map[uint]string{1: "a", 2: "changed"}, 3)
+
+	if len(missing) != 1 || missing[0].Version != 3 {
+		t.Errorf("Expected only applied migration 3 to lack a checksum, got %+v", missing)
+	}
+	if len(modified) != 1 || modified[0].Version != 2 {
+		t.Errorf("Expected migration 2 to be reported as modified, got %+v", modified)
+	}
+}
+
+// Проверки раннера на тестовой базе: dbtest уже применил все миграции
+func integrationMigrationConfig(t *testing.T) (*MigrationConfig, *pgxpool.Pool) {
+	t.Helper()
+	pool := dbtest.Pool(t)
+	return &MigrationConfig{
+		MigrationsPath: filepath.Join("..", "..", "db", "migrations"),
+		Direction:      MigrateUp,
+		DatabaseURL:    os.Getenv(dbtest.URLEnv),
+		LockTimeout:    time.Second,
+	}, pool
+}
+
+func TestRunMigrationsRecordsAndVerifiesChecksums(t *testing.T) {
+	cfg, pool := integrationMigrationConfig(t)
+	ctx := context.Background()
+
+	if err := RunMigrations(ctx, cfg); err != nil {
+		t.Fatalf("RunMigrations() returned error: %v", err)
+	}
+	state, err := MigrationStatus(ctx, cfg)
+	if err != nil {
+		t.Fatalf("MigrationStatus() returned error: %v", err)
+	}
+	if state.Dirty || len(state.Pending) != 0 || len(state.Modified) != 0 || len(state.Unverified) != 0 {
+		t.Fatalf("Expected a clean, fully verified schema, got %+v", state)
+	}
+
+	if _, err := pool.Exec(ctx, "UPDATE "+migrationChecksumTable+" SET checksum = 'tampered' WHERE version = 1"); err != nil {
+		t.Fatalf("Failed to tamper checksum: %v", err)
+	}
+
+	err = RunMigrations(ctx, cfg)
+	var modified *ModifiedMigrationsError
+	if !errors.As(err, &modified) || len(modified.Files) != 1 || !strings.HasPrefix(modified.Files[0], "000001_") {
+		t.Fatalf("Expected modified migration 000001 to be reported, got %v", err)
+	}
+
+	state, err = MigrationStatus(ctx, cfg)
+	if err != nil {
+		t.Fatalf("MigrationStatus() returned error: %v", err)
+	}
+	forced := *cfg
+	forced.Direction = MigrateForce
+	forced.Steps = int(state.Version)
+	if err := RunMigrations(ctx, &forced); err != nil {
+		t.Fatalf("RunMigrations(force) returned error: %v", err)
+	}
+	if err := RunMigrations(ctx, cfg); err != nil {
+		t.Errorf("Expected force to accept the current files, got %v", err)
+	}
+}
+
+func TestRunMigrationsWaitsForLockHeldByAnotherInstance(t *testing.T) {
+	cfg, pool := integrationMigrationConfig(t)
+	ctx := context.Background()
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Failed to acquire connection: %v", err)
+	}
+	defer conn.Release()
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		t.Fatalf("Failed to take migration lock: %v", err)
+	}
+	defer conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+
+	previous := migrationLockPollInterval
+	migrationLockPollInterval = 50 * time.Millisecond
+	t.Cleanup(func() { migrationLockPollInterval = previous })
+	cfg.LockTimeout = 200 * time.Millisecond
+
+	err = RunMigrations(ctx, cfg)
+	if err == nil || !strings.Contains(err.Error(), "another instance is applying migrations") {
+		t.Fatalf("Expected lock timeout, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// NotificationFeedbackStats оценки уведомлений одного шаблона
+type NotificationFeedbackStats struct {
+	Template  string
+	Useful    int
+	NotUseful int
+}
+
+// UsefulShare доля оценок 👍 в процентах
+func (s NotificationFeedbackStats) UsefulShare() float64 {
+	total := s.Useful + s.NotUseful
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Useful) * 100 / float64(total)
+}
+
+type NotificationFeedbackRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewNotificationFeedbackRepository(pool *pgxpool.Pool) *NotificationFeedbackRepository {
+	return &NotificationFeedbackRepository{pool: pool}
+}
+
+// Save сохраняет оценку клиента под сообщением messageID. Повторная оценка того же сообщения заменяет прежнюю.
+// Возвращает false, если клиента нет
+func (r *NotificationFeedbackRepository) Save(ctx context.Context, telegramID int64, messageID int, template string, useful bool, at time.Time) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		INSERT INTO notification_feedback (customer_id, message_id, template, useful, created_at, updated_at)
+		SELECT id, $2, $3, $4, $5, $5 FROM customer WHERE telegram_id = $1
+		ON CONFLICT (customer_id, message_id) DO UPDATE SET useful = EXCLUDED.useful, updated_at = EXCLUDED.updated_at`,
+		telegramID, messageID, template, useful, at)
+	if err != nil {
+		return false, fmt.Errorf("failed to save notification feedback: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Stats возвращает оценки по шаблонам уведомлений начиная с since, шаблоны с большим числом оценок первыми
+func (r *NotificationFeedbackRepository) Stats(ctx context.Context, since time.Time) ([]NotificationFeedbackStats, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT template, COUNT(*) FILTER (WHERE useful), COUNT(*) FILTER (WHERE NOT useful)
+		FROM notification_feedback
+		WHERE created_at >= $1
+		GROUP BY template
+		ORDER BY COUNT(*) DESC, template`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification feedback stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []NotificationFeedbackStats
+	for rows.Next() {
+		var s NotificationFeedbackStats
+		if err := rows.Scan(&s.Template, &s.Useful, &s.NotUseful); err != nil {
+			return nil, fmt.Errorf("failed to scan notification feedback stats: %w", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
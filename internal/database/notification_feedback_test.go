@@ -0,0 +1,12 @@
+package database
+
+import "testing"
+
+func TestNotificationFeedbackStatsUsefulShare(t *testing.T) {
+	if got := (NotificationFeedbackStats{Useful: 3, NotUseful: 1}).UsefulShare(); got != 75 {
+		t.Errorf("UsefulShare() = %v, want 75", got)
+	}
+	if got := (NotificationFeedbackStats{}).UsefulShare(); got != 0 {
+		t.Errorf("UsefulShare() without feedback = %v, want 0", got)
+	}
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// OutboxMaxAttempts после стольких неудачных отправок уведомление больше не отправляется
+const OutboxMaxAttempts = 3
+
+// Виды отложенных уведомлений
+const (
+	OutboxKindExpiring        = "expiring"
+	OutboxKindRecurringNotice = "recurring_notice"
+	OutboxKindExpired         = "expired"
+	OutboxKindWinback         = "winback"
+	OutboxKindWinbackPaid     = "winback_paid"
+	OutboxKindTrialUpsell     = "trial_upsell"
+	OutboxKindRecurringBulk   = "recurring_bulk"
+)
+
+// OutboxMessage уведомление, отложенное до разрешённых часов по местному времени клиента
+type OutboxMessage struct {
+	ID         int64
+	CustomerID int64
+	Kind       string
+	ChatID     int64
+	Text       string
+	ParseMode  string
+	// ReplyMarkup inline-клавиатура в JSON, nil — без клавиатуры
+	ReplyMarkup []byte
+	SendAfter   time.Time
+	Attempts    int
+}
+
+type OutboxRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewOutboxRepository(pool *pgxpool.Pool) *OutboxRepository {
+	return &OutboxRepository{pool: pool}
+}
+
+// Enqueue ставит уведомление в очередь
+func (r *OutboxRepository) Enqueue(ctx context.Context, message *OutboxMessage) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO notification_outbox (customer_id, kind, chat_id, text, parse_mode, reply_markup, send_after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		message.CustomerID, message.Kind, message.ChatID, message.Text, message.ParseMode, message.ReplyMarkup, message.SendAfter)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue notification: %w", err)
+	}
+	return nil
+}
+
+// buildDueOutboxQuery выбирает неотправленные уведомления, время отправки которых наступило в промежутке (from, to].
+// Более старые не отправляются: за время простоя бота напоминание могло устареть.
+// Сообщения цепочки после триала не отправляются, если клиент успел оплатить подписку
+func buildDueOutboxQuery(from, to time.Time, limit int) sq.SelectBuilder {
+	return sq.Select("id", "customer_id", "kind", "chat_id", "text", "parse_mode", "reply_markup", "send_after", "attempts").
+		From("notification_outbox").
+		Where(sq.And{
+			sq.Eq{"sent_at": nil},
+			sq.Lt{"attempts": OutboxMaxAttempts},
+			sq.Gt{"send_after": from},
+			sq.LtOrEq{"send_after": to},
+			sq.Or{
+				sq.NotEq{"kind": OutboxKindTrialUpsell},
+				sq.Expr(`NOT EXISTS (
+					SELECT 1 FROM purchase p
+					WHERE p.customer_id = notification_outbox.customer_id AND p.status = ? AND NOT p.is_test
+					  AND p.paid_at >= notification_outbox.created_at
+				)`, PurchaseStatusPaid),
+			},
+		}).
+		OrderBy("send_after", "id").
+		Limit(uint64(limit))
+}
+
+// FindDue возвращает до limit уведомлений, которые пора отправить
+func (r *OutboxRepository) FindDue(ctx context.Context, from, to time.Time, limit int) ([]OutboxMessage, error) {
+	sql, args, err := buildDueOutboxQuery(from, to, limit).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build due notifications query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []OutboxMessage
+	for rows.Next() {
+		var m OutboxMessage
+		if err := rows.Scan(&m.ID, &m.CustomerID, &m.Kind, &m.ChatID, &m.Text, &m.ParseMode, &m.ReplyMarkup, &m.SendAfter, &m.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan notification: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// MarkSent отмечает уведомление отправленным
+func (r *OutboxRepository) MarkSent(ctx context.Context, id int64, sentAt time.Time) error {
+	_, err := r.pool.Exec(ctx, "UPDATE notification_outbox SET sent_at = $2 WHERE id = $1", id, sentAt)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification sent: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed учитывает неудачную отправку, уведомление повторится при следующем запуске
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id int64, errText string) error {
+	_, err := r.pool.Exec(ctx, "UPDATE notification_outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1", id, errText)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification failed: %w", err)
+	}
+	return nil
+}
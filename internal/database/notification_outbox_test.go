@@ -0,0 +1,40 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+func TestBuildDueOutboxQuery(t *testing.T) {
+	to := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	from := to.Add(-12 * time.Hour)
+
+	sql, args, err := buildDueOutboxQuery(from, to, 100).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() returned error: %v", err)
+	}
+
+	for _, want := range []string{
+		"sent_at IS NULL",
+		"attempts < $1",
+		"send_after > $2",
+		"send_after <= $3",
+		"kind <> $4",
+		"p.paid_at >= notification_outbox.created_at",
+		"ORDER BY send_after, id",
+		"LIMIT 100",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("SQL does not contain %q: %s", want, sql)
+		}
+	}
+
+	expectedArgs := []interface{}{OutboxMaxAttempts, from, to, OutboxKindTrialUpsell, PurchaseStatusPaid}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Fatalf("unexpected args, want %v, got %v", expectedArgs, args)
+	}
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// OfferKind вид персонального предложения. Значение — префикс колонок предложения в таблице customer
+type OfferKind string
+
+const (
+	OfferKindPromoTariff OfferKind = "promo_offer"
+	OfferKindWinback     OfferKind = "winback_offer"
+)
+
+func (k OfferKind) column(name string) string {
+	return string(k) + "_" + name
+}
+
+type OfferReminderRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewOfferReminderRepository(pool *pgxpool.Pool) *OfferReminderRepository {
+	return &OfferReminderRepository{pool: pool}
+}
+
+// buildExpiringOfferQuery выбирает клиентов, у которых предложение kind заканчивается в промежутке (from, to]
+// и напоминание о нём ещё не отправлялось. Клиенты, отказавшиеся от winback, напоминаний не получают
+func buildExpiringOfferQuery(kind OfferKind, from, to time.Time, limit int) sq.SelectBuilder {
+	conditions := sq.And{
+		sq.NotEq{kind.column("price"): nil},
+		sq.Gt{kind.column("expires_at"): from},
+		sq.LtOrEq{kind.column("expires_at"): to},
+		sq.Eq{kind.column("reminded_at"): nil},
+		notDeleted,
+	}
+	if kind == OfferKindWinback {
+		conditions = append(conditions, sq.Eq{"winback_opted_out_at": nil})
+	}
+	return sq.Select(customerColumns()...).
+		From("customer").
+		Where(conditions).
+		OrderBy(kind.column("expires_at")).
+		Limit(uint64(limit))
+}
+
+// FindExpiring возвращает до limit клиентов, чьё предложение kind заканчивается в промежутке (from, to]
+func (r *OfferReminderRepository) FindExpiring(ctx context.Context, kind OfferKind, from, to time.Time, limit int) ([]Customer, error) {
+	sql, args, err := buildExpiringOfferQuery(kind, from, to, limit).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build expiring offer query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expiring offers: %w", err)
+	}
+	defer rows.Close()
+
+	var customers []Customer
+	for rows.Next() {
+		customer, err := scanCustomerFromRows(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan customer row: %w", err)
+		}
+		customers = append(customers, *customer)
+	}
+	return customers, rows.Err()
+}
+
+// Claim отмечает, что по предложению со сроком expiresAt отправляется напоминание. Возвращает false, если
+// напоминание уже было или предложение успели заменить новым. Отметка ставится до отправки,
+// поэтому при сбое отправки напоминание не повторяется
+func (r *OfferReminderRepository) Claim(ctx context.Context, kind OfferKind, customerID int64, expiresAt, at time.Time) (bool, error) {
+	sql, args, err := sq.Update("customer").
+		Set(kind.column("reminded_at"), at).
+		Where(sq.Eq{
+			"id":                       customerID,
+			kind.column("expires_at"):  expiresAt,
+			kind.column("reminded_at"): nil,
+		}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build offer reminder claim query: %w", err)
+	}
+
+	tag, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim offer reminder: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+func TestBuildExpiringOfferQuery(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(6 * time.Hour)
+
+	sql, args, err := buildExpiringOfferQuery(OfferKindWinback, from, to, 100).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"winback_offer_price IS NOT NULL",
+		"winback_offer_expires_at > $1",
+		"winback_offer_expires_at <= $2",
+		"winback_offer_reminded_at IS NULL",
+		"winback_opted_out_at IS NULL",
+		"deleted_at IS NULL",
+		"ORDER BY winback_offer_expires_at",
+		"LIMIT 100",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("SQL does not contain %q: %s", want, sql)
+		}
+	}
+	if expectedArgs := []interface{}{from, to}; !reflect.DeepEqual(args, expectedArgs) {
+		t.Fatalf("unexpected args, want %v, got %v", expectedArgs, args)
+	}
+
+	sql, _, err = buildExpiringOfferQuery(OfferKindPromoTariff, from, to, 100).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() returned error: %v", err)
+	}
+	_, where, _ := strings.Cut(sql, "WHERE")
+	if !strings.Contains(where, "promo_offer_reminded_at IS NULL") || strings.Contains(where, "winback") {
+		t.Fatalf("unexpected promo offer query: %s", sql)
+	}
+}
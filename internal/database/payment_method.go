@@ -0,0 +1,251 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"remnawave-tg-shop-bot/internal/fieldcrypt"
+)
+
+// PaymentMethod сохранённый способ оплаты ЮKassa. Автопродление списывается с того,
+// чей MethodID записан в customer.payment_method_id
+type PaymentMethod struct {
+	ID         int64     `db:"id"`
+	CustomerID int64     `db:"customer_id"`
+	MethodID   string    `db:"method_id"`
+	CardType   *string   `db:"card_type"`
+	CardLast4  *string   `db:"card_last4"`
+	Title      *string   `db:"title"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// Label возвращает маскированное название для показа пользователю: "Visa •••• 4444".
+// Пустая строка — данные карты ещё неизвестны (карта перенесена из старой схемы)
+func (m PaymentMethod) Label() string {
+	if m.CardLast4 != nil && *m.CardLast4 != "" {
+		if m.CardType != nil && *m.CardType != "" {
+			return fmt.Sprintf("%s •••• %s", *m.CardType, *m.CardLast4)
+		}
+		return "•••• " + *m.CardLast4
+	}
+	if m.Title != nil {
+		return *m.Title
+	}
+	return ""
+}
+
+// IsRecurring возвращает true, если с этого способа оплаты списывается автопродление клиента
+func (m PaymentMethod) IsRecurring(customer *Customer) bool {
+	return customer.PaymentMethodID != nil && *customer.PaymentMethodID == m.MethodID
+}
+
+var paymentMethodColumns = []string{"id", "customer_id", "method_id", "card_type", "card_last4", "title", "created_at"}
+
+type PaymentMethodRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPaymentMethodRepository(pool *pgxpool.Pool) *PaymentMethodRepository {
+	return &PaymentMethodRepository{pool: pool}
+}
+
+func scanPaymentMethod(row pgx.Row) (*PaymentMethod, error) {
+	var m PaymentMethod
+	if err := row.Scan(&m.ID, &m.CustomerID, &m.MethodID, &m.CardType, &m.CardLast4, &m.Title, &m.CreatedAt); err != nil {
+		return nil, err
+	}
+	methodID, err := fieldcrypt.Decrypt(m.MethodID)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt method_id: %w", err)
+	}
+	m.MethodID = methodID
+	return &m, nil
+}
+
+// Save добавляет способ оплаты клиенту. Повторное сохранение той же карты обновляет её данные,
+// неизвестные поля (nil) не затирают уже сохранённые.
+// method_id может храниться зашифрованным со случайным nonce, поэтому ту же карту ищем расшифровкой,
+// а не через ON CONFLICT. Строка клиента блокируется, чтобы параллельное сохранение не создало дубль
+func (r *PaymentMethodRepository) Save(ctx context.Context, method *PaymentMethod) error {
+	err := r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, "SELECT 1 FROM customer WHERE id = $1 FOR UPDATE", method.CustomerID); err != nil {
+			return err
+		}
+		existingID, err := findPaymentMethodID(ctx, tx, method.CustomerID, method.MethodID)
+		if err != nil {
+			return err
+		}
+		if existingID != 0 {
+			_, err = tx.Exec(ctx, `
+				UPDATE payment_method SET
+					card_type = COALESCE($2, card_type),
+					card_last4 = COALESCE($3, card_last4),
+					title = COALESCE($4, title)
+				WHERE id = $1`,
+				existingID, method.CardType, method.CardLast4, method.Title)
+			return err
+		}
+
+		encryptedMethodID, err := fieldcrypt.Encrypt(method.MethodID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO payment_method (customer_id, method_id, card_type, card_last4, title)
+			VALUES ($1, $2, $3, $4, $5)`,
+			method.CustomerID, encryptedMethodID, method.CardType, method.CardLast4, method.Title)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save payment method: %w", err)
+	}
+	return nil
+}
+
+// findPaymentMethodID возвращает id сохранённого способа оплаты клиента с данным method_id, 0 если его нет
+func findPaymentMethodID(ctx context.Context, tx pgx.Tx, customerID int64, methodID string) (int64, error) {
+	rows, err := tx.Query(ctx, "SELECT id, method_id FROM payment_method WHERE customer_id = $1", customerID)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var stored string
+		if err := rows.Scan(&id, &stored); err != nil {
+			return 0, err
+		}
+		plain, err := fieldcrypt.Decrypt(stored)
+		if err != nil {
+			return 0, fmt.Errorf("decrypt method_id: %w", err)
+		}
+		if plain == methodID {
+			return id, nil
+		}
+	}
+	return 0, rows.Err()
+}
+
+// FindByCustomer возвращает способы оплаты клиента в порядке сохранения
+func (r *PaymentMethodRepository) FindByCustomer(ctx context.Context, customerID int64) ([]PaymentMethod, error) {
+	sql, args, err := sq.Select(paymentMethodColumns...).
+		From("payment_method").
+		Where(sq.Eq{"customer_id": customerID}).
+		OrderBy("created_at", "id").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select payment methods query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payment methods: %w", err)
+	}
+	defer rows.Close()
+
+	var methods []PaymentMethod
+	for rows.Next() {
+		method, err := scanPaymentMethod(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan payment method: %w", err)
+		}
+		methods = append(methods, *method)
+	}
+	return methods, rows.Err()
+}
+
+// FindByID возвращает способ оплаты клиента, nil если его нет или он принадлежит другому клиенту
+func (r *PaymentMethodRepository) FindByID(ctx context.Context, customerID, id int64) (*PaymentMethod, error) {
+	sql, args, err := sq.Select(paymentMethodColumns...).
+		From("payment_method").
+		Where(sq.Eq{"id": id, "customer_id": customerID}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select payment method query: %w", err)
+	}
+
+	method, err := scanPaymentMethod(r.pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find payment method: %w", err)
+	}
+	return method, nil
+}
+
+// SelectForRecurring привязывает автопродление клиента к его способу оплаты id.
+// Возвращает false, если такого способа оплаты у клиента нет
+func (r *PaymentMethodRepository) SelectForRecurring(ctx context.Context, customerID, id int64) (bool, error) {
+	defer cachedCustomers.invalidate(customerID)
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE customer SET payment_method_id = pm.method_id
+		FROM payment_method pm
+		WHERE customer.id = $1 AND pm.customer_id = customer.id AND pm.id = $2`,
+		customerID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to select payment method for recurring: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Delete удаляет способ оплаты клиента. Если с него списывалось автопродление, настройки автопродления
+// сбрасываются так же, как в CustomerRepository.DeletePaymentMethod. Возвращает удалённый способ оплаты
+// (nil если его не было) и признак того, что автопродление было отключено
+func (r *PaymentMethodRepository) Delete(ctx context.Context, customerID, id int64) (*PaymentMethod, bool, error) {
+	defer cachedCustomers.invalidate(customerID)
+	var deleted *PaymentMethod
+	recurringReset := false
+	err := r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		method, err := scanPaymentMethod(tx.QueryRow(ctx,
+			"DELETE FROM payment_method WHERE id = $1 AND customer_id = $2 RETURNING "+strings.Join(paymentMethodColumns, ", "),
+			id, customerID))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		deleted = method
+
+		// method_id клиента сравниваем после расшифровки: шифртексты одного значения различаются
+		var recurringMethodID *string
+		err = tx.QueryRow(ctx, "SELECT payment_method_id FROM customer WHERE id = $1 FOR UPDATE", customerID).Scan(&recurringMethodID)
+		if err != nil {
+			return err
+		}
+		if err := fieldcrypt.DecryptInPlace(recurringMethodID); err != nil {
+			return fmt.Errorf("decrypt payment_method_id: %w", err)
+		}
+		if recurringMethodID == nil || *recurringMethodID != method.MethodID {
+			return nil
+		}
+
+		tag, err := tx.Exec(ctx, `
+			UPDATE customer SET recurring_enabled = false, payment_method_id = NULL,
+				recurring_amount = NULL, recurring_months = NULL, recurring_days = NULL, recurring_tariff_name = NULL,
+				recurring_list_price = NULL, recurring_device_limit = NULL, recurring_locked_at = NULL,
+				recurring_pending_amount = NULL, recurring_pending_effective_at = NULL, recurring_pending_consented_at = NULL
+			WHERE id = $1`,
+			customerID)
+		if err != nil {
+			return err
+		}
+		recurringReset = tag.RowsAffected() > 0
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to delete payment method: %w", err)
+	}
+	return deleted, recurringReset, nil
+}
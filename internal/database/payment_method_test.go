@@ -0,0 +1,24 @@
+package database
+
+import "testing"
+
+func TestPaymentMethodLabel(t *testing.T) {
+	str := func(s string) *string { return &s }
+	tests := []struct {
+		name   string
+		method PaymentMethod
+		want   string
+	}{
+		{"card with type", PaymentMethod{CardType: str("Visa"), CardLast4: str("4444"), Title: str("Bank card *4444")}, "Visa •••• 4444"},
+		{"card without type", PaymentMethod{CardLast4: str("4444")}, "•••• 4444"},
+		{"non-card method", PaymentMethod{Title: str("YooMoney wallet 410011758831136")}, "YooMoney wallet 410011758831136"},
+		{"migrated card", PaymentMethod{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.method.Label(); got != tt.want {
+				t.Errorf("Label() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
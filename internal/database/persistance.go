@@ -8,42 +8,106 @@ import (
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
-	"github.com/jackc/pgx/v4/pgxpool"
 	_ "github.com/lib/pq"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"remnawave-tg-shop-bot/internal/config"
+	"time"
+)
+
+// Направления MigrationConfig.Direction
+const (
+	MigrateUp    = "up"
+	MigrateDown  = "down"
+	MigrateForce = "force"
+)
+
+const (
+	// migrationLockKey ключ pg_advisory_lock, общий для всех реплик бота: миграции применяет одна реплика,
+	// остальные ждут её и затем видят, что применять нечего
+	migrationLockKey int64 = 7_318_043_921_506
+	// defaultMigrationLockTimeout сколько реплика ждёт чужие миграции, если LockTimeout не задан
+	defaultMigrationLockTimeout = 5 * time.Minute
+)
+
+var (
+	migrationLockPollInterval = 2 * time.Second
+	// База в docker compose может подниматься дольше бота
+	migrationConnectAttempts = 5
+	migrationConnectDelay    = 2 * time.Second
 )
 
 type MigrationConfig struct {
 	MigrationsPath string
 	Direction      string
-	Steps          int
+	// Steps число миграций для up и down (0 — все), для force — версия, -1 — ни одной применённой
+	Steps int
+	// DatabaseURL по умолчанию DATABASE_URL из конфигурации
+	DatabaseURL string
+	// LockTimeout по умолчанию defaultMigrationLockTimeout
+	LockTimeout time.Duration
 }
 
-func RunMigrations(ctx context.Context, migrationConfig *MigrationConfig, pool *pgxpool.Pool) error {
-	if err := pool.Ping(ctx); err != nil {
-		return err
-	}
+// DirtyMigrationError миграция упала на полпути, и схема в неизвестном состоянии. Автоматически
+// такое не чинится: оператор проверяет схему и отмечает версию командой migrate force
+type DirtyMigrationError struct {
+	Version  uint
+	File     string
+	Previous int
+}
+
+func (e *DirtyMigrationError) Error() string {
+	return fmt.Sprintf("database schema is dirty at version %d: %s failed halfway; fix the schema by hand, "+
+		"then run `migrate force %d` if the migration is fully applied or `migrate force %d` to retry it",
+		e.Version, e.File, e.Version, e.Previous)
+}
+
+// MigrationState состояние схемы для migrate status
+type MigrationState struct {
+	// Version последняя применённая миграция, 0 — ни одной
+	Version uint
+	Dirty   bool
+	Pending []string
+	// Modified применённые миграции, файлы которых изменились после применения
+	Modified []string
+	// Unverified применённые миграции без записанной суммы: её запишет следующий migrate up
+	Unverified []string
+}
 
+// migrations файлы миграций и подключение golang-migrate к базе
+type migrations struct {
+	db    *sql.DB
+	m     *migrate.Migrate
+	files []migrationFile
+}
+
+func openMigrations(ctx context.Context, migrationConfig *MigrationConfig) (*migrations, error) {
 	absPath, err := filepath.Abs(migrationConfig.MigrationsPath)
 	if err != nil {
-		return fmt.Errorf("invalid migrations path: %w", err)
+		return nil, fmt.Errorf("invalid migrations path: %w", err)
 	}
 	if _, err := os.Stat(absPath); os.IsNotExist(err) {
-		return fmt.Errorf("migrations directory does not exist: %s", absPath)
+		return nil, fmt.Errorf("migrations directory does not exist: %s", absPath)
+	}
+	files, err := readMigrationFiles(absPath)
+	if err != nil {
+		return nil, err
 	}
 
-	db, err := sql.Open("postgres", config.DadaBaseUrl())
+	databaseURL := migrationConfig.DatabaseURL
+	if databaseURL == "" {
+		databaseURL = config.DadaBaseUrl()
+	}
+	db, err := connectMigrationDB(ctx, databaseURL)
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return nil, err
 	}
-	defer db.Close()
 
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
 	if err != nil {
-		return fmt.Errorf("could not create migration driver: %w", err)
+		db.Close()
+		return nil, fmt.Errorf("could not create migration driver: %w", err)
 	}
 
 	m, err := migrate.NewWithDatabaseInstance(
@@ -51,63 +115,207 @@ func RunMigrations(ctx context.Context, migrationConfig *MigrationConfig, pool *
 		"postgres", driver,
 	)
 	if err != nil {
-		return fmt.Errorf("migration initialization failed: %w", err)
+		db.Close()
+		return nil, fmt.Errorf("migration initialization failed: %w", err)
 	}
+	return &migrations{db: db, m: m, files: files}, nil
+}
 
-	version, dirty, verErr := m.Version()
-	if verErr != nil && verErr != migrate.ErrNilVersion {
-		return fmt.Errorf("failed to get migration version: %w", verErr)
+func (ms *migrations) Close() {
+	ms.m.Close()
+	ms.db.Close()
+}
+
+// connectMigrationDB подключается к базе, повторяя попытки, пока она поднимается
+func connectMigrationDB(ctx context.Context, databaseURL string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	for attempt := 1; ; attempt++ {
+		err = db.PingContext(ctx)
+		if err == nil {
+			return db, nil
+		}
+		if attempt == migrationConnectAttempts || ctx.Err() != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to connect to database after %d attempt(s): %w", attempt, err)
+		}
+		slog.Warn("Database is not reachable, retrying", "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(migrationConnectDelay):
+		}
+	}
+}
+
+// lockMigrations берёт advisory lock на отдельном соединении и держит его до вызова unlock.
+// Пока миграции применяет другая реплика, ждёт не дольше timeout
+func lockMigrations(ctx context.Context, db *sql.DB, timeout time.Duration) (unlock func(), err error) {
+	if timeout <= 0 {
+		timeout = defaultMigrationLockTimeout
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("acquire migration lock: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	logged := false
+	for {
+		var locked bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", migrationLockKey).Scan(&locked); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("acquire migration lock: %w", err)
+		}
+		if locked {
+			return func() {
+				if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey); err != nil {
+					slog.Warn("Failed to release migration lock", "error", err)
+				}
+				conn.Close()
+			}, nil
+		}
+		if !time.Now().Before(deadline) {
+			conn.Close()
+			return nil, fmt.Errorf("timed out after %s waiting for the migration lock: another instance is applying migrations", timeout)
+		}
+		if !logged {
+			slog.Info("Another instance is applying migrations, waiting for it to finish")
+			logged = true
+		}
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			return nil, fmt.Errorf("wait for migration lock: %w", ctx.Err())
+		case <-time.After(migrationLockPollInterval):
+		}
+	}
+}
+
+// version последняя применённая миграция; applied=false, если не применено ни одной
+func (ms *migrations) version() (version uint, dirty, applied bool, err error) {
+	version, dirty, err = ms.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, false, nil
+	}
+	if err != nil {
+		return 0, false, false, fmt.Errorf("failed to get migration version: %w", err)
+	}
+	return version, dirty, true, nil
+}
+
+func (ms *migrations) dirtyError(version uint) error {
+	err := &DirtyMigrationError{Version: version, File: fmt.Sprintf("migration %06d", version), Previous: -1}
+	for _, f := range ms.files {
+		if f.Version == version {
+			err.File = f.File
+		}
+		if f.Version < version {
+			err.Previous = int(f.Version)
+		}
+	}
+	return err
+}
+
+// failure дополняет ошибку golang-migrate подсказкой, если упавшая миграция оставила схему dirty
+func (ms *migrations) failure(migErr error) error {
+	if version, dirty, _, err := ms.version(); err == nil && dirty {
+		return fmt.Errorf("migration failed: %w; %w", migErr, ms.dirtyError(version))
+	}
+	return fmt.Errorf("migration failed: %w", migErr)
+}
+
+// RunMigrations применяет, откатывает или отмечает миграции под advisory lock, так что реплики,
+// стартующие одновременно, не применяют их параллельно. Перед up и down сверяет суммы уже применённых
+// файлов, после — записывает суммы новых
+func RunMigrations(ctx context.Context, migrationConfig *MigrationConfig) error {
+	switch migrationConfig.Direction {
+	case MigrateUp, MigrateDown:
+	case MigrateForce:
+		if migrationConfig.Steps < -1 {
+			return errors.New("version cannot be less than -1 for force command")
+		}
+	default:
+		return fmt.Errorf("unknown migration direction %q", migrationConfig.Direction)
+	}
+
+	ms, err := openMigrations(ctx, migrationConfig)
+	if err != nil {
+		return err
+	}
+	defer ms.Close()
+
+	unlock, err := lockMigrations(ctx, ms.db, migrationConfig.LockTimeout)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := ensureChecksumTable(ctx, ms.db); err != nil {
+		return err
+	}
+
+	version, dirty, _, err := ms.version()
+	if err != nil {
+		return err
 	}
 
 	if dirty && version == 3 {
 		slog.Warn("Detected dirty migration at version 3: forcing pointer and running down script")
 
-		if err := m.Force(int(version)); err != nil {
+		if err := ms.m.Force(int(version)); err != nil {
 			return fmt.Errorf("failed to force migration version: %w", err)
 		}
 
-		if err := m.Steps(-1); err != nil && err != migrate.ErrNoChange {
+		if err := ms.m.Steps(-1); err != nil && err != migrate.ErrNoChange {
 			return fmt.Errorf("failed to run down migration: %w", err)
 		}
 
-		if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-			return fmt.Errorf("failed to re-apply migrations: %w", err)
+		if err := ms.m.Up(); err != nil && err != migrate.ErrNoChange {
+			return ms.failure(err)
 		}
 
 		slog.Info("Down + re-Up completed successfully")
-		return nil
+		return ms.syncChecksums(ctx, false)
+	}
+
+	if migrationConfig.Direction == MigrateForce {
+		if err := ms.m.Force(migrationConfig.Steps); err != nil {
+			return fmt.Errorf("failed to force migration version: %w", err)
+		}
+		slog.Info("Migration version forced", "version", migrationConfig.Steps)
+		return ms.syncChecksums(ctx, true)
+	}
+
+	if dirty {
+		return ms.dirtyError(version)
+	}
+	recorded, err := recordedChecksums(ctx, ms.db)
+	if err != nil {
+		return err
+	}
+	if _, modified := diffChecksums(ms.files, recorded, version); len(modified) > 0 {
+		return modifiedMigrationsError(modified)
 	}
 
 	var migErr error
-	switch migrationConfig.Direction {
-	case "up":
-		if migrationConfig.Steps > 0 {
-			migErr = m.Steps(migrationConfig.Steps)
-		} else {
-			migErr = m.Up()
-		}
-	case "down":
-		if migrationConfig.Steps > 0 {
-			migErr = m.Steps(-migrationConfig.Steps)
-		} else {
-			migErr = m.Down()
-		}
-	case "force":
-		if migrationConfig.Steps < 0 {
-			return errors.New("version cannot be negative for force command")
-		}
-		migErr = m.Force(migrationConfig.Steps)
+	switch {
+	case migrationConfig.Direction == MigrateUp && migrationConfig.Steps > 0:
+		migErr = ms.m.Steps(migrationConfig.Steps)
+	case migrationConfig.Direction == MigrateUp:
+		migErr = ms.m.Up()
+	case migrationConfig.Steps > 0:
+		migErr = ms.m.Steps(-migrationConfig.Steps)
 	default:
-		v, d, dbErr := m.Version()
-		if dbErr != nil && dbErr != migrate.ErrNilVersion {
-			return fmt.Errorf("failed to get migration version: %w", dbErr)
-		}
-		slog.Info("Current migration version", "version", v, "dirty", d)
-		return nil
+		migErr = ms.m.Down()
 	}
 
 	if migErr != nil && migErr != migrate.ErrNoChange {
-		return fmt.Errorf("migration failed: %w", migErr)
+		return ms.failure(migErr)
+	}
+	if err := ms.syncChecksums(ctx, false); err != nil {
+		return err
 	}
 	if errors.Is(migErr, migrate.ErrNoChange) {
 		slog.Info("No migrations to apply")
@@ -116,33 +324,51 @@ func RunMigrations(ctx context.Context, migrationConfig *MigrationConfig, pool *
 	}
 	return nil
 }
-func GetMigrationVersion(migrationsPath string) (uint, bool, error) {
-	db, err := sql.Open("postgres", config.DadaBaseUrl())
+
+func (ms *migrations) syncChecksums(ctx context.Context, overwrite bool) error {
+	version, _, applied, err := ms.version()
 	if err != nil {
-		return 0, false, fmt.Errorf("failed to connect to database: %w", err)
+		return err
 	}
-	defer db.Close()
+	return syncChecksums(ctx, ms.db, ms.files, version, applied, overwrite)
+}
 
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+// MigrationStatus читает версию схемы и сверяет файлы с записанными суммами. Ничего не меняет и lock не берёт
+func MigrationStatus(ctx context.Context, migrationConfig *MigrationConfig) (*MigrationState, error) {
+	ms, err := openMigrations(ctx, migrationConfig)
 	if err != nil {
-		return 0, false, fmt.Errorf("could not create migration driver: %w", err)
+		return nil, err
 	}
+	defer ms.Close()
 
-	m, err := migrate.NewWithDatabaseInstance(
-		fmt.Sprintf("file://%s", migrationsPath),
-		"postgres", driver)
+	version, dirty, _, err := ms.version()
 	if err != nil {
-		return 0, false, fmt.Errorf("migration initialization failed: %w", err)
+		return nil, err
 	}
 
-	version, dirty, err := m.Version()
-	if err != nil && err != migrate.ErrNilVersion {
-		return 0, false, fmt.Errorf("failed to get migration version: %w", err)
+	recorded := map[uint]string{}
+	var tableExists bool
+	if err := ms.db.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", migrationChecksumTable).Scan(&tableExists); err != nil {
+		return nil, fmt.Errorf("read migration checksums: %w", err)
 	}
-
-	if err == migrate.ErrNilVersion {
-		return 0, false, nil
+	if tableExists {
+		if recorded, err = recordedChecksums(ctx, ms.db); err != nil {
+			return nil, err
+		}
 	}
 
-	return version, dirty, nil
+	state := &MigrationState{Version: version, Dirty: dirty}
+	missing, modified := diffChecksums(ms.files, recorded, version)
+	for _, f := range missing {
+		state.Unverified = append(state.Unverified, f.File)
+	}
+	for _, f := range modified {
+		state.Modified = append(state.Modified, f.File)
+	}
+	for _, f := range ms.files {
+		if f.Version > version {
+			state.Pending = append(state.Pending, f.File)
+		}
+	}
+	return state, nil
 }
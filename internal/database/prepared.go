@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// preparedStatement именованный запрос горячего пути. Подготавливается один раз на соединение
+// при первом использовании, дальше выполняется по имени без повторного разбора
+type preparedStatement struct {
+	name string
+	sql  string
+}
+
+var (
+	stmtFindCustomerByTelegramID = preparedStatement{
+		name: "customer_find_by_telegram_id",
+		sql:  fmt.Sprintf("SELECT %s FROM customer WHERE telegram_id = $1 AND deleted_at IS NULL", strings.Join(customerColumns(), ", ")),
+	}
+	stmtFindPurchasesByInvoiceTypeAndStatus = preparedStatement{
+		name: "purchase_find_by_invoice_type_and_status",
+		sql:  fmt.Sprintf("SELECT %s FROM purchase WHERE invoice_type = $1 AND status = $2", strings.Join(purchaseColumns(), ", ")),
+	}
+)
+
+// queryPrepared выполняет prepared statement на соединении из пула.
+// Соединение возвращается в пул при закрытии rows
+func queryPrepared(ctx context.Context, pool *pgxpool.Pool, stmt preparedStatement, args ...interface{}) (pgx.Rows, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.Conn().Prepare(ctx, stmt.name, stmt.sql); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("prepare %s: %w", stmt.name, err)
+	}
+
+	rows, err := conn.Query(ctx, stmt.name, args...)
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+	return &preparedRows{Rows: rows, conn: conn}, nil
+}
+
+// queryRowPrepared аналог pool.QueryRow для prepared statement
+func queryRowPrepared(ctx context.Context, pool *pgxpool.Pool, stmt preparedStatement, args ...interface{}) pgx.Row {
+	rows, err := queryPrepared(ctx, pool, stmt, args...)
+	return &preparedRow{rows: rows, err: err}
+}
+
+type preparedRows struct {
+	pgx.Rows
+	conn *pgxpool.Conn
+}
+
+func (r *preparedRows) Close() {
+	r.Rows.Close()
+	r.conn.Release()
+}
+
+func (r *preparedRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.Close()
+	return false
+}
+
+type preparedRow struct {
+	rows pgx.Rows
+	err  error
+}
+
+func (r *preparedRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	r.rows.Close()
+	return r.rows.Err()
+}
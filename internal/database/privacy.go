@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"remnawave-tg-shop-bot/internal/fieldcrypt"
+)
+
+// customerQuery запрос по данным одного клиента. Принимает один параметр: id клиента
+// или telegram_id, если byTelegramID
+type customerQuery struct {
+	name         string
+	query        string
+	byTelegramID bool
+}
+
+func (q customerQuery) arg(customer *Customer) int64 {
+	if q.byTelegramID {
+		return customer.TelegramID
+	}
+	return customer.ID
+}
+
+// exportSections всё, что бот хранит о клиенте. Хеш телефона не выгружается: по нему нельзя
+// восстановить номер, клиенту показывается только факт подтверждения
+var exportSections = []customerQuery{
+	{name: "purchases", query: "SELECT * FROM " + purchaseHistoryView + " WHERE customer_id = $1 ORDER BY created_at"},
+	{name: "receipts", query: "SELECT purchase_id, number, issued_at FROM purchase_receipt WHERE customer_id = $1 ORDER BY issued_at"},
+	{name: "payment_methods", query: "SELECT card_type, card_last4, title, created_at FROM payment_method WHERE customer_id = $1 ORDER BY created_at"},
+	{name: "recurring_charges", query: "SELECT amount, months, status, created_at FROM recurring_charge_log WHERE customer_id = $1 ORDER BY created_at"},
+	{name: "referrals", query: "SELECT referrer_id, referee_id, used_at, bonus_granted FROM referral WHERE referrer_id = $1 OR referee_id = $1 ORDER BY used_at", byTelegramID: true},
+	{name: "promo_codes", query: "SELECT p.code, a.channel, a.activated_at FROM promo_code_activation a JOIN promo_code p ON p.id = a.promo_code_id WHERE a.customer_id = $1 ORDER BY a.activated_at"},
+	{name: "promo_tariff_codes", query: "SELECT p.code, a.channel, a.activated_at FROM promo_tariff_activation a JOIN promo_tariff_code p ON p.id = a.promo_tariff_id WHERE a.customer_id = $1 ORDER BY a.activated_at"},
+	{name: "teams", query: "SELECT id, seats, months, expires_at, created_at FROM team WHERE owner_customer_id = $1 ORDER BY created_at"},
+	{name: "team_seats", query: "SELECT team_id, redeemed_at FROM team_seat WHERE member_customer_id = $1 ORDER BY redeemed_at"},
+	{name: "winback_offers", query: "SELECT campaign, sent_at, converted_at, opted_out_at FROM winback_send WHERE customer_id = $1 ORDER BY sent_at"},
+	{name: "checkout_reminders", query: "SELECT purchase_id, sent_at FROM checkout_reminder WHERE customer_id = $1 ORDER BY sent_at"},
+	{name: "trial_upsell_messages", query: "SELECT step, trial_activated_at, sent_at FROM trial_upsell_send WHERE customer_id = $1 ORDER BY sent_at"},
+	{name: "surveys", query: "SELECT trigger, sent_at, rating, comment, answered_at FROM satisfaction_survey WHERE customer_id = $1 ORDER BY sent_at"},
+	{name: "notification_feedback", query: "SELECT template, useful, created_at FROM notification_feedback WHERE customer_id = $1 ORDER BY created_at"},
+	{name: "subscription_rotations", query: "SELECT admin_id IS NOT NULL AS by_admin, created_at FROM subscription_rotation WHERE customer_id = $1 ORDER BY created_at"},
+	{name: "phone", query: "SELECT verified_at FROM customer_phone WHERE customer_id = $1"},
+	{name: "tags", query: "SELECT tag, created_at FROM customer_tag WHERE customer_id = $1 ORDER BY tag"},
+	{name: "notes", query: "SELECT note, updated_at FROM customer_note WHERE customer_id = $1"},
+	{name: "admin_actions", query: "SELECT action, details, created_at FROM admin_audit_log WHERE customer_id = $1 ORDER BY created_at"},
+	{name: "funnel_events", query: "SELECT event, created_at FROM funnel_event WHERE telegram_id = $1 ORDER BY created_at", byTelegramID: true},
+}
+
+// anonymizeStatements удаляют персональные данные клиента из связанных таблиц.
+// Рефералы удаляются первыми: они ссылаются на telegram_id, который затем заменяется
+var anonymizeStatements = []customerQuery{
+	{query: "DELETE FROM referral WHERE referrer_id = $1 OR referee_id = $1", byTelegramID: true},
+	{query: "DELETE FROM funnel_event WHERE telegram_id = $1", byTelegramID: true},
+	{query: "DELETE FROM customer_tag WHERE customer_id = $1"},
+	{query: "DELETE FROM customer_note WHERE customer_id = $1"},
+	{query: "DELETE FROM customer_phone WHERE customer_id = $1"},
+	{query: "DELETE FROM payment_method WHERE customer_id = $1"},
+	{query: "DELETE FROM notification_outbox WHERE customer_id = $1"},
+	{query: "DELETE FROM trial_upsell_send WHERE customer_id = $1"},
+	{query: "UPDATE admin_audit_log SET details = NULL WHERE customer_id = $1"},
+	{query: "UPDATE satisfaction_survey SET comment = NULL WHERE customer_id = $1"},
+	{query: "UPDATE purchase_receipt SET file_id = NULL WHERE customer_id = $1"},
+}
+
+// IsAnonymized возвращает true, если данные клиента обезличены по его запросу
+func IsAnonymized(c *Customer) bool {
+	return c.TelegramID < 0
+}
+
+type PrivacyRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPrivacyRepository(pool *pgxpool.Pool) *PrivacyRepository {
+	return &PrivacyRepository{pool: pool}
+}
+
+// Export возвращает все данные клиента по разделам: "customer" — запись клиента, остальные — списки строк
+func (r *PrivacyRepository) Export(ctx context.Context, customer *Customer) (map[string]json.RawMessage, error) {
+	data := make(map[string]json.RawMessage, len(exportSections)+1)
+
+	var row []byte
+	if err := r.pool.QueryRow(ctx, "SELECT row_to_json(c) FROM customer c WHERE id = $1", customer.ID).Scan(&row); err != nil {
+		return nil, fmt.Errorf("failed to export customer: %w", err)
+	}
+	row, err := decryptExportedCustomer(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export customer: %w", err)
+	}
+	data["customer"] = row
+
+	for _, section := range exportSections {
+		var rows []byte
+		if err := r.pool.QueryRow(ctx, buildExportSectionQuery(section), section.arg(customer)).Scan(&rows); err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", section.name, err)
+		}
+		data[section.name] = rows
+	}
+	return data, nil
+}
+
+// decryptExportedCustomer расшифровывает зашифрованные колонки в JSON записи клиента:
+// в выгрузку попадают сами данные, а не шифротекст
+func decryptExportedCustomer(row []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(row, &fields); err != nil {
+		return nil, err
+	}
+	for column := range encryptedCustomerColumns {
+		raw, ok := fields[column]
+		if !ok {
+			continue
+		}
+		var value *string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("decode %s: %w", column, err)
+		}
+		if err := fieldcrypt.DecryptInPlace(value); err != nil {
+			return nil, fmt.Errorf("decrypt %s: %w", column, err)
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		fields[column] = encoded
+	}
+	return json.Marshal(fields)
+}
+
+// buildExportSectionQuery собирает строки раздела в JSON-массив, пустой раздел — []
+func buildExportSectionQuery(section customerQuery) string {
+	return "SELECT COALESCE(json_agg(row_to_json(t)), '[]'::json) FROM (" + section.query + ") t"
+}
+
+// buildAnonymizeCustomerQuery убирает из записи клиента идентификаторы и всё, что связывает её с человеком.
+// Остаются покупки, баланс и принятие условий — они нужны для отчётности
+func buildAnonymizeCustomerQuery(customerID int64, at time.Time) sq.UpdateBuilder {
+	return sq.Update("customer").
+		Set("telegram_id", sq.Expr("-id")).
+		Set("subscription_link", nil).
+		Set("expire_at", nil).
+		Set("source", nil).
+		Set("last_active_at", nil).
+		Set("timezone", nil).
+		Set("email", nil).
+		Set("payment_method_id", nil).
+		Set("recurring_enabled", false).
+		Set("recurring_tariff_name", nil).
+		Set("recurring_months", nil).
+		Set("recurring_days", nil).
+		Set("recurring_amount", nil).
+		SetMap(clearRecurringPriceLock).
+		Set("promo_offer_price", nil).
+		Set("promo_offer_devices", nil).
+		Set("promo_offer_months", nil).
+		Set("promo_offer_expires_at", nil).
+		Set("promo_offer_code_id", nil).
+		Set("winback_offer_price", nil).
+		Set("winback_offer_devices", nil).
+		Set("winback_offer_months", nil).
+		Set("winback_offer_expires_at", nil).
+		Set("winback_opted_out_at", at).
+		Set("marketing_opted_out_at", at).
+		Set("anonymized_at", at).
+		Where(sq.Eq{"id": customerID, "anonymized_at": nil})
+}
+
+// Anonymize обезличивает клиента в одной транзакции. Возвращает false, если клиент уже обезличен
+func (r *PrivacyRepository) Anonymize(ctx context.Context, customer *Customer, at time.Time) (bool, error) {
+	defer cachedCustomers.invalidateTelegramID(customer.TelegramID)
+	sql, args, err := buildAnonymizeCustomerQuery(customer.ID, at).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build anonymize query: %w", err)
+	}
+
+	anonymized := false
+	err = r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		for _, statement := range anonymizeStatements {
+			if _, err := tx.Exec(ctx, statement.query, statement.arg(customer)); err != nil {
+				return fmt.Errorf("failed to anonymize related rows: %w", err)
+			}
+		}
+		tag, err := tx.Exec(ctx, sql, args...)
+		if err != nil {
+			return fmt.Errorf("failed to anonymize customer: %w", err)
+		}
+		anonymized = tag.RowsAffected() == 1
+		return nil
+	})
+	return anonymized, err
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+func TestBuildAnonymizeCustomerQuery(t *testing.T) {
+	at := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	sql, args, err := buildAnonymizeCustomerQuery(7, at).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() returned error: %v", err)
+	}
+
+	for _, want := range []string{
+		"telegram_id = -id",
+		"subscription_link = $1",
+		"payment_method_id = $",
+		"email = $",
+		"marketing_opted_out_at = $",
+		"anonymized_at = $",
+		"WHERE anonymized_at IS NULL AND id = $",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("SQL does not contain %q: %s", want, sql)
+		}
+	}
+	if args[len(args)-1] != int64(7) {
+		t.Fatalf("Expected customer id as the last arg, got %v", args)
+	}
+}
+
+func TestAnonymizeStatementsDeleteReferralsFirst(t *testing.T) {
+	// referral ссылается на customer.telegram_id, поэтому строки удаляются до замены telegram_id
+	if !strings.HasPrefix(anonymizeStatements[0].query, "DELETE FROM referral") || !anonymizeStatements[0].byTelegramID {
+		t.Fatalf("Expected referrals to be deleted first by telegram_id, got %+v", anonymizeStatements[0])
+	}
+}
+
+// TestPrivacyCoversTables проверяет, что таблицы с данными клиента попадают и в выгрузку, и в обезличивание
+func TestPrivacyCoversTables(t *testing.T) {
+	for _, table := range []string{"trial_upsell_send"} {
+		exported := false
+		for _, section := range exportSections {
+			exported = exported || strings.Contains(section.query, "FROM "+table+" ")
+		}
+		if !exported {
+			t.Errorf("Table %s is missing from exportSections", table)
+		}
+
+		anonymized := false
+		for _, statement := range anonymizeStatements {
+			anonymized = anonymized || strings.Contains(statement.query, " "+table+" ")
+		}
+		if !anonymized {
+			t.Errorf("Table %s is missing from anonymizeStatements", table)
+		}
+	}
+}
+
+func TestExportSections(t *testing.T) {
+	customer := &Customer{ID: 7, TelegramID: 42}
+	seen := make(map[string]bool)
+	for _, section := range exportSections {
+		if seen[section.name] || section.name == "customer" {
+			t.Errorf("Duplicate export section %q", section.name)
+		}
+		seen[section.name] = true
+
+		if strings.Count(section.query, "$") != strings.Count(section.query, "$1") {
+			t.Errorf("Section %q must use only $1: %s", section.name, section.query)
+		}
+		wantArg := customer.ID
+		if strings.Contains(section.query, "telegram_id") || strings.Contains(section.query, "referrer_id") {
+			wantArg = customer.TelegramID
+		}
+		if got := section.arg(customer); got != wantArg {
+			t.Errorf("Section %q arg = %d, want %d", section.name, got, wantArg)
+		}
+	}
+
+	query := buildExportSectionQuery(exportSections[0])
+	if !strings.HasPrefix(query, "SELECT COALESCE(json_agg(row_to_json(t)), '[]'::json) FROM (SELECT") {
+		t.Fatalf("Unexpected export query: %s", query)
+	}
+}
+
+func TestDecryptExportedCustomer(t *testing.T) {
+	testFieldKeyring(t, testFieldKey("k1", 'a'))
+
+	link := "https://sub.example.com/abc"
+	encryptedLink, err := encryptColumnValue(link)
+	if err != nil {
+		t.Fatalf("encryptColumnValue() returned error: %v", err)
+	}
+	row, err := json.Marshal(map[string]interface{}{
+		"id":                7,
+		"subscription_link": encryptedLink,
+		"payment_method_id": nil,
+		"language":          "ru",
+	})
+	if err != nil {
+		t.Fatalf("Marshal() returned error: %v", err)
+	}
+
+	decrypted, err := decryptExportedCustomer(row)
+	if err != nil {
+		t.Fatalf("decryptExportedCustomer() returned error: %v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(decrypted, &got); err != nil {
+		t.Fatalf("Unmarshal() returned error: %v", err)
+	}
+	if got["subscription_link"] != link || got["payment_method_id"] != nil || got["language"] != "ru" || got["id"] != float64(7) {
+		t.Errorf("Unexpected exported customer: %s", decrypted)
+	}
+}
@@ -40,6 +40,13 @@ type PromoCodeActivation struct {
 	ActivatedAt time.Time `db:"activated_at"`
 }
 
+// PromoChannelCount количество активаций промокода по каналу распространения ссылки.
+// Channel пустой для промокодов, введённых вручную
+type PromoChannelCount struct {
+	Channel string
+	Count   int
+}
+
 type PromoRepository struct {
 	pool *pgxpool.Pool
 }
@@ -187,10 +194,11 @@ func (r *PromoRepository) IsUsedByCustomer(ctx context.Context, promoID, custome
 	return true, nil
 }
 
-func (r *PromoRepository) RecordActivation(ctx context.Context, promoID, customerID int64) error {
+// RecordActivation записывает активацию промокода. channel — канал ссылки, nil для ручного ввода
+func (r *PromoRepository) RecordActivation(ctx context.Context, promoID, customerID int64, channel *string) error {
 	query := sq.Insert("promo_code_activation").
-		Columns("promo_code_id", "customer_id").
-		Values(promoID, customerID).
+		Columns("promo_code_id", "customer_id", "channel").
+		Values(promoID, customerID, channel).
 		PlaceholderFormat(sq.Dollar)
 
 	sql, args, err := query.ToSql()
@@ -286,3 +294,38 @@ func (r *PromoRepository) GetActivationsByPromo(ctx context.Context, promoID int
 	}
 	return list, nil
 }
+
+// CountActivationsByChannel возвращает количество активаций промокода по каналам, начиная с самого частого
+func (r *PromoRepository) CountActivationsByChannel(ctx context.Context, promoID int64) ([]PromoChannelCount, error) {
+	return countActivationsByChannel(ctx, r.pool, "promo_code_activation", "promo_code_id", promoID)
+}
+
+func countActivationsByChannel(ctx context.Context, pool *pgxpool.Pool, table, promoColumn string, promoID int64) ([]PromoChannelCount, error) {
+	query := sq.Select("COALESCE(channel, '')", "COUNT(*)").
+		From(table).
+		Where(sq.Eq{promoColumn: promoID}).
+		GroupBy("channel").
+		OrderBy("COUNT(*) DESC", "channel").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build activations by channel query: %w", err)
+	}
+
+	rows, err := pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query activations by channel: %w", err)
+	}
+	defer rows.Close()
+
+	var list []PromoChannelCount
+	for rows.Next() {
+		var c PromoChannelCount
+		if err := rows.Scan(&c.Channel, &c.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan activations by channel row: %w", err)
+		}
+		list = append(list, c)
+	}
+	return list, rows.Err()
+}
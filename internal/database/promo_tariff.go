@@ -195,6 +195,7 @@ func (r *PromoTariffRepository) SetActive(ctx context.Context, id int64, isActiv
 
 // Delete удаляет промокод
 func (r *PromoTariffRepository) Delete(ctx context.Context, id int64) error {
+	defer cachedCustomers.invalidateAll()
 	// Сначала обнуляем ссылки на этот промокод в customer
 	clearQuery := sq.Update("customer").
 		Set("promo_offer_code_id", nil).
@@ -272,11 +273,11 @@ func (r *PromoTariffRepository) IsUsedByCustomer(ctx context.Context, promoTarif
 	return true, nil
 }
 
-// RecordActivation записывает активацию промокода пользователем
-func (r *PromoTariffRepository) RecordActivation(ctx context.Context, promoTariffID, customerID int64) error {
+// RecordActivation записывает активацию промокода пользователем. channel — канал ссылки, nil для ручного ввода
+func (r *PromoTariffRepository) RecordActivation(ctx context.Context, promoTariffID, customerID int64, channel *string) error {
 	query := sq.Insert("promo_tariff_activation").
-		Columns("promo_tariff_id", "customer_id").
-		Values(promoTariffID, customerID).
+		Columns("promo_tariff_id", "customer_id", "channel").
+		Values(promoTariffID, customerID, channel).
 		PlaceholderFormat(sq.Dollar)
 
 	sql, args, err := query.ToSql()
@@ -320,3 +321,8 @@ func (r *PromoTariffRepository) GetActivationsByPromo(ctx context.Context, promo
 	}
 	return list, nil
 }
+
+// CountActivationsByChannel возвращает количество активаций промокода по каналам, начиная с самого частого
+func (r *PromoTariffRepository) CountActivationsByChannel(ctx context.Context, promoTariffID int64) ([]PromoChannelCount, error) {
+	return countActivationsByChannel(ctx, r.pool, "promo_tariff_activation", "promo_tariff_id", promoTariffID)
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+
+	"remnawave-tg-shop-bot/internal/config"
 )
 
 type InvoiceType string
@@ -19,6 +21,8 @@ const (
 	InvoiceTypeYookasa  InvoiceType = "yookasa"
 	InvoiceTypeTelegram InvoiceType = "telegram"
 	InvoiceTypeTribute  InvoiceType = "tribute"
+	// InvoiceTypeSandbox тестовая покупка админа за 0 ₽ без платёжного провайдера
+	InvoiceTypeSandbox InvoiceType = "sandbox"
 )
 
 type PurchaseStatus string
@@ -28,6 +32,10 @@ const (
 	PurchaseStatusPending PurchaseStatus = "pending"
 	PurchaseStatusPaid    PurchaseStatus = "paid"
 	PurchaseStatusCancel  PurchaseStatus = "cancel"
+	// PurchaseStatusRefunded покупка полностью возвращена, оплаченный период отозван
+	PurchaseStatusRefunded PurchaseStatus = "refunded"
+	// PurchaseStatusExpired счёт не был оплачен за INVOICE_TTL_MINUTES и больше не проверяется
+	PurchaseStatusExpired PurchaseStatus = "expired"
 )
 
 type Purchase struct {
@@ -47,6 +55,36 @@ type Purchase struct {
 	YookasaID         *uuid.UUID     `db:"yookasa_id"`
 	TariffName        *string        `db:"tariff_name"`
 	DeviceLimit       *int           `db:"device_limit"`
+	RefundedAmount    float64        `db:"refunded_amount"`
+	RefundedAt        *time.Time     `db:"refunded_at"`
+	MessageID         *int           `db:"message_id"`
+	Source            *string        `db:"source"`
+	IsTest            bool           `db:"is_test"`
+	// TeamSeats количество мест командной подписки. Для обычной покупки nil
+	TeamSeats *int `db:"team_seats"`
+	// ProvisionedDeviceLimit лимит устройств тарифа или предложения на момент создания счёта.
+	// В отличие от DeviceLimit заполняется и для обычных покупок по тарифу
+	ProvisionedDeviceLimit *int `db:"provisioned_device_limit"`
+	// ProviderPaymentID идентификатор платежа у платёжного провайдера
+	ProviderPaymentID *string `db:"provider_payment_id"`
+	// PaymentMethodType способ оплаты по данным провайдера, например bank_card или sbp
+	PaymentMethodType *string `db:"payment_method_type"`
+	// PeriodDays срок посуточной покупки в днях (Month = 0). Для покупки на месяцы nil
+	PeriodDays *int `db:"period_days"`
+	// GiftFromCustomerID клиент, оплативший подписку в подарок. Покупка записана на получателя, для обычной покупки nil
+	GiftFromCustomerID *int64 `db:"gift_from_customer_id"`
+	// ExternalSquadUUID внешний сквад панели для покупки по спецпредложению. nil — сквад по умолчанию (EXTERNAL_SQUAD_UUID)
+	ExternalSquadUUID *uuid.UUID `db:"external_squad_uuid"`
+	// PanelExpireAt срок подписки в панели, сохранённый перед выдачей покупки. Повтор выдачи выставляет его же
+	PanelExpireAt *time.Time `db:"panel_expire_at"`
+}
+
+// Period срок покупки: дни для посуточного плана, иначе месяцы
+func (p *Purchase) Period() config.Period {
+	if p.PeriodDays != nil {
+		return config.DaysPeriod(*p.PeriodDays)
+	}
+	return config.MonthsPeriod(p.Month)
 }
 
 // purchaseColumns returns all purchase columns for SELECT queries in correct order
@@ -55,7 +93,11 @@ func purchaseColumns() []string {
 		"id", "amount", "customer_id", "created_at", "month",
 		"paid_at", "currency", "expire_at", "status", "invoice_type",
 		"crypto_invoice_id", "crypto_invoice_url", "yookasa_url", "yookasa_id",
-		"tariff_name", "device_limit",
+		"tariff_name", "device_limit", "refunded_amount", "refunded_at",
+		"message_id", "source", "is_test", "team_seats",
+		"provisioned_device_limit", "provider_payment_id", "payment_method_type",
+		"period_days", "gift_from_customer_id", "external_squad_uuid",
+		"panel_expire_at",
 	}
 }
 
@@ -66,7 +108,11 @@ func scanPurchase(row pgx.Row) (*Purchase, error) {
 		&p.ID, &p.Amount, &p.CustomerID, &p.CreatedAt, &p.Month,
 		&p.PaidAt, &p.Currency, &p.ExpireAt, &p.Status, &p.InvoiceType,
 		&p.CryptoInvoiceID, &p.CryptoInvoiceLink, &p.YookasaURL, &p.YookasaID,
-		&p.TariffName, &p.DeviceLimit,
+		&p.TariffName, &p.DeviceLimit, &p.RefundedAmount, &p.RefundedAt,
+		&p.MessageID, &p.Source, &p.IsTest, &p.TeamSeats,
+		&p.ProvisionedDeviceLimit, &p.ProviderPaymentID, &p.PaymentMethodType,
+		&p.PeriodDays, &p.GiftFromCustomerID, &p.ExternalSquadUUID,
+		&p.PanelExpireAt,
 	)
 	if err != nil {
 		return nil, err
@@ -81,7 +127,11 @@ func scanPurchaseFromRows(rows pgx.Rows) (*Purchase, error) {
 		&p.ID, &p.Amount, &p.CustomerID, &p.CreatedAt, &p.Month,
 		&p.PaidAt, &p.Currency, &p.ExpireAt, &p.Status, &p.InvoiceType,
 		&p.CryptoInvoiceID, &p.CryptoInvoiceLink, &p.YookasaURL, &p.YookasaID,
-		&p.TariffName, &p.DeviceLimit,
+		&p.TariffName, &p.DeviceLimit, &p.RefundedAmount, &p.RefundedAt,
+		&p.MessageID, &p.Source, &p.IsTest, &p.TeamSeats,
+		&p.ProvisionedDeviceLimit, &p.ProviderPaymentID, &p.PaymentMethodType,
+		&p.PeriodDays, &p.GiftFromCustomerID, &p.ExternalSquadUUID,
+		&p.PanelExpireAt,
 	)
 	if err != nil {
 		return nil, err
@@ -101,8 +151,8 @@ func NewPurchaseRepository(pool *pgxpool.Pool) *PurchaseRepository {
 
 func (cr *PurchaseRepository) Create(ctx context.Context, purchase *Purchase) (int64, error) {
 	buildInsert := sq.Insert("purchase").
-		Columns("amount", "customer_id", "month", "currency", "expire_at", "status", "invoice_type", "crypto_invoice_id", "crypto_invoice_url", "yookasa_url", "yookasa_id", "tariff_name", "device_limit").
-		Values(purchase.Amount, purchase.CustomerID, purchase.Month, purchase.Currency, purchase.ExpireAt, purchase.Status, purchase.InvoiceType, purchase.CryptoInvoiceID, purchase.CryptoInvoiceLink, purchase.YookasaURL, purchase.YookasaID, purchase.TariffName, purchase.DeviceLimit).
+		Columns("amount", "customer_id", "month", "currency", "expire_at", "status", "invoice_type", "crypto_invoice_id", "crypto_invoice_url", "yookasa_url", "yookasa_id", "tariff_name", "device_limit", "source", "is_test", "team_seats", "provisioned_device_limit", "provider_payment_id", "period_days", "gift_from_customer_id", "external_squad_uuid").
+		Values(purchase.Amount, purchase.CustomerID, purchase.Month, purchase.Currency, purchase.ExpireAt, purchase.Status, purchase.InvoiceType, purchase.CryptoInvoiceID, purchase.CryptoInvoiceLink, purchase.YookasaURL, purchase.YookasaID, purchase.TariffName, purchase.DeviceLimit, purchase.Source, purchase.IsTest, purchase.TeamSeats, purchase.ProvisionedDeviceLimit, purchase.ProviderPaymentID, purchase.PeriodDays, purchase.GiftFromCustomerID, purchase.ExternalSquadUUID).
 		Suffix("RETURNING id").
 		PlaceholderFormat(sq.Dollar)
 
@@ -120,13 +170,34 @@ func (cr *PurchaseRepository) Create(ctx context.Context, purchase *Purchase) (i
 	return id, nil
 }
 
+// FindByInvoiceTypeAndStatus вызывается проверкой инвойсов каждые 5 секунд, поэтому выполняется через prepared statement
 func (cr *PurchaseRepository) FindByInvoiceTypeAndStatus(ctx context.Context, invoiceType InvoiceType, status PurchaseStatus) (*[]Purchase, error) {
+	rows, err := queryPrepared(ctx, cr.pool, stmtFindPurchasesByInvoiceTypeAndStatus, invoiceType, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query purchases: %w", err)
+	}
+	defer rows.Close()
+
+	var purchases []Purchase
+	for rows.Next() {
+		purchase, err := scanPurchaseFromRows(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan purchase: %w", err)
+		}
+		purchases = append(purchases, *purchase)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return &purchases, nil
+}
+
+func (cr *PurchaseRepository) FindById(ctx context.Context, id int64) (*Purchase, error) {
 	buildSelect := sq.Select(purchaseColumns()...).
 		From("purchase").
-		Where(sq.And{
-			sq.Eq{"invoice_type": invoiceType},
-			sq.Eq{"status": status},
-		}).
+		Where(sq.Eq{"id": id}).
 		PlaceholderFormat(sq.Dollar)
 
 	sql, args, err := buildSelect.ToSql()
@@ -134,9 +205,40 @@ func (cr *PurchaseRepository) FindByInvoiceTypeAndStatus(ctx context.Context, in
 		return nil, err
 	}
 
+	purchase, err := scanPurchase(cr.pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to query purchase: %w", err)
+	}
+
+	return purchase, nil
+}
+
+// buildStalePendingQuery выбирает неоплаченные счета указанных типов, созданные раньше cutoff
+func buildStalePendingQuery(invoiceTypes []InvoiceType, cutoff time.Time, limit int) sq.SelectBuilder {
+	return sq.Select(purchaseColumns()...).
+		From("purchase").
+		Where(sq.And{
+			sq.Eq{"status": PurchaseStatusPending},
+			sq.Eq{"invoice_type": invoiceTypes},
+			sq.Lt{"created_at": cutoff},
+		}).
+		OrderBy("created_at").
+		Limit(uint64(limit))
+}
+
+// FindStalePending возвращает до limit неоплаченных счетов, созданных раньше cutoff
+func (cr *PurchaseRepository) FindStalePending(ctx context.Context, invoiceTypes []InvoiceType, cutoff time.Time, limit int) ([]Purchase, error) {
+	sql, args, err := buildStalePendingQuery(invoiceTypes, cutoff, limit).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build stale pending query: %w", err)
+	}
+
 	rows, err := cr.pool.Query(ctx, sql, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query purchases: %w", err)
+		return nil, fmt.Errorf("failed to query stale purchases: %w", err)
 	}
 	defer rows.Close()
 
@@ -149,17 +251,89 @@ func (cr *PurchaseRepository) FindByInvoiceTypeAndStatus(ctx context.Context, in
 		purchases = append(purchases, *purchase)
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+	return purchases, rows.Err()
+}
+
+// FindPaidBetween возвращает оплаченные (в том числе позже возвращённые) покупки указанных типов,
+// оплаченные в интервале [from, to). Тестовые покупки не возвращаются
+func (cr *PurchaseRepository) FindPaidBetween(ctx context.Context, invoiceTypes []InvoiceType, from, to time.Time) ([]Purchase, error) {
+	sql, args, err := sq.Select(purchaseColumns()...).
+		From("purchase").
+		Where(sq.And{
+			sq.Eq{"status": []PurchaseStatus{PurchaseStatusPaid, PurchaseStatusRefunded}},
+			sq.Eq{"invoice_type": invoiceTypes},
+			sq.Eq{"is_test": false},
+			sq.GtOrEq{"paid_at": from},
+			sq.Lt{"paid_at": to},
+		}).
+		OrderBy("paid_at").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build paid purchases query: %w", err)
 	}
 
-	return &purchases, nil
+	rows, err := cr.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query paid purchases: %w", err)
+	}
+	defer rows.Close()
+
+	var purchases []Purchase
+	for rows.Next() {
+		purchase, err := scanPurchaseFromRows(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan purchase: %w", err)
+		}
+		purchases = append(purchases, *purchase)
+	}
+
+	return purchases, rows.Err()
 }
 
-func (cr *PurchaseRepository) FindById(ctx context.Context, id int64) (*Purchase, error) {
+// ExpireIfPending переводит покупку в статус expired, только если она всё ещё ожидает оплаты.
+// Возвращает false если покупка успела смениться (например, была оплачена)
+func (cr *PurchaseRepository) ExpireIfPending(ctx context.Context, id int64) (bool, error) {
+	sql, args, err := sq.Update("purchase").
+		Set("status", PurchaseStatusExpired).
+		Where(sq.Eq{"id": id, "status": PurchaseStatusPending}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build expire query: %w", err)
+	}
+
+	tag, err := cr.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to expire purchase: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// CancelIfPending переводит покупку в статус cancel, только если она всё ещё ожидает оплаты.
+// Возвращает false если покупка уже обработана: оплачена, истекла или отменена раньше
+func (cr *PurchaseRepository) CancelIfPending(ctx context.Context, id int64) (bool, error) {
+	sql, args, err := sq.Update("purchase").
+		Set("status", PurchaseStatusCancel).
+		Where(sq.Eq{"id": id, "status": []PurchaseStatus{PurchaseStatusNew, PurchaseStatusPending}}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build cancel query: %w", err)
+	}
+
+	tag, err := cr.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to cancel purchase: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// FindByYookasaID возвращает покупку по ID платежа ЮKassa или nil, если её нет
+func (cr *PurchaseRepository) FindByYookasaID(ctx context.Context, yookasaID uuid.UUID) (*Purchase, error) {
 	buildSelect := sq.Select(purchaseColumns()...).
 		From("purchase").
-		Where(sq.Eq{"id": id}).
+		Where(sq.Eq{"yookasa_id": yookasaID}).
 		PlaceholderFormat(sq.Dollar)
 
 	sql, args, err := buildSelect.ToSql()
@@ -172,7 +346,7 @@ func (cr *PurchaseRepository) FindById(ctx context.Context, id int64) (*Purchase
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("failed to query purchase: %w", err)
+		return nil, fmt.Errorf("failed to query purchase by yookasa id: %w", err)
 	}
 
 	return purchase, nil
@@ -220,6 +394,47 @@ func (pr *PurchaseRepository) MarkAsPaid(ctx context.Context, purchaseID int64)
 	return pr.UpdateFields(ctx, purchaseID, updates)
 }
 
+// ClaimRefund атомарно сохраняет общую сумму возвратов оплаченной покупки, если она больше сохранённой.
+// При полном возврате покупка переводится в статус refunded. Возвращает сумму возвратов до обновления;
+// ok=false — возврат уже учтён повтором webhook или параллельной проверкой
+func (pr *PurchaseRepository) ClaimRefund(ctx context.Context, purchaseID int64, refundedAmount float64, full bool) (previous float64, ok bool, err error) {
+	status := PurchaseStatusPaid
+	if full {
+		status = PurchaseStatusRefunded
+	}
+	err = pr.pool.QueryRow(ctx, `
+		UPDATE purchase p SET refunded_amount = $2, refunded_at = $3, status = $4
+		FROM (SELECT id, refunded_amount FROM purchase WHERE id = $1 FOR UPDATE) prev
+		WHERE p.id = prev.id AND p.status = $5 AND p.refunded_amount < $2
+		RETURNING prev.refunded_amount`,
+		purchaseID, refundedAmount, time.Now(), status, PurchaseStatusPaid).Scan(&previous)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("claim refund: %w", err)
+	}
+	return previous, true, nil
+}
+
+// ReleaseRefund отменяет ClaimRefund, если возврат не удалось применить: следующая проверка обработает его заново
+func (pr *PurchaseRepository) ReleaseRefund(ctx context.Context, purchaseID int64, refundedAmount, previous float64) error {
+	query := sq.Update("purchase").
+		Set("refunded_amount", previous).
+		Set("status", PurchaseStatusPaid).
+		Where(sq.Eq{"id": purchaseID, "refunded_amount": refundedAmount}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+	if _, err := pr.pool.Exec(ctx, sql, args...); err != nil {
+		return fmt.Errorf("release refund: %w", err)
+	}
+	return nil
+}
+
 func buildLatestActiveTributesQuery(customerIDs []int64) sq.SelectBuilder {
 	return sq.
 		Select(purchaseColumns()...).
@@ -334,6 +549,70 @@ func (pr *PurchaseRepository) FindSuccessfulPaidPurchaseByCustomer(ctx context.C
 	return p, nil
 }
 
+// FindLastPaidPurchaseByCustomer возвращает последнюю оплаченную покупку клиента любым способом оплаты
+// (тариф и лимит устройств текущей подписки). nil если оплаченных покупок нет
+func (pr *PurchaseRepository) FindLastPaidPurchaseByCustomer(ctx context.Context, customerID int64) (*Purchase, error) {
+	query := sq.Select(purchaseColumns()...).
+		From("purchase").
+		Where(sq.And{
+			sq.Eq{"customer_id": customerID},
+			sq.Eq{"status": PurchaseStatusPaid},
+		}).
+		OrderBy("paid_at DESC").
+		Limit(1).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	p, err := scanPurchase(pr.pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("query purchase: %w", err)
+	}
+
+	return p, nil
+}
+
+// FindPaidByCustomer возвращает последние оплаченные покупки клиента без тестовых, новые первыми
+func (pr *PurchaseRepository) FindPaidByCustomer(ctx context.Context, customerID int64, limit int) ([]Purchase, error) {
+	query := sq.Select(purchaseColumns()...).
+		From("purchase").
+		Where(sq.And{
+			sq.Eq{"customer_id": customerID},
+			sq.Eq{"status": PurchaseStatusPaid},
+			sq.Eq{"is_test": false},
+		}).
+		OrderBy("paid_at DESC").
+		Limit(uint64(limit)).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	rows, err := pr.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query paid purchases: %w", err)
+	}
+	defer rows.Close()
+
+	var purchases []Purchase
+	for rows.Next() {
+		p, err := scanPurchaseFromRows(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan purchase: %w", err)
+		}
+		purchases = append(purchases, *p)
+	}
+	return purchases, rows.Err()
+}
+
 // HasRecentPaidPurchase проверяет был ли у пользователя оплаченный платёж за последние N минут
 // Используется для защиты от race condition при автоплатежах
 func (pr *PurchaseRepository) HasRecentPaidPurchase(ctx context.Context, customerID int64, withinMinutes int) (bool, error) {
@@ -366,10 +645,10 @@ func (pr *PurchaseRepository) HasRecentPaidPurchase(ctx context.Context, custome
 	return true, nil
 }
 
-// HasPaidPurchases проверяет есть ли у пользователя оплаченные покупки
+// HasPaidPurchases проверяет есть ли у пользователя оплаченные покупки (включая архивные)
 func (pr *PurchaseRepository) HasPaidPurchases(ctx context.Context, customerID int64) (bool, error) {
 	query := sq.Select("1").
-		From("purchase").
+		From(purchaseHistoryView).
 		Where(sq.And{
 			sq.Eq{"customer_id": customerID},
 			sq.Eq{"status": PurchaseStatusPaid},
@@ -393,3 +672,25 @@ func (pr *PurchaseRepository) HasPaidPurchases(ctx context.Context, customerID i
 
 	return true, nil
 }
+
+// CountCreatedSince возвращает количество покупок клиента, созданных начиная с since
+func (pr *PurchaseRepository) CountCreatedSince(ctx context.Context, customerID int64, since time.Time) (int, error) {
+	query := sq.Select("COUNT(*)").
+		From("purchase").
+		Where(sq.And{
+			sq.Eq{"customer_id": customerID},
+			sq.GtOrEq{"created_at": since},
+		}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("build query: %w", err)
+	}
+
+	var count int
+	if err := pr.pool.QueryRow(ctx, sql, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count purchases: %w", err)
+	}
+	return count, nil
+}
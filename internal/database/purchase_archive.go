@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// purchaseHistoryView представление со всеми покупками (purchase + purchase_archive)
+const purchaseHistoryView = "purchase_history"
+
+type PurchaseArchiveRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewPurchaseArchiveRepository(pool *pgxpool.Pool) *PurchaseArchiveRepository {
+	return &PurchaseArchiveRepository{pool: pool}
+}
+
+// buildArchivePurchasesQuery переносит до limit покупок, созданных раньше cutoff, в purchase_archive.
+// Последняя покупка клиента по каждому типу инвойса (и последняя оплаченная) остаётся в purchase —
+// на неё опираются проверки активной подписки Tribute и повторной оплаты
+func buildArchivePurchasesQuery(cutoff time.Time, limit int) (string, []interface{}) {
+	columns := strings.Join(purchaseColumns(), ", ")
+	query := fmt.Sprintf(`
+		WITH candidates AS (
+			SELECT id FROM purchase
+			WHERE created_at < $1
+			  AND id NOT IN (
+				  SELECT DISTINCT ON (customer_id, invoice_type, status = '%[2]s') id
+				  FROM purchase
+				  ORDER BY customer_id, invoice_type, status = '%[2]s', created_at DESC
+			  )
+			ORDER BY id
+			LIMIT $2
+		), moved AS (
+			DELETE FROM purchase p
+			USING candidates c
+			WHERE p.id = c.id
+			RETURNING p.*
+		)
+		INSERT INTO purchase_archive (%[1]s)
+		SELECT %[1]s FROM moved
+	`, columns, PurchaseStatusPaid)
+
+	return query, []interface{}{cutoff, limit}
+}
+
+// ArchiveOlderThan переносит покупки старше cutoff в архив пачками по batchSize.
+// Возвращает количество перенесённых покупок
+func (r *PurchaseArchiveRepository) ArchiveOlderThan(ctx context.Context, cutoff time.Time, batchSize int) (int64, error) {
+	sql, args := buildArchivePurchasesQuery(cutoff, batchSize)
+
+	var total int64
+	for {
+		tag, err := r.pool.Exec(ctx, sql, args...)
+		if err != nil {
+			return total, fmt.Errorf("archive purchases: %w", err)
+		}
+		total += tag.RowsAffected()
+		if tag.RowsAffected() < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
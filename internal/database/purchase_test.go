@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 )
@@ -48,3 +49,44 @@ func TestFindLatestActiveTributesByCustomerIDsEmpty(t *testing.T) {
 		t.Fatalf("expected empty result, got %d", len(*result))
 	}
 }
+
+func TestBuildArchivePurchasesQuery(t *testing.T) {
+	cutoff := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sql, args := buildArchivePurchasesQuery(cutoff, 500)
+
+	for _, want := range []string{
+		"DELETE FROM purchase p",
+		"INSERT INTO purchase_archive (" + strings.Join(purchaseColumns(), ", ") + ")",
+		"DISTINCT ON (customer_id, invoice_type, status = 'paid')",
+		"LIMIT $2",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected SQL to contain %q, got: %s", want, sql)
+		}
+	}
+
+	expectedArgs := []interface{}{cutoff, 500}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Fatalf("unexpected args, want %v, got %v", expectedArgs, args)
+	}
+}
+
+func TestBuildStalePendingQuery(t *testing.T) {
+	cutoff := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	sql, args, err := buildStalePendingQuery([]InvoiceType{InvoiceTypeCrypto, InvoiceTypeYookasa}, cutoff, 100).
+		PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() returned error: %v", err)
+	}
+
+	if !strings.Contains(sql, "invoice_type IN ($2,$3)") || !strings.Contains(sql, "created_at < $4") || !strings.Contains(sql, "LIMIT 100") {
+		t.Fatalf("unexpected SQL: %s", sql)
+	}
+
+	expectedArgs := []interface{}{PurchaseStatusPending, InvoiceTypeCrypto, InvoiceTypeYookasa, cutoff}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Fatalf("unexpected args, want %v, got %v", expectedArgs, args)
+	}
+}
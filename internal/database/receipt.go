@@ -0,0 +1,76 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Receipt квитанция об оплате покупки. FileID nil — документ ещё не был отправлен в Telegram
+type Receipt struct {
+	PurchaseID int64     `db:"purchase_id"`
+	CustomerID int64     `db:"customer_id"`
+	Number     string    `db:"number"`
+	FileID     *string   `db:"file_id"`
+	IssuedAt   time.Time `db:"issued_at"`
+}
+
+type ReceiptRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewReceiptRepository(pool *pgxpool.Pool) *ReceiptRepository {
+	return &ReceiptRepository{pool: pool}
+}
+
+func (r *ReceiptRepository) FindByPurchaseID(ctx context.Context, purchaseID int64) (*Receipt, error) {
+	var receipt Receipt
+	err := r.pool.QueryRow(ctx,
+		"SELECT purchase_id, customer_id, number, file_id, issued_at FROM purchase_receipt WHERE purchase_id = $1", purchaseID).
+		Scan(&receipt.PurchaseID, &receipt.CustomerID, &receipt.Number, &receipt.FileID, &receipt.IssuedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find receipt: %w", err)
+	}
+	return &receipt, nil
+}
+
+// Issue выдаёт покупке номер квитанции вида <prefix>-000042. Повторный вызов возвращает уже выданную квитанцию
+func (r *ReceiptRepository) Issue(ctx context.Context, purchaseID, customerID int64, prefix string) (*Receipt, error) {
+	existing, err := r.FindByPurchaseID(ctx, purchaseID)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+
+	_, err = r.pool.Exec(ctx, `
+		INSERT INTO purchase_receipt (purchase_id, customer_id, number)
+		VALUES ($1, $2, $3 || '-' || lpad(nextval('purchase_receipt_number_seq')::text, 6, '0'))
+		ON CONFLICT (purchase_id) DO NOTHING`, purchaseID, customerID, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue receipt: %w", err)
+	}
+
+	receipt, err := r.FindByPurchaseID(ctx, purchaseID)
+	if err != nil {
+		return nil, err
+	}
+	if receipt == nil {
+		return nil, fmt.Errorf("receipt for purchase %d not found after insert", purchaseID)
+	}
+	return receipt, nil
+}
+
+// SetFileID сохраняет file_id отправленного документа для повторной отправки
+func (r *ReceiptRepository) SetFileID(ctx context.Context, purchaseID int64, fileID string) error {
+	_, err := r.pool.Exec(ctx, "UPDATE purchase_receipt SET file_id = $2 WHERE purchase_id = $1", purchaseID, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to save receipt file id: %w", err)
+	}
+	return nil
+}
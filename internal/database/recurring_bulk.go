@@ -0,0 +1,272 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// RecurringSegment клиенты с включённым автопродлением по одному тарифу. TariffName nil — без тарифа
+type RecurringSegment struct {
+	TariffName *string
+	Count      int
+}
+
+// RecurringBulkDisable массовое отключение автопродления. TariffName nil — у всех клиентов
+type RecurringBulkDisable struct {
+	ID          int64
+	TariffName  *string
+	CustomerIDs []int64
+	CreatedBy   *int64
+	CreatedAt   time.Time
+	UndoUntil   time.Time
+	UndoneAt    *time.Time
+	NotifiedAt  *time.Time
+}
+
+var recurringBulkDisableColumns = []string{"id", "tariff_name", "customer_ids", "created_by", "created_at", "undo_until", "undone_at", "notified_at"}
+
+type RecurringBulkRepository struct {
+	pool *pgxpool.Pool
+	// readPool реплика для подсчёта сегментов. Совпадает с pool, если реплика не настроена
+	readPool *pgxpool.Pool
+}
+
+func NewRecurringBulkRepository(pool *pgxpool.Pool) *RecurringBulkRepository {
+	return &RecurringBulkRepository{pool: pool, readPool: pool}
+}
+
+// SetReadPool направляет аналитические запросы в реплику только для чтения. nil — в основной пул
+func (r *RecurringBulkRepository) SetReadPool(pool *pgxpool.Pool) {
+	if pool != nil {
+		r.readPool = pool
+	}
+}
+
+// recurringSegmentCondition клиенты, с которых сейчас списывается автопродление, по тарифу или все (tariff nil)
+func recurringSegmentCondition(tariff *string) sq.And {
+	cond := sq.And{
+		sq.Eq{"recurring_enabled": true},
+		sq.NotEq{"payment_method_id": nil},
+		notDeleted,
+	}
+	if tariff != nil {
+		cond = append(cond, sq.Eq{"recurring_tariff_name": *tariff})
+	}
+	return cond
+}
+
+// Segments возвращает клиентов с включённым автопродлением по тарифам, по убыванию числа клиентов
+func (r *RecurringBulkRepository) Segments(ctx context.Context) ([]RecurringSegment, error) {
+	sql, args, err := sq.Select("recurring_tariff_name", "COUNT(*)").
+		From("customer").
+		Where(recurringSegmentCondition(nil)).
+		GroupBy("recurring_tariff_name").
+		OrderBy("COUNT(*) DESC", "recurring_tariff_name").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build recurring segments query: %w", err)
+	}
+
+	rows, err := r.readPool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recurring segments: %w", err)
+	}
+	defer rows.Close()
+
+	var segments []RecurringSegment
+	for rows.Next() {
+		var s RecurringSegment
+		if err := rows.Scan(&s.TariffName, &s.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan recurring segment: %w", err)
+		}
+		segments = append(segments, s)
+	}
+	return segments, rows.Err()
+}
+
+// buildRecurringBulkDisableQuery отключает автопродление сегмента, отмечает клиентов операцией opID
+// и возвращает их id. Способ оплаты сохраняется, поэтому операцию можно отменить
+func buildRecurringBulkDisableQuery(tariff *string, opID int64) sq.UpdateBuilder {
+	return sq.Update("customer").
+		Set("recurring_enabled", false).
+		Set("recurring_bulk_disable_id", opID).
+		Where(recurringSegmentCondition(tariff)).
+		Suffix("RETURNING id")
+}
+
+// Disable отключает автопродление сегмента и сохраняет операцию, которую можно отменить до undoUntil
+func (r *RecurringBulkRepository) Disable(ctx context.Context, tariff *string, createdBy int64, undoUntil time.Time) (*RecurringBulkDisable, error) {
+	defer cachedCustomers.invalidateAll()
+	var op *RecurringBulkDisable
+	err := r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var opID int64
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO recurring_bulk_disable (tariff_name, customer_ids, created_by, undo_until)
+			VALUES ($1, '{}', $2, $3)
+			RETURNING id`,
+			tariff, createdBy, undoUntil).Scan(&opID); err != nil {
+			return err
+		}
+
+		sql, args, err := buildRecurringBulkDisableQuery(tariff, opID).PlaceholderFormat(sq.Dollar).ToSql()
+		if err != nil {
+			return err
+		}
+		rows, err := tx.Query(ctx, sql, args...)
+		if err != nil {
+			return err
+		}
+		customerIDs := []int64{}
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			customerIDs = append(customerIDs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		op, err = scanRecurringBulkDisable(tx.QueryRow(ctx, `
+			UPDATE recurring_bulk_disable SET customer_ids = $2
+			WHERE id = $1
+			RETURNING `+strings.Join(recurringBulkDisableColumns, ", "),
+			opID, customerIDs))
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to disable recurring for segment: %w", err)
+	}
+	return op, nil
+}
+
+// Undo включает автопродление обратно клиентам операции, если срок отмены не истёк. Клиенты, удалившие
+// карту или сами переключившие автопродление за это время, не затрагиваются. Возвращает число клиентов
+// с восстановленным автопродлением и false, если операцию уже нельзя отменить
+func (r *RecurringBulkRepository) Undo(ctx context.Context, id int64, now time.Time) (int, bool, error) {
+	defer cachedCustomers.invalidateAll()
+	restored := 0
+	undone := false
+	err := r.pool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var customerIDs []int64
+		err := tx.QueryRow(ctx, `
+			UPDATE recurring_bulk_disable SET undone_at = $2
+			WHERE id = $1 AND undone_at IS NULL AND notified_at IS NULL AND undo_until > $2
+			RETURNING customer_ids`,
+			id, now).Scan(&customerIDs)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		undone = true
+
+		tag, err := tx.Exec(ctx, `
+			UPDATE customer SET recurring_enabled = true, recurring_bulk_disable_id = NULL
+			WHERE id = ANY($1) AND recurring_bulk_disable_id = $2 AND recurring_enabled = false AND payment_method_id IS NOT NULL`,
+			customerIDs, id)
+		if err != nil {
+			return err
+		}
+		restored = int(tag.RowsAffected())
+		return nil
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to undo recurring bulk disable: %w", err)
+	}
+	return restored, undone, nil
+}
+
+// FindByID возвращает операцию или nil, если её нет
+func (r *RecurringBulkRepository) FindByID(ctx context.Context, id int64) (*RecurringBulkDisable, error) {
+	sql, args, err := sq.Select(recurringBulkDisableColumns...).
+		From("recurring_bulk_disable").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select query: %w", err)
+	}
+
+	op, err := scanRecurringBulkDisable(r.pool.QueryRow(ctx, sql, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recurring bulk disable: %w", err)
+	}
+	return op, nil
+}
+
+// ClaimDue отмечает уведомлённой первую операцию, срок отмены которой истёк, и возвращает её (nil если таких нет).
+// Отметка ставится до отправки уведомлений, поэтому при сбое они не повторяются
+func (r *RecurringBulkRepository) ClaimDue(ctx context.Context, now time.Time) (*RecurringBulkDisable, error) {
+	op, err := scanRecurringBulkDisable(r.pool.QueryRow(ctx, `
+		UPDATE recurring_bulk_disable SET notified_at = $1
+		WHERE id = (
+			SELECT id FROM recurring_bulk_disable
+			WHERE undone_at IS NULL AND notified_at IS NULL AND undo_until <= $1
+			ORDER BY undo_until
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+strings.Join(recurringBulkDisableColumns, ", "),
+		now))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim recurring bulk disable: %w", err)
+	}
+	return op, nil
+}
+
+// Recipients возвращает клиентов операции, у которых автопродление всё ещё отключено этой операцией
+func (r *RecurringBulkRepository) Recipients(ctx context.Context, op *RecurringBulkDisable) ([]Customer, error) {
+	sql, args, err := sq.Select(customerColumns()...).
+		From("customer").
+		Where(sq.Expr("id = ANY(?)", op.CustomerIDs)).
+		Where(sq.Eq{"recurring_enabled": false, "recurring_bulk_disable_id": op.ID}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recurring bulk recipients: %w", err)
+	}
+	defer rows.Close()
+
+	var customers []Customer
+	for rows.Next() {
+		customer, err := scanCustomerFromRows(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan customer row: %w", err)
+		}
+		customers = append(customers, *customer)
+	}
+	return customers, rows.Err()
+}
+
+func scanRecurringBulkDisable(row pgx.Row) (*RecurringBulkDisable, error) {
+	var op RecurringBulkDisable
+	err := row.Scan(&op.ID, &op.TariffName, &op.CustomerIDs, &op.CreatedBy, &op.CreatedAt, &op.UndoUntil, &op.UndoneAt, &op.NotifiedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+func TestBuildRecurringBulkDisableQuery(t *testing.T) {
+	tariff := "PRO"
+	sql, args, err := buildRecurringBulkDisableQuery(&tariff, 9).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"UPDATE customer SET recurring_enabled = $1, recurring_bulk_disable_id = $2",
+		"recurring_enabled = $3",
+		"payment_method_id IS NOT NULL",
+		"recurring_tariff_name = $4",
+		"RETURNING id",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("SQL does not contain %q: %s", want, sql)
+		}
+	}
+	if want := []interface{}{false, int64(9), true, "PRO"}; !reflect.DeepEqual(args, want) {
+		t.Fatalf("unexpected args, want %v, got %v", want, args)
+	}
+
+	sql, _, err = buildRecurringBulkDisableQuery(nil, 9).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() returned error: %v", err)
+	}
+	if strings.Contains(sql, "recurring_tariff_name") {
+		t.Fatalf("segment of all customers must not filter by tariff: %s", sql)
+	}
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// recurringConfirmWindow сколько действует запрос подтверждения: запрос отправляется за сутки до списания,
+// запас — на задержку события окончания подписки. Подтверждение прошлого продления к следующему не переносится
+const recurringConfirmWindow = 48 * time.Hour
+
+// IsRecurringChargeConfirmed возвращает true, если клиент подтвердил автосписание в момент at:
+// запрос подтверждения отправлен не раньше recurringConfirmWindow и клиент нажал кнопку после запроса
+func IsRecurringChargeConfirmed(c *Customer, at time.Time) bool {
+	if c.RecurringConfirmRequestedAt == nil || c.RecurringConfirmedAt == nil {
+		return false
+	}
+	return at.Sub(*c.RecurringConfirmRequestedAt) <= recurringConfirmWindow && !c.RecurringConfirmedAt.Before(*c.RecurringConfirmRequestedAt)
+}
+
+// RecurringConfirmationRequest поля клиента, которые сохраняются при запросе подтверждения ближайшего автосписания.
+// Прежнее подтверждение сбрасывается
+func RecurringConfirmationRequest(at time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"recurring_confirm_requested_at": at,
+		"recurring_confirmed_at":         nil,
+	}
+}
+
+// ConfirmRecurringCharge отмечает, что клиент подтвердил ближайшее автосписание.
+// Возвращает false, если автопродление выключено или подтверждение не запрашивалось
+func (cr *CustomerRepository) ConfirmRecurringCharge(ctx context.Context, id int64, at time.Time) (bool, error) {
+	defer cachedCustomers.invalidate(id)
+	sql, args, err := sq.Update("customer").
+		Set("recurring_confirmed_at", at).
+		Where(sq.Eq{"id": id, "recurring_enabled": true}).
+		Where(sq.NotEq{"recurring_confirm_requested_at": nil}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build update query: %w", err)
+	}
+
+	tag, err := cr.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to confirm recurring charge: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// clearRecurringPriceLock сбрасывает снимок тарифа и запланированное изменение цены вместе с настройками автопродления
+var clearRecurringPriceLock = map[string]interface{}{
+	"recurring_list_price":           nil,
+	"recurring_device_limit":         nil,
+	"recurring_locked_at":            nil,
+	"recurring_pending_amount":       nil,
+	"recurring_pending_effective_at": nil,
+	"recurring_pending_consented_at": nil,
+}
+
+// clearPendingRecurringPrice отменяет запланированное изменение цены автопродления
+func clearPendingRecurringPrice() map[string]interface{} {
+	return map[string]interface{}{
+		"recurring_pending_amount":       nil,
+		"recurring_pending_effective_at": nil,
+		"recurring_pending_consented_at": nil,
+	}
+}
+
+// RecurringCharge сумма очередного автосписания с учётом запланированного изменения цены
+type RecurringCharge struct {
+	Amount int
+	// Updates поля клиента, которые нужно сохранить до списания: изменение цены вступило в силу или отменено
+	Updates map[string]interface{}
+	// ConsentRequired повышение цены вступило в силу, но клиент его не подтвердил — списывать нельзя
+	ConsentRequired bool
+}
+
+// HasPendingRecurringPriceIncrease возвращает true, если запланировано повышение цены автопродления
+func HasPendingRecurringPriceIncrease(c *Customer) bool {
+	return c.RecurringPendingAmount != nil && c.RecurringAmount != nil && *c.RecurringPendingAmount > *c.RecurringAmount
+}
+
+// RecurringChargeAt возвращает, сколько списать за автопродление в момент at. До даты вступления изменения
+// в силу списывается зафиксированная цена, после — новая: уменьшение применяется всегда,
+// повышение — только с согласия клиента
+func RecurringChargeAt(c *Customer, at time.Time) RecurringCharge {
+	amount := 0
+	if c.RecurringAmount != nil {
+		amount = *c.RecurringAmount
+	}
+	if c.RecurringPendingAmount == nil || c.RecurringPendingEffectiveAt == nil || at.Before(*c.RecurringPendingEffectiveAt) {
+		return RecurringCharge{Amount: amount}
+	}
+	if HasPendingRecurringPriceIncrease(c) && c.RecurringPendingConsentedAt == nil {
+		return RecurringCharge{Amount: amount, Updates: clearPendingRecurringPrice(), ConsentRequired: true}
+	}
+
+	pending := *c.RecurringPendingAmount
+	updates := clearPendingRecurringPrice()
+	updates["recurring_amount"] = pending
+	updates["recurring_locked_at"] = at
+	if c.RecurringListPrice != nil {
+		updates["recurring_list_price"] = *c.RecurringListPrice + pending - amount
+	}
+	return RecurringCharge{Amount: pending, Updates: updates}
+}
+
+// RepricedRecurringAmount возвращает новую сумму автопродления, если цена тарифа клиента стала listPrice.
+// Скидка клиента относительно цены тарифа на момент подключения сохраняется.
+// false — цена тарифа не изменилась или это изменение уже запланировано
+func RepricedRecurringAmount(c *Customer, listPrice int) (int, bool) {
+	if c.RecurringAmount == nil || listPrice <= 0 {
+		return 0, false
+	}
+	locked := *c.RecurringAmount
+	lockedListPrice := locked
+	if c.RecurringListPrice != nil {
+		lockedListPrice = *c.RecurringListPrice
+	}
+	if listPrice == lockedListPrice {
+		return 0, false
+	}
+
+	amount := max(locked+listPrice-lockedListPrice, 1)
+	if amount == locked || (c.RecurringPendingAmount != nil && *c.RecurringPendingAmount == amount) {
+		return 0, false
+	}
+	return amount, true
+}
+
+// ScheduleRecurringPriceChange планирует новую сумму автопродления с даты effectiveAt.
+// Прежнее согласие клиента на повышение сбрасывается
+func (cr *CustomerRepository) ScheduleRecurringPriceChange(ctx context.Context, id int64, amount int, effectiveAt time.Time) error {
+	return cr.UpdateFields(ctx, id, map[string]interface{}{
+		"recurring_pending_amount":       amount,
+		"recurring_pending_effective_at": effectiveAt,
+		"recurring_pending_consented_at": nil,
+	})
+}
+
+// AcceptRecurringPriceChange отмечает согласие клиента с запланированной ценой.
+// Возвращает false, если изменения цены не запланировано
+func (cr *CustomerRepository) AcceptRecurringPriceChange(ctx context.Context, id int64, at time.Time) (bool, error) {
+	defer cachedCustomers.invalidate(id)
+	sql, args, err := sq.Update("customer").
+		Set("recurring_pending_consented_at", at).
+		Where(sq.Eq{"id": id}).
+		Where(sq.NotEq{"recurring_pending_amount": nil}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("failed to build update query: %w", err)
+	}
+
+	tag, err := cr.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to accept recurring price change: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRepricedRecurringAmount(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	tests := []struct {
+		name       string
+		customer   Customer
+		listPrice  int
+		wantAmount int
+		wantOK     bool
+	}{
+		{"price unchanged", Customer{RecurringAmount: intPtr(500), RecurringListPrice: intPtr(500)}, 500, 0, false},
+		{"price increased", Customer{RecurringAmount: intPtr(500), RecurringListPrice: intPtr(500)}, 600, 600, true},
+		{"price decreased", Customer{RecurringAmount: intPtr(500), RecurringListPrice: intPtr(500)}, 450, 450, true},
+		{"discount is kept", Customer{RecurringAmount: intPtr(300), RecurringListPrice: intPtr(500)}, 600, 400, true},
+		{"discounted price unchanged", Customer{RecurringAmount: intPtr(300), RecurringListPrice: intPtr(500)}, 500, 0, false},
+		{"legacy enrollment without snapshot", Customer{RecurringAmount: intPtr(500)}, 600, 600, true},
+		{"already scheduled", Customer{RecurringAmount: intPtr(500), RecurringListPrice: intPtr(500), RecurringPendingAmount: intPtr(600)}, 600, 0, false},
+		{"never below one", Customer{RecurringAmount: intPtr(50), RecurringListPrice: intPtr(500)}, 100, 1, true},
+		{"unknown tariff", Customer{RecurringAmount: intPtr(500), RecurringListPrice: intPtr(500)}, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, ok := RepricedRecurringAmount(&tt.customer, tt.listPrice)
+			if amount != tt.wantAmount || ok != tt.wantOK {
+				t.Errorf("RepricedRecurringAmount() = %d, %v, want %d, %v", amount, ok, tt.wantAmount, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRecurringChargeAt(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	effectiveAt := now.AddDate(0, 0, -1)
+	later := now.AddDate(0, 0, 3)
+
+	tests := []struct {
+		name            string
+		customer        Customer
+		wantAmount      int
+		wantApplied     bool
+		wantConsentNeed bool
+	}{
+		{"no pending change", Customer{RecurringAmount: intPtr(500)}, 500, false, false},
+		{"change not in effect yet", Customer{RecurringAmount: intPtr(500), RecurringPendingAmount: intPtr(600), RecurringPendingEffectiveAt: &later}, 500, false, false},
+		{"decrease in effect", Customer{RecurringAmount: intPtr(500), RecurringListPrice: intPtr(500), RecurringPendingAmount: intPtr(450), RecurringPendingEffectiveAt: &effectiveAt}, 450, true, false},
+		{"increase without consent", Customer{RecurringAmount: intPtr(500), RecurringPendingAmount: intPtr(600), RecurringPendingEffectiveAt: &effectiveAt}, 500, false, true},
+		{"increase with consent", Customer{RecurringAmount: intPtr(500), RecurringListPrice: intPtr(500), RecurringPendingAmount: intPtr(600), RecurringPendingEffectiveAt: &effectiveAt, RecurringPendingConsentedAt: &effectiveAt}, 600, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RecurringChargeAt(&tt.customer, now)
+			applied := got.Updates["recurring_amount"] != nil
+			if got.Amount != tt.wantAmount || applied != tt.wantApplied || got.ConsentRequired != tt.wantConsentNeed {
+				t.Errorf("RecurringChargeAt() = %+v, want amount %d, applied %v, consent required %v", got, tt.wantAmount, tt.wantApplied, tt.wantConsentNeed)
+			}
+			if tt.wantApplied && got.Updates["recurring_list_price"] != tt.wantAmount {
+				t.Errorf("Expected list price snapshot to follow the new amount, got %v", got.Updates["recurring_list_price"])
+			}
+		})
+	}
+}
@@ -0,0 +1,831 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/database/dbtest"
+)
+
+func TestCustomerRepositoryFindTrialUsersForInactiveNotification(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	repo := NewCustomerRepository(pool)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	clock.SetDefault(clock.NewFake(now))
+	t.Cleanup(func() { clock.SetDefault(nil) })
+
+	trialEnd := now.Add(48 * time.Hour)
+	inWindow := createTestCustomer(t, repo, 1, map[string]interface{}{"created_at": now.Add(-90 * time.Minute), "expire_at": trialEnd})
+	createTestCustomer(t, repo, 2, map[string]interface{}{"created_at": now.Add(-30 * time.Minute), "expire_at": trialEnd})
+	createTestCustomer(t, repo, 3, map[string]interface{}{"created_at": now.Add(-3 * time.Hour), "expire_at": trialEnd})
+	createTestCustomer(t, repo, 4, map[string]interface{}{"created_at": now.Add(-90 * time.Minute), "expire_at": trialEnd, "trial_inactive_notified_at": now})
+	createTestCustomer(t, repo, 5, map[string]interface{}{"created_at": now.Add(-90 * time.Minute), "expire_at": now.Add(-time.Minute)})
+	createTestCustomer(t, repo, 6, map[string]interface{}{"created_at": now.Add(-90 * time.Minute)})
+	paying := createTestCustomer(t, repo, 7, map[string]interface{}{"created_at": now.Add(-90 * time.Minute), "expire_at": trialEnd})
+	createTestPurchase(t, pool, paying.ID, PurchaseStatusPaid)
+	pending := createTestCustomer(t, repo, 8, map[string]interface{}{"created_at": now.Add(-90 * time.Minute), "expire_at": trialEnd})
+	createTestPurchase(t, pool, pending.ID, PurchaseStatusPending)
+
+	customers, err := repo.FindTrialUsersForInactiveNotification(ctx)
+	if err != nil {
+		t.Fatalf("FindTrialUsersForInactiveNotification() returned error: %v", err)
+	}
+
+	found := map[int64]bool{}
+	for _, c := range customers {
+		found[c.TelegramID] = true
+	}
+	if len(found) != 2 || !found[inWindow.TelegramID] || !found[pending.TelegramID] {
+		t.Fatalf("Expected customers 1 and 8 (unpaid invoice is still a trial), got %v", found)
+	}
+}
+
+func TestCustomerRepositoryUpdateBatch(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	repo := NewCustomerRepository(pool)
+
+	oldExpire := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	oldLink := "https://sub.example.com/old"
+	updated := createTestCustomer(t, repo, 100, map[string]interface{}{"expire_at": oldExpire, "subscription_link": oldLink})
+	untouched := createTestCustomer(t, repo, 200, map[string]interface{}{"expire_at": oldExpire, "subscription_link": oldLink})
+
+	newExpire := time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC)
+	newLink := "https://sub.example.com/new"
+	err := repo.UpdateBatch(ctx, []Customer{
+		{TelegramID: updated.TelegramID, ExpireAt: &newExpire, SubscriptionLink: &newLink},
+		{TelegramID: 300, ExpireAt: &newExpire, SubscriptionLink: &newLink},
+	})
+	if err != nil {
+		t.Fatalf("UpdateBatch() returned error: %v", err)
+	}
+
+	got, err := repo.FindById(ctx, updated.ID)
+	if err != nil {
+		t.Fatalf("FindById() returned error: %v", err)
+	}
+	if got.ExpireAt == nil || !got.ExpireAt.Equal(newExpire) || got.SubscriptionLink == nil || *got.SubscriptionLink != newLink {
+		t.Errorf("Expected expire_at %s and link %s, got %v and %v", newExpire, newLink, got.ExpireAt, got.SubscriptionLink)
+	}
+
+	got, err = repo.FindById(ctx, untouched.ID)
+	if err != nil {
+		t.Fatalf("FindById() returned error: %v", err)
+	}
+	if got.ExpireAt == nil || !got.ExpireAt.Equal(oldExpire) || *got.SubscriptionLink != oldLink {
+		t.Errorf("Customer outside the batch changed: %v, %v", got.ExpireAt, *got.SubscriptionLink)
+	}
+
+	existing, err := repo.FindByTelegramIds(ctx, []int64{300})
+	if err != nil || len(existing) != 0 {
+		t.Errorf("UpdateBatch must not create customers, got %v (err %v)", existing, err)
+	}
+}
+
+func TestCustomerRepositorySoftDeleteRestoreAndPurge(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	repo := NewCustomerRepository(pool)
+
+	inPanel := createTestCustomer(t, repo, 1, nil)
+	gone := createTestCustomer(t, repo, 2, nil)
+	createTestPurchase(t, pool, gone.ID, PurchaseStatusPaid)
+	returning := createTestCustomer(t, repo, 3, nil)
+
+	deletedAt := time.Now().UTC().Add(-48 * time.Hour).Truncate(time.Second)
+	deleted, err := repo.SoftDeleteByNotInTelegramIds(ctx, []int64{inPanel.TelegramID}, deletedAt)
+	if err != nil || deleted != 2 {
+		t.Fatalf("SoftDeleteByNotInTelegramIds() = %d, %v, want 2", deleted, err)
+	}
+	if found, err := repo.FindByTelegramId(ctx, gone.TelegramID); err != nil || found != nil {
+		t.Fatalf("Expected soft-deleted customer to be hidden, got %v (err %v)", found, err)
+	}
+	if all, err := repo.FindAll(ctx); err != nil || len(all) != 1 || all[0].ID != inPanel.ID {
+		t.Fatalf("Expected only customer 1 in FindAll, got %v (err %v)", all, err)
+	}
+	if found, err := repo.FindById(ctx, gone.ID); err != nil || found == nil || found.DeletedAt == nil {
+		t.Fatalf("Expected FindById to return the soft-deleted customer, got %v (err %v)", found, err)
+	}
+
+	// Клиент снова появился в панели: синхронизация обновляет его и снимает пометку
+	if err := repo.UpdateBatch(ctx, []Customer{{TelegramID: returning.TelegramID, ExpireAt: &deletedAt}}); err != nil {
+		t.Fatalf("UpdateBatch() returned error: %v", err)
+	}
+	if found, err := repo.FindByTelegramId(ctx, returning.TelegramID); err != nil || found == nil {
+		t.Fatalf("Expected customer 3 to be restored, got %v (err %v)", found, err)
+	}
+
+	total, purgeable, err := repo.DeletedStats(ctx, time.Now())
+	if err != nil || total != 1 || purgeable != 1 {
+		t.Fatalf("DeletedStats() = %d, %d, %v, want 1, 1", total, purgeable, err)
+	}
+	if purged, err := repo.PurgeDeleted(ctx, deletedAt); err != nil || purged != 0 {
+		t.Fatalf("Expected nothing to be purged within retention, got %d (err %v)", purged, err)
+	}
+	if purged, err := repo.PurgeDeleted(ctx, time.Now()); err != nil || purged != 1 {
+		t.Fatalf("PurgeDeleted() = %d, %v, want 1", purged, err)
+	}
+	if found, err := repo.FindById(ctx, gone.ID); err != nil || found != nil {
+		t.Errorf("Expected purged customer to be deleted, got %v (err %v)", found, err)
+	}
+
+	// Повторный /start восстанавливает клиента вместо создания нового
+	if _, err := repo.SoftDeleteByNotInTelegramIds(ctx, nil, deletedAt); err != nil {
+		t.Fatalf("SoftDeleteByNotInTelegramIds() returned error: %v", err)
+	}
+	restored, err := repo.Create(ctx, &Customer{TelegramID: inPanel.TelegramID, Language: "ru"})
+	if err != nil || restored.ID != inPanel.ID || restored.DeletedAt != nil {
+		t.Errorf("Expected /start to restore customer 1, got %+v (err %v)", restored, err)
+	}
+}
+
+func TestCustomerRepositoryAssignPromoOffer(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	repo := NewCustomerRepository(pool)
+
+	target := createTestCustomer(t, repo, 1, map[string]interface{}{"promo_offer_reminded_at": time.Now()})
+	other := createTestCustomer(t, repo, 2, nil)
+
+	expiresAt := time.Now().UTC().Add(48 * time.Hour).Truncate(time.Second)
+	updated, err := repo.AssignPromoOffer(ctx, []int64{target.ID}, 199, 3, 1, expiresAt)
+	if err != nil || updated != 1 {
+		t.Fatalf("AssignPromoOffer() = %d, %v, want 1", updated, err)
+	}
+
+	found, err := repo.FindById(ctx, target.ID)
+	if err != nil {
+		t.Fatalf("FindById() returned error: %v", err)
+	}
+	if found.PromoOfferPrice == nil || *found.PromoOfferPrice != 199 || *found.PromoOfferDevices != 3 || *found.PromoOfferMonths != 1 {
+		t.Errorf("Expected offer 199/3/1, got %+v", found)
+	}
+	var reminded bool
+	if err := pool.QueryRow(ctx, `SELECT promo_offer_reminded_at IS NOT NULL FROM customer WHERE id = $1`, target.ID).Scan(&reminded); err != nil {
+		t.Fatalf("Failed to query reminder: %v", err)
+	}
+	if found.PromoOfferCodeID != nil || reminded {
+		t.Errorf("Expected offer without code and reminder, got code %v reminded %v", found.PromoOfferCodeID, reminded)
+	}
+	if found, err := repo.FindById(ctx, other.ID); err != nil || found.PromoOfferPrice != nil {
+		t.Errorf("Expected customer outside the segment to keep no offer, got %+v (err %v)", found, err)
+	}
+}
+
+func TestPromoRepositoryActivations(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	customers := NewCustomerRepository(pool)
+	repo := NewPromoRepository(pool)
+
+	first := createTestCustomer(t, customers, 1, nil)
+	second := createTestCustomer(t, customers, 2, nil)
+	promo, err := repo.Create(ctx, "SUMMER", 7, 10, 999, nil)
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+
+	channel := "vk"
+	for _, activation := range []struct {
+		customerID int64
+		channel    *string
+	}{{first.ID, &channel}, {second.ID, nil}} {
+		if err := repo.RecordActivation(ctx, promo.ID, activation.customerID, activation.channel); err != nil {
+			t.Fatalf("RecordActivation() returned error: %v", err)
+		}
+		if err := repo.IncrementActivations(ctx, promo.ID); err != nil {
+			t.Fatalf("IncrementActivations() returned error: %v", err)
+		}
+	}
+	if err := repo.RecordActivation(ctx, promo.ID, first.ID, nil); err == nil {
+		t.Error("Expected repeated activation by the same customer to fail")
+	}
+
+	got, err := repo.FindByID(ctx, promo.ID)
+	if err != nil {
+		t.Fatalf("FindByID() returned error: %v", err)
+	}
+	if got.CurrentActivations != 2 {
+		t.Errorf("Expected 2 activations, got %d", got.CurrentActivations)
+	}
+
+	used, err := repo.IsUsedByCustomer(ctx, promo.ID, first.ID)
+	if err != nil || !used {
+		t.Errorf("Expected promo to be used by the first customer, got %v (err %v)", used, err)
+	}
+	third := createTestCustomer(t, customers, 3, nil)
+	if used, err := repo.IsUsedByCustomer(ctx, promo.ID, third.ID); err != nil || used {
+		t.Errorf("Expected promo not to be used by the third customer, got %v (err %v)", used, err)
+	}
+
+	channels, err := repo.CountActivationsByChannel(ctx, promo.ID)
+	if err != nil {
+		t.Fatalf("CountActivationsByChannel() returned error: %v", err)
+	}
+	counts := map[string]int{}
+	for _, c := range channels {
+		counts[c.Channel] = c.Count
+	}
+	if len(counts) != 2 || counts["vk"] != 1 || counts[""] != 1 {
+		t.Errorf("Unexpected activations by channel: %v", channels)
+	}
+}
+
+func TestBroadcastRepositoryFrequencyCap(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	customers := NewCustomerRepository(pool)
+	repo := NewBroadcastRepository(pool)
+
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	windowSince := now.AddDate(0, 0, -7)
+	capped := createTestCustomer(t, customers, 1, nil)
+	fresh := createTestCustomer(t, customers, 2, nil)
+
+	for i := 0; i < 2; i++ {
+		if err := repo.RecordDelivery(ctx, capped.ID, now.Add(time.Duration(i)*time.Hour), windowSince); err != nil {
+			t.Fatalf("RecordDelivery() returned error: %v", err)
+		}
+	}
+
+	excluded, err := repo.FindExcludedCustomerIDs(ctx, BroadcastExclusion{FrequencyCap: 2, FrequencyWindowSince: windowSince})
+	if err != nil {
+		t.Fatalf("FindExcludedCustomerIDs() returned error: %v", err)
+	}
+	if !excluded[capped.ID] || excluded[fresh.ID] {
+		t.Fatalf("Expected only the capped customer to be excluded, got %v", excluded)
+	}
+
+	// Окно, начатое до windowSince, закончилось: следующая доставка начинает новое окно
+	later := now.AddDate(0, 0, 8)
+	if err := repo.RecordDelivery(ctx, capped.ID, later, later.AddDate(0, 0, -7)); err != nil {
+		t.Fatalf("RecordDelivery() returned error: %v", err)
+	}
+	excluded, err = repo.FindExcludedCustomerIDs(ctx, BroadcastExclusion{FrequencyCap: 2, FrequencyWindowSince: later.AddDate(0, 0, -7)})
+	if err != nil {
+		t.Fatalf("FindExcludedCustomerIDs() returned error: %v", err)
+	}
+	if excluded[capped.ID] {
+		t.Fatal("Expected the frequency window to restart after it expired")
+	}
+}
+
+func TestBroadcastRepositoryClaimInterrupted(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	repo := NewBroadcastRepository(pool)
+
+	id, err := repo.Create(ctx, "all", "hello")
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	if err := repo.SetOptions(ctx, id, []byte(`{"MiniAppURL":"https://app"}`)); err != nil {
+		t.Fatalf("SetOptions() returned error: %v", err)
+	}
+	if err := repo.Interrupt(ctx, id, 42, 5, 1); err != nil {
+		t.Fatalf("Interrupt() returned error: %v", err)
+	}
+
+	claimed, err := repo.ClaimInterrupted(ctx)
+	if err != nil || len(claimed) != 1 {
+		t.Fatalf("ClaimInterrupted() = %+v, %v; expected one broadcast", claimed, err)
+	}
+	got := claimed[0]
+	if got.ID != id || got.LastCustomerID != 42 || got.SentCount != 5 || got.FailedCount != 1 || !strings.Contains(string(got.Options), "https://app") {
+		t.Errorf("ClaimInterrupted() = %+v; expected saved position and options", got)
+	}
+	if again, err := repo.ClaimInterrupted(ctx); err != nil || len(again) != 0 {
+		t.Errorf("second ClaimInterrupted() = %+v, %v; expected the broadcast to be claimed once", again, err)
+	}
+}
+
+func TestPrivacyRepositoryAnonymize(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	customers := NewCustomerRepository(pool)
+	repo := NewPrivacyRepository(pool)
+
+	link := "https://sub.example.com/abc"
+	customer := createTestCustomer(t, customers, 42, map[string]interface{}{"subscription_link": link, "source": "vk"})
+	referrer := createTestCustomer(t, customers, 43, nil)
+	if _, err := NewReferralRepository(pool).Create(ctx, referrer.TelegramID, customer.TelegramID); err != nil {
+		t.Fatalf("Referral Create() returned error: %v", err)
+	}
+	if _, err := NewCustomerNoteRepository(pool).AddTag(ctx, customer.ID, "vip"); err != nil {
+		t.Fatalf("AddTag() returned error: %v", err)
+	}
+	purchaseID := createTestPurchase(t, pool, customer.ID, PurchaseStatusPaid)
+	if _, err := pool.Exec(ctx, "INSERT INTO trial_upsell_send (step, customer_id, trial_activated_at) VALUES ('day1', $1, NOW())", customer.ID); err != nil {
+		t.Fatalf("Failed to insert trial upsell send: %v", err)
+	}
+
+	export, err := repo.Export(ctx, customer)
+	if err != nil {
+		t.Fatalf("Export() returned error: %v", err)
+	}
+	var referrals []map[string]interface{}
+	if err := json.Unmarshal(export["referrals"], &referrals); err != nil || len(referrals) != 1 {
+		t.Errorf("Expected one referral in export, got %s (err %v)", export["referrals"], err)
+	}
+	var upsells []map[string]interface{}
+	if err := json.Unmarshal(export["trial_upsell_messages"], &upsells); err != nil || len(upsells) != 1 || upsells[0]["step"] != "day1" {
+		t.Errorf("Expected one trial upsell message in export, got %s (err %v)", export["trial_upsell_messages"], err)
+	}
+
+	anonymized, err := repo.Anonymize(ctx, customer, time.Now())
+	if err != nil || !anonymized {
+		t.Fatalf("Anonymize() = %v, %v", anonymized, err)
+	}
+	if again, err := repo.Anonymize(ctx, customer, time.Now()); err != nil || again {
+		t.Errorf("Expected repeated Anonymize() to be a no-op, got %v (err %v)", again, err)
+	}
+
+	if found, _ := customers.FindByTelegramId(ctx, customer.TelegramID); found != nil {
+		t.Error("Expected the Telegram ID to be released")
+	}
+	got, err := customers.FindById(ctx, customer.ID)
+	if err != nil || got == nil {
+		t.Fatalf("FindById() = %v, %v", got, err)
+	}
+	if !IsAnonymized(got) || got.SubscriptionLink != nil || got.Source != nil {
+		t.Errorf("Expected identifiers to be scrubbed, got %+v", got)
+	}
+
+	var purchases int
+	if err := pool.QueryRow(ctx, "SELECT COUNT(*) FROM purchase WHERE id = $1 AND customer_id = $2", purchaseID, customer.ID).Scan(&purchases); err != nil || purchases != 1 {
+		t.Errorf("Expected the purchase to be kept, got %d (err %v)", purchases, err)
+	}
+	var upsellRows int
+	if err := pool.QueryRow(ctx, "SELECT COUNT(*) FROM trial_upsell_send WHERE customer_id = $1", customer.ID).Scan(&upsellRows); err != nil || upsellRows != 0 {
+		t.Errorf("Expected trial upsell sends to be deleted, got %d (err %v)", upsellRows, err)
+	}
+	all, err := customers.FindAll(ctx)
+	if err != nil || len(all) != 1 || all[0].ID != referrer.ID {
+		t.Errorf("Expected anonymized customers to be skipped by FindAll, got %v (err %v)", all, err)
+	}
+}
+
+func TestPaymentMethodRepositorySelectAndDelete(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	customers := NewCustomerRepository(pool)
+	repo := NewPaymentMethodRepository(pool)
+
+	amount, months := 500, 1
+	customer := createTestCustomer(t, customers, 1, nil)
+	other := createTestCustomer(t, customers, 2, nil)
+	visa, last4 := "Visa", "4444"
+	for _, method := range []*PaymentMethod{
+		{CustomerID: customer.ID, MethodID: "pm-first"},
+		{CustomerID: customer.ID, MethodID: "pm-second"},
+		// Повторное сохранение дополняет данные карты, а не создаёт вторую запись
+		{CustomerID: customer.ID, MethodID: "pm-first", CardType: &visa, CardLast4: &last4},
+	} {
+		if err := repo.Save(ctx, method); err != nil {
+			t.Fatalf("Save() returned error: %v", err)
+		}
+	}
+	firstMethodID := "pm-first"
+	if err := customers.UpdateRecurringSettings(ctx, customer.ID, true, &firstMethodID, nil, &months, nil, &amount, &amount, nil); err != nil {
+		t.Fatalf("UpdateRecurringSettings() returned error: %v", err)
+	}
+
+	methods, err := repo.FindByCustomer(ctx, customer.ID)
+	if err != nil || len(methods) != 2 {
+		t.Fatalf("FindByCustomer() = %v, %v", methods, err)
+	}
+	first, second := methods[0], methods[1]
+	if first.Label() != "Visa •••• 4444" {
+		t.Errorf("Expected card details to be updated, got %q", first.Label())
+	}
+
+	if selected, err := repo.SelectForRecurring(ctx, other.ID, second.ID); err != nil || selected {
+		t.Errorf("Expected selecting another customer's card to fail, got %v (err %v)", selected, err)
+	}
+	if selected, err := repo.SelectForRecurring(ctx, customer.ID, second.ID); err != nil || !selected {
+		t.Fatalf("SelectForRecurring() = %v, %v", selected, err)
+	}
+
+	// Удаление карты, не привязанной к автопродлению, настройки не трогает
+	deleted, recurringDisabled, err := repo.Delete(ctx, customer.ID, first.ID)
+	if err != nil || deleted == nil || recurringDisabled {
+		t.Fatalf("Delete(first) = %v, %v, %v", deleted, recurringDisabled, err)
+	}
+	got, _ := customers.FindById(ctx, customer.ID)
+	if !got.RecurringEnabled || got.PaymentMethodID == nil || *got.PaymentMethodID != "pm-second" {
+		t.Fatalf("Expected recurring to stay on the second card, got %v %v", got.RecurringEnabled, got.PaymentMethodID)
+	}
+
+	deleted, recurringDisabled, err = repo.Delete(ctx, customer.ID, second.ID)
+	if err != nil || deleted == nil || !recurringDisabled {
+		t.Fatalf("Delete(second) = %v, %v, %v", deleted, recurringDisabled, err)
+	}
+	got, _ = customers.FindById(ctx, customer.ID)
+	if got.RecurringEnabled || got.PaymentMethodID != nil || got.RecurringAmount != nil {
+		t.Errorf("Expected recurring to be reset, got %+v", got)
+	}
+	if deleted, _, err := repo.Delete(ctx, customer.ID, second.ID); err != nil || deleted != nil {
+		t.Errorf("Expected repeated Delete() to be a no-op, got %v (err %v)", deleted, err)
+	}
+}
+
+func TestFieldEncryptionRepositoryEncryptAll(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	customers := NewCustomerRepository(pool)
+	methods := NewPaymentMethodRepository(pool)
+
+	// Записано до включения шифрования
+	link := "https://sub.example.com/plain"
+	legacy := createTestCustomer(t, customers, 1, map[string]interface{}{"subscription_link": link})
+	if err := methods.Save(ctx, &PaymentMethod{CustomerID: legacy.ID, MethodID: "pm-legacy"}); err != nil {
+		t.Fatalf("Save() returned error: %v", err)
+	}
+
+	oldKeyring := testFieldKeyring(t, testFieldKey("old", 'a'))
+	encrypted, err := NewFieldEncryptionRepository(pool).EncryptAll(ctx, oldKeyring, 1)
+	if err != nil || encrypted != 2 {
+		t.Fatalf("EncryptAll() = %d, %v, want 2 values", encrypted, err)
+	}
+	var storedLink, storedMethod string
+	if err := pool.QueryRow(ctx, "SELECT subscription_link FROM customer WHERE id = $1", legacy.ID).Scan(&storedLink); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(storedLink, "enc:v1:old:") {
+		t.Fatalf("Expected link to be encrypted, got %q", storedLink)
+	}
+
+	// Повторное сохранение той же карты находит зашифрованную запись
+	visa := "Visa"
+	if err := methods.Save(ctx, &PaymentMethod{CustomerID: legacy.ID, MethodID: "pm-legacy", CardType: &visa}); err != nil {
+		t.Fatalf("Save() returned error: %v", err)
+	}
+	saved, err := methods.FindByCustomer(ctx, legacy.ID)
+	if err != nil || len(saved) != 1 || saved[0].MethodID != "pm-legacy" || saved[0].CardType == nil {
+		t.Fatalf("FindByCustomer() = %+v, %v", saved, err)
+	}
+
+	// После ротации значения перешифровываются новым ключом и читаются
+	newKeyring := testFieldKeyring(t, testFieldKey("new", 'b')+","+testFieldKey("old", 'a'))
+	encrypted, err = NewFieldEncryptionRepository(pool).EncryptAll(ctx, newKeyring, 100)
+	if err != nil || encrypted != 2 {
+		t.Fatalf("EncryptAll() after rotation = %d, %v, want 2 values", encrypted, err)
+	}
+	if err := pool.QueryRow(ctx, "SELECT method_id FROM payment_method WHERE customer_id = $1", legacy.ID).Scan(&storedMethod); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(storedMethod, "enc:v1:new:") {
+		t.Errorf("Expected method to be re-encrypted with the new key, got %q", storedMethod)
+	}
+	got, err := customers.FindById(ctx, legacy.ID)
+	if err != nil || got.SubscriptionLink == nil || *got.SubscriptionLink != link {
+		t.Errorf("FindById() link = %v, %v", got.SubscriptionLink, err)
+	}
+}
+
+func TestStatsRepositoryQuickStatsCounts(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	customers := NewCustomerRepository(pool)
+	broadcasts := NewBroadcastRepository(pool)
+	stats := NewStatsRepository(pool)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	createTestCustomer(t, customers, 1, map[string]interface{}{"expire_at": now.Add(24 * time.Hour)})
+	createTestCustomer(t, customers, 2, map[string]interface{}{"expire_at": now.Add(-time.Hour)})
+	createTestCustomer(t, customers, 3, nil)
+	createTestCustomer(t, customers, 4, map[string]interface{}{"expire_at": now.Add(24 * time.Hour), "deleted_at": now})
+
+	active, err := stats.CountActiveSubscriptions(ctx, now)
+	if err != nil {
+		t.Fatalf("CountActiveSubscriptions() returned error: %v", err)
+	}
+	if active != 1 {
+		t.Errorf("Expected 1 active subscription, got %d", active)
+	}
+
+	for _, status := range []BroadcastStatus{BroadcastStatusPending, BroadcastStatusInProgress, BroadcastStatusCompleted} {
+		id, err := broadcasts.Create(ctx, "all", "text")
+		if err != nil {
+			t.Fatalf("Create() returned error: %v", err)
+		}
+		if err := broadcasts.UpdateStatus(ctx, id, string(status), 0, 0); err != nil {
+			t.Fatalf("UpdateStatus() returned error: %v", err)
+		}
+	}
+	pending, err := stats.CountPendingBroadcasts(ctx)
+	if err != nil {
+		t.Fatalf("CountPendingBroadcasts() returned error: %v", err)
+	}
+	if pending != 2 {
+		t.Errorf("Expected pending and in-progress broadcasts to count, got %d", pending)
+	}
+}
+
+func TestTrialCleanupRepositoryFindExpired(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	customers := NewCustomerRepository(pool)
+	repo := NewTrialCleanupRepository(pool)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	expiredBefore := now.AddDate(0, 0, -14)
+	trial := func(expireAt time.Time) map[string]interface{} {
+		return map[string]interface{}{"expire_at": expireAt, "trial_activated_at": expireAt.AddDate(0, 0, -3)}
+	}
+	longExpired := createTestCustomer(t, customers, 1, trial(now.AddDate(0, 0, -30)))
+	paid := createTestCustomer(t, customers, 2, trial(now.AddDate(0, 0, -30)))
+	createTestPurchase(t, pool, paid.ID, PurchaseStatusPaid)
+	createTestCustomer(t, customers, 3, trial(now.AddDate(0, 0, -2)))
+	cleaned := trial(now.AddDate(0, 0, -30))
+	cleaned["trial_cleaned_at"] = now.AddDate(0, 0, -10)
+	createTestCustomer(t, customers, 4, cleaned)
+	// Подписку снова выдали после очистки (реферальный бонус), и она тоже давно истекла
+	reissued := trial(now.AddDate(0, 0, -20))
+	reissued["trial_cleaned_at"] = now.AddDate(0, 0, -40)
+	reissuedCustomer := createTestCustomer(t, customers, 5, reissued)
+
+	found, err := repo.FindExpired(ctx, expiredBefore, 10)
+	if err != nil {
+		t.Fatalf("FindExpired() returned error: %v", err)
+	}
+	if len(found) != 2 || found[0].ID != longExpired.ID || found[1].ID != reissuedCustomer.ID {
+		t.Fatalf("Expected unpaid long expired trials 1 and 5, got %+v", found)
+	}
+
+	if err := repo.MarkCleaned(ctx, longExpired.ID, now); err != nil {
+		t.Fatalf("MarkCleaned() returned error: %v", err)
+	}
+	found, err = repo.FindExpired(ctx, expiredBefore, 10)
+	if err != nil {
+		t.Fatalf("FindExpired() returned error: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != reissuedCustomer.ID {
+		t.Errorf("Expected cleaned customer to be skipped, got %+v", found)
+	}
+	if customer, err := customers.FindById(ctx, longExpired.ID); err != nil || customer.TrialCleanedAt == nil {
+		t.Errorf("Expected trial_cleaned_at to be set, got %+v (%v)", customer, err)
+	}
+}
+
+func TestSurveyRepository(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	customers := NewCustomerRepository(pool)
+	repo := NewSurveyRepository(pool)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	customer := createTestCustomer(t, customers, 1, nil)
+
+	id, created, err := repo.Create(ctx, customer.ID, SurveyTriggerRenewal, now, now.AddDate(0, 0, -90))
+	if err != nil || !created {
+		t.Fatalf("Create() = %v, %v; expected survey to be created", created, err)
+	}
+	// Второй опрос в пределах интервала не создаётся
+	if _, created, err := repo.Create(ctx, customer.ID, SurveyTriggerSupport, now, now.AddDate(0, 0, -90)); err != nil || created {
+		t.Fatalf("Create() = %v, %v; expected frequency cap", created, err)
+	}
+
+	if saved, err := repo.SaveRating(ctx, id, customer.TelegramID+1, 5, now); err != nil || saved {
+		t.Fatalf("SaveRating() by another user = %v, %v; expected no update", saved, err)
+	}
+	if saved, err := repo.SaveRating(ctx, id, customer.TelegramID, 4, now); err != nil || !saved {
+		t.Fatalf("SaveRating() = %v, %v", saved, err)
+	}
+	if saved, _ := repo.SaveRating(ctx, id, customer.TelegramID, 1, now); saved {
+		t.Error("Expected repeated rating to be ignored")
+	}
+	if saved, err := repo.SaveComment(ctx, id, customer.TelegramID, "Всё отлично"); err != nil || !saved {
+		t.Fatalf("SaveComment() = %v, %v", saved, err)
+	}
+
+	stats, err := repo.Stats(ctx, now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("Stats() returned error: %v", err)
+	}
+	if stats.Sent != 1 || stats.Answered != 1 || stats.Average != 4 || stats.Ratings[3] != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	comments, err := repo.RecentComments(ctx, 10)
+	if err != nil {
+		t.Fatalf("RecentComments() returned error: %v", err)
+	}
+	if len(comments) != 1 || comments[0].Comment != "Всё отлично" || comments[0].Rating != 4 || comments[0].TelegramID != customer.TelegramID {
+		t.Errorf("Unexpected comments %+v", comments)
+	}
+}
+
+func TestNotificationFeedbackRepository(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	repo := NewNotificationFeedbackRepository(pool)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	first := createTestCustomer(t, NewCustomerRepository(pool), 1, nil)
+	second := createTestCustomer(t, NewCustomerRepository(pool), 2, nil)
+
+	if saved, err := repo.Save(ctx, 999, 10, "winback_offer", true, now); err != nil || saved {
+		t.Fatalf("Save() for unknown customer = %v, %v; expected no row", saved, err)
+	}
+	for _, f := range []struct {
+		telegramID int64
+		messageID  int
+		template   string
+		useful     bool
+	}{
+		{first.TelegramID, 10, "winback_offer", true},
+		// Клиент передумал: оценка под тем же сообщением заменяется
+		{first.TelegramID, 10, "winback_offer", false},
+		{second.TelegramID, 10, "winback_offer", true},
+		{second.TelegramID, 11, "trial_upsell_social_proof", true},
+	} {
+		if saved, err := repo.Save(ctx, f.telegramID, f.messageID, f.template, f.useful, now); err != nil || !saved {
+			t.Fatalf("Save(%+v) = %v, %v", f, saved, err)
+		}
+	}
+
+	stats, err := repo.Stats(ctx, now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Stats() returned error: %v", err)
+	}
+	want := []NotificationFeedbackStats{
+		{Template: "winback_offer", Useful: 1, NotUseful: 1},
+		{Template: "trial_upsell_social_proof", Useful: 1},
+	}
+	if len(stats) != len(want) || stats[0] != want[0] || stats[1] != want[1] {
+		t.Errorf("Stats() = %+v, want %+v", stats, want)
+	}
+}
+
+func TestFailedFulfillmentRepository(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	repo := NewFailedFulfillmentRepository(pool)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	customer := createTestCustomer(t, NewCustomerRepository(pool), 1, nil)
+	transient := createTestPurchase(t, pool, customer.ID, PurchaseStatusPending)
+	permanent := createTestPurchase(t, pool, customer.ID, PurchaseStatusPending)
+
+	retryAt := now.Add(time.Minute)
+	id, created, err := repo.Record(ctx, transient, string(InvoiceTypeYookasa), []byte(`{"id":"p1"}`), "timeout", &retryAt, now)
+	if err != nil || !created {
+		t.Fatalf("Record() = %d, %v, %v; expected a new entry", id, created, err)
+	}
+	// Повторная ошибка опроса той же покупки не создаёт вторую запись и не сбрасывает расписание повторов
+	again, created, err := repo.Record(ctx, transient, string(InvoiceTypeYookasa), nil, "connection reset", nil, now)
+	if err != nil || created || again != id {
+		t.Fatalf("Record() for open entry = %d, %v, %v; expected update of %d", again, created, err, id)
+	}
+	if _, _, err := repo.Record(ctx, permanent, string(InvoiceTypeTelegram), nil, "customer not found", nil, now); err != nil {
+		t.Fatalf("Record() returned error: %v", err)
+	}
+
+	entry, err := repo.FindByID(ctx, id)
+	if err != nil || entry == nil {
+		t.Fatalf("FindByID() = %v, %v", entry, err)
+	}
+	if entry.Status != FailedFulfillmentStatusPending || entry.LastError != "connection reset" || string(entry.Payload) != `{"id": "p1"}` {
+		t.Errorf("FindByID() = %+v; expected pending entry with latest error and original payload", entry)
+	}
+
+	if due, err := repo.FindDue(ctx, now, 10); err != nil || len(due) != 0 {
+		t.Errorf("FindDue() before retry time = %v, %v; expected none", due, err)
+	}
+	due, err := repo.FindDue(ctx, retryAt, 10)
+	if err != nil || len(due) != 1 || due[0].ID != id {
+		t.Fatalf("FindDue() = %v, %v; expected entry %d", due, err, id)
+	}
+
+	if err := repo.ScheduleRetry(ctx, id, 2, "timeout", now.Add(time.Hour), now); err != nil {
+		t.Fatalf("ScheduleRetry() returned error: %v", err)
+	}
+	open, err := repo.FindOpen(ctx, 10)
+	if err != nil || len(open) != 2 {
+		t.Fatalf("FindOpen() = %v, %v; expected 2 entries", open, err)
+	}
+
+	if resolved, err := repo.ResolveByPurchase(ctx, transient, now); err != nil || !resolved {
+		t.Fatalf("ResolveByPurchase() = %v, %v", resolved, err)
+	}
+	if resolved, err := repo.ResolveByPurchase(ctx, transient, now); err != nil || resolved {
+		t.Errorf("ResolveByPurchase() without open entry = %v, %v; expected false", resolved, err)
+	}
+	// После решения новая ошибка по той же покупке открывает новую запись
+	if _, created, err := repo.Record(ctx, transient, string(InvoiceTypeYookasa), nil, "timeout", &retryAt, now); err != nil || !created {
+		t.Errorf("Record() after resolve = %v, %v; expected a new entry", created, err)
+	}
+}
+
+func TestWebhookEventRepositoryClaimDue(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	repo := NewWebhookEventRepository(pool)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	inline, created, err := repo.CreateIfNotExists(ctx, "inline", "user.expired", []byte(`{}`), now.Add(10*time.Minute))
+	if err != nil || !created {
+		t.Fatalf("CreateIfNotExists() = %v, %v", created, err)
+	}
+	retry, _, err := repo.CreateIfNotExists(ctx, "retry", "user.expired", []byte(`{}`), now.Add(10*time.Minute))
+	if err != nil {
+		t.Fatalf("CreateIfNotExists() returned error: %v", err)
+	}
+	if err := repo.ScheduleRetry(ctx, retry, 1, "telegram unavailable", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("ScheduleRetry() returned error: %v", err)
+	}
+
+	claimed, err := repo.ClaimDue(ctx, now, now.Add(10*time.Minute), 10)
+	if err != nil || len(claimed) != 1 || claimed[0].ID != retry || claimed[0].Status != WebhookEventStatusProcessing {
+		t.Fatalf("ClaimDue() = %+v, %v; expected only the due retry %d (inline event %d is being processed)", claimed, err, retry, inline)
+	}
+	if again, err := repo.ClaimDue(ctx, now, now.Add(10*time.Minute), 10); err != nil || len(again) != 0 {
+		t.Fatalf("second ClaimDue() = %+v, %v; expected claimed events to be skipped", again, err)
+	}
+
+	// Обработчик упал, не сохранив результат: после срока захвата события снова доступны
+	later := now.Add(11 * time.Minute)
+	if reclaimed, err := repo.ClaimDue(ctx, later, later.Add(10*time.Minute), 10); err != nil || len(reclaimed) != 2 {
+		t.Fatalf("ClaimDue() after claim timeout = %+v, %v; expected both events", reclaimed, err)
+	}
+}
+
+func TestPurchaseRepositoryClaimRefund(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	repo := NewPurchaseRepository(pool)
+
+	customer := createTestCustomer(t, NewCustomerRepository(pool), 1, nil)
+	id := createTestPurchase(t, pool, customer.ID, PurchaseStatusPaid)
+
+	previous, ok, err := repo.ClaimRefund(ctx, id, 40, false)
+	if err != nil || !ok || previous != 0 {
+		t.Fatalf("ClaimRefund() = %v, %v, %v; expected claim from 0", previous, ok, err)
+	}
+	// Повтор webhook с той же суммой не должен сокращать подписку второй раз
+	if _, ok, err := repo.ClaimRefund(ctx, id, 40, false); err != nil || ok {
+		t.Fatalf("repeated ClaimRefund() = %v, %v; expected already claimed", ok, err)
+	}
+
+	previous, ok, err = repo.ClaimRefund(ctx, id, 100, true)
+	if err != nil || !ok || previous != 40 {
+		t.Fatalf("ClaimRefund() for full refund = %v, %v, %v; expected claim from 40", previous, ok, err)
+	}
+	if err := repo.ReleaseRefund(ctx, id, 100, previous); err != nil {
+		t.Fatalf("ReleaseRefund() returned error: %v", err)
+	}
+	purchase, err := repo.FindById(ctx, id)
+	if err != nil || purchase == nil || purchase.Status != PurchaseStatusPaid || purchase.RefundedAmount != 40 {
+		t.Fatalf("FindById() after release = %+v, %v; expected paid purchase with 40 refunded", purchase, err)
+	}
+	if _, ok, err := repo.ClaimRefund(ctx, id, 100, true); err != nil || !ok {
+		t.Errorf("ClaimRefund() after release = %v, %v; expected claim", ok, err)
+	}
+}
+
+func TestRecurringBulkRepositoryUndoSkipsManualToggle(t *testing.T) {
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+	customers := NewCustomerRepository(pool)
+	repo := NewRecurringBulkRepository(pool)
+
+	amount, months := 500, 1
+	kept := createTestCustomer(t, customers, 1, nil)
+	toggled := createTestCustomer(t, customers, 2, nil)
+	for i, customer := range []*Customer{kept, toggled} {
+		methodID := fmt.Sprintf("pm-%d", i)
+		if err := customers.UpdateRecurringSettings(ctx, customer.ID, true, &methodID, nil, &months, nil, &amount, &amount, nil); err != nil {
+			t.Fatalf("UpdateRecurringSettings() returned error: %v", err)
+		}
+	}
+
+	op, err := repo.Disable(ctx, nil, 1, time.Now().Add(time.Hour))
+	if err != nil || len(op.CustomerIDs) != 2 {
+		t.Fatalf("Disable() = %+v, %v; expected both customers", op, err)
+	}
+	// Клиент сам отключил автопродление в окне отмены: отмена операции его не включает
+	if err := customers.DisableRecurring(ctx, toggled.ID); err != nil {
+		t.Fatalf("DisableRecurring() returned error: %v", err)
+	}
+	if recipients, err := repo.Recipients(ctx, op); err != nil || len(recipients) != 1 || recipients[0].ID != kept.ID {
+		t.Fatalf("Recipients() = %v, %v; expected only the untouched customer", recipients, err)
+	}
+
+	restored, undone, err := repo.Undo(ctx, op.ID, time.Now())
+	if err != nil || !undone || restored != 1 {
+		t.Fatalf("Undo() = %v, %v, %v; expected one restored customer", restored, undone, err)
+	}
+	if got, _ := customers.FindById(ctx, kept.ID); !got.RecurringEnabled {
+		t.Errorf("Expected recurring to be restored for customer %d", kept.ID)
+	}
+	if got, _ := customers.FindById(ctx, toggled.ID); got.RecurringEnabled {
+		t.Errorf("Expected recurring to stay off for customer %d who disabled it manually", toggled.ID)
+	}
+}
@@ -0,0 +1,393 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type RecurringChargeStatus string
+
+const (
+	RecurringChargeStatusSucceeded RecurringChargeStatus = "succeeded"
+	RecurringChargeStatusFailed    RecurringChargeStatus = "failed"
+)
+
+// ProviderPaymentStats агрегированные оплаты по платёжному провайдеру и валюте
+type ProviderPaymentStats struct {
+	InvoiceType InvoiceType
+	Currency    string
+	Count       int
+	Revenue     float64
+}
+
+// BroadcastStats агрегированные результаты рассылок за период
+type BroadcastStats struct {
+	Count  int
+	Sent   int
+	Failed int
+}
+
+// SourceReferral источник клиентов, пришедших по реферальной ссылке
+const SourceReferral = "referral"
+
+// SourceStats регистрации, конверсии и выручка по источнику привлечения.
+// Source пустой для пользователей без атрибуции (органика)
+type SourceStats struct {
+	Source        string
+	Registrations int                // пользователей, созданных в периоде
+	Converted     int                // из них оплатили хотя бы одну покупку
+	Payments      int                // оплат в периоде по покупкам с этим источником
+	Revenue       map[string]float64 // выручка за период по валютам
+}
+
+type StatsRepository struct {
+	pool *pgxpool.Pool
+	// readPool реплика для отчётов. Совпадает с pool, если реплика не настроена
+	readPool *pgxpool.Pool
+}
+
+func NewStatsRepository(pool *pgxpool.Pool) *StatsRepository {
+	return &StatsRepository{pool: pool, readPool: pool}
+}
+
+// SetReadPool направляет аналитические запросы в реплику только для чтения. nil — в основной пул
+func (r *StatsRepository) SetReadPool(pool *pgxpool.Pool) {
+	if pool != nil {
+		r.readPool = pool
+	}
+}
+
+// CountNewCustomers возвращает количество пользователей, созданных в периоде [from, to)
+func (r *StatsRepository) CountNewCustomers(ctx context.Context, from, to time.Time) (int, error) {
+	query := sq.Select("COUNT(*)").
+		From("customer").
+		Where(sq.And{
+			sq.GtOrEq{"created_at": from},
+			sq.Lt{"created_at": to},
+			notDeleted,
+		}).
+		PlaceholderFormat(sq.Dollar)
+
+	return r.count(ctx, r.readPool, query)
+}
+
+// CountTrialsStarted возвращает количество пользователей, созданных в периоде и получивших подписку
+// без оплаченных покупок (триал)
+func (r *StatsRepository) CountTrialsStarted(ctx context.Context, from, to time.Time) (int, error) {
+	query := sq.Select("COUNT(*)").
+		From("customer c").
+		Where(sq.And{
+			sq.NotEq{"c.expire_at": nil},
+			sq.GtOrEq{"c.created_at": from},
+			sq.Lt{"c.created_at": to},
+			sq.Eq{"c.deleted_at": nil},
+			sq.Expr("NOT EXISTS (SELECT 1 FROM purchase p WHERE p.customer_id = c.id AND p.status = ? AND NOT p.is_test)", PurchaseStatusPaid),
+		}).
+		PlaceholderFormat(sq.Dollar)
+
+	return r.count(ctx, r.readPool, query)
+}
+
+// CountExpiringBetween возвращает количество пользователей, у которых подписка истекает в периоде [from, to)
+func (r *StatsRepository) CountExpiringBetween(ctx context.Context, from, to time.Time) (int, error) {
+	query := sq.Select("COUNT(*)").
+		From("customer").
+		Where(sq.And{
+			sq.GtOrEq{"expire_at": from},
+			sq.Lt{"expire_at": to},
+			notDeleted,
+		}).
+		PlaceholderFormat(sq.Dollar)
+
+	return r.count(ctx, r.readPool, query)
+}
+
+// CountActiveSubscriptions возвращает количество пользователей с подпиской, действующей на момент now
+func (r *StatsRepository) CountActiveSubscriptions(ctx context.Context, now time.Time) (int, error) {
+	query := sq.Select("COUNT(*)").
+		From("customer").
+		Where(sq.And{
+			sq.Gt{"expire_at": now},
+			notDeleted,
+		}).
+		PlaceholderFormat(sq.Dollar)
+
+	return r.count(ctx, r.readPool, query)
+}
+
+// CountPendingBroadcasts возвращает количество рассылок, которые ждут отправки или отправляются сейчас
+func (r *StatsRepository) CountPendingBroadcasts(ctx context.Context) (int, error) {
+	query := sq.Select("COUNT(*)").
+		From("broadcast_history").
+		Where(sq.Eq{"status": []string{string(BroadcastStatusPending), string(BroadcastStatusInProgress)}}).
+		PlaceholderFormat(sq.Dollar)
+
+	return r.count(ctx, r.readPool, query)
+}
+
+// CountFailedRecurringCharges возвращает количество неудачных автосписаний в периоде [from, to)
+func (r *StatsRepository) CountFailedRecurringCharges(ctx context.Context, from, to time.Time) (int, error) {
+	query := sq.Select("COUNT(*)").
+		From("recurring_charge_log").
+		Where(sq.And{
+			sq.Eq{"status": RecurringChargeStatusFailed},
+			sq.GtOrEq{"created_at": from},
+			sq.Lt{"created_at": to},
+		}).
+		PlaceholderFormat(sq.Dollar)
+
+	return r.count(ctx, r.readPool, query)
+}
+
+// PaymentsByProvider возвращает количество и сумму оплат в периоде [from, to) по провайдерам
+// (включая архивные покупки, без тестовых)
+func (r *StatsRepository) PaymentsByProvider(ctx context.Context, from, to time.Time) ([]ProviderPaymentStats, error) {
+	query := sq.Select("COALESCE(invoice_type, '')", "COALESCE(currency, '')", "COUNT(*)", "COALESCE(SUM(amount), 0)").
+		From(purchaseHistoryView).
+		Where(sq.And{
+			sq.Eq{"status": PurchaseStatusPaid},
+			sq.Eq{"is_test": false},
+			sq.GtOrEq{"paid_at": from},
+			sq.Lt{"paid_at": to},
+		}).
+		GroupBy("invoice_type", "currency").
+		OrderBy("invoice_type", "currency").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	rows, err := r.readPool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query payments stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []ProviderPaymentStats
+	for rows.Next() {
+		var s ProviderPaymentStats
+		if err := rows.Scan(&s.InvoiceType, &s.Currency, &s.Count, &s.Revenue); err != nil {
+			return nil, fmt.Errorf("scan payments stats: %w", err)
+		}
+		stats = append(stats, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+	return stats, nil
+}
+
+// BroadcastResults возвращает результаты рассылок, созданных в периоде [from, to)
+func (r *StatsRepository) BroadcastResults(ctx context.Context, from, to time.Time) (*BroadcastStats, error) {
+	query := sq.Select("COUNT(*)", "COALESCE(SUM(sent_count), 0)", "COALESCE(SUM(failed_count), 0)").
+		From("broadcast_history").
+		Where(sq.And{
+			sq.GtOrEq{"created_at": from},
+			sq.Lt{"created_at": to},
+		}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	var s BroadcastStats
+	err = r.readPool.QueryRow(ctx, sql, args...).Scan(&s.Count, &s.Sent, &s.Failed)
+	if err != nil {
+		return nil, fmt.Errorf("query broadcast stats: %w", err)
+	}
+	return &s, nil
+}
+
+// LogRecurringCharge сохраняет результат попытки автосписания
+func (r *StatsRepository) LogRecurringCharge(ctx context.Context, customerID int64, amount, months int, status RecurringChargeStatus, errText *string) error {
+	query := sq.Insert("recurring_charge_log").
+		Columns("customer_id", "amount", "months", "status", "error").
+		Values(customerID, amount, months, status, errText).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+
+	_, err = r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("insert recurring charge log: %w", err)
+	}
+	return nil
+}
+
+// SumSucceededRecurringCharges возвращает сумму успешных автосписаний клиента начиная с since
+func (r *StatsRepository) SumSucceededRecurringCharges(ctx context.Context, customerID int64, since time.Time) (int, error) {
+	query := sq.Select("COALESCE(SUM(amount), 0)").
+		From("recurring_charge_log").
+		Where(sq.And{
+			sq.Eq{"customer_id": customerID},
+			sq.Eq{"status": RecurringChargeStatusSucceeded},
+			sq.GtOrEq{"created_at": since},
+		}).
+		PlaceholderFormat(sq.Dollar)
+
+	// Лимит автосписаний проверяется перед оплатой, отставание реплики недопустимо
+	return r.count(ctx, r.pool, query)
+}
+
+// FunnelUsers возвращает количество уникальных пользователей на каждом шаге воронки в периоде [from, to)
+func (r *StatsRepository) FunnelUsers(ctx context.Context, from, to time.Time) (map[FunnelEvent]int, error) {
+	query := sq.Select("event", "COUNT(DISTINCT telegram_id)").
+		From("funnel_event").
+		Where(sq.And{
+			sq.GtOrEq{"created_at": from},
+			sq.Lt{"created_at": to},
+		}).
+		GroupBy("event").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	rows, err := r.readPool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query funnel stats: %w", err)
+	}
+	defer rows.Close()
+
+	users := make(map[FunnelEvent]int)
+	for rows.Next() {
+		var event FunnelEvent
+		var count int
+		if err := rows.Scan(&event, &count); err != nil {
+			return nil, fmt.Errorf("scan funnel stats: %w", err)
+		}
+		users[event] = count
+	}
+
+	return users, rows.Err()
+}
+
+// StatsBySource возвращает статистику по источникам привлечения: регистрации и их конверсию в оплату
+// считаются по клиентам, созданным в периоде [from, to), выручка — по оплатам в периоде (включая архивные покупки).
+// Тестовые покупки не учитываются.
+// Источники отсортированы по количеству регистраций
+func (r *StatsRepository) StatsBySource(ctx context.Context, from, to time.Time) ([]SourceStats, error) {
+	registrations := sq.Select("COALESCE(c.source, '')", "COUNT(*)",
+		fmt.Sprintf("COUNT(*) FILTER (WHERE EXISTS (SELECT 1 FROM %s p WHERE p.customer_id = c.id AND p.status = '%s' AND NOT p.is_test))", purchaseHistoryView, PurchaseStatusPaid)).
+		From("customer c").
+		Where(sq.And{
+			sq.GtOrEq{"c.created_at": from},
+			sq.Lt{"c.created_at": to},
+			sq.Eq{"c.deleted_at": nil},
+		}).
+		GroupBy("c.source").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := registrations.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	bySource := make(map[string]*SourceStats)
+	var order []string
+	get := func(source string) *SourceStats {
+		if s, ok := bySource[source]; ok {
+			return s
+		}
+		s := &SourceStats{Source: source, Revenue: make(map[string]float64)}
+		bySource[source] = s
+		order = append(order, source)
+		return s
+	}
+
+	rows, err := r.readPool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query source registrations: %w", err)
+	}
+	for rows.Next() {
+		var source string
+		var registered, converted int
+		if err := rows.Scan(&source, &registered, &converted); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan source registrations: %w", err)
+		}
+		s := get(source)
+		s.Registrations = registered
+		s.Converted = converted
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	revenue := sq.Select("COALESCE(source, '')", "COALESCE(currency, '')", "COUNT(*)", "COALESCE(SUM(amount), 0)").
+		From(purchaseHistoryView).
+		Where(sq.And{
+			sq.Eq{"status": PurchaseStatusPaid},
+			sq.Eq{"is_test": false},
+			sq.GtOrEq{"paid_at": from},
+			sq.Lt{"paid_at": to},
+		}).
+		GroupBy("source", "currency").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err = revenue.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	rows, err = r.readPool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query source revenue: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var source, currency string
+		var count int
+		var amount float64
+		if err := rows.Scan(&source, &currency, &count, &amount); err != nil {
+			return nil, fmt.Errorf("scan source revenue: %w", err)
+		}
+		s := get(source)
+		s.Payments += count
+		s.Revenue[currency] += amount
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+
+	stats := make([]SourceStats, 0, len(order))
+	for _, source := range order {
+		stats = append(stats, *bySource[source])
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].Registrations != stats[j].Registrations {
+			return stats[i].Registrations > stats[j].Registrations
+		}
+		return stats[i].Source < stats[j].Source
+	})
+	return stats, nil
+}
+
+func (r *StatsRepository) count(ctx context.Context, pool *pgxpool.Pool, query sq.SelectBuilder) (int, error) {
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("build query: %w", err)
+	}
+
+	var count int
+	if err := pool.QueryRow(ctx, sql, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count query: %w", err)
+	}
+	return count, nil
+}
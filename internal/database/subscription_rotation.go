@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type SubscriptionRotationRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewSubscriptionRotationRepository(pool *pgxpool.Pool) *SubscriptionRotationRepository {
+	return &SubscriptionRotationRepository{pool: pool}
+}
+
+// Record сохраняет перевыпуск ссылки подписки. adminID nil — клиент сменил ссылку сам
+func (r *SubscriptionRotationRepository) Record(ctx context.Context, customerID int64, adminID *int64) error {
+	_, err := r.pool.Exec(ctx, `
+		INSERT INTO subscription_rotation (customer_id, admin_id)
+		VALUES ($1, $2)`, customerID, adminID)
+	if err != nil {
+		return fmt.Errorf("failed to save subscription rotation: %w", err)
+	}
+	return nil
+}
+
+// CountSelfService возвращает, сколько раз клиент сам менял ссылку начиная с since
+func (r *SubscriptionRotationRepository) CountSelfService(ctx context.Context, customerID int64, since time.Time) (int, error) {
+	var count int
+	err := r.pool.QueryRow(ctx, `
+		SELECT COUNT(*) FROM subscription_rotation
+		WHERE customer_id = $1 AND admin_id IS NULL AND created_at >= $2`, customerID, since).
+		Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count subscription rotations: %w", err)
+	}
+	return count, nil
+}
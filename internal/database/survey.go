@@ -0,0 +1,157 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Поводы опроса удовлетворённости
+const (
+	SurveyTriggerRenewal = "renewal"
+	SurveyTriggerSupport = "support"
+)
+
+// surveyCommentMaxLength ограничивает длину комментария к оценке
+const surveyCommentMaxLength = 1000
+
+// SurveyStats итоги опросов за период. Ratings[i] — количество оценок i+1
+type SurveyStats struct {
+	Sent     int
+	Answered int
+	Average  float64
+	Ratings  [5]int
+}
+
+// CSAT доля оценок 4 и 5 среди ответов в процентах
+func (s SurveyStats) CSAT() float64 {
+	if s.Answered == 0 {
+		return 0
+	}
+	return float64(s.Ratings[3]+s.Ratings[4]) * 100 / float64(s.Answered)
+}
+
+// SurveyComment комментарий клиента к оценке
+type SurveyComment struct {
+	TelegramID int64
+	Trigger    string
+	Rating     int
+	Comment    string
+	AnsweredAt time.Time
+}
+
+type SurveyRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewSurveyRepository(pool *pgxpool.Pool) *SurveyRepository {
+	return &SurveyRepository{pool: pool}
+}
+
+// Create записывает отправку опроса, если клиенту не отправляли опрос начиная с since.
+// Возвращает false, если опрос уже был: так клиента не опрашивают чаще SURVEY_INTERVAL_DAYS
+func (r *SurveyRepository) Create(ctx context.Context, customerID int64, trigger string, sentAt, since time.Time) (int64, bool, error) {
+	var id int64
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO satisfaction_survey (customer_id, trigger, sent_at)
+		SELECT $1, $2, $3
+		WHERE NOT EXISTS (SELECT 1 FROM satisfaction_survey WHERE customer_id = $1 AND sent_at >= $4)
+		RETURNING id`, customerID, trigger, sentAt, since).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to create survey: %w", err)
+	}
+	return id, true, nil
+}
+
+// Delete удаляет опрос, который не удалось отправить, чтобы он не занимал интервал
+func (r *SurveyRepository) Delete(ctx context.Context, id int64) error {
+	if _, err := r.pool.Exec(ctx, `DELETE FROM satisfaction_survey WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete survey: %w", err)
+	}
+	return nil
+}
+
+// SaveRating сохраняет оценку клиента. Возвращает false, если опрос не его или оценка уже поставлена
+func (r *SurveyRepository) SaveRating(ctx context.Context, id, telegramID int64, rating int, at time.Time) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE satisfaction_survey s SET rating = $3, answered_at = $4
+		FROM customer c
+		WHERE s.id = $1 AND c.id = s.customer_id AND c.telegram_id = $2 AND s.rating IS NULL`,
+		id, telegramID, rating, at)
+	if err != nil {
+		return false, fmt.Errorf("failed to save survey rating: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// SaveComment сохраняет комментарий к поставленной оценке. Возвращает false, если опрос не его,
+// оценки ещё нет или комментарий уже оставлен
+func (r *SurveyRepository) SaveComment(ctx context.Context, id, telegramID int64, comment string) (bool, error) {
+	if runes := []rune(comment); len(runes) > surveyCommentMaxLength {
+		comment = string(runes[:surveyCommentMaxLength])
+	}
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE satisfaction_survey s SET comment = $3
+		FROM customer c
+		WHERE s.id = $1 AND c.id = s.customer_id AND c.telegram_id = $2
+		  AND s.rating IS NOT NULL AND s.comment IS NULL`,
+		id, telegramID, comment)
+	if err != nil {
+		return false, fmt.Errorf("failed to save survey comment: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Stats возвращает итоги опросов, отправленных начиная с since
+func (r *SurveyRepository) Stats(ctx context.Context, since time.Time) (SurveyStats, error) {
+	var stats SurveyStats
+	err := r.pool.QueryRow(ctx, `
+		SELECT COUNT(*),
+		       COUNT(rating),
+		       COALESCE(AVG(rating), 0)::float8,
+		       COUNT(*) FILTER (WHERE rating = 1),
+		       COUNT(*) FILTER (WHERE rating = 2),
+		       COUNT(*) FILTER (WHERE rating = 3),
+		       COUNT(*) FILTER (WHERE rating = 4),
+		       COUNT(*) FILTER (WHERE rating = 5)
+		FROM satisfaction_survey
+		WHERE sent_at >= $1`, since).
+		Scan(&stats.Sent, &stats.Answered, &stats.Average,
+			&stats.Ratings[0], &stats.Ratings[1], &stats.Ratings[2], &stats.Ratings[3], &stats.Ratings[4])
+	if err != nil {
+		return SurveyStats{}, fmt.Errorf("failed to query survey stats: %w", err)
+	}
+	return stats, nil
+}
+
+// RecentComments возвращает до limit последних комментариев, новые первыми
+func (r *SurveyRepository) RecentComments(ctx context.Context, limit int) ([]SurveyComment, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT c.telegram_id, s.trigger, s.rating, s.comment, s.answered_at
+		FROM satisfaction_survey s
+		JOIN customer c ON c.id = s.customer_id
+		WHERE s.comment IS NOT NULL
+		ORDER BY s.answered_at DESC
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query survey comments: %w", err)
+	}
+	defer rows.Close()
+
+	var comments []SurveyComment
+	for rows.Next() {
+		var c SurveyComment
+		if err := rows.Scan(&c.TelegramID, &c.Trigger, &c.Rating, &c.Comment, &c.AnsweredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan survey comment: %w", err)
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
@@ -0,0 +1,13 @@
+package database
+
+import "testing"
+
+func TestSurveyStatsCSAT(t *testing.T) {
+	if got := (SurveyStats{}).CSAT(); got != 0 {
+		t.Errorf("Expected 0 without answers, got %v", got)
+	}
+	stats := SurveyStats{Answered: 8, Ratings: [5]int{1, 1, 0, 2, 4}}
+	if got := stats.CSAT(); got != 75 {
+		t.Errorf("Expected CSAT 75%%, got %v", got)
+	}
+}
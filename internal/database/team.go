@@ -0,0 +1,251 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Team командная подписка: плательщик купил Seats мест на Months месяцев.
+// Все места действуют до ExpiresAt независимо от того, когда их активировали
+type Team struct {
+	ID              int64     `db:"id"`
+	OwnerCustomerID int64     `db:"owner_customer_id"`
+	PurchaseID      int64     `db:"purchase_id"`
+	Seats           int       `db:"seats"`
+	Months          int       `db:"months"`
+	ExpiresAt       time.Time `db:"expires_at"`
+	CreatedAt       time.Time `db:"created_at"`
+}
+
+// TeamSeat место в команде. MemberCustomerID nil — место свободно и ждёт активации по коду
+type TeamSeat struct {
+	ID               int64      `db:"id"`
+	TeamID           int64      `db:"team_id"`
+	Code             string     `db:"code"`
+	MemberCustomerID *int64     `db:"member_customer_id"`
+	RedeemedAt       *time.Time `db:"redeemed_at"`
+	// MemberTelegramID заполняется при выборке мест команды для отображения владельцу
+	MemberTelegramID *int64 `db:"-"`
+}
+
+// IsFree возвращает true если место ещё никем не занято
+func (s TeamSeat) IsFree() bool {
+	return s.MemberCustomerID == nil
+}
+
+var teamColumns = []string{"id", "owner_customer_id", "purchase_id", "seats", "months", "expires_at", "created_at"}
+
+type TeamRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewTeamRepository(pool *pgxpool.Pool) *TeamRepository {
+	return &TeamRepository{pool: pool}
+}
+
+func scanTeam(row pgx.Row) (*Team, error) {
+	var t Team
+	if err := row.Scan(&t.ID, &t.OwnerCustomerID, &t.PurchaseID, &t.Seats, &t.Months, &t.ExpiresAt, &t.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateWithSeats создаёт команду и её места с кодами codes одним запросом.
+// Команда по той же покупке создаётся один раз: при повторной обработке возвращается уже существующая
+func (r *TeamRepository) CreateWithSeats(ctx context.Context, team *Team, codes []string) (*Team, error) {
+	rows, err := r.pool.Query(ctx, `
+		WITH t AS (
+			INSERT INTO team (owner_customer_id, purchase_id, seats, months, expires_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (purchase_id) DO NOTHING
+			RETURNING id
+		)
+		INSERT INTO team_seat (team_id, code)
+		SELECT t.id, code FROM t, unnest($6::text[]) AS code`,
+		team.OwnerCustomerID, team.PurchaseID, team.Seats, team.Months, team.ExpiresAt, codes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create team: %w", err)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to create team: %w", err)
+	}
+
+	created, err := r.FindByPurchaseID(ctx, team.PurchaseID)
+	if err != nil {
+		return nil, err
+	}
+	if created == nil {
+		return nil, fmt.Errorf("team for purchase %d not found after insert", team.PurchaseID)
+	}
+	return created, nil
+}
+
+func (r *TeamRepository) findOne(ctx context.Context, where sq.Eq) (*Team, error) {
+	sql, args, err := sq.Select(teamColumns...).
+		From("team").
+		Where(where).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select team query: %w", err)
+	}
+
+	team, err := scanTeam(r.pool.QueryRow(ctx, sql, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find team: %w", err)
+	}
+	return team, nil
+}
+
+func (r *TeamRepository) FindByID(ctx context.Context, id int64) (*Team, error) {
+	return r.findOne(ctx, sq.Eq{"id": id})
+}
+
+func (r *TeamRepository) FindByPurchaseID(ctx context.Context, purchaseID int64) (*Team, error) {
+	return r.findOne(ctx, sq.Eq{"purchase_id": purchaseID})
+}
+
+// FindByOwner возвращает команды плательщика, новые первыми
+func (r *TeamRepository) FindByOwner(ctx context.Context, ownerCustomerID int64) ([]Team, error) {
+	sql, args, err := sq.Select(teamColumns...).
+		From("team").
+		Where(sq.Eq{"owner_customer_id": ownerCustomerID}).
+		OrderBy("created_at DESC").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select teams query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query teams: %w", err)
+	}
+	defer rows.Close()
+
+	var teams []Team
+	for rows.Next() {
+		team, err := scanTeam(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan team: %w", err)
+		}
+		teams = append(teams, *team)
+	}
+	return teams, rows.Err()
+}
+
+// CountTakenSeats возвращает количество занятых мест команды
+func (r *TeamRepository) CountTakenSeats(ctx context.Context, teamID int64) (int, error) {
+	var count int
+	err := r.pool.QueryRow(ctx, "SELECT COUNT(*) FROM team_seat WHERE team_id = $1 AND member_customer_id IS NOT NULL", teamID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count team seats: %w", err)
+	}
+	return count, nil
+}
+
+// FindSeats возвращает места команды в порядке создания вместе с telegram ID участников
+func (r *TeamRepository) FindSeats(ctx context.Context, teamID int64) ([]TeamSeat, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT s.id, s.team_id, s.code, s.member_customer_id, s.redeemed_at, c.telegram_id
+		FROM team_seat s
+		LEFT JOIN customer c ON c.id = s.member_customer_id
+		WHERE s.team_id = $1
+		ORDER BY s.id`, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query team seats: %w", err)
+	}
+	defer rows.Close()
+
+	var seats []TeamSeat
+	for rows.Next() {
+		var s TeamSeat
+		if err := rows.Scan(&s.ID, &s.TeamID, &s.Code, &s.MemberCustomerID, &s.RedeemedAt, &s.MemberTelegramID); err != nil {
+			return nil, fmt.Errorf("failed to scan team seat: %w", err)
+		}
+		seats = append(seats, s)
+	}
+	return seats, rows.Err()
+}
+
+func (r *TeamRepository) findSeat(ctx context.Context, where sq.Eq) (*TeamSeat, error) {
+	sql, args, err := sq.Select("id", "team_id", "code", "member_customer_id", "redeemed_at").
+		From("team_seat").
+		Where(where).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build select team seat query: %w", err)
+	}
+
+	var s TeamSeat
+	err = r.pool.QueryRow(ctx, sql, args...).Scan(&s.ID, &s.TeamID, &s.Code, &s.MemberCustomerID, &s.RedeemedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find team seat: %w", err)
+	}
+	return &s, nil
+}
+
+func (r *TeamRepository) FindSeatByID(ctx context.Context, id int64) (*TeamSeat, error) {
+	return r.findSeat(ctx, sq.Eq{"id": id})
+}
+
+func (r *TeamRepository) FindSeatByCode(ctx context.Context, code string) (*TeamSeat, error) {
+	return r.findSeat(ctx, sq.Eq{"code": code})
+}
+
+// IsMember возвращает true если клиент уже занимает место в команде
+func (r *TeamRepository) IsMember(ctx context.Context, teamID, customerID int64) (bool, error) {
+	var exists bool
+	err := r.pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM team_seat WHERE team_id = $1 AND member_customer_id = $2)", teamID, customerID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check team membership: %w", err)
+	}
+	return exists, nil
+}
+
+// ClaimSeat занимает свободное место за клиентом. Возвращает false, если место уже занято
+// (например, одновременная активация одной ссылки двумя пользователями)
+func (r *TeamRepository) ClaimSeat(ctx context.Context, seatID, customerID int64, redeemedAt time.Time) (bool, error) {
+	tag, err := r.pool.Exec(ctx,
+		"UPDATE team_seat SET member_customer_id = $2, redeemed_at = $3 WHERE id = $1 AND member_customer_id IS NULL",
+		seatID, customerID, redeemedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim team seat: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseSeat освобождает место и заменяет его код: старая ссылка-приглашение перестаёт работать
+func (r *TeamRepository) ReleaseSeat(ctx context.Context, seatID int64, code string) error {
+	_, err := r.pool.Exec(ctx,
+		"UPDATE team_seat SET member_customer_id = NULL, redeemed_at = NULL, code = $2 WHERE id = $1",
+		seatID, code)
+	if err != nil {
+		return fmt.Errorf("failed to release team seat: %w", err)
+	}
+	return nil
+}
+
+// Expire завершает срок команды в момент at: свободные места больше нельзя активировать
+func (r *TeamRepository) Expire(ctx context.Context, teamID int64, at time.Time) error {
+	_, err := r.pool.Exec(ctx, "UPDATE team SET expires_at = $2 WHERE id = $1 AND expires_at > $2", teamID, at)
+	if err != nil {
+		return fmt.Errorf("failed to expire team: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// createTestCustomer создаёт клиента через репозиторий и задаёт поля, которые Create не принимает
+func createTestCustomer(t *testing.T, repo *CustomerRepository, telegramID int64, updates map[string]interface{}) *Customer {
+	t.Helper()
+	ctx := context.Background()
+
+	customer, err := repo.Create(ctx, &Customer{TelegramID: telegramID, Language: "ru"})
+	if err != nil {
+		t.Fatalf("Create() returned error: %v", err)
+	}
+	if len(updates) > 0 {
+		if err := repo.UpdateFields(ctx, customer.ID, updates); err != nil {
+			t.Fatalf("UpdateFields() returned error: %v", err)
+		}
+		if customer, err = repo.FindById(ctx, customer.ID); err != nil {
+			t.Fatalf("FindById() returned error: %v", err)
+		}
+	}
+	return customer
+}
+
+// createTestPurchase добавляет покупку клиента с заданным статусом
+func createTestPurchase(t *testing.T, pool *pgxpool.Pool, customerID int64, status PurchaseStatus) int64 {
+	t.Helper()
+	var id int64
+	err := pool.QueryRow(context.Background(), `
+		INSERT INTO purchase (amount, customer_id, month, currency, status, invoice_type)
+		VALUES (100, $1, 1, 'RUB', $2, $3) RETURNING id`, customerID, status, InvoiceTypeYookasa).Scan(&id)
+	if err != nil {
+		t.Fatalf("Failed to insert purchase: %v", err)
+	}
+	return id
+}
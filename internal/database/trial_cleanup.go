@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type TrialCleanupRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewTrialCleanupRepository(pool *pgxpool.Pool) *TrialCleanupRepository {
+	return &TrialCleanupRepository{pool: pool}
+}
+
+// buildTrialCleanupQuery выбирает клиентов, чей триал истёк раньше expiredBefore и которые ни разу не платили.
+// Клиенты, уже очищенные после окончания подписки, не выбираются. Если подписку после очистки снова выдали
+// (реферальный бонус, подарок), expire_at становится позже trial_cleaned_at и клиент выбирается повторно
+func buildTrialCleanupQuery(expiredBefore time.Time, limit int) sq.SelectBuilder {
+	return sq.Select(prefixedCustomerColumns("c")...).
+		From("customer c").
+		Where(sq.And{
+			sq.NotEq{"c.trial_activated_at": nil},
+			sq.Eq{"c.deleted_at": nil},
+			sq.Lt{"c.expire_at": expiredBefore},
+			sq.Or{
+				sq.Eq{"c.trial_cleaned_at": nil},
+				sq.Expr("c.trial_cleaned_at < c.expire_at"),
+			},
+			sq.Expr(`NOT EXISTS (
+				SELECT 1 FROM purchase p
+				WHERE p.customer_id = c.id AND p.status = ? AND NOT p.is_test
+			)`, PurchaseStatusPaid),
+		}).
+		OrderBy("c.expire_at").
+		Limit(uint64(limit))
+}
+
+// FindExpired возвращает до limit клиентов без оплат, чей триал истёк раньше expiredBefore
+func (r *TrialCleanupRepository) FindExpired(ctx context.Context, expiredBefore time.Time, limit int) ([]Customer, error) {
+	sql, args, err := buildTrialCleanupQuery(expiredBefore, limit).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trial cleanup query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired trial customers: %w", err)
+	}
+	defer rows.Close()
+
+	var customers []Customer
+	for rows.Next() {
+		customer, err := scanCustomerFromRows(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan customer row: %w", err)
+		}
+		customers = append(customers, *customer)
+	}
+	return customers, rows.Err()
+}
+
+// MarkCleaned отмечает, что пользователь панели клиента отключён или удалён
+func (r *TrialCleanupRepository) MarkCleaned(ctx context.Context, customerID int64, at time.Time) error {
+	if _, err := r.pool.Exec(ctx, `UPDATE customer SET trial_cleaned_at = $1 WHERE id = $2`, at, customerID); err != nil {
+		return fmt.Errorf("failed to mark trial cleaned: %w", err)
+	}
+	cachedCustomers.invalidate(customerID)
+	return nil
+}
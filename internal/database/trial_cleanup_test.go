@@ -0,0 +1,36 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+func TestBuildTrialCleanupQuery(t *testing.T) {
+	expiredBefore := time.Date(2025, 3, 6, 3, 30, 0, 0, time.UTC)
+
+	sql, args, err := buildTrialCleanupQuery(expiredBefore, 500).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"FROM customer c",
+		"c.trial_activated_at IS NOT NULL",
+		"c.deleted_at IS NULL",
+		"c.expire_at < $1",
+		"(c.trial_cleaned_at IS NULL OR c.trial_cleaned_at < c.expire_at)",
+		"p.status = $2 AND NOT p.is_test",
+		"ORDER BY c.expire_at",
+		"LIMIT 500",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("SQL does not contain %q: %s", want, sql)
+		}
+	}
+	if expectedArgs := []interface{}{expiredBefore, PurchaseStatusPaid}; !reflect.DeepEqual(args, expectedArgs) {
+		t.Fatalf("unexpected args, want %v, got %v", expectedArgs, args)
+	}
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type TrialUpsellRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewTrialUpsellRepository(pool *pgxpool.Pool) *TrialUpsellRepository {
+	return &TrialUpsellRepository{pool: pool}
+}
+
+// buildTrialUpsellQuery выбирает клиентов с действующим триалом, которым пора отправить шаг step: column
+// (trial_activated_at или expire_at) попадает в промежуток (from, to]. Клиенты, оплатившие подписку после
+// активации триала, клиенты, отказавшиеся от маркетинговых сообщений, и клиенты, уже получившие шаг за этот триал,
+// не выбираются
+func buildTrialUpsellQuery(step, column string, from, to, now time.Time, limit int) sq.SelectBuilder {
+	return sq.Select(prefixedCustomerColumns("c")...).
+		From("customer c").
+		Where(sq.And{
+			sq.NotEq{"c.trial_activated_at": nil},
+			sq.Eq{"c.marketing_opted_out_at": nil},
+			sq.Eq{"c.deleted_at": nil},
+			sq.Gt{"c.expire_at": now},
+			sq.Gt{"c." + column: from},
+			sq.LtOrEq{"c." + column: to},
+			sq.Expr(`NOT EXISTS (
+				SELECT 1 FROM purchase p
+				WHERE p.customer_id = c.id AND p.status = ? AND NOT p.is_test AND p.paid_at >= c.trial_activated_at
+			)`, PurchaseStatusPaid),
+			sq.Expr(`NOT EXISTS (
+				SELECT 1 FROM trial_upsell_send s
+				WHERE s.step = ? AND s.customer_id = c.id AND s.trial_activated_at = c.trial_activated_at
+			)`, step),
+		}).
+		OrderBy("c." + column).
+		Limit(uint64(limit))
+}
+
+// FindDue возвращает до limit клиентов, которым пора отправить шаг step. column — от чего отсчитывается шаг:
+// trial_activated_at или expire_at
+func (r *TrialUpsellRepository) FindDue(ctx context.Context, step, column string, from, to, now time.Time, limit int) ([]Customer, error) {
+	sql, args, err := buildTrialUpsellQuery(step, column, from, to, now, limit).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trial upsell query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trial upsell customers: %w", err)
+	}
+	defer rows.Close()
+
+	var customers []Customer
+	for rows.Next() {
+		customer, err := scanCustomerFromRows(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan customer row: %w", err)
+		}
+		customers = append(customers, *customer)
+	}
+	return customers, rows.Err()
+}
+
+// Claim отмечает, что клиенту отправляется шаг step за триал, активированный в trialActivatedAt.
+// Возвращает false, если шаг уже отправлялся. Отметка ставится до отправки, поэтому при сбое шаг не повторяется
+func (r *TrialUpsellRepository) Claim(ctx context.Context, step string, customerID int64, trialActivatedAt, at time.Time) (bool, error) {
+	tag, err := r.pool.Exec(ctx, `
+		INSERT INTO trial_upsell_send (step, customer_id, trial_activated_at, sent_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (step, customer_id, trial_activated_at) DO NOTHING`,
+		step, customerID, trialActivatedAt, at)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim trial upsell step: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
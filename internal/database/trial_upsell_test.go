@@ -0,0 +1,42 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+func TestBuildTrialUpsellQuery(t *testing.T) {
+	now := time.Date(2025, 1, 3, 12, 0, 0, 0, time.UTC)
+	to := now.Add(-48 * time.Hour)
+	from := to.Add(-12 * time.Hour)
+
+	sql, args, err := buildTrialUpsellQuery("discount", "trial_activated_at", from, to, now, 100).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() returned error: %v", err)
+	}
+	for _, want := range []string{
+		"FROM customer c",
+		"c.trial_activated_at IS NOT NULL",
+		"c.marketing_opted_out_at IS NULL",
+		"c.deleted_at IS NULL",
+		"c.expire_at > $1",
+		"c.trial_activated_at > $2",
+		"c.trial_activated_at <= $3",
+		"p.status = $4",
+		"p.paid_at >= c.trial_activated_at",
+		"s.step = $5",
+		"ORDER BY c.trial_activated_at",
+		"LIMIT 100",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("SQL does not contain %q: %s", want, sql)
+		}
+	}
+	if expectedArgs := []interface{}{now, from, to, PurchaseStatusPaid, "discount"}; !reflect.DeepEqual(args, expectedArgs) {
+		t.Fatalf("unexpected args, want %v, got %v", expectedArgs, args)
+	}
+}
@@ -0,0 +1,222 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type WebhookEventStatus string
+
+const (
+	WebhookEventStatusPending WebhookEventStatus = "pending"
+	// WebhookEventStatusProcessing событие захвачено обработчиком до next_attempt_at. Если обработчик упал,
+	// не сохранив результат, после next_attempt_at событие снова захватывается
+	WebhookEventStatusProcessing WebhookEventStatus = "processing"
+	WebhookEventStatusProcessed  WebhookEventStatus = "processed"
+	WebhookEventStatusFailed     WebhookEventStatus = "failed"
+)
+
+// WebhookEvent представляет сохранённое входящее событие Remnawave webhook
+type WebhookEvent struct {
+	ID            int64              `db:"id"`
+	EventID       string             `db:"event_id"`
+	Event         string             `db:"event"`
+	Payload       []byte             `db:"payload"`
+	Status        WebhookEventStatus `db:"status"`
+	Attempts      int                `db:"attempts"`
+	LastError     *string            `db:"last_error"`
+	NextAttemptAt *time.Time         `db:"next_attempt_at"`
+	CreatedAt     time.Time          `db:"created_at"`
+	ProcessedAt   *time.Time         `db:"processed_at"`
+}
+
+func webhookEventColumns() []string {
+	return []string{
+		"id", "event_id", "event", "payload", "status", "attempts",
+		"last_error", "next_attempt_at", "created_at", "processed_at",
+	}
+}
+
+func scanWebhookEventFromRows(rows pgx.Rows) (*WebhookEvent, error) {
+	var e WebhookEvent
+	err := rows.Scan(
+		&e.ID, &e.EventID, &e.Event, &e.Payload, &e.Status, &e.Attempts,
+		&e.LastError, &e.NextAttemptAt, &e.CreatedAt, &e.ProcessedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+type WebhookEventRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewWebhookEventRepository(pool *pgxpool.Pool) *WebhookEventRepository {
+	return &WebhookEventRepository{pool: pool}
+}
+
+// CreateIfNotExists сохраняет событие, сразу захваченное для обработки до claimUntil. Возвращает created=false если событие
+// с таким event_id уже было получено ранее (дубликат)
+func (r *WebhookEventRepository) CreateIfNotExists(ctx context.Context, eventID, event string, payload []byte, claimUntil time.Time) (id int64, created bool, err error) {
+	query := sq.Insert("remnawave_webhook_event").
+		Columns("event_id", "event", "payload", "status", "next_attempt_at").
+		Values(eventID, event, string(payload), WebhookEventStatusProcessing, claimUntil).
+		Suffix("ON CONFLICT (event_id) DO NOTHING RETURNING id").
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return 0, false, fmt.Errorf("build query: %w", err)
+	}
+
+	err = r.pool.QueryRow(ctx, sql, args...).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("insert webhook event: %w", err)
+	}
+	return id, true, nil
+}
+
+// ClaimDue захватывает до limit событий, ожидающих (повторной) обработки, до claimUntil и возвращает их.
+// Захват атомарный: параллельные запуски и обработка сразу после получения не берут одно событие дважды.
+// События processing с истёкшим сроком захвата (обработчик упал) захватываются снова
+func (r *WebhookEventRepository) ClaimDue(ctx context.Context, now, claimUntil time.Time, limit int) ([]WebhookEvent, error) {
+	sql := fmt.Sprintf(`
+		UPDATE remnawave_webhook_event SET status = $1, next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM remnawave_webhook_event
+			WHERE status IN ($3, $1) AND next_attempt_at <= $4
+			ORDER BY next_attempt_at ASC
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING %s`, strings.Join(webhookEventColumns(), ", "))
+
+	rows, err := r.pool.Query(ctx, sql, WebhookEventStatusProcessing, claimUntil, WebhookEventStatusPending, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook events: %w", err)
+	}
+	return collectWebhookEvents(rows)
+}
+
+// FindFailed возвращает события, для которых исчерпаны попытки обработки
+func (r *WebhookEventRepository) FindFailed(ctx context.Context, limit int) ([]WebhookEvent, error) {
+	query := sq.Select(webhookEventColumns()...).
+		From("remnawave_webhook_event").
+		Where(sq.Eq{"status": WebhookEventStatusFailed}).
+		OrderBy("created_at ASC").
+		Limit(uint64(limit)).
+		PlaceholderFormat(sq.Dollar)
+
+	return r.query(ctx, query)
+}
+
+func (r *WebhookEventRepository) query(ctx context.Context, query sq.SelectBuilder) ([]WebhookEvent, error) {
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("build query: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query webhook events: %w", err)
+	}
+	return collectWebhookEvents(rows)
+}
+
+func collectWebhookEvents(rows pgx.Rows) ([]WebhookEvent, error) {
+	defer rows.Close()
+
+	var events []WebhookEvent
+	for rows.Next() {
+		e, err := scanWebhookEventFromRows(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan webhook event: %w", err)
+		}
+		events = append(events, *e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate rows: %w", err)
+	}
+	return events, nil
+}
+
+// MarkProcessed помечает событие как успешно обработанное
+func (r *WebhookEventRepository) MarkProcessed(ctx context.Context, id int64, attempts int) error {
+	return r.update(ctx, id, map[string]interface{}{
+		"status":       WebhookEventStatusProcessed,
+		"attempts":     attempts,
+		"last_error":   nil,
+		"processed_at": time.Now(),
+	})
+}
+
+// ScheduleRetry сохраняет ошибку и время следующей попытки обработки
+func (r *WebhookEventRepository) ScheduleRetry(ctx context.Context, id int64, attempts int, lastError string, nextAttemptAt time.Time) error {
+	return r.update(ctx, id, map[string]interface{}{
+		"status":          WebhookEventStatusPending,
+		"attempts":        attempts,
+		"last_error":      lastError,
+		"next_attempt_at": nextAttemptAt,
+	})
+}
+
+// MarkFailed помечает событие как окончательно необработанное
+func (r *WebhookEventRepository) MarkFailed(ctx context.Context, id int64, attempts int, lastError string) error {
+	return r.update(ctx, id, map[string]interface{}{
+		"status":     WebhookEventStatusFailed,
+		"attempts":   attempts,
+		"last_error": lastError,
+	})
+}
+
+// ResetFailed возвращает все failed события в очередь на обработку
+func (r *WebhookEventRepository) ResetFailed(ctx context.Context) (int64, error) {
+	query := sq.Update("remnawave_webhook_event").
+		Set("status", WebhookEventStatusPending).
+		Set("attempts", 0).
+		Set("next_attempt_at", time.Now()).
+		Where(sq.Eq{"status": WebhookEventStatusFailed}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("build query: %w", err)
+	}
+
+	result, err := r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, fmt.Errorf("reset failed webhook events: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
+
+func (r *WebhookEventRepository) update(ctx context.Context, id int64, updates map[string]interface{}) error {
+	query := sq.Update("remnawave_webhook_event").
+		SetMap(updates).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar)
+
+	sql, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("build query: %w", err)
+	}
+
+	_, err = r.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return fmt.Errorf("update webhook event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ChurnedCustomer ушедший платящий клиент вместе с параметрами его последней оплаченной покупки
+type ChurnedCustomer struct {
+	Customer
+	LastTariff  *string
+	LastMonths  int
+	LastDevices *int
+}
+
+// WinbackOffer предложение ушедшему клиенту, сохраняемое в customer.winback_offer_*
+type WinbackOffer struct {
+	Campaign  string
+	Tariff    *string
+	Price     int
+	Devices   int
+	Months    int
+	SentAt    time.Time
+	ExpiresAt time.Time
+}
+
+// WinbackCampaignStats статистика кампании за всё время
+type WinbackCampaignStats struct {
+	Campaign  string
+	Sent      int
+	Converted int
+	OptedOut  int
+}
+
+type WinbackRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewWinbackRepository(pool *pgxpool.Pool) *WinbackRepository {
+	return &WinbackRepository{pool: pool}
+}
+
+// FindChurned возвращает клиентов с оплаченными покупками, чья подписка закончилась в промежутке (from, to],
+// которые не отказались от предложений и ещё не получали предложение кампании campaign за этот уход.
+// Командные и тестовые покупки не считаются: по ним нечего предлагать продлить
+func (r *WinbackRepository) FindChurned(ctx context.Context, campaign string, from, to time.Time, limit int) ([]ChurnedCustomer, error) {
+	query := `
+		SELECT ` + strings.Join(prefixedCustomerColumns("c"), ", ") + `, lp.tariff_name, lp.month, lp.device_limit
+		FROM customer c
+		JOIN LATERAL (
+			SELECT p.tariff_name, p.month, p.device_limit
+			FROM ` + purchaseHistoryView + ` p
+			WHERE p.customer_id = c.id
+			  AND p.status = $1
+			  AND NOT p.is_test
+			  AND NOT EXISTS (SELECT 1 FROM team t WHERE t.purchase_id = p.id)
+			ORDER BY p.paid_at DESC NULLS LAST, p.id DESC
+			LIMIT 1
+		) lp ON TRUE
+		WHERE c.expire_at > $2
+		  AND c.expire_at <= $3
+		  AND c.winback_opted_out_at IS NULL
+		  AND c.deleted_at IS NULL
+		  AND NOT EXISTS (
+			SELECT 1 FROM winback_send s
+			WHERE s.campaign = $4 AND s.customer_id = c.id AND s.expire_at = c.expire_at
+		  )
+		ORDER BY c.expire_at
+		LIMIT $5`
+
+	rows, err := r.pool.Query(ctx, query, PurchaseStatusPaid, from, to, campaign, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query churned customers: %w", err)
+	}
+	defer rows.Close()
+
+	var customers []ChurnedCustomer
+	for rows.Next() {
+		var c ChurnedCustomer
+		if err := rows.Scan(append(customerFields(&c.Customer), &c.LastTariff, &c.LastMonths, &c.LastDevices)...); err != nil {
+			return nil, fmt.Errorf("failed to scan churned customer: %w", err)
+		}
+		if err := decryptCustomer(&c.Customer); err != nil {
+			return nil, fmt.Errorf("failed to scan churned customer: %w", err)
+		}
+		customers = append(customers, c)
+	}
+	return customers, rows.Err()
+}
+
+// SaveOffer сохраняет предложение клиенту и отмечает отправку в кампании. churnedAt — дата окончания подписки,
+// по ней одно и то же предложение не отправляется повторно за один уход
+func (r *WinbackRepository) SaveOffer(ctx context.Context, customerID int64, churnedAt time.Time, offer WinbackOffer) error {
+	defer cachedCustomers.invalidate(customerID)
+	_, err := r.pool.Exec(ctx, `
+		WITH s AS (
+			INSERT INTO winback_send (campaign, customer_id, expire_at, sent_at)
+			VALUES ($2, $1, $3, $4)
+			ON CONFLICT (campaign, customer_id, expire_at) DO NOTHING
+		)
+		UPDATE customer SET
+			winback_offer_sent_at = $4,
+			winback_offer_expires_at = $5,
+			winback_offer_price = $6,
+			winback_offer_devices = $7,
+			winback_offer_months = $8,
+			winback_offer_tariff = $9,
+			winback_offer_campaign = $2,
+			winback_offer_reminded_at = NULL
+		WHERE id = $1`,
+		customerID, offer.Campaign, churnedAt, offer.SentAt, offer.ExpiresAt, offer.Price, offer.Devices, offer.Months, offer.Tariff)
+	if err != nil {
+		return fmt.Errorf("failed to save winback offer: %w", err)
+	}
+	return nil
+}
+
+// MarkConverted отмечает последнее предложение кампании клиенту как сработавшее
+func (r *WinbackRepository) MarkConverted(ctx context.Context, customerID int64, campaign string, purchaseID int64, at time.Time) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE winback_send SET purchase_id = $3, converted_at = $4
+		WHERE id = (
+			SELECT id FROM winback_send
+			WHERE customer_id = $1 AND campaign = $2 AND converted_at IS NULL
+			ORDER BY sent_at DESC
+			LIMIT 1
+		)`, customerID, campaign, purchaseID, at)
+	if err != nil {
+		return fmt.Errorf("failed to mark winback conversion: %w", err)
+	}
+	return nil
+}
+
+// OptOut отключает предложения клиенту и засчитывает отказ последней отправленной ему кампании
+func (r *WinbackRepository) OptOut(ctx context.Context, customerID int64, at time.Time) error {
+	defer cachedCustomers.invalidate(customerID)
+	_, err := r.pool.Exec(ctx, `
+		WITH s AS (
+			UPDATE winback_send SET opted_out_at = $2
+			WHERE id = (
+				SELECT id FROM winback_send
+				WHERE customer_id = $1
+				ORDER BY sent_at DESC
+				LIMIT 1
+			) AND opted_out_at IS NULL
+		)
+		UPDATE customer SET winback_opted_out_at = $2
+		WHERE id = $1 AND winback_opted_out_at IS NULL`, customerID, at)
+	if err != nil {
+		return fmt.Errorf("failed to opt out of winback: %w", err)
+	}
+	return nil
+}
+
+// CampaignStats возвращает статистику по каждой кампании: отправлено, куплено и отказались
+func (r *WinbackRepository) CampaignStats(ctx context.Context) ([]WinbackCampaignStats, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT campaign, COUNT(*), COUNT(converted_at), COUNT(opted_out_at)
+		FROM winback_send
+		GROUP BY campaign
+		ORDER BY campaign`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query winback stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []WinbackCampaignStats
+	for rows.Next() {
+		var st WinbackCampaignStats
+		if err := rows.Scan(&st.Campaign, &st.Sent, &st.Converted, &st.OptedOut); err != nil {
+			return nil, fmt.Errorf("failed to scan winback stats: %w", err)
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
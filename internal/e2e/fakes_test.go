@@ -0,0 +1,367 @@
+package e2e
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/v2/api"
+	"github.com/go-telegram/bot/models"
+	"github.com/google/uuid"
+
+	"remnawave-tg-shop-bot/internal/cryptopay"
+	"remnawave-tg-shop-bot/internal/yookasa"
+)
+
+// botCall запрос к Bot API, записанный фейковым сервером
+type botCall struct {
+	method    string
+	params    map[string]string
+	messageID int
+}
+
+// keyboard возвращает inline-клавиатуру из параметров вызова
+func (c botCall) keyboard() [][]models.InlineKeyboardButton {
+	var markup models.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(c.params["reply_markup"]), &markup); err != nil {
+		return nil
+	}
+	return markup.InlineKeyboard
+}
+
+// fakeTelegram фейковый Bot API: записывает вызовы, выдаёт сообщениям номера и помнит их клавиатуры
+type fakeTelegram struct {
+	mu     sync.Mutex
+	calls  []botCall
+	nextID int
+}
+
+func newFakeTelegram(t *testing.T) (*fakeTelegram, string) {
+	tg := &fakeTelegram{nextID: 100}
+	srv := httptest.NewServer(http.HandlerFunc(tg.serve))
+	t.Cleanup(srv.Close)
+	return tg, srv.URL
+}
+
+func (f *fakeTelegram) serve(w http.ResponseWriter, r *http.Request) {
+	call := botCall{method: r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], params: map[string]string{}}
+	if err := r.ParseMultipartForm(1 << 20); err == nil {
+		for k, v := range r.MultipartForm.Value {
+			call.params[k] = v[0]
+		}
+	}
+
+	f.mu.Lock()
+	if id, err := strconv.Atoi(call.params["message_id"]); err == nil {
+		call.messageID = id
+	} else {
+		f.nextID++
+		call.messageID = f.nextID
+	}
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+
+	var result any = true
+	if call.method != "answerCallbackQuery" && call.method != "deleteMessage" {
+		chatID, _ := strconv.ParseInt(call.params["chat_id"], 10, 64)
+		result = models.Message{ID: call.messageID, Chat: models.Chat{ID: chatID}}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+// called возвращает вызовы метода method
+func (f *fakeTelegram) called(method string) []botCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []botCall
+	for _, c := range f.calls {
+		if c.method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// button ищет в последних показанных клавиатурах кнопку, callback_data которой начинается с prefix
+// и содержит все части contains. Возвращает данные кнопки и номер сообщения с ней
+func (f *fakeTelegram) button(t *testing.T, prefix string, contains ...string) (string, int) {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.calls) - 1; i >= 0; i-- {
+		for _, row := range f.calls[i].keyboard() {
+			for _, btn := range row {
+				if strings.HasPrefix(btn.CallbackData, prefix) && containsAll(btn.CallbackData, contains) {
+					return btn.CallbackData, f.calls[i].messageID
+				}
+			}
+		}
+	}
+	t.Fatalf("Button %q %v not found in any keyboard shown to the user", prefix, contains)
+	return "", 0
+}
+
+func containsAll(s string, parts []string) bool {
+	for _, part := range parts {
+		if !strings.Contains(s, part) {
+			return false
+		}
+	}
+	return true
+}
+
+// fakeYookasa фейковое API ЮKassa: создаёт платежи и отдаёт их статус, оплату подтверждает тест
+type fakeYookasa struct {
+	mu       sync.Mutex
+	payments map[uuid.UUID]*yookasa.Payment
+}
+
+func newFakeYookasa(t *testing.T) (*fakeYookasa, string) {
+	f := &fakeYookasa{payments: map[uuid.UUID]*yookasa.Payment{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /payments", f.create)
+	mux.HandleFunc("GET /payments/{id}", f.get)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return f, srv.URL
+}
+
+func (f *fakeYookasa) create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Amount   yookasa.Amount `json:"amount"`
+		Metadata map[string]any `json:"metadata"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// ЮKassa возвращает метаданные строками
+	metadata := make(map[string]string, len(req.Metadata))
+	for k, v := range req.Metadata {
+		metadata[k] = fmt.Sprint(v)
+	}
+	id := uuid.New()
+	payment := &yookasa.Payment{
+		ID:           id,
+		Status:       "pending",
+		Amount:       req.Amount,
+		Confirmation: yookasa.ConfirmationType{Type: "redirect", ConfirmationURL: "https://yoomoney.test/checkout/" + id.String()},
+		Metadata:     metadata,
+	}
+
+	f.mu.Lock()
+	f.payments[id] = payment
+	f.mu.Unlock()
+	writeJSON(w, http.StatusOK, payment)
+}
+
+func (f *fakeYookasa) get(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	payment, ok := f.payments[id]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, payment)
+}
+
+// created возвращает копии созданных платежей
+func (f *fakeYookasa) created() []yookasa.Payment {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var payments []yookasa.Payment
+	for _, payment := range f.payments {
+		payments = append(payments, *payment)
+	}
+	return payments
+}
+
+// pay отмечает платёж оплаченным, как после оплаты на странице ЮKassa
+func (f *fakeYookasa) pay(t *testing.T, id uuid.UUID) {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	payment, ok := f.payments[id]
+	if !ok {
+		t.Fatalf("YooKassa payment %s was not created", id)
+	}
+	payment.Status = "succeeded"
+	payment.Paid = true
+}
+
+// fakeCryptoPay фейковое API CryptoPay: создаёт счета и отдаёт их статус, оплату подтверждает тест
+type fakeCryptoPay struct {
+	mu       sync.Mutex
+	invoices map[int64]*cryptopay.InvoiceResponse
+	nextID   int64
+}
+
+func newFakeCryptoPay(t *testing.T) (*fakeCryptoPay, string) {
+	f := &fakeCryptoPay{invoices: map[int64]*cryptopay.InvoiceResponse{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/createInvoice", f.create)
+	mux.HandleFunc("GET /api/getInvoices", f.list)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return f, srv.URL
+}
+
+func (f *fakeCryptoPay) create(w http.ResponseWriter, r *http.Request) {
+	var req cryptopay.InvoiceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.nextID++
+	id := f.nextID
+	invoice := &cryptopay.InvoiceResponse{
+		InvoiceID:     &id,
+		CurrencyType:  req.CurrencyType,
+		Fiat:          req.Fiat,
+		Amount:        req.Amount,
+		Status:        "active",
+		Payload:       req.Payload,
+		BotInvoiceUrl: fmt.Sprintf("https://t.me/CryptoBot?start=IV%d", id),
+	}
+	f.invoices[id] = invoice
+	f.mu.Unlock()
+	writeJSON(w, http.StatusOK, cryptopay.ResponseWrapper[cryptopay.InvoiceResponse]{Ok: true, Result: *invoice})
+}
+
+func (f *fakeCryptoPay) list(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var items []cryptopay.InvoiceResponse
+	for _, raw := range strings.Split(r.URL.Query().Get("invoice_ids"), ",") {
+		id, _ := strconv.ParseInt(raw, 10, 64)
+		if invoice, ok := f.invoices[id]; ok {
+			items = append(items, *invoice)
+		}
+	}
+	writeJSON(w, http.StatusOK, cryptopay.ResponseListWrapper[cryptopay.InvoiceResponse]{
+		Ok: true, Result: cryptopay.ResultListWrapper[cryptopay.InvoiceResponse]{Items: items},
+	})
+}
+
+// created возвращает копии созданных счетов
+func (f *fakeCryptoPay) created() []cryptopay.InvoiceResponse {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var invoices []cryptopay.InvoiceResponse
+	for _, invoice := range f.invoices {
+		invoices = append(invoices, *invoice)
+	}
+	return invoices
+}
+
+// pay отмечает счёт оплаченным, как после оплаты в @CryptoBot
+func (f *fakeCryptoPay) pay(t *testing.T, id int64) {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	invoice, ok := f.invoices[id]
+	if !ok {
+		t.Fatalf("CryptoPay invoice %d was not created", id)
+	}
+	invoice.Status = "paid"
+}
+
+// fakeRemnawave фейковая панель Remnawave. Поддерживает только выдачу подписки новым пользователям
+type fakeRemnawave struct {
+	mu      sync.Mutex
+	created []remapi.CreateUserRequestDto
+}
+
+func newFakeRemnawave(t *testing.T) (*fakeRemnawave, string) {
+	f := &fakeRemnawave{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users/by-telegram-id/{id}", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("GET /api/internal-squads", f.squads)
+	mux.HandleFunc("POST /api/users", f.createUser)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return f, srv.URL
+}
+
+func (f *fakeRemnawave) squads(w http.ResponseWriter, r *http.Request) {
+	var resp remapi.GetInternalSquadsResponseDto
+	resp.SetFake()
+	resp.Response.InternalSquads = nil
+	writeOgenJSON(w, http.StatusOK, &resp)
+}
+
+func (f *fakeRemnawave) createUser(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req remapi.CreateUserRequestDto
+	if err := req.UnmarshalJSON(body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.created = append(f.created, req)
+	f.mu.Unlock()
+
+	var resp remapi.UserResponse
+	resp.SetFake()
+	user := &resp.Response
+	user.UUID = uuid.New()
+	user.Username = req.Username
+	user.ExpireAt = req.ExpireAt
+	user.TelegramId = remapi.NilInt{Null: true}
+	if v, ok := req.TelegramId.Get(); ok {
+		user.TelegramId = remapi.NewNilInt(v)
+	}
+	user.SubscriptionUrl = "https://sub.example.com/" + req.Username
+	user.HwidDeviceLimit = remapi.NilInt{Null: true}
+	if v, ok := req.HwidDeviceLimit.Get(); ok {
+		user.HwidDeviceLimit = remapi.NewNilInt(v)
+	}
+	writeOgenJSON(w, http.StatusCreated, &resp)
+}
+
+// createdUsers возвращает запросы на создание пользователей в панели
+func (f *fakeRemnawave) createdUsers() []remapi.CreateUserRequestDto {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]remapi.CreateUserRequestDto(nil), f.created...)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeOgenJSON отвечает моделью сгенерированного клиента Remnawave в его собственной JSON-кодировке
+func writeOgenJSON(w http.ResponseWriter, status int, v json.Marshaler) {
+	body, err := v.MarshalJSON()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
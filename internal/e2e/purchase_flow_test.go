@@ -0,0 +1,332 @@
+package e2e
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/cache"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/cryptopay"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/database/dbtest"
+	"remnawave-tg-shop-bot/internal/handler"
+	"remnawave-tg-shop-bot/internal/payment"
+	"remnawave-tg-shop-bot/internal/remnawave"
+	"remnawave-tg-shop-bot/internal/translation"
+	"remnawave-tg-shop-bot/internal/yookasa"
+)
+
+const (
+	buyerID       = int64(42)
+	buyerUsername = "buyer"
+	proDevices    = 3
+	proPrice1     = 199
+)
+
+// simulator бот с настоящими обработчиками, платёжным сервисом и базой,
+// у которого Telegram, платёжные системы и панель Remnawave заменены фейковыми серверами
+type simulator struct {
+	t         *testing.T
+	ctx       context.Context
+	bot       *bot.Bot
+	tg        *fakeTelegram
+	yookasa   *fakeYookasa
+	crypto    *fakeCryptoPay
+	panel     *fakeRemnawave
+	payments  *payment.PaymentService
+	customers *database.CustomerRepository
+	purchases *database.PurchaseRepository
+	tm        *translation.Manager
+	updateID  int64
+}
+
+func newSimulator(t *testing.T) *simulator {
+	t.Helper()
+	pool := dbtest.Pool(t)
+	ctx := context.Background()
+
+	tg, tgURL := newFakeTelegram(t)
+	yk, ykURL := newFakeYookasa(t)
+	crypto, cryptoURL := newFakeCryptoPay(t)
+	panel, panelURL := newFakeRemnawave(t)
+
+	for key, value := range map[string]string{
+		"TELEGRAM_TOKEN":      "test-token",
+		"ADMIN_TELEGRAM_ID":   "1",
+		"DATABASE_URL":        "postgres://unused",
+		"REMNAWAVE_URL":       panelURL,
+		"REMNAWAVE_TOKEN":     "test-token",
+		"TRIAL_TRAFFIC_LIMIT": "10",
+		"TRIAL_DAYS":          "3",
+		"TRAFFIC_LIMIT":       "100",
+		"REFERRAL_DAYS":       "7",
+		"DAYS_IN_MONTH":       "30",
+		"PRICE_1":             "150",
+		"PRICE_3":             "400",
+		"PRICE_6":             "750",
+		"PRICE_12":            "1400",
+		"YOOKASA_ENABLED":     "true",
+		"YOOKASA_URL":         ykURL,
+		"YOOKASA_SHOP_ID":     "shop",
+		"YOOKASA_SECRET_KEY":  "secret",
+		"YOOKASA_EMAIL":       "shop@example.com",
+		"CRYPTO_PAY_ENABLED":  "true",
+		"CRYPTO_PAY_URL":      cryptoURL,
+		"CRYPTO_PAY_TOKEN":    "token",
+		// Два тарифа: покупатель проходит через выбор тарифа
+		"TARIFF_BASE_ENABLED":  "true",
+		"TARIFF_BASE_DEVICES":  "1",
+		"TARIFF_BASE_PRICE_1":  "99",
+		"TARIFF_BASE_PRICE_3":  "249",
+		"TARIFF_BASE_PRICE_6":  "449",
+		"TARIFF_BASE_PRICE_12": "799",
+		"TARIFF_PRO_ENABLED":   "true",
+		"TARIFF_PRO_DEVICES":   strconv.Itoa(proDevices),
+		"TARIFF_PRO_PRICE_1":   strconv.Itoa(proPrice1),
+		"TARIFF_PRO_PRICE_3":   "549",
+		"TARIFF_PRO_PRICE_6":   "999",
+		"TARIFF_PRO_PRICE_12":  "1799",
+	} {
+		t.Setenv(key, value)
+	}
+	if err := config.Load(); err != nil {
+		t.Fatalf("config.Load() returned error: %v", err)
+	}
+
+	tm := translation.GetInstance()
+	if err := tm.InitTranslations("../../translations", "ru"); err != nil {
+		t.Fatalf("InitTranslations() returned error: %v", err)
+	}
+
+	b, err := bot.New("test-token", bot.WithServerURL(tgURL), bot.WithSkipGetMe(), bot.WithNotAsyncHandlers())
+	if err != nil {
+		t.Fatalf("bot.New() returned error: %v", err)
+	}
+
+	stateCache := cache.NewCache(time.Minute)
+	customerRepository := database.NewCustomerRepository(pool)
+	purchaseRepository := database.NewPurchaseRepository(pool)
+	referralRepository := database.NewReferralRepository(pool)
+	remnawaveClient := remnawave.NewClient(config.RemnawaveUrl(), config.RemnawaveToken(), config.RemnawaveMode())
+	cryptoPayClient := cryptopay.NewCryptoPayClient(config.CryptoPayUrl(), config.CryptoPayToken())
+	yookasaClient := yookasa.NewClient(config.YookasaUrl(), config.YookasaShopId(), config.YookasaSecretKey())
+
+	paymentService := payment.NewPaymentService(tm, purchaseRepository, remnawaveClient, customerRepository, b, cryptoPayClient, yookasaClient, referralRepository, stateCache)
+	payments := handler.NewPaymentHandlers(tm, stateCache, customerRepository, purchaseRepository, paymentService, database.NewReceiptRepository(pool), database.NewWinbackRepository(pool), database.NewPaymentMethodRepository(pool))
+	profile := handler.NewProfileHandlers(tm, stateCache, customerRepository, purchaseRepository, paymentService, referralRepository, nil, remnawaveClient, nil, nil, nil, nil, payments)
+
+	// Маршруты покупки — так же, как они зарегистрированы в main
+	b.RegisterHandler(bot.HandlerTypeMessageText, "/start", bot.MatchTypePrefix, profile.StartCommandHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackBuy, bot.MatchTypeExact, payments.BuyCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackTariff, bot.MatchTypePrefix, payments.TariffCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackSell, bot.MatchTypePrefix, payments.SellCallbackHandler, profile.SuspiciousUserFilterMiddleware)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, handler.CallbackPayment, bot.MatchTypePrefix, payments.PaymentCallbackHandler, profile.SuspiciousUserFilterMiddleware, profile.TosAcceptanceMiddleware)
+
+	return &simulator{
+		t:         t,
+		ctx:       ctx,
+		bot:       b,
+		tg:        tg,
+		yookasa:   yk,
+		crypto:    crypto,
+		panel:     panel,
+		payments:  paymentService,
+		customers: customerRepository,
+		purchases: purchaseRepository,
+		tm:        tm,
+	}
+}
+
+// send отправляет боту сообщение от покупателя
+func (s *simulator) send(text string) {
+	s.updateID++
+	s.bot.ProcessUpdate(s.ctx, &models.Update{ID: s.updateID, Message: &models.Message{
+		ID:   int(s.updateID),
+		Text: text,
+		From: &models.User{ID: buyerID, Username: buyerUsername, LanguageCode: "ru"},
+		Chat: models.Chat{ID: buyerID},
+	}})
+}
+
+// click нажимает кнопку из последних показанных покупателю клавиатур
+func (s *simulator) click(prefix string, contains ...string) {
+	s.t.Helper()
+	data, messageID := s.tg.button(s.t, prefix, contains...)
+	s.updateID++
+	s.bot.ProcessUpdate(s.ctx, &models.Update{ID: s.updateID, CallbackQuery: &models.CallbackQuery{
+		ID:   strconv.FormatInt(s.updateID, 10),
+		Data: data,
+		From: models.User{ID: buyerID, Username: buyerUsername, LanguageCode: "ru"},
+		Message: models.MaybeInaccessibleMessage{
+			Type:    models.MaybeInaccessibleMessageTypeMessage,
+			Message: &models.Message{ID: messageID, Chat: models.Chat{ID: buyerID}},
+		},
+	}})
+}
+
+// choosePaymentMethod проходит от /start до выбора способа оплаты тарифа PRO на месяц
+func (s *simulator) choosePaymentMethod(invoiceType database.InvoiceType) *database.Customer {
+	s.t.Helper()
+	s.send("/start")
+	customer, err := s.customers.FindByTelegramId(s.ctx, buyerID)
+	if err != nil || customer == nil {
+		s.t.Fatalf("Expected /start to create the customer, got %v (err %v)", customer, err)
+	}
+
+	s.click(handler.CallbackBuy)
+	s.click(handler.CallbackTariff, "name=PRO")
+	s.click(handler.CallbackSell, "month=1", "tariff=PRO")
+	s.click(handler.CallbackPayment, "t="+string(invoiceType))
+	return customer
+}
+
+// purchase перечитывает покупку из базы
+func (s *simulator) purchase(id int64) *database.Purchase {
+	s.t.Helper()
+	p, err := s.purchases.FindById(s.ctx, id)
+	if err != nil || p == nil {
+		s.t.Fatalf("FindById(%d) = %v, %v", id, p, err)
+	}
+	return p
+}
+
+// assertPending проверяет покупку сразу после создания счёта и кнопку оплаты у покупателя
+func (s *simulator) assertPending(p *database.Purchase, invoiceType database.InvoiceType, payURL string) {
+	s.t.Helper()
+	if p.Status != database.PurchaseStatusPending || p.InvoiceType != invoiceType {
+		s.t.Errorf("Expected pending %s purchase, got %s %s", invoiceType, p.Status, p.InvoiceType)
+	}
+	if p.Amount != proPrice1 || p.Month != 1 || p.TariffName == nil || *p.TariffName != "PRO" {
+		s.t.Errorf("Expected PRO for 1 month at %d, got %v for %d months (tariff %v)", proPrice1, p.Amount, p.Month, p.TariffName)
+	}
+
+	edits := s.tg.called("editMessageReplyMarkup")
+	if len(edits) == 0 || !strings.Contains(edits[len(edits)-1].params["reply_markup"], payURL) {
+		s.t.Fatalf("Expected the pay button to link to %s", payURL)
+	}
+	if p.MessageID == nil || *p.MessageID != edits[len(edits)-1].messageID {
+		s.t.Errorf("Expected the payment message to be saved on the purchase, got %v", p.MessageID)
+	}
+}
+
+// assertFulfilled проверяет выдачу подписки после подтверждения оплаты
+func (s *simulator) assertFulfilled(customer *database.Customer, purchaseID int64) {
+	s.t.Helper()
+	p := s.purchase(purchaseID)
+	if p.Status != database.PurchaseStatusPaid || p.PaidAt == nil {
+		s.t.Errorf("Expected purchase to be paid, got %s (paid_at %v)", p.Status, p.PaidAt)
+	}
+
+	created := s.panel.createdUsers()
+	if len(created) != 1 {
+		s.t.Fatalf("Expected one panel user to be created, got %d", len(created))
+	}
+	if tgID, _ := created[0].TelegramId.Get(); int64(tgID) != buyerID {
+		s.t.Errorf("Expected panel user for Telegram ID %d, got %d", buyerID, tgID)
+	}
+	if devices, ok := created[0].HwidDeviceLimit.Get(); !ok || devices != proDevices {
+		s.t.Errorf("Expected the PRO device limit %d on the first purchase, got %d (set %v)", proDevices, devices, ok)
+	}
+
+	updated, err := s.customers.FindById(s.ctx, customer.ID)
+	if err != nil || updated == nil {
+		s.t.Fatalf("FindById() = %v, %v", updated, err)
+	}
+	if updated.SubscriptionLink == nil || *updated.SubscriptionLink != "https://sub.example.com/"+created[0].Username {
+		s.t.Errorf("Expected the panel subscription link to be saved, got %v", updated.SubscriptionLink)
+	}
+	wantExpire := time.Now().AddDate(0, 0, config.DaysInMonth())
+	if updated.ExpireAt == nil || updated.ExpireAt.Sub(wantExpire).Abs() > time.Minute {
+		s.t.Errorf("Expected subscription until about %s, got %v", wantExpire, updated.ExpireAt)
+	}
+
+	activated := s.tm.GetText("ru", "subscription_activated")
+	var notified bool
+	for _, call := range s.tg.called("editMessageText") {
+		if call.params["text"] == activated && p.MessageID != nil && call.messageID == *p.MessageID {
+			notified = true
+		}
+	}
+	if !notified {
+		s.t.Error("Expected the payment message to be replaced with the activation notice")
+	}
+}
+
+func TestPurchaseFlowYookasa(t *testing.T) {
+	s := newSimulator(t)
+	customer := s.choosePaymentMethod(database.InvoiceTypeYookasa)
+
+	payments := s.yookasa.created()
+	if len(payments) != 1 {
+		t.Fatalf("Expected one YooKassa payment, got %d", len(payments))
+	}
+	yp := payments[0]
+	if yp.Amount.Value != strconv.Itoa(proPrice1) || yp.Metadata["username"] != buyerUsername {
+		t.Errorf("Unexpected YooKassa payment: amount %s, metadata %v", yp.Amount.Value, yp.Metadata)
+	}
+	purchaseID, err := strconv.ParseInt(yp.Metadata["purchaseId"], 10, 64)
+	if err != nil {
+		t.Fatalf("YooKassa payment has no purchaseId in metadata: %v", yp.Metadata)
+	}
+
+	p := s.purchase(purchaseID)
+	s.assertPending(p, database.InvoiceTypeYookasa, yp.Confirmation.ConfirmationURL)
+	if p.YookasaID == nil || *p.YookasaID != yp.ID {
+		t.Errorf("Expected yookasa_id %s, got %v", yp.ID, p.YookasaID)
+	}
+
+	// Пока платёж не оплачен, проверка счетов ничего не меняет
+	s.payments.CheckYookasaInvoices(s.ctx)
+	if got := s.purchase(purchaseID).Status; got != database.PurchaseStatusPending {
+		t.Fatalf("Expected unpaid purchase to stay pending, got %s", got)
+	}
+
+	s.yookasa.pay(t, yp.ID)
+	s.payments.CheckYookasaInvoices(s.ctx)
+	s.assertFulfilled(customer, purchaseID)
+
+	// Повторная проверка не выдаёт подписку второй раз
+	s.payments.CheckYookasaInvoices(s.ctx)
+	if created := s.panel.createdUsers(); len(created) != 1 {
+		t.Errorf("Expected the paid purchase to be fulfilled once, got %d panel users", len(created))
+	}
+}
+
+func TestPurchaseFlowCryptoPay(t *testing.T) {
+	s := newSimulator(t)
+	customer := s.choosePaymentMethod(database.InvoiceTypeCrypto)
+
+	invoices := s.crypto.created()
+	if len(invoices) != 1 {
+		t.Fatalf("Expected one CryptoPay invoice, got %d", len(invoices))
+	}
+	invoice := invoices[0]
+	if invoice.Amount != strconv.Itoa(proPrice1) || invoice.Fiat != "RUB" {
+		t.Errorf("Unexpected CryptoPay invoice: %s %s", invoice.Amount, invoice.Fiat)
+	}
+	purchaseID, err := strconv.ParseInt(strings.TrimPrefix(strings.Split(invoice.Payload, "&")[0], "purchaseId="), 10, 64)
+	if err != nil {
+		t.Fatalf("CryptoPay invoice has no purchaseId in payload: %q", invoice.Payload)
+	}
+
+	p := s.purchase(purchaseID)
+	s.assertPending(p, database.InvoiceTypeCrypto, invoice.BotInvoiceUrl)
+	if p.CryptoInvoiceID == nil || *p.CryptoInvoiceID != *invoice.InvoiceID {
+		t.Errorf("Expected crypto_invoice_id %d, got %v", *invoice.InvoiceID, p.CryptoInvoiceID)
+	}
+
+	s.payments.CheckCryptoPayInvoices(s.ctx)
+	if got := s.purchase(purchaseID).Status; got != database.PurchaseStatusPending {
+		t.Fatalf("Expected unpaid purchase to stay pending, got %s", got)
+	}
+
+	s.crypto.pay(t, *invoice.InvoiceID)
+	s.payments.CheckCryptoPayInvoices(s.ctx)
+	s.assertFulfilled(customer, purchaseID)
+}
@@ -0,0 +1,295 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Провайдеры продуктовой аналитики
+const (
+	AnalyticsPostHog   = "posthog"
+	AnalyticsAmplitude = "amplitude"
+)
+
+// analyticsAttempts количество попыток отправки пачки. Повторяются сетевые ошибки, 429 и 5xx
+const analyticsAttempts = 3
+
+// analyticsEventNames имена событий в аналитике. Остальные события шины в аналитику не попадают
+var analyticsEventNames = map[Type]string{
+	TypeMenuViewed:       "menu_view",
+	TypeTariffSelected:   "tariff_selected",
+	TypePaymentCreated:   "payment_created",
+	TypePaymentSucceeded: "payment_succeeded",
+	TypeTrialActivated:   "trial_activated",
+}
+
+// analyticsEvent обезличенное событие: вместо Telegram ID — хеш с солью, без ID клиента и покупки
+type analyticsEvent struct {
+	id         string
+	name       string
+	distinctID string
+	time       time.Time
+	properties map[string]interface{}
+}
+
+// AnalyticsSink копит продуктовые события и отправляет их пачками в PostHog или Amplitude.
+// Пачка уходит, когда набралось batchSize событий, по Run раз в интервал и при закрытии шины.
+// Пока enabled возвращает false (аварийное выключение), события и накопленная пачка отбрасываются
+type AnalyticsSink struct {
+	provider  string
+	url       string
+	apiKey    string
+	salt      string
+	batchSize int
+	enabled   func() bool
+	client    *http.Client
+	// backoff пауза перед повторной попыткой attempt (начиная с 1)
+	backoff func(attempt int) time.Duration
+
+	mu    sync.Mutex
+	batch []analyticsEvent
+}
+
+func NewAnalyticsSink(provider, url, apiKey, salt string, batchSize int, enabled func() bool) *AnalyticsSink {
+	return &AnalyticsSink{
+		provider:  provider,
+		url:       url,
+		apiKey:    apiKey,
+		salt:      salt,
+		batchSize: batchSize,
+		enabled:   enabled,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		backoff: func(attempt int) time.Duration {
+			return time.Duration(attempt*attempt) * time.Second
+		},
+	}
+}
+
+func (s *AnalyticsSink) Name() string {
+	return "analytics"
+}
+
+func (s *AnalyticsSink) Handle(ctx context.Context, event Event) error {
+	if !s.enabled() {
+		s.take()
+		return nil
+	}
+	e, ok := s.convert(event)
+	if !ok {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.batch = append(s.batch, e)
+	full := len(s.batch) >= s.batchSize
+	s.mu.Unlock()
+
+	if !full {
+		return nil
+	}
+	return s.Flush(ctx)
+}
+
+// Flush отправляет накопленные события. При ошибке пачка отбрасывается: аналитика не стоит повторов без конца
+func (s *AnalyticsSink) Flush(ctx context.Context) error {
+	batch := s.take()
+	if len(batch) == 0 || !s.enabled() {
+		return nil
+	}
+	if err := s.send(ctx, batch); err != nil {
+		return fmt.Errorf("send %d analytics events: %w", len(batch), err)
+	}
+	return nil
+}
+
+// Run отправляет накопленные события раз в interval, пока не отменён ctx
+func (s *AnalyticsSink) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			flushCtx, cancel := context.WithTimeout(context.Background(), sinkTimeout)
+			if err := s.Flush(flushCtx); err != nil {
+				slog.Error("Failed to flush analytics events", "provider", s.provider, "error", err)
+			}
+			cancel()
+		}
+	}
+}
+
+func (s *AnalyticsSink) take() []analyticsEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batch := s.batch
+	s.batch = nil
+	return batch
+}
+
+// convert превращает событие шины в событие аналитики. Тестовые оплаты не учитываются
+func (s *AnalyticsSink) convert(event Event) (analyticsEvent, bool) {
+	name, ok := analyticsEventNames[event.Type]
+	if !ok {
+		return analyticsEvent{}, false
+	}
+
+	var telegramID int64
+	properties := map[string]interface{}{}
+	switch data := event.Data.(type) {
+	case MenuView:
+		telegramID = data.TelegramID
+		properties["menu"] = data.Menu
+	case TariffSelection:
+		telegramID = data.TelegramID
+		properties["tariff"] = data.TariffName
+		properties["months"] = data.Months
+		properties["days"] = data.Days
+	case Checkout:
+		telegramID = data.TelegramID
+		properties["amount"] = data.Amount
+		properties["currency"] = data.Currency
+		properties["invoice_type"] = data.InvoiceType
+		properties["tariff"] = data.TariffName
+		properties["months"] = data.Months
+		properties["days"] = data.Days
+	case Payment:
+		if data.IsTest {
+			return analyticsEvent{}, false
+		}
+		telegramID = data.TelegramID
+		properties["amount"] = data.Amount
+		properties["currency"] = data.Currency
+		properties["invoice_type"] = data.InvoiceType
+		properties["months"] = data.Months
+		properties["days"] = data.Days
+		if data.TariffName != nil {
+			properties["tariff"] = *data.TariffName
+		}
+		if data.TeamSeats != nil {
+			properties["team_seats"] = *data.TeamSeats
+		}
+	case Trial:
+		telegramID = data.TelegramID
+		properties["days"] = data.Days
+	default:
+		return analyticsEvent{}, false
+	}
+
+	return analyticsEvent{
+		id:         event.ID,
+		name:       name,
+		distinctID: AnonymousID(s.salt, telegramID),
+		time:       event.OccurredAt,
+		properties: properties,
+	}, true
+}
+
+// AnonymousID обезличенный идентификатор пользователя: hex(HMAC-SHA256(salt, telegramID)).
+// Без соли по нему нельзя восстановить Telegram ID перебором
+func AnonymousID(salt string, telegramID int64) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(strconv.FormatInt(telegramID, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *AnalyticsSink) body(batch []analyticsEvent) ([]byte, error) {
+	if s.provider == AnalyticsAmplitude {
+		type amplitudeEvent struct {
+			UserID          string                 `json:"user_id"`
+			EventType       string                 `json:"event_type"`
+			Time            int64                  `json:"time"`
+			InsertID        string                 `json:"insert_id"`
+			EventProperties map[string]interface{} `json:"event_properties"`
+		}
+		events := make([]amplitudeEvent, 0, len(batch))
+		for _, e := range batch {
+			events = append(events, amplitudeEvent{
+				UserID:          e.distinctID,
+				EventType:       e.name,
+				Time:            e.time.UnixMilli(),
+				InsertID:        e.id,
+				EventProperties: e.properties,
+			})
+		}
+		return json.Marshal(map[string]interface{}{"api_key": s.apiKey, "events": events})
+	}
+
+	type posthogEvent struct {
+		Event      string                 `json:"event"`
+		Properties map[string]interface{} `json:"properties"`
+		Timestamp  time.Time              `json:"timestamp"`
+	}
+	events := make([]posthogEvent, 0, len(batch))
+	for _, e := range batch {
+		properties := make(map[string]interface{}, len(e.properties)+1)
+		for k, v := range e.properties {
+			properties[k] = v
+		}
+		properties["distinct_id"] = e.distinctID
+		events = append(events, posthogEvent{Event: e.name, Properties: properties, Timestamp: e.time})
+	}
+	return json.Marshal(map[string]interface{}{"api_key": s.apiKey, "batch": events})
+}
+
+func (s *AnalyticsSink) send(ctx context.Context, batch []analyticsEvent) error {
+	body, err := s.body(batch)
+	if err != nil {
+		return fmt.Errorf("marshal events: %w", err)
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= analyticsAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+			case <-time.After(s.backoff(attempt - 1)):
+			}
+		}
+
+		retry, err := s.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+	return lastErr
+}
+
+// post отправляет пачку. Возвращает retry=true для ошибок, при которых имеет смысл повторить запрос
+func (s *AnalyticsSink) post(ctx context.Context, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("post events: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("%s responded with status %d", s.provider, resp.StatusCode)
+}
@@ -0,0 +1,216 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// analyticsServer принимает пачки событий и сохраняет тела запросов
+type analyticsServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	bodies [][]byte
+}
+
+func newAnalyticsServer(t *testing.T) *analyticsServer {
+	s := &analyticsServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.bodies = append(s.bodies, body)
+		s.mu.Unlock()
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *analyticsServer) requests() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bodies
+}
+
+func newTestAnalyticsSink(provider, url string, batchSize int, enabled *atomic.Bool) *AnalyticsSink {
+	sink := NewAnalyticsSink(provider, url, "key", "salt-salt-salt-salt", batchSize, enabled.Load)
+	sink.backoff = func(int) time.Duration { return 0 }
+	return sink
+}
+
+func TestAnalyticsSinkBatchesAnonymizedEvents(t *testing.T) {
+	srv := newAnalyticsServer(t)
+	var enabled atomic.Bool
+	enabled.Store(true)
+	sink := newTestAnalyticsSink(AnalyticsPostHog, srv.URL, 2, &enabled)
+	ctx := context.Background()
+
+	if err := sink.Handle(ctx, MenuViewed(MenuView{TelegramID: 123456789, Menu: "main"})); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if len(srv.requests()) != 0 {
+		t.Fatal("Expected event to wait for a full batch")
+	}
+	tariff := "PRO"
+	if err := sink.Handle(ctx, PaymentSucceeded(Payment{PurchaseID: 77, CustomerID: 55, TelegramID: 123456789, Amount: 199, Currency: "RUB", Months: 1, TariffName: &tariff})); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	requests := srv.requests()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 batch, got %d", len(requests))
+	}
+	body := string(requests[0])
+	for _, leaked := range []string{"123456789", "purchase_id", "customer_id", "telegram_id"} {
+		if strings.Contains(body, leaked) {
+			t.Errorf("Batch leaks %q: %s", leaked, body)
+		}
+	}
+
+	var decoded struct {
+		APIKey string `json:"api_key"`
+		Batch  []struct {
+			Event      string                 `json:"event"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"batch"`
+	}
+	if err := json.Unmarshal(requests[0], &decoded); err != nil {
+		t.Fatalf("Body is not JSON: %v", err)
+	}
+	if decoded.APIKey != "key" || len(decoded.Batch) != 2 {
+		t.Fatalf("Unexpected batch: %s", body)
+	}
+	if decoded.Batch[0].Event != "menu_view" || decoded.Batch[1].Event != "payment_succeeded" {
+		t.Errorf("Unexpected event names: %s", body)
+	}
+	wantID := AnonymousID("salt-salt-salt-salt", 123456789)
+	for _, e := range decoded.Batch {
+		if e.Properties["distinct_id"] != wantID {
+			t.Errorf("Expected distinct_id %s, got %v", wantID, e.Properties["distinct_id"])
+		}
+	}
+	if decoded.Batch[1].Properties["tariff"] != "PRO" {
+		t.Errorf("Expected tariff property, got %v", decoded.Batch[1].Properties)
+	}
+}
+
+func TestAnalyticsSinkAmplitudeFormat(t *testing.T) {
+	srv := newAnalyticsServer(t)
+	var enabled atomic.Bool
+	enabled.Store(true)
+	sink := newTestAnalyticsSink(AnalyticsAmplitude, srv.URL, 10, &enabled)
+
+	event := TrialActivated(Trial{CustomerID: 5, TelegramID: 42, Days: 3})
+	if err := sink.Handle(context.Background(), event); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if err := sink.Flush(context.Background()); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	requests := srv.requests()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 batch, got %d", len(requests))
+	}
+	var decoded struct {
+		Events []struct {
+			UserID    string `json:"user_id"`
+			EventType string `json:"event_type"`
+			Time      int64  `json:"time"`
+			InsertID  string `json:"insert_id"`
+		} `json:"events"`
+	}
+	if err := json.Unmarshal(requests[0], &decoded); err != nil {
+		t.Fatalf("Body is not JSON: %v", err)
+	}
+	if len(decoded.Events) != 1 {
+		t.Fatalf("Unexpected body: %s", requests[0])
+	}
+	got := decoded.Events[0]
+	if got.EventType != "trial_activated" || got.UserID != AnonymousID("salt-salt-salt-salt", 42) ||
+		got.InsertID != event.ID || got.Time != event.OccurredAt.UnixMilli() {
+		t.Errorf("Unexpected event: %+v", got)
+	}
+}
+
+func TestAnalyticsSinkSkipsNonProductEvents(t *testing.T) {
+	srv := newAnalyticsServer(t)
+	var enabled atomic.Bool
+	enabled.Store(true)
+	sink := newTestAnalyticsSink(AnalyticsPostHog, srv.URL, 1, &enabled)
+	ctx := context.Background()
+
+	for _, event := range []Event{
+		SyncCompleted(Sync{Users: 10}),
+		PaymentRefunded(Refund{PurchaseID: 1}),
+		PaymentSucceeded(Payment{TelegramID: 1, IsTest: true}),
+	} {
+		if err := sink.Handle(ctx, event); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+	}
+	if n := len(srv.requests()); n != 0 {
+		t.Errorf("Expected no batches for non-product and test events, got %d", n)
+	}
+}
+
+// Аварийное выключение отбрасывает и новые события, и уже накопленную пачку
+func TestAnalyticsSinkKillSwitch(t *testing.T) {
+	srv := newAnalyticsServer(t)
+	var enabled atomic.Bool
+	enabled.Store(true)
+	sink := newTestAnalyticsSink(AnalyticsPostHog, srv.URL, 2, &enabled)
+	ctx := context.Background()
+
+	if err := sink.Handle(ctx, MenuViewed(MenuView{TelegramID: 1, Menu: "main"})); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	enabled.Store(false)
+	for i := 0; i < 3; i++ {
+		if err := sink.Handle(ctx, MenuViewed(MenuView{TelegramID: 1, Menu: "buy"})); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+	}
+	if err := sink.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if n := len(srv.requests()); n != 0 {
+		t.Fatalf("Expected nothing sent while disabled, got %d batches", n)
+	}
+
+	enabled.Store(true)
+	if err := sink.Handle(ctx, MenuViewed(MenuView{TelegramID: 1, Menu: "main"})); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if err := sink.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	requests := srv.requests()
+	if len(requests) != 1 || strings.Count(string(requests[0]), "menu_view") != 1 {
+		t.Errorf("Expected only the event after re-enabling, got %q", requests)
+	}
+}
+
+// Закрытие шины отправляет неполную пачку
+func TestBusCloseFlushesAnalytics(t *testing.T) {
+	srv := newAnalyticsServer(t)
+	var enabled atomic.Bool
+	enabled.Store(true)
+	bus := NewBus(10, newTestAnalyticsSink(AnalyticsPostHog, srv.URL, 50, &enabled))
+	bus.Start()
+
+	bus.Publish(context.Background(), TariffSelected(TariffSelection{TelegramID: 1, TariffName: "PRO", Days: 7}))
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	requests := srv.requests()
+	if len(requests) != 1 || !strings.Contains(string(requests[0]), "tariff_selected") {
+		t.Errorf("Expected pending batch to be sent on close, got %q", requests)
+	}
+}
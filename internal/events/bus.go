@@ -0,0 +1,166 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/alert"
+)
+
+// sinkTimeout ограничивает доставку одного события в один приёмник, включая повторы
+const sinkTimeout = 30 * time.Second
+
+// Sink приёмник событий: внешний webhook, канал в Telegram и т.п.
+type Sink interface {
+	Name() string
+	Handle(ctx context.Context, event Event) error
+}
+
+// Bus доставляет события приёмникам в фоне: публикация не ждёт внешние системы и не замедляет
+// оплату или выдачу подписки. Если очередь переполнена, событие отбрасывается с предупреждением в лог
+type Bus struct {
+	sinks []Sink
+	queue chan Event
+	done  chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewBus создаёт шину с очередью на bufferSize событий
+func NewBus(bufferSize int, sinks ...Sink) *Bus {
+	return &Bus{
+		sinks: sinks,
+		queue: make(chan Event, bufferSize),
+		done:  make(chan struct{}),
+	}
+}
+
+// Start запускает доставку событий
+func (b *Bus) Start() {
+	go func() {
+		defer close(b.done)
+		for event := range b.queue {
+			b.deliver(event)
+		}
+	}()
+}
+
+// Publish ставит событие в очередь. Возвращает false, если очередь заполнена или шина уже закрыта
+func (b *Bus) Publish(ctx context.Context, event Event) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return false
+	}
+	select {
+	case b.queue <- event:
+		return true
+	default:
+		slog.WarnContext(ctx, "Event queue is full, event dropped", "type", event.Type, "id", event.ID)
+		return false
+	}
+}
+
+// Close перестаёт принимать события и ждёт доставки уже поставленных в очередь, но не дольше ctx
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.mu.Unlock()
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return fmt.Errorf("events not delivered: %d left in queue: %w", len(b.queue), ctx.Err())
+	}
+
+	// Приёмники с пачками отправляют накопленное
+	for _, sink := range b.sinks {
+		if f, ok := sink.(flusher); ok {
+			if err := f.Flush(ctx); err != nil {
+				return fmt.Errorf("flush %s: %w", sink.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// flusher приёмник, который копит события и отправляет их пачками
+type flusher interface {
+	Flush(ctx context.Context) error
+}
+
+func (b *Bus) deliver(event Event) {
+	for _, sink := range b.sinks {
+		b.deliverTo(sink, event)
+	}
+}
+
+func (b *Bus) deliverTo(sink Sink, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Panic in event sink", "sink", sink.Name(), "panic", r)
+			alert.NotifyPanic("event sink "+sink.Name(), r)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), sinkTimeout)
+	defer cancel()
+	if err := sink.Handle(ctx, event); err != nil {
+		slog.Error("Failed to deliver event", "sink", sink.Name(), "type", event.Type, "id", event.ID, "error", err)
+	}
+}
+
+// filteredSink пропускает в приёмник только события выбранных типов
+type filteredSink struct {
+	Sink
+	types map[Type]bool
+}
+
+// Filter ограничивает приёмник типами types. Пустой список — все события
+func Filter(sink Sink, types []Type) Sink {
+	if len(types) == 0 {
+		return sink
+	}
+	allowed := make(map[Type]bool, len(types))
+	for _, t := range types {
+		allowed[t] = true
+	}
+	return &filteredSink{Sink: sink, types: allowed}
+}
+
+func (s *filteredSink) Handle(ctx context.Context, event Event) error {
+	if !s.types[event.Type] {
+		return nil
+	}
+	return s.Sink.Handle(ctx, event)
+}
+
+var (
+	defaultMu  sync.RWMutex
+	defaultBus *Bus
+)
+
+// SetDefault устанавливает глобальную шину, используемую функциями пакета
+func SetDefault(b *Bus) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultBus = b
+}
+
+// Publish публикует событие через глобальную шину (no-op если интеграции не настроены)
+func Publish(ctx context.Context, event Event) {
+	defaultMu.RLock()
+	b := defaultBus
+	defaultMu.RUnlock()
+
+	if b != nil {
+		b.Publish(ctx, event)
+	}
+}
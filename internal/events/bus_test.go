@@ -0,0 +1,106 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sinkMock struct {
+	mu     sync.Mutex
+	events []Event
+	err    error
+	panic  bool
+}
+
+func (s *sinkMock) Name() string { return "mock" }
+
+func (s *sinkMock) Handle(ctx context.Context, event Event) error {
+	if s.panic {
+		panic("boom")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return s.err
+}
+
+func (s *sinkMock) received() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event(nil), s.events...)
+}
+
+func TestBusDeliversToAllSinks(t *testing.T) {
+	failing := &sinkMock{err: errors.New("down")}
+	panicking := &sinkMock{panic: true}
+	ok := &sinkMock{}
+	bus := NewBus(10, failing, panicking, ok)
+	bus.Start()
+
+	for i := 0; i < 3; i++ {
+		if !bus.Publish(context.Background(), TrialActivated(Trial{CustomerID: int64(i)})) {
+			t.Fatalf("Publish %d rejected", i)
+		}
+	}
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Ошибка или паника одного приёмника не мешает доставке в остальные
+	if got := len(failing.received()); got != 3 {
+		t.Errorf("failing sink received %d events, want 3", got)
+	}
+	if got := len(ok.received()); got != 3 {
+		t.Errorf("ok sink received %d events, want 3", got)
+	}
+	if bus.Publish(context.Background(), TrialActivated(Trial{})) {
+		t.Error("Publish after Close should be rejected")
+	}
+}
+
+func TestFilterSink(t *testing.T) {
+	webhook := &sinkMock{}
+	logChannel := &sinkMock{}
+	bus := NewBus(10, webhook, Filter(logChannel, []Type{TypePaymentSucceeded}))
+	bus.Start()
+
+	bus.Publish(context.Background(), TrialActivated(Trial{}))
+	bus.Publish(context.Background(), PaymentSucceeded(Payment{PurchaseID: 1}))
+	_ = bus.Close(context.Background())
+
+	// Фильтр одного приёмника не влияет на остальные
+	if got := len(webhook.received()); got != 2 {
+		t.Errorf("unfiltered sink received %d events, want 2", got)
+	}
+	events := logChannel.received()
+	if len(events) != 1 || events[0].Type != TypePaymentSucceeded {
+		t.Fatalf("Unexpected events: %+v", events)
+	}
+}
+
+func TestBusDropsWhenQueueFull(t *testing.T) {
+	bus := NewBus(1, &sinkMock{})
+	// Доставка не запущена: первое событие занимает очередь, второе отбрасывается
+	if !bus.Publish(context.Background(), TrialActivated(Trial{})) {
+		t.Fatal("First event should be queued")
+	}
+	if bus.Publish(context.Background(), TrialActivated(Trial{})) {
+		t.Fatal("Second event should be dropped")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := bus.Close(ctx); err == nil {
+		t.Fatal("Close should time out when events are not delivered")
+	}
+}
+
+func TestEventIDsAreUnique(t *testing.T) {
+	a, b := PaymentSucceeded(Payment{}), PaymentSucceeded(Payment{})
+	if a.ID == "" || a.ID == b.ID {
+		t.Fatalf("Expected unique ids, got %q and %q", a.ID, b.ID)
+	}
+}
@@ -0,0 +1,19 @@
+package events
+
+import (
+	"slices"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+// Типы из EVENTS_TYPES проверяются в config, поэтому списки должны совпадать
+func TestTypesMatchConfig(t *testing.T) {
+	var types []string
+	for _, typ := range Types() {
+		types = append(types, string(typ))
+	}
+	if !slices.Equal(types, config.KnownEventTypes()) {
+		t.Fatalf("events.Types() = %v, config.KnownEventTypes() = %v", types, config.KnownEventTypes())
+	}
+}
@@ -0,0 +1,185 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/clock"
+)
+
+// Type тип события. Значения входят в публичный контракт внешних интеграций, их нельзя переименовывать
+type Type string
+
+const (
+	TypePaymentSucceeded    Type = "payment.succeeded"
+	TypePaymentRefunded     Type = "payment.refunded"
+	TypeRecurringFailed     Type = "payment.recurring_failed"
+	TypeTrialActivated      Type = "trial.activated"
+	TypeSubscriptionExpired Type = "subscription.expired"
+	TypeSyncCompleted       Type = "sync.completed"
+)
+
+// Продуктовые события для аналитики. Не входят в Types(): в webhook и лог-канал не отправляются
+const (
+	TypeMenuViewed     Type = "menu.viewed"
+	TypeTariffSelected Type = "tariff.selected"
+	TypePaymentCreated Type = "payment.created"
+)
+
+// Types возвращает все типы событий
+func Types() []Type {
+	return []Type{
+		TypePaymentSucceeded, TypePaymentRefunded, TypeRecurringFailed,
+		TypeTrialActivated, TypeSubscriptionExpired, TypeSyncCompleted,
+	}
+}
+
+// Event событие для внешних систем. ID уникален для каждого события: по нему получатель отбрасывает повторы
+type Event struct {
+	ID         string      `json:"id"`
+	Type       Type        `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// Payment данные оплаченной покупки
+type Payment struct {
+	PurchaseID  int64      `json:"purchase_id"`
+	CustomerID  int64      `json:"customer_id"`
+	TelegramID  int64      `json:"telegram_id"`
+	Amount      float64    `json:"amount"`
+	Currency    string     `json:"currency"`
+	InvoiceType string     `json:"invoice_type"`
+	Months      int        `json:"months"`
+	Days        int        `json:"days,omitempty"`
+	TariffName  *string    `json:"tariff_name,omitempty"`
+	TeamSeats   *int       `json:"team_seats,omitempty"`
+	ExpireAt    *time.Time `json:"expire_at,omitempty"`
+	IsTest      bool       `json:"is_test"`
+}
+
+// Refund данные возврата по покупке
+type Refund struct {
+	PurchaseID     int64   `json:"purchase_id"`
+	CustomerID     int64   `json:"customer_id"`
+	TelegramID     int64   `json:"telegram_id"`
+	Amount         float64 `json:"amount"`
+	RefundedAmount float64 `json:"refunded_amount"`
+	Currency       string  `json:"currency"`
+	Full           bool    `json:"full"`
+	ClawbackDays   int     `json:"clawback_days"`
+}
+
+// Причины неудачного автопродления, кроме ошибок платёжной системы
+const (
+	RecurringReasonPermissionRevoked   = "permission_revoked"
+	RecurringReasonSpendingCapExceeded = "spending_cap_exceeded"
+)
+
+// RecurringFailure данные неудачного автопродления. Reason — одна из RecurringReason* или текст ошибки
+type RecurringFailure struct {
+	CustomerID  int64  `json:"customer_id"`
+	TelegramID  int64  `json:"telegram_id"`
+	Amount      int    `json:"amount"`
+	FromBalance int    `json:"from_balance"`
+	Months      int    `json:"months"`
+	Reason      string `json:"reason"`
+}
+
+// Trial данные активированного пробного периода
+type Trial struct {
+	CustomerID int64      `json:"customer_id"`
+	TelegramID int64      `json:"telegram_id"`
+	Days       int        `json:"days"`
+	ExpireAt   *time.Time `json:"expire_at,omitempty"`
+}
+
+// Subscription данные закончившейся подписки. CustomerID 0 — пользователь панели без клиента в боте
+type Subscription struct {
+	CustomerID int64      `json:"customer_id,omitempty"`
+	TelegramID int64      `json:"telegram_id"`
+	ExpireAt   *time.Time `json:"expire_at,omitempty"`
+}
+
+// Sync итоги синхронизации клиентов с панелью
+type Sync struct {
+	Users           int     `json:"users"`
+	Created         int     `json:"created"`
+	Updated         int     `json:"updated"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// MenuView показ экрана бота клиенту. Menu — имя экрана, например "main" или "buy"
+type MenuView struct {
+	TelegramID int64  `json:"telegram_id"`
+	Menu       string `json:"menu"`
+}
+
+// TariffSelection выбор срока покупки. TariffName пуст без тарифов
+type TariffSelection struct {
+	TelegramID int64  `json:"telegram_id"`
+	TariffName string `json:"tariff_name,omitempty"`
+	Months     int    `json:"months"`
+	Days       int    `json:"days,omitempty"`
+}
+
+// Checkout созданный счёт на оплату
+type Checkout struct {
+	PurchaseID  int64   `json:"purchase_id"`
+	CustomerID  int64   `json:"customer_id"`
+	TelegramID  int64   `json:"telegram_id"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	InvoiceType string  `json:"invoice_type"`
+	Months      int     `json:"months"`
+	Days        int     `json:"days,omitempty"`
+	TariffName  string  `json:"tariff_name,omitempty"`
+}
+
+func newEvent(t Type, data interface{}) Event {
+	return Event{ID: newID(), Type: t, OccurredAt: clock.Now().UTC(), Data: data}
+}
+
+func PaymentSucceeded(p Payment) Event {
+	return newEvent(TypePaymentSucceeded, p)
+}
+
+func PaymentRefunded(r Refund) Event {
+	return newEvent(TypePaymentRefunded, r)
+}
+
+func RecurringFailed(r RecurringFailure) Event {
+	return newEvent(TypeRecurringFailed, r)
+}
+
+func TrialActivated(t Trial) Event {
+	return newEvent(TypeTrialActivated, t)
+}
+
+func SubscriptionExpired(s Subscription) Event {
+	return newEvent(TypeSubscriptionExpired, s)
+}
+
+func SyncCompleted(s Sync) Event {
+	return newEvent(TypeSyncCompleted, s)
+}
+
+func MenuViewed(m MenuView) Event {
+	return newEvent(TypeMenuViewed, m)
+}
+
+func TariffSelected(t TariffSelection) Event {
+	return newEvent(TypeTariffSelected, t)
+}
+
+func PaymentCreated(c Checkout) Event {
+	return newEvent(TypePaymentCreated, c)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	// crypto/rand не возвращает ошибку на поддерживаемых платформах
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,167 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/utils"
+)
+
+type telegramSender interface {
+	SendMessage(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error)
+}
+
+// TelegramSink пишет карточки событий в лог-канал. Не больше maxPerMinute сообщений в минуту:
+// Telegram ограничивает частоту сообщений в канал, а при всплеске активности журнал становится нечитаемым.
+// Сверх лимита события пропускаются, их количество выводится в первой карточке следующей минуты
+type TelegramSink struct {
+	sender       telegramSender
+	chatID       int64
+	maxPerMinute int
+	now          func() time.Time
+
+	mu          sync.Mutex
+	windowStart time.Time
+	sent        int
+	skipped     int
+}
+
+// NewTelegramSink создаёт приёмник для канала chatID. maxPerMinute 0 — без ограничения частоты
+func NewTelegramSink(sender telegramSender, chatID int64, maxPerMinute int) *TelegramSink {
+	return &TelegramSink{sender: sender, chatID: chatID, maxPerMinute: maxPerMinute, now: time.Now}
+}
+
+func (s *TelegramSink) Name() string {
+	return "telegram"
+}
+
+func (s *TelegramSink) Handle(ctx context.Context, event Event) error {
+	allowed, skipped := s.take()
+	if !allowed {
+		slog.DebugContext(ctx, "Log channel rate limit reached, event skipped", "type", event.Type, "id", event.ID)
+		return nil
+	}
+
+	text := FormatTelegram(event)
+	if skipped > 0 {
+		text = fmt.Sprintf("⏸ <i>Пропущено событий из-за ограничения частоты: %d</i>\n\n", skipped) + text
+	}
+	_, err := s.sender.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    s.chatID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+	})
+	return err
+}
+
+// take учитывает сообщение в лимите текущей минуты. Возвращает, можно ли отправить сообщение,
+// и сколько событий было пропущено с прошлой отправки
+func (s *TelegramSink) take() (allowed bool, skipped int) {
+	if s.maxPerMinute <= 0 {
+		return true, 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Sub(s.windowStart) >= time.Minute {
+		s.windowStart = now
+		s.sent = 0
+	}
+	if s.sent >= s.maxPerMinute {
+		s.skipped++
+		return false, 0
+	}
+	s.sent++
+	skipped, s.skipped = s.skipped, 0
+	return true, skipped
+}
+
+// FormatTelegram форматирует событие для лог-канала
+func FormatTelegram(event Event) string {
+	var sb strings.Builder
+	switch data := event.Data.(type) {
+	case Payment:
+		title := "💳 <b>Оплата</b>"
+		if data.IsTest {
+			title += " (тест)"
+		}
+		sb.WriteString(title + "\n")
+		sb.WriteString(fmt.Sprintf("Покупка #%d, клиент %d (telegram <code>%d</code>)\n", data.PurchaseID, data.CustomerID, data.TelegramID))
+		sb.WriteString(fmt.Sprintf("Сумма: %s, %s\n", formatAmount(data.Amount, data.Currency), utils.EscapeHTML(data.InvoiceType)))
+		period := fmt.Sprintf("%d мес.", data.Months)
+		if data.Days > 0 {
+			period = fmt.Sprintf("%d дн.", data.Days)
+		}
+		if data.TariffName != nil {
+			period += ", тариф " + utils.EscapeHTML(*data.TariffName)
+		}
+		if data.TeamSeats != nil {
+			period += fmt.Sprintf(", команда на %d мест", *data.TeamSeats)
+		}
+		sb.WriteString("Период: " + period)
+		if data.ExpireAt != nil {
+			sb.WriteString("\nПодписка до " + data.ExpireAt.Format("02.01.2006 15:04"))
+		}
+	case Refund:
+		sb.WriteString("↩️ <b>Возврат</b>\n")
+		sb.WriteString(fmt.Sprintf("Покупка #%d, клиент %d (telegram <code>%d</code>)\n", data.PurchaseID, data.CustomerID, data.TelegramID))
+		sb.WriteString(fmt.Sprintf("Возвращено %s из %s, подписка сокращена на %d дн.",
+			formatAmount(data.RefundedAmount, data.Currency), formatAmount(data.Amount, data.Currency), data.ClawbackDays))
+	case RecurringFailure:
+		sb.WriteString("⚠️ <b>Автопродление не прошло</b>\n")
+		sb.WriteString(fmt.Sprintf("Клиент %d (telegram <code>%d</code>)\n", data.CustomerID, data.TelegramID))
+		amount := formatAmount(float64(data.Amount), "RUB")
+		if data.FromBalance > 0 {
+			amount += fmt.Sprintf(", из них с баланса %s", formatAmount(float64(data.FromBalance), "RUB"))
+		}
+		sb.WriteString(fmt.Sprintf("Сумма: %s за %d мес.\n", amount, data.Months))
+		sb.WriteString("Причина: " + recurringReason(data.Reason))
+	case Trial:
+		sb.WriteString("🎁 <b>Пробный период</b>\n")
+		sb.WriteString(fmt.Sprintf("Клиент %d (telegram <code>%d</code>), %d дн.", data.CustomerID, data.TelegramID, data.Days))
+	case Subscription:
+		sb.WriteString("⌛ <b>Подписка закончилась</b>\n")
+		if data.CustomerID != 0 {
+			sb.WriteString(fmt.Sprintf("Клиент %d ", data.CustomerID))
+		}
+		sb.WriteString(fmt.Sprintf("(telegram <code>%d</code>)", data.TelegramID))
+	case Sync:
+		sb.WriteString("🔄 <b>Синхронизация с панелью</b>\n")
+		sb.WriteString(fmt.Sprintf("Пользователей: %d, новых: %d, обновлено: %d\n", data.Users, data.Created, data.Updated))
+		sb.WriteString(fmt.Sprintf("Заняла %.1f с", data.DurationSeconds))
+	default:
+		sb.WriteString(fmt.Sprintf("📌 <b>%s</b>", utils.EscapeHTML(string(event.Type))))
+	}
+	sb.WriteString(fmt.Sprintf("\n\n<i>%s · %s</i>", event.OccurredAt.Format(time.RFC3339), event.ID))
+	return sb.String()
+}
+
+func recurringReason(reason string) string {
+	switch reason {
+	case RecurringReasonPermissionRevoked:
+		return "клиент отозвал разрешение на списания"
+	case RecurringReasonSpendingCapExceeded:
+		return "превышен суточный лимит автосписаний"
+	default:
+		return utils.EscapeHTML(reason)
+	}
+}
+
+func formatAmount(amount float64, currency string) string {
+	switch currency {
+	case "STARS", "XTR":
+		return fmt.Sprintf("%d ⭐", int(amount))
+	case "", "RUB":
+		return fmt.Sprintf("%.2f ₽", amount)
+	default:
+		return fmt.Sprintf("%.2f %s", amount, utils.EscapeHTML(currency))
+	}
+}
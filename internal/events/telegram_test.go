@@ -0,0 +1,114 @@
+package events
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+func TestFormatTelegram(t *testing.T) {
+	tariff := "<Pro>"
+	tests := []struct {
+		name  string
+		event Event
+		want  []string
+	}{
+		{
+			name:  "payment",
+			event: PaymentSucceeded(Payment{PurchaseID: 5, CustomerID: 2, TelegramID: 42, Amount: 299, Currency: "RUB", InvoiceType: "yookasa", Months: 1, TariffName: &tariff}),
+			want:  []string{"💳 <b>Оплата</b>", "Покупка #5", "299.00 ₽, yookasa", "тариф &lt;Pro&gt;"},
+		},
+		{
+			name:  "stars refund",
+			event: PaymentRefunded(Refund{PurchaseID: 5, Amount: 150, RefundedAmount: 150, Currency: "STARS", ClawbackDays: 30}),
+			want:  []string{"↩️ <b>Возврат</b>", "Возвращено 150 ⭐ из 150 ⭐", "30 дн."},
+		},
+		{
+			name:  "recurring failure",
+			event: RecurringFailed(RecurringFailure{CustomerID: 2, TelegramID: 42, Amount: 299, FromBalance: 100, Months: 1, Reason: RecurringReasonSpendingCapExceeded}),
+			want:  []string{"⚠️ <b>Автопродление не прошло</b>", "299.00 ₽, из них с баланса 100.00 ₽ за 1 мес.", "суточный лимит"},
+		},
+		{
+			name:  "recurring failure with error text",
+			event: RecurringFailed(RecurringFailure{Reason: "payment cancelled: <insufficient_funds>"}),
+			want:  []string{"Причина: payment cancelled: &lt;insufficient_funds&gt;"},
+		},
+		{
+			name:  "sync",
+			event: SyncCompleted(Sync{Users: 120, Created: 3, Updated: 117, DurationSeconds: 2.35}),
+			want:  []string{"🔄 <b>Синхронизация с панелью</b>", "Пользователей: 120, новых: 3, обновлено: 117", "2.4 с"},
+		},
+		{
+			name:  "expired without customer",
+			event: SubscriptionExpired(Subscription{TelegramID: 42}),
+			want:  []string{"⌛ <b>Подписка закончилась</b>\n(telegram <code>42</code>)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := FormatTelegram(tt.event)
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("Expected %q in:\n%s", want, text)
+				}
+			}
+			if !strings.Contains(text, tt.event.ID) {
+				t.Errorf("Expected event id in:\n%s", text)
+			}
+		})
+	}
+}
+
+type senderMock struct {
+	texts []string
+}
+
+func (s *senderMock) SendMessage(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+	s.texts = append(s.texts, params.Text)
+	return &models.Message{}, nil
+}
+
+func TestTelegramSinkThrottles(t *testing.T) {
+	sender := &senderMock{}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	sink := NewTelegramSink(sender, -100, 2)
+	sink.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		if err := sink.Handle(context.Background(), TrialActivated(Trial{})); err != nil {
+			t.Fatalf("Handle: %v", err)
+		}
+	}
+	if len(sender.texts) != 2 {
+		t.Fatalf("Sent %d messages within a minute, want 2", len(sender.texts))
+	}
+
+	// В новой минуте лимит сбрасывается, а первая карточка сообщает о пропущенных событиях
+	now = now.Add(time.Minute)
+	if err := sink.Handle(context.Background(), TrialActivated(Trial{})); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if len(sender.texts) != 3 || !strings.Contains(sender.texts[2], "ограничения частоты: 3") {
+		t.Fatalf("Expected skipped counter in the next message, got %q", sender.texts)
+	}
+	_ = sink.Handle(context.Background(), TrialActivated(Trial{}))
+	if strings.Contains(sender.texts[3], "ограничения частоты") {
+		t.Errorf("Skipped counter should be reported once, got %q", sender.texts[3])
+	}
+}
+
+func TestTelegramSinkWithoutLimit(t *testing.T) {
+	sender := &senderMock{}
+	sink := NewTelegramSink(sender, -100, 0)
+	for i := 0; i < 50; i++ {
+		_ = sink.Handle(context.Background(), TrialActivated(Trial{}))
+	}
+	if len(sender.texts) != 50 {
+		t.Fatalf("Sent %d messages, want 50", len(sender.texts))
+	}
+}
@@ -0,0 +1,118 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Заголовки исходящего webhook
+const (
+	HeaderEventID   = "X-Event-Id"
+	HeaderEventType = "X-Event-Type"
+	HeaderTimestamp = "X-Event-Timestamp"
+	HeaderSignature = "X-Event-Signature"
+)
+
+// webhookAttempts количество попыток доставки. Повторяются сетевые ошибки, 429 и 5xx
+const webhookAttempts = 3
+
+// WebhookSink отправляет события POST-запросом с JSON телом события.
+// Подпись: hex(HMAC-SHA256(secret, "<timestamp>.<body>")) в заголовке X-Event-Signature,
+// timestamp (unix-время в секундах) — в X-Event-Timestamp, чтобы получатель мог отбрасывать старые запросы
+type WebhookSink struct {
+	url    string
+	secret string
+	client *http.Client
+	now    func() time.Time
+	// backoff пауза перед повторной попыткой attempt (начиная с 1)
+	backoff func(attempt int) time.Duration
+}
+
+func NewWebhookSink(url, secret string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		secret: secret,
+		client: &http.Client{Timeout: timeout},
+		now:    time.Now,
+		backoff: func(attempt int) time.Duration {
+			return time.Duration(attempt*attempt) * time.Second
+		},
+	}
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Handle(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+			case <-time.After(s.backoff(attempt - 1)):
+			}
+		}
+
+		retry, err := s.post(ctx, event, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+	return lastErr
+}
+
+// post отправляет событие. Возвращает retry=true для ошибок, при которых имеет смысл повторить запрос
+func (s *WebhookSink) post(ctx context.Context, event Event, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("build request: %w", err)
+	}
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventID, event.ID)
+	req.Header.Set(HeaderEventType, string(event.Type))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(s.secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("post event: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+}
+
+// Sign возвращает подпись тела webhook для проверки на стороне получателя
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestWebhookSink(url string) *WebhookSink {
+	s := NewWebhookSink(url, "secret", time.Second)
+	s.now = func() time.Time { return time.Unix(1700000000, 0) }
+	s.backoff = func(int) time.Duration { return 0 }
+	return s
+}
+
+func TestWebhookSinkSignsRequest(t *testing.T) {
+	var got struct {
+		header http.Header
+		body   []byte
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.header = r.Header.Clone()
+		got.body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	event := PaymentSucceeded(Payment{PurchaseID: 7, Amount: 199, Currency: "RUB"})
+	if err := newTestWebhookSink(srv.URL).Handle(context.Background(), event); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	if got.header.Get(HeaderEventID) != event.ID || got.header.Get(HeaderEventType) != "payment.succeeded" {
+		t.Errorf("Unexpected event headers: %v", got.header)
+	}
+	if ts := got.header.Get(HeaderTimestamp); ts != "1700000000" {
+		t.Errorf("Timestamp = %q", ts)
+	}
+	if sig := got.header.Get(HeaderSignature); sig != Sign("secret", "1700000000", got.body) {
+		t.Errorf("Signature %q does not match body", sig)
+	}
+
+	var decoded struct {
+		Type Type `json:"type"`
+		Data struct {
+			PurchaseID int64 `json:"purchase_id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(got.body, &decoded); err != nil {
+		t.Fatalf("Body is not JSON: %v", err)
+	}
+	if decoded.Type != TypePaymentSucceeded || decoded.Data.PurchaseID != 7 {
+		t.Errorf("Unexpected body: %s", got.body)
+	}
+}
+
+func TestWebhookSinkRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		wantCalls int32
+	}{
+		{"server error is retried", http.StatusBadGateway, webhookAttempts},
+		{"rate limit is retried", http.StatusTooManyRequests, webhookAttempts},
+		{"client error is not retried", http.StatusBadRequest, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&calls, 1)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			if err := newTestWebhookSink(srv.URL).Handle(context.Background(), TrialActivated(Trial{})); err == nil {
+				t.Fatal("Expected error")
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
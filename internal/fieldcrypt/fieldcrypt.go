@@ -0,0 +1,176 @@
+// Package fieldcrypt шифрует отдельные колонки БД (ссылки подписки, способы оплаты) с помощью AES-GCM.
+// Значение хранится как "enc:v1:<id ключа>:<base64(nonce+шифртекст)>", поэтому при смене ключа старые
+// значения расшифровываются прежним ключом, пока задача field_encryption не перешифрует их текущим.
+// Значения без префикса считаются открытым текстом (записаны до включения шифрования)
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Prefix начало всех зашифрованных значений
+const Prefix = "enc:v1:"
+
+const keySize = 32
+
+var keyIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,16}$`)
+
+var ErrUnknownKey = errors.New("encryption key not configured")
+
+// Keyring набор ключей. Новые значения шифруются текущим (первым) ключом, расшифровываются любым из набора
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// ParseKeys разбирает список ключей "id:base64,id2:base64" (32 байта каждый). Первый ключ — текущий,
+// остальные нужны только для чтения значений, ещё не перешифрованных после ротации
+func ParseKeys(spec string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(part, ":")
+		if !ok || !keyIDRegex.MatchString(id) {
+			return nil, fmt.Errorf("key must be <id>:<base64>, id of up to 16 letters, digits, _ or -")
+		}
+		if _, exists := k.keys[id]; exists {
+			return nil, fmt.Errorf("duplicate key id %q", id)
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not valid base64: %w", id, err)
+		}
+		if len(raw) != keySize {
+			return nil, fmt.Errorf("key %q must be %d bytes, got %d", id, keySize, len(raw))
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		if k.current == "" {
+			k.current = id
+		}
+		k.keys[id] = aead
+	}
+	if k.current == "" {
+		return nil, errors.New("no keys")
+	}
+	return k, nil
+}
+
+// CurrentPrefix префикс значений, зашифрованных текущим ключом
+func (k *Keyring) CurrentPrefix() string {
+	return Prefix + k.current + ":"
+}
+
+// Encrypt шифрует значение текущим ключом
+func (k *Keyring) Encrypt(plain string) (string, error) {
+	aead := k.keys[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plain), nil)
+	return k.CurrentPrefix() + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt расшифровывает значение любым ключом из набора. Открытый текст возвращается как есть
+func (k *Keyring) Decrypt(value string) (string, error) {
+	rest, ok := strings.CutPrefix(value, Prefix)
+	if !ok {
+		return value, nil
+	}
+	id, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", errors.New("malformed encrypted value")
+	}
+	if k == nil {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	aead, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt with key %q: %w", id, err)
+	}
+	return string(plain), nil
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultKeyring *Keyring
+)
+
+// SetDefault задаёт ключи, которыми пользуются функции пакета. nil — шифрование выключено
+func SetDefault(k *Keyring) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultKeyring = k
+}
+
+// Default ключи пакета, nil если шифрование выключено
+func Default() *Keyring {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultKeyring
+}
+
+// Encrypt шифрует значение ключами пакета. Без ключей значение сохраняется открытым текстом
+func Encrypt(plain string) (string, error) {
+	k := Default()
+	if k == nil {
+		return plain, nil
+	}
+	return k.Encrypt(plain)
+}
+
+// Decrypt расшифровывает значение ключами пакета
+func Decrypt(value string) (string, error) {
+	return Default().Decrypt(value)
+}
+
+// EncryptPtr Encrypt для nullable колонок
+func EncryptPtr(plain *string) (*string, error) {
+	if plain == nil {
+		return nil, nil
+	}
+	value, err := Encrypt(*plain)
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+// DecryptInPlace расшифровывает nullable значение, прочитанное из БД
+func DecryptInPlace(value *string) error {
+	if value == nil {
+		return nil
+	}
+	plain, err := Decrypt(*value)
+	if err != nil {
+		return err
+	}
+	*value = plain
+	return nil
+}
@@ -0,0 +1,113 @@
+package fieldcrypt
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(rune(b)), keySize)))
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	k, err := ParseKeys("k1:" + testKey('a'))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := k.Encrypt("https://sub.example.com/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encrypted, "enc:v1:k1:") || strings.Contains(encrypted, "example") {
+		t.Fatalf("Unexpected encrypted value %q", encrypted)
+	}
+	again, _ := k.Encrypt("https://sub.example.com/abc")
+	if again == encrypted {
+		t.Error("Each encryption must use a fresh nonce")
+	}
+
+	plain, err := k.Decrypt(encrypted)
+	if err != nil || plain != "https://sub.example.com/abc" {
+		t.Fatalf("Decrypt = %q, %v", plain, err)
+	}
+}
+
+func TestDecryptPlaintextPassesThrough(t *testing.T) {
+	k, _ := ParseKeys("k1:" + testKey('a'))
+	for _, keyring := range []*Keyring{k, nil} {
+		plain, err := keyring.Decrypt("2d1f1c3e-0000-4000-8000-000000000000")
+		if err != nil || plain != "2d1f1c3e-0000-4000-8000-000000000000" {
+			t.Errorf("Decrypt of legacy plaintext = %q, %v", plain, err)
+		}
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old, _ := ParseKeys("old:" + testKey('a'))
+	encrypted, _ := old.Encrypt("secret")
+
+	rotated, err := ParseKeys("new:" + testKey('b') + ",old:" + testKey('a'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := rotated.Decrypt(encrypted)
+	if err != nil || plain != "secret" {
+		t.Fatalf("Old value must still decrypt after rotation, got %q, %v", plain, err)
+	}
+	if strings.HasPrefix(encrypted, rotated.CurrentPrefix()) {
+		t.Error("Value encrypted with the old key must not look current")
+	}
+	reencrypted, _ := rotated.Encrypt(plain)
+	if !strings.HasPrefix(reencrypted, "enc:v1:new:") {
+		t.Errorf("New values must use the first key, got %q", reencrypted)
+	}
+
+	withoutOld, _ := ParseKeys("new:" + testKey('b'))
+	if _, err := withoutOld.Decrypt(encrypted); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestDecryptRejectsTamperedValue(t *testing.T) {
+	k, _ := ParseKeys("k1:" + testKey('a'))
+	encrypted, _ := k.Encrypt("secret")
+	tampered := encrypted[:len(encrypted)-2] + "AA"
+	if tampered == encrypted {
+		tampered = encrypted[:len(encrypted)-2] + "BB"
+	}
+	if _, err := k.Decrypt(tampered); err == nil {
+		t.Error("Tampered value must not decrypt")
+	}
+}
+
+func TestParseKeysErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"nokey",
+		"bad id:" + testKey('a'),
+		"k1:not-base64!",
+		"k1:" + base64.StdEncoding.EncodeToString([]byte("short")),
+		"k1:" + testKey('a') + ",k1:" + testKey('b'),
+	} {
+		if _, err := ParseKeys(spec); err == nil {
+			t.Errorf("ParseKeys(%q) expected error", spec)
+		}
+	}
+}
+
+func TestPackageFunctionsWithoutKeys(t *testing.T) {
+	SetDefault(nil)
+	value, err := Encrypt("plain")
+	if err != nil || value != "plain" {
+		t.Errorf("Without keys values must be stored as is, got %q, %v", value, err)
+	}
+
+	k, _ := ParseKeys("k1:" + testKey('a'))
+	encrypted, _ := k.Encrypt("secret")
+	if err := DecryptInPlace(&encrypted); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Encrypted value without keys must fail, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
@@ -13,9 +14,10 @@ import (
 
 	"remnawave-tg-shop-bot/internal/broadcast"
 	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/utils"
 )
 
-func (h Handler) AdminCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h AdminHandlers) AdminCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.Message.From.ID != config.GetAdminTelegramId() {
 		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID: update.Message.Chat.ID,
@@ -38,6 +40,45 @@ func (h Handler) AdminCommandHandler(ctx context.Context, b *bot.Bot, update *mo
 			{
 				{Text: "🧪 Тест уведомлений", CallbackData: "admin_test_notifications"},
 			},
+			{
+				{Text: "🕒 Задачи", CallbackData: "admin_jobs"},
+			},
+			{
+				{Text: "🚩 Фичи", CallbackData: "admin_features"},
+			},
+			{
+				{Text: "📣 Winback", CallbackData: "admin_winback"},
+			},
+			{
+				{Text: "🛒 Неоплаченные счета", CallbackData: "admin_checkout_reminders"},
+			},
+			{
+				{Text: "⭐ Опросы", CallbackData: "admin_survey"},
+			},
+			{
+				{Text: "📈 Качество уведомлений", CallbackData: "admin_notification_feedback"},
+			},
+			{
+				{Text: "⚠️ Ошибки", CallbackData: "admin_fulfillment_errors"},
+			},
+			{
+				{Text: "💬 Команды", CallbackData: "admin_commands"},
+			},
+			{
+				{Text: "❓ FAQ", CallbackData: adminFaqListPrefix},
+			},
+			{
+				{Text: "🔁 Автопродление", CallbackData: "admin_recurring"},
+			},
+			{
+				{Text: "👥 Дубли в панели", CallbackData: "admin_duplicates"},
+			},
+			{
+				{Text: "🖥 Статус серверов", CallbackData: "admin_server_status"},
+			},
+			{
+				{Text: "🗑 Удалённые клиенты", CallbackData: adminDeletedCustomers},
+			},
 			{
 				{Text: "❌ Закрыть", CallbackData: "admin_close"},
 			},
@@ -46,16 +87,34 @@ func (h Handler) AdminCommandHandler(ctx context.Context, b *bot.Bot, update *mo
 
 	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID:      update.Message.Chat.ID,
-		Text:        "🔧 <b>Панель администратора</b>\n\nВыберите действие:",
+		Text:        "🔧 <b>Панель администратора</b>\n\n" + h.quickStatsHeader(ctx) + "Выберите действие:",
 		ParseMode:   models.ParseModeHTML,
 		ReplyMarkup: keyboard,
 	})
 	if err != nil {
-		slog.Error("Error sending admin menu", "error", err)
+		slog.ErrorContext(ctx, "Error sending admin menu", "error", err)
 	}
 }
 
-func (h Handler) AdminBroadcastCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+// quickStatsTimeout сколько /admin ждёт сводку: при медленной базе панель открывается без неё
+const quickStatsTimeout = 3 * time.Second
+
+// quickStatsHeader сводка за сегодня с пустой строкой после неё или пустая строка, если сводку не удалось посчитать
+func (h AdminHandlers) quickStatsHeader(ctx context.Context) string {
+	if h.quickStats == nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, quickStatsTimeout)
+	defer cancel()
+	header, err := h.quickStats.Header(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Error collecting admin quick stats", "error", err)
+		return ""
+	}
+	return header + "\n\n"
+}
+
+func (h AdminHandlers) AdminBroadcastCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 			CallbackQueryID: update.CallbackQuery.ID,
@@ -88,10 +147,18 @@ func (h Handler) AdminBroadcastCallback(ctx context.Context, b *bot.Bot, update
 				{Text: "👋 Только нажали /start", CallbackData: "broadcast_target_start_only"},
 			},
 			{
-				{Text: "🔙 Назад", CallbackData: "admin_back"},
+				{Text: "🏷 По тегу", CallbackData: "admin_broadcast_tags"},
 			},
 		},
 	}
+	if config.IsRemnawaveTagSyncEnabled() {
+		keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []models.InlineKeyboardButton{
+			{Text: "🏷 По тегу панели", CallbackData: "admin_broadcast_rwtags"},
+		})
+	}
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, []models.InlineKeyboardButton{
+		{Text: "🔙 Назад", CallbackData: "admin_back"},
+	})
 
 	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
 		ChatID:      update.CallbackQuery.Message.Message.Chat.ID,
@@ -101,7 +168,7 @@ func (h Handler) AdminBroadcastCallback(ctx context.Context, b *bot.Bot, update
 		ReplyMarkup: keyboard,
 	})
 	if err != nil {
-		slog.Error("Error editing message", "error", err)
+		slog.ErrorContext(ctx, "Error editing message", "error", err)
 	}
 
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
@@ -109,7 +176,85 @@ func (h Handler) AdminBroadcastCallback(ctx context.Context, b *bot.Bot, update
 	})
 }
 
-func (h Handler) AdminBroadcastTargetCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+// AdminBroadcastTagsCallback показывает теги поддержки для выбора аудитории рассылки по тегу
+func (h AdminHandlers) AdminBroadcastTagsCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	tags, err := h.customerNotes.ListTags(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing tags for broadcast", "error", err)
+		return
+	}
+
+	text := "🏷 <b>Рассылка по тегу</b>\n\nВыберите тег:"
+	if len(tags) == 0 {
+		text = "🏷 <b>Рассылка по тегу</b>\n\nТегов пока нет. Добавить тег пользователю: <code>/tag &lt;telegram_id&gt; &lt;тег&gt;</code>"
+	}
+
+	var keyboard [][]models.InlineKeyboardButton
+	for _, t := range tags {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: fmt.Sprintf("#%s (%d)", t.Tag, t.Count), CallbackData: SafeCallbackData("broadcast_target_" + broadcast.TargetTagPrefix + t.Tag)},
+		})
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: "🔙 Назад", CallbackData: "admin_broadcast"},
+	})
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      update.CallbackQuery.Message.Message.Chat.ID,
+		MessageID:   update.CallbackQuery.Message.Message.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error editing message", "error", err)
+	}
+}
+
+// AdminBroadcastPanelTagsCallback показывает теги панели, выставленные синхронизацией тегов, для выбора аудитории рассылки
+func (h AdminHandlers) AdminBroadcastPanelTagsCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	tags, err := h.customerRepository.ListRemnawaveTags(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing remnawave tags for broadcast", "error", err)
+		return
+	}
+
+	text := "🏷 <b>Рассылка по тегу панели</b>\n\nВыберите тег:"
+	if len(tags) == 0 {
+		text = "🏷 <b>Рассылка по тегу панели</b>\n\nТегов пока нет: они появляются у клиентов после оплаты или триала при включённом REMNAWAVE_TAG_SYNC_ENABLED"
+	}
+
+	var keyboard [][]models.InlineKeyboardButton
+	for _, t := range tags {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: fmt.Sprintf("%s (%d)", t.Tag, t.Count), CallbackData: "broadcast_target_" + broadcast.TargetRemnawaveTagPrefix + t.Tag},
+		})
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: "🔙 Назад", CallbackData: "admin_broadcast"},
+	})
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      update.CallbackQuery.Message.Message.Chat.ID,
+		MessageID:   update.CallbackQuery.Message.Message.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error editing message", "error", err)
+	}
+}
+
+func (h AdminHandlers) AdminBroadcastTargetCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 			CallbackQueryID: update.CallbackQuery.ID,
@@ -127,6 +272,7 @@ func (h Handler) AdminBroadcastTargetCallback(ctx context.Context, b *bot.Bot, u
 	h.cache.Delete(fmt.Sprintf("broadcast_media_type_%d", userID))
 	h.cache.Delete(fmt.Sprintf("broadcast_text_%d", userID))
 	h.cache.Delete(fmt.Sprintf("broadcast_buttons_%d", userID))
+	h.clearBroadcastExclusions(userID)
 
 	// Сохраняем выбор в кеш для следующего шага
 	key := fmt.Sprintf("broadcast_target_%d", userID)
@@ -149,14 +295,16 @@ func (h Handler) AdminBroadcastTargetCallback(ctx context.Context, b *bot.Bot, u
 			"📝 <b>Введите текст сообщения</b>\n\n"+
 				"Целевая аудитория: %s\n\n"+
 				"Отправьте текст, фото, GIF, видео или кружок для рассылки.\n"+
-				"Поддерживается HTML разметка.",
+				"Поддерживается HTML разметка.\n\n"+
+				"<b>Переменные</b> подставляются для каждого получателя:\n%s",
 			targetName,
+			templateVariablesHelp(),
 		),
 		ParseMode:   models.ParseModeHTML,
 		ReplyMarkup: keyboard,
 	})
 	if err != nil {
-		slog.Error("Error editing message", "error", err)
+		slog.ErrorContext(ctx, "Error editing message", "error", err)
 	}
 
 	// Сохраняем состояние ожидания сообщения
@@ -168,7 +316,7 @@ func (h Handler) AdminBroadcastTargetCallback(ctx context.Context, b *bot.Bot, u
 	})
 }
 
-func (h Handler) AdminBroadcastMessageHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h AdminHandlers) AdminBroadcastMessageHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.Message.From.ID != config.GetAdminTelegramId() {
 		return
 	}
@@ -223,6 +371,12 @@ func (h Handler) AdminBroadcastMessageHandler(ctx context.Context, b *bot.Bot, u
 		return
 	}
 
+	// Сломанная разметка не дойдёт ни до одного получателя: ошибку показываем сразу, состояние ввода сохраняется
+	if err := broadcast.ValidateTemplate(messageText); err != nil {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, formatTemplateError(err)+"\n\nИсправьте текст и отправьте его ещё раз.")
+		return
+	}
+
 	// Сохраняем данные в кеш
 	h.cache.SetString(fmt.Sprintf("broadcast_text_%d", userID), messageText, 600)
 	if mediaFileID != "" {
@@ -286,7 +440,7 @@ func getMediaInfo(mediaType string) string {
 	}
 }
 
-func (h Handler) AdminBroadcastButtonCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h AdminHandlers) AdminBroadcastButtonCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 			CallbackQueryID: update.CallbackQuery.ID,
@@ -382,7 +536,7 @@ func (h Handler) AdminBroadcastButtonCallback(ctx context.Context, b *bot.Bot, u
 	})
 }
 
-func (h Handler) buildBroadcastButtonsKeyboard(selected []string) *models.InlineKeyboardMarkup {
+func (h AdminHandlers) buildBroadcastButtonsKeyboard(selected []string) *models.InlineKeyboardMarkup {
 	isSelected := func(name string) bool {
 		for _, s := range selected {
 			if s == name {
@@ -426,7 +580,7 @@ func (h Handler) buildBroadcastButtonsKeyboard(selected []string) *models.Inline
 	}
 }
 
-func (h Handler) showBroadcastConfirmation(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h AdminHandlers) showBroadcastConfirmation(ctx context.Context, b *bot.Bot, update *models.Update) {
 	userID := update.CallbackQuery.From.ID
 
 	targetKey := fmt.Sprintf("broadcast_target_%d", userID)
@@ -442,6 +596,27 @@ func (h Handler) showBroadcastConfirmation(ctx context.Context, b *bot.Bot, upda
 
 	textKey := fmt.Sprintf("broadcast_text_%d", userID)
 	messageText, _ := h.cache.GetString(textKey)
+	chatID := update.CallbackQuery.Message.Message.Chat.ID
+
+	// Предпросмотр отправляется до создания рассылки: админ видит сообщение глазами получателя,
+	// а текст, который Telegram не примет, не попадёт в историю
+	_, _ = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    chatID,
+		MessageID: update.CallbackQuery.Message.Message.ID,
+		Text:      "👁 <b>Предпросмотр рассылки</b>\n\nТак сообщение увидят получатели. Вместо переменных подставлены примеры значений.",
+		ParseMode: models.ParseModeHTML,
+	})
+	vars := broadcast.SampleTemplateVars(update.CallbackQuery.From.FirstName)
+	if err := h.broadcastService.SendPreview(ctx, chatID, messageText, vars, h.broadcastOptions(userID)); err != nil {
+		slog.ErrorContext(ctx, "Failed to send broadcast preview", "error", err)
+		h.cache.SetString(fmt.Sprintf("broadcast_state_%d", userID), "waiting_message", 600)
+		h.sendAdminText(ctx, b, chatID, "❌ <b>Telegram не принял сообщение</b>\n\n"+utils.EscapeHTML(err.Error())+
+			"\n\nИсправьте текст и отправьте его ещё раз.")
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+		})
+		return
+	}
 
 	// Создаем запись в истории рассылок
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
@@ -449,7 +624,7 @@ func (h Handler) showBroadcastConfirmation(ctx context.Context, b *bot.Bot, upda
 
 	broadcastID, err := h.broadcastService.CreateBroadcast(ctxWithTimeout, targetType, messageText)
 	if err != nil {
-		slog.Error("Failed to create broadcast", "error", err)
+		slog.ErrorContext(ctx, "Failed to create broadcast", "error", err)
 		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 			CallbackQueryID: update.CallbackQuery.ID,
 			Text:            "Ошибка создания рассылки",
@@ -461,12 +636,35 @@ func (h Handler) showBroadcastConfirmation(ctx context.Context, b *bot.Bot, upda
 	// Сохраняем ID рассылки
 	h.cache.SetString(fmt.Sprintf("broadcast_id_%d", userID), fmt.Sprintf("%d", broadcastID), 600)
 
+	h.sendBroadcastConfirmation(ctx, b, chatID, userID, broadcastID, targetType)
+
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+}
+
+// sendBroadcastConfirmation отправляет карточку подтверждения рассылки. Подтверждение отправляется
+// новым сообщением, чтобы кнопки были под предпросмотром
+func (h AdminHandlers) sendBroadcastConfirmation(ctx context.Context, b *bot.Bot, chatID, userID, broadcastID int64, targetType string) {
+	text, keyboard := h.broadcastConfirmation(ctx, userID, broadcastID, targetType)
+	_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: keyboard,
+	})
+}
+
+// broadcastConfirmation карточка подтверждения: аудитория с учётом исключений, медиа, кнопки
+// и переключатели исключений
+func (h AdminHandlers) broadcastConfirmation(ctx context.Context, userID, broadcastID int64, targetType string) (string, *models.InlineKeyboardMarkup) {
 	targetName := getTargetName(targetType)
+	exclusions := h.broadcastExclusions(userID)
 
 	// Получаем количество получателей
-	recipientsCount, err := h.broadcastService.GetTargetCustomersCount(ctx, targetType)
+	recipientsCount, excludedCount, err := h.broadcastService.CountRecipients(ctx, targetType, exclusions)
 	if err != nil {
-		slog.Error("Failed to get recipients count", "error", err)
+		slog.ErrorContext(ctx, "Failed to get recipients count", "error", err)
 		recipientsCount = 0
 	}
 
@@ -481,42 +679,126 @@ func (h Handler) showBroadcastConfirmation(ctx context.Context, b *bot.Bot, upda
 		buttonsInfo = "\n🔘 Кнопки: " + buttons
 	}
 
-	keyboard := &models.InlineKeyboardMarkup{
-		InlineKeyboard: [][]models.InlineKeyboardButton{
-			{
-				{Text: fmt.Sprintf("✅ Отправить %d получателям", recipientsCount), CallbackData: fmt.Sprintf("broadcast_confirm_%d", broadcastID)},
-			},
-			{
-				{Text: "❌ Отменить", CallbackData: "admin_broadcast"},
-			},
+	excludedInfo := ""
+	if excludedCount > 0 {
+		excludedInfo = fmt.Sprintf("\n🚫 Исключено: %d", excludedCount)
+	}
+
+	rows := [][]models.InlineKeyboardButton{
+		{
+			{Text: fmt.Sprintf("✅ Отправить %d получателям", recipientsCount), CallbackData: fmt.Sprintf("broadcast_confirm_%d", broadcastID)},
+		},
+		{
+			{Text: "👁 Тест себе", CallbackData: fmt.Sprintf("broadcast_test_%d", broadcastID)},
 		},
 	}
+	rows = append(rows, exclusionButtons(broadcastID, exclusions)...)
+	rows = append(rows, []models.InlineKeyboardButton{
+		{Text: "❌ Отменить", CallbackData: "admin_broadcast"},
+	})
 
-	_, _ = b.EditMessageText(ctx, &bot.EditMessageTextParams{
-		ChatID:    update.CallbackQuery.Message.Message.Chat.ID,
+	text := fmt.Sprintf(
+		"📋 <b>Подтверждение рассылки</b>\n\n"+
+			"Целевая аудитория: %s\n"+
+			"👥 <b>Получателей: %d</b>%s%s%s\n\n"+
+			"Проверьте предпросмотр выше и подтвердите отправку рассылки.",
+		targetName,
+		recipientsCount,
+		excludedInfo,
+		mediaInfo,
+		buttonsInfo,
+	)
+	return text, &models.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
+// AdminBroadcastTestCallback отправляет админу рассылку в том виде, в каком её получат клиенты: с медиа,
+// кнопками и его собственными значениями переменных. Карточка подтверждения переносится под тестовое сообщение
+func (h AdminHandlers) AdminBroadcastTestCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	userID := update.CallbackQuery.From.ID
+	broadcastID, err := strconv.ParseInt(strings.TrimPrefix(update.CallbackQuery.Data, "broadcast_test_"), 10, 64)
+	if err != nil {
+		slog.ErrorContext(ctx, "Invalid broadcast ID", "error", err)
+		return
+	}
+
+	item, err := h.broadcastService.GetBroadcast(ctx, broadcastID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get broadcast", "error", err)
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+			Text:            "Рассылка не найдена",
+			ShowAlert:       true,
+		})
+		return
+	}
+
+	chatID := update.CallbackQuery.Message.Message.Chat.ID
+	err = h.broadcastService.SendTest(ctx, broadcastID, chatID, item.MessageText, update.CallbackQuery.From.FirstName, h.broadcastOptions(userID))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to send broadcast test", "error", err, "id", broadcastID)
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+			Text:            "Telegram не принял сообщение: " + err.Error(),
+			ShowAlert:       true,
+		})
+		return
+	}
+
+	_, _ = b.DeleteMessage(ctx, &bot.DeleteMessageParams{
+		ChatID:    chatID,
 		MessageID: update.CallbackQuery.Message.Message.ID,
-		Text: fmt.Sprintf(
-			"📋 <b>Подтверждение рассылки</b>\n\n"+
-				"Целевая аудитория: %s\n"+
-				"👥 <b>Получателей: %d</b>%s%s\n\n"+
-				"<b>Текст сообщения:</b>\n%s\n\n"+
-				"Подтвердите отправку рассылки.",
-			targetName,
-			recipientsCount,
-			mediaInfo,
-			buttonsInfo,
-			messageText,
-		),
-		ParseMode:   models.ParseModeHTML,
-		ReplyMarkup: keyboard,
 	})
+	h.sendBroadcastConfirmation(ctx, b, chatID, userID, broadcastID, item.TargetType)
 
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            "Тестовое сообщение отправлено",
 	})
 }
 
-func (h Handler) AdminBroadcastConfirmCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+// broadcastOptions собирает медиа и кнопки рассылки, выбранные админом
+func (h AdminHandlers) broadcastOptions(userID int64) *broadcast.BroadcastOptions {
+	mediaFileID, _ := h.cache.GetString(fmt.Sprintf("broadcast_media_%d", userID))
+	mediaType, _ := h.cache.GetString(fmt.Sprintf("broadcast_media_type_%d", userID))
+	buttonsStr, _ := h.cache.GetString(fmt.Sprintf("broadcast_buttons_%d", userID))
+	var buttons []string
+	if buttonsStr != "" {
+		for _, btn := range strings.Split(buttonsStr, ",") {
+			if btn != "" {
+				buttons = append(buttons, btn)
+			}
+		}
+	}
+	return &broadcast.BroadcastOptions{
+		MediaType:   mediaType,
+		MediaFileID: mediaFileID,
+		Buttons:     buttons,
+		MiniAppURL:  config.GetMiniAppURL(),
+		Exclusions:  h.broadcastExclusions(userID),
+	}
+}
+
+// templateVariablesHelp список переменных шаблона рассылки с описаниями
+func templateVariablesHelp() string {
+	var sb strings.Builder
+	for _, v := range broadcast.TemplateVariables {
+		sb.WriteString(fmt.Sprintf("<code>{%s}</code> — %s\n", v.Name, v.Description))
+	}
+	sb.WriteString("Если данных нет, подставляется запасное значение: «друг», «—» или 0.")
+	return sb.String()
+}
+
+// formatTemplateError сообщение админу об ошибке в шаблоне с указанием места ошибки
+func formatTemplateError(err error) string {
+	var tmplErr *broadcast.TemplateError
+	if !errors.As(err, &tmplErr) {
+		return "❌ Ошибка в шаблоне: " + utils.EscapeHTML(err.Error())
+	}
+	return fmt.Sprintf("❌ <b>Ошибка в шаблоне</b> (строка %d, символ %d)\n%s\n\n<code>%s</code>",
+		tmplErr.Line, tmplErr.Column, utils.EscapeHTML(tmplErr.Message), utils.EscapeHTML(tmplErr.Snippet))
+}
+
+func (h AdminHandlers) AdminBroadcastConfirmCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 			CallbackQueryID: update.CallbackQuery.ID,
@@ -531,7 +813,7 @@ func (h Handler) AdminBroadcastConfirmCallback(ctx context.Context, b *bot.Bot,
 	broadcastIDStr := strings.TrimPrefix(update.CallbackQuery.Data, "broadcast_confirm_")
 	broadcastID, err := strconv.ParseInt(broadcastIDStr, 10, 64)
 	if err != nil {
-		slog.Error("Invalid broadcast ID", "error", err)
+		slog.ErrorContext(ctx, "Invalid broadcast ID", "error", err)
 		return
 	}
 
@@ -541,35 +823,12 @@ func (h Handler) AdminBroadcastConfirmCallback(ctx context.Context, b *bot.Bot,
 
 	broadcastData, err := h.broadcastService.GetBroadcast(ctxWithTimeout, broadcastID)
 	if err != nil {
-		slog.Error("Failed to get broadcast", "error", err)
+		slog.ErrorContext(ctx, "Failed to get broadcast", "error", err)
 		return
 	}
 
-	// Получаем опции из кеша
-	mediaKey := fmt.Sprintf("broadcast_media_%d", userID)
-	mediaFileID, _ := h.cache.GetString(mediaKey)
-
-	mediaTypeKey := fmt.Sprintf("broadcast_media_type_%d", userID)
-	mediaType, _ := h.cache.GetString(mediaTypeKey)
-
-	buttonsKey := fmt.Sprintf("broadcast_buttons_%d", userID)
-	buttonsStr, _ := h.cache.GetString(buttonsKey)
-	var buttons []string
-	if buttonsStr != "" {
-		for _, btn := range strings.Split(buttonsStr, ",") {
-			if btn != "" {
-				buttons = append(buttons, btn)
-			}
-		}
-	}
-
-	// Запускаем рассылку с опциями
-	opts := &broadcast.BroadcastOptions{
-		MediaType:   mediaType,
-		MediaFileID: mediaFileID,
-		Buttons:     buttons,
-		MiniAppURL:  config.GetMiniAppURL(),
-	}
+	// Запускаем рассылку с опциями из кеша
+	opts := h.broadcastOptions(userID)
 	h.broadcastService.StartBroadcastWithOptions(ctx, broadcastID, broadcastData.TargetType, broadcastData.MessageText, opts)
 
 	// Очищаем кеш
@@ -580,6 +839,7 @@ func (h Handler) AdminBroadcastConfirmCallback(ctx context.Context, b *bot.Bot,
 	h.cache.Delete(fmt.Sprintf("broadcast_buttons_%d", userID))
 	h.cache.Delete(fmt.Sprintf("broadcast_id_%d", userID))
 	h.cache.Delete(fmt.Sprintf("broadcast_state_%d", userID))
+	h.clearBroadcastExclusions(userID)
 
 	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
 		ChatID:    update.CallbackQuery.Message.Message.Chat.ID,
@@ -595,7 +855,7 @@ func (h Handler) AdminBroadcastConfirmCallback(ctx context.Context, b *bot.Bot,
 	})
 
 	if err != nil {
-		slog.Error("Error editing message", "error", err)
+		slog.ErrorContext(ctx, "Error editing message", "error", err)
 	}
 
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
@@ -604,7 +864,11 @@ func (h Handler) AdminBroadcastConfirmCallback(ctx context.Context, b *bot.Bot,
 	})
 }
 
-func (h Handler) AdminBroadcastHistoryCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+// broadcastHistoryPageSize количество рассылок на странице истории
+const broadcastHistoryPageSize = 10
+
+// AdminBroadcastHistoryCallback показывает историю рассылок постранично
+func (h AdminHandlers) AdminBroadcastHistoryCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 			CallbackQueryID: update.CallbackQuery.ID,
@@ -618,11 +882,16 @@ func (h Handler) AdminBroadcastHistoryCallback(ctx context.Context, b *bot.Bot,
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	history, err := h.broadcastService.GetBroadcastHistory(ctxWithTimeout, 10, 0)
+	page := listPage(update.CallbackQuery.Data, "admin_broadcast_history")
+	history, err := h.broadcastService.GetBroadcastHistory(ctxWithTimeout, broadcastHistoryPageSize+1, page*broadcastHistoryPageSize)
 	if err != nil {
-		slog.Error("Failed to get broadcast history", "error", err)
+		slog.ErrorContext(ctx, "Failed to get broadcast history", "error", err)
 		return
 	}
+	hasNext := len(history) > broadcastHistoryPageSize
+	if hasNext {
+		history = history[:broadcastHistoryPageSize]
+	}
 
 	text := "📊 <b>История рассылок</b>\n\nНажмите на рассылку для просмотра деталей:"
 
@@ -648,6 +917,9 @@ func (h Handler) AdminBroadcastHistoryCallback(ctx context.Context, b *bot.Bot,
 		}
 	}
 
+	if nav := pageNavigationRow("admin_broadcast_history", page, hasNext); nav != nil {
+		rows = append(rows, nav)
+	}
 	rows = append(rows, []models.InlineKeyboardButton{
 		{Text: "🔙 Назад", CallbackData: "admin_back"},
 	})
@@ -663,7 +935,7 @@ func (h Handler) AdminBroadcastHistoryCallback(ctx context.Context, b *bot.Bot,
 	})
 
 	if err != nil {
-		slog.Error("Error editing message", "error", err)
+		slog.ErrorContext(ctx, "Error editing message", "error", err)
 	}
 
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
@@ -672,7 +944,7 @@ func (h Handler) AdminBroadcastHistoryCallback(ctx context.Context, b *bot.Bot,
 }
 
 // AdminBroadcastViewCallback показывает детали рассылки
-func (h Handler) AdminBroadcastViewCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h AdminHandlers) AdminBroadcastViewCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 			CallbackQueryID: update.CallbackQuery.ID,
@@ -685,7 +957,7 @@ func (h Handler) AdminBroadcastViewCallback(ctx context.Context, b *bot.Bot, upd
 	broadcastIDStr := strings.TrimPrefix(update.CallbackQuery.Data, "broadcast_view_")
 	broadcastID, err := strconv.ParseInt(broadcastIDStr, 10, 64)
 	if err != nil {
-		slog.Error("Invalid broadcast ID", "error", err)
+		slog.ErrorContext(ctx, "Invalid broadcast ID", "error", err)
 		return
 	}
 
@@ -694,7 +966,7 @@ func (h Handler) AdminBroadcastViewCallback(ctx context.Context, b *bot.Bot, upd
 
 	item, err := h.broadcastService.GetBroadcast(ctxWithTimeout, broadcastID)
 	if err != nil {
-		slog.Error("Failed to get broadcast", "error", err)
+		slog.ErrorContext(ctx, "Failed to get broadcast", "error", err)
 		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 			CallbackQueryID: update.CallbackQuery.ID,
 			Text:            "Рассылка не найдена",
@@ -709,13 +981,8 @@ func (h Handler) AdminBroadcastViewCallback(ctx context.Context, b *bot.Bot, upd
 		completedAt = item.CompletedAt.Format("02.01.2006 15:04")
 	}
 
-	// Sanitize and truncate text
-	msgPreview := strings.ToValidUTF8(item.MessageText, "")
-	msgPreview = escapeHTML(msgPreview)
-	runes := []rune(msgPreview)
-	if len(runes) > 200 {
-		msgPreview = string(runes[:200]) + "..."
-	}
+	// Длинный текст рассылки не обрезается: editLongMessage разобьёт карточку на несколько сообщений
+	msgPreview := utils.EscapeHTML(strings.ToValidUTF8(item.MessageText, ""))
 
 	text := fmt.Sprintf(
 		"<b>Рассылка #%d</b>\n\n"+
@@ -723,6 +990,8 @@ func (h Handler) AdminBroadcastViewCallback(ctx context.Context, b *bot.Bot, upd
 			"Аудитория: %s\n"+
 			"Отправлено: %d/%d\n"+
 			"Ошибок: %d\n"+
+			"Исключено: %d\n"+
+			"Тестовых отправок: %d\n"+
 			"Создана: %s\n"+
 			"Завершена: %s\n\n"+
 			"<b>Текст:</b>\n%s",
@@ -733,6 +1002,8 @@ func (h Handler) AdminBroadcastViewCallback(ctx context.Context, b *bot.Bot, upd
 		item.SentCount,
 		item.TotalCount,
 		item.FailedCount,
+		item.ExcludedCount,
+		item.TestSentCount,
 		item.CreatedAt.Format("02.01.2006 15:04"),
 		completedAt,
 		msgPreview,
@@ -749,7 +1020,7 @@ func (h Handler) AdminBroadcastViewCallback(ctx context.Context, b *bot.Bot, upd
 		},
 	}
 
-	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+	err = editLongMessage(ctx, b, &bot.EditMessageTextParams{
 		ChatID:      update.CallbackQuery.Message.Message.Chat.ID,
 		MessageID:   update.CallbackQuery.Message.Message.ID,
 		Text:        text,
@@ -758,7 +1029,7 @@ func (h Handler) AdminBroadcastViewCallback(ctx context.Context, b *bot.Bot, upd
 	})
 
 	if err != nil {
-		slog.Error("Error editing message", "error", err)
+		slog.ErrorContext(ctx, "Error editing message", "error", err)
 	}
 
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
@@ -767,7 +1038,7 @@ func (h Handler) AdminBroadcastViewCallback(ctx context.Context, b *bot.Bot, upd
 }
 
 // AdminBroadcastDeleteCallback удаляет рассылку из истории
-func (h Handler) AdminBroadcastDeleteCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h AdminHandlers) AdminBroadcastDeleteCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 			CallbackQueryID: update.CallbackQuery.ID,
@@ -780,7 +1051,7 @@ func (h Handler) AdminBroadcastDeleteCallback(ctx context.Context, b *bot.Bot, u
 	broadcastIDStr := strings.TrimPrefix(update.CallbackQuery.Data, "broadcast_delete_")
 	broadcastID, err := strconv.ParseInt(broadcastIDStr, 10, 64)
 	if err != nil {
-		slog.Error("Invalid broadcast ID", "error", err)
+		slog.ErrorContext(ctx, "Invalid broadcast ID", "error", err)
 		return
 	}
 
@@ -789,7 +1060,7 @@ func (h Handler) AdminBroadcastDeleteCallback(ctx context.Context, b *bot.Bot, u
 
 	err = h.broadcastService.DeleteBroadcast(ctxWithTimeout, broadcastID)
 	if err != nil {
-		slog.Error("Failed to delete broadcast", "error", err)
+		slog.ErrorContext(ctx, "Failed to delete broadcast", "error", err)
 		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 			CallbackQueryID: update.CallbackQuery.ID,
 			Text:            "Ошибка удаления",
@@ -807,7 +1078,7 @@ func (h Handler) AdminBroadcastDeleteCallback(ctx context.Context, b *bot.Bot, u
 	h.AdminBroadcastHistoryCallback(ctx, b, update)
 }
 
-func (h Handler) AdminBackCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h AdminHandlers) AdminBackCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	// Сразу отвечаем на callback чтобы убрать "часики"
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
@@ -818,6 +1089,8 @@ func (h Handler) AdminBackCallback(ctx context.Context, b *bot.Bot, update *mode
 	h.cache.Delete(fmt.Sprintf("broadcast_state_%d", userID))
 	h.cache.Delete(fmt.Sprintf("broadcast_target_%d", userID))
 	h.cache.Delete(fmt.Sprintf("admin_promo_state_%d", userID))
+	h.cache.Delete(fmt.Sprintf("admin_command_state_%d", userID))
+	h.cache.Delete(fmt.Sprintf("admin_faq_state_%d", userID))
 	h.cache.Delete(fmt.Sprintf("promo_state_%d", userID))
 
 	// Удаляем старое сообщение
@@ -835,7 +1108,7 @@ func (h Handler) AdminBackCallback(ctx context.Context, b *bot.Bot, update *mode
 	})
 }
 
-func (h Handler) AdminCloseCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h AdminHandlers) AdminCloseCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	// Сразу отвечаем на callback
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
@@ -846,6 +1119,8 @@ func (h Handler) AdminCloseCallback(ctx context.Context, b *bot.Bot, update *mod
 	h.cache.Delete(fmt.Sprintf("broadcast_state_%d", userID))
 	h.cache.Delete(fmt.Sprintf("broadcast_target_%d", userID))
 	h.cache.Delete(fmt.Sprintf("admin_promo_state_%d", userID))
+	h.cache.Delete(fmt.Sprintf("admin_command_state_%d", userID))
+	h.cache.Delete(fmt.Sprintf("admin_faq_state_%d", userID))
 	h.cache.Delete(fmt.Sprintf("promo_state_%d", userID))
 
 	_, _ = b.DeleteMessage(ctx, &bot.DeleteMessageParams{
@@ -855,7 +1130,7 @@ func (h Handler) AdminCloseCallback(ctx context.Context, b *bot.Bot, update *mod
 }
 
 // AdminTextInputHandler - объединённый обработчик текстового ввода для админа
-func (h Handler) AdminTextInputHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h AdminHandlers) AdminTextInputHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.Message == nil || update.Message.From.ID != config.GetAdminTelegramId() {
 		return
 	}
@@ -865,14 +1140,35 @@ func (h Handler) AdminTextInputHandler(ctx context.Context, b *bot.Bot, update *
 	// Проверяем состояние создания промокода (админ)
 	promoStateKey := fmt.Sprintf("admin_promo_state_%d", userID)
 	if state, found := h.cache.GetString(promoStateKey); found && state == "waiting_code" {
-		h.AdminPromoCreateInputHandler(ctx, b, update)
+		h.promoInput.AdminPromoCreateInputHandler(ctx, b, update)
 		return
 	}
 
 	// Проверяем состояние создания промокода на тариф (админ)
 	promoTariffStateKey := fmt.Sprintf("admin_promo_tariff_state_%d", userID)
 	if state, found := h.cache.GetString(promoTariffStateKey); found && state == "waiting_code" {
-		h.AdminPromoTariffCreateInputHandler(ctx, b, update)
+		h.promoInput.AdminPromoTariffCreateInputHandler(ctx, b, update)
+		return
+	}
+
+	// Проверяем состояние ввода предложения сегменту (админ)
+	promoOfferStateKey := fmt.Sprintf("admin_promo_offer_state_%d", userID)
+	if state, found := h.cache.GetString(promoOfferStateKey); found && state == "waiting_offer" {
+		h.promoInput.AdminPromoOfferInputHandler(ctx, b, update)
+		return
+	}
+
+	// Проверяем состояние добавления команды
+	commandStateKey := fmt.Sprintf("admin_command_state_%d", userID)
+	if state, found := h.cache.GetString(commandStateKey); found && state == "waiting_command" {
+		h.AdminCustomCommandInputHandler(ctx, b, update)
+		return
+	}
+
+	// Проверяем состояние добавления вопроса FAQ
+	faqStateKey := fmt.Sprintf("admin_faq_state_%d", userID)
+	if state, found := h.cache.GetString(faqStateKey); found && state == "waiting_entry" {
+		h.faqInput.AdminFaqCreateInputHandler(ctx, b, update)
 		return
 	}
 
@@ -886,7 +1182,19 @@ func (h Handler) AdminTextInputHandler(ctx context.Context, b *bot.Bot, update *
 	// Проверяем состояние ввода промокода (как пользователь)
 	userPromoStateKey := fmt.Sprintf("promo_state_%d", userID)
 	if state, found := h.cache.GetString(userPromoStateKey); found && state == "waiting_code" {
-		h.PromoCodeInputHandler(ctx, b, update)
+		h.promoInput.PromoCodeInputHandler(ctx, b, update)
+		return
+	}
+
+	// Проверяем состояние ввода email для чеков (как пользователь)
+	if state, found := h.cache.GetString(receiptEmailStateKey(userID)); found && state == "waiting_email" {
+		h.emailInput.ReceiptEmailInputHandler(ctx, b, update)
+		return
+	}
+
+	// Проверяем состояние поиска по FAQ (как пользователь)
+	if state, found := h.cache.GetString(fmt.Sprintf("faq_search_%d", userID)); found && state == "waiting_query" {
+		h.faqInput.FaqSearchInputHandler(ctx, b, update)
 		return
 	}
 }
@@ -894,6 +1202,12 @@ func (h Handler) AdminTextInputHandler(ctx context.Context, b *bot.Bot, update *
 // Helper functions
 
 func getTargetName(targetType string) string {
+	if tag, ok := strings.CutPrefix(targetType, broadcast.TargetTagPrefix); ok {
+		return "С тегом #" + tag
+	}
+	if tag, ok := strings.CutPrefix(targetType, broadcast.TargetRemnawaveTagPrefix); ok {
+		return "С тегом панели " + tag
+	}
 	switch targetType {
 	case "all":
 		return "Все пользователи"
@@ -920,6 +1234,8 @@ func getStatusEmoji(status string) string {
 		return "✅"
 	case "failed":
 		return "❌"
+	case "interrupted":
+		return "⏸"
 	case "pending":
 		return "🕐"
 	default:
@@ -928,6 +1244,12 @@ func getStatusEmoji(status string) string {
 }
 
 func getTargetShortName(targetType string) string {
+	if tag, ok := strings.CutPrefix(targetType, broadcast.TargetTagPrefix); ok {
+		return "#" + tag
+	}
+	if tag, ok := strings.CutPrefix(targetType, broadcast.TargetRemnawaveTagPrefix); ok {
+		return tag
+	}
 	switch targetType {
 	case "all":
 		return "Все"
@@ -944,10 +1266,3 @@ func getTargetShortName(targetType string) string {
 	}
 }
 
-// escapeHTML экранирует HTML символы для безопасного отображения в Telegram
-func escapeHTML(s string) string {
-	s = strings.ReplaceAll(s, "&", "&amp;")
-	s = strings.ReplaceAll(s, "<", "&lt;")
-	s = strings.ReplaceAll(s, ">", "&gt;")
-	return s
-}
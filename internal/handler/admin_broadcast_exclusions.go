@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/broadcast"
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+// Исключения получателей рассылки выбираются в карточке подтверждения и хранятся в кеше до запуска
+const (
+	broadcastRecentDaysKey   = "broadcast_recent_days_%d"
+	broadcastExcludeTagsKey  = "broadcast_exclude_tags_%d"
+	broadcastIgnoreCapKey    = "broadcast_ignore_cap_%d"
+	broadcastExclusionsTTL   = 600
+	callbackBroadcastRecent  = "broadcast_recent_"
+	callbackBroadcastCap     = "broadcast_cap_"
+	callbackBroadcastTags    = "broadcast_exclude_tags_"
+	callbackBroadcastTag     = "broadcast_exclude_tag_"
+	callbackBroadcastConfirm = "broadcast_exclusions_"
+)
+
+// broadcastExclusions исключения, выбранные админом для текущей рассылки
+func (h AdminHandlers) broadcastExclusions(userID int64) broadcast.Exclusions {
+	var e broadcast.Exclusions
+	if v, ok := h.cache.GetString(fmt.Sprintf(broadcastRecentDaysKey, userID)); ok {
+		e.RecentDays, _ = strconv.Atoi(v)
+	}
+	if v, ok := h.cache.GetString(fmt.Sprintf(broadcastExcludeTagsKey, userID)); ok && v != "" {
+		e.Tags = strings.Split(v, ",")
+	}
+	_, e.IgnoreFrequencyCap = h.cache.GetString(fmt.Sprintf(broadcastIgnoreCapKey, userID))
+	return e
+}
+
+func (h AdminHandlers) clearBroadcastExclusions(userID int64) {
+	h.cache.Delete(fmt.Sprintf(broadcastRecentDaysKey, userID))
+	h.cache.Delete(fmt.Sprintf(broadcastExcludeTagsKey, userID))
+	h.cache.Delete(fmt.Sprintf(broadcastIgnoreCapKey, userID))
+}
+
+// exclusionButtons переключатели исключений в карточке подтверждения
+func exclusionButtons(broadcastID int64, e broadcast.Exclusions) [][]models.InlineKeyboardButton {
+	recent := "не исключать"
+	if e.RecentDays > 0 {
+		recent = fmt.Sprintf("за %d дн.", e.RecentDays)
+	}
+	tags := "нет"
+	if len(e.Tags) > 0 {
+		tags = "#" + strings.Join(e.Tags, ", #")
+	}
+
+	rows := [][]models.InlineKeyboardButton{
+		{{Text: "🕒 Получившим рассылку: " + recent, CallbackData: fmt.Sprintf("%s%d", callbackBroadcastRecent, broadcastID)}},
+		{{Text: "🏷 Исключить теги: " + tags, CallbackData: fmt.Sprintf("%s%d", callbackBroadcastTags, broadcastID)}},
+	}
+	if frequencyCap := config.BroadcastFrequencyCap(); frequencyCap > 0 {
+		label := fmt.Sprintf("⏱ Лимит частоты: %d за %d дн.", frequencyCap, config.BroadcastFrequencyWindowDays())
+		if e.IgnoreFrequencyCap {
+			label = "⚠️ Лимит частоты отключён"
+		}
+		rows = append(rows, []models.InlineKeyboardButton{
+			{Text: label, CallbackData: fmt.Sprintf("%s%d", callbackBroadcastCap, broadcastID)},
+		})
+	}
+	return rows
+}
+
+// nextRecentDays следующий вариант паузы после предыдущей рассылки
+func nextRecentDays(current int) int {
+	i := slices.Index(broadcast.RecentDaysOptions, current)
+	return broadcast.RecentDaysOptions[(i+1)%len(broadcast.RecentDaysOptions)]
+}
+
+// AdminBroadcastRecentCallback переключает исключение клиентов, недавно получивших любую рассылку
+func (h AdminHandlers) AdminBroadcastRecentCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	userID := update.CallbackQuery.From.ID
+	days := nextRecentDays(h.broadcastExclusions(userID).RecentDays)
+	if days == 0 {
+		h.cache.Delete(fmt.Sprintf(broadcastRecentDaysKey, userID))
+	} else {
+		h.cache.SetString(fmt.Sprintf(broadcastRecentDaysKey, userID), strconv.Itoa(days), broadcastExclusionsTTL)
+	}
+	h.editBroadcastConfirmation(ctx, b, update, callbackBroadcastRecent)
+}
+
+// AdminBroadcastCapCallback включает и отключает лимит частоты для срочной рассылки
+func (h AdminHandlers) AdminBroadcastCapCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	userID := update.CallbackQuery.From.ID
+	key := fmt.Sprintf(broadcastIgnoreCapKey, userID)
+	if h.broadcastExclusions(userID).IgnoreFrequencyCap {
+		h.cache.Delete(key)
+	} else {
+		h.cache.SetString(key, "1", broadcastExclusionsTTL)
+	}
+	h.editBroadcastConfirmation(ctx, b, update, callbackBroadcastCap)
+}
+
+// AdminBroadcastExclusionsCallback возвращает к карточке подтверждения из выбора тегов
+func (h AdminHandlers) AdminBroadcastExclusionsCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	h.editBroadcastConfirmation(ctx, b, update, callbackBroadcastConfirm)
+}
+
+// AdminBroadcastExcludeTagsCallback показывает теги поддержки, клиентов с которыми можно исключить из рассылки
+func (h AdminHandlers) AdminBroadcastExcludeTagsCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	broadcastID, err := strconv.ParseInt(strings.TrimPrefix(update.CallbackQuery.Data, callbackBroadcastTags), 10, 64)
+	if err != nil {
+		slog.ErrorContext(ctx, "Invalid broadcast ID", "error", err)
+		return
+	}
+	h.showExcludeTags(ctx, b, update, broadcastID)
+}
+
+// AdminBroadcastExcludeTagCallback добавляет тег в исключения рассылки или убирает его оттуда
+func (h AdminHandlers) AdminBroadcastExcludeTagCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	idStr, tag, found := strings.Cut(strings.TrimPrefix(update.CallbackQuery.Data, callbackBroadcastTag), ":")
+	broadcastID, err := strconv.ParseInt(idStr, 10, 64)
+	if !found || err != nil || tag == "" {
+		slog.ErrorContext(ctx, "Invalid broadcast exclude tag callback", "data", update.CallbackQuery.Data)
+		return
+	}
+
+	userID := update.CallbackQuery.From.ID
+	tags := h.broadcastExclusions(userID).Tags
+	if i := slices.Index(tags, tag); i >= 0 {
+		tags = slices.Delete(tags, i, i+1)
+	} else {
+		tags = append(tags, tag)
+	}
+	key := fmt.Sprintf(broadcastExcludeTagsKey, userID)
+	if len(tags) == 0 {
+		h.cache.Delete(key)
+	} else {
+		h.cache.SetString(key, strings.Join(tags, ","), broadcastExclusionsTTL)
+	}
+	h.showExcludeTags(ctx, b, update, broadcastID)
+}
+
+func (h AdminHandlers) showExcludeTags(ctx context.Context, b *bot.Bot, update *models.Update, broadcastID int64) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	tags, err := h.customerNotes.ListTags(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing tags for broadcast exclusions", "error", err)
+		return
+	}
+
+	text := "🏷 <b>Исключить теги</b>\n\nКлиенты с отмеченными тегами не получат рассылку."
+	if len(tags) == 0 {
+		text = "🏷 <b>Исключить теги</b>\n\nТегов пока нет. Добавить тег пользователю: <code>/tag &lt;telegram_id&gt; &lt;тег&gt;</code>"
+	}
+
+	excluded := h.broadcastExclusions(update.CallbackQuery.From.ID).Tags
+	var keyboard [][]models.InlineKeyboardButton
+	for _, t := range tags {
+		mark := "⬜️"
+		if slices.Contains(excluded, t.Tag) {
+			mark = "✅"
+		}
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: fmt.Sprintf("%s #%s (%d)", mark, t.Tag, t.Count), CallbackData: SafeCallbackData(fmt.Sprintf("%s%d:%s", callbackBroadcastTag, broadcastID, t.Tag))},
+		})
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: "✅ Готово", CallbackData: fmt.Sprintf("%s%d", callbackBroadcastConfirm, broadcastID)},
+	})
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      update.CallbackQuery.Message.Message.Chat.ID,
+		MessageID:   update.CallbackQuery.Message.Message.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error editing message", "error", err)
+	}
+}
+
+// editBroadcastConfirmation перерисовывает карточку подтверждения после изменения исключений
+func (h AdminHandlers) editBroadcastConfirmation(ctx context.Context, b *bot.Bot, update *models.Update, prefix string) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	broadcastID, err := strconv.ParseInt(strings.TrimPrefix(update.CallbackQuery.Data, prefix), 10, 64)
+	if err != nil {
+		slog.ErrorContext(ctx, "Invalid broadcast ID", "error", err)
+		return
+	}
+	item, err := h.broadcastService.GetBroadcast(ctx, broadcastID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get broadcast", "error", err)
+		return
+	}
+
+	text, keyboard := h.broadcastConfirmation(ctx, update.CallbackQuery.From.ID, broadcastID, item.TargetType)
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      update.CallbackQuery.Message.Message.Chat.ID,
+		MessageID:   update.CallbackQuery.Message.Message.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: keyboard,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error editing message", "error", err)
+	}
+}
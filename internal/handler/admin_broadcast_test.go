@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/broadcast"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+// mockBroadcastService реализует BroadcastService: записывает тестовые отправки
+type mockBroadcastService struct {
+	BroadcastService
+	testErr    error
+	testSends  []int64
+	opts       *broadcast.BroadcastOptions
+	exclusions broadcast.Exclusions
+}
+
+func (m *mockBroadcastService) GetBroadcast(ctx context.Context, id int64) (*database.BroadcastHistory, error) {
+	return &database.BroadcastHistory{ID: id, TargetType: "all", MessageText: "Привет, {name}!"}, nil
+}
+
+func (m *mockBroadcastService) CountRecipients(ctx context.Context, targetType string, exclusions broadcast.Exclusions) (int, int, error) {
+	m.exclusions = exclusions
+	if exclusions.RecentDays > 0 {
+		return 1000, 200, nil
+	}
+	return 1200, 0, nil
+}
+
+func (m *mockBroadcastService) SendTest(ctx context.Context, broadcastID, chatID int64, messageText, firstName string, opts *broadcast.BroadcastOptions) error {
+	m.testSends = append(m.testSends, broadcastID)
+	m.opts = opts
+	return m.testErr
+}
+
+// mockTagList реализует customerNoteStore: список тегов поддержки
+type mockTagList struct {
+	customerNoteStore
+	tags []database.TagCount
+}
+
+func (m *mockTagList) ListTags(ctx context.Context) ([]database.TagCount, error) {
+	return m.tags, nil
+}
+
+func broadcastTestUpdate() *models.Update {
+	return &models.Update{CallbackQuery: &models.CallbackQuery{
+		ID:   "cb",
+		Data: "broadcast_test_15",
+		From: models.User{ID: 42, FirstName: "Админ"},
+		Message: models.MaybeInaccessibleMessage{
+			Message: &models.Message{ID: 99, Chat: models.Chat{ID: 42}},
+		},
+	}}
+}
+
+func TestAdminBroadcastTestCallback(t *testing.T) {
+	t.Run("sends test and moves confirmation below it", func(t *testing.T) {
+		b, tg := newTestBot(t)
+		cache := newFakeCache()
+		cache.SetString("broadcast_buttons_42", "buy", 600)
+		service := &mockBroadcastService{}
+		h := NewAdminHandlers(fakeTranslator{}, cache, nil, nil, service, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+		h.AdminBroadcastTestCallback(context.Background(), b, broadcastTestUpdate())
+
+		if len(service.testSends) != 1 || service.testSends[0] != 15 {
+			t.Fatalf("Expected test send of broadcast 15, got %v", service.testSends)
+		}
+		if service.opts == nil || len(service.opts.Buttons) != 1 || service.opts.Buttons[0] != "buy" {
+			t.Errorf("Expected composed buttons in test send, got %+v", service.opts)
+		}
+		if deleted := tg.called("deleteMessage"); len(deleted) != 1 || deleted[0].params["message_id"] != "99" {
+			t.Errorf("Expected old confirmation to be deleted, got %+v", deleted)
+		}
+		sent := tg.called("sendMessage")
+		if len(sent) != 1 || !strings.Contains(sent[0].params["reply_markup"], "broadcast_confirm_15") ||
+			!strings.Contains(sent[0].params["reply_markup"], "broadcast_test_15") {
+			t.Fatalf("Expected new confirmation with send and test buttons, got %+v", sent)
+		}
+	})
+
+	t.Run("telegram rejects message", func(t *testing.T) {
+		b, tg := newTestBot(t)
+		service := &mockBroadcastService{testErr: errors.New("Bad Request: can't parse entities")}
+		h := NewAdminHandlers(fakeTranslator{}, newFakeCache(), nil, nil, service, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+		h.AdminBroadcastTestCallback(context.Background(), b, broadcastTestUpdate())
+
+		if len(tg.called("deleteMessage")) != 0 || len(tg.called("sendMessage")) != 0 {
+			t.Error("Expected confirmation to stay in place")
+		}
+		answers := tg.called("answerCallbackQuery")
+		if len(answers) != 1 || !strings.Contains(answers[0].params["text"], "can't parse entities") {
+			t.Errorf("Expected error alert, got %+v", answers)
+		}
+	})
+}
+
+func TestAdminBroadcastExclusions(t *testing.T) {
+	update := func(data string) *models.Update {
+		u := broadcastTestUpdate()
+		u.CallbackQuery.Data = data
+		return u
+	}
+	b, tg := newTestBot(t)
+	cache := newFakeCache()
+	service := &mockBroadcastService{}
+	notes := &mockTagList{tags: []database.TagCount{{Tag: "vip", Count: 3}, {Tag: "refund", Count: 1}}}
+	h := NewAdminHandlers(fakeTranslator{}, cache, nil, nil, service, notes, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	h.AdminBroadcastRecentCallback(context.Background(), b, update("broadcast_recent_15"))
+	if service.exclusions.RecentDays != 1 {
+		t.Fatalf("Expected 1 day pause after first toggle, got %+v", service.exclusions)
+	}
+	edited := tg.called("editMessageText")
+	if len(edited) != 1 || !strings.Contains(edited[0].params["text"], "Исключено: 200") ||
+		!strings.Contains(edited[0].params["reply_markup"], "Отправить 1000 получателям") {
+		t.Fatalf("Expected confirmation with exclusions, got %+v", edited)
+	}
+
+	h.AdminBroadcastExcludeTagCallback(context.Background(), b, update("broadcast_exclude_tag_15:vip"))
+	h.AdminBroadcastExcludeTagCallback(context.Background(), b, update("broadcast_exclude_tag_15:refund"))
+	h.AdminBroadcastExcludeTagCallback(context.Background(), b, update("broadcast_exclude_tag_15:vip"))
+
+	edited = tg.called("editMessageText")
+	if markup := edited[len(edited)-1].params["reply_markup"]; !strings.Contains(markup, "✅ #refund") || !strings.Contains(markup, "⬜️ #vip") {
+		t.Errorf("Expected refund to be marked as excluded, got %s", markup)
+	}
+
+	opts := h.broadcastOptions(42)
+	if opts.Exclusions.RecentDays != 1 || len(opts.Exclusions.Tags) != 1 || opts.Exclusions.Tags[0] != "refund" {
+		t.Errorf("Unexpected exclusions in broadcast options: %+v", opts.Exclusions)
+	}
+
+	for range broadcast.RecentDaysOptions[1:] {
+		h.AdminBroadcastRecentCallback(context.Background(), b, update("broadcast_recent_15"))
+	}
+	if days := h.broadcastExclusions(42).RecentDays; days != 0 {
+		t.Errorf("Expected pause to cycle back to off, got %d", days)
+	}
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+// checkoutReminderStatsPeriods периоды статистики напоминаний в днях
+var checkoutReminderStatsPeriods = []int{7, 30}
+
+// AdminCheckoutRemindersCallback показывает, сколько напоминаний о неоплаченных счетах отправлено
+// и сколько из них закончилось оплатой
+func (h AdminHandlers) AdminCheckoutRemindersCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	msg := update.CallbackQuery.Message.Message
+
+	stats := make([]database.CheckoutReminderStats, 0, len(checkoutReminderStatsPeriods))
+	for _, days := range checkoutReminderStatsPeriods {
+		st, err := h.checkoutReminderRepository.Stats(ctx, clock.Now().AddDate(0, 0, -days))
+		if err != nil {
+			slog.ErrorContext(ctx, "Error loading checkout reminder stats", "error", err)
+			return
+		}
+		stats = append(stats, st)
+	}
+
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		Text:      formatCheckoutReminderStats(config.IsCheckoutReminderEnabled(), config.CheckoutReminderDelayMinutes(), stats),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: "🔄 Обновить", CallbackData: "admin_checkout_reminders"}},
+			{{Text: "🔙 Назад", CallbackData: "admin_back"}},
+		}},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing checkout reminder stats message", "error", err)
+	}
+}
+
+// formatCheckoutReminderStats форматирует статистику; stats идут в порядке checkoutReminderStatsPeriods
+func formatCheckoutReminderStats(enabled bool, delayMinutes int, stats []database.CheckoutReminderStats) string {
+	var sb strings.Builder
+	sb.WriteString("🛒 <b>Напоминания о неоплаченных счетах</b>\n")
+	if enabled {
+		sb.WriteString(fmt.Sprintf("Включены, через %d мин. после создания счёта\n", delayMinutes))
+	} else {
+		sb.WriteString("Выключены\n")
+	}
+
+	for i, st := range stats {
+		paid := st.PaidOriginal + st.PaidRecreated
+		conversion := 0.0
+		if st.Sent > 0 {
+			conversion = float64(paid) * 100 / float64(st.Sent)
+		}
+		sb.WriteString(fmt.Sprintf("\n<b>За %d дн.</b>\n", checkoutReminderStatsPeriods[i]))
+		sb.WriteString(fmt.Sprintf("отправлено: %d, оплатили: %d (%.1f%%)\n", st.Sent, paid, conversion))
+		sb.WriteString(fmt.Sprintf("по исходному счёту: %d, новым счётом: %d\n", st.PaidOriginal, st.PaidRecreated))
+	}
+	return sb.String()
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+func TestFormatCheckoutReminderStats(t *testing.T) {
+	text := formatCheckoutReminderStats(true, 30, []database.CheckoutReminderStats{
+		{Sent: 20, PaidOriginal: 3, PaidRecreated: 1},
+		{Sent: 0},
+	})
+	for _, want := range []string{
+		"Включены, через 30 мин.",
+		"<b>За 7 дн.</b>",
+		"отправлено: 20, оплатили: 4 (20.0%)",
+		"по исходному счёту: 3, новым счётом: 1",
+		"отправлено: 0, оплатили: 0 (0.0%)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in:\n%s", want, text)
+		}
+	}
+
+	if disabled := formatCheckoutReminderStats(false, 30, nil); !strings.Contains(disabled, "Выключены") {
+		t.Errorf("Expected disabled state, got %q", disabled)
+	}
+}
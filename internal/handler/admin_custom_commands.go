@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/broadcast"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+const (
+	adminCommandViewPrefix   = "admin_command_view_"
+	adminCommandTogglePrefix = "admin_command_toggle_"
+	adminCommandDeletePrefix = "admin_command_delete_"
+)
+
+const customCommandFormatHelp = "Пришлите команду одним сообщением (можно с фото, GIF или видео):\n\n" +
+	"<code>/speed Проверка скорости | Speed test\n" +
+	"Ответ на русском\n" +
+	"---\n" +
+	"Answer in English\n" +
+	"---\n" +
+	"Скачать | https://example.com</code>\n\n" +
+	"Первая строка — команда и описание для меню (русское | английское). " +
+	"Английский ответ и кнопки необязательны, в ответе можно использовать HTML. " +
+	"Если команда уже есть, её ответ будет заменён."
+
+// AdminCustomCommandsCallback показывает команды, добавленные админом
+func (h AdminHandlers) AdminCustomCommandsCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	h.cache.Delete(fmt.Sprintf("admin_command_state_%d", update.CallbackQuery.From.ID))
+	h.showCustomCommands(ctx, b, update.CallbackQuery.Message.Message)
+}
+
+// AdminCustomCommandCreateCallback ждёт от админа новую команду или новый ответ существующей
+func (h AdminHandlers) AdminCustomCommandCreateCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	h.cache.SetString(fmt.Sprintf("admin_command_state_%d", update.CallbackQuery.From.ID), "waiting_command", 600)
+
+	msg := update.CallbackQuery.Message.Message
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		Text:      "➕ <b>Новая команда</b>\n\n" + customCommandFormatHelp,
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: "❌ Отмена", CallbackData: "admin_commands"}},
+		}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error editing custom command create message", "error", err)
+	}
+}
+
+// AdminCustomCommandInputHandler сохраняет команду, присланную админом. Перед сохранением ответ отправляется
+// админу для проверки: ошибка в HTML разметке видна сразу, а состояние ввода сохраняется
+func (h AdminHandlers) AdminCustomCommandInputHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	msg := update.Message
+	stateKey := fmt.Sprintf("admin_command_state_%d", msg.From.ID)
+	sendError := func(text string) {
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    msg.Chat.ID,
+			Text:      "❌ " + utils.EscapeHTML(text) + "\n\nИсправьте и пришлите команду ещё раз.",
+			ParseMode: models.ParseModeHTML,
+			ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+				{{Text: "❌ Отмена", CallbackData: "admin_commands"}},
+			}},
+		})
+	}
+
+	text := msg.Text
+	var mediaType, mediaFileID string
+	switch {
+	case len(msg.Photo) > 0:
+		mediaType, mediaFileID, text = broadcast.MediaTypePhoto, msg.Photo[len(msg.Photo)-1].FileID, msg.Caption
+	case msg.Animation != nil:
+		mediaType, mediaFileID, text = broadcast.MediaTypeGIF, msg.Animation.FileID, msg.Caption
+	case msg.Video != nil:
+		mediaType, mediaFileID, text = broadcast.MediaTypeVideo, msg.Video.FileID, msg.Caption
+	case msg.VideoNote != nil:
+		sendError("Кружок нельзя подписать: пришлите фото, GIF или видео")
+		return
+	}
+
+	command, err := parseCustomCommand(text)
+	if err != nil {
+		sendError(err.Error())
+		return
+	}
+	command.MediaType, command.MediaFileID = mediaType, mediaFileID
+	if command.TextRu == "" && command.TextEn == "" && mediaFileID == "" {
+		sendError("Нет ответа: добавьте текст после первой строки или пришлите медиа")
+		return
+	}
+	if mediaFileID != "" && (len(utf16.Encode([]rune(command.TextRu))) > maxCaptionLength || len(utf16.Encode([]rune(command.TextEn))) > maxCaptionLength) {
+		sendError(fmt.Sprintf("Подпись к медиа не может быть длиннее %d символов", maxCaptionLength))
+		return
+	}
+
+	for _, lang := range []string{"ru", "en"} {
+		if lang == "en" && command.TextEn == "" {
+			continue
+		}
+		if err := sendCustomCommand(ctx, b, msg.Chat.ID, command, lang); err != nil {
+			sendError("Telegram не принял ответ (" + lang + "): " + err.Error())
+			return
+		}
+	}
+
+	adminID := msg.From.ID
+	command.CreatedBy = &adminID
+	saved, err := h.customCommands.Save(ctx, command)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving custom command", "command", command.Command, "error", err)
+		sendError("Не удалось сохранить команду")
+		return
+	}
+	h.cache.Delete(stateKey)
+	h.reloadCustomCommands(ctx, b)
+	slog.InfoContext(ctx, "Custom command saved by admin", "command", saved.Command, "adminId", adminID)
+
+	status := ""
+	if !saved.IsActive {
+		status = "\nКоманда выключена — включите её в списке."
+	}
+	_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    msg.Chat.ID,
+		Text:      utils.FormatHTML("✅ Команда /%s сохранена, выше — как её увидят клиенты.%s", saved.Command, status),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: "📋 К списку команд", CallbackData: "admin_commands"}},
+		}},
+	})
+}
+
+// AdminCustomCommandViewCallback показывает команду с кнопками включения и удаления
+func (h AdminHandlers) AdminCustomCommandViewCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	id, _ := strconv.ParseInt(strings.TrimPrefix(update.CallbackQuery.Data, adminCommandViewPrefix), 10, 64)
+	h.showCustomCommand(ctx, b, update.CallbackQuery.Message.Message, id)
+}
+
+// AdminCustomCommandToggleCallback включает или выключает команду
+func (h AdminHandlers) AdminCustomCommandToggleCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	id, _ := strconv.ParseInt(strings.TrimPrefix(update.CallbackQuery.Data, adminCommandTogglePrefix), 10, 64)
+	command, err := h.customCommands.FindByID(ctx, id)
+	if err != nil || command == nil {
+		h.answerFeatureCallback(ctx, b, update, "❌ Команда не найдена")
+		return
+	}
+	if err := h.customCommands.SetActive(ctx, id, !command.IsActive); err != nil {
+		slog.ErrorContext(ctx, "Error toggling custom command", "command", command.Command, "error", err)
+		h.answerFeatureCallback(ctx, b, update, "❌ Не удалось сохранить")
+		return
+	}
+	h.reloadCustomCommands(ctx, b)
+	slog.InfoContext(ctx, "Custom command toggled by admin", "command", command.Command, "active", !command.IsActive, "adminId", update.CallbackQuery.From.ID)
+
+	h.answerFeatureCallback(ctx, b, update, featureMark(!command.IsActive)+" /"+command.Command)
+	h.showCustomCommand(ctx, b, update.CallbackQuery.Message.Message, id)
+}
+
+// AdminCustomCommandDeleteCallback удаляет команду
+func (h AdminHandlers) AdminCustomCommandDeleteCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	id, _ := strconv.ParseInt(strings.TrimPrefix(update.CallbackQuery.Data, adminCommandDeletePrefix), 10, 64)
+	if err := h.customCommands.Delete(ctx, id); err != nil {
+		slog.ErrorContext(ctx, "Error deleting custom command", "id", id, "error", err)
+		h.answerFeatureCallback(ctx, b, update, "❌ Не удалось удалить")
+		return
+	}
+	h.reloadCustomCommands(ctx, b)
+	slog.InfoContext(ctx, "Custom command deleted by admin", "id", id, "adminId", update.CallbackQuery.From.ID)
+
+	h.answerFeatureCallback(ctx, b, update, "🗑 Команда удалена")
+	h.showCustomCommands(ctx, b, update.CallbackQuery.Message.Message)
+}
+
+// reloadCustomCommands применяет изменения сразу на этом экземпляре, остальные подхватят их задачей custom_commands_refresh
+func (h AdminHandlers) reloadCustomCommands(ctx context.Context, b *bot.Bot) {
+	if err := h.commandRegistry.Reload(ctx, b); err != nil {
+		slog.ErrorContext(ctx, "Error reloading custom commands", "error", err)
+	}
+}
+
+func (h AdminHandlers) showCustomCommands(ctx context.Context, b *bot.Bot, msg *models.Message) {
+	commands, err := h.customCommands.List(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing custom commands", "error", err)
+	}
+
+	var keyboard [][]models.InlineKeyboardButton
+	for _, command := range commands {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{{
+			Text:         featureMark(command.IsActive) + " /" + command.Command,
+			CallbackData: fmt.Sprintf("%s%d", adminCommandViewPrefix, command.ID),
+		}})
+	}
+	keyboard = append(keyboard,
+		[]models.InlineKeyboardButton{{Text: "➕ Добавить или изменить", CallbackData: "admin_command_create"}},
+		[]models.InlineKeyboardButton{{Text: "🔙 Назад", CallbackData: "admin_back"}},
+	)
+
+	err = editLongMessage(ctx, b, &bot.EditMessageTextParams{
+		ChatID:      msg.Chat.ID,
+		MessageID:   msg.ID,
+		Text:        formatCustomCommands(commands),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing custom commands message", "error", err)
+	}
+}
+
+func (h AdminHandlers) showCustomCommand(ctx context.Context, b *bot.Bot, msg *models.Message, id int64) {
+	command, err := h.customCommands.FindByID(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding custom command", "id", id, "error", err)
+	}
+	if command == nil {
+		h.showCustomCommands(ctx, b, msg)
+		return
+	}
+
+	toggle := "⛔️ Выключить"
+	if !command.IsActive {
+		toggle = "✅ Включить"
+	}
+	err = editLongMessage(ctx, b, &bot.EditMessageTextParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		Text:      formatCustomCommand(*command),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: toggle, CallbackData: fmt.Sprintf("%s%d", adminCommandTogglePrefix, command.ID)}},
+			{{Text: "🗑 Удалить", CallbackData: fmt.Sprintf("%s%d", adminCommandDeletePrefix, command.ID)}},
+			{{Text: "🔙 Назад", CallbackData: "admin_commands"}},
+		}},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing custom command message", "error", err)
+	}
+}
+
+func formatCustomCommands(commands []database.CustomCommand) string {
+	var sb strings.Builder
+	sb.WriteString("💬 <b>Команды</b>\n\nКоманды с постоянным ответом, например /speed или /faq. " +
+		"Включённые команды появляются в меню бота без перезапуска.")
+	if len(commands) == 0 {
+		sb.WriteString("\n\nКоманд пока нет.")
+	}
+	for _, command := range commands {
+		sb.WriteString(fmt.Sprintf("\n%s /%s — %s", featureMark(command.IsActive), command.Command, utils.EscapeHTML(command.DescriptionRu)))
+	}
+	return sb.String()
+}
+
+func formatCustomCommand(command database.CustomCommand) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("💬 <b>/%s</b> %s\n\n", command.Command, featureMark(command.IsActive)))
+	sb.WriteString(fmt.Sprintf("<b>Описание:</b> %s", utils.EscapeHTML(command.DescriptionRu)))
+	if command.DescriptionEn != "" {
+		sb.WriteString(" | " + utils.EscapeHTML(command.DescriptionEn))
+	}
+	if info := getMediaInfo(command.MediaType); info != "" {
+		sb.WriteString(info)
+	}
+	sb.WriteString("\n\n<b>Ответ (ru):</b>\n" + utils.EscapeHTML(command.TextRu))
+	if command.TextEn != "" {
+		sb.WriteString("\n\n<b>Ответ (en):</b>\n" + utils.EscapeHTML(command.TextEn))
+	}
+	if command.Buttons != "" {
+		sb.WriteString("\n\n<b>Кнопки:</b>\n" + utils.EscapeHTML(command.Buttons))
+	}
+	sb.WriteString("\n\nЧтобы изменить ответ, добавьте команду с тем же именем ещё раз.")
+	return sb.String()
+}
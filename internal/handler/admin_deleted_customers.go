@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+const (
+	adminDeletedCustomers = "admin_deleted_customers"
+	// adminDeletedCustomersPurge без confirm=1 спрашивает подтверждение, с ним — удаляет
+	adminDeletedCustomersPurge = "admin_deleted_customers_purge"
+)
+
+// AdminDeletedCustomersCallback показывает клиентов, удалённых синхронизацией, и сколько из них можно удалить окончательно
+func (h AdminHandlers) AdminDeletedCustomersCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	h.showDeletedCustomers(ctx, b, update.CallbackQuery.Message.Message, "")
+}
+
+// AdminDeletedCustomersPurgeCallback окончательно удаляет клиентов, удалённых синхронизацией раньше
+// DELETED_CUSTOMER_RETENTION_DAYS дней, вместе с покупками. Сначала спрашивает подтверждение
+func (h AdminHandlers) AdminDeletedCustomersPurgeCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	msg := update.CallbackQuery.Message.Message
+	before := deletedCustomersPurgeBefore()
+
+	if parseCallbackData(update.CallbackQuery.Data)["confirm"] != "1" {
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+		_, purgeable, err := h.customerRepository.DeletedStats(ctx, before)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error counting deleted customers", "error", err)
+			h.showDeletedCustomers(ctx, b, msg, "")
+			return
+		}
+		text := fmt.Sprintf("🗑 Удалить навсегда <b>%d</b> клиентов, удалённых синхронизацией раньше %s?\n\n"+
+			"Их покупки и история тоже будут удалены. Отменить нельзя.", purgeable, before.Format("02.01.2006"))
+		h.editDeletedCustomersMessage(ctx, b, msg, text, [][]models.InlineKeyboardButton{
+			{{Text: "✅ Удалить", CallbackData: adminDeletedCustomersPurge + "?confirm=1"}},
+			{{Text: "❌ Отмена", CallbackData: adminDeletedCustomers}},
+		})
+		return
+	}
+
+	purged, err := h.customerRepository.PurgeDeleted(ctx, before)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error purging deleted customers", "error", err)
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+			Text:            "Не удалось удалить клиентов",
+			ShowAlert:       true,
+		})
+		return
+	}
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+	slog.InfoContext(ctx, "Purged deleted customers", "count", purged, "adminId", update.CallbackQuery.From.ID)
+	h.showDeletedCustomers(ctx, b, msg, fmt.Sprintf("✅ Удалено навсегда: %d\n\n", purged))
+}
+
+// deletedCustomersPurgeBefore клиенты, удалённые синхронизацией раньше этого момента, можно удалить окончательно
+func deletedCustomersPurgeBefore() time.Time {
+	return clock.Now().AddDate(0, 0, -config.DeletedCustomerRetentionDays())
+}
+
+func (h AdminHandlers) showDeletedCustomers(ctx context.Context, b *bot.Bot, msg *models.Message, notice string) {
+	total, purgeable, err := h.customerRepository.DeletedStats(ctx, deletedCustomersPurgeBefore())
+	if err != nil {
+		slog.ErrorContext(ctx, "Error counting deleted customers", "error", err)
+		h.editDeletedCustomersMessage(ctx, b, msg, notice+"❌ Не удалось посчитать удалённых клиентов", [][]models.InlineKeyboardButton{
+			{{Text: "🔄 Повторить", CallbackData: adminDeletedCustomers}},
+			{{Text: "🔙 Назад", CallbackData: "admin_back"}},
+		})
+		return
+	}
+
+	var keyboard [][]models.InlineKeyboardButton
+	if purgeable > 0 {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{{
+			Text:         fmt.Sprintf("🗑 Удалить навсегда (%d)", purgeable),
+			CallbackData: adminDeletedCustomersPurge,
+		}})
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{{Text: "🔙 Назад", CallbackData: "admin_back"}})
+	h.editDeletedCustomersMessage(ctx, b, msg, notice+FormatDeletedCustomers(total, purgeable, config.DeletedCustomerRetentionDays()), keyboard)
+}
+
+func (h AdminHandlers) editDeletedCustomersMessage(ctx context.Context, b *bot.Bot, msg *models.Message, text string, keyboard [][]models.InlineKeyboardButton) {
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      msg.Chat.ID,
+		MessageID:   msg.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing deleted customers message", "error", err)
+	}
+}
+
+// FormatDeletedCustomers сводка по клиентам, удалённым синхронизацией
+func FormatDeletedCustomers(total, purgeable, retentionDays int) string {
+	var sb strings.Builder
+	sb.WriteString("🗑 <b>Удалённые клиенты</b>\n\n")
+	sb.WriteString("Клиенты, которых синхронизация не нашла в панели. Они не видны в выборках и рассылках, " +
+		"история покупок сохраняется, а при появлении в панели или новом /start клиент восстанавливается.\n\n")
+	sb.WriteString(fmt.Sprintf("Удалено синхронизацией: <b>%d</b>\n", total))
+	sb.WriteString(fmt.Sprintf("Удалены больше %d дн. назад: <b>%d</b>", retentionDays, purgeable))
+	if purgeable > 0 {
+		sb.WriteString(" — их можно удалить навсегда вместе с покупками")
+	}
+	return sb.String()
+}
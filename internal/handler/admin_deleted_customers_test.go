@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+// mockDeletedCustomers хранит момент удаления синхронизацией для каждого клиента
+type mockDeletedCustomers struct {
+	mockAdminCustomers
+	deletedAt []time.Time
+	purges    []time.Time
+}
+
+func (m *mockDeletedCustomers) DeletedStats(ctx context.Context, before time.Time) (total, purgeable int, err error) {
+	for _, at := range m.deletedAt {
+		if at.Before(before) {
+			purgeable++
+		}
+	}
+	return len(m.deletedAt), purgeable, nil
+}
+
+func (m *mockDeletedCustomers) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	m.purges = append(m.purges, before)
+	var kept []time.Time
+	for _, at := range m.deletedAt {
+		if !at.Before(before) {
+			kept = append(kept, at)
+		}
+	}
+	purged := len(m.deletedAt) - len(kept)
+	m.deletedAt = kept
+	return int64(purged), nil
+}
+
+func TestAdminDeletedCustomersPurgeAsksConfirmation(t *testing.T) {
+	t.Cleanup(config.InitConfig)
+	t.Setenv("DELETED_CUSTOMER_RETENTION_DAYS", "30")
+	config.InitConfig()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock.SetDefault(clock.NewFake(now))
+	t.Cleanup(func() { clock.SetDefault(nil) })
+
+	b, tg := newTestBot(t)
+	customers := &mockDeletedCustomers{deletedAt: []time.Time{now.AddDate(0, 0, -40), now.AddDate(0, 0, -31), now.AddDate(0, 0, -2)}}
+	h := NewAdminHandlers(fakeTranslator{}, newFakeCache(), customers, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	h.AdminDeletedCustomersPurgeCallback(context.Background(), b, paymentCallbackUpdate(adminDeletedCustomersPurge))
+	if len(customers.purges) != 0 {
+		t.Fatal("Expected purge to wait for confirmation")
+	}
+	edited := tg.called("editMessageText")
+	if len(edited) != 1 || !strings.Contains(edited[0].params["text"], "<b>2</b>") {
+		t.Fatalf("Expected confirmation for 2 customers, got %+v", edited)
+	}
+
+	h.AdminDeletedCustomersPurgeCallback(context.Background(), b, paymentCallbackUpdate(adminDeletedCustomersPurge+"?confirm=1"))
+	if len(customers.purges) != 1 || !customers.purges[0].Equal(now.AddDate(0, 0, -30)) {
+		t.Fatalf("Expected one purge of customers deleted before the retention period, got %v", customers.purges)
+	}
+	edited = tg.called("editMessageText")
+	if len(edited) != 2 || !strings.HasPrefix(edited[1].params["text"], "✅ Удалено навсегда: 2") {
+		t.Fatalf("Expected purge result, got %+v", edited)
+	}
+	if len(customers.deletedAt) != 1 {
+		t.Errorf("Expected the recently deleted customer to be kept, got %v", customers.deletedAt)
+	}
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/remnawave"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// maxDuplicateGroupsInReport количество Telegram ID с дублями в отчёте и кнопок объединения
+const maxDuplicateGroupsInReport = 15
+
+// AdminDuplicatesCallback показывает Telegram ID, к которым в панели привязано несколько включённых пользователей
+func (h AdminHandlers) AdminDuplicatesCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	h.showDuplicates(ctx, b, update.CallbackQuery.Message.Message, "")
+}
+
+// AdminDuplicatesMergeCallback объединяет пользователей панели одного Telegram ID: остаётся пользователь с самой
+// поздней датой окончания, он получает остаток трафика остальных, остальные отключаются
+func (h AdminHandlers) AdminDuplicatesMergeCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	telegramID, err := strconv.ParseInt(parseCallbackData(update.CallbackQuery.Data)["id"], 10, 64)
+	if err != nil {
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+		return
+	}
+
+	result, err := h.syncService.MergeDuplicates(ctx, telegramID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error merging duplicate panel users", "telegramId", telegramID, "error", err)
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+			Text:            "Не удалось объединить: " + err.Error(),
+			ShowAlert:       true,
+		})
+		return
+	}
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	notice := fmt.Sprintf("✅ <code>%d</code>: оставлен %s, отключено дублей: %d\n\n",
+		telegramID, utils.EscapeHTML(result.Primary.Username), result.Disabled)
+	h.showDuplicates(ctx, b, update.CallbackQuery.Message.Message, notice)
+}
+
+func (h AdminHandlers) showDuplicates(ctx context.Context, b *bot.Bot, msg *models.Message, notice string) {
+	groups, err := h.syncService.FindDuplicates(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding duplicate panel users", "error", err)
+		h.editDuplicatesMessage(ctx, b, msg, notice+"❌ Не удалось получить пользователей панели", [][]models.InlineKeyboardButton{
+			{{Text: "🔄 Повторить", CallbackData: "admin_duplicates"}},
+			{{Text: "🔙 Назад", CallbackData: "admin_back"}},
+		})
+		return
+	}
+
+	var keyboard [][]models.InlineKeyboardButton
+	for i, group := range groups {
+		if i == maxDuplicateGroupsInReport {
+			break
+		}
+		keyboard = append(keyboard, []models.InlineKeyboardButton{{
+			Text:         fmt.Sprintf("🔀 Объединить %d", group.TelegramID),
+			CallbackData: fmt.Sprintf("admin_duplicates_merge?id=%d", group.TelegramID),
+		}})
+	}
+	keyboard = append(keyboard,
+		[]models.InlineKeyboardButton{{Text: "🔄 Обновить", CallbackData: "admin_duplicates"}},
+		[]models.InlineKeyboardButton{{Text: "🔙 Назад", CallbackData: "admin_back"}},
+	)
+	h.editDuplicatesMessage(ctx, b, msg, notice+FormatDuplicates(groups), keyboard)
+}
+
+func (h AdminHandlers) editDuplicatesMessage(ctx context.Context, b *bot.Bot, msg *models.Message, text string, keyboard [][]models.InlineKeyboardButton) {
+	err := editLongMessage(ctx, b, &bot.EditMessageTextParams{
+		ChatID:      msg.Chat.ID,
+		MessageID:   msg.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing duplicates message", "error", err)
+	}
+}
+
+// FormatDuplicates отчёт о дублях пользователей панели. Основной пользователь группы отмечен ⭐
+func FormatDuplicates(groups []remnawave.DuplicateGroup) string {
+	var sb strings.Builder
+	sb.WriteString("👥 <b>Дубли в панели</b>\n\n")
+	if len(groups) == 0 {
+		sb.WriteString("Каждому Telegram ID соответствует не больше одного включённого пользователя")
+		return sb.String()
+	}
+
+	sb.WriteString(fmt.Sprintf("Telegram ID с несколькими включёнными пользователями: <b>%d</b>\n"+
+		"При объединении остаётся ⭐ (самая поздняя дата окончания), он получает остаток трафика остальных, остальные отключаются.\n", len(groups)))
+	for i, group := range groups {
+		if i == maxDuplicateGroupsInReport {
+			sb.WriteString(fmt.Sprintf("\n…и ещё %d", len(groups)-maxDuplicateGroupsInReport))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("\n<code>%d</code>\n", group.TelegramID))
+		for j, user := range group.Users {
+			mark := "•"
+			if j == 0 {
+				mark = "⭐"
+			}
+			traffic := "безлимит"
+			if user.TrafficLimitBytes > 0 {
+				traffic = locale.FormatTraffic("ru", user.UsedTrafficBytes) + " / " + locale.FormatTraffic("ru", user.TrafficLimitBytes)
+			}
+			sb.WriteString(fmt.Sprintf("%s %s — до %s, %s, %s\n", mark, utils.EscapeHTML(user.Username),
+				user.ExpireAt.Format("02.01.2006"), traffic, user.Status))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/remnawave"
+)
+
+func TestFormatDuplicates(t *testing.T) {
+	if text := FormatDuplicates(nil); !strings.Contains(text, "не больше одного") {
+		t.Errorf("Unexpected empty report %q", text)
+	}
+
+	expireAt := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	text := FormatDuplicates([]remnawave.DuplicateGroup{{
+		TelegramID: 100,
+		Users: []remnawave.UserInfo{
+			{Username: "1_100", ExpireAt: expireAt, Status: "ACTIVE"},
+			{Username: "<manual>", ExpireAt: expireAt.AddDate(0, -1, 0), Status: "ACTIVE", TrafficLimitBytes: 10 << 30, UsedTrafficBytes: 5 << 30},
+		},
+	}})
+	for _, want := range []string{
+		"<code>100</code>",
+		"⭐ 1_100 — до 01.06.2025, безлимит, ACTIVE",
+		"• &lt;manual&gt; — до 01.05.2025",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected report to contain %q, got:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+const (
+	adminFaqListPrefix   = "admin_faq_list"
+	adminFaqViewPrefix   = "admin_faq_view_"
+	adminFaqTogglePrefix = "admin_faq_toggle_"
+	adminFaqDeletePrefix = "admin_faq_delete_"
+	// adminFaqPageSize сколько вопросов на странице списка в админке
+	adminFaqPageSize = 10
+)
+
+// faqLanguages языки, на которых можно добавлять вопросы
+var faqLanguages = map[string]bool{"ru": true, "en": true}
+
+const faqFormatHelp = "Пришлите вопрос одним сообщением:\n\n" +
+	"<code>ru | Подключение\n" +
+	"Как подключиться на iPhone?\n" +
+	"---\n" +
+	"Установите приложение и нажмите «Подключиться» в меню бота.</code>\n\n" +
+	"Первая строка — язык (ru или en) и категория, вторая — вопрос, после <code>---</code> — ответ. " +
+	"В ответе можно использовать HTML."
+
+// AdminFaqCallback показывает вопросы FAQ постранично
+func (h FaqHandlers) AdminFaqCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	h.cache.Delete(fmt.Sprintf("admin_faq_state_%d", update.CallbackQuery.From.ID))
+	h.showAdminFaq(ctx, b, update.CallbackQuery.Message.Message, listPage(update.CallbackQuery.Data, adminFaqListPrefix))
+}
+
+// AdminFaqCreateCallback ждёт от админа новый вопрос
+func (h FaqHandlers) AdminFaqCreateCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	h.cache.SetString(fmt.Sprintf("admin_faq_state_%d", update.CallbackQuery.From.ID), "waiting_entry", 600)
+
+	msg := update.CallbackQuery.Message.Message
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		Text:      "➕ <b>Новый вопрос</b>\n\n" + faqFormatHelp,
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: "❌ Отмена", CallbackData: adminFaqListPrefix}},
+		}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error editing faq create message", "error", err)
+	}
+}
+
+// AdminFaqCreateInputHandler сохраняет вопрос, присланный админом. Перед сохранением ответ отправляется
+// админу: ошибка в HTML разметке видна сразу, а состояние ввода сохраняется
+func (h FaqHandlers) AdminFaqCreateInputHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if update.Message == nil || update.Message.From.ID != config.GetAdminTelegramId() {
+		return
+	}
+	chatID := update.Message.Chat.ID
+	sendError := func(text string) {
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    chatID,
+			Text:      "❌ " + utils.EscapeHTML(text) + "\n\nИсправьте и пришлите вопрос ещё раз.",
+			ParseMode: models.ParseModeHTML,
+			ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+				{{Text: "❌ Отмена", CallbackData: adminFaqListPrefix}},
+			}},
+		})
+	}
+
+	entry, err := parseFaqEntry(update.Message.Text)
+	if err != nil {
+		sendError(err.Error())
+		return
+	}
+
+	preview := fmt.Sprintf("❓ <b>%s</b>\n\n%s", utils.EscapeHTML(entry.Question), entry.Answer)
+	if _, err := b.SendMessage(ctx, &bot.SendMessageParams{ChatID: chatID, Text: preview, ParseMode: models.ParseModeHTML}); err != nil {
+		sendError("Telegram не принял ответ: " + err.Error())
+		return
+	}
+
+	adminID := update.Message.From.ID
+	entry.CreatedBy = &adminID
+	created, err := h.faq.Create(ctx, entry)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error creating faq entry", "error", err)
+		sendError("Не удалось сохранить вопрос")
+		return
+	}
+	h.cache.Delete(fmt.Sprintf("admin_faq_state_%d", adminID))
+	slog.InfoContext(ctx, "Faq entry created by admin", "id", created.ID, "language", created.Language, "category", created.Category, "adminId", adminID)
+
+	_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   fmt.Sprintf("✅ Вопрос добавлен в категорию «%s» (%s), выше — как его увидят клиенты.", utils.EscapeHTML(created.Category), created.Language),
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: "➕ Ещё вопрос", CallbackData: "admin_faq_create"}},
+			{{Text: "📋 К списку", CallbackData: adminFaqListPrefix}},
+		}},
+	})
+}
+
+// AdminFaqViewCallback показывает вопрос с кнопками включения и удаления
+func (h FaqHandlers) AdminFaqViewCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	id, _ := strconv.ParseInt(strings.TrimPrefix(update.CallbackQuery.Data, adminFaqViewPrefix), 10, 64)
+	h.showAdminFaqEntry(ctx, b, update.CallbackQuery.Message.Message, id)
+}
+
+// AdminFaqToggleCallback скрывает вопрос от клиентов или снова показывает его
+func (h FaqHandlers) AdminFaqToggleCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	id, _ := strconv.ParseInt(strings.TrimPrefix(update.CallbackQuery.Data, adminFaqTogglePrefix), 10, 64)
+	entry, err := h.faq.FindByID(ctx, id)
+	if err != nil || entry == nil {
+		h.answerAdminFaq(ctx, b, update, "❌ Вопрос не найден")
+		return
+	}
+	if err := h.faq.SetActive(ctx, id, !entry.IsActive); err != nil {
+		slog.ErrorContext(ctx, "Error toggling faq entry", "id", id, "error", err)
+		h.answerAdminFaq(ctx, b, update, "❌ Не удалось сохранить")
+		return
+	}
+	slog.InfoContext(ctx, "Faq entry toggled by admin", "id", id, "active", !entry.IsActive, "adminId", update.CallbackQuery.From.ID)
+
+	h.answerAdminFaq(ctx, b, update, featureMark(!entry.IsActive))
+	h.showAdminFaqEntry(ctx, b, update.CallbackQuery.Message.Message, id)
+}
+
+// AdminFaqDeleteCallback удаляет вопрос
+func (h FaqHandlers) AdminFaqDeleteCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	id, _ := strconv.ParseInt(strings.TrimPrefix(update.CallbackQuery.Data, adminFaqDeletePrefix), 10, 64)
+	if err := h.faq.Delete(ctx, id); err != nil {
+		slog.ErrorContext(ctx, "Error deleting faq entry", "id", id, "error", err)
+		h.answerAdminFaq(ctx, b, update, "❌ Не удалось удалить")
+		return
+	}
+	slog.InfoContext(ctx, "Faq entry deleted by admin", "id", id, "adminId", update.CallbackQuery.From.ID)
+
+	h.answerAdminFaq(ctx, b, update, "🗑 Вопрос удалён")
+	h.showAdminFaq(ctx, b, update.CallbackQuery.Message.Message, 0)
+}
+
+func (h FaqHandlers) answerAdminFaq(ctx context.Context, b *bot.Bot, update *models.Update, text string) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            text,
+	})
+}
+
+func (h FaqHandlers) showAdminFaq(ctx context.Context, b *bot.Bot, msg *models.Message, page int) {
+	entries, err := h.faq.List(ctx, adminFaqPageSize+1, page*adminFaqPageSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error listing faq entries", "error", err)
+	}
+	hasNext := len(entries) > adminFaqPageSize
+	if hasNext {
+		entries = entries[:adminFaqPageSize]
+	}
+
+	var keyboard [][]models.InlineKeyboardButton
+	for _, entry := range entries {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{{
+			Text:         fmt.Sprintf("%s %s · %s", featureMark(entry.IsActive), entry.Language, truncateRunes(entry.Question, faqButtonLength)),
+			CallbackData: fmt.Sprintf("%s%d", adminFaqViewPrefix, entry.ID),
+		}})
+	}
+	if row := pageNavigationRow(adminFaqListPrefix, page, hasNext); row != nil {
+		keyboard = append(keyboard, row)
+	}
+	keyboard = append(keyboard,
+		[]models.InlineKeyboardButton{{Text: "➕ Добавить вопрос", CallbackData: "admin_faq_create"}},
+		[]models.InlineKeyboardButton{{Text: "🔙 Назад", CallbackData: "admin_back"}},
+	)
+
+	text := "❓ <b>FAQ</b>\n\nВопросы показываются клиентам в разделе «FAQ» главного меню на их языке. " +
+		"Чтобы изменить вопрос, добавьте новый и удалите старый."
+	if !config.IsFaqEnabled() {
+		text += "\n\n⛔️ Раздел выключен: включите его в «🚩 Фичи» или переменной FAQ_ENABLED."
+	}
+	if len(entries) == 0 && page == 0 {
+		text += "\n\nВопросов пока нет."
+	}
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      msg.Chat.ID,
+		MessageID:   msg.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing faq list message", "error", err)
+	}
+}
+
+func (h FaqHandlers) showAdminFaqEntry(ctx context.Context, b *bot.Bot, msg *models.Message, id int64) {
+	entry, err := h.faq.FindByID(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding faq entry", "id", id, "error", err)
+	}
+	if entry == nil {
+		h.showAdminFaq(ctx, b, msg, 0)
+		return
+	}
+
+	toggle := "⛔️ Скрыть"
+	if !entry.IsActive {
+		toggle = "✅ Показать"
+	}
+	text := fmt.Sprintf("%s <b>%s</b> · %s\n\n❓ <b>%s</b>\n\n%s",
+		featureMark(entry.IsActive), utils.EscapeHTML(entry.Category), entry.Language, utils.EscapeHTML(entry.Question), entry.Answer)
+	err = editLongMessage(ctx, b, &bot.EditMessageTextParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: toggle, CallbackData: fmt.Sprintf("%s%d", adminFaqTogglePrefix, entry.ID)}},
+			{{Text: "🗑 Удалить", CallbackData: fmt.Sprintf("%s%d", adminFaqDeletePrefix, entry.ID)}},
+			{{Text: "🔙 Назад", CallbackData: adminFaqListPrefix}},
+		}},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing faq entry message", "error", err)
+	}
+}
+
+// parseFaqEntry разбирает вопрос, который админ прислал в панели: первая строка "язык | категория",
+// вторая — вопрос, после строки "---" — ответ
+func parseFaqEntry(text string) (database.FaqEntry, error) {
+	head, answer, ok := strings.Cut(strings.ReplaceAll(text, "\r\n", "\n"), "\n---\n")
+	if !ok || strings.TrimSpace(answer) == "" {
+		return database.FaqEntry{}, errors.New("нет ответа: напишите его после строки ---")
+	}
+	header, question, _ := strings.Cut(strings.TrimSpace(head), "\n")
+	lang, category, ok := strings.Cut(header, "|")
+	lang, category = strings.ToLower(strings.TrimSpace(lang)), strings.TrimSpace(category)
+	if !ok || !faqLanguages[lang] {
+		return database.FaqEntry{}, errors.New("первая строка должна быть вида «ru | Категория» или «en | Category»")
+	}
+	if category == "" {
+		return database.FaqEntry{}, errors.New("укажите категорию после языка")
+	}
+	question = strings.Join(strings.Fields(question), " ")
+	if question == "" {
+		return database.FaqEntry{}, errors.New("напишите вопрос второй строкой")
+	}
+	return database.FaqEntry{
+		Language: lang,
+		Category: category,
+		Question: question,
+		Answer:   strings.TrimSpace(answer),
+	}, nil
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/utils"
+)
+
+const (
+	adminFeatureTogglePrefix = "admin_feature_toggle_"
+	adminFeatureResetPrefix  = "admin_feature_reset_"
+)
+
+// AdminFeaturesCallback показывает панель фич: текущее значение каждой и откуда оно взято (env или админка)
+func (h AdminHandlers) AdminFeaturesCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	h.showAdminFeatures(ctx, b, update.CallbackQuery.Message.Message)
+}
+
+// AdminFeatureToggleCallback переключает фичу. Значение сохраняется в БД и применяется сразу, без перезапуска
+func (h AdminHandlers) AdminFeatureToggleCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	flag, ok := config.FindFeatureFlag(strings.TrimPrefix(update.CallbackQuery.Data, adminFeatureTogglePrefix))
+	if !ok {
+		h.answerFeatureCallback(ctx, b, update, "❌ Фича не найдена")
+		return
+	}
+
+	enabled, _ := config.FeatureState(flag)
+	if err := h.featureFlags.Set(ctx, flag.Name, !enabled, update.CallbackQuery.From.ID); err != nil {
+		slog.ErrorContext(ctx, "Error saving feature flag", "feature", flag.Name, "error", err)
+		h.answerFeatureCallback(ctx, b, update, "❌ Не удалось сохранить")
+		return
+	}
+	config.SetFeatureOverride(flag.Name, !enabled)
+	slog.InfoContext(ctx, "Feature flag changed by admin", "feature", flag.Name, "enabled", !enabled, "adminId", update.CallbackQuery.From.ID)
+
+	text := "✅ Включено"
+	if enabled {
+		text = "⛔️ Выключено"
+	}
+	h.answerFeatureCallback(ctx, b, update, text)
+	h.showAdminFeatures(ctx, b, update.CallbackQuery.Message.Message)
+}
+
+// AdminFeatureResetCallback снимает переопределение: фича снова берётся из переменной окружения
+func (h AdminHandlers) AdminFeatureResetCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	flag, ok := config.FindFeatureFlag(strings.TrimPrefix(update.CallbackQuery.Data, adminFeatureResetPrefix))
+	if !ok {
+		h.answerFeatureCallback(ctx, b, update, "❌ Фича не найдена")
+		return
+	}
+
+	if err := h.featureFlags.Delete(ctx, flag.Name); err != nil {
+		slog.ErrorContext(ctx, "Error deleting feature flag", "feature", flag.Name, "error", err)
+		h.answerFeatureCallback(ctx, b, update, "❌ Не удалось сбросить")
+		return
+	}
+	config.ClearFeatureOverride(flag.Name)
+	slog.InfoContext(ctx, "Feature flag reset to env by admin", "feature", flag.Name, "adminId", update.CallbackQuery.From.ID)
+
+	h.answerFeatureCallback(ctx, b, update, "↩️ Значение из "+flag.Env)
+	h.showAdminFeatures(ctx, b, update.CallbackQuery.Message.Message)
+}
+
+func (h AdminHandlers) answerFeatureCallback(ctx context.Context, b *bot.Bot, update *models.Update, text string) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            text,
+	})
+}
+
+func (h AdminHandlers) showAdminFeatures(ctx context.Context, b *bot.Bot, msg *models.Message) {
+	var keyboard [][]models.InlineKeyboardButton
+	for _, flag := range config.FeatureFlags() {
+		enabled, overridden := config.FeatureState(flag)
+		row := []models.InlineKeyboardButton{
+			{Text: featureMark(enabled) + " " + flag.Title, CallbackData: adminFeatureTogglePrefix + flag.Name},
+		}
+		if overridden {
+			row = append(row, models.InlineKeyboardButton{Text: "↩️ env", CallbackData: adminFeatureResetPrefix + flag.Name})
+		}
+		keyboard = append(keyboard, row)
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{{Text: "🔙 Назад", CallbackData: "admin_back"}})
+
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      msg.Chat.ID,
+		MessageID:   msg.ID,
+		Text:        formatFeatureFlags(config.FeatureFlags(), config.FeatureState),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing features message", "error", err)
+	}
+}
+
+func formatFeatureFlags(flags []config.FeatureFlag, state func(config.FeatureFlag) (bool, bool)) string {
+	var sb strings.Builder
+	sb.WriteString("🚩 <b>Фичи</b>\n\nНажмите на фичу, чтобы переключить её без перезапуска. " +
+		"«↩️ env» возвращает значение из переменной окружения.\n")
+	for _, flag := range flags {
+		enabled, overridden := state(flag)
+		source := "env"
+		if overridden {
+			source = fmt.Sprintf("админка, в env: %s", featureOnOff(flag.EnvValue()))
+		}
+		sb.WriteString(fmt.Sprintf("\n%s %s — <code>%s</code> (%s)", featureMark(enabled), utils.EscapeHTML(flag.Title), flag.Env, source))
+	}
+	return sb.String()
+}
+
+func featureMark(enabled bool) string {
+	if enabled {
+		return "✅"
+	}
+	return "⛔️"
+}
+
+func featureOnOff(enabled bool) string {
+	if enabled {
+		return "вкл"
+	}
+	return "выкл"
+}
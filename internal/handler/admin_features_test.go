@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+func TestFormatFeatureFlags(t *testing.T) {
+	state := func(f config.FeatureFlag) (bool, bool) {
+		if f.Name == config.FeatureWinback {
+			return true, true
+		}
+		return false, false
+	}
+
+	text := formatFeatureFlags(config.FeatureFlags(), state)
+	for _, want := range []string{
+		"✅ Winback предложения — <code>WINBACK_ENABLED</code> (админка, в env: выкл)",
+		"⛔️ Ежедневная сводка — <code>DAILY_REPORT_ENABLED</code> (env)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected text to contain %q, got:\n%s", want, text)
+		}
+	}
+}
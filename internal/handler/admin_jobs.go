@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/scheduler"
+	"remnawave-tg-shop-bot/utils"
+)
+
+const adminJobRunPrefix = "admin_job_run_"
+
+// JobScheduler интерфейс планировщика фоновых задач для админки
+type JobScheduler interface {
+	Statuses() []scheduler.Status
+	RunNow(name string) error
+}
+
+// jobScheduler хранит ссылку на планировщик фоновых задач
+var jobScheduler JobScheduler
+
+// SetJobScheduler устанавливает планировщик для просмотра и ручного запуска задач из админки
+func SetJobScheduler(s JobScheduler) {
+	jobScheduler = s
+}
+
+// AdminJobsCallback показывает фоновые задачи: расписание, последний запуск, длительность, последнюю ошибку
+// и счётчики запусков с момента старта бота
+func (h AdminHandlers) AdminJobsCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	h.showAdminJobs(ctx, b, update.CallbackQuery.Message.Message)
+}
+
+// AdminJobRunCallback запускает задачу вне расписания
+func (h AdminHandlers) AdminJobRunCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if jobScheduler == nil {
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+			Text:            "❌ Планировщик не инициализирован",
+			ShowAlert:       true,
+		})
+		return
+	}
+
+	name := strings.TrimPrefix(update.CallbackQuery.Data, adminJobRunPrefix)
+	text := "▶️ Задача запущена"
+	err := jobScheduler.RunNow(name)
+	switch {
+	case errors.Is(err, scheduler.ErrAlreadyRunning):
+		text = "⏳ Задача уже выполняется"
+	case err != nil:
+		text = "❌ Задача не найдена"
+	default:
+		slog.InfoContext(ctx, "Job started by admin", "job", name)
+	}
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            text,
+	})
+
+	// Короткая пауза, чтобы быстрые задачи успели завершиться и результат был виден сразу
+	time.Sleep(500 * time.Millisecond)
+	h.showAdminJobs(ctx, b, update.CallbackQuery.Message.Message)
+}
+
+func (h AdminHandlers) showAdminJobs(ctx context.Context, b *bot.Bot, msg *models.Message) {
+	var statuses []scheduler.Status
+	if jobScheduler != nil {
+		statuses = jobScheduler.Statuses()
+	}
+
+	var keyboard [][]models.InlineKeyboardButton
+	for _, st := range statuses {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: "▶️ " + st.Title, CallbackData: adminJobRunPrefix + st.Name},
+		})
+	}
+	keyboard = append(keyboard,
+		[]models.InlineKeyboardButton{{Text: "🔄 Обновить", CallbackData: "admin_jobs"}},
+		[]models.InlineKeyboardButton{{Text: "🔙 Назад", CallbackData: "admin_back"}},
+	)
+
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      msg.Chat.ID,
+		MessageID:   msg.ID,
+		Text:        formatJobStatuses(statuses, time.Now()),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing jobs message", "error", err)
+	}
+}
+
+func formatJobStatuses(statuses []scheduler.Status, now time.Time) string {
+	var sb strings.Builder
+	sb.WriteString("🕒 <b>Задачи</b>\n")
+	if len(statuses) == 0 {
+		sb.WriteString("\nНет зарегистрированных задач")
+		return sb.String()
+	}
+
+	for _, st := range statuses {
+		sb.WriteString(fmt.Sprintf("\n<b>%s</b> <code>%s</code>\n", utils.EscapeHTML(st.Title), utils.EscapeHTML(st.Schedule)))
+		switch {
+		case st.Running:
+			sb.WriteString("⏳ выполняется\n")
+		case st.LastRun.IsZero():
+			sb.WriteString("ещё не запускалась\n")
+		default:
+			status := "✅"
+			if st.LastError != "" {
+				status = "❌"
+			}
+			sb.WriteString(fmt.Sprintf("%s %s назад, %s\n", status, formatAge(now.Sub(st.LastRun)), formatJobDuration(st.LastDuration)))
+		}
+		if st.Runs > 0 {
+			sb.WriteString(fmt.Sprintf("запусков: %d, ошибок: %d, пропущено: %d, макс: %s\n",
+				st.Runs, st.Failures, st.Skipped, formatJobDuration(st.MaxDuration)))
+		}
+		if st.LastError != "" {
+			sb.WriteString(fmt.Sprintf("Ошибка: %s\n", utils.EscapeHTML(truncateRunes(st.LastError, 200))))
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// formatAge показывает давность запуска в крупнейших целых единицах
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%d с", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%d мин", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d ч", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%d д", int(d.Hours()/24))
+	}
+}
+
+func formatJobDuration(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%d мс", d.Milliseconds())
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
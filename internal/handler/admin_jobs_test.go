@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/scheduler"
+)
+
+func TestFormatJobStatuses(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	statuses := []scheduler.Status{
+		{Name: "webhook_retry", Title: "Повтор webhook событий", Schedule: "* * * * *", LastRun: now.Add(-90 * time.Second), LastDuration: 250 * time.Millisecond},
+		{Name: "reconciliation", Title: "Сверка платежей", Schedule: "0 5 * * *", LastRun: now.Add(-7 * time.Hour), LastDuration: 3 * time.Second, LastError: "yookasa: <timeout>", Runs: 4, Failures: 2, Skipped: 1, MaxDuration: 12 * time.Second},
+		{Name: "daily_report", Title: "Ежедневная сводка", Schedule: "0 9 * * *"},
+		{Name: "trial_inactive", Title: "Неактивные триалы", Schedule: "0 * * * *", Running: true},
+	}
+
+	text := formatJobStatuses(statuses, now)
+	for _, want := range []string{
+		"<code>* * * * *</code>",
+		"✅ 1 мин назад, 250 мс",
+		"❌ 7 ч назад, 3s",
+		"Ошибка: yookasa: &lt;timeout&gt;",
+		"запусков: 4, ошибок: 2, пропущено: 1, макс: 12s",
+		"ещё не запускалась",
+		"⏳ выполняется",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected text to contain %q, got:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+type mockQuickStats struct {
+	header string
+	err    error
+}
+
+func (m mockQuickStats) Header(context.Context) (string, error) {
+	return m.header, m.err
+}
+
+func adminCommandUpdate() *models.Update {
+	admin := config.GetAdminTelegramId()
+	return &models.Update{Message: &models.Message{
+		Text: "/admin",
+		From: &models.User{ID: admin},
+		Chat: models.Chat{ID: admin},
+	}}
+}
+
+func TestAdminCommandShowsQuickStatsHeader(t *testing.T) {
+	b, tg := newTestBot(t)
+	h := NewAdminHandlers(fakeTranslator{}, newFakeCache(), nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		mockQuickStats{header: "💰 Сегодня: <b>1700 RUB</b>"})
+
+	h.AdminCommandHandler(context.Background(), b, adminCommandUpdate())
+
+	sent := tg.called("sendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("Expected admin menu, got %v", sent)
+	}
+	if text := sent[0].params["text"]; !strings.Contains(text, "Панель администратора</b>\n\n💰 Сегодня: <b>1700 RUB</b>\n\nВыберите действие:") {
+		t.Errorf("Expected quick stats between title and prompt, got %q", text)
+	}
+}
+
+// Без сводки панель всё равно открывается: админ не должен терять доступ к меню из-за медленной базы
+func TestAdminCommandOpensWithoutQuickStatsOnError(t *testing.T) {
+	b, tg := newTestBot(t)
+	h := NewAdminHandlers(fakeTranslator{}, newFakeCache(), nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		mockQuickStats{err: errors.New("db down")})
+
+	h.AdminCommandHandler(context.Background(), b, adminCommandUpdate())
+
+	sent := tg.called("sendMessage")
+	if len(sent) != 1 || sent[0].params["text"] != "🔧 <b>Панель администратора</b>\n\nВыберите действие:" {
+		t.Errorf("Expected bare admin menu, got %v", sent)
+	}
+}
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// AdminRecurringCallback показывает клиентов с автопродлением по тарифам и предлагает выбрать,
+// у кого отключить автопродление
+func (h AdminHandlers) AdminRecurringCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	segments, err := h.recurringBulk.Segments(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading recurring segments", "error", err)
+		return
+	}
+
+	var keyboard [][]models.InlineKeyboardButton
+	total := 0
+	for _, s := range segments {
+		total += s.Count
+		if s.TariffName == nil {
+			continue
+		}
+		keyboard = append(keyboard, []models.InlineKeyboardButton{{
+			Text:         fmt.Sprintf("%s (%d)", *s.TariffName, s.Count),
+			CallbackData: SafeCallbackData("admin_recurring_seg?t=" + *s.TariffName),
+		}})
+	}
+	if total > 0 {
+		keyboard = append([][]models.InlineKeyboardButton{{{
+			Text:         fmt.Sprintf("👥 Все (%d)", total),
+			CallbackData: "admin_recurring_seg?all=1",
+		}}}, keyboard...)
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{{Text: "🔙 Назад", CallbackData: "admin_back"}})
+
+	text := "🔁 <b>Автопродление</b>\n\nВыберите, у кого отключить автопродление:"
+	if total == 0 {
+		text = "🔁 <b>Автопродление</b>\n\nКлиентов с включённым автопродлением нет"
+	}
+	h.editRecurringMessage(ctx, b, update.CallbackQuery.Message.Message, text, keyboard)
+}
+
+// AdminRecurringSegmentCallback показывает, у скольких клиентов сегмента будет отключено автопродление, и просит подтвердить
+func (h AdminHandlers) AdminRecurringSegmentCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	tariff, query := recurringSegmentFromCallback(update.CallbackQuery.Data)
+	segments, err := h.recurringBulk.Segments(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading recurring segments", "error", err)
+		return
+	}
+	count := recurringSegmentCount(segments, tariff)
+
+	keyboard := [][]models.InlineKeyboardButton{{{Text: "🔙 Назад", CallbackData: "admin_recurring"}}}
+	if count > 0 {
+		keyboard = append([][]models.InlineKeyboardButton{{{
+			Text:         fmt.Sprintf("⛔️ Отключить у %d", count),
+			CallbackData: SafeCallbackData("admin_recurring_disable?" + query),
+		}}}, keyboard...)
+	}
+
+	text := fmt.Sprintf("🔁 <b>Отключение автопродления</b>\n\nСегмент: %s\nКлиентов с автопродлением: <b>%d</b>\n\n"+
+		"Сохранённые карты не удаляются. Операцию можно отменить в течение %d мин., клиенты получат уведомление после этого срока.",
+		recurringSegmentName(tariff), count, config.RecurringBulkUndoMinutes())
+	h.editRecurringMessage(ctx, b, update.CallbackQuery.Message.Message, text, keyboard)
+}
+
+// AdminRecurringDisableCallback отключает автопродление у клиентов сегмента
+func (h AdminHandlers) AdminRecurringDisableCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	tariff, _ := recurringSegmentFromCallback(update.CallbackQuery.Data)
+	undoUntil := clock.Now().Add(time.Duration(config.RecurringBulkUndoMinutes()) * time.Minute)
+
+	op, err := h.recurringBulk.Disable(ctx, tariff, update.CallbackQuery.From.ID, undoUntil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error disabling recurring for segment", "error", err)
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+			Text:            "Не удалось отключить автопродление",
+			ShowAlert:       true,
+		})
+		return
+	}
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	slog.InfoContext(ctx, "Recurring disabled for segment", "operationId", op.ID, "segment", recurringSegmentName(tariff), "customers", len(op.CustomerIDs))
+
+	text := fmt.Sprintf("✅ <b>Автопродление отключено</b>\n\nСегмент: %s\nКлиентов: <b>%d</b>\n\n"+
+		"Отменить можно до %s, после этого клиенты получат уведомление.",
+		recurringSegmentName(tariff), len(op.CustomerIDs), op.UndoUntil.Format("02.01.2006 15:04"))
+	keyboard := [][]models.InlineKeyboardButton{
+		{{Text: "🔙 В меню", CallbackData: "admin_back"}},
+	}
+	if len(op.CustomerIDs) > 0 {
+		keyboard = append([][]models.InlineKeyboardButton{
+			{{Text: "↩️ Отменить", CallbackData: fmt.Sprintf("admin_recurring_undo?id=%d", op.ID)}},
+		}, keyboard...)
+	}
+	h.editRecurringMessage(ctx, b, update.CallbackQuery.Message.Message, text, keyboard)
+}
+
+// AdminRecurringUndoCallback включает автопродление обратно, пока не истёк срок отмены
+func (h AdminHandlers) AdminRecurringUndoCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	id, err := strconv.ParseInt(parseCallbackData(update.CallbackQuery.Data)["id"], 10, 64)
+	if err != nil {
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+		return
+	}
+
+	restored, undone, err := h.recurringBulk.Undo(ctx, id, clock.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "Error undoing recurring bulk disable", "operationId", id, "error", err)
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+			Text:            "Не удалось отменить операцию",
+			ShowAlert:       true,
+		})
+		return
+	}
+	if !undone {
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+			Text:            "Срок отмены истёк или операция уже отменена",
+			ShowAlert:       true,
+		})
+		return
+	}
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	slog.InfoContext(ctx, "Recurring bulk disable undone", "operationId", id, "restored", restored)
+
+	text := fmt.Sprintf("↩️ <b>Операция отменена</b>\n\nАвтопродление снова включено у %d клиентов. Уведомления не отправлялись.", restored)
+	h.editRecurringMessage(ctx, b, update.CallbackQuery.Message.Message, text, [][]models.InlineKeyboardButton{
+		{{Text: "🔁 Автопродление", CallbackData: "admin_recurring"}},
+		{{Text: "🔙 В меню", CallbackData: "admin_back"}},
+	})
+}
+
+func (h AdminHandlers) editRecurringMessage(ctx context.Context, b *bot.Bot, msg *models.Message, text string, keyboard [][]models.InlineKeyboardButton) {
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      msg.Chat.ID,
+		MessageID:   msg.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing recurring message", "error", err)
+	}
+}
+
+// recurringSegmentFromCallback тариф сегмента из callback data вида "<callback>?t=<тариф>" или "<callback>?all=1"
+// (nil — все клиенты) и параметры для следующей кнопки
+func recurringSegmentFromCallback(data string) (*string, string) {
+	params := parseCallbackData(data)
+	if tariff, ok := params["t"]; ok && tariff != "" {
+		return &tariff, "t=" + tariff
+	}
+	return nil, "all=1"
+}
+
+// recurringSegmentCount число клиентов с автопродлением в сегменте
+func recurringSegmentCount(segments []database.RecurringSegment, tariff *string) int {
+	count := 0
+	for _, s := range segments {
+		if tariff == nil || (s.TariffName != nil && *s.TariffName == *tariff) {
+			count += s.Count
+		}
+	}
+	return count
+}
+
+func recurringSegmentName(tariff *string) string {
+	if tariff == nil {
+		return "все клиенты"
+	}
+	return "тариф " + utils.EscapeHTML(*tariff)
+}
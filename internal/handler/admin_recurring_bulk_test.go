@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+func TestRecurringSegmentFromCallback(t *testing.T) {
+	tariff, query := recurringSegmentFromCallback("admin_recurring_seg?t=PRO")
+	if tariff == nil || *tariff != "PRO" || query != "t=PRO" {
+		t.Fatalf("unexpected tariff segment %v %q", tariff, query)
+	}
+	tariff, query = recurringSegmentFromCallback("admin_recurring_seg?all=1")
+	if tariff != nil || query != "all=1" {
+		t.Fatalf("unexpected all segment %v %q", tariff, query)
+	}
+}
+
+func TestRecurringSegmentCount(t *testing.T) {
+	pro, start := "PRO", "START"
+	segments := []database.RecurringSegment{
+		{TariffName: &pro, Count: 5},
+		{TariffName: nil, Count: 2},
+		{TariffName: &start, Count: 1},
+	}
+	if got := recurringSegmentCount(segments, nil); got != 8 {
+		t.Errorf("all segments: got %d, want 8", got)
+	}
+	if got := recurringSegmentCount(segments, &pro); got != 5 {
+		t.Errorf("PRO segment: got %d, want 5", got)
+	}
+	missing := "VIP"
+	if got := recurringSegmentCount(segments, &missing); got != 0 {
+		t.Errorf("missing segment: got %d, want 0", got)
+	}
+}
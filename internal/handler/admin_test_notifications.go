@@ -4,17 +4,19 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 
 	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/utils"
 )
 
 // NotificationTester интерфейс для тестирования уведомлений
 type NotificationTester interface {
-	ProcessTrialInactiveNotifications() error
+	ProcessTrialInactiveNotifications(ctx context.Context) error
 }
 
 // notificationTester хранит ссылку на сервис уведомлений
@@ -25,8 +27,60 @@ func SetNotificationTester(tester NotificationTester) {
 	notificationTester = tester
 }
 
+// NotificationPreview образец уведомления для проверки админом. Render собирает сообщение с примерными
+// данными на языке lang, получателя проставляет вызывающий
+type NotificationPreview struct {
+	Title  string
+	Render func(lang string) *bot.SendMessageParams
+}
+
+// notificationPreviews образцы уведомлений других пакетов (фоновые рассылки)
+var notificationPreviews []NotificationPreview
+
+// RegisterNotificationPreviews добавляет образцы уведомлений в меню тестирования
+func RegisterNotificationPreviews(previews ...NotificationPreview) {
+	notificationPreviews = append(notificationPreviews, previews...)
+}
+
+// languageLister переводчик, который знает список загруженных языков
+type languageLister interface {
+	Languages() []string
+}
+
+// webhookNotificationPreviews образцы уведомлений, которые отправляются по событиям Remnawave
+func webhookNotificationPreviews(tm translationManager) []NotificationPreview {
+	amount := config.Price1()
+	return []NotificationPreview{
+		{"Подписка истекает через 24 часа", func(lang string) *bot.SendMessageParams { return expiringNotification(tm, lang) }},
+		{"Подписка истекла", func(lang string) *bot.SendMessageParams { return expiredNotification(tm, lang) }},
+		{"Автопродление: предстоящее списание", func(lang string) *bot.SendMessageParams {
+			return recurringChargeNotification(tm, lang, amount, 0)
+		}},
+		{"Автопродление: предстоящее списание с баланса", func(lang string) *bot.SendMessageParams {
+			return recurringChargeNotification(tm, lang, amount, amount/2)
+		}},
+		{"Автопродление: согласие с новой ценой", func(lang string) *bot.SendMessageParams {
+			return recurringPriceConsentNotification(tm, lang, amount+amount/10)
+		}},
+		{"Автопродление: успешно", func(lang string) *bot.SendMessageParams { return recurringSuccessNotification(tm, lang, 0, amount) }},
+		{"Автопродление: успешно с баланса", func(lang string) *bot.SendMessageParams {
+			return recurringSuccessNotification(tm, lang, amount/2, amount-amount/2)
+		}},
+		{"Автопродление: ошибка оплаты", func(lang string) *bot.SendMessageParams {
+			return recurringDisabledNotification(tm, lang, "recurring_failed")
+		}},
+		{"Автопродление: разрешение отозвано", func(lang string) *bot.SendMessageParams {
+			return recurringDisabledNotification(tm, lang, "recurring_permission_revoked")
+		}},
+		{"Winback предложение", func(lang string) *bot.SendMessageParams {
+			return winbackOfferNotification(tm, lang, config.GetWinbackPrice(), config.GetWinbackDevices(), config.GetWinbackValidHours())
+		}},
+		{"Израсходован трафик", func(lang string) *bot.SendMessageParams { return trafficThresholdNotification(tm, lang, 80) }},
+	}
+}
+
 // AdminTestNotificationsCallback показывает меню тестирования уведомлений
-func (h Handler) AdminTestNotificationsCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h AdminHandlers) AdminTestNotificationsCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 			CallbackQueryID: update.CallbackQuery.ID,
@@ -41,6 +95,9 @@ func (h Handler) AdminTestNotificationsCallback(ctx context.Context, b *bot.Bot,
 			{
 				{Text: "📵 Тест: Неактивный триал", CallbackData: "admin_test_inactive_trial"},
 			},
+			{
+				{Text: "📨 Образцы всех уведомлений", CallbackData: "admin_test_previews"},
+			},
 			{
 				{Text: "🔙 Назад", CallbackData: "admin_back"},
 			},
@@ -53,6 +110,8 @@ func (h Handler) AdminTestNotificationsCallback(ctx context.Context, b *bot.Bot,
 		"• Создали аккаунт > 1 часа назад\n" +
 		"• Ещё не подключались (firstConnectedAt = null)\n" +
 		"• Не получали это уведомление ранее\n\n" +
+		"<b>Образцы всех уведомлений:</b>\n" +
+		"Пришлёт вам каждое уведомление с примерными данными на всех языках бота\n\n" +
 		"<b>Winback:</b>\n" +
 		"Теперь обрабатывается автоматически через вебхук Remnawave (user.expired_24_hours_ago)\n\n" +
 		"⚠️ Это реальная отправка уведомлений!"
@@ -65,7 +124,7 @@ func (h Handler) AdminTestNotificationsCallback(ctx context.Context, b *bot.Bot,
 		ReplyMarkup: keyboard,
 	})
 	if err != nil {
-		slog.Error("Error editing message", "error", err)
+		slog.ErrorContext(ctx, "Error editing message", "error", err)
 	}
 
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
@@ -74,7 +133,7 @@ func (h Handler) AdminTestNotificationsCallback(ctx context.Context, b *bot.Bot,
 }
 
 // AdminTestInactiveTrialCallback запускает тест уведомлений о неактивности триала
-func (h Handler) AdminTestInactiveTrialCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h AdminHandlers) AdminTestInactiveTrialCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 			CallbackQueryID: update.CallbackQuery.ID,
@@ -110,16 +169,16 @@ func (h Handler) AdminTestInactiveTrialCallback(ctx context.Context, b *bot.Bot,
 	}
 
 	start := time.Now()
-	err := notificationTester.ProcessTrialInactiveNotifications()
+	err := notificationTester.ProcessTrialInactiveNotifications(ctx)
 	duration := time.Since(start)
 
 	var resultText string
 	if err != nil {
 		resultText = fmt.Sprintf("❌ Ошибка: %v\n\nВремя: %v", err, duration)
-		slog.Error("Test inactive trial notifications failed", "error", err)
+		slog.ErrorContext(ctx, "Test inactive trial notifications failed", "error", err)
 	} else {
 		resultText = fmt.Sprintf("✅ Проверка завершена!\n\nВремя: %v\n\nПроверьте логи для деталей.", duration)
-		slog.Info("Test inactive trial notifications completed", "duration", duration)
+		slog.InfoContext(ctx, "Test inactive trial notifications completed", "duration", duration)
 	}
 
 	keyboard := &models.InlineKeyboardMarkup{
@@ -139,8 +198,60 @@ func (h Handler) AdminTestInactiveTrialCallback(ctx context.Context, b *bot.Bot,
 	})
 }
 
+// AdminTestPreviewsCallback присылает админу образцы всех уведомлений на каждом языке бота
+func (h AdminHandlers) AdminTestPreviewsCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            "Отправляю образцы...",
+	})
+
+	chatID := update.CallbackQuery.Message.Message.Chat.ID
+	languages := []string{config.DefaultLanguage()}
+	if lister, ok := h.translation.(languageLister); ok {
+		languages = lister.Languages()
+	}
+	previews := append(webhookNotificationPreviews(h.translation), notificationPreviews...)
+
+	sent := 0
+	var failed []string
+	for _, lang := range languages {
+		for _, preview := range previews {
+			params := preview.Render(lang)
+			params.ChatID = chatID
+			params.Text = fmt.Sprintf("🧪 <b>%s</b> [%s]\n\n", utils.EscapeHTML(preview.Title), lang) + params.Text
+			if _, err := b.SendMessage(ctx, params); err != nil {
+				slog.WarnContext(ctx, "Failed to send notification preview", "title", preview.Title, "lang", lang, "error", err)
+				failed = append(failed, fmt.Sprintf("%s [%s]: %s", preview.Title, lang, err))
+				continue
+			}
+			sent++
+
+			// Задержка между сообщениями, чтобы не упереться в лимит Telegram
+			if err := utils.Sleep(ctx, 35*time.Millisecond); err != nil {
+				return
+			}
+		}
+	}
+
+	resultText := fmt.Sprintf("✅ Отправлено образцов: %d (языки: %s)", sent, strings.Join(languages, ", "))
+	if len(failed) > 0 {
+		resultText += "\n\n❌ Не отправлены:\n• " + utils.EscapeHTML(strings.Join(failed, "\n• "))
+	}
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      resultText,
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: "🔙 Назад", CallbackData: "admin_test_notifications"}},
+		}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending notification previews report", "error", err)
+	}
+}
+
 // AdminTestWinbackCallback - deprecated, winback теперь через вебхук
-func (h Handler) AdminTestWinbackCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h AdminHandlers) AdminTestWinbackCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 		Text:            "Winback теперь через вебхук Remnawave",
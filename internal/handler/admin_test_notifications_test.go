@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// multiLangTranslator fakeTranslator со списком языков
+type multiLangTranslator struct {
+	fakeTranslator
+}
+
+func (multiLangTranslator) Languages() []string {
+	return []string{"en", "ru"}
+}
+
+func TestAdminTestPreviewsCallback(t *testing.T) {
+	saved := notificationPreviews
+	t.Cleanup(func() { notificationPreviews = saved })
+	notificationPreviews = nil
+	RegisterNotificationPreviews(NotificationPreview{
+		Title: "Образец <пакета>",
+		Render: func(lang string) *bot.SendMessageParams {
+			return &bot.SendMessageParams{Text: "sample_" + lang, ParseMode: models.ParseModeHTML}
+		},
+	})
+
+	b, tg := newTestBot(t)
+	h := NewAdminHandlers(multiLangTranslator{}, newFakeCache(), nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	update := &models.Update{CallbackQuery: &models.CallbackQuery{
+		ID:      "cb",
+		Data:    "admin_test_previews",
+		From:    models.User{ID: 42},
+		Message: models.MaybeInaccessibleMessage{Message: &models.Message{ID: 99, Chat: models.Chat{ID: 42}}},
+	}}
+
+	h.AdminTestPreviewsCallback(context.Background(), b, update)
+
+	previews := len(webhookNotificationPreviews(fakeTranslator{})) + 1
+	sent := tg.called("sendMessage")
+	if len(sent) != 2*previews+1 {
+		t.Fatalf("Expected %d previews in 2 languages and a report, got %d messages", 2*previews, len(sent))
+	}
+	for _, lang := range []string{"en", "ru"} {
+		found := false
+		for _, c := range sent {
+			if strings.Contains(c.params["text"], "Образец &lt;пакета&gt;</b> ["+lang+"]") && strings.HasSuffix(c.params["text"], "sample_"+lang) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected registered preview in %s", lang)
+		}
+	}
+	for _, c := range sent[:len(sent)-1] {
+		if c.params["chat_id"] != "42" || c.params["parse_mode"] != "HTML" {
+			t.Errorf("Expected HTML preview to admin chat, got %+v", c.params)
+		}
+	}
+	if report := sent[len(sent)-1].params["text"]; !strings.Contains(report, fmt.Sprintf("Отправлено образцов: %d", 2*previews)) || !strings.Contains(report, "en, ru") {
+		t.Errorf("Unexpected report %q", report)
+	}
+}
+
+func TestWebhookNotificationPreviewsRender(t *testing.T) {
+	for _, preview := range webhookNotificationPreviews(fakeTranslator{}) {
+		params := preview.Render("en")
+		if params.Text == "" || params.ParseMode != "HTML" || params.ReplyMarkup == nil && !strings.HasPrefix(params.Text, "recurring_success") {
+			t.Errorf("%s: unexpected message %+v", preview.Title, params)
+		}
+	}
+}
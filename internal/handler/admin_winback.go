@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// AdminWinbackCallback показывает статистику winback кампаний: сколько предложений отправлено,
+// сколько закончилось покупкой и сколько клиентов отказались от предложений
+func (h AdminHandlers) AdminWinbackCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	msg := update.CallbackQuery.Message.Message
+
+	stats, err := h.winbackRepository.CampaignStats(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading winback stats", "error", err)
+		return
+	}
+
+	err = editLongMessage(ctx, b, &bot.EditMessageTextParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		Text:      formatWinbackStats(stats),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: "🔄 Обновить", CallbackData: "admin_winback"}},
+			{{Text: "🔙 Назад", CallbackData: "admin_back"}},
+		}},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing winback stats message", "error", err)
+	}
+}
+
+func formatWinbackStats(stats []database.WinbackCampaignStats) string {
+	var sb strings.Builder
+	sb.WriteString("📣 <b>Winback кампании</b>\n")
+	if len(stats) == 0 {
+		sb.WriteString("\nПредложения ещё не отправлялись")
+		return sb.String()
+	}
+
+	for _, st := range stats {
+		conversion := 0.0
+		if st.Sent > 0 {
+			conversion = float64(st.Converted) * 100 / float64(st.Sent)
+		}
+		sb.WriteString(fmt.Sprintf("\n<b>%s</b>\n", utils.EscapeHTML(st.Campaign)))
+		sb.WriteString(fmt.Sprintf("отправлено: %d, купили: %d (%.1f%%), отказались: %d\n",
+			st.Sent, st.Converted, conversion, st.OptedOut))
+	}
+	return sb.String()
+}
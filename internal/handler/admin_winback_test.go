@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+func TestFormatWinbackStats(t *testing.T) {
+	text := formatWinbackStats([]database.WinbackCampaignStats{
+		{Campaign: "paid_7d", Sent: 40, Converted: 6, OptedOut: 2},
+		{Campaign: "paid_30d", Sent: 0},
+	})
+	for _, want := range []string{
+		"<b>paid_7d</b>",
+		"отправлено: 40, купили: 6 (15.0%), отказались: 2",
+		"отправлено: 0, купили: 0 (0.0%), отказались: 0",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in:\n%s", want, text)
+		}
+	}
+
+	if empty := formatWinbackStats(nil); !strings.Contains(empty, "ещё не отправлялись") {
+		t.Errorf("Expected empty state, got %q", empty)
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/jackc/pgx/v4"
+
+	"remnawave-tg-shop-bot/utils"
+)
+
+// AddBalanceCommandHandler обрабатывает команду админа /add_balance <telegram_id> <сумма>:
+// зачисляет рубли на внутренний баланс клиента (например, компенсация). Баланс расходуется на автопродление
+func (h AdminHandlers) AddBalanceCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	telegramID, amount, err := parseAddBalanceArgs(update.Message.Text)
+	if err != nil {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Использование: <code>/add_balance &lt;telegram_id&gt; &lt;сумма&gt;</code>")
+		return
+	}
+
+	balance, err := h.customerRepository.CreditBalanceByTelegramID(ctx, telegramID, amount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Пользователь не найден")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error crediting balance", "error", err)
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Не удалось пополнить баланс")
+		return
+	}
+
+	slog.InfoContext(ctx, "Balance credited by admin", "amount", amount, "balance", balance)
+	h.sendAdminText(ctx, b, update.Message.Chat.ID,
+		fmt.Sprintf("✅ Зачислено %d ₽ пользователю <code>%d</code>\n\nБаланс: %d ₽", amount, telegramID, balance))
+}
+
+func parseAddBalanceArgs(text string) (int64, int, error) {
+	args := strings.Fields(text)
+	if len(args) != 3 {
+		return 0, 0, errors.New("expected telegram id and amount")
+	}
+	telegramID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	amount, err := strconv.Atoi(args[2])
+	if err != nil {
+		return 0, 0, err
+	}
+	if amount <= 0 {
+		return 0, 0, errors.New("amount must be positive")
+	}
+	return telegramID, amount, nil
+}
+
+func (h base) sendAdminText(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	_, err := utils.SendLongMessage(ctx, b, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending admin message", "error", err)
+	}
+}
@@ -0,0 +1,16 @@
+package handler
+
+import "testing"
+
+func TestParseAddBalanceArgs(t *testing.T) {
+	telegramID, amount, err := parseAddBalanceArgs("/add_balance 123456 300")
+	if err != nil || telegramID != 123456 || amount != 300 {
+		t.Errorf("parseAddBalanceArgs() = %d, %d, %v", telegramID, amount, err)
+	}
+
+	for _, text := range []string{"/add_balance", "/add_balance 123456", "/add_balance abc 300", "/add_balance 123456 -5", "/add_balance 123456 0"} {
+		if _, _, err := parseAddBalanceArgs(text); err == nil {
+			t.Errorf("parseAddBalanceArgs(%q) expected error", text)
+		}
+	}
+}
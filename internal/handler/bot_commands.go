@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+// botCommandLanguages языки, для которых задаётся меню команд. Остальные видят меню на языке по умолчанию
+var botCommandLanguages = []string{"ru", "en"}
+
+// builtinCommand встроенная команда бота. Админские команды видны в меню только в чате админа,
+// enabled nil — команда доступна всегда
+type builtinCommand struct {
+	Command       string
+	DescriptionRu string
+	DescriptionEn string
+	Admin         bool
+	enabled       func() bool
+}
+
+// builtinCommands все встроенные команды бота. Новую команду достаточно добавить сюда: она появится в меню
+// команд Telegram, а админ не сможет перекрыть её своей командой
+var builtinCommands = []builtinCommand{
+	{Command: "start", DescriptionRu: "Начать работу с ботом", DescriptionEn: "Start using the bot"},
+	{Command: "connect", DescriptionRu: "Подключиться", DescriptionEn: "Connect"},
+	{Command: "receipts", DescriptionRu: "Чеки об оплате", DescriptionEn: "Payment receipts"},
+	{Command: "privacy", DescriptionRu: "Мои данные", DescriptionEn: "My data"},
+	{Command: "timezone", DescriptionRu: "Часовой пояс уведомлений", DescriptionEn: "Notification time zone"},
+	{Command: "notifications", DescriptionRu: "Настройки уведомлений", DescriptionEn: "Notification settings"},
+
+	{Command: "admin", DescriptionRu: "Панель администратора", Admin: true},
+	{Command: "user", DescriptionRu: "Карточка пользователя: /user <id>", Admin: true},
+	{Command: "add_balance", DescriptionRu: "Пополнить баланс: /add_balance <id> <сумма>", Admin: true},
+	{Command: "grant_trial", DescriptionRu: "Разрешить ещё один триал: /grant_trial <id>", Admin: true},
+	{Command: "rotate", DescriptionRu: "Сменить ссылку подписки: /rotate <id>", Admin: true},
+	{Command: "tag", DescriptionRu: "Добавить тег: /tag <id> <тег>", Admin: true},
+	{Command: "untag", DescriptionRu: "Снять тег: /untag <id> <тег>", Admin: true},
+	{Command: "note", DescriptionRu: "Заметка поддержки: /note <id> [текст]", Admin: true},
+	{Command: "survey", DescriptionRu: "Опрос после обращения: /survey <id>", Admin: true, enabled: config.IsSurveyEnabled},
+	{Command: "export", DescriptionRu: "Выгрузить данные клиента: /export <id>", Admin: true},
+	{Command: "erase", DescriptionRu: "Удалить данные клиента: /erase <id>", Admin: true},
+	{Command: "sync", DescriptionRu: "Синхронизировать клиентов с панелью", Admin: true},
+	{Command: "reconcile", DescriptionRu: "Сверка платежей: /reconcile [ГГГГ-ММ-ДД]", Admin: true},
+	{Command: "recurring_prices", DescriptionRu: "Новые цены автопродления: /recurring_prices [apply]", Admin: true},
+	{Command: "webhook_retry", DescriptionRu: "Повторить необработанные события панели", Admin: true, enabled: func() bool {
+		return config.GetRemnawaveWebhookSecret() != ""
+	}},
+	{Command: "db_stats", DescriptionRu: "Тяжёлые запросы к БД", Admin: true},
+	{Command: "language", DescriptionRu: "Полнота переводов по языкам", Admin: true},
+	{Command: "reload_config", DescriptionRu: "Перечитать .env без перезапуска", Admin: true},
+	{Command: "bot_mode", DescriptionRu: "Режим получения обновлений: /bot_mode [polling|webhook]", Admin: true},
+	{Command: "time_travel", DescriptionRu: "Сдвиг часов бота: /time_travel <72h|3d|reset>", Admin: true, enabled: config.IsTimeTravelEnabled},
+}
+
+func (c builtinCommand) description(lang string) string {
+	if isEnglish(lang) && c.DescriptionEn != "" || c.DescriptionRu == "" {
+		return c.DescriptionEn
+	}
+	return c.DescriptionRu
+}
+
+// SetBotCommands задаёт меню команд бота: встроенные команды и команды, добавленные админом, на каждом языке
+// из botCommandLanguages и на языке по умолчанию для остальных. В чате админа меню дополняется админскими командами
+func SetBotCommands(ctx context.Context, b *bot.Bot, custom []database.CustomCommand) error {
+	for _, lang := range botCommandLanguages {
+		_, err := b.SetMyCommands(ctx, &bot.SetMyCommandsParams{
+			Commands:     botMenuCommands(lang, custom, false),
+			LanguageCode: lang,
+		})
+		if err != nil {
+			return fmt.Errorf("set %s bot commands: %w", lang, err)
+		}
+	}
+
+	_, err := b.SetMyCommands(ctx, &bot.SetMyCommandsParams{
+		Commands: botMenuCommands(config.DefaultLanguage(), custom, false),
+	})
+	if err != nil {
+		return fmt.Errorf("set default bot commands: %w", err)
+	}
+
+	if adminID := config.GetAdminTelegramId(); adminID != 0 {
+		_, err := b.SetMyCommands(ctx, &bot.SetMyCommandsParams{
+			Commands: botMenuCommands("ru", custom, true),
+			Scope:    &models.BotCommandScopeChat{ChatID: adminID},
+		})
+		if err != nil {
+			return fmt.Errorf("set admin bot commands: %w", err)
+		}
+	}
+	return nil
+}
+
+// botMenuCommands меню команд на языке lang: встроенные, добавленные админом, затем при admin — админские
+func botMenuCommands(lang string, custom []database.CustomCommand, admin bool) []models.BotCommand {
+	var commands, adminCommands []models.BotCommand
+	for _, command := range builtinCommands {
+		if command.enabled != nil && !command.enabled() {
+			continue
+		}
+		item := models.BotCommand{Command: command.Command, Description: command.description(lang)}
+		if command.Admin {
+			adminCommands = append(adminCommands, item)
+		} else {
+			commands = append(commands, item)
+		}
+	}
+	for _, command := range custom {
+		if description := customCommandDescription(command, lang); description != "" {
+			commands = append(commands, models.BotCommand{Command: command.Command, Description: description})
+		}
+	}
+	if admin {
+		commands = append(commands, adminCommands...)
+	}
+	return commands
+}
@@ -18,8 +18,46 @@ const (
 	CallbackRecurringDisable       = "recurring_disable"
 	CallbackDeletePaymentMethod    = "delete_payment_method"
 	CallbackSavedPaymentMethods    = "saved_payment_methods"
+	CallbackSelectPaymentMethod    = "select_payment_method"
+	CallbackRecurringPriceAccept   = "recurring_price_accept"
+	CallbackRecurringConfirm       = "recurring_confirm"
 	CallbackPromoTariff            = "promo_tariff"
 	CallbackCloseMessage           = "close_message"
+	CallbackBack                   = "back"
+	CallbackTosAccept              = "tos_accept"
+	CallbackTosDecline             = "tos_decline"
+	CallbackSandboxPayment         = "sandbox_payment"
+	CallbackTeam                   = "team"
+	CallbackTeamSeats              = "team_seats"
+	CallbackTeamMonths             = "team_months"
+	CallbackTeamPayment            = "team_pay"
+	CallbackTeamView               = "team_view"
+	CallbackTeamAskRevoke          = "team_ask_revoke"
+	CallbackTeamDoRevoke           = "team_do_revoke"
+	CallbackGift                   = "gift"
+	CallbackGiftTariff             = "gift_tariff"
+	CallbackGiftPayment            = "gift_pay"
+	CallbackWinbackOptOut          = "winback_opt_out"
+	CallbackReceipt                = "receipt"
+	CallbackRotateLink             = "rotate_link"
+	CallbackRotateLinkConfirm      = "rotate_link_do"
+	CallbackPrivacyExport          = "privacy_export"
+	CallbackPrivacyErase           = "privacy_erase"
+	CallbackPrivacyEraseConfirm    = "privacy_erase_do"
+	CallbackTimezone               = "timezone"
+	CallbackTimezoneSet            = "timezone_set"
+	CallbackReceiptEmail           = "email"
+	CallbackReceiptEmailClear      = "email_clear"
+	CallbackFaq                    = "faq"
+	CallbackFaqCategory            = "faq_cat"
+	CallbackFaqEntry               = "faq_entry"
+	CallbackFaqSearch              = "faq_search"
+	CallbackServerStatus           = "server_status"
+	CallbackNotifications          = "notifications"
+	CallbackNotificationsToggle    = "notifications_toggle"
+	CallbackSurveyRate             = "survey_rate"
+	CallbackSurveyComment          = "survey_comment"
+	CallbackNotificationFeedback   = "notif_feedback"
 )
 
 // MaxCallbackDataLength - максимальная длина callback_data в Telegram (64 байта)
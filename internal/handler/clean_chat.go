@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+// menuMessageTTL сколько секунд помнить последнее меню: Telegram даёт боту удалять только сообщения не старше 48 часов
+const menuMessageTTL = 48 * 60 * 60
+
+// sendMenu отправляет новое меню. В режиме чистого чата предыдущее меню бота в этом чате удаляется,
+// а новое запоминается, чтобы удалить его при следующем показе
+func (h base) sendMenu(ctx context.Context, b *bot.Bot, params *bot.SendMessageParams) (*models.Message, error) {
+	msg, err := b.SendMessage(ctx, params)
+	if err != nil {
+		return msg, err
+	}
+	if chatID, ok := params.ChatID.(int64); ok {
+		h.rememberMenu(ctx, b, chatID, msg.ID)
+	}
+	return msg, nil
+}
+
+// rememberMenu в режиме чистого чата удаляет предыдущее меню бота в чате и запоминает новое
+func (h base) rememberMenu(ctx context.Context, b *bot.Bot, chatID int64, messageID int) {
+	if !cleanChatAllows(config.CleanChatMode(), config.CleanChatMenus) {
+		return
+	}
+	key := fmt.Sprintf("menu_msg_%d", chatID)
+	if prev, found := h.cache.GetString(key); found {
+		if prevID, err := strconv.Atoi(prev); err == nil && prevID != messageID {
+			h.deleteChatMessage(ctx, b, chatID, prevID)
+		}
+	}
+	h.cache.SetString(key, strconv.Itoa(messageID), menuMessageTTL)
+}
+
+// deleteUserMessage удаляет сообщение пользователя, если режим чистого чата не ниже required:
+// введённые промокоды удаляются начиная с menus, команды — только в full
+func (h base) deleteUserMessage(ctx context.Context, b *bot.Bot, msg *models.Message, required string) {
+	if msg == nil || !cleanChatAllows(config.CleanChatMode(), required) {
+		return
+	}
+	h.deleteChatMessage(ctx, b, msg.Chat.ID, msg.ID)
+}
+
+// deleteChatMessage удаляет сообщение без повторов. Ошибка не критична: сообщение могли удалить вручную
+// или оно старше 48 часов
+func (h base) deleteChatMessage(ctx context.Context, b *bot.Bot, chatID int64, messageID int) {
+	if _, err := b.DeleteMessage(ctx, &bot.DeleteMessageParams{ChatID: chatID, MessageID: messageID}); err != nil {
+		slog.DebugContext(ctx, "Clean chat: failed to delete message", "error", err)
+	}
+}
+
+// cleanChatAllows проверяет, что режим mode не ниже required (off < menus < full)
+func cleanChatAllows(mode, required string) bool {
+	return cleanChatLevel(mode) >= cleanChatLevel(required) && cleanChatLevel(mode) > 0
+}
+
+func cleanChatLevel(mode string) int {
+	switch mode {
+	case config.CleanChatMenus:
+		return 1
+	case config.CleanChatFull:
+		return 2
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+func TestCleanChatAllows(t *testing.T) {
+	tests := []struct {
+		mode     string
+		required string
+		want     bool
+	}{
+		{config.CleanChatOff, config.CleanChatMenus, false},
+		{config.CleanChatOff, config.CleanChatOff, false},
+		{"", config.CleanChatMenus, false},
+		{config.CleanChatMenus, config.CleanChatMenus, true},
+		{config.CleanChatMenus, config.CleanChatFull, false},
+		{config.CleanChatFull, config.CleanChatMenus, true},
+		{config.CleanChatFull, config.CleanChatFull, true},
+	}
+
+	for _, tt := range tests {
+		if got := cleanChatAllows(tt.mode, tt.required); got != tt.want {
+			t.Errorf("cleanChatAllows(%q, %q) = %v, want %v", tt.mode, tt.required, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// ReloadConfigCommandHandler /reload_config: перечитывает .env и применяет ссылки, цены и параметры winback без перезапуска
+func ReloadConfigCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	result, err := config.Reload()
+	if err != nil {
+		slog.WarnContext(ctx, "Config reload rejected", "error", err)
+	} else {
+		slog.InfoContext(ctx, "Config reloaded", "applied", result.Applied, "restartRequired", result.RestartRequired)
+	}
+
+	_, err = utils.SendLongMessage(ctx, b, &bot.SendMessageParams{
+		ChatID:    update.Message.Chat.ID,
+		Text:      FormatConfigReload(result, err),
+		ParseMode: models.ParseModeHTML,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending config reload report", "error", err)
+	}
+}
+
+// FormatConfigReload отчёт о перезагрузке конфигурации для админа
+func FormatConfigReload(result *config.ReloadResult, err error) string {
+	var sb strings.Builder
+	sb.WriteString("⚙️ <b>Перезагрузка конфигурации</b>\n\n")
+
+	if err != nil {
+		sb.WriteString("❌ Новая конфигурация не применена, работает прежняя:\n")
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			for _, issue := range validationErr.Issues {
+				sb.WriteString("• " + utils.EscapeHTML(issue) + "\n")
+			}
+		} else {
+			sb.WriteString(utils.EscapeHTML(err.Error()) + "\n")
+		}
+		return sb.String()
+	}
+
+	if len(result.Applied) == 0 {
+		sb.WriteString("Применяемые на лету настройки не изменились\n")
+	} else {
+		sb.WriteString("✅ Применено:\n")
+		for _, key := range result.Applied {
+			sb.WriteString("• <code>" + key + "</code>\n")
+		}
+	}
+	if len(result.RestartRequired) > 0 {
+		sb.WriteString("\n⚠️ Изменены, но применятся только после перезапуска:\n")
+		for _, key := range result.RestartRequired {
+			sb.WriteString("• <code>" + utils.EscapeHTML(key) + "</code>\n")
+		}
+	}
+	return sb.String()
+}
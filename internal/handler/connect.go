@@ -12,44 +12,58 @@ import (
 	"log/slog"
 
 	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/locale"
 	"remnawave-tg-shop-bot/internal/translation"
 	"remnawave-tg-shop-bot/utils"
 )
 
-func (h Handler) ConnectCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h ProfileHandlers) ConnectCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	customer, err := h.customerRepository.FindByTelegramId(ctx, update.Message.Chat.ID)
 	if err != nil {
-		slog.Error("Error finding customer", "error", err)
+		slog.ErrorContext(ctx, "Error finding customer", "error", err)
 		return
 	}
 	if customer == nil {
-		slog.Error("customer not exist", "telegramId", utils.MaskHalfInt64(update.Message.Chat.ID), "error", err)
+		slog.ErrorContext(ctx, "customer not exist", "telegramId", utils.MaskHalfInt64(update.Message.Chat.ID), "error", err)
 		return
 	}
 
 	langCode := update.Message.From.LanguageCode
+	defer h.deleteUserMessage(ctx, b, update.Message, config.CleanChatFull)
+	link := subscriptionPageURL(ctx, h.purchaseRepository, customer, langCode)
+
+	var markup [][]models.InlineKeyboardButton
+	if row := h.rotateLinkButtonRow(customer, langCode); row != nil {
+		markup = append(markup, row)
+	}
+	if row := h.timezoneButtonRow(langCode); row != nil {
+		markup = append(markup, row)
+	}
+	if row := h.receiptEmailButtonRow(langCode); row != nil {
+		markup = append(markup, row)
+	}
+	markup = append(markup, h.notificationsButtonRow(langCode))
+	markup = append(markup, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack}})
 
 	isDisabled := true
-	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+	_, err = h.sendMenu(ctx, b, &bot.SendMessageParams{
 		ChatID:    update.Message.Chat.ID,
-		Text:      buildConnectText(customer, langCode),
+		Text:      buildConnectText(customer, link, langCode),
 		ParseMode: models.ParseModeHTML,
 		LinkPreviewOptions: &models.LinkPreviewOptions{
 			IsDisabled: &isDisabled,
 		},
 		ReplyMarkup: models.InlineKeyboardMarkup{
-			InlineKeyboard: [][]models.InlineKeyboardButton{
-				{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart}},
-			},
+			InlineKeyboard: markup,
 		},
 	})
 
 	if err != nil {
-		slog.Error("Error sending connect message", "error", err)
+		slog.ErrorContext(ctx, "Error sending connect message", "error", err)
 	}
 }
 
-func (h Handler) ConnectCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h ProfileHandlers) ConnectCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -58,33 +72,46 @@ func (h Handler) ConnectCallbackHandler(ctx context.Context, b *bot.Bot, update
 
 	customer, err := h.customerRepository.FindByTelegramId(ctx, callback.Chat.ID)
 	if err != nil {
-		slog.Error("Error finding customer", "error", err)
+		slog.ErrorContext(ctx, "Error finding customer", "error", err)
 		return
 	}
 	if customer == nil {
-		slog.Error("customer not exist", "telegramId", utils.MaskHalfInt64(callback.Chat.ID), "error", err)
+		slog.ErrorContext(ctx, "customer not exist", "telegramId", utils.MaskHalfInt64(callback.Chat.ID), "error", err)
 		return
 	}
 
 	langCode := update.CallbackQuery.From.LanguageCode
+	// Возврат из экрана email отменяет ожидание ввода адреса
+	h.cache.Delete(receiptEmailStateKey(update.CallbackQuery.From.ID))
+	link := subscriptionPageURL(ctx, h.purchaseRepository, customer, langCode)
 
 	var markup [][]models.InlineKeyboardButton
 	if config.IsWepAppLinkEnabled() {
-		if customer.SubscriptionLink != nil && customer.ExpireAt.After(time.Now()) {
+		if link != "" && customer.ExpireAt.After(time.Now()) {
 			markup = append(markup, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "connect_button"),
 				WebApp: &models.WebAppInfo{
-					URL: *customer.SubscriptionLink,
+					URL: link,
 				}}})
 		}
 	}
-	markup = append(markup, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart}})
+	if row := h.rotateLinkButtonRow(customer, langCode); row != nil {
+		markup = append(markup, row)
+	}
+	if row := h.timezoneButtonRow(langCode); row != nil {
+		markup = append(markup, row)
+	}
+	if row := h.receiptEmailButtonRow(langCode); row != nil {
+		markup = append(markup, row)
+	}
+	markup = append(markup, h.notificationsButtonRow(langCode))
+	markup = append(markup, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack}})
 
 	isDisabled := true
 	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
 		ChatID:    callback.Chat.ID,
 		MessageID: callback.ID,
 		ParseMode: models.ParseModeHTML,
-		Text:      buildConnectText(customer, langCode),
+		Text:      buildConnectText(customer, link, langCode),
 		LinkPreviewOptions: &models.LinkPreviewOptions{
 			IsDisabled: &isDisabled,
 		},
@@ -94,11 +121,12 @@ func (h Handler) ConnectCallbackHandler(ctx context.Context, b *bot.Bot, update
 	})
 
 	if err != nil {
-		slog.Error("Error sending connect message", "error", err)
+		slog.ErrorContext(ctx, "Error sending connect message", "error", err)
 	}
 }
 
-func buildConnectText(customer *database.Customer, langCode string) string {
+// buildConnectText текст экрана подключения. link — ссылка на подписку для показа клиенту (см. subscriptionPageURL)
+func buildConnectText(customer *database.Customer, link, langCode string) string {
 	var info strings.Builder
 
 	tm := translation.GetInstance()
@@ -107,16 +135,16 @@ func buildConnectText(customer *database.Customer, langCode string) string {
 		currentTime := time.Now()
 
 		if currentTime.Before(*customer.ExpireAt) {
-			formattedDate := customer.ExpireAt.Format("02.01.2006 15:04")
+			formattedDate := locale.FormatDateTime(langCode, *customer.ExpireAt)
 
 			subscriptionActiveText := tm.GetText(langCode, "subscription_active")
 			info.WriteString(fmt.Sprintf(subscriptionActiveText, formattedDate))
 
-			if customer.SubscriptionLink != nil && *customer.SubscriptionLink != "" {
+			if link != "" {
 				if config.IsWepAppLinkEnabled() {
 				} else {
 					subscriptionLinkText := tm.GetText(langCode, "subscription_link")
-					info.WriteString(fmt.Sprintf(subscriptionLinkText, *customer.SubscriptionLink))
+					info.WriteString(fmt.Sprintf(subscriptionLinkText, link))
 				}
 			}
 		} else {
@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/broadcast"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+const (
+	// customCommandSeparator строка, разделяющая русский ответ, английский ответ и кнопки при вводе команды в админке
+	customCommandSeparator = "---"
+	// maxCaptionLength лимит подписи к медиа в Telegram (в UTF-16 символах)
+	maxCaptionLength = 1024
+)
+
+var customCommandName = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// customCommandStore источник включённых команд для реестра
+type customCommandStore interface {
+	ListActive(ctx context.Context) ([]database.CustomCommand, error)
+}
+
+// CustomCommands реестр команд, добавленных админом. Команды перечитываются из БД задачей custom_commands_refresh
+// и сразу после изменения в админке, меню команд бота обновляется, когда меняется набор команд
+type CustomCommands struct {
+	store    customCommandStore
+	mu       sync.RWMutex
+	commands map[string]database.CustomCommand
+	menu     string
+}
+
+func NewCustomCommands(store customCommandStore) *CustomCommands {
+	return &CustomCommands{store: store}
+}
+
+// Reload перечитывает команды из БД и обновляет меню команд бота, если набор команд изменился
+func (c *CustomCommands) Reload(ctx context.Context, b *bot.Bot) error {
+	list, err := c.store.ListActive(ctx)
+	if err != nil {
+		return fmt.Errorf("load custom commands: %w", err)
+	}
+
+	commands := make(map[string]database.CustomCommand, len(list))
+	for _, command := range list {
+		commands[command.Command] = command
+	}
+	menu := customCommandsMenuKey(list)
+
+	c.mu.Lock()
+	c.commands = commands
+	changed := c.menu != menu
+	c.menu = menu
+	c.mu.Unlock()
+
+	if changed {
+		return SetBotCommands(ctx, b, list)
+	}
+	return nil
+}
+
+// customCommandsMenuKey строка, по которой видно, изменилось ли меню команд
+func customCommandsMenuKey(list []database.CustomCommand) string {
+	var sb strings.Builder
+	for _, command := range list {
+		sb.WriteString(command.Command + "\x00" + command.DescriptionRu + "\x00" + command.DescriptionEn + "\n")
+	}
+	return sb.String()
+}
+
+// Match подходит ли сообщение под включённую команду, добавленную админом
+func (c *CustomCommands) Match(update *models.Update) bool {
+	_, ok := c.find(update)
+	return ok
+}
+
+func (c *CustomCommands) find(update *models.Update) (database.CustomCommand, bool) {
+	if update.Message == nil {
+		return database.CustomCommand{}, false
+	}
+	name, ok := parseCommandName(update.Message.Text)
+	if !ok {
+		return database.CustomCommand{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	command, ok := c.commands[name]
+	return command, ok
+}
+
+// CommandHandler отвечает на команду, добавленную админом, текстом на языке клиента, медиа и кнопками
+func (c *CustomCommands) CommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	command, ok := c.find(update)
+	if !ok {
+		return
+	}
+	err := sendCustomCommand(ctx, b, update.Message.Chat.ID, command, update.Message.From.LanguageCode)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending custom command response", "command", command.Command, "error", err)
+	}
+}
+
+// parseCommandName имя команды из текста сообщения: "/speed", "/speed@bot" и "/speed аргументы" дают speed
+func parseCommandName(text string) (string, bool) {
+	if !strings.HasPrefix(text, "/") {
+		return "", false
+	}
+	name, _, _ := strings.Cut(strings.TrimPrefix(strings.Fields(text)[0], "/"), "@")
+	return strings.ToLower(name), name != ""
+}
+
+// isEnglish язык клиента английский, в том числе с регионом (en-US, en-GB)
+func isEnglish(lang string) bool {
+	base, _, _ := strings.Cut(strings.ToLower(lang), "-")
+	return base == "en"
+}
+
+func customCommandText(command database.CustomCommand, lang string) string {
+	if isEnglish(lang) && command.TextEn != "" || command.TextRu == "" {
+		return command.TextEn
+	}
+	return command.TextRu
+}
+
+func customCommandDescription(command database.CustomCommand, lang string) string {
+	if isEnglish(lang) && command.DescriptionEn != "" || command.DescriptionRu == "" {
+		return command.DescriptionEn
+	}
+	return command.DescriptionRu
+}
+
+func sendCustomCommand(ctx context.Context, b *bot.Bot, chatID int64, command database.CustomCommand, lang string) error {
+	text := customCommandText(command, lang)
+	var keyboard models.ReplyMarkup
+	if buttons, _ := parseCommandButtons(command.Buttons); len(buttons) > 0 {
+		keyboard = &models.InlineKeyboardMarkup{InlineKeyboard: buttons}
+	}
+
+	var err error
+	media := &models.InputFileString{Data: command.MediaFileID}
+	switch command.MediaType {
+	case broadcast.MediaTypePhoto:
+		_, err = b.SendPhoto(ctx, &bot.SendPhotoParams{ChatID: chatID, Photo: media, Caption: text, ParseMode: models.ParseModeHTML, ReplyMarkup: keyboard})
+	case broadcast.MediaTypeGIF:
+		_, err = b.SendAnimation(ctx, &bot.SendAnimationParams{ChatID: chatID, Animation: media, Caption: text, ParseMode: models.ParseModeHTML, ReplyMarkup: keyboard})
+	case broadcast.MediaTypeVideo:
+		_, err = b.SendVideo(ctx, &bot.SendVideoParams{ChatID: chatID, Video: media, Caption: text, ParseMode: models.ParseModeHTML, ReplyMarkup: keyboard})
+	default:
+		_, err = utils.SendLongMessage(ctx, b, &bot.SendMessageParams{
+			ChatID:      chatID,
+			Text:        text,
+			ParseMode:   models.ParseModeHTML,
+			ReplyMarkup: keyboard,
+		})
+	}
+	return err
+}
+
+// parseCommandButtons разбирает кнопки-ссылки, по одной на строку: "Текст | https://..."
+func parseCommandButtons(text string) ([][]models.InlineKeyboardButton, error) {
+	var rows [][]models.InlineKeyboardButton
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		label, url, ok := strings.Cut(line, "|")
+		label, url = strings.TrimSpace(label), strings.TrimSpace(url)
+		if !ok || label == "" {
+			return nil, fmt.Errorf("кнопка «%s»: нужен формат «Текст | ссылка»", line)
+		}
+		if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "tg://") {
+			return nil, fmt.Errorf("кнопка «%s»: ссылка должна начинаться с https://, http:// или tg://", label)
+		}
+		rows = append(rows, []models.InlineKeyboardButton{{Text: label, URL: url}})
+	}
+	return rows, nil
+}
+
+// parseCustomCommand разбирает команду, которую админ прислал в панели:
+//
+//	/speed Проверка скорости | Speed test
+//	Ответ на русском
+//	---
+//	Answer in English
+//	---
+//	Кнопка | https://example.com
+//
+// Английский ответ и кнопки необязательны, английское описание — тоже
+func parseCustomCommand(text string) (database.CustomCommand, error) {
+	sections := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n"+customCommandSeparator+"\n")
+	if len(sections) > 3 {
+		return database.CustomCommand{}, errors.New("слишком много разделителей " + customCommandSeparator + ": ожидаются русский ответ, английский ответ и кнопки")
+	}
+
+	header, body, _ := strings.Cut(strings.TrimSpace(sections[0]), "\n")
+	name, descriptions, _ := strings.Cut(strings.TrimSpace(header), " ")
+	name = strings.ToLower(strings.TrimPrefix(name, "/"))
+	if !strings.HasPrefix(header, "/") || !customCommandName.MatchString(name) {
+		return database.CustomCommand{}, errors.New("первая строка должна начинаться с команды: латиница, цифры и _, до 32 символов, например /speed")
+	}
+	// Встроенные команды сопоставляются по префиксу: /users попал бы в обработчик /user
+	for _, builtin := range builtinCommands {
+		if strings.HasPrefix(name, builtin.Command) {
+			return database.CustomCommand{}, fmt.Errorf("/%s пересекается со встроенной командой /%s", name, builtin.Command)
+		}
+	}
+
+	descriptionRu, descriptionEn, _ := strings.Cut(descriptions, "|")
+	command := database.CustomCommand{
+		Command:       name,
+		DescriptionRu: strings.TrimSpace(descriptionRu),
+		DescriptionEn: strings.TrimSpace(descriptionEn),
+		TextRu:        strings.TrimSpace(body),
+	}
+	if command.DescriptionRu == "" {
+		return database.CustomCommand{}, errors.New("после команды укажите описание для меню, например /speed Проверка скорости | Speed test")
+	}
+	if len(sections) > 1 {
+		command.TextEn = strings.TrimSpace(sections[1])
+	}
+	if len(sections) > 2 {
+		command.Buttons = strings.TrimSpace(sections[2])
+		if _, err := parseCommandButtons(command.Buttons); err != nil {
+			return database.CustomCommand{}, err
+		}
+	}
+	return command, nil
+}
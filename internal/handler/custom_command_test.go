@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+func TestParseCustomCommand(t *testing.T) {
+	command, err := parseCustomCommand("/Speed Проверка скорости | Speed test\nЗамерьте скорость\n---\nMeasure your speed\n---\nОткрыть | https://speedtest.net\nКанал | tg://resolve?domain=news")
+	if err != nil {
+		t.Fatalf("parseCustomCommand returned error: %v", err)
+	}
+	want := database.CustomCommand{
+		Command:       "speed",
+		DescriptionRu: "Проверка скорости",
+		DescriptionEn: "Speed test",
+		TextRu:        "Замерьте скорость",
+		TextEn:        "Measure your speed",
+		Buttons:       "Открыть | https://speedtest.net\nКанал | tg://resolve?domain=news",
+	}
+	if command != want {
+		t.Fatalf("unexpected command %+v", command)
+	}
+}
+
+func TestParseCustomCommandOnlyRussian(t *testing.T) {
+	command, err := parseCustomCommand("/faq Частые вопросы\nОтветы на вопросы\n\nвторой абзац")
+	if err != nil {
+		t.Fatalf("parseCustomCommand returned error: %v", err)
+	}
+	if command.Command != "faq" || command.DescriptionEn != "" || command.TextRu != "Ответы на вопросы\n\nвторой абзац" || command.TextEn != "" {
+		t.Fatalf("unexpected command %+v", command)
+	}
+}
+
+func TestParseCustomCommandErrors(t *testing.T) {
+	cases := map[string]string{
+		"без слеша":          "speed Проверка\nответ",
+		"кириллица":          "/скорость Проверка\nответ",
+		"без описания":       "/speed\nответ",
+		"встроенная":         "/start Начать\nответ",
+		"префикс встроенной": "/users Пользователи\nответ",
+		"кнопка без ссылки":  "/speed Проверка\nответ\n---\n\n---\nОткрыть",
+		"кнопка не ссылка":   "/speed Проверка\nответ\n---\n\n---\nОткрыть | speedtest.net",
+		"лишний раздел":      "/speed Проверка\nru\n---\nen\n---\nКнопка | https://a.b\n---\nещё",
+	}
+	for name, text := range cases {
+		if _, err := parseCustomCommand(text); err == nil {
+			t.Errorf("%s: expected error for %q", name, text)
+		}
+	}
+}
+
+func TestParseCommandName(t *testing.T) {
+	cases := map[string]string{
+		"/speed":          "speed",
+		"/Speed@shop_bot": "speed",
+		"/faq как быть":   "faq",
+	}
+	for text, want := range cases {
+		if got, ok := parseCommandName(text); !ok || got != want {
+			t.Errorf("parseCommandName(%q) = %q, %v, want %q", text, got, ok, want)
+		}
+	}
+	for _, text := range []string{"", "speed", "/", "/ speed"} {
+		if got, ok := parseCommandName(text); ok {
+			t.Errorf("parseCommandName(%q) = %q, expected no command", text, got)
+		}
+	}
+}
+
+func TestCustomCommandsMatch(t *testing.T) {
+	c := NewCustomCommands(nil)
+	c.commands = map[string]database.CustomCommand{"speed": {Command: "speed"}}
+
+	if !c.Match(&models.Update{Message: &models.Message{Text: "/speed@shop_bot"}}) {
+		t.Error("expected /speed@shop_bot to match")
+	}
+	if c.Match(&models.Update{Message: &models.Message{Text: "/apps"}}) {
+		t.Error("unknown command must not match")
+	}
+	if c.Match(&models.Update{CallbackQuery: &models.CallbackQuery{Data: "/speed"}}) {
+		t.Error("callback must not match")
+	}
+}
+
+func TestCustomCommandTextFallsBack(t *testing.T) {
+	both := database.CustomCommand{TextRu: "привет", TextEn: "hello"}
+	onlyRu := database.CustomCommand{TextRu: "привет"}
+	onlyEn := database.CustomCommand{TextEn: "hello"}
+
+	cases := []struct {
+		command database.CustomCommand
+		lang    string
+		want    string
+	}{
+		{both, "en", "hello"},
+		{both, "en-GB", "hello"},
+		{both, "ru", "привет"},
+		{both, "de", "привет"},
+		{onlyRu, "en", "привет"},
+		{onlyEn, "ru", "hello"},
+	}
+	for _, c := range cases {
+		if got := customCommandText(c.command, c.lang); got != c.want {
+			t.Errorf("customCommandText(%+v, %q) = %q, want %q", c.command, c.lang, got, c.want)
+		}
+	}
+}
+
+func TestBotMenuCommands(t *testing.T) {
+	custom := []database.CustomCommand{
+		{Command: "faq", DescriptionRu: "Частые вопросы"},
+		{Command: "speed", DescriptionRu: "Проверка скорости", DescriptionEn: "Speed test"},
+	}
+
+	en := botMenuCommands("en", custom, false)
+	last := len(en) - 1
+	if en[0].Command != "start" || en[last-1].Description != "Частые вопросы" || en[last].Description != "Speed test" {
+		t.Fatalf("unexpected en menu %+v", en)
+	}
+	ru := botMenuCommands("ru", custom, false)
+	if ru[0].Description != "Начать работу с ботом" || ru[last].Description != "Проверка скорости" {
+		t.Fatalf("unexpected ru menu %+v", ru)
+	}
+	if slices.ContainsFunc(ru, func(c models.BotCommand) bool { return c.Command == "admin" }) {
+		t.Errorf("Admin commands must not be in the user menu: %+v", ru)
+	}
+
+	admin := botMenuCommands("ru", custom, true)
+	names := make([]string, 0, len(admin))
+	for _, c := range admin {
+		names = append(names, c.Command)
+	}
+	if !slices.Contains(names, "admin") || !slices.Contains(names, "sync") || slices.Index(names, "speed") > slices.Index(names, "admin") {
+		t.Errorf("Expected admin commands after user and custom ones, got %v", names)
+	}
+	if slices.Contains(names, "time_travel") {
+		t.Errorf("Disabled commands must not be in the menu, got %v", names)
+	}
+}
+
+func TestBuiltinCommandsMatchRegisteredHandlers(t *testing.T) {
+	source, err := os.ReadFile("../../cmd/app/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registered := regexp.MustCompile(`HandlerTypeMessageText, "/([a-z_]+)"`).FindAllStringSubmatch(string(source), -1)
+	if len(registered) == 0 {
+		t.Fatal("No command handlers found in main.go")
+	}
+	for _, m := range registered {
+		if !slices.ContainsFunc(builtinCommands, func(c builtinCommand) bool { return c.Command == m[1] }) {
+			t.Errorf("/%s is registered in main.go but missing from builtinCommands", m[1])
+		}
+	}
+}
+
+func TestFormatCustomCommandsEscapesDescription(t *testing.T) {
+	text := formatCustomCommands([]database.CustomCommand{{Command: "faq", DescriptionRu: "<FAQ>", IsActive: true}})
+	if !strings.Contains(text, "✅ /faq — &lt;FAQ&gt;") {
+		t.Fatalf("unexpected text %q", text)
+	}
+	if !strings.Contains(formatCustomCommands(nil), "Команд пока нет") {
+		t.Fatal("empty list must be explained")
+	}
+}
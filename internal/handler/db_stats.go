@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// dbStatsLimit количество самых тяжёлых запросов в ответе /db_stats
+const dbStatsLimit = 10
+
+// DBStatsCommandHandler возвращает обработчик команды админа /db_stats:
+// самые тяжёлые запросы к БД по суммарному времени выполнения
+func DBStatsCommandHandler(metrics *database.QueryMetrics) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		_, err := utils.SendLongMessage(ctx, b, &bot.SendMessageParams{
+			ChatID:    update.Message.Chat.ID,
+			Text:      formatDBStats(metrics.Snapshot()),
+			ParseMode: models.ParseModeHTML,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error sending db stats", "error", err)
+		}
+	}
+}
+
+func formatDBStats(stats []database.QueryStat) string {
+	if len(stats) == 0 {
+		return "🗄 <b>Статистика запросов к БД</b>\n\nЗапросов пока не было"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🗄 <b>Статистика запросов к БД</b>\n")
+	for i, s := range stats {
+		if i == dbStatsLimit {
+			break
+		}
+		sb.WriteString(fmt.Sprintf("\n%d. <code>%s</code>\n", i+1, utils.EscapeHTML(s.Query)))
+		sb.WriteString(fmt.Sprintf("   вызовов: %d, ошибок: %d, ср: %s, макс: %s, всего: %s\n",
+			s.Count, s.Errors, formatQueryDuration(s.Avg()), formatQueryDuration(s.Max), formatQueryDuration(s.Total)))
+	}
+	return sb.String()
+}
+
+func formatQueryDuration(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond / 10).String()
+}
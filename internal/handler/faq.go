@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+const (
+	// faqSearchLimit сколько вопросов показывать в результатах поиска
+	faqSearchLimit = 10
+	// faqButtonLength сколько символов вопроса помещается на кнопку
+	faqButtonLength = 60
+)
+
+// FaqCallbackHandler показывает категории FAQ и кнопку поиска
+func (h FaqHandlers) FaqCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	h.cache.Delete(fmt.Sprintf("faq_search_%d", update.CallbackQuery.From.ID))
+
+	langCode := update.CallbackQuery.From.LanguageCode
+	categories, err := h.faq.Categories(ctx, h.faqLanguage(ctx, langCode))
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading faq categories", "error", err)
+	}
+
+	var keyboard [][]models.InlineKeyboardButton
+	for _, category := range categories {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{{
+			Text:         fmt.Sprintf("%s (%d)", category.Name, category.Count),
+			CallbackData: fmt.Sprintf("%s?id=%d", CallbackFaqCategory, category.EntryID),
+		}})
+	}
+	if len(categories) > 0 {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "faq_search_button"), CallbackData: CallbackFaqSearch}})
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack}})
+
+	text := h.translation.GetText(langCode, "faq_title")
+	if len(categories) == 0 {
+		text = h.translation.GetText(langCode, "faq_empty")
+	}
+	h.editFaqMessage(ctx, b, update.CallbackQuery.Message.Message, text, keyboard)
+}
+
+// FaqCategoryCallbackHandler показывает вопросы категории. Категория задаётся любым своим вопросом (faq_cat?id=)
+func (h FaqHandlers) FaqCategoryCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	langCode := update.CallbackQuery.From.LanguageCode
+	entry := h.callbackFaqEntry(ctx, update.CallbackQuery.Data)
+	if entry == nil {
+		h.editFaqMessage(ctx, b, update.CallbackQuery.Message.Message, h.translation.GetText(langCode, "faq_not_found"), faqBackKeyboard(h.translation, langCode))
+		return
+	}
+
+	entries, err := h.faq.ListCategory(ctx, entry.Language, entry.Category)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading faq category", "category", entry.Category, "error", err)
+	}
+	keyboard := faqEntriesKeyboard(entries)
+	keyboard = append(keyboard, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack}})
+
+	text := h.translation.GetTextTemplate(langCode, "faq_category", map[string]interface{}{"category": entry.Category})
+	h.editFaqMessage(ctx, b, update.CallbackQuery.Message.Message, text, keyboard)
+}
+
+// FaqEntryCallbackHandler показывает ответ на вопрос
+func (h FaqHandlers) FaqEntryCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	langCode := update.CallbackQuery.From.LanguageCode
+	entry := h.callbackFaqEntry(ctx, update.CallbackQuery.Data)
+	if entry == nil {
+		h.editFaqMessage(ctx, b, update.CallbackQuery.Message.Message, h.translation.GetText(langCode, "faq_not_found"), faqBackKeyboard(h.translation, langCode))
+		return
+	}
+
+	text := fmt.Sprintf("❓ <b>%s</b>\n\n%s", utils.EscapeHTML(entry.Question), entry.Answer)
+	h.editFaqMessage(ctx, b, update.CallbackQuery.Message.Message, text, faqBackKeyboard(h.translation, langCode))
+}
+
+// FaqSearchCallbackHandler ждёт от клиента текст для поиска по вопросам и ответам
+func (h FaqHandlers) FaqSearchCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	langCode := update.CallbackQuery.From.LanguageCode
+	h.cache.SetString(fmt.Sprintf("faq_search_%d", update.CallbackQuery.From.ID), "waiting_query", 600)
+
+	h.editFaqMessage(ctx, b, update.CallbackQuery.Message.Message, h.translation.GetText(langCode, "faq_search_prompt"), [][]models.InlineKeyboardButton{
+		{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackFaq}},
+	})
+}
+
+// FaqSearchInputHandler ищет вопросы по тексту клиента и присылает найденные кнопками
+func (h FaqHandlers) FaqSearchInputHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	langCode := update.Message.From.LanguageCode
+	h.cache.Delete(fmt.Sprintf("faq_search_%d", update.Message.From.ID))
+
+	query := strings.TrimSpace(update.Message.Text)
+	entries, err := h.faq.Search(ctx, h.faqLanguage(ctx, langCode), query, faqSearchLimit)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error searching faq", "error", err)
+	}
+
+	key := "faq_search_results"
+	if len(entries) == 0 {
+		key = "faq_search_not_found"
+	}
+	keyboard := faqEntriesKeyboard(entries)
+	keyboard = append(keyboard,
+		[]models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "faq_search_again_button"), CallbackData: CallbackFaqSearch}},
+		[]models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "faq_button"), CallbackData: CallbackFaq}},
+	)
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      update.Message.Chat.ID,
+		Text:        h.translation.GetTextTemplate(langCode, key, map[string]interface{}{"query": query}),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending faq search results", "error", err)
+	}
+}
+
+// faqLanguage язык вопросов для клиента: его язык, если на нём есть вопросы, иначе язык бота по умолчанию
+func (h FaqHandlers) faqLanguage(ctx context.Context, langCode string) string {
+	if langCode == config.DefaultLanguage() {
+		return langCode
+	}
+	categories, err := h.faq.Categories(ctx, langCode)
+	if err != nil || len(categories) == 0 {
+		return config.DefaultLanguage()
+	}
+	return langCode
+}
+
+// callbackFaqEntry включённый вопрос из callback data вида "<callback>?id=<id>", nil если его нет
+func (h FaqHandlers) callbackFaqEntry(ctx context.Context, data string) *database.FaqEntry {
+	id, err := strconv.ParseInt(parseCallbackData(data)["id"], 10, 64)
+	if err != nil {
+		return nil
+	}
+	entry, err := h.faq.FindByID(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding faq entry", "id", id, "error", err)
+		return nil
+	}
+	if entry == nil || !entry.IsActive {
+		return nil
+	}
+	return entry
+}
+
+func (h FaqHandlers) editFaqMessage(ctx context.Context, b *bot.Bot, msg *models.Message, text string, keyboard [][]models.InlineKeyboardButton) {
+	err := editLongMessage(ctx, b, &bot.EditMessageTextParams{
+		ChatID:      msg.Chat.ID,
+		MessageID:   msg.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing faq message", "error", err)
+	}
+}
+
+// faqEntriesKeyboard кнопка на каждый вопрос
+func faqEntriesKeyboard(entries []database.FaqEntry) [][]models.InlineKeyboardButton {
+	var keyboard [][]models.InlineKeyboardButton
+	for _, entry := range entries {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{{
+			Text:         truncateRunes(entry.Question, faqButtonLength),
+			CallbackData: fmt.Sprintf("%s?id=%d", CallbackFaqEntry, entry.ID),
+		}})
+	}
+	return keyboard
+}
+
+func faqBackKeyboard(tm translator, langCode string) [][]models.InlineKeyboardButton {
+	return [][]models.InlineKeyboardButton{
+		{{Text: tm.GetText(langCode, "back_button"), CallbackData: CallbackBack}},
+	}
+}
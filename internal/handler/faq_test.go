@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+type fakeFaqStore struct {
+	faqStore
+	categories map[string][]database.FaqCategory
+}
+
+func (f fakeFaqStore) Categories(_ context.Context, lang string) ([]database.FaqCategory, error) {
+	return f.categories[lang], nil
+}
+
+func TestParseFaqEntry(t *testing.T) {
+	entry, err := parseFaqEntry("RU | Подключение\nКак   подключиться\nна iPhone?\n---\nУстановите <b>приложение</b>.\n\nГотово")
+	if err != nil {
+		t.Fatalf("parseFaqEntry returned error: %v", err)
+	}
+	want := database.FaqEntry{
+		Language: "ru",
+		Category: "Подключение",
+		Question: "Как подключиться на iPhone?",
+		Answer:   "Установите <b>приложение</b>.\n\nГотово",
+	}
+	if entry.Language != want.Language || entry.Category != want.Category || entry.Question != want.Question || entry.Answer != want.Answer {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+}
+
+func TestParseFaqEntryErrors(t *testing.T) {
+	cases := map[string]string{
+		"без ответа":    "ru | Оплата\nКак оплатить?",
+		"пустой ответ":  "ru | Оплата\nКак оплатить?\n---\n ",
+		"без языка":     "Оплата\nКак оплатить?\n---\nКартой",
+		"чужой язык":    "de | Zahlung\nWie?\n---\nKarte",
+		"без категории": "ru |\nКак оплатить?\n---\nКартой",
+		"без вопроса":   "ru | Оплата\n---\nКартой",
+	}
+	for name, text := range cases {
+		if _, err := parseFaqEntry(text); err == nil {
+			t.Errorf("%s: expected error for %q", name, text)
+		}
+	}
+}
+
+func TestFaqLanguageFallsBackToDefault(t *testing.T) {
+	h := FaqHandlers{faq: fakeFaqStore{categories: map[string][]database.FaqCategory{
+		"ru": {{Name: "Оплата", EntryID: 1, Count: 2}},
+	}}}
+
+	if got := h.faqLanguage(context.Background(), "en"); got != "ru" {
+		t.Errorf("expected fallback to default language, got %q", got)
+	}
+
+	h.faq = fakeFaqStore{categories: map[string][]database.FaqCategory{
+		"en": {{Name: "Payment", EntryID: 3, Count: 1}},
+	}}
+	if got := h.faqLanguage(context.Background(), "en"); got != "en" {
+		t.Errorf("expected client language, got %q", got)
+	}
+}
+
+func TestFaqEntriesKeyboard(t *testing.T) {
+	keyboard := faqEntriesKeyboard([]database.FaqEntry{
+		{ID: 7, Question: "Как оплатить?"},
+		{ID: 8, Question: strings.Repeat("я", 100)},
+	})
+	if len(keyboard) != 2 || keyboard[0][0].CallbackData != "faq_entry?id=7" {
+		t.Fatalf("unexpected keyboard %+v", keyboard)
+	}
+	if got := []rune(keyboard[1][0].Text); len(got) != faqButtonLength+1 {
+		t.Errorf("long question must be truncated, got %d runes", len(got))
+	}
+}
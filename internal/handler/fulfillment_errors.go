@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/payment"
+	"remnawave-tg-shop-bot/utils"
+)
+
+const (
+	adminFulfillmentErrors = "admin_fulfillment_errors"
+	// adminFulfillmentRetry повтор выдачи: admin_fulfillment_retry?id=<ID записи>
+	adminFulfillmentRetry = "admin_fulfillment_retry"
+	// fulfillmentErrorsLimit сколько нерешённых ошибок показывать в админке
+	fulfillmentErrorsLimit = 10
+)
+
+// AdminFulfillmentErrorsCallback показывает оплаченные покупки, выдача которых завершилась ошибкой
+func (h FulfillmentErrorHandlers) AdminFulfillmentErrorsCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	h.showFulfillmentErrors(ctx, b, update.CallbackQuery.Message.Message)
+}
+
+// AdminFulfillmentRetryCallback повторяет выдачу покупки из журнала ошибок и обновляет список
+func (h FulfillmentErrorHandlers) AdminFulfillmentRetryCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	id, err := strconv.ParseInt(parseCallbackData(update.CallbackQuery.Data)["id"], 10, 64)
+	if err != nil {
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+		return
+	}
+
+	entry, retryErr := h.retrier.RetryFailedFulfillment(ctx, id)
+	var text string
+	switch {
+	case errors.Is(retryErr, payment.ErrFailedFulfillmentNotFound):
+		text = "Запись не найдена"
+	case entry == nil:
+		slog.ErrorContext(ctx, "Error retrying fulfillment", "id", id, "error", retryErr)
+		text = "❌ Не удалось повторить выдачу"
+	case entry.Status == database.FailedFulfillmentStatusResolved:
+		text = fmt.Sprintf("✅ Покупка #%d выдана", entry.PurchaseID)
+	default:
+		text = truncateRunes(fmt.Sprintf("❌ Покупка #%d не выдана: %s", entry.PurchaseID, entry.LastError), 190)
+	}
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            text,
+		ShowAlert:       true,
+	})
+
+	h.showFulfillmentErrors(ctx, b, update.CallbackQuery.Message.Message)
+}
+
+func (h FulfillmentErrorHandlers) showFulfillmentErrors(ctx context.Context, b *bot.Bot, msg *models.Message) {
+	if msg == nil {
+		return
+	}
+	entries, err := h.failed.FindOpen(ctx, fulfillmentErrorsLimit)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading failed fulfillments", "error", err)
+		return
+	}
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		Text:      formatFulfillmentErrors(entries),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: fulfillmentErrorsKeyboard(entries),
+		},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing fulfillment errors message", "error", err)
+	}
+}
+
+// formatFulfillmentErrors форматирует список нерешённых ошибок выдачи
+func formatFulfillmentErrors(entries []database.FailedFulfillment) string {
+	var sb strings.Builder
+	sb.WriteString("⚠️ <b>Ошибки выдачи покупок</b>\n")
+	if len(entries) == 0 {
+		sb.WriteString("\nОшибок нет: все оплаченные покупки выданы")
+		return sb.String()
+	}
+	sb.WriteString("Временные ошибки повторяются автоматически, остальные — кнопкой «повторить»\n")
+
+	for _, e := range entries {
+		status := "⛔ нужен повтор вручную"
+		if e.Status == database.FailedFulfillmentStatusPending && e.NextAttemptAt != nil {
+			status = "⏳ автоповтор в " + e.NextAttemptAt.In(config.Timezone()).Format("15:04")
+		}
+		sb.WriteString(utils.FormatHTML("\n<b>#%d</b> покупка #%d · %s · %s\nпопыток: %d, %s\n<code>%s</code>\n",
+			e.ID, e.PurchaseID, e.Source, e.CreatedAt.In(config.Timezone()).Format("02.01 15:04"),
+			e.Attempts, status, truncateRunes(e.LastError, 200)))
+	}
+	return sb.String()
+}
+
+// fulfillmentErrorsKeyboard кнопки повтора для каждой ошибки
+func fulfillmentErrorsKeyboard(entries []database.FailedFulfillment) [][]models.InlineKeyboardButton {
+	keyboard := make([][]models.InlineKeyboardButton, 0, len(entries)+2)
+	for _, e := range entries {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{{
+			Text:         fmt.Sprintf("🔁 Повторить #%d", e.ID),
+			CallbackData: fmt.Sprintf("%s?id=%d", adminFulfillmentRetry, e.ID),
+		}})
+	}
+	return append(keyboard,
+		[]models.InlineKeyboardButton{{Text: "🔄 Обновить", CallbackData: adminFulfillmentErrors}},
+		[]models.InlineKeyboardButton{{Text: "🔙 Назад", CallbackData: "admin_back"}},
+	)
+}
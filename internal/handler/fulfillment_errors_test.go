@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+type mockFailedFulfillments struct {
+	entries []database.FailedFulfillment
+	retried []int64
+	result  *database.FailedFulfillment
+	err     error
+}
+
+func (m *mockFailedFulfillments) FindOpen(context.Context, int) ([]database.FailedFulfillment, error) {
+	return m.entries, nil
+}
+
+func (m *mockFailedFulfillments) RetryFailedFulfillment(_ context.Context, id int64) (*database.FailedFulfillment, error) {
+	m.retried = append(m.retried, id)
+	return m.result, m.err
+}
+
+func TestFormatFulfillmentErrors(t *testing.T) {
+	if got := formatFulfillmentErrors(nil); !strings.Contains(got, "Ошибок нет") {
+		t.Errorf("Expected empty state, got %q", got)
+	}
+
+	next := time.Now().Add(time.Minute)
+	got := formatFulfillmentErrors([]database.FailedFulfillment{
+		{ID: 3, PurchaseID: 71, Source: "yookasa", Status: database.FailedFulfillmentStatusPending, Attempts: 2, LastError: "timeout", NextAttemptAt: &next},
+		{ID: 4, PurchaseID: 72, Source: "telegram", Status: database.FailedFulfillmentStatusFailed, Attempts: 1, LastError: "customer <nil> not found"},
+	})
+	for _, want := range []string{"покупка #71", "автоповтор", "покупка #72", "повтор вручную", "customer &lt;nil&gt; not found"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in %q", want, got)
+		}
+	}
+}
+
+func TestAdminFulfillmentRetryCallback(t *testing.T) {
+	b, tg := newTestBot(t)
+	store := &mockFailedFulfillments{
+		result: &database.FailedFulfillment{ID: 3, PurchaseID: 71, Status: database.FailedFulfillmentStatusFailed, LastError: "customer not found"},
+		err:    errors.New("customer not found"),
+	}
+	h := NewFulfillmentErrorHandlers(fakeTranslator{}, newFakeCache(), store, store)
+
+	h.AdminFulfillmentRetryCallback(context.Background(), b, paymentCallbackUpdate(adminFulfillmentRetry+"?id=3"))
+
+	if len(store.retried) != 1 || store.retried[0] != 3 {
+		t.Fatalf("Expected entry 3 to be retried, got %v", store.retried)
+	}
+	answers := tg.called("answerCallbackQuery")
+	if len(answers) != 1 || !strings.Contains(answers[0].params["text"], "не выдана: customer not found") {
+		t.Errorf("Expected retry error in the answer, got %v", answers)
+	}
+	if len(tg.called("editMessageText")) != 1 {
+		t.Errorf("Expected the list to be refreshed")
+	}
+}
@@ -0,0 +1,332 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/activity"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/payment"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// giftRecipientTTL сколько секунд действует выбранный получатель подарка
+const giftRecipientTTL = 1800
+
+// telegramUsernamePattern имя пользователя Telegram: 5–32 символа, латиница, цифры и подчёркивание
+var telegramUsernamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{4,31}$`)
+
+// giftStateKey состояние ожидания @username получателя подарка
+func giftStateKey(userID int64) string {
+	return fmt.Sprintf("gift_state_%d", userID)
+}
+
+// giftRecipientKey Telegram ID получателя, выбранного плательщиком
+func giftRecipientKey(userID int64) string {
+	return fmt.Sprintf("gift_to_%d", userID)
+}
+
+// parseGiftRecipient возвращает идентификатор чата для getChat: @username (в том числе из ссылки t.me) или числовой ID
+func parseGiftRecipient(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	for _, prefix := range []string{"https://t.me/", "http://t.me/", "t.me/", "@"} {
+		text = strings.TrimPrefix(text, prefix)
+	}
+	if id, err := strconv.ParseInt(text, 10, 64); err == nil && id > 0 {
+		return text, true
+	}
+	if !telegramUsernamePattern.MatchString(text) {
+		return "", false
+	}
+	return "@" + text, true
+}
+
+// canGift проверяет, что клиент может оплатить подарок звёздами: при REQUIRE_PAID_PURCHASE_FOR_STARS
+// нужна собственная оплаченная покупка
+func (h ProfileHandlers) canGift(ctx context.Context, customer *database.Customer) (bool, error) {
+	if !config.RequirePaidPurchaseForStars() {
+		return true, nil
+	}
+	purchase, err := h.purchaseRepository.FindLastPaidPurchaseByCustomer(ctx, customer.ID)
+	if err != nil {
+		return false, err
+	}
+	return purchase != nil, nil
+}
+
+// GiftCallbackHandler начинает оформление подарка: просит @username получателя
+func (h ProfileHandlers) GiftCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	if !config.IsGiftsEnabled() {
+		return
+	}
+
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	customer, err := h.customerRepository.FindByTelegramId(ctx, update.CallbackQuery.From.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for gift", "error", err)
+		return
+	}
+
+	textKey := "gift_enter_username"
+	allowed, err := h.canGift(ctx, customer)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error checking gift eligibility", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		return
+	}
+	if allowed {
+		h.cache.SetString(giftStateKey(update.CallbackQuery.From.ID), "waiting_username", 300)
+	} else {
+		textKey = "gift_requires_paid_purchase"
+	}
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    callback.Chat.ID,
+		MessageID: callback.ID,
+		Text:      h.translation.GetText(langCode, textKey),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart}},
+		}},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error sending gift prompt", "error", err)
+	}
+}
+
+// GiftRecipientInputHandler находит получателя подарка через getChat и показывает варианты подписки.
+// Получатель должен хотя бы раз запустить бота: иначе Telegram не отдаёт его чат, а подписку не к кому привязать
+func (h ProfileHandlers) GiftRecipientInputHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	userID := update.Message.From.ID
+	stateKey := giftStateKey(userID)
+	if state, found := h.cache.GetString(stateKey); !found || state != "waiting_username" {
+		return
+	}
+	h.cache.Delete(stateKey)
+	defer h.deleteUserMessage(ctx, b, update.Message, config.CleanChatMenus)
+
+	chatID := update.Message.Chat.ID
+	langCode := update.Message.From.LanguageCode
+	refuse := func(textKey string) {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: chatID,
+			Text:   h.translation.GetText(langCode, textKey),
+			ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+				{{Text: h.translation.GetText(langCode, "gift_retry_button"), CallbackData: CallbackGift}},
+				{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart}},
+			}},
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error sending gift recipient error", "error", err)
+		}
+	}
+
+	target, ok := parseGiftRecipient(update.Message.Text)
+	if !ok {
+		refuse("gift_username_invalid")
+		return
+	}
+	chat, err := b.GetChat(ctx, &bot.GetChatParams{ChatID: target})
+	if err != nil || chat.Type != models.ChatTypePrivate {
+		slog.InfoContext(ctx, "Gift recipient not resolved", "error", err)
+		refuse("gift_recipient_not_found")
+		return
+	}
+	if chat.ID == userID {
+		refuse("gift_recipient_self")
+		return
+	}
+	recipient, err := h.customerRepository.FindByTelegramId(ctx, chat.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding gift recipient", "error", err)
+		return
+	}
+	if recipient == nil {
+		refuse("gift_recipient_not_found")
+		return
+	}
+
+	h.cache.SetString(giftRecipientKey(userID), strconv.FormatInt(chat.ID, 10), giftRecipientTTL)
+
+	name := chat.FirstName
+	if chat.Username != "" {
+		name = "@" + chat.Username
+	}
+	tariffName := ""
+	if tariffs := config.GetTariffs(); len(tariffs) == 1 {
+		tariffName = tariffs[0].Name
+	}
+	_, err = h.sendMenu(ctx, b, &bot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        h.translation.GetTextTemplate(langCode, "gift_select_plan", map[string]interface{}{"name": name}),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: h.giftPlanKeyboard(langCode, tariffName)},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending gift plans", "error", err)
+	}
+}
+
+// giftPlanKeyboard кнопки выбора подарка: тарифы, если их несколько и тариф ещё не выбран, иначе сроки с ценой в звёздах
+func (h ProfileHandlers) giftPlanKeyboard(langCode, tariffName string) [][]models.InlineKeyboardButton {
+	var keyboard [][]models.InlineKeyboardButton
+	if tariffs := config.GetTariffs(); tariffName == "" && len(tariffs) > 1 {
+		for _, tariff := range tariffs {
+			if !tariff.AllowsPaymentMethod(string(database.InvoiceTypeTelegram)) {
+				continue
+			}
+			keyboard = append(keyboard, []models.InlineKeyboardButton{{
+				Text:         FormatTariffButtonText(tariff, langCode, h.translation),
+				CallbackData: SafeCallbackData(fmt.Sprintf("%s?n=%s", CallbackGiftTariff, tariff.Name)),
+			}})
+		}
+	} else {
+		var periodButtons []models.InlineKeyboardButton
+		for _, months := range config.SupportedMonths() {
+			amount, ok := periodPaymentAmount(database.InvoiceTypeTelegram, strconv.Itoa(months), tariffName)
+			if !ok || amount <= 0 {
+				continue
+			}
+			callbackData := fmt.Sprintf("%s?m=%d", CallbackGiftPayment, months)
+			if tariffName != "" {
+				callbackData += "&n=" + tariffName
+			}
+			periodButtons = append(periodButtons, models.InlineKeyboardButton{
+				Text:         h.translation.GetTextTemplate(langCode, "gift_period_button", map[string]interface{}{"months": months, "price": amount}),
+				CallbackData: SafeCallbackData(callbackData),
+			})
+		}
+		for i := 0; i < len(periodButtons); i += 2 {
+			keyboard = append(keyboard, periodButtons[i:min(i+2, len(periodButtons))])
+		}
+	}
+	return append(keyboard, []models.InlineKeyboardButton{
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart},
+	})
+}
+
+// GiftTariffCallbackHandler показывает сроки подарка для выбранного тарифа
+func (h ProfileHandlers) GiftTariffCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	tariff := config.GetTariffByName(parseCallbackData(update.CallbackQuery.Data)["n"])
+	if tariff == nil || !config.IsGiftsEnabled() {
+		slog.WarnContext(ctx, "Invalid gift tariff in callback", "data", update.CallbackQuery.Data)
+		return
+	}
+
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      callback.Chat.ID,
+		MessageID:   callback.ID,
+		Text:        h.translation.GetTextTemplate(langCode, "gift_select_period", map[string]interface{}{"tariff": tariff.Name}),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: h.giftPlanKeyboard(langCode, tariff.Name)},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending gift periods", "error", err)
+	}
+}
+
+// GiftPaymentCallbackHandler создаёт счёт в звёздах на подарок выбранному получателю.
+// Цена берётся из конфига, а получатель — из кеша, а не из callback data
+func (h ProfileHandlers) GiftPaymentCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	if !config.IsGiftsEnabled() {
+		return
+	}
+
+	callbackQuery := parseCallbackData(update.CallbackQuery.Data)
+	month, tariffName := callbackQuery["m"], callbackQuery["n"]
+	amount, ok := periodPaymentAmount(database.InvoiceTypeTelegram, month, tariffName)
+	period, _ := config.ParsePeriod(month)
+	if !ok || amount <= 0 {
+		slog.WarnContext(ctx, "Invalid gift plan in callback", "data", update.CallbackQuery.Data)
+		return
+	}
+
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	sendText := func(textKey string) {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: callback.Chat.ID,
+			Text:   h.translation.GetText(langCode, textKey),
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error sending gift refusal message", "error", err)
+		}
+	}
+
+	storedRecipient, _ := h.cache.GetString(giftRecipientKey(update.CallbackQuery.From.ID))
+	recipientID, err := strconv.ParseInt(storedRecipient, 10, 64)
+	if err != nil {
+		sendText("gift_session_expired")
+		return
+	}
+	payer, err := h.customerRepository.FindByTelegramId(ctx, update.CallbackQuery.From.ID)
+	if err != nil || payer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for gift purchase", "error", err)
+		return
+	}
+	if allowed, err := h.canGift(ctx, payer); err != nil || !allowed {
+		slog.WarnContext(ctx, "Gift purchase without access", "error", err, "customerId", utils.MaskHalfInt64(payer.ID))
+		return
+	}
+	recipient, err := h.customerRepository.FindByTelegramId(ctx, recipientID)
+	if err != nil || recipient == nil {
+		slog.ErrorContext(ctx, "Error finding gift recipient", "error", err)
+		sendText("gift_recipient_not_found")
+		return
+	}
+	activity.Record(ctx, payer.TelegramID, database.FunnelEventSelectedPayment)
+
+	// Без тарифов имя из callback data не сохраняется в покупке
+	var tariffNamePtr *string
+	if tariffName != "" && config.GetTariffByName(tariffName) != nil {
+		tariffNamePtr = &tariffName
+	}
+	ctxWithUsername := context.WithValue(ctx, "username", update.CallbackQuery.From.Username)
+	paymentURL, purchaseId, err := h.paymentService.CreateGiftPurchase(ctxWithUsername, float64(amount), period, payer, recipient, tariffNamePtr)
+	if errors.Is(err, payment.ErrPurchaseLimitExceeded) {
+		sendText("purchase_limit_exceeded")
+		return
+	}
+	if errors.Is(err, payment.ErrInvalidPaymentAmount) {
+		sendText("payment_method_unavailable")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error creating gift payment", "error", err)
+		return
+	}
+
+	message, err := b.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+		ChatID:    callback.Chat.ID,
+		MessageID: callback.ID,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{{
+			{Text: h.translation.GetText(langCode, "pay_button"), URL: paymentURL},
+			{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart},
+		}}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error updating gift payment message", "error", err)
+		return
+	}
+	h.paymentService.SavePurchaseMessage(ctx, purchaseId, message.ID)
+}
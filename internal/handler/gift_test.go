@@ -0,0 +1,272 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/payment"
+)
+
+// giftCustomers клиенты по Telegram ID: плательщик и получатель подарка
+type giftCustomers struct {
+	mockProfileCustomers
+	byTelegram map[int64]*database.Customer
+}
+
+func (m *giftCustomers) FindByTelegramId(_ context.Context, telegramID int64) (*database.Customer, error) {
+	return m.byTelegram[telegramID], nil
+}
+
+// lastPaidPurchase последняя оплаченная покупка плательщика
+type lastPaidPurchase struct {
+	purchase *database.Purchase
+}
+
+func (m lastPaidPurchase) FindLastPaidPurchaseByCustomer(context.Context, int64) (*database.Purchase, error) {
+	return m.purchase, nil
+}
+
+// giftCall параметры созданного счёта на подарок
+type giftCall struct {
+	amount    float64
+	period    config.Period
+	payer     *database.Customer
+	recipient *database.Customer
+	tariff    *string
+}
+
+// mockGiftPayments платёжный сервис профиля, записывает счета на подарки
+type mockGiftPayments struct {
+	gifts    []giftCall
+	messages map[int64]int
+}
+
+func (m *mockGiftPayments) ActivateTrial(context.Context, int64) (string, error) {
+	return "", nil
+}
+
+func (m *mockGiftPayments) CheckTrialEligibility(context.Context, *database.Customer) (payment.TrialEligibility, error) {
+	return payment.TrialEligibility{}, nil
+}
+
+func (m *mockGiftPayments) CreateTeamPurchase(context.Context, float64, int, int, *database.Customer, database.InvoiceType) (string, int64, error) {
+	return "", 0, nil
+}
+
+func (m *mockGiftPayments) CreateGiftPurchase(_ context.Context, amount float64, period config.Period, payer, recipient *database.Customer, tariffName *string) (string, int64, error) {
+	m.gifts = append(m.gifts, giftCall{amount: amount, period: period, payer: payer, recipient: recipient, tariff: tariffName})
+	return "https://t.me/$invoice", 501, nil
+}
+
+func (m *mockGiftPayments) SavePurchaseMessage(_ context.Context, purchaseID int64, messageID int) {
+	if m.messages == nil {
+		m.messages = map[int64]int{}
+	}
+	m.messages[purchaseID] = messageID
+}
+
+func (m *mockGiftPayments) SaveProviderPayment(context.Context, int64, string, string) {}
+
+func setGiftConfig(t *testing.T) {
+	t.Helper()
+	t.Cleanup(config.InitConfig)
+	t.Setenv("GIFTS_ENABLED", "true")
+	t.Setenv("TELEGRAM_STARS_ENABLED", "true")
+	t.Setenv("STARS_PRICE_1", "100")
+	t.Setenv("STARS_PRICE_3", "250")
+	t.Setenv("STARS_PRICE_6", "0")
+	t.Setenv("STARS_PRICE_12", "0")
+	config.InitConfig()
+}
+
+func newGiftHandlers(payments *mockGiftPayments, purchases lastPurchaseFinder) (ProfileHandlers, *fakeCache) {
+	cache := newFakeCache()
+	customers := &giftCustomers{byTelegram: map[int64]*database.Customer{
+		42: {ID: 1, TelegramID: 42, Language: "ru"},
+		77: {ID: 2, TelegramID: 77, Language: "en"},
+	}}
+	return ProfileHandlers{
+		base:               base{translation: fakeTranslator{}, cache: cache},
+		customerRepository: customers,
+		purchaseRepository: purchases,
+		paymentService:     payments,
+	}, cache
+}
+
+func giftTextUpdate(text string) *models.Update {
+	return &models.Update{Message: &models.Message{
+		ID:   3,
+		Text: text,
+		From: &models.User{ID: 42, LanguageCode: "ru"},
+		Chat: models.Chat{ID: 42},
+	}}
+}
+
+func TestParseGiftRecipient(t *testing.T) {
+	for input, want := range map[string]string{
+		"@friend_01":                          "@friend_01",
+		" friend_01 ":                         "@friend_01",
+		"https://t.me/friend_01":              "@friend_01",
+		"123456789":                           "123456789",
+		"@abc":                                "",
+		"@1friend":                            "",
+		"friend name":                         "",
+		"-100123":                             "",
+		"@friend_01_with_too_long_username_x": "",
+	} {
+		got, ok := parseGiftRecipient(input)
+		if got != want || ok != (want != "") {
+			t.Errorf("parseGiftRecipient(%q) = %q, %v, want %q", input, got, ok, want)
+		}
+	}
+}
+
+func TestGiftCallbackRequiresPaidPurchaseForStars(t *testing.T) {
+	setGiftConfig(t)
+	t.Setenv("REQUIRE_PAID_PURCHASE_FOR_STARS", "true")
+	config.InitConfig()
+
+	b, tg := newTestBot(t)
+	h, cache := newGiftHandlers(&mockGiftPayments{}, lastPaidPurchase{})
+
+	h.GiftCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackGift))
+
+	if _, waiting := cache.GetString(giftStateKey(42)); waiting {
+		t.Error("Expected no username prompt without a paid purchase")
+	}
+	edits := tg.called("editMessageText")
+	if len(edits) != 1 || edits[0].params["text"] != "gift_requires_paid_purchase" {
+		t.Fatalf("Expected paid purchase requirement, got %v", edits)
+	}
+}
+
+// Получатель находится по @username, счёт создаётся на получателя, а ссылка на оплату — в чате плательщика
+func TestGiftFlowCreatesInvoiceForRecipient(t *testing.T) {
+	setGiftConfig(t)
+	b, tg := newTestBot(t)
+	tg.results = map[string]any{"getChat": models.ChatFullInfo{ID: 77, Type: models.ChatTypePrivate, Username: "friend_01"}}
+	payments := &mockGiftPayments{}
+	h, cache := newGiftHandlers(payments, lastPaidPurchase{})
+	ctx := context.Background()
+
+	h.GiftCallbackHandler(ctx, b, paymentCallbackUpdate(CallbackGift))
+	if state, _ := cache.GetString(giftStateKey(42)); state != "waiting_username" {
+		t.Fatalf("Expected username prompt, got state %q", state)
+	}
+
+	h.GiftRecipientInputHandler(ctx, b, giftTextUpdate("@friend_01"))
+	chats := tg.called("getChat")
+	if len(chats) != 1 || chats[0].params["chat_id"] != "@friend_01" {
+		t.Fatalf("Expected recipient to be resolved via getChat, got %v", chats)
+	}
+	if recipient, _ := cache.GetString(giftRecipientKey(42)); recipient != "77" {
+		t.Fatalf("Expected recipient 77 to be remembered, got %q", recipient)
+	}
+	sent := tg.called("sendMessage")
+	if len(sent) != 1 || sent[0].params["text"] != "gift_select_plan" {
+		t.Fatalf("Expected gift plans, got %v", sent)
+	}
+	markup := sent[0].params["reply_markup"]
+	if !strings.Contains(markup, CallbackGiftPayment+"?m=1") || !strings.Contains(markup, CallbackGiftPayment+"?m=3") ||
+		strings.Contains(markup, CallbackGiftPayment+"?m=6") {
+		t.Errorf("Expected only periods with a Stars price, got %s", markup)
+	}
+
+	h.GiftPaymentCallbackHandler(ctx, b, paymentCallbackUpdate(CallbackGiftPayment+"?m=3"))
+	if len(payments.gifts) != 1 {
+		t.Fatalf("Expected one gift invoice, got %d", len(payments.gifts))
+	}
+	gift := payments.gifts[0]
+	if gift.amount != 250 || gift.period.Months != 3 || gift.payer.TelegramID != 42 || gift.recipient.TelegramID != 77 || gift.tariff != nil {
+		t.Errorf("Unexpected gift invoice %+v", gift)
+	}
+	if _, saved := payments.messages[501]; !saved {
+		t.Error("Expected payment message to be saved for the invoice")
+	}
+}
+
+func TestGiftRecipientInputRefusesSelfAndUnknownUsers(t *testing.T) {
+	setGiftConfig(t)
+	tests := []struct {
+		name string
+		chat models.ChatFullInfo
+		want string
+	}{
+		{name: "self", chat: models.ChatFullInfo{ID: 42, Type: models.ChatTypePrivate}, want: "gift_recipient_self"},
+		{name: "channel", chat: models.ChatFullInfo{ID: 77, Type: models.ChatTypeChannel}, want: "gift_recipient_not_found"},
+		{name: "never started the bot", chat: models.ChatFullInfo{ID: 99, Type: models.ChatTypePrivate}, want: "gift_recipient_not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, tg := newTestBot(t)
+			tg.results = map[string]any{"getChat": tt.chat}
+			h, cache := newGiftHandlers(&mockGiftPayments{}, lastPaidPurchase{})
+			cache.SetString(giftStateKey(42), "waiting_username", 300)
+
+			h.GiftRecipientInputHandler(context.Background(), b, giftTextUpdate("@friend_01"))
+
+			if _, stored := cache.GetString(giftRecipientKey(42)); stored {
+				t.Error("Expected recipient not to be remembered")
+			}
+			sent := tg.called("sendMessage")
+			if len(sent) != 1 || sent[0].params["text"] != tt.want {
+				t.Errorf("Expected %s, got %v", tt.want, sent)
+			}
+		})
+	}
+}
+
+// Подделанное имя тарифа в подарке не оплачивается по глобальной цене и не сохраняется в покупке
+func TestGiftPaymentRejectsForgedTariff(t *testing.T) {
+	setGiftConfig(t)
+	b, _ := newTestBot(t)
+	payments := &mockGiftPayments{}
+	h, cache := newGiftHandlers(payments, lastPaidPurchase{})
+	cache.SetString(giftRecipientKey(42), "77", 300)
+
+	h.GiftPaymentCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackGiftPayment+"?m=1&n=FORGED"))
+	if len(payments.gifts) != 1 || payments.gifts[0].amount != 100 || payments.gifts[0].tariff != nil {
+		t.Fatalf("Expected legacy gift without tariff name when no tariffs are configured, got %+v", payments.gifts)
+	}
+
+	t.Setenv("TARIFF_BASIC_ENABLED", "true")
+	t.Setenv("TARIFF_BASIC_DEVICES", "2")
+	t.Setenv("TARIFF_BASIC_PRICE_1", "300")
+	t.Setenv("TARIFF_BASIC_PRICE_3", "800")
+	t.Setenv("TARIFF_BASIC_PRICE_6", "1500")
+	t.Setenv("TARIFF_BASIC_PRICE_12", "2800")
+	t.Setenv("TARIFF_BASIC_STARS_PRICE_1", "150")
+	config.InitConfig()
+
+	h.GiftPaymentCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackGiftPayment+"?m=1&n=FORGED"))
+	if len(payments.gifts) != 1 {
+		t.Fatalf("Expected forged tariff to be rejected, got %+v", payments.gifts)
+	}
+
+	h.GiftPaymentCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackGiftPayment+"?m=1&n=BASIC"))
+	if len(payments.gifts) != 2 || payments.gifts[1].amount != 150 || payments.gifts[1].tariff == nil || *payments.gifts[1].tariff != "BASIC" {
+		t.Errorf("Expected BASIC gift for 150 stars, got %+v", payments.gifts)
+	}
+}
+
+func TestGiftPaymentRequiresRecipient(t *testing.T) {
+	setGiftConfig(t)
+	b, tg := newTestBot(t)
+	payments := &mockGiftPayments{}
+	h, _ := newGiftHandlers(payments, lastPaidPurchase{})
+
+	h.GiftPaymentCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackGiftPayment+"?m=1"))
+
+	if len(payments.gifts) != 0 {
+		t.Fatalf("Expected no invoice without a recipient, got %v", payments.gifts)
+	}
+	sent := tg.called("sendMessage")
+	if len(sent) != 1 || sent[0].params["text"] != "gift_session_expired" {
+		t.Errorf("Expected session expired message, got %v", sent)
+	}
+}
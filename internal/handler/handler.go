@@ -4,16 +4,16 @@ import (
 	"context"
 	"time"
 
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
 	"remnawave-tg-shop-bot/internal/broadcast"
-	"remnawave-tg-shop-bot/internal/cache"
-	"remnawave-tg-shop-bot/internal/cryptopay"
+	"remnawave-tg-shop-bot/internal/config"
 	"remnawave-tg-shop-bot/internal/database"
 	"remnawave-tg-shop-bot/internal/payment"
 	"remnawave-tg-shop-bot/internal/promo"
 	"remnawave-tg-shop-bot/internal/remnawave"
-	"remnawave-tg-shop-bot/internal/sync"
-	"remnawave-tg-shop-bot/internal/translation"
-	"remnawave-tg-shop-bot/internal/yookasa"
+	"remnawave-tg-shop-bot/internal/team"
 )
 
 // BroadcastService interface для избежания циклических импортов
@@ -21,7 +21,9 @@ type BroadcastService interface {
 	CreateBroadcast(ctx context.Context, targetType, messageText string) (int64, error)
 	StartBroadcast(ctx context.Context, broadcastID int64, targetType, messageText string)
 	StartBroadcastWithOptions(ctx context.Context, broadcastID int64, targetType, messageText string, opts *broadcast.BroadcastOptions)
-	GetTargetCustomersCount(ctx context.Context, targetType string) (int, error)
+	SendPreview(ctx context.Context, chatID int64, messageText string, vars map[string]string, opts *broadcast.BroadcastOptions) error
+	SendTest(ctx context.Context, broadcastID, chatID int64, messageText, firstName string, opts *broadcast.BroadcastOptions) error
+	CountRecipients(ctx context.Context, targetType string, exclusions broadcast.Exclusions) (recipients, excluded int, err error)
 	GetBroadcast(ctx context.Context, id int64) (*database.BroadcastHistory, error)
 	GetBroadcastHistory(ctx context.Context, limit, offset int) ([]database.BroadcastHistory, error)
 	DeleteBroadcast(ctx context.Context, id int64) error
@@ -33,9 +35,11 @@ type PromoServiceInterface interface {
 	CreatePromoCode(ctx context.Context, code string, bonusDays, maxActivations int, adminID int64, validUntil *time.Time) (*database.PromoCode, error)
 	GetAllPromoCodes(ctx context.Context, limit, offset int) ([]database.PromoCode, error)
 	GetPromoByID(ctx context.Context, id int64) (*database.PromoCode, error)
+	GetPromoByCode(ctx context.Context, code string) (*database.PromoCode, error)
 	DeactivatePromo(ctx context.Context, promoID int64) error
 	ActivatePromo(ctx context.Context, promoID int64) error
 	DeletePromo(ctx context.Context, promoID int64) error
+	GetActivationChannels(ctx context.Context, promoID int64) ([]database.PromoChannelCount, error)
 }
 
 // PromoTariffServiceInterface interface для промокодов на тариф
@@ -47,52 +51,514 @@ type PromoTariffServiceInterface interface {
 	DeactivatePromoTariff(ctx context.Context, promoID int64) error
 	ActivatePromoTariff(ctx context.Context, promoID int64) error
 	DeletePromoTariff(ctx context.Context, promoID int64) error
+	GetPromoTariffActivationChannels(ctx context.Context, promoID int64) ([]database.PromoChannelCount, error)
 }
 
-type Handler struct {
-	customerRepository  *database.CustomerRepository
-	purchaseRepository  *database.PurchaseRepository
-	cryptoPayClient     *cryptopay.Client
-	yookasaClient       *yookasa.Client
-	translation         *translation.Manager
-	paymentService      *payment.PaymentService
-	syncService         *sync.SyncService
-	referralRepository  *database.ReferralRepository
-	cache               *cache.Cache
-	broadcastService    BroadcastService
-	promoService        PromoServiceInterface
-	promoTariffService  PromoTariffServiceInterface
-	remnawaveClient     *remnawave.Client
-}
-
-func NewHandler(
-	syncService *sync.SyncService,
-	paymentService *payment.PaymentService,
-	translation *translation.Manager,
-	customerRepository *database.CustomerRepository,
-	purchaseRepository *database.PurchaseRepository,
-	cryptoPayClient *cryptopay.Client,
-	yookasaClient *yookasa.Client,
-	referralRepository *database.ReferralRepository,
-	cache *cache.Cache,
-	broadcastService BroadcastService,
-	promoService PromoServiceInterface,
-	promoTariffService PromoTariffServiceInterface,
-	remnawaveClient *remnawave.Client,
-) *Handler {
-	return &Handler{
-		syncService:        syncService,
-		paymentService:     paymentService,
+// translator интерфейс для работы с переводами
+type translator interface {
+	GetText(langCode, key string) string
+	GetTextTemplate(langCode, key string, data map[string]interface{}) string
+}
+
+// stateCache интерфейс кеша: состояния диалогов, последние меню и т.п.
+type stateCache interface {
+	GetString(key string) (string, bool)
+	SetString(key string, value string, ttl int)
+	Delete(key string)
+}
+
+// base общие зависимости обработчиков: переводы и кеш. Встраивается в каждую группу обработчиков
+// вместе с общими методами (sendMenu, sendAdminText и т.п.)
+type base struct {
+	translation translator
+	cache       stateCache
+}
+
+// profileCustomers интерфейс для работы с клиентами на пользовательских экранах
+type profileCustomers interface {
+	FindByTelegramId(ctx context.Context, telegramId int64) (*database.Customer, error)
+	FindById(ctx context.Context, id int64) (*database.Customer, error)
+	Create(ctx context.Context, customer *database.Customer) (*database.Customer, error)
+	UpdateFields(ctx context.Context, id int64, updates map[string]interface{}) error
+	AcceptTos(ctx context.Context, id int64, version string, acceptedAt time.Time) error
+	IsPhoneVerified(ctx context.Context, id int64) (bool, error)
+	SavePhoneHash(ctx context.Context, id int64, phoneHash string, verifiedAt time.Time) error
+}
+
+// lastPurchaseFinder интерфейс для карточки статуса подписки
+type lastPurchaseFinder interface {
+	FindLastPaidPurchaseByCustomer(ctx context.Context, customerID int64) (*database.Purchase, error)
+}
+
+// profilePayments интерфейс платёжного сервиса для триала, командных тарифов и подарков
+type profilePayments interface {
+	ActivateTrial(ctx context.Context, telegramId int64) (string, error)
+	CheckTrialEligibility(ctx context.Context, customer *database.Customer) (payment.TrialEligibility, error)
+	CreateTeamPurchase(ctx context.Context, amount float64, months, seats int, customer *database.Customer, invoiceType database.InvoiceType) (url string, purchaseId int64, err error)
+	CreateGiftPurchase(ctx context.Context, amount float64, period config.Period, payer, recipient *database.Customer, tariffName *string) (url string, purchaseId int64, err error)
+	SavePurchaseMessage(ctx context.Context, purchaseID int64, messageID int)
+	SaveProviderPayment(ctx context.Context, purchaseID int64, providerPaymentID, methodType string)
+}
+
+// referralStore интерфейс для работы с рефералами
+type referralStore interface {
+	Create(ctx context.Context, referrerID, refereeID int64) (*database.Referral, error)
+	CountByReferrer(ctx context.Context, referrerID int64) (int, error)
+}
+
+// teamSeats интерфейс командных тарифов: приглашения и управление местами
+type teamSeats interface {
+	Redeem(ctx context.Context, customer *database.Customer, code string) (*team.RedeemResult, error)
+	Revoke(ctx context.Context, ownerCustomerID, seatID int64) (*team.RevokeResult, error)
+	OwnedTeams(ctx context.Context, ownerCustomerID int64) ([]database.Team, error)
+	OwnedTeam(ctx context.Context, ownerCustomerID, teamID int64) (*database.Team, []database.TeamSeat, error)
+	OwnedSeat(ctx context.Context, ownerCustomerID, seatID int64) (*database.TeamSeat, *database.Team, error)
+	CountTakenSeats(ctx context.Context, teamID int64) (int, error)
+}
+
+// panelUserGetter интерфейс для чтения пользователя из панели Remnawave
+type panelUserGetter interface {
+	GetUserByTelegramID(ctx context.Context, telegramID int64) (*remnawave.UserInfo, error)
+}
+
+// serverStatusGetter интерфейс статуса серверов панели: ноды, пользователи онлайн и трафик
+type serverStatusGetter interface {
+	ServerStatus(ctx context.Context) (*remnawave.ServerStatus, error)
+}
+
+// promoLinkRedeemer активация промокода из deep link /start promo_<код>
+type promoLinkRedeemer interface {
+	RedeemPromoLink(ctx context.Context, b *bot.Bot, customer *database.Customer, from *models.User, code, channel string)
+}
+
+// tariffCheckout меню оплаты выбранного тарифа для deep link /start buy_<тариф>_<период>
+type tariffCheckout interface {
+	SendCheckout(ctx context.Context, b *bot.Bot, chatID int64, langCode, month, tariff string)
+}
+
+// customerPrivacy выгрузка и удаление данных клиента по его запросу
+type customerPrivacy interface {
+	Export(ctx context.Context, customer *database.Customer) ([]byte, error)
+	Erase(ctx context.Context, customer *database.Customer, deletePanelUser bool) (int, error)
+}
+
+// ProfileHandlers пользовательские экраны: старт, подключение, триал, рефералы, команды и middleware
+type ProfileHandlers struct {
+	base
+	customerRepository profileCustomers
+	purchaseRepository lastPurchaseFinder
+	paymentService     profilePayments
+	referralRepository referralStore
+	teamService        teamSeats
+	remnawaveClient    panelUserGetter
+	links              *LinkRotator
+	promoLinks         promoLinkRedeemer
+	privacy            customerPrivacy
+	serverStatus       serverStatusGetter
+	checkout           tariffCheckout
+}
+
+func NewProfileHandlers(
+	tm translator,
+	cache stateCache,
+	customerRepository profileCustomers,
+	purchaseRepository lastPurchaseFinder,
+	paymentService profilePayments,
+	referralRepository referralStore,
+	teamService teamSeats,
+	remnawaveClient panelUserGetter,
+	links *LinkRotator,
+	promoLinks promoLinkRedeemer,
+	privacy customerPrivacy,
+	serverStatus serverStatusGetter,
+	checkout tariffCheckout,
+) *ProfileHandlers {
+	return &ProfileHandlers{
+		base:               base{translation: tm, cache: cache},
 		customerRepository: customerRepository,
 		purchaseRepository: purchaseRepository,
-		cryptoPayClient:    cryptoPayClient,
-		yookasaClient:      yookasaClient,
-		translation:        translation,
+		paymentService:     paymentService,
 		referralRepository: referralRepository,
-		cache:              cache,
-		broadcastService:   broadcastService,
+		teamService:        teamService,
+		remnawaveClient:    remnawaveClient,
+		links:              links,
+		promoLinks:         promoLinks,
+		privacy:            privacy,
+		serverStatus:       serverStatus,
+		checkout:           checkout,
+	}
+}
+
+// paymentCustomers интерфейс для работы с клиентами при покупке
+type paymentCustomers interface {
+	FindByTelegramId(ctx context.Context, telegramId int64) (*database.Customer, error)
+	DisableRecurring(ctx context.Context, id int64) error
+	DeletePaymentMethod(ctx context.Context, id int64) error
+	AcceptRecurringPriceChange(ctx context.Context, id int64, at time.Time) (bool, error)
+	ConfirmRecurringCharge(ctx context.Context, id int64, at time.Time) (bool, error)
+}
+
+// savedPaymentMethods интерфейс списка сохранённых карт клиента
+type savedPaymentMethods interface {
+	FindByCustomer(ctx context.Context, customerID int64) ([]database.PaymentMethod, error)
+	SelectForRecurring(ctx context.Context, customerID, id int64) (bool, error)
+	Delete(ctx context.Context, customerID, id int64) (*database.PaymentMethod, bool, error)
+}
+
+// paymentPurchases интерфейс для чтения покупок
+type paymentPurchases interface {
+	FindById(ctx context.Context, id int64) (*database.Purchase, error)
+	FindPaidByCustomer(ctx context.Context, customerID int64, limit int) ([]database.Purchase, error)
+	FindSuccessfulPaidPurchaseByCustomer(ctx context.Context, customerID int64) (*database.Purchase, error)
+}
+
+// purchaseService интерфейс платёжного сервиса: создание счетов и обработка оплат
+type purchaseService interface {
+	CreatePeriodPurchase(ctx context.Context, amount float64, period config.Period, customer *database.Customer, invoiceType database.InvoiceType, tariffName *string, deviceLimit *int, savePaymentMethod bool) (url string, purchaseId int64, err error)
+	CreateSandboxPurchase(ctx context.Context, period config.Period, customer *database.Customer, tariffName *string, deviceLimit *int) (int64, error)
+	CreateTestYookasaPurchase(ctx context.Context, amount float64, period config.Period, customer *database.Customer, tariffName *string, deviceLimit *int) (url string, purchaseId int64, err error)
+	FulfillPurchase(ctx context.Context, purchaseID int64, source database.InvoiceType, payload []byte) error
+	SavePurchaseMessage(ctx context.Context, purchaseID int64, messageID int)
+	SaveProviderPayment(ctx context.Context, purchaseID int64, providerPaymentID, methodType string)
+}
+
+// receiptStore интерфейс для работы с квитанциями
+type receiptStore interface {
+	FindByPurchaseID(ctx context.Context, purchaseID int64) (*database.Receipt, error)
+	Issue(ctx context.Context, purchaseID, customerID int64, prefix string) (*database.Receipt, error)
+	SetFileID(ctx context.Context, purchaseID int64, fileID string) error
+}
+
+// winbackOptOuts интерфейс для отписки от winback-предложений
+type winbackOptOuts interface {
+	OptOut(ctx context.Context, customerID int64, at time.Time) error
+}
+
+// PaymentHandlers выбор тарифа, оплата, автопродление, квитанции и winback-предложения
+type PaymentHandlers struct {
+	base
+	customerRepository paymentCustomers
+	purchaseRepository paymentPurchases
+	paymentService     purchaseService
+	receiptRepository  receiptStore
+	winbackRepository  winbackOptOuts
+	paymentMethods     savedPaymentMethods
+}
+
+func NewPaymentHandlers(
+	tm translator,
+	cache stateCache,
+	customerRepository paymentCustomers,
+	purchaseRepository paymentPurchases,
+	paymentService purchaseService,
+	receiptRepository receiptStore,
+	winbackRepository winbackOptOuts,
+	paymentMethods savedPaymentMethods,
+) *PaymentHandlers {
+	return &PaymentHandlers{
+		base:               base{translation: tm, cache: cache},
+		customerRepository: customerRepository,
+		purchaseRepository: purchaseRepository,
+		paymentService:     paymentService,
+		receiptRepository:  receiptRepository,
+		winbackRepository:  winbackRepository,
+		paymentMethods:     paymentMethods,
+	}
+}
+
+// customerFinder интерфейс для поиска клиента по Telegram ID
+type customerFinder interface {
+	FindByTelegramId(ctx context.Context, telegramId int64) (*database.Customer, error)
+}
+
+// promoOfferSegments выставление promo tariff предложения сегменту и уведомление о нём через рассылку
+type promoOfferSegments interface {
+	AudienceSize(ctx context.Context, targetType string) (int, error)
+	AssignPromoOffer(ctx context.Context, targetType string, offer broadcast.PromoOffer) (int64, error)
+	CreateBroadcast(ctx context.Context, targetType, messageText string) (int64, error)
+	StartBroadcastWithOptions(ctx context.Context, broadcastID int64, targetType, messageText string, opts *broadcast.BroadcastOptions)
+}
+
+// PromoHandlers ввод промокодов пользователем и управление промокодами в админке
+type PromoHandlers struct {
+	base
+	customerRepository customerFinder
+	promoService       PromoServiceInterface
+	promoTariffService PromoTariffServiceInterface
+	offerSegments      promoOfferSegments
+}
+
+func NewPromoHandlers(
+	tm translator,
+	cache stateCache,
+	customerRepository customerFinder,
+	promoService PromoServiceInterface,
+	promoTariffService PromoTariffServiceInterface,
+	offerSegments promoOfferSegments,
+) *PromoHandlers {
+	return &PromoHandlers{
+		base:               base{translation: tm, cache: cache},
+		customerRepository: customerRepository,
 		promoService:       promoService,
 		promoTariffService: promoTariffService,
-		remnawaveClient:    remnawaveClient,
+		offerSegments:      offerSegments,
+	}
+}
+
+// faqStore интерфейс вопросов и ответов FAQ
+type faqStore interface {
+	Categories(ctx context.Context, lang string) ([]database.FaqCategory, error)
+	ListCategory(ctx context.Context, lang, category string) ([]database.FaqEntry, error)
+	Search(ctx context.Context, lang, query string, limit int) ([]database.FaqEntry, error)
+	List(ctx context.Context, limit, offset int) ([]database.FaqEntry, error)
+	FindByID(ctx context.Context, id int64) (*database.FaqEntry, error)
+	Create(ctx context.Context, entry database.FaqEntry) (*database.FaqEntry, error)
+	SetActive(ctx context.Context, id int64, active bool) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// FaqHandlers раздел FAQ в главном меню и управление вопросами в админке
+type FaqHandlers struct {
+	base
+	faq faqStore
+}
+
+func NewFaqHandlers(tm translator, cache stateCache, faq faqStore) *FaqHandlers {
+	return &FaqHandlers{
+		base: base{translation: tm, cache: cache},
+		faq:  faq,
+	}
+}
+
+// surveyStore интерфейс ответов на опросы удовлетворённости и их итогов
+type surveyStore interface {
+	SaveRating(ctx context.Context, id, telegramID int64, rating int, at time.Time) (bool, error)
+	SaveComment(ctx context.Context, id, telegramID int64, comment string) (bool, error)
+	Stats(ctx context.Context, since time.Time) (database.SurveyStats, error)
+	RecentComments(ctx context.Context, limit int) ([]database.SurveyComment, error)
+}
+
+// surveyOfferer отправка опроса клиенту
+type surveyOfferer interface {
+	Offer(ctx context.Context, customer *database.Customer, trigger string) (bool, error)
+}
+
+// SurveyHandlers ответы клиентов на опрос удовлетворённости, опрос после поддержки и итоги в админке
+type SurveyHandlers struct {
+	base
+	surveys   surveyStore
+	customers customerFinder
+	offerer   surveyOfferer
+}
+
+func NewSurveyHandlers(tm translator, cache stateCache, surveys surveyStore, customers customerFinder, offerer surveyOfferer) *SurveyHandlers {
+	return &SurveyHandlers{
+		base:      base{translation: tm, cache: cache},
+		surveys:   surveys,
+		customers: customers,
+		offerer:   offerer,
+	}
+}
+
+// notificationFeedbackStore интерфейс оценок 👍/👎 под уведомлениями
+type notificationFeedbackStore interface {
+	Save(ctx context.Context, telegramID int64, messageID int, template string, useful bool, at time.Time) (bool, error)
+	Stats(ctx context.Context, since time.Time) ([]database.NotificationFeedbackStats, error)
+}
+
+// NotificationFeedbackHandlers оценки уведомлений клиентами и отчёт о качестве шаблонов в админке
+type NotificationFeedbackHandlers struct {
+	base
+	feedback notificationFeedbackStore
+}
+
+func NewNotificationFeedbackHandlers(tm translator, cache stateCache, feedback notificationFeedbackStore) *NotificationFeedbackHandlers {
+	return &NotificationFeedbackHandlers{
+		base:     base{translation: tm, cache: cache},
+		feedback: feedback,
+	}
+}
+
+// failedFulfillmentList интерфейс журнала ошибок выдачи оплаченных покупок
+type failedFulfillmentList interface {
+	FindOpen(ctx context.Context, limit int) ([]database.FailedFulfillment, error)
+}
+
+// fulfillmentRetrier повтор выдачи покупки из журнала ошибок
+type fulfillmentRetrier interface {
+	RetryFailedFulfillment(ctx context.Context, id int64) (*database.FailedFulfillment, error)
+}
+
+// FulfillmentErrorHandlers ошибки выдачи оплаченных покупок в админке и их повтор
+type FulfillmentErrorHandlers struct {
+	base
+	failed  failedFulfillmentList
+	retrier fulfillmentRetrier
+}
+
+func NewFulfillmentErrorHandlers(tm translator, cache stateCache, failed failedFulfillmentList, retrier fulfillmentRetrier) *FulfillmentErrorHandlers {
+	return &FulfillmentErrorHandlers{
+		base:    base{translation: tm, cache: cache},
+		failed:  failed,
+		retrier: retrier,
+	}
+}
+
+// adminCustomers интерфейс для работы с клиентами в админке
+type adminCustomers interface {
+	FindByTelegramId(ctx context.Context, telegramId int64) (*database.Customer, error)
+	GetBalance(ctx context.Context, id int64) (int, error)
+	CreditBalanceByTelegramID(ctx context.Context, telegramID int64, amount int) (int, error)
+	UpdateFields(ctx context.Context, id int64, updates map[string]interface{}) error
+	ListRemnawaveTags(ctx context.Context) ([]database.TagCount, error)
+	DeletedStats(ctx context.Context, before time.Time) (total, purgeable int, err error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+}
+
+// recurringBulkStore интерфейс массового отключения автопродления
+type recurringBulkStore interface {
+	Segments(ctx context.Context) ([]database.RecurringSegment, error)
+	Disable(ctx context.Context, tariff *string, createdBy int64, undoUntil time.Time) (*database.RecurringBulkDisable, error)
+	Undo(ctx context.Context, id int64, now time.Time) (int, bool, error)
+}
+
+// customerSyncer интерфейс синхронизации клиентов с панелью
+type customerSyncer interface {
+	Sync(ctx context.Context)
+	FindDuplicates(ctx context.Context) ([]remnawave.DuplicateGroup, error)
+	MergeDuplicates(ctx context.Context, telegramID int64) (*remnawave.MergeResult, error)
+}
+
+// customerNoteStore интерфейс тегов и заметок поддержки
+type customerNoteStore interface {
+	GetTags(ctx context.Context, customerID int64) ([]string, error)
+	AddTag(ctx context.Context, customerID int64, tag string) (bool, error)
+	RemoveTag(ctx context.Context, customerID int64, tag string) (bool, error)
+	ListTags(ctx context.Context) ([]database.TagCount, error)
+	GetNote(ctx context.Context, customerID int64) (*database.CustomerNote, error)
+	SetNote(ctx context.Context, customerID int64, note string, adminID int64) error
+	DeleteNote(ctx context.Context, customerID int64) (bool, error)
+}
+
+// auditLogStore интерфейс журнала действий админа
+type auditLogStore interface {
+	Record(ctx context.Context, adminID, customerID int64, action, details string) error
+	FindByCustomer(ctx context.Context, customerID int64, limit int) ([]database.AuditLogEntry, error)
+}
+
+// featureFlagStore интерфейс переключения фич из админки
+type featureFlagStore interface {
+	Set(ctx context.Context, name string, enabled bool, adminID int64) error
+	Delete(ctx context.Context, name string) error
+}
+
+// winbackCampaignStats интерфейс статистики winback-кампаний
+type winbackCampaignStats interface {
+	CampaignStats(ctx context.Context) ([]database.WinbackCampaignStats, error)
+}
+
+// checkoutReminderStats интерфейс статистики напоминаний о неоплаченных счетах
+type checkoutReminderStats interface {
+	Stats(ctx context.Context, since time.Time) (database.CheckoutReminderStats, error)
+}
+
+// customCommandAdmin интерфейс управления командами, добавленными админом
+type customCommandAdmin interface {
+	List(ctx context.Context) ([]database.CustomCommand, error)
+	FindByID(ctx context.Context, id int64) (*database.CustomCommand, error)
+	Save(ctx context.Context, command database.CustomCommand) (*database.CustomCommand, error)
+	SetActive(ctx context.Context, id int64, active bool) error
+	Delete(ctx context.Context, id int64) error
+}
+
+// faqInputHandlers обработчики ввода FAQ, на которые AdminTextInputHandler передаёт текст админа
+type faqInputHandlers interface {
+	AdminFaqCreateInputHandler(ctx context.Context, b *bot.Bot, update *models.Update)
+	FaqSearchInputHandler(ctx context.Context, b *bot.Bot, update *models.Update)
+}
+
+// promoInputHandlers обработчики ввода промокодов, на которые AdminTextInputHandler передаёт текст админа
+type promoInputHandlers interface {
+	AdminPromoCreateInputHandler(ctx context.Context, b *bot.Bot, update *models.Update)
+	AdminPromoTariffCreateInputHandler(ctx context.Context, b *bot.Bot, update *models.Update)
+	AdminPromoOfferInputHandler(ctx context.Context, b *bot.Bot, update *models.Update)
+	PromoCodeInputHandler(ctx context.Context, b *bot.Bot, update *models.Update)
+}
+
+// emailInputHandler обработчик ввода email для чеков, на который AdminTextInputHandler передаёт текст админа
+type emailInputHandler interface {
+	ReceiptEmailInputHandler(ctx context.Context, b *bot.Bot, update *models.Update)
+}
+
+// adminQuickStats сводка за сегодня для шапки /admin
+type adminQuickStats interface {
+	Header(ctx context.Context) (string, error)
+}
+
+// AdminHandlers панель администратора: рассылки, поддержка, фичи, задачи и статистика
+type AdminHandlers struct {
+	base
+	customerRepository         adminCustomers
+	syncService                customerSyncer
+	broadcastService           BroadcastService
+	customerNotes              customerNoteStore
+	auditLog                   auditLogStore
+	featureFlags               featureFlagStore
+	winbackRepository          winbackCampaignStats
+	checkoutReminderRepository checkoutReminderStats
+	links                      *LinkRotator
+	promoInput                 promoInputHandlers
+	faqInput                   faqInputHandlers
+	privacy                    customerPrivacy
+	customCommands             customCommandAdmin
+	commandRegistry            *CustomCommands
+	recurringBulk              recurringBulkStore
+	emailInput                 emailInputHandler
+	serverStatus               serverStatusGetter
+	quickStats                 adminQuickStats
+}
+
+func NewAdminHandlers(
+	tm translator,
+	cache stateCache,
+	customerRepository adminCustomers,
+	syncService customerSyncer,
+	broadcastService BroadcastService,
+	customerNotes customerNoteStore,
+	auditLog auditLogStore,
+	featureFlags featureFlagStore,
+	winbackRepository winbackCampaignStats,
+	checkoutReminderRepository checkoutReminderStats,
+	links *LinkRotator,
+	promoInput promoInputHandlers,
+	privacy customerPrivacy,
+	customCommands customCommandAdmin,
+	commandRegistry *CustomCommands,
+	faqInput faqInputHandlers,
+	recurringBulk recurringBulkStore,
+	emailInput emailInputHandler,
+	serverStatus serverStatusGetter,
+	quickStats adminQuickStats,
+) *AdminHandlers {
+	return &AdminHandlers{
+		base:                       base{translation: tm, cache: cache},
+		customerRepository:         customerRepository,
+		syncService:                syncService,
+		broadcastService:           broadcastService,
+		customerNotes:              customerNotes,
+		auditLog:                   auditLog,
+		featureFlags:               featureFlags,
+		winbackRepository:          winbackRepository,
+		checkoutReminderRepository: checkoutReminderRepository,
+		links:                      links,
+		promoInput:                 promoInput,
+		privacy:                    privacy,
+		customCommands:             customCommands,
+		commandRegistry:            commandRegistry,
+		faqInput:                   faqInput,
+		recurringBulk:              recurringBulk,
+		emailInput:                 emailInput,
+		serverStatus:               serverStatus,
+		quickStats:                 quickStats,
 	}
 }
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// fakeTranslator возвращает ключ перевода вместо текста
+type fakeTranslator struct{}
+
+func (fakeTranslator) GetText(langCode, key string) string {
+	return key
+}
+
+func (fakeTranslator) GetTextTemplate(langCode, key string, data map[string]interface{}) string {
+	return key
+}
+
+// fakeCache кеш состояний в памяти
+type fakeCache struct {
+	values map[string]string
+}
+
+func newFakeCache() *fakeCache {
+	return &fakeCache{values: map[string]string{}}
+}
+
+func (c *fakeCache) GetString(key string) (string, bool) {
+	v, ok := c.values[key]
+	return v, ok
+}
+
+func (c *fakeCache) SetString(key string, value string, ttl int) {
+	c.values[key] = value
+}
+
+func (c *fakeCache) Delete(key string) {
+	delete(c.values, key)
+}
+
+// botCall запрос к Bot API, записанный фейковым сервером
+type botCall struct {
+	method string
+	params map[string]string
+}
+
+// fakeTelegram фейковый Bot API: записывает вызовы методов и отвечает успехом.
+// results задаёт ответы отдельных методов, остальные получают сообщение или true
+type fakeTelegram struct {
+	mu      sync.Mutex
+	calls   []botCall
+	results map[string]any
+}
+
+// newTestBot создаёт бота, который ходит в фейковый Bot API вместо Telegram
+func newTestBot(t *testing.T) (*bot.Bot, *fakeTelegram) {
+	t.Helper()
+	tg := &fakeTelegram{}
+	srv := httptest.NewServer(http.HandlerFunc(tg.serve))
+	t.Cleanup(srv.Close)
+
+	b, err := bot.New("test-token", bot.WithServerURL(srv.URL), bot.WithSkipGetMe())
+	if err != nil {
+		t.Fatalf("bot.New: %v", err)
+	}
+	return b, tg
+}
+
+func (f *fakeTelegram) serve(w http.ResponseWriter, r *http.Request) {
+	call := botCall{method: r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:], params: map[string]string{}}
+	if err := r.ParseMultipartForm(1 << 20); err == nil {
+		for k, v := range r.MultipartForm.Value {
+			call.params[k] = v[0]
+		}
+	}
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+
+	var result any = true
+	if call.method != "answerCallbackQuery" && call.method != "deleteMessage" && call.method != "answerInlineQuery" {
+		result = models.Message{ID: 1, Chat: models.Chat{ID: 1}}
+	}
+	if r, ok := f.results[call.method]; ok {
+		result = r
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": result})
+}
+
+// called возвращает вызовы метода method
+func (f *fakeTelegram) called(method string) []botCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []botCall
+	for _, c := range f.calls {
+		if c.method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/promo"
+	"remnawave-tg-shop-bot/utils"
+)
+
+const (
+	// inlineCacheSeconds сколько Telegram кэширует ответ на inline запрос. Ответ персональный:
+	// в каждой карточке реферальная ссылка того, кто её отправляет
+	inlineCacheSeconds = 60
+	// inlinePromoChannel канал активаций промокодов, отправленных через inline режим
+	inlinePromoChannel = "inline"
+)
+
+// InlineQueryHandler отвечает на inline запрос (@bot в любом чате) карточками, которые клиент может отправить
+// друзьям: реферальная ссылка, промокоды из INLINE_PROMO_CODES и описание тарифов. Текст запроса фильтрует карточки
+func (h PromoHandlers) InlineQueryHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	query := update.InlineQuery
+	var results []models.InlineQueryResult
+	if config.IsInlineModeEnabled() && !config.GetBlockedTelegramIds()[query.From.ID] {
+		results = filterInlineResults(h.inlineResults(ctx, query.From), query.Query)
+	}
+
+	_, err := b.AnswerInlineQuery(ctx, &bot.AnswerInlineQueryParams{
+		InlineQueryID: query.ID,
+		Results:       results,
+		CacheTime:     inlineCacheSeconds,
+		IsPersonal:    true,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error answering inline query", "userId", utils.MaskHalfInt64(query.From.ID), "error", err)
+	}
+}
+
+func (h PromoHandlers) inlineResults(ctx context.Context, from *models.User) []models.InlineQueryResult {
+	lang := from.LanguageCode
+	refLink := inlineReferralLink(from.ID)
+
+	results := []models.InlineQueryResult{
+		h.inlineArticle("referral", lang, "inline_referral", h.translation.GetText(lang, "inline_referral_message"), refLink),
+	}
+
+	now := clock.Now()
+	for _, code := range config.InlinePromoCodes() {
+		p, err := h.promoService.GetPromoByCode(ctx, code)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error finding inline promo code", "code", code, "error", err)
+			continue
+		}
+		if !promoShareable(p, now) {
+			continue
+		}
+		data := map[string]interface{}{
+			"code": p.Code,
+			"days": p.BonusDays,
+		}
+		result := h.inlineArticle("promo_"+p.Code, lang, "inline_promo", h.translation.GetTextTemplate(lang, "inline_promo_message", data), inlinePromoLink(p.Code))
+		result.Title = h.translation.GetTextTemplate(lang, "inline_promo_title", data)
+		result.Description = h.translation.GetTextTemplate(lang, "inline_promo_description", data)
+		results = append(results, result)
+	}
+
+	if tariffs := h.inlineTariffsText(lang); tariffs != "" {
+		results = append(results, h.inlineArticle("tariffs", lang, "inline_tariffs", tariffs, refLink))
+	}
+	return results
+}
+
+// inlineArticle карточка inline результата: заголовок и описание из переводов <key>_title и <key>_description,
+// кнопка под отправленным сообщением ведёт в бота по ссылке link
+func (h PromoHandlers) inlineArticle(id, lang, key, text, link string) *models.InlineQueryResultArticle {
+	return &models.InlineQueryResultArticle{
+		ID:          id,
+		Title:       h.translation.GetText(lang, key+"_title"),
+		Description: h.translation.GetText(lang, key+"_description"),
+		InputMessageContent: &models.InputTextMessageContent{
+			MessageText:        text,
+			ParseMode:          models.ParseModeHTML,
+			LinkPreviewOptions: &models.LinkPreviewOptions{IsDisabled: bot.True()},
+		},
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: h.translation.GetText(lang, "inline_open_bot_button"), URL: link}},
+		}},
+	}
+}
+
+// inlineTariffsText описание тарифов с ценами по периодам, пустая строка если цен нет
+func (h PromoHandlers) inlineTariffsText(lang string) string {
+	var sb strings.Builder
+	if config.IsTariffsEnabled() {
+		for _, t := range config.GetTariffs() {
+			sb.WriteString(fmt.Sprintf("\n\n<b>%s</b> — %s", utils.EscapeHTML(t.Name),
+				h.translation.GetTextTemplate(lang, "inline_tariff_devices", map[string]interface{}{"devices": t.Devices})))
+			h.writeInlinePrices(&sb, lang, t.Price)
+		}
+	} else {
+		h.writeInlinePrices(&sb, lang, config.Price)
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return h.translation.GetText(lang, "inline_tariffs_message") + sb.String()
+}
+
+func (h PromoHandlers) writeInlinePrices(sb *strings.Builder, lang string, price func(month int) int) {
+	for _, month := range config.SupportedMonths() {
+		if p := price(month); p > 0 {
+			sb.WriteString("\n• " + h.translation.GetTextTemplate(lang, fmt.Sprintf("month_%d", month), map[string]interface{}{
+				"price": locale.FormatMoney(lang, float64(p)),
+			}))
+		}
+	}
+}
+
+// promoShareable промокод можно предлагать друзьям: он существует, активен, не истёк и не исчерпан
+func promoShareable(p *database.PromoCode, now time.Time) bool {
+	if p == nil || !p.IsActive {
+		return false
+	}
+	if p.ValidUntil != nil && now.After(*p.ValidUntil) {
+		return false
+	}
+	return p.CurrentActivations < p.MaxActivations
+}
+
+func inlineReferralLink(telegramID int64) string {
+	return fmt.Sprintf("%s?start=ref_%d", config.BotURL(), telegramID)
+}
+
+func inlinePromoLink(code string) string {
+	return fmt.Sprintf("%s?start=%s%s%s%s", config.BotURL(), promo.StartPrefix, code, promo.ChannelSeparator, inlinePromoChannel)
+}
+
+// filterInlineResults оставляет карточки, в заголовке или описании которых есть текст запроса
+func filterInlineResults(results []models.InlineQueryResult, query string) []models.InlineQueryResult {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return results
+	}
+	var filtered []models.InlineQueryResult
+	for _, r := range results {
+		article, ok := r.(*models.InlineQueryResultArticle)
+		if !ok || strings.Contains(strings.ToLower(article.Title+" "+article.Description), query) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+func inlineQueryUpdate(query string) *models.Update {
+	return &models.Update{InlineQuery: &models.InlineQuery{
+		ID:    "q1",
+		From:  &models.User{ID: 42, LanguageCode: "ru"},
+		Query: query,
+	}}
+}
+
+func TestInlineQueryHandler(t *testing.T) {
+	config.SetBotURL("https://t.me/test_bot")
+	h := NewPromoHandlers(fakeTranslator{}, newFakeCache(), &mockCustomerFinder{}, &mockPromoService{}, nil, nil)
+
+	t.Run("disabled inline mode answers with no results", func(t *testing.T) {
+		config.SetFeatureOverride(config.FeatureInlineMode, false)
+		t.Cleanup(func() { config.ClearFeatureOverride(config.FeatureInlineMode) })
+		b, tg := newTestBot(t)
+
+		h.InlineQueryHandler(context.Background(), b, inlineQueryUpdate(""))
+
+		answers := tg.called("answerInlineQuery")
+		if len(answers) != 1 || strings.Contains(answers[0].params["results"], "inline_referral") {
+			t.Fatalf("Expected empty answer, got %+v", answers)
+		}
+	})
+
+	t.Run("referral card carries sender's link", func(t *testing.T) {
+		config.SetFeatureOverride(config.FeatureInlineMode, true)
+		t.Cleanup(func() { config.ClearFeatureOverride(config.FeatureInlineMode) })
+		b, tg := newTestBot(t)
+
+		h.InlineQueryHandler(context.Background(), b, inlineQueryUpdate(""))
+
+		answers := tg.called("answerInlineQuery")
+		if len(answers) != 1 {
+			t.Fatalf("Expected one answer, got %d", len(answers))
+		}
+		results := answers[0].params["results"]
+		for _, want := range []string{"inline_referral_message", "https://t.me/test_bot?start=ref_42"} {
+			if !strings.Contains(results, want) {
+				t.Errorf("Expected %q in results %s", want, results)
+			}
+		}
+		if answers[0].params["is_personal"] != "true" {
+			t.Errorf("Expected personal answer, got %+v", answers[0].params)
+		}
+	})
+}
+
+func TestPromoShareable(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		promo *database.PromoCode
+		want  bool
+	}{
+		{"missing", nil, false},
+		{"active", &database.PromoCode{IsActive: true, MaxActivations: 10, CurrentActivations: 3, ValidUntil: &future}, true},
+		{"inactive", &database.PromoCode{IsActive: false, MaxActivations: 10}, false},
+		{"expired", &database.PromoCode{IsActive: true, MaxActivations: 10, ValidUntil: &past}, false},
+		{"exhausted", &database.PromoCode{IsActive: true, MaxActivations: 10, CurrentActivations: 10}, false},
+	}
+	for _, tt := range tests {
+		if got := promoShareable(tt.promo, now); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestFilterInlineResults(t *testing.T) {
+	results := []models.InlineQueryResult{
+		&models.InlineQueryResultArticle{ID: "referral", Title: "Пригласить друга", Description: "Реферальная ссылка"},
+		&models.InlineQueryResultArticle{ID: "tariffs", Title: "Тарифы и цены"},
+	}
+
+	if got := filterInlineResults(results, " "); len(got) != 2 {
+		t.Fatalf("Expected all results for empty query, got %d", len(got))
+	}
+	got := filterInlineResults(results, "ТАРИФ")
+	if len(got) != 1 || got[0].(*models.InlineQueryResultArticle).ID != "tariffs" {
+		t.Fatalf("Expected only tariffs card, got %+v", got)
+	}
+}
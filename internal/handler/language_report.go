@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/translation"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// languageReportMissingLimit сколько недостающих ключей показывать для каждого языка
+const languageReportMissingLimit = 15
+
+// localeReporter переводчик, который умеет сравнивать полноту языков
+type localeReporter interface {
+	Completeness() []translation.LocaleCompleteness
+}
+
+// LanguageReportCommandHandler возвращает обработчик команды админа /language:
+// полнота переводов по языкам и откуда берутся недостающие строки
+func LanguageReportCommandHandler(reporter localeReporter) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		_, err := utils.SendLongMessage(ctx, b, &bot.SendMessageParams{
+			ChatID:    update.Message.Chat.ID,
+			Text:      formatLanguageReport(reporter.Completeness()),
+			ParseMode: models.ParseModeHTML,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error sending language report", "error", err)
+		}
+	}
+}
+
+func formatLanguageReport(report []translation.LocaleCompleteness) string {
+	var sb strings.Builder
+	sb.WriteString("🌐 <b>Переводы</b>\n")
+	sb.WriteString("Новые файлы translations/*.json подхватываются без перезапуска\n")
+
+	for _, item := range report {
+		translated := item.Total - len(item.Missing)
+		percent := 100.0
+		if item.Total > 0 {
+			percent = float64(translated) * 100 / float64(item.Total)
+		}
+		sb.WriteString(utils.FormatHTML("\n<b>%s</b> — %.0f%% (%d из %d)\n", item.Language, percent, translated, item.Total))
+		if len(item.Fallbacks) > 0 {
+			sb.WriteString(utils.FormatHTML("недостающие строки: %s\n", strings.Join(item.Fallbacks, " → ")))
+		}
+		if len(item.Missing) == 0 {
+			continue
+		}
+		for i, key := range item.Missing {
+			if i == languageReportMissingLimit {
+				sb.WriteString(fmt.Sprintf("… и ещё %d\n", len(item.Missing)-i))
+				break
+			}
+			sb.WriteString(utils.FormatHTML("• <code>%s</code>\n", key))
+		}
+	}
+	return sb.String()
+}
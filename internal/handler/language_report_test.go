@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/translation"
+)
+
+func TestFormatLanguageReport(t *testing.T) {
+	missing := make([]string, languageReportMissingLimit+2)
+	for i := range missing {
+		missing[i] = fmt.Sprintf("key_%02d", i)
+	}
+	got := formatLanguageReport([]translation.LocaleCompleteness{
+		{Language: "ru", Total: 40},
+		{Language: "uk", Total: 40, Missing: missing, Fallbacks: []string{"ru", "en"}},
+	})
+
+	for _, want := range []string{"<b>ru</b> — 100% (40 из 40)", "<b>uk</b> — 58% (23 из 40)", "ru → en", "<code>key_00</code>", "… и ещё 2"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in %q", want, got)
+		}
+	}
+	if strings.Contains(got, fmt.Sprintf("key_%02d", languageReportMissingLimit)) {
+		t.Errorf("Expected missing keys to be limited, got %q", got)
+	}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/utils"
+)
+
+// editLongMessage редактирует сообщение первой частью текста, а остальные части, если текст не укладывается
+// в лимиты Telegram, досылает новыми сообщениями. Клавиатура остаётся на последней части
+func editLongMessage(ctx context.Context, b *bot.Bot, params *bot.EditMessageTextParams) error {
+	chunks := utils.SplitMessage(params.Text)
+	first := *params
+	first.Text = chunks[0]
+	if len(chunks) > 1 {
+		first.ReplyMarkup = nil
+	}
+	if _, err := b.EditMessageText(ctx, &first); err != nil {
+		return err
+	}
+	if len(chunks) == 1 {
+		return nil
+	}
+
+	_, err := utils.SendLongMessage(ctx, b, &bot.SendMessageParams{
+		ChatID:             params.ChatID,
+		Text:               strings.Join(chunks[1:], "\n"),
+		ParseMode:          params.ParseMode,
+		LinkPreviewOptions: params.LinkPreviewOptions,
+		ReplyMarkup:        params.ReplyMarkup,
+	})
+	return err
+}
+
+// listPage номер страницы списка из callback data вида "<prefix>_<page>", без номера — первая страница
+func listPage(data, prefix string) int {
+	page, err := strconv.Atoi(strings.TrimPrefix(strings.TrimPrefix(data, prefix), "_"))
+	if err != nil || page < 0 {
+		return 0
+	}
+	return page
+}
+
+// pageNavigationRow кнопки перехода между страницами списка, nil если список умещается на одной странице
+func pageNavigationRow(prefix string, page int, hasNext bool) []models.InlineKeyboardButton {
+	var row []models.InlineKeyboardButton
+	if page > 0 {
+		row = append(row, models.InlineKeyboardButton{Text: "◀️", CallbackData: fmt.Sprintf("%s_%d", prefix, page-1)})
+	}
+	if page > 0 || hasNext {
+		row = append(row, models.InlineKeyboardButton{Text: fmt.Sprintf("стр. %d", page+1), CallbackData: fmt.Sprintf("%s_%d", prefix, page)})
+	}
+	if hasNext {
+		row = append(row, models.InlineKeyboardButton{Text: "▶️", CallbackData: fmt.Sprintf("%s_%d", prefix, page+1)})
+	}
+	return row
+}
@@ -0,0 +1,35 @@
+package handler
+
+import "testing"
+
+func TestListPage(t *testing.T) {
+	cases := map[string]int{
+		"admin_promo_list":      0,
+		"admin_promo_list_3":    3,
+		"admin_promo_list_-1":   0,
+		"admin_promo_list_x":    0,
+		"admin_promo_delete_15": 0,
+		"admin_promo_list_0":    0,
+	}
+	for data, want := range cases {
+		if got := listPage(data, "admin_promo_list"); got != want {
+			t.Errorf("listPage(%q) = %d, want %d", data, got, want)
+		}
+	}
+}
+
+func TestPageNavigationRow(t *testing.T) {
+	if row := pageNavigationRow("admin_promo_list", 0, false); row != nil {
+		t.Fatalf("single page list must not have navigation, got %+v", row)
+	}
+
+	row := pageNavigationRow("admin_promo_list", 0, true)
+	if len(row) != 2 || row[1].CallbackData != "admin_promo_list_1" {
+		t.Fatalf("unexpected first page navigation %+v", row)
+	}
+
+	row = pageNavigationRow("admin_promo_list", 2, false)
+	if len(row) != 2 || row[0].CallbackData != "admin_promo_list_1" || row[1].Text != "стр. 3" {
+		t.Fatalf("unexpected last page navigation %+v", row)
+	}
+}
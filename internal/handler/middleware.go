@@ -13,7 +13,7 @@ import (
 	"remnawave-tg-shop-bot/utils"
 )
 
-func (h Handler) CreateCustomerIfNotExistMiddleware(next bot.HandlerFunc) bot.HandlerFunc {
+func (h ProfileHandlers) CreateCustomerIfNotExistMiddleware(next bot.HandlerFunc) bot.HandlerFunc {
 	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
 		var telegramId int64
 		var langCode string
@@ -26,7 +26,7 @@ func (h Handler) CreateCustomerIfNotExistMiddleware(next bot.HandlerFunc) bot.Ha
 		}
 		existingCustomer, err := h.customerRepository.FindByTelegramId(ctx, telegramId)
 		if err != nil {
-			slog.Error("error finding customer by telegram id", "error", err)
+			slog.ErrorContext(ctx, "error finding customer by telegram id", "error", err)
 			return
 		}
 
@@ -36,7 +36,7 @@ func (h Handler) CreateCustomerIfNotExistMiddleware(next bot.HandlerFunc) bot.Ha
 				Language:   langCode,
 			})
 			if err != nil {
-				slog.Error("error creating customer", "error", err)
+				slog.ErrorContext(ctx, "error creating customer", "error", err)
 				return
 			}
 		} else {
@@ -46,7 +46,7 @@ func (h Handler) CreateCustomerIfNotExistMiddleware(next bot.HandlerFunc) bot.Ha
 
 			err = h.customerRepository.UpdateFields(ctx, existingCustomer.ID, updates)
 			if err != nil {
-				slog.Error("Error updating customer", "error", err)
+				slog.ErrorContext(ctx, "Error updating customer", "error", err)
 				return
 			}
 		}
@@ -55,7 +55,7 @@ func (h Handler) CreateCustomerIfNotExistMiddleware(next bot.HandlerFunc) bot.Ha
 	}
 }
 
-func (h Handler) SuspiciousUserFilterMiddleware(next bot.HandlerFunc) bot.HandlerFunc {
+func (h ProfileHandlers) SuspiciousUserFilterMiddleware(next bot.HandlerFunc) bot.HandlerFunc {
 	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
 		var username, firstName, lastName *string
 		var userID int64
@@ -82,33 +82,33 @@ func (h Handler) SuspiciousUserFilterMiddleware(next bot.HandlerFunc) bot.Handle
 		}
 
 		if config.GetBlockedTelegramIds()[userID] {
-			slog.Warn("blocked user by telegram id", "userId", utils.MaskHalfInt64(userID))
+			slog.WarnContext(ctx, "blocked user by telegram id", "userId", utils.MaskHalfInt64(userID))
 			_, err := b.SendMessage(ctx, &bot.SendMessageParams{
 				ChatID:    chatID,
 				Text:      h.translation.GetText(langCode, "access_denied"),
 				ParseMode: models.ParseModeHTML,
 			})
 			if err != nil {
-				slog.Error("error sending blocked user message", "error", err)
+				slog.ErrorContext(ctx, "error sending blocked user message", "error", err)
 			}
 			return
 		}
 
 		if config.GetWhitelistedTelegramIds()[userID] {
-			slog.Info("whitelisted user allowed", "userId", utils.MaskHalfInt64(userID))
+			slog.InfoContext(ctx, "whitelisted user allowed", "userId", utils.MaskHalfInt64(userID))
 			next(ctx, b, update)
 			return
 		}
 
 		if utils.IsSuspiciousUser(username, firstName, lastName) {
-			slog.Warn("suspicious user blocked", "userId", utils.MaskHalfInt64(userID))
+			slog.WarnContext(ctx, "suspicious user blocked", "userId", utils.MaskHalfInt64(userID))
 			_, err := b.SendMessage(ctx, &bot.SendMessageParams{
 				ChatID:    chatID,
 				Text:      h.translation.GetText(langCode, "access_denied"),
 				ParseMode: models.ParseModeHTML,
 			})
 			if err != nil {
-				slog.Error("error sending suspicious user message", "error", err)
+				slog.ErrorContext(ctx, "error sending suspicious user message", "error", err)
 			}
 			return
 		}
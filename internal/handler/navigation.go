@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/cache"
+)
+
+const (
+	// navigationStackTTL время хранения истории экранов сообщения (секунды)
+	navigationStackTTL = 24 * 60 * 60
+	// navigationStackMaxDepth максимальная глубина истории экранов
+	navigationStackMaxDepth = 10
+)
+
+// navigationScreens callback-и, которые открывают экран меню и запоминаются в истории
+var navigationScreens = map[string]bool{
+	CallbackStart:               true,
+	CallbackBuy:                 true,
+	CallbackTariff:              true,
+	CallbackSell:                true,
+	CallbackConnect:             true,
+	CallbackReferral:            true,
+	CallbackTrial:               true,
+	CallbackPromo:               true,
+	CallbackPromoTariff:         true,
+	CallbackSavedPaymentMethods: true,
+	CallbackPayment:             true,
+	CallbackRecurringToggle:     true,
+	CallbackWinbackActivate:     true,
+	CallbackTeam:                true,
+	CallbackTeamSeats:           true,
+	CallbackTeamMonths:          true,
+	CallbackTeamPayment:         true,
+	CallbackTeamView:            true,
+	CallbackTeamAskRevoke:       true,
+	CallbackFaq:                 true,
+	CallbackFaqCategory:         true,
+	CallbackFaqEntry:            true,
+	CallbackServerStatus:        true,
+}
+
+// transientScreens экраны, которые нельзя открыть повторно (создают новый счёт) — "Назад" их пропускает
+var transientScreens = map[string]bool{
+	CallbackPayment:         true,
+	CallbackRecurringToggle: true,
+	CallbackWinbackActivate: true,
+	CallbackTeamPayment:     true,
+}
+
+// callbackScreen возвращает имя экрана из callback data ("sell?month=1" → "sell")
+func callbackScreen(data string) string {
+	screen, _, _ := strings.Cut(data, "?")
+	return screen
+}
+
+// navigationStack история экранов сообщения (callback data), последний элемент — текущий экран
+type navigationStack []string
+
+// push добавляет открытый экран. Главное меню сбрасывает историю, а повторное открытие
+// экрана из истории (например, старой кнопкой "Назад") обрезает её до этого экрана
+func (s navigationStack) push(data string) navigationStack {
+	if callbackScreen(data) == CallbackStart {
+		return navigationStack{data}
+	}
+	for i, entry := range s {
+		if entry == data {
+			return s[:i+1]
+		}
+	}
+	s = append(s, data)
+	if len(s) > navigationStackMaxDepth {
+		s = s[len(s)-navigationStackMaxDepth:]
+	}
+	return s
+}
+
+// back убирает текущий экран и возвращает предыдущий, который можно открыть повторно.
+// Пустая строка — истории нет, нужно вернуться в главное меню
+func (s navigationStack) back() (string, navigationStack) {
+	if len(s) > 0 {
+		s = s[:len(s)-1]
+	}
+	for len(s) > 0 && transientScreens[callbackScreen(s[len(s)-1])] {
+		s = s[:len(s)-1]
+	}
+	if len(s) == 0 {
+		return "", nil
+	}
+	return s[len(s)-1], s
+}
+
+// Navigation хранит для каждого сообщения историю открытых в нём экранов, чтобы кнопка "Назад"
+// возвращала на предыдущий экран с теми же параметрами (тариф, период, способ оплаты)
+type Navigation struct {
+	cache *cache.Cache
+}
+
+func NewNavigation(cache *cache.Cache) *Navigation {
+	return &Navigation{cache: cache}
+}
+
+// Middleware запоминает экраны, открытые через callback-кнопки
+func (n *Navigation) Middleware(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		if key, ok := navigationKey(update); ok && navigationScreens[callbackScreen(update.CallbackQuery.Data)] {
+			n.save(key, n.load(key).push(update.CallbackQuery.Data))
+		}
+		next(ctx, b, update)
+	}
+}
+
+// BackCallbackHandler открывает предыдущий экран сообщения, повторно обрабатывая его callback.
+// Если история не сохранилась (например, после перезапуска) — открывает главное меню
+func (n *Navigation) BackCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	target := CallbackStart
+	if key, ok := navigationKey(update); ok {
+		previous, stack := n.load(key).back()
+		n.save(key, stack)
+		if previous != "" {
+			target = previous
+		}
+	}
+
+	callbackQuery := *update.CallbackQuery
+	callbackQuery.Data = target
+	replay := *update
+	replay.CallbackQuery = &callbackQuery
+	b.ProcessUpdate(ctx, &replay)
+}
+
+func navigationKey(update *models.Update) (string, bool) {
+	if update.CallbackQuery == nil || update.CallbackQuery.Message.Message == nil {
+		return "", false
+	}
+	message := update.CallbackQuery.Message.Message
+	return fmt.Sprintf("nav_%d_%d", message.Chat.ID, message.ID), true
+}
+
+func (n *Navigation) load(key string) navigationStack {
+	value, ok := n.cache.GetString(key)
+	if !ok || value == "" {
+		return nil
+	}
+	return strings.Split(value, "\n")
+}
+
+func (n *Navigation) save(key string, stack navigationStack) {
+	if len(stack) == 0 {
+		n.cache.Delete(key)
+		return
+	}
+	n.cache.SetString(key, strings.Join(stack, "\n"), navigationStackTTL)
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNavigationStackPush(t *testing.T) {
+	var s navigationStack
+	s = s.push(CallbackBuy)
+	s = s.push("tariff?name=PRO")
+	s = s.push("sell?month=3&tariff=PRO")
+
+	want := navigationStack{CallbackBuy, "tariff?name=PRO", "sell?month=3&tariff=PRO"}
+	if !reflect.DeepEqual(s, want) {
+		t.Fatalf("unexpected stack: %v", s)
+	}
+
+	// Повторное открытие экрана из истории обрезает её
+	if got := s.push("tariff?name=PRO"); !reflect.DeepEqual(got, navigationStack{CallbackBuy, "tariff?name=PRO"}) {
+		t.Errorf("Expected stack to be truncated, got %v", got)
+	}
+
+	// Главное меню сбрасывает историю
+	if got := s.push(CallbackStart); !reflect.DeepEqual(got, navigationStack{CallbackStart}) {
+		t.Errorf("Expected stack to be reset, got %v", got)
+	}
+}
+
+func TestNavigationStackMaxDepth(t *testing.T) {
+	var s navigationStack
+	for i := 0; i < navigationStackMaxDepth+5; i++ {
+		s = s.push(CallbackSell + "?month=" + string(rune('a'+i)))
+	}
+	if len(s) != navigationStackMaxDepth {
+		t.Errorf("Expected stack depth %d, got %d", navigationStackMaxDepth, len(s))
+	}
+}
+
+func TestNavigationStackBack(t *testing.T) {
+	s := navigationStack{CallbackBuy, "tariff?name=PRO", "sell?month=1&tariff=PRO", "payment?m=1&t=yookasa&n=PRO", "recurring_toggle?m=1&t=yookasa&r=1"}
+
+	// Экраны оплаты создают новый счёт, поэтому "Назад" возвращает к выбору способа оплаты
+	target, s := s.back()
+	if target != "sell?month=1&tariff=PRO" {
+		t.Fatalf("Expected to return to payment methods, got %q", target)
+	}
+
+	target, s = s.back()
+	if target != "tariff?name=PRO" {
+		t.Fatalf("Expected to return to tariff, got %q", target)
+	}
+
+	target, s = s.back()
+	if target != CallbackBuy {
+		t.Fatalf("Expected to return to tariffs list, got %q", target)
+	}
+
+	// Истории больше нет — возврат в главное меню
+	if target, s = s.back(); target != "" || len(s) != 0 {
+		t.Errorf("Expected empty history, got %q %v", target, s)
+	}
+}
+
+func TestCallbackScreen(t *testing.T) {
+	if got := callbackScreen("sell?month=1"); got != CallbackSell {
+		t.Errorf("Expected %q, got %q", CallbackSell, got)
+	}
+	if got := callbackScreen(CallbackBuy); got != CallbackBuy {
+		t.Errorf("Expected %q, got %q", CallbackBuy, got)
+	}
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// notificationTemplateRegex допустимые шаблоны уведомлений: ключи переводов. Callback data присылает клиент,
+// поэтому в отчёт не должен попасть произвольный текст
+var notificationTemplateRegex = regexp.MustCompile(`^[a-z0-9_]{1,40}$`)
+
+// notificationFeedbackPeriods периоды отчёта о качестве уведомлений в днях
+var notificationFeedbackPeriods = []int{7, 30}
+
+// WithNotificationFeedback добавляет под клавиатуру уведомления ряд кнопок 👍/👎, если оценка уведомлений включена.
+// template — ключ перевода текста уведомления: по нему оценки группируются в отчёте
+func WithNotificationFeedback(keyboard [][]models.InlineKeyboardButton, template string) [][]models.InlineKeyboardButton {
+	if !config.IsNotificationFeedbackEnabled() {
+		return keyboard
+	}
+	return append(keyboard, []models.InlineKeyboardButton{
+		{Text: "👍", CallbackData: fmt.Sprintf("%s?t=%s&v=1", CallbackNotificationFeedback, template)},
+		{Text: "👎", CallbackData: fmt.Sprintf("%s?t=%s&v=0", CallbackNotificationFeedback, template)},
+	})
+}
+
+// NotificationFeedbackCallbackHandler сохраняет оценку уведомления (notif_feedback?t=<шаблон>&v=<1|0>)
+// и убирает кнопки оценки, оставляя остальные кнопки сообщения
+func (h NotificationFeedbackHandlers) NotificationFeedbackCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	params := parseCallbackData(update.CallbackQuery.Data)
+	msg := update.CallbackQuery.Message.Message
+	template := params["t"]
+	if !notificationTemplateRegex.MatchString(template) || msg == nil {
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+		return
+	}
+
+	_, err := h.feedback.Save(ctx, update.CallbackQuery.From.ID, msg.ID, template, params["v"] == "1", clock.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving notification feedback", "error", err)
+	}
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            h.translation.GetText(update.CallbackQuery.From.LanguageCode, "notification_feedback_thanks"),
+	})
+	if err != nil {
+		return
+	}
+
+	var keyboard [][]models.InlineKeyboardButton
+	if msg.ReplyMarkup != nil {
+		keyboard = withoutNotificationFeedback(msg.ReplyMarkup.InlineKeyboard)
+	}
+	_, err = b.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+		ChatID:      msg.Chat.ID,
+		MessageID:   msg.ID,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error removing notification feedback buttons", "error", err)
+	}
+}
+
+// withoutNotificationFeedback возвращает клавиатуру без рядов с кнопками оценки
+func withoutNotificationFeedback(keyboard [][]models.InlineKeyboardButton) [][]models.InlineKeyboardButton {
+	result := make([][]models.InlineKeyboardButton, 0, len(keyboard))
+	for _, row := range keyboard {
+		if len(row) > 0 && strings.HasPrefix(row[0].CallbackData, CallbackNotificationFeedback+"?") {
+			continue
+		}
+		result = append(result, row)
+	}
+	return result
+}
+
+// AdminNotificationFeedbackCallback показывает оценки уведомлений по шаблонам
+func (h NotificationFeedbackHandlers) AdminNotificationFeedbackCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	msg := update.CallbackQuery.Message.Message
+
+	stats := make([][]database.NotificationFeedbackStats, 0, len(notificationFeedbackPeriods))
+	for _, days := range notificationFeedbackPeriods {
+		st, err := h.feedback.Stats(ctx, clock.Now().AddDate(0, 0, -days))
+		if err != nil {
+			slog.ErrorContext(ctx, "Error loading notification feedback stats", "error", err)
+			return
+		}
+		stats = append(stats, st)
+	}
+
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		Text:      formatNotificationFeedback(config.IsNotificationFeedbackEnabled(), stats),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: "🔄 Обновить", CallbackData: "admin_notification_feedback"}},
+			{{Text: "🔙 Назад", CallbackData: "admin_back"}},
+		}},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing notification feedback message", "error", err)
+	}
+}
+
+// formatNotificationFeedback форматирует отчёт о качестве уведомлений; stats идут в порядке notificationFeedbackPeriods
+func formatNotificationFeedback(enabled bool, stats [][]database.NotificationFeedbackStats) string {
+	var sb strings.Builder
+	sb.WriteString("📈 <b>Качество уведомлений</b>\n")
+	if enabled {
+		sb.WriteString("Кнопки 👍/👎 показываются под winback и маркетинговыми уведомлениями\n")
+	} else {
+		sb.WriteString("Оценка выключена (NOTIFICATION_FEEDBACK_ENABLED)\n")
+	}
+
+	for i, period := range stats {
+		sb.WriteString(fmt.Sprintf("\n<b>За %d дн.</b>\n", notificationFeedbackPeriods[i]))
+		if len(period) == 0 {
+			sb.WriteString("оценок нет\n")
+			continue
+		}
+		for _, s := range period {
+			sb.WriteString(utils.FormatHTML("<code>%s</code>: 👍 %d, 👎 %d (%.0f%% полезно)\n",
+				s.Template, s.Useful, s.NotUseful, s.UsefulShare()))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+type mockNotificationFeedbackStore struct {
+	notificationFeedbackStore
+	saved []string
+}
+
+func (m *mockNotificationFeedbackStore) Save(_ context.Context, _ int64, _ int, template string, useful bool, _ time.Time) (bool, error) {
+	vote := "👎"
+	if useful {
+		vote = "👍"
+	}
+	m.saved = append(m.saved, template+" "+vote)
+	return true, nil
+}
+
+func enableNotificationFeedback(t *testing.T) {
+	config.SetFeatureOverride(config.FeatureNotificationFeedback, true)
+	t.Cleanup(func() { config.ClearFeatureOverride(config.FeatureNotificationFeedback) })
+}
+
+func TestWithNotificationFeedback(t *testing.T) {
+	keyboard := [][]models.InlineKeyboardButton{{{Text: "Купить", CallbackData: CallbackBuy}}}
+	if got := WithNotificationFeedback(keyboard, "winback_offer"); len(got) != 1 {
+		t.Fatalf("Expected no feedback row when disabled, got %+v", got)
+	}
+
+	enableNotificationFeedback(t)
+	got := WithNotificationFeedback(keyboard, "winback_offer")
+	if len(got) != 2 || got[1][0].CallbackData != "notif_feedback?t=winback_offer&v=1" || got[1][1].CallbackData != "notif_feedback?t=winback_offer&v=0" {
+		t.Errorf("Expected feedback row under the keyboard, got %+v", got)
+	}
+}
+
+func TestNotificationFeedbackCallbackHandler(t *testing.T) {
+	enableNotificationFeedback(t)
+	b, tg := newTestBot(t)
+	store := &mockNotificationFeedbackStore{}
+	h := NewNotificationFeedbackHandlers(fakeTranslator{}, newFakeCache(), store)
+
+	update := paymentCallbackUpdate(CallbackNotificationFeedback + "?t=offer_reminder_winback&v=0")
+	update.CallbackQuery.Message.Message.ReplyMarkup = &models.InlineKeyboardMarkup{
+		InlineKeyboard: WithNotificationFeedback([][]models.InlineKeyboardButton{{{Text: "Активировать", CallbackData: CallbackWinbackActivate}}}, "offer_reminder_winback"),
+	}
+	h.NotificationFeedbackCallbackHandler(context.Background(), b, update)
+
+	if len(store.saved) != 1 || store.saved[0] != "offer_reminder_winback 👎" {
+		t.Fatalf("Expected negative feedback to be saved, got %v", store.saved)
+	}
+	edits := tg.called("editMessageReplyMarkup")
+	if len(edits) != 1 || strings.Contains(edits[0].params["reply_markup"], CallbackNotificationFeedback) ||
+		!strings.Contains(edits[0].params["reply_markup"], CallbackWinbackActivate) {
+		t.Errorf("Expected feedback buttons to be removed and other buttons kept, got %v", edits)
+	}
+}
+
+func TestNotificationFeedbackCallbackHandlerRejectsUnknownTemplate(t *testing.T) {
+	b, _ := newTestBot(t)
+	store := &mockNotificationFeedbackStore{}
+	h := NewNotificationFeedbackHandlers(fakeTranslator{}, newFakeCache(), store)
+
+	h.NotificationFeedbackCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackNotificationFeedback+"?t=<b>spam</b>&v=1"))
+
+	if len(store.saved) != 0 {
+		t.Errorf("Expected arbitrary template to be ignored, got %v", store.saved)
+	}
+}
+
+func TestFormatNotificationFeedback(t *testing.T) {
+	text := formatNotificationFeedback(true, [][]database.NotificationFeedbackStats{
+		{{Template: "winback_offer", Useful: 3, NotUseful: 1}},
+		nil,
+	})
+
+	for _, want := range []string{
+		"<code>winback_offer</code>: 👍 3, 👎 1 (75% полезно)",
+		"<b>За 30 дн.</b>\nоценок нет",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in report, got:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// Настройки уведомлений, которые клиент может отключить. Транзакционные сообщения (оплата, окончание подписки)
+// не отключаются
+const (
+	notificationMarketing = "marketing"
+	notificationWinback   = "winback"
+)
+
+// notificationColumns колонка customer с моментом отказа для каждой настройки
+var notificationColumns = map[string]string{
+	notificationMarketing: "marketing_opted_out_at",
+	notificationWinback:   "winback_opted_out_at",
+}
+
+// notificationsButtonRow строка с кнопкой настроек уведомлений для экрана подключения
+func (h ProfileHandlers) notificationsButtonRow(langCode string) []models.InlineKeyboardButton {
+	return []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "notifications_button"), CallbackData: CallbackNotifications}}
+}
+
+// notificationsMenu возвращает текст и клавиатуру настроек уведомлений: ✅ — сообщения приходят, 🔕 — отключены
+func (h ProfileHandlers) notificationsMenu(customer *database.Customer, langCode string) (string, models.InlineKeyboardMarkup) {
+	toggle := func(kind, key string, optedOutAt *time.Time) []models.InlineKeyboardButton {
+		mark := "✅ "
+		if optedOutAt != nil {
+			mark = "🔕 "
+		}
+		return []models.InlineKeyboardButton{{Text: mark + h.translation.GetText(langCode, key), CallbackData: CallbackNotificationsToggle + "?k=" + kind}}
+	}
+	keyboard := [][]models.InlineKeyboardButton{
+		toggle(notificationMarketing, "notifications_marketing", customer.MarketingOptedOutAt),
+		toggle(notificationWinback, "notifications_winback", customer.WinbackOptedOutAt),
+		{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackConnect}},
+	}
+	return h.translation.GetText(langCode, "notifications_menu"), models.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+}
+
+// NotificationsCommandHandler обрабатывает /notifications: показывает настройки уведомлений
+func (h ProfileHandlers) NotificationsCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	customer, err := h.customerRepository.FindByTelegramId(ctx, update.Message.Chat.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for notification settings", "error", err)
+		return
+	}
+
+	text, keyboard := h.notificationsMenu(customer, update.Message.From.LanguageCode)
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      update.Message.Chat.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: keyboard,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending notification settings", "error", err)
+	}
+}
+
+// NotificationsCallbackHandler показывает настройки уведомлений вместо экрана подключения
+func (h ProfileHandlers) NotificationsCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	callback := update.CallbackQuery.Message.Message
+	customer, err := h.customerRepository.FindByTelegramId(ctx, callback.Chat.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for notification settings", "error", err)
+		return
+	}
+	h.editNotificationsMenu(ctx, b, callback, customer, update.CallbackQuery.From.LanguageCode)
+}
+
+// NotificationsToggleCallbackHandler включает или отключает выбранный вид сообщений
+func (h ProfileHandlers) NotificationsToggleCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	kind := parseCallbackData(update.CallbackQuery.Data)["k"]
+	column, ok := notificationColumns[kind]
+	if !ok {
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+		return
+	}
+
+	customer, err := h.customerRepository.FindByTelegramId(ctx, callback.Chat.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for notification settings", "error", err)
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+		return
+	}
+
+	field := &customer.MarketingOptedOutAt
+	if kind == notificationWinback {
+		field = &customer.WinbackOptedOutAt
+	}
+	var optedOutAt *time.Time
+	if *field == nil {
+		now := clock.Now()
+		optedOutAt = &now
+	}
+	if err := h.customerRepository.UpdateFields(ctx, customer.ID, map[string]interface{}{column: optedOutAt}); err != nil {
+		slog.ErrorContext(ctx, "Error saving notification settings", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+		return
+	}
+	*field = optedOutAt
+
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            h.translation.GetText(langCode, "notifications_saved"),
+	})
+	h.editNotificationsMenu(ctx, b, callback, customer, langCode)
+}
+
+func (h ProfileHandlers) editNotificationsMenu(ctx context.Context, b *bot.Bot, callback *models.Message, customer *database.Customer, langCode string) {
+	text, keyboard := h.notificationsMenu(customer, langCode)
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      callback.Chat.ID,
+		MessageID:   callback.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: keyboard,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending notification settings", "error", err)
+	}
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+// mockProfileCustomers клиент для экранов профиля, сохраняет последние изменения полей
+type mockProfileCustomers struct {
+	customer *database.Customer
+	updates  []map[string]interface{}
+}
+
+func (m *mockProfileCustomers) FindByTelegramId(context.Context, int64) (*database.Customer, error) {
+	return m.customer, nil
+}
+
+func (m *mockProfileCustomers) FindById(context.Context, int64) (*database.Customer, error) {
+	return m.customer, nil
+}
+
+func (m *mockProfileCustomers) Create(_ context.Context, customer *database.Customer) (*database.Customer, error) {
+	return customer, nil
+}
+
+func (m *mockProfileCustomers) UpdateFields(_ context.Context, _ int64, updates map[string]interface{}) error {
+	m.updates = append(m.updates, updates)
+	return nil
+}
+
+func (m *mockProfileCustomers) AcceptTos(context.Context, int64, string, time.Time) error {
+	return nil
+}
+
+func (m *mockProfileCustomers) IsPhoneVerified(context.Context, int64) (bool, error) {
+	return false, nil
+}
+
+func (m *mockProfileCustomers) SavePhoneHash(context.Context, int64, string, time.Time) error {
+	return nil
+}
+
+func notificationsToggleUpdate(kind string) *models.Update {
+	return &models.Update{CallbackQuery: &models.CallbackQuery{
+		ID:   "cb",
+		From: models.User{ID: 42, LanguageCode: "ru"},
+		Data: CallbackNotificationsToggle + "?k=" + kind,
+		Message: models.MaybeInaccessibleMessage{
+			Message: &models.Message{ID: 1, Chat: models.Chat{ID: 42}},
+		},
+	}}
+}
+
+func TestNotificationsToggle(t *testing.T) {
+	b, tg := newTestBot(t)
+	customers := &mockProfileCustomers{customer: &database.Customer{ID: 7, TelegramID: 42}}
+	h := ProfileHandlers{base: base{translation: fakeTranslator{}, cache: newFakeCache()}, customerRepository: customers}
+	ctx := context.Background()
+
+	h.NotificationsToggleCallbackHandler(ctx, b, notificationsToggleUpdate(notificationMarketing))
+	if len(customers.updates) != 1 || customers.updates[0]["marketing_opted_out_at"].(*time.Time) == nil {
+		t.Fatalf("Expected marketing opt-out to be saved, got %v", customers.updates)
+	}
+	if customers.customer.MarketingOptedOutAt == nil || customers.customer.WinbackOptedOutAt != nil {
+		t.Fatalf("Expected only marketing to be muted, got %+v", customers.customer)
+	}
+	edits := tg.called("editMessageText")
+	if len(edits) != 1 {
+		t.Fatalf("Expected menu to be redrawn, got %d edits", len(edits))
+	}
+	edit := edits[0]
+	if !strings.Contains(edit.params["reply_markup"], "🔕 notifications_marketing") ||
+		!strings.Contains(edit.params["reply_markup"], "✅ notifications_winback") {
+		t.Errorf("Expected menu to show muted marketing, got %s", edit.params["reply_markup"])
+	}
+
+	h.NotificationsToggleCallbackHandler(ctx, b, notificationsToggleUpdate(notificationMarketing))
+	if len(customers.updates) != 2 || customers.updates[1]["marketing_opted_out_at"].(*time.Time) != nil {
+		t.Fatalf("Expected marketing opt-out to be cleared, got %v", customers.updates)
+	}
+
+	h.NotificationsToggleCallbackHandler(ctx, b, notificationsToggleUpdate("payments"))
+	if len(customers.updates) != 2 {
+		t.Errorf("Transactional messages must not be toggled, got %v", customers.updates)
+	}
+}
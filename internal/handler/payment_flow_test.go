@@ -0,0 +1,316 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/payment"
+)
+
+type mockPaymentCustomers struct {
+	customer    *database.Customer
+	confirmedAt *time.Time
+}
+
+func (m *mockPaymentCustomers) FindByTelegramId(ctx context.Context, telegramId int64) (*database.Customer, error) {
+	return m.customer, nil
+}
+
+func (m *mockPaymentCustomers) DisableRecurring(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *mockPaymentCustomers) DeletePaymentMethod(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *mockPaymentCustomers) AcceptRecurringPriceChange(ctx context.Context, id int64, at time.Time) (bool, error) {
+	return false, nil
+}
+
+func (m *mockPaymentCustomers) ConfirmRecurringCharge(ctx context.Context, id int64, at time.Time) (bool, error) {
+	if m.customer == nil || !m.customer.RecurringEnabled || m.customer.RecurringConfirmRequestedAt == nil {
+		return false, nil
+	}
+	m.confirmedAt = &at
+	return true, nil
+}
+
+// createdPurchase параметры счёта, переданные платёжному сервису
+type createdPurchase struct {
+	amount      float64
+	months      int
+	days        int
+	invoiceType database.InvoiceType
+	deviceLimit *int
+}
+
+// mockPurchaseService реализует purchaseService: записывает созданные счета
+type mockPurchaseService struct {
+	purchaseService
+	createErr     error
+	created       []createdPurchase
+	tariffNames   []*string
+	savedMessages map[int64]int
+}
+
+func (m *mockPurchaseService) CreatePeriodPurchase(ctx context.Context, amount float64, period config.Period, customer *database.Customer, invoiceType database.InvoiceType, tariffName *string, deviceLimit *int, savePaymentMethod bool) (string, int64, error) {
+	m.created = append(m.created, createdPurchase{amount: amount, months: period.Months, days: period.Days, invoiceType: invoiceType, deviceLimit: deviceLimit})
+	m.tariffNames = append(m.tariffNames, tariffName)
+	if m.createErr != nil {
+		return "", 0, m.createErr
+	}
+	return "https://pay.example/invoice", 55, nil
+}
+
+func (m *mockPurchaseService) SavePurchaseMessage(ctx context.Context, purchaseID int64, messageID int) {
+	if m.savedMessages == nil {
+		m.savedMessages = map[int64]int{}
+	}
+	m.savedMessages[purchaseID] = messageID
+}
+
+func paymentCallbackUpdate(data string) *models.Update {
+	return &models.Update{CallbackQuery: &models.CallbackQuery{
+		ID:   "cb",
+		Data: data,
+		From: models.User{ID: 42, LanguageCode: "ru"},
+		Message: models.MaybeInaccessibleMessage{
+			Type:    models.MaybeInaccessibleMessageTypeMessage,
+			Message: &models.Message{ID: 5, Chat: models.Chat{ID: 42}},
+		},
+	}}
+}
+
+func TestPaymentCallbackHandler(t *testing.T) {
+	winbackPrice, winbackMonths, winbackDevices := 300, 3, 2
+	customer := &database.Customer{ID: 7, TelegramID: 42}
+	winbackCustomer := &database.Customer{ID: 7, TelegramID: 42,
+		WinbackOfferPrice: &winbackPrice, WinbackOfferMonths: &winbackMonths, WinbackOfferDevices: &winbackDevices}
+
+	tests := []struct {
+		name        string
+		data        string
+		customer    *database.Customer
+		createErr   error
+		want        *createdPurchase
+		wantMessage string
+	}{
+		{"price from config", CallbackPayment + "?m=3&t=crypto", customer, nil,
+			&createdPurchase{amount: 1200, months: 3, invoiceType: database.InvoiceTypeCrypto}, ""},
+		// Сумма из callback data не используется
+		{"amount in callback is ignored", CallbackPayment + "?m=1&t=yookasa&amount=1", customer, nil,
+			&createdPurchase{amount: 500, months: 1, invoiceType: database.InvoiceTypeYookasa}, ""},
+		{"winback offer parameters", CallbackPayment + "?m=1&t=yookasa&w=1", winbackCustomer, nil,
+			&createdPurchase{amount: 300, months: 3, invoiceType: database.InvoiceTypeYookasa, deviceLimit: &winbackDevices}, ""},
+		{"winback without offer", CallbackPayment + "?m=1&t=yookasa&w=1", customer, nil, nil, ""},
+		{"unsupported month", CallbackPayment + "?m=2&t=crypto", customer, nil, nil, ""},
+		// Посуточный план есть только у тарифа
+		{"day period without tariff", CallbackPayment + "?m=7d&t=crypto", customer, nil, nil, ""},
+		{"unknown customer", CallbackPayment + "?m=1&t=crypto", nil, nil, nil, ""},
+		{"purchase limit exceeded", CallbackPayment + "?m=1&t=crypto", customer, payment.ErrPurchaseLimitExceeded,
+			&createdPurchase{amount: 500, months: 1, invoiceType: database.InvoiceTypeCrypto}, "purchase_limit_exceeded"},
+		{"amount outside provider limits", CallbackPayment + "?m=1&t=yookasa&w=1", winbackCustomer, payment.ErrInvalidPaymentAmount,
+			&createdPurchase{amount: 300, months: 3, invoiceType: database.InvoiceTypeYookasa, deviceLimit: &winbackDevices}, "payment_method_unavailable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, tg := newTestBot(t)
+			purchases := &mockPurchaseService{createErr: tt.createErr}
+			h := NewPaymentHandlers(fakeTranslator{}, newFakeCache(), &mockPaymentCustomers{customer: tt.customer}, nil, purchases, nil, nil, nil)
+
+			h.PaymentCallbackHandler(context.Background(), b, paymentCallbackUpdate(tt.data))
+
+			if tt.want == nil {
+				if len(purchases.created) != 0 {
+					t.Fatalf("Expected no purchase, got %+v", purchases.created)
+				}
+				if len(tg.called("editMessageReplyMarkup")) != 0 {
+					t.Error("Expected payment keyboard not to be shown")
+				}
+				return
+			}
+			if len(purchases.created) != 1 {
+				t.Fatalf("Expected 1 purchase, got %d", len(purchases.created))
+			}
+			got := purchases.created[0]
+			if got.amount != tt.want.amount || got.months != tt.want.months || got.days != tt.want.days || got.invoiceType != tt.want.invoiceType {
+				t.Errorf("Expected purchase %+v, got %+v", *tt.want, got)
+			}
+			if (tt.want.deviceLimit == nil) != (got.deviceLimit == nil) || (got.deviceLimit != nil && *got.deviceLimit != *tt.want.deviceLimit) {
+				t.Errorf("Expected device limit %v, got %v", tt.want.deviceLimit, got.deviceLimit)
+			}
+
+			if tt.wantMessage != "" {
+				sent := tg.called("sendMessage")
+				if len(sent) != 1 || sent[0].params["text"] != tt.wantMessage {
+					t.Fatalf("Expected message %q, got %+v", tt.wantMessage, sent)
+				}
+				return
+			}
+			edits := tg.called("editMessageReplyMarkup")
+			if len(edits) != 1 || !strings.Contains(edits[0].params["reply_markup"], "https://pay.example/invoice") {
+				t.Fatalf("Expected pay button with invoice URL, got %+v", edits)
+			}
+			if purchases.savedMessages[55] != 1 {
+				t.Errorf("Expected purchase message to be saved, got %v", purchases.savedMessages)
+			}
+		})
+	}
+}
+
+func TestPaymentCallbackHandlerDayPlan(t *testing.T) {
+	t.Cleanup(config.InitConfig)
+	t.Setenv("TARIFF_WEEK_ENABLED", "true")
+	t.Setenv("TARIFF_WEEK_DEVICES", "2")
+	t.Setenv("TARIFF_WEEK_PRICE_1", "300")
+	t.Setenv("TARIFF_WEEK_PRICE_3", "800")
+	t.Setenv("TARIFF_WEEK_PRICE_6", "1500")
+	t.Setenv("TARIFF_WEEK_PRICE_12", "2800")
+	t.Setenv("TARIFF_WEEK_PRICE_7D", "99")
+	config.InitConfig()
+
+	b, _ := newTestBot(t)
+	purchases := &mockPurchaseService{}
+	h := NewPaymentHandlers(fakeTranslator{}, newFakeCache(), &mockPaymentCustomers{customer: &database.Customer{ID: 7, TelegramID: 42}}, nil, purchases, nil, nil, nil)
+
+	h.PaymentCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackPayment+"?m=7d&t=yookasa&n=WEEK"))
+	h.PaymentCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackPayment+"?m=14d&t=yookasa&n=WEEK"))
+
+	want := createdPurchase{amount: 99, days: 7, invoiceType: database.InvoiceTypeYookasa}
+	if len(purchases.created) != 1 || purchases.created[0] != want {
+		t.Fatalf("Expected only the weekly purchase %+v, got %+v", want, purchases.created)
+	}
+	if amount, ok := periodPaymentAmount(database.InvoiceTypeYookasa, "7d", "WEEK"); !ok || amount != 99 {
+		t.Errorf("Expected weekly card amount 99, got %d (ok %v)", amount, ok)
+	}
+}
+
+// Подделанное имя тарифа не оплачивается по глобальной цене и не сохраняется в покупке
+func TestPaymentCallbackHandlerRejectsForgedTariff(t *testing.T) {
+	customers := &mockPaymentCustomers{customer: &database.Customer{ID: 7, TelegramID: 42}}
+
+	b, _ := newTestBot(t)
+	purchases := &mockPurchaseService{}
+	h := NewPaymentHandlers(fakeTranslator{}, newFakeCache(), customers, nil, purchases, nil, nil, nil)
+	h.PaymentCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackPayment+"?m=1&t=yookasa&n=FORGED"))
+	if len(purchases.created) != 1 || purchases.created[0].amount != 500 || purchases.tariffNames[0] != nil {
+		t.Fatalf("Expected legacy purchase without tariff name when no tariffs are configured, got %+v %v", purchases.created, purchases.tariffNames)
+	}
+
+	t.Cleanup(config.InitConfig)
+	t.Setenv("TARIFF_BASIC_ENABLED", "true")
+	t.Setenv("TARIFF_BASIC_DEVICES", "2")
+	t.Setenv("TARIFF_BASIC_PRICE_1", "300")
+	t.Setenv("TARIFF_BASIC_PRICE_3", "800")
+	t.Setenv("TARIFF_BASIC_PRICE_6", "1500")
+	t.Setenv("TARIFF_BASIC_PRICE_12", "2800")
+	config.InitConfig()
+
+	purchases = &mockPurchaseService{}
+	h = NewPaymentHandlers(fakeTranslator{}, newFakeCache(), customers, nil, purchases, nil, nil, nil)
+	h.PaymentCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackPayment+"?m=1&t=yookasa&n=FORGED"))
+	if len(purchases.created) != 0 {
+		t.Fatalf("Expected forged tariff to be rejected, got %+v", purchases.created)
+	}
+
+	h.PaymentCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackPayment+"?m=1&t=yookasa&n=BASIC"))
+	if len(purchases.created) != 1 || purchases.created[0].amount != 300 || purchases.tariffNames[0] == nil || *purchases.tariffNames[0] != "BASIC" {
+		t.Errorf("Expected BASIC purchase for 300, got %+v %v", purchases.created, purchases.tariffNames)
+	}
+}
+
+func TestPaymentCallbackHandlerAppliesPaymentFee(t *testing.T) {
+	t.Cleanup(config.InitConfig)
+	t.Setenv("PAYMENT_FEE_CRYPTO_PERCENT", "10")
+	config.InitConfig()
+
+	winbackPrice := 300
+	tests := []struct {
+		name     string
+		data     string
+		customer *database.Customer
+		want     float64
+	}{
+		{"surcharge on crypto", CallbackPayment + "?m=3&t=crypto", &database.Customer{ID: 7, TelegramID: 42}, 1320},
+		{"no fee on card", CallbackPayment + "?m=3&t=yookasa", &database.Customer{ID: 7, TelegramID: 42}, 1200},
+		// Цена предложения не меняется: клиент платит сумму из уведомления
+		{"winback offer keeps its price", CallbackPayment + "?m=1&t=crypto&w=1", &database.Customer{ID: 7, TelegramID: 42, WinbackOfferPrice: &winbackPrice}, 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newTestBot(t)
+			purchases := &mockPurchaseService{}
+			h := NewPaymentHandlers(fakeTranslator{}, newFakeCache(), &mockPaymentCustomers{customer: tt.customer}, nil, purchases, nil, nil, nil)
+
+			h.PaymentCallbackHandler(context.Background(), b, paymentCallbackUpdate(tt.data))
+
+			if len(purchases.created) != 1 || purchases.created[0].amount != tt.want {
+				t.Fatalf("Expected purchase for %v, got %+v", tt.want, purchases.created)
+			}
+		})
+	}
+
+	amount, ok := periodPaymentAmount(database.InvoiceTypeCrypto, "3", "")
+	if !ok || amount != 1320 {
+		t.Fatalf("Expected crypto amount 1320, got %d (ok %v)", amount, ok)
+	}
+	if got, want := paymentMethodButtonText("crypto_button", "ru", database.InvoiceTypeCrypto, amount), "crypto_button — "+locale.FormatMoney("ru", 1320); got != want {
+		t.Errorf("Expected button %q, got %q", want, got)
+	}
+	// Без цены в звёздах кнопка Stars не показывается
+	if _, ok := periodPaymentAmount(database.InvoiceTypeTelegram, "1", ""); ok {
+		t.Error("Expected stars button to be hidden without stars price")
+	}
+}
+
+// Тариф только для карт: способы вне TARIFF_<NAME>_PAYMENT_METHODS скрыты в меню и отклоняются в подделанном callback
+func TestPaymentCallbackHandlerRespectsTariffPaymentMethods(t *testing.T) {
+	t.Cleanup(config.InitConfig)
+	t.Setenv("TARIFF_CARD_ENABLED", "true")
+	t.Setenv("TARIFF_CARD_DEVICES", "2")
+	t.Setenv("TARIFF_CARD_PRICE_1", "300")
+	t.Setenv("TARIFF_CARD_PRICE_3", "800")
+	t.Setenv("TARIFF_CARD_PRICE_6", "1500")
+	t.Setenv("TARIFF_CARD_PRICE_12", "2800")
+	t.Setenv("TARIFF_CARD_PAYMENT_METHODS", "card")
+	t.Setenv("YOOKASA_ENABLED", "true")
+	t.Setenv("YOOKASA_URL", "https://api.yookassa.ru/v3")
+	t.Setenv("YOOKASA_SHOP_ID", "shop")
+	t.Setenv("YOOKASA_SECRET_KEY", "secret")
+	t.Setenv("YOOKASA_EMAIL", "shop@example.com")
+	config.InitConfig()
+
+	if _, ok := periodPaymentAmount(database.InvoiceTypeCrypto, "1", "CARD"); ok {
+		t.Error("Expected crypto button to be hidden for a card-only tariff")
+	}
+	if amount, ok := periodPaymentAmount(database.InvoiceTypeYookasa, "1", "CARD"); !ok || amount != 300 {
+		t.Errorf("Expected card amount 300, got %d (ok %v)", amount, ok)
+	}
+
+	b, tg := newTestBot(t)
+	purchases := &mockPurchaseService{}
+	h := NewPaymentHandlers(fakeTranslator{}, newFakeCache(), &mockPaymentCustomers{customer: &database.Customer{ID: 7, TelegramID: 42}}, nil, purchases, nil, nil, nil)
+
+	h.PaymentCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackPayment+"?m=1&t=crypto&n=CARD"))
+	if len(purchases.created) != 0 {
+		t.Fatalf("Expected crypto invoice to be refused, got %+v", purchases.created)
+	}
+	sent := tg.called("sendMessage")
+	if len(sent) != 1 || sent[0].params["text"] != "payment_method_unavailable" {
+		t.Errorf("Expected payment method refusal, got %v", sent)
+	}
+
+	h.PaymentCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackPayment+"?m=1&t=yookasa&n=CARD"))
+	if len(purchases.created) != 1 || purchases.created[0].amount != 300 {
+		t.Errorf("Expected card invoice for 300, got %+v", purchases.created)
+	}
+}
@@ -2,6 +2,8 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -10,17 +12,25 @@ import (
 	"github.com/go-telegram/bot/models"
 	"log/slog"
 
+	"remnawave-tg-shop-bot/internal/activity"
+	"remnawave-tg-shop-bot/internal/clock"
 	"remnawave-tg-shop-bot/internal/config"
 	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/events"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/payment"
+	"remnawave-tg-shop-bot/utils"
 )
 
-func (h Handler) BuyCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PaymentHandlers) BuyCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
 
 	callback := update.CallbackQuery.Message.Message
 	langCode := update.CallbackQuery.From.LanguageCode
+	activity.Record(ctx, update.CallbackQuery.From.ID, database.FunnelEventViewedPrices)
+	publishMenuView(ctx, update.CallbackQuery.From.ID, menuBuy)
 
 	tariffs := config.GetTariffs()
 
@@ -41,7 +51,7 @@ func (h Handler) BuyCallbackHandler(ctx context.Context, b *bot.Bot, update *mod
 }
 
 // BroadcastBuyCallbackHandler - обработчик кнопки купить из broadcast (всегда новое сообщение)
-func (h Handler) BroadcastBuyCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PaymentHandlers) BroadcastBuyCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -69,7 +79,7 @@ func (h Handler) BroadcastBuyCallbackHandler(ctx context.Context, b *bot.Bot, up
 
 // showTariffMenu показывает меню выбора тарифов (редактирует сообщение)
 // Requirements: 5.1, 5.2 - показывает кнопку promo tariff если есть активное предложение
-func (h Handler) showTariffMenu(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, tariffs []config.Tariff) {
+func (h PaymentHandlers) showTariffMenu(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, tariffs []config.Tariff) {
 	keyboard := [][]models.InlineKeyboardButton{}
 
 	// Проверяем наличие активного promo offer у пользователя
@@ -78,7 +88,7 @@ func (h Handler) showTariffMenu(ctx context.Context, b *bot.Bot, callback *model
 	if err == nil && customer != nil && database.HasActivePromoOffer(customer) {
 		// Добавляем кнопку promo tariff с эмодзи 🎁 в начало меню
 		btnText := h.translation.GetTextTemplate(langCode, "promo_tariff_button", map[string]interface{}{
-			"price":   *customer.PromoOfferPrice,
+			"price":   locale.FormatMoney(langCode, float64(*customer.PromoOfferPrice)),
 			"months":  *customer.PromoOfferMonths,
 			"devices": *customer.PromoOfferDevices,
 		})
@@ -101,7 +111,7 @@ func (h Handler) showTariffMenu(ctx context.Context, b *bot.Bot, callback *model
 	}
 
 	keyboard = append(keyboard, []models.InlineKeyboardButton{
-		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart},
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
 	})
 
 	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
@@ -121,7 +131,7 @@ func (h Handler) showTariffMenu(ctx context.Context, b *bot.Bot, callback *model
 			return
 		}
 		// Fallback: отправляем новое сообщение если не удалось отредактировать
-		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+		_, _ = h.sendMenu(ctx, b, &bot.SendMessageParams{
 			ChatID:    callback.Chat.ID,
 			ParseMode: models.ParseModeHTML,
 			ReplyMarkup: models.InlineKeyboardMarkup{
@@ -134,7 +144,7 @@ func (h Handler) showTariffMenu(ctx context.Context, b *bot.Bot, callback *model
 
 // showTariffMenuNew отправляет новое сообщение с меню тарифов
 // Requirements: 5.1, 5.2 - показывает кнопку promo tariff если есть активное предложение
-func (h Handler) showTariffMenuNew(ctx context.Context, b *bot.Bot, chatID int64, langCode string, tariffs []config.Tariff) {
+func (h PaymentHandlers) showTariffMenuNew(ctx context.Context, b *bot.Bot, chatID int64, langCode string, tariffs []config.Tariff) {
 	keyboard := [][]models.InlineKeyboardButton{}
 
 	// Проверяем наличие активного promo offer у пользователя
@@ -143,7 +153,7 @@ func (h Handler) showTariffMenuNew(ctx context.Context, b *bot.Bot, chatID int64
 	if err == nil && customer != nil && database.HasActivePromoOffer(customer) {
 		// Добавляем кнопку promo tariff с эмодзи 🎁 в начало меню
 		btnText := h.translation.GetTextTemplate(langCode, "promo_tariff_button", map[string]interface{}{
-			"price":   *customer.PromoOfferPrice,
+			"price":   locale.FormatMoney(langCode, float64(*customer.PromoOfferPrice)),
 			"months":  *customer.PromoOfferMonths,
 			"devices": *customer.PromoOfferDevices,
 		})
@@ -165,10 +175,10 @@ func (h Handler) showTariffMenuNew(ctx context.Context, b *bot.Bot, chatID int64
 	}
 
 	keyboard = append(keyboard, []models.InlineKeyboardButton{
-		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart},
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
 	})
 
-	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+	_, err = h.sendMenu(ctx, b, &bot.SendMessageParams{
 		ChatID:    chatID,
 		ParseMode: models.ParseModeHTML,
 		ReplyMarkup: models.InlineKeyboardMarkup{
@@ -178,13 +188,13 @@ func (h Handler) showTariffMenuNew(ctx context.Context, b *bot.Bot, chatID int64
 	})
 
 	if err != nil {
-		slog.Error("Error sending tariff menu", slog.Any("error", err))
+		slog.ErrorContext(ctx, "Error sending tariff menu", slog.Any("error", err))
 	}
 }
 
 // showTariffPriceMenuNew отправляет новое сообщение с ценами тарифа
 // Requirements: 5.1, 5.2 - показывает кнопку promo tariff если есть активное предложение
-func (h Handler) showTariffPriceMenuNew(ctx context.Context, b *bot.Bot, chatID int64, langCode string, tariff *config.Tariff) {
+func (h PaymentHandlers) showTariffPriceMenuNew(ctx context.Context, b *bot.Bot, chatID int64, langCode string, tariff *config.Tariff) {
 	keyboard := [][]models.InlineKeyboardButton{}
 
 	// Проверяем наличие активного promo offer у пользователя
@@ -193,7 +203,7 @@ func (h Handler) showTariffPriceMenuNew(ctx context.Context, b *bot.Bot, chatID
 	if err == nil && customer != nil && database.HasActivePromoOffer(customer) {
 		// Добавляем кнопку promo tariff с эмодзи 🎁 в начало меню
 		btnText := h.translation.GetTextTemplate(langCode, "promo_tariff_button", map[string]interface{}{
-			"price":   *customer.PromoOfferPrice,
+			"price":   locale.FormatMoney(langCode, float64(*customer.PromoOfferPrice)),
 			"months":  *customer.PromoOfferMonths,
 			"devices": *customer.PromoOfferDevices,
 		})
@@ -206,32 +216,35 @@ func (h Handler) showTariffPriceMenuNew(ctx context.Context, b *bot.Bot, chatID
 
 	if tariff.Price1 > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_1", map[string]interface{}{"price": tariff.Price1}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 1, tariff.Price1, tariff.Name),
+			Text:         h.translation.GetTextTemplate(langCode, "month_1", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price1))}),
+			CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 1, tariff.Name),
 		})
 	}
 
 	if tariff.Price3 > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_3", map[string]interface{}{"price": tariff.Price3}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 3, tariff.Price3, tariff.Name),
+			Text:         h.translation.GetTextTemplate(langCode, "month_3", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price3))}),
+			CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 3, tariff.Name),
 		})
 	}
 
 	if tariff.Price6 > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_6", map[string]interface{}{"price": tariff.Price6}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 6, tariff.Price6, tariff.Name),
+			Text:         h.translation.GetTextTemplate(langCode, "month_6", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price6))}),
+			CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 6, tariff.Name),
 		})
 	}
 
 	if tariff.Price12 > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_12", map[string]interface{}{"price": tariff.Price12}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 12, tariff.Price12, tariff.Name),
+			Text:         h.translation.GetTextTemplate(langCode, "month_12", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price12))}),
+			CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 12, tariff.Name),
 		})
 	}
 
+	if dayButtons := dayPlanButtons(*tariff, langCode, h.translation); len(dayButtons) > 0 {
+		keyboard = append(keyboard, dayButtons)
+	}
 	if len(priceButtons) == 4 {
 		keyboard = append(keyboard, priceButtons[:2])
 		keyboard = append(keyboard, priceButtons[2:])
@@ -240,14 +253,14 @@ func (h Handler) showTariffPriceMenuNew(ctx context.Context, b *bot.Bot, chatID
 	}
 
 	keyboard = append(keyboard, []models.InlineKeyboardButton{
-		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart},
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
 	})
 
 	pricingText := h.translation.GetTextTemplate(langCode, "select_period_text", map[string]interface{}{
 		"devices": tariff.Devices,
 	})
 
-	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+	_, err = h.sendMenu(ctx, b, &bot.SendMessageParams{
 		ChatID:    chatID,
 		ParseMode: models.ParseModeHTML,
 		ReplyMarkup: models.InlineKeyboardMarkup{
@@ -257,13 +270,13 @@ func (h Handler) showTariffPriceMenuNew(ctx context.Context, b *bot.Bot, chatID
 	})
 
 	if err != nil {
-		slog.Error("Error sending tariff price menu", slog.Any("error", err))
+		slog.ErrorContext(ctx, "Error sending tariff price menu", slog.Any("error", err))
 	}
 }
 
 // showTariffPriceMenu показывает меню цен для конкретного тарифа
 // Requirements: 5.1, 5.2 - показывает кнопку promo tariff если есть активное предложение
-func (h Handler) showTariffPriceMenu(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, tariff *config.Tariff) {
+func (h PaymentHandlers) showTariffPriceMenu(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, tariff *config.Tariff) {
 	keyboard := [][]models.InlineKeyboardButton{}
 
 	// Проверяем наличие активного promo offer у пользователя
@@ -272,7 +285,7 @@ func (h Handler) showTariffPriceMenu(ctx context.Context, b *bot.Bot, callback *
 	if err == nil && customer != nil && database.HasActivePromoOffer(customer) {
 		// Добавляем кнопку promo tariff с эмодзи 🎁 в начало меню
 		btnText := h.translation.GetTextTemplate(langCode, "promo_tariff_button", map[string]interface{}{
-			"price":   *customer.PromoOfferPrice,
+			"price":   locale.FormatMoney(langCode, float64(*customer.PromoOfferPrice)),
 			"months":  *customer.PromoOfferMonths,
 			"devices": *customer.PromoOfferDevices,
 		})
@@ -285,32 +298,35 @@ func (h Handler) showTariffPriceMenu(ctx context.Context, b *bot.Bot, callback *
 
 	if tariff.Price1 > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_1", map[string]interface{}{"price": tariff.Price1}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 1, tariff.Price1, tariff.Name),
+			Text:         h.translation.GetTextTemplate(langCode, "month_1", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price1))}),
+			CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 1, tariff.Name),
 		})
 	}
 
 	if tariff.Price3 > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_3", map[string]interface{}{"price": tariff.Price3}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 3, tariff.Price3, tariff.Name),
+			Text:         h.translation.GetTextTemplate(langCode, "month_3", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price3))}),
+			CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 3, tariff.Name),
 		})
 	}
 
 	if tariff.Price6 > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_6", map[string]interface{}{"price": tariff.Price6}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 6, tariff.Price6, tariff.Name),
+			Text:         h.translation.GetTextTemplate(langCode, "month_6", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price6))}),
+			CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 6, tariff.Name),
 		})
 	}
 
 	if tariff.Price12 > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_12", map[string]interface{}{"price": tariff.Price12}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 12, tariff.Price12, tariff.Name),
+			Text:         h.translation.GetTextTemplate(langCode, "month_12", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price12))}),
+			CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 12, tariff.Name),
 		})
 	}
 
+	if dayButtons := dayPlanButtons(*tariff, langCode, h.translation); len(dayButtons) > 0 {
+		keyboard = append(keyboard, dayButtons)
+	}
 	if len(priceButtons) == 4 {
 		keyboard = append(keyboard, priceButtons[:2])
 		keyboard = append(keyboard, priceButtons[2:])
@@ -319,7 +335,7 @@ func (h Handler) showTariffPriceMenu(ctx context.Context, b *bot.Bot, callback *
 	}
 
 	keyboard = append(keyboard, []models.InlineKeyboardButton{
-		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart},
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
 	})
 
 	pricingText := h.translation.GetTextTemplate(langCode, "select_period_text", map[string]interface{}{
@@ -343,7 +359,7 @@ func (h Handler) showTariffPriceMenu(ctx context.Context, b *bot.Bot, callback *
 			return
 		}
 		// Fallback: отправляем новое сообщение если не удалось отредактировать
-		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+		_, _ = h.sendMenu(ctx, b, &bot.SendMessageParams{
 			ChatID:    callback.Chat.ID,
 			ParseMode: models.ParseModeHTML,
 			ReplyMarkup: models.InlineKeyboardMarkup{
@@ -356,7 +372,7 @@ func (h Handler) showTariffPriceMenu(ctx context.Context, b *bot.Bot, callback *
 
 // showLegacyPriceMenu показывает старое меню цен (без тарифов)
 // Requirements: 5.1, 5.2 - показывает кнопку promo tariff если есть активное предложение
-func (h Handler) showLegacyPriceMenu(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string) {
+func (h PaymentHandlers) showLegacyPriceMenu(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string) {
 	keyboard := [][]models.InlineKeyboardButton{}
 
 	// Проверяем наличие активного promo offer у пользователя
@@ -365,7 +381,7 @@ func (h Handler) showLegacyPriceMenu(ctx context.Context, b *bot.Bot, callback *
 	if err == nil && customer != nil && database.HasActivePromoOffer(customer) {
 		// Добавляем кнопку promo tariff с эмодзи 🎁 в начало меню
 		btnText := h.translation.GetTextTemplate(langCode, "promo_tariff_button", map[string]interface{}{
-			"price":   *customer.PromoOfferPrice,
+			"price":   locale.FormatMoney(langCode, float64(*customer.PromoOfferPrice)),
 			"months":  *customer.PromoOfferMonths,
 			"devices": *customer.PromoOfferDevices,
 		})
@@ -378,29 +394,29 @@ func (h Handler) showLegacyPriceMenu(ctx context.Context, b *bot.Bot, callback *
 
 	if config.Price1() > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_1", map[string]interface{}{"price": config.Price1()}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d", CallbackSell, 1, config.Price1()),
+			Text:         h.translation.GetTextTemplate(langCode, "month_1", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(config.Price1()))}),
+			CallbackData: fmt.Sprintf("%s?month=%d", CallbackSell, 1),
 		})
 	}
 
 	if config.Price3() > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_3", map[string]interface{}{"price": config.Price3()}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d", CallbackSell, 3, config.Price3()),
+			Text:         h.translation.GetTextTemplate(langCode, "month_3", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(config.Price3()))}),
+			CallbackData: fmt.Sprintf("%s?month=%d", CallbackSell, 3),
 		})
 	}
 
 	if config.Price6() > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_6", map[string]interface{}{"price": config.Price6()}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d", CallbackSell, 6, config.Price6()),
+			Text:         h.translation.GetTextTemplate(langCode, "month_6", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(config.Price6()))}),
+			CallbackData: fmt.Sprintf("%s?month=%d", CallbackSell, 6),
 		})
 	}
 
 	if config.Price12() > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_12", map[string]interface{}{"price": config.Price12()}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d", CallbackSell, 12, config.Price12()),
+			Text:         h.translation.GetTextTemplate(langCode, "month_12", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(config.Price12()))}),
+			CallbackData: fmt.Sprintf("%s?month=%d", CallbackSell, 12),
 		})
 	}
 
@@ -412,7 +428,7 @@ func (h Handler) showLegacyPriceMenu(ctx context.Context, b *bot.Bot, callback *
 	}
 
 	keyboard = append(keyboard, []models.InlineKeyboardButton{
-		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart},
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
 	})
 
 	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
@@ -432,7 +448,7 @@ func (h Handler) showLegacyPriceMenu(ctx context.Context, b *bot.Bot, callback *
 			return
 		}
 		// Fallback: отправляем новое сообщение если не удалось отредактировать
-		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+		_, _ = h.sendMenu(ctx, b, &bot.SendMessageParams{
 			ChatID:    callback.Chat.ID,
 			ParseMode: models.ParseModeHTML,
 			ReplyMarkup: models.InlineKeyboardMarkup{
@@ -443,7 +459,7 @@ func (h Handler) showLegacyPriceMenu(ctx context.Context, b *bot.Bot, callback *
 	}
 }
 
-func (h Handler) SellCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PaymentHandlers) SellCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -452,22 +468,30 @@ func (h Handler) SellCallbackHandler(ctx context.Context, b *bot.Bot, update *mo
 	callbackQuery := parseCallbackData(update.CallbackQuery.Data)
 	langCode := update.CallbackQuery.From.LanguageCode
 	month := callbackQuery["month"]
-	amount := callbackQuery["amount"]
 	tariff := callbackQuery["tariff"] // Получаем имя тарифа из callback
-
-	// Проверяем есть ли у пользователя сохранённый метод оплаты — если да, включаем recurring по умолчанию
-	recurringEnabled := false
-	if config.IsRecurringPaymentsEnabled() {
-		customer, err := h.customerRepository.FindByTelegramId(ctx, callback.Chat.ID)
-		if err == nil && customer != nil && customer.PaymentMethodID != nil {
-			recurringEnabled = true
-		}
+	if period, ok := config.ParsePeriod(month); ok {
+		events.Publish(ctx, events.TariffSelected(events.TariffSelection{
+			TelegramID: update.CallbackQuery.From.ID,
+			TariffName: tariff,
+			Months:     period.Months,
+			Days:       period.Days,
+		}))
 	}
 
-	h.showPaymentMethodsWithRecurring(ctx, b, callback, langCode, month, amount, tariff, recurringEnabled)
+	h.showPaymentMethodsWithRecurring(ctx, b, callback, langCode, month, tariff, h.defaultRecurring(ctx, callback.Chat.ID))
 }
 
-func (h Handler) PaymentCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+// defaultRecurring возвращает true, если у клиента есть сохранённый метод оплаты: тогда автопродление
+// в меню оплаты включено по умолчанию
+func (h PaymentHandlers) defaultRecurring(ctx context.Context, chatID int64) bool {
+	if !config.IsRecurringPaymentsEnabled() {
+		return false
+	}
+	customer, err := h.customerRepository.FindByTelegramId(ctx, chatID)
+	return err == nil && customer != nil && customer.PaymentMethodID != nil
+}
+
+func (h PaymentHandlers) PaymentCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -480,9 +504,9 @@ func (h Handler) PaymentCallbackHandler(ctx context.Context, b *bot.Bot, update
 	if monthStr == "" {
 		monthStr = callbackQuery["month"]
 	}
-	month, err := strconv.Atoi(monthStr)
-	if err != nil {
-		slog.Error("Error getting month from query", "error", err)
+	period, ok := config.ParsePeriod(monthStr)
+	if !ok {
+		slog.ErrorContext(ctx, "Error getting period from query", "month", monthStr)
 		return
 	}
 
@@ -500,17 +524,23 @@ func (h Handler) PaymentCallbackHandler(ctx context.Context, b *bot.Bot, update
 	isWinback := callbackQuery["winback"] == "true" || callbackQuery["w"] == "1"
 	isRecurring := callbackQuery["recurring"] == "true" || callbackQuery["r"] == "1"
 	isPromoTariff := callbackQuery["pt"] == "1"
+	isTestShop := callbackQuery["x"] == "1"
+	if isTestShop && (update.CallbackQuery.From.ID != config.GetAdminTelegramId() || !config.IsYookasaTestShopEnabled() || invoiceType != database.InvoiceTypeYookasa) {
+		slog.WarnContext(ctx, "Test shop payment requested without access", "telegramId", utils.MaskHalfInt64(update.CallbackQuery.From.ID))
+		return
+	}
 
 	// Получаем customer сразу — нужен для winback, promo tariff и далее
 	customer, err := h.customerRepository.FindByTelegramId(ctx, callback.Chat.ID)
 	if err != nil {
-		slog.Error("Error finding customer", "error", err)
+		slog.ErrorContext(ctx, "Error finding customer", "error", err)
 		return
 	}
 	if customer == nil {
-		slog.Error("customer not exist", "chatID", callback.Chat.ID)
+		slog.ErrorContext(ctx, "customer not exist", "chatID", callback.Chat.ID)
 		return
 	}
+	activity.Record(ctx, customer.TelegramID, database.FunnelEventSelectedPayment)
 
 	// Определяем цену и месяцы
 	var price int
@@ -518,52 +548,96 @@ func (h Handler) PaymentCallbackHandler(ctx context.Context, b *bot.Bot, update
 		// Property 8: Purchase Uses Offer Parameters
 		// Для promo tariff берём параметры из сохранённого предложения в БД
 		if customer.PromoOfferPrice == nil || customer.PromoOfferMonths == nil {
-			slog.Error("Cannot get promo tariff parameters - offer not found", "customerId", customer.ID)
+			slog.ErrorContext(ctx, "Cannot get promo tariff parameters - offer not found", "customerId", customer.ID)
 			return
 		}
 		// Проверяем что предложение не истекло
 		if !database.HasActivePromoOffer(customer) {
-			slog.Warn("Promo tariff offer expired", "customerId", customer.ID)
+			slog.WarnContext(ctx, "Promo tariff offer expired", "customerId", customer.ID)
 			return
 		}
 		price = *customer.PromoOfferPrice
-		month = *customer.PromoOfferMonths // Переопределяем месяцы из предложения
-		slog.Debug("Using promo tariff price from saved offer", "price", price, "months", month)
+		period = config.MonthsPeriod(*customer.PromoOfferMonths) // Переопределяем месяцы из предложения
+		slog.DebugContext(ctx, "Using promo tariff price from saved offer", "price", price, "months", period.Months)
 	} else if isWinback {
 		// Для winback берём цену из сохранённого предложения в БД
 		// Это гарантирует что пользователь заплатит ту цену, которую видел в уведомлении
 		if customer.WinbackOfferPrice == nil {
-			slog.Error("Cannot get winback price - offer not found", "customerId", customer.ID)
+			slog.ErrorContext(ctx, "Cannot get winback price - offer not found", "customerId", customer.ID)
 			return
 		}
 		price = *customer.WinbackOfferPrice
-		slog.Debug("Using winback price from saved offer", "price", price)
+		if customer.WinbackOfferMonths != nil {
+			period = config.MonthsPeriod(*customer.WinbackOfferMonths) // Переопределяем месяцы из предложения
+		}
+		// Тариф тоже берём из предложения: у ушедших платящих клиентов это их прежний тариф
+		tariffName = ""
+		if customer.WinbackOfferTariff != nil {
+			tariffName = *customer.WinbackOfferTariff
+		}
+		slog.DebugContext(ctx, "Using winback price from saved offer", "price", price, "months", period.Months)
 	} else if tariffName != "" {
 		tariff := config.GetTariffByName(tariffName)
 		if tariff != nil {
 			if invoiceType == database.InvoiceTypeTelegram {
-				price = tariff.StarsPrice(month)
+				price = tariff.PeriodStarsPrice(period)
 			} else {
-				price = tariff.Price(month)
+				price = tariff.PeriodPrice(period)
 			}
-			slog.Debug("Using tariff price from config", "tariff", tariffName, "price", price, "invoiceType", invoiceType)
+			slog.DebugContext(ctx, "Using tariff price from config", "tariff", tariffName, "price", price, "invoiceType", invoiceType)
+		} else if len(config.GetTariffs()) > 0 {
+			// Кнопки содержат только настроенные тарифы: неизвестное имя — подделанный callback
+			slog.WarnContext(ctx, "Unknown tariff in callback data", "tariff", tariffName, "customerId", customer.ID)
+			return
 		} else {
-			slog.Warn("Tariff not found, using default price", "tariff", tariffName)
+			// Без тарифов имя из callback data не сохраняется в покупке
+			slog.WarnContext(ctx, "Tariff not found, using default price", "tariff", tariffName)
+			tariffName = ""
 			if invoiceType == database.InvoiceTypeTelegram {
-				price = config.StarsPrice(month)
+				price = config.StarsPrice(period.Months)
 			} else {
-				price = config.Price(month)
+				price = config.Price(period.Months)
 			}
 		}
 	} else {
 		// Legacy flow без тарифов — используем глобальные цены
 		if invoiceType == database.InvoiceTypeTelegram {
-			price = config.StarsPrice(month)
+			price = config.StarsPrice(period.Months)
 		} else {
-			price = config.Price(month)
+			price = config.Price(period.Months)
 		}
 	}
 
+	// Цена всегда берётся из конфига или сохранённого предложения — сумма из callback data не используется.
+	// Период проверяем отдельно: Price() для неизвестного периода возвращает цену за 1 месяц,
+	// посуточный план должен быть задан у тарифа
+	if !isPromoTariff && !isWinback && !config.IsSupportedTariffPeriod(tariffName, period) {
+		slog.WarnContext(ctx, "Unsupported purchase period in callback data", "period", period.String(), "customerId", customer.ID)
+		return
+	}
+	// Кнопки запрещённых тарифом способов не показываются, но callback data можно подделать.
+	// Предложения winback и promo tariff оплачиваются на своих условиях, как и без наценки способа
+	if !isPromoTariff && !isWinback && !config.TariffAllowsPaymentMethod(tariffName, string(invoiceType)) {
+		slog.WarnContext(ctx, "Payment method not allowed for tariff", "tariff", tariffName, "invoiceType", invoiceType, "customerId", customer.ID)
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: callback.Chat.ID,
+			Text:   h.translation.GetText(update.CallbackQuery.From.LanguageCode, "payment_method_unavailable"),
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error sending purchase refusal message", "error", err)
+		}
+		return
+	}
+	// Наценка или скидка способа оплаты. Предложения winback и promo tariff оплачиваются по цене из уведомления.
+	// Сумму вне лимитов платёжной системы не меняем: сервис откажет в счёте и сообщит админу
+	if !isPromoTariff && !isWinback {
+		price, _ = config.PaymentAmount(string(invoiceType), price)
+	}
+	if price <= 0 {
+		slog.WarnContext(ctx, "Invalid purchase price", "price", price, "period", period.String(), "tariff", tariffName, "invoiceType", invoiceType)
+		return
+	}
+
 	ctxWithUsername := context.WithValue(ctx, "username", update.CallbackQuery.From.Username)
 
 	// Передаём tariffName в CreatePurchase (nil если пустой)
@@ -577,54 +651,62 @@ func (h Handler) PaymentCallbackHandler(ctx context.Context, b *bot.Bot, update
 	var deviceLimit *int
 	if isPromoTariff && customer.PromoOfferDevices != nil {
 		deviceLimit = customer.PromoOfferDevices
-		slog.Info("Creating promo tariff purchase", "price", price, "months", month, "devices", *deviceLimit)
+		slog.InfoContext(ctx, "Creating promo tariff purchase", "price", price, "months", period.Months, "devices", *deviceLimit)
 	} else if isWinback && customer.WinbackOfferDevices != nil {
 		// Для winback берём deviceLimit из сохранённого предложения в БД
 		// Это гарантирует консистентность с тем что пользователь видел в уведомлении
 		deviceLimit = customer.WinbackOfferDevices
-		slog.Info("Creating winback purchase", "price", price, "months", month, "devices", *deviceLimit)
+		slog.InfoContext(ctx, "Creating winback purchase", "price", price, "months", period.Months, "devices", *deviceLimit)
 	}
 
 	// Определяем нужно ли сохранять способ оплаты для автопродления
 	// Автопродление поддерживается только для YooKassa и если функция включена
-	savePaymentMethod := isRecurring && invoiceType == database.InvoiceTypeYookasa && config.IsRecurringPaymentsEnabled()
+	savePaymentMethod := isRecurring && invoiceType == database.InvoiceTypeYookasa && config.IsRecurringPaymentsEnabled() && !isTestShop
 
 	if savePaymentMethod {
-		slog.Info("Creating payment with recurring enabled", "price", price, "months", month, "tariff", tariffName)
+		slog.InfoContext(ctx, "Creating payment with recurring enabled", "price", price, "period", period.String(), "tariff", tariffName)
 	}
 
-	paymentURL, purchaseId, err := h.paymentService.CreatePurchaseWithRecurring(ctxWithUsername, float64(price), month, customer, invoiceType, tariffNamePtr, deviceLimit, savePaymentMethod)
+	var paymentURL string
+	var purchaseId int64
+	if isTestShop {
+		paymentURL, purchaseId, err = h.paymentService.CreateTestYookasaPurchase(ctxWithUsername, float64(price), period, customer, tariffNamePtr, deviceLimit)
+	} else {
+		paymentURL, purchaseId, err = h.paymentService.CreatePeriodPurchase(ctxWithUsername, float64(price), period, customer, invoiceType, tariffNamePtr, deviceLimit, savePaymentMethod)
+	}
+	if errors.Is(err, payment.ErrPurchaseLimitExceeded) || errors.Is(err, payment.ErrInvalidPaymentAmount) || errors.Is(err, payment.ErrPeriodNotSupported) {
+		textKey := "purchase_limit_exceeded"
+		if !errors.Is(err, payment.ErrPurchaseLimitExceeded) {
+			textKey = "payment_method_unavailable"
+		}
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: callback.Chat.ID,
+			Text:   h.translation.GetText(update.CallbackQuery.From.LanguageCode, textKey),
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error sending purchase refusal message", "error", err)
+		}
+		return
+	}
 	if err != nil {
-		slog.Error("Error creating payment", "error", err)
+		slog.ErrorContext(ctx, "Error creating payment", "error", err)
 		return
 	}
 
 	langCode := update.CallbackQuery.From.LanguageCode
 
-	// Формируем callback для кнопки "назад" с учётом тарифа, winback и promo tariff
-	var backCallback string
-	if isPromoTariff {
-		backCallback = CallbackPromoTariff // Для promo tariff возвращаемся к выбору оплаты
-	} else if isWinback {
-		backCallback = CallbackStart // Для winback возвращаемся в главное меню
-	} else if tariffName != "" {
-		backCallback = fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, month, price, tariffName)
-	} else {
-		backCallback = fmt.Sprintf("%s?month=%d&amount=%d", CallbackSell, month, price)
-	}
-
 	var keyboard [][]models.InlineKeyboardButton
 
 	// Кнопки Оплатить и Назад
 	keyboard = append(keyboard, []models.InlineKeyboardButton{
 		{Text: h.translation.GetText(langCode, "pay_button"), URL: paymentURL},
-		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: backCallback},
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
 	})
 
 	// Показываем чекбокс автопродления только для YooKassa
 	// Для winback показываем только если WINBACK_RECURRING_ENABLED=true
 	// Для promo tariff показываем только если PROMO_TARIFF_RECURRING_ENABLED=true
-	showRecurringCheckbox := invoiceType == database.InvoiceTypeYookasa && config.IsRecurringPaymentsEnabled() &&
+	showRecurringCheckbox := invoiceType == database.InvoiceTypeYookasa && config.IsRecurringPaymentsEnabled() && !isTestShop &&
 		(!isWinback || config.IsWinbackRecurringEnabled()) &&
 		(!isPromoTariff || config.IsPromoTariffRecurringEnabled())
 	if showRecurringCheckbox {
@@ -633,7 +715,7 @@ func (h Handler) PaymentCallbackHandler(ctx context.Context, b *bot.Bot, update
 			checkboxText = "☑ " + h.translation.GetText(langCode, "recurring_checkbox")
 		}
 		// Формируем callback для toggle с текущими параметрами
-		toggleCallback := fmt.Sprintf("%s?m=%d&a=%d&t=%s", CallbackRecurringToggle, month, price, invoiceType)
+		toggleCallback := fmt.Sprintf("%s?m=%s&t=%s", CallbackRecurringToggle, period, invoiceType)
 		if tariffName != "" {
 			toggleCallback += fmt.Sprintf("&n=%s", tariffName)
 		}
@@ -660,35 +742,38 @@ func (h Handler) PaymentCallbackHandler(ctx context.Context, b *bot.Bot, update
 		},
 	})
 	if err != nil {
-		slog.Error("Error updating sell message", "error", err)
+		slog.ErrorContext(ctx, "Error updating sell message", "error", err)
 		return
 	}
-	h.cache.Set(purchaseId, message.ID)
+	h.paymentService.SavePurchaseMessage(ctx, purchaseId, message.ID)
 }
 
-func (h Handler) PreCheckoutCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PaymentHandlers) PreCheckoutCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, err := b.AnswerPreCheckoutQuery(ctx, &bot.AnswerPreCheckoutQueryParams{
 		PreCheckoutQueryID: update.PreCheckoutQuery.ID,
 		OK:                 true,
 	})
 	if err != nil {
-		slog.Error("Error sending answer pre checkout query", "error", err)
+		slog.ErrorContext(ctx, "Error sending answer pre checkout query", "error", err)
 	}
 }
 
-func (h Handler) SuccessPaymentHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PaymentHandlers) SuccessPaymentHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	payload := strings.Split(update.Message.SuccessfulPayment.InvoicePayload, "&")
 	purchaseId, err := strconv.Atoi(payload[0])
 	username := payload[1]
 	if err != nil {
-		slog.Error("Error parsing purchase id", "error", err)
+		slog.ErrorContext(ctx, "Error parsing purchase id", "error", err)
 		return
 	}
 
+	h.paymentService.SaveProviderPayment(ctx, int64(purchaseId), update.Message.SuccessfulPayment.TelegramPaymentChargeID, "")
+
 	ctxWithUsername := context.WithValue(ctx, "username", username)
-	err = h.paymentService.ProcessPurchaseById(ctxWithUsername, int64(purchaseId))
+	providerPayload, _ := json.Marshal(update.Message.SuccessfulPayment)
+	err = h.paymentService.FulfillPurchase(ctxWithUsername, int64(purchaseId), database.InvoiceTypeTelegram, providerPayload)
 	if err != nil {
-		slog.Error("Error processing purchase", "error", err)
+		slog.ErrorContext(ctx, "Error processing purchase", "error", err)
 	}
 }
 
@@ -713,7 +798,7 @@ func parseCallbackData(data string) map[string]string {
 
 // RecurringToggleCallbackHandler обрабатывает переключение чекбокса автопродления
 // Переключает состояние recurring и перенаправляет на PaymentCallbackHandler с новым состоянием
-func (h Handler) RecurringToggleCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PaymentHandlers) RecurringToggleCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	callbackQuery := parseCallbackData(update.CallbackQuery.Data)
 	currentRecurring := callbackQuery["recurring"] == "true" || callbackQuery["r"] == "1"
 	newRecurring := !currentRecurring
@@ -723,10 +808,6 @@ func (h Handler) RecurringToggleCallbackHandler(ctx context.Context, b *bot.Bot,
 	if month == "" {
 		month = callbackQuery["month"]
 	}
-	amount := callbackQuery["a"]
-	if amount == "" {
-		amount = callbackQuery["amount"]
-	}
 	tariff := callbackQuery["n"]
 	if tariff == "" {
 		tariff = callbackQuery["tariff"]
@@ -739,7 +820,7 @@ func (h Handler) RecurringToggleCallbackHandler(ctx context.Context, b *bot.Bot,
 	isPromoTariff := callbackQuery["pt"] == "1"
 
 	// Формируем новый callback data с переключённым состоянием recurring
-	newCallbackData := fmt.Sprintf("%s?m=%s&t=%s&a=%s", CallbackPayment, month, invoiceType, amount)
+	newCallbackData := fmt.Sprintf("%s?m=%s&t=%s", CallbackPayment, month, invoiceType)
 	if tariff != "" {
 		newCallbackData += fmt.Sprintf("&n=%s", tariff)
 	}
@@ -759,10 +840,28 @@ func (h Handler) RecurringToggleCallbackHandler(ctx context.Context, b *bot.Bot,
 }
 
 // showPaymentMethodsWithRecurring показывает меню выбора способа оплаты с чекбоксом автопродления
-func (h Handler) showPaymentMethodsWithRecurring(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, month string, amount string, tariff string, recurringEnabled bool) {
+func (h PaymentHandlers) showPaymentMethodsWithRecurring(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, month string, tariff string, recurringEnabled bool) {
+	text, keyboard := h.paymentMethodsMenu(ctx, callback.Chat.ID, langCode, month, tariff, recurringEnabled)
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    callback.Chat.ID,
+		MessageID: callback.ID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{
+			InlineKeyboard: keyboard,
+		},
+	})
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Error updating payment methods menu", "error", err)
+	}
+}
+
+// paymentMethodsMenu возвращает текст и клавиатуру выбора способа оплаты периода month тарифа tariff
+func (h PaymentHandlers) paymentMethodsMenu(ctx context.Context, chatID int64, langCode string, month string, tariff string, recurringEnabled bool) (string, [][]models.InlineKeyboardButton) {
 	// Формируем базовый callback с тарифом и recurring (короткие ключи для лимита 64 байта)
 	buildPaymentCallback := func(invoiceType database.InvoiceType) string {
-		base := fmt.Sprintf("%s?m=%s&t=%s&a=%s", CallbackPayment, month, invoiceType, amount)
+		base := fmt.Sprintf("%s?m=%s&t=%s", CallbackPayment, month, invoiceType)
 		if tariff != "" {
 			base += fmt.Sprintf("&n=%s", tariff)
 		}
@@ -775,11 +874,11 @@ func (h Handler) showPaymentMethodsWithRecurring(ctx context.Context, b *bot.Bot
 	var keyboard [][]models.InlineKeyboardButton
 
 	// Сохранённый способ оплаты показываем ПЕРВЫМ (сверху) если есть
-	if config.IsYookasaEnabled() && config.IsRecurringPaymentsEnabled() {
-		customer, err := h.customerRepository.FindByTelegramId(ctx, callback.Chat.ID)
-		if err == nil && customer != nil && customer.PaymentMethodID != nil {
+	if config.IsYookasaEnabled() && config.IsRecurringPaymentsEnabled() && config.TariffAllowsPaymentMethod(tariff, string(database.InvoiceTypeYookasa)) {
+		customer, err := h.customerRepository.FindByTelegramId(ctx, chatID)
+		if err == nil && customer != nil && h.hasSavedPaymentMethods(ctx, customer) {
 			// Передаём параметры чтобы кнопка "Назад" вернула в это меню
-			savedCallback := fmt.Sprintf("%s?m=%s&a=%s", CallbackSavedPaymentMethods, month, amount)
+			savedCallback := fmt.Sprintf("%s?m=%s", CallbackSavedPaymentMethods, month)
 			if tariff != "" {
 				savedCallback += fmt.Sprintf("&n=%s", tariff)
 			}
@@ -789,31 +888,31 @@ func (h Handler) showPaymentMethodsWithRecurring(ctx context.Context, b *bot.Bot
 		}
 	}
 
-	if config.IsCryptoPayEnabled() {
+	if amount, ok := periodPaymentAmount(database.InvoiceTypeCrypto, month, tariff); config.IsCryptoPayEnabled() && ok {
 		keyboard = append(keyboard, []models.InlineKeyboardButton{
-			{Text: h.translation.GetText(langCode, "crypto_button"), CallbackData: buildPaymentCallback(database.InvoiceTypeCrypto)},
+			{Text: paymentMethodButtonText(h.translation.GetText(langCode, "crypto_button"), langCode, database.InvoiceTypeCrypto, amount), CallbackData: buildPaymentCallback(database.InvoiceTypeCrypto)},
 		})
 	}
 
-	if config.IsYookasaEnabled() {
+	if amount, ok := periodPaymentAmount(database.InvoiceTypeYookasa, month, tariff); config.IsYookasaEnabled() && ok {
 		// Кнопка оплаты картой
 		keyboard = append(keyboard, []models.InlineKeyboardButton{
-			{Text: h.translation.GetText(langCode, "card_button"), CallbackData: buildPaymentCallback(database.InvoiceTypeYookasa)},
+			{Text: paymentMethodButtonText(h.translation.GetText(langCode, "card_button"), langCode, database.InvoiceTypeYookasa, amount), CallbackData: buildPaymentCallback(database.InvoiceTypeYookasa)},
 		})
 	}
 
-	if config.IsTelegramStarsEnabled() {
+	if amount, ok := periodPaymentAmount(database.InvoiceTypeTelegram, month, tariff); config.IsTelegramStarsEnabled() && ok {
 		shouldShowStarsButton := true
 
 		if config.RequirePaidPurchaseForStars() {
-			customer, err := h.customerRepository.FindByTelegramId(ctx, callback.Chat.ID)
+			customer, err := h.customerRepository.FindByTelegramId(ctx, chatID)
 			if err != nil {
-				slog.Error("Error finding customer for stars check", "error", err)
+				slog.ErrorContext(ctx, "Error finding customer for stars check", "error", err)
 				shouldShowStarsButton = false
 			} else if customer != nil {
 				paidPurchase, err := h.purchaseRepository.FindSuccessfulPaidPurchaseByCustomer(ctx, customer.ID)
 				if err != nil {
-					slog.Error("Error checking paid purchase", "error", err)
+					slog.ErrorContext(ctx, "Error checking paid purchase", "error", err)
 					shouldShowStarsButton = false
 				} else if paidPurchase == nil {
 					shouldShowStarsButton = false
@@ -825,12 +924,14 @@ func (h Handler) showPaymentMethodsWithRecurring(ctx context.Context, b *bot.Bot
 
 		if shouldShowStarsButton {
 			keyboard = append(keyboard, []models.InlineKeyboardButton{
-				{Text: h.translation.GetText(langCode, "stars_button"), CallbackData: buildPaymentCallback(database.InvoiceTypeTelegram)},
+				{Text: paymentMethodButtonText(h.translation.GetText(langCode, "stars_button"), langCode, database.InvoiceTypeTelegram, amount), CallbackData: buildPaymentCallback(database.InvoiceTypeTelegram)},
 			})
 		}
 	}
 
-	if config.GetTributeWebHookUrl() != "" {
+	// Подписка Tribute помесячная: для посуточных планов кнопку не показываем
+	if period, _ := config.ParsePeriod(month); config.GetTributeWebHookUrl() != "" && !period.IsDays() &&
+		config.TariffAllowsPaymentMethod(tariff, string(database.InvoiceTypeTribute)) {
 		// Если указан тариф — используем его tribute URL, иначе общий
 		tributeURL := config.GetTributePaymentUrl()
 		if tariff != "" {
@@ -846,13 +947,29 @@ func (h Handler) showPaymentMethodsWithRecurring(ctx context.Context, b *bot.Bot
 		}
 	}
 
-	// Кнопка "Назад" ведёт в меню периодов для текущего тарифа
-	backCallback := CallbackBuy
-	if tariff != "" {
-		backCallback = fmt.Sprintf("%s?name=%s", CallbackTariff, tariff)
+	// Тестовые оплаты видит только админ: покупка помечается is_test и не попадает в статистику
+	if chatID == config.GetAdminTelegramId() && config.IsSandboxPaymentsEnabled() {
+		sandboxCallback := fmt.Sprintf("%s?m=%s", CallbackSandboxPayment, month)
+		if tariff != "" {
+			sandboxCallback += fmt.Sprintf("&n=%s", tariff)
+		}
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: "🧪 Тестовая оплата (0 ₽)", CallbackData: SafeCallbackData(sandboxCallback)},
+		})
+		if config.IsYookasaTestShopEnabled() && config.TariffAllowsPaymentMethod(tariff, string(database.InvoiceTypeYookasa)) {
+			testShopCallback := fmt.Sprintf("%s?m=%s&t=%s", CallbackPayment, month, database.InvoiceTypeYookasa)
+			if tariff != "" {
+				testShopCallback += fmt.Sprintf("&n=%s", tariff)
+			}
+			keyboard = append(keyboard, []models.InlineKeyboardButton{
+				{Text: "🧪 ЮKassa (тест)", CallbackData: SafeCallbackData(testShopCallback + "&x=1")},
+			})
+		}
 	}
+
+	// Кнопка "Назад" ведёт на предыдущий экран (меню периодов для текущего тарифа)
 	keyboard = append(keyboard, []models.InlineKeyboardButton{
-		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: backCallback},
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
 	})
 
 	// Определяем текст с учётом тарифа
@@ -870,25 +987,56 @@ func (h Handler) showPaymentMethodsWithRecurring(ctx context.Context, b *bot.Bot
 	} else {
 		text = h.translation.GetText(langCode, "pricing_info_legacy")
 	}
+	return text, keyboard
+}
 
-	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
-		ChatID:    callback.Chat.ID,
-		MessageID: callback.ID,
-		Text:      text,
-		ParseMode: models.ParseModeHTML,
-		ReplyMarkup: models.InlineKeyboardMarkup{
-			InlineKeyboard: keyboard,
-		},
-	})
+// periodPaymentAmount возвращает сумму счёта за период с наценкой или скидкой способа оплаты.
+// ok=false, если цена не задана, не укладывается в лимиты платёжной системы или тариф не оплачивается
+// этим способом (TARIFF_<NAME>_PAYMENT_METHODS): кнопка способа не показывается
+func periodPaymentAmount(invoiceType database.InvoiceType, month, tariffName string) (int, bool) {
+	period, ok := config.ParsePeriod(month)
+	if !ok || !config.IsSupportedTariffPeriod(tariffName, period) || !config.TariffAllowsPaymentMethod(tariffName, string(invoiceType)) {
+		return 0, false
+	}
 
-	if err != nil {
-		slog.Error("Error updating payment methods menu", "error", err)
+	tariff := config.GetTariffByName(tariffName)
+	// Кнопки содержат только настроенные тарифы: неизвестное имя — подделанный callback
+	if tariffName != "" && tariff == nil && len(config.GetTariffs()) > 0 {
+		return 0, false
 	}
+
+	stars := invoiceType == database.InvoiceTypeTelegram
+	var price int
+	switch {
+	case tariff != nil && stars:
+		price = tariff.PeriodStarsPrice(period)
+	case tariff != nil:
+		price = tariff.PeriodPrice(period)
+	case stars:
+		price = config.StarsPrice(period.Months)
+	default:
+		price = config.Price(period.Months)
+	}
+	return config.PaymentAmount(string(invoiceType), price)
+}
+
+// paymentMethodButtonText добавляет к подписи способа оплаты сумму счёта, если для какого-либо способа
+// задана наценка или скидка: тогда суммы на кнопках различаются и клиенту видно, сколько он заплатит
+func paymentMethodButtonText(label, langCode string, invoiceType database.InvoiceType, amount int) string {
+	if config.PaymentFeePercent(string(database.InvoiceTypeCrypto)) == 0 &&
+		config.PaymentFeePercent(string(database.InvoiceTypeYookasa)) == 0 &&
+		config.PaymentFeePercent(string(database.InvoiceTypeTelegram)) == 0 {
+		return label
+	}
+	if invoiceType == database.InvoiceTypeTelegram {
+		return fmt.Sprintf("%s — %d ⭐", label, amount)
+	}
+	return label + " — " + locale.FormatMoney(langCode, float64(amount))
 }
 
 // RecurringDisableCallbackHandler обрабатывает отключение автопродления
 // Requirements: 3.1, 3.2
-func (h Handler) RecurringDisableCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PaymentHandlers) RecurringDisableCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -900,22 +1048,22 @@ func (h Handler) RecurringDisableCallbackHandler(ctx context.Context, b *bot.Bot
 	// Находим пользователя
 	customer, err := h.customerRepository.FindByTelegramId(ctx, telegramID)
 	if err != nil {
-		slog.Error("Error finding customer for recurring disable", "error", err)
+		slog.ErrorContext(ctx, "Error finding customer for recurring disable", "error", err)
 		return
 	}
 	if customer == nil {
-		slog.Error("Customer not found for recurring disable", "telegramID", telegramID)
+		slog.ErrorContext(ctx, "Customer not found for recurring disable", "telegramID", telegramID)
 		return
 	}
 
 	// Отключаем автопродление и очищаем payment_method_id
 	err = h.customerRepository.DisableRecurring(ctx, customer.ID)
 	if err != nil {
-		slog.Error("Error disabling recurring", "customerID", customer.ID, "error", err)
+		slog.ErrorContext(ctx, "Error disabling recurring", "customerID", customer.ID, "error", err)
 		return
 	}
 
-	slog.Info("Recurring disabled by user", "customerID", customer.ID, "telegramID", telegramID)
+	slog.InfoContext(ctx, "Recurring disabled by user", "customerID", customer.ID, "telegramID", telegramID)
 
 	// Отправляем подтверждение
 	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
@@ -931,7 +1079,7 @@ func (h Handler) RecurringDisableCallbackHandler(ctx context.Context, b *bot.Bot
 	})
 	if err != nil {
 		// Если не удалось отредактировать, отправляем новое сообщение
-		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+		_, _ = h.sendMenu(ctx, b, &bot.SendMessageParams{
 			ChatID:    callback.Chat.ID,
 			ParseMode: models.ParseModeHTML,
 			Text:      h.translation.GetText(langCode, "recurring_disabled_confirmation"),
@@ -944,8 +1092,8 @@ func (h Handler) RecurringDisableCallbackHandler(ctx context.Context, b *bot.Bot
 	}
 }
 
-// DeletePaymentMethodCallbackHandler удаляет сохранённый способ оплаты
-func (h Handler) DeletePaymentMethodCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+// RecurringPriceAcceptCallbackHandler принимает согласие клиента с повышением цены автопродления
+func (h PaymentHandlers) RecurringPriceAcceptCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -956,29 +1104,155 @@ func (h Handler) DeletePaymentMethodCallbackHandler(ctx context.Context, b *bot.
 
 	customer, err := h.customerRepository.FindByTelegramId(ctx, telegramID)
 	if err != nil {
-		slog.Error("Error finding customer for delete payment method", "error", err)
+		slog.ErrorContext(ctx, "Error finding customer for recurring price accept", "error", err)
 		return
 	}
 	if customer == nil {
-		slog.Error("Customer not found for delete payment method", "telegramID", telegramID)
+		slog.ErrorContext(ctx, "Customer not found for recurring price accept", "telegramID", telegramID)
 		return
 	}
 
-	// Удаляем способ оплаты и отключаем автопродление
-	err = h.customerRepository.DeletePaymentMethod(ctx, customer.ID)
+	text := h.translation.GetText(langCode, "recurring_price_nothing_to_accept")
+	if customer.RecurringEnabled && customer.RecurringPendingAmount != nil && customer.RecurringPendingEffectiveAt != nil {
+		accepted, err := h.customerRepository.AcceptRecurringPriceChange(ctx, customer.ID, clock.Now())
+		if err != nil {
+			slog.ErrorContext(ctx, "Error accepting recurring price change", "customerID", customer.ID, "error", err)
+			return
+		}
+		if accepted {
+			slog.InfoContext(ctx, "Recurring price change accepted", "customerID", customer.ID, "amount", *customer.RecurringPendingAmount)
+			text = h.translation.GetTextTemplate(langCode, "recurring_price_accepted", map[string]interface{}{
+				"new_price": locale.FormatMoney(langCode, float64(*customer.RecurringPendingAmount)),
+				"date":      locale.FormatDate(langCode, *customer.RecurringPendingEffectiveAt),
+			})
+		}
+	}
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    callback.Chat.ID,
+		MessageID: callback.ID,
+		ParseMode: models.ParseModeHTML,
+		Text:      text,
+		ReplyMarkup: models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{
+				{{Text: h.translation.GetText(langCode, "close_button"), CallbackData: CallbackCloseMessage}},
+			},
+		},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error editing recurring price accept message", "error", err)
+	}
+}
+
+// RecurringConfirmCallbackHandler принимает подтверждение клиентом ближайшего дорогого автосписания
+func (h PaymentHandlers) RecurringConfirmCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	telegramID := update.CallbackQuery.From.ID
+
+	customer, err := h.customerRepository.FindByTelegramId(ctx, telegramID)
 	if err != nil {
-		slog.Error("Error deleting payment method", "customerID", customer.ID, "error", err)
+		slog.ErrorContext(ctx, "Error finding customer for recurring confirm", "error", err)
+		return
+	}
+	if customer == nil {
+		slog.ErrorContext(ctx, "Customer not found for recurring confirm", "telegramID", telegramID)
 		return
 	}
 
-	slog.Info("Payment method deleted by user", "customerID", customer.ID, "telegramID", telegramID)
+	confirmed, err := h.customerRepository.ConfirmRecurringCharge(ctx, customer.ID, clock.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "Error confirming recurring charge", "customerID", customer.ID, "error", err)
+		return
+	}
+	text := h.translation.GetText(langCode, "recurring_confirm_nothing")
+	if confirmed {
+		slog.InfoContext(ctx, "Recurring charge confirmed", "customerID", customer.ID)
+		text = h.translation.GetText(langCode, "recurring_confirmed")
+	}
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    callback.Chat.ID,
+		MessageID: callback.ID,
+		ParseMode: models.ParseModeHTML,
+		Text:      text,
+		ReplyMarkup: models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{
+				{{Text: h.translation.GetText(langCode, "close_button"), CallbackData: CallbackCloseMessage}},
+			},
+		},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error editing recurring confirm message", "error", err)
+	}
+}
+
+// DeletePaymentMethodCallbackHandler удаляет сохранённую карту id. Кнопки из старых сообщений приходят без id —
+// для них удаляется карта, с которой списывается автопродление
+func (h PaymentHandlers) DeletePaymentMethodCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	telegramID := update.CallbackQuery.From.ID
+	callbackQuery := parseCallbackData(update.CallbackQuery.Data)
+
+	customer, err := h.customerRepository.FindByTelegramId(ctx, telegramID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding customer for delete payment method", "error", err)
+		return
+	}
+	if customer == nil {
+		slog.ErrorContext(ctx, "Customer not found for delete payment method", "telegramID", telegramID)
+		return
+	}
+
+	id, _ := strconv.ParseInt(callbackQuery["id"], 10, 64)
+	if id == 0 && h.paymentMethods != nil {
+		id = h.recurringPaymentMethodID(ctx, customer)
+	}
+
+	recurringDisabled := true
+	if id != 0 && h.paymentMethods != nil {
+		var deleted *database.PaymentMethod
+		deleted, recurringDisabled, err = h.paymentMethods.Delete(ctx, customer.ID, id)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error deleting payment method", "customerID", customer.ID, "error", err)
+			return
+		}
+		if deleted == nil {
+			// Карта уже удалена (повторное нажатие) — показываем актуальный список
+			h.showSavedPaymentMethods(ctx, b, callback, langCode, customer, callbackQuery["from"] == "notification")
+			return
+		}
+	} else {
+		// Удаляем способ оплаты и отключаем автопродление
+		err = h.customerRepository.DeletePaymentMethod(ctx, customer.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error deleting payment method", "customerID", customer.ID, "error", err)
+			return
+		}
+	}
+
+	slog.InfoContext(ctx, "Payment method deleted by user", "customerID", customer.ID, "telegramID", telegramID, "recurringDisabled", recurringDisabled)
+
+	text := h.translation.GetText(langCode, "payment_method_deleted")
+	if recurringDisabled {
+		text += h.translation.GetText(langCode, "payment_method_deleted_recurring_disabled")
+	}
 
 	// Отправляем подтверждение
 	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
 		ChatID:    callback.Chat.ID,
 		MessageID: callback.ID,
 		ParseMode: models.ParseModeHTML,
-		Text:      h.translation.GetText(langCode, "payment_method_deleted"),
+		Text:      text,
 		ReplyMarkup: models.InlineKeyboardMarkup{
 			InlineKeyboard: [][]models.InlineKeyboardButton{
 				{{Text: h.translation.GetText(langCode, "back_to_menu"), CallbackData: CallbackStart}},
@@ -986,10 +1260,10 @@ func (h Handler) DeletePaymentMethodCallbackHandler(ctx context.Context, b *bot.
 		},
 	})
 	if err != nil {
-		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+		_, _ = h.sendMenu(ctx, b, &bot.SendMessageParams{
 			ChatID:    callback.Chat.ID,
 			ParseMode: models.ParseModeHTML,
-			Text:      h.translation.GetText(langCode, "payment_method_deleted"),
+			Text:      text,
 			ReplyMarkup: models.InlineKeyboardMarkup{
 				InlineKeyboard: [][]models.InlineKeyboardButton{
 					{{Text: h.translation.GetText(langCode, "back_to_menu"), CallbackData: CallbackStart}},
@@ -999,9 +1273,67 @@ func (h Handler) DeletePaymentMethodCallbackHandler(ctx context.Context, b *bot.
 	}
 }
 
+// recurringPaymentMethodID возвращает id сохранённой карты, с которой списывается автопродление (0 если такой нет)
+func (h PaymentHandlers) recurringPaymentMethodID(ctx context.Context, customer *database.Customer) int64 {
+	methods, err := h.paymentMethods.FindByCustomer(ctx, customer.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding payment methods", "customerID", customer.ID, "error", err)
+		return 0
+	}
+	for _, method := range methods {
+		if method.IsRecurring(customer) {
+			return method.ID
+		}
+	}
+	return 0
+}
+
+// SelectPaymentMethodCallbackHandler привязывает автопродление к выбранной сохранённой карте
+func (h PaymentHandlers) SelectPaymentMethodCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	telegramID := update.CallbackQuery.From.ID
+	callbackQuery := parseCallbackData(update.CallbackQuery.Data)
+
+	id, err := strconv.ParseInt(callbackQuery["id"], 10, 64)
+	if err != nil || h.paymentMethods == nil {
+		slog.ErrorContext(ctx, "Invalid payment method select callback", "data", update.CallbackQuery.Data)
+		return
+	}
+
+	customer, err := h.customerRepository.FindByTelegramId(ctx, telegramID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding customer for select payment method", "error", err)
+		return
+	}
+	if customer == nil {
+		slog.ErrorContext(ctx, "Customer not found for select payment method", "telegramID", telegramID)
+		return
+	}
+
+	selected, err := h.paymentMethods.SelectForRecurring(ctx, customer.ID, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error selecting payment method", "customerID", customer.ID, "error", err)
+		return
+	}
+	if selected {
+		slog.InfoContext(ctx, "Recurring payment method selected by user", "customerID", customer.ID, "paymentMethod", id)
+		// Перечитываем клиента, чтобы отметить выбранную карту в списке
+		if updated, err := h.customerRepository.FindByTelegramId(ctx, telegramID); err == nil && updated != nil {
+			customer = updated
+		}
+	}
+
+	h.showSavedPaymentMethods(ctx, b, callback, langCode, customer, callbackQuery["from"] == "notification")
+}
+
 // showLegacyPriceMenuNew показывает старое меню цен (новое сообщение)
 // Requirements: 5.1, 5.2 - показывает кнопку promo tariff если есть активное предложение
-func (h Handler) showLegacyPriceMenuNew(ctx context.Context, b *bot.Bot, chatID int64, langCode string) {
+func (h PaymentHandlers) showLegacyPriceMenuNew(ctx context.Context, b *bot.Bot, chatID int64, langCode string) {
 	keyboard := [][]models.InlineKeyboardButton{}
 
 	// Проверяем наличие активного promo offer у пользователя
@@ -1010,7 +1342,7 @@ func (h Handler) showLegacyPriceMenuNew(ctx context.Context, b *bot.Bot, chatID
 	if err == nil && customer != nil && database.HasActivePromoOffer(customer) {
 		// Добавляем кнопку promo tariff с эмодзи 🎁 в начало меню
 		btnText := h.translation.GetTextTemplate(langCode, "promo_tariff_button", map[string]interface{}{
-			"price":   *customer.PromoOfferPrice,
+			"price":   locale.FormatMoney(langCode, float64(*customer.PromoOfferPrice)),
 			"months":  *customer.PromoOfferMonths,
 			"devices": *customer.PromoOfferDevices,
 		})
@@ -1023,29 +1355,29 @@ func (h Handler) showLegacyPriceMenuNew(ctx context.Context, b *bot.Bot, chatID
 
 	if config.Price1() > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_1", map[string]interface{}{"price": config.Price1()}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d", CallbackSell, 1, config.Price1()),
+			Text:         h.translation.GetTextTemplate(langCode, "month_1", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(config.Price1()))}),
+			CallbackData: fmt.Sprintf("%s?month=%d", CallbackSell, 1),
 		})
 	}
 
 	if config.Price3() > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_3", map[string]interface{}{"price": config.Price3()}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d", CallbackSell, 3, config.Price3()),
+			Text:         h.translation.GetTextTemplate(langCode, "month_3", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(config.Price3()))}),
+			CallbackData: fmt.Sprintf("%s?month=%d", CallbackSell, 3),
 		})
 	}
 
 	if config.Price6() > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_6", map[string]interface{}{"price": config.Price6()}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d", CallbackSell, 6, config.Price6()),
+			Text:         h.translation.GetTextTemplate(langCode, "month_6", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(config.Price6()))}),
+			CallbackData: fmt.Sprintf("%s?month=%d", CallbackSell, 6),
 		})
 	}
 
 	if config.Price12() > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_12", map[string]interface{}{"price": config.Price12()}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d", CallbackSell, 12, config.Price12()),
+			Text:         h.translation.GetTextTemplate(langCode, "month_12", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(config.Price12()))}),
+			CallbackData: fmt.Sprintf("%s?month=%d", CallbackSell, 12),
 		})
 	}
 
@@ -1057,10 +1389,10 @@ func (h Handler) showLegacyPriceMenuNew(ctx context.Context, b *bot.Bot, chatID
 	}
 
 	keyboard = append(keyboard, []models.InlineKeyboardButton{
-		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart},
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
 	})
 
-	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+	_, err = h.sendMenu(ctx, b, &bot.SendMessageParams{
 		ChatID:    chatID,
 		ParseMode: models.ParseModeHTML,
 		ReplyMarkup: models.InlineKeyboardMarkup{
@@ -1070,13 +1402,13 @@ func (h Handler) showLegacyPriceMenuNew(ctx context.Context, b *bot.Bot, chatID
 	})
 
 	if err != nil {
-		slog.Error("Error sending buy message", slog.Any("error", err))
+		slog.ErrorContext(ctx, "Error sending buy message", slog.Any("error", err))
 	}
 }
 
 // SavedPaymentMethodsCallbackHandler показывает сохранённые способы оплаты
 // Requirements: 4.1, 4.2
-func (h Handler) SavedPaymentMethodsCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PaymentHandlers) SavedPaymentMethodsCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -1092,45 +1424,40 @@ func (h Handler) SavedPaymentMethodsCallbackHandler(ctx context.Context, b *bot.
 	// Находим пользователя
 	customer, err := h.customerRepository.FindByTelegramId(ctx, telegramID)
 	if err != nil {
-		slog.Error("Error finding customer for saved payment methods", "error", err)
+		slog.ErrorContext(ctx, "Error finding customer for saved payment methods", "error", err)
 		return
 	}
 	if customer == nil {
-		slog.Error("Customer not found for saved payment methods", "telegramID", telegramID)
+		slog.ErrorContext(ctx, "Customer not found for saved payment methods", "telegramID", telegramID)
 		return
 	}
 
+	h.showSavedPaymentMethods(ctx, b, callback, langCode, customer, fromNotification)
+}
+
+// showSavedPaymentMethods показывает список сохранённых карт: нажатие на карту привязывает к ней автопродление,
+// 🗑 рядом удаляет её
+func (h PaymentHandlers) showSavedPaymentMethods(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, customer *database.Customer, fromNotification bool) {
+	var methods []database.PaymentMethod
+	if h.paymentMethods != nil {
+		var err error
+		methods, err = h.paymentMethods.FindByCustomer(ctx, customer.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error finding payment methods", "customerID", customer.ID, "error", err)
+		}
+	}
+
 	var text string
 	var keyboard [][]models.InlineKeyboardButton
 
 	// Если нет сохранённого способа оплаты
-	if customer.PaymentMethodID == nil {
+	if len(methods) == 0 && customer.PaymentMethodID == nil {
 		text = h.translation.GetText(langCode, "saved_payment_methods_empty")
-		if fromNotification {
-			keyboard = [][]models.InlineKeyboardButton{
-				{{Text: h.translation.GetText(langCode, "close_button"), CallbackData: CallbackCloseMessage}},
-			}
-		} else {
-			// Формируем callback для возврата в меню способов оплаты
-			backCallback := CallbackBuy
-			month := callbackQuery["m"]
-			amount := callbackQuery["a"]
-			tariff := callbackQuery["n"]
-			if month != "" && amount != "" {
-				backCallback = fmt.Sprintf("%s?month=%s&amount=%s", CallbackSell, month, amount)
-				if tariff != "" {
-					backCallback += fmt.Sprintf("&tariff=%s", tariff)
-				}
-			}
-			keyboard = [][]models.InlineKeyboardButton{
-				{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: backCallback}},
-			}
-		}
 	} else {
 		// Есть сохранённый способ оплаты
 		text = h.translation.GetText(langCode, "saved_payment_methods_title")
 
-		if customer.RecurringEnabled {
+		if customer.RecurringEnabled && customer.PaymentMethodID != nil {
 			// Автопродление включено - показываем детали
 			tariffName := "—"
 			if customer.RecurringTariffName != nil {
@@ -1144,12 +1471,12 @@ func (h Handler) SavedPaymentMethodsCallbackHandler(ctx context.Context, b *bot.
 
 			nextCharge := "—"
 			if customer.ExpireAt != nil {
-				nextCharge = customer.ExpireAt.Format("02.01.2006")
+				nextCharge = locale.FormatDate(langCode, *customer.ExpireAt)
 			}
 
 			text += h.translation.GetTextTemplate(langCode, "saved_payment_methods_status_enabled", map[string]interface{}{
 				"tariff":      tariffName,
-				"amount":      amount,
+				"amount":      locale.FormatMoney(langCode, float64(amount)),
 				"next_charge": nextCharge,
 			})
 		} else {
@@ -1157,32 +1484,29 @@ func (h Handler) SavedPaymentMethodsCallbackHandler(ctx context.Context, b *bot.
 			text += h.translation.GetText(langCode, "saved_payment_methods_status_disabled")
 		}
 
-		keyboard = [][]models.InlineKeyboardButton{
-			{{Text: h.translation.GetText(langCode, "delete_saved_payment_method"), CallbackData: CallbackDeletePaymentMethod}},
-		}
-		if fromNotification {
-			keyboard = append(keyboard, []models.InlineKeyboardButton{
-				{Text: h.translation.GetText(langCode, "close_button"), CallbackData: CallbackCloseMessage},
-			})
+		if len(methods) > 0 {
+			text += h.translation.GetText(langCode, "saved_payment_methods_hint")
+			keyboard = h.savedPaymentMethodButtons(langCode, customer, methods, fromNotification)
 		} else {
-			// Формируем callback для возврата в меню способов оплаты
-			backCallback := CallbackBuy
-			month := callbackQuery["m"]
-			amount := callbackQuery["a"]
-			tariff := callbackQuery["n"]
-			if month != "" && amount != "" {
-				backCallback = fmt.Sprintf("%s?month=%s&amount=%s", CallbackSell, month, amount)
-				if tariff != "" {
-					backCallback += fmt.Sprintf("&tariff=%s", tariff)
-				}
+			// Список карт недоступен — оставляем удаление карты автопродления
+			keyboard = [][]models.InlineKeyboardButton{
+				{{Text: h.translation.GetText(langCode, "delete_saved_payment_method"), CallbackData: CallbackDeletePaymentMethod}},
 			}
-			keyboard = append(keyboard, []models.InlineKeyboardButton{
-				{Text: h.translation.GetText(langCode, "back_button"), CallbackData: backCallback},
-			})
 		}
 	}
 
-	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+	if fromNotification {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: h.translation.GetText(langCode, "close_button"), CallbackData: CallbackCloseMessage},
+		})
+	} else {
+		// "Назад" возвращает в меню способов оплаты
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
+		})
+	}
+
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
 		ChatID:    callback.Chat.ID,
 		MessageID: callback.ID,
 		ParseMode: models.ParseModeHTML,
@@ -1193,36 +1517,67 @@ func (h Handler) SavedPaymentMethodsCallbackHandler(ctx context.Context, b *bot.
 	})
 	if err != nil {
 		// Если не удалось отредактировать, отправляем новое сообщение с кнопкой закрытия
+		if !fromNotification {
+			keyboard[len(keyboard)-1] = []models.InlineKeyboardButton{
+				{Text: h.translation.GetText(langCode, "close_button"), CallbackData: CallbackCloseMessage},
+			}
+		}
 		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
 			ChatID:    callback.Chat.ID,
 			ParseMode: models.ParseModeHTML,
 			Text:      text,
 			ReplyMarkup: models.InlineKeyboardMarkup{
-				InlineKeyboard: h.savedPaymentMethodsKeyboardWithClose(langCode, customer),
+				InlineKeyboard: keyboard,
 			},
 		})
 	}
 }
 
-// savedPaymentMethodsKeyboardWithClose формирует клавиатуру для нового сообщения с кнопкой закрытия
-func (h Handler) savedPaymentMethodsKeyboardWithClose(langCode string, customer *database.Customer) [][]models.InlineKeyboardButton {
-	var keyboard [][]models.InlineKeyboardButton
+// savedPaymentMethodButtons строка на каждую карту: выбор карты для автопродления и удаление.
+// Карта, с которой сейчас списывается автопродление, отмечена ✅
+func (h PaymentHandlers) savedPaymentMethodButtons(langCode string, customer *database.Customer, methods []database.PaymentMethod, fromNotification bool) [][]models.InlineKeyboardButton {
+	suffix := ""
+	if fromNotification {
+		suffix = "&from=notification"
+	}
 
-	if customer.PaymentMethodID != nil {
+	var keyboard [][]models.InlineKeyboardButton
+	for _, method := range methods {
+		label := method.Label()
+		if label == "" {
+			label = h.translation.GetText(langCode, "saved_payment_method_unknown")
+		}
+		if method.IsRecurring(customer) {
+			label = "✅ " + label
+		} else {
+			label = "💳 " + label
+		}
 		keyboard = append(keyboard, []models.InlineKeyboardButton{
-			{Text: h.translation.GetText(langCode, "delete_saved_payment_method"), CallbackData: CallbackDeletePaymentMethod},
+			{Text: label, CallbackData: fmt.Sprintf("%s?id=%d%s", CallbackSelectPaymentMethod, method.ID, suffix)},
+			{Text: "🗑", CallbackData: fmt.Sprintf("%s?id=%d%s", CallbackDeletePaymentMethod, method.ID, suffix)},
 		})
 	}
-
-	keyboard = append(keyboard, []models.InlineKeyboardButton{
-		{Text: h.translation.GetText(langCode, "close_button"), CallbackData: CallbackCloseMessage},
-	})
-
 	return keyboard
 }
 
+// hasSavedPaymentMethods возвращает true, если у клиента есть хотя бы одна сохранённая карта
+func (h PaymentHandlers) hasSavedPaymentMethods(ctx context.Context, customer *database.Customer) bool {
+	if customer.PaymentMethodID != nil {
+		return true
+	}
+	if h.paymentMethods == nil {
+		return false
+	}
+	methods, err := h.paymentMethods.FindByCustomer(ctx, customer.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding payment methods", "customerID", customer.ID, "error", err)
+		return false
+	}
+	return len(methods) > 0
+}
+
 // CloseMessageCallbackHandler удаляет сообщение при нажатии на кнопку "Закрыть"
-func (h Handler) CloseMessageCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PaymentHandlers) CloseMessageCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -0,0 +1,310 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/privacy"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// Выгрузка тяжёлая, поэтому клиент может запросить её не чаще раза в privacyExportTTL секунд.
+// Удаление нужно подтвердить в течение privacyEraseTTL секунд после предупреждения
+const (
+	privacyExportKey    = "privacy_export_%d"
+	privacyExportTTL    = 600
+	privacyEraseKey     = "privacy_erase_%d"
+	privacyEraseTTL     = 300
+	callbackAdminErase  = "admin_erase_"
+	adminEraseWithPanel = "panel"
+)
+
+// PrivacyCommandHandler обрабатывает /privacy: клиент может выгрузить свои данные или удалить их
+func (h ProfileHandlers) PrivacyCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if !config.IsPrivacySelfServiceEnabled() || h.privacy == nil {
+		return
+	}
+
+	langCode := update.Message.From.LanguageCode
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    update.Message.Chat.ID,
+		Text:      h.translation.GetText(langCode, "privacy_menu"),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: h.translation.GetText(langCode, "privacy_export_button"), CallbackData: CallbackPrivacyExport}},
+			{{Text: h.translation.GetText(langCode, "privacy_erase_button"), CallbackData: CallbackPrivacyErase}},
+			{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart}},
+		}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending privacy menu", "error", err)
+	}
+}
+
+// PrivacyExportCallbackHandler отправляет клиенту JSON-файл со всеми его данными
+func (h ProfileHandlers) PrivacyExportCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	chatID := update.CallbackQuery.From.ID
+	langCode := update.CallbackQuery.From.LanguageCode
+	customer, ok := h.privacyCustomer(ctx, chatID)
+	if !ok {
+		return
+	}
+
+	key := fmt.Sprintf(privacyExportKey, chatID)
+	if _, found := h.cache.GetString(key); found {
+		h.sendPrivacyText(ctx, b, chatID, h.translation.GetText(langCode, "privacy_export_too_often"))
+		return
+	}
+
+	content, err := h.privacy.Export(ctx, customer)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error exporting customer data", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		h.sendPrivacyText(ctx, b, chatID, h.translation.GetText(langCode, "privacy_export_failed"))
+		return
+	}
+	h.cache.SetString(key, "1", privacyExportTTL)
+
+	_, err = b.SendDocument(ctx, &bot.SendDocumentParams{
+		ChatID:    chatID,
+		Document:  &models.InputFileUpload{Filename: privacy.FileName(customer), Data: bytes.NewReader(content)},
+		Caption:   h.translation.GetText(langCode, "privacy_export_caption"),
+		ParseMode: models.ParseModeHTML,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending customer data export", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+	}
+}
+
+// PrivacyEraseCallbackHandler предупреждает, что удаление необратимо и подписка перестанет работать.
+// Подтвердить удаление можно только в течение privacyEraseTTL секунд
+func (h ProfileHandlers) PrivacyEraseCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	customer, ok := h.privacyCustomer(ctx, callback.Chat.ID)
+	if !ok {
+		return
+	}
+	h.cache.SetString(fmt.Sprintf(privacyEraseKey, callback.Chat.ID), "1", privacyEraseTTL)
+
+	text := h.translation.GetText(langCode, "privacy_erase_confirm")
+	if customer.ExpireAt != nil && customer.ExpireAt.After(clock.Now()) {
+		text += h.translation.GetTextTemplate(langCode, "privacy_erase_subscription_warning", map[string]interface{}{
+			"date": locale.FormatDate(langCode, *customer.ExpireAt),
+		})
+	}
+
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    callback.Chat.ID,
+		MessageID: callback.ID,
+		ParseMode: models.ParseModeHTML,
+		Text:      text,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: h.translation.GetText(langCode, "privacy_erase_confirm_button"), CallbackData: CallbackPrivacyEraseConfirm}},
+			{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart}},
+		}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending privacy erase confirmation", "error", err)
+	}
+}
+
+// PrivacyEraseConfirmCallbackHandler удаляет пользователя в панели и обезличивает клиента
+func (h ProfileHandlers) PrivacyEraseConfirmCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	customer, ok := h.privacyCustomer(ctx, callback.Chat.ID)
+	if !ok {
+		return
+	}
+
+	key := fmt.Sprintf(privacyEraseKey, callback.Chat.ID)
+	text := h.translation.GetText(langCode, "privacy_erase_expired")
+	if _, found := h.cache.GetString(key); found {
+		h.cache.Delete(key)
+		text = h.translation.GetText(langCode, "privacy_erase_done")
+		if _, err := h.privacy.Erase(ctx, customer, true); err != nil {
+			slog.ErrorContext(ctx, "Error erasing customer data", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+			text = h.translation.GetText(langCode, "privacy_erase_failed")
+		}
+	}
+
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    callback.Chat.ID,
+		MessageID: callback.ID,
+		ParseMode: models.ParseModeHTML,
+		Text:      text,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending privacy erase result", "error", err)
+	}
+}
+
+// privacyCustomer находит клиента для выгрузки или удаления данных. ok=false, если функция выключена
+func (h ProfileHandlers) privacyCustomer(ctx context.Context, telegramID int64) (*database.Customer, bool) {
+	if !config.IsPrivacySelfServiceEnabled() || h.privacy == nil {
+		return nil, false
+	}
+	customer, err := h.customerRepository.FindByTelegramId(ctx, telegramID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for privacy request", "error", err)
+		return nil, false
+	}
+	return customer, true
+}
+
+func (h ProfileHandlers) sendPrivacyText(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending privacy message", "error", err)
+	}
+}
+
+// ExportCommandHandler обрабатывает команду админа /export <telegram_id>: присылает JSON со всеми данными клиента
+func (h AdminHandlers) ExportCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	customer, ok := h.privacyCommandCustomer(ctx, b, update, "/export")
+	if !ok {
+		return
+	}
+
+	content, err := h.privacy.Export(ctx, customer)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error exporting customer data by admin", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Не удалось выгрузить данные: "+utils.EscapeHTML(err.Error()))
+		return
+	}
+
+	_, err = b.SendDocument(ctx, &bot.SendDocumentParams{
+		ChatID:    update.Message.Chat.ID,
+		Document:  &models.InputFileUpload{Filename: privacy.FileName(customer), Data: bytes.NewReader(content)},
+		Caption:   fmt.Sprintf("📦 Данные пользователя <code>%d</code>", customer.TelegramID),
+		ParseMode: models.ParseModeHTML,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending customer data export to admin", "error", err)
+		return
+	}
+	h.recordAudit(ctx, update.Message.From.ID, customer.ID, database.AuditActionDataExported, "")
+}
+
+// EraseCommandHandler обрабатывает команду админа /erase <telegram_id>: показывает карточку пользователя
+// и просит подтвердить удаление. Пользователя в панели можно оставить или удалить вместе с данными
+func (h AdminHandlers) EraseCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	customer, ok := h.privacyCommandCustomer(ctx, b, update, "/erase")
+	if !ok {
+		return
+	}
+
+	text := "⚠️ <b>Удаление данных пользователя</b>\n\n" +
+		"Telegram ID, ссылка подписки, рефералы, теги, заметка, телефон и способ оплаты будут удалены, " +
+		"покупки останутся обезличенными. Отменить нельзя.\n" +
+		"Если оставить пользователя в панели, синхронизация снова создаст его в боте.\n\n" +
+		h.supportCard(ctx, customer)
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    update.Message.Chat.ID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: "🗑 Удалить данные и пользователя в панели", CallbackData: fmt.Sprintf("%s%d:%s", callbackAdminErase, customer.TelegramID, adminEraseWithPanel)}},
+			{{Text: "🗑 Удалить данные, панель не трогать", CallbackData: fmt.Sprintf("%s%d", callbackAdminErase, customer.TelegramID)}},
+			{{Text: "❌ Отмена", CallbackData: "admin_close"}},
+		}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending erase confirmation", "error", err)
+	}
+}
+
+// AdminEraseCallback удаляет данные пользователя после подтверждения админом
+func (h AdminHandlers) AdminEraseCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	idStr, mode, _ := strings.Cut(strings.TrimPrefix(update.CallbackQuery.Data, callbackAdminErase), ":")
+	telegramID, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		slog.ErrorContext(ctx, "Invalid erase callback", "data", update.CallbackQuery.Data)
+		return
+	}
+	deletePanelUser := mode == adminEraseWithPanel
+
+	chatID := update.CallbackQuery.Message.Message.Chat.ID
+	customer, ok := h.findSupportCustomer(ctx, b, chatID, telegramID)
+	if !ok || h.privacy == nil {
+		return
+	}
+
+	result := "✅ Данные пользователя удалены"
+	deleted, err := h.privacy.Erase(ctx, customer, deletePanelUser)
+	switch {
+	case errors.Is(err, privacy.ErrAlreadyAnonymized):
+		result = "ℹ️ Данные пользователя уже удалены"
+	case err != nil:
+		slog.ErrorContext(ctx, "Error erasing customer data by admin", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		result = "❌ Не удалось удалить данные: " + utils.EscapeHTML(err.Error())
+	default:
+		details := ""
+		if deletePanelUser {
+			details = fmt.Sprintf("пользователей в панели удалено: %d", deleted)
+			result += fmt.Sprintf(", пользователей в панели удалено: %d", deleted)
+		}
+		h.recordAudit(ctx, update.CallbackQuery.From.ID, customer.ID, database.AuditActionDataErased, details)
+	}
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    chatID,
+		MessageID: update.CallbackQuery.Message.Message.ID,
+		ParseMode: models.ParseModeHTML,
+		Text:      result,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error editing message", "error", err)
+	}
+}
+
+// privacyCommandCustomer разбирает "<команда> <telegram_id>" и находит пользователя
+func (h AdminHandlers) privacyCommandCustomer(ctx context.Context, b *bot.Bot, update *models.Update, command string) (*database.Customer, bool) {
+	args := strings.Fields(update.Message.Text)
+	if len(args) != 2 {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, fmt.Sprintf("❌ Использование: <code>%s &lt;telegram_id&gt;</code>", command))
+		return nil, false
+	}
+	telegramID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Неверный telegram_id")
+		return nil, false
+	}
+	if h.privacy == nil {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Выгрузка и удаление данных не настроены")
+		return nil, false
+	}
+	return h.findSupportCustomer(ctx, b, update.Message.Chat.ID, telegramID)
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/privacy"
+)
+
+type mockAdminCustomers struct {
+	adminCustomers
+	customer *database.Customer
+}
+
+func (m *mockAdminCustomers) FindByTelegramId(ctx context.Context, telegramId int64) (*database.Customer, error) {
+	return m.customer, nil
+}
+
+type mockAuditLog struct {
+	auditLogStore
+	actions []string
+}
+
+func (m *mockAuditLog) Record(ctx context.Context, adminID, customerID int64, action, details string) error {
+	m.actions = append(m.actions, action)
+	return nil
+}
+
+// mockPrivacy запоминает, с какими параметрами вызывалось удаление данных
+type mockPrivacy struct {
+	customerPrivacy
+	err             error
+	erased          []int64
+	deletePanelUser bool
+}
+
+func (m *mockPrivacy) Erase(ctx context.Context, customer *database.Customer, deletePanelUser bool) (int, error) {
+	m.erased = append(m.erased, customer.ID)
+	m.deletePanelUser = deletePanelUser
+	return 1, m.err
+}
+
+func TestAdminEraseCallback(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		err           error
+		wantPanel     bool
+		wantAudit     bool
+		wantResultPfx string
+	}{
+		{"erase with panel user", "admin_erase_42:panel", nil, true, true, "✅"},
+		{"erase keeping panel user", "admin_erase_42", nil, false, true, "✅"},
+		{"already erased", "admin_erase_42", privacy.ErrAlreadyAnonymized, false, false, "ℹ️"},
+		{"panel failure", "admin_erase_42:panel", errors.New("timeout"), true, false, "❌"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, tg := newTestBot(t)
+			audit := &mockAuditLog{}
+			service := &mockPrivacy{err: tt.err}
+			customers := &mockAdminCustomers{customer: &database.Customer{ID: 7, TelegramID: 42}}
+			h := NewAdminHandlers(fakeTranslator{}, newFakeCache(), customers, nil, nil, nil, audit, nil, nil, nil, nil, nil, service, nil, nil, nil, nil, nil, nil, nil)
+
+			h.AdminEraseCallback(context.Background(), b, paymentCallbackUpdate(tt.data))
+
+			if len(service.erased) != 1 || service.erased[0] != 7 {
+				t.Fatalf("Expected customer 7 to be erased, got %v", service.erased)
+			}
+			if service.deletePanelUser != tt.wantPanel {
+				t.Errorf("Expected deletePanelUser=%v", tt.wantPanel)
+			}
+			if tt.wantAudit != (len(audit.actions) == 1 && audit.actions[0] == database.AuditActionDataErased) {
+				t.Errorf("Unexpected audit log %v", audit.actions)
+			}
+			edited := tg.called("editMessageText")
+			if len(edited) != 1 || !strings.HasPrefix(edited[0].params["text"], tt.wantResultPfx) {
+				t.Fatalf("Expected result %q, got %+v", tt.wantResultPfx, edited)
+			}
+		})
+	}
+}
+
+func TestAdminEraseCallbackUnknownCustomer(t *testing.T) {
+	b, tg := newTestBot(t)
+	service := &mockPrivacy{}
+	h := NewAdminHandlers(fakeTranslator{}, newFakeCache(), &mockAdminCustomers{}, nil, nil, nil, &mockAuditLog{}, nil, nil, nil, nil, nil, service, nil, nil, nil, nil, nil, nil, nil)
+
+	h.AdminEraseCallback(context.Background(), b, paymentCallbackUpdate("admin_erase_42:panel"))
+
+	if len(service.erased) != 0 {
+		t.Fatalf("Expected nothing to be erased, got %v", service.erased)
+	}
+	if sent := tg.called("sendMessage"); len(sent) != 1 || !strings.Contains(sent[0].params["text"], "не найден") {
+		t.Fatalf("Expected not found message, got %+v", sent)
+	}
+}
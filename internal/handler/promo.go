@@ -13,12 +13,14 @@ import (
 
 	"remnawave-tg-shop-bot/internal/config"
 	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/utils"
 )
 
 
 
 // User handler - apply promo code (из главного меню — редактирует сообщение)
-func (h Handler) PromoCodeCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) PromoCodeCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	lang := update.CallbackQuery.From.LanguageCode
 	callback := update.CallbackQuery.Message.Message
 	chatID := callback.Chat.ID
@@ -48,7 +50,7 @@ func (h Handler) PromoCodeCallbackHandler(ctx context.Context, b *bot.Bot, updat
 }
 
 // BroadcastPromoCallbackHandler - обработчик кнопки промокода из broadcast (всегда новое сообщение)
-func (h Handler) BroadcastPromoCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) BroadcastPromoCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	lang := update.CallbackQuery.From.LanguageCode
 	chatID := update.CallbackQuery.Message.Message.Chat.ID
 
@@ -63,7 +65,7 @@ func (h Handler) BroadcastPromoCallbackHandler(ctx context.Context, b *bot.Bot,
 	}
 
 	// Всегда новое сообщение чтобы не терять broadcast
-	_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+	_, _ = h.sendMenu(ctx, b, &bot.SendMessageParams{
 		ChatID:      chatID,
 		Text:        h.translation.GetText(lang, "promo_enter_code"),
 		ParseMode:   models.ParseModeHTML,
@@ -77,7 +79,7 @@ func (h Handler) BroadcastPromoCallbackHandler(ctx context.Context, b *bot.Bot,
 
 // Handle promo code text input
 // Requirements: 4.1, 4.2, 4.6, 7.1, 7.2
-func (h Handler) PromoCodeInputHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) PromoCodeInputHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.Message == nil {
 		return
 	}
@@ -92,6 +94,8 @@ func (h Handler) PromoCodeInputHandler(ctx context.Context, b *bot.Bot, update *
 
 	// Clear state
 	h.cache.Delete(stateKey)
+	// В режиме чистого чата введённый промокод удаляется после ответа
+	defer h.deleteUserMessage(ctx, b, update.Message, config.CleanChatMenus)
 
 	lang := update.Message.From.LanguageCode
 	chatID := update.Message.Chat.ID
@@ -100,83 +104,79 @@ func (h Handler) PromoCodeInputHandler(ctx context.Context, b *bot.Bot, update *
 	// Get customer
 	customer, err := h.customerRepository.FindByTelegramId(ctx, userID)
 	if err != nil || customer == nil {
-		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+		_, _ = h.sendMenu(ctx, b, &bot.SendMessageParams{
 			ChatID: chatID,
 			Text:   h.translation.GetText(lang, "error_occurred"),
 		})
 		return
 	}
 
+	errorKey := h.applyPromoCode(ctx, b, chatID, lang, customer, update.Message.From.Username, code)
+	if errorKey == "" {
+		return
+	}
+
+	// Восстанавливаем состояние для повторного ввода
+	h.cache.SetString(stateKey, "waiting_code", 300)
+
+	keyboard := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: h.translation.GetText(lang, "back_to_menu"), CallbackData: CallbackStart}},
+		},
+	}
+	_, _ = h.sendMenu(ctx, b, &bot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        h.translation.GetText(lang, errorKey) + "\n\n" + h.translation.GetText(lang, "promo_try_again"),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: keyboard,
+	})
+}
+
+// applyPromoCode активирует промокод на тариф или обычный промокод и отправляет клиенту результат.
+// Возвращает ключ перевода ошибки, если промокод не активирован: сообщение об ошибке отправляет вызывающий
+func (h PromoHandlers) applyPromoCode(ctx context.Context, b *bot.Bot, chatID int64, lang string, customer *database.Customer, username, code string) (errorKey string) {
 	// First try promo tariff code if feature is enabled
 	// Requirements: 4.6 - backward compatibility with regular promo codes
 	if config.IsPromoTariffCodesEnabled() {
 		tariffResult := h.promoTariffService.ApplyPromoTariffCode(ctx, customer.ID, code)
-		
+
 		// If promo tariff code found (success or specific error), handle it
 		if tariffResult.Success || (tariffResult.ErrorKey != "promo_tariff_not_found" && tariffResult.ErrorKey != "promo_tariff_invalid_format") {
 			if !tariffResult.Success {
 				// Promo tariff code found but validation failed
-				h.cache.SetString(stateKey, "waiting_code", 300)
-				
-				keyboard := &models.InlineKeyboardMarkup{
-					InlineKeyboard: [][]models.InlineKeyboardButton{
-						{{Text: h.translation.GetText(lang, "back_to_menu"), CallbackData: CallbackStart}},
-					},
-				}
-				_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
-					ChatID:      chatID,
-					Text:        h.translation.GetText(lang, tariffResult.ErrorKey) + "\n\n" + h.translation.GetText(lang, "promo_try_again"),
-					ParseMode:   models.ParseModeHTML,
-					ReplyMarkup: keyboard,
-				})
-				return
+				return tariffResult.ErrorKey
 			}
 
 			// Success - promo tariff code activated
-			slog.Info("Promo tariff code activated",
+			slog.InfoContext(ctx, "Promo tariff code activated",
 				"customerID", customer.ID,
 				"code", code)
 
 			// Получаем обновлённые данные customer с promo offer
-			updatedCustomer, err := h.customerRepository.FindByTelegramId(ctx, userID)
+			updatedCustomer, err := h.customerRepository.FindByTelegramId(ctx, customer.TelegramID)
 			if err != nil || updatedCustomer == nil {
-				slog.Error("Error getting updated customer after promo tariff activation", "error", err)
-				return
+				slog.ErrorContext(ctx, "Error getting updated customer after promo tariff activation", "error", err)
+				return ""
 			}
 
 			// Показываем сообщение с информацией о тарифе
 			h.sendPromoTariffActivatedMessage(ctx, b, chatID, lang, updatedCustomer, tariffResult.OfferExpires)
-			return
+			return ""
 		}
 		// If not found or invalid format, fall through to regular promo codes
 	}
 
 	// Apply regular promo code (backward compatibility)
-	ctxWithUsername := context.WithValue(ctx, "username", update.Message.From.Username)
-	result := h.promoService.ApplyPromoCode(ctxWithUsername, customer.ID, userID, code)
-
+	ctxWithUsername := context.WithValue(ctx, "username", username)
+	result := h.promoService.ApplyPromoCode(ctxWithUsername, customer.ID, customer.TelegramID, code)
 	if !result.Success {
-		// Восстанавливаем состояние для повторного ввода
-		h.cache.SetString(stateKey, "waiting_code", 300)
-		
-		keyboard := &models.InlineKeyboardMarkup{
-			InlineKeyboard: [][]models.InlineKeyboardButton{
-				{{Text: h.translation.GetText(lang, "back_to_menu"), CallbackData: CallbackStart}},
-			},
-		}
-		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID:      chatID,
-			Text:        h.translation.GetText(lang, result.ErrorKey) + "\n\n" + h.translation.GetText(lang, "promo_try_again"),
-			ParseMode:   models.ParseModeHTML,
-			ReplyMarkup: keyboard,
-		})
-		return
+		return result.ErrorKey
 	}
 
 	// Success message
 	expireStr := ""
 	if result.NewExpire != nil {
-		expireStr = result.NewExpire.Format("02.01.2006")
+		expireStr = locale.FormatDate(lang, *result.NewExpire)
 	}
 
 	text := h.translation.GetTextTemplate(lang, "promo_success", map[string]interface{}{
@@ -190,72 +190,42 @@ func (h Handler) PromoCodeInputHandler(ctx context.Context, b *bot.Bot, update *
 		},
 	}
 
-	_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+	_, _ = h.sendMenu(ctx, b, &bot.SendMessageParams{
 		ChatID:      chatID,
 		Text:        text,
 		ParseMode:   models.ParseModeHTML,
 		ReplyMarkup: keyboard,
 	})
+	return ""
 }
 
 // sendPromoTariffActivatedMessage отправляет сообщение об успешной активации промокода на тариф
 // Показывает характеристики тарифа и кнопку активации
-func (h Handler) sendPromoTariffActivatedMessage(ctx context.Context, b *bot.Bot, chatID int64, langCode string, customer *database.Customer, expiresAt *time.Time) {
+func (h PromoHandlers) sendPromoTariffActivatedMessage(ctx context.Context, b *bot.Bot, chatID int64, langCode string, customer *database.Customer, expiresAt *time.Time) {
 	if customer == nil || customer.PromoOfferPrice == nil || customer.PromoOfferMonths == nil || customer.PromoOfferDevices == nil {
-		slog.Error("Invalid promo offer data")
+		slog.ErrorContext(ctx, "Invalid promo offer data")
 		return
 	}
 
-	price := *customer.PromoOfferPrice
-	months := *customer.PromoOfferMonths
-	devices := *customer.PromoOfferDevices
-
-	// Форматируем срок действия (через X часов/дней — не зависит от timezone)
-	expiresStr := ""
+	// Срок действия показываем как "через X" — не зависит от timezone
+	expiresIn := ""
 	if expiresAt != nil {
-		hours := int(time.Until(*expiresAt).Hours())
-		if hours > 48 {
-			expiresStr = fmt.Sprintf("%d дн.", hours/24)
-		} else if hours > 0 {
-			expiresStr = fmt.Sprintf("%d ч.", hours)
-		} else {
-			expiresStr = "< 1 ч."
-		}
+		expiresIn = locale.FormatDuration(langCode, time.Until(*expiresAt))
 	}
 
-	// Форматируем период
-	monthsWord := "месяц"
-	if months >= 2 && months <= 4 {
-		monthsWord = "месяца"
-	} else if months >= 5 {
-		monthsWord = "месяцев"
-	}
-
-	// Форматируем устройства
-	devicesWord := "устройство"
-	if devices >= 2 && devices <= 4 {
-		devicesWord = "устройства"
-	} else if devices >= 5 {
-		devicesWord = "устройств"
-	}
-
-	// Формируем текст сообщения
-	text := fmt.Sprintf(
-		"✅ <b>Промокод активирован!</b>\n\n"+
-			"🎁 <b>Вам доступен специальный тариф:</b>\n\n"+
-			"💰 Цена: <b>%d₽</b>\n"+
-			"📅 Период: <b>%d %s</b>\n"+
-			"📱 Устройств: <b>%d %s</b>\n\n"+
-			"⏰ Предложение истекает через: <b>%s</b>",
-		price, months, monthsWord, devices, devicesWord, expiresStr,
-	)
+	text := h.translation.GetTextTemplate(langCode, "promo_tariff_offer", map[string]interface{}{
+		"price":      locale.FormatMoney(langCode, float64(*customer.PromoOfferPrice)),
+		"months":     *customer.PromoOfferMonths,
+		"devices":    *customer.PromoOfferDevices,
+		"expires_in": expiresIn,
+	})
 
 	keyboard := [][]models.InlineKeyboardButton{
-		{{Text: "🎁 Активировать тариф", CallbackData: CallbackPromoTariff}},
-		{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart}},
+		{{Text: h.translation.GetText(langCode, "promo_tariff_activate_button"), CallbackData: CallbackPromoTariff}},
+		{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack}},
 	}
 
-	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+	_, err := h.sendMenu(ctx, b, &bot.SendMessageParams{
 		ChatID:    chatID,
 		ParseMode: models.ParseModeHTML,
 		ReplyMarkup: models.InlineKeyboardMarkup{
@@ -264,13 +234,13 @@ func (h Handler) sendPromoTariffActivatedMessage(ctx context.Context, b *bot.Bot
 		Text: text,
 	})
 	if err != nil {
-		slog.Error("Error sending promo tariff activated message", "error", err)
+		slog.ErrorContext(ctx, "Error sending promo tariff activated message", "error", err)
 	}
 }
 
 // Admin handlers
 
-func (h Handler) AdminPromoCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) AdminPromoCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		return
 	}
@@ -307,7 +277,7 @@ func (h Handler) AdminPromoCallback(ctx context.Context, b *bot.Bot, update *mod
 		ReplyMarkup: keyboard,
 	})
 	if err != nil {
-		slog.Error("Error editing promo admin menu", "error", err)
+		slog.ErrorContext(ctx, "Error editing promo admin menu", "error", err)
 	}
 
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
@@ -315,7 +285,7 @@ func (h Handler) AdminPromoCallback(ctx context.Context, b *bot.Bot, update *mod
 	})
 }
 
-func (h Handler) AdminPromoCreateCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) AdminPromoCreateCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		return
 	}
@@ -349,7 +319,7 @@ func (h Handler) AdminPromoCreateCallback(ctx context.Context, b *bot.Bot, updat
 		ReplyMarkup: keyboard,
 	})
 	if err != nil {
-		slog.Error("Error editing promo create message", "error", err)
+		slog.ErrorContext(ctx, "Error editing promo create message", "error", err)
 	}
 
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
@@ -357,7 +327,7 @@ func (h Handler) AdminPromoCreateCallback(ctx context.Context, b *bot.Bot, updat
 	})
 }
 
-func (h Handler) AdminPromoCreateInputHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) AdminPromoCreateInputHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.Message == nil || update.Message.From.ID != config.GetAdminTelegramId() {
 		return
 	}
@@ -446,9 +416,9 @@ func (h Handler) AdminPromoCreateInputHandler(ctx context.Context, b *bot.Bot, u
 
 	_, err = h.promoService.CreatePromoCode(ctx, code, days, limit, userID, validUntil)
 	if err != nil {
-		errMsg := fmt.Sprintf("❌ Ошибка создания: %v", err)
+		errMsg := utils.FormatHTML("❌ Ошибка создания: %v", err)
 		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") {
-			errMsg = fmt.Sprintf("❌ Промокод <code>%s</code> уже существует", code)
+			errMsg = utils.FormatHTML("❌ Промокод <code>%s</code> уже существует", code)
 		}
 		h.cache.SetString(stateKey, "waiting_code", 600)
 		keyboard := &models.InlineKeyboardMarkup{
@@ -491,16 +461,25 @@ func (h Handler) AdminPromoCreateInputHandler(ctx context.Context, b *bot.Bot, u
 	})
 }
 
-func (h Handler) AdminPromoListCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+// promoListPageSize количество промокодов на странице списка
+const promoListPageSize = 20
+
+// AdminPromoListCallback показывает список промокодов постранично
+func (h PromoHandlers) AdminPromoListCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		return
 	}
 
-	promos, err := h.promoService.GetAllPromoCodes(ctx, 20, 0)
+	page := listPage(update.CallbackQuery.Data, "admin_promo_list")
+	promos, err := h.promoService.GetAllPromoCodes(ctx, promoListPageSize+1, page*promoListPageSize)
 	if err != nil {
-		slog.Error("Error getting promo list", "error", err)
+		slog.ErrorContext(ctx, "Error getting promo list", "error", err)
 		return
 	}
+	hasNext := len(promos) > promoListPageSize
+	if hasNext {
+		promos = promos[:promoListPageSize]
+	}
 
 	text := "📋 <b>Список промокодов</b>\n\nНажмите на промокод для управления:"
 
@@ -521,6 +500,9 @@ func (h Handler) AdminPromoListCallback(ctx context.Context, b *bot.Bot, update
 		}
 	}
 
+	if nav := pageNavigationRow("admin_promo_list", page, hasNext); nav != nil {
+		buttons = append(buttons, nav)
+	}
 	buttons = append(buttons, []models.InlineKeyboardButton{{Text: "🔙 Назад", CallbackData: "admin_promo"}})
 
 	keyboard := &models.InlineKeyboardMarkup{
@@ -535,7 +517,7 @@ func (h Handler) AdminPromoListCallback(ctx context.Context, b *bot.Bot, update
 		ReplyMarkup: keyboard,
 	})
 	if err != nil {
-		slog.Error("Error editing promo list", "error", err)
+		slog.ErrorContext(ctx, "Error editing promo list", "error", err)
 	}
 
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
@@ -543,7 +525,7 @@ func (h Handler) AdminPromoListCallback(ctx context.Context, b *bot.Bot, update
 	})
 }
 
-func (h Handler) AdminPromoViewCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) AdminPromoViewCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		return
 	}
@@ -573,7 +555,7 @@ func (h Handler) AdminPromoViewCallback(ctx context.Context, b *bot.Bot, update
 		validStr = promo.ValidUntil.Format("02.01.2006")
 	}
 
-	text := fmt.Sprintf(
+	text := utils.FormatHTML(
 		"🎟 <b>Промокод: %s</b>\n\n"+
 			"Статус: %s\n"+
 			"Бонус: +%d дней\n"+
@@ -582,6 +564,11 @@ func (h Handler) AdminPromoViewCallback(ctx context.Context, b *bot.Bot, update
 			"Создан: %s",
 		promo.Code, status, promo.BonusDays, promo.CurrentActivations, promo.MaxActivations, validStr, promo.CreatedAt.Format("02.01.2006 15:04"),
 	)
+	channels, err := h.promoService.GetActivationChannels(ctx, promo.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error counting promo activations by channel", "promoID", promo.ID, "error", err)
+	}
+	text += promoLinkSection(promo.Code, channels)
 
 	var buttons [][]models.InlineKeyboardButton
 	if promo.IsActive {
@@ -605,7 +592,7 @@ func (h Handler) AdminPromoViewCallback(ctx context.Context, b *bot.Bot, update
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
 }
 
-func (h Handler) AdminPromoDeleteCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) AdminPromoDeleteCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		return
 	}
@@ -635,7 +622,7 @@ func (h Handler) AdminPromoDeleteCallback(ctx context.Context, b *bot.Bot, updat
 	h.AdminPromoListCallback(ctx, b, update)
 }
 
-func (h Handler) AdminPromoToggleCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) AdminPromoToggleCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		return
 	}
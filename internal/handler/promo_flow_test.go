@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/promo"
+)
+
+type mockCustomerFinder struct {
+	customer *database.Customer
+}
+
+func (m *mockCustomerFinder) FindByTelegramId(ctx context.Context, telegramId int64) (*database.Customer, error) {
+	return m.customer, nil
+}
+
+// mockPromoService реализует PromoServiceInterface: применение промокода возвращает заданный результат
+type mockPromoService struct {
+	PromoServiceInterface
+	result  *promo.ApplyResult
+	applied []string
+}
+
+func (m *mockPromoService) ApplyPromoCode(ctx context.Context, customerID int64, telegramID int64, code string) *promo.ApplyResult {
+	m.applied = append(m.applied, code)
+	return m.result
+}
+
+func promoInputUpdate(text string) *models.Update {
+	return &models.Update{Message: &models.Message{
+		ID:   10,
+		Text: text,
+		From: &models.User{ID: 42, LanguageCode: "ru"},
+		Chat: models.Chat{ID: 42},
+	}}
+}
+
+func TestPromoCodeInputHandler(t *testing.T) {
+	expire := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	customer := &database.Customer{ID: 7, TelegramID: 42}
+
+	tests := []struct {
+		name        string
+		state       string
+		customer    *database.Customer
+		result      *promo.ApplyResult
+		wantApplied bool
+		wantText    string
+		wantState   bool
+	}{
+		{"code applied", "waiting_code", customer, &promo.ApplyResult{Success: true, BonusDays: 7, NewExpire: &expire}, true, "promo_success", false},
+		{"invalid code asks again", "waiting_code", customer, &promo.ApplyResult{ErrorKey: "promo_not_found"}, true, "promo_not_found", true},
+		{"unknown customer", "waiting_code", nil, nil, false, "error_occurred", false},
+		{"text without promo state is ignored", "", customer, nil, false, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, tg := newTestBot(t)
+			cache := newFakeCache()
+			if tt.state != "" {
+				cache.SetString("promo_state_42", tt.state, 300)
+			}
+			promos := &mockPromoService{result: tt.result}
+			h := NewPromoHandlers(fakeTranslator{}, cache, &mockCustomerFinder{customer: tt.customer}, promos, nil, nil)
+
+			h.PromoCodeInputHandler(context.Background(), b, promoInputUpdate(" SUMMER "))
+
+			if tt.wantApplied != (len(promos.applied) == 1) {
+				t.Fatalf("Expected promo applied=%v, got calls %v", tt.wantApplied, promos.applied)
+			}
+			if tt.wantApplied && promos.applied[0] != "SUMMER" {
+				t.Errorf("Expected trimmed code, got %q", promos.applied[0])
+			}
+
+			sent := tg.called("sendMessage")
+			if tt.wantText == "" {
+				if len(sent) != 0 {
+					t.Fatalf("Expected no messages, got %d", len(sent))
+				}
+				return
+			}
+			if len(sent) != 1 || !strings.HasPrefix(sent[0].params["text"], tt.wantText) {
+				t.Fatalf("Expected message %q, got %+v", tt.wantText, sent)
+			}
+			if _, found := cache.GetString("promo_state_42"); found != tt.wantState {
+				t.Errorf("Expected promo state kept=%v", tt.wantState)
+			}
+		})
+	}
+}
+
+func TestRedeemPromoLink(t *testing.T) {
+	customer := &database.Customer{ID: 7, TelegramID: 42}
+	from := &models.User{ID: 42, LanguageCode: "ru"}
+
+	tests := []struct {
+		name     string
+		result   *promo.ApplyResult
+		wantText string
+	}{
+		{"code applied", &promo.ApplyResult{Success: true, BonusDays: 7}, "promo_success"},
+		{"expired code", &promo.ApplyResult{ErrorKey: "promo_expired"}, "promo_link_failed"},
+		{"limit reached", &promo.ApplyResult{ErrorKey: "promo_limit_reached"}, "promo_link_failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, tg := newTestBot(t)
+			promos := &mockPromoService{result: tt.result}
+			h := NewPromoHandlers(fakeTranslator{}, newFakeCache(), &mockCustomerFinder{customer: customer}, promos, nil, nil)
+
+			h.RedeemPromoLink(context.Background(), b, customer, from, "SUMMER", "vk")
+
+			if len(promos.applied) != 1 || promos.applied[0] != "SUMMER" {
+				t.Fatalf("Expected SUMMER to be applied, got %v", promos.applied)
+			}
+			sent := tg.called("sendMessage")
+			if len(sent) != 1 || sent[0].params["text"] != tt.wantText {
+				t.Fatalf("Expected message %q, got %+v", tt.wantText, sent)
+			}
+			// При ошибке клиент может сразу ввести другой промокод
+			if tt.result.Success == strings.Contains(sent[0].params["reply_markup"], `"callback_data":"`+CallbackPromo+`"`) {
+				t.Errorf("Unexpected keyboard %s", sent[0].params["reply_markup"])
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/promo"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// promoStartCode возвращает промокод и канал из команды "/start promo_<код>__<канал>".
+// Канал необязателен: без него активация учитывается как переход по ссылке
+func promoStartCode(text string) (code, channel string, ok bool) {
+	args := strings.Fields(text)
+	if len(args) < 2 {
+		return "", "", false
+	}
+	payload, ok := strings.CutPrefix(args[1], promo.StartPrefix)
+	if !ok {
+		return "", "", false
+	}
+	code, channel, _ = strings.Cut(payload, promo.ChannelSeparator)
+	if code == "" {
+		return "", "", false
+	}
+	channel = sanitizeSource(channel)
+	if channel == "" {
+		channel = promo.ChannelLink
+	}
+	return code, channel, true
+}
+
+// RedeemPromoLink активирует промокод из deep link. Клиент уже создан, поэтому промокод применяется
+// и при первом входе в бота. Если промокод не подошёл, клиент видит причину и может ввести другой
+func (h PromoHandlers) RedeemPromoLink(ctx context.Context, b *bot.Bot, customer *database.Customer, from *models.User, code, channel string) {
+	lang := from.LanguageCode
+	errorKey := h.applyPromoCode(promo.WithChannel(ctx, channel), b, customer.TelegramID, lang, customer, from.Username, code)
+	if errorKey == "" {
+		return
+	}
+
+	keyboard := &models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: h.translation.GetText(lang, "promo_button"), CallbackData: CallbackPromo}},
+			{{Text: h.translation.GetText(lang, "back_to_menu"), CallbackData: CallbackStart}},
+		},
+	}
+	_, _ = h.sendMenu(ctx, b, &bot.SendMessageParams{
+		ChatID: customer.TelegramID,
+		Text: h.translation.GetTextTemplate(lang, "promo_link_failed", map[string]interface{}{
+			"code":   strings.ToUpper(code),
+			"reason": utils.RawHTML(h.translation.GetText(lang, errorKey)),
+		}),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: keyboard,
+	})
+}
+
+// promoLinkSection блок карточки промокода в админке: ссылка для распространения и активации по каналам
+func promoLinkSection(code string, channels []database.PromoChannelCount) string {
+	link := fmt.Sprintf("%s?start=%s%s", config.BotURL(), promo.StartPrefix, code)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("\n\n🔗 Ссылка: <code>%s</code>\n", utils.EscapeHTML(link)))
+	sb.WriteString(fmt.Sprintf("Чтобы видеть, откуда пришли активации, добавьте канал: <code>%s%s</code>", utils.EscapeHTML(link), promo.ChannelSeparator+"vk"))
+	if len(channels) == 0 {
+		return sb.String()
+	}
+	sb.WriteString("\n\n📊 <b>Активации по каналам:</b>")
+	for _, c := range channels {
+		name := c.Channel
+		if name == "" {
+			name = "введён вручную"
+		}
+		sb.WriteString(fmt.Sprintf("\n• %s — %d", utils.EscapeHTML(name), c.Count))
+	}
+	return sb.String()
+}
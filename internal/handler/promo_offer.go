@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/broadcast"
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// promoOfferSegmentTargets сегменты, которым админ может выставить предложение. Совпадают с аудиториями рассылок
+var promoOfferSegmentTargets = []string{"all", "with_subscription", "without_subscription", "expiring", "start_only"}
+
+// segmentPromoOffer параметры предложения сегменту, введённые админом
+type segmentPromoOffer struct {
+	price   int
+	devices int
+	months  int
+	hours   int
+}
+
+// parseSegmentPromoOffer разбирает ввод «ЦЕНА УСТРОЙСТВА МЕСЯЦЫ ЧАСЫ» с теми же ограничениями, что у промокодов на тариф
+func parseSegmentPromoOffer(text string) (segmentPromoOffer, error) {
+	parts := strings.Fields(text)
+	if len(parts) != 4 {
+		return segmentPromoOffer{}, errors.New("❌ Неверный формат. Используйте: <code>ЦЕНА УСТРОЙСТВА МЕСЯЦЫ ЧАСЫ</code>")
+	}
+	values := make([]int, len(parts))
+	names := []string{"цена", "количество устройств", "количество месяцев", "срок действия в часах"}
+	for i, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil || v <= 0 {
+			return segmentPromoOffer{}, fmt.Errorf("❌ Неверное значение: %s (должно быть положительное число)", names[i])
+		}
+		values[i] = v
+	}
+	offer := segmentPromoOffer{price: values[0], devices: values[1], months: values[2], hours: values[3]}
+	if offer.months > 12 {
+		return segmentPromoOffer{}, errors.New("❌ Максимум 12 месяцев")
+	}
+	if offer.hours > 720 { // 30 дней
+		return segmentPromoOffer{}, errors.New("❌ Максимум 720 часов (30 дней)")
+	}
+	return offer, nil
+}
+
+// String возвращает предложение в формате ввода, в котором оно хранится в кеше до подтверждения
+func (o segmentPromoOffer) String() string {
+	return fmt.Sprintf("%d %d %d %d", o.price, o.devices, o.months, o.hours)
+}
+
+// segmentPromoOfferMessage текст уведомления о предложении, отправляемый рассылкой
+func segmentPromoOfferMessage(offer segmentPromoOffer, expiresAt time.Time) string {
+	return fmt.Sprintf("🎁 <b>Специальное предложение для вас!</b>\n\n"+
+		"💰 Цена: <b>%d₽</b>\n"+
+		"📅 Период: <b>%d мес.</b>\n"+
+		"📱 Устройств: <b>%d</b>\n\n"+
+		"⏰ Предложение действует до <b>%s</b>",
+		offer.price, offer.months, offer.devices, expiresAt.In(config.Timezone()).Format("02.01.2006 15:04"))
+}
+
+// AdminPromoOfferCallback начинает выставление promo tariff предложения сегменту: выбор аудитории
+func (h PromoHandlers) AdminPromoOfferCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	userID := update.CallbackQuery.From.ID
+	h.cache.Delete(fmt.Sprintf("admin_promo_offer_state_%d", userID))
+	h.cache.Delete(fmt.Sprintf("admin_promo_offer_%d", userID))
+
+	var rows [][]models.InlineKeyboardButton
+	for _, target := range promoOfferSegmentTargets {
+		rows = append(rows, []models.InlineKeyboardButton{
+			{Text: getTargetName(target), CallbackData: "admin_promo_offer_seg_" + target},
+		})
+	}
+	rows = append(rows, []models.InlineKeyboardButton{{Text: "🔙 Назад", CallbackData: "admin_promo_tariff"}})
+
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    update.CallbackQuery.Message.Message.Chat.ID,
+		MessageID: update.CallbackQuery.Message.Message.ID,
+		Text: "📤 <b>Предложение сегменту</b>\n\n" +
+			"Специальный тариф выставляется клиентам сегмента без промокода и заменяет их текущие предложения.\n\n" +
+			"Выберите сегмент:",
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: rows},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error editing promo offer segment menu", "error", err)
+	}
+}
+
+// AdminPromoOfferSegmentCallback запоминает сегмент и ждёт параметры предложения
+func (h PromoHandlers) AdminPromoOfferSegmentCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	userID := update.CallbackQuery.From.ID
+	target := strings.TrimPrefix(update.CallbackQuery.Data, "admin_promo_offer_seg_")
+
+	// Ввод текста админа разбирает AdminTextInputHandler, другие состояния ввода ему помешали бы
+	h.cache.Delete(fmt.Sprintf("admin_promo_state_%d", userID))
+	h.cache.Delete(fmt.Sprintf("admin_promo_tariff_state_%d", userID))
+	h.cache.SetString(fmt.Sprintf("admin_promo_offer_target_%d", userID), target, 600)
+	h.cache.SetString(fmt.Sprintf("admin_promo_offer_state_%d", userID), "waiting_offer", 600)
+
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    update.CallbackQuery.Message.Message.Chat.ID,
+		MessageID: update.CallbackQuery.Message.Message.ID,
+		Text: "📤 <b>Предложение сегменту</b>\n\n" +
+			"Сегмент: " + getTargetName(target) + "\n\n" +
+			"Отправьте параметры в формате:\n" +
+			"<code>ЦЕНА УСТРОЙСТВА МЕСЯЦЫ ЧАСЫ</code>\n\n" +
+			"Пример: <code>199 3 1 48</code>\n" +
+			"(цена 199₽, 3 устройства, 1 месяц, предложение действует 48 часов)",
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: "❌ Отмена", CallbackData: "admin_promo_offer"}},
+		}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error editing promo offer input message", "error", err)
+	}
+}
+
+// AdminPromoOfferInputHandler проверяет параметры предложения и показывает подтверждение с размером сегмента
+func (h PromoHandlers) AdminPromoOfferInputHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	userID := update.Message.From.ID
+	chatID := update.Message.Chat.ID
+	cancelKeyboard := &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+		{{Text: "❌ Отмена", CallbackData: "admin_promo_offer"}},
+	}}
+
+	target, found := h.cache.GetString(fmt.Sprintf("admin_promo_offer_target_%d", userID))
+	if !found {
+		h.cache.Delete(fmt.Sprintf("admin_promo_offer_state_%d", userID))
+		h.sendPromoOfferText(ctx, b, chatID, "❌ Сегмент не выбран, начните заново", cancelKeyboard)
+		return
+	}
+
+	offer, err := parseSegmentPromoOffer(update.Message.Text)
+	if err != nil {
+		h.sendPromoOfferText(ctx, b, chatID, err.Error()+"\n\nПопробуйте ещё раз или нажмите Отмена.", cancelKeyboard)
+		return
+	}
+
+	audience, err := h.offerSegments.AudienceSize(ctx, target)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error counting promo offer segment", "error", err)
+		h.sendPromoOfferText(ctx, b, chatID, "❌ Не удалось получить сегмент", cancelKeyboard)
+		return
+	}
+
+	h.cache.Delete(fmt.Sprintf("admin_promo_offer_state_%d", userID))
+	h.cache.SetString(fmt.Sprintf("admin_promo_offer_%d", userID), offer.String(), 600)
+
+	h.sendPromoOfferText(ctx, b, chatID, fmt.Sprintf(
+		"📋 <b>Подтверждение предложения</b>\n\n"+
+			"Сегмент: %s\n"+
+			"👥 Клиентов: <b>%d</b>\n\n"+
+			"Цена: %d₽\n"+
+			"Устройства: %d\n"+
+			"Период: %d мес.\n"+
+			"Предложение действует: %d ч.\n\n"+
+			"Текущие предложения клиентов сегмента будут заменены. "+
+			"Уведомление отправляется рассылкой: отказавшиеся от рассылок получат предложение без сообщения.",
+		getTargetName(target), audience, offer.price, offer.devices, offer.months, offer.hours,
+	), &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+		{{Text: "📨 Выставить и уведомить", CallbackData: "admin_promo_offer_confirm?n=1"}},
+		{{Text: "✅ Выставить без уведомления", CallbackData: "admin_promo_offer_confirm?n=0"}},
+		{{Text: "❌ Отмена", CallbackData: "admin_promo_offer"}},
+	}})
+}
+
+// AdminPromoOfferConfirmCallback выставляет предложение сегменту и, если выбрано, запускает рассылку с кнопкой оплаты
+func (h PromoHandlers) AdminPromoOfferConfirmCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	userID := update.CallbackQuery.From.ID
+	msg := update.CallbackQuery.Message.Message
+	notify := parseCallbackData(update.CallbackQuery.Data)["n"] == "1"
+
+	target, targetFound := h.cache.GetString(fmt.Sprintf("admin_promo_offer_target_%d", userID))
+	stored, offerFound := h.cache.GetString(fmt.Sprintf("admin_promo_offer_%d", userID))
+	offer, err := parseSegmentPromoOffer(stored)
+	if !targetFound || !offerFound || err != nil {
+		h.editPromoOfferResult(ctx, b, msg, "❌ Данные предложения устарели, начните заново")
+		return
+	}
+	// Повторное нажатие не должно выставить предложение и запустить рассылку второй раз
+	h.cache.Delete(fmt.Sprintf("admin_promo_offer_%d", userID))
+	h.cache.Delete(fmt.Sprintf("admin_promo_offer_target_%d", userID))
+
+	expiresAt := clock.Now().Add(time.Duration(offer.hours) * time.Hour)
+	assigned, err := h.offerSegments.AssignPromoOffer(ctx, target, broadcast.PromoOffer{
+		Price:     offer.price,
+		Devices:   offer.devices,
+		Months:    offer.months,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error assigning promo offer to segment", "error", err, "target", target)
+		h.editPromoOfferResult(ctx, b, msg, "❌ Не удалось выставить предложение")
+		return
+	}
+	slog.InfoContext(ctx, "Promo offer assigned to segment", "target", target, "customers", assigned,
+		"price", offer.price, "devices", offer.devices, "months", offer.months, "hours", offer.hours)
+
+	text := fmt.Sprintf("✅ <b>Предложение выставлено</b>\n\nСегмент: %s\nКлиентов: %d", getTargetName(target), assigned)
+	if notify && assigned > 0 {
+		text += "\n\n" + h.notifyPromoOfferSegment(ctx, target, segmentPromoOfferMessage(offer, expiresAt))
+	}
+	h.editPromoOfferResult(ctx, b, msg, text)
+}
+
+// notifyPromoOfferSegment запускает рассылку о предложении и возвращает строку итога для админа
+func (h PromoHandlers) notifyPromoOfferSegment(ctx context.Context, target, messageText string) string {
+	broadcastID, err := h.offerSegments.CreateBroadcast(ctx, target, messageText)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error creating promo offer broadcast", "error", err)
+		return "❌ Не удалось создать рассылку: " + utils.EscapeHTML(err.Error())
+	}
+	h.offerSegments.StartBroadcastWithOptions(ctx, broadcastID, target, messageText, &broadcast.BroadcastOptions{
+		Buttons: []string{broadcast.ButtonPromoTariff},
+	})
+	return "📨 Рассылка запущена, прогресс — в разделе «История рассылок»."
+}
+
+func (h PromoHandlers) sendPromoOfferText(ctx context.Context, b *bot.Bot, chatID int64, text string, keyboard *models.InlineKeyboardMarkup) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: keyboard,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending promo offer message", "error", err)
+	}
+}
+
+func (h PromoHandlers) editPromoOfferResult(ctx context.Context, b *bot.Bot, msg *models.Message, text string) {
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: "🔙 Назад", CallbackData: "admin_promo_tariff"}},
+		}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error editing promo offer result", "error", err)
+	}
+}
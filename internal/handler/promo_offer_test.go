@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/broadcast"
+)
+
+type mockPromoOfferSegments struct {
+	assigned   []broadcast.PromoOffer
+	broadcasts []string
+	buttons    []string
+}
+
+func (m *mockPromoOfferSegments) AudienceSize(_ context.Context, _ string) (int, error) {
+	return 3, nil
+}
+
+func (m *mockPromoOfferSegments) AssignPromoOffer(_ context.Context, _ string, offer broadcast.PromoOffer) (int64, error) {
+	m.assigned = append(m.assigned, offer)
+	return 3, nil
+}
+
+func (m *mockPromoOfferSegments) CreateBroadcast(_ context.Context, _, messageText string) (int64, error) {
+	m.broadcasts = append(m.broadcasts, messageText)
+	return 1, nil
+}
+
+func (m *mockPromoOfferSegments) StartBroadcastWithOptions(_ context.Context, _ int64, _, _ string, opts *broadcast.BroadcastOptions) {
+	m.buttons = opts.Buttons
+}
+
+func TestParseSegmentPromoOffer(t *testing.T) {
+	offer, err := parseSegmentPromoOffer(" 199 3 1  48 ")
+	if err != nil || offer != (segmentPromoOffer{price: 199, devices: 3, months: 1, hours: 48}) {
+		t.Fatalf("parseSegmentPromoOffer() = %+v, %v", offer, err)
+	}
+	for _, input := range []string{"199 3 1", "199 3 1 48 5", "199 0 1 48", "199 3 13 48", "199 3 1 721", "abc 3 1 48"} {
+		if _, err := parseSegmentPromoOffer(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestAdminPromoOfferFlow(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		notify bool
+	}{
+		{"assign only", "admin_promo_offer_confirm?n=0", false},
+		{"assign and notify", "admin_promo_offer_confirm?n=1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, tg := newTestBot(t)
+			cache := newFakeCache()
+			segments := &mockPromoOfferSegments{}
+			h := NewPromoHandlers(fakeTranslator{}, cache, &mockCustomerFinder{}, nil, nil, segments)
+
+			h.AdminPromoOfferSegmentCallback(context.Background(), b, paymentCallbackUpdate("admin_promo_offer_seg_with_subscription"))
+			if state, _ := cache.GetString("admin_promo_offer_state_42"); state != "waiting_offer" {
+				t.Fatalf("Expected offer input state, got %q", state)
+			}
+
+			h.AdminPromoOfferInputHandler(context.Background(), b, &models.Update{Message: &models.Message{
+				Text: "199 3 1 48",
+				From: &models.User{ID: 42},
+				Chat: models.Chat{ID: 42},
+			}})
+			sent := tg.called("sendMessage")
+			if len(sent) != 1 || !strings.Contains(sent[0].params["text"], "Клиентов: <b>3</b>") {
+				t.Fatalf("Expected confirmation with segment size, got %v", sent)
+			}
+
+			h.AdminPromoOfferConfirmCallback(context.Background(), b, paymentCallbackUpdate(tt.data))
+			if len(segments.assigned) != 1 || segments.assigned[0].Price != 199 || segments.assigned[0].Devices != 3 || segments.assigned[0].Months != 1 {
+				t.Fatalf("Expected offer 199/3/1 to be assigned, got %+v", segments.assigned)
+			}
+			if notified := len(segments.broadcasts) == 1; notified != tt.notify {
+				t.Fatalf("Expected notify=%v, got broadcasts %v", tt.notify, segments.broadcasts)
+			}
+			if tt.notify && (len(segments.buttons) != 1 || segments.buttons[0] != broadcast.ButtonPromoTariff) {
+				t.Errorf("Expected promo tariff button in broadcast, got %v", segments.buttons)
+			}
+
+			// Повторное подтверждение не выставляет предложение второй раз
+			h.AdminPromoOfferConfirmCallback(context.Background(), b, paymentCallbackUpdate(tt.data))
+			if len(segments.assigned) != 1 {
+				t.Errorf("Expected single assignment, got %d", len(segments.assigned))
+			}
+		})
+	}
+}
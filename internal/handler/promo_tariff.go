@@ -11,13 +11,15 @@ import (
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 
+	"remnawave-tg-shop-bot/internal/clock"
 	"remnawave-tg-shop-bot/internal/config"
 	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
 )
 
 // AdminPromoTariffCallback показывает меню управления промокодами на тариф
 // Requirements: 3.1
-func (h Handler) AdminPromoTariffCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) AdminPromoTariffCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -29,11 +31,13 @@ func (h Handler) AdminPromoTariffCallback(ctx context.Context, b *bot.Bot, updat
 	// Clear any pending input states when returning to menu
 	h.cache.Delete(fmt.Sprintf("admin_promo_state_%d", update.CallbackQuery.From.ID))
 	h.cache.Delete(fmt.Sprintf("admin_promo_tariff_state_%d", update.CallbackQuery.From.ID))
+	h.cache.Delete(fmt.Sprintf("admin_promo_offer_state_%d", update.CallbackQuery.From.ID))
 
 	keyboard := &models.InlineKeyboardMarkup{
 		InlineKeyboard: [][]models.InlineKeyboardButton{
 			{{Text: "➕ Создать промокод на тариф", CallbackData: "admin_promo_tariff_create"}},
 			{{Text: "📋 Список промокодов на тариф", CallbackData: "admin_promo_tariff_list"}},
+			{{Text: "📤 Предложение сегменту", CallbackData: "admin_promo_offer"}},
 			{{Text: "🔙 Назад", CallbackData: "admin_promo"}},
 		},
 	}
@@ -46,13 +50,13 @@ func (h Handler) AdminPromoTariffCallback(ctx context.Context, b *bot.Bot, updat
 		ReplyMarkup: keyboard,
 	})
 	if err != nil {
-		slog.Error("Error editing promo tariff admin menu", "error", err)
+		slog.ErrorContext(ctx, "Error editing promo tariff admin menu", "error", err)
 	}
 }
 
 // AdminPromoTariffCreateCallback начинает процесс создания промокода на тариф
 // Requirements: 2.1
-func (h Handler) AdminPromoTariffCreateCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) AdminPromoTariffCreateCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -90,13 +94,13 @@ func (h Handler) AdminPromoTariffCreateCallback(ctx context.Context, b *bot.Bot,
 		ReplyMarkup: keyboard,
 	})
 	if err != nil {
-		slog.Error("Error editing promo tariff create message", "error", err)
+		slog.ErrorContext(ctx, "Error editing promo tariff create message", "error", err)
 	}
 }
 
 // AdminPromoTariffCreateInputHandler обрабатывает ввод данных для создания промокода на тариф
 // Requirements: 2.2, 2.3, 2.4
-func (h Handler) AdminPromoTariffCreateInputHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) AdminPromoTariffCreateInputHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.Message == nil || update.Message.From.ID != config.GetAdminTelegramId() {
 		return
 	}
@@ -207,9 +211,9 @@ func (h Handler) AdminPromoTariffCreateInputHandler(ctx context.Context, b *bot.
 
 	promo, err := h.promoTariffService.CreatePromoTariffCode(ctx, code, price, devices, months, maxActivations, validHours, userID, validUntil)
 	if err != nil {
-		errMsg := fmt.Sprintf("❌ Ошибка создания: %v", err)
+		errMsg := utils.FormatHTML("❌ Ошибка создания: %v", err)
 		if strings.Contains(err.Error(), "duplicate") || strings.Contains(err.Error(), "unique") || strings.Contains(err.Error(), "exists") {
-			errMsg = fmt.Sprintf("❌ Промокод <code>%s</code> уже существует", code)
+			errMsg = utils.FormatHTML("❌ Промокод <code>%s</code> уже существует", code)
 		}
 		h.cache.SetString(stateKey, "waiting_code", 600)
 		keyboard := &models.InlineKeyboardMarkup{
@@ -239,7 +243,7 @@ func (h Handler) AdminPromoTariffCreateInputHandler(ctx context.Context, b *bot.
 
 	_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID: chatID,
-		Text: fmt.Sprintf(
+		Text: utils.FormatHTML(
 			"✅ <b>Промокод на тариф создан!</b>\n\n"+
 				"Код: <code>%s</code>\n"+
 				"Цена: %d₽\n"+
@@ -255,19 +259,26 @@ func (h Handler) AdminPromoTariffCreateInputHandler(ctx context.Context, b *bot.
 	})
 }
 
+// promoTariffListPageSize количество промокодов на тариф на странице списка
+const promoTariffListPageSize = 20
 
-// AdminPromoTariffListCallback показывает список промокодов на тариф
+// AdminPromoTariffListCallback показывает список промокодов на тариф постранично
 // Requirements: 3.1
-func (h Handler) AdminPromoTariffListCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) AdminPromoTariffListCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		return
 	}
 
-	promos, err := h.promoTariffService.GetAllPromoTariffCodes(ctx, 20, 0)
+	page := listPage(update.CallbackQuery.Data, "admin_promo_tariff_list")
+	promos, err := h.promoTariffService.GetAllPromoTariffCodes(ctx, promoTariffListPageSize+1, page*promoTariffListPageSize)
 	if err != nil {
-		slog.Error("Error getting promo tariff list", "error", err)
+		slog.ErrorContext(ctx, "Error getting promo tariff list", "error", err)
 		return
 	}
+	hasNext := len(promos) > promoTariffListPageSize
+	if hasNext {
+		promos = promos[:promoTariffListPageSize]
+	}
 
 	text := "📋 <b>Список промокодов на тариф</b>\n\nНажмите на промокод для управления:"
 
@@ -290,6 +301,9 @@ func (h Handler) AdminPromoTariffListCallback(ctx context.Context, b *bot.Bot, u
 		}
 	}
 
+	if nav := pageNavigationRow("admin_promo_tariff_list", page, hasNext); nav != nil {
+		buttons = append(buttons, nav)
+	}
 	buttons = append(buttons, []models.InlineKeyboardButton{{Text: "🔙 Назад", CallbackData: "admin_promo_tariff"}})
 
 	keyboard := &models.InlineKeyboardMarkup{
@@ -304,7 +318,7 @@ func (h Handler) AdminPromoTariffListCallback(ctx context.Context, b *bot.Bot, u
 		ReplyMarkup: keyboard,
 	})
 	if err != nil {
-		slog.Error("Error editing promo tariff list", "error", err)
+		slog.ErrorContext(ctx, "Error editing promo tariff list", "error", err)
 	}
 
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
@@ -314,7 +328,7 @@ func (h Handler) AdminPromoTariffListCallback(ctx context.Context, b *bot.Bot, u
 
 // AdminPromoTariffViewCallback показывает детали промокода на тариф
 // Requirements: 3.2, 3.3
-func (h Handler) AdminPromoTariffViewCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) AdminPromoTariffViewCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		return
 	}
@@ -344,7 +358,7 @@ func (h Handler) AdminPromoTariffViewCallback(ctx context.Context, b *bot.Bot, u
 		validStr = promo.ValidUntil.Format("02.01.2006")
 	}
 
-	text := fmt.Sprintf(
+	text := utils.FormatHTML(
 		"🎁 <b>Промокод на тариф: %s</b>\n\n"+
 			"Статус: %s\n"+
 			"Цена: %d₽\n"+
@@ -358,6 +372,11 @@ func (h Handler) AdminPromoTariffViewCallback(ctx context.Context, b *bot.Bot, u
 		promo.CurrentActivations, promo.MaxActivations, promo.ValidHours,
 		validStr, promo.CreatedAt.Format("02.01.2006 15:04"),
 	)
+	channels, err := h.promoTariffService.GetPromoTariffActivationChannels(ctx, promo.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error counting promo tariff activations by channel", "promoID", promo.ID, "error", err)
+	}
+	text += promoLinkSection(promo.Code, channels)
 
 	var buttons [][]models.InlineKeyboardButton
 	if promo.IsActive {
@@ -391,7 +410,7 @@ func (h Handler) AdminPromoTariffViewCallback(ctx context.Context, b *bot.Bot, u
 
 // AdminPromoTariffDeleteCallback удаляет промокод на тариф
 // Requirements: 3.3
-func (h Handler) AdminPromoTariffDeleteCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) AdminPromoTariffDeleteCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		return
 	}
@@ -423,7 +442,7 @@ func (h Handler) AdminPromoTariffDeleteCallback(ctx context.Context, b *bot.Bot,
 
 // AdminPromoTariffToggleCallback активирует/деактивирует промокод на тариф
 // Requirements: 3.2
-func (h Handler) AdminPromoTariffToggleCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) AdminPromoTariffToggleCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
 	if update.CallbackQuery.From.ID != config.GetAdminTelegramId() {
 		return
 	}
@@ -475,7 +494,7 @@ func (h Handler) AdminPromoTariffToggleCallback(ctx context.Context, b *bot.Bot,
 // PromoTariffCallbackHandler обрабатывает нажатие на кнопку promo tariff в меню тарифов
 // Показывает кнопки оплаты с ценой из promo offer (аналогично winback)
 // Requirements: 5.3
-func (h Handler) PromoTariffCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PromoHandlers) PromoTariffCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -487,17 +506,17 @@ func (h Handler) PromoTariffCallbackHandler(ctx context.Context, b *bot.Bot, upd
 	// Get customer
 	customer, err := h.customerRepository.FindByTelegramId(ctx, telegramID)
 	if err != nil {
-		slog.Error("Error finding customer for promo tariff", "error", err)
+		slog.ErrorContext(ctx, "Error finding customer for promo tariff", "error", err)
 		return
 	}
 	if customer == nil {
-		slog.Error("Customer not found for promo tariff")
+		slog.ErrorContext(ctx, "Customer not found for promo tariff")
 		return
 	}
 
 	// Check if customer has active promo offer
 	if !HasActivePromoOffer(customer) {
-		slog.Warn("No active promo offer for customer", "customerID", customer.ID)
+		slog.WarnContext(ctx, "No active promo offer for customer", "customerID", customer.ID)
 		h.sendPromoTariffError(ctx, b, callback, langCode, "promo_tariff_offer_expired")
 		return
 	}
@@ -507,18 +526,18 @@ func (h Handler) PromoTariffCallbackHandler(ctx context.Context, b *bot.Bot, upd
 	months := customer.PromoOfferMonths
 
 	if price == nil || months == nil {
-		slog.Error("Promo offer has nil parameters", "customerID", customer.ID)
+		slog.ErrorContext(ctx, "Promo offer has nil parameters", "customerID", customer.ID)
 		h.sendPromoTariffError(ctx, b, callback, langCode, "promo_tariff_error")
 		return
 	}
 
-	slog.Info("Showing promo tariff payment options",
+	slog.InfoContext(ctx, "Showing promo tariff payment options",
 		"customerID", customer.ID,
 		"price", *price,
 		"months", *months)
 
 	// Show payment options (like winback)
-	h.showPromoTariffPaymentOptions(ctx, b, callback, langCode, *price, *months)
+	h.showPromoTariffPaymentOptions(ctx, b, callback, langCode, *months)
 }
 
 // HasActivePromoOffer проверяет, есть ли у пользователя активное promo tariff предложение
@@ -530,15 +549,16 @@ func HasActivePromoOffer(customer *database.Customer) bool {
 	if customer.PromoOfferPrice == nil || customer.PromoOfferExpiresAt == nil {
 		return false
 	}
-	return customer.PromoOfferExpiresAt.After(time.Now())
+	return customer.PromoOfferExpiresAt.After(clock.Now())
 }
 
 // showPromoTariffPaymentOptions показывает кнопки оплаты для promo tariff предложения
 // Аналогично winback, но с пометкой promo_tariff
-func (h Handler) showPromoTariffPaymentOptions(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, price int, months int) {
-	// Build payment callback with promo_tariff flag (short keys for 64 byte limit)
+func (h PromoHandlers) showPromoTariffPaymentOptions(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, months int) {
+	// Build payment callback with promo_tariff flag (short keys for 64 byte limit).
+	// Цена в callback не передаётся: она берётся из предложения клиента при оплате
 	buildPaymentCallback := func(invoiceType database.InvoiceType) string {
-		return fmt.Sprintf("%s?m=%d&t=%s&a=%d&pt=1", CallbackPayment, months, invoiceType, price)
+		return fmt.Sprintf("%s?m=%d&t=%s&pt=1", CallbackPayment, months, invoiceType)
 	}
 
 	var keyboard [][]models.InlineKeyboardButton
@@ -563,7 +583,7 @@ func (h Handler) showPromoTariffPaymentOptions(ctx context.Context, b *bot.Bot,
 
 
 	keyboard = append(keyboard, []models.InlineKeyboardButton{
-		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBuy},
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
 	})
 
 	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
@@ -577,12 +597,12 @@ func (h Handler) showPromoTariffPaymentOptions(ctx context.Context, b *bot.Bot,
 	})
 
 	if err != nil {
-		slog.Error("Error showing promo tariff payment options", "error", err)
+		slog.ErrorContext(ctx, "Error showing promo tariff payment options", "error", err)
 	}
 }
 
 // sendPromoTariffError отправляет сообщение об ошибке
-func (h Handler) sendPromoTariffError(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, errorKey string) {
+func (h PromoHandlers) sendPromoTariffError(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, errorKey string) {
 	text := h.translation.GetText(langCode, errorKey)
 	if text == "" {
 		text = h.translation.GetText(langCode, "promo_tariff_error")
@@ -596,11 +616,11 @@ func (h Handler) sendPromoTariffError(ctx context.Context, b *bot.Bot, callback
 		ReplyMarkup: models.InlineKeyboardMarkup{
 			InlineKeyboard: [][]models.InlineKeyboardButton{
 				{{Text: h.translation.GetText(langCode, "buy_button"), CallbackData: CallbackBuy}},
-				{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart}},
+				{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack}},
 			},
 		},
 	})
 	if err != nil {
-		slog.Error("Error sending promo tariff error message", "error", err)
+		slog.ErrorContext(ctx, "Error sending promo tariff error message", "error", err)
 	}
 }
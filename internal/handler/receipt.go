@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/receipt"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// receiptsListLimit сколько последних покупок показывается в /receipts
+const receiptsListLimit = 10
+
+// ReceiptsCommandHandler показывает последние оплаченные покупки с кнопками получения квитанций
+func (h PaymentHandlers) ReceiptsCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if !config.IsReceiptsEnabled() || h.receiptRepository == nil {
+		return
+	}
+
+	langCode := update.Message.From.LanguageCode
+	customer, err := h.customerRepository.FindByTelegramId(ctx, update.Message.Chat.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for receipts", "error", err)
+		return
+	}
+
+	purchases, err := h.purchaseRepository.FindPaidByCustomer(ctx, customer.ID, receiptsListLimit)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading paid purchases for receipts", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		return
+	}
+
+	text := h.translation.GetText(langCode, "receipts_empty")
+	var keyboard [][]models.InlineKeyboardButton
+	if len(purchases) > 0 {
+		text = h.translation.GetText(langCode, "receipts_list")
+		for _, p := range purchases {
+			paidAt := p.CreatedAt
+			if p.PaidAt != nil {
+				paidAt = *p.PaidAt
+			}
+			keyboard = append(keyboard, []models.InlineKeyboardButton{{
+				Text: h.translation.GetTextTemplate(langCode, "receipts_list_button", map[string]interface{}{
+					"date":   locale.FormatDate(langCode, paidAt),
+					"amount": receipt.FormatAmount(langCode, p.Amount, p.Currency),
+				}),
+				CallbackData: fmt.Sprintf("%s?id=%d", CallbackReceipt, p.ID),
+			}})
+		}
+	}
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      update.Message.Chat.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending receipts list", "error", err)
+	}
+}
+
+// ReceiptCallbackHandler отправляет квитанцию по покупке. Квитанция формируется один раз: номер и file_id
+// отправленного документа сохраняются, повторный запрос пересылает тот же документ
+func (h PaymentHandlers) ReceiptCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	if !config.IsReceiptsEnabled() || h.receiptRepository == nil {
+		return
+	}
+
+	purchaseID, err := strconv.ParseInt(parseCallbackData(update.CallbackQuery.Data)["id"], 10, 64)
+	if err != nil {
+		slog.WarnContext(ctx, "Invalid purchase id in receipt callback", "data", update.CallbackQuery.Data)
+		return
+	}
+
+	chatID := update.CallbackQuery.From.ID
+	langCode := update.CallbackQuery.From.LanguageCode
+	customer, err := h.customerRepository.FindByTelegramId(ctx, chatID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for receipt", "error", err)
+		return
+	}
+
+	existing, err := h.receiptRepository.FindByPurchaseID(ctx, purchaseID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding receipt", "error", err, "purchaseId", purchaseID)
+		h.sendReceiptError(ctx, b, chatID, langCode, "receipt_error")
+		return
+	}
+	if existing != nil && existing.CustomerID != customer.ID {
+		slog.WarnContext(ctx, "Receipt requested by another customer", "purchaseId", purchaseID, "customerId", utils.MaskHalfInt64(customer.ID))
+		h.sendReceiptError(ctx, b, chatID, langCode, "receipt_not_available")
+		return
+	}
+	if existing != nil && existing.FileID != nil {
+		_, err := b.SendDocument(ctx, &bot.SendDocumentParams{
+			ChatID:    chatID,
+			Document:  &models.InputFileString{Data: *existing.FileID},
+			Caption:   h.receiptCaption(langCode, existing.Number),
+			ParseMode: models.ParseModeHTML,
+		})
+		if err == nil {
+			return
+		}
+		// file_id мог стать недействительным — формируем документ заново
+		slog.WarnContext(ctx, "Error resending receipt, rendering again", "error", err, "purchaseId", purchaseID)
+	}
+
+	purchase, err := h.purchaseRepository.FindById(ctx, purchaseID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding purchase for receipt", "error", err, "purchaseId", purchaseID)
+		h.sendReceiptError(ctx, b, chatID, langCode, "receipt_error")
+		return
+	}
+	if purchase == nil || purchase.CustomerID != customer.ID || purchase.Status != database.PurchaseStatusPaid || purchase.IsTest {
+		h.sendReceiptError(ctx, b, chatID, langCode, "receipt_not_available")
+		return
+	}
+
+	issued, err := h.receiptRepository.Issue(ctx, purchase.ID, customer.ID, config.ReceiptNumberPrefix())
+	if err != nil {
+		slog.ErrorContext(ctx, "Error issuing receipt", "error", err, "purchaseId", purchaseID)
+		h.sendReceiptError(ctx, b, chatID, langCode, "receipt_error")
+		return
+	}
+
+	content, err := receipt.Render(receipt.NewDocument(issued, purchase, customer.TelegramID, langCode))
+	if err != nil {
+		slog.ErrorContext(ctx, "Error rendering receipt", "error", err, "purchaseId", purchaseID)
+		h.sendReceiptError(ctx, b, chatID, langCode, "receipt_error")
+		return
+	}
+
+	msg, err := b.SendDocument(ctx, &bot.SendDocumentParams{
+		ChatID:    chatID,
+		Document:  &models.InputFileUpload{Filename: receipt.FileName(issued.Number), Data: bytes.NewReader(content)},
+		Caption:   h.receiptCaption(langCode, issued.Number),
+		ParseMode: models.ParseModeHTML,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending receipt", "error", err, "purchaseId", purchaseID)
+		return
+	}
+	if msg.Document != nil {
+		if err := h.receiptRepository.SetFileID(ctx, purchase.ID, msg.Document.FileID); err != nil {
+			slog.ErrorContext(ctx, "Error saving receipt file id", "error", err, "purchaseId", purchaseID)
+		}
+	}
+	slog.InfoContext(ctx, "Receipt issued", "purchaseId", purchaseID, "number", issued.Number)
+}
+
+func (h PaymentHandlers) receiptCaption(langCode, number string) string {
+	return h.translation.GetTextTemplate(langCode, "receipt_caption", map[string]interface{}{"number": number})
+}
+
+func (h PaymentHandlers) sendReceiptError(ctx context.Context, b *bot.Bot, chatID int64, langCode, key string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: chatID,
+		Text:   h.translation.GetText(langCode, key),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending receipt error", "error", err)
+	}
+}
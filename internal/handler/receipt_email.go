@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/yookasa"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// receiptEmailStateKey состояние ожидания email клиента для чеков
+func receiptEmailStateKey(userID int64) string {
+	return fmt.Sprintf("email_state_%d", userID)
+}
+
+// receiptEmailButtonRow возвращает строку с кнопкой email для чеков на экране подключения или nil.
+// Email нужен только для чеков ЮKassa
+func (h ProfileHandlers) receiptEmailButtonRow(langCode string) []models.InlineKeyboardButton {
+	if !config.IsYookasaEnabled() {
+		return nil
+	}
+	return []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "receipt_email_button"), CallbackData: CallbackReceiptEmail}}
+}
+
+// receiptEmailMenu возвращает текст и клавиатуру экрана email для чеков
+func (h ProfileHandlers) receiptEmailMenu(customer *database.Customer, langCode string) (string, models.InlineKeyboardMarkup) {
+	var current interface{} = utils.RawHTML(h.translation.GetText(langCode, "receipt_email_not_set"))
+	if customer.Email != nil {
+		current = *customer.Email
+	}
+	text := h.translation.GetTextTemplate(langCode, "receipt_email_menu", map[string]interface{}{"current": current})
+
+	var keyboard [][]models.InlineKeyboardButton
+	if customer.Email != nil {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: h.translation.GetText(langCode, "receipt_email_clear_button"), CallbackData: CallbackReceiptEmailClear},
+		})
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackConnect},
+	})
+	return text, models.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+}
+
+// ReceiptEmailCallbackHandler показывает email для чеков и ждёт ввода нового адреса
+func (h ProfileHandlers) ReceiptEmailCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	callback := update.CallbackQuery.Message.Message
+	customer, err := h.customerRepository.FindByTelegramId(ctx, callback.Chat.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for receipt email", "error", err)
+		return
+	}
+
+	h.cache.SetString(receiptEmailStateKey(update.CallbackQuery.From.ID), "waiting_email", 300)
+	h.editReceiptEmailMenu(ctx, b, callback, customer, update.CallbackQuery.From.LanguageCode)
+}
+
+// ReceiptEmailClearCallbackHandler удаляет email клиента: чеки снова уходят на YOOKASA_EMAIL
+func (h ProfileHandlers) ReceiptEmailClearCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+
+	customer, err := h.customerRepository.FindByTelegramId(ctx, callback.Chat.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for receipt email", "error", err)
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+		return
+	}
+	if err := h.customerRepository.UpdateFields(ctx, customer.ID, map[string]interface{}{"email": nil}); err != nil {
+		slog.ErrorContext(ctx, "Error clearing customer email", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+		return
+	}
+	customer.Email = nil
+
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            h.translation.GetText(langCode, "receipt_email_cleared"),
+	})
+	h.editReceiptEmailMenu(ctx, b, callback, customer, langCode)
+}
+
+// ReceiptEmailInputHandler проверяет и сохраняет email, введённый клиентом
+func (h ProfileHandlers) ReceiptEmailInputHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	stateKey := receiptEmailStateKey(update.Message.From.ID)
+	if state, found := h.cache.GetString(stateKey); !found || state != "waiting_email" {
+		return
+	}
+	h.cache.Delete(stateKey)
+	// В режиме чистого чата введённый адрес удаляется после ответа
+	defer h.deleteUserMessage(ctx, b, update.Message, config.CleanChatMenus)
+
+	langCode := update.Message.From.LanguageCode
+	backRow := []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackConnect}}
+
+	email, ok := yookasa.NormalizeReceiptEmail(update.Message.Text)
+	if !ok {
+		_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.Message.Chat.ID,
+			Text:   h.translation.GetText(langCode, "receipt_email_invalid"),
+			ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+				{{Text: h.translation.GetText(langCode, "receipt_email_retry_button"), CallbackData: CallbackReceiptEmail}},
+				backRow,
+			}},
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error sending receipt email error", "error", err)
+		}
+		return
+	}
+
+	customer, err := h.customerRepository.FindByTelegramId(ctx, update.Message.Chat.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for receipt email", "error", err)
+		return
+	}
+	if err := h.customerRepository.UpdateFields(ctx, customer.ID, map[string]interface{}{"email": email}); err != nil {
+		slog.ErrorContext(ctx, "Error saving customer email", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		return
+	}
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      update.Message.Chat.ID,
+		Text:        h.translation.GetTextTemplate(langCode, "receipt_email_saved", map[string]interface{}{"email": email}),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{backRow}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending receipt email confirmation", "error", err)
+	}
+}
+
+func (h ProfileHandlers) editReceiptEmailMenu(ctx context.Context, b *bot.Bot, callback *models.Message, customer *database.Customer, langCode string) {
+	text, keyboard := h.receiptEmailMenu(customer, langCode)
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      callback.Chat.ID,
+		MessageID:   callback.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: keyboard,
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error sending receipt email menu", "error", err)
+	}
+}
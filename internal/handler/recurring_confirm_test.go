@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/google/uuid"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/yookasa"
+)
+
+// recordingTelegramBot запоминает отправленные сообщения
+type recordingTelegramBot struct {
+	sent []*bot.SendMessageParams
+}
+
+func (m *recordingTelegramBot) SendMessage(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+	m.sent = append(m.sent, params)
+	return &models.Message{}, nil
+}
+
+func enableRecurringConfirmation(t *testing.T, threshold string) {
+	t.Cleanup(config.InitConfig)
+	t.Setenv("YOOKASA_ENABLED", "true")
+	t.Setenv("YOOKASA_URL", "http://yookasa.test")
+	t.Setenv("YOOKASA_SHOP_ID", "shop")
+	t.Setenv("YOOKASA_SECRET_KEY", "secret")
+	t.Setenv("YOOKASA_EMAIL", "test@example.com")
+	t.Setenv("RECURRING_PAYMENTS_ENABLED", "true")
+	t.Setenv("RECURRING_CONFIRM_THRESHOLD", threshold)
+	config.InitConfig()
+}
+
+func recurringCustomer(amount, months int) *database.Customer {
+	paymentMethodID := uuid.New().String()
+	return &database.Customer{
+		ID: 1, TelegramID: 42, RecurringEnabled: true,
+		PaymentMethodID: &paymentMethodID, RecurringAmount: &amount, RecurringMonths: &months,
+	}
+}
+
+func TestExpiresIn24HoursRequestsConfirmationAboveThreshold(t *testing.T) {
+	enableRecurringConfirmation(t, "3000")
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	firstConnected := now.AddDate(0, -11, 0)
+
+	tests := []struct {
+		name        string
+		amount      int
+		wantRequest bool
+	}{
+		{"monthly renewal", 500, false},
+		{"at threshold", 3000, false},
+		{"annual renewal", 3500, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			customerRepo := &mockCustomerRepo{customer: recurringCustomer(tt.amount, 12)}
+			telegramBot := &recordingTelegramBot{}
+			h := &RemnawaveWebhookHandler{
+				tm:           &mockTranslationManager{},
+				telegramBot:  telegramBot,
+				customerRepo: customerRepo,
+				clock:        clock.NewFake(now),
+			}
+
+			err := h.processUserExpiresIn24Hours(context.Background(), WebhookUser{TelegramID: "42", FirstConnectedAt: &firstConnected})
+			if err != nil {
+				t.Fatalf("processUserExpiresIn24Hours failed: %v", err)
+			}
+			if len(telegramBot.sent) != 1 {
+				t.Fatalf("Expected one notification, got %d", len(telegramBot.sent))
+			}
+
+			requested := strings.HasPrefix(telegramBot.sent[0].Text, "recurring_confirm_request")
+			if requested != tt.wantRequest {
+				t.Errorf("Expected confirmation request=%v, got text %q", tt.wantRequest, telegramBot.sent[0].Text)
+			}
+			if tt.wantRequest {
+				if at, ok := customerRepo.lastUpdates["recurring_confirm_requested_at"].(time.Time); !ok || !at.Equal(now) {
+					t.Errorf("Expected confirmation request to be saved, got %v", customerRepo.lastUpdates)
+				}
+				markup := telegramBot.sent[0].ReplyMarkup.(*models.InlineKeyboardMarkup)
+				if markup.InlineKeyboard[0][0].CallbackData != CallbackRecurringConfirm {
+					t.Errorf("Expected confirm button first, got %+v", markup.InlineKeyboard)
+				}
+			}
+		})
+	}
+}
+
+func TestRecurringPaymentRequiresConfirmationAboveThreshold(t *testing.T) {
+	enableRecurringConfirmation(t, "3000")
+	now := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	requested := now.Add(-24 * time.Hour)
+	confirmed := requested.Add(time.Hour)
+	stale := now.AddDate(0, -1, 0)
+
+	tests := []struct {
+		name        string
+		requestedAt *time.Time
+		confirmedAt *time.Time
+		wantCharged bool
+	}{
+		{"not confirmed", &requested, nil, false},
+		{"confirmed", &requested, &confirmed, true},
+		{"confirmation from previous renewal", &stale, &stale, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			customer := recurringCustomer(3500, 12)
+			customer.RecurringConfirmRequestedAt = tt.requestedAt
+			customer.RecurringConfirmedAt = tt.confirmedAt
+			yookasaClient := &mockYookasaClient{returnPayment: &yookasa.Payment{ID: uuid.New(), Status: "succeeded", Paid: true}}
+			telegramBot := &recordingTelegramBot{}
+			customerRepo := &mockCustomerRepo{customer: customer}
+			h := &RemnawaveWebhookHandler{
+				tm:           &mockTranslationManager{},
+				telegramBot:  telegramBot,
+				customerRepo: customerRepo,
+				purchaseRepo: &mockPurchaseRepo{},
+				yookasa:      yookasaClient,
+				remnawave:    &mockRemnawaveClient{},
+				clock:        clock.NewFake(now),
+			}
+
+			if err := h.processRecurringPayment(context.Background(), customer, 42, "ru"); err != nil {
+				t.Fatalf("processRecurringPayment failed: %v", err)
+			}
+
+			if charged := yookasaClient.lastAmount != 0; charged != tt.wantCharged {
+				t.Errorf("Expected charged=%v, got %v", tt.wantCharged, charged)
+			}
+			if !tt.wantCharged {
+				if len(telegramBot.sent) != 1 || telegramBot.sent[0].Text != "subscription_expired" {
+					t.Errorf("Expected a normal expiry reminder, got %+v", telegramBot.sent)
+				}
+				if customerRepo.disableRecurringCalls != 0 {
+					t.Error("Expected recurring to stay enabled without confirmation")
+				}
+			}
+		})
+	}
+}
+
+func TestRecurringConfirmCallbackHandler(t *testing.T) {
+	b, tg := newTestBot(t)
+	requested := time.Now().Add(-time.Hour)
+	customer := recurringCustomer(3500, 12)
+	customer.RecurringConfirmRequestedAt = &requested
+	customers := &mockPaymentCustomers{customer: customer}
+	h := NewPaymentHandlers(fakeTranslator{}, newFakeCache(), customers, nil, nil, nil, nil, nil)
+
+	h.RecurringConfirmCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackRecurringConfirm))
+
+	if customers.confirmedAt == nil {
+		t.Fatal("Expected recurring charge to be confirmed")
+	}
+	edited := tg.called("editMessageText")
+	if len(edited) != 1 || edited[0].params["text"] != "recurring_confirmed" {
+		t.Errorf("Expected confirmation message, got %v", edited)
+	}
+}
@@ -9,14 +9,14 @@ import (
 	"log/slog"
 )
 
-func (h Handler) ReferralCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h ProfileHandlers) ReferralCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
 
 	customer, err := h.customerRepository.FindByTelegramId(ctx, update.CallbackQuery.From.ID)
 	if err != nil || customer == nil {
-		slog.Error("error finding customer for referral", "error", err)
+		slog.ErrorContext(ctx, "error finding customer for referral", "error", err)
 		return
 	}
 	langCode := update.CallbackQuery.From.LanguageCode
@@ -25,7 +25,7 @@ func (h Handler) ReferralCallbackHandler(ctx context.Context, b *bot.Bot, update
 	refLink := fmt.Sprintf("https://telegram.me/share/url?url=https://t.me/%s?start=ref_%d", update.CallbackQuery.Message.Message.From.Username, refCode)
 	count, err := h.referralRepository.CountByReferrer(ctx, customer.TelegramID)
 	if err != nil {
-		slog.Error("error counting referrals", "error", err)
+		slog.ErrorContext(ctx, "error counting referrals", "error", err)
 		return
 	}
 	text := fmt.Sprintf(h.translation.GetText(langCode, "referral_text"), count)
@@ -40,11 +40,11 @@ func (h Handler) ReferralCallbackHandler(ctx context.Context, b *bot.Bot, update
 				{Text: h.translation.GetText(langCode, "share_referral_button"), URL: refLink},
 			},
 			{
-				{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart},
+				{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
 			},
 		}},
 	})
 	if err != nil {
-		slog.Error("Error sending referral message", "error", err)
+		slog.ErrorContext(ctx, "Error sending referral message", "error", err)
 	}
 }
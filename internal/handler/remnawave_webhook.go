@@ -10,6 +10,7 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-telegram/bot"
@@ -17,8 +18,15 @@ import (
 	"github.com/google/uuid"
 	remapi "github.com/Jolymmiles/remnawave-api-go/v2/api"
 
+	"remnawave-tg-shop-bot/internal/alert"
+	"remnawave-tg-shop-bot/internal/clock"
 	"remnawave-tg-shop-bot/internal/config"
 	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/events"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/logging"
+	"remnawave-tg-shop-bot/internal/payment"
+	"remnawave-tg-shop-bot/internal/remnawave"
 	"remnawave-tg-shop-bot/internal/yookasa"
 	"remnawave-tg-shop-bot/utils"
 )
@@ -32,11 +40,62 @@ type WebhookPayload struct {
 
 // WebhookUser представляет данные пользователя из webhook payload
 type WebhookUser struct {
-	UUID             string          `json:"uuid"`
-	TelegramID       json.Number     `json:"telegramId"`
-	FirstConnectedAt *time.Time      `json:"firstConnectedAt"`
-	ExpireAt         time.Time       `json:"expireAt"`
-	Status           string          `json:"status"`
+	UUID              string          `json:"uuid"`
+	TelegramID        json.Number     `json:"telegramId"`
+	FirstConnectedAt  *time.Time      `json:"firstConnectedAt"`
+	ExpireAt          time.Time       `json:"expireAt"`
+	Status            string          `json:"status"`
+	SubscriptionUrl   string          `json:"subscriptionUrl"`
+	UsedTrafficBytes  json.Number     `json:"usedTrafficBytes"`
+	TrafficLimitBytes json.Number     `json:"trafficLimitBytes"`
+}
+
+// TrafficUsagePercent возвращает процент использованного трафика
+// Возвращает 0 если лимит не задан (безлимитный трафик) или данные отсутствуют
+func (u WebhookUser) TrafficUsagePercent() int {
+	limit, err := u.TrafficLimitBytes.Float64()
+	if err != nil || limit <= 0 {
+		return 0
+	}
+	used, err := u.UsedTrafficBytes.Float64()
+	if err != nil || used <= 0 {
+		return 0
+	}
+	percent := int(used * 100 / limit)
+	if percent > 100 {
+		return 100
+	}
+	return percent
+}
+
+// webhookEventID вычисляет ключ дедупликации события
+// Remnawave не передаёт отдельный id события, поэтому используем тип события, uuid пользователя и timestamp.
+// Если timestamp отсутствует — хэш исходного тела запроса
+func webhookEventID(payload WebhookPayload, body []byte) string {
+	var source []byte
+	if payload.Timestamp != "" {
+		source = []byte(strings.Join([]string{payload.Event, payload.Data.UUID, payload.Timestamp}, "|"))
+	} else {
+		source = body
+	}
+	sum := sha256.Sum256(source)
+	return hex.EncodeToString(sum[:])
+}
+
+// webhookRetryDelay возвращает задержку перед следующей попыткой обработки события
+// Экспоненциальный backoff: 1, 2, 4, 8... минут, но не более часа
+func webhookRetryDelay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > 7 {
+		return time.Hour
+	}
+	delay := time.Minute << (attempt - 1)
+	if delay > time.Hour {
+		return time.Hour
+	}
+	return delay
 }
 
 // GetTelegramID возвращает telegramId как int64
@@ -57,6 +116,7 @@ type customerRepository interface {
 	UpdateWinbackOffer(ctx context.Context, id int64, sentAt, expiresAt time.Time, price, devices, months int) error
 	UpdateRecurringNotifiedAt(ctx context.Context, id int64, notifiedAt time.Time) error
 	DisableRecurring(ctx context.Context, id int64) error
+	UpdateFields(ctx context.Context, id int64, updates map[string]interface{}) error
 }
 
 // purchaseRepository интерфейс для проверки оплаченных покупок
@@ -80,6 +140,44 @@ type translationManager interface {
 	GetText(langCode, key string) string
 }
 
+// webhookEventStore интерфейс для хранения webhook событий (дедупликация и повторная обработка)
+type webhookEventStore interface {
+	CreateIfNotExists(ctx context.Context, eventID, event string, payload []byte, claimUntil time.Time) (int64, bool, error)
+	ClaimDue(ctx context.Context, now, claimUntil time.Time, limit int) ([]database.WebhookEvent, error)
+	MarkProcessed(ctx context.Context, id int64, attempts int) error
+	ScheduleRetry(ctx context.Context, id int64, attempts int, lastError string, nextAttemptAt time.Time) error
+	MarkFailed(ctx context.Context, id int64, attempts int, lastError string) error
+	ResetFailed(ctx context.Context) (int64, error)
+}
+
+// recurringChargeLogger интерфейс для журнала попыток автосписания
+type recurringChargeLogger interface {
+	LogRecurringCharge(ctx context.Context, customerID int64, amount, months int, status database.RecurringChargeStatus, errText *string) error
+	SumSucceededRecurringCharges(ctx context.Context, customerID int64, since time.Time) (int, error)
+}
+
+// balanceStore интерфейс внутреннего баланса клиента
+type balanceStore interface {
+	GetBalance(ctx context.Context, id int64) (int, error)
+	DebitBalance(ctx context.Context, id int64, limit int) (int, error)
+	CreditBalance(ctx context.Context, id int64, amount int) error
+}
+
+// paymentMethodStore интерфейс списка сохранённых карт клиента
+type paymentMethodStore interface {
+	Save(ctx context.Context, method *database.PaymentMethod) error
+}
+
+// recurringFulfiller выдаёт оплаченное автопродление через покупку с повтором из журнала ошибок выдачи
+type recurringFulfiller interface {
+	FulfillRecurringExtension(ctx context.Context, customer *database.Customer, ext payment.RecurringExtension) (int64, error)
+}
+
+// localTimeSender доставляет уведомления в разрешённые часы по местному времени клиента
+type localTimeSender interface {
+	SendAtLocalTime(ctx context.Context, customer *database.Customer, kind string, params *bot.SendMessageParams) (time.Time, error)
+}
+
 // telegramBotClient интерфейс для работы с Telegram Bot API
 type telegramBotClient interface {
 	SendMessage(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error)
@@ -94,6 +192,14 @@ type RemnawaveWebhookHandler struct {
 	webhookSecret  string
 	yookasa        yookasaClient
 	remnawave      remnawaveClient
+	eventStore     webhookEventStore
+	chargeLog      recurringChargeLogger
+	balance        balanceStore
+	paymentMethods paymentMethodStore
+	localTime      localTimeSender
+	survey         surveyOfferer
+	fulfillment    recurringFulfiller
+	clock          clock.Clock
 }
 
 // NewRemnawaveWebhookHandler создаёт новый handler для Remnawave webhooks
@@ -112,6 +218,18 @@ func NewRemnawaveWebhookHandler(
 	}
 }
 
+// SetClock подменяет источник времени (для тестов и машины времени на staging)
+func (h *RemnawaveWebhookHandler) SetClock(c clock.Clock) {
+	h.clock = c
+}
+
+func (h *RemnawaveWebhookHandler) now() time.Time {
+	if h.clock != nil {
+		return h.clock.Now()
+	}
+	return clock.Now()
+}
+
 // SetYookasaClient устанавливает YooKassa клиент для рекуррентных платежей
 func (h *RemnawaveWebhookHandler) SetYookasaClient(client yookasaClient) {
 	h.yookasa = client
@@ -122,6 +240,52 @@ func (h *RemnawaveWebhookHandler) SetRemnawaveClient(client remnawaveClient) {
 	h.remnawave = client
 }
 
+// SetEventStore включает сохранение событий с дедупликацией и повторной обработкой при ошибках
+func (h *RemnawaveWebhookHandler) SetEventStore(store webhookEventStore) {
+	h.eventStore = store
+}
+
+// SetRecurringChargeLog включает журналирование результатов автосписаний
+func (h *RemnawaveWebhookHandler) SetRecurringChargeLog(chargeLog recurringChargeLogger) {
+	h.chargeLog = chargeLog
+}
+
+// SetBalanceStore включает оплату автопродления с внутреннего баланса до списания с карты
+func (h *RemnawaveWebhookHandler) SetBalanceStore(balance balanceStore) {
+	h.balance = balance
+}
+
+// SetPaymentMethodStore включает обновление данных карты (тип, последние цифры) после автосписания
+func (h *RemnawaveWebhookHandler) SetPaymentMethodStore(store paymentMethodStore) {
+	h.paymentMethods = store
+}
+
+// SetSurvey включает опрос удовлетворённости после успешного автопродления (может быть nil)
+func (h *RemnawaveWebhookHandler) SetSurvey(survey surveyOfferer) {
+	h.survey = survey
+}
+
+// SetRecurringFulfillment включает повтор продления, которое не удалось выдать после успешного автосписания
+func (h *RemnawaveWebhookHandler) SetRecurringFulfillment(fulfillment recurringFulfiller) {
+	h.fulfillment = fulfillment
+}
+
+// SetLocalTimeSender включает доставку напоминаний и winback предложений в часы NOTIFY_LOCAL_HOURS
+// по местному времени клиента (может быть nil)
+func (h *RemnawaveWebhookHandler) SetLocalTimeSender(sender localTimeSender) {
+	h.localTime = sender
+}
+
+// sendAtLocalTime отправляет уведомление клиенту в разрешённые часы, без клиента в БД — сразу.
+// Возвращает время, когда клиент получит уведомление
+func (h *RemnawaveWebhookHandler) sendAtLocalTime(ctx context.Context, customer *database.Customer, kind string, params *bot.SendMessageParams) (time.Time, error) {
+	if h.localTime == nil || customer == nil {
+		_, err := h.telegramBot.SendMessage(ctx, params)
+		return h.now(), err
+	}
+	return h.localTime.SendAtLocalTime(ctx, customer, kind, params)
+}
+
 
 // validateSignature проверяет подпись webhook запроса
 // Возвращает true если HMAC-SHA256(body, secret) == X-Remnawave-Signature
@@ -157,6 +321,8 @@ func (h *RemnawaveWebhookHandler) HandleWebhook(w http.ResponseWriter, r *http.R
 	signature := r.Header.Get("X-Remnawave-Signature")
 	if !h.validateSignature(body, signature) {
 		slog.Warn("Invalid webhook signature")
+		alert.RecordError(r.Context(), alert.SeverityWarning, alert.KeyWebhookSignature,
+			fmt.Sprintf("Неверная подпись Remnawave webhook (IP: %s)", r.RemoteAddr))
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -169,32 +335,178 @@ func (h *RemnawaveWebhookHandler) HandleWebhook(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// Роутим по типу события (логируем только обработанные события)
 	ctx := r.Context()
-	switch payload.Event {
+	if h.eventStore != nil {
+		h.storeAndProcessEvent(ctx, payload, body)
+	} else if err := h.dispatchEvent(ctx, payload); err != nil {
+		slog.ErrorContext(ctx, "Failed to process webhook event", "error", err)
+	}
+
+	// Всегда возвращаем 200 OK — повторная обработка выполняется на нашей стороне
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// eventProcessor возвращает обработчик для типа события или nil если событие не обрабатывается
+func (h *RemnawaveWebhookHandler) eventProcessor(event string) func(context.Context, WebhookUser) error {
+	switch event {
 	case "user.expires_in_48_hours":
-		if err := h.processUserExpiresIn48Hours(ctx, payload.Data); err != nil {
-			slog.Error("Failed to process user.expires_in_48_hours", "error", err)
-		}
+		return h.processUserExpiresIn48Hours
 	case "user.expires_in_24_hours":
-		if err := h.processUserExpiresIn24Hours(ctx, payload.Data); err != nil {
-			slog.Error("Failed to process user.expires_in_24_hours", "error", err)
-		}
+		return h.processUserExpiresIn24Hours
 	case "user.expired":
-		if err := h.processUserExpired(ctx, payload.Data); err != nil {
-			slog.Error("Failed to process user.expired", "error", err)
-		}
+		return h.processUserExpired
 	case "user.expired_24_hours_ago":
-		if err := h.processUserExpired24HoursAgo(ctx, payload.Data); err != nil {
-			slog.Error("Failed to process user.expired_24_hours_ago", "error", err)
-		}
+		return h.processUserExpired24HoursAgo
+	case "user.first_connected":
+		return h.processUserFirstConnected
+	case "user.bandwidth_usage_threshold_reached":
+		return h.processUserBandwidthThresholdReached
+	case "user.enabled", "user.disabled":
+		return h.processUserStatusChanged
 	default:
-		// Игнорируем неизвестные события без логирования
+		return nil
 	}
+}
 
-	// Всегда возвращаем 200 OK чтобы Remnawave не ретраил
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+// dispatchEvent роутит событие по типу (неизвестные события игнорируются без логирования)
+func (h *RemnawaveWebhookHandler) dispatchEvent(ctx context.Context, payload WebhookPayload) error {
+	process := h.eventProcessor(payload.Event)
+	if process == nil {
+		return nil
+	}
+	// Событие может прийти в другой экземпляр бота сразу после записи клиента: автосписание и предложения
+	// принимают решения только по состоянию из БД, а не по кешу
+	if telegramID := payload.Data.GetTelegramID(); telegramID != nil {
+		database.InvalidateCustomerCache(*telegramID)
+	}
+	if err := process(ctx, payload.Data); err != nil {
+		return fmt.Errorf("failed to process %s: %w", payload.Event, err)
+	}
+	return nil
+}
+
+// storeAndProcessEvent сохраняет событие (дубликаты отбрасываются) и сразу пытается его обработать
+// При ошибке событие остаётся в очереди и будет обработано повторно в ProcessPendingEvents
+func (h *RemnawaveWebhookHandler) storeAndProcessEvent(ctx context.Context, payload WebhookPayload, body []byte) {
+	if h.eventProcessor(payload.Event) == nil {
+		return
+	}
+
+	eventID := webhookEventID(payload, body)
+	id, created, err := h.eventStore.CreateIfNotExists(ctx, eventID, payload.Event, body, h.now().Add(webhookEventClaimTimeout))
+	if err != nil {
+		// Не удалось сохранить — обрабатываем как раньше, без гарантии повтора
+		slog.ErrorContext(ctx, "Failed to store webhook event, processing inline", "event", payload.Event, "error", err)
+		if err := h.dispatchEvent(ctx, payload); err != nil {
+			slog.ErrorContext(ctx, "Failed to process webhook event", "error", err)
+		}
+		return
+	}
+	if !created {
+		slog.InfoContext(ctx, "Duplicate webhook event skipped", "event", payload.Event, "eventId", eventID)
+		return
+	}
+
+	h.processStoredEvent(ctx, id, 0, payload)
+}
+
+// processStoredEvent обрабатывает сохранённое событие и фиксирует результат попытки
+func (h *RemnawaveWebhookHandler) processStoredEvent(ctx context.Context, id int64, prevAttempts int, payload WebhookPayload) {
+	attempts := prevAttempts + 1
+	procErr := h.dispatchEvent(ctx, payload)
+
+	// Результат сохраняем даже если контекст запроса уже отменён
+	saveCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if procErr == nil {
+		if err := h.eventStore.MarkProcessed(saveCtx, id, attempts); err != nil {
+			slog.ErrorContext(ctx, "Failed to mark webhook event processed", "id", id, "error", err)
+		}
+		return
+	}
+
+	if attempts >= config.GetRemnawaveWebhookMaxAttempts() {
+		slog.ErrorContext(ctx, "Webhook event failed, attempts exhausted", "id", id, "event", payload.Event, "attempts", attempts, "error", procErr)
+		if err := h.eventStore.MarkFailed(saveCtx, id, attempts, procErr.Error()); err != nil {
+			slog.ErrorContext(ctx, "Failed to mark webhook event failed", "id", id, "error", err)
+		}
+		return
+	}
+
+	nextAttemptAt := h.now().Add(webhookRetryDelay(attempts))
+	slog.WarnContext(ctx, "Webhook event processing failed, will retry", "id", id, "event", payload.Event, "attempts", attempts, "nextAttemptAt", nextAttemptAt, "error", procErr)
+	if err := h.eventStore.ScheduleRetry(saveCtx, id, attempts, procErr.Error(), nextAttemptAt); err != nil {
+		slog.ErrorContext(ctx, "Failed to schedule webhook event retry", "id", id, "error", err)
+	}
+}
+
+// webhookEventClaimTimeout на сколько событие захватывается обработчиком. Если обработчик упал, не сохранив
+// результат, событие снова обрабатывается после этого срока
+const webhookEventClaimTimeout = 10 * time.Minute
+
+// ProcessPendingEvents повторно обрабатывает события, время следующей попытки которых наступило.
+// События захватываются атомарно, поэтому параллельные запуски не обрабатывают одно событие дважды
+func (h *RemnawaveWebhookHandler) ProcessPendingEvents(ctx context.Context) error {
+	if h.eventStore == nil {
+		return nil
+	}
+
+	now := h.now()
+	events, err := h.eventStore.ClaimDue(ctx, now, now.Add(webhookEventClaimTimeout), 50)
+	if err != nil {
+		return fmt.Errorf("failed to find pending webhook events: %w", err)
+	}
+
+	for _, event := range events {
+		ctx := logging.WithRequestID(ctx, fmt.Sprintf("webhook-event-%d", event.ID))
+		var payload WebhookPayload
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			slog.ErrorContext(ctx, "Failed to parse stored webhook payload", "id", event.ID, "error", err)
+			if err := h.eventStore.MarkFailed(ctx, event.ID, event.Attempts, err.Error()); err != nil {
+				slog.ErrorContext(ctx, "Failed to mark webhook event failed", "id", event.ID, "error", err)
+			}
+			continue
+		}
+		h.processStoredEvent(ctx, event.ID, event.Attempts, payload)
+	}
+
+	if len(events) > 0 {
+		slog.InfoContext(ctx, "Processed pending webhook events", "count", len(events))
+	}
+	return nil
+}
+
+// ReprocessFailedEventsCommandHandler возвращает failed события в очередь и сразу обрабатывает их (команда админа)
+func (h *RemnawaveWebhookHandler) ReprocessFailedEventsCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if h.eventStore == nil {
+		return
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	var text string
+	count, err := h.eventStore.ResetFailed(ctxWithTimeout)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to reset failed webhook events", "error", err)
+		text = "❌ Ошибка при возврате событий в очередь"
+	} else {
+		if err := h.ProcessPendingEvents(ctxWithTimeout); err != nil {
+			slog.ErrorContext(ctx, "Failed to reprocess webhook events", "error", err)
+		}
+		text = fmt.Sprintf("🔁 <b>Повторная обработка webhook событий</b>\n\nВозвращено в очередь: %d", count)
+	}
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    update.Message.Chat.ID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending webhook retry result", "error", err)
+	}
 }
 
 // processUserExpiresIn48Hours обрабатывает событие истечения через 48 часов
@@ -211,13 +523,13 @@ func (h *RemnawaveWebhookHandler) processUserExpiresIn48Hours(ctx context.Contex
 func (h *RemnawaveWebhookHandler) processUserExpiresIn24Hours(ctx context.Context, user WebhookUser) error {
 	// Проверяем firstConnectedAt
 	if user.FirstConnectedAt == nil {
-		slog.Debug("Skipping notification for user without firstConnectedAt", "uuid", user.UUID)
+		slog.DebugContext(ctx, "Skipping notification for user without firstConnectedAt", "uuid", user.UUID)
 		return nil
 	}
 
 	telegramID := user.GetTelegramID()
 	if telegramID == nil {
-		slog.Warn("User has no telegramId", "uuid", user.UUID)
+		slog.WarnContext(ctx, "User has no telegramId", "uuid", user.UUID)
 		return nil
 	}
 
@@ -234,79 +546,73 @@ func (h *RemnawaveWebhookHandler) processUserExpiresIn24Hours(ctx context.Contex
 
 	// Проверяем автопродление
 	if config.IsRecurringPaymentsEnabled() && customer != nil && customer.RecurringEnabled && customer.PaymentMethodID != nil {
-		// Формируем сумму списания
-		amount := 0
-		if customer.RecurringAmount != nil {
-			amount = *customer.RecurringAmount
+		// Формируем сумму списания: к моменту списания может вступить в силу новая цена
+		charge := database.RecurringChargeAt(customer, h.now().Add(24*time.Hour))
+		amount := charge.Amount
+		if charge.ConsentRequired {
+			return h.sendRecurringPriceConsentReminder(ctx, customer, *telegramID, lang)
 		}
-
-		// Уведомление о предстоящем списании
-		message := fmt.Sprintf(
-			h.tm.GetText(lang, "recurring_charge_notification"),
-			amount,
-		)
-
-		// Кнопка управления сохранёнными способами оплаты
-		keyboard := &models.InlineKeyboardMarkup{
-			InlineKeyboard: [][]models.InlineKeyboardButton{
-				{
-					{Text: h.tm.GetText(lang, "saved_payment_methods_button"), CallbackData: CallbackSavedPaymentMethods + "?from=notification"},
-				},
-			},
+		if config.RecurringChargeNeedsConfirmation(amount) {
+			return h.sendRecurringConfirmRequest(ctx, customer, *telegramID, lang, amount)
 		}
 
-		_, err = h.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
-			ChatID:      *telegramID,
-			Text:        message,
-			ParseMode:   "HTML",
-			ReplyMarkup: keyboard,
-		})
+		// Уведомление о предстоящем списании, с учётом внутреннего баланса
+		params := recurringChargeNotification(h.tm, lang, amount, min(h.customerBalance(ctx, customer.ID), amount))
+		params.ChatID = *telegramID
+		_, err = h.sendAtLocalTime(ctx, customer, database.OutboxKindRecurringNotice, params)
 		if err != nil {
 			return fmt.Errorf("failed to send recurring notification: %w", err)
 		}
 
-		slog.Info("Sent recurring charge notification (24h)", "telegramId", utils.MaskHalfInt64(*telegramID), "amount", amount)
+		slog.InfoContext(ctx, "Sent recurring charge notification (24h)", "telegramId", utils.MaskHalfInt64(*telegramID), "amount", amount)
 		return nil
 	}
 
 	// Обычное уведомление об истечении подписки
-	message := h.tm.GetText(lang, "subscription_expiring_1day")
-
-	// Кнопка продления
-	keyboard := &models.InlineKeyboardMarkup{
-		InlineKeyboard: [][]models.InlineKeyboardButton{
-			{
-				{Text: h.tm.GetText(lang, "renew_subscription_button"), CallbackData: CallbackBuy},
-			},
-		},
-	}
-
-	_, err = h.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID:      *telegramID,
-		Text:        message,
-		ParseMode:   "HTML",
-		ReplyMarkup: keyboard,
-	})
+	params := expiringNotification(h.tm, lang)
+	params.ChatID = *telegramID
+	_, err = h.sendAtLocalTime(ctx, customer, database.OutboxKindExpiring, params)
 	if err != nil {
 		return fmt.Errorf("failed to send telegram message: %w", err)
 	}
 
-	slog.Info("Sent 24-hour expiration notification", "telegramId", utils.MaskHalfInt64(*telegramID))
+	slog.InfoContext(ctx, "Sent 24-hour expiration notification", "telegramId", utils.MaskHalfInt64(*telegramID))
 	return nil
 }
 
+// expiringNotification уведомление за сутки до окончания подписки без автопродления
+func expiringNotification(tm translationManager, lang string) *bot.SendMessageParams {
+	return &bot.SendMessageParams{
+		Text:      tm.GetText(lang, "subscription_expiring_1day"),
+		ParseMode: "HTML",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: tm.GetText(lang, "renew_subscription_button"), CallbackData: CallbackBuy}},
+		}},
+	}
+}
+
+// recurringChargeNotification уведомление о предстоящем автосписании amount, из которых fromBalance спишется с баланса
+func recurringChargeNotification(tm translationManager, lang string, amount, fromBalance int) *bot.SendMessageParams {
+	message := fmt.Sprintf(tm.GetText(lang, "recurring_charge_notification"), locale.FormatMoney(lang, float64(amount)))
+	if fromBalance > 0 {
+		message = fmt.Sprintf(tm.GetText(lang, "recurring_charge_notification_balance"),
+			locale.FormatMoney(lang, float64(fromBalance)), locale.FormatMoney(lang, float64(amount-fromBalance)))
+	}
+	return &bot.SendMessageParams{
+		Text:      message,
+		ParseMode: "HTML",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: tm.GetText(lang, "saved_payment_methods_button"), CallbackData: CallbackSavedPaymentMethods + "?from=notification"}},
+		}},
+	}
+}
+
 // processUserExpired обрабатывает событие истечения подписки
 // Если у пользователя включено автопродление - выполняет автоплатёж
 func (h *RemnawaveWebhookHandler) processUserExpired(ctx context.Context, user WebhookUser) error {
-	// Проверяем firstConnectedAt
-	if user.FirstConnectedAt == nil {
-		slog.Debug("Skipping notification for user without firstConnectedAt", "uuid", user.UUID)
-		return nil
-	}
-
 	telegramID := user.GetTelegramID()
 	if telegramID == nil {
-		slog.Warn("User has no telegramId", "uuid", user.UUID)
+		slog.WarnContext(ctx, "User has no telegramId", "uuid", user.UUID)
 		return nil
 	}
 
@@ -316,6 +622,19 @@ func (h *RemnawaveWebhookHandler) processUserExpired(ctx context.Context, user W
 		return fmt.Errorf("failed to find customer: %w", err)
 	}
 
+	// Событие для внешних интеграций отправляется и для тех, кто так и не подключился
+	expired := events.Subscription{TelegramID: *telegramID, ExpireAt: &user.ExpireAt}
+	if customer != nil {
+		expired.CustomerID = customer.ID
+	}
+	events.Publish(ctx, events.SubscriptionExpired(expired))
+
+	// Проверяем firstConnectedAt
+	if user.FirstConnectedAt == nil {
+		slog.DebugContext(ctx, "Skipping notification for user without firstConnectedAt", "uuid", user.UUID)
+		return nil
+	}
+
 	lang := config.DefaultLanguage()
 	if customer != nil && customer.Language != "" {
 		lang = customer.Language
@@ -326,7 +645,9 @@ func (h *RemnawaveWebhookHandler) processUserExpired(ctx context.Context, user W
 		// Пытаемся выполнить автоплатёж
 		err := h.processRecurringPayment(ctx, customer, *telegramID, lang)
 		if err != nil {
-			slog.Error("Recurring payment failed", "telegramId", utils.MaskHalfInt64(*telegramID), "error", err)
+			slog.ErrorContext(ctx, "Recurring payment failed", "telegramId", utils.MaskHalfInt64(*telegramID), "error", err)
+			alert.RecordError(ctx, alert.SeverityCritical, alert.KeyRecurringPaymentErr,
+				fmt.Sprintf("Всплеск ошибок автоплатежей, последняя: %v", err))
 			// При ошибке отправляем уведомление о неудачном списании
 			h.sendRecurringFailedNotification(ctx, *telegramID, lang)
 		}
@@ -334,34 +655,30 @@ func (h *RemnawaveWebhookHandler) processUserExpired(ctx context.Context, user W
 	}
 
 	// Стандартное уведомление об истечении подписки
-	message := h.tm.GetText(lang, "subscription_expired")
-
-	// Кнопка продления
-	keyboard := &models.InlineKeyboardMarkup{
-		InlineKeyboard: [][]models.InlineKeyboardButton{
-			{
-				{Text: h.tm.GetText(lang, "renew_subscription_button"), CallbackData: CallbackBuy},
-			},
-		},
-	}
-
-	// Отправляем уведомление с кнопкой
-	_, err = h.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID:      *telegramID,
-		Text:        message,
-		ParseMode:   "HTML",
-		ReplyMarkup: keyboard,
-	})
+	params := expiredNotification(h.tm, lang)
+	params.ChatID = *telegramID
+	_, err = h.sendAtLocalTime(ctx, customer, database.OutboxKindExpired, params)
 	if err != nil {
 		return fmt.Errorf("failed to send telegram message: %w", err)
 	}
 
-	slog.Info("Sent expired notification", "telegramId", utils.MaskHalfInt64(*telegramID))
+	slog.InfoContext(ctx, "Sent expired notification", "telegramId", utils.MaskHalfInt64(*telegramID))
 	return nil
 }
 
+// expiredNotification уведомление об окончании подписки без автопродления
+func expiredNotification(tm translationManager, lang string) *bot.SendMessageParams {
+	return &bot.SendMessageParams{
+		Text:      tm.GetText(lang, "subscription_expired"),
+		ParseMode: "HTML",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: tm.GetText(lang, "renew_subscription_button"), CallbackData: CallbackBuy}},
+		}},
+	}
+}
+
 // processRecurringPayment выполняет автоматическое списание для пользователя с автопродлением
-func (h *RemnawaveWebhookHandler) processRecurringPayment(ctx context.Context, customer *database.Customer, telegramID int64, lang string) error {
+func (h *RemnawaveWebhookHandler) processRecurringPayment(ctx context.Context, customer *database.Customer, telegramID int64, lang string) (err error) {
 	if h.yookasa == nil || h.remnawave == nil {
 		return fmt.Errorf("yookasa or remnawave client not configured")
 	}
@@ -371,9 +688,9 @@ func (h *RemnawaveWebhookHandler) processRecurringPayment(ctx context.Context, c
 	if h.purchaseRepo != nil {
 		hasRecent, err := h.purchaseRepo.HasRecentPaidPurchase(ctx, customer.ID, 1)
 		if err != nil {
-			slog.Warn("Failed to check recent purchases, proceeding with caution", "error", err)
+			slog.WarnContext(ctx, "Failed to check recent purchases, proceeding with caution", "error", err)
 		} else if hasRecent {
-			slog.Info("Skipping recurring payment - recent payment exists", "customerId", utils.MaskHalfInt64(customer.ID))
+			slog.InfoContext(ctx, "Skipping recurring payment - recent payment exists", "customerId", utils.MaskHalfInt64(customer.ID))
 			return nil
 		}
 	}
@@ -385,151 +702,413 @@ func (h *RemnawaveWebhookHandler) processRecurringPayment(ctx context.Context, c
 	}
 
 	// Получаем параметры автопродления
-	amount := 0
-	if customer.RecurringAmount != nil {
-		amount = *customer.RecurringAmount
+	// Списываем зафиксированную цену; запланированное изменение цены применяется, когда вступило в силу
+	charge := database.RecurringChargeAt(customer, h.now())
+	if len(charge.Updates) > 0 {
+		if err := h.customerRepo.UpdateFields(ctx, customer.ID, charge.Updates); err != nil {
+			return fmt.Errorf("failed to apply recurring price change: %w", err)
+		}
 	}
+	if charge.ConsentRequired {
+		// Повышение цены без согласия клиента не списываем: автопродление отключается
+		if err := h.customerRepo.DisableRecurring(ctx, customer.ID); err != nil {
+			slog.ErrorContext(ctx, "Failed to disable recurring after price increase without consent", "customerId", utils.MaskHalfInt64(customer.ID), "error", err)
+		}
+		h.sendRecurringDisabledNotification(ctx, telegramID, lang, "recurring_price_not_accepted")
+		slog.InfoContext(ctx, "Recurring disabled: price increase not accepted", "telegramId", utils.MaskHalfInt64(telegramID))
+		return nil
+	}
+	amount := charge.Amount
 	if amount == 0 {
 		return fmt.Errorf("recurring amount is zero")
 	}
-
-	months := 1
-	if customer.RecurringMonths != nil {
-		months = *customer.RecurringMonths
+	if config.RecurringChargeNeedsConfirmation(amount) && !database.IsRecurringChargeConfirmed(customer, h.now()) {
+		// Дорогое списание без подтверждения клиента не выполняется: автопродление остаётся включённым,
+		// клиенту приходит обычное напоминание продлить подписку
+		slog.InfoContext(ctx, "Skipping recurring payment - charge not confirmed", "telegramId", utils.MaskHalfInt64(telegramID), "amount", amount)
+		params := expiredNotification(h.tm, lang)
+		params.ChatID = telegramID
+		if _, err := h.sendAtLocalTime(ctx, customer, database.OutboxKindExpired, params); err != nil {
+			return fmt.Errorf("failed to send expired notification: %w", err)
+		}
+		return nil
 	}
 
+	// Посуточный план продлевается на RecurringDays дней, для него months = 0
+	period := customer.RecurringPeriod()
+	months := period.Months
+
 	// Формируем описание платежа
-	var monthString string
-	switch months {
-	case 1:
-		monthString = "месяц"
-	case 3, 4:
-		monthString = "месяца"
-	default:
-		monthString = "месяцев"
-	}
-	description := fmt.Sprintf("Автопродление подписки на %d %s", months, monthString)
+	description := "Автопродление подписки на " + yookasa.PeriodText(period)
 
-	// Создаём автоплатёж
-	payment, err := h.yookasa.CreateRecurringPayment(ctx, paymentMethodID, amount, months, customer.ID, description)
+	// Сначала покрываем продление с внутреннего баланса, с карты списываем только остаток
+	fromBalance, err := h.debitBalance(ctx, customer.ID, amount)
 	if err != nil {
-		return fmt.Errorf("failed to create recurring payment: %w", err)
+		return err
+	}
+	cardAmount := amount - fromBalance
+	var recurringPayment *yookasa.Payment
+
+	// С этого момента фиксируем результат списания в журнале.
+	// Если продление не состоялось, списанное с баланса возвращается
+	permissionRevoked := false
+	spendingCapExceeded := false
+	defer func() {
+		var reason string
+		switch {
+		case err != nil:
+			reason = err.Error()
+		case permissionRevoked:
+			reason = events.RecurringReasonPermissionRevoked
+		case spendingCapExceeded:
+			reason = events.RecurringReasonSpendingCapExceeded
+		default:
+			h.logRecurringCharge(ctx, customer.ID, cardAmount, months, "")
+			return
+		}
+		h.logRecurringCharge(ctx, customer.ID, cardAmount, months, reason)
+		h.refundBalance(ctx, customer.ID, fromBalance)
+		events.Publish(ctx, events.RecurringFailed(events.RecurringFailure{
+			CustomerID:  customer.ID,
+			TelegramID:  telegramID,
+			Amount:      amount,
+			FromBalance: fromBalance,
+			Months:      months,
+			Reason:      reason,
+		}))
+	}()
+
+	// Защита от перебора карт: ограничение суммы автосписаний за сутки
+	if cardAmount > 0 && h.exceedsRecurringSpendingCap(ctx, customer, cardAmount) {
+		if err := h.customerRepo.DisableRecurring(ctx, customer.ID); err != nil {
+			slog.ErrorContext(ctx, "Failed to disable recurring after spending cap exceeded", "customerId", utils.MaskHalfInt64(customer.ID), "error", err)
+		}
+		h.sendRecurringDisabledNotification(ctx, telegramID, lang, "recurring_spending_cap_exceeded")
+		spendingCapExceeded = true
+		return nil
 	}
 
-	// Проверяем результат платежа
-	if payment.IsCancelled() {
-		// Проверяем причину отмены
-		if payment.IsPermissionRevoked() {
-			// Отзыв разрешения - отключаем автопродление
-			if err := h.customerRepo.DisableRecurring(ctx, customer.ID); err != nil {
-				slog.Error("Failed to disable recurring after permission_revoked", "customerId", utils.MaskHalfInt64(customer.ID), "error", err)
+	// Создаём автоплатёж на остаток, если баланса не хватило
+	if cardAmount > 0 {
+		if customer.Email != nil {
+			ctx = yookasa.WithReceiptEmail(ctx, *customer.Email)
+		}
+		payment, err := h.yookasa.CreateRecurringPayment(ctx, paymentMethodID, cardAmount, months, customer.ID, description)
+		if err != nil {
+			return fmt.Errorf("failed to create recurring payment: %w", err)
+		}
+
+		// Проверяем результат платежа
+		if payment.IsCancelled() {
+			// Проверяем причину отмены
+			if payment.IsPermissionRevoked() {
+				// Отзыв разрешения - отключаем автопродление
+				if err := h.customerRepo.DisableRecurring(ctx, customer.ID); err != nil {
+					slog.ErrorContext(ctx, "Failed to disable recurring after permission_revoked", "customerId", utils.MaskHalfInt64(customer.ID), "error", err)
+				}
+				h.sendPermissionRevokedNotification(ctx, telegramID, lang)
+				permissionRevoked = true
+				slog.InfoContext(ctx, "Recurring disabled due to permission_revoked", "telegramId", utils.MaskHalfInt64(telegramID))
+				return nil
 			}
-			h.sendPermissionRevokedNotification(ctx, telegramID, lang)
-			slog.Info("Recurring disabled due to permission_revoked", "telegramId", utils.MaskHalfInt64(telegramID))
-			return nil
+			return fmt.Errorf("payment cancelled: %s", payment.CancellationDetails.Reason)
 		}
-		return fmt.Errorf("payment cancelled: %s", payment.CancellationDetails.Reason)
-	}
 
-	if !payment.IsSucceeded() {
-		return fmt.Errorf("payment not succeeded, status: %s", payment.Status)
+		if !payment.IsSucceeded() {
+			return fmt.Errorf("payment not succeeded, status: %s", payment.Status)
+		}
+		recurringPayment = payment
+
+		// Карты, перенесённые из старой схемы, не содержат маски и типа — берём их из успешного платежа
+		if h.paymentMethods != nil {
+			cardType, last4, title := payment.SavedMethodDetails()
+			method := &database.PaymentMethod{
+				CustomerID: customer.ID,
+				MethodID:   *customer.PaymentMethodID,
+				CardType:   cardType,
+				CardLast4:  last4,
+				Title:      title,
+			}
+			if err := h.paymentMethods.Save(ctx, method); err != nil {
+				slog.WarnContext(ctx, "Failed to update saved card details", "customerId", utils.MaskHalfInt64(customer.ID), "error", err)
+			}
+		}
 	}
 
 	// Платёж успешен - продлеваем подписку
-	days := months * config.DaysInMonth()
+	days := period.TotalDays()
 
-	// Получаем лимит устройств из тарифа если есть
-	var deviceLimit *int
-	if customer.RecurringTariffName != nil {
+	// Лимит устройств из снимка тарифа на момент подключения, для старых подключений — из текущего тарифа
+	deviceLimit := customer.RecurringDeviceLimit
+	if deviceLimit == nil && customer.RecurringTariffName != nil {
 		tariff := config.GetTariffByName(*customer.RecurringTariffName)
 		if tariff != nil {
 			deviceLimit = &tariff.Devices
 		}
 	}
 
-	_, err = h.remnawave.CreateOrUpdateUserWithDeviceLimit(ctx, customer.ID, telegramID, config.TrafficLimit(), days, false, deviceLimit, false)
-	if err != nil {
-		slog.Error("Failed to extend subscription after recurring payment", "telegramId", utils.MaskHalfInt64(telegramID), "error", err)
-		return fmt.Errorf("failed to extend subscription: %w", err)
+	// Автопродление возвращает тег тарифа подключения, например после покупки по winback
+	panelCtx := ctx
+	panelTag := ""
+	if config.IsRemnawaveTagSyncEnabled() && customer.RecurringTariffName != nil {
+		panelTag = remnawave.TariffTag(*customer.RecurringTariffName)
+	}
+	if panelTag != "" {
+		panelCtx = remnawave.WithTag(ctx, panelTag)
+	}
+
+	_, extendErr := h.remnawave.CreateOrUpdateUserWithDeviceLimit(panelCtx, customer.ID, telegramID, config.TrafficLimit(), days, false, deviceLimit, false)
+	if extendErr != nil {
+		// Деньги уже списаны: списание считается успешным, а продление повторяется отдельно
+		slog.ErrorContext(ctx, "Failed to extend subscription after recurring payment", "telegramId", utils.MaskHalfInt64(telegramID), "error", extendErr)
+		h.retryRecurringExtension(ctx, customer, payment.RecurringExtension{
+			Amount:      float64(amount),
+			Period:      period,
+			TariffName:  customer.RecurringTariffName,
+			DeviceLimit: customer.RecurringDeviceLimit,
+			Payment:     recurringPayment,
+		}, extendErr)
+		return nil
+	}
+	if panelTag != "" {
+		if err := h.customerRepo.UpdateFields(ctx, customer.ID, map[string]interface{}{"remnawave_tag": panelTag}); err != nil {
+			slog.WarnContext(ctx, "Failed to save remnawave tag", "customerId", utils.MaskHalfInt64(customer.ID), "error", err)
+		}
 	}
 
 	// Отправляем уведомление об успешном продлении
-	h.sendRecurringSuccessNotification(ctx, telegramID, lang, amount, months)
+	h.sendRecurringSuccessNotification(ctx, telegramID, lang, fromBalance, cardAmount)
+	if h.survey != nil {
+		if _, err := h.survey.Offer(ctx, customer, database.SurveyTriggerRenewal); err != nil {
+			slog.WarnContext(ctx, "Failed to send renewal survey", "customerId", utils.MaskHalfInt64(customer.ID), "error", err)
+		}
+	}
 
-	slog.Info("Recurring payment successful", "telegramId", utils.MaskHalfInt64(telegramID), "amount", amount, "months", months)
+	slog.InfoContext(ctx, "Recurring payment successful", "telegramId", utils.MaskHalfInt64(telegramID), "amount", amount, "fromBalance", fromBalance, "period", period.String())
 	return nil
 }
 
-// sendRecurringSuccessNotification отправляет уведомление об успешном автопродлении
-func (h *RemnawaveWebhookHandler) sendRecurringSuccessNotification(ctx context.Context, telegramID int64, lang string, amount int, months int) {
-	message := h.tm.GetText(lang, "recurring_success_simple")
+// retryRecurringExtension выдаёт оплаченное автопродление повторно через покупку. Если и эта попытка не удалась,
+// покупка остаётся в журнале ошибок выдачи: автоматический повтор, алерт админу и повтор из /admin → Ошибки
+func (h *RemnawaveWebhookHandler) retryRecurringExtension(ctx context.Context, customer *database.Customer, ext payment.RecurringExtension, extendErr error) {
+	if h.fulfillment == nil {
+		alert.Notify(ctx, alert.SeverityCritical, fmt.Sprintf("%s:%d", alert.KeyRecurringPaymentErr, customer.ID),
+			fmt.Sprintf("Автопродление клиента %d оплачено, но подписка не продлена: %v. Продлите подписку вручную", customer.ID, extendErr))
+		return
+	}
 
-	_, err := h.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID:    telegramID,
-		Text:      message,
-		ParseMode: "HTML",
-	})
+	purchaseID, err := h.fulfillment.FulfillRecurringExtension(ctx, customer, ext)
+	switch {
+	case err == nil:
+		slog.InfoContext(ctx, "Recurring extension fulfilled by retry", "customerId", utils.MaskHalfInt64(customer.ID), "purchaseId", purchaseID)
+	case purchaseID == 0:
+		alert.Notify(ctx, alert.SeverityCritical, fmt.Sprintf("%s:%d", alert.KeyRecurringPaymentErr, customer.ID),
+			fmt.Sprintf("Автопродление клиента %d оплачено, но подписка не продлена: %v. Продлите подписку вручную", customer.ID, err))
+	default:
+		slog.WarnContext(ctx, "Recurring extension left for retry", "customerId", utils.MaskHalfInt64(customer.ID), "purchaseId", purchaseID, "error", err)
+	}
+}
+
+// customerBalance возвращает внутренний баланс клиента (0 если баланс не подключён или недоступен)
+func (h *RemnawaveWebhookHandler) customerBalance(ctx context.Context, customerID int64) int {
+	if h.balance == nil {
+		return 0
+	}
+	balance, err := h.balance.GetBalance(ctx, customerID)
 	if err != nil {
-		slog.Error("Failed to send recurring success notification", "telegramId", utils.MaskHalfInt64(telegramID), "error", err)
+		slog.WarnContext(ctx, "Failed to get customer balance", "customerId", utils.MaskHalfInt64(customerID), "error", err)
+		return 0
 	}
+	return balance
 }
 
-// sendRecurringFailedNotification отправляет уведомление о неудачном автоплатеже
-func (h *RemnawaveWebhookHandler) sendRecurringFailedNotification(ctx context.Context, telegramID int64, lang string) {
-	message := h.tm.GetText(lang, "recurring_failed")
+// debitBalance списывает с внутреннего баланса до amount рублей в счёт автопродления
+func (h *RemnawaveWebhookHandler) debitBalance(ctx context.Context, customerID int64, amount int) (int, error) {
+	if h.balance == nil {
+		return 0, nil
+	}
+	debited, err := h.balance.DebitBalance(ctx, customerID, amount)
+	if err != nil {
+		return 0, fmt.Errorf("failed to debit balance: %w", err)
+	}
+	return debited, nil
+}
 
-	keyboard := &models.InlineKeyboardMarkup{
-		InlineKeyboard: [][]models.InlineKeyboardButton{
-			{
-				{Text: h.tm.GetText(lang, "renew_subscription_button"), CallbackData: CallbackBuy},
-			},
-		},
+// refundBalance возвращает на баланс сумму, списанную в счёт несостоявшегося продления
+func (h *RemnawaveWebhookHandler) refundBalance(ctx context.Context, customerID int64, amount int) {
+	if amount == 0 {
+		return
+	}
+	if err := h.balance.CreditBalance(ctx, customerID, amount); err != nil {
+		slog.ErrorContext(ctx, "Failed to refund balance after failed renewal", "customerId", utils.MaskHalfInt64(customerID), "amount", amount, "error", err)
+		alert.Notify(ctx, alert.SeverityCritical, fmt.Sprintf("%s:%d", alert.KeyBalanceRefund, customerID),
+			fmt.Sprintf("Не удалось вернуть %d ₽ на баланс клиента %d после неудачного автопродления: %v", amount, customerID, err))
 	}
+}
 
-	_, err := h.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID:      telegramID,
-		Text:        message,
-		ParseMode:   "HTML",
-		ReplyMarkup: keyboard,
-	})
+// exceedsRecurringSpendingCap проверяет, превысит ли списание amount лимит автосписаний клиента за сутки.
+// При превышении отправляет алерт админу
+func (h *RemnawaveWebhookHandler) exceedsRecurringSpendingCap(ctx context.Context, customer *database.Customer, amount int) bool {
+	limit := config.GetMaxRecurringAmountPerDay()
+	if limit <= 0 || h.chargeLog == nil {
+		return false
+	}
+
+	spent, err := h.chargeLog.SumSucceededRecurringCharges(ctx, customer.ID, h.now().Add(-24*time.Hour))
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to check recurring spending cap, proceeding", "customerId", utils.MaskHalfInt64(customer.ID), "error", err)
+		return false
+	}
+	if spent+amount <= limit {
+		return false
+	}
+
+	slog.WarnContext(ctx, "Recurring spending cap exceeded, disabling recurring",
+		"customerId", utils.MaskHalfInt64(customer.ID), "spent", spent, "amount", amount, "limit", limit)
+	alert.Notify(ctx, alert.SeverityCritical, fmt.Sprintf("%s:%d", alert.KeyRecurringSpendingCap, customer.ID),
+		fmt.Sprintf("Превышен лимит автосписаний за сутки: клиент %d (telegram %d), списано %d ₽, попытка %d ₽, лимит %d ₽. Автопродление отключено",
+			customer.ID, customer.TelegramID, spent, amount, limit))
+	return true
+}
+
+// logRecurringCharge сохраняет результат автосписания, пустой errText означает успешное списание
+func (h *RemnawaveWebhookHandler) logRecurringCharge(ctx context.Context, customerID int64, amount, months int, errText string) {
+	if h.chargeLog == nil {
+		return
+	}
+
+	status := database.RecurringChargeStatusSucceeded
+	var errPtr *string
+	if errText != "" {
+		status = database.RecurringChargeStatusFailed
+		errPtr = &errText
+	}
+
+	if err := h.chargeLog.LogRecurringCharge(ctx, customerID, amount, months, status, errPtr); err != nil {
+		slog.ErrorContext(ctx, "Failed to log recurring charge", "customerId", utils.MaskHalfInt64(customerID), "error", err)
+	}
+}
+
+// sendRecurringSuccessNotification отправляет уведомление об успешном автопродлении.
+// Если продление частично или полностью оплачено с баланса, сообщает сколько списано с баланса и с карты
+func (h *RemnawaveWebhookHandler) sendRecurringSuccessNotification(ctx context.Context, telegramID int64, lang string, fromBalance, cardAmount int) {
+	params := recurringSuccessNotification(h.tm, lang, fromBalance, cardAmount)
+	params.ChatID = telegramID
+	_, err := h.telegramBot.SendMessage(ctx, params)
 	if err != nil {
-		slog.Error("Failed to send recurring failed notification", "telegramId", utils.MaskHalfInt64(telegramID), "error", err)
+		slog.ErrorContext(ctx, "Failed to send recurring success notification", "telegramId", utils.MaskHalfInt64(telegramID), "error", err)
+	}
+}
+
+// recurringSuccessNotification уведомление об успешном автопродлении, fromBalance из суммы списано с баланса
+func recurringSuccessNotification(tm translationManager, lang string, fromBalance, cardAmount int) *bot.SendMessageParams {
+	message := tm.GetText(lang, "recurring_success_simple")
+	if fromBalance > 0 {
+		message = fmt.Sprintf(tm.GetText(lang, "recurring_success_balance"),
+			locale.FormatMoney(lang, float64(fromBalance)), locale.FormatMoney(lang, float64(cardAmount)))
+	}
+	return &bot.SendMessageParams{Text: message, ParseMode: "HTML"}
+}
+
+// sendRecurringPriceConsentReminder напоминает за сутки до списания, что повышение цены автопродления
+// не подтверждено и без подтверждения автопродление будет отключено
+func (h *RemnawaveWebhookHandler) sendRecurringPriceConsentReminder(ctx context.Context, customer *database.Customer, telegramID int64, lang string) error {
+	params := recurringPriceConsentNotification(h.tm, lang, *customer.RecurringPendingAmount)
+	params.ChatID = telegramID
+	_, err := h.telegramBot.SendMessage(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to send recurring price consent reminder: %w", err)
+	}
+	slog.InfoContext(ctx, "Sent recurring price consent reminder (24h)", "telegramId", utils.MaskHalfInt64(telegramID))
+	return nil
+}
+
+// sendRecurringConfirmRequest просит клиента подтвердить завтрашнее автосписание amount. Без подтверждения
+// списания не будет
+func (h *RemnawaveWebhookHandler) sendRecurringConfirmRequest(ctx context.Context, customer *database.Customer, telegramID int64, lang string, amount int) error {
+	if err := h.customerRepo.UpdateFields(ctx, customer.ID, database.RecurringConfirmationRequest(h.now())); err != nil {
+		return fmt.Errorf("failed to save recurring confirmation request: %w", err)
+	}
+	params := recurringConfirmNotification(h.tm, lang, amount)
+	params.ChatID = telegramID
+	// Отправляется сразу, а не в часы NOTIFY_LOCAL_HOURS: у клиента должны быть сутки на подтверждение
+	if _, err := h.telegramBot.SendMessage(ctx, params); err != nil {
+		return fmt.Errorf("failed to send recurring confirmation request: %w", err)
+	}
+	slog.InfoContext(ctx, "Sent recurring charge confirmation request (24h)", "telegramId", utils.MaskHalfInt64(telegramID), "amount", amount)
+	return nil
+}
+
+// recurringConfirmNotification просьба подтвердить завтрашнее автосписание amount
+func recurringConfirmNotification(tm translationManager, lang string, amount int) *bot.SendMessageParams {
+	return &bot.SendMessageParams{
+		Text:      fmt.Sprintf(tm.GetText(lang, "recurring_confirm_request"), locale.FormatMoney(lang, float64(amount))),
+		ParseMode: "HTML",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: tm.GetText(lang, "recurring_confirm_button"), CallbackData: CallbackRecurringConfirm}},
+			{{Text: tm.GetText(lang, "saved_payment_methods_button"), CallbackData: CallbackSavedPaymentMethods + "?from=notification"}},
+		}},
+	}
+}
+
+// recurringPriceConsentNotification напоминание согласиться с новой ценой автопродления pendingAmount
+func recurringPriceConsentNotification(tm translationManager, lang string, pendingAmount int) *bot.SendMessageParams {
+	return &bot.SendMessageParams{
+		Text:      fmt.Sprintf(tm.GetText(lang, "recurring_price_consent_reminder"), locale.FormatMoney(lang, float64(pendingAmount))),
+		ParseMode: "HTML",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: tm.GetText(lang, "recurring_price_accept_button"), CallbackData: CallbackRecurringPriceAccept}},
+			{{Text: tm.GetText(lang, "saved_payment_methods_button"), CallbackData: CallbackSavedPaymentMethods + "?from=notification"}},
+		}},
+	}
+}
+
+// sendRecurringFailedNotification отправляет уведомление о неудачном автоплатеже
+func (h *RemnawaveWebhookHandler) sendRecurringFailedNotification(ctx context.Context, telegramID int64, lang string) {
+	params := recurringDisabledNotification(h.tm, lang, "recurring_failed")
+	params.ChatID = telegramID
+	_, err := h.telegramBot.SendMessage(ctx, params)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to send recurring failed notification", "telegramId", utils.MaskHalfInt64(telegramID), "error", err)
 	}
 }
 
 // sendPermissionRevokedNotification отправляет уведомление об отзыве разрешения на автоплатежи
 func (h *RemnawaveWebhookHandler) sendPermissionRevokedNotification(ctx context.Context, telegramID int64, lang string) {
-	message := h.tm.GetText(lang, "recurring_permission_revoked")
+	h.sendRecurringDisabledNotification(ctx, telegramID, lang, "recurring_permission_revoked")
+}
 
-	keyboard := &models.InlineKeyboardMarkup{
-		InlineKeyboard: [][]models.InlineKeyboardButton{
-			{
-				{Text: h.tm.GetText(lang, "renew_subscription_button"), CallbackData: CallbackBuy},
-			},
-		},
+// sendRecurringDisabledNotification сообщает пользователю об отключении автопродления с предложением продлить вручную
+func (h *RemnawaveWebhookHandler) sendRecurringDisabledNotification(ctx context.Context, telegramID int64, lang, messageKey string) {
+	params := recurringDisabledNotification(h.tm, lang, messageKey)
+	params.ChatID = telegramID
+	_, err := h.telegramBot.SendMessage(ctx, params)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to send recurring disabled notification", "messageKey", messageKey, "telegramId", utils.MaskHalfInt64(telegramID), "error", err)
 	}
+}
 
-	_, err := h.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID:      telegramID,
-		Text:        message,
-		ParseMode:   "HTML",
-		ReplyMarkup: keyboard,
-	})
-	if err != nil {
-		slog.Error("Failed to send permission revoked notification", "telegramId", utils.MaskHalfInt64(telegramID), "error", err)
+// recurringDisabledNotification сообщение messageKey об отключении или сбое автопродления с кнопкой ручного продления
+func recurringDisabledNotification(tm translationManager, lang, messageKey string) *bot.SendMessageParams {
+	return &bot.SendMessageParams{
+		Text:      tm.GetText(lang, messageKey),
+		ParseMode: "HTML",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: tm.GetText(lang, "renew_subscription_button"), CallbackData: CallbackBuy}},
+		}},
 	}
 }
 
 // processUserExpired24HoursAgo обрабатывает событие истечения подписки 24 часа назад (winback)
 func (h *RemnawaveWebhookHandler) processUserExpired24HoursAgo(ctx context.Context, user WebhookUser) error {
 	if !config.IsWinbackEnabled() {
-		slog.Debug("Winback disabled, skipping", "uuid", user.UUID)
+		slog.DebugContext(ctx, "Winback disabled, skipping", "uuid", user.UUID)
 		return nil
 	}
 
 	telegramID := user.GetTelegramID()
 	if telegramID == nil {
-		slog.Warn("User has no telegramId for winback", "uuid", user.UUID)
+		slog.WarnContext(ctx, "User has no telegramId for winback", "uuid", user.UUID)
 		return nil
 	}
 
@@ -539,13 +1118,13 @@ func (h *RemnawaveWebhookHandler) processUserExpired24HoursAgo(ctx context.Conte
 		return fmt.Errorf("failed to find customer: %w", err)
 	}
 	if customer == nil {
-		slog.Warn("Customer not found for winback", "telegramId", utils.MaskHalfInt64(*telegramID))
+		slog.WarnContext(ctx, "Customer not found for winback", "telegramId", utils.MaskHalfInt64(*telegramID))
 		return nil
 	}
 
 	// Проверяем что winback ещё не отправлялся
 	if customer.WinbackOfferSentAt != nil {
-		slog.Debug("Winback already sent", "customerId", utils.MaskHalfInt64(customer.ID))
+		slog.DebugContext(ctx, "Winback already sent", "customerId", utils.MaskHalfInt64(customer.ID))
 		return nil
 	}
 
@@ -555,50 +1134,30 @@ func (h *RemnawaveWebhookHandler) processUserExpired24HoursAgo(ctx context.Conte
 		return fmt.Errorf("failed to check paid purchases: %w", err)
 	}
 	if hasPaid {
-		slog.Debug("User has paid purchases, skipping winback", "customerId", utils.MaskHalfInt64(customer.ID))
+		slog.DebugContext(ctx, "User has paid purchases, skipping winback", "customerId", utils.MaskHalfInt64(customer.ID))
 		return nil
 	}
 
 	// Получаем параметры winback из конфига
-	now := time.Now()
+	now := h.now()
 	price := config.GetWinbackPrice()
 	devices := config.GetWinbackDevices()
 	months := config.GetWinbackMonths()
 	validHours := config.GetWinbackValidHours()
-	expiresAt := now.Add(time.Duration(validHours) * time.Hour)
 
 	lang := config.DefaultLanguage()
 	if customer.Language != "" {
 		lang = customer.Language
 	}
 
-	// Формируем сообщение winback
-	message := fmt.Sprintf(
-		h.tm.GetText(lang, "winback_offer"),
-		price,
-		devices,
-		validHours,
-	)
-
-	// Кнопка активации winback
-	keyboard := &models.InlineKeyboardMarkup{
-		InlineKeyboard: [][]models.InlineKeyboardButton{
-			{
-				{Text: h.tm.GetText(lang, "winback_activate_button"), CallbackData: CallbackWinbackActivate},
-			},
-		},
-	}
-
-	// Отправляем уведомление
-	_, err = h.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID:      *telegramID,
-		Text:        message,
-		ParseMode:   "HTML",
-		ReplyMarkup: keyboard,
-	})
+	// Отправляем уведомление. Срок предложения считается с момента, когда клиент его получит
+	params := winbackOfferNotification(h.tm, lang, price, devices, validHours)
+	params.ChatID = *telegramID
+	deliveredAt, err := h.sendAtLocalTime(ctx, customer, database.OutboxKindWinback, params)
 	if err != nil {
 		return fmt.Errorf("failed to send winback message: %w", err)
 	}
+	expiresAt := deliveredAt.Add(time.Duration(validHours) * time.Hour)
 
 	// Сохраняем информацию о предложении в БД
 	err = h.customerRepo.UpdateWinbackOffer(ctx, customer.ID, now, expiresAt, price, devices, months)
@@ -606,10 +1165,148 @@ func (h *RemnawaveWebhookHandler) processUserExpired24HoursAgo(ctx context.Conte
 		return fmt.Errorf("failed to update winback offer: %w", err)
 	}
 
-	slog.Info("Sent winback offer via webhook",
+	slog.InfoContext(ctx, "Sent winback offer via webhook",
 		"customerId", utils.MaskHalfInt64(customer.ID),
 		"price", price,
 		"devices", devices,
 		"months", months)
 	return nil
 }
+
+// winbackOfferNotification winback-предложение: devices устройств за price, действует validHours часов
+func winbackOfferNotification(tm translationManager, lang string, price, devices, validHours int) *bot.SendMessageParams {
+	return &bot.SendMessageParams{
+		Text: fmt.Sprintf(tm.GetText(lang, "winback_offer"),
+			locale.FormatMoney(lang, float64(price)), devices, locale.FormatDuration(lang, time.Duration(validHours)*time.Hour)),
+		ParseMode: "HTML",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: WithNotificationFeedback([][]models.InlineKeyboardButton{
+			{{Text: tm.GetText(lang, "winback_activate_button"), CallbackData: CallbackWinbackActivate}},
+		}, "winback_offer")},
+	}
+}
+
+// findCustomerForEvent находит customer по telegramId из события и определяет язык
+// Возвращает nil customer если у пользователя нет telegramId
+func (h *RemnawaveWebhookHandler) findCustomerForEvent(ctx context.Context, user WebhookUser) (*int64, *database.Customer, string, error) {
+	telegramID := user.GetTelegramID()
+	if telegramID == nil {
+		slog.WarnContext(ctx, "User has no telegramId", "uuid", user.UUID)
+		return nil, nil, "", nil
+	}
+
+	customer, err := h.customerRepo.FindByTelegramId(ctx, *telegramID)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to find customer: %w", err)
+	}
+
+	lang := config.DefaultLanguage()
+	if customer != nil && customer.Language != "" {
+		lang = customer.Language
+	}
+	return telegramID, customer, lang, nil
+}
+
+// processUserFirstConnected отправляет приветствие и советы по настройке после первого подключения
+func (h *RemnawaveWebhookHandler) processUserFirstConnected(ctx context.Context, user WebhookUser) error {
+	if !config.IsFirstConnectedNotificationEnabled() {
+		return nil
+	}
+
+	telegramID, _, lang, err := h.findCustomerForEvent(ctx, user)
+	if err != nil || telegramID == nil {
+		return err
+	}
+
+	var keyboard [][]models.InlineKeyboardButton
+	if config.SupportURL() != "" {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: h.tm.GetText(lang, "support_button"), URL: config.SupportURL()},
+		})
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: h.tm.GetText(lang, "close_button"), CallbackData: CallbackCloseMessage},
+	})
+
+	_, err = h.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      *telegramID,
+		Text:        h.tm.GetText(lang, "first_connected_welcome"),
+		ParseMode:   "HTML",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send first connected message: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Sent first connected welcome", "telegramId", utils.MaskHalfInt64(*telegramID))
+	return nil
+}
+
+// processUserBandwidthThresholdReached предлагает продлить/сменить тариф при приближении к лимиту трафика
+func (h *RemnawaveWebhookHandler) processUserBandwidthThresholdReached(ctx context.Context, user WebhookUser) error {
+	if !config.IsTrafficThresholdNotificationEnabled() {
+		return nil
+	}
+
+	percent := user.TrafficUsagePercent()
+	if percent == 0 {
+		slog.DebugContext(ctx, "Skipping traffic threshold notification without traffic data", "uuid", user.UUID)
+		return nil
+	}
+
+	telegramID, _, lang, err := h.findCustomerForEvent(ctx, user)
+	if err != nil || telegramID == nil {
+		return err
+	}
+
+	params := trafficThresholdNotification(h.tm, lang, percent)
+	params.ChatID = *telegramID
+	_, err = h.telegramBot.SendMessage(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to send traffic threshold message: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Sent traffic threshold notification", "telegramId", utils.MaskHalfInt64(*telegramID), "percent", percent)
+	return nil
+}
+
+// trafficThresholdNotification уведомление об израсходованных percent процентах трафика
+func trafficThresholdNotification(tm translationManager, lang string, percent int) *bot.SendMessageParams {
+	return &bot.SendMessageParams{
+		Text:      fmt.Sprintf(tm.GetText(lang, "traffic_threshold_notification"), percent),
+		ParseMode: "HTML",
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: tm.GetText(lang, "buy_button"), CallbackData: CallbackBuy}},
+		}},
+	}
+}
+
+// processUserStatusChanged синхронизирует локальные данные customer при включении/отключении пользователя в панели
+func (h *RemnawaveWebhookHandler) processUserStatusChanged(ctx context.Context, user WebhookUser) error {
+	if !config.IsUserStatusSyncEnabled() {
+		return nil
+	}
+
+	_, customer, _, err := h.findCustomerForEvent(ctx, user)
+	if err != nil || customer == nil {
+		return err
+	}
+
+	// Событие без срока или ссылки не должно затирать сохранённые значения
+	updates := map[string]interface{}{}
+	if !user.ExpireAt.IsZero() {
+		updates["expire_at"] = user.ExpireAt
+	}
+	if user.SubscriptionUrl != "" {
+		updates["subscription_link"] = user.SubscriptionUrl
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	if err := h.customerRepo.UpdateFields(ctx, customer.ID, updates); err != nil {
+		return fmt.Errorf("failed to sync customer state: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Synced customer state from webhook", "customerId", utils.MaskHalfInt64(customer.ID), "status", user.Status)
+	return nil
+}
@@ -2,7 +2,12 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"testing/quick"
 	"time"
@@ -12,8 +17,10 @@ import (
 	"github.com/google/uuid"
 	remapi "github.com/Jolymmiles/remnawave-api-go/v2/api"
 
+	"remnawave-tg-shop-bot/internal/clock"
 	"remnawave-tg-shop-bot/internal/config"
 	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/payment"
 	"remnawave-tg-shop-bot/internal/yookasa"
 )
 
@@ -46,6 +53,9 @@ type mockCustomerRepo struct {
 	customer              *database.Customer
 	disableRecurringCalls int
 	updateNotifiedCalls   int
+	lastUpdates           map[string]interface{}
+	winbackSentAt         time.Time
+	winbackExpiresAt      time.Time
 }
 
 func (m *mockCustomerRepo) FindByTelegramId(ctx context.Context, telegramId int64) (*database.Customer, error) {
@@ -53,6 +63,8 @@ func (m *mockCustomerRepo) FindByTelegramId(ctx context.Context, telegramId int6
 }
 
 func (m *mockCustomerRepo) UpdateWinbackOffer(ctx context.Context, id int64, sentAt, expiresAt time.Time, price, devices, months int) error {
+	m.winbackSentAt = sentAt
+	m.winbackExpiresAt = expiresAt
 	return nil
 }
 
@@ -66,6 +78,11 @@ func (m *mockCustomerRepo) DisableRecurring(ctx context.Context, id int64) error
 	return nil
 }
 
+func (m *mockCustomerRepo) UpdateFields(ctx context.Context, id int64, updates map[string]interface{}) error {
+	m.lastUpdates = updates
+	return nil
+}
+
 // mockPurchaseRepo реализует purchaseRepository для тестов
 type mockPurchaseRepo struct {
 	hasRecentPurchase bool
@@ -89,10 +106,14 @@ func (m *mockTranslationManager) GetText(langCode, key string) string {
 // mockTelegramBot реализует telegramBotClient для тестов
 type mockTelegramBot struct {
 	sendMessageCalls int
+	sendErr          error
 }
 
 func (m *mockTelegramBot) SendMessage(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
 	m.sendMessageCalls++
+	if m.sendErr != nil {
+		return nil, m.sendErr
+	}
 	return &models.Message{}, nil
 }
 
@@ -115,12 +136,16 @@ type mockRemnawaveClient struct {
 	lastDays        int
 	lastDeviceLimit *int
 	callCount       int
+	err             error
 }
 
 func (m *mockRemnawaveClient) CreateOrUpdateUserWithDeviceLimit(ctx context.Context, customerId int64, telegramId int64, trafficLimit int, days int, isTrialUser bool, deviceLimit *int, forceDeviceLimit bool) (*remapi.UserResponseResponse, error) {
 	m.lastDays = days
 	m.lastDeviceLimit = deviceLimit
 	m.callCount++
+	if m.err != nil {
+		return nil, m.err
+	}
 	return &remapi.UserResponseResponse{}, nil
 }
 
@@ -634,3 +659,706 @@ func TestRecurringPaymentNoRecentPurchase(t *testing.T) {
 		t.Errorf("Expected 1 SendMessage call, got %d", telegramBot.sendMessageCalls)
 	}
 }
+
+// mockWebhookEventStore реализует webhookEventStore в памяти
+type mockWebhookEventStore struct {
+	events         map[string]*database.WebhookEvent
+	nextID         int64
+	processedCalls int
+	retryCalls     int
+	failedCalls    int
+}
+
+func newMockWebhookEventStore() *mockWebhookEventStore {
+	return &mockWebhookEventStore{events: make(map[string]*database.WebhookEvent)}
+}
+
+func (m *mockWebhookEventStore) byID(id int64) *database.WebhookEvent {
+	for _, e := range m.events {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+func (m *mockWebhookEventStore) CreateIfNotExists(ctx context.Context, eventID, event string, payload []byte, claimUntil time.Time) (int64, bool, error) {
+	if _, ok := m.events[eventID]; ok {
+		return 0, false, nil
+	}
+	m.nextID++
+	m.events[eventID] = &database.WebhookEvent{ID: m.nextID, EventID: eventID, Event: event, Payload: payload,
+		Status: database.WebhookEventStatusProcessing, NextAttemptAt: &claimUntil}
+	return m.nextID, true, nil
+}
+
+func (m *mockWebhookEventStore) ClaimDue(ctx context.Context, now, claimUntil time.Time, limit int) ([]database.WebhookEvent, error) {
+	var due []database.WebhookEvent
+	for _, e := range m.events {
+		claimable := e.Status == database.WebhookEventStatusPending ||
+			e.Status == database.WebhookEventStatusProcessing && e.NextAttemptAt != nil && !e.NextAttemptAt.After(now)
+		if claimable {
+			e.Status = database.WebhookEventStatusProcessing
+			e.NextAttemptAt = &claimUntil
+			due = append(due, *e)
+		}
+	}
+	return due, nil
+}
+
+func (m *mockWebhookEventStore) MarkProcessed(ctx context.Context, id int64, attempts int) error {
+	m.processedCalls++
+	e := m.byID(id)
+	e.Status = database.WebhookEventStatusProcessed
+	e.Attempts = attempts
+	return nil
+}
+
+func (m *mockWebhookEventStore) ScheduleRetry(ctx context.Context, id int64, attempts int, lastError string, nextAttemptAt time.Time) error {
+	m.retryCalls++
+	e := m.byID(id)
+	e.Status = database.WebhookEventStatusPending
+	e.Attempts = attempts
+	e.LastError = &lastError
+	return nil
+}
+
+func (m *mockWebhookEventStore) MarkFailed(ctx context.Context, id int64, attempts int, lastError string) error {
+	m.failedCalls++
+	e := m.byID(id)
+	e.Status = database.WebhookEventStatusFailed
+	e.Attempts = attempts
+	return nil
+}
+
+func (m *mockWebhookEventStore) ResetFailed(ctx context.Context) (int64, error) {
+	var count int64
+	for _, e := range m.events {
+		if e.Status == database.WebhookEventStatusFailed {
+			e.Status = database.WebhookEventStatusPending
+			e.Attempts = 0
+			count++
+		}
+	}
+	return count, nil
+}
+
+const testExpiresIn24HoursBody = `{"event":"user.expires_in_24_hours","timestamp":"2025-01-01T00:00:00Z","data":{"uuid":"u-1","telegramId":42,"firstConnectedAt":"2024-12-01T00:00:00Z","expireAt":"2025-01-02T00:00:00Z","status":"ACTIVE"}}`
+
+func postWebhook(h *RemnawaveWebhookHandler, body string) int {
+	req := httptest.NewRequest(http.MethodPost, "/remnawave-webhook", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.HandleWebhook(rec, req)
+	return rec.Code
+}
+
+// TestWebhookEventDeduplication - повторная доставка того же события не обрабатывается дважды
+func TestWebhookEventDeduplication(t *testing.T) {
+	telegramBot := &mockTelegramBot{}
+	store := newMockWebhookEventStore()
+	handler := &RemnawaveWebhookHandler{
+		tm:           &mockTranslationManager{},
+		telegramBot:  telegramBot,
+		customerRepo: &mockCustomerRepo{},
+		purchaseRepo: &mockPurchaseRepo{},
+		eventStore:   store,
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := postWebhook(handler, testExpiresIn24HoursBody); code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", code)
+		}
+	}
+
+	if telegramBot.sendMessageCalls != 1 {
+		t.Errorf("Expected 1 SendMessage call, got %d", telegramBot.sendMessageCalls)
+	}
+	if len(store.events) != 1 {
+		t.Errorf("Expected 1 stored event, got %d", len(store.events))
+	}
+	if store.processedCalls != 1 {
+		t.Errorf("Expected 1 MarkProcessed call, got %d", store.processedCalls)
+	}
+}
+
+// TestWebhookEventRetryUntilFailed - при ошибке событие повторяется до исчерпания попыток,
+// после чего может быть возвращено в очередь командой админа
+func TestWebhookEventRetryUntilFailed(t *testing.T) {
+	telegramBot := &mockTelegramBot{sendErr: errors.New("telegram unavailable")}
+	store := newMockWebhookEventStore()
+	handler := &RemnawaveWebhookHandler{
+		tm:           &mockTranslationManager{},
+		telegramBot:  telegramBot,
+		customerRepo: &mockCustomerRepo{},
+		purchaseRepo: &mockPurchaseRepo{},
+		eventStore:   store,
+	}
+
+	postWebhook(handler, testExpiresIn24HoursBody)
+
+	maxAttempts := config.GetRemnawaveWebhookMaxAttempts()
+	for i := 1; i < maxAttempts; i++ {
+		if err := handler.ProcessPendingEvents(context.Background()); err != nil {
+			t.Fatalf("ProcessPendingEvents failed: %v", err)
+		}
+	}
+
+	if store.retryCalls != maxAttempts-1 {
+		t.Errorf("Expected %d retries scheduled, got %d", maxAttempts-1, store.retryCalls)
+	}
+	if store.failedCalls != 1 {
+		t.Errorf("Expected event to be marked failed once, got %d", store.failedCalls)
+	}
+
+	// Все попытки исчерпаны — воркер больше не трогает событие
+	calls := telegramBot.sendMessageCalls
+	_ = handler.ProcessPendingEvents(context.Background())
+	if telegramBot.sendMessageCalls != calls {
+		t.Errorf("Failed event should not be retried automatically")
+	}
+
+	// После восстановления и ResetFailed событие обрабатывается успешно
+	telegramBot.sendErr = nil
+	if count, _ := store.ResetFailed(context.Background()); count != 1 {
+		t.Fatalf("Expected 1 event reset, got %d", count)
+	}
+	_ = handler.ProcessPendingEvents(context.Background())
+	if store.processedCalls != 1 {
+		t.Errorf("Expected event to be processed after reset, got %d MarkProcessed calls", store.processedCalls)
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	prev := time.Duration(0)
+	for attempt := 1; attempt <= 20; attempt++ {
+		delay := webhookRetryDelay(attempt)
+		if delay < prev {
+			t.Errorf("Delay must not decrease: attempt %d got %v after %v", attempt, delay, prev)
+		}
+		if delay > time.Hour {
+			t.Errorf("Delay must be capped at 1h, got %v", delay)
+		}
+		prev = delay
+	}
+	if webhookRetryDelay(1) != time.Minute {
+		t.Errorf("Expected first retry after 1 minute, got %v", webhookRetryDelay(1))
+	}
+}
+
+// enableExtraWebhookEvents включает обработку дополнительных событий на время теста
+func enableExtraWebhookEvents(t *testing.T) {
+	t.Cleanup(config.InitConfig)
+	t.Setenv("FIRST_CONNECTED_NOTIFICATION_ENABLED", "true")
+	t.Setenv("TRAFFIC_THRESHOLD_NOTIFICATION_ENABLED", "true")
+	t.Setenv("USER_STATUS_SYNC_ENABLED", "true")
+	config.InitConfig()
+}
+
+func TestExtraWebhookEventsDisabledByDefault(t *testing.T) {
+	events := []string{
+		`{"event":"user.first_connected","data":{"uuid":"u-1","telegramId":42}}`,
+		`{"event":"user.bandwidth_usage_threshold_reached","data":{"uuid":"u-1","telegramId":42,"usedTrafficBytes":80,"trafficLimitBytes":100}}`,
+		`{"event":"user.disabled","data":{"uuid":"u-1","telegramId":42,"expireAt":"2025-01-02T00:00:00Z"}}`,
+	}
+
+	telegramBot := &mockTelegramBot{}
+	customerRepo := &mockCustomerRepo{customer: &database.Customer{ID: 1, TelegramID: 42}}
+	handler := &RemnawaveWebhookHandler{
+		tm:           &mockTranslationManager{},
+		telegramBot:  telegramBot,
+		customerRepo: customerRepo,
+		purchaseRepo: &mockPurchaseRepo{},
+	}
+
+	for _, body := range events {
+		postWebhook(handler, body)
+	}
+
+	if telegramBot.sendMessageCalls != 0 {
+		t.Errorf("Expected no messages when events are disabled, got %d", telegramBot.sendMessageCalls)
+	}
+	if customerRepo.lastUpdates != nil {
+		t.Errorf("Expected no customer updates when status sync is disabled")
+	}
+}
+
+func TestFirstConnectedSendsWelcome(t *testing.T) {
+	enableExtraWebhookEvents(t)
+
+	telegramBot := &mockTelegramBot{}
+	handler := &RemnawaveWebhookHandler{
+		tm:           &mockTranslationManager{},
+		telegramBot:  telegramBot,
+		customerRepo: &mockCustomerRepo{customer: &database.Customer{ID: 1, TelegramID: 42, Language: "en"}},
+		purchaseRepo: &mockPurchaseRepo{},
+	}
+
+	err := handler.dispatchEvent(context.Background(), WebhookPayload{
+		Event: "user.first_connected",
+		Data:  WebhookUser{UUID: "u-1", TelegramID: "42"},
+	})
+	if err != nil {
+		t.Fatalf("dispatchEvent failed: %v", err)
+	}
+	if telegramBot.sendMessageCalls != 1 {
+		t.Errorf("Expected 1 welcome message, got %d", telegramBot.sendMessageCalls)
+	}
+}
+
+func TestBandwidthThresholdNotification(t *testing.T) {
+	enableExtraWebhookEvents(t)
+
+	tests := []struct {
+		name         string
+		used         json.Number
+		limit        json.Number
+		expectedSent int
+	}{
+		{name: "80 percent used", used: "80", limit: "100", expectedSent: 1},
+		{name: "unlimited traffic", used: "80", limit: "0", expectedSent: 0},
+		{name: "no traffic data", used: "", limit: "", expectedSent: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			telegramBot := &mockTelegramBot{}
+			handler := &RemnawaveWebhookHandler{
+				tm:           &mockTranslationManager{},
+				telegramBot:  telegramBot,
+				customerRepo: &mockCustomerRepo{},
+				purchaseRepo: &mockPurchaseRepo{},
+			}
+
+			err := handler.dispatchEvent(context.Background(), WebhookPayload{
+				Event: "user.bandwidth_usage_threshold_reached",
+				Data:  WebhookUser{UUID: "u-1", TelegramID: "42", UsedTrafficBytes: tt.used, TrafficLimitBytes: tt.limit},
+			})
+			if err != nil {
+				t.Fatalf("dispatchEvent failed: %v", err)
+			}
+			if telegramBot.sendMessageCalls != tt.expectedSent {
+				t.Errorf("Expected %d messages, got %d", tt.expectedSent, telegramBot.sendMessageCalls)
+			}
+		})
+	}
+}
+
+func TestUserStatusChangedSyncsCustomer(t *testing.T) {
+	enableExtraWebhookEvents(t)
+
+	for _, event := range []string{"user.enabled", "user.disabled"} {
+		t.Run(event, func(t *testing.T) {
+			expireAt := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+			customerRepo := &mockCustomerRepo{customer: &database.Customer{ID: 1, TelegramID: 42}}
+			handler := &RemnawaveWebhookHandler{
+				tm:           &mockTranslationManager{},
+				telegramBot:  &mockTelegramBot{},
+				customerRepo: customerRepo,
+				purchaseRepo: &mockPurchaseRepo{},
+			}
+
+			err := handler.dispatchEvent(context.Background(), WebhookPayload{
+				Event: event,
+				Data:  WebhookUser{UUID: "u-1", TelegramID: "42", ExpireAt: expireAt, SubscriptionUrl: "https://sub/abc"},
+			})
+			if err != nil {
+				t.Fatalf("dispatchEvent failed: %v", err)
+			}
+			if customerRepo.lastUpdates["expire_at"] != expireAt {
+				t.Errorf("Expected expire_at to be synced, got %v", customerRepo.lastUpdates["expire_at"])
+			}
+			if customerRepo.lastUpdates["subscription_link"] != "https://sub/abc" {
+				t.Errorf("Expected subscription_link to be synced, got %v", customerRepo.lastUpdates["subscription_link"])
+			}
+		})
+	}
+}
+
+func TestUserStatusChangedKeepsExpireWhenMissing(t *testing.T) {
+	enableExtraWebhookEvents(t)
+
+	customerRepo := &mockCustomerRepo{customer: &database.Customer{ID: 1, TelegramID: 42}}
+	handler := &RemnawaveWebhookHandler{
+		tm:           &mockTranslationManager{},
+		telegramBot:  &mockTelegramBot{},
+		customerRepo: customerRepo,
+		purchaseRepo: &mockPurchaseRepo{},
+	}
+
+	err := handler.dispatchEvent(context.Background(), WebhookPayload{
+		Event: "user.disabled",
+		Data:  WebhookUser{UUID: "u-1", TelegramID: "42", SubscriptionUrl: "https://sub/abc"},
+	})
+	if err != nil {
+		t.Fatalf("dispatchEvent failed: %v", err)
+	}
+	if _, ok := customerRepo.lastUpdates["expire_at"]; ok {
+		t.Errorf("Expected expire_at to be left untouched, got %v", customerRepo.lastUpdates)
+	}
+	if customerRepo.lastUpdates["subscription_link"] != "https://sub/abc" {
+		t.Errorf("Expected subscription_link to be synced, got %v", customerRepo.lastUpdates["subscription_link"])
+	}
+
+	customerRepo.lastUpdates = nil
+	err = handler.dispatchEvent(context.Background(), WebhookPayload{
+		Event: "user.enabled",
+		Data:  WebhookUser{UUID: "u-1", TelegramID: "42"},
+	})
+	if err != nil {
+		t.Fatalf("dispatchEvent failed: %v", err)
+	}
+	if customerRepo.lastUpdates != nil {
+		t.Errorf("Expected no update for event without state, got %v", customerRepo.lastUpdates)
+	}
+}
+
+// mockRecurringChargeLog реализует recurringChargeLogger для тестов
+type mockRecurringChargeLog struct {
+	statuses []database.RecurringChargeStatus
+	errTexts []*string
+	spent    int
+}
+
+func (m *mockRecurringChargeLog) LogRecurringCharge(ctx context.Context, customerID int64, amount, months int, status database.RecurringChargeStatus, errText *string) error {
+	m.statuses = append(m.statuses, status)
+	m.errTexts = append(m.errTexts, errText)
+	return nil
+}
+
+func (m *mockRecurringChargeLog) SumSucceededRecurringCharges(ctx context.Context, customerID int64, since time.Time) (int, error) {
+	return m.spent, nil
+}
+
+func TestRecurringChargeIsLogged(t *testing.T) {
+	tests := []struct {
+		name       string
+		payment    *yookasa.Payment
+		wantStatus database.RecurringChargeStatus
+	}{
+		{
+			name:       "succeeded",
+			payment:    &yookasa.Payment{ID: uuid.New(), Status: "succeeded", Paid: true},
+			wantStatus: database.RecurringChargeStatusSucceeded,
+		},
+		{
+			name: "insufficient funds",
+			payment: &yookasa.Payment{ID: uuid.New(), Status: "canceled", CancellationDetails: &yookasa.CancellationDetails{
+				Party: "payment_network", Reason: "insufficient_funds",
+			}},
+			wantStatus: database.RecurringChargeStatusFailed,
+		},
+		{
+			name: "permission revoked",
+			payment: &yookasa.Payment{ID: uuid.New(), Status: "canceled", CancellationDetails: &yookasa.CancellationDetails{
+				Party: "yoo_money", Reason: "permission_revoked",
+			}},
+			wantStatus: database.RecurringChargeStatusFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paymentMethodID := uuid.New().String()
+			amount, months := 500, 1
+			customer := &database.Customer{
+				ID: 1, TelegramID: 42, RecurringEnabled: true,
+				PaymentMethodID: &paymentMethodID, RecurringAmount: &amount, RecurringMonths: &months,
+			}
+			chargeLog := &mockRecurringChargeLog{}
+			handler := &RemnawaveWebhookHandler{
+				tm:           &mockTranslationManager{},
+				telegramBot:  &mockTelegramBot{},
+				customerRepo: &mockCustomerRepo{customer: customer},
+				purchaseRepo: &mockPurchaseRepo{},
+				yookasa:      &mockYookasaClient{returnPayment: tt.payment},
+				remnawave:    &mockRemnawaveClient{},
+				chargeLog:    chargeLog,
+			}
+
+			_ = handler.processRecurringPayment(context.Background(), customer, 42, "ru")
+
+			if len(chargeLog.statuses) != 1 {
+				t.Fatalf("Expected 1 logged charge, got %d", len(chargeLog.statuses))
+			}
+			if chargeLog.statuses[0] != tt.wantStatus {
+				t.Errorf("Expected status %s, got %s", tt.wantStatus, chargeLog.statuses[0])
+			}
+			if (tt.wantStatus == database.RecurringChargeStatusFailed) != (chargeLog.errTexts[0] != nil) {
+				t.Errorf("Expected error text only for failed charges, got %v", chargeLog.errTexts[0])
+			}
+		})
+	}
+}
+
+func TestRecurringSpendingCap(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       string
+		spent       int
+		wantCharged bool
+	}{
+		{"no limit", "0", 10000, true},
+		{"within limit", "1000", 500, true},
+		{"limit exceeded", "1000", 600, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(config.InitConfig)
+			t.Setenv("MAX_RECURRING_AMOUNT_PER_DAY", tt.limit)
+			config.InitConfig()
+
+			paymentMethodID := uuid.New().String()
+			amount, months := 500, 1
+			customer := &database.Customer{
+				ID: 1, TelegramID: 42, RecurringEnabled: true,
+				PaymentMethodID: &paymentMethodID, RecurringAmount: &amount, RecurringMonths: &months,
+			}
+			customerRepo := &mockCustomerRepo{customer: customer}
+			yookasaClient := &mockYookasaClient{returnPayment: &yookasa.Payment{ID: uuid.New(), Status: "succeeded", Paid: true}}
+			chargeLog := &mockRecurringChargeLog{spent: tt.spent}
+			handler := &RemnawaveWebhookHandler{
+				tm:           &mockTranslationManager{},
+				telegramBot:  &mockTelegramBot{},
+				customerRepo: customerRepo,
+				purchaseRepo: &mockPurchaseRepo{},
+				yookasa:      yookasaClient,
+				remnawave:    &mockRemnawaveClient{},
+				chargeLog:    chargeLog,
+			}
+
+			if err := handler.processRecurringPayment(context.Background(), customer, 42, "ru"); err != nil {
+				t.Fatalf("processRecurringPayment failed: %v", err)
+			}
+
+			charged := yookasaClient.lastAmount != 0
+			if charged != tt.wantCharged {
+				t.Errorf("Expected charged=%v, got %v", tt.wantCharged, charged)
+			}
+			if !tt.wantCharged {
+				if customerRepo.disableRecurringCalls != 1 {
+					t.Errorf("Expected recurring to be disabled, got %d calls", customerRepo.disableRecurringCalls)
+				}
+				if chargeLog.statuses[0] != database.RecurringChargeStatusFailed || *chargeLog.errTexts[0] != "spending_cap_exceeded" {
+					t.Errorf("Expected charge to be logged as spending_cap_exceeded, got %s %v", chargeLog.statuses[0], chargeLog.errTexts[0])
+				}
+			}
+		})
+	}
+}
+
+// TestWinbackOfferExpiresWithClock - срок winback предложения считается по часам обработчика
+// и истекает при сдвиге времени
+func TestWinbackOfferExpiresWithClock(t *testing.T) {
+	t.Cleanup(config.InitConfig)
+	t.Setenv("WINBACK_ENABLED", "true")
+	t.Setenv("WINBACK_VALID_HOURS", "48")
+	config.InitConfig()
+
+	fakeClock := clock.NewFake(time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC))
+	clock.SetDefault(fakeClock)
+	t.Cleanup(func() { clock.SetDefault(nil) })
+
+	customerRepo := &mockCustomerRepo{customer: &database.Customer{ID: 1, TelegramID: 42}}
+	handler := &RemnawaveWebhookHandler{
+		tm:           &mockTranslationManager{},
+		telegramBot:  &mockTelegramBot{},
+		customerRepo: customerRepo,
+		purchaseRepo: &mockPurchaseRepo{},
+	}
+	handler.SetClock(fakeClock)
+
+	err := handler.dispatchEvent(context.Background(), WebhookPayload{
+		Event: "user.expired_24_hours_ago",
+		Data:  WebhookUser{UUID: "u-1", TelegramID: "42"},
+	})
+	if err != nil {
+		t.Fatalf("dispatchEvent failed: %v", err)
+	}
+
+	wantExpiresAt := fakeClock.Now().Add(48 * time.Hour)
+	if !customerRepo.winbackExpiresAt.Equal(wantExpiresAt) {
+		t.Fatalf("Expected offer to expire at %s, got %s", wantExpiresAt, customerRepo.winbackExpiresAt)
+	}
+
+	customer := &database.Customer{WinbackOfferSentAt: &customerRepo.winbackSentAt, WinbackOfferExpiresAt: &customerRepo.winbackExpiresAt}
+	fakeClock.Advance(47 * time.Hour)
+	if !database.HasActiveWinbackOffer(customer) || !IsWinbackOfferValid(customer.WinbackOfferExpiresAt, clock.Now()) {
+		t.Error("Expected offer to be active before expiry")
+	}
+	fakeClock.Advance(2 * time.Hour)
+	if database.HasActiveWinbackOffer(customer) || IsWinbackOfferValid(customer.WinbackOfferExpiresAt, clock.Now()) {
+		t.Error("Expected offer to expire after 48 hours")
+	}
+}
+
+// mockBalanceStore реализует balanceStore в памяти
+type mockBalanceStore struct {
+	balance int
+}
+
+func (m *mockBalanceStore) GetBalance(ctx context.Context, id int64) (int, error) {
+	return m.balance, nil
+}
+
+func (m *mockBalanceStore) DebitBalance(ctx context.Context, id int64, limit int) (int, error) {
+	debited := min(m.balance, limit)
+	m.balance -= debited
+	return debited, nil
+}
+
+func (m *mockBalanceStore) CreditBalance(ctx context.Context, id int64, amount int) error {
+	m.balance += amount
+	return nil
+}
+
+// TestRecurringRenewalUsesBalanceFirst - автопродление сначала покрывается с баланса,
+// с карты списывается только остаток, при неудаче баланс возвращается
+func TestRecurringRenewalUsesBalanceFirst(t *testing.T) {
+	succeeded := &yookasa.Payment{ID: uuid.New(), Status: "succeeded", Paid: true}
+	declined := &yookasa.Payment{ID: uuid.New(), Status: "canceled", CancellationDetails: &yookasa.CancellationDetails{
+		Party: "payment_network", Reason: "insufficient_funds",
+	}}
+
+	tests := []struct {
+		name           string
+		balance        int
+		payment        *yookasa.Payment
+		wantCardAmount int
+		wantBalance    int
+		wantExtended   bool
+	}{
+		{"no balance", 0, succeeded, 500, 0, true},
+		{"partial balance", 200, succeeded, 300, 0, true},
+		{"balance covers renewal", 700, nil, 0, 200, true},
+		{"card declined refunds balance", 200, declined, 300, 200, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paymentMethodID := uuid.New().String()
+			amount, months := 500, 1
+			customer := &database.Customer{
+				ID: 1, TelegramID: 42, RecurringEnabled: true,
+				PaymentMethodID: &paymentMethodID, RecurringAmount: &amount, RecurringMonths: &months,
+			}
+			yookasaClient := &mockYookasaClient{returnPayment: tt.payment}
+			remnawaveClient := &mockRemnawaveClient{}
+			balance := &mockBalanceStore{balance: tt.balance}
+			chargeLog := &mockRecurringChargeLog{}
+			handler := &RemnawaveWebhookHandler{
+				tm:           &mockTranslationManager{},
+				telegramBot:  &mockTelegramBot{},
+				customerRepo: &mockCustomerRepo{customer: customer},
+				purchaseRepo: &mockPurchaseRepo{},
+				yookasa:      yookasaClient,
+				remnawave:    remnawaveClient,
+				chargeLog:    chargeLog,
+				balance:      balance,
+			}
+
+			_ = handler.processRecurringPayment(context.Background(), customer, 42, "ru")
+
+			if yookasaClient.lastAmount != tt.wantCardAmount {
+				t.Errorf("Expected card charge %d, got %d", tt.wantCardAmount, yookasaClient.lastAmount)
+			}
+			if balance.balance != tt.wantBalance {
+				t.Errorf("Expected balance %d, got %d", tt.wantBalance, balance.balance)
+			}
+			if (remnawaveClient.callCount == 1) != tt.wantExtended {
+				t.Errorf("Expected extended=%v, got %d extension calls", tt.wantExtended, remnawaveClient.callCount)
+			}
+			if len(chargeLog.statuses) != 1 {
+				t.Fatalf("Expected 1 logged charge, got %d", len(chargeLog.statuses))
+			}
+		})
+	}
+}
+
+// mockRecurringFulfiller реализует recurringFulfiller для тестов
+type mockRecurringFulfiller struct {
+	extensions []payment.RecurringExtension
+}
+
+func (m *mockRecurringFulfiller) FulfillRecurringExtension(ctx context.Context, customer *database.Customer, ext payment.RecurringExtension) (int64, error) {
+	m.extensions = append(m.extensions, ext)
+	return 1, errors.New("panel unavailable")
+}
+
+// TestRecurringExtensionFailureAfterCharge - если деньги списаны, а панель не продлила подписку,
+// списание не считается неудачным: баланс не возвращается, а продление уходит на повтор
+func TestRecurringExtensionFailureAfterCharge(t *testing.T) {
+	paymentMethodID := uuid.New().String()
+	amount, months := 500, 1
+	customer := &database.Customer{
+		ID: 1, TelegramID: 42, RecurringEnabled: true,
+		PaymentMethodID: &paymentMethodID, RecurringAmount: &amount, RecurringMonths: &months,
+	}
+	charged := &yookasa.Payment{ID: uuid.New(), Status: "succeeded", Paid: true}
+	balance := &mockBalanceStore{balance: 200}
+	chargeLog := &mockRecurringChargeLog{}
+	fulfiller := &mockRecurringFulfiller{}
+	handler := &RemnawaveWebhookHandler{
+		tm:           &mockTranslationManager{},
+		telegramBot:  &mockTelegramBot{},
+		customerRepo: &mockCustomerRepo{customer: customer},
+		purchaseRepo: &mockPurchaseRepo{},
+		yookasa:      &mockYookasaClient{returnPayment: charged},
+		remnawave:    &mockRemnawaveClient{err: errors.New("panel unavailable")},
+		chargeLog:    chargeLog,
+		balance:      balance,
+		fulfillment:  fulfiller,
+	}
+
+	if err := handler.processRecurringPayment(context.Background(), customer, 42, "ru"); err != nil {
+		t.Fatalf("Expected paid charge not to be reported as failed, got %v", err)
+	}
+	if len(chargeLog.statuses) != 1 || chargeLog.statuses[0] != database.RecurringChargeStatusSucceeded {
+		t.Errorf("Expected charge logged as succeeded, got %v", chargeLog.statuses)
+	}
+	if balance.balance != 0 {
+		t.Errorf("Expected balance part to stay spent, got balance %d", balance.balance)
+	}
+	if len(fulfiller.extensions) != 1 {
+		t.Fatalf("Expected extension to be scheduled for retry, got %d", len(fulfiller.extensions))
+	}
+	ext := fulfiller.extensions[0]
+	if ext.Amount != 500 || ext.Period.Months != 1 || ext.Payment == nil || ext.Payment.ID != charged.ID {
+		t.Errorf("Expected retry for the full 500 RUB renewal paid by %s, got %+v", charged.ID, ext)
+	}
+}
+
+// TestProcessPendingEventsSkipsClaimedEvent - событие, которое обрабатывается сразу после получения,
+// не берёт параллельный запуск повторной обработки
+func TestProcessPendingEventsSkipsClaimedEvent(t *testing.T) {
+	store := newMockWebhookEventStore()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	claimUntil := now.Add(webhookEventClaimTimeout)
+	if _, _, err := store.CreateIfNotExists(context.Background(), "evt", "user.expires_in_24_hours", []byte(testExpiresIn24HoursBody), claimUntil); err != nil {
+		t.Fatal(err)
+	}
+	telegramBot := &mockTelegramBot{}
+	handler := &RemnawaveWebhookHandler{
+		tm:           &mockTranslationManager{},
+		telegramBot:  telegramBot,
+		customerRepo: &mockCustomerRepo{},
+		purchaseRepo: &mockPurchaseRepo{},
+		eventStore:   store,
+		clock:        clock.NewFake(now),
+	}
+
+	if err := handler.ProcessPendingEvents(context.Background()); err != nil {
+		t.Fatalf("ProcessPendingEvents failed: %v", err)
+	}
+	if telegramBot.sendMessageCalls != 0 || store.processedCalls != 0 {
+		t.Fatalf("Expected claimed event to be skipped, got %d messages, %d processed", telegramBot.sendMessageCalls, store.processedCalls)
+	}
+
+	handler.clock = clock.NewFake(claimUntil.Add(time.Second))
+	if err := handler.ProcessPendingEvents(context.Background()); err != nil {
+		t.Fatalf("ProcessPendingEvents failed: %v", err)
+	}
+	if telegramBot.sendMessageCalls != 1 || store.processedCalls != 1 {
+		t.Errorf("Expected event to be processed after claim timeout, got %d messages, %d processed", telegramBot.sendMessageCalls, store.processedCalls)
+	}
+}
@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// rotationWindow период, за который считается лимит самостоятельных смен ссылки
+const rotationWindow = 30 * 24 * time.Hour
+
+// canRotateLink возвращает true, если клиенту можно показать кнопку смены ссылки:
+// самостоятельная смена включена и есть активная подписка со ссылкой
+func canRotateLink(customer *database.Customer, limit int, now time.Time) bool {
+	return limit > 0 &&
+		customer.SubscriptionLink != nil && *customer.SubscriptionLink != "" &&
+		customer.ExpireAt != nil && customer.ExpireAt.After(now)
+}
+
+// rotateLinkButtonRow возвращает строку с кнопкой смены ссылки для экрана подключения или nil
+func (h ProfileHandlers) rotateLinkButtonRow(customer *database.Customer, langCode string) []models.InlineKeyboardButton {
+	if h.links == nil || !canRotateLink(customer, config.SubscriptionRotationLimit(), clock.Now()) {
+		return nil
+	}
+	return []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "rotate_link_button"), CallbackData: CallbackRotateLink}}
+}
+
+// RotateLinkCallbackHandler показывает предупреждение перед сменой ссылки: старая ссылка перестанет работать
+// во всех приложениях. При исчерпанном лимите предлагает обратиться в поддержку
+func (h ProfileHandlers) RotateLinkCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	customer, left, ok := h.rotationCustomer(ctx, callback.Chat.ID)
+	if !ok {
+		return
+	}
+
+	text := h.translation.GetTextTemplate(langCode, "rotate_link_confirm", map[string]interface{}{"left": left})
+	var keyboard [][]models.InlineKeyboardButton
+	if left > 0 {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: h.translation.GetText(langCode, "rotate_link_confirm_button"), CallbackData: CallbackRotateLinkConfirm},
+		})
+	} else {
+		text = h.translation.GetTextTemplate(langCode, "rotate_link_limit", map[string]interface{}{"limit": config.SubscriptionRotationLimit()})
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackConnect},
+	})
+
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      callback.Chat.ID,
+		MessageID:   callback.ID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        text,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending rotate link confirmation", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+	}
+}
+
+// RotateLinkConfirmCallbackHandler меняет ссылку подписки по запросу клиента и показывает новую
+func (h ProfileHandlers) RotateLinkConfirmCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	customer, left, ok := h.rotationCustomer(ctx, callback.Chat.ID)
+	if !ok || left <= 0 {
+		return
+	}
+
+	rotateErr := h.links.Rotate(ctx, customer, nil)
+	// При ошибке ссылка в customer остаётся прежней и продолжает работать
+	link := subscriptionPageURL(ctx, h.purchaseRepository, customer, langCode)
+	text := h.translation.GetText(langCode, "rotate_link_failed")
+	if rotateErr != nil {
+		slog.ErrorContext(ctx, "Error rotating subscription link", "error", rotateErr, "customerId", utils.MaskHalfInt64(customer.ID))
+	} else {
+		text = h.translation.GetText(langCode, "rotate_link_done") + buildConnectText(customer, link, langCode)
+	}
+
+	isDisabled := true
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    callback.Chat.ID,
+		MessageID: callback.ID,
+		ParseMode: models.ParseModeHTML,
+		Text:      text,
+		LinkPreviewOptions: &models.LinkPreviewOptions{
+			IsDisabled: &isDisabled,
+		},
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: h.rotatedLinkKeyboard(link, langCode)},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending rotated link", "error", err)
+	}
+}
+
+// RotateLinkCommandHandler обрабатывает команду админа /rotate <telegram_id>: принудительно меняет ссылку
+// подписки, например при подозрении на утечку. В лимит клиента не входит, пишется в журнал действий админа
+func (h AdminHandlers) RotateLinkCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	args := strings.Fields(update.Message.Text)
+	if len(args) != 2 {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Использование: <code>/rotate &lt;telegram_id&gt;</code>")
+		return
+	}
+	telegramID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Неверный telegram_id")
+		return
+	}
+
+	customer, ok := h.findSupportCustomer(ctx, b, update.Message.Chat.ID, telegramID)
+	if !ok {
+		return
+	}
+	if customer.SubscriptionLink == nil || *customer.SubscriptionLink == "" {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ У пользователя нет подписки в панели")
+		return
+	}
+
+	adminID := update.Message.From.ID
+	if h.links == nil {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Смена ссылок не настроена")
+		return
+	}
+	if err := h.links.Rotate(ctx, customer, &adminID); err != nil {
+		slog.ErrorContext(ctx, "Error rotating subscription link by admin", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Не удалось перевыпустить ссылку: "+utils.EscapeHTML(err.Error()))
+		return
+	}
+	h.recordAudit(ctx, adminID, customer.ID, database.AuditActionLinkRotated, "")
+
+	langCode := customer.Language
+	link := subscriptionPageURL(ctx, h.links.purchases, customer, langCode)
+	isDisabled := true
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    customer.TelegramID,
+		Text:      h.translation.GetText(langCode, "rotate_link_forced") + buildConnectText(customer, link, langCode),
+		ParseMode: models.ParseModeHTML,
+		LinkPreviewOptions: &models.LinkPreviewOptions{
+			IsDisabled: &isDisabled,
+		},
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: h.rotatedLinkKeyboard(link, langCode)},
+	})
+	result := "✅ Ссылка подписки перевыпущена, пользователь получил новую"
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending rotated link to customer", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		result = "⚠️ Ссылка подписки перевыпущена, но отправить её пользователю не удалось"
+	}
+	h.sendAdminText(ctx, b, update.Message.Chat.ID, result+"\n\n"+h.supportCard(ctx, customer))
+}
+
+// rotationCustomer находит клиента и считает оставшиеся самостоятельные смены ссылки.
+// ok=false, если смена ссылки клиенту недоступна
+func (h ProfileHandlers) rotationCustomer(ctx context.Context, telegramID int64) (customer *database.Customer, left int, ok bool) {
+	limit := config.SubscriptionRotationLimit()
+	if h.links == nil {
+		return nil, 0, false
+	}
+	customer, err := h.customerRepository.FindByTelegramId(ctx, telegramID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for link rotation", "error", err)
+		return nil, 0, false
+	}
+	if !canRotateLink(customer, limit, clock.Now()) {
+		return nil, 0, false
+	}
+
+	used, err := h.links.SelfServiceCount(ctx, customer.ID, clock.Now().Add(-rotationWindow))
+	if err != nil {
+		slog.ErrorContext(ctx, "Error counting link rotations", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		return nil, 0, false
+	}
+	return customer, max(limit-used, 0), true
+}
+
+// subscriptionRevoker перевыпуск подписки в панели
+type subscriptionRevoker interface {
+	RevokeSubscription(ctx context.Context, telegramID int64) (string, error)
+}
+
+// customerUpdater обновление полей клиента
+type customerUpdater interface {
+	UpdateFields(ctx context.Context, id int64, updates map[string]interface{}) error
+}
+
+// rotationLog журнал смен ссылки подписки
+type rotationLog interface {
+	Record(ctx context.Context, customerID int64, adminID *int64) error
+	CountSelfService(ctx context.Context, customerID int64, since time.Time) (int, error)
+}
+
+// LinkRotator перевыпускает ссылку подписки. Используется экраном подключения и командой админа /rotate.
+// purchases нужен, чтобы показать новую ссылку по шаблону тарифа клиента
+type LinkRotator struct {
+	panel     subscriptionRevoker
+	customers customerUpdater
+	rotations rotationLog
+	purchases lastPurchaseFinder
+}
+
+func NewLinkRotator(panel subscriptionRevoker, customers customerUpdater, rotations rotationLog, purchases lastPurchaseFinder) *LinkRotator {
+	return &LinkRotator{panel: panel, customers: customers, rotations: rotations, purchases: purchases}
+}
+
+// Rotate перевыпускает подписку в панели и сохраняет новую ссылку в customer.
+// adminID nil — смену запросил сам клиент
+func (r *LinkRotator) Rotate(ctx context.Context, customer *database.Customer, adminID *int64) error {
+	link, err := r.panel.RevokeSubscription(ctx, customer.TelegramID)
+	if err != nil {
+		return fmt.Errorf("revoke subscription: %w", err)
+	}
+	if err := r.customers.UpdateFields(ctx, customer.ID, map[string]interface{}{"subscription_link": link}); err != nil {
+		return fmt.Errorf("save subscription link: %w", err)
+	}
+	customer.SubscriptionLink = &link
+
+	// Ссылка уже сменилась, поэтому ошибка записи в журнал не отменяет результат
+	if err := r.rotations.Record(ctx, customer.ID, adminID); err != nil {
+		slog.ErrorContext(ctx, "Error recording link rotation", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+	}
+	slog.InfoContext(ctx, "Subscription link rotated", "customerId", utils.MaskHalfInt64(customer.ID), "byAdmin", adminID != nil)
+	return nil
+}
+
+// SelfServiceCount возвращает, сколько раз клиент сам менял ссылку начиная с since
+func (r *LinkRotator) SelfServiceCount(ctx context.Context, customerID int64, since time.Time) (int, error) {
+	return r.rotations.CountSelfService(ctx, customerID, since)
+}
+
+// rotatedLinkKeyboard клавиатура под новой ссылкой link: подключение через мини-приложение, если оно включено
+func (h base) rotatedLinkKeyboard(link, langCode string) [][]models.InlineKeyboardButton {
+	var keyboard [][]models.InlineKeyboardButton
+	if config.IsWepAppLinkEnabled() && link != "" {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "connect_button"),
+			WebApp: &models.WebAppInfo{URL: link}}})
+	}
+	return append(keyboard, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack}})
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+func TestCanRotateLink(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	link := "https://sub.example.com/abc"
+	empty := ""
+	active := now.Add(24 * time.Hour)
+	expired := now.Add(-time.Hour)
+
+	tests := []struct {
+		name     string
+		customer database.Customer
+		limit    int
+		want     bool
+	}{
+		{"active subscription", database.Customer{SubscriptionLink: &link, ExpireAt: &active}, 3, true},
+		{"self-service disabled", database.Customer{SubscriptionLink: &link, ExpireAt: &active}, 0, false},
+		{"expired subscription", database.Customer{SubscriptionLink: &link, ExpireAt: &expired}, 3, false},
+		{"no link", database.Customer{ExpireAt: &active}, 3, false},
+		{"empty link", database.Customer{SubscriptionLink: &empty, ExpireAt: &active}, 3, false},
+		{"never subscribed", database.Customer{SubscriptionLink: &link}, 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canRotateLink(&tt.customer, tt.limit, now); got != tt.want {
+				t.Errorf("canRotateLink() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
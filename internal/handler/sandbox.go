@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+// SandboxPaymentCallbackHandler обрабатывает кнопку админа "Тестовая оплата": создаёт покупку за 0 ₽
+// с пометкой is_test и проводит её через полную выдачу подписки без реального платежа
+func (h PaymentHandlers) SandboxPaymentCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	chatID := update.CallbackQuery.From.ID
+	if !config.IsSandboxPaymentsEnabled() {
+		h.sendAdminText(ctx, b, chatID, "❌ Тестовые оплаты выключены (SANDBOX_PAYMENTS_ENABLED)")
+		return
+	}
+
+	callbackQuery := parseCallbackData(update.CallbackQuery.Data)
+	period, ok := config.ParsePeriod(callbackQuery["m"])
+	if !ok || !config.IsSupportedTariffPeriod(callbackQuery["n"], period) {
+		h.sendAdminText(ctx, b, chatID, "❌ Неверный период тестовой оплаты")
+		return
+	}
+
+	// Лимит устройств берётся из тарифа при выдаче подписки, как и для обычной покупки
+	var tariffName *string
+	if name := callbackQuery["n"]; name != "" {
+		if config.GetTariffByName(name) == nil {
+			h.sendAdminText(ctx, b, chatID, "❌ Тариф не найден")
+			return
+		}
+		tariffName = &name
+	}
+
+	customer, err := h.customerRepository.FindByTelegramId(ctx, chatID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for sandbox payment", "error", err)
+		h.sendAdminText(ctx, b, chatID, "❌ Пользователь не найден")
+		return
+	}
+
+	ctxWithUsername := context.WithValue(ctx, "username", update.CallbackQuery.From.Username)
+	purchaseId, err := h.paymentService.CreateSandboxPurchase(ctxWithUsername, period, customer, tariffName, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error creating sandbox purchase", "error", err)
+		h.sendAdminText(ctx, b, chatID, "❌ Не удалось провести тестовую оплату")
+		return
+	}
+
+	h.sendAdminText(ctx, b, chatID, fmt.Sprintf("🧪 Тестовая покупка <code>#%d</code> проведена. В статистику и сверку она не попадает", purchaseId))
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/remnawave"
+	"remnawave-tg-shop-bot/utils"
+)
+
+const (
+	// serverStatusFetchTimeout ограничивает запросы в Remnawave, чтобы экран статуса не ждал медленную панель
+	serverStatusFetchTimeout = 5 * time.Second
+	// serverStatusCacheKey общий для админки и клиентов: сводка не зависит от пользователя
+	serverStatusCacheKey = "server_status"
+	// adminServerStatusRefresh callback кнопки "Обновить": запрашивает панель в обход кеша
+	adminServerStatusRefresh = "admin_server_status?refresh=1"
+)
+
+// loadServerStatus возвращает статус серверов через короткий кеш SERVER_STATUS_CACHE_SECONDS.
+// refresh запрашивает панель в обход кеша
+func loadServerStatus(ctx context.Context, panel serverStatusGetter, cache stateCache, refresh bool) (*remnawave.ServerStatus, error) {
+	if !refresh {
+		if cached, ok := cache.GetString(serverStatusCacheKey); ok {
+			var status remnawave.ServerStatus
+			if err := json.Unmarshal([]byte(cached), &status); err == nil {
+				return &status, nil
+			}
+		}
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, serverStatusFetchTimeout)
+	defer cancel()
+	status, err := panel.ServerStatus(ctxWithTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl := config.ServerStatusCacheSeconds(); ttl > 0 {
+		if data, err := json.Marshal(status); err == nil {
+			cache.SetString(serverStatusCacheKey, string(data), ttl)
+		}
+	}
+	return status, nil
+}
+
+// AdminServerStatusCallback показывает админу ноды панели, пользователей онлайн и трафик
+func (h AdminHandlers) AdminServerStatusCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	refresh := parseCallbackData(update.CallbackQuery.Data)["refresh"] == "1"
+	var text string
+	status, err := loadServerStatus(ctx, h.serverStatus, h.cache, refresh)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching server status", "error", err)
+		text = "❌ Не удалось получить статус серверов из панели"
+	} else {
+		text = FormatServerStatus(status)
+	}
+
+	msg := update.CallbackQuery.Message.Message
+	err = editLongMessage(ctx, b, &bot.EditMessageTextParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: "🔄 Обновить", CallbackData: adminServerStatusRefresh}},
+			{{Text: "🔙 Назад", CallbackData: "admin_back"}},
+		}},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing server status message", "error", err)
+	}
+}
+
+// FormatServerStatus подробный статус серверов для админа: ноды, пользователи онлайн и трафик
+func FormatServerStatus(status *remnawave.ServerStatus) string {
+	var sb strings.Builder
+	sb.WriteString("🖥 <b>Статус серверов</b>\n\n")
+
+	online, total := status.OnlineNodes()
+	sb.WriteString(fmt.Sprintf("Ноды: <b>%d</b> из <b>%d</b> работают\n", online, total))
+	sb.WriteString(fmt.Sprintf("Онлайн: <b>%d</b>, за сутки: <b>%d</b>\n", status.OnlineNow, status.OnlineLastDay))
+	sb.WriteString(fmt.Sprintf("Трафик сегодня: <b>%s</b>, вчера: %s, за месяц: %s\n",
+		bandwidthOrDash(status.BandwidthToday), bandwidthOrDash(status.BandwidthYesterday), bandwidthOrDash(status.BandwidthMonth)))
+
+	if len(status.Nodes) == 0 {
+		sb.WriteString("\nВ панели нет нод")
+	}
+	for _, node := range status.Nodes {
+		sb.WriteString(fmt.Sprintf("\n%s %s<b>%s</b>", nodeStatusMark(node), countryFlag(node.CountryCode), utils.EscapeHTML(node.Name)))
+		if node.Disabled {
+			sb.WriteString(" — выключена")
+			continue
+		}
+		traffic := locale.FormatTraffic("ru", node.TrafficUsedBytes)
+		if node.TrafficLimitBytes > 0 {
+			traffic += " / " + locale.FormatTraffic("ru", node.TrafficLimitBytes)
+		}
+		sb.WriteString(fmt.Sprintf(" — онлайн %d, трафик %s", node.UsersOnline, traffic))
+		if !node.Online && node.Message != "" {
+			sb.WriteString("\n   <i>" + utils.EscapeHTML(node.Message) + "</i>")
+		}
+	}
+
+	sb.WriteString("\n\nОбновлено: " + status.FetchedAt.Format("02.01.2006 15:04:05"))
+	return sb.String()
+}
+
+// ServerStatusCallbackHandler показывает клиенту краткий статус серверов: какие ноды работают, без нагрузки и трафика
+func (h ProfileHandlers) ServerStatusCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	langCode := update.CallbackQuery.From.LanguageCode
+
+	var text string
+	status, err := loadServerStatus(ctx, h.serverStatus, h.cache, false)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error fetching server status", "error", err)
+		text = h.translation.GetText(langCode, "server_status_unavailable")
+	} else {
+		text = formatPublicServerStatus(h.translation, langCode, status)
+	}
+
+	var keyboard [][]models.InlineKeyboardButton
+	if url := config.ServerStatusURL(); url != "" {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "server_status_page_button"), URL: url}})
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack}})
+
+	msg := update.CallbackQuery.Message.Message
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      msg.Chat.ID,
+		MessageID:   msg.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing server status message", "error", err)
+	}
+}
+
+// formatPublicServerStatus краткий статус для клиентов: выключенные ноды не показываются
+func formatPublicServerStatus(tm translator, langCode string, status *remnawave.ServerStatus) string {
+	var nodes []string
+	for _, node := range status.Nodes {
+		if node.Disabled {
+			continue
+		}
+		nodes = append(nodes, fmt.Sprintf("%s %s%s", nodeStatusMark(node), countryFlag(node.CountryCode), utils.EscapeHTML(node.Name)))
+	}
+	online, total := status.OnlineNodes()
+	return tm.GetTextTemplate(langCode, "server_status_public", map[string]interface{}{
+		"online": online,
+		"total":  total,
+		"nodes":  utils.RawHTML(strings.Join(nodes, "\n")),
+	})
+}
+
+func nodeStatusMark(node remnawave.NodeStatus) string {
+	switch {
+	case node.Disabled:
+		return "⚪️"
+	case node.Online:
+		return "🟢"
+	default:
+		return "🔴"
+	}
+}
+
+// countryFlag флаг страны по двухбуквенному коду ("DE" → 🇩🇪) с пробелом после. Пусто для неизвестного кода
+// и XX, которым панель помечает ноды без страны
+func countryFlag(code string) string {
+	code = strings.ToUpper(code)
+	if len(code) != 2 || code == "XX" || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return ""
+	}
+	return string([]rune{rune(code[0]-'A') + 0x1F1E6, rune(code[1]-'A') + 0x1F1E6}) + " "
+}
+
+func bandwidthOrDash(value string) string {
+	if value == "" {
+		return "—"
+	}
+	return utils.EscapeHTML(value)
+}
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/remnawave"
+	"remnawave-tg-shop-bot/internal/translation"
+)
+
+type fakeServerStatus struct {
+	status *remnawave.ServerStatus
+	err    error
+	calls  int
+}
+
+func (f *fakeServerStatus) ServerStatus(context.Context) (*remnawave.ServerStatus, error) {
+	f.calls++
+	return f.status, f.err
+}
+
+func testServerStatus() *remnawave.ServerStatus {
+	return &remnawave.ServerStatus{
+		Nodes: []remnawave.NodeStatus{
+			{Name: "Frankfurt", CountryCode: "DE", Online: true, UsersOnline: 12, TrafficUsedBytes: 5 << 30, TrafficLimitBytes: 100 << 30},
+			{Name: "<Amsterdam>", CountryCode: "NL", UsersOnline: 0, Message: "Connection refused"},
+			{Name: "Reserve", CountryCode: "XX", Disabled: true},
+		},
+		OnlineNow:      12,
+		OnlineLastDay:  40,
+		BandwidthToday: "1.5 GiB",
+		FetchedAt:      time.Date(2025, 3, 1, 10, 30, 0, 0, time.UTC),
+	}
+}
+
+func TestFormatServerStatus(t *testing.T) {
+	text := FormatServerStatus(testServerStatus())
+	for _, want := range []string{
+		"Ноды: <b>1</b> из <b>2</b> работают",
+		"Онлайн: <b>12</b>, за сутки: <b>40</b>",
+		"Трафик сегодня: <b>1.5 GiB</b>, вчера: —",
+		"🟢 🇩🇪 <b>Frankfurt</b> — онлайн 12, трафик 5 ГБ / 100 ГБ",
+		"🔴 🇳🇱 <b>&lt;Amsterdam&gt;</b>",
+		"<i>Connection refused</i>",
+		"⚪️ <b>Reserve</b> — выключена",
+		"Обновлено: 01.03.2025 10:30:00",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected status to contain %q, got:\n%s", want, text)
+		}
+	}
+}
+
+func TestLoadServerStatusUsesCache(t *testing.T) {
+	panel := &fakeServerStatus{status: testServerStatus()}
+	cache := newFakeCache()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		status, err := loadServerStatus(ctx, panel, cache, false)
+		if err != nil || status.OnlineNow != 12 || len(status.Nodes) != 3 {
+			t.Fatalf("Unexpected status %+v, error %v", status, err)
+		}
+	}
+	if panel.calls != 1 {
+		t.Errorf("Expected one panel request with cache, got %d", panel.calls)
+	}
+
+	if _, err := loadServerStatus(ctx, panel, cache, true); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if panel.calls != 2 {
+		t.Errorf("Expected refresh to bypass cache, got %d requests", panel.calls)
+	}
+
+	panel.err = errors.New("panel unavailable")
+	if _, err := loadServerStatus(ctx, panel, newFakeCache(), false); err == nil {
+		t.Error("Expected panel error without cached status")
+	}
+}
+
+// nodesTranslator возвращает список нод из шаблона, чтобы проверить, что видит клиент
+type nodesTranslator struct {
+	fakeTranslator
+}
+
+func (nodesTranslator) GetTextTemplate(langCode, key string, data map[string]interface{}) string {
+	return fmt.Sprintf("%v/%v\n%v", data["online"], data["total"], data["nodes"])
+}
+
+func TestFormatPublicServerStatusHidesDisabledNodes(t *testing.T) {
+	text := formatPublicServerStatus(nodesTranslator{}, "en", testServerStatus())
+	want := "1/2\n🟢 🇩🇪 Frankfurt\n🔴 🇳🇱 &lt;Amsterdam&gt;"
+	if text != want {
+		t.Errorf("Expected public status %q, got %q", want, text)
+	}
+}
+
+// Имя ноды экранируется один раз: шаблон перевода не экранирует уже готовый список повторно
+func TestFormatPublicServerStatusEscapesNodeNamesOnce(t *testing.T) {
+	tm := translation.GetInstance()
+	if err := tm.InitTranslations("../../translations", "ru"); err != nil {
+		t.Fatalf("Failed to load translations: %v", err)
+	}
+
+	text := formatPublicServerStatus(tm, "ru", testServerStatus())
+	if !strings.Contains(text, "🔴 🇳🇱 &lt;Amsterdam&gt;") || strings.Contains(text, "&amp;lt;") {
+		t.Errorf("Expected node name escaped exactly once, got:\n%s", text)
+	}
+	if !strings.Contains(text, "<b>") {
+		t.Errorf("Expected template markup to stay intact, got:\n%s", text)
+	}
+}
@@ -11,18 +11,22 @@ import (
 	"github.com/go-telegram/bot/models"
 	"log/slog"
 
+	"remnawave-tg-shop-bot/internal/activity"
 	"remnawave-tg-shop-bot/internal/config"
 	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/events"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/promo"
 	"remnawave-tg-shop-bot/utils"
 )
 
-func (h Handler) StartCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h ProfileHandlers) StartCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	ctxWithTime, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	langCode := update.Message.From.LanguageCode
 	existingCustomer, err := h.customerRepository.FindByTelegramId(ctx, update.Message.Chat.ID)
 	if err != nil {
-		slog.Error("error finding customer by telegram id", "error", err)
+		slog.ErrorContext(ctx, "error finding customer by telegram id", "error", err)
 		return
 	}
 
@@ -30,9 +34,10 @@ func (h Handler) StartCommandHandler(ctx context.Context, b *bot.Bot, update *mo
 		existingCustomer, err = h.customerRepository.Create(ctxWithTime, &database.Customer{
 			TelegramID: update.Message.Chat.ID,
 			Language:   langCode,
+			Source:     parseStartSource(update.Message.Text),
 		})
 		if err != nil {
-			slog.Error("error creating customer", "error", err)
+			slog.ErrorContext(ctx, "error creating customer", "error", err)
 			return
 		}
 
@@ -42,30 +47,64 @@ func (h Handler) StartCommandHandler(ctx context.Context, b *bot.Bot, update *mo
 				code := strings.TrimPrefix(arg, "ref_")
 				referrerId, err := strconv.ParseInt(code, 10, 64)
 				if err != nil {
-					slog.Error("error parsing referrer id", "error", err)
+					slog.ErrorContext(ctx, "error parsing referrer id", "error", err)
 					return
 				}
 				_, err = h.customerRepository.FindByTelegramId(ctx, referrerId)
 				if err == nil {
 					_, err := h.referralRepository.Create(ctx, referrerId, existingCustomer.TelegramID)
 					if err != nil {
-						slog.Error("error creating referral", "error", err)
+						slog.ErrorContext(ctx, "error creating referral", "error", err)
 						return
 					}
-					slog.Info("referral created", "referrerId", utils.MaskHalfInt64(referrerId), "refereeId", utils.MaskHalfInt64(existingCustomer.TelegramID))
+					slog.InfoContext(ctx, "referral created", "referrerId", utils.MaskHalfInt64(referrerId), "refereeId", utils.MaskHalfInt64(existingCustomer.TelegramID))
 				}
 			}
 		}
 	}
 	// Язык не обновляем — используем DEFAULT_LANGUAGE из конфига
+	activity.Record(ctx, update.Message.Chat.ID, database.FunnelEventStart)
+	defer h.deleteUserMessage(ctx, b, update.Message, config.CleanChatFull)
+
+	// Ссылка-приглашение на место в команде. Оплаченные места активируются и при выключенных командах
+	if code, ok := teamStartCode(update.Message.Text); ok && h.teamService != nil {
+		h.redeemTeamSeat(ctx, b, update.Message.Chat.ID, existingCustomer, code, langCode)
+		return
+	}
+
+	// Ссылка с промокодом: клиент уже создан, промокод применяется сразу
+	if code, channel, ok := promoStartCode(update.Message.Text); ok && h.promoLinks != nil {
+		h.promoLinks.RedeemPromoLink(ctx, b, existingCustomer, update.Message.From, code, channel)
+		return
+	}
+
+	// Рекламная ссылка на триал: активируем сразу и показываем инструкцию подключения
+	if isTrialStartLink(update.Message.Text) && config.TrialDays() > 0 {
+		h.activateTrialFromStart(ctx, b, update)
+		return
+	}
+
+	// Рекламная ссылка на оплату тарифа: сразу показываем способы оплаты. Устаревшая ссылка ведёт в меню тарифов
+	if strings.HasPrefix(startPayload(update.Message.Text), buyStartPrefix) && h.checkout != nil {
+		activity.Record(ctx, update.Message.Chat.ID, database.FunnelEventViewedPrices)
+		if tariff, month, _, ok := buyStartLink(update.Message.Text); ok {
+			publishMenuView(ctx, update.Message.Chat.ID, menuBuy)
+			h.checkout.SendCheckout(ctx, b, update.Message.Chat.ID, langCode, month, tariff)
+			return
+		}
+		slog.WarnContext(ctx, "Invalid tariff deep link, showing tariffs menu", "payload", startPayload(update.Message.Text))
+	}
 
 	// Проверяем параметр deep link для перехода к тарифам
 	if strings.Contains(update.Message.Text, "tariffs") || strings.Contains(update.Message.Text, "buy") {
+		activity.Record(ctx, update.Message.Chat.ID, database.FunnelEventViewedPrices)
+		publishMenuView(ctx, update.Message.Chat.ID, menuBuy)
 		h.sendTariffsMenu(ctx, b, update.Message.Chat.ID, langCode)
 		return
 	}
 
-	inlineKeyboard := h.buildStartKeyboard(existingCustomer, langCode)
+	publishMenuView(ctx, update.Message.Chat.ID, menuMain)
+	inlineKeyboard := h.buildStartKeyboard(ctx, existingCustomer, langCode)
 
 	m, err := b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID: update.Message.Chat.ID,
@@ -76,7 +115,7 @@ func (h Handler) StartCommandHandler(ctx context.Context, b *bot.Bot, update *mo
 	})
 
 	if err != nil {
-		slog.Error("Error sending removing reply keyboard", "error", err)
+		slog.ErrorContext(ctx, "Error sending removing reply keyboard", "error", err)
 		return
 	}
 
@@ -86,25 +125,100 @@ func (h Handler) StartCommandHandler(ctx context.Context, b *bot.Bot, update *mo
 	})
 
 	if err != nil {
-		slog.Error("Error deleting message", "error", err)
+		slog.ErrorContext(ctx, "Error deleting message", "error", err)
 		return
 	}
 
-	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID:    update.Message.Chat.ID,
-		ParseMode: models.ParseModeHTML,
-		ReplyMarkup: models.InlineKeyboardMarkup{
-			InlineKeyboard: inlineKeyboard,
-		},
-		Text: h.translation.GetText(langCode, "greeting"),
-	})
+	_, err = h.sendStartMenu(ctx, b, update.Message.Chat.ID, langCode, h.startText(ctx, existingCustomer, langCode), inlineKeyboard)
 	if err != nil {
-		slog.Error("Error sending /start message", "error", err)
+		slog.ErrorContext(ctx, "Error sending /start message", "error", err)
 	}
 }
 
+// Экраны бота в событиях продуктовой аналитики
+const (
+	menuMain = "main"
+	menuBuy  = "buy"
+)
+
+// publishMenuView публикует показ экрана menu для продуктовой аналитики
+func publishMenuView(ctx context.Context, telegramID int64, menu string) {
+	events.Publish(ctx, events.MenuViewed(events.MenuView{TelegramID: telegramID, Menu: menu}))
+}
+
+// utmPrefix префикс рекламного deep link: /start utm_<источник>
+const utmPrefix = "utm_"
+
+// maxSourceLength соответствует размеру колонки customer.source
+const maxSourceLength = 64
+
+// parseStartSource определяет источник привлечения по параметру /start.
+// Реферальная ссылка (ref_<id>) атрибутируется как referral, рекламная (utm_<источник> или trial_<источник>) — как источник,
+// ссылка с промокодом (promo_<код>__<канал>) — как канал, если он указан, ссылка на оплату тарифа
+// (buy_<тариф>_<период>__<канал>) — как канал или, без канала, как сама ссылка.
+// Атрибуция first-touch: источник сохраняется только при создании клиента и дальше не меняется
+func parseStartSource(text string) *string {
+	args := strings.Fields(text)
+	if len(args) < 2 {
+		return nil
+	}
+	payload := args[1]
+
+	if strings.HasPrefix(payload, "ref_") {
+		source := database.SourceReferral
+		return &source
+	}
+
+	var source string
+	if promoPayload, ok := strings.CutPrefix(payload, promo.StartPrefix); ok {
+		_, source, _ = strings.Cut(promoPayload, promo.ChannelSeparator)
+	} else if strings.HasPrefix(payload, buyStartPrefix) {
+		var channel string
+		if source, channel, ok = strings.Cut(payload, promo.ChannelSeparator); ok {
+			source = channel
+		}
+	} else if source, ok = strings.CutPrefix(payload, utmPrefix); !ok {
+		if source, ok = strings.CutPrefix(payload, trialStartPayload+"_"); !ok {
+			return nil
+		}
+	}
+	source = sanitizeSource(source)
+	if source == "" || source == database.SourceReferral {
+		return nil
+	}
+	return &source
+}
+
+// startPayload параметр команды /start или пустая строка
+func startPayload(text string) string {
+	args := strings.Fields(text)
+	if len(args) < 2 {
+		return ""
+	}
+	return args[1]
+}
+
+// sanitizeSource приводит источник к нижнему регистру, оставляет только латиницу, цифры, "_" и "-"
+// и обрезает до размера колонки
+func sanitizeSource(source string) string {
+	source = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return -1
+		}
+	}, source)
+	if len(source) > maxSourceLength {
+		source = source[:maxSourceLength]
+	}
+	return source
+}
+
 // sendTariffsMenu отправляет меню тарифов напрямую (для deep link)
-func (h Handler) sendTariffsMenu(ctx context.Context, b *bot.Bot, chatID int64, langCode string) {
+func (h ProfileHandlers) sendTariffsMenu(ctx context.Context, b *bot.Bot, chatID int64, langCode string) {
 	tariffs := config.GetTariffs()
 
 	var keyboard [][]models.InlineKeyboardButton
@@ -122,37 +236,40 @@ func (h Handler) sendTariffsMenu(ctx context.Context, b *bot.Bot, chatID int64,
 	} else if len(tariffs) == 1 {
 		// Один тариф - показываем сразу цены
 		tariff := tariffs[0]
+		if dayButtons := dayPlanButtons(tariff, langCode, h.translation); len(dayButtons) > 0 {
+			keyboard = append(keyboard, dayButtons)
+		}
 		if tariff.Price1 > 0 {
 			keyboard = append(keyboard, []models.InlineKeyboardButton{
-				{Text: h.translation.GetTextTemplate(langCode, "month_1", map[string]interface{}{"price": tariff.Price1}),
-					CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 1, tariff.Price1, tariff.Name)},
+				{Text: h.translation.GetTextTemplate(langCode, "month_1", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price1))}),
+					CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 1, tariff.Name)},
 			})
 		}
 		if tariff.Price3 > 0 {
 			keyboard = append(keyboard, []models.InlineKeyboardButton{
-				{Text: h.translation.GetTextTemplate(langCode, "month_3", map[string]interface{}{"price": tariff.Price3}),
-					CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 3, tariff.Price3, tariff.Name)},
+				{Text: h.translation.GetTextTemplate(langCode, "month_3", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price3))}),
+					CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 3, tariff.Name)},
 			})
 		}
 		if tariff.Price6 > 0 {
 			keyboard = append(keyboard, []models.InlineKeyboardButton{
-				{Text: h.translation.GetTextTemplate(langCode, "month_6", map[string]interface{}{"price": tariff.Price6}),
-					CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 6, tariff.Price6, tariff.Name)},
+				{Text: h.translation.GetTextTemplate(langCode, "month_6", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price6))}),
+					CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 6, tariff.Name)},
 			})
 		}
 		if tariff.Price12 > 0 {
 			keyboard = append(keyboard, []models.InlineKeyboardButton{
-				{Text: h.translation.GetTextTemplate(langCode, "month_12", map[string]interface{}{"price": tariff.Price12}),
-					CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 12, tariff.Price12, tariff.Name)},
+				{Text: h.translation.GetTextTemplate(langCode, "month_12", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price12))}),
+					CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 12, tariff.Name)},
 			})
 		}
 	}
 
 	keyboard = append(keyboard, []models.InlineKeyboardButton{
-		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart},
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
 	})
 
-	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+	_, err := h.sendMenu(ctx, b, &bot.SendMessageParams{
 		ChatID:    chatID,
 		ParseMode: models.ParseModeHTML,
 		ReplyMarkup: models.InlineKeyboardMarkup{
@@ -161,11 +278,11 @@ func (h Handler) sendTariffsMenu(ctx context.Context, b *bot.Bot, chatID int64,
 		Text: h.translation.GetText(langCode, "select_tariff"),
 	})
 	if err != nil {
-		slog.Error("Error sending tariffs menu", "error", err)
+		slog.ErrorContext(ctx, "Error sending tariffs menu", "error", err)
 	}
 }
 
-func (h Handler) StartCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h ProfileHandlers) StartCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -182,7 +299,7 @@ func (h Handler) StartCallbackHandler(ctx context.Context, b *bot.Bot, update *m
 
 	existingCustomer, err := h.customerRepository.FindByTelegramId(ctxWithTime, callback.From.ID)
 	if err != nil {
-		slog.Error("error finding customer by telegram id", "error", err)
+		slog.ErrorContext(ctx, "error finding customer by telegram id", "error", err)
 		return
 	}
 
@@ -193,42 +310,57 @@ func (h Handler) StartCallbackHandler(ctx context.Context, b *bot.Bot, update *m
 			Language:   langCode,
 		})
 		if err != nil {
-			slog.Error("error creating customer in callback", "error", err)
+			slog.ErrorContext(ctx, "error creating customer in callback", "error", err)
 			return
 		}
 	}
 
-	inlineKeyboard := h.buildStartKeyboard(existingCustomer, langCode)
+	publishMenuView(ctx, callback.From.ID, menuMain)
+	inlineKeyboard := h.buildStartKeyboard(ctxWithTime, existingCustomer, langCode)
+	text := h.startText(ctxWithTime, existingCustomer, langCode)
 
-	// Пробуем отредактировать, если не получится (фото) — отправляем новое
-	_, err = b.EditMessageText(ctxWithTime, &bot.EditMessageTextParams{
-		ChatID:    callback.Message.Message.Chat.ID,
-		MessageID: callback.Message.Message.ID,
-		ParseMode: models.ParseModeHTML,
-		ReplyMarkup: models.InlineKeyboardMarkup{
-			InlineKeyboard: inlineKeyboard,
-		},
-		Text: h.translation.GetText(langCode, "greeting"),
-	})
-	if err != nil {
-		// Игнорируем ошибки "message is not modified" (двойной клик)
-		if strings.Contains(err.Error(), "message is not modified") ||
-			strings.Contains(err.Error(), "exactly the same") {
-			return
-		}
-		// Если сообщение с фото — отправляем новое
-		_, _ = b.SendMessage(ctxWithTime, &bot.SendMessageParams{
-			ChatID:    callback.Message.Message.Chat.ID,
-			ParseMode: models.ParseModeHTML,
-			ReplyMarkup: models.InlineKeyboardMarkup{
-				InlineKeyboard: inlineKeyboard,
-			},
-			Text: h.translation.GetText(langCode, "greeting"),
+	message := callback.Message.Message
+	if message == nil {
+		return
+	}
+	_, withMedia := startMediaFor(langCode, text)
+	// Текст в медиа и обратно редактированием не превратить — такое сообщение заменяется новым
+	replace := withMedia != isMediaMessage(message)
+
+	switch {
+	case replace:
+		h.deleteChatMessage(ctxWithTime, b, message.Chat.ID, message.ID)
+	case withMedia:
+		_, err = b.EditMessageCaption(ctxWithTime, &bot.EditMessageCaptionParams{
+			ChatID:      message.Chat.ID,
+			MessageID:   message.ID,
+			ParseMode:   models.ParseModeHTML,
+			ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: inlineKeyboard},
+			Caption:     text,
+		})
+	default:
+		_, err = b.EditMessageText(ctxWithTime, &bot.EditMessageTextParams{
+			ChatID:      message.Chat.ID,
+			MessageID:   message.ID,
+			ParseMode:   models.ParseModeHTML,
+			ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: inlineKeyboard},
+			Text:        text,
 		})
 	}
+	if err == nil && !replace {
+		return
+	}
+	// Игнорируем ошибки "message is not modified" (двойной клик)
+	if err != nil && (strings.Contains(err.Error(), "message is not modified") ||
+		strings.Contains(err.Error(), "exactly the same")) {
+		return
+	}
+	if _, err := h.sendStartMenu(ctxWithTime, b, message.Chat.ID, langCode, text, inlineKeyboard); err != nil {
+		slog.ErrorContext(ctx, "Error sending start menu", "error", err)
+	}
 }
 
-func (h Handler) resolveConnectButton(lang string) []models.InlineKeyboardButton {
+func (h ProfileHandlers) resolveConnectButton(lang string) []models.InlineKeyboardButton {
 	var inlineKeyboard []models.InlineKeyboardButton
 
 	if config.GetMiniAppURL() != "" {
@@ -245,44 +377,89 @@ func (h Handler) resolveConnectButton(lang string) []models.InlineKeyboardButton
 	return inlineKeyboard
 }
 
-func (h Handler) buildStartKeyboard(existingCustomer *database.Customer, langCode string) [][]models.InlineKeyboardButton {
+// buildStartKeyboard строит стартовое меню по раскладке START_MENU_LAYOUT
+func (h ProfileHandlers) buildStartKeyboard(ctx context.Context, existingCustomer *database.Customer, langCode string) [][]models.InlineKeyboardButton {
 	var inlineKeyboard [][]models.InlineKeyboardButton
 
-	if existingCustomer.SubscriptionLink == nil && config.TrialDays() > 0 {
-		inlineKeyboard = append(inlineKeyboard, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "trial_button"), CallbackData: CallbackTrial}})
-	}
-
-	inlineKeyboard = append(inlineKeyboard, [][]models.InlineKeyboardButton{{{Text: h.translation.GetText(langCode, "buy_button"), CallbackData: CallbackBuy}}}...)
-
-	if existingCustomer.SubscriptionLink != nil && existingCustomer.ExpireAt.After(time.Now()) {
-		inlineKeyboard = append(inlineKeyboard, h.resolveConnectButton(langCode))
-	}
-
-	// Кнопка промокода
-	inlineKeyboard = append(inlineKeyboard, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "promo_button"), CallbackData: CallbackPromo}})
-
-	if config.GetReferralDays() > 0 {
-		inlineKeyboard = append(inlineKeyboard, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "referral_button"), CallbackData: CallbackReferral}})
-	}
-
-	if config.ServerStatusURL() != "" {
-		inlineKeyboard = append(inlineKeyboard, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "server_status_button"), URL: config.ServerStatusURL()}})
-	}
-
-	if config.SupportURL() != "" {
-		inlineKeyboard = append(inlineKeyboard, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "support_button"), URL: config.SupportURL()}})
+	for _, row := range config.GetStartMenuLayout() {
+		var buttons []models.InlineKeyboardButton
+		for _, id := range row {
+			if button := h.startMenuButton(ctx, id, existingCustomer, langCode); button != nil {
+				buttons = append(buttons, *button)
+			}
+		}
+		if len(buttons) > 0 {
+			inlineKeyboard = append(inlineKeyboard, buttons)
+		}
 	}
+	return inlineKeyboard
+}
 
-	if config.FeedbackURL() != "" {
-		inlineKeyboard = append(inlineKeyboard, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "feedback_button"), URL: config.FeedbackURL()}})
+// startMenuButton возвращает кнопку стартового меню или nil если кнопка сейчас не показывается
+func (h ProfileHandlers) startMenuButton(ctx context.Context, id string, existingCustomer *database.Customer, langCode string) *models.InlineKeyboardButton {
+	label := func(key string) string {
+		if text := config.GetMenuButton(id).Label(langCode); text != "" {
+			return text
+		}
+		return h.translation.GetText(langCode, key)
 	}
-
-	if config.ChannelURL() != "" {
-		inlineKeyboard = append(inlineKeyboard, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "channel_button"), URL: config.ChannelURL()}})
+	urlButton := func(key, url string) *models.InlineKeyboardButton {
+		if url == "" {
+			return nil
+		}
+		return &models.InlineKeyboardButton{Text: label(key), URL: url}
 	}
 
-	if config.TosURL() != "" {
-		inlineKeyboard = append(inlineKeyboard, []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "tos_button"), URL: config.TosURL()}})
+	switch id {
+	case config.MenuButtonTrial:
+		if config.TrialDays() > 0 && h.trialEligibility(ctx, existingCustomer).Allowed {
+			return &models.InlineKeyboardButton{Text: label("trial_button"), CallbackData: CallbackTrial}
+		}
+	case config.MenuButtonBuy:
+		return &models.InlineKeyboardButton{Text: label("buy_button"), CallbackData: CallbackBuy}
+	case config.MenuButtonConnect:
+		if existingCustomer.SubscriptionLink != nil && existingCustomer.ExpireAt.After(time.Now()) {
+			button := h.resolveConnectButton(langCode)[0]
+			button.Text = label("connect_button")
+			return &button
+		}
+	case config.MenuButtonPromo:
+		return &models.InlineKeyboardButton{Text: label("promo_button"), CallbackData: CallbackPromo}
+	case config.MenuButtonReferral:
+		if config.GetReferralDays() > 0 {
+			return &models.InlineKeyboardButton{Text: label("referral_button"), CallbackData: CallbackReferral}
+		}
+	case config.MenuButtonTeam:
+		if config.IsTeamPlansEnabled() && h.teamService != nil {
+			return &models.InlineKeyboardButton{Text: label("team_button"), CallbackData: CallbackTeam}
+		}
+	case config.MenuButtonGift:
+		if config.IsGiftsEnabled() {
+			return &models.InlineKeyboardButton{Text: label("gift_button"), CallbackData: CallbackGift}
+		}
+	case config.MenuButtonFaq:
+		if config.IsFaqEnabled() {
+			return &models.InlineKeyboardButton{Text: label("faq_button"), CallbackData: CallbackFaq}
+		}
+	case config.MenuButtonServerStatus:
+		if config.IsServerStatusPublic() && h.serverStatus != nil {
+			return &models.InlineKeyboardButton{Text: label("server_status_button"), CallbackData: CallbackServerStatus}
+		}
+		return urlButton("server_status_button", config.ServerStatusURL())
+	case config.MenuButtonSupport:
+		return urlButton("support_button", config.SupportURL())
+	case config.MenuButtonFeedback:
+		return urlButton("feedback_button", config.FeedbackURL())
+	case config.MenuButtonChannel:
+		return urlButton("channel_button", config.ChannelURL())
+	case config.MenuButtonTos:
+		return urlButton("tos_button", config.TosURL())
+	default:
+		// Пользовательская кнопка-ссылка
+		custom := config.GetMenuButton(id)
+		if custom != nil && custom.URL != "" && custom.Label(langCode) != "" {
+			return &models.InlineKeyboardButton{Text: custom.Label(langCode), URL: custom.URL}
+		}
 	}
-	return inlineKeyboard
+	return nil
 }
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"unicode/utf16"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+// startMediaKey помечает главное меню с фото или видео, чтобы MediaMenu не трогал рассылки с медиа и кнопками
+func startMediaKey(chatID int64, messageID int) string {
+	return fmt.Sprintf("start_media_%d_%d", chatID, messageID)
+}
+
+// startMediaFor возвращает медиа главного меню для языка. Подпись к медиа ограничена 1024 символами,
+// поэтому более длинное меню (например, с карточкой подписки) показывается текстом
+func startMediaFor(langCode, text string) (config.StartMedia, bool) {
+	media, ok := config.GetStartMedia(langCode)
+	if !ok || len(utf16.Encode([]rune(text))) > maxCaptionLength {
+		return config.StartMedia{}, false
+	}
+	return media, true
+}
+
+// sendStartMenu отправляет главное меню с медиа, если оно задано для языка, иначе текстом.
+// Если медиа отправить не удалось (неверный file_id или URL), меню отправляется текстом
+func (h base) sendStartMenu(ctx context.Context, b *bot.Bot, chatID int64, langCode, text string, keyboard [][]models.InlineKeyboardButton) (*models.Message, error) {
+	if media, ok := startMediaFor(langCode, text); ok {
+		msg, err := h.sendMenuMedia(ctx, b, chatID, media, text, keyboard)
+		if err == nil {
+			return msg, nil
+		}
+		slog.WarnContext(ctx, "Error sending start menu media, sending text instead", "type", media.Type, "error", err)
+	}
+	return h.sendMenu(ctx, b, &bot.SendMessageParams{
+		ChatID:      chatID,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+		Text:        text,
+	})
+}
+
+func (h base) sendMenuMedia(ctx context.Context, b *bot.Bot, chatID int64, media config.StartMedia, caption string, keyboard [][]models.InlineKeyboardButton) (*models.Message, error) {
+	file := &models.InputFileString{Data: media.File}
+	markup := models.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+	var msg *models.Message
+	var err error
+	switch media.Type {
+	case config.StartMediaVideo:
+		msg, err = b.SendVideo(ctx, &bot.SendVideoParams{ChatID: chatID, Video: file, Caption: caption, ParseMode: models.ParseModeHTML, ReplyMarkup: markup})
+	default:
+		msg, err = b.SendPhoto(ctx, &bot.SendPhotoParams{ChatID: chatID, Photo: file, Caption: caption, ParseMode: models.ParseModeHTML, ReplyMarkup: markup})
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.cache.SetString(startMediaKey(chatID, msg.ID), "1", menuMessageTTL)
+	h.rememberMenu(ctx, b, chatID, msg.ID)
+	return msg, nil
+}
+
+// isMediaMessage сообщение с фото или видео: его текст меняется через подпись, а не EditMessageText
+func isMediaMessage(message *models.Message) bool {
+	return len(message.Photo) > 0 || message.Video != nil
+}
+
+// MediaMenu переводит главное меню с медиа в текст, когда из него открывают другой экран:
+// экраны редактируют текст сообщения, а заменить фото или видео текстом редактированием Telegram не позволяет
+type MediaMenu struct {
+	base
+}
+
+func NewMediaMenu(cache stateCache) *MediaMenu {
+	return &MediaMenu{base: base{cache: cache}}
+}
+
+// Middleware подменяет главное меню с медиа текстовым сообщением с той же подписью и кнопками
+// до вызова обработчика, чтобы тот отредактировал уже текстовое сообщение.
+// Должен стоять перед Navigation.Middleware: история экранов привязана к id сообщения
+func (m *MediaMenu) Middleware(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		if update.CallbackQuery != nil {
+			m.replaceWithText(ctx, b, update)
+		}
+		next(ctx, b, update)
+	}
+}
+
+func (m *MediaMenu) replaceWithText(ctx context.Context, b *bot.Bot, update *models.Update) {
+	message := update.CallbackQuery.Message.Message
+	// Главное меню само редактирует подпись, "Назад" повторно проходит через middleware с целевым экраном
+	screen := callbackScreen(update.CallbackQuery.Data)
+	if message == nil || screen == CallbackStart || screen == CallbackBack || !isMediaMessage(message) {
+		return
+	}
+	key := startMediaKey(message.Chat.ID, message.ID)
+	if _, ok := m.cache.GetString(key); !ok {
+		return
+	}
+
+	text := message.Caption
+	if text == "" {
+		text = "⚡"
+	}
+	params := &bot.SendMessageParams{ChatID: message.Chat.ID, Text: text, Entities: message.CaptionEntities}
+	if message.ReplyMarkup != nil {
+		params.ReplyMarkup = message.ReplyMarkup
+	}
+	sent, err := m.sendMenu(ctx, b, params)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error replacing start menu media with text", "error", err)
+		return
+	}
+	m.cache.Delete(key)
+	m.deleteChatMessage(ctx, b, message.Chat.ID, message.ID)
+
+	replaced := *message
+	replaced.ID = sent.ID
+	replaced.Text, replaced.Entities = text, message.CaptionEntities
+	replaced.Caption, replaced.CaptionEntities = "", nil
+	replaced.Photo, replaced.Video = nil, nil
+	callbackQuery := *update.CallbackQuery
+	callbackQuery.Message.Message = &replaced
+	update.CallbackQuery = &callbackQuery
+}
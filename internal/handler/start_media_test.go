@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+func startMediaCallback(data string, message *models.Message) *models.Update {
+	return &models.Update{CallbackQuery: &models.CallbackQuery{
+		ID:      "cb",
+		Data:    data,
+		From:    models.User{ID: 42},
+		Message: models.MaybeInaccessibleMessage{Type: models.MaybeInaccessibleMessageTypeMessage, Message: message},
+	}}
+}
+
+func TestMediaMenuMiddlewareReplacesStartMedia(t *testing.T) {
+	keyboard := &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{{{Text: "Buy", CallbackData: CallbackBuy}}}}
+	mediaMessage := func(id int) *models.Message {
+		return &models.Message{ID: id, Chat: models.Chat{ID: 42}, Photo: []models.PhotoSize{{FileID: "photo"}}, Caption: "greeting", ReplyMarkup: keyboard}
+	}
+
+	tests := []struct {
+		name     string
+		data     string
+		marked   bool
+		replaced bool
+	}{
+		{"other screen from start media", CallbackBuy, true, true},
+		{"start menu edits caption itself", CallbackStart, true, false},
+		{"back replays the target screen", CallbackBack, true, false},
+		{"broadcast media with buttons", CallbackBuy, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, tg := newTestBot(t)
+			cache := newFakeCache()
+			if tt.marked {
+				cache.SetString(startMediaKey(42, 7), "1", menuMessageTTL)
+			}
+
+			var got *models.Message
+			next := func(ctx context.Context, b *bot.Bot, update *models.Update) {
+				got = update.CallbackQuery.Message.Message
+			}
+			NewMediaMenu(cache).Middleware(next)(context.Background(), b, startMediaCallback(tt.data, mediaMessage(7)))
+
+			if !tt.replaced {
+				if got.ID != 7 || len(tg.called("sendMessage")) != 0 {
+					t.Fatalf("Expected message to be left as is, got id %d and %d sends", got.ID, len(tg.called("sendMessage")))
+				}
+				return
+			}
+			sent := tg.called("sendMessage")
+			if len(sent) != 1 || sent[0].params["text"] != "greeting" || !strings.Contains(sent[0].params["reply_markup"], CallbackBuy) {
+				t.Fatalf("Expected text copy of the menu, got %+v", sent)
+			}
+			if deleted := tg.called("deleteMessage"); len(deleted) != 1 || deleted[0].params["message_id"] != "7" {
+				t.Errorf("Expected media message to be deleted, got %+v", deleted)
+			}
+			if got.ID != 1 || got.Text != "greeting" || isMediaMessage(got) {
+				t.Errorf("Handler must see the new text message, got %+v", got)
+			}
+			if _, ok := cache.GetString(startMediaKey(42, 7)); ok {
+				t.Error("Expected start media mark to be removed")
+			}
+		})
+	}
+}
+
+func TestSendStartMenu(t *testing.T) {
+	t.Cleanup(config.InitConfig)
+	t.Setenv("START_MEDIA", "photo:AgACAgIAAxkBAAIB")
+	t.Setenv("START_MEDIA_EN", "video:https://cdn.example.com/start.mp4")
+	config.InitConfig()
+
+	keyboard := [][]models.InlineKeyboardButton{{{Text: "Buy", CallbackData: CallbackBuy}}}
+	tests := []struct {
+		name   string
+		lang   string
+		text   string
+		method string
+	}{
+		{"language media", "en", "greeting", "sendVideo"},
+		{"default media", "ru", "greeting", "sendPhoto"},
+		{"too long for a caption", "ru", strings.Repeat("a", maxCaptionLength+1), "sendMessage"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, tg := newTestBot(t)
+			cache := newFakeCache()
+			h := base{translation: fakeTranslator{}, cache: cache}
+
+			if _, err := h.sendStartMenu(context.Background(), b, 42, tt.lang, tt.text, keyboard); err != nil {
+				t.Fatalf("sendStartMenu() returned error: %v", err)
+			}
+			if calls := tg.called(tt.method); len(calls) != 1 {
+				t.Fatalf("Expected one %s call, got %+v", tt.method, tg.calls)
+			}
+			_, marked := cache.GetString(startMediaKey(42, 1))
+			if marked != (tt.method != "sendMessage") {
+				t.Errorf("Expected media mark only for media menus, got %v", marked)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+func TestParseStartSource(t *testing.T) {
+	tests := []struct {
+		text string
+		want string // пусто — источник не определён
+	}{
+		{"/start", ""},
+		{"/start tariffs", ""},
+		{"/start ref_123456", "referral"},
+		{"/start utm_TG_Ads-march", "tg_ads-march"},
+		{"/start utm_", ""},
+		{"/start utm_referral", ""},
+		{"/start utm_" + strings.Repeat("a", 70), strings.Repeat("a", 64)},
+		{"/start promo_SUMMER", ""},
+		{"/start promo_SUMMER__VK_Ads", "vk_ads"},
+		{"/start trial", ""},
+		{"/start trial_VK_Ads", "vk_ads"},
+		{"/start buy_PRO_3", "buy_pro_3"},
+		{"/start buy_PRO_3__VK_Ads", "vk_ads"},
+	}
+
+	for _, tt := range tests {
+		got := parseStartSource(tt.text)
+		if tt.want == "" {
+			if got != nil {
+				t.Errorf("parseStartSource(%q) = %q, want nil", tt.text, *got)
+			}
+			continue
+		}
+		if got == nil || *got != tt.want {
+			t.Errorf("parseStartSource(%q) = %v, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTeamStartCode(t *testing.T) {
+	tests := []struct {
+		text string
+		want string // пусто — это не приглашение в команду
+	}{
+		{"/start", ""},
+		{"/start team_", ""},
+		{"/start ref_123456", ""},
+		{"/start team_ABCDEF2345", "ABCDEF2345"},
+	}
+
+	for _, tt := range tests {
+		got, ok := teamStartCode(tt.text)
+		if ok != (tt.want != "") || got != tt.want {
+			t.Errorf("teamStartCode(%q) = %q, %v, want %q", tt.text, got, ok, tt.want)
+		}
+	}
+}
+
+func TestPromoStartCode(t *testing.T) {
+	tests := []struct {
+		text        string
+		wantCode    string // пусто — это не ссылка с промокодом
+		wantChannel string
+	}{
+		{"/start", "", ""},
+		{"/start promo_", "", ""},
+		{"/start promo___vk", "", ""},
+		{"/start team_ABCDEF", "", ""},
+		{"/start promo_SUMMER", "SUMMER", "link"},
+		{"/start promo_SUMMER_2026", "SUMMER_2026", "link"},
+		{"/start promo_SUMMER_2026__TG-channel", "SUMMER_2026", "tg-channel"},
+		{"/start promo_SUMMER__!!!", "SUMMER", "link"},
+	}
+
+	for _, tt := range tests {
+		code, channel, ok := promoStartCode(tt.text)
+		if ok != (tt.wantCode != "") || code != tt.wantCode || channel != tt.wantChannel {
+			t.Errorf("promoStartCode(%q) = %q, %q, %v, want %q, %q", tt.text, code, channel, ok, tt.wantCode, tt.wantChannel)
+		}
+	}
+}
+
+func TestIsTrialStartLink(t *testing.T) {
+	tests := map[string]bool{
+		"/start":             false,
+		"/start trial":       true,
+		"/start trial_vk":    true,
+		"/start trialvk":     false,
+		"/start tariffs":     false,
+		"/start ref_123456":  false,
+		"/start utm_trial_x": false,
+	}
+	for text, want := range tests {
+		if got := isTrialStartLink(text); got != want {
+			t.Errorf("isTrialStartLink(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestActivateTrialFromStartReplaysActivateCallback(t *testing.T) {
+	b, tg := newTestBot(t)
+	replayed := make(chan *models.Update, 1)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, CallbackActivateTrial, bot.MatchTypeExact, func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		replayed <- update
+	})
+	h := ProfileHandlers{base: base{translation: fakeTranslator{}, cache: newFakeCache()}}
+
+	h.activateTrialFromStart(context.Background(), b, &models.Update{Message: &models.Message{
+		Chat: models.Chat{ID: 42},
+		From: &models.User{ID: 42, Username: "user"},
+		Text: "/start trial_vk",
+	}})
+
+	select {
+	case update := <-replayed:
+		if update.CallbackQuery.From.ID != 42 || update.CallbackQuery.Message.Message == nil || update.CallbackQuery.Message.Message.ID != 1 {
+			t.Errorf("Expected activation callback on the sent message, got %+v", update.CallbackQuery)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected activate_trial callback to be processed")
+	}
+	if sent := tg.called("sendMessage"); len(sent) != 1 || sent[0].params["text"] != "trial_activating" {
+		t.Errorf("Expected activation message, got %+v", sent)
+	}
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/remnawave"
+)
+
+// statusCardFetchTimeout ограничивает запрос в Remnawave, чтобы /start не ждал медленную панель
+const statusCardFetchTimeout = 2 * time.Second
+
+// startText возвращает текст стартового экрана: карточка подписки (если она есть) и приветствие
+func (h ProfileHandlers) startText(ctx context.Context, customer *database.Customer, langCode string) string {
+	greeting := h.translation.GetText(langCode, "greeting")
+	if customer == nil || customer.ExpireAt == nil {
+		return greeting
+	}
+
+	purchase, err := h.purchaseRepository.FindLastPaidPurchaseByCustomer(ctx, customer.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding last paid purchase for status card", "error", err)
+	}
+
+	card := buildStatusCard(h.translation, langCode, customer, purchase, h.remnawaveUserInfo(ctx, customer.TelegramID), clock.Now())
+	return card + "\n\n" + greeting
+}
+
+// remnawaveUserInfo возвращает актуальные данные пользователя из Remnawave через короткий кеш.
+// При выключенном запросе или ошибке панели возвращает nil — карточка строится по данным БД
+func (h ProfileHandlers) remnawaveUserInfo(ctx context.Context, telegramID int64) *remnawave.UserInfo {
+	ttl := config.StatusCardCacheSeconds()
+	if ttl == 0 || h.remnawaveClient == nil {
+		return nil
+	}
+
+	key := fmt.Sprintf("status_card_%d", telegramID)
+	if cached, ok := h.cache.GetString(key); ok {
+		var info remnawave.UserInfo
+		if err := json.Unmarshal([]byte(cached), &info); err == nil {
+			return &info
+		}
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, statusCardFetchTimeout)
+	defer cancel()
+	info, err := h.remnawaveClient.GetUserByTelegramID(ctxWithTimeout, telegramID)
+	if err != nil {
+		slog.WarnContext(ctx, "Error fetching remnawave user for status card", "error", err)
+		return nil
+	}
+
+	if data, err := json.Marshal(info); err == nil {
+		h.cache.SetString(key, string(data), ttl)
+	}
+	return info
+}
+
+// buildStatusCard собирает карточку подписки: статус (активна, пробный период, истекла), сколько осталось,
+// тариф, лимит устройств и остаток трафика. Тариф и лимит берутся из последней оплаченной покупки,
+// лимит устройств и трафик — из Remnawave, если данные оттуда получены
+func buildStatusCard(tm translator, langCode string, customer *database.Customer, purchase *database.Purchase, user *remnawave.UserInfo, now time.Time) string {
+	expireAt := *customer.ExpireAt
+	if user != nil && !user.ExpireAt.IsZero() {
+		expireAt = user.ExpireAt
+	}
+
+	if !expireAt.After(now) {
+		return tm.GetTextTemplate(langCode, "status_card_expired", map[string]interface{}{
+			"date": locale.FormatDate(langCode, expireAt),
+		})
+	}
+
+	lines := []string{tm.GetText(langCode, "status_card_active")}
+	if purchase == nil {
+		lines[0] = tm.GetText(langCode, "status_card_trial")
+	}
+	lines = append(lines, tm.GetTextTemplate(langCode, "status_card_remaining", map[string]interface{}{
+		"remaining": locale.FormatDuration(langCode, expireAt.Sub(now)),
+		"date":      locale.FormatDate(langCode, expireAt),
+	}))
+
+	var devices *int
+	if purchase != nil {
+		if purchase.TariffName != nil {
+			lines = append(lines, tm.GetTextTemplate(langCode, "status_card_tariff", map[string]interface{}{
+				"tariff": *purchase.TariffName,
+			}))
+			if tariff := config.GetTariffByName(*purchase.TariffName); tariff != nil {
+				devices = &tariff.Devices
+			}
+		}
+		if purchase.DeviceLimit != nil {
+			devices = purchase.DeviceLimit
+		}
+	}
+	if user != nil && user.DeviceLimit != nil {
+		devices = user.DeviceLimit
+	}
+	if devices != nil && *devices > 0 {
+		lines = append(lines, tm.GetTextTemplate(langCode, "status_card_devices", map[string]interface{}{
+			"devices": *devices,
+		}))
+	}
+
+	if user != nil {
+		if user.TrafficLimitBytes > 0 {
+			lines = append(lines, tm.GetTextTemplate(langCode, "status_card_traffic", map[string]interface{}{
+				"remaining": locale.FormatTraffic(langCode, user.TrafficLimitBytes-user.UsedTrafficBytes),
+				"limit":     locale.FormatTraffic(langCode, user.TrafficLimitBytes),
+			}))
+		} else {
+			lines = append(lines, tm.GetText(langCode, "status_card_traffic_unlimited"))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
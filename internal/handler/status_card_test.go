@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/remnawave"
+	"remnawave-tg-shop-bot/internal/translation"
+)
+
+func TestBuildStatusCard(t *testing.T) {
+	tm := translation.GetInstance()
+	if err := tm.InitTranslations("../../translations", "ru"); err != nil {
+		t.Fatalf("Failed to load translations: %v", err)
+	}
+
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	expireAt := now.Add(3*24*time.Hour + 5*time.Hour)
+	tariffName := "PRO"
+	devices := 3
+
+	tests := []struct {
+		name     string
+		customer *database.Customer
+		purchase *database.Purchase
+		user     *remnawave.UserInfo
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "trial without remnawave data",
+			customer: &database.Customer{ExpireAt: &expireAt},
+			want:     []string{"Пробный период", "3 дня 5 часов", "13.03.2025"},
+			notWant:  []string{"Трафик", "Тариф"},
+		},
+		{
+			name:     "paid with tariff and traffic",
+			customer: &database.Customer{ExpireAt: &expireAt},
+			purchase: &database.Purchase{TariffName: &tariffName, DeviceLimit: &devices},
+			user:     &remnawave.UserInfo{ExpireAt: expireAt, TrafficLimitBytes: 100 << 30, UsedTrafficBytes: 25 << 30},
+			want:     []string{"Подписка активна", "<b>PRO</b>", "до 3", "75\u00a0ГБ</b> из 100\u00a0ГБ"},
+		},
+		{
+			name:     "remnawave device limit and unlimited traffic",
+			customer: &database.Customer{ExpireAt: &expireAt},
+			purchase: &database.Purchase{DeviceLimit: &devices},
+			user:     &remnawave.UserInfo{ExpireAt: expireAt, DeviceLimit: func() *int { v := 5; return &v }()},
+			want:     []string{"до 5", "безлимит"},
+		},
+		{
+			name:     "expired in remnawave",
+			customer: &database.Customer{ExpireAt: &expireAt},
+			user:     &remnawave.UserInfo{ExpireAt: now.Add(-time.Hour)},
+			want:     []string{"Подписка истекла", "10.03.2025"},
+			notWant:  []string{"Осталось"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := buildStatusCard(tm, "ru", tt.customer, tt.purchase, tt.user, now)
+			for _, want := range tt.want {
+				if !strings.Contains(card, want) {
+					t.Errorf("Expected card to contain %q, got:\n%s", want, card)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(card, notWant) {
+					t.Errorf("Expected card not to contain %q, got:\n%s", notWant, card)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+// subscriptionPageURL ссылка на подписку для показа клиенту (см. database.Customer.SubscriptionPageURL).
+// Последняя оплаченная покупка ищется, только если ссылка зависит от тарифа
+func subscriptionPageURL(ctx context.Context, purchases lastPurchaseFinder, customer *database.Customer, langCode string) string {
+	var lastPaid *database.Purchase
+	if purchases != nil && customer.SubscriptionLink != nil && config.SubscriptionPageUsesTariff() {
+		purchase, err := purchases.FindLastPaidPurchaseByCustomer(ctx, customer.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error finding last paid purchase for subscription page", "error", err)
+		}
+		lastPaid = purchase
+	}
+	return customer.SubscriptionPageURL(lastPaid, langCode)
+}
@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// supportAuditLimit сколько последних действий админа показывать в карточке пользователя
+const supportAuditLimit = 5
+
+// UserLookupCommandHandler обрабатывает команду админа /user <telegram_id>: карточка пользователя
+// для поддержки — подписка, баланс, источник, теги, заметка и последние действия админа
+func (h AdminHandlers) UserLookupCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	args := strings.Fields(update.Message.Text)
+	if len(args) != 2 {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Использование: <code>/user &lt;telegram_id&gt;</code>")
+		return
+	}
+	telegramID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Неверный telegram_id")
+		return
+	}
+
+	customer, ok := h.findSupportCustomer(ctx, b, update.Message.Chat.ID, telegramID)
+	if !ok {
+		return
+	}
+	h.sendAdminText(ctx, b, update.Message.Chat.ID, h.supportCard(ctx, customer))
+}
+
+// TagCommandHandler обрабатывает команды админа /tag и /untag <telegram_id> <тег>:
+// добавляет или снимает тег поддержки. Изменение пишется в журнал действий админа
+func (h AdminHandlers) TagCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	remove := strings.HasPrefix(update.Message.Text, "/untag")
+	telegramID, tag, err := parseTagArgs(update.Message.Text)
+	if err != nil {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID,
+			"❌ Использование: <code>/tag &lt;telegram_id&gt; &lt;тег&gt;</code> или <code>/untag &lt;telegram_id&gt; &lt;тег&gt;</code>\n\n"+
+				"Тег — латиница, цифры, <code>_</code> и <code>-</code>, например <code>vip</code> или <code>chargeback_risk</code>")
+		return
+	}
+
+	customer, ok := h.findSupportCustomer(ctx, b, update.Message.Chat.ID, telegramID)
+	if !ok {
+		return
+	}
+
+	var changed bool
+	action := database.AuditActionTagAdded
+	if remove {
+		action = database.AuditActionTagRemoved
+		changed, err = h.customerNotes.RemoveTag(ctx, customer.ID, tag)
+	} else {
+		changed, err = h.customerNotes.AddTag(ctx, customer.ID, tag)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error changing customer tag", "error", err)
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Не удалось изменить теги")
+		return
+	}
+
+	result := utils.FormatHTML("✅ Тег <code>%s</code> добавлен", tag)
+	switch {
+	case remove && changed:
+		result = utils.FormatHTML("✅ Тег <code>%s</code> снят", tag)
+	case remove:
+		result = utils.FormatHTML("ℹ️ Тега <code>%s</code> у пользователя не было", tag)
+	case !changed:
+		result = utils.FormatHTML("ℹ️ Тег <code>%s</code> уже есть у пользователя", tag)
+	}
+	if changed {
+		h.recordAudit(ctx, update.Message.From.ID, customer.ID, action, tag)
+	}
+
+	h.sendAdminText(ctx, b, update.Message.Chat.ID, result+"\n\n"+h.supportCard(ctx, customer))
+}
+
+// NoteCommandHandler обрабатывает команду админа /note <telegram_id> [текст]: сохраняет заметку поддержки,
+// без текста — удаляет её. Изменение пишется в журнал действий админа
+func (h AdminHandlers) NoteCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	telegramID, note, err := parseNoteArgs(update.Message.Text)
+	if err != nil {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID,
+			"❌ Использование: <code>/note &lt;telegram_id&gt; &lt;текст&gt;</code>\n\nБез текста заметка удаляется")
+		return
+	}
+
+	customer, ok := h.findSupportCustomer(ctx, b, update.Message.Chat.ID, telegramID)
+	if !ok {
+		return
+	}
+
+	result := "✅ Заметка сохранена"
+	if note == "" {
+		deleted, err := h.customerNotes.DeleteNote(ctx, customer.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error deleting customer note", "error", err)
+			h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Не удалось удалить заметку")
+			return
+		}
+		result = "ℹ️ Заметки не было"
+		if deleted {
+			result = "✅ Заметка удалена"
+			h.recordAudit(ctx, update.Message.From.ID, customer.ID, database.AuditActionNoteDeleted, "")
+		}
+	} else {
+		if err := h.customerNotes.SetNote(ctx, customer.ID, note, update.Message.From.ID); err != nil {
+			slog.ErrorContext(ctx, "Error saving customer note", "error", err)
+			h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Не удалось сохранить заметку")
+			return
+		}
+		h.recordAudit(ctx, update.Message.From.ID, customer.ID, database.AuditActionNoteSet, note)
+	}
+
+	h.sendAdminText(ctx, b, update.Message.Chat.ID, result+"\n\n"+h.supportCard(ctx, customer))
+}
+
+func (h AdminHandlers) findSupportCustomer(ctx context.Context, b *bot.Bot, chatID, telegramID int64) (*database.Customer, bool) {
+	customer, err := h.customerRepository.FindByTelegramId(ctx, telegramID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding customer for support", "error", err)
+		h.sendAdminText(ctx, b, chatID, "❌ Не удалось найти пользователя")
+		return nil, false
+	}
+	if customer == nil {
+		h.sendAdminText(ctx, b, chatID, "❌ Пользователь не найден")
+		return nil, false
+	}
+	return customer, true
+}
+
+func (h AdminHandlers) recordAudit(ctx context.Context, adminID, customerID int64, action, details string) {
+	if err := h.auditLog.Record(ctx, adminID, customerID, action, details); err != nil {
+		slog.ErrorContext(ctx, "Error writing audit log", "error", err, "action", action)
+	}
+}
+
+// supportCard собирает карточку пользователя. Ошибки чтения отдельных блоков логируются,
+// карточка показывается без них
+func (h AdminHandlers) supportCard(ctx context.Context, customer *database.Customer) string {
+	card := supportCardData{Customer: customer}
+	var err error
+	if card.Balance, err = h.customerRepository.GetBalance(ctx, customer.ID); err != nil {
+		slog.ErrorContext(ctx, "Error getting balance for support card", "error", err)
+	}
+	if card.Tags, err = h.customerNotes.GetTags(ctx, customer.ID); err != nil {
+		slog.ErrorContext(ctx, "Error getting tags for support card", "error", err)
+	}
+	if card.Note, err = h.customerNotes.GetNote(ctx, customer.ID); err != nil {
+		slog.ErrorContext(ctx, "Error getting note for support card", "error", err)
+	}
+	if card.Audit, err = h.auditLog.FindByCustomer(ctx, customer.ID, supportAuditLimit); err != nil {
+		slog.ErrorContext(ctx, "Error getting audit log for support card", "error", err)
+	}
+	return formatSupportCard(card, time.Now())
+}
+
+type supportCardData struct {
+	Customer *database.Customer
+	Balance  int
+	Tags     []string
+	Note     *database.CustomerNote
+	Audit    []database.AuditLogEntry
+}
+
+func formatSupportCard(card supportCardData, now time.Time) string {
+	c := card.Customer
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("👤 <b>Пользователь</b> <code>%d</code> (id %d)\n", c.TelegramID, c.ID))
+	sb.WriteString(fmt.Sprintf("Создан: %s, язык: %s\n", c.CreatedAt.Format("02.01.2006"), utils.EscapeHTML(c.Language)))
+	switch {
+	case c.ExpireAt == nil:
+		sb.WriteString("Подписка: нет\n")
+	case c.ExpireAt.After(now):
+		sb.WriteString(fmt.Sprintf("Подписка: ✅ до %s\n", c.ExpireAt.Format("02.01.2006 15:04")))
+	default:
+		sb.WriteString(fmt.Sprintf("Подписка: ❌ истекла %s\n", c.ExpireAt.Format("02.01.2006 15:04")))
+	}
+	if c.RecurringEnabled {
+		sb.WriteString("Автопродление: включено\n")
+	}
+	switch {
+	case c.TrialRegrantedAt != nil:
+		sb.WriteString("Триал: 🎁 выдан повторно, ещё не активирован\n")
+	case c.TrialActivatedAt != nil:
+		sb.WriteString(fmt.Sprintf("Триал: взят %s\n", c.TrialActivatedAt.Format("02.01.2006")))
+	}
+	sb.WriteString(fmt.Sprintf("Баланс: %d ₽\n", card.Balance))
+	if c.Source != nil {
+		sb.WriteString(fmt.Sprintf("Источник: %s\n", utils.EscapeHTML(*c.Source)))
+	}
+
+	sb.WriteString("\n🏷 Теги: ")
+	if len(card.Tags) == 0 {
+		sb.WriteString("нет")
+	} else {
+		for i, tag := range card.Tags {
+			if i > 0 {
+				sb.WriteString(" ")
+			}
+			sb.WriteString("#" + utils.EscapeHTML(tag))
+		}
+	}
+	sb.WriteString("\n")
+
+	if card.Note != nil {
+		sb.WriteString(fmt.Sprintf("📝 Заметка (%s):\n%s\n", card.Note.UpdatedAt.Format("02.01.2006 15:04"), utils.EscapeHTML(card.Note.Note)))
+	}
+
+	if len(card.Audit) > 0 {
+		sb.WriteString("\n🕘 <b>Последние действия</b>\n")
+		for _, e := range card.Audit {
+			sb.WriteString(fmt.Sprintf("• %s %s", e.CreatedAt.Format("02.01 15:04"), auditActionName(e.Action)))
+			if e.Details != nil && *e.Details != "" {
+				sb.WriteString(": " + utils.EscapeHTML(truncateRunes(*e.Details, 50)))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func auditActionName(action string) string {
+	switch action {
+	case database.AuditActionTagAdded:
+		return "добавлен тег"
+	case database.AuditActionTagRemoved:
+		return "снят тег"
+	case database.AuditActionNoteSet:
+		return "заметка"
+	case database.AuditActionNoteDeleted:
+		return "заметка удалена"
+	case database.AuditActionLinkRotated:
+		return "ссылка подписки перевыпущена"
+	case database.AuditActionDataExported:
+		return "выгружены данные"
+	case database.AuditActionDataErased:
+		return "данные удалены"
+	case database.AuditActionTrialRegranted:
+		return "триал выдан повторно"
+	default:
+		return action
+	}
+}
+
+func truncateRunes(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n]) + "…"
+	}
+	return s
+}
+
+func parseTagArgs(text string) (int64, string, error) {
+	args := strings.Fields(text)
+	if len(args) < 3 {
+		return 0, "", errors.New("expected telegram id and tag")
+	}
+	telegramID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+	tag := database.NormalizeTag(strings.Join(args[2:], " "))
+	if tag == "" {
+		return 0, "", errors.New("empty tag")
+	}
+	return telegramID, tag, nil
+}
+
+// parseNoteArgs разбирает /note <telegram_id> [текст]. Текст может быть многострочным
+func parseNoteArgs(text string) (int64, string, error) {
+	args := strings.Fields(text)
+	if len(args) < 2 {
+		return 0, "", errors.New("expected telegram id")
+	}
+	telegramID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+	idx := strings.Index(text, args[1]) + len(args[1])
+	return telegramID, strings.TrimSpace(text[idx:]), nil
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+func TestParseTagArgs(t *testing.T) {
+	telegramID, tag, err := parseTagArgs("/tag 12345 Chargeback Risk")
+	if err != nil || telegramID != 12345 || tag != "chargeback_risk" {
+		t.Errorf("Unexpected result: %d %q %v", telegramID, tag, err)
+	}
+
+	for _, text := range []string{"/tag", "/tag 12345", "/tag abc vip", "/untag 12345 !!!"} {
+		if _, _, err := parseTagArgs(text); err == nil {
+			t.Errorf("Expected error for %q", text)
+		}
+	}
+}
+
+func TestParseNoteArgs(t *testing.T) {
+	telegramID, note, err := parseNoteArgs("/note 12345 Просил возврат\nобещали компенсацию")
+	if err != nil || telegramID != 12345 || note != "Просил возврат\nобещали компенсацию" {
+		t.Errorf("Unexpected result: %d %q %v", telegramID, note, err)
+	}
+
+	_, note, err = parseNoteArgs("/note 12345")
+	if err != nil || note != "" {
+		t.Errorf("Expected empty note to delete, got %q %v", note, err)
+	}
+
+	if _, _, err := parseNoteArgs("/note abc text"); err == nil {
+		t.Error("Expected error for invalid telegram id")
+	}
+}
+
+func TestFormatSupportCard(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	expireAt := now.Add(48 * time.Hour)
+	source := "utm_<ads>"
+	details := "vip"
+
+	text := formatSupportCard(supportCardData{
+		Customer: &database.Customer{ID: 7, TelegramID: 12345, ExpireAt: &expireAt, Language: "ru", Source: &source},
+		Balance:  150,
+		Tags:     []string{"chargeback_risk", "vip"},
+		Note:     &database.CustomerNote{Note: "Не выдавать <промо>", UpdatedAt: now},
+		Audit: []database.AuditLogEntry{
+			{Action: database.AuditActionTagAdded, Details: &details, CreatedAt: now},
+		},
+	}, now)
+
+	for _, want := range []string{"<code>12345</code>", "✅ до 12.03.2025", "Баланс: 150 ₽", "utm_&lt;ads&gt;",
+		"#chargeback_risk #vip", "Не выдавать &lt;промо&gt;", "добавлен тег: vip"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected card to contain %q, got:\n%s", want, text)
+		}
+	}
+
+	empty := formatSupportCard(supportCardData{Customer: &database.Customer{TelegramID: 1}}, now)
+	if !strings.Contains(empty, "Подписка: нет") || !strings.Contains(empty, "Теги: нет") || strings.Contains(empty, "Последние действия") {
+		t.Errorf("Unexpected card for customer without data:\n%s", empty)
+	}
+}
@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+const (
+	// surveyRecentComments сколько последних комментариев показывать в админке
+	surveyRecentComments = 10
+	// surveyCommentPreview сколько символов комментария показывать в админке
+	surveyCommentPreview = 200
+)
+
+// surveyStatsPeriods периоды итогов опросов в днях
+var surveyStatsPeriods = []int{30, 90}
+
+// SurveyRateCallbackHandler сохраняет оценку из опроса (survey_rate?id=<id>&r=<1-5>) и предлагает оставить комментарий
+func (h SurveyHandlers) SurveyRateCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	langCode := update.CallbackQuery.From.LanguageCode
+	params := parseCallbackData(update.CallbackQuery.Data)
+	id, err := strconv.ParseInt(params["id"], 10, 64)
+	if err != nil {
+		return
+	}
+	rating, err := strconv.Atoi(params["r"])
+	if err != nil || rating < 1 || rating > 5 {
+		return
+	}
+
+	saved, err := h.surveys.SaveRating(ctx, id, update.CallbackQuery.From.ID, rating, clock.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving survey rating", "error", err)
+		return
+	}
+
+	text := h.translation.GetText(langCode, "survey_already_answered")
+	var keyboard [][]models.InlineKeyboardButton
+	if saved {
+		text = h.translation.GetText(langCode, "survey_thanks")
+		keyboard = [][]models.InlineKeyboardButton{{{
+			Text:         h.translation.GetText(langCode, "survey_comment_button"),
+			CallbackData: fmt.Sprintf("%s?id=%d", CallbackSurveyComment, id),
+		}}}
+	}
+	h.editSurveyMessage(ctx, b, update.CallbackQuery.Message.Message, text, keyboard)
+}
+
+// SurveyCommentCallbackHandler ждёт от клиента комментарий к оценке
+func (h SurveyHandlers) SurveyCommentCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	id, err := strconv.ParseInt(parseCallbackData(update.CallbackQuery.Data)["id"], 10, 64)
+	if err != nil {
+		return
+	}
+	h.cache.SetString(fmt.Sprintf("survey_comment_%d", update.CallbackQuery.From.ID), strconv.FormatInt(id, 10), 600)
+	h.editSurveyMessage(ctx, b, update.CallbackQuery.Message.Message,
+		h.translation.GetText(update.CallbackQuery.From.LanguageCode, "survey_comment_prompt"), nil)
+}
+
+// SurveyCommentInputHandler сохраняет комментарий клиента к оценке
+func (h SurveyHandlers) SurveyCommentInputHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	key := fmt.Sprintf("survey_comment_%d", update.Message.From.ID)
+	state, found := h.cache.GetString(key)
+	h.cache.Delete(key)
+	id, err := strconv.ParseInt(state, 10, 64)
+	if !found || err != nil {
+		return
+	}
+
+	comment := strings.TrimSpace(update.Message.Text)
+	if comment == "" {
+		return
+	}
+	if _, err := h.surveys.SaveComment(ctx, id, update.Message.From.ID, comment); err != nil {
+		slog.ErrorContext(ctx, "Error saving survey comment", "error", err)
+		return
+	}
+
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID: update.Message.Chat.ID,
+		Text:   h.translation.GetText(update.Message.From.LanguageCode, "survey_comment_thanks"),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending survey comment reply", "error", err)
+	}
+}
+
+// SurveyCommandHandler обрабатывает команду админа /survey <telegram_id>: опрос после закрытого обращения в поддержку
+func (h SurveyHandlers) SurveyCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	chatID := update.Message.Chat.ID
+	args := strings.Fields(update.Message.Text)
+	if len(args) != 2 {
+		h.sendAdminText(ctx, b, chatID, "❌ Использование: <code>/survey &lt;telegram_id&gt;</code>")
+		return
+	}
+	telegramID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		h.sendAdminText(ctx, b, chatID, "❌ Неверный telegram_id")
+		return
+	}
+	if !config.IsSurveyEnabled() {
+		h.sendAdminText(ctx, b, chatID, "ℹ️ Опросы выключены (SURVEY_ENABLED)")
+		return
+	}
+
+	customer, err := h.customers.FindByTelegramId(ctx, telegramID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding customer for survey", "error", err)
+		h.sendAdminText(ctx, b, chatID, "❌ Не удалось найти пользователя")
+		return
+	}
+	if customer == nil {
+		h.sendAdminText(ctx, b, chatID, "❌ Пользователь не найден")
+		return
+	}
+
+	sent, err := h.offerer.Offer(ctx, customer, database.SurveyTriggerSupport)
+	switch {
+	case err != nil:
+		slog.ErrorContext(ctx, "Error sending support survey", "error", err)
+		h.sendAdminText(ctx, b, chatID, "❌ Не удалось отправить опрос")
+	case sent:
+		h.sendAdminText(ctx, b, chatID, "✅ Опрос отправлен")
+	default:
+		h.sendAdminText(ctx, b, chatID, fmt.Sprintf("ℹ️ Опрос не отправлен: пользователь уже получал опрос за последние %d дн. или отказался от рассылок",
+			config.SurveyIntervalDays()))
+	}
+}
+
+// AdminSurveyCallback показывает итоги опросов и последние комментарии
+func (h SurveyHandlers) AdminSurveyCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	msg := update.CallbackQuery.Message.Message
+
+	stats := make([]database.SurveyStats, 0, len(surveyStatsPeriods))
+	for _, days := range surveyStatsPeriods {
+		st, err := h.surveys.Stats(ctx, clock.Now().AddDate(0, 0, -days))
+		if err != nil {
+			slog.ErrorContext(ctx, "Error loading survey stats", "error", err)
+			return
+		}
+		stats = append(stats, st)
+	}
+	comments, err := h.surveys.RecentComments(ctx, surveyRecentComments)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading survey comments", "error", err)
+		return
+	}
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		Text:      formatSurveyStats(config.IsSurveyEnabled(), stats, comments),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: "🔄 Обновить", CallbackData: "admin_survey"}},
+			{{Text: "🔙 Назад", CallbackData: "admin_back"}},
+		}},
+	})
+	if err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing survey stats message", "error", err)
+	}
+}
+
+func (h SurveyHandlers) editSurveyMessage(ctx context.Context, b *bot.Bot, msg *models.Message, text string, keyboard [][]models.InlineKeyboardButton) {
+	params := &bot.EditMessageTextParams{
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+	}
+	if keyboard != nil {
+		params.ReplyMarkup = models.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+	}
+	if _, err := b.EditMessageText(ctx, params); err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.ErrorContext(ctx, "Error editing survey message", "error", err)
+	}
+}
+
+// formatSurveyStats форматирует итоги опросов; stats идут в порядке surveyStatsPeriods
+func formatSurveyStats(enabled bool, stats []database.SurveyStats, comments []database.SurveyComment) string {
+	var sb strings.Builder
+	sb.WriteString("⭐ <b>Опросы удовлетворённости</b>\n")
+	if enabled {
+		sb.WriteString(fmt.Sprintf("Включены, не чаще раза в %d дн. на клиента\n", config.SurveyIntervalDays()))
+	} else {
+		sb.WriteString("Выключены\n")
+	}
+
+	for i, st := range stats {
+		responseRate := 0.0
+		if st.Sent > 0 {
+			responseRate = float64(st.Answered) * 100 / float64(st.Sent)
+		}
+		sb.WriteString(fmt.Sprintf("\n<b>За %d дн.</b>\n", surveyStatsPeriods[i]))
+		sb.WriteString(fmt.Sprintf("отправлено: %d, ответили: %d (%.1f%%)\n", st.Sent, st.Answered, responseRate))
+		if st.Answered == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("средняя оценка: %.2f, CSAT (4–5): %.1f%%\n", st.Average, st.CSAT()))
+		for rating := 5; rating >= 1; rating-- {
+			sb.WriteString(fmt.Sprintf("%d⭐ — %d  ", rating, st.Ratings[rating-1]))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(comments) > 0 {
+		sb.WriteString("\n<b>Последние комментарии</b>\n")
+		for _, c := range comments {
+			sb.WriteString(utils.FormatHTML("\n%d⭐ <code>%d</code> (%s, %s)\n%s\n",
+				c.Rating, c.TelegramID, surveyTriggerTitle(c.Trigger), c.AnsweredAt.In(config.Timezone()).Format("02.01 15:04"),
+				truncateRunes(c.Comment, surveyCommentPreview)))
+		}
+	}
+	return sb.String()
+}
+
+func surveyTriggerTitle(trigger string) string {
+	switch trigger {
+	case database.SurveyTriggerRenewal:
+		return "продление"
+	case database.SurveyTriggerSupport:
+		return "поддержка"
+	default:
+		return trigger
+	}
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+type mockSurveyStore struct {
+	surveyStore
+	answered bool
+	ratings  map[int64]int
+	comments map[int64]string
+}
+
+func (m *mockSurveyStore) SaveRating(_ context.Context, id, _ int64, rating int, _ time.Time) (bool, error) {
+	if m.answered {
+		return false, nil
+	}
+	if m.ratings == nil {
+		m.ratings = map[int64]int{}
+	}
+	m.ratings[id] = rating
+	return true, nil
+}
+
+func (m *mockSurveyStore) SaveComment(_ context.Context, id, _ int64, comment string) (bool, error) {
+	if m.comments == nil {
+		m.comments = map[int64]string{}
+	}
+	m.comments[id] = comment
+	return true, nil
+}
+
+func TestSurveyRateCallbackHandler(t *testing.T) {
+	b, tg := newTestBot(t)
+	store := &mockSurveyStore{}
+	h := NewSurveyHandlers(fakeTranslator{}, newFakeCache(), store, nil, nil)
+
+	h.SurveyRateCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackSurveyRate+"?id=3&r=4"))
+
+	if store.ratings[3] != 4 {
+		t.Fatalf("Expected rating 4 for survey 3, got %v", store.ratings)
+	}
+	edited := tg.called("editMessageText")
+	if len(edited) != 1 || edited[0].params["text"] != "survey_thanks" || !strings.Contains(edited[0].params["reply_markup"], CallbackSurveyComment+"?id=3") {
+		t.Errorf("Expected thanks with comment button, got %v", edited)
+	}
+}
+
+func TestSurveyRateCallbackHandlerIgnoresRepeatedAnswer(t *testing.T) {
+	b, tg := newTestBot(t)
+	h := NewSurveyHandlers(fakeTranslator{}, newFakeCache(), &mockSurveyStore{answered: true}, nil, nil)
+
+	h.SurveyRateCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackSurveyRate+"?id=3&r=1"))
+
+	edited := tg.called("editMessageText")
+	if len(edited) != 1 || edited[0].params["text"] != "survey_already_answered" {
+		t.Errorf("Expected already answered message, got %v", edited)
+	}
+}
+
+func TestSurveyCommentFlow(t *testing.T) {
+	b, tg := newTestBot(t)
+	cache := newFakeCache()
+	store := &mockSurveyStore{}
+	h := NewSurveyHandlers(fakeTranslator{}, cache, store, nil, nil)
+
+	h.SurveyCommentCallbackHandler(context.Background(), b, paymentCallbackUpdate(CallbackSurveyComment+"?id=3"))
+	if state, _ := cache.GetString("survey_comment_42"); state != "3" {
+		t.Fatalf("Expected survey 3 to wait for comment, got %q", state)
+	}
+
+	h.SurveyCommentInputHandler(context.Background(), b, &models.Update{Message: &models.Message{
+		Text: "  Быстро ответили  ",
+		From: &models.User{ID: 42},
+		Chat: models.Chat{ID: 42},
+	}})
+
+	if store.comments[3] != "Быстро ответили" {
+		t.Errorf("Expected trimmed comment to be saved, got %v", store.comments)
+	}
+	if _, found := cache.GetString("survey_comment_42"); found {
+		t.Error("Expected comment state to be cleared")
+	}
+	if sent := tg.called("sendMessage"); len(sent) != 1 || sent[0].params["text"] != "survey_comment_thanks" {
+		t.Errorf("Expected thanks for comment, got %v", sent)
+	}
+}
+
+func TestFormatSurveyStats(t *testing.T) {
+	answeredAt := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	text := formatSurveyStats(true,
+		[]database.SurveyStats{
+			{Sent: 10, Answered: 4, Average: 4.25, Ratings: [5]int{0, 0, 1, 1, 2}},
+			{Sent: 3},
+		},
+		[]database.SurveyComment{{TelegramID: 42, Trigger: database.SurveyTriggerSupport, Rating: 2, Comment: "долго <ждал>", AnsweredAt: answeredAt}},
+	)
+
+	for _, want := range []string{
+		"отправлено: 10, ответили: 4 (40.0%)",
+		"средняя оценка: 4.25, CSAT (4–5): 75.0%",
+		"5⭐ — 2",
+		"отправлено: 3, ответили: 0 (0.0%)",
+		"2⭐ <code>42</code> (поддержка,",
+		"долго &lt;ждал&gt;",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in stats, got:\n%s", want, text)
+		}
+	}
+}
@@ -8,13 +8,13 @@ import (
 	"log/slog"
 )
 
-func (h Handler) SyncUsersCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
-	h.syncService.Sync()
+func (h AdminHandlers) SyncUsersCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	h.syncService.Sync(ctx)
 	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
 		ChatID: update.Message.Chat.ID,
 		Text:   "Users synced",
 	})
 	if err != nil {
-		slog.Error("Error sending sync message", "error", err)
+		slog.ErrorContext(ctx, "Error sending sync message", "error", err)
 	}
 }
@@ -10,12 +10,12 @@ import (
 	"github.com/go-telegram/bot/models"
 
 	"remnawave-tg-shop-bot/internal/config"
-	"remnawave-tg-shop-bot/internal/translation"
+	"remnawave-tg-shop-bot/internal/locale"
 )
 
 // FormatTariffButtonText форматирует текст кнопки тарифа с учётом локализации
-// Формат: "{emoji} {Name} — до {Devices} устройств — от N ₽/мес"
-func FormatTariffButtonText(tariff config.Tariff, langCode string, tm *translation.Manager) string {
+// Формат: "{emoji} До {Devices} устройств — от N ₽/мес (за год)"
+func FormatTariffButtonText(tariff config.Tariff, langCode string, tm translator) string {
 	// Разные эмодзи для разных тарифов
 	emoji := "📱"
 	switch tariff.Name {
@@ -32,11 +32,29 @@ func FormatTariffButtonText(tariff config.Tariff, langCode string, tm *translati
 	// Считаем среднемесячную цену от годовой подписки
 	monthlyPrice := tariff.Price12 / 12
 
-	return fmt.Sprintf("%s До %d устройств — от %d ₽/мес (за год)", emoji, tariff.Devices, monthlyPrice)
+	return emoji + " " + tm.GetTextTemplate(langCode, "tariff_button", map[string]interface{}{
+		"devices": tariff.Devices,
+		"price":   locale.FormatMoney(langCode, float64(monthlyPrice)),
+	})
+}
+
+// dayPlanButtons кнопки посуточных планов тарифа (TARIFF_<NAME>_PRICE_<N>D): "7 дней — 99 ₽"
+func dayPlanButtons(tariff config.Tariff, langCode string, tm translator) []models.InlineKeyboardButton {
+	var buttons []models.InlineKeyboardButton
+	for _, plan := range tariff.DayPlans {
+		buttons = append(buttons, models.InlineKeyboardButton{
+			Text: tm.GetTextTemplate(langCode, "period_days", map[string]interface{}{
+				"days":  locale.FormatDays(langCode, plan.Days),
+				"price": locale.FormatMoney(langCode, float64(plan.Price)),
+			}),
+			CallbackData: fmt.Sprintf("%s?month=%s&tariff=%s", CallbackSell, config.DaysPeriod(plan.Days), tariff.Name),
+		})
+	}
+	return buttons
 }
 
 // TariffCallbackHandler обрабатывает выбор тарифа и показывает меню цен
-func (h Handler) TariffCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PaymentHandlers) TariffCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -47,13 +65,13 @@ func (h Handler) TariffCallbackHandler(ctx context.Context, b *bot.Bot, update *
 
 	tariffName := callbackQuery["name"]
 	if tariffName == "" {
-		slog.Error("Tariff name not provided in callback")
+		slog.ErrorContext(ctx, "Tariff name not provided in callback")
 		return
 	}
 
 	tariff := config.GetTariffByName(tariffName)
 	if tariff == nil {
-		slog.Error("Tariff not found", "name", tariffName)
+		slog.ErrorContext(ctx, "Tariff not found", "name", tariffName)
 		return
 	}
 
@@ -62,34 +80,37 @@ func (h Handler) TariffCallbackHandler(ctx context.Context, b *bot.Bot, update *
 
 	if tariff.Price1 > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_1", map[string]interface{}{"price": tariff.Price1}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 1, tariff.Price1, tariffName),
+			Text:         h.translation.GetTextTemplate(langCode, "month_1", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price1))}),
+			CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 1, tariffName),
 		})
 	}
 
 	if tariff.Price3 > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_3", map[string]interface{}{"price": tariff.Price3}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 3, tariff.Price3, tariffName),
+			Text:         h.translation.GetTextTemplate(langCode, "month_3", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price3))}),
+			CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 3, tariffName),
 		})
 	}
 
 	if tariff.Price6 > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_6", map[string]interface{}{"price": tariff.Price6}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 6, tariff.Price6, tariffName),
+			Text:         h.translation.GetTextTemplate(langCode, "month_6", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price6))}),
+			CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 6, tariffName),
 		})
 	}
 
 	if tariff.Price12 > 0 {
 		priceButtons = append(priceButtons, models.InlineKeyboardButton{
-			Text:         h.translation.GetTextTemplate(langCode, "month_12", map[string]interface{}{"price": tariff.Price12}),
-			CallbackData: fmt.Sprintf("%s?month=%d&amount=%d&tariff=%s", CallbackSell, 12, tariff.Price12, tariffName),
+			Text:         h.translation.GetTextTemplate(langCode, "month_12", map[string]interface{}{"price": locale.FormatMoney(langCode, float64(tariff.Price12))}),
+			CallbackData: fmt.Sprintf("%s?month=%d&tariff=%s", CallbackSell, 12, tariffName),
 		})
 	}
 
 	keyboard := [][]models.InlineKeyboardButton{}
 
+	if dayButtons := dayPlanButtons(*tariff, langCode, h.translation); len(dayButtons) > 0 {
+		keyboard = append(keyboard, dayButtons)
+	}
 	if len(priceButtons) == 4 {
 		keyboard = append(keyboard, priceButtons[:2])
 		keyboard = append(keyboard, priceButtons[2:])
@@ -98,15 +119,9 @@ func (h Handler) TariffCallbackHandler(ctx context.Context, b *bot.Bot, update *
 	}
 
 	// Кнопка назад - к меню тарифов или к старту
-	if len(config.GetTariffs()) > 1 {
-		keyboard = append(keyboard, []models.InlineKeyboardButton{
-			{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBuy},
-		})
-	} else {
-		keyboard = append(keyboard, []models.InlineKeyboardButton{
-			{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart},
-		})
-	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
+	})
 
 	// Текст с информацией о тарифе
 	pricingText := h.translation.GetTextTemplate(langCode, "select_period_text", map[string]interface{}{
@@ -132,7 +147,7 @@ func (h Handler) TariffCallbackHandler(ctx context.Context, b *bot.Bot, update *
 			return
 		}
 		// Если сообщение с фото — отправляем новое
-		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+		_, _ = h.sendMenu(ctx, b, &bot.SendMessageParams{
 			ChatID:    callback.Chat.ID,
 			ParseMode: models.ParseModeHTML,
 			ReplyMarkup: models.InlineKeyboardMarkup{
@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/events"
+	"remnawave-tg-shop-bot/internal/promo"
+)
+
+// buyStartPrefix префикс рекламной ссылки на оплату тарифа: /start buy_<тариф>_<период>__<канал>
+const buyStartPrefix = "buy_"
+
+// buyStartLink разбирает ссылку "/start buy_PRO_3" или "/start buy_PRO_7d__vk". Период — после последнего "_",
+// поэтому имя тарифа может содержать "_"; без тарифов ссылка имеет вид buy_3. Тариф сверяется без учёта регистра.
+// ok=false, если ссылка не на оплату или тариф, период или цена устарели: тогда показывается обычное меню тарифов
+func buyStartLink(text string) (tariff, month, channel string, ok bool) {
+	args := strings.Fields(text)
+	if len(args) < 2 {
+		return "", "", "", false
+	}
+	payload, ok := strings.CutPrefix(args[1], buyStartPrefix)
+	if !ok {
+		return "", "", "", false
+	}
+	payload, channel, _ = strings.Cut(payload, promo.ChannelSeparator)
+	if i := strings.LastIndex(payload, "_"); i >= 0 {
+		tariff, month = payload[:i], payload[i+1:]
+	} else {
+		month = payload
+	}
+
+	if tariff != "" || len(config.GetTariffs()) > 0 {
+		t := findTariff(tariff)
+		if t == nil {
+			return "", "", "", false
+		}
+		tariff = t.Name
+	}
+	if !isCheckoutAvailable(month, tariff) {
+		return "", "", "", false
+	}
+	return tariff, month, sanitizeSource(channel), true
+}
+
+// findTariff ищет тариф по имени: сначала точное совпадение, затем без учёта регистра
+func findTariff(name string) *config.Tariff {
+	if name == "" {
+		return nil
+	}
+	if t := config.GetTariffByName(name); t != nil {
+		return t
+	}
+	tariffs := config.GetTariffs()
+	for i := range tariffs {
+		if strings.EqualFold(tariffs[i].Name, name) {
+			return &tariffs[i]
+		}
+	}
+	return nil
+}
+
+// isCheckoutAvailable true, если период тарифа продаётся хотя бы одним включённым способом оплаты
+func isCheckoutAvailable(month, tariff string) bool {
+	methods := map[database.InvoiceType]bool{
+		database.InvoiceTypeCrypto:   config.IsCryptoPayEnabled(),
+		database.InvoiceTypeYookasa:  config.IsYookasaEnabled(),
+		database.InvoiceTypeTelegram: config.IsTelegramStarsEnabled(),
+	}
+	for invoiceType, enabled := range methods {
+		if _, ok := periodPaymentAmount(invoiceType, month, tariff); enabled && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SendCheckout отправляет меню выбора способа оплаты периода month тарифа tariff, минуя выбор тарифа и периода.
+// "Назад" ведёт к пропущенным экранам так же, как если бы клиент прошёл их сам
+func (h PaymentHandlers) SendCheckout(ctx context.Context, b *bot.Bot, chatID int64, langCode, month, tariff string) {
+	if period, ok := config.ParsePeriod(month); ok {
+		events.Publish(ctx, events.TariffSelected(events.TariffSelection{
+			TelegramID: chatID,
+			TariffName: tariff,
+			Months:     period.Months,
+			Days:       period.Days,
+		}))
+	}
+
+	text, keyboard := h.paymentMethodsMenu(ctx, chatID, langCode, month, tariff, h.defaultRecurring(ctx, chatID))
+	msg, err := h.sendMenu(ctx, b, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{
+			InlineKeyboard: keyboard,
+		},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending payment methods menu", "error", err)
+		return
+	}
+
+	stack := []string{CallbackBuy}
+	if len(config.GetTariffs()) > 1 {
+		stack = append(stack, fmt.Sprintf("%s?name=%s", CallbackTariff, tariff))
+	}
+	stack = append(stack, fmt.Sprintf("%s?month=%s&tariff=%s", CallbackSell, month, tariff))
+	h.cache.SetString(fmt.Sprintf("nav_%d_%d", chatID, msg.ID), strings.Join(stack, "\n"), navigationStackTTL)
+}
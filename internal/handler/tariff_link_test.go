@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+func setupBuyLinkTariffs(t *testing.T) {
+	t.Helper()
+	t.Cleanup(config.InitConfig)
+	t.Setenv("CRYPTO_PAY_ENABLED", "true")
+	t.Setenv("CRYPTO_PAY_URL", "https://pay.crypt.bot")
+	t.Setenv("CRYPTO_PAY_TOKEN", "token")
+	for _, name := range []string{"PRO", "FAMILY_PLUS"} {
+		t.Setenv("TARIFF_"+name+"_ENABLED", "true")
+		t.Setenv("TARIFF_"+name+"_DEVICES", "3")
+		t.Setenv("TARIFF_"+name+"_PRICE_1", "300")
+		t.Setenv("TARIFF_"+name+"_PRICE_3", "800")
+		t.Setenv("TARIFF_"+name+"_PRICE_6", "0")
+		t.Setenv("TARIFF_"+name+"_PRICE_12", "0")
+	}
+	config.InitConfig()
+}
+
+func TestBuyStartLink(t *testing.T) {
+	setupBuyLinkTariffs(t)
+	if len(config.GetTariffs()) != 2 {
+		t.Fatalf("Expected 2 test tariffs, got %+v", config.GetTariffs())
+	}
+
+	tests := []struct {
+		text        string
+		wantTariff  string
+		wantMonth   string
+		wantChannel string
+		wantOK      bool
+	}{
+		{"/start buy_PRO_3", "PRO", "3", "", true},
+		{"/start buy_pro_1__VK_Ads", "PRO", "1", "vk_ads", true},
+		{"/start buy_FAMILY_PLUS_3", "FAMILY_PLUS", "3", "", true},
+		// Период без цены, неизвестный тариф, ссылка без тарифа и мусор — обычное меню тарифов
+		{"/start buy_PRO_12", "", "", "", false},
+		{"/start buy_BASIC_3", "", "", "", false},
+		{"/start buy_3", "", "", "", false},
+		{"/start buy_PRO_x", "", "", "", false},
+		{"/start buy", "", "", "", false},
+		{"/start tariffs", "", "", "", false},
+	}
+	for _, tt := range tests {
+		tariff, month, channel, ok := buyStartLink(tt.text)
+		if ok != tt.wantOK || tariff != tt.wantTariff || month != tt.wantMonth || channel != tt.wantChannel {
+			t.Errorf("buyStartLink(%q) = %q, %q, %q, %v, want %q, %q, %q, %v", tt.text, tariff, month, channel, ok,
+				tt.wantTariff, tt.wantMonth, tt.wantChannel, tt.wantOK)
+		}
+	}
+}
+
+// Ссылка на оплату показывает способы оплаты и позволяет вернуться "Назад" к выбору периода и тарифа
+func TestSendCheckoutSeedsNavigation(t *testing.T) {
+	setupBuyLinkTariffs(t)
+	b, tg := newTestBot(t)
+	cache := newFakeCache()
+	h := NewPaymentHandlers(fakeTranslator{}, cache, &mockPaymentCustomers{customer: &database.Customer{ID: 7, TelegramID: 42}}, nil, nil, nil, nil, nil)
+
+	h.SendCheckout(context.Background(), b, 42, "ru", "3", "PRO")
+
+	sent := tg.called("sendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("Expected payment methods menu to be sent, got %d messages", len(sent))
+	}
+	if markup := sent[0].params["reply_markup"]; !strings.Contains(markup, CallbackPayment) {
+		t.Errorf("Expected payment method buttons, got %s", markup)
+	}
+
+	stack, ok := cache.GetString("nav_42_1")
+	want := "buy\ntariff?name=PRO\nsell?month=3&tariff=PRO"
+	if !ok || stack != want {
+		t.Errorf("Expected navigation stack %q, got %q", want, stack)
+	}
+}
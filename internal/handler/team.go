@@ -0,0 +1,517 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/activity"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/payment"
+	"remnawave-tg-shop-bot/internal/team"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// TeamCallbackHandler показывает меню командных подписок: варианты количества мест и уже купленные команды
+func (h ProfileHandlers) TeamCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	if !config.IsTeamPlansEnabled() || h.teamService == nil {
+		return
+	}
+
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	customer, err := h.customerRepository.FindByTelegramId(ctx, update.CallbackQuery.From.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for team menu", "error", err)
+		return
+	}
+
+	var keyboard [][]models.InlineKeyboardButton
+	var seatButtons []models.InlineKeyboardButton
+	for _, seats := range config.TeamSeatOptions() {
+		seatButtons = append(seatButtons, models.InlineKeyboardButton{
+			Text:         h.translation.GetTextTemplate(langCode, "team_seats_button", map[string]interface{}{"seats": seats}),
+			CallbackData: fmt.Sprintf("%s?s=%d", CallbackTeamSeats, seats),
+		})
+	}
+	if len(seatButtons) > 0 {
+		keyboard = append(keyboard, seatButtons)
+	}
+
+	teams, err := h.teamService.OwnedTeams(ctx, customer.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading customer teams", "error", err)
+		return
+	}
+	for _, t := range teams {
+		taken, err := h.teamService.CountTakenSeats(ctx, t.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error counting team seats", "error", err, "teamId", t.ID)
+			return
+		}
+		keyboard = append(keyboard, []models.InlineKeyboardButton{{
+			Text: h.translation.GetTextTemplate(langCode, "team_list_button", map[string]interface{}{
+				"taken":  taken,
+				"seats":  t.Seats,
+				"expire": locale.FormatDate(langCode, t.ExpiresAt),
+			}),
+			CallbackData: fmt.Sprintf("%s?id=%d", CallbackTeamView, t.ID),
+		}})
+	}
+
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart},
+	})
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    callback.Chat.ID,
+		MessageID: callback.ID,
+		Text: h.translation.GetTextTemplate(langCode, "team_menu_text", map[string]interface{}{
+			"price": locale.FormatMoney(langCode, float64(config.TeamPrice(1, 1))),
+		}),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending team menu", "error", err)
+	}
+}
+
+// TeamSeatsCallbackHandler показывает сроки командной подписки для выбранного количества мест
+func (h ProfileHandlers) TeamSeatsCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	seats, err := strconv.Atoi(parseCallbackData(update.CallbackQuery.Data)["s"])
+	if err != nil || !config.IsTeamPlansEnabled() || !config.IsTeamSeatOption(seats) {
+		slog.WarnContext(ctx, "Invalid team seats in callback", "data", update.CallbackQuery.Data)
+		return
+	}
+
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+
+	var monthButtons []models.InlineKeyboardButton
+	for _, months := range config.SupportedMonths() {
+		monthButtons = append(monthButtons, models.InlineKeyboardButton{
+			Text: h.translation.GetTextTemplate(langCode, fmt.Sprintf("month_%d", months), map[string]interface{}{
+				"price": locale.FormatMoney(langCode, float64(config.TeamPrice(seats, months))),
+			}),
+			CallbackData: fmt.Sprintf("%s?s=%d&m=%d", CallbackTeamMonths, seats, months),
+		})
+	}
+
+	var keyboard [][]models.InlineKeyboardButton
+	for i := 0; i < len(monthButtons); i += 2 {
+		keyboard = append(keyboard, monthButtons[i:min(i+2, len(monthButtons))])
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
+	})
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      callback.Chat.ID,
+		MessageID:   callback.ID,
+		Text:        h.translation.GetTextTemplate(langCode, "team_select_period_text", map[string]interface{}{"seats": seats}),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending team period menu", "error", err)
+	}
+}
+
+// TeamMonthsCallbackHandler показывает способы оплаты командной подписки.
+// Tribute и автопродление для команд недоступны
+func (h ProfileHandlers) TeamMonthsCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	seats, months, ok := parseTeamPlan(parseCallbackData(update.CallbackQuery.Data))
+	if !ok {
+		slog.WarnContext(ctx, "Invalid team plan in callback", "data", update.CallbackQuery.Data)
+		return
+	}
+
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	payCallback := func(invoiceType database.InvoiceType) string {
+		return SafeCallbackData(fmt.Sprintf("%s?s=%d&m=%d&t=%s", CallbackTeamPayment, seats, months, invoiceType))
+	}
+
+	var keyboard [][]models.InlineKeyboardButton
+	if config.IsCryptoPayEnabled() {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: h.translation.GetText(langCode, "crypto_button"), CallbackData: payCallback(database.InvoiceTypeCrypto)},
+		})
+	}
+	if config.IsYookasaEnabled() {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: h.translation.GetText(langCode, "card_button"), CallbackData: payCallback(database.InvoiceTypeYookasa)},
+		})
+	}
+	if config.IsTelegramStarsEnabled() && config.TeamStarsPrice(seats, months) > 0 {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: h.translation.GetText(langCode, "stars_button"), CallbackData: payCallback(database.InvoiceTypeTelegram)},
+		})
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
+	})
+
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    callback.Chat.ID,
+		MessageID: callback.ID,
+		Text: h.translation.GetTextTemplate(langCode, "team_select_payment_text", map[string]interface{}{
+			"seats":  seats,
+			"months": months,
+			"price":  locale.FormatMoney(langCode, float64(config.TeamPrice(seats, months))),
+		}),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending team payment methods", "error", err)
+	}
+}
+
+// TeamPaymentCallbackHandler создаёт счёт на командную подписку. Цена берётся из конфига, а не из callback data
+func (h ProfileHandlers) TeamPaymentCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	callbackQuery := parseCallbackData(update.CallbackQuery.Data)
+	seats, months, ok := parseTeamPlan(callbackQuery)
+	if !ok {
+		slog.WarnContext(ctx, "Invalid team plan in callback", "data", update.CallbackQuery.Data)
+		return
+	}
+
+	invoiceType := database.InvoiceType(callbackQuery["t"])
+	var price int
+	switch invoiceType {
+	case database.InvoiceTypeCrypto, database.InvoiceTypeYookasa:
+		price = config.TeamPrice(seats, months)
+	case database.InvoiceTypeTelegram:
+		price = config.TeamStarsPrice(seats, months)
+	}
+	if price <= 0 {
+		slog.WarnContext(ctx, "Invalid team purchase price", "price", price, "invoiceType", invoiceType)
+		return
+	}
+
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	customer, err := h.customerRepository.FindByTelegramId(ctx, callback.Chat.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for team purchase", "error", err)
+		return
+	}
+	activity.Record(ctx, customer.TelegramID, database.FunnelEventSelectedPayment)
+
+	ctxWithUsername := context.WithValue(ctx, "username", update.CallbackQuery.From.Username)
+	paymentURL, purchaseId, err := h.paymentService.CreateTeamPurchase(ctxWithUsername, float64(price), months, seats, customer, invoiceType)
+	if errors.Is(err, payment.ErrPurchaseLimitExceeded) {
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: callback.Chat.ID,
+			Text:   h.translation.GetText(langCode, "purchase_limit_exceeded"),
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error sending purchase limit message", "error", err)
+		}
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error creating team payment", "error", err)
+		return
+	}
+
+	message, err := b.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+		ChatID:    callback.Chat.ID,
+		MessageID: callback.ID,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{{
+			{Text: h.translation.GetText(langCode, "pay_button"), URL: paymentURL},
+			{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
+		}}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error updating team payment message", "error", err)
+		return
+	}
+	h.paymentService.SavePurchaseMessage(ctx, purchaseId, message.ID)
+}
+
+// TeamViewCallbackHandler показывает владельцу места команды: ссылки свободных мест и кнопки отзыва занятых
+func (h ProfileHandlers) TeamViewCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	teamID, err := strconv.ParseInt(parseCallbackData(update.CallbackQuery.Data)["id"], 10, 64)
+	if err != nil || h.teamService == nil {
+		return
+	}
+
+	customer, err := h.customerRepository.FindByTelegramId(ctx, update.CallbackQuery.From.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for team view", "error", err)
+		return
+	}
+	h.showTeam(ctx, b, update.CallbackQuery.Message.Message, update.CallbackQuery.From.LanguageCode, customer.ID, teamID, "")
+}
+
+// showTeam выводит места команды в сообщение message. notice добавляется перед списком (результат отзыва)
+func (h ProfileHandlers) showTeam(ctx context.Context, b *bot.Bot, message *models.Message, langCode string, ownerID, teamID int64, notice string) {
+	t, seats, err := h.teamService.OwnedTeam(ctx, ownerID, teamID)
+	if errors.Is(err, team.ErrNotOwner) {
+		slog.WarnContext(ctx, "Team view requested by non-owner", "teamId", teamID, "customerId", utils.MaskHalfInt64(ownerID))
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error loading team", "error", err, "teamId", teamID)
+		return
+	}
+
+	var lines []string
+	var keyboard [][]models.InlineKeyboardButton
+	taken := 0
+	for i, seat := range seats {
+		n := i + 1
+		if seat.IsFree() {
+			lines = append(lines, h.translation.GetTextTemplate(langCode, "team_seat_free", map[string]interface{}{
+				"n":    n,
+				"link": team.InviteLink(seat.Code),
+			}))
+			continue
+		}
+		taken++
+		member := "—"
+		if seat.MemberTelegramID != nil {
+			member = utils.MaskHalfInt64(*seat.MemberTelegramID)
+		}
+		since := ""
+		if seat.RedeemedAt != nil {
+			since = locale.FormatDate(langCode, *seat.RedeemedAt)
+		}
+		lines = append(lines, h.translation.GetTextTemplate(langCode, "team_seat_taken", map[string]interface{}{
+			"n":      n,
+			"member": member,
+			"since":  since,
+		}))
+		keyboard = append(keyboard, []models.InlineKeyboardButton{{
+			Text:         h.translation.GetTextTemplate(langCode, "team_revoke_button", map[string]interface{}{"n": n}),
+			CallbackData: fmt.Sprintf("%s?id=%d", CallbackTeamAskRevoke, seat.ID),
+		}})
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackTeam},
+	})
+
+	text := h.translation.GetTextTemplate(langCode, "team_view_text", map[string]interface{}{
+		"seats":  t.Seats,
+		"taken":  taken,
+		"expire": locale.FormatDate(langCode, t.ExpiresAt),
+		"list":   utils.RawHTML(strings.Join(lines, "\n")),
+	})
+	if notice != "" {
+		text = notice + "\n\n" + text
+	}
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      message.Chat.ID,
+		MessageID:   message.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+		LinkPreviewOptions: &models.LinkPreviewOptions{
+			IsDisabled: bot.True(),
+		},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending team view", "error", err)
+	}
+}
+
+// TeamAskRevokeCallbackHandler просит владельца подтвердить отзыв места
+func (h ProfileHandlers) TeamAskRevokeCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	seatID, err := strconv.ParseInt(parseCallbackData(update.CallbackQuery.Data)["id"], 10, 64)
+	if err != nil || h.teamService == nil {
+		return
+	}
+
+	customer, err := h.customerRepository.FindByTelegramId(ctx, update.CallbackQuery.From.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for team revoke", "error", err)
+		return
+	}
+	seat, t, err := h.teamService.OwnedSeat(ctx, customer.ID, seatID)
+	if err != nil {
+		slog.WarnContext(ctx, "Team seat not available for revoke", "error", err, "seatId", seatID)
+		return
+	}
+
+	langCode := update.CallbackQuery.From.LanguageCode
+	callback := update.CallbackQuery.Message.Message
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    callback.Chat.ID,
+		MessageID: callback.ID,
+		Text:      h.translation.GetText(langCode, "team_revoke_confirm"),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: h.translation.GetText(langCode, "team_revoke_yes_button"), CallbackData: fmt.Sprintf("%s?id=%d", CallbackTeamDoRevoke, seat.ID)}},
+			{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: fmt.Sprintf("%s?id=%d", CallbackTeamView, t.ID)}},
+		}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending team revoke confirmation", "error", err)
+	}
+}
+
+// TeamDoRevokeCallbackHandler отзывает место: участник теряет оставшиеся дни команды и получает уведомление
+func (h ProfileHandlers) TeamDoRevokeCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+	seatID, err := strconv.ParseInt(parseCallbackData(update.CallbackQuery.Data)["id"], 10, 64)
+	if err != nil || h.teamService == nil {
+		return
+	}
+
+	customer, err := h.customerRepository.FindByTelegramId(ctx, update.CallbackQuery.From.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for team revoke", "error", err)
+		return
+	}
+
+	langCode := update.CallbackQuery.From.LanguageCode
+	result, err := h.teamService.Revoke(ctx, customer.ID, seatID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error revoking team seat", "error", err, "seatId", seatID)
+		_, _ = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: update.CallbackQuery.From.ID,
+			Text:   h.translation.GetText(langCode, "team_revoke_error"),
+		})
+		return
+	}
+
+	if result.Member != nil {
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    result.Member.TelegramID,
+			ParseMode: models.ParseModeHTML,
+			Text: h.translation.GetTextTemplate(result.Member.Language, "team_seat_revoked", map[string]interface{}{
+				"days": result.Days,
+			}),
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error notifying member about revoked seat", "error", err)
+		}
+	}
+
+	h.showTeam(ctx, b, update.CallbackQuery.Message.Message, langCode, customer.ID, result.Seat.TeamID,
+		h.translation.GetText(langCode, "team_revoked"))
+}
+
+// redeemTeamSeat активирует место по ссылке-приглашению /start team_<код>
+func (h ProfileHandlers) redeemTeamSeat(ctx context.Context, b *bot.Bot, chatID int64, customer *database.Customer, code, langCode string) {
+	text := h.translation.GetText(langCode, "team_join_error")
+	result, err := h.teamService.Redeem(ctx, customer, code)
+	switch {
+	case errors.Is(err, team.ErrSeatNotFound), errors.Is(err, team.ErrSeatTaken):
+		text = h.translation.GetText(langCode, "team_code_invalid")
+	case errors.Is(err, team.ErrAlreadyMember):
+		text = h.translation.GetText(langCode, "team_already_member")
+	case errors.Is(err, team.ErrTeamExpired):
+		text = h.translation.GetText(langCode, "team_expired")
+	case err != nil:
+		slog.ErrorContext(ctx, "Error redeeming team seat", "error", err)
+	default:
+		text = h.translation.GetTextTemplate(langCode, "team_joined", map[string]interface{}{
+			"days":   result.Days,
+			"expire": locale.FormatDate(langCode, result.NewExpire),
+		})
+		h.notifyTeamOwner(ctx, b, result.Team)
+	}
+
+	_, err = h.sendMenu(ctx, b, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			h.resolveConnectButton(langCode),
+			{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart}},
+		}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending team redeem result", "error", err)
+	}
+}
+
+// notifyTeamOwner сообщает владельцу, что по его приглашению заняли место
+func (h ProfileHandlers) notifyTeamOwner(ctx context.Context, b *bot.Bot, t *database.Team) {
+	owner, err := h.customerRepository.FindById(ctx, t.OwnerCustomerID)
+	if err != nil || owner == nil {
+		slog.ErrorContext(ctx, "Error finding team owner", "error", err, "teamId", t.ID)
+		return
+	}
+	taken, err := h.teamService.CountTakenSeats(ctx, t.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error counting team seats", "error", err, "teamId", t.ID)
+		return
+	}
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    owner.TelegramID,
+		ParseMode: models.ParseModeHTML,
+		Text: h.translation.GetTextTemplate(owner.Language, "team_member_joined", map[string]interface{}{
+			"taken": taken,
+			"seats": t.Seats,
+		}),
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: h.translation.GetText(owner.Language, "team_manage_button"), CallbackData: fmt.Sprintf("%s?id=%d", CallbackTeamView, t.ID)}},
+		}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error notifying team owner", "error", err)
+	}
+}
+
+// parseTeamPlan достаёт из callback data количество мест и срок, проверяя их по конфигу
+func parseTeamPlan(query map[string]string) (seats, months int, ok bool) {
+	seats, err := strconv.Atoi(query["s"])
+	if err != nil {
+		return 0, 0, false
+	}
+	months, err = strconv.Atoi(query["m"])
+	if err != nil {
+		return 0, 0, false
+	}
+	if !config.IsTeamPlansEnabled() || !config.IsTeamSeatOption(seats) || !config.IsSupportedMonth(months) {
+		return 0, 0, false
+	}
+	return seats, months, true
+}
+
+// teamStartCode возвращает код приглашения из команды "/start team_<код>"
+func teamStartCode(text string) (string, bool) {
+	args := strings.Fields(text)
+	if len(args) < 2 {
+		return "", false
+	}
+	code, ok := strings.CutPrefix(args[1], team.StartPrefix)
+	if !ok || code == "" {
+		return "", false
+	}
+	return code, true
+}
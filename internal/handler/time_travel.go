@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+)
+
+// TimeTravelCommandHandler возвращает обработчик скрытой команды админа /time_travel (только для staging).
+// Сдвигает часы бота для проверки истечения предложений и окон уведомлений:
+// /time_travel 72h или 3d — сдвиг относительно настоящего, /time_travel reset — вернуться в настоящее.
+// Запросы, использующие NOW() в SQL, сдвиг не учитывают
+func TimeTravelCommandHandler(c *clock.Offset) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		args := strings.Fields(update.Message.Text)
+		if len(args) > 1 {
+			offset, err := parseTimeOffset(args[1])
+			if err != nil {
+				sendTimeTravelMessage(ctx, b, update.Message.Chat.ID, "❌ Неверный сдвиг. Примеры: <code>/time_travel 72h</code>, <code>/time_travel 3d</code>, <code>/time_travel reset</code>")
+				return
+			}
+			c.Set(offset)
+			slog.WarnContext(ctx, "Clock offset changed", "offset", offset)
+		}
+
+		sendTimeTravelMessage(ctx, b, update.Message.Chat.ID, formatTimeTravelStatus(c.Offset(), c.Now()))
+	}
+}
+
+// parseTimeOffset разбирает сдвиг часов: длительность Go (72h, -30m), дни (3d) или reset
+func parseTimeOffset(s string) (time.Duration, error) {
+	if s == "reset" {
+		return 0, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func formatTimeTravelStatus(offset time.Duration, now time.Time) string {
+	if offset == 0 {
+		return fmt.Sprintf("🕰 Часы бота идут по настоящему времени\n\nСейчас: <code>%s</code>", now.Format(time.DateTime))
+	}
+	return fmt.Sprintf("🕰 Часы бота сдвинуты на <code>%s</code>\n\nСейчас для бота: <code>%s</code>", offset, now.Format(time.DateTime))
+}
+
+func sendTimeTravelMessage(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending time travel message", "error", err)
+	}
+}
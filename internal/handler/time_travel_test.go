@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeOffset(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"reset", 0, false},
+		{"72h", 72 * time.Hour, false},
+		{"-30m", -30 * time.Minute, false},
+		{"3d", 72 * time.Hour, false},
+		{"-1d", -24 * time.Hour, false},
+		{"xd", 0, true},
+		{"tomorrow", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTimeOffset(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTimeOffset(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseTimeOffset(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
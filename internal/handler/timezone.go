@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// timezoneButtonRow возвращает строку с кнопкой выбора часового пояса для экрана подключения или nil.
+// Часовой пояс нужен только для доставки уведомлений в часы NOTIFY_LOCAL_HOURS
+func (h ProfileHandlers) timezoneButtonRow(langCode string) []models.InlineKeyboardButton {
+	if _, _, enabled := config.NotifyLocalHours(); !enabled {
+		return nil
+	}
+	return []models.InlineKeyboardButton{{Text: h.translation.GetText(langCode, "timezone_button"), CallbackData: CallbackTimezone}}
+}
+
+// timezoneMenu возвращает текст и клавиатуру выбора часового пояса. Текущий пояс клиента отмечен галочкой
+func (h ProfileHandlers) timezoneMenu(customer *database.Customer, langCode string) (string, models.InlineKeyboardMarkup) {
+	now := clock.Now()
+	current := locale.FormatTimezone(locale.CustomerLocation(customer.Timezone).String(), now)
+	from, to, _ := config.NotifyLocalHours()
+	text := h.translation.GetTextTemplate(langCode, "timezone_menu", map[string]interface{}{
+		"current": current,
+		"from":    from,
+		"to":      to,
+	})
+
+	var keyboard [][]models.InlineKeyboardButton
+	var row []models.InlineKeyboardButton
+	for _, tz := range locale.Timezones {
+		label := locale.FormatTimezone(tz, now)
+		if customer.Timezone != nil && *customer.Timezone == tz {
+			label = "✅ " + label
+		}
+		row = append(row, models.InlineKeyboardButton{Text: label, CallbackData: CallbackTimezoneSet + "?tz=" + tz})
+		if len(row) == 2 {
+			keyboard = append(keyboard, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		keyboard = append(keyboard, row)
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackConnect},
+	})
+	return text, models.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+}
+
+// TimezoneCommandHandler обрабатывает /timezone: показывает выбор часового пояса для уведомлений
+func (h ProfileHandlers) TimezoneCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	if _, _, enabled := config.NotifyLocalHours(); !enabled {
+		return
+	}
+	customer, err := h.customerRepository.FindByTelegramId(ctx, update.Message.Chat.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for timezone menu", "error", err)
+		return
+	}
+
+	text, keyboard := h.timezoneMenu(customer, update.Message.From.LanguageCode)
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      update.Message.Chat.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: keyboard,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending timezone menu", "error", err)
+	}
+}
+
+// TimezoneCallbackHandler показывает выбор часового пояса вместо экрана подключения
+func (h ProfileHandlers) TimezoneCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	callback := update.CallbackQuery.Message.Message
+	customer, err := h.customerRepository.FindByTelegramId(ctx, callback.Chat.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for timezone menu", "error", err)
+		return
+	}
+	h.editTimezoneMenu(ctx, b, callback, customer, update.CallbackQuery.From.LanguageCode)
+}
+
+// TimezoneSetCallbackHandler сохраняет выбранный клиентом часовой пояс
+func (h ProfileHandlers) TimezoneSetCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	tz := parseCallbackData(update.CallbackQuery.Data)["tz"]
+	if !locale.IsKnownTimezone(tz) {
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+		return
+	}
+
+	customer, err := h.customerRepository.FindByTelegramId(ctx, callback.Chat.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for timezone update", "error", err)
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+		return
+	}
+	if err := h.customerRepository.UpdateFields(ctx, customer.ID, map[string]interface{}{"timezone": tz}); err != nil {
+		slog.ErrorContext(ctx, "Error saving customer timezone", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: update.CallbackQuery.ID})
+		return
+	}
+	customer.Timezone = &tz
+
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            h.translation.GetText(langCode, "timezone_saved"),
+	})
+	h.editTimezoneMenu(ctx, b, callback, customer, langCode)
+}
+
+func (h ProfileHandlers) editTimezoneMenu(ctx context.Context, b *bot.Bot, callback *models.Message, customer *database.Customer, langCode string) {
+	text, keyboard := h.timezoneMenu(customer, langCode)
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      callback.Chat.ID,
+		MessageID:   callback.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: keyboard,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending timezone menu", "error", err)
+	}
+}
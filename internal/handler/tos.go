@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// tosPendingTTL время, в течение которого после принятия условий повторяется исходное действие (секунды)
+const tosPendingTTL = 1800
+
+// TosAcceptanceMiddleware не пускает к активации триала и созданию счёта, пока пользователь не принял
+// текущую версию условий использования. Вместо действия показывается экран принятия условий,
+// а исходный callback запоминается и повторяется после нажатия "Принимаю"
+func (h ProfileHandlers) TosAcceptanceMiddleware(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		if !config.IsTosAcceptanceRequired() || update.CallbackQuery == nil || update.CallbackQuery.Message.Message == nil {
+			next(ctx, b, update)
+			return
+		}
+
+		customer, err := h.customerRepository.FindByTelegramId(ctx, update.CallbackQuery.From.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error finding customer for tos check", "error", err)
+			return
+		}
+		if customer == nil || !customer.NeedsTosAcceptance() {
+			next(ctx, b, update)
+			return
+		}
+
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+		})
+
+		message := update.CallbackQuery.Message.Message
+		h.cache.SetString(tosPendingKey(message), update.CallbackQuery.Data, tosPendingTTL)
+
+		langCode := update.CallbackQuery.From.LanguageCode
+		_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    message.Chat.ID,
+			MessageID: message.ID,
+			Text:      fmt.Sprintf(h.translation.GetText(langCode, "tos_required"), config.TosURL()),
+			ParseMode: models.ParseModeHTML,
+			ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+				{{Text: h.translation.GetText(langCode, "tos_button"), URL: config.TosURL()}},
+				{{Text: h.translation.GetText(langCode, "tos_accept_button"), CallbackData: CallbackTosAccept}},
+				{{Text: h.translation.GetText(langCode, "tos_decline_button"), CallbackData: CallbackTosDecline}},
+			}},
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error sending tos acceptance message", "error", err)
+		}
+	}
+}
+
+// TosAcceptCallbackHandler сохраняет принятие текущей версии условий и повторяет действие,
+// ради которого был показан экран условий
+func (h ProfileHandlers) TosAcceptCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	langCode := update.CallbackQuery.From.LanguageCode
+	customer, err := h.customerRepository.FindByTelegramId(ctx, update.CallbackQuery.From.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for tos acceptance", "error", err)
+		return
+	}
+
+	if err := h.customerRepository.AcceptTos(ctx, customer.ID, config.TosVersion(), clock.Now()); err != nil {
+		slog.ErrorContext(ctx, "Error saving tos acceptance", "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "Terms of service accepted", "telegramId", utils.MaskHalfInt64(customer.TelegramID), "version", config.TosVersion())
+
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+		Text:            h.translation.GetText(langCode, "tos_accepted"),
+	})
+
+	target := CallbackStart
+	if message := update.CallbackQuery.Message.Message; message != nil {
+		if pending, ok := h.cache.GetString(tosPendingKey(message)); ok && pending != "" {
+			target = pending
+		}
+		h.cache.Delete(tosPendingKey(message))
+	}
+
+	callbackQuery := *update.CallbackQuery
+	callbackQuery.Data = target
+	replay := *update
+	replay.CallbackQuery = &callbackQuery
+	b.ProcessUpdate(ctx, &replay)
+}
+
+// TosDeclineCallbackHandler сообщает, что без принятия условий триал и покупка недоступны
+func (h ProfileHandlers) TosDeclineCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	message := update.CallbackQuery.Message.Message
+	if message == nil {
+		return
+	}
+	h.cache.Delete(tosPendingKey(message))
+
+	langCode := update.CallbackQuery.From.LanguageCode
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    message.Chat.ID,
+		MessageID: message.ID,
+		Text:      h.translation.GetText(langCode, "tos_declined"),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: h.translation.GetText(langCode, "tos_accept_button"), CallbackData: CallbackTosAccept}},
+			{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart}},
+		}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending tos declined message", "error", err)
+	}
+}
+
+func tosPendingKey(message *models.Message) string {
+	return fmt.Sprintf("tos_pending_%d_%d", message.Chat.ID, message.ID)
+}
@@ -2,16 +2,22 @@ package handler
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 	"log/slog"
 
+	"remnawave-tg-shop-bot/internal/clock"
 	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/payment"
 	"remnawave-tg-shop-bot/utils"
 )
 
-func (h Handler) TrialCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h ProfileHandlers) TrialCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -21,18 +27,19 @@ func (h Handler) TrialCallbackHandler(ctx context.Context, b *bot.Bot, update *m
 	}
 	c, err := h.customerRepository.FindByTelegramId(ctx, update.CallbackQuery.From.ID)
 	if err != nil {
-		slog.Error("Error finding customer", "error", err)
+		slog.ErrorContext(ctx, "Error finding customer", "error", err)
 		return
 	}
 	if c == nil {
-		slog.Error("customer not exist", "telegramId", utils.MaskHalfInt64(update.CallbackQuery.From.ID), "error", err)
-		return
-	}
-	if c.SubscriptionLink != nil {
+		slog.ErrorContext(ctx, "customer not exist", "telegramId", utils.MaskHalfInt64(update.CallbackQuery.From.ID), "error", err)
 		return
 	}
 	callback := update.CallbackQuery.Message.Message
 	langCode := update.CallbackQuery.From.LanguageCode
+	if eligibility := h.trialEligibility(ctx, c); !eligibility.Allowed {
+		h.showTrialUnavailable(ctx, b, callback, langCode, eligibility)
+		return
+	}
 	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
 		ChatID:    callback.Chat.ID,
 		MessageID: callback.ID,
@@ -40,15 +47,15 @@ func (h Handler) TrialCallbackHandler(ctx context.Context, b *bot.Bot, update *m
 		ParseMode: models.ParseModeHTML,
 		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
 			{{Text: h.translation.GetText(langCode, "activate_trial_button"), CallbackData: CallbackActivateTrial}},
-			{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart}},
+			{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack}},
 		}},
 	})
 	if err != nil {
-		slog.Error("Error sending /trial message", "error", err)
+		slog.ErrorContext(ctx, "Error sending /trial message", "error", err)
 	}
 }
 
-func (h Handler) ActivateTrialCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h ProfileHandlers) ActivateTrialCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -58,20 +65,37 @@ func (h Handler) ActivateTrialCallbackHandler(ctx context.Context, b *bot.Bot, u
 	}
 	c, err := h.customerRepository.FindByTelegramId(ctx, update.CallbackQuery.From.ID)
 	if err != nil {
-		slog.Error("Error finding customer", "error", err)
+		slog.ErrorContext(ctx, "Error finding customer", "error", err)
 		return
 	}
 	if c == nil {
-		slog.Error("customer not exist", "telegramId", utils.MaskHalfInt64(update.CallbackQuery.From.ID), "error", err)
+		slog.ErrorContext(ctx, "customer not exist", "telegramId", utils.MaskHalfInt64(update.CallbackQuery.From.ID), "error", err)
 		return
 	}
-	if c.SubscriptionLink != nil {
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+	if eligibility := h.trialEligibility(ctx, c); !eligibility.Allowed {
+		h.showTrialUnavailable(ctx, b, callback, langCode, eligibility)
 		return
 	}
-	callback := update.CallbackQuery.Message.Message
 	ctxWithUsername := context.WithValue(ctx, "username", update.CallbackQuery.From.Username)
 	_, err = h.paymentService.ActivateTrial(ctxWithUsername, update.CallbackQuery.From.ID)
-	langCode := update.CallbackQuery.From.LanguageCode
+	if err != nil {
+		slog.ErrorContext(ctx, "Error activating trial", "error", err, "telegramId", utils.MaskHalfInt64(update.CallbackQuery.From.ID))
+		_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:    callback.Chat.ID,
+			MessageID: callback.ID,
+			Text:      h.translation.GetText(langCode, "trial_activation_error"),
+			ParseMode: models.ParseModeHTML,
+			ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+				{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack}},
+			}},
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error sending trial activation error", "error", err)
+		}
+		return
+	}
 	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
 		ChatID:      callback.Chat.ID,
 		MessageID:   callback.ID,
@@ -80,16 +104,147 @@ func (h Handler) ActivateTrialCallbackHandler(ctx context.Context, b *bot.Bot, u
 		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: h.createConnectKeyboard(langCode)},
 	})
 	if err != nil {
-		slog.Error("Error sending /trial message", "error", err)
+		slog.ErrorContext(ctx, "Error sending /trial message", "error", err)
+	}
+}
+
+// trialStartPayload параметр рекламной ссылки на триал: /start trial или /start trial_<источник>
+const trialStartPayload = "trial"
+
+// isTrialStartLink возвращает true для рекламной ссылки, по которой триал активируется сразу, без меню
+func isTrialStartLink(text string) bool {
+	args := strings.Fields(text)
+	return len(args) >= 2 && (args[1] == trialStartPayload || strings.HasPrefix(args[1], trialStartPayload+"_"))
+}
+
+// activateTrialFromStart активирует триал по рекламной ссылке: отправляет сообщение, которое станет
+// инструкцией подключения, и обрабатывает его как нажатие "Активировать" — с теми же проверками
+// (условия использования, номер телефона, повторный триал), что и кнопка в меню
+func (h ProfileHandlers) activateTrialFromStart(ctx context.Context, b *bot.Bot, update *models.Update) {
+	langCode := update.Message.From.LanguageCode
+	msg, err := h.sendMenu(ctx, b, &bot.SendMessageParams{
+		ChatID:    update.Message.Chat.ID,
+		Text:      h.translation.GetText(langCode, "trial_activating"),
+		ParseMode: models.ParseModeHTML,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending trial activation message", "error", err)
+		return
+	}
+
+	b.ProcessUpdate(ctx, &models.Update{
+		ID: update.ID,
+		CallbackQuery: &models.CallbackQuery{
+			From:    *update.Message.From,
+			Message: models.MaybeInaccessibleMessage{Type: models.MaybeInaccessibleMessageTypeMessage, Message: msg},
+			Data:    CallbackActivateTrial,
+		},
+	})
+}
+
+// trialEligibility проверяет политику повторного триала. Ошибка проверки логируется, триал при этом не предлагается
+func (h ProfileHandlers) trialEligibility(ctx context.Context, customer *database.Customer) payment.TrialEligibility {
+	eligibility, err := h.paymentService.CheckTrialEligibility(ctx, customer)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error checking trial eligibility", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		return payment.TrialEligibility{}
+	}
+	return eligibility
+}
+
+// showTrialUnavailable объясняет клиенту, почему триал сейчас недоступен, и предлагает оформить подписку
+func (h ProfileHandlers) showTrialUnavailable(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, eligibility payment.TrialEligibility) {
+	var text string
+	keyboard := [][]models.InlineKeyboardButton{
+		{{Text: h.translation.GetText(langCode, "buy_button"), CallbackData: CallbackBuy}},
+	}
+	switch eligibility.Reason {
+	case payment.TrialDeniedActive:
+		text = h.translation.GetText(langCode, "trial_unavailable_active")
+		keyboard = nil
+	case payment.TrialDeniedCooldown:
+		text = h.translation.GetTextTemplate(langCode, "trial_unavailable_cooldown", map[string]interface{}{
+			"date": eligibility.AvailableAt.Format("02.01.2006"),
+		})
+	default:
+		text = h.translation.GetText(langCode, "trial_unavailable_used")
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
+	})
+
+	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:      callback.Chat.ID,
+		MessageID:   callback.ID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending trial unavailable message", "error", err)
+	}
+}
+
+// GrantTrialCommandHandler обрабатывает команду админа /grant_trial <telegram_id>: разрешает клиенту
+// ещё один триал в обход TRIAL_POLICY. Разрешение действует до следующей активации триала
+func (h AdminHandlers) GrantTrialCommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	args := strings.Fields(update.Message.Text)
+	if len(args) != 2 {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Использование: <code>/grant_trial &lt;telegram_id&gt;</code>")
+		return
+	}
+	telegramID, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Неверный telegram_id")
+		return
+	}
+	if config.TrialDays() == 0 {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Триал выключен (TRIAL_DAYS=0)")
+		return
+	}
+
+	customer, ok := h.findSupportCustomer(ctx, b, update.Message.Chat.ID, telegramID)
+	if !ok {
+		return
+	}
+	now := clock.Now()
+	if customer.ExpireAt != nil && customer.ExpireAt.After(now) {
+		h.sendAdminText(ctx, b, update.Message.Chat.ID,
+			fmt.Sprintf("❌ У пользователя действующая подписка до %s, триал можно выдать после её окончания", customer.ExpireAt.Format("02.01.2006 15:04")))
+		return
+	}
+
+	if err := h.customerRepository.UpdateFields(ctx, customer.ID, map[string]interface{}{"trial_regranted_at": now}); err != nil {
+		slog.ErrorContext(ctx, "Error granting trial", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		h.sendAdminText(ctx, b, update.Message.Chat.ID, "❌ Не удалось выдать триал")
+		return
+	}
+	customer.TrialRegrantedAt = &now
+	h.recordAudit(ctx, update.Message.From.ID, customer.ID, database.AuditActionTrialRegranted, "")
+
+	langCode := customer.Language
+	_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    customer.TelegramID,
+		Text:      h.translation.GetText(langCode, "trial_regranted"),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: h.translation.GetText(langCode, "trial_button"), CallbackData: CallbackTrial}},
+		}},
+	})
+	result := "✅ Триал выдан повторно, пользователь получил уведомление"
+	if err != nil {
+		slog.ErrorContext(ctx, "Error notifying customer about granted trial", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		result = "⚠️ Триал выдан повторно, но уведомить пользователя не удалось"
 	}
+	h.sendAdminText(ctx, b, update.Message.Chat.ID, result+"\n\n"+h.supportCard(ctx, customer))
 }
 
-func (h Handler) createConnectKeyboard(lang string) [][]models.InlineKeyboardButton {
+func (h ProfileHandlers) createConnectKeyboard(lang string) [][]models.InlineKeyboardButton {
 	var inlineCustomerKeyboard [][]models.InlineKeyboardButton
 	inlineCustomerKeyboard = append(inlineCustomerKeyboard, h.resolveConnectButton(lang))
 
 	inlineCustomerKeyboard = append(inlineCustomerKeyboard, []models.InlineKeyboardButton{
-		{Text: h.translation.GetText(lang, "back_button"), CallbackData: CallbackStart},
+		{Text: h.translation.GetText(lang, "back_button"), CallbackData: CallbackBack},
 	})
 	return inlineCustomerKeyboard
 }
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// TrialPhoneVerificationMiddleware не пускает к активации триала, пока пользователь не поделился своим номером
+// телефона. Вместо активации отправляется сообщение с кнопкой Telegram "Поделиться контактом"
+func (h ProfileHandlers) TrialPhoneVerificationMiddleware(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		if !config.IsTrialPhoneVerificationEnabled() || update.CallbackQuery == nil || update.CallbackQuery.Message.Message == nil {
+			next(ctx, b, update)
+			return
+		}
+
+		customer, err := h.customerRepository.FindByTelegramId(ctx, update.CallbackQuery.From.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error finding customer for phone verification", "error", err)
+			return
+		}
+		// Без клиента или с уже выданной подпиской обработчик триала сам ничего не сделает
+		if customer == nil || customer.SubscriptionLink != nil {
+			next(ctx, b, update)
+			return
+		}
+
+		verified, err := h.customerRepository.IsPhoneVerified(ctx, customer.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error checking phone verification", "error", err)
+			return
+		}
+		if verified {
+			next(ctx, b, update)
+			return
+		}
+
+		_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+			CallbackQueryID: update.CallbackQuery.ID,
+		})
+
+		langCode := update.CallbackQuery.From.LanguageCode
+		_, err = b.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    update.CallbackQuery.Message.Message.Chat.ID,
+			Text:      h.translation.GetText(langCode, "trial_phone_required"),
+			ParseMode: models.ParseModeHTML,
+			ReplyMarkup: &models.ReplyKeyboardMarkup{
+				Keyboard: [][]models.KeyboardButton{
+					{{Text: h.translation.GetText(langCode, "trial_phone_button"), RequestContact: true}},
+				},
+				ResizeKeyboard:  true,
+				OneTimeKeyboard: true,
+			},
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error sending phone request message", "error", err)
+		}
+	}
+}
+
+// TrialPhoneContactHandler принимает контакт, отправленный кнопкой запроса номера, сохраняет HMAC номера
+// и снова предлагает активировать триал. Номер, уже подтверждённый другим аккаунтом, отклоняется
+func (h ProfileHandlers) TrialPhoneContactHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	msg := update.Message
+	if !config.IsTrialPhoneVerificationEnabled() {
+		return
+	}
+	langCode := msg.From.LanguageCode
+
+	// Принимаем только собственный номер: чужой контакт можно переслать вручную
+	if msg.Contact.UserID != msg.From.ID {
+		h.sendPhoneResult(ctx, b, msg.Chat.ID, h.translation.GetText(langCode, "trial_phone_not_own"), false)
+		return
+	}
+	defer h.deleteUserMessage(ctx, b, msg, config.CleanChatMenus)
+
+	customer, err := h.customerRepository.FindByTelegramId(ctx, msg.From.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for phone contact", "error", err)
+		return
+	}
+
+	err = h.customerRepository.SavePhoneHash(ctx, customer.ID, hashPhone(config.TrialPhoneHashSecret(), msg.Contact.PhoneNumber), clock.Now())
+	if errors.Is(err, database.ErrPhoneAlreadyUsed) {
+		slog.WarnContext(ctx, "Trial phone already used by another customer", "telegramId", utils.MaskHalfInt64(msg.From.ID))
+		h.sendPhoneResult(ctx, b, msg.Chat.ID, h.translation.GetText(langCode, "trial_phone_already_used"), true)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving phone hash", "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "Trial phone verified", "telegramId", utils.MaskHalfInt64(msg.From.ID))
+
+	h.sendPhoneResult(ctx, b, msg.Chat.ID, h.translation.GetText(langCode, "trial_phone_verified"), true)
+	if config.TrialDays() == 0 || !h.trialEligibility(ctx, customer).Allowed {
+		return
+	}
+	_, err = h.sendMenu(ctx, b, &bot.SendMessageParams{
+		ChatID:    msg.Chat.ID,
+		Text:      h.translation.GetText(langCode, "trial_text"),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: h.translation.GetText(langCode, "activate_trial_button"), CallbackData: CallbackActivateTrial}},
+			{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart}},
+		}},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending trial message after phone verification", "error", err)
+	}
+}
+
+// sendPhoneResult сообщает результат проверки номера. removeKeyboard убирает клавиатуру с кнопкой контакта
+func (h ProfileHandlers) sendPhoneResult(ctx context.Context, b *bot.Bot, chatID int64, text string, removeKeyboard bool) {
+	params := &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+	}
+	if removeKeyboard {
+		params.ReplyMarkup = &models.ReplyKeyboardRemove{RemoveKeyboard: true}
+	}
+	if _, err := b.SendMessage(ctx, params); err != nil {
+		slog.ErrorContext(ctx, "Error sending phone verification result", "error", err)
+	}
+}
+
+// hashPhone возвращает HMAC-SHA256 номера, приведённого к цифрам: "+7 (999) 123-45-67" и "79991234567" совпадают.
+// Обычный хэш короткого номера легко подобрать перебором, поэтому используется ключ
+func hashPhone(secret, phone string) string {
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(digits.String()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,21 @@
+package handler
+
+import "testing"
+
+func TestHashPhone(t *testing.T) {
+	hash := hashPhone("secret", "79991234567")
+	if len(hash) != 64 {
+		t.Fatalf("Expected hex SHA-256 (64 chars), got %d", len(hash))
+	}
+	for _, phone := range []string{"+79991234567", "+7 (999) 123-45-67"} {
+		if got := hashPhone("secret", phone); got != hash {
+			t.Errorf("Expected %q to hash the same as digits only", phone)
+		}
+	}
+	if hashPhone("other", "79991234567") == hash {
+		t.Error("Expected different secrets to produce different hashes")
+	}
+	if hashPhone("secret", "79991234568") == hash {
+		t.Error("Expected different numbers to produce different hashes")
+	}
+}
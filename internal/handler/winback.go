@@ -9,6 +9,7 @@ import (
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 
+	"remnawave-tg-shop-bot/internal/clock"
 	"remnawave-tg-shop-bot/internal/config"
 	"remnawave-tg-shop-bot/internal/database"
 	"remnawave-tg-shop-bot/utils"
@@ -17,7 +18,7 @@ import (
 // WinbackCallbackHandler обрабатывает активацию winback предложения
 // Показывает кнопки оплаты с ценой из winback предложения
 // Requirements: 3.4, 3.5
-func (h Handler) WinbackCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+func (h PaymentHandlers) WinbackCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
 	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
 		CallbackQueryID: update.CallbackQuery.ID,
 	})
@@ -29,25 +30,25 @@ func (h Handler) WinbackCallbackHandler(ctx context.Context, b *bot.Bot, update
 	// Получаем customer
 	customer, err := h.customerRepository.FindByTelegramId(ctx, telegramID)
 	if err != nil {
-		slog.Error("Error finding customer for winback", "error", err, "telegramId", utils.MaskHalfInt64(telegramID))
+		slog.ErrorContext(ctx, "Error finding customer for winback", "error", err, "telegramId", utils.MaskHalfInt64(telegramID))
 		return
 	}
 	if customer == nil {
-		slog.Error("Customer not found for winback", "telegramId", utils.MaskHalfInt64(telegramID))
+		slog.ErrorContext(ctx, "Customer not found for winback", "telegramId", utils.MaskHalfInt64(telegramID))
 		return
 	}
 
 	// Проверяем наличие winback предложения
 	if customer.WinbackOfferSentAt == nil {
-		slog.Warn("No winback offer for customer", "customerId", utils.MaskHalfInt64(customer.ID))
+		slog.WarnContext(ctx, "No winback offer for customer", "customerId", utils.MaskHalfInt64(customer.ID))
 		h.sendWinbackError(ctx, b, callback, langCode, "winback_no_offer")
 		return
 	}
 
 	// Проверяем срок действия предложения (Property 4: Winback Offer Activation Validity)
 	// Предложение действительно только когда WinbackOfferExpiresAt > current time
-	if !IsWinbackOfferValid(customer.WinbackOfferExpiresAt, time.Now()) {
-		slog.Info("Winback offer expired", "customerId", utils.MaskHalfInt64(customer.ID),
+	if !IsWinbackOfferValid(customer.WinbackOfferExpiresAt, clock.Now()) {
+		slog.InfoContext(ctx, "Winback offer expired", "customerId", utils.MaskHalfInt64(customer.ID),
 			"expiresAt", customer.WinbackOfferExpiresAt)
 		h.sendWinbackExpired(ctx, b, callback, langCode)
 		return
@@ -58,18 +59,51 @@ func (h Handler) WinbackCallbackHandler(ctx context.Context, b *bot.Bot, update
 	months := customer.WinbackOfferMonths
 
 	if price == nil || months == nil {
-		slog.Error("Winback offer has nil parameters", "customerId", utils.MaskHalfInt64(customer.ID))
+		slog.ErrorContext(ctx, "Winback offer has nil parameters", "customerId", utils.MaskHalfInt64(customer.ID))
 		h.sendWinbackError(ctx, b, callback, langCode, "winback_error")
 		return
 	}
 
-	slog.Info("Showing winback payment options",
+	slog.InfoContext(ctx, "Showing winback payment options",
 		"customerId", utils.MaskHalfInt64(customer.ID),
 		"price", *price,
 		"months", *months)
 
 	// Показываем кнопки оплаты (как в SellCallbackHandler)
-	h.showWinbackPaymentOptions(ctx, b, callback, langCode, *price, *months)
+	h.showWinbackPaymentOptions(ctx, b, callback, langCode, *months)
+}
+
+// WinbackOptOutCallbackHandler отключает предложения ушедшим клиентам по кнопке из сообщения с предложением
+func (h PaymentHandlers) WinbackOptOutCallbackHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	_, _ = b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: update.CallbackQuery.ID,
+	})
+
+	callback := update.CallbackQuery.Message.Message
+	langCode := update.CallbackQuery.From.LanguageCode
+
+	customer, err := h.customerRepository.FindByTelegramId(ctx, update.CallbackQuery.From.ID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for winback opt-out", "error", err, "telegramId", utils.MaskHalfInt64(update.CallbackQuery.From.ID))
+		return
+	}
+
+	if err := h.winbackRepository.OptOut(ctx, customer.ID, clock.Now()); err != nil {
+		slog.ErrorContext(ctx, "Error opting out of winback", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+		h.sendWinbackError(ctx, b, callback, langCode, "winback_error")
+		return
+	}
+	slog.InfoContext(ctx, "Customer opted out of winback offers", "customerId", utils.MaskHalfInt64(customer.ID))
+
+	_, err = b.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    callback.Chat.ID,
+		MessageID: callback.ID,
+		Text:      h.translation.GetText(langCode, "winback_opted_out"),
+		ParseMode: models.ParseModeHTML,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending winback opt-out message", "error", err)
+	}
 }
 
 // IsWinbackOfferValid проверяет действительность winback предложения
@@ -117,10 +151,10 @@ func ExtractWinbackPurchaseParams(
 
 // showWinbackPaymentOptions показывает кнопки оплаты для winback предложения
 // Аналогично SellCallbackHandler, но с параметрами из winback
-func (h Handler) showWinbackPaymentOptions(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, price int, months int) {
+func (h PaymentHandlers) showWinbackPaymentOptions(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, months int) {
 	// Формируем callback для оплаты с пометкой winback (короткие ключи для лимита 64 байта)
 	buildPaymentCallback := func(invoiceType database.InvoiceType) string {
-		return fmt.Sprintf("%s?m=%d&t=%s&a=%d&w=1", CallbackPayment, months, invoiceType, price)
+		return fmt.Sprintf("%s?m=%d&t=%s&w=1", CallbackPayment, months, invoiceType)
 	}
 
 	var keyboard [][]models.InlineKeyboardButton
@@ -145,7 +179,7 @@ func (h Handler) showWinbackPaymentOptions(ctx context.Context, b *bot.Bot, call
 
 
 	keyboard = append(keyboard, []models.InlineKeyboardButton{
-		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart},
+		{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack},
 	})
 
 	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
@@ -159,12 +193,12 @@ func (h Handler) showWinbackPaymentOptions(ctx context.Context, b *bot.Bot, call
 	})
 
 	if err != nil {
-		slog.Error("Error showing winback payment options", "error", err)
+		slog.ErrorContext(ctx, "Error showing winback payment options", "error", err)
 	}
 }
 
 // sendWinbackExpired отправляет сообщение об истечении срока предложения
-func (h Handler) sendWinbackExpired(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string) {
+func (h PaymentHandlers) sendWinbackExpired(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string) {
 	_, err := b.EditMessageText(ctx, &bot.EditMessageTextParams{
 		ChatID:    callback.Chat.ID,
 		MessageID: callback.ID,
@@ -173,17 +207,17 @@ func (h Handler) sendWinbackExpired(ctx context.Context, b *bot.Bot, callback *m
 		ReplyMarkup: models.InlineKeyboardMarkup{
 			InlineKeyboard: [][]models.InlineKeyboardButton{
 				{{Text: h.translation.GetText(langCode, "buy_button"), CallbackData: CallbackBuy}},
-				{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart}},
+				{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack}},
 			},
 		},
 	})
 	if err != nil {
-		slog.Error("Error sending winback expired message", "error", err)
+		slog.ErrorContext(ctx, "Error sending winback expired message", "error", err)
 	}
 }
 
 // sendWinbackError отправляет сообщение об ошибке
-func (h Handler) sendWinbackError(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, errorKey string) {
+func (h PaymentHandlers) sendWinbackError(ctx context.Context, b *bot.Bot, callback *models.Message, langCode string, errorKey string) {
 	text := h.translation.GetText(langCode, errorKey)
 	if text == "" {
 		text = h.translation.GetText(langCode, "winback_error")
@@ -196,11 +230,11 @@ func (h Handler) sendWinbackError(ctx context.Context, b *bot.Bot, callback *mod
 		ParseMode: models.ParseModeHTML,
 		ReplyMarkup: models.InlineKeyboardMarkup{
 			InlineKeyboard: [][]models.InlineKeyboardButton{
-				{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackStart}},
+				{{Text: h.translation.GetText(langCode, "back_button"), CallbackData: CallbackBack}},
 			},
 		},
 	})
 	if err != nil {
-		slog.Error("Error sending winback error message", "error", err)
+		slog.ErrorContext(ctx, "Error sending winback error message", "error", err)
 	}
 }
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"remnawave-tg-shop-bot/internal/alert"
+	"remnawave-tg-shop-bot/internal/payment"
+	"remnawave-tg-shop-bot/internal/yookasa"
+)
+
+type yookasaPaymentGetter interface {
+	GetPayment(ctx context.Context, paymentID uuid.UUID) (*yookasa.Payment, error)
+}
+
+type paymentEventProcessor interface {
+	ProcessYookassaRefund(ctx context.Context, invoice *yookasa.Payment) error
+	ProcessYookassaCancellation(ctx context.Context, invoice *yookasa.Payment) error
+}
+
+// YookasaWebhookHandler обрабатывает HTTP-уведомления ЮKassa о возвратах и отменённых платежах
+type YookasaWebhookHandler struct {
+	yookasa  yookasaPaymentGetter
+	payments paymentEventProcessor
+	// testShop тестовый магазин ЮKassa для тестовых покупок админа (может быть nil)
+	testShop yookasaPaymentGetter
+}
+
+// NewYookasaWebhookHandler создаёт handler уведомлений ЮKassa
+func NewYookasaWebhookHandler(client yookasaPaymentGetter, payments paymentEventProcessor) *YookasaWebhookHandler {
+	return &YookasaWebhookHandler{yookasa: client, payments: payments}
+}
+
+// SetTestShopClient подключает тестовый магазин ЮKassa: платежи, не найденные в основном магазине, ищутся в нём
+func (h *YookasaWebhookHandler) SetTestShopClient(client yookasaPaymentGetter) {
+	h.testShop = client
+}
+
+// HandleWebhook принимает уведомления refund.succeeded и payment.canceled. Уведомления ЮKassa не подписываются,
+// поэтому данные платежа перечитываются через API, а содержимое уведомления используется только как ID.
+// При ошибке обработки возвращается 500 — ЮKassa повторит уведомление
+func (h *YookasaWebhookHandler) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		slog.Error("Failed to read yookasa notification body", "error", err)
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	var notification yookasa.Notification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		slog.Error("Failed to parse yookasa notification", "error", err)
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	switch notification.Event {
+	case yookasa.EventRefundSucceeded:
+		err = h.processRefund(ctx, notification.Object)
+	case yookasa.EventPaymentCanceled:
+		err = h.processCancellation(ctx, notification.Object.ID)
+	default:
+		slog.InfoContext(ctx, "Ignoring yookasa notification", "event", notification.Event)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to process yookasa notification", "event", notification.Event, "objectId", notification.Object.ID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+func (h *YookasaWebhookHandler) processRefund(ctx context.Context, refund yookasa.Refund) error {
+	invoice, err := h.getPayment(ctx, refund.PaymentID)
+	if err != nil {
+		return fmt.Errorf("get payment: %w", err)
+	}
+	if invoice.RefundedValue() <= 0 {
+		slog.WarnContext(ctx, "Refund notification for payment without refunds", "paymentId", refund.PaymentID, "refundId", refund.ID)
+		return nil
+	}
+
+	err = h.payments.ProcessYookassaRefund(ctx, invoice)
+	if errors.Is(err, payment.ErrPurchaseNotFound) || errors.Is(err, payment.ErrCustomerNotFound) {
+		// Например, возврат автосписания — для него нет покупки, период нужно сократить вручную
+		alert.Notify(ctx, alert.SeverityCritical, fmt.Sprintf("%s:%s", alert.KeyPaymentRefund, invoice.ID),
+			fmt.Sprintf("Возврат по платежу ЮKassa %s на %s ₽ не сопоставлен с покупкой (%v). Проверьте подписку вручную",
+				invoice.ID, invoice.RefundedAmount.Value, err))
+		return nil
+	}
+	return err
+}
+
+// getPayment загружает платёж из основного магазина, а если его там нет — из тестового
+func (h *YookasaWebhookHandler) getPayment(ctx context.Context, paymentID uuid.UUID) (*yookasa.Payment, error) {
+	invoice, err := h.yookasa.GetPayment(ctx, paymentID)
+	if err != nil && h.testShop != nil {
+		invoice, err = h.testShop.GetPayment(ctx, paymentID)
+	}
+	return invoice, err
+}
+
+// processCancellation сообщает пользователю причину отмены платежа. Автосписания и платежи без покупки пропускаются
+func (h *YookasaWebhookHandler) processCancellation(ctx context.Context, objectID string) error {
+	paymentID, err := uuid.Parse(objectID)
+	if err != nil {
+		slog.WarnContext(ctx, "Invalid payment id in yookasa notification", "objectId", objectID)
+		return nil
+	}
+	invoice, err := h.getPayment(ctx, paymentID)
+	if err != nil {
+		return fmt.Errorf("get payment: %w", err)
+	}
+	if !invoice.IsCancelled() || invoice.IsRecurring() {
+		return nil
+	}
+
+	err = h.payments.ProcessYookassaCancellation(ctx, invoice)
+	if errors.Is(err, payment.ErrPurchaseNotFound) {
+		return nil
+	}
+	return err
+}
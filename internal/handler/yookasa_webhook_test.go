@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"remnawave-tg-shop-bot/internal/payment"
+	"remnawave-tg-shop-bot/internal/yookasa"
+)
+
+type mockPaymentGetter struct {
+	payment *yookasa.Payment
+	err     error
+	calls   int
+}
+
+func (m *mockPaymentGetter) GetPayment(ctx context.Context, paymentID uuid.UUID) (*yookasa.Payment, error) {
+	m.calls++
+	return m.payment, m.err
+}
+
+type mockRefundProcessor struct {
+	processed []*yookasa.Payment
+	cancelled []*yookasa.Payment
+	err       error
+}
+
+func (m *mockRefundProcessor) ProcessYookassaRefund(ctx context.Context, invoice *yookasa.Payment) error {
+	m.processed = append(m.processed, invoice)
+	return m.err
+}
+
+func (m *mockRefundProcessor) ProcessYookassaCancellation(ctx context.Context, invoice *yookasa.Payment) error {
+	m.cancelled = append(m.cancelled, invoice)
+	return m.err
+}
+
+func TestYookasaWebhookRefund(t *testing.T) {
+	paymentID := uuid.New()
+	refundNotification := `{"type":"notification","event":"refund.succeeded","object":{"id":"r1","payment_id":"` + paymentID.String() + `","status":"succeeded","amount":{"value":"100.00","currency":"RUB"}}}`
+	refunded := &yookasa.Payment{ID: paymentID, RefundedAmount: &yookasa.Amount{Value: "100.00", Currency: "RUB"}}
+
+	tests := []struct {
+		name          string
+		body          string
+		payment       *yookasa.Payment
+		processErr    error
+		wantStatus    int
+		wantProcessed int
+	}{
+		{"refund processed", refundNotification, refunded, nil, http.StatusOK, 1},
+		{"other events are ignored", `{"type":"notification","event":"payment.succeeded","object":{}}`, refunded, nil, http.StatusOK, 0},
+		{"payment without refunds is not processed", refundNotification, &yookasa.Payment{ID: paymentID}, nil, http.StatusOK, 0},
+		{"unknown purchase is acknowledged", refundNotification, refunded, payment.ErrPurchaseNotFound, http.StatusOK, 1},
+		{"processing error is retried", refundNotification, refunded, errors.New("remnawave down"), http.StatusInternalServerError, 1},
+		{"invalid json", `{`, refunded, nil, http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getter := &mockPaymentGetter{payment: tt.payment}
+			processor := &mockRefundProcessor{err: tt.processErr}
+			h := NewYookasaWebhookHandler(getter, processor)
+
+			rec := httptest.NewRecorder()
+			h.HandleWebhook(rec, httptest.NewRequest(http.MethodPost, "/yookasa-webhook", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if len(processor.processed) != tt.wantProcessed {
+				t.Fatalf("Expected %d processed refunds, got %d", tt.wantProcessed, len(processor.processed))
+			}
+			// Обрабатываются данные платежа из API, а не из тела уведомления
+			if tt.wantProcessed > 0 && processor.processed[0] != tt.payment {
+				t.Error("Expected refund to be processed with payment loaded from API")
+			}
+		})
+	}
+}
+
+func TestYookasaWebhookRefundFromTestShop(t *testing.T) {
+	paymentID := uuid.New()
+	body := `{"type":"notification","event":"refund.succeeded","object":{"id":"r1","payment_id":"` + paymentID.String() + `","status":"succeeded","amount":{"value":"100.00","currency":"RUB"}}}`
+	refunded := &yookasa.Payment{ID: paymentID, RefundedAmount: &yookasa.Amount{Value: "100.00", Currency: "RUB"}}
+
+	processor := &mockRefundProcessor{}
+	h := NewYookasaWebhookHandler(&mockPaymentGetter{err: errors.New("payment not found")}, processor)
+	testShop := &mockPaymentGetter{payment: refunded}
+	h.SetTestShopClient(testShop)
+
+	rec := httptest.NewRecorder()
+	h.HandleWebhook(rec, httptest.NewRequest(http.MethodPost, "/yookasa-webhook", strings.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+	if testShop.calls != 1 || len(processor.processed) != 1 {
+		t.Errorf("Expected refund to be loaded from test shop, got %d calls and %d processed", testShop.calls, len(processor.processed))
+	}
+}
+
+func TestYookasaWebhookPaymentCanceled(t *testing.T) {
+	paymentID := uuid.New()
+	body := `{"type":"notification","event":"payment.canceled","object":{"id":"` + paymentID.String() + `","status":"canceled"}}`
+	cancelled := &yookasa.Payment{ID: paymentID, Status: "canceled", CancellationDetails: &yookasa.CancellationDetails{Reason: "insufficient_funds"}}
+	recurring := &yookasa.Payment{ID: paymentID, Status: "canceled", Metadata: map[string]string{"recurring_payment": "true"}}
+
+	tests := []struct {
+		name          string
+		body          string
+		payment       *yookasa.Payment
+		processErr    error
+		wantStatus    int
+		wantCancelled int
+	}{
+		{"cancellation processed", body, cancelled, nil, http.StatusOK, 1},
+		{"recurring payment is skipped", body, recurring, nil, http.StatusOK, 0},
+		{"payment not cancelled in API", body, &yookasa.Payment{ID: paymentID, Status: "pending"}, nil, http.StatusOK, 0},
+		{"unknown purchase is acknowledged", body, cancelled, payment.ErrPurchaseNotFound, http.StatusOK, 1},
+		{"processing error is retried", body, cancelled, errors.New("db down"), http.StatusInternalServerError, 1},
+		{"invalid payment id", `{"type":"notification","event":"payment.canceled","object":{"id":"x"}}`, cancelled, nil, http.StatusOK, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := &mockRefundProcessor{err: tt.processErr}
+			h := NewYookasaWebhookHandler(&mockPaymentGetter{payment: tt.payment}, processor)
+
+			rec := httptest.NewRecorder()
+			h.HandleWebhook(rec, httptest.NewRequest(http.MethodPost, "/yookasa-webhook", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if len(processor.cancelled) != tt.wantCancelled {
+				t.Fatalf("Expected %d processed cancellations, got %d", tt.wantCancelled, len(processor.cancelled))
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package locale
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+// nbsp неразрывный пробел: Telegram не переносит сумму и знак валюты на разные строки
+const nbsp = "\u00a0"
+
+const bytesInGigabyte = 1 << 30
+
+type format struct {
+	thousands      string
+	decimal        string
+	symbolFirst    bool
+	date           string
+	dateTime       string
+	day            [3]string
+	hour           [3]string
+	minute         [3]string
+	lessThanMinute string
+	gigabyte       string
+	// plural возвращает индекс формы слова для числа
+	plural func(n int) int
+}
+
+var formats = map[string]format{
+	"ru": {
+		thousands:      nbsp,
+		decimal:        ",",
+		date:           "02.01.2006",
+		dateTime:       "02.01.2006 15:04",
+		day:            [3]string{"день", "дня", "дней"},
+		hour:           [3]string{"час", "часа", "часов"},
+		minute:         [3]string{"минута", "минуты", "минут"},
+		lessThanMinute: "меньше минуты",
+		gigabyte:       "ГБ",
+		plural:         pluralRu,
+	},
+	"en": {
+		thousands:      ",",
+		decimal:        ".",
+		symbolFirst:    true,
+		date:           "Jan 2, 2006",
+		dateTime:       "Jan 2, 2006 15:04",
+		day:            [3]string{"day", "days", "days"},
+		hour:           [3]string{"hour", "hours", "hours"},
+		minute:         [3]string{"minute", "minutes", "minutes"},
+		lessThanMinute: "less than a minute",
+		gigabyte:       "GB",
+		plural:         pluralEn,
+	},
+}
+
+// formatFor возвращает формат для языка пользователя (ru, ru-RU, en-US...).
+// Для языков без своего формата используется язык по умолчанию, как и для переводов
+func formatFor(langCode string) format {
+	for _, code := range []string{langCode, config.DefaultLanguage()} {
+		base, _, _ := strings.Cut(strings.ToLower(code), "-")
+		if f, ok := formats[base]; ok {
+			return f
+		}
+	}
+	return formats["en"]
+}
+
+// FormatMoney форматирует сумму в рублях: "1 990 ₽" для ru, "₽1,990" для en.
+// Копейки показываются только если они есть
+func FormatMoney(langCode string, amount float64) string {
+	f := formatFor(langCode)
+
+	cents := int64(math.Round(math.Abs(amount) * 100))
+	number := groupThousands(strconv.FormatInt(cents/100, 10), f.thousands)
+	if cents%100 != 0 {
+		number += fmt.Sprintf("%s%02d", f.decimal, cents%100)
+	}
+	sign := ""
+	if amount < 0 && cents != 0 {
+		sign = "-"
+	}
+
+	if f.symbolFirst {
+		return sign + "₽" + number
+	}
+	return sign + number + nbsp + "₽"
+}
+
+// FormatDate форматирует дату без времени: "02.01.2006" для ru, "Jan 2, 2006" для en
+func FormatDate(langCode string, t time.Time) string {
+	return t.Format(formatFor(langCode).date)
+}
+
+// FormatDateTime форматирует дату со временем: "02.01.2006 15:04" для ru, "Jan 2, 2006 15:04" для en
+func FormatDateTime(langCode string, t time.Time) string {
+	return t.Format(formatFor(langCode).dateTime)
+}
+
+// FormatDuration форматирует длительность двумя старшими единицами: "2 дня 3 часа", "45 minutes".
+// Секунды отбрасываются, длительность меньше минуты выводится словами
+func FormatDuration(langCode string, d time.Duration) string {
+	f := formatFor(langCode)
+
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+
+	var parts []string
+	for _, unit := range []struct {
+		n     int
+		forms [3]string
+	}{{days, f.day}, {hours, f.hour}, {minutes, f.minute}} {
+		if unit.n > 0 && len(parts) < 2 {
+			parts = append(parts, fmt.Sprintf("%d %s", unit.n, unit.forms[f.plural(unit.n)]))
+		} else if len(parts) > 0 {
+			break
+		}
+	}
+
+	if len(parts) == 0 {
+		return f.lessThanMinute
+	}
+	return strings.Join(parts, " ")
+}
+
+// FormatDays форматирует количество дней: "7 дней", "1 day"
+func FormatDays(langCode string, days int) string {
+	f := formatFor(langCode)
+	return fmt.Sprintf("%d %s", days, f.day[f.plural(days)])
+}
+
+// FormatTraffic форматирует объём трафика в гигабайтах с точностью до десятой: "12,5 ГБ", "0.3 GB"
+func FormatTraffic(langCode string, bytes int64) string {
+	f := formatFor(langCode)
+	tenths := int64(math.Round(float64(max(bytes, 0)) / bytesInGigabyte * 10))
+	number := groupThousands(strconv.FormatInt(tenths/10, 10), f.thousands)
+	if tenths%10 != 0 {
+		number += fmt.Sprintf("%s%d", f.decimal, tenths%10)
+	}
+	return number + nbsp + f.gigabyte
+}
+
+// pluralRu выбирает форму по правилам русского языка: 1 день, 2 дня, 5 дней, 11 дней, 21 день
+func pluralRu(n int) int {
+	n %= 100
+	if n >= 11 && n <= 14 {
+		return 2
+	}
+	switch n % 10 {
+	case 1:
+		return 0
+	case 2, 3, 4:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func pluralEn(n int) int {
+	if n == 1 {
+		return 0
+	}
+	return 1
+}
+
+func groupThousands(digits, sep string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	var sb strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		sb.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if sb.Len() > 0 {
+			sb.WriteString(sep)
+		}
+		sb.WriteString(digits[i : i+3])
+	}
+	return sb.String()
+}
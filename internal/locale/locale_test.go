@@ -0,0 +1,93 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		lang   string
+		amount float64
+		want   string
+	}{
+		{"ru", 199, "199\u00a0₽"},
+		{"ru", 1990, "1\u00a0990\u00a0₽"},
+		{"ru", 1234567.5, "1\u00a0234\u00a0567,50\u00a0₽"},
+		{"ru-RU", 99.99, "99,99\u00a0₽"},
+		{"en", 1990, "₽1,990"},
+		{"en-US", 199.5, "₽199.50"},
+		{"en", -50, "-₽50"},
+		{"en", 0, "₽0"},
+	}
+	for _, tt := range tests {
+		if got := FormatMoney(tt.lang, tt.amount); got != tt.want {
+			t.Errorf("FormatMoney(%q, %v) = %q, want %q", tt.lang, tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDate(t *testing.T) {
+	date := time.Date(2025, 3, 7, 9, 5, 0, 0, time.UTC)
+
+	if got := FormatDate("ru", date); got != "07.03.2025" {
+		t.Errorf("Expected ru date 07.03.2025, got %q", got)
+	}
+	if got := FormatDate("en", date); got != "Mar 7, 2025" {
+		t.Errorf("Expected en date Mar 7, 2025, got %q", got)
+	}
+	if got := FormatDateTime("ru", date); got != "07.03.2025 09:05" {
+		t.Errorf("Expected ru date time 07.03.2025 09:05, got %q", got)
+	}
+	if got := FormatDateTime("en", date); got != "Mar 7, 2025 09:05" {
+		t.Errorf("Expected en date time Mar 7, 2025 09:05, got %q", got)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		lang string
+		d    time.Duration
+		want string
+	}{
+		{"ru", 48 * time.Hour, "2 дня"},
+		{"ru", 26*time.Hour + 30*time.Minute, "1 день 2 часа"},
+		{"ru", 5 * time.Hour, "5 часов"},
+		{"ru", 21 * time.Hour, "21 час"},
+		{"ru", 11 * 24 * time.Hour, "11 дней"},
+		{"ru", 45 * time.Minute, "45 минут"},
+		{"ru", 30 * time.Second, "меньше минуты"},
+		{"en", 21 * 24 * time.Hour, "21 days"},
+		{"en", 25 * time.Hour, "1 day 1 hour"},
+		{"en", 24*time.Hour + 5*time.Minute, "1 day"},
+		{"en", -time.Hour, "less than a minute"},
+	}
+	for _, tt := range tests {
+		if got := FormatDuration(tt.lang, tt.d); got != tt.want {
+			t.Errorf("FormatDuration(%q, %v) = %q, want %q", tt.lang, tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDays(t *testing.T) {
+	for days, want := range map[int]string{1: "1 день", 3: "3 дня", 7: "7 дней", 14: "14 дней", 21: "21 день"} {
+		if got := FormatDays("ru", days); got != want {
+			t.Errorf("FormatDays(ru, %d) = %q, want %q", days, got, want)
+		}
+	}
+	if got := FormatDays("en", 7); got != "7 days" {
+		t.Errorf("Expected 7 days, got %q", got)
+	}
+}
+
+func TestFormatTraffic(t *testing.T) {
+	if got := FormatTraffic("ru", 12*(1<<30)+(1<<29)); got != "12,5\u00a0ГБ" {
+		t.Errorf("Expected 12,5 ГБ, got %q", got)
+	}
+	if got := FormatTraffic("en", 100*(1<<30)); got != "100\u00a0GB" {
+		t.Errorf("Expected 100 GB, got %q", got)
+	}
+	if got := FormatTraffic("en", -5); got != "0\u00a0GB" {
+		t.Errorf("Expected negative traffic to be shown as 0 GB, got %q", got)
+	}
+}
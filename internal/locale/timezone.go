@@ -0,0 +1,98 @@
+package locale
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+// Timezones часовые пояса, которые клиент может выбрать в профиле
+var Timezones = []string{
+	"Europe/Kaliningrad",
+	"Europe/Moscow",
+	"Europe/Samara",
+	"Asia/Yekaterinburg",
+	"Asia/Omsk",
+	"Asia/Novosibirsk",
+	"Asia/Irkutsk",
+	"Asia/Yakutsk",
+	"Asia/Vladivostok",
+	"Asia/Magadan",
+	"Asia/Kamchatka",
+	"Europe/Minsk",
+	"Europe/Kyiv",
+	"Asia/Almaty",
+	"Asia/Tashkent",
+	"Asia/Tbilisi",
+	"Europe/Berlin",
+	"Europe/London",
+	"UTC",
+}
+
+var locations sync.Map
+
+func loadLocation(name string) (*time.Location, bool) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), true
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, false
+	}
+	locations.Store(name, loc)
+	return loc, true
+}
+
+// IsKnownTimezone возвращает true, если часовой пояс есть в списке выбора профиля
+func IsKnownTimezone(name string) bool {
+	return slices.Contains(Timezones, name)
+}
+
+// CustomerLocation возвращает часовой пояс клиента из профиля, а если он не указан или
+// не загружается — часовой пояс бота
+func CustomerLocation(timezone *string) *time.Location {
+	if timezone != nil && *timezone != "" {
+		if loc, ok := loadLocation(*timezone); ok {
+			return loc
+		}
+	}
+	return config.Timezone()
+}
+
+// FormatTimezone возвращает короткое название часового пояса со смещением от UTC на момент now: "Moscow (UTC+3)"
+func FormatTimezone(name string, now time.Time) string {
+	loc, ok := loadLocation(name)
+	if !ok || name == "UTC" {
+		return name
+	}
+	_, offset := now.In(loc).Zone()
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	utc := fmt.Sprintf("UTC%s%d", sign, offset/3600)
+	if minutes := offset % 3600 / 60; minutes != 0 {
+		utc += fmt.Sprintf(":%02d", minutes)
+	}
+	city := strings.ReplaceAll(name[strings.LastIndex(name, "/")+1:], "_", " ")
+	return fmt.Sprintf("%s (%s)", city, utc)
+}
+
+// NextDeliveryTime возвращает ближайший момент не раньше now, когда в часовом поясе loc
+// местное время попадает в часы [fromHour, toHour)
+func NextDeliveryTime(now time.Time, loc *time.Location, fromHour, toHour int) time.Time {
+	local := now.In(loc)
+	if local.Hour() >= fromHour && local.Hour() < toHour {
+		return now
+	}
+	day := local
+	if local.Hour() >= toHour {
+		day = local.AddDate(0, 0, 1)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), fromHour, 0, 0, 0, loc)
+}
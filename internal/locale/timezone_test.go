@@ -0,0 +1,54 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextDeliveryTime(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skipf("tzdata not available: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"inside window", time.Date(2025, 6, 15, 12, 30, 0, 0, moscow), time.Date(2025, 6, 15, 12, 30, 0, 0, moscow)},
+		{"before window", time.Date(2025, 6, 15, 4, 0, 0, 0, moscow), time.Date(2025, 6, 15, 9, 0, 0, 0, moscow)},
+		{"after window", time.Date(2025, 6, 15, 21, 0, 0, 0, moscow), time.Date(2025, 6, 16, 9, 0, 0, 0, moscow)},
+		{"server in another zone", time.Date(2025, 6, 15, 1, 0, 0, 0, time.UTC), time.Date(2025, 6, 15, 9, 0, 0, 0, moscow)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NextDeliveryTime(tt.now, moscow, 9, 21); !got.Equal(tt.want) {
+				t.Errorf("NextDeliveryTime() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatTimezone(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := map[string]string{
+		"Europe/Moscow": "Moscow (UTC+3)",
+		"Europe/London": "London (UTC+1)",
+		"UTC":           "UTC",
+		"Mars/Olympus":  "Mars/Olympus",
+	}
+	for name, want := range tests {
+		if got := FormatTimezone(name, now); got != want {
+			t.Errorf("FormatTimezone(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestTimezonesAreLoadable(t *testing.T) {
+	for _, name := range Timezones {
+		if _, err := time.LoadLocation(name); err != nil {
+			t.Errorf("Timezone %q cannot be loaded: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type ctxKey struct{}
+
+// RequestIDKey имя атрибута с идентификатором запроса/апдейта в логах
+const RequestIDKey = "request_id"
+
+// WithRequestID возвращает контекст с идентификатором запроса/апдейта
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, requestID)
+}
+
+// RequestID возвращает идентификатор запроса/апдейта из контекста или пустую строку
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// NewRequestID генерирует случайный идентификатор запроса
+func NewRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+// contextHandler добавляет request_id из контекста ко всем записям лога
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// NewHandler создаёт slog.Handler в формате format ("json" или "text"),
+// добавляющий request_id из контекста
+func NewHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{Level: level}
+
+	var base slog.Handler
+	if strings.EqualFold(format, "json") {
+		base = slog.NewJSONHandler(w, opts)
+	} else {
+		base = slog.NewTextHandler(w, opts)
+	}
+	return contextHandler{base}
+}
+
+// ParseLevel преобразует строку (debug, info, warn, error) в slog.Level, по умолчанию info
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Setup устанавливает глобальный slog логгер. Вывод стандартного пакета log
+// также направляется через него
+func Setup(w io.Writer, format string, level slog.Level) {
+	slog.SetDefault(slog.New(NewHandler(w, format, level)))
+}
@@ -0,0 +1,105 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+func TestHandlerAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, "json", slog.LevelInfo))
+
+	ctx := WithRequestID(context.Background(), "upd-42")
+	logger.InfoContext(ctx, "hello", "key", "value")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected JSON log line, got %q: %v", buf.String(), err)
+	}
+	if entry[RequestIDKey] != "upd-42" {
+		t.Errorf("Expected request_id upd-42, got %v", entry[RequestIDKey])
+	}
+
+	buf.Reset()
+	logger.With("component", "test").InfoContext(context.Background(), "no id")
+	if strings.Contains(buf.String(), RequestIDKey) {
+		t.Errorf("Expected no request_id without context value, got %q", buf.String())
+	}
+}
+
+func TestHandlerTextFormatAndLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, "text", ParseLevel("warn")))
+
+	logger.Info("skipped")
+	logger.WarnContext(WithRequestID(context.Background(), "abc"), "kept")
+
+	out := buf.String()
+	if strings.Contains(out, "skipped") {
+		t.Errorf("Expected info record to be filtered at warn level, got %q", out)
+	}
+	if !strings.Contains(out, "request_id=abc") {
+		t.Errorf("Expected text record with request_id, got %q", out)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		"INFO":    slog.LevelInfo,
+		"warn":    slog.LevelWarn,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+		"":        slog.LevelInfo,
+		"unknown": slog.LevelInfo,
+	}
+	for in, want := range tests {
+		if got := ParseLevel(in); got != want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestBotMiddlewareInjectsUpdateID(t *testing.T) {
+	var got string
+	handler := BotMiddleware(func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		got = RequestID(ctx)
+	})
+
+	handler(context.Background(), nil, &models.Update{ID: 777})
+
+	if got != "upd-777" {
+		t.Errorf("Expected request id upd-777, got %q", got)
+	}
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	var got string
+	handler := HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = RequestID(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	req.Header.Set(RequestIDHeader, "external-id")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got != "external-id" || rec.Header().Get(RequestIDHeader) != "external-id" {
+		t.Errorf("Expected incoming request id to be propagated, got %q / %q", got, rec.Header().Get(RequestIDHeader))
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhook", nil))
+	if got == "" || got == "external-id" {
+		t.Errorf("Expected generated request id, got %q", got)
+	}
+}
@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// RequestIDHeader HTTP заголовок, из которого берётся (и в который возвращается) идентификатор запроса
+const RequestIDHeader = "X-Request-ID"
+
+// UpdateID формирует идентификатор для Telegram апдейта
+func UpdateID(update *models.Update) string {
+	return fmt.Sprintf("upd-%d", update.ID)
+}
+
+// BotMiddleware присваивает каждому Telegram апдейту идентификатор и кладёт его в контекст
+func BotMiddleware(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		ctx = WithRequestID(ctx, UpdateID(update))
+		start := time.Now()
+		next(ctx, b, update)
+		slog.DebugContext(ctx, "Update handled", "duration", time.Since(start))
+	}
+}
+
+// HTTPMiddleware присваивает идентификатор входящему HTTP запросу (webhooks) и кладёт его в контекст
+func HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = NewRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), requestID)))
+	})
+}
@@ -0,0 +1,450 @@
+package miniapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/payment"
+	"remnawave-tg-shop-bot/utils"
+)
+
+type customerRepository interface {
+	FindByTelegramId(ctx context.Context, telegramId int64) (*database.Customer, error)
+	FindOrCreate(ctx context.Context, customer *database.Customer) (*database.Customer, error)
+	AcceptTos(ctx context.Context, id int64, version string, acceptedAt time.Time) error
+}
+
+type purchaseRepository interface {
+	FindById(ctx context.Context, id int64) (*database.Purchase, error)
+	FindLastPaidPurchaseByCustomer(ctx context.Context, customerID int64) (*database.Purchase, error)
+}
+
+type paymentService interface {
+	CreatePurchaseWithRecurring(ctx context.Context, amount float64, months int, customer *database.Customer, invoiceType database.InvoiceType, tariffName *string, deviceLimit *int, savePaymentMethod bool) (url string, purchaseId int64, err error)
+}
+
+type ctxKey struct{}
+
+// API JSON API для Telegram Mini App: статус подписки, тарифы и покупки
+type API struct {
+	customerRepo   customerRepository
+	purchaseRepo   purchaseRepository
+	paymentService paymentService
+	botToken       string
+	initDataMaxAge time.Duration
+	now            func() time.Time
+}
+
+func NewAPI(customerRepo customerRepository, purchaseRepo purchaseRepository, paymentService paymentService) *API {
+	return &API{
+		customerRepo:   customerRepo,
+		purchaseRepo:   purchaseRepo,
+		paymentService: paymentService,
+		botToken:       config.TelegramToken(),
+		initDataMaxAge: time.Duration(config.GetMiniAppInitDataTTLHours()) * time.Hour,
+		now:            time.Now,
+	}
+}
+
+// Handler возвращает http.Handler с маршрутами API под префиксом prefix
+func (a *API) Handler(prefix string) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+prefix+"/subscription", a.auth(a.handleSubscription))
+	mux.HandleFunc("GET "+prefix+"/tariffs", a.auth(a.handleTariffs))
+	mux.HandleFunc("POST "+prefix+"/purchases", a.auth(a.handleCreatePurchase))
+	mux.HandleFunc("GET "+prefix+"/purchases/{id}", a.auth(a.handlePurchaseStatus))
+	mux.HandleFunc("POST "+prefix+"/tos", a.auth(a.handleAcceptTos))
+	return a.cors(mux)
+}
+
+// cors разрешает запросы с домена Mini App
+func (a *API) cors(next http.Handler) http.Handler {
+	allowedOrigin := ""
+	if u, err := url.Parse(config.GetMiniAppURL()); err == nil && u.Host != "" {
+		allowedOrigin = u.Scheme + "://" + u.Host
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && origin == allowedOrigin {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Vary", "Origin")
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// auth проверяет initData из заголовка "Authorization: tma <initData>"
+func (a *API) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		initData := strings.TrimPrefix(r.Header.Get("Authorization"), "tma ")
+		data, err := ValidateInitData(initData, a.botToken, a.initDataMaxAge, a.now())
+		if err != nil {
+			slog.WarnContext(r.Context(), "Mini App init data rejected", "error", err)
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, data)))
+	}
+}
+
+func initDataFromContext(ctx context.Context) *InitData {
+	data, _ := ctx.Value(ctxKey{}).(*InitData)
+	return data
+}
+
+// findOrCreateCustomer возвращает customer для пользователя Mini App, создавая его при первом обращении
+func (a *API) findOrCreateCustomer(ctx context.Context, user WebAppUser) (*database.Customer, error) {
+	customer, err := a.customerRepo.FindByTelegramId(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if customer != nil {
+		return customer, nil
+	}
+	return a.customerRepo.FindOrCreate(ctx, &database.Customer{
+		TelegramID: user.ID,
+		Language:   user.LanguageCode,
+	})
+}
+
+type subscriptionResponse struct {
+	Active           bool       `json:"active"`
+	ExpireAt         *time.Time `json:"expire_at"`
+	SubscriptionLink *string    `json:"subscription_link"`
+	RecurringEnabled bool       `json:"recurring_enabled"`
+	// TosURL и TosRequired: перед покупкой нужно принять условия (POST /tos)
+	TosURL      string `json:"tos_url,omitempty"`
+	TosRequired bool   `json:"tos_required"`
+}
+
+func (a *API) handleSubscription(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := initDataFromContext(ctx).User
+
+	customer, err := a.findOrCreateCustomer(ctx, user)
+	if err != nil {
+		slog.ErrorContext(ctx, "Mini App: failed to get customer", "telegramId", utils.MaskHalfInt64(user.ID), "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	// Ссылка та же, что в боте: по шаблону тарифа и языку клиента
+	var subscriptionLink *string
+	if customer.SubscriptionLink != nil {
+		var lastPaid *database.Purchase
+		if config.SubscriptionPageUsesTariff() {
+			lastPaid, err = a.purchaseRepo.FindLastPaidPurchaseByCustomer(ctx, customer.ID)
+			if err != nil {
+				slog.ErrorContext(ctx, "Mini App: failed to find last paid purchase", "customerId", utils.MaskHalfInt64(customer.ID), "error", err)
+			}
+		}
+		link := customer.SubscriptionPageURL(lastPaid, user.LanguageCode)
+		subscriptionLink = &link
+	}
+
+	writeJSON(w, http.StatusOK, subscriptionResponse{
+		Active:           customer.ExpireAt != nil && customer.ExpireAt.After(a.now()),
+		ExpireAt:         customer.ExpireAt,
+		SubscriptionLink: subscriptionLink,
+		RecurringEnabled: customer.RecurringEnabled,
+		TosURL:           config.TosURL(),
+		TosRequired:      customer.NeedsTosAcceptance(),
+	})
+}
+
+type priceResponse struct {
+	Months     int `json:"months"`
+	Price      int `json:"price"`
+	StarsPrice int `json:"stars_price"`
+}
+
+type tariffResponse struct {
+	Name    string          `json:"name"`
+	Devices int             `json:"devices,omitempty"`
+	Prices  []priceResponse `json:"prices"`
+	// PaymentMethods способы оплаты тарифа (TARIFF_<NAME>_PAYMENT_METHODS) из включённых
+	PaymentMethods []string `json:"payment_methods"`
+}
+
+type tariffsResponse struct {
+	Tariffs          []tariffResponse `json:"tariffs"`
+	PaymentMethods   []string         `json:"payment_methods"`
+	RecurringEnabled bool             `json:"recurring_enabled"`
+	// PaymentFees наценка (+) или скидка (-) способа оплаты в процентах, цены в Tariffs указаны без неё
+	PaymentFees map[string]int `json:"payment_fees,omitempty"`
+}
+
+func (a *API) handleTariffs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, tariffsResponse{
+		Tariffs:          availableTariffs(),
+		PaymentMethods:   availablePaymentMethods(),
+		RecurringEnabled: config.IsRecurringPaymentsEnabled() && config.IsYookasaEnabled(),
+		PaymentFees:      paymentFees(),
+	})
+}
+
+// paymentFees возвращает ненулевые наценки и скидки включённых способов оплаты
+func paymentFees() map[string]int {
+	fees := make(map[string]int)
+	for _, method := range availablePaymentMethods() {
+		if percent := config.PaymentFeePercent(method); percent != 0 {
+			fees[method] = percent
+		}
+	}
+	return fees
+}
+
+// availableTariffs возвращает тарифы из конфигурации или глобальные цены (legacy режим без тарифов)
+func availableTariffs() []tariffResponse {
+	var tariffs []tariffResponse
+	if config.IsTariffsEnabled() {
+		for _, t := range config.GetTariffs() {
+			tariff := tariffResponse{Name: t.Name, Devices: t.Devices, PaymentMethods: tariffPaymentMethods(t.Name)}
+			for _, m := range config.SupportedMonths() {
+				if t.Price(m) > 0 {
+					tariff.Prices = append(tariff.Prices, priceResponse{Months: m, Price: t.Price(m), StarsPrice: t.StarsPrice(m)})
+				}
+			}
+			tariffs = append(tariffs, tariff)
+		}
+		return tariffs
+	}
+
+	tariff := tariffResponse{PaymentMethods: availablePaymentMethods()}
+	for _, m := range config.SupportedMonths() {
+		if config.Price(m) > 0 {
+			tariff.Prices = append(tariff.Prices, priceResponse{Months: m, Price: config.Price(m), StarsPrice: config.StarsPrice(m)})
+		}
+	}
+	return append(tariffs, tariff)
+}
+
+// availablePaymentMethods возвращает включённые способы оплаты, поддерживаемые Mini App
+func availablePaymentMethods() []string {
+	var methods []string
+	if config.IsYookasaEnabled() {
+		methods = append(methods, string(database.InvoiceTypeYookasa))
+	}
+	if config.IsCryptoPayEnabled() {
+		methods = append(methods, string(database.InvoiceTypeCrypto))
+	}
+	if config.IsTelegramStarsEnabled() {
+		methods = append(methods, string(database.InvoiceTypeTelegram))
+	}
+	return methods
+}
+
+// tariffPaymentMethods возвращает включённые способы оплаты, которыми можно оплатить тариф
+func tariffPaymentMethods(tariffName string) []string {
+	var methods []string
+	for _, m := range availablePaymentMethods() {
+		if config.TariffAllowsPaymentMethod(tariffName, m) {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
+
+type createPurchaseRequest struct {
+	Tariff      string `json:"tariff"`
+	Months      int    `json:"months"`
+	InvoiceType string `json:"invoice_type"`
+	Recurring   bool   `json:"recurring"`
+}
+
+type createPurchaseResponse struct {
+	PurchaseID int64  `json:"purchase_id"`
+	PaymentURL string `json:"payment_url"`
+}
+
+var errInvalidPurchase = errors.New("invalid purchase request")
+
+// resolvePrice определяет цену покупки по тарифу, периоду и способу оплаты
+func resolvePrice(req createPurchaseRequest) (int, error) {
+	invoiceType := database.InvoiceType(req.InvoiceType)
+	if !slices.Contains(tariffPaymentMethods(req.Tariff), req.InvoiceType) {
+		return 0, errInvalidPurchase
+	}
+
+	if !config.IsSupportedMonth(req.Months) {
+		return 0, errInvalidPurchase
+	}
+
+	var price int
+	if config.IsTariffsEnabled() {
+		tariff := config.GetTariffByName(req.Tariff)
+		if tariff == nil {
+			return 0, errInvalidPurchase
+		}
+		price = tariff.Price(req.Months)
+		if invoiceType == database.InvoiceTypeTelegram {
+			price = tariff.StarsPrice(req.Months)
+		}
+	} else {
+		price = config.Price(req.Months)
+		if invoiceType == database.InvoiceTypeTelegram {
+			price = config.StarsPrice(req.Months)
+		}
+	}
+
+	price, ok := config.PaymentAmount(req.InvoiceType, price)
+	if !ok {
+		return 0, errInvalidPurchase
+	}
+	return price, nil
+}
+
+func (a *API) handleCreatePurchase(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := initDataFromContext(ctx).User
+
+	var req createPurchaseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	price, err := resolvePrice(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	customer, err := a.findOrCreateCustomer(ctx, user)
+	if err != nil {
+		slog.ErrorContext(ctx, "Mini App: failed to get customer", "telegramId", utils.MaskHalfInt64(user.ID), "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if customer.NeedsTosAcceptance() {
+		writeError(w, http.StatusForbidden, "terms of service not accepted")
+		return
+	}
+
+	var tariffName *string
+	if config.IsTariffsEnabled() {
+		tariffName = &req.Tariff
+	}
+	invoiceType := database.InvoiceType(req.InvoiceType)
+	savePaymentMethod := req.Recurring && invoiceType == database.InvoiceTypeYookasa && config.IsRecurringPaymentsEnabled()
+
+	ctxWithUsername := context.WithValue(ctx, "username", user.Username)
+	paymentURL, purchaseID, err := a.paymentService.CreatePurchaseWithRecurring(ctxWithUsername, float64(price), req.Months, customer, invoiceType, tariffName, nil, savePaymentMethod)
+	if errors.Is(err, payment.ErrPurchaseLimitExceeded) {
+		writeError(w, http.StatusTooManyRequests, "purchase limit exceeded")
+		return
+	}
+	// Сумма вне лимитов провайдера или неподдерживаемый срок — ошибка запроса, а не сбой провайдера
+	if errors.Is(err, payment.ErrInvalidPaymentAmount) || errors.Is(err, payment.ErrPeriodNotSupported) {
+		writeError(w, http.StatusBadRequest, "payment method unavailable")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Mini App: failed to create purchase", "telegramId", utils.MaskHalfInt64(user.ID), "error", err)
+		writeError(w, http.StatusBadGateway, "failed to create payment")
+		return
+	}
+
+	slog.InfoContext(ctx, "Mini App purchase created", "purchaseId", purchaseID, "invoiceType", invoiceType, "months", req.Months, "price", price)
+	writeJSON(w, http.StatusCreated, createPurchaseResponse{PurchaseID: purchaseID, PaymentURL: paymentURL})
+}
+
+// handleAcceptTos сохраняет принятие текущей версии условий использования
+func (a *API) handleAcceptTos(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := initDataFromContext(ctx).User
+
+	customer, err := a.findOrCreateCustomer(ctx, user)
+	if err != nil {
+		slog.ErrorContext(ctx, "Mini App: failed to get customer", "telegramId", utils.MaskHalfInt64(user.ID), "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if err := a.customerRepo.AcceptTos(ctx, customer.ID, config.TosVersion(), a.now()); err != nil {
+		slog.ErrorContext(ctx, "Mini App: failed to accept tos", "telegramId", utils.MaskHalfInt64(user.ID), "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type purchaseStatusResponse struct {
+	ID          int64      `json:"id"`
+	Status      string     `json:"status"`
+	InvoiceType string     `json:"invoice_type"`
+	Amount      float64    `json:"amount"`
+	Currency    string     `json:"currency"`
+	Months      int        `json:"months"`
+	PaidAt      *time.Time `json:"paid_at"`
+}
+
+func (a *API) handlePurchaseStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	user := initDataFromContext(ctx).User
+
+	purchaseID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid purchase id")
+		return
+	}
+
+	customer, err := a.customerRepo.FindByTelegramId(ctx, user.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Mini App: failed to get customer", "telegramId", utils.MaskHalfInt64(user.ID), "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	purchase, err := a.purchaseRepo.FindById(ctx, purchaseID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Mini App: failed to get purchase", "purchaseId", purchaseID, "error", err)
+		writeError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	// Покупки других пользователей не раскрываем
+	if customer == nil || purchase == nil || purchase.CustomerID != customer.ID {
+		writeError(w, http.StatusNotFound, "purchase not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, purchaseStatusResponse{
+		ID:          purchase.ID,
+		Status:      string(purchase.Status),
+		InvoiceType: string(purchase.InvoiceType),
+		Amount:      purchase.Amount,
+		Currency:    purchase.Currency,
+		Months:      purchase.Month,
+		PaidAt:      purchase.PaidAt,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
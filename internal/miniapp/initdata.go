@@ -0,0 +1,100 @@
+package miniapp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInitDataMissing   = errors.New("init data is missing")
+	ErrInitDataSignature = errors.New("init data signature is invalid")
+	ErrInitDataExpired   = errors.New("init data is expired")
+)
+
+// WebAppUser пользователь Telegram из initData
+type WebAppUser struct {
+	ID           int64  `json:"id"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	Username     string `json:"username"`
+	LanguageCode string `json:"language_code"`
+}
+
+// InitData проверенные данные запуска Mini App
+type InitData struct {
+	User     WebAppUser
+	AuthDate time.Time
+}
+
+// ValidateInitData проверяет подпись initData по алгоритму Telegram Web Apps
+// (HMAC-SHA256 с ключом HMAC-SHA256("WebAppData", botToken)) и срок её действия
+func ValidateInitData(initData, botToken string, maxAge time.Duration, now time.Time) (*InitData, error) {
+	if initData == "" {
+		return nil, ErrInitDataMissing
+	}
+
+	values, err := url.ParseQuery(initData)
+	if err != nil {
+		return nil, fmt.Errorf("parse init data: %w", err)
+	}
+
+	hash := values.Get("hash")
+	if hash == "" {
+		return nil, ErrInitDataSignature
+	}
+
+	if !hmac.Equal([]byte(hash), []byte(signInitData(values, botToken))) {
+		return nil, ErrInitDataSignature
+	}
+
+	authDateUnix, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse auth_date: %w", err)
+	}
+	authDate := time.Unix(authDateUnix, 0)
+	if maxAge > 0 && now.Sub(authDate) > maxAge {
+		return nil, ErrInitDataExpired
+	}
+
+	var user WebAppUser
+	if err := json.Unmarshal([]byte(values.Get("user")), &user); err != nil {
+		return nil, fmt.Errorf("parse user: %w", err)
+	}
+	if user.ID == 0 {
+		return nil, fmt.Errorf("init data has no user id")
+	}
+
+	return &InitData{User: user, AuthDate: authDate}, nil
+}
+
+// signInitData вычисляет hash для initData (все поля кроме hash)
+func signInitData(values url.Values, botToken string) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		if k != "hash" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+values.Get(k))
+	}
+
+	secret := hmac.New(sha256.New, []byte("WebAppData"))
+	secret.Write([]byte(botToken))
+
+	mac := hmac.New(sha256.New, secret.Sum(nil))
+	mac.Write([]byte(strings.Join(pairs, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
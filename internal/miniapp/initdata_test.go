@@ -0,0 +1,64 @@
+package miniapp
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testBotToken = "123456:TEST-TOKEN"
+
+// buildInitData формирует подписанную initData как это делает Telegram
+func buildInitData(botToken string, userJSON string, authDate time.Time) string {
+	values := url.Values{}
+	values.Set("query_id", "AAH-test")
+	values.Set("user", userJSON)
+	values.Set("auth_date", strconv.FormatInt(authDate.Unix(), 10))
+	values.Set("hash", signInitData(values, botToken))
+	return values.Encode()
+}
+
+func TestValidateInitData(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	user := `{"id":42,"first_name":"Ivan","username":"ivan","language_code":"ru"}`
+
+	data, err := ValidateInitData(buildInitData(testBotToken, user, now.Add(-time.Hour)), testBotToken, 24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("Expected valid init data, got %v", err)
+	}
+	if data.User.ID != 42 || data.User.Username != "ivan" || data.User.LanguageCode != "ru" {
+		t.Errorf("Unexpected user: %+v", data.User)
+	}
+}
+
+func TestValidateInitDataRejected(t *testing.T) {
+	now := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	user := `{"id":42,"first_name":"Ivan"}`
+	valid := buildInitData(testBotToken, user, now)
+
+	tampered, _ := url.ParseQuery(valid)
+	tampered.Set("user", `{"id":43,"first_name":"Ivan"}`)
+
+	tests := []struct {
+		name     string
+		initData string
+		wantErr  error
+	}{
+		{"missing", "", ErrInitDataMissing},
+		{"wrong bot token", buildInitData("other:token", user, now), ErrInitDataSignature},
+		{"tampered user", tampered.Encode(), ErrInitDataSignature},
+		{"no hash", "user=%7B%7D&auth_date=1", ErrInitDataSignature},
+		{"expired", buildInitData(testBotToken, user, now.Add(-25*time.Hour)), ErrInitDataExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateInitData(tt.initData, testBotToken, 24*time.Hour, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
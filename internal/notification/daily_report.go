@@ -0,0 +1,270 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+type statsRepository interface {
+	CountNewCustomers(ctx context.Context, from, to time.Time) (int, error)
+	CountTrialsStarted(ctx context.Context, from, to time.Time) (int, error)
+	CountExpiringBetween(ctx context.Context, from, to time.Time) (int, error)
+	CountFailedRecurringCharges(ctx context.Context, from, to time.Time) (int, error)
+	PaymentsByProvider(ctx context.Context, from, to time.Time) ([]database.ProviderPaymentStats, error)
+	BroadcastResults(ctx context.Context, from, to time.Time) (*database.BroadcastStats, error)
+	FunnelUsers(ctx context.Context, from, to time.Time) (map[database.FunnelEvent]int, error)
+	StatsBySource(ctx context.Context, from, to time.Time) ([]database.SourceStats, error)
+}
+
+// DailyReport данные ежедневной сводки для админа
+type DailyReport struct {
+	From                   time.Time
+	To                     time.Time
+	NewUsers               int
+	TrialsStarted          int
+	Payments               []database.ProviderPaymentStats
+	ExpiringIn3Days        int
+	FailedRecurringCharges int
+	Broadcasts             database.BroadcastStats
+	Funnel                 map[database.FunnelEvent]int // уникальные пользователи на шаге воронки
+	Sources                []database.SourceStats
+}
+
+type DailyReportService struct {
+	stats       statsRepository
+	telegramBot *bot.Bot
+}
+
+func NewDailyReportService(stats statsRepository, telegramBot *bot.Bot) *DailyReportService {
+	return &DailyReportService{stats: stats, telegramBot: telegramBot}
+}
+
+// CollectDailyReport собирает статистику за 24 часа до now
+func (s *DailyReportService) CollectDailyReport(ctx context.Context, now time.Time) (*DailyReport, error) {
+	from := now.Add(-24 * time.Hour)
+	report := &DailyReport{From: from, To: now}
+
+	var err error
+	if report.NewUsers, err = s.stats.CountNewCustomers(ctx, from, now); err != nil {
+		return nil, fmt.Errorf("count new customers: %w", err)
+	}
+	if report.TrialsStarted, err = s.stats.CountTrialsStarted(ctx, from, now); err != nil {
+		return nil, fmt.Errorf("count trials: %w", err)
+	}
+	if report.Payments, err = s.stats.PaymentsByProvider(ctx, from, now); err != nil {
+		return nil, fmt.Errorf("payments by provider: %w", err)
+	}
+	if report.ExpiringIn3Days, err = s.stats.CountExpiringBetween(ctx, now, now.Add(72*time.Hour)); err != nil {
+		return nil, fmt.Errorf("count expiring: %w", err)
+	}
+	if report.FailedRecurringCharges, err = s.stats.CountFailedRecurringCharges(ctx, from, now); err != nil {
+		return nil, fmt.Errorf("count failed recurring charges: %w", err)
+	}
+	broadcasts, err := s.stats.BroadcastResults(ctx, from, now)
+	if err != nil {
+		return nil, fmt.Errorf("broadcast results: %w", err)
+	}
+	report.Broadcasts = *broadcasts
+	if report.Funnel, err = s.stats.FunnelUsers(ctx, from, now); err != nil {
+		return nil, fmt.Errorf("funnel users: %w", err)
+	}
+	if report.Sources, err = s.stats.StatsBySource(ctx, from, now); err != nil {
+		return nil, fmt.Errorf("stats by source: %w", err)
+	}
+
+	return report, nil
+}
+
+// SendDailyReport собирает сводку и отправляет её админу одним HTML сообщением
+func (s *DailyReportService) SendDailyReport(ctx context.Context) error {
+	if !config.IsDailyReportEnabled() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	report, err := s.CollectDailyReport(ctx, time.Now().In(config.Timezone()))
+	if err != nil {
+		return err
+	}
+
+	_, err = utils.SendLongMessage(ctx, s.telegramBot, &bot.SendMessageParams{
+		ChatID:    config.GetAdminTelegramId(),
+		Text:      FormatDailyReport(report),
+		ParseMode: "HTML",
+	})
+	if err != nil {
+		return fmt.Errorf("send daily report: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Daily admin report sent")
+	return nil
+}
+
+// FormatDailyReport форматирует сводку в HTML сообщение
+func FormatDailyReport(r *DailyReport) string {
+	var sb strings.Builder
+
+	sb.WriteString("📊 <b>Ежедневная сводка</b>\n")
+	sb.WriteString(fmt.Sprintf("<i>%s — %s</i>\n\n", r.From.Format("02.01.2006 15:04"), r.To.Format("02.01.2006 15:04")))
+
+	sb.WriteString(fmt.Sprintf("👤 Новых пользователей: <b>%d</b>\n", r.NewUsers))
+	sb.WriteString(fmt.Sprintf("🎁 Триалов активировано: <b>%d</b>\n\n", r.TrialsStarted))
+
+	totalPayments := 0
+	for _, p := range r.Payments {
+		totalPayments += p.Count
+	}
+	sb.WriteString(fmt.Sprintf("💰 Оплат: <b>%d</b>\n", totalPayments))
+	for _, p := range r.Payments {
+		sb.WriteString(fmt.Sprintf("  • %s: %d на %s %s\n", providerName(p.InvoiceType), p.Count, formatAmount(p.Revenue), p.Currency))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString(fmt.Sprintf("⏳ Истекает в ближайшие 3 дня: <b>%d</b>\n", r.ExpiringIn3Days))
+	sb.WriteString(fmt.Sprintf("❌ Неудачных автосписаний: <b>%d</b>\n\n", r.FailedRecurringCharges))
+
+	sb.WriteString(formatFunnel(r.Funnel))
+	sb.WriteString(formatSources(r.Sources))
+
+	if r.Broadcasts.Count == 0 {
+		sb.WriteString("📨 Рассылок не было")
+	} else {
+		sb.WriteString(fmt.Sprintf("📨 Рассылок: <b>%d</b> (доставлено %d, ошибок %d)",
+			r.Broadcasts.Count, r.Broadcasts.Sent, r.Broadcasts.Failed))
+	}
+
+	return sb.String()
+}
+
+// formatFunnel форматирует воронку продаж с конверсией каждого шага относительно /start
+func formatFunnel(funnel map[database.FunnelEvent]int) string {
+	started := funnel[database.FunnelEventStart]
+	if started == 0 {
+		return "🔻 Воронка: нет новых /start\n\n"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🔻 <b>Воронка</b>\n")
+	for _, event := range database.FunnelEvents {
+		users := funnel[event]
+		if event == database.FunnelEventStart {
+			sb.WriteString(fmt.Sprintf("  • %s: %d\n", funnelStepName(event), users))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("  • %s: %d (%.1f%%)\n", funnelStepName(event), users, float64(users)*100/float64(started)))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// maxReportSources количество источников в сводке, остальные суммируются
+const maxReportSources = 10
+
+// formatSources форматирует статистику по источникам привлечения. Блок не выводится,
+// если за период нет ни одного атрибутированного пользователя или оплаты
+func formatSources(sources []database.SourceStats) string {
+	attributed := false
+	for _, s := range sources {
+		if s.Source != "" {
+			attributed = true
+			break
+		}
+	}
+	if !attributed {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📣 <b>Источники</b>\n")
+	for i, s := range sources {
+		if i == maxReportSources {
+			sb.WriteString(fmt.Sprintf("  • … и ещё %d\n", len(sources)-i))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("  • %s: %d рег.", utils.EscapeHTML(sourceName(s.Source)), s.Registrations))
+		if s.Registrations > 0 {
+			sb.WriteString(fmt.Sprintf(", оплатили %d (%.1f%%)", s.Converted, float64(s.Converted)*100/float64(s.Registrations)))
+		}
+		if s.Payments > 0 {
+			sb.WriteString(fmt.Sprintf(", оплат %d на %s", s.Payments, formatRevenue(s.Revenue)))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+func sourceName(source string) string {
+	switch source {
+	case "":
+		return "Без источника"
+	case database.SourceReferral:
+		return "Рефералы"
+	default:
+		return source
+	}
+}
+
+func formatRevenue(revenue map[string]float64) string {
+	currencies := make([]string, 0, len(revenue))
+	for currency := range revenue {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	parts := make([]string, 0, len(currencies))
+	for _, currency := range currencies {
+		parts = append(parts, fmt.Sprintf("%s %s", formatAmount(revenue[currency]), currency))
+	}
+	return strings.Join(parts, " + ")
+}
+
+func funnelStepName(event database.FunnelEvent) string {
+	switch event {
+	case database.FunnelEventStart:
+		return "Старт"
+	case database.FunnelEventViewedPrices:
+		return "Смотрели цены"
+	case database.FunnelEventSelectedPayment:
+		return "Выбрали оплату"
+	case database.FunnelEventPaid:
+		return "Оплатили"
+	default:
+		return string(event)
+	}
+}
+
+func providerName(invoiceType database.InvoiceType) string {
+	switch invoiceType {
+	case database.InvoiceTypeYookasa:
+		return "YooKassa"
+	case database.InvoiceTypeCrypto:
+		return "CryptoPay"
+	case database.InvoiceTypeTelegram:
+		return "Telegram Stars"
+	case database.InvoiceTypeTribute:
+		return "Tribute"
+	case "":
+		return "Другое"
+	default:
+		return string(invoiceType)
+	}
+}
+
+func formatAmount(amount float64) string {
+	if amount == float64(int64(amount)) {
+		return fmt.Sprintf("%d", int64(amount))
+	}
+	return fmt.Sprintf("%.2f", amount)
+}
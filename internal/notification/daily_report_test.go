@@ -0,0 +1,172 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+type statsRepoMock struct {
+	newUsers      int
+	trials        int
+	expiring      int
+	failedCharges int
+	payments      []database.ProviderPaymentStats
+	broadcasts    database.BroadcastStats
+	funnel        map[database.FunnelEvent]int
+	sources       []database.SourceStats
+	err           error
+	expiringFrom  time.Time
+	expiringTo    time.Time
+}
+
+func (m *statsRepoMock) CountNewCustomers(ctx context.Context, from, to time.Time) (int, error) {
+	return m.newUsers, m.err
+}
+
+func (m *statsRepoMock) CountTrialsStarted(ctx context.Context, from, to time.Time) (int, error) {
+	return m.trials, nil
+}
+
+func (m *statsRepoMock) CountExpiringBetween(ctx context.Context, from, to time.Time) (int, error) {
+	m.expiringFrom, m.expiringTo = from, to
+	return m.expiring, nil
+}
+
+func (m *statsRepoMock) CountFailedRecurringCharges(ctx context.Context, from, to time.Time) (int, error) {
+	return m.failedCharges, nil
+}
+
+func (m *statsRepoMock) PaymentsByProvider(ctx context.Context, from, to time.Time) ([]database.ProviderPaymentStats, error) {
+	return m.payments, nil
+}
+
+func (m *statsRepoMock) BroadcastResults(ctx context.Context, from, to time.Time) (*database.BroadcastStats, error) {
+	return &m.broadcasts, nil
+}
+
+func (m *statsRepoMock) FunnelUsers(ctx context.Context, from, to time.Time) (map[database.FunnelEvent]int, error) {
+	return m.funnel, nil
+}
+
+func (m *statsRepoMock) StatsBySource(ctx context.Context, from, to time.Time) ([]database.SourceStats, error) {
+	return m.sources, nil
+}
+
+func TestCollectDailyReport(t *testing.T) {
+	now := time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC)
+	stats := &statsRepoMock{
+		newUsers:      12,
+		trials:        7,
+		expiring:      4,
+		failedCharges: 2,
+		payments: []database.ProviderPaymentStats{
+			{InvoiceType: database.InvoiceTypeYookasa, Currency: "RUB", Count: 3, Revenue: 1500},
+		},
+		broadcasts: database.BroadcastStats{Count: 1, Sent: 100, Failed: 3},
+		funnel:     map[database.FunnelEvent]int{database.FunnelEventStart: 20, database.FunnelEventPaid: 3},
+	}
+	service := NewDailyReportService(stats, nil)
+
+	report, err := service.CollectDailyReport(context.Background(), now)
+	if err != nil {
+		t.Fatalf("CollectDailyReport failed: %v", err)
+	}
+
+	if !report.From.Equal(now.Add(-24*time.Hour)) || !report.To.Equal(now) {
+		t.Errorf("Unexpected report period %v - %v", report.From, report.To)
+	}
+	if !stats.expiringFrom.Equal(now) || !stats.expiringTo.Equal(now.Add(72*time.Hour)) {
+		t.Errorf("Expected expiring window of 3 days from now, got %v - %v", stats.expiringFrom, stats.expiringTo)
+	}
+	if report.NewUsers != 12 || report.TrialsStarted != 7 || report.ExpiringIn3Days != 4 || report.FailedRecurringCharges != 2 {
+		t.Errorf("Unexpected counters: %+v", report)
+	}
+	if report.Funnel[database.FunnelEventPaid] != 3 {
+		t.Errorf("Expected funnel to be collected, got %v", report.Funnel)
+	}
+	if report.Broadcasts.Sent != 100 {
+		t.Errorf("Expected broadcast results to be collected, got %+v", report.Broadcasts)
+	}
+}
+
+func TestCollectDailyReport_Error(t *testing.T) {
+	service := NewDailyReportService(&statsRepoMock{err: errors.New("db down")}, nil)
+
+	if _, err := service.CollectDailyReport(context.Background(), time.Now()); err == nil {
+		t.Error("Expected error when stats query fails")
+	}
+}
+
+func TestFormatDailyReport(t *testing.T) {
+	report := &DailyReport{
+		From:          time.Date(2025, 3, 9, 9, 0, 0, 0, time.UTC),
+		To:            time.Date(2025, 3, 10, 9, 0, 0, 0, time.UTC),
+		NewUsers:      12,
+		TrialsStarted: 7,
+		Payments: []database.ProviderPaymentStats{
+			{InvoiceType: database.InvoiceTypeYookasa, Currency: "RUB", Count: 3, Revenue: 1500},
+			{InvoiceType: database.InvoiceTypeCrypto, Currency: "USDT", Count: 1, Revenue: 2.5},
+		},
+		ExpiringIn3Days:        4,
+		FailedRecurringCharges: 2,
+		Funnel: map[database.FunnelEvent]int{
+			database.FunnelEventStart:           40,
+			database.FunnelEventViewedPrices:    20,
+			database.FunnelEventSelectedPayment: 10,
+			database.FunnelEventPaid:            3,
+		},
+	}
+
+	text := FormatDailyReport(report)
+
+	for _, want := range []string{
+		"09.03.2025 09:00 — 10.03.2025 09:00",
+		"Новых пользователей: <b>12</b>",
+		"Триалов активировано: <b>7</b>",
+		"Оплат: <b>4</b>",
+		"YooKassa: 3 на 1500 RUB",
+		"CryptoPay: 1 на 2.50 USDT",
+		"Истекает в ближайшие 3 дня: <b>4</b>",
+		"Неудачных автосписаний: <b>2</b>",
+		"Рассылок не было",
+		"Старт: 40",
+		"Смотрели цены: 20 (50.0%)",
+		"Выбрали оплату: 10 (25.0%)",
+		"Оплатили: 3 (7.5%)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected report to contain %q, got:\n%s", want, text)
+		}
+	}
+}
+
+func TestFormatDailyReportSources(t *testing.T) {
+	report := &DailyReport{
+		Sources: []database.SourceStats{
+			{Source: "tg_ads", Registrations: 20, Converted: 5, Payments: 6, Revenue: map[string]float64{"RUB": 1194, "STARS": 150}},
+			{Source: database.SourceReferral, Registrations: 4, Converted: 1},
+			{Source: "", Registrations: 10},
+		},
+	}
+
+	text := FormatDailyReport(report)
+	for _, want := range []string{
+		"tg_ads: 20 рег., оплатили 5 (25.0%), оплат 6 на 1194 RUB + 150 STARS",
+		"Рефералы: 4 рег., оплатили 1 (25.0%)",
+		"Без источника: 10 рег., оплатили 0 (0.0%)",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected report to contain %q, got:\n%s", want, text)
+		}
+	}
+
+	report.Sources = []database.SourceStats{{Source: "", Registrations: 10}}
+	if strings.Contains(FormatDailyReport(report), "Источники") {
+		t.Error("Expected sources block to be hidden without attributed users")
+	}
+}
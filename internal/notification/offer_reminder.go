@@ -0,0 +1,164 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/handler"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/translation"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// offerReminderBatchSize ограничивает количество напоминаний одного вида за запуск
+const offerReminderBatchSize = 500
+
+type offerReminderRepository interface {
+	FindExpiring(ctx context.Context, kind database.OfferKind, from, to time.Time, limit int) ([]database.Customer, error)
+	Claim(ctx context.Context, kind database.OfferKind, customerID int64, expiresAt, at time.Time) (bool, error)
+}
+
+// OfferReminderService напоминает клиентам о промо-тарифе и winback предложении за
+// OFFER_REMINDER_HOURS_BEFORE часов до окончания. По каждому предложению — одно напоминание
+type OfferReminderService struct {
+	repository  offerReminderRepository
+	telegramBot *bot.Bot
+	tm          *translation.Manager
+}
+
+func NewOfferReminderService(repository offerReminderRepository, telegramBot *bot.Bot, tm *translation.Manager) *OfferReminderService {
+	return &OfferReminderService{repository: repository, telegramBot: telegramBot, tm: tm}
+}
+
+// offerReminder условия предложения, о котором напоминаем
+type offerReminder struct {
+	ExpiresAt      time.Time
+	Price          int
+	Months         int
+	Devices        int
+	TextKey        string
+	ButtonKey      string
+	ButtonCallback string
+}
+
+// offerReminderFor возвращает условия предложения kind из записи клиента. false — предложения нет
+func offerReminderFor(kind database.OfferKind, c database.Customer) (offerReminder, bool) {
+	switch kind {
+	case database.OfferKindPromoTariff:
+		if c.PromoOfferExpiresAt == nil || c.PromoOfferPrice == nil || c.PromoOfferMonths == nil || c.PromoOfferDevices == nil {
+			return offerReminder{}, false
+		}
+		return offerReminder{
+			ExpiresAt:      *c.PromoOfferExpiresAt,
+			Price:          *c.PromoOfferPrice,
+			Months:         *c.PromoOfferMonths,
+			Devices:        *c.PromoOfferDevices,
+			TextKey:        "offer_reminder_promo_tariff",
+			ButtonKey:      "promo_tariff_activate_button",
+			ButtonCallback: handler.CallbackPromoTariff,
+		}, true
+	case database.OfferKindWinback:
+		if c.WinbackOfferExpiresAt == nil || c.WinbackOfferPrice == nil || c.WinbackOfferMonths == nil || c.WinbackOfferDevices == nil {
+			return offerReminder{}, false
+		}
+		return offerReminder{
+			ExpiresAt:      *c.WinbackOfferExpiresAt,
+			Price:          *c.WinbackOfferPrice,
+			Months:         *c.WinbackOfferMonths,
+			Devices:        *c.WinbackOfferDevices,
+			TextKey:        "offer_reminder_winback",
+			ButtonKey:      "winback_activate_button",
+			ButtonCallback: handler.CallbackWinbackActivate,
+		}, true
+	}
+	return offerReminder{}, false
+}
+
+// Run отправляет напоминания о предложениях, которые закончатся в ближайшие OFFER_REMINDER_HOURS_BEFORE часов
+func (s *OfferReminderService) Run(ctx context.Context) error {
+	if !config.IsOfferReminderEnabled() {
+		return nil
+	}
+
+	now := clock.Now()
+	until := now.Add(time.Duration(config.OfferReminderHoursBefore()) * time.Hour)
+	for _, kind := range []database.OfferKind{database.OfferKindPromoTariff, database.OfferKindWinback} {
+		sent, err := s.remind(ctx, kind, now, until)
+		if err != nil {
+			return fmt.Errorf("%s reminders: %w", kind, err)
+		}
+		if sent > 0 {
+			slog.InfoContext(ctx, "Offer reminders sent", "kind", kind, "sent", sent)
+		}
+	}
+	return nil
+}
+
+func (s *OfferReminderService) remind(ctx context.Context, kind database.OfferKind, now, until time.Time) (int, error) {
+	customers, err := s.repository.FindExpiring(ctx, kind, now, until, offerReminderBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, c := range customers {
+		offer, ok := offerReminderFor(kind, c)
+		if !ok {
+			continue
+		}
+
+		claimed, err := s.repository.Claim(ctx, kind, c.ID, offer.ExpiresAt, now)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := s.send(ctx, c, offer, now); err != nil {
+			slog.WarnContext(ctx, "Failed to send offer reminder", "kind", kind, "customerId", utils.MaskHalfInt64(c.ID), "error", err)
+			continue
+		}
+		sent++
+
+		if err := utils.ThrottleSend(ctx); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+func (s *OfferReminderService) send(ctx context.Context, customer database.Customer, offer offerReminder, now time.Time) error {
+	lang := customer.Language
+	if lang == "" {
+		lang = config.DefaultLanguage()
+	}
+
+	params := offerReminderMessage(s.tm, lang, offer, now)
+	params.ChatID = customer.TelegramID
+	_, err := s.telegramBot.SendMessage(ctx, params)
+	return err
+}
+
+// offerReminderMessage напоминание о предложении offer без получателя
+func offerReminderMessage(tm *translation.Manager, lang string, offer offerReminder, now time.Time) *bot.SendMessageParams {
+	return &bot.SendMessageParams{
+		ParseMode: models.ParseModeHTML,
+		Text: tm.GetTextTemplate(lang, offer.TextKey, map[string]interface{}{
+			"months":    offer.Months,
+			"devices":   offer.Devices,
+			"price":     locale.FormatMoney(lang, float64(offer.Price)),
+			"time_left": locale.FormatDuration(lang, offer.ExpiresAt.Sub(now)),
+		}),
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: handler.WithNotificationFeedback([][]models.InlineKeyboardButton{
+			{{Text: tm.GetText(lang, offer.ButtonKey), CallbackData: offer.ButtonCallback}},
+		}, offer.TextKey)},
+	}
+}
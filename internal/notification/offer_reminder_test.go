@@ -0,0 +1,37 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/handler"
+)
+
+func TestOfferReminderFor(t *testing.T) {
+	expires := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	n := func(v int) *int { return &v }
+	c := database.Customer{
+		PromoOfferPrice: n(99), PromoOfferMonths: n(1), PromoOfferDevices: n(3), PromoOfferExpiresAt: &expires,
+		WinbackOfferPrice: n(150), WinbackOfferMonths: n(3), WinbackOfferDevices: n(2),
+	}
+
+	promo, ok := offerReminderFor(database.OfferKindPromoTariff, c)
+	if !ok {
+		t.Fatal("Expected promo tariff offer")
+	}
+	if promo.Price != 99 || promo.Months != 1 || promo.Devices != 3 || !promo.ExpiresAt.Equal(expires) || promo.ButtonCallback != handler.CallbackPromoTariff {
+		t.Errorf("Unexpected promo tariff reminder: %+v", promo)
+	}
+
+	// Winback без срока действия: напоминать не о чем
+	if _, ok := offerReminderFor(database.OfferKindWinback, c); ok {
+		t.Error("Expected no reminder for winback offer without expiry")
+	}
+
+	c.WinbackOfferExpiresAt = &expires
+	winback, ok := offerReminderFor(database.OfferKindWinback, c)
+	if !ok || winback.Price != 150 || winback.ButtonCallback != handler.CallbackWinbackActivate {
+		t.Errorf("Unexpected winback reminder: %+v", winback)
+	}
+}
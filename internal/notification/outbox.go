@@ -0,0 +1,160 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// outboxBatchSize ограничивает количество уведомлений, отправляемых за один запуск
+const outboxBatchSize = 200
+
+// outboxMaxDelay уведомления, которые не удалось отправить в течение этого времени после наступления
+// их срока (бот был остановлен), не отправляются: напоминание об окончании подписки уже устарело
+const outboxMaxDelay = 12 * time.Hour
+
+type outboxRepository interface {
+	Enqueue(ctx context.Context, message *database.OutboxMessage) error
+	FindDue(ctx context.Context, from, to time.Time, limit int) ([]database.OutboxMessage, error)
+	MarkSent(ctx context.Context, id int64, sentAt time.Time) error
+	MarkFailed(ctx context.Context, id int64, errText string) error
+}
+
+// OutboxService доставляет напоминания и предложения в часы NOTIFY_LOCAL_HOURS по местному времени клиента.
+// Уведомление, которое пришлось бы на ночь, откладывается в очередь и отправляется задачей outbox_delivery
+type OutboxService struct {
+	repository  outboxRepository
+	telegramBot *bot.Bot
+}
+
+func NewOutboxService(repository outboxRepository, telegramBot *bot.Bot) *OutboxService {
+	return &OutboxService{repository: repository, telegramBot: telegramBot}
+}
+
+// SendAtLocalTime отправляет уведомление сразу, если у клиента сейчас разрешённые часы, иначе ставит его
+// в очередь на их начало. Возвращает время, когда клиент получит уведомление
+func (s *OutboxService) SendAtLocalTime(ctx context.Context, customer *database.Customer, kind string, params *bot.SendMessageParams) (time.Time, error) {
+	now := clock.Now()
+	at := deliveryTime(customer, now)
+	if !at.After(now) {
+		_, err := s.telegramBot.SendMessage(ctx, params)
+		return now, err
+	}
+
+	message, err := outboxMessage(customer.ID, kind, params, at)
+	if err != nil {
+		return now, err
+	}
+	if err := s.repository.Enqueue(ctx, message); err != nil {
+		return now, err
+	}
+	slog.InfoContext(ctx, "Notification deferred to customer local hours", "customerId", utils.MaskHalfInt64(customer.ID), "kind", kind, "sendAfter", at)
+	return at, nil
+}
+
+// deliveryTime возвращает ближайший момент не раньше now, когда клиенту можно отправить уведомление
+func deliveryTime(customer *database.Customer, now time.Time) time.Time {
+	from, to, enabled := config.NotifyLocalHours()
+	if !enabled {
+		return now
+	}
+	return locale.NextDeliveryTime(now, locale.CustomerLocation(customer.Timezone), from, to)
+}
+
+// outboxMessage сохраняет параметры сообщения для отправки в sendAfter. Поддерживается только inline-клавиатура
+func outboxMessage(customerID int64, kind string, params *bot.SendMessageParams, sendAfter time.Time) (*database.OutboxMessage, error) {
+	chatID, ok := params.ChatID.(int64)
+	if !ok {
+		return nil, fmt.Errorf("unsupported chat id %v for deferred notification", params.ChatID)
+	}
+	message := &database.OutboxMessage{
+		CustomerID: customerID,
+		Kind:       kind,
+		ChatID:     chatID,
+		Text:       params.Text,
+		ParseMode:  string(params.ParseMode),
+		SendAfter:  sendAfter,
+	}
+
+	var markup *models.InlineKeyboardMarkup
+	switch m := params.ReplyMarkup.(type) {
+	case nil:
+	case models.InlineKeyboardMarkup:
+		markup = &m
+	case *models.InlineKeyboardMarkup:
+		markup = m
+	default:
+		return nil, fmt.Errorf("unsupported reply markup %T for deferred notification", params.ReplyMarkup)
+	}
+	if markup != nil {
+		raw, err := json.Marshal(markup)
+		if err != nil {
+			return nil, fmt.Errorf("marshal reply markup: %w", err)
+		}
+		message.ReplyMarkup = raw
+	}
+	return message, nil
+}
+
+// outboxSendParams восстанавливает параметры отправки отложенного уведомления
+func outboxSendParams(message database.OutboxMessage) (*bot.SendMessageParams, error) {
+	params := &bot.SendMessageParams{
+		ChatID:    message.ChatID,
+		Text:      message.Text,
+		ParseMode: models.ParseMode(message.ParseMode),
+	}
+	if len(message.ReplyMarkup) > 0 {
+		var markup models.InlineKeyboardMarkup
+		if err := json.Unmarshal(message.ReplyMarkup, &markup); err != nil {
+			return nil, fmt.Errorf("unmarshal reply markup: %w", err)
+		}
+		params.ReplyMarkup = markup
+	}
+	return params, nil
+}
+
+// Run отправляет отложенные уведомления, срок которых наступил
+func (s *OutboxService) Run(ctx context.Context) error {
+	now := clock.Now()
+	messages, err := s.repository.FindDue(ctx, now.Add(-outboxMaxDelay), now, outboxBatchSize)
+	if err != nil {
+		return err
+	}
+
+	sent := 0
+	for _, message := range messages {
+		params, err := outboxSendParams(message)
+		if err == nil {
+			_, err = s.telegramBot.SendMessage(ctx, params)
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to send deferred notification", "customerId", utils.MaskHalfInt64(message.CustomerID), "kind", message.Kind, "error", err)
+			if err := s.repository.MarkFailed(ctx, message.ID, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.repository.MarkSent(ctx, message.ID, clock.Now()); err != nil {
+			return err
+		}
+		sent++
+
+		if err := utils.ThrottleSend(ctx); err != nil {
+			return err
+		}
+	}
+	if sent > 0 {
+		slog.InfoContext(ctx, "Deferred notifications sent", "sent", sent)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package notification
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+func TestOutboxMessageRoundTrip(t *testing.T) {
+	sendAfter := time.Date(2025, 6, 15, 9, 0, 0, 0, time.UTC)
+	keyboard := [][]models.InlineKeyboardButton{{{Text: "Продлить", CallbackData: "buy"}}}
+
+	for name, markup := range map[string]models.ReplyMarkup{
+		"value":   models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+		"pointer": &models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	} {
+		t.Run(name, func(t *testing.T) {
+			message, err := outboxMessage(7, "expiring", &bot.SendMessageParams{
+				ChatID:      int64(42),
+				Text:        "Подписка заканчивается",
+				ParseMode:   models.ParseModeHTML,
+				ReplyMarkup: markup,
+			}, sendAfter)
+			if err != nil {
+				t.Fatalf("outboxMessage() returned error: %v", err)
+			}
+			if message.CustomerID != 7 || message.ChatID != 42 || message.Kind != "expiring" || !message.SendAfter.Equal(sendAfter) {
+				t.Fatalf("Unexpected outbox message: %+v", message)
+			}
+
+			params, err := outboxSendParams(*message)
+			if err != nil {
+				t.Fatalf("outboxSendParams() returned error: %v", err)
+			}
+			if params.ChatID != int64(42) || params.Text != "Подписка заканчивается" || params.ParseMode != models.ParseModeHTML {
+				t.Fatalf("Unexpected send params: %+v", params)
+			}
+			got, ok := params.ReplyMarkup.(models.InlineKeyboardMarkup)
+			if !ok || !reflect.DeepEqual(got.InlineKeyboard, keyboard) {
+				t.Fatalf("Expected keyboard %v, got %v", keyboard, params.ReplyMarkup)
+			}
+		})
+	}
+}
+
+func TestOutboxMessageWithoutKeyboard(t *testing.T) {
+	message, err := outboxMessage(7, "winback", &bot.SendMessageParams{ChatID: int64(42), Text: "Предложение"}, time.Now())
+	if err != nil {
+		t.Fatalf("outboxMessage() returned error: %v", err)
+	}
+	if message.ReplyMarkup != nil {
+		t.Fatalf("Expected no keyboard, got %s", message.ReplyMarkup)
+	}
+	params, err := outboxSendParams(*message)
+	if err != nil || params.ReplyMarkup != nil {
+		t.Fatalf("outboxSendParams() = %+v, %v, want no keyboard", params, err)
+	}
+}
+
+func TestOutboxMessageRejectsUnsupportedMarkup(t *testing.T) {
+	_, err := outboxMessage(7, "expiring", &bot.SendMessageParams{
+		ChatID:      int64(42),
+		ReplyMarkup: models.ReplyKeyboardRemove{RemoveKeyboard: true},
+	}, time.Now())
+	if err == nil {
+		t.Fatal("Expected error for reply keyboard")
+	}
+}
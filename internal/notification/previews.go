@@ -0,0 +1,59 @@
+package notification
+
+import (
+	"time"
+
+	"github.com/go-telegram/bot"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/handler"
+	"remnawave-tg-shop-bot/internal/translation"
+)
+
+// Previews образцы уведомлений фоновых рассылок для меню тестирования уведомлений админа
+func Previews(tm *translation.Manager) []handler.NotificationPreview {
+	return []handler.NotificationPreview{
+		{Title: "Неактивный триал", Render: func(lang string) *bot.SendMessageParams {
+			return inactiveTrialMessage(tm, lang)
+		}},
+		{Title: "Напоминание о промо-тарифе", Render: func(lang string) *bot.SendMessageParams {
+			return offerReminderMessage(tm, lang, sampleOfferReminder("offer_reminder_promo_tariff", "promo_tariff_activate_button", handler.CallbackPromoTariff), time.Now())
+		}},
+		{Title: "Напоминание о winback предложении", Render: func(lang string) *bot.SendMessageParams {
+			return offerReminderMessage(tm, lang, sampleOfferReminder("offer_reminder_winback", "winback_activate_button", handler.CallbackWinbackActivate), time.Now())
+		}},
+		{Title: "Winback для ушедших платящих", Render: func(lang string) *bot.SendMessageParams {
+			price := config.Price1()
+			return paidWinbackMessage(tm, lang, PaidWinbackOffer{
+				Months:    1,
+				Devices:   config.GetWinbackDevices(),
+				BasePrice: price,
+				Price:     price * (100 - config.WinbackPaidDiscountPercent()) / 100,
+			})
+		}},
+		{Title: "Повышение цены автопродления", Render: func(lang string) *bot.SendMessageParams {
+			return recurringPriceMessage(tm, lang, sampleRecurringPriceChange(config.Price1()+config.Price1()/10), time.Now().AddDate(0, 0, recurringPriceNoticeDays))
+		}},
+		{Title: "Снижение цены автопродления", Render: func(lang string) *bot.SendMessageParams {
+			return recurringPriceMessage(tm, lang, sampleRecurringPriceChange(config.Price1()-config.Price1()/10), time.Now().AddDate(0, 0, recurringPriceNoticeDays))
+		}},
+	}
+}
+
+func sampleOfferReminder(textKey, buttonKey, callback string) offerReminder {
+	return offerReminder{
+		ExpiresAt:      time.Now().Add(time.Duration(config.OfferReminderHoursBefore()) * time.Hour),
+		Price:          config.GetWinbackPrice(),
+		Months:         config.GetWinbackMonths(),
+		Devices:        config.GetWinbackDevices(),
+		TextKey:        textKey,
+		ButtonKey:      buttonKey,
+		ButtonCallback: callback,
+	}
+}
+
+func sampleRecurringPriceChange(amount int) RecurringPriceChange {
+	current := config.Price1()
+	return RecurringPriceChange{Customer: database.Customer{RecurringAmount: &current}, ListPrice: amount, Amount: amount}
+}
@@ -0,0 +1,23 @@
+package notification
+
+import (
+	"strings"
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/translation"
+)
+
+func TestPreviewsRender(t *testing.T) {
+	tm := translation.GetInstance()
+	if err := tm.InitTranslations("../../translations", "en"); err != nil {
+		t.Fatalf("InitTranslations: %v", err)
+	}
+	for _, lang := range tm.Languages() {
+		for _, preview := range Previews(tm) {
+			params := preview.Render(lang)
+			if params.Text == "" || params.ChatID != nil || strings.Contains(params.Text, "%!") || strings.Contains(params.Text, "{{.") {
+				t.Errorf("%s [%s]: unexpected message %+v", preview.Title, lang, params)
+			}
+		}
+	}
+}
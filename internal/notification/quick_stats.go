@@ -0,0 +1,110 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+// quickStatsTTL сколько живёт сводка в шапке /admin: повторные открытия панели не ходят в базу
+const quickStatsTTL = 60 * time.Second
+
+type quickStatsRepository interface {
+	CountNewCustomers(ctx context.Context, from, to time.Time) (int, error)
+	PaymentsByProvider(ctx context.Context, from, to time.Time) ([]database.ProviderPaymentStats, error)
+	CountActiveSubscriptions(ctx context.Context, now time.Time) (int, error)
+	CountPendingBroadcasts(ctx context.Context) (int, error)
+}
+
+// QuickStats сводка для шапки админ-панели: сегодня считается с полуночи в TIMEZONE
+type QuickStats struct {
+	At                  time.Time
+	Revenue             map[string]float64 // выручка за сегодня по валютам
+	Payments            int
+	NewUsers            int
+	ActiveSubscriptions int
+	PendingBroadcasts   int
+}
+
+// QuickStatsService считает сводку для шапки /admin и кеширует её на quickStatsTTL
+type QuickStatsService struct {
+	stats quickStatsRepository
+
+	mu     sync.Mutex
+	cached *QuickStats
+}
+
+func NewQuickStatsService(stats quickStatsRepository) *QuickStatsService {
+	return &QuickStatsService{stats: stats}
+}
+
+// Get возвращает сводку не старше quickStatsTTL. Пока считается новая, параллельные вызовы ждут её, а не запрашивают базу
+func (s *QuickStatsService) Get(ctx context.Context) (*QuickStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := clock.Now().In(config.Timezone())
+	if s.cached != nil && now.Sub(s.cached.At) < quickStatsTTL {
+		return s.cached, nil
+	}
+
+	stats, err := s.collect(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	s.cached = stats
+	return stats, nil
+}
+
+func (s *QuickStatsService) collect(ctx context.Context, now time.Time) (*QuickStats, error) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	stats := &QuickStats{At: now, Revenue: map[string]float64{}}
+
+	payments, err := s.stats.PaymentsByProvider(ctx, midnight, now)
+	if err != nil {
+		return nil, fmt.Errorf("payments by provider: %w", err)
+	}
+	for _, p := range payments {
+		stats.Payments += p.Count
+		stats.Revenue[p.Currency] += p.Revenue
+	}
+	if stats.NewUsers, err = s.stats.CountNewCustomers(ctx, midnight, now); err != nil {
+		return nil, fmt.Errorf("count new customers: %w", err)
+	}
+	if stats.ActiveSubscriptions, err = s.stats.CountActiveSubscriptions(ctx, now); err != nil {
+		return nil, fmt.Errorf("count active subscriptions: %w", err)
+	}
+	if stats.PendingBroadcasts, err = s.stats.CountPendingBroadcasts(ctx); err != nil {
+		return nil, fmt.Errorf("count pending broadcasts: %w", err)
+	}
+	return stats, nil
+}
+
+// Header сводка одним HTML блоком для шапки /admin
+func (s *QuickStatsService) Header(ctx context.Context) (string, error) {
+	stats, err := s.Get(ctx)
+	if err != nil {
+		return "", err
+	}
+	return FormatQuickStats(stats), nil
+}
+
+// FormatQuickStats форматирует сводку в несколько коротких строк HTML
+func FormatQuickStats(s *QuickStats) string {
+	var sb strings.Builder
+	revenue := "0"
+	if len(s.Revenue) > 0 {
+		revenue = formatRevenue(s.Revenue)
+	}
+	sb.WriteString(fmt.Sprintf("💰 Сегодня: <b>%s</b> (оплат: %d)\n", revenue, s.Payments))
+	sb.WriteString(fmt.Sprintf("👤 Новых: <b>%d</b> · ✅ Активных подписок: <b>%d</b>\n", s.NewUsers, s.ActiveSubscriptions))
+	sb.WriteString(fmt.Sprintf("📨 Рассылок в очереди: <b>%d</b>\n", s.PendingBroadcasts))
+	sb.WriteString(fmt.Sprintf("<i>на %s</i>", s.At.Format("15:04")))
+	return sb.String()
+}
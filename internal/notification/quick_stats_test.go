@@ -0,0 +1,102 @@
+package notification
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+type quickStatsRepoMock struct {
+	calls       int
+	paymentFrom time.Time
+}
+
+func (m *quickStatsRepoMock) CountNewCustomers(context.Context, time.Time, time.Time) (int, error) {
+	return 5, nil
+}
+
+func (m *quickStatsRepoMock) PaymentsByProvider(_ context.Context, from, _ time.Time) ([]database.ProviderPaymentStats, error) {
+	m.calls++
+	m.paymentFrom = from
+	return []database.ProviderPaymentStats{
+		{InvoiceType: database.InvoiceTypeYookasa, Currency: "RUB", Count: 3, Revenue: 1500},
+		{InvoiceType: database.InvoiceTypeCrypto, Currency: "RUB", Count: 1, Revenue: 200},
+		{InvoiceType: database.InvoiceTypeTelegram, Currency: "XTR", Count: 2, Revenue: 300},
+	}, nil
+}
+
+func (m *quickStatsRepoMock) CountActiveSubscriptions(context.Context, time.Time) (int, error) {
+	return 420, nil
+}
+
+func (m *quickStatsRepoMock) CountPendingBroadcasts(context.Context) (int, error) {
+	return 1, nil
+}
+
+func TestQuickStatsServiceCachesForTTL(t *testing.T) {
+	now := time.Date(2025, 3, 10, 14, 30, 0, 0, time.Local)
+	fake := clock.NewFake(now)
+	clock.SetDefault(fake)
+	t.Cleanup(func() { clock.SetDefault(nil) })
+
+	repo := &quickStatsRepoMock{}
+	service := NewQuickStatsService(repo)
+
+	stats, err := service.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get() returned error: %v", err)
+	}
+	if want := time.Date(2025, 3, 10, 0, 0, 0, 0, time.Local); !repo.paymentFrom.Equal(want) {
+		t.Errorf("Expected today to start at %v, got %v", want, repo.paymentFrom)
+	}
+	if stats.Payments != 6 || stats.Revenue["RUB"] != 1700 || stats.Revenue["XTR"] != 300 ||
+		stats.NewUsers != 5 || stats.ActiveSubscriptions != 420 || stats.PendingBroadcasts != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	fake.Advance(59 * time.Second)
+	if _, err := service.Get(context.Background()); err != nil {
+		t.Fatalf("Get() returned error: %v", err)
+	}
+	if repo.calls != 1 {
+		t.Errorf("Expected cached stats within the TTL, got %d queries", repo.calls)
+	}
+
+	fake.Advance(time.Second)
+	if _, err := service.Get(context.Background()); err != nil {
+		t.Fatalf("Get() returned error: %v", err)
+	}
+	if repo.calls != 2 {
+		t.Errorf("Expected stats to be recomputed after the TTL, got %d queries", repo.calls)
+	}
+}
+
+func TestFormatQuickStats(t *testing.T) {
+	text := FormatQuickStats(&QuickStats{
+		At:                  time.Date(2025, 3, 10, 14, 30, 0, 0, time.UTC),
+		Revenue:             map[string]float64{"RUB": 1700, "XTR": 300},
+		Payments:            6,
+		NewUsers:            5,
+		ActiveSubscriptions: 420,
+		PendingBroadcasts:   1,
+	})
+
+	for _, want := range []string{
+		"Сегодня: <b>1700 RUB + 300 XTR</b> (оплат: 6)",
+		"Новых: <b>5</b>",
+		"Активных подписок: <b>420</b>",
+		"Рассылок в очереди: <b>1</b>",
+		"на 14:30",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in:\n%s", want, text)
+		}
+	}
+	if empty := FormatQuickStats(&QuickStats{Revenue: map[string]float64{}}); !strings.Contains(empty, "Сегодня: <b>0</b> (оплат: 0)") {
+		t.Errorf("Expected zero revenue without payments, got:\n%s", empty)
+	}
+}
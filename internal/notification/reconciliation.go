@@ -0,0 +1,477 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/cryptopay"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/yookasa"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// reconcileMargin запас при выборке платежей провайдера: оплата у провайдера и отметка в БД
+// могут попасть в разные сутки
+const reconcileMargin = time.Hour
+
+const (
+	cryptoInvoicesPageSize   = 1000
+	starTransactionsPageSize = 100
+	// reconcileMaxPages ограничивает выборку у провайдера, чтобы сверка не зависала на больших историях
+	reconcileMaxPages = 200
+)
+
+// errUpstreamTruncated выборка у провайдера остановлена на reconcileMaxPages: часть платежей периода не получена
+var errUpstreamTruncated = errors.New("provider history truncated at page limit")
+
+// maxReconcileMismatches количество расхождений каждого вида в отчёте
+const maxReconcileMismatches = 20
+
+type reconcilePurchaseRepository interface {
+	FindPaidBetween(ctx context.Context, invoiceTypes []database.InvoiceType, from, to time.Time) ([]database.Purchase, error)
+	FindById(ctx context.Context, id int64) (*database.Purchase, error)
+}
+
+type yookasaPaymentLister interface {
+	ListSucceededPayments(ctx context.Context, from, to time.Time) ([]yookasa.Payment, error)
+}
+
+type cryptoInvoiceLister interface {
+	GetInvoices(ctx context.Context, status, fiat, asset, invoiceIds string, offset, limit int) (*[]cryptopay.InvoiceResponse, error)
+}
+
+type starTransactionLister interface {
+	GetStarTransactions(ctx context.Context, params *bot.GetStarTransactionsParams) (*models.StarTransactions, error)
+}
+
+// UpstreamPayment успешный платёж на стороне провайдера
+type UpstreamPayment struct {
+	InvoiceType database.InvoiceType
+	ExternalID  string
+	PurchaseID  int64 // 0 если платёж не удалось связать с покупкой
+	Amount      string
+	PaidAt      time.Time
+}
+
+// ReconciliationMismatch расхождение между провайдером и БД
+type ReconciliationMismatch struct {
+	InvoiceType database.InvoiceType
+	PurchaseID  int64
+	ExternalID  string
+	Amount      string
+	Status      database.PurchaseStatus // статус покупки в БД (пусто, если покупки нет)
+}
+
+// ReconciliationReport результат сверки платежей за период
+type ReconciliationReport struct {
+	From         time.Time
+	To           time.Time
+	Checked      map[database.InvoiceType]int // успешных платежей у провайдера за период
+	NotFulfilled []ReconciliationMismatch     // оплачено у провайдера, но покупка не выдана
+	NoUpstream   []ReconciliationMismatch     // покупка выдана, но успешного платежа у провайдера нет
+	Errors       []string                     // провайдеры, которые не удалось проверить
+}
+
+// HasIssues возвращает true если найдены расхождения или часть провайдеров не проверена
+func (r *ReconciliationReport) HasIssues() bool {
+	return len(r.NotFulfilled) > 0 || len(r.NoUpstream) > 0 || len(r.Errors) > 0
+}
+
+type upstreamSource struct {
+	invoiceType database.InvoiceType
+	list        func(ctx context.Context, from, to time.Time) ([]UpstreamPayment, error)
+}
+
+// ReconciliationService сверяет успешные платежи провайдеров с оплаченными покупками,
+// чтобы находить потерянные выдачи подписок
+type ReconciliationService struct {
+	purchases   reconcilePurchaseRepository
+	sources     []upstreamSource
+	telegramBot *bot.Bot
+}
+
+// NewReconciliationService создаёт сервис сверки для включённых в конфиге провайдеров
+func NewReconciliationService(purchases reconcilePurchaseRepository, yookasaClient *yookasa.Client,
+	cryptoPayClient *cryptopay.Client, telegramBot *bot.Bot) *ReconciliationService {
+	s := &ReconciliationService{purchases: purchases, telegramBot: telegramBot}
+	if config.IsYookasaEnabled() {
+		s.addSource(database.InvoiceTypeYookasa, yookasaUpstream(yookasaClient))
+	}
+	if config.IsCryptoPayEnabled() {
+		s.addSource(database.InvoiceTypeCrypto, cryptoUpstream(cryptoPayClient))
+	}
+	if config.IsTelegramStarsEnabled() {
+		s.addSource(database.InvoiceTypeTelegram, starsUpstream(telegramBot))
+	}
+	return s
+}
+
+func (s *ReconciliationService) addSource(invoiceType database.InvoiceType, list func(ctx context.Context, from, to time.Time) ([]UpstreamPayment, error)) {
+	s.sources = append(s.sources, upstreamSource{invoiceType: invoiceType, list: list})
+}
+
+// Reconcile сверяет платежи за период [from, to)
+func (s *ReconciliationService) Reconcile(ctx context.Context, from, to time.Time) (*ReconciliationReport, error) {
+	report := &ReconciliationReport{From: from, To: to, Checked: make(map[database.InvoiceType]int)}
+
+	var checkedTypes []database.InvoiceType
+	upstreamPurchases := make(map[int64]bool)
+	for _, src := range s.sources {
+		payments, err := src.list(ctx, from.Add(-reconcileMargin), to.Add(reconcileMargin))
+		switch {
+		case errors.Is(err, errUpstreamTruncated):
+			// Полученные платежи ещё можно проверить на выдачу, но «выдано без платежа» по неполной выборке не определить
+			slog.WarnContext(ctx, "Provider payments truncated for reconciliation", "invoiceType", src.invoiceType, "received", len(payments))
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", src.invoiceType, err))
+		case err != nil:
+			slog.ErrorContext(ctx, "Failed to list provider payments for reconciliation", "invoiceType", src.invoiceType, "error", err)
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", src.invoiceType, err))
+			continue
+		default:
+			checkedTypes = append(checkedTypes, src.invoiceType)
+		}
+
+		for _, p := range payments {
+			if p.PurchaseID != 0 {
+				upstreamPurchases[p.PurchaseID] = true
+			}
+			if p.PaidAt.Before(from) || !p.PaidAt.Before(to) {
+				continue
+			}
+			report.Checked[p.InvoiceType]++
+
+			mismatch := ReconciliationMismatch{InvoiceType: p.InvoiceType, PurchaseID: p.PurchaseID, ExternalID: p.ExternalID, Amount: p.Amount}
+			if p.PurchaseID == 0 {
+				report.NotFulfilled = append(report.NotFulfilled, mismatch)
+				continue
+			}
+			purchase, err := s.purchases.FindById(ctx, p.PurchaseID)
+			if err != nil {
+				return nil, fmt.Errorf("find purchase %d: %w", p.PurchaseID, err)
+			}
+			if purchase != nil {
+				if isFulfilled(purchase.Status) {
+					continue
+				}
+				mismatch.Status = purchase.Status
+			}
+			report.NotFulfilled = append(report.NotFulfilled, mismatch)
+		}
+	}
+
+	if len(checkedTypes) == 0 {
+		return report, nil
+	}
+
+	paid, err := s.purchases.FindPaidBetween(ctx, checkedTypes, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("find paid purchases: %w", err)
+	}
+	for _, purchase := range paid {
+		if upstreamPurchases[purchase.ID] {
+			continue
+		}
+		mismatch := ReconciliationMismatch{
+			InvoiceType: purchase.InvoiceType,
+			PurchaseID:  purchase.ID,
+			Amount:      strconv.FormatFloat(purchase.Amount, 'f', -1, 64),
+			Status:      purchase.Status,
+		}
+		if purchase.YookasaID != nil {
+			mismatch.ExternalID = purchase.YookasaID.String()
+		} else if purchase.CryptoInvoiceID != nil {
+			mismatch.ExternalID = strconv.FormatInt(*purchase.CryptoInvoiceID, 10)
+		}
+		report.NoUpstream = append(report.NoUpstream, mismatch)
+	}
+
+	return report, nil
+}
+
+func isFulfilled(status database.PurchaseStatus) bool {
+	return status == database.PurchaseStatusPaid || status == database.PurchaseStatusRefunded
+}
+
+// ReconcileDay сверяет платежи за календарные сутки day
+func (s *ReconciliationService) ReconcileDay(ctx context.Context, day time.Time) (*ReconciliationReport, error) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	return s.Reconcile(ctx, from, from.AddDate(0, 0, 1))
+}
+
+// RunDailyReconciliation сверяет платежи за вчерашние сутки и отправляет отчёт админу, если есть расхождения
+func (s *ReconciliationService) RunDailyReconciliation(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	report, err := s.ReconcileDay(ctx, time.Now().In(config.Timezone()).AddDate(0, 0, -1))
+	if err != nil {
+		return err
+	}
+	if !report.HasIssues() {
+		slog.InfoContext(ctx, "Payment reconciliation found no mismatches", "checked", report.Checked)
+		return nil
+	}
+
+	slog.WarnContext(ctx, "Payment reconciliation found mismatches",
+		"notFulfilled", len(report.NotFulfilled), "noUpstream", len(report.NoUpstream), "errors", len(report.Errors))
+	_, err = utils.SendLongMessage(ctx, s.telegramBot, &bot.SendMessageParams{
+		ChatID:    config.GetAdminTelegramId(),
+		Text:      FormatReconciliationReport(report),
+		ParseMode: models.ParseModeHTML,
+	})
+	if err != nil {
+		return fmt.Errorf("send reconciliation report: %w", err)
+	}
+	return nil
+}
+
+// CommandHandler обрабатывает команду админа /reconcile [ГГГГ-ММ-ДД] (по умолчанию — вчерашние сутки)
+func (s *ReconciliationService) CommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	day := time.Now().In(config.Timezone()).AddDate(0, 0, -1)
+	if args := strings.Fields(update.Message.Text); len(args) > 1 {
+		parsed, err := time.ParseInLocation("2006-01-02", args[1], config.Timezone())
+		if err != nil {
+			s.reply(ctx, b, update.Message.Chat.ID, "❌ Неверный формат даты. Используйте: <code>/reconcile ГГГГ-ММ-ДД</code>")
+			return
+		}
+		day = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	report, err := s.ReconcileDay(ctx, day)
+	if err != nil {
+		slog.ErrorContext(ctx, "Payment reconciliation failed", "error", err)
+		s.reply(ctx, b, update.Message.Chat.ID, "❌ Не удалось выполнить сверку, подробности в логах")
+		return
+	}
+	s.reply(ctx, b, update.Message.Chat.ID, FormatReconciliationReport(report))
+}
+
+func (s *ReconciliationService) reply(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	_, err := utils.SendLongMessage(ctx, b, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending reconciliation report", "error", err)
+	}
+}
+
+// FormatReconciliationReport форматирует отчёт сверки в HTML сообщение
+func FormatReconciliationReport(r *ReconciliationReport) string {
+	var sb strings.Builder
+
+	sb.WriteString("🧾 <b>Сверка платежей</b>\n")
+	sb.WriteString(fmt.Sprintf("<i>%s — %s</i>\n\n", r.From.Format("02.01.2006 15:04"), r.To.Format("02.01.2006 15:04")))
+
+	for _, invoiceType := range []database.InvoiceType{database.InvoiceTypeYookasa, database.InvoiceTypeCrypto, database.InvoiceTypeTelegram} {
+		if count, ok := r.Checked[invoiceType]; ok {
+			sb.WriteString(fmt.Sprintf("• %s: успешных платежей <b>%d</b>\n", invoiceType, count))
+		}
+	}
+
+	if !r.HasIssues() {
+		sb.WriteString("\n✅ Расхождений не найдено")
+		return sb.String()
+	}
+
+	writeMismatches(&sb, "❗ Оплачено у провайдера, но не выдано", r.NotFulfilled)
+	writeMismatches(&sb, "⚠️ Выдано без успешного платежа у провайдера", r.NoUpstream)
+
+	if len(r.Errors) > 0 {
+		sb.WriteString("\n🚫 <b>Не удалось проверить</b>\n")
+		for _, e := range r.Errors {
+			sb.WriteString(fmt.Sprintf("• %s\n", utils.EscapeHTML(e)))
+		}
+	}
+	return sb.String()
+}
+
+func writeMismatches(sb *strings.Builder, title string, mismatches []ReconciliationMismatch) {
+	if len(mismatches) == 0 {
+		return
+	}
+	sb.WriteString(fmt.Sprintf("\n%s: <b>%d</b>\n", title, len(mismatches)))
+	for i, m := range mismatches {
+		if i == maxReconcileMismatches {
+			sb.WriteString(fmt.Sprintf("… и ещё %d\n", len(mismatches)-i))
+			break
+		}
+		purchase := "покупка не найдена"
+		if m.PurchaseID != 0 {
+			purchase = fmt.Sprintf("покупка #%d", m.PurchaseID)
+			if m.Status != "" {
+				purchase += fmt.Sprintf(" (%s)", m.Status)
+			}
+		}
+		sb.WriteString(fmt.Sprintf("• %s %s, %s", m.InvoiceType, utils.EscapeHTML(m.Amount), purchase))
+		if m.ExternalID != "" {
+			sb.WriteString(fmt.Sprintf(", <code>%s</code>", utils.EscapeHTML(m.ExternalID)))
+		}
+		sb.WriteString("\n")
+	}
+}
+
+// yookasaUpstream успешные платежи ЮKassa. Автосписания не связаны с покупками и пропускаются
+func yookasaUpstream(client yookasaPaymentLister) func(ctx context.Context, from, to time.Time) ([]UpstreamPayment, error) {
+	return func(ctx context.Context, from, to time.Time) ([]UpstreamPayment, error) {
+		payments, err := client.ListSucceededPayments(ctx, from, to)
+		if err != nil {
+			return nil, err
+		}
+
+		var result []UpstreamPayment
+		for _, p := range payments {
+			if p.IsRecurring() {
+				continue
+			}
+			paidAt := p.CreatedAt
+			if p.CapturedAt != nil {
+				paidAt = *p.CapturedAt
+			}
+			purchaseID, _ := strconv.ParseInt(p.Metadata["purchaseId"], 10, 64)
+			result = append(result, UpstreamPayment{
+				InvoiceType: database.InvoiceTypeYookasa,
+				ExternalID:  p.ID.String(),
+				PurchaseID:  purchaseID,
+				Amount:      p.Amount.Value + " " + p.Amount.Currency,
+				PaidAt:      paidAt,
+			})
+		}
+		return result, nil
+	}
+}
+
+// cryptoUpstream оплаченные счета CryptoPay. Payload счёта: purchaseId=<id>&username=<name>.
+// Счета возвращаются от новых к старым: выборка останавливается на странице, целиком оплаченной до from
+func cryptoUpstream(client cryptoInvoiceLister) func(ctx context.Context, from, to time.Time) ([]UpstreamPayment, error) {
+	return func(ctx context.Context, from, to time.Time) ([]UpstreamPayment, error) {
+		var result []UpstreamPayment
+		for page := 0; page < reconcileMaxPages; page++ {
+			invoices, err := client.GetInvoices(ctx, "paid", "", "", "", page*cryptoInvoicesPageSize, cryptoInvoicesPageSize)
+			if err != nil {
+				return nil, err
+			}
+			reachedFrom := true
+			for _, inv := range *invoices {
+				if inv.PaidAt == nil || inv.PaidAt.Before(from) {
+					continue
+				}
+				reachedFrom = false
+				if !inv.PaidAt.Before(to) {
+					continue
+				}
+				values, _ := url.ParseQuery(inv.Payload)
+				purchaseID, _ := strconv.ParseInt(values.Get("purchaseId"), 10, 64)
+				var externalID string
+				if inv.InvoiceID != nil {
+					externalID = strconv.FormatInt(*inv.InvoiceID, 10)
+				}
+				result = append(result, UpstreamPayment{
+					InvoiceType: database.InvoiceTypeCrypto,
+					ExternalID:  externalID,
+					PurchaseID:  purchaseID,
+					Amount:      inv.Amount + " " + inv.Fiat,
+					PaidAt:      *inv.PaidAt,
+				})
+			}
+			if reachedFrom || len(*invoices) < cryptoInvoicesPageSize {
+				return result, nil
+			}
+		}
+		return result, errUpstreamTruncated
+	}
+}
+
+// starsUpstream входящие платежи Telegram Stars. Payload счёта: <purchaseId>&<username>.
+// Транзакции возвращаются в хронологическом порядке: выборка начинается с первой транзакции не раньше from
+func starsUpstream(client starTransactionLister) func(ctx context.Context, from, to time.Time) ([]UpstreamPayment, error) {
+	return func(ctx context.Context, from, to time.Time) ([]UpstreamPayment, error) {
+		start, err := starsStartOffset(ctx, client, from)
+		if err != nil {
+			return nil, err
+		}
+
+		var result []UpstreamPayment
+		for page := 0; page < reconcileMaxPages; page++ {
+			txs, err := client.GetStarTransactions(ctx, &bot.GetStarTransactionsParams{
+				Offset: start + page*starTransactionsPageSize,
+				Limit:  starTransactionsPageSize,
+			})
+			if err != nil {
+				return nil, err
+			}
+			for _, tx := range txs.Transactions {
+				paidAt := time.Unix(int64(tx.Date), 0)
+				if !paidAt.Before(to) {
+					return result, nil
+				}
+				if paidAt.Before(from) || tx.Source == nil || tx.Source.User == nil || tx.Source.User.InvoicePayload == "" {
+					continue
+				}
+				purchaseID, _ := strconv.ParseInt(strings.SplitN(tx.Source.User.InvoicePayload, "&", 2)[0], 10, 64)
+				result = append(result, UpstreamPayment{
+					InvoiceType: database.InvoiceTypeTelegram,
+					ExternalID:  tx.ID,
+					PurchaseID:  purchaseID,
+					Amount:      fmt.Sprintf("%d XTR", tx.Amount),
+					PaidAt:      paidAt,
+				})
+			}
+			if len(txs.Transactions) < starTransactionsPageSize {
+				return result, nil
+			}
+		}
+		return result, errUpstreamTruncated
+	}
+}
+
+// starsStartOffset ищет смещение первой транзакции не раньше from, запрашивая по одной транзакции:
+// сначала удваивает шаг до транзакции после from или конца истории, затем делит отрезок пополам
+func starsStartOffset(ctx context.Context, client starTransactionLister, from time.Time) (int, error) {
+	before := func(offset int) (bool, error) {
+		txs, err := client.GetStarTransactions(ctx, &bot.GetStarTransactionsParams{Offset: offset, Limit: 1})
+		if err != nil {
+			return false, err
+		}
+		return len(txs.Transactions) > 0 && time.Unix(int64(txs.Transactions[0].Date), 0).Before(from), nil
+	}
+
+	// Все транзакции до lo раньше from, транзакция hi — нет
+	lo, hi := 0, starTransactionsPageSize
+	for {
+		ok, err := before(hi)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		lo, hi = hi+1, hi*2
+	}
+	for lo < hi {
+		mid := (lo + hi) / 2
+		ok, err := before(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
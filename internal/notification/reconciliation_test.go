@@ -0,0 +1,235 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"remnawave-tg-shop-bot/internal/cryptopay"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+type reconcileRepoMock struct {
+	purchases map[int64]*database.Purchase
+	paid      []database.Purchase
+	paidTypes []database.InvoiceType
+}
+
+func (m *reconcileRepoMock) FindPaidBetween(ctx context.Context, invoiceTypes []database.InvoiceType, from, to time.Time) ([]database.Purchase, error) {
+	m.paidTypes = invoiceTypes
+	return m.paid, nil
+}
+
+func (m *reconcileRepoMock) FindById(ctx context.Context, id int64) (*database.Purchase, error) {
+	return m.purchases[id], nil
+}
+
+func staticSource(payments []UpstreamPayment, err error) func(ctx context.Context, from, to time.Time) ([]UpstreamPayment, error) {
+	return func(ctx context.Context, from, to time.Time) ([]UpstreamPayment, error) {
+		return payments, err
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	from := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+
+	repo := &reconcileRepoMock{
+		purchases: map[int64]*database.Purchase{
+			1: {ID: 1, Status: database.PurchaseStatusPaid},
+			2: {ID: 2, Status: database.PurchaseStatusPending},
+		},
+		paid: []database.Purchase{
+			{ID: 1, InvoiceType: database.InvoiceTypeYookasa, Status: database.PurchaseStatusPaid, Amount: 199},
+			{ID: 5, InvoiceType: database.InvoiceTypeYookasa, Status: database.PurchaseStatusPaid, Amount: 499},
+			// оплачена у провайдера накануне вечером, отмечена после полуночи
+			{ID: 6, InvoiceType: database.InvoiceTypeYookasa, Status: database.PurchaseStatusPaid, Amount: 199},
+		},
+	}
+	s := &ReconciliationService{purchases: repo}
+	s.addSource(database.InvoiceTypeYookasa, staticSource([]UpstreamPayment{
+		{InvoiceType: database.InvoiceTypeYookasa, PurchaseID: 1, PaidAt: from.Add(time.Hour)},
+		{InvoiceType: database.InvoiceTypeYookasa, PurchaseID: 2, PaidAt: from.Add(2 * time.Hour)},
+		{InvoiceType: database.InvoiceTypeYookasa, PurchaseID: 3, PaidAt: from.Add(3 * time.Hour)},
+		{InvoiceType: database.InvoiceTypeYookasa, PurchaseID: 6, PaidAt: from.Add(-time.Minute)},
+	}, nil))
+	s.addSource(database.InvoiceTypeCrypto, staticSource(nil, errors.New("timeout")))
+
+	report, err := s.Reconcile(context.Background(), from, to)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if report.Checked[database.InvoiceTypeYookasa] != 3 {
+		t.Errorf("Expected 3 checked payments inside the period, got %d", report.Checked[database.InvoiceTypeYookasa])
+	}
+	if len(report.NotFulfilled) != 2 || report.NotFulfilled[0].PurchaseID != 2 || report.NotFulfilled[1].PurchaseID != 3 {
+		t.Errorf("Expected purchases 2 and 3 not fulfilled, got %+v", report.NotFulfilled)
+	}
+	if report.NotFulfilled[0].Status != database.PurchaseStatusPending {
+		t.Errorf("Expected pending status for purchase 2, got %q", report.NotFulfilled[0].Status)
+	}
+	if len(report.NoUpstream) != 1 || report.NoUpstream[0].PurchaseID != 5 {
+		t.Errorf("Expected only purchase 5 without upstream payment, got %+v", report.NoUpstream)
+	}
+	if len(report.Errors) != 1 {
+		t.Errorf("Expected failed provider to be reported, got %v", report.Errors)
+	}
+	if len(repo.paidTypes) != 1 || repo.paidTypes[0] != database.InvoiceTypeYookasa {
+		t.Errorf("Expected local purchases to be checked only for reachable providers, got %v", repo.paidTypes)
+	}
+}
+
+func TestReconcileNoIssues(t *testing.T) {
+	from := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	repo := &reconcileRepoMock{
+		purchases: map[int64]*database.Purchase{1: {ID: 1, Status: database.PurchaseStatusPaid}},
+		paid:      []database.Purchase{{ID: 1, InvoiceType: database.InvoiceTypeTelegram, Status: database.PurchaseStatusPaid}},
+	}
+	s := &ReconciliationService{purchases: repo}
+	s.addSource(database.InvoiceTypeTelegram, staticSource([]UpstreamPayment{
+		{InvoiceType: database.InvoiceTypeTelegram, PurchaseID: 1, PaidAt: from.Add(time.Hour)},
+	}, nil))
+
+	report, err := s.ReconcileDay(context.Background(), from.Add(15*time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if report.HasIssues() {
+		t.Errorf("Expected no issues, got %+v", report)
+	}
+	if !strings.Contains(FormatReconciliationReport(report), "Расхождений не найдено") {
+		t.Error("Expected clean report message")
+	}
+}
+
+func TestFormatReconciliationReport(t *testing.T) {
+	report := &ReconciliationReport{
+		From:    time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+		To:      time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC),
+		Checked: map[database.InvoiceType]int{database.InvoiceTypeYookasa: 3},
+		NotFulfilled: []ReconciliationMismatch{
+			{InvoiceType: database.InvoiceTypeYookasa, PurchaseID: 2, Amount: "199.00 RUB", Status: database.PurchaseStatusPending, ExternalID: "abc"},
+		},
+		Errors: []string{"crypto: <timeout>"},
+	}
+
+	text := FormatReconciliationReport(report)
+	for _, want := range []string{"покупка #2 (pending)", "<code>abc</code>", "&lt;timeout&gt;", "успешных платежей <b>3</b>"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected report to contain %q, got:\n%s", want, text)
+		}
+	}
+}
+
+// starHistoryMock хранит историю Telegram Stars в хронологическом порядке
+type starHistoryMock struct {
+	txs   []models.StarTransaction
+	calls int
+}
+
+func (m *starHistoryMock) GetStarTransactions(ctx context.Context, params *bot.GetStarTransactionsParams) (*models.StarTransactions, error) {
+	m.calls++
+	start := min(params.Offset, len(m.txs))
+	end := min(start+params.Limit, len(m.txs))
+	return &models.StarTransactions{Transactions: m.txs[start:end]}, nil
+}
+
+func TestStarsUpstreamSkipsOldHistory(t *testing.T) {
+	from := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+
+	// Десять тысяч старых транзакций, две за сутки и одна после
+	history := &starHistoryMock{}
+	for i := 0; i < 10000; i++ {
+		history.txs = append(history.txs, models.StarTransaction{ID: "old", Date: int(from.Add(-time.Duration(10000-i) * time.Minute).Unix())})
+	}
+	for i, at := range []time.Time{from.Add(time.Hour), from.Add(2 * time.Hour), to.Add(time.Hour)} {
+		history.txs = append(history.txs, models.StarTransaction{
+			ID:     strconv.Itoa(i + 1),
+			Amount: 100,
+			Date:   int(at.Unix()),
+			Source: &models.TransactionPartner{User: &models.TransactionPartnerUser{InvoicePayload: strconv.Itoa(i+1) + "&user"}},
+		})
+	}
+
+	payments, err := starsUpstream(history)(context.Background(), from, to)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(payments) != 2 || payments[0].PurchaseID != 1 || payments[1].PurchaseID != 2 {
+		t.Errorf("Expected payments for purchases 1 and 2, got %+v", payments)
+	}
+	if history.calls > 40 {
+		t.Errorf("Expected old history to be skipped by offset search, got %d requests", history.calls)
+	}
+}
+
+// cryptoInvoicesMock возвращает оплаченные счета от новых к старым
+type cryptoInvoicesMock struct {
+	invoices []cryptopay.InvoiceResponse
+	calls    int
+}
+
+func (m *cryptoInvoicesMock) GetInvoices(ctx context.Context, status, fiat, asset, invoiceIds string, offset, limit int) (*[]cryptopay.InvoiceResponse, error) {
+	m.calls++
+	start := min(offset, len(m.invoices))
+	end := min(start+limit, len(m.invoices))
+	page := m.invoices[start:end]
+	return &page, nil
+}
+
+func TestCryptoUpstreamStopsBeforePeriod(t *testing.T) {
+	from := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+
+	invoices := &cryptoInvoicesMock{}
+	paidAt := from.Add(time.Hour)
+	invoices.invoices = append(invoices.invoices, cryptopay.InvoiceResponse{Payload: "purchaseId=7&username=u", PaidAt: &paidAt})
+	for i := 0; i < 5*cryptoInvoicesPageSize; i++ {
+		old := from.Add(-time.Duration(i+1) * time.Minute)
+		invoices.invoices = append(invoices.invoices, cryptopay.InvoiceResponse{PaidAt: &old})
+	}
+
+	payments, err := cryptoUpstream(invoices)(context.Background(), from, to)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(payments) != 1 || payments[0].PurchaseID != 7 {
+		t.Errorf("Expected payment for purchase 7, got %+v", payments)
+	}
+	if invoices.calls != 2 {
+		t.Errorf("Expected paging to stop at the first page before the period, got %d requests", invoices.calls)
+	}
+}
+
+func TestReconcileReportsTruncatedUpstream(t *testing.T) {
+	from := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	repo := &reconcileRepoMock{
+		purchases: map[int64]*database.Purchase{2: {ID: 2, Status: database.PurchaseStatusPending}},
+		paid:      []database.Purchase{{ID: 5, InvoiceType: database.InvoiceTypeCrypto, Status: database.PurchaseStatusPaid}},
+	}
+	s := &ReconciliationService{purchases: repo}
+	s.addSource(database.InvoiceTypeCrypto, staticSource([]UpstreamPayment{
+		{InvoiceType: database.InvoiceTypeCrypto, PurchaseID: 2, PaidAt: from.Add(time.Hour)},
+	}, errUpstreamTruncated))
+
+	report, err := s.Reconcile(context.Background(), from, from.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(report.Errors) != 1 || !strings.Contains(report.Errors[0], "truncated") {
+		t.Errorf("Expected truncated provider to be reported, got %v", report.Errors)
+	}
+	if len(report.NotFulfilled) != 1 || report.NotFulfilled[0].PurchaseID != 2 {
+		t.Errorf("Expected received payments to be checked, got %+v", report.NotFulfilled)
+	}
+	if len(report.NoUpstream) != 0 || repo.paidTypes != nil {
+		t.Errorf("Expected no upstream check for truncated provider, got %+v", report.NoUpstream)
+	}
+}
@@ -0,0 +1,100 @@
+package notification
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/handler"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/translation"
+	"remnawave-tg-shop-bot/utils"
+)
+
+type recurringBulkRepository interface {
+	ClaimDue(ctx context.Context, now time.Time) (*database.RecurringBulkDisable, error)
+	Recipients(ctx context.Context, op *database.RecurringBulkDisable) ([]database.Customer, error)
+}
+
+// RecurringBulkNotifier сообщает клиентам об отключении автопродления из админки, когда срок отмены
+// операции истёк. Отменённые операции и клиенты, снова включившие автопродление, уведомлений не получают
+type RecurringBulkNotifier struct {
+	repository  recurringBulkRepository
+	telegramBot *bot.Bot
+	tm          *translation.Manager
+	outbox      *OutboxService
+}
+
+func NewRecurringBulkNotifier(repository recurringBulkRepository, telegramBot *bot.Bot, tm *translation.Manager) *RecurringBulkNotifier {
+	return &RecurringBulkNotifier{repository: repository, telegramBot: telegramBot, tm: tm}
+}
+
+// SetOutbox включает доставку уведомлений в часы NOTIFY_LOCAL_HOURS по местному времени клиента (может быть nil)
+func (s *RecurringBulkNotifier) SetOutbox(outbox *OutboxService) {
+	s.outbox = outbox
+}
+
+// Run уведомляет клиентов всех операций, срок отмены которых истёк
+func (s *RecurringBulkNotifier) Run(ctx context.Context) error {
+	for {
+		op, err := s.repository.ClaimDue(ctx, clock.Now())
+		if err != nil {
+			return err
+		}
+		if op == nil {
+			return nil
+		}
+
+		customers, err := s.repository.Recipients(ctx, op)
+		if err != nil {
+			return err
+		}
+		sent := 0
+		for i := range customers {
+			if err := s.send(ctx, &customers[i]); err != nil {
+				slog.WarnContext(ctx, "Failed to send recurring bulk disable notification", "customerId", utils.MaskHalfInt64(customers[i].ID), "error", err)
+				continue
+			}
+			sent++
+
+			if err := utils.ThrottleSend(ctx); err != nil {
+				return err
+			}
+		}
+		slog.InfoContext(ctx, "Recurring bulk disable notifications sent", "operationId", op.ID, "sent", sent)
+	}
+}
+
+func (s *RecurringBulkNotifier) send(ctx context.Context, customer *database.Customer) error {
+	lang := customer.Language
+	if lang == "" {
+		lang = config.DefaultLanguage()
+	}
+
+	textKey := "recurring_bulk_disabled"
+	data := map[string]interface{}{}
+	if customer.ExpireAt != nil && customer.ExpireAt.After(clock.Now()) {
+		textKey = "recurring_bulk_disabled_active"
+		data["expire_date"] = locale.FormatDate(lang, *customer.ExpireAt)
+	}
+
+	params := &bot.SendMessageParams{
+		ChatID:    customer.TelegramID,
+		Text:      s.tm.GetTextTemplate(lang, textKey, data),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: s.tm.GetText(lang, "buy_button"), CallbackData: handler.CallbackBuy}},
+		}},
+	}
+	if s.outbox != nil {
+		_, err := s.outbox.SendAtLocalTime(ctx, customer, database.OutboxKindRecurringBulk, params)
+		return err
+	}
+	_, err := s.telegramBot.SendMessage(ctx, params)
+	return err
+}
@@ -0,0 +1,219 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/handler"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/translation"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// recurringPriceNoticeDays за сколько дней до вступления новой цены в силу клиент получает уведомление
+const recurringPriceNoticeDays = 7
+
+// maxRecurringPriceChangesInReport количество клиентов в отчёте /recurring_prices
+const maxRecurringPriceChangesInReport = 20
+
+type recurringPriceRepository interface {
+	FindCustomersWithRecurringEnabled(ctx context.Context) ([]database.Customer, error)
+	ScheduleRecurringPriceChange(ctx context.Context, id int64, amount int, effectiveAt time.Time) error
+}
+
+// RecurringPriceChange новая сумма автопродления клиента после изменения цены его тарифа
+type RecurringPriceChange struct {
+	Customer  database.Customer
+	ListPrice int
+	Amount    int
+}
+
+// IsIncrease возвращает true, если новая сумма больше зафиксированной: такое изменение требует согласия клиента
+func (c RecurringPriceChange) IsIncrease() bool {
+	return c.Amount > *c.Customer.RecurringAmount
+}
+
+// RecurringPriceService переводит автопродления на новые цены тарифов. Клиенты платят зафиксированную
+// при подключении цену, пока админ не запустит /recurring_prices apply: тогда новая цена вступает в силу
+// через recurringPriceNoticeDays дней, клиент получает уведомление, а повышение нужно подтвердить
+type RecurringPriceService struct {
+	repository  recurringPriceRepository
+	telegramBot *bot.Bot
+	tm          *translation.Manager
+}
+
+func NewRecurringPriceService(repository recurringPriceRepository, telegramBot *bot.Bot, tm *translation.Manager) *RecurringPriceService {
+	return &RecurringPriceService{repository: repository, telegramBot: telegramBot, tm: tm}
+}
+
+// FindChanges возвращает автопродления, у которых текущая цена тарифа отличается от цены при подключении
+func (s *RecurringPriceService) FindChanges(ctx context.Context) ([]RecurringPriceChange, error) {
+	customers, err := s.repository.FindCustomersWithRecurringEnabled(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return recurringPriceChanges(customers), nil
+}
+
+func recurringPriceChanges(customers []database.Customer) []RecurringPriceChange {
+	var changes []RecurringPriceChange
+	for _, c := range customers {
+		if c.RecurringMonths == nil && c.RecurringDays == nil {
+			continue
+		}
+		tariffName := ""
+		if c.RecurringTariffName != nil {
+			tariffName = *c.RecurringTariffName
+		}
+		listPrice := config.RecurringPeriodListPrice(tariffName, c.RecurringPeriod())
+		amount, changed := database.RepricedRecurringAmount(&c, listPrice)
+		if !changed {
+			continue
+		}
+		changes = append(changes, RecurringPriceChange{Customer: c, ListPrice: listPrice, Amount: amount})
+	}
+	return changes
+}
+
+// Apply планирует новые цены и уведомляет клиентов. Возвращает количество уведомлённых клиентов
+func (s *RecurringPriceService) Apply(ctx context.Context, changes []RecurringPriceChange, now time.Time) (int, error) {
+	effectiveAt := now.AddDate(0, 0, recurringPriceNoticeDays)
+	notified := 0
+	for _, change := range changes {
+		if err := s.repository.ScheduleRecurringPriceChange(ctx, change.Customer.ID, change.Amount, effectiveAt); err != nil {
+			return notified, err
+		}
+
+		if err := s.send(ctx, change, effectiveAt); err != nil {
+			slog.WarnContext(ctx, "Failed to send recurring price change notice", "customerId", utils.MaskHalfInt64(change.Customer.ID), "error", err)
+			continue
+		}
+		notified++
+
+		if err := utils.ThrottleSend(ctx); err != nil {
+			return notified, err
+		}
+	}
+	return notified, nil
+}
+
+func (s *RecurringPriceService) send(ctx context.Context, change RecurringPriceChange, effectiveAt time.Time) error {
+	lang := change.Customer.Language
+	if lang == "" {
+		lang = config.DefaultLanguage()
+	}
+
+	params := recurringPriceMessage(s.tm, lang, change, effectiveAt)
+	params.ChatID = change.Customer.TelegramID
+	_, err := s.telegramBot.SendMessage(ctx, params)
+	return err
+}
+
+// recurringPriceMessage уведомление об изменении суммы автопродления без получателя
+func recurringPriceMessage(tm *translation.Manager, lang string, change RecurringPriceChange, effectiveAt time.Time) *bot.SendMessageParams {
+	textKey := "recurring_price_decrease"
+	var keyboard [][]models.InlineKeyboardButton
+	if change.IsIncrease() {
+		textKey = "recurring_price_increase"
+		keyboard = [][]models.InlineKeyboardButton{
+			{{Text: tm.GetText(lang, "recurring_price_accept_button"), CallbackData: handler.CallbackRecurringPriceAccept}},
+			{{Text: tm.GetText(lang, "recurring_disable_button"), CallbackData: handler.CallbackRecurringDisable}},
+		}
+	}
+
+	params := &bot.SendMessageParams{
+		ParseMode: models.ParseModeHTML,
+		Text: tm.GetTextTemplate(lang, textKey, map[string]interface{}{
+			"old_price": locale.FormatMoney(lang, float64(*change.Customer.RecurringAmount)),
+			"new_price": locale.FormatMoney(lang, float64(change.Amount)),
+			"date":      locale.FormatDate(lang, effectiveAt),
+		}),
+	}
+	if len(keyboard) > 0 {
+		params.ReplyMarkup = models.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+	}
+	return params
+}
+
+// CommandHandler обрабатывает команду админа /recurring_prices [apply]: без аргумента показывает,
+// у кого изменится сумма автопродления, с apply — планирует новые цены и уведомляет клиентов
+func (s *RecurringPriceService) CommandHandler(ctx context.Context, b *bot.Bot, update *models.Update) {
+	args := strings.Fields(update.Message.Text)
+	apply := len(args) > 1 && args[1] == "apply"
+	if len(args) > 1 && !apply {
+		s.reply(ctx, b, update.Message.Chat.ID, "❌ Использование: <code>/recurring_prices</code> или <code>/recurring_prices apply</code>")
+		return
+	}
+
+	changes, err := s.FindChanges(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding recurring price changes", "error", err)
+		s.reply(ctx, b, update.Message.Chat.ID, "❌ Не удалось получить автопродления, подробности в логах")
+		return
+	}
+	now := clock.Now()
+	if !apply || len(changes) == 0 {
+		s.reply(ctx, b, update.Message.Chat.ID, FormatRecurringPriceChanges(changes, now.AddDate(0, 0, recurringPriceNoticeDays)))
+		return
+	}
+
+	notified, err := s.Apply(ctx, changes, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error scheduling recurring price changes", "error", err)
+		s.reply(ctx, b, update.Message.Chat.ID, fmt.Sprintf("❌ Ошибка после %d уведомлений, подробности в логах", notified))
+		return
+	}
+	s.reply(ctx, b, update.Message.Chat.ID, fmt.Sprintf("✅ Новые цены запланированы для %d автопродлений, уведомлено клиентов: %d", len(changes), notified))
+}
+
+func (s *RecurringPriceService) reply(ctx context.Context, b *bot.Bot, chatID int64, text string) {
+	_, err := b.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending recurring prices report", "error", err)
+	}
+}
+
+// FormatRecurringPriceChanges форматирует список изменений сумм автопродления в HTML сообщение
+func FormatRecurringPriceChanges(changes []RecurringPriceChange, effectiveAt time.Time) string {
+	if len(changes) == 0 {
+		return "✅ Все автопродления списываются по текущим ценам тарифов"
+	}
+
+	increases := 0
+	for _, change := range changes {
+		if change.IsIncrease() {
+			increases++
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("💳 <b>Изменение цен автопродления</b>\n\n")
+	sb.WriteString(fmt.Sprintf("Автопродлений с изменившейся ценой тарифа: <b>%d</b>\n", len(changes)))
+	sb.WriteString(fmt.Sprintf("• повышение (нужно согласие клиента): %d\n", increases))
+	sb.WriteString(fmt.Sprintf("• снижение: %d\n\n", len(changes)-increases))
+
+	for i, change := range changes {
+		if i == maxRecurringPriceChangesInReport {
+			sb.WriteString(fmt.Sprintf("… и ещё %d\n", len(changes)-i))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("• <code>%d</code>: %d → %d ₽\n", change.Customer.TelegramID, *change.Customer.RecurringAmount, change.Amount))
+	}
+
+	sb.WriteString(fmt.Sprintf("\nПосле <code>/recurring_prices apply</code> новые цены вступят в силу %s. "+
+		"Клиенты получат уведомление, без согласия на повышение автопродление отключится",
+		effectiveAt.Format("02.01.2006")))
+	return sb.String()
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 	"github.com/google/uuid"
+	"remnawave-tg-shop-bot/internal/clock"
 	"remnawave-tg-shop-bot/internal/config"
 	"remnawave-tg-shop-bot/internal/database"
 	"remnawave-tg-shop-bot/internal/handler"
@@ -49,6 +50,7 @@ type SubscriptionService struct {
 	telegramBot        *bot.Bot
 	tm                 *translation.Manager
 	remnawaveClient    remnawaveClient
+	clock              clock.Clock
 }
 
 func NewSubscriptionService(customerRepository customerRepository,
@@ -59,6 +61,18 @@ func NewSubscriptionService(customerRepository customerRepository,
 	return &SubscriptionService{customerRepository: customerRepository, purchaseRepository: purchaseRepository, paymentService: paymentService, telegramBot: telegramBot, tm: tm}
 }
 
+// SetClock подменяет источник времени (для тестов и машины времени на staging)
+func (s *SubscriptionService) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+func (s *SubscriptionService) now() time.Time {
+	if s.clock != nil {
+		return s.clock.Now()
+	}
+	return clock.Now()
+}
+
 // SetRemnawaveClient устанавливает клиент Remnawave для проверки firstConnectedAt
 func (s *SubscriptionService) SetRemnawaveClient(client remnawaveClient) {
 	s.remnawaveClient = client
@@ -91,7 +105,7 @@ func ShouldSendInactiveNotification(customer *database.Customer, firstConnectedA
 // ProcessTrialInactiveNotifications обрабатывает отправку уведомлений неактивным триальным пользователям
 // Получает список триальных пользователей, проверяет firstConnectedAt через Remnawave API, отправляет уведомления
 // **Validates: Requirements 2.1, 2.2**
-func (s *SubscriptionService) ProcessTrialInactiveNotifications() error {
+func (s *SubscriptionService) ProcessTrialInactiveNotifications(ctx context.Context) error {
 	if !config.IsTrialInactiveNotificationEnabled() {
 		return nil
 	}
@@ -101,13 +115,13 @@ func (s *SubscriptionService) ProcessTrialInactiveNotifications() error {
 		return nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
 	// Получаем триальных пользователей для проверки
 	customers, err := s.customerRepository.FindTrialUsersForInactiveNotification(ctx)
 	if err != nil {
-		slog.Error("Failed to find trial users for inactive notification", "error", err)
+		slog.ErrorContext(ctx, "Failed to find trial users for inactive notification", "error", err)
 		return err
 	}
 
@@ -115,16 +129,21 @@ func (s *SubscriptionService) ProcessTrialInactiveNotifications() error {
 		return nil
 	}
 
-	slog.Info("Found trial users for inactive notification check", "count", len(customers))
+	slog.InfoContext(ctx, "Found trial users for inactive notification check", "count", len(customers))
 
-	now := time.Now()
+	now := s.now()
 	notificationsSent := 0
 
 	for _, customer := range customers {
+		if err := ctx.Err(); err != nil {
+			slog.WarnContext(ctx, "Trial inactive notifications interrupted", "sent", notificationsSent, "error", err)
+			return err
+		}
+
 		// Получаем информацию о пользователе из Remnawave по telegram_id
 		userInfo, err := s.remnawaveClient.GetUserByTelegramID(ctx, customer.TelegramID)
 		if err != nil {
-			slog.Warn("Failed to get user info from Remnawave", "customer_id", customer.ID, "error", err)
+			slog.WarnContext(ctx, "Failed to get user info from Remnawave", "customer_id", customer.ID, "error", err)
 			continue
 		}
 
@@ -136,22 +155,22 @@ func (s *SubscriptionService) ProcessTrialInactiveNotifications() error {
 		// Отправляем уведомление
 		err = s.sendInactiveTrialNotification(ctx, customer)
 		if err != nil {
-			slog.Error("Failed to send inactive trial notification", "customer_id", customer.ID, "error", err)
+			slog.ErrorContext(ctx, "Failed to send inactive trial notification", "customer_id", customer.ID, "error", err)
 			continue
 		}
 
 		// Обновляем время отправки уведомления
 		err = s.customerRepository.UpdateTrialInactiveNotifiedAt(ctx, customer.ID, now)
 		if err != nil {
-			slog.Error("Failed to update trial inactive notified at", "customer_id", customer.ID, "error", err)
+			slog.ErrorContext(ctx, "Failed to update trial inactive notified at", "customer_id", customer.ID, "error", err)
 			continue
 		}
 
 		notificationsSent++
-		slog.Info("Sent inactive trial notification", "customer_id", customer.ID)
+		slog.InfoContext(ctx, "Sent inactive trial notification", "customer_id", customer.ID)
 	}
 
-	slog.Info("Processed trial inactive notifications", "sent", notificationsSent, "total_checked", len(customers))
+	slog.InfoContext(ctx, "Processed trial inactive notifications", "sent", notificationsSent, "total_checked", len(customers))
 	return nil
 }
 
@@ -160,20 +179,22 @@ func (s *SubscriptionService) ProcessTrialInactiveNotifications() error {
 // **Feature: trial-notifications, Property 5: Inactive Notification Message Contains MiniApp Button**
 // **Validates: Requirements 2.2**
 func (s *SubscriptionService) sendInactiveTrialNotification(ctx context.Context, customer database.Customer) error {
-	messageText := s.tm.GetText(customer.Language, "trial_inactive_notification")
+	params := inactiveTrialMessage(s.tm, customer.Language)
+	params.ChatID = customer.TelegramID
+	_, err := s.telegramBot.SendMessage(ctx, params)
 
-	keyboard := BuildInactiveNotificationKeyboard(customer.Language, s.tm)
+	return err
+}
 
-	_, err := s.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID:    customer.TelegramID,
-		Text:      messageText,
+// inactiveTrialMessage уведомление о неактивности триала без получателя
+func inactiveTrialMessage(tm *translation.Manager, language string) *bot.SendMessageParams {
+	return &bot.SendMessageParams{
+		Text:      tm.GetText(language, "trial_inactive_notification"),
 		ParseMode: models.ParseModeHTML,
 		ReplyMarkup: models.InlineKeyboardMarkup{
-			InlineKeyboard: keyboard,
+			InlineKeyboard: handler.WithNotificationFeedback(BuildInactiveNotificationKeyboard(language, tm), "trial_inactive_notification"),
 		},
-	})
-
-	return err
+	}
 }
 
 // BuildInactiveNotificationKeyboard создаёт клавиатуру для уведомления о неактивности
@@ -0,0 +1,84 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/handler"
+	"remnawave-tg-shop-bot/utils"
+)
+
+type surveyRepository interface {
+	Create(ctx context.Context, customerID int64, trigger string, sentAt, since time.Time) (int64, bool, error)
+	Delete(ctx context.Context, id int64) error
+}
+
+type surveyBot interface {
+	SendMessage(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error)
+}
+
+type surveyTranslator interface {
+	GetText(langCode, key string) string
+}
+
+// SurveyService предлагает клиенту оценить сервис от 1 до 5 звёзд после продления подписки
+// или обращения в поддержку. Один клиент получает опрос не чаще раза в SURVEY_INTERVAL_DAYS дней
+type SurveyService struct {
+	repository  surveyRepository
+	telegramBot surveyBot
+	tm          surveyTranslator
+}
+
+func NewSurveyService(repository surveyRepository, telegramBot surveyBot, tm surveyTranslator) *SurveyService {
+	return &SurveyService{repository: repository, telegramBot: telegramBot, tm: tm}
+}
+
+// Offer отправляет опрос с поводом trigger (database.SurveyTrigger*). Возвращает false, если опросы выключены,
+// клиент отказался от рассылок или уже получал опрос в течение SURVEY_INTERVAL_DAYS дней
+func (s *SurveyService) Offer(ctx context.Context, customer *database.Customer, trigger string) (bool, error) {
+	if !config.IsSurveyEnabled() || database.IsAnonymized(customer) || customer.MarketingOptedOutAt != nil {
+		return false, nil
+	}
+
+	now := clock.Now()
+	id, created, err := s.repository.Create(ctx, customer.ID, trigger, now, now.AddDate(0, 0, -config.SurveyIntervalDays()))
+	if err != nil || !created {
+		return false, err
+	}
+
+	_, err = s.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      customer.TelegramID,
+		Text:        s.tm.GetText(customer.Language, "survey_request_"+trigger),
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: surveyKeyboard(id)},
+	})
+	if err != nil {
+		// Неотправленный опрос не должен откладывать следующий на весь интервал
+		if delErr := s.repository.Delete(ctx, id); delErr != nil {
+			slog.WarnContext(ctx, "Failed to delete unsent survey", "customerId", utils.MaskHalfInt64(customer.ID), "error", delErr)
+		}
+		return false, fmt.Errorf("send survey: %w", err)
+	}
+	slog.InfoContext(ctx, "Survey sent", "customerId", utils.MaskHalfInt64(customer.ID), "trigger", trigger)
+	return true, nil
+}
+
+// surveyKeyboard кнопки оценок от 1 до 5 в один ряд
+func surveyKeyboard(surveyID int64) [][]models.InlineKeyboardButton {
+	row := make([]models.InlineKeyboardButton, 0, 5)
+	for rating := 1; rating <= 5; rating++ {
+		row = append(row, models.InlineKeyboardButton{
+			Text:         fmt.Sprintf("%d⭐", rating),
+			CallbackData: fmt.Sprintf("%s?id=%d&r=%d", handler.CallbackSurveyRate, surveyID, rating),
+		})
+	}
+	return [][]models.InlineKeyboardButton{row}
+}
@@ -0,0 +1,119 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+type surveyRepoMock struct {
+	exists  bool
+	created []string
+	deleted []int64
+}
+
+func (m *surveyRepoMock) Create(_ context.Context, _ int64, trigger string, _, _ time.Time) (int64, bool, error) {
+	if m.exists {
+		return 0, false, nil
+	}
+	m.created = append(m.created, trigger)
+	return 7, true, nil
+}
+
+func (m *surveyRepoMock) Delete(_ context.Context, id int64) error {
+	m.deleted = append(m.deleted, id)
+	return nil
+}
+
+type surveyBotMock struct {
+	sent []*bot.SendMessageParams
+	err  error
+}
+
+func (m *surveyBotMock) SendMessage(_ context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.sent = append(m.sent, params)
+	return &models.Message{}, nil
+}
+
+type keyTranslator struct{}
+
+func (keyTranslator) GetText(_, key string) string {
+	return key
+}
+
+func enableSurvey(t *testing.T) {
+	config.SetFeatureOverride(config.FeatureSurvey, true)
+	t.Cleanup(func() { config.ClearFeatureOverride(config.FeatureSurvey) })
+	clock.SetDefault(clock.NewFake(time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)))
+	t.Cleanup(func() { clock.SetDefault(nil) })
+}
+
+func TestSurveyOfferSendsRatingButtons(t *testing.T) {
+	enableSurvey(t)
+	repo := &surveyRepoMock{}
+	telegramBot := &surveyBotMock{}
+
+	sent, err := NewSurveyService(repo, telegramBot, keyTranslator{}).Offer(context.Background(), &database.Customer{ID: 1, TelegramID: 42}, database.SurveyTriggerRenewal)
+	if err != nil || !sent {
+		t.Fatalf("Expected survey to be sent, got %v, %v", sent, err)
+	}
+	if len(telegramBot.sent) != 1 || telegramBot.sent[0].Text != "survey_request_renewal" {
+		t.Fatalf("Expected renewal survey message, got %+v", telegramBot.sent)
+	}
+	row := telegramBot.sent[0].ReplyMarkup.(models.InlineKeyboardMarkup).InlineKeyboard[0]
+	if len(row) != 5 || row[0].CallbackData != "survey_rate?id=7&r=1" || row[4].CallbackData != "survey_rate?id=7&r=5" {
+		t.Errorf("Expected five rating buttons, got %+v", row)
+	}
+}
+
+func TestSurveyOfferSkipped(t *testing.T) {
+	optedOut := time.Now()
+	tests := []struct {
+		name     string
+		enabled  bool
+		exists   bool
+		customer *database.Customer
+	}{
+		{"disabled", false, false, &database.Customer{ID: 1, TelegramID: 42}},
+		{"surveyed within interval", true, true, &database.Customer{ID: 1, TelegramID: 42}},
+		{"marketing opt-out", true, false, &database.Customer{ID: 1, TelegramID: 42, MarketingOptedOutAt: &optedOut}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.enabled {
+				enableSurvey(t)
+			}
+			telegramBot := &surveyBotMock{}
+
+			sent, err := NewSurveyService(&surveyRepoMock{exists: tt.exists}, telegramBot, keyTranslator{}).Offer(context.Background(), tt.customer, database.SurveyTriggerSupport)
+			if err != nil || sent || len(telegramBot.sent) != 0 {
+				t.Errorf("Expected no survey, got sent=%v err=%v messages=%d", sent, err, len(telegramBot.sent))
+			}
+		})
+	}
+}
+
+// Неотправленный опрос удаляется: иначе клиент не получил бы опрос весь интервал
+func TestSurveyOfferDeletesUnsentSurvey(t *testing.T) {
+	enableSurvey(t)
+	repo := &surveyRepoMock{}
+
+	sent, err := NewSurveyService(repo, &surveyBotMock{err: errors.New("bot was blocked")}, keyTranslator{}).Offer(context.Background(), &database.Customer{ID: 1, TelegramID: 42}, database.SurveyTriggerRenewal)
+	if err == nil || sent {
+		t.Fatalf("Expected send error, got sent=%v err=%v", sent, err)
+	}
+	if len(repo.deleted) != 1 || repo.deleted[0] != 7 {
+		t.Errorf("Expected unsent survey to be deleted, got %v", repo.deleted)
+	}
+}
@@ -0,0 +1,196 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/handler"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/translation"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// TrialUpsellCampaign кампания winback, под которой сохраняется скидка шага discount. По ней покупка
+// засчитывается в статистике /admin → Winback
+const TrialUpsellCampaign = "trial_upsell"
+
+// trialUpsellWindow сколько часов после срока шага клиент ещё может его получить. Запас на случай,
+// когда задача не запускалась, без сообщений о настройке спустя несколько дней
+const trialUpsellWindow = 12 * time.Hour
+
+// trialUpsellBatchSize ограничивает количество сообщений одного шага за запуск
+const trialUpsellBatchSize = 500
+
+type trialUpsellRepository interface {
+	FindDue(ctx context.Context, step, column string, from, to, now time.Time, limit int) ([]database.Customer, error)
+	Claim(ctx context.Context, step string, customerID int64, trialActivatedAt, at time.Time) (bool, error)
+}
+
+type trialUpsellOfferRepository interface {
+	SaveOffer(ctx context.Context, customerID int64, churnedAt time.Time, offer database.WinbackOffer) error
+}
+
+// TrialUpsellService отправляет клиентам на триале цепочку сообщений: советы по настройке, отзывы со скидкой
+// и напоминание в последний день. Шаги задаются TRIAL_UPSELL_*, после оплаты цепочка останавливается
+type TrialUpsellService struct {
+	repository  trialUpsellRepository
+	offers      trialUpsellOfferRepository
+	telegramBot *bot.Bot
+	tm          *translation.Manager
+	outbox      *OutboxService
+}
+
+func NewTrialUpsellService(repository trialUpsellRepository, offers trialUpsellOfferRepository, telegramBot *bot.Bot, tm *translation.Manager) *TrialUpsellService {
+	return &TrialUpsellService{repository: repository, offers: offers, telegramBot: telegramBot, tm: tm}
+}
+
+// SetOutbox включает доставку сообщений в часы NOTIFY_LOCAL_HOURS по местному времени клиента (может быть nil)
+func (s *TrialUpsellService) SetOutbox(outbox *OutboxService) {
+	s.outbox = outbox
+}
+
+// trialUpsellWindowFor возвращает колонку клиента, от которой отсчитывается шаг, и промежуток (from, to],
+// в который она должна попасть, чтобы шаг был отправлен сейчас
+func trialUpsellWindowFor(step config.TrialUpsellStep, now time.Time) (column string, from, to time.Time) {
+	offset := time.Duration(step.Hours) * time.Hour
+	if step.BeforeExpiry {
+		return "expire_at", now, now.Add(offset)
+	}
+	to = now.Add(-offset)
+	return "trial_activated_at", to.Add(-trialUpsellWindow), to
+}
+
+// trialUpsellOffer рассчитывает скидку на месяц первого тарифа (или общей цены без тарифов).
+// Возвращает false, если цена не задана
+func trialUpsellOffer(tariffs []config.Tariff, basePrice func(months int) int, defaultDevices, discountPercent int) (PaidWinbackOffer, bool) {
+	var tariff *config.Tariff
+	if len(tariffs) > 0 {
+		tariff = &tariffs[0]
+	}
+	return paidWinbackOfferFor(database.ChurnedCustomer{LastMonths: 1}, tariff, basePrice, defaultDevices, discountPercent)
+}
+
+// Run отправляет клиентам на триале шаги цепочки, срок которых наступил
+func (s *TrialUpsellService) Run(ctx context.Context) error {
+	if !config.IsTrialUpsellEnabled() {
+		return nil
+	}
+
+	now := clock.Now()
+	for _, step := range config.TrialUpsellSteps() {
+		sent, err := s.runStep(ctx, step, now)
+		if err != nil {
+			return fmt.Errorf("trial upsell step %s: %w", step.Name, err)
+		}
+		if sent > 0 {
+			slog.InfoContext(ctx, "Trial upsell messages sent", "step", step.Name, "sent", sent)
+		}
+	}
+	return nil
+}
+
+func (s *TrialUpsellService) runStep(ctx context.Context, step config.TrialUpsellStep, now time.Time) (int, error) {
+	column, from, to := trialUpsellWindowFor(step, now)
+	customers, err := s.repository.FindDue(ctx, step.Name, column, from, to, now, trialUpsellBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, c := range customers {
+		claimed, err := s.repository.Claim(ctx, step.Name, c.ID, *c.TrialActivatedAt, now)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := s.send(ctx, c, step.Name, now); err != nil {
+			slog.WarnContext(ctx, "Failed to send trial upsell message", "step", step.Name, "customerId", utils.MaskHalfInt64(c.ID), "error", err)
+			continue
+		}
+		sent++
+
+		if err := utils.ThrottleSend(ctx); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+func (s *TrialUpsellService) send(ctx context.Context, customer database.Customer, step string, now time.Time) error {
+	lang := customer.Language
+	if lang == "" {
+		lang = config.DefaultLanguage()
+	}
+
+	textKey, buttonKey, callback := "trial_upsell_"+step, "buy_button", handler.CallbackBuy
+	data := map[string]interface{}{
+		"time_left": locale.FormatDuration(lang, customer.ExpireAt.Sub(now)),
+	}
+
+	var offer *PaidWinbackOffer
+	switch step {
+	case config.TrialUpsellStepSetup:
+		buttonKey, callback = "connect_button", handler.CallbackConnect
+	case config.TrialUpsellStepDiscount:
+		// Скидка не подменяет действующее предложение и не предлагается отказавшимся от предложений
+		if customer.WinbackOptedOutAt == nil && !database.HasActiveWinbackOffer(&customer) {
+			if o, ok := trialUpsellOffer(config.GetTariffs(), config.Price, config.GetWinbackDevices(), config.TrialUpsellDiscountPercent()); ok {
+				offer = &o
+			}
+		}
+		if offer == nil {
+			textKey = "trial_upsell_social_proof"
+			break
+		}
+		buttonKey, callback = "winback_activate_button", handler.CallbackWinbackActivate
+		data["percent"] = config.TrialUpsellDiscountPercent()
+		data["months"] = offer.Months
+		data["devices"] = offer.Devices
+		data["price"] = locale.FormatMoney(lang, float64(offer.Price))
+		data["base_price"] = locale.FormatMoney(lang, float64(offer.BasePrice))
+		data["valid_for"] = locale.FormatDuration(lang, time.Duration(config.TrialUpsellDiscountValidHours())*time.Hour)
+	}
+
+	params := &bot.SendMessageParams{
+		ChatID:    customer.TelegramID,
+		Text:      s.tm.GetTextTemplate(lang, textKey, data),
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: handler.WithNotificationFeedback([][]models.InlineKeyboardButton{
+			{{Text: s.tm.GetText(lang, buttonKey), CallbackData: callback}},
+		}, textKey)},
+	}
+	deliveredAt := now
+	if s.outbox != nil {
+		at, err := s.outbox.SendAtLocalTime(ctx, &customer, database.OutboxKindTrialUpsell, params)
+		if err != nil {
+			return err
+		}
+		deliveredAt = at
+	} else if _, err := s.telegramBot.SendMessage(ctx, params); err != nil {
+		return err
+	}
+
+	if offer == nil {
+		return nil
+	}
+	// Срок скидки считается с момента, когда клиент получит сообщение
+	return s.offers.SaveOffer(ctx, customer.ID, *customer.ExpireAt, database.WinbackOffer{
+		Campaign:  TrialUpsellCampaign,
+		Tariff:    offer.Tariff,
+		Price:     offer.Price,
+		Devices:   offer.Devices,
+		Months:    offer.Months,
+		SentAt:    now,
+		ExpiresAt: deliveredAt.Add(time.Duration(config.TrialUpsellDiscountValidHours()) * time.Hour),
+	})
+}
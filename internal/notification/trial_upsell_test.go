@@ -0,0 +1,36 @@
+package notification
+
+import (
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+func TestTrialUpsellWindowFor(t *testing.T) {
+	now := time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC)
+
+	column, from, to := trialUpsellWindowFor(config.TrialUpsellStep{Name: config.TrialUpsellStepDiscount, Hours: 48}, now)
+	if column != "trial_activated_at" || !to.Equal(now.Add(-48*time.Hour)) || !from.Equal(to.Add(-trialUpsellWindow)) {
+		t.Errorf("Unexpected activation window %s (%s, %s]", column, from, to)
+	}
+
+	column, from, to = trialUpsellWindowFor(config.TrialUpsellStep{Name: config.TrialUpsellStepLastDay, Hours: 24, BeforeExpiry: true}, now)
+	if column != "expire_at" || !from.Equal(now) || !to.Equal(now.Add(24*time.Hour)) {
+		t.Errorf("Unexpected expiry window %s (%s, %s]", column, from, to)
+	}
+}
+
+func TestTrialUpsellOffer(t *testing.T) {
+	price := func(months int) int { return 200 * months }
+
+	offer, ok := trialUpsellOffer([]config.Tariff{{Name: "BASIC", Devices: 3, Price1: 150}}, price, 1, 20)
+	if !ok || offer.Tariff == nil || *offer.Tariff != "BASIC" || offer.Months != 1 || offer.Devices != 3 || offer.Price != 120 {
+		t.Errorf("Unexpected tariff offer: %+v", offer)
+	}
+
+	offer, ok = trialUpsellOffer(nil, price, 2, 50)
+	if !ok || offer.Tariff != nil || offer.Devices != 2 || offer.Price != 100 {
+		t.Errorf("Unexpected offer without tariffs: %+v", offer)
+	}
+}
@@ -0,0 +1,217 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/handler"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/translation"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// paidWinbackWindow сколько дней после срока кампании клиент ещё может получить её предложение.
+// Запас на случай, когда задача не запускалась (бот был остановлен), без рассылки давно ушедшим клиентам
+const paidWinbackWindow = 3 * 24 * time.Hour
+
+// paidWinbackBatchSize ограничивает количество предложений одной кампании за запуск
+const paidWinbackBatchSize = 500
+
+type paidWinbackRepository interface {
+	FindChurned(ctx context.Context, campaign string, from, to time.Time, limit int) ([]database.ChurnedCustomer, error)
+	SaveOffer(ctx context.Context, customerID int64, churnedAt time.Time, offer database.WinbackOffer) error
+}
+
+// PaidWinbackService отправляет ушедшим платящим клиентам предложение продлить прежний тариф со скидкой.
+// Каждое значение WINBACK_PAID_DAYS — отдельная кампания: предложение приходит через столько дней после
+// окончания подписки, если клиент не продлил её и не отказался от предложений
+type PaidWinbackService struct {
+	repository  paidWinbackRepository
+	telegramBot *bot.Bot
+	tm          *translation.Manager
+	outbox      *OutboxService
+}
+
+func NewPaidWinbackService(repository paidWinbackRepository, telegramBot *bot.Bot, tm *translation.Manager) *PaidWinbackService {
+	return &PaidWinbackService{repository: repository, telegramBot: telegramBot, tm: tm}
+}
+
+// SetOutbox включает доставку предложений в часы NOTIFY_LOCAL_HOURS по местному времени клиента (может быть nil)
+func (s *PaidWinbackService) SetOutbox(outbox *OutboxService) {
+	s.outbox = outbox
+}
+
+// PaidWinbackCampaign возвращает имя кампании для предложения через days дней после ухода
+func PaidWinbackCampaign(days int) string {
+	return fmt.Sprintf("paid_%dd", days)
+}
+
+// PaidWinbackOffer параметры предложения, рассчитанные по последней покупке
+type PaidWinbackOffer struct {
+	Tariff    *string
+	Months    int
+	Devices   int
+	BasePrice int
+	Price     int
+}
+
+// buildPaidWinbackOffer рассчитывает предложение по последней оплаченной покупке клиента: тот же тариф,
+// период и лимит устройств по текущей цене тарифа со скидкой discountPercent.
+// Возвращает false, если предложить нечего (цена периода не задана)
+func buildPaidWinbackOffer(c database.ChurnedCustomer, discountPercent int) (PaidWinbackOffer, bool) {
+	var tariff *config.Tariff
+	if c.LastTariff != nil && *c.LastTariff != "" {
+		tariff = config.GetTariffByName(*c.LastTariff)
+	}
+	return paidWinbackOfferFor(c, tariff, config.Price, config.GetWinbackDevices(), discountPercent)
+}
+
+// paidWinbackOfferFor рассчитывает предложение для найденного тарифа. tariff nil — тариф удалён или покупка
+// была без тарифа: период предлагается по общей цене basePrice
+func paidWinbackOfferFor(c database.ChurnedCustomer, tariff *config.Tariff, basePrice func(months int) int,
+	defaultDevices, discountPercent int) (PaidWinbackOffer, bool) {
+	offer := PaidWinbackOffer{Months: c.LastMonths}
+	if !config.IsSupportedMonth(offer.Months) {
+		offer.Months = 1
+	}
+
+	if tariff != nil {
+		offer.Tariff = &tariff.Name
+		offer.BasePrice = tariff.Price(offer.Months)
+	} else {
+		offer.BasePrice = basePrice(offer.Months)
+	}
+
+	switch {
+	case c.LastDevices != nil && *c.LastDevices > 0:
+		offer.Devices = *c.LastDevices
+	case tariff != nil:
+		offer.Devices = tariff.Devices
+	default:
+		// Лимит неизвестен. При повторной покупке лимит в панели не занижается, если он был больше
+		offer.Devices = defaultDevices
+	}
+
+	offer.Price = offer.BasePrice * (100 - discountPercent) / 100
+	if offer.Price <= 0 {
+		return PaidWinbackOffer{}, false
+	}
+	return offer, true
+}
+
+// Run отправляет предложения по всем кампаниям
+func (s *PaidWinbackService) Run(ctx context.Context) error {
+	if !config.IsWinbackPaidEnabled() {
+		return nil
+	}
+
+	now := clock.Now()
+	for _, days := range config.WinbackPaidDays() {
+		sent, err := s.runCampaign(ctx, days, now)
+		if err != nil {
+			return fmt.Errorf("winback campaign %s: %w", PaidWinbackCampaign(days), err)
+		}
+		if sent > 0 {
+			slog.InfoContext(ctx, "Paid winback offers sent", "campaign", PaidWinbackCampaign(days), "sent", sent)
+		}
+	}
+	return nil
+}
+
+func (s *PaidWinbackService) runCampaign(ctx context.Context, days int, now time.Time) (int, error) {
+	campaign := PaidWinbackCampaign(days)
+	to := now.AddDate(0, 0, -days)
+	customers, err := s.repository.FindChurned(ctx, campaign, to.Add(-paidWinbackWindow), to, paidWinbackBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, c := range customers {
+		// Предыдущее предложение ещё действует — не подменяем его, клиент получит это при следующем запуске
+		if database.HasActiveWinbackOffer(&c.Customer) {
+			continue
+		}
+		offer, ok := buildPaidWinbackOffer(c, config.WinbackPaidDiscountPercent())
+		if !ok {
+			slog.WarnContext(ctx, "No price for paid winback offer", "customerId", utils.MaskHalfInt64(c.ID), "months", c.LastMonths)
+			continue
+		}
+
+		deliveredAt, err := s.send(ctx, c.Customer, offer, now)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to send paid winback offer", "customerId", utils.MaskHalfInt64(c.ID), "error", err)
+			continue
+		}
+
+		// Срок предложения считается с момента, когда клиент его получит
+		validFor := time.Duration(config.WinbackPaidValidHours()) * time.Hour
+		err = s.repository.SaveOffer(ctx, c.ID, *c.ExpireAt, database.WinbackOffer{
+			Campaign:  campaign,
+			Tariff:    offer.Tariff,
+			Price:     offer.Price,
+			Devices:   offer.Devices,
+			Months:    offer.Months,
+			SentAt:    now,
+			ExpiresAt: deliveredAt.Add(validFor),
+		})
+		if err != nil {
+			return sent, err
+		}
+		sent++
+
+		if err := utils.ThrottleSend(ctx); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// send отправляет предложение и возвращает время, когда клиент его получит
+func (s *PaidWinbackService) send(ctx context.Context, customer database.Customer, offer PaidWinbackOffer, now time.Time) (time.Time, error) {
+	lang := customer.Language
+	if lang == "" {
+		lang = config.DefaultLanguage()
+	}
+
+	params := paidWinbackMessage(s.tm, lang, offer)
+	params.ChatID = customer.TelegramID
+	if s.outbox != nil {
+		return s.outbox.SendAtLocalTime(ctx, &customer, database.OutboxKindWinbackPaid, params)
+	}
+	_, err := s.telegramBot.SendMessage(ctx, params)
+	return now, err
+}
+
+// paidWinbackMessage предложение offer без получателя
+func paidWinbackMessage(tm *translation.Manager, lang string, offer PaidWinbackOffer) *bot.SendMessageParams {
+	plan := tm.GetText(lang, "winback_paid_default_plan")
+	if offer.Tariff != nil {
+		plan = *offer.Tariff
+	}
+	text := fmt.Sprintf(tm.GetText(lang, "winback_paid_offer"),
+		config.WinbackPaidDiscountPercent(),
+		plan,
+		offer.Months,
+		offer.Devices,
+		locale.FormatMoney(lang, float64(offer.Price)),
+		locale.FormatMoney(lang, float64(offer.BasePrice)),
+		locale.FormatDuration(lang, time.Duration(config.WinbackPaidValidHours())*time.Hour),
+	)
+
+	return &bot.SendMessageParams{
+		Text:      text,
+		ParseMode: models.ParseModeHTML,
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: handler.WithNotificationFeedback([][]models.InlineKeyboardButton{
+			{{Text: tm.GetText(lang, "winback_activate_button"), CallbackData: handler.CallbackWinbackActivate}},
+			{{Text: tm.GetText(lang, "winback_opt_out_button"), CallbackData: handler.CallbackWinbackOptOut}},
+		}, "winback_paid_offer")},
+	}
+}
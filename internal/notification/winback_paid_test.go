@@ -0,0 +1,57 @@
+package notification
+
+import (
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+func TestPaidWinbackOfferFor(t *testing.T) {
+	pro := &config.Tariff{Name: "PRO", Devices: 5, Price1: 300, Price3: 800}
+	legacyPrice := func(months int) int { return 200 * months }
+	devices := func(n int) *int { return &n }
+
+	t.Run("keeps previous tariff, period and devices", func(t *testing.T) {
+		c := database.ChurnedCustomer{LastMonths: 3, LastDevices: devices(3)}
+		offer, ok := paidWinbackOfferFor(c, pro, legacyPrice, 1, 25)
+		if !ok {
+			t.Fatal("Expected offer")
+		}
+		if offer.Tariff == nil || *offer.Tariff != "PRO" || offer.Months != 3 || offer.Devices != 3 {
+			t.Errorf("Unexpected offer params: %+v", offer)
+		}
+		if offer.BasePrice != 800 || offer.Price != 600 {
+			t.Errorf("Expected 600 of 800, got %d of %d", offer.Price, offer.BasePrice)
+		}
+	})
+
+	t.Run("tariff devices when purchase has no limit", func(t *testing.T) {
+		offer, _ := paidWinbackOfferFor(database.ChurnedCustomer{LastMonths: 1}, pro, legacyPrice, 1, 10)
+		if offer.Devices != 5 || offer.Price != 270 {
+			t.Errorf("Expected 5 devices for 270, got %+v", offer)
+		}
+	})
+
+	t.Run("legacy purchase without tariff", func(t *testing.T) {
+		offer, ok := paidWinbackOfferFor(database.ChurnedCustomer{LastMonths: 2}, nil, legacyPrice, 2, 50)
+		if !ok {
+			t.Fatal("Expected offer")
+		}
+		if offer.Tariff != nil || offer.Months != 1 || offer.Devices != 2 || offer.Price != 100 {
+			t.Errorf("Expected 1 month, 2 devices for 100 without tariff, got %+v", offer)
+		}
+	})
+
+	t.Run("no price for period", func(t *testing.T) {
+		if _, ok := paidWinbackOfferFor(database.ChurnedCustomer{LastMonths: 6}, pro, legacyPrice, 1, 20); ok {
+			t.Error("Expected no offer when the tariff has no price for the period")
+		}
+	})
+}
+
+func TestPaidWinbackCampaign(t *testing.T) {
+	if got := PaidWinbackCampaign(7); got != "paid_7d" {
+		t.Errorf("Expected paid_7d, got %s", got)
+	}
+}
@@ -0,0 +1,117 @@
+package payment
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/yookasa"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// cancellationReasonKeys ключи переводов для причин отмены платежа ЮKassa (cancellation_details.reason).
+// Причины, которых нет в списке, показываются общим текстом payment_cancel_reason_general
+var cancellationReasonKeys = map[string]string{
+	"insufficient_funds":            "payment_cancel_reason_insufficient_funds",
+	"expired_on_confirmation":       "payment_cancel_reason_expired",
+	"3d_secure_failed":              "payment_cancel_reason_3ds",
+	"card_expired":                  "payment_cancel_reason_card",
+	"invalid_card_number":           "payment_cancel_reason_card",
+	"invalid_csc":                   "payment_cancel_reason_card",
+	"fraud_suspected":               "payment_cancel_reason_fraud",
+	"payment_method_limit_exceeded": "payment_cancel_reason_limit",
+	"call_issuer":                   "payment_cancel_reason_issuer",
+	"issuer_unavailable":            "payment_cancel_reason_issuer",
+	"payment_method_restricted":     "payment_cancel_reason_issuer",
+	"country_forbidden":             "payment_cancel_reason_issuer",
+	"unsupported_mobile_operator":   "payment_cancel_reason_issuer",
+	"internal_timeout":              "payment_cancel_reason_technical",
+	"expired_on_capture":            "payment_cancel_reason_technical",
+	"identification_required":       "payment_cancel_reason_identification",
+	"deal_expired":                  "payment_cancel_reason_expired",
+}
+
+// cancellationReasonKey возвращает ключ перевода с объяснением причины отмены платежа
+func cancellationReasonKey(details *yookasa.CancellationDetails) string {
+	if details != nil {
+		if key, ok := cancellationReasonKeys[details.Reason]; ok {
+			return key
+		}
+	}
+	return "payment_cancel_reason_general"
+}
+
+// ProcessYookassaCancellation отмечает покупку отменённой и объясняет пользователю, почему оплата не прошла.
+// Отмену может заметить и проверка инвойсов, и уведомление ЮKassa: сообщение отправляется один раз,
+// только если покупка ещё ожидала оплаты
+func (s PaymentService) ProcessYookassaCancellation(ctx context.Context, invoice *yookasa.Payment) error {
+	purchase, err := s.purchaseRepository.FindByYookasaID(ctx, invoice.ID)
+	if err != nil {
+		return err
+	}
+	if purchase == nil {
+		return ErrPurchaseNotFound
+	}
+
+	cancelled, err := s.purchaseRepository.CancelIfPending(ctx, purchase.ID)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return nil
+	}
+
+	reason := ""
+	if invoice.CancellationDetails != nil {
+		reason = invoice.CancellationDetails.Reason
+	}
+	slog.InfoContext(ctx, "Yookassa payment cancelled", "purchase_id", utils.MaskHalfInt64(purchase.ID), "reason", reason)
+	s.showPaymentCancelled(ctx, purchase, cancellationReasonKey(invoice.CancellationDetails))
+	return nil
+}
+
+// showPaymentCancelled заменяет сообщение со ссылкой на оплату на причину отмены с кнопкой повторной оплаты
+// того же тарифа и периода. Если сообщения нет или его нельзя изменить — отправляет новое
+func (s PaymentService) showPaymentCancelled(ctx context.Context, purchase *database.Purchase, reasonKey string) {
+	customer, err := s.customerRepository.FindById(ctx, purchase.CustomerID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for cancelled payment", "error", err, "purchase_id", utils.MaskHalfInt64(purchase.ID))
+		return
+	}
+
+	text := s.translation.GetTextTemplate(customer.Language, "payment_cancelled", map[string]interface{}{
+		"reason": utils.RawHTML(s.translation.GetText(customer.Language, reasonKey)),
+	})
+	keyboard := models.InlineKeyboardMarkup{
+		InlineKeyboard: [][]models.InlineKeyboardButton{
+			{{Text: s.translation.GetText(customer.Language, "payment_retry_button"), CallbackData: recreateInvoiceCallback(purchase)}},
+		},
+	}
+
+	if messageID, ok := s.purchaseMessageID(purchase); ok {
+		_, err := s.telegramBot.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:      customer.TelegramID,
+			MessageID:   messageID,
+			ParseMode:   models.ParseModeHTML,
+			Text:        text,
+			ReplyMarkup: keyboard,
+		})
+		if err == nil {
+			return
+		}
+		slog.WarnContext(ctx, "Error editing payment message, sending new one", "error", err, "purchase_id", utils.MaskHalfInt64(purchase.ID))
+	}
+
+	_, err = s.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      customer.TelegramID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        text,
+		ReplyMarkup: keyboard,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending cancelled payment message", "error", err, "purchase_id", utils.MaskHalfInt64(purchase.ID), "telegram_id", utils.MaskHalfInt64(customer.TelegramID))
+	}
+}
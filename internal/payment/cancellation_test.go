@@ -0,0 +1,29 @@
+package payment
+
+import (
+	"testing"
+
+	"remnawave-tg-shop-bot/internal/yookasa"
+)
+
+func TestCancellationReasonKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		details *yookasa.CancellationDetails
+		want    string
+	}{
+		{"no details", nil, "payment_cancel_reason_general"},
+		{"insufficient funds", &yookasa.CancellationDetails{Reason: "insufficient_funds"}, "payment_cancel_reason_insufficient_funds"},
+		{"confirmation timeout", &yookasa.CancellationDetails{Reason: "expired_on_confirmation"}, "payment_cancel_reason_expired"},
+		{"card problem", &yookasa.CancellationDetails{Reason: "invalid_csc"}, "payment_cancel_reason_card"},
+		{"unknown reason", &yookasa.CancellationDetails{Reason: "something_new"}, "payment_cancel_reason_general"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cancellationReasonKey(tt.details); got != tt.want {
+				t.Errorf("cancellationReasonKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package payment
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/receipt"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// checkoutReminderMaxAge счета старше суток считаются забытыми окончательно: о них не напоминаем,
+// даже если задача долго не запускалась
+const checkoutReminderMaxAge = 24 * time.Hour
+
+// checkoutReminderBatchSize ограничивает количество напоминаний за запуск
+const checkoutReminderBatchSize = 100
+
+// reminderInvoiceTypes типы счетов, о которых напоминаем. Tribute оплачивается подпиской в канале, а не по ссылке
+var reminderInvoiceTypes = []database.InvoiceType{database.InvoiceTypeCrypto, database.InvoiceTypeYookasa, database.InvoiceTypeTelegram}
+
+// RemindAbandonedCheckouts отправляет одно напоминание по каждому счёту, который не оплачен дольше delay.
+// В напоминании — исходная ссылка на оплату, если она сохранена, и кнопка выбора оплаты заново
+func (s PaymentService) RemindAbandonedCheckouts(ctx context.Context, delay time.Duration) (int, error) {
+	if !config.IsCheckoutReminderEnabled() || s.checkoutReminderRepository == nil {
+		return 0, nil
+	}
+
+	now := time.Now()
+	purchases, err := s.checkoutReminderRepository.FindAbandoned(ctx, reminderInvoiceTypes, now.Add(-checkoutReminderMaxAge), now.Add(-delay), checkoutReminderBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range purchases {
+		purchase := &purchases[i]
+		customer, err := s.customerRepository.FindById(ctx, purchase.CustomerID)
+		if err != nil || customer == nil {
+			slog.ErrorContext(ctx, "Error finding customer for checkout reminder", "error", err, "purchase_id", utils.MaskHalfInt64(purchase.ID))
+			continue
+		}
+
+		claimed, err := s.checkoutReminderRepository.Claim(ctx, purchase.ID, customer.ID, now)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := s.sendCheckoutReminder(ctx, customer, purchase); err != nil {
+			slog.WarnContext(ctx, "Failed to send checkout reminder", "error", err, "purchase_id", utils.MaskHalfInt64(purchase.ID))
+			continue
+		}
+		sent++
+
+		if err := utils.ThrottleSend(ctx); err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+func (s PaymentService) sendCheckoutReminder(ctx context.Context, customer *database.Customer, purchase *database.Purchase) error {
+	var keyboard [][]models.InlineKeyboardButton
+	if url := reminderPaymentURL(purchase); url != "" {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: s.translation.GetText(customer.Language, "checkout_reminder_pay_button"), URL: url},
+		})
+	}
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: s.translation.GetText(customer.Language, "invoice_recreate_button"), CallbackData: recreateInvoiceCallback(purchase)},
+	})
+
+	textKey := "checkout_reminder"
+	data := map[string]interface{}{
+		"months": purchase.Month,
+		"amount": receipt.FormatAmount(customer.Language, purchase.Amount, purchase.Currency),
+	}
+	// Посуточный план тарифа: срок в днях вместо месяцев
+	if period := purchase.Period(); period.IsDays() {
+		textKey = "checkout_reminder_days"
+		data["days"] = locale.FormatDays(customer.Language, period.Days)
+	}
+	_, err := s.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      customer.TelegramID,
+		ParseMode:   models.ParseModeHTML,
+		Text:        s.translation.GetTextTemplate(customer.Language, textKey, data),
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: keyboard},
+	})
+	return err
+}
+
+// reminderPaymentURL возвращает сохранённую ссылку на оплату счёта. Ссылка на счёт в звёздах не сохраняется
+func reminderPaymentURL(purchase *database.Purchase) string {
+	switch {
+	case purchase.InvoiceType == database.InvoiceTypeCrypto && purchase.CryptoInvoiceLink != nil:
+		return *purchase.CryptoInvoiceLink
+	case purchase.InvoiceType == database.InvoiceTypeYookasa && purchase.YookasaURL != nil:
+		return *purchase.YookasaURL
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,289 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/alert"
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/logging"
+	"remnawave-tg-shop-bot/internal/remnawave"
+	"remnawave-tg-shop-bot/internal/yookasa"
+)
+
+// failedFulfillmentStore журнал оплаченных покупок, выдача которых завершилась ошибкой
+type failedFulfillmentStore interface {
+	Record(ctx context.Context, purchaseID int64, source string, payload []byte, lastError string, nextAttemptAt *time.Time, at time.Time) (int64, bool, error)
+	FindByID(ctx context.Context, id int64) (*database.FailedFulfillment, error)
+	FindDue(ctx context.Context, now time.Time, limit int) ([]database.FailedFulfillment, error)
+	ScheduleRetry(ctx context.Context, id int64, attempts int, lastError string, nextAttemptAt, at time.Time) error
+	MarkFailed(ctx context.Context, id int64, attempts int, lastError string, at time.Time) error
+	MarkResolved(ctx context.Context, id int64, attempts int, at time.Time) error
+	ResolveByPurchase(ctx context.Context, purchaseID int64, at time.Time) (bool, error)
+}
+
+// ErrFailedFulfillmentNotFound запись об ошибке выдачи не найдена
+var ErrFailedFulfillmentNotFound = errors.New("failed fulfillment not found")
+
+// SetFailedFulfillments подключает журнал ошибок выдачи: без него ошибки выдачи попадают только в логи
+func (s *PaymentService) SetFailedFulfillments(store failedFulfillmentStore) {
+	s.failedFulfillments = store
+}
+
+// FulfillPurchase выдаёт оплаченную покупку, как ProcessPurchaseById, и записывает ошибку выдачи в журнал
+// вместе с данными провайдера об оплате. Временные ошибки повторяются задачей fulfillment_retry,
+// остальные ждут повтора из /admin → Ошибки
+func (s PaymentService) FulfillPurchase(ctx context.Context, purchaseID int64, source database.InvoiceType, payload []byte) error {
+	procErr := s.ProcessPurchaseById(ctx, purchaseID)
+	if s.failedFulfillments == nil {
+		return procErr
+	}
+
+	saveCtx := context.WithoutCancel(ctx)
+	now := clock.Now()
+	if procErr == nil {
+		// Покупка могла быть выдана повторным опросом провайдера раньше задачи повтора
+		if resolved, err := s.failedFulfillments.ResolveByPurchase(saveCtx, purchaseID, now); err != nil {
+			slog.ErrorContext(ctx, "Failed to resolve failed fulfillment", "purchaseId", purchaseID, "error", err)
+		} else if resolved {
+			slog.InfoContext(ctx, "Failed fulfillment resolved", "purchaseId", purchaseID)
+		}
+		return nil
+	}
+
+	recordFailedFulfillment(saveCtx, s.failedFulfillments, purchaseID, source, payload, procErr, now)
+	return procErr
+}
+
+// RecurringExtension оплаченное автопродление, которое не удалось выдать сразу после списания
+type RecurringExtension struct {
+	// Amount полная сумма продления, включая часть, оплаченную с баланса
+	Amount      float64
+	Period      config.Period
+	TariffName  *string
+	DeviceLimit *int
+	// Payment автоплатёж ЮKassa. nil, если продление целиком оплачено с баланса
+	Payment *yookasa.Payment
+}
+
+// FulfillRecurringExtension оформляет оплаченное автопродление как покупку и выдаёт её через FulfillPurchase:
+// деньги уже списаны, поэтому ошибка выдачи не отменяет продление, а попадает в журнал вместе с данными
+// автоплатежа ЮKassa для автоматического повтора, алерта админу и повтора из /admin → Ошибки
+func (s PaymentService) FulfillRecurringExtension(ctx context.Context, customer *database.Customer, ext RecurringExtension) (int64, error) {
+	purchaseID, err := s.purchaseRepository.Create(ctx, recurringExtensionPurchase(customer, ext))
+	if err != nil {
+		return 0, fmt.Errorf("create recurring purchase: %w", err)
+	}
+	slog.InfoContext(ctx, "Recurring extension scheduled as purchase", "purchaseId", purchaseID, "customerId", customer.ID)
+
+	var payload []byte
+	if ext.Payment != nil {
+		payload = providerPayload(ext.Payment)
+	}
+	return purchaseID, s.FulfillPurchase(ctx, purchaseID, database.InvoiceTypeYookasa, payload)
+}
+
+// recurringExtensionPurchase покупка для оплаченного автопродления. Создаётся в статусе new:
+// проверка и истечение счетов ЮKassa её не трогают
+func recurringExtensionPurchase(customer *database.Customer, ext RecurringExtension) *database.Purchase {
+	purchase := &database.Purchase{
+		InvoiceType:            database.InvoiceTypeYookasa,
+		Status:                 database.PurchaseStatusNew,
+		Amount:                 ext.Amount,
+		Currency:               "RUB",
+		CustomerID:             customer.ID,
+		Month:                  ext.Period.Months,
+		PeriodDays:             purchasePeriodDays(ext.Period),
+		TariffName:             ext.TariffName,
+		DeviceLimit:            ext.DeviceLimit,
+		Source:                 customer.Source,
+		ProvisionedDeviceLimit: provisionedDeviceLimit(ext.TariffName, ext.DeviceLimit),
+	}
+	if ext.Payment != nil {
+		paymentID := ext.Payment.ID.String()
+		purchase.YookasaID = &ext.Payment.ID
+		purchase.ProviderPaymentID = &paymentID
+	}
+	return purchase
+}
+
+// withPanelExpire делает выдачу покупки повторяемой: срок подписки сохраняется в покупке до запроса в панель,
+// а повтор после сбоя (панель продлила подписку, но ответ не дошёл) выставляет тот же срок вместо нового продления
+func (s PaymentService) withPanelExpire(ctx context.Context, purchase *database.Purchase) context.Context {
+	if purchase.PanelExpireAt != nil {
+		slog.InfoContext(ctx, "Retrying purchase fulfillment with saved expiry", "purchaseId", purchase.ID, "expireAt", *purchase.PanelExpireAt)
+		return remnawave.WithExpireAt(ctx, *purchase.PanelExpireAt)
+	}
+	return remnawave.WithExpireHook(ctx, func(expireAt time.Time) error {
+		if err := s.purchaseRepository.UpdateFields(ctx, purchase.ID, map[string]interface{}{"panel_expire_at": expireAt}); err != nil {
+			return fmt.Errorf("save panel expiry: %w", err)
+		}
+		return nil
+	})
+}
+
+// providerPayload сериализует данные провайдера об оплате для журнала ошибок выдачи
+func providerPayload(v interface{}) []byte {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return payload
+}
+
+// recordFailedFulfillment записывает первую ошибку выдачи. Админ получает алерт, если автоматического повтора не будет
+func recordFailedFulfillment(ctx context.Context, store failedFulfillmentStore, purchaseID int64, source database.InvoiceType, payload []byte, procErr error, now time.Time) {
+	var nextAttemptAt *time.Time
+	if IsTransientFulfillmentError(procErr) && config.GetFulfillmentRetryMaxAttempts() > 1 {
+		next := now.Add(fulfillmentRetryDelay(1))
+		nextAttemptAt = &next
+	}
+
+	id, created, err := store.Record(ctx, purchaseID, string(source), payload, procErr.Error(), nextAttemptAt, now)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to record failed fulfillment", "purchaseId", purchaseID, "error", err)
+		return
+	}
+	if !created {
+		return
+	}
+	slog.WarnContext(ctx, "Purchase fulfillment failed", "id", id, "purchaseId", purchaseID, "source", source,
+		"retry", nextAttemptAt != nil, "error", procErr)
+	if nextAttemptAt == nil {
+		notifyFailedFulfillment(ctx, purchaseID, procErr)
+	}
+}
+
+// RetryDueFulfillments повторяет выдачу покупок после временных ошибок, время следующей попытки которых наступило
+func (s PaymentService) RetryDueFulfillments(ctx context.Context) error {
+	if s.failedFulfillments == nil {
+		return nil
+	}
+
+	entries, err := s.failedFulfillments.FindDue(ctx, clock.Now(), 50)
+	if err != nil {
+		return fmt.Errorf("failed to find due fulfillments: %w", err)
+	}
+
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		ctx := logging.WithRequestID(ctx, fmt.Sprintf("purchase-%d", entry.PurchaseID))
+		procErr := s.ProcessPurchaseById(ctx, entry.PurchaseID)
+		applyFulfillmentRetry(ctx, s.failedFulfillments, entry, procErr, false, clock.Now())
+	}
+
+	if len(entries) > 0 {
+		slog.InfoContext(ctx, "Retried failed fulfillments", "count", len(entries))
+	}
+	return nil
+}
+
+// RetryFailedFulfillment повторяет выдачу покупки из журнала по команде админа, независимо от статуса записи.
+// Возвращает обновлённую запись и ошибку повторной выдачи
+func (s PaymentService) RetryFailedFulfillment(ctx context.Context, id int64) (*database.FailedFulfillment, error) {
+	if s.failedFulfillments == nil {
+		return nil, ErrFailedFulfillmentNotFound
+	}
+	entry, err := s.failedFulfillments.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, ErrFailedFulfillmentNotFound
+	}
+	if entry.Status == database.FailedFulfillmentStatusResolved {
+		return entry, nil
+	}
+
+	ctx = logging.WithRequestID(ctx, fmt.Sprintf("purchase-%d", entry.PurchaseID))
+	procErr := s.ProcessPurchaseById(ctx, entry.PurchaseID)
+	return applyFulfillmentRetry(context.WithoutCancel(ctx), s.failedFulfillments, *entry, procErr, true, clock.Now()), procErr
+}
+
+// applyFulfillmentRetry сохраняет результат повторной попытки выдачи. Повтор из админки не продолжает
+// автоматические попытки: запись остаётся в failed до следующего повтора вручную
+func applyFulfillmentRetry(ctx context.Context, store failedFulfillmentStore, entry database.FailedFulfillment, procErr error, manual bool, now time.Time) *database.FailedFulfillment {
+	entry.Attempts++
+	entry.UpdatedAt = now
+
+	if procErr == nil {
+		if err := store.MarkResolved(ctx, entry.ID, entry.Attempts, now); err != nil {
+			slog.ErrorContext(ctx, "Failed to mark fulfillment resolved", "id", entry.ID, "error", err)
+		}
+		slog.InfoContext(ctx, "Failed fulfillment resolved by retry", "id", entry.ID, "purchaseId", entry.PurchaseID, "attempts", entry.Attempts)
+		entry.Status = database.FailedFulfillmentStatusResolved
+		entry.NextAttemptAt = nil
+		entry.ResolvedAt = &now
+		return &entry
+	}
+
+	entry.LastError = procErr.Error()
+	if !manual && IsTransientFulfillmentError(procErr) && entry.Attempts < config.GetFulfillmentRetryMaxAttempts() {
+		next := now.Add(fulfillmentRetryDelay(entry.Attempts))
+		slog.WarnContext(ctx, "Fulfillment retry failed, will retry", "id", entry.ID, "purchaseId", entry.PurchaseID,
+			"attempts", entry.Attempts, "nextAttemptAt", next, "error", procErr)
+		if err := store.ScheduleRetry(ctx, entry.ID, entry.Attempts, entry.LastError, next, now); err != nil {
+			slog.ErrorContext(ctx, "Failed to schedule fulfillment retry", "id", entry.ID, "error", err)
+		}
+		entry.Status = database.FailedFulfillmentStatusPending
+		entry.NextAttemptAt = &next
+		return &entry
+	}
+
+	slog.ErrorContext(ctx, "Fulfillment retry failed", "id", entry.ID, "purchaseId", entry.PurchaseID,
+		"attempts", entry.Attempts, "manual", manual, "error", procErr)
+	if err := store.MarkFailed(ctx, entry.ID, entry.Attempts, entry.LastError, now); err != nil {
+		slog.ErrorContext(ctx, "Failed to mark fulfillment failed", "id", entry.ID, "error", err)
+	}
+	if !manual && entry.Status == database.FailedFulfillmentStatusPending {
+		notifyFailedFulfillment(ctx, entry.PurchaseID, procErr)
+	}
+	entry.Status = database.FailedFulfillmentStatusFailed
+	entry.NextAttemptAt = nil
+	return &entry
+}
+
+// notifyFailedFulfillment сообщает админу об оплаченной покупке, которую нужно выдать вручную
+func notifyFailedFulfillment(ctx context.Context, purchaseID int64, procErr error) {
+	alert.Notify(ctx, alert.SeverityCritical, fmt.Sprintf("%s:%d", alert.KeyFulfillmentFailed, purchaseID),
+		fmt.Sprintf("Оплаченная покупка #%d не выдана: %v. Повторите выдачу в /admin → ⚠️ Ошибки", purchaseID, procErr))
+}
+
+// IsTransientFulfillmentError определяет временные ошибки выдачи (сеть, таймауты, недоступность панели),
+// которые имеет смысл повторить автоматически
+func IsTransientFulfillmentError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) || remnawave.IsUnavailable(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// fulfillmentRetryDelay возвращает задержку перед следующей попыткой выдачи
+// Экспоненциальный backoff: 1, 2, 4, 8... минут, но не более часа
+func fulfillmentRetryDelay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt > 7 {
+		return time.Hour
+	}
+	delay := time.Minute << (attempt - 1)
+	if delay > time.Hour {
+		return time.Hour
+	}
+	return delay
+}
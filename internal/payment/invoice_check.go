@@ -0,0 +1,322 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/logging"
+	"remnawave-tg-shop-bot/internal/yookasa"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// CheckYookasaInvoices проверяет ожидающие оплаты счета ЮKassa и выдаёт подписку по оплаченным
+func (s PaymentService) CheckYookasaInvoices(ctx context.Context) {
+	pendingPurchases, err := s.purchaseRepository.FindByInvoiceTypeAndStatus(
+		ctx,
+		database.InvoiceTypeYookasa,
+		database.PurchaseStatusPending,
+	)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding pending purchases", "error", err)
+		return
+	}
+	if len(*pendingPurchases) == 0 {
+		return
+	}
+
+	for i, purchase := range *pendingPurchases {
+		// Задержка между запросами чтобы не перегружать API ЮКассы
+		if i > 0 {
+			if err := utils.Sleep(ctx, 200*time.Millisecond); err != nil {
+				slog.WarnContext(ctx, "YooKassa invoice check interrupted", "checked", i, "pending", len(*pendingPurchases), "error", err)
+				return
+			}
+		}
+
+		ctx := logging.WithRequestID(ctx, fmt.Sprintf("purchase-%d", purchase.ID))
+		// Тестовые покупки админа создаются в тестовом магазине ЮKassa
+		invoice, err := s.YookasaClientFor(&purchase).GetPayment(ctx, *purchase.YookasaID)
+
+		if err != nil {
+			slog.ErrorContext(ctx, "Error getting invoice", "invoiceId", purchase.YookasaID, "error", err)
+			continue
+		}
+
+		if invoice.IsCancelled() {
+			err := s.ProcessYookassaCancellation(ctx, invoice)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error canceling invoice", "invoiceId", invoice.ID, "purchaseId", purchase.ID, "error", err)
+			}
+			continue
+		}
+
+		if !invoice.Paid {
+			continue
+		}
+
+		// Продолжаем логи апдейта, в котором был создан платёж
+		if requestID := invoice.Metadata["request_id"]; requestID != "" {
+			ctx = logging.WithRequestID(ctx, requestID)
+		}
+
+		purchaseId, err := strconv.Atoi(invoice.Metadata["purchaseId"])
+		if err != nil {
+			slog.ErrorContext(ctx, "Error parsing purchaseId", "invoiceId", invoice.ID, "error", err)
+		}
+		s.SaveProviderPayment(ctx, purchase.ID, "", invoice.PaymentMethod.Type)
+
+		ctxWithValue := context.WithValue(ctx, "username", invoice.Metadata["username"])
+		err = s.FulfillPurchase(ctxWithValue, int64(purchaseId), database.InvoiceTypeYookasa, providerPayload(invoice))
+		if err != nil {
+			slog.ErrorContext(ctx, "Error processing invoice", "invoiceId", invoice.ID, "purchaseId", purchaseId, "error", err)
+		} else {
+			slog.InfoContext(ctx, "Invoice processed", "invoiceId", invoice.ID, "purchaseId", purchaseId)
+		}
+
+		// Тестовые покупки не меняют настройки автопродления: способ оплаты тестового магазина
+		// нельзя использовать для автосписаний через основной магазин
+		if purchase.IsTest {
+			continue
+		}
+
+		// Управление recurring после успешной оплаты YooKassa
+		// ВАЖНО: ProcessPurchaseById уже мог удалить payment method для promo/winback покупок
+		// когда соответствующий recurring отключён.
+		// Проверяем текущее состояние customer — если payment_method_id уже NULL,
+		// значит ProcessPurchaseById его удалил и не нужно восстанавливать.
+		if invoice.IsPaymentMethodSaved() {
+			// Карта попадает в список сохранённых в любом случае — пользователь разрешил её сохранить.
+			// Ниже решается только, привязывать ли к ней автопродление
+			s.savePaymentMethod(ctx, invoice, purchase.CustomerID)
+
+			// Перечитываем customer чтобы увидеть изменения от ProcessPurchaseById
+			updatedCustomer, err := s.customerRepository.FindById(ctx, purchase.CustomerID)
+			if err != nil {
+				slog.ErrorContext(ctx, "Error finding customer after purchase", "customerID", purchase.CustomerID, "error", err)
+			} else if updatedCustomer != nil && updatedCustomer.PaymentMethodID == nil {
+				// ProcessPurchaseById удалил payment method (promo/winback с отключённым recurring)
+				// Не восстанавливаем его
+				slog.InfoContext(ctx, "Payment method was deleted by ProcessPurchaseById, not restoring", "customerID", purchase.CustomerID)
+			} else {
+				// Пользователь включил автопродление — сохраняем payment_method_id
+				// Передаём purchase для fallback данных (если пользователь не включил recurring в боте,
+				// но разрешил автосписания на форме ЮКассы)
+				s.saveRecurringPaymentMethod(ctx, invoice, purchase.CustomerID, &purchase)
+			}
+		} else {
+			// Пользователь НЕ включил автопродление для этой покупки — отключаем recurring
+			// Но карту не удаляем — она может пригодиться для будущих покупок
+			if err := s.customerRepository.DisableRecurring(ctx, purchase.CustomerID); err != nil {
+				slog.ErrorContext(ctx, "Error disabling recurring after purchase without save", "customerID", purchase.CustomerID, "error", err)
+			} else {
+				slog.InfoContext(ctx, "Disabled recurring after purchase without payment method save", "customerID", purchase.CustomerID)
+			}
+		}
+
+	}
+}
+
+// savePaymentMethod добавляет карту из платежа в список сохранённых способов оплаты клиента
+func (s PaymentService) savePaymentMethod(ctx context.Context, invoice *yookasa.Payment, customerID int64) {
+	if s.paymentMethodRepository == nil {
+		return
+	}
+	cardType, last4, title := invoice.SavedMethodDetails()
+	method := &database.PaymentMethod{
+		CustomerID: customerID,
+		MethodID:   invoice.GetPaymentMethodID().String(),
+		CardType:   cardType,
+		CardLast4:  last4,
+		Title:      title,
+	}
+	if err := s.paymentMethodRepository.Save(ctx, method); err != nil {
+		slog.ErrorContext(ctx, "Error saving payment method", "customerID", customerID, "error", err)
+	}
+}
+
+// saveRecurringPaymentMethod сохраняет payment_method_id и настройки рекуррентных платежей
+// Requirements: 1.3
+// purchase передаётся для fallback данных, если метаданные отсутствуют
+// (случай когда пользователь не включил recurring в боте, но разрешил автосписания на форме ЮКассы)
+func (s PaymentService) saveRecurringPaymentMethod(ctx context.Context, invoice *yookasa.Payment, customerID int64, purchase *database.Purchase) {
+	paymentMethodID := invoice.GetPaymentMethodID().String()
+
+	// Получаем настройки recurring из метаданных платежа
+	var tariffName *string
+	var months *int
+	var days *int
+	var amount *int
+
+	if tn, ok := invoice.Metadata["recurring_tariff_name"]; ok && tn != "" {
+		tariffName = &tn
+	}
+
+	if m, ok := invoice.Metadata["recurring_months"]; ok {
+		if monthsInt, err := strconv.Atoi(m); err == nil {
+			months = &monthsInt
+		}
+	}
+
+	// Посуточный план тарифа продлевается на recurring_days дней вместо месяцев
+	if d, ok := invoice.Metadata["recurring_days"]; ok {
+		if daysInt, err := strconv.Atoi(d); err == nil {
+			days = &daysInt
+		}
+	}
+
+	if a, ok := invoice.Metadata["recurring_amount"]; ok {
+		if amountInt, err := strconv.Atoi(a); err == nil {
+			amount = &amountInt
+		}
+	}
+
+	// Fallback: если метаданные отсутствуют (пользователь не включил recurring в боте,
+	// но разрешил автосписания на форме ЮКассы), берём данные из purchase
+	if purchase != nil {
+		if tariffName == nil && purchase.TariffName != nil {
+			tariffName = purchase.TariffName
+			slog.InfoContext(ctx, "Using tariff name from purchase (fallback)", "tariffName", *tariffName)
+		}
+		if months == nil && days == nil && purchase.PeriodDays != nil {
+			d := *purchase.PeriodDays
+			days = &d
+			slog.InfoContext(ctx, "Using period days from purchase (fallback)", "days", d)
+		}
+		if months == nil && days == nil && purchase.Month > 0 {
+			m := purchase.Month
+			months = &m
+			slog.InfoContext(ctx, "Using months from purchase (fallback)", "months", m)
+		}
+		if amount == nil && purchase.Amount > 0 {
+			a := int(purchase.Amount)
+			amount = &a
+			slog.InfoContext(ctx, "Using amount from purchase (fallback)", "amount", a)
+		}
+	}
+
+	// Снимок тарифа на момент подключения: по нему видно, когда цена тарифа изменилась
+	listPrice := amount
+	if months != nil || days != nil {
+		name := ""
+		if tariffName != nil {
+			name = *tariffName
+		}
+		var period config.Period
+		if days != nil {
+			period = config.DaysPeriod(*days)
+		} else {
+			period = config.MonthsPeriod(*months)
+		}
+		if price := config.RecurringPeriodListPrice(name, period); price > 0 {
+			listPrice = &price
+		}
+	}
+	var deviceLimit *int
+	if purchase != nil {
+		deviceLimit = purchase.DeviceLimit
+	}
+
+	err := s.customerRepository.UpdateRecurringSettings(
+		ctx,
+		customerID,
+		true, // recurring_enabled
+		&paymentMethodID,
+		tariffName,
+		months,
+		days,
+		amount,
+		listPrice,
+		deviceLimit,
+	)
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Error saving recurring payment method",
+			"customerID", customerID,
+			"paymentMethodID", paymentMethodID,
+			"error", err)
+	} else {
+		// Разыменовываем указатели для логирования
+		var tariffNameVal string
+		var monthsVal, daysVal, amountVal int
+		if tariffName != nil {
+			tariffNameVal = *tariffName
+		}
+		if months != nil {
+			monthsVal = *months
+		}
+		if days != nil {
+			daysVal = *days
+		}
+		if amount != nil {
+			amountVal = *amount
+		}
+		slog.InfoContext(ctx, "Recurring payment method saved",
+			"customerID", customerID,
+			"paymentMethodID", paymentMethodID,
+			"tariffName", tariffNameVal,
+			"months", monthsVal,
+			"days", daysVal,
+			"amount", amountVal)
+	}
+}
+
+// CheckCryptoPayInvoices проверяет ожидающие оплаты счета CryptoPay и выдаёт подписку по оплаченным
+func (s PaymentService) CheckCryptoPayInvoices(ctx context.Context) {
+	pendingPurchases, err := s.purchaseRepository.FindByInvoiceTypeAndStatus(
+		ctx,
+		database.InvoiceTypeCrypto,
+		database.PurchaseStatusPending,
+	)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding pending purchases", "error", err)
+		return
+	}
+	if len(*pendingPurchases) == 0 {
+		return
+	}
+
+	var invoiceIDs []string
+
+	for _, purchase := range *pendingPurchases {
+		if purchase.CryptoInvoiceID != nil {
+			invoiceIDs = append(invoiceIDs, fmt.Sprintf("%d", *purchase.CryptoInvoiceID))
+		}
+	}
+
+	if len(invoiceIDs) == 0 {
+		return
+	}
+
+	stringInvoiceIDs := strings.Join(invoiceIDs, ",")
+	invoices, err := s.cryptoPayClient.GetInvoices(ctx, "", "", "", stringInvoiceIDs, 0, 0)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting invoices", "error", err)
+		return
+	}
+
+	for _, invoice := range *invoices {
+		if ctx.Err() != nil {
+			slog.WarnContext(ctx, "CryptoPay invoice check interrupted", "error", ctx.Err())
+			return
+		}
+		if invoice.InvoiceID != nil && invoice.IsPaid() {
+			payload := strings.Split(invoice.Payload, "&")
+			purchaseID, err := strconv.Atoi(strings.Split(payload[0], "=")[1])
+			username := strings.Split(payload[1], "=")[1]
+			ctxWithUsername := context.WithValue(logging.WithRequestID(ctx, fmt.Sprintf("purchase-%d", purchaseID)), "username", username)
+			err = s.FulfillPurchase(ctxWithUsername, int64(purchaseID), database.InvoiceTypeCrypto, providerPayload(invoice))
+			if err != nil {
+				slog.ErrorContext(ctxWithUsername, "Error processing invoice", "invoiceId", invoice.InvoiceID, "error", err)
+			} else {
+				slog.InfoContext(ctxWithUsername, "Invoice processed", "invoiceId", invoice.InvoiceID, "purchaseId", purchaseID)
+			}
+
+		}
+	}
+}
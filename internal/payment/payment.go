@@ -6,18 +6,31 @@ import (
 	"fmt"
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
+	"github.com/google/uuid"
 	"log/slog"
+	"math"
+	"remnawave-tg-shop-bot/internal/activity"
+	"remnawave-tg-shop-bot/internal/alert"
 	"remnawave-tg-shop-bot/internal/cache"
+	"remnawave-tg-shop-bot/internal/clock"
 	"remnawave-tg-shop-bot/internal/config"
 	"remnawave-tg-shop-bot/internal/cryptopay"
 	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/events"
+	"remnawave-tg-shop-bot/internal/locale"
 	"remnawave-tg-shop-bot/internal/remnawave"
+	"remnawave-tg-shop-bot/internal/shutdown"
+	"remnawave-tg-shop-bot/internal/team"
 	"remnawave-tg-shop-bot/internal/translation"
 	"remnawave-tg-shop-bot/internal/yookasa"
 	"remnawave-tg-shop-bot/utils"
+	"strconv"
 	"time"
 )
 
+// referralBonusTimeout ограничивает начисление бонуса рефереру после выдачи покупки
+const referralBonusTimeout = 30 * time.Second
+
 type PaymentService struct {
 	purchaseRepository *database.PurchaseRepository
 	remnawaveClient    *remnawave.Client
@@ -28,6 +41,25 @@ type PaymentService struct {
 	yookasaClient      *yookasa.Client
 	referralRepository *database.ReferralRepository
 	cache              *cache.Cache
+	// yookasaTestClient тестовый магазин ЮKassa для тестовых покупок админа (может быть nil)
+	yookasaTestClient *yookasa.Client
+	// teamService создаёт команды по оплаченным командным подпискам (может быть nil)
+	teamService *team.Service
+	// winbackRepository учитывает покупки по предложениям ушедшим клиентам в статистике кампаний (может быть nil)
+	winbackRepository *database.WinbackRepository
+	// checkoutReminderRepository учитывает напоминания о неоплаченных счетах (может быть nil)
+	checkoutReminderRepository *database.CheckoutReminderRepository
+	// paymentMethodRepository список сохранённых карт клиента (может быть nil)
+	paymentMethodRepository *database.PaymentMethodRepository
+	// survey предлагает оценить сервис после продления (может быть nil)
+	survey surveyOfferer
+	// failedFulfillments журнал ошибок выдачи оплаченных покупок (может быть nil)
+	failedFulfillments failedFulfillmentStore
+}
+
+// surveyOfferer отправка опроса удовлетворённости
+type surveyOfferer interface {
+	Offer(ctx context.Context, customer *database.Customer, trigger string) (bool, error)
 }
 
 func NewPaymentService(
@@ -54,7 +86,50 @@ func NewPaymentService(
 	}
 }
 
+// SetYookasaTestClient подключает тестовый магазин ЮKassa для тестовых покупок
+func (s *PaymentService) SetYookasaTestClient(client *yookasa.Client) {
+	s.yookasaTestClient = client
+}
+
+// SetTeamService подключает командные подписки
+func (s *PaymentService) SetTeamService(teamService *team.Service) {
+	s.teamService = teamService
+}
+
+// SetWinbackRepository подключает учёт покупок по winback кампаниям
+func (s *PaymentService) SetWinbackRepository(winbackRepository *database.WinbackRepository) {
+	s.winbackRepository = winbackRepository
+}
+
+// SetPaymentMethodRepository подключает список сохранённых карт
+func (s *PaymentService) SetPaymentMethodRepository(paymentMethodRepository *database.PaymentMethodRepository) {
+	s.paymentMethodRepository = paymentMethodRepository
+}
+
+// SetCheckoutReminderRepository подключает напоминания о неоплаченных счетах
+func (s *PaymentService) SetCheckoutReminderRepository(checkoutReminderRepository *database.CheckoutReminderRepository) {
+	s.checkoutReminderRepository = checkoutReminderRepository
+}
+
+// SetSurvey подключает опрос удовлетворённости после продления подписки
+func (s *PaymentService) SetSurvey(survey surveyOfferer) {
+	s.survey = survey
+}
+
+// YookasaClientFor возвращает клиент ЮKassa, в магазине которого создан платёж покупки
+func (s PaymentService) YookasaClientFor(purchase *database.Purchase) *yookasa.Client {
+	if purchase.IsTest && s.yookasaTestClient != nil {
+		return s.yookasaTestClient
+	}
+	return s.yookasaClient
+}
+
+// ProcessPurchaseById выдаёт подписку по оплаченной покупке. Деньги уже получены, поэтому обработка
+// не прерывается отменой контекста вызывающего, а остановка бота дожидается её завершения
 func (s PaymentService) ProcessPurchaseById(ctx context.Context, purchaseId int64) error {
+	defer shutdown.Track(shutdown.KindPayment)()
+	ctx = context.WithoutCancel(ctx)
+
 	purchase, err := s.purchaseRepository.FindById(ctx, purchaseId)
 	if err != nil {
 		return err
@@ -65,7 +140,7 @@ func (s PaymentService) ProcessPurchaseById(ctx context.Context, purchaseId int6
 
 	// Проверяем что purchase ещё не обработан (защита от двойной обработки)
 	if purchase.Status == database.PurchaseStatusPaid {
-		slog.Debug("Purchase already processed, skipping", "purchaseId", purchaseId)
+		slog.DebugContext(ctx, "Purchase already processed, skipping", "purchaseId", purchaseId)
 		return nil
 	}
 
@@ -77,14 +152,8 @@ func (s PaymentService) ProcessPurchaseById(ctx context.Context, purchaseId int6
 		return fmt.Errorf("customer %s not found", utils.MaskHalfInt64(purchase.CustomerID))
 	}
 
-	if messageId, b := s.cache.Get(purchase.ID); b {
-		_, err = s.telegramBot.DeleteMessage(ctx, &bot.DeleteMessageParams{
-			ChatID:    customer.TelegramID,
-			MessageID: messageId,
-		})
-		if err != nil {
-			slog.Error("Error deleting message", "error", err)
-		}
+	if purchase.TeamSeats != nil {
+		return s.processTeamPurchase(ctx, customer, purchase)
 	}
 
 	// Определяем лимит устройств: сначала из purchase (winback), потом из тарифа
@@ -92,16 +161,23 @@ func (s PaymentService) ProcessPurchaseById(ctx context.Context, purchaseId int6
 	if purchase.DeviceLimit != nil {
 		// Winback или явно указанный лимит — используем сохранённый в purchase
 		deviceLimit = purchase.DeviceLimit
-		slog.Debug("Using purchase device limit (winback)", "devices", *purchase.DeviceLimit)
+		slog.DebugContext(ctx, "Using purchase device limit (winback)", "devices", *purchase.DeviceLimit)
 	} else if purchase.TariffName != nil && *purchase.TariffName != "" {
 		tariff := config.GetTariffByName(*purchase.TariffName)
 		if tariff != nil {
 			deviceLimit = &tariff.Devices
-			slog.Debug("Using tariff device limit", "tariff", *purchase.TariffName, "devices", tariff.Devices)
+			slog.DebugContext(ctx, "Using tariff device limit", "tariff", *purchase.TariffName, "devices", tariff.Devices)
+		} else if purchase.ProvisionedDeviceLimit != nil {
+			// Тариф был удалён после создания покупки — выдаём лимит, сохранённый при создании счёта
+			deviceLimit = purchase.ProvisionedDeviceLimit
+			slog.WarnContext(ctx, "Tariff not found for purchase, using device limit saved with purchase",
+				"tariff", *purchase.TariffName,
+				"purchaseId", purchase.ID,
+				"devices", *deviceLimit)
 		} else {
 			// Тариф был удалён после создания покупки — логируем warning
 			// deviceLimit остаётся nil, пользователь получит лимит по умолчанию из панели
-			slog.Warn("Tariff not found for purchase, using default device limit",
+			slog.WarnContext(ctx, "Tariff not found for purchase, using default device limit",
 				"tariff", *purchase.TariffName,
 				"purchaseId", purchase.ID)
 		}
@@ -114,29 +190,55 @@ func (s PaymentService) ProcessPurchaseById(ctx context.Context, purchaseId int6
 	if deviceLimit != nil {
 		hasPaid, err := s.purchaseRepository.HasPaidPurchases(ctx, customer.ID)
 		if err != nil {
-			slog.Warn("Failed to check paid purchases, using force=false", "error", err)
+			slog.WarnContext(ctx, "Failed to check paid purchases, using force=false", "error", err)
 		} else {
 			forceDeviceLimit = !hasPaid
 			if forceDeviceLimit {
-				slog.Debug("First purchase detected, will force device limit", "customerId", customer.ID)
+				slog.DebugContext(ctx, "First purchase detected, will force device limit", "customerId", customer.ID)
 			}
 		}
 	}
 
-	user, err := s.remnawaveClient.CreateOrUpdateUserWithDeviceLimit(ctx, customer.ID, customer.TelegramID, config.TrafficLimit(), purchase.Month*config.DaysInMonth(), false, deviceLimit, forceDeviceLimit)
+	// Проверяем была ли это WINBACK покупка (не просто наличие offer, а именно покупка по winback)
+	// Определяем по совпадению параметров purchase с параметрами winback offer
+	isWinbackPurchase := isWinbackOfferPurchase(customer, purchase.Amount, purchase.Month, purchase.DeviceLimit)
+
+	panelCtx := remnawave.WithExternalSquad(s.withPanelExpire(ctx, purchase), purchaseExternalSquad(customer, purchase))
+	panelTag := ""
+	if config.IsRemnawaveTagSyncEnabled() {
+		panelTag = purchaseRemnawaveTag(purchase, isWinbackPurchase)
+		panelCtx = remnawave.WithTag(panelCtx, panelTag)
+	}
+
+	user, err := s.remnawaveClient.CreateOrUpdateUserWithDeviceLimit(panelCtx, customer.ID, customer.TelegramID, config.TrafficLimit(), purchase.Period().TotalDays(), false, deviceLimit, forceDeviceLimit)
 	if err != nil {
 		return err
 	}
 
+	renewal := s.isRenewal(ctx, customer, purchase)
+
 	err = s.purchaseRepository.MarkAsPaid(ctx, purchase.ID)
 	if err != nil {
 		return err
 	}
+	activity.Record(ctx, customer.TelegramID, database.FunnelEventPaid)
+	events.Publish(ctx, paymentSucceededEvent(customer, purchase, &user.ExpireAt))
 
 	customerFilesToUpdate := map[string]interface{}{
 		"subscription_link": user.SubscriptionUrl,
 		"expire_at":         user.ExpireAt,
 	}
+	if config.IsRemnawaveTagSyncEnabled() {
+		customerFilesToUpdate["remnawave_tag"] = nullableTag(panelTag)
+	}
+	// Клиент, удалённый синхронизацией до оплаты, снова есть в панели
+	if customer.DeletedAt != nil {
+		customerFilesToUpdate["deleted_at"] = nil
+	}
+	// Пользователь панели, очищенный после триала, только что включён или создан заново
+	if customer.TrialCleanedAt != nil {
+		customerFilesToUpdate["trial_cleaned_at"] = nil
+	}
 
 	err = s.customerRepository.UpdateFields(ctx, customer.ID, customerFilesToUpdate)
 	if err != nil {
@@ -146,25 +248,22 @@ func (s PaymentService) ProcessPurchaseById(ctx context.Context, purchaseId int6
 	// Property 9: Offer Cleared After Purchase
 	// Проверяем была ли это PROMO TARIFF покупка (не просто наличие offer, а именно покупка по promo)
 	// Определяем по совпадению параметров purchase с параметрами promo offer
-	isPromoTariffPurchase := database.HasActivePromoOffer(customer) &&
-		customer.PromoOfferPrice != nil && int(purchase.Amount) == *customer.PromoOfferPrice &&
-		customer.PromoOfferMonths != nil && purchase.Month == *customer.PromoOfferMonths &&
-		customer.PromoOfferDevices != nil && purchase.DeviceLimit != nil && *purchase.DeviceLimit == *customer.PromoOfferDevices
+	isPromoTariffPurchase := isPromoTariffOfferPurchase(customer, purchase.Amount, purchase.Month, purchase.DeviceLimit)
 
 	// Очищаем promo offer после успешной покупки (если был использован)
 	if isPromoTariffPurchase {
 		if err := s.customerRepository.ClearPromoOffer(ctx, customer.ID); err != nil {
-			slog.Error("Error clearing promo offer after purchase", "error", err, "customerId", customer.ID)
+			slog.ErrorContext(ctx, "Error clearing promo offer after purchase", "error", err, "customerId", customer.ID)
 			// Не возвращаем ошибку - покупка уже обработана
 		} else {
-			slog.Info("Cleared promo offer after purchase", "customerId", customer.ID)
+			slog.InfoContext(ctx, "Cleared promo offer after purchase", "customerId", customer.ID)
 		}
 
 		// Управление recurring при покупке промо тарифа
 		if config.IsPromoTariffRecurringEnabled() {
 			// Автопродление для promo tariff включено — оставляем payment method
 			// Пользователь сможет использовать его для автопродления
-			slog.Info("Promo tariff recurring enabled, keeping payment method", "customerId", customer.ID)
+			slog.InfoContext(ctx, "Promo tariff recurring enabled, keeping payment method", "customerId", customer.ID)
 		} else {
 			// Полностью сбрасываем recurring настройки при покупке промо тарифа
 			// Это предотвращает:
@@ -172,33 +271,31 @@ func (s PaymentService) ProcessPurchaseById(ctx context.Context, purchaseId int6
 			// 2. Сохранение promo данных (tariff, months, amount) для будущих автоплатежей
 			// Пользователь начинает "с чистого листа" по автоплатежам после promo покупки
 			if err := s.customerRepository.DeletePaymentMethod(ctx, customer.ID); err != nil {
-				slog.Error("Error deleting payment method after promo purchase", "error", err, "customerId", customer.ID)
+				slog.ErrorContext(ctx, "Error deleting payment method after promo purchase", "error", err, "customerId", customer.ID)
 			} else {
-				slog.Info("Deleted payment method after promo tariff purchase", "customerId", customer.ID)
+				slog.InfoContext(ctx, "Deleted payment method after promo tariff purchase", "customerId", customer.ID)
 			}
 		}
 	}
 
-	// Проверяем была ли это WINBACK покупка (не просто наличие offer, а именно покупка по winback)
-	// Определяем по совпадению параметров purchase с параметрами winback offer
-	isWinbackPurchase := database.HasActiveWinbackOffer(customer) &&
-		customer.WinbackOfferPrice != nil && int(purchase.Amount) == *customer.WinbackOfferPrice &&
-		customer.WinbackOfferMonths != nil && purchase.Month == *customer.WinbackOfferMonths &&
-		customer.WinbackOfferDevices != nil && purchase.DeviceLimit != nil && *purchase.DeviceLimit == *customer.WinbackOfferDevices
-
 	// Очищаем winback offer после успешной покупки (если был использован)
 	if isWinbackPurchase {
+		if customer.WinbackOfferCampaign != nil && s.winbackRepository != nil {
+			if err := s.winbackRepository.MarkConverted(ctx, customer.ID, *customer.WinbackOfferCampaign, purchase.ID, clock.Now()); err != nil {
+				slog.ErrorContext(ctx, "Error marking winback conversion", "error", err, "customerId", customer.ID)
+			}
+		}
 		if err := s.customerRepository.ClearWinbackOffer(ctx, customer.ID); err != nil {
-			slog.Error("Error clearing winback offer after purchase", "error", err, "customerId", customer.ID)
+			slog.ErrorContext(ctx, "Error clearing winback offer after purchase", "error", err, "customerId", customer.ID)
 			// Не возвращаем ошибку - покупка уже обработана
 		} else {
-			slog.Info("Cleared winback offer after purchase", "customerId", customer.ID)
+			slog.InfoContext(ctx, "Cleared winback offer after purchase", "customerId", customer.ID)
 		}
 
 		// Управление recurring при покупке winback
 		if config.IsWinbackRecurringEnabled() {
 			// Автопродление для winback включено — оставляем payment method
-			slog.Info("Winback recurring enabled, keeping payment method", "customerId", customer.ID)
+			slog.InfoContext(ctx, "Winback recurring enabled, keeping payment method", "customerId", customer.ID)
 		} else {
 			// Полностью сбрасываем recurring настройки при покупке winback
 			// Это предотвращает:
@@ -206,25 +303,32 @@ func (s PaymentService) ProcessPurchaseById(ctx context.Context, purchaseId int6
 			// 2. Сохранение winback данных (tariff, months, amount) для будущих автоплатежей
 			// Пользователь начинает "с чистого листа" по автоплатежам после winback покупки
 			if err := s.customerRepository.DeletePaymentMethod(ctx, customer.ID); err != nil {
-				slog.Error("Error deleting payment method after winback purchase", "error", err, "customerId", customer.ID)
+				slog.ErrorContext(ctx, "Error deleting payment method after winback purchase", "error", err, "customerId", customer.ID)
 			} else {
-				slog.Info("Deleted payment method after winback purchase", "customerId", customer.ID)
+				slog.InfoContext(ctx, "Deleted payment method after winback purchase", "customerId", customer.ID)
 			}
 		}
 	}
 
-	_, err = s.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
-		ChatID: customer.TelegramID,
-		Text:   s.translation.GetText(customer.Language, "subscription_activated"),
-		ReplyMarkup: models.InlineKeyboardMarkup{
-			InlineKeyboard: s.createConnectKeyboard(customer),
-		},
-	})
-	if err != nil {
+	if purchase.GiftFromCustomerID != nil {
+		s.sendGiftDelivered(ctx, customer, purchase, user.ExpireAt)
+	} else if err := s.sendSubscriptionActivated(ctx, customer, purchase); err != nil {
 		return err
 	}
+	if renewal {
+		if _, err := s.survey.Offer(ctx, customer, database.SurveyTriggerRenewal); err != nil {
+			slog.WarnContext(ctx, "Failed to send renewal survey", "customerId", utils.MaskHalfInt64(customer.ID), "error", err)
+		}
+	}
+
+	if purchase.IsTest {
+		slog.InfoContext(ctx, "Test purchase processed, referral bonus skipped", "purchase_id", utils.MaskHalfInt64(purchase.ID), "type", purchase.InvoiceType)
+		return nil
+	}
 
-	ctxReferee := context.Background()
+	// Покупка уже выдана: бонус рефереру начисляем, даже если запрос оплаты отменён, но не дольше referralBonusTimeout
+	ctxReferee, cancel := context.WithTimeout(context.WithoutCancel(ctx), referralBonusTimeout)
+	defer cancel()
 	referee, err := s.referralRepository.FindByReferee(ctxReferee, customer.TelegramID)
 	if referee == nil {
 		return nil
@@ -255,7 +359,7 @@ func (s PaymentService) ProcessPurchaseById(ctx context.Context, purchaseId int6
 	if err != nil {
 		return err
 	}
-	slog.Info("Granted referral bonus", "customer_id", utils.MaskHalfInt64(refereeCustomer.ID))
+	slog.InfoContext(ctx, "Granted referral bonus", "customer_id", utils.MaskHalfInt64(refereeCustomer.ID))
 	_, err = s.telegramBot.SendMessage(ctxReferee, &bot.SendMessageParams{
 		ChatID:    refereeCustomer.TelegramID,
 		ParseMode: models.ParseModeHTML,
@@ -264,11 +368,205 @@ func (s PaymentService) ProcessPurchaseById(ctx context.Context, purchaseId int6
 			InlineKeyboard: s.createConnectKeyboard(refereeCustomer),
 		},
 	})
-	slog.Info("purchase processed", "purchase_id", utils.MaskHalfInt64(purchase.ID), "type", purchase.InvoiceType, "customer_id", utils.MaskHalfInt64(customer.ID))
+	slog.InfoContext(ctx, "purchase processed", "purchase_id", utils.MaskHalfInt64(purchase.ID), "type", purchase.InvoiceType, "customer_id", utils.MaskHalfInt64(customer.ID))
+
+	return nil
+}
+
+// isRenewal возвращает true, если после покупки клиенту нужно предложить опрос: опрос подключён, покупка
+// не тестовая и не подарок, а у клиента уже есть оплаченные покупки. Проверяется до отметки покупки оплаченной
+func (s PaymentService) isRenewal(ctx context.Context, customer *database.Customer, purchase *database.Purchase) bool {
+	if s.survey == nil || purchase.IsTest || purchase.GiftFromCustomerID != nil {
+		return false
+	}
+	hasPaid, err := s.purchaseRepository.HasPaidPurchases(ctx, customer.ID)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to check paid purchases for survey", "customerId", utils.MaskHalfInt64(customer.ID), "error", err)
+		return false
+	}
+	return hasPaid
+}
+
+// cancelRefundedTeam отменяет команду полностью возвращённой покупки: участники теряют оставшиеся дни,
+// ссылки-приглашения перестают работать
+func (s PaymentService) cancelRefundedTeam(ctx context.Context, purchase *database.Purchase) error {
+	if s.teamService == nil {
+		return fmt.Errorf("team purchase %s refunded but team plans are not configured", utils.MaskHalfInt64(purchase.ID))
+	}
+	_, err := s.teamService.CancelForPurchase(ctx, purchase.ID)
+	return err
+}
+
+// processTeamPurchase создаёт команду по оплаченной командной подписке. Подписка плательщика не продлевается:
+// он получает ссылки-приглашения и сам может занять одно из мест
+func (s PaymentService) processTeamPurchase(ctx context.Context, customer *database.Customer, purchase *database.Purchase) error {
+	if s.teamService == nil {
+		return fmt.Errorf("team purchase %s received but team plans are not configured", utils.MaskHalfInt64(purchase.ID))
+	}
+
+	createdTeam, err := s.teamService.CreateForPurchase(ctx, purchase)
+	if err != nil {
+		return err
+	}
+
+	if err := s.purchaseRepository.MarkAsPaid(ctx, purchase.ID); err != nil {
+		return err
+	}
+	activity.Record(ctx, customer.TelegramID, database.FunnelEventPaid)
+	events.Publish(ctx, paymentSucceededEvent(customer, purchase, nil))
+
+	text := s.translation.GetTextTemplate(customer.Language, "team_purchased", map[string]interface{}{
+		"seats":  createdTeam.Seats,
+		"expire": locale.FormatDate(customer.Language, createdTeam.ExpiresAt),
+	})
+	keyboard := models.InlineKeyboardMarkup{InlineKeyboard: s.withReceiptButton(customer, purchase, [][]models.InlineKeyboardButton{
+		{{Text: s.translation.GetText(customer.Language, "team_manage_button"), CallbackData: fmt.Sprintf("team_view?id=%d", createdTeam.ID)}},
+		{{Text: s.translation.GetText(customer.Language, "back_button"), CallbackData: "start"}},
+	})}
 
+	sent := false
+	if messageID, ok := s.purchaseMessageID(purchase); ok {
+		_, err := s.telegramBot.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:      customer.TelegramID,
+			MessageID:   messageID,
+			Text:        text,
+			ParseMode:   models.ParseModeHTML,
+			ReplyMarkup: keyboard,
+		})
+		sent = err == nil
+	}
+	if !sent {
+		_, err = s.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:      customer.TelegramID,
+			Text:        text,
+			ParseMode:   models.ParseModeHTML,
+			ReplyMarkup: keyboard,
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Error sending team purchased message", "error", err)
+		}
+	}
+
+	slog.InfoContext(ctx, "team purchase processed", "purchase_id", utils.MaskHalfInt64(purchase.ID), "team_id", createdTeam.ID, "seats", createdTeam.Seats, "customer_id", utils.MaskHalfInt64(customer.ID))
 	return nil
 }
 
+// paymentSucceededEvent собирает событие об оплате для внешних интеграций. expireAt nil — подписка плательщика
+// не продлевалась (командная покупка)
+func paymentSucceededEvent(customer *database.Customer, purchase *database.Purchase, expireAt *time.Time) events.Event {
+	return events.PaymentSucceeded(events.Payment{
+		PurchaseID:  purchase.ID,
+		CustomerID:  customer.ID,
+		TelegramID:  customer.TelegramID,
+		Amount:      purchase.Amount,
+		Currency:    purchase.Currency,
+		InvoiceType: string(purchase.InvoiceType),
+		Months:      purchase.Month,
+		Days:        purchase.Period().Days,
+		TariffName:  purchase.TariffName,
+		TeamSeats:   purchase.TeamSeats,
+		ExpireAt:    expireAt,
+		IsTest:      purchase.IsTest,
+	})
+}
+
+// sendSubscriptionActivated превращает сообщение со ссылкой на оплату в сообщение об активации подписки,
+// чтобы кнопки оплаты не оставались активными. Если сообщения нет или его нельзя изменить — отправляет новое
+func (s PaymentService) sendSubscriptionActivated(ctx context.Context, customer *database.Customer, purchase *database.Purchase) error {
+	text := s.translation.GetText(customer.Language, "subscription_activated")
+	keyboard := models.InlineKeyboardMarkup{
+		InlineKeyboard: s.withReceiptButton(customer, purchase, s.createConnectKeyboard(customer)),
+	}
+
+	if messageID, ok := s.purchaseMessageID(purchase); ok {
+		_, err := s.telegramBot.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:      customer.TelegramID,
+			MessageID:   messageID,
+			Text:        text,
+			ReplyMarkup: keyboard,
+		})
+		if err == nil {
+			return nil
+		}
+		slog.WarnContext(ctx, "Error editing payment message, sending new one", "error", err, "purchase_id", utils.MaskHalfInt64(purchase.ID))
+	}
+
+	_, err := s.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      customer.TelegramID,
+		Text:        text,
+		ReplyMarkup: keyboard,
+	})
+	return err
+}
+
+// sendGiftDelivered сообщает получателю о подаренной подписке, а плательщику — что подарок доставлен.
+// Сообщение со ссылкой на оплату находится в чате плательщика, поэтому заменяется подтверждением для него
+func (s PaymentService) sendGiftDelivered(ctx context.Context, recipient *database.Customer, purchase *database.Purchase, expireAt time.Time) {
+	from := s.translation.GetText(recipient.Language, "gift_sender_unknown")
+	if username, _ := ctx.Value("username").(string); username != "" {
+		from = "@" + username
+	}
+	_, err := s.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    recipient.TelegramID,
+		ParseMode: models.ParseModeHTML,
+		Text: s.translation.GetTextTemplate(recipient.Language, "gift_received", map[string]interface{}{
+			"from":   from,
+			"expire": locale.FormatDate(recipient.Language, expireAt),
+		}),
+		ReplyMarkup: models.InlineKeyboardMarkup{InlineKeyboard: s.createConnectKeyboard(recipient)},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending gift received message", "error", err, "purchase_id", utils.MaskHalfInt64(purchase.ID))
+	}
+
+	payer, err := s.customerRepository.FindById(ctx, *purchase.GiftFromCustomerID)
+	if err != nil || payer == nil {
+		slog.ErrorContext(ctx, "Error finding gift payer", "error", err, "purchase_id", utils.MaskHalfInt64(purchase.ID))
+		return
+	}
+	text := s.translation.GetTextTemplate(payer.Language, "gift_delivered", map[string]interface{}{
+		"expire": locale.FormatDate(payer.Language, expireAt),
+	})
+	keyboard := models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{
+		{{Text: s.translation.GetText(payer.Language, "back_button"), CallbackData: "start"}},
+	}}
+	if messageID, ok := s.purchaseMessageID(purchase); ok {
+		_, err := s.telegramBot.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:      payer.TelegramID,
+			MessageID:   messageID,
+			Text:        text,
+			ParseMode:   models.ParseModeHTML,
+			ReplyMarkup: keyboard,
+		})
+		if err == nil {
+			return
+		}
+		slog.WarnContext(ctx, "Error editing payment message, sending new one", "error", err, "purchase_id", utils.MaskHalfInt64(purchase.ID))
+	}
+	_, err = s.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      payer.TelegramID,
+		Text:        text,
+		ParseMode:   models.ParseModeHTML,
+		ReplyMarkup: keyboard,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending gift delivered message", "error", err, "purchase_id", utils.MaskHalfInt64(purchase.ID))
+	}
+}
+
+// withReceiptButton добавляет перед кнопкой «Назад» кнопку получения квитанции по оплаченной покупке
+func (s PaymentService) withReceiptButton(customer *database.Customer, purchase *database.Purchase, keyboard [][]models.InlineKeyboardButton) [][]models.InlineKeyboardButton {
+	if !config.IsReceiptsEnabled() || purchase.IsTest || len(keyboard) == 0 {
+		return keyboard
+	}
+	receiptRow := []models.InlineKeyboardButton{
+		{Text: s.translation.GetText(customer.Language, "receipt_button"), CallbackData: fmt.Sprintf("receipt?id=%d", purchase.ID)},
+	}
+	last := len(keyboard) - 1
+	result := append(keyboard[:last:last], receiptRow)
+	return append(result, keyboard[last])
+}
+
 func (s PaymentService) createConnectKeyboard(customer *database.Customer) [][]models.InlineKeyboardButton {
 	var inlineCustomerKeyboard [][]models.InlineKeyboardButton
 
@@ -302,24 +600,64 @@ func (s PaymentService) CreatePurchaseWithTariff(ctx context.Context, amount flo
 // CreatePurchaseWithTariffAndDeviceLimit создаёт покупку с указанным тарифом и лимитом устройств
 // deviceLimit используется для winback предложений
 func (s PaymentService) CreatePurchaseWithTariffAndDeviceLimit(ctx context.Context, amount float64, months int, customer *database.Customer, invoiceType database.InvoiceType, tariffName *string, deviceLimit *int) (url string, purchaseId int64, err error) {
+	return s.createPeriodPurchase(ctx, amount, config.MonthsPeriod(months), customer, invoiceType, tariffName, deviceLimit)
+}
+
+func (s PaymentService) createPeriodPurchase(ctx context.Context, amount float64, period config.Period, customer *database.Customer, invoiceType database.InvoiceType, tariffName *string, deviceLimit *int) (url string, purchaseId int64, err error) {
+	if err := checkPaymentAmount(ctx, invoiceType, amount, period, tariffName); err != nil {
+		return "", 0, err
+	}
 	switch invoiceType {
 	case database.InvoiceTypeCrypto:
-		return s.createCryptoInvoice(ctx, amount, months, customer, tariffName, deviceLimit)
+		url, purchaseId, err = s.createCryptoInvoice(ctx, amount, period, customer, tariffName, deviceLimit)
 	case database.InvoiceTypeYookasa:
-		return s.createYookasaInvoice(ctx, amount, months, customer, tariffName, deviceLimit)
+		url, purchaseId, err = s.createYookasaInvoice(ctx, amount, period, customer, tariffName, deviceLimit)
 	case database.InvoiceTypeTelegram:
-		return s.createTelegramInvoice(ctx, amount, months, customer, tariffName, deviceLimit)
+		url, purchaseId, err = s.createTelegramInvoice(ctx, amount, period, customer, tariffName, deviceLimit)
 	case database.InvoiceTypeTribute:
-		return s.createTributeInvoice(ctx, amount, months, customer, tariffName, deviceLimit)
+		// Подписка Tribute продлевается помесячно на стороне Tribute, посуточных планов там нет
+		if period.IsDays() {
+			return "", 0, ErrPeriodNotSupported
+		}
+		url, purchaseId, err = s.createTributeInvoice(ctx, amount, period, customer, tariffName, deviceLimit)
 	default:
 		return "", 0, fmt.Errorf("unknown invoice type: %s", invoiceType)
 	}
+	if err == nil {
+		publishPaymentCreated(ctx, customer, purchaseId, amount, period, invoiceType, tariffName)
+	}
+	return url, purchaseId, err
+}
+
+// publishPaymentCreated публикует созданный счёт для продуктовой аналитики
+func publishPaymentCreated(ctx context.Context, customer *database.Customer, purchaseId int64, amount float64, period config.Period, invoiceType database.InvoiceType, tariffName *string) {
+	if purchaseId == 0 {
+		return
+	}
+	currency := "RUB"
+	if invoiceType == database.InvoiceTypeTelegram {
+		currency = "STARS"
+	}
+	checkout := events.Checkout{
+		PurchaseID:  purchaseId,
+		CustomerID:  customer.ID,
+		TelegramID:  customer.TelegramID,
+		Amount:      amount,
+		Currency:    currency,
+		InvoiceType: string(invoiceType),
+		Months:      period.Months,
+		Days:        period.Days,
+	}
+	if tariffName != nil {
+		checkout.TariffName = *tariffName
+	}
+	events.Publish(ctx, events.PaymentCreated(checkout))
 }
 
 var ErrCustomerNotFound = errors.New("customer not found")
 
 func (s PaymentService) CancelTributePurchase(ctx context.Context, telegramId int64) error {
-	slog.Info("Canceling tribute purchase", "telegram_id", utils.MaskHalfInt64(telegramId))
+	slog.InfoContext(ctx, "Canceling tribute purchase", "telegram_id", utils.MaskHalfInt64(telegramId))
 	customer, err := s.customerRepository.FindByTelegramId(ctx, telegramId)
 	if err != nil {
 		return err
@@ -334,7 +672,7 @@ func (s PaymentService) CancelTributePurchase(ctx context.Context, telegramId in
 	if tributePurchase == nil {
 		return errors.New("tribute purchase not found")
 	}
-	expireAt, err := s.remnawaveClient.DecreaseSubscription(ctx, telegramId, config.TrafficLimit(), -tributePurchase.Month*config.DaysInMonth())
+	expireAt, err := s.remnawaveClient.DecreaseSubscription(ctx, telegramId, config.TrafficLimit(), -tributePurchase.Period().TotalDays())
 	if err != nil {
 		return err
 	}
@@ -356,40 +694,340 @@ func (s PaymentService) CancelTributePurchase(ctx context.Context, telegramId in
 		Text:      s.translation.GetText(customer.Language, "tribute_cancelled"),
 	})
 	if err != nil {
-		slog.Error("Error sending message about tribute cancelled", "error", err, "telegram_id", utils.MaskHalfInt64(telegramId))
+		slog.ErrorContext(ctx, "Error sending message about tribute cancelled", "error", err, "telegram_id", utils.MaskHalfInt64(telegramId))
+	}
+	slog.InfoContext(ctx, "Canceled tribute purchase", "purchase_id", utils.MaskHalfInt64(tributePurchase.ID), "telegram_id", utils.MaskHalfInt64(telegramId))
+	return nil
+}
+
+// SavePurchaseMessage запоминает сообщение со ссылкой на оплату: после оплаты оно заменяется
+// на сообщение об активации подписки, а при истечении счёта — на "счёт истёк"
+func (s PaymentService) SavePurchaseMessage(ctx context.Context, purchaseID int64, messageID int) {
+	s.cache.Set(purchaseID, messageID)
+	if err := s.purchaseRepository.UpdateFields(ctx, purchaseID, map[string]interface{}{
+		"message_id": messageID,
+	}); err != nil {
+		slog.ErrorContext(ctx, "Error saving purchase message", "error", err, "purchase_id", utils.MaskHalfInt64(purchaseID))
+	}
+}
+
+// SaveProviderPayment запоминает идентификатор платежа и способ оплаты по данным провайдера.
+// Пустые значения не перезаписывают сохранённые
+func (s PaymentService) SaveProviderPayment(ctx context.Context, purchaseID int64, providerPaymentID, methodType string) {
+	updates := map[string]interface{}{}
+	if providerPaymentID != "" {
+		updates["provider_payment_id"] = providerPaymentID
+	}
+	if methodType != "" {
+		updates["payment_method_type"] = methodType
+	}
+	if len(updates) == 0 {
+		return
+	}
+	if err := s.purchaseRepository.UpdateFields(ctx, purchaseID, updates); err != nil {
+		slog.ErrorContext(ctx, "Error saving provider payment", "error", err, "purchase_id", utils.MaskHalfInt64(purchaseID))
+	}
+}
+
+// provisionedDeviceLimit лимит устройств, который получит клиент по покупке: явный лимит предложения
+// или лимит тарифа на момент создания счёта. nil — лимит по умолчанию из панели
+func provisionedDeviceLimit(tariffName *string, deviceLimit *int) *int {
+	if deviceLimit != nil {
+		return deviceLimit
+	}
+	if tariffName == nil || *tariffName == "" {
+		return nil
+	}
+	if tariff := config.GetTariffByName(*tariffName); tariff != nil {
+		devices := tariff.Devices
+		return &devices
+	}
+	return nil
+}
+
+// purchaseMessageID возвращает сообщение со ссылкой на оплату из кеша, а после перезапуска — из БД
+func (s PaymentService) purchaseMessageID(purchase *database.Purchase) (int, bool) {
+	if messageID, ok := s.cache.Get(purchase.ID); ok {
+		return messageID, true
+	}
+	if purchase.MessageID != nil {
+		return *purchase.MessageID, true
+	}
+	return 0, false
+}
+
+// expiringInvoiceTypes типы счетов, которые проверяются по расписанию и истекают по INVOICE_TTL_MINUTES
+var expiringInvoiceTypes = []database.InvoiceType{database.InvoiceTypeCrypto, database.InvoiceTypeYookasa}
+
+// invoiceExpiresIn возвращает срок жизни счёта CryptoPay в секундах (nil — без ограничения)
+func invoiceExpiresIn() *int {
+	if config.GetInvoiceTTLMinutes() <= 0 {
+		return nil
+	}
+	seconds := config.GetInvoiceTTLMinutes() * 60
+	return &seconds
+}
+
+// ExpireStalePurchases переводит неоплаченные дольше ttl счета в статус expired, после чего они
+// больше не проверяются. Счёт CryptoPay истекает сам (expires_in), платёж ЮKassa перед истечением
+// перепроверяется и при необходимости отменяется. Сообщение с оплатой заменяется на "счёт истёк"
+func (s PaymentService) ExpireStalePurchases(ctx context.Context, ttl time.Duration) (int, error) {
+	purchases, err := s.purchaseRepository.FindStalePending(ctx, expiringInvoiceTypes, clock.Now().Add(-ttl), 100)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range purchases {
+		purchase := &purchases[i]
+		if purchase.InvoiceType == database.InvoiceTypeYookasa && !s.releaseYookasaPayment(ctx, purchase) {
+			continue
+		}
+
+		ok, err := s.purchaseRepository.ExpireIfPending(ctx, purchase.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error expiring purchase", "error", err, "purchase_id", utils.MaskHalfInt64(purchase.ID))
+			continue
+		}
+		if !ok {
+			continue
+		}
+		expired++
+		s.showInvoiceExpired(ctx, purchase)
+	}
+
+	return expired, nil
+}
+
+// releaseYookasaPayment проверяет платёж перед истечением. Возвращает false если платёж ещё может быть
+// оплачен — тогда его обработает обычная проверка инвойсов. Платёж в статусе pending через API не отменить:
+// он остаётся в ожидании, пока ЮKassa не отменит его сама, иначе оплату после INVOICE_TTL_MINUTES
+// никто бы не выдал
+func (s PaymentService) releaseYookasaPayment(ctx context.Context, purchase *database.Purchase) bool {
+	if purchase.YookasaID == nil {
+		return true
+	}
+
+	client := s.YookasaClientFor(purchase)
+	invoice, err := client.GetPayment(ctx, *purchase.YookasaID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error getting yookasa payment before expiration", "error", err, "purchase_id", utils.MaskHalfInt64(purchase.ID))
+		return false
+	}
+	if invoice.Paid || invoice.IsSucceeded() {
+		return false
+	}
+	if invoice.IsWaitingForCapture() {
+		if _, err := client.CancelPayment(ctx, invoice.ID); err != nil {
+			slog.ErrorContext(ctx, "Error cancelling yookasa payment", "error", err, "purchase_id", utils.MaskHalfInt64(purchase.ID))
+			return false
+		}
+		return true
+	}
+	return invoice.IsCancelled()
+}
+
+// showInvoiceExpired заменяет сообщение со ссылкой на оплату на "счёт истёк" с кнопкой создания нового
+func (s PaymentService) showInvoiceExpired(ctx context.Context, purchase *database.Purchase) {
+	messageID, ok := s.purchaseMessageID(purchase)
+	if !ok {
+		return
+	}
+
+	customer, err := s.customerRepository.FindById(ctx, purchase.CustomerID)
+	if err != nil || customer == nil {
+		slog.ErrorContext(ctx, "Error finding customer for expired invoice", "error", err, "purchase_id", utils.MaskHalfInt64(purchase.ID))
+		return
+	}
+
+	_, err = s.telegramBot.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    customer.TelegramID,
+		MessageID: messageID,
+		ParseMode: models.ParseModeHTML,
+		Text:      s.translation.GetText(customer.Language, "invoice_expired"),
+		ReplyMarkup: models.InlineKeyboardMarkup{
+			InlineKeyboard: [][]models.InlineKeyboardButton{
+				{{Text: s.translation.GetText(customer.Language, "invoice_recreate_button"), CallbackData: recreateInvoiceCallback(purchase)}},
+			},
+		},
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error updating expired invoice message", "error", err, "purchase_id", utils.MaskHalfInt64(purchase.ID))
+	}
+}
+
+// recreateInvoiceCallback возвращает callback выбора способа оплаты для того же тарифа и периода.
+// Для командной подписки — к выбору срока для того же количества мест,
+// для покупок по специальным предложениям (winback, промо-тариф) — к списку тарифов
+func recreateInvoiceCallback(purchase *database.Purchase) string {
+	if purchase.TeamSeats != nil {
+		return fmt.Sprintf("team_seats?s=%d", *purchase.TeamSeats)
+	}
+	if purchase.DeviceLimit != nil {
+		return "buy"
+	}
+	if purchase.TariffName != nil && *purchase.TariffName != "" {
+		return fmt.Sprintf("sell?month=%s&tariff=%s", purchase.Period(), *purchase.TariffName)
+	}
+	return fmt.Sprintf("sell?month=%s", purchase.Period())
+}
+
+var ErrPurchaseNotFound = errors.New("purchase not found")
+
+// ProcessYookassaRefund обрабатывает возврат по платежу ЮKassa, в том числе частичный или по chargeback:
+// сокращает оплаченный период пропорционально сумме возврата, отмечает покупку, уведомляет пользователя и админа.
+// Учитывается общая сумма возвратов платежа, поэтому повторные уведомления ничего не меняют
+func (s PaymentService) ProcessYookassaRefund(ctx context.Context, invoice *yookasa.Payment) error {
+	purchase, err := s.purchaseRepository.FindByYookasaID(ctx, invoice.ID)
+	if err != nil {
+		return err
+	}
+	if purchase == nil {
+		return ErrPurchaseNotFound
+	}
+
+	refunded := math.Min(invoice.RefundedValue(), purchase.Amount)
+	if purchase.Status != database.PurchaseStatusPaid || refunded <= purchase.RefundedAmount {
+		slog.InfoContext(ctx, "Refund already processed", "purchase_id", utils.MaskHalfInt64(purchase.ID), "status", purchase.Status)
+		return nil
+	}
+
+	customer, err := s.customerRepository.FindById(ctx, purchase.CustomerID)
+	if err != nil {
+		return err
+	}
+	if customer == nil {
+		return ErrCustomerNotFound
+	}
+
+	full := refunded >= purchase.Amount
+	// Возврат фиксируется до обращения к панели: повтор webhook и параллельная проверка не сократят подписку дважды
+	previous, claimed, err := s.purchaseRepository.ClaimRefund(ctx, purchase.ID, refunded, full)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		slog.InfoContext(ctx, "Refund already processed", "purchase_id", utils.MaskHalfInt64(purchase.ID))
+		return nil
+	}
+	purchase.RefundedAmount = previous
+
+	releaseClaim := func() {
+		if releaseErr := s.purchaseRepository.ReleaseRefund(context.WithoutCancel(ctx), purchase.ID, refunded, previous); releaseErr != nil {
+			slog.ErrorContext(ctx, "Error releasing refund claim", "error", releaseErr, "purchase_id", utils.MaskHalfInt64(purchase.ID))
+		}
+	}
+
+	periodDays := purchase.Period().TotalDays()
+	days := refundClawbackDays(periodDays, purchase.Amount, refunded) - refundClawbackDays(periodDays, purchase.Amount, previous)
+	// Командная подписка не продлевала подписку плательщика: сокращать нечего. При полном возврате
+	// команда отменяется, при частичном места участников остаются
+	if purchase.TeamSeats != nil {
+		days = 0
+		if full {
+			if err := s.cancelRefundedTeam(ctx, purchase); err != nil {
+				releaseClaim()
+				return err
+			}
+		}
+	}
+
+	if days > 0 {
+		// Списание не включает отключённого пользователя и может завершить подписку сразу
+		expireAt, err := s.remnawaveClient.ClawbackSubscription(ctx, customer.TelegramID, days)
+		if err != nil {
+			releaseClaim()
+			return err
+		}
+		if err := s.customerRepository.UpdateFields(ctx, customer.ID, map[string]interface{}{
+			"expire_at": expireAt,
+		}); err != nil {
+			return err
+		}
 	}
-	slog.Info("Canceled tribute purchase", "purchase_id", utils.MaskHalfInt64(tributePurchase.ID), "telegram_id", utils.MaskHalfInt64(telegramId))
+
+	events.Publish(ctx, events.PaymentRefunded(events.Refund{
+		PurchaseID:     purchase.ID,
+		CustomerID:     customer.ID,
+		TelegramID:     customer.TelegramID,
+		Amount:         purchase.Amount,
+		RefundedAmount: refunded,
+		Currency:       purchase.Currency,
+		Full:           full,
+		ClawbackDays:   days,
+	}))
+
+	// Карта, по которой полностью вернули деньги, не должна списываться автопродлением
+	if full && customer.RecurringEnabled {
+		if err := s.customerRepository.DisableRecurring(ctx, customer.ID); err != nil {
+			slog.ErrorContext(ctx, "Error disabling recurring after refund", "error", err, "customer_id", utils.MaskHalfInt64(customer.ID))
+		}
+	}
+
+	_, err = s.telegramBot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    customer.TelegramID,
+		ParseMode: models.ParseModeHTML,
+		Text: s.translation.GetTextTemplate(customer.Language, "payment_refunded", map[string]interface{}{
+			"amount": locale.FormatMoney(customer.Language, refunded-purchase.RefundedAmount),
+			"days":   days,
+		}),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error sending message about refund", "error", err, "telegram_id", utils.MaskHalfInt64(customer.TelegramID))
+	}
+
+	alert.Notify(ctx, alert.SeverityWarning, fmt.Sprintf("%s:%d", alert.KeyPaymentRefund, purchase.ID),
+		fmt.Sprintf("Возврат по платежу ЮKassa %s: клиент %d (telegram %d), покупка %d, возвращено %.2f из %.2f ₽, подписка сокращена на %d дн.",
+			invoice.ID, customer.ID, customer.TelegramID, purchase.ID, refunded, purchase.Amount, days))
+
+	slog.InfoContext(ctx, "Processed yookassa refund", "purchase_id", utils.MaskHalfInt64(purchase.ID), "refunded", refunded, "days", days, "full", full)
 	return nil
 }
 
-func (s PaymentService) createCryptoInvoice(ctx context.Context, amount float64, months int, customer *database.Customer, tariffName *string, deviceLimit *int) (url string, purchaseId int64, err error) {
+// refundClawbackDays возвращает количество дней оплаченного периода, соответствующее сумме возврата
+func refundClawbackDays(periodDays int, amount, refunded float64) int {
+	if amount <= 0 || refunded <= 0 {
+		return 0
+	}
+	if refunded >= amount {
+		return periodDays
+	}
+	return int(math.Round(float64(periodDays) * refunded / amount))
+}
+
+func (s PaymentService) createCryptoInvoice(ctx context.Context, amount float64, period config.Period, customer *database.Customer, tariffName *string, deviceLimit *int) (url string, purchaseId int64, err error) {
 	purchaseId, err = s.purchaseRepository.Create(ctx, &database.Purchase{
-		InvoiceType: database.InvoiceTypeCrypto,
-		Status:      database.PurchaseStatusNew,
-		Amount:      amount,
-		Currency:    "RUB",
-		CustomerID:  customer.ID,
-		Month:       months,
-		TariffName:  tariffName,
-		DeviceLimit: deviceLimit,
+		InvoiceType:            database.InvoiceTypeCrypto,
+		Status:                 database.PurchaseStatusNew,
+		Amount:                 amount,
+		Currency:               "RUB",
+		CustomerID:             customer.ID,
+		Month:                  period.Months,
+		PeriodDays:             purchasePeriodDays(period),
+		TariffName:             tariffName,
+		DeviceLimit:            deviceLimit,
+		Source:                 customer.Source,
+		ProvisionedDeviceLimit: provisionedDeviceLimit(tariffName, deviceLimit),
+		ExternalSquadUUID:      offerExternalSquad(customer, amount, period.Months, deviceLimit),
 	})
 	if err != nil {
-		slog.Error("Error creating purchase", "error", err)
+		slog.ErrorContext(ctx, "Error creating purchase", "error", err)
 		return "", 0, err
 	}
 
-	invoice, err := s.cryptoPayClient.CreateInvoice(&cryptopay.InvoiceRequest{
+	invoice, err := s.cryptoPayClient.CreateInvoice(ctx, &cryptopay.InvoiceRequest{
 		CurrencyType:   "fiat",
 		Fiat:           "RUB",
 		Amount:         fmt.Sprintf("%d", int(amount)),
 		AcceptedAssets: "USDT",
 		Payload:        fmt.Sprintf("purchaseId=%d&username=%s", purchaseId, ctx.Value("username")),
-		Description:    fmt.Sprintf("Subscription on %d month", months),
+		Description:    cryptoInvoiceDescription(period),
 		PaidBtnName:    "callback",
 		PaidBtnUrl:     config.BotURL(),
+		ExpiresIn:      invoiceExpiresIn(),
 	})
 	if err != nil {
-		slog.Error("Error creating invoice", "error", err)
+		slog.ErrorContext(ctx, "Error creating invoice", "error", err)
 		return "", 0, err
 	}
 
@@ -398,34 +1036,47 @@ func (s PaymentService) createCryptoInvoice(ctx context.Context, amount float64,
 		"crypto_invoice_id":  invoice.InvoiceID,
 		"status":             database.PurchaseStatusPending,
 	}
+	if invoice.InvoiceID != nil {
+		updates["provider_payment_id"] = strconv.FormatInt(*invoice.InvoiceID, 10)
+	}
 
 	err = s.purchaseRepository.UpdateFields(ctx, purchaseId, updates)
 	if err != nil {
-		slog.Error("Error updating purchase", "error", err)
+		slog.ErrorContext(ctx, "Error updating purchase", "error", err)
 		return "", 0, err
 	}
 
 	return invoice.BotInvoiceUrl, purchaseId, nil
 }
 
-func (s PaymentService) createYookasaInvoice(ctx context.Context, amount float64, months int, customer *database.Customer, tariffName *string, deviceLimit *int) (url string, purchaseId int64, err error) {
-	return s.createYookasaInvoiceWithRecurring(ctx, amount, months, customer, tariffName, deviceLimit, false)
+func (s PaymentService) createYookasaInvoice(ctx context.Context, amount float64, period config.Period, customer *database.Customer, tariffName *string, deviceLimit *int) (url string, purchaseId int64, err error) {
+	return s.createYookasaInvoiceWithRecurring(ctx, amount, period, customer, tariffName, deviceLimit, false)
 }
 
 // createYookasaInvoiceWithRecurring создаёт платёж YooKassa с опциональным сохранением способа оплаты
-func (s PaymentService) createYookasaInvoiceWithRecurring(ctx context.Context, amount float64, months int, customer *database.Customer, tariffName *string, deviceLimit *int, savePaymentMethod bool) (url string, purchaseId int64, err error) {
+func (s PaymentService) createYookasaInvoiceWithRecurring(ctx context.Context, amount float64, period config.Period, customer *database.Customer, tariffName *string, deviceLimit *int, savePaymentMethod bool) (url string, purchaseId int64, err error) {
+	return s.createYookasaPayment(ctx, s.yookasaClient, amount, period, customer, tariffName, deviceLimit, savePaymentMethod, false)
+}
+
+// createYookasaPayment создаёт покупку и платёж в магазине client. Тестовые покупки создаются в тестовом магазине
+func (s PaymentService) createYookasaPayment(ctx context.Context, client *yookasa.Client, amount float64, period config.Period, customer *database.Customer, tariffName *string, deviceLimit *int, savePaymentMethod, isTest bool) (url string, purchaseId int64, err error) {
 	purchaseId, err = s.purchaseRepository.Create(ctx, &database.Purchase{
-		InvoiceType: database.InvoiceTypeYookasa,
-		Status:      database.PurchaseStatusNew,
-		Amount:      amount,
-		Currency:    "RUB",
-		CustomerID:  customer.ID,
-		Month:       months,
-		TariffName:  tariffName,
-		DeviceLimit: deviceLimit,
+		InvoiceType:            database.InvoiceTypeYookasa,
+		Status:                 database.PurchaseStatusNew,
+		Amount:                 amount,
+		Currency:               "RUB",
+		CustomerID:             customer.ID,
+		Month:                  period.Months,
+		PeriodDays:             purchasePeriodDays(period),
+		TariffName:             tariffName,
+		DeviceLimit:            deviceLimit,
+		Source:                 customer.Source,
+		ProvisionedDeviceLimit: provisionedDeviceLimit(tariffName, deviceLimit),
+		ExternalSquadUUID:      offerExternalSquad(customer, amount, period.Months, deviceLimit),
+		IsTest:                 isTest,
 	})
 	if err != nil {
-		slog.Error("Error creating purchase", "error", err)
+		slog.ErrorContext(ctx, "Error creating purchase", "error", err)
 		return "", 0, err
 	}
 
@@ -438,55 +1089,222 @@ func (s PaymentService) createYookasaInvoiceWithRecurring(ctx context.Context, a
 	// Определяем сумму для recurring (та же что и текущий платёж)
 	recurringAmount := int(amount)
 
+	// Чек уходит на email клиента из профиля, если он указан
+	if customer.Email != nil {
+		ctx = yookasa.WithReceiptEmail(ctx, *customer.Email)
+	}
+
 	var invoice *yookasa.Payment
 	if savePaymentMethod {
-		invoice, err = s.yookasaClient.CreateInvoiceWithSave(ctx, int(amount), months, customer.ID, purchaseId, true, tariffNameStr, recurringAmount)
+		invoice, err = client.CreatePeriodInvoice(ctx, int(amount), period, customer.ID, purchaseId, true, tariffNameStr, recurringAmount)
 	} else {
-		invoice, err = s.yookasaClient.CreateInvoice(ctx, int(amount), months, customer.ID, purchaseId)
+		invoice, err = client.CreatePeriodInvoice(ctx, int(amount), period, customer.ID, purchaseId, false, "", 0)
 	}
 	if err != nil {
-		slog.Error("Error creating invoice", "error", err)
+		slog.ErrorContext(ctx, "Error creating invoice", "error", err)
 		return "", 0, err
 	}
 
 	updates := map[string]interface{}{
-		"yookasa_url": invoice.Confirmation.ConfirmationURL,
-		"yookasa_id":  invoice.ID,
-		"status":      database.PurchaseStatusPending,
+		"yookasa_url":         invoice.Confirmation.ConfirmationURL,
+		"yookasa_id":          invoice.ID,
+		"provider_payment_id": invoice.ID.String(),
+		"status":              database.PurchaseStatusPending,
 	}
 
 	err = s.purchaseRepository.UpdateFields(ctx, purchaseId, updates)
 	if err != nil {
-		slog.Error("Error updating purchase", "error", err)
+		slog.ErrorContext(ctx, "Error updating purchase", "error", err)
 		return "", 0, err
 	}
 
 	return invoice.Confirmation.ConfirmationURL, purchaseId, nil
 }
 
+// ErrPurchaseLimitExceeded пользователь превысил лимит покупок за сутки (MAX_PURCHASES_PER_DAY)
+var ErrPurchaseLimitExceeded = errors.New("purchase limit exceeded")
+
+// ErrPeriodNotSupported посуточный план нельзя оплатить этим способом
+var ErrPeriodNotSupported = errors.New("period not supported by payment method")
+
 // CreatePurchaseWithRecurring создаёт покупку с опциональным сохранением способа оплаты для автопродления
 func (s PaymentService) CreatePurchaseWithRecurring(ctx context.Context, amount float64, months int, customer *database.Customer, invoiceType database.InvoiceType, tariffName *string, deviceLimit *int, savePaymentMethod bool) (url string, purchaseId int64, err error) {
+	return s.CreatePeriodPurchase(ctx, amount, config.MonthsPeriod(months), customer, invoiceType, tariffName, deviceLimit, savePaymentMethod)
+}
+
+// CreatePeriodPurchase как CreatePurchaseWithRecurring, но на произвольный период: месяцы или посуточный план тарифа.
+// Автопродление посуточного плана списывает ту же сумму каждые period.Days дней
+func (s PaymentService) CreatePeriodPurchase(ctx context.Context, amount float64, period config.Period, customer *database.Customer, invoiceType database.InvoiceType, tariffName *string, deviceLimit *int, savePaymentMethod bool) (url string, purchaseId int64, err error) {
+	if err := s.checkPurchaseVelocity(ctx, customer); err != nil {
+		return "", 0, err
+	}
+
 	// Сохранение способа оплаты поддерживается только для YooKassa
 	if invoiceType == database.InvoiceTypeYookasa && savePaymentMethod {
-		return s.createYookasaInvoiceWithRecurring(ctx, amount, months, customer, tariffName, deviceLimit, true)
+		if err := checkPaymentAmount(ctx, invoiceType, amount, period, tariffName); err != nil {
+			return "", 0, err
+		}
+		url, purchaseId, err = s.createYookasaInvoiceWithRecurring(ctx, amount, period, customer, tariffName, deviceLimit, true)
+		if err == nil {
+			publishPaymentCreated(ctx, customer, purchaseId, amount, period, invoiceType, tariffName)
+		}
+		return url, purchaseId, err
 	}
 	// Для остальных типов используем стандартный метод
-	return s.CreatePurchaseWithTariffAndDeviceLimit(ctx, amount, months, customer, invoiceType, tariffName, deviceLimit)
+	return s.createPeriodPurchase(ctx, amount, period, customer, invoiceType, tariffName, deviceLimit)
 }
 
-func (s PaymentService) createTelegramInvoice(ctx context.Context, amount float64, months int, customer *database.Customer, tariffName *string, deviceLimit *int) (url string, purchaseId int64, err error) {
+// CreateTeamPurchase создаёт счёт на командную подписку: seats мест на months месяцев.
+// Автопродление и Tribute для команд не поддерживаются
+func (s PaymentService) CreateTeamPurchase(ctx context.Context, amount float64, months, seats int, customer *database.Customer, invoiceType database.InvoiceType) (url string, purchaseId int64, err error) {
+	if err := s.checkPurchaseVelocity(ctx, customer); err != nil {
+		return "", 0, err
+	}
+
+	url, purchaseId, err = s.CreatePurchaseWithTariffAndDeviceLimit(ctx, amount, months, customer, invoiceType, nil, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	// Ссылка на оплату ещё не показана пользователю, поэтому отметка успевает до оплаты.
+	// При ошибке ссылка не отдаётся, и счёт без отметки оплатить нельзя
+	if err := s.purchaseRepository.UpdateFields(ctx, purchaseId, map[string]interface{}{
+		"team_seats": seats,
+	}); err != nil {
+		return "", 0, fmt.Errorf("mark team purchase: %w", err)
+	}
+	return url, purchaseId, nil
+}
+
+// CreateGiftPurchase создаёт счёт в звёздах, который payer оплачивает за recipient. Покупка записывается на получателя:
+// после оплаты продлевается его подписка, а плательщик сохраняется в gift_from_customer_id
+func (s PaymentService) CreateGiftPurchase(ctx context.Context, amount float64, period config.Period, payer, recipient *database.Customer, tariffName *string) (url string, purchaseId int64, err error) {
+	if err := s.checkPurchaseVelocity(ctx, payer); err != nil {
+		return "", 0, err
+	}
+
+	url, purchaseId, err = s.createPeriodPurchase(ctx, amount, period, recipient, database.InvoiceTypeTelegram, tariffName, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	// Как и у командной покупки, отметка ставится до того, как ссылка на оплату показана плательщику
+	if err := s.purchaseRepository.UpdateFields(ctx, purchaseId, map[string]interface{}{
+		"gift_from_customer_id": payer.ID,
+	}); err != nil {
+		return "", 0, fmt.Errorf("mark gift purchase: %w", err)
+	}
+	return url, purchaseId, nil
+}
+
+// ErrSandboxDisabled тестовые оплаты выключены (SANDBOX_PAYMENTS_ENABLED) или не настроен тестовый магазин
+var ErrSandboxDisabled = errors.New("sandbox payments disabled")
+
+// CreateSandboxPurchase создаёт тестовую покупку за 0 ₽ и сразу проводит её через полную выдачу подписки
+// (Remnawave, уведомления). Покупка помечается is_test и не попадает в статистику и сверку
+func (s PaymentService) CreateSandboxPurchase(ctx context.Context, period config.Period, customer *database.Customer, tariffName *string, deviceLimit *int) (int64, error) {
+	if !config.IsSandboxPaymentsEnabled() {
+		return 0, ErrSandboxDisabled
+	}
+
+	purchaseId, err := s.purchaseRepository.Create(ctx, &database.Purchase{
+		InvoiceType:            database.InvoiceTypeSandbox,
+		Status:                 database.PurchaseStatusPending,
+		Amount:                 0,
+		Currency:               "RUB",
+		CustomerID:             customer.ID,
+		Month:                  period.Months,
+		PeriodDays:             purchasePeriodDays(period),
+		TariffName:             tariffName,
+		DeviceLimit:            deviceLimit,
+		Source:                 customer.Source,
+		ProvisionedDeviceLimit: provisionedDeviceLimit(tariffName, deviceLimit),
+		IsTest:                 true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("create sandbox purchase: %w", err)
+	}
+
+	slog.WarnContext(ctx, "Sandbox purchase created", "purchaseId", purchaseId, "period", period.String())
+	if err := s.ProcessPurchaseById(ctx, purchaseId); err != nil {
+		return purchaseId, fmt.Errorf("process sandbox purchase: %w", err)
+	}
+	return purchaseId, nil
+}
+
+// CreateTestYookasaPurchase создаёт тестовую покупку с платежом в тестовом магазине ЮKassa.
+// Способ оплаты не сохраняется: автосписания идут через основной магазин
+func (s PaymentService) CreateTestYookasaPurchase(ctx context.Context, amount float64, period config.Period, customer *database.Customer, tariffName *string, deviceLimit *int) (url string, purchaseId int64, err error) {
+	if !config.IsYookasaTestShopEnabled() || s.yookasaTestClient == nil {
+		return "", 0, ErrSandboxDisabled
+	}
+	return s.createYookasaPayment(ctx, s.yookasaTestClient, amount, period, customer, tariffName, deviceLimit, false, true)
+}
+
+// ErrInvalidPaymentAmount сумма счёта вне лимитов платёжной системы
+var ErrInvalidPaymentAmount = errors.New("payment amount outside provider limits")
+
+// checkPaymentAmount не даёт создать счёт, который платёжная система отклонит, и сообщает админу
+// о неверно настроенной цене. Tribute оплачивается по своей ссылке, сумма счёта ему не передаётся
+func checkPaymentAmount(ctx context.Context, invoiceType database.InvoiceType, amount float64, period config.Period, tariffName *string) error {
+	if invoiceType == database.InvoiceTypeTribute || config.IsValidPaymentAmount(string(invoiceType), int(math.Floor(amount))) {
+		return nil
+	}
+
+	tariff := "без тарифа"
+	if tariffName != nil {
+		tariff = "тариф " + *tariffName
+	}
+	minAmount, maxAmount := config.PaymentAmountRange(string(invoiceType))
+	limits := fmt.Sprintf("минимум %d", minAmount)
+	if maxAmount > 0 {
+		limits += fmt.Sprintf(", максимум %d", maxAmount)
+	}
+	slog.WarnContext(ctx, "Payment amount outside provider limits", "invoiceType", invoiceType, "amount", amount, "min", minAmount, "max", maxAmount)
+	alert.Notify(ctx, alert.SeverityWarning, fmt.Sprintf("%s:%s:%v", alert.KeyInvalidPaymentAmount, invoiceType, amount),
+		fmt.Sprintf("Счёт не создан: сумма %v вне лимитов %s (%s), %s, %s. Проверьте цены и наценки способов оплаты",
+			amount, invoiceType, limits, tariff, periodLabel(period)))
+	return ErrInvalidPaymentAmount
+}
+
+// checkPurchaseVelocity проверяет лимит покупок пользователя за последние сутки
+// и при превышении сообщает админу (защита от перебора карт)
+func (s PaymentService) checkPurchaseVelocity(ctx context.Context, customer *database.Customer) error {
+	limit := config.GetMaxPurchasesPerDay()
+	if limit <= 0 {
+		return nil
+	}
+
+	count, err := s.purchaseRepository.CountCreatedSince(ctx, customer.ID, clock.Now().Add(-24*time.Hour))
+	if err != nil {
+		return fmt.Errorf("check purchase velocity: %w", err)
+	}
+	if count < limit {
+		return nil
+	}
+
+	slog.WarnContext(ctx, "Purchase limit exceeded", "customerId", utils.MaskHalfInt64(customer.ID), "count", count, "limit", limit)
+	alert.Notify(ctx, alert.SeverityWarning, fmt.Sprintf("%s:%d", alert.KeyPurchaseVelocity, customer.ID),
+		fmt.Sprintf("Превышен лимит покупок за сутки: клиент %d (telegram %d), покупок: %d, лимит: %d",
+			customer.ID, customer.TelegramID, count, limit))
+	return ErrPurchaseLimitExceeded
+}
+
+func (s PaymentService) createTelegramInvoice(ctx context.Context, amount float64, period config.Period, customer *database.Customer, tariffName *string, deviceLimit *int) (url string, purchaseId int64, err error) {
 	purchaseId, err = s.purchaseRepository.Create(ctx, &database.Purchase{
-		InvoiceType: database.InvoiceTypeTelegram,
-		Status:      database.PurchaseStatusNew,
-		Amount:      amount,
-		Currency:    "STARS",
-		CustomerID:  customer.ID,
-		Month:       months,
-		TariffName:  tariffName,
-		DeviceLimit: deviceLimit,
+		InvoiceType:            database.InvoiceTypeTelegram,
+		Status:                 database.PurchaseStatusNew,
+		Amount:                 amount,
+		Currency:               "STARS",
+		CustomerID:             customer.ID,
+		Month:                  period.Months,
+		PeriodDays:             purchasePeriodDays(period),
+		TariffName:             tariffName,
+		DeviceLimit:            deviceLimit,
+		Source:                 customer.Source,
+		ProvisionedDeviceLimit: provisionedDeviceLimit(tariffName, deviceLimit),
+		ExternalSquadUUID:      offerExternalSquad(customer, amount, period.Months, deviceLimit),
 	})
 	if err != nil {
-		slog.Error("Error creating purchase", "error", err)
+		slog.ErrorContext(ctx, "Error creating purchase", "error", err)
 		return "", 0, nil
 	}
 
@@ -509,7 +1327,7 @@ func (s PaymentService) createTelegramInvoice(ctx context.Context, amount float6
 
 	err = s.purchaseRepository.UpdateFields(ctx, purchaseId, updates)
 	if err != nil {
-		slog.Error("Error updating purchase", "error", err)
+		slog.ErrorContext(ctx, "Error updating purchase", "error", err)
 		return "", 0, err
 	}
 
@@ -522,21 +1340,38 @@ func (s PaymentService) ActivateTrial(ctx context.Context, telegramId int64) (st
 	}
 	customer, err := s.customerRepository.FindByTelegramId(ctx, telegramId)
 	if err != nil {
-		slog.Error("Error finding customer", "error", err)
+		slog.ErrorContext(ctx, "Error finding customer", "error", err)
 		return "", err
 	}
 	if customer == nil {
 		return "", fmt.Errorf("customer %d not found", telegramId)
 	}
-	user, err := s.remnawaveClient.CreateOrUpdateUser(ctx, customer.ID, telegramId, config.TrialTrafficLimit(), config.TrialDays(), true)
+	eligibility, err := s.CheckTrialEligibility(ctx, customer)
+	if err != nil {
+		return "", err
+	}
+	if !eligibility.Allowed {
+		return "", ErrTrialNotAvailable
+	}
+	// При синхронизации тегов триал помечается своим тегом и у пользователя, уже существующего в панели
+	panelCtx := ctx
+	if config.IsRemnawaveTagSyncEnabled() {
+		panelCtx = remnawave.WithTag(ctx, config.TrialRemnawaveTag())
+	}
+	user, err := s.remnawaveClient.CreateOrUpdateUser(panelCtx, customer.ID, telegramId, config.TrialTrafficLimit(), config.TrialDays(), true)
 	if err != nil {
-		slog.Error("Error creating user", "error", err)
+		slog.ErrorContext(ctx, "Error creating user", "error", err)
 		return "", err
 	}
 
 	customerFilesToUpdate := map[string]interface{}{
-		"subscription_link": user.GetSubscriptionUrl(),
-		"expire_at":         user.GetExpireAt(),
+		"subscription_link":  user.GetSubscriptionUrl(),
+		"expire_at":          user.GetExpireAt(),
+		"trial_activated_at": clock.Now(),
+		"trial_regranted_at": nil,
+	}
+	if config.IsRemnawaveTagSyncEnabled() {
+		customerFilesToUpdate["remnawave_tag"] = nullableTag(config.TrialRemnawaveTag())
 	}
 
 	err = s.customerRepository.UpdateFields(ctx, customer.ID, customerFilesToUpdate)
@@ -544,48 +1379,127 @@ func (s PaymentService) ActivateTrial(ctx context.Context, telegramId int64) (st
 		return "", err
 	}
 
+	expireAt := user.GetExpireAt()
+	events.Publish(ctx, events.TrialActivated(events.Trial{
+		CustomerID: customer.ID,
+		TelegramID: telegramId,
+		Days:       config.TrialDays(),
+		ExpireAt:   &expireAt,
+	}))
+
 	return user.GetSubscriptionUrl(), nil
 
 }
 
-func (s PaymentService) CancelYookassaPayment(purchaseId int64) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	purchase, err := s.purchaseRepository.FindById(ctx, purchaseId)
+func (s PaymentService) createTributeInvoice(ctx context.Context, amount float64, period config.Period, customer *database.Customer, tariffName *string, deviceLimit *int) (url string, purchaseId int64, err error) {
+	purchaseId, err = s.purchaseRepository.Create(ctx, &database.Purchase{
+		InvoiceType:            database.InvoiceTypeTribute,
+		Status:                 database.PurchaseStatusPending,
+		Amount:                 amount,
+		Currency:               "RUB",
+		CustomerID:             customer.ID,
+		Month:                  period.Months,
+		PeriodDays:             purchasePeriodDays(period),
+		TariffName:             tariffName,
+		DeviceLimit:            deviceLimit,
+		Source:                 customer.Source,
+		ProvisionedDeviceLimit: provisionedDeviceLimit(tariffName, deviceLimit),
+		ExternalSquadUUID:      offerExternalSquad(customer, amount, period.Months, deviceLimit),
+	})
 	if err != nil {
-		return err
+		slog.ErrorContext(ctx, "Error creating purchase", "error", err)
+		return "", 0, err
 	}
-	if purchase == nil {
-		return fmt.Errorf("purchase with crypto invoice id %s not found", utils.MaskHalfInt64(purchaseId))
+
+	return "", purchaseId, nil
+}
+
+// purchasePeriodDays значение period_days покупки: срок посуточного плана или nil для месяцев
+func purchasePeriodDays(period config.Period) *int {
+	if !period.IsDays() {
+		return nil
 	}
+	days := period.Days
+	return &days
+}
 
-	purchaseFieldsToUpdate := map[string]interface{}{
-		"status": database.PurchaseStatusCancel,
+// periodLabel срок покупки для сообщений админу: "3 мес." или "7 дн."
+func periodLabel(period config.Period) string {
+	if period.IsDays() {
+		return fmt.Sprintf("%d дн.", period.Days)
 	}
+	return fmt.Sprintf("%d мес.", period.Months)
+}
 
-	err = s.purchaseRepository.UpdateFields(ctx, purchaseId, purchaseFieldsToUpdate)
-	if err != nil {
-		return err
+// cryptoInvoiceDescription описание счёта CryptoPay
+func cryptoInvoiceDescription(period config.Period) string {
+	if period.IsDays() {
+		return fmt.Sprintf("Subscription on %d days", period.Days)
 	}
+	return fmt.Sprintf("Subscription on %d month", period.Months)
+}
 
-	return nil
+// purchaseRemnawaveTag тег панели для оплаченной покупки: winback, тариф покупки или REMNAWAVE_TAG
+func purchaseRemnawaveTag(purchase *database.Purchase, isWinbackPurchase bool) string {
+	if isWinbackPurchase && config.WinbackRemnawaveTag() != "" {
+		return config.WinbackRemnawaveTag()
+	}
+	if purchase.TariffName != nil {
+		if tag := remnawave.TariffTag(*purchase.TariffName); tag != "" {
+			return tag
+		}
+	}
+	return config.RemnawaveTag()
 }
 
-func (s PaymentService) createTributeInvoice(ctx context.Context, amount float64, months int, customer *database.Customer, tariffName *string, deviceLimit *int) (url string, purchaseId int64, err error) {
-	purchaseId, err = s.purchaseRepository.Create(ctx, &database.Purchase{
-		InvoiceType: database.InvoiceTypeTribute,
-		Status:      database.PurchaseStatusPending,
-		Amount:      amount,
-		Currency:    "RUB",
-		CustomerID:  customer.ID,
-		Month:       months,
-		TariffName:  tariffName,
-		DeviceLimit: deviceLimit,
-	})
-	if err != nil {
-		slog.Error("Error creating purchase", "error", err)
-		return "", 0, err
+// isWinbackOfferPurchase покупка с параметрами активного winback предложения клиента
+func isWinbackOfferPurchase(customer *database.Customer, amount float64, months int, deviceLimit *int) bool {
+	return database.HasActiveWinbackOffer(customer) &&
+		customer.WinbackOfferPrice != nil && int(amount) == *customer.WinbackOfferPrice &&
+		customer.WinbackOfferMonths != nil && months == *customer.WinbackOfferMonths &&
+		customer.WinbackOfferDevices != nil && deviceLimit != nil && *deviceLimit == *customer.WinbackOfferDevices
+}
+
+// isPromoTariffOfferPurchase покупка с параметрами активного промо-тарифа клиента
+func isPromoTariffOfferPurchase(customer *database.Customer, amount float64, months int, deviceLimit *int) bool {
+	return database.HasActivePromoOffer(customer) &&
+		customer.PromoOfferPrice != nil && int(amount) == *customer.PromoOfferPrice &&
+		customer.PromoOfferMonths != nil && months == *customer.PromoOfferMonths &&
+		customer.PromoOfferDevices != nil && deviceLimit != nil && *deviceLimit == *customer.PromoOfferDevices
+}
+
+// offerExternalSquad внешний сквад для покупки по спецпредложению клиента (WINBACK_EXTERNAL_SQUAD_UUID,
+// PROMO_TARIFF_EXTERNAL_SQUAD_UUID). Сохраняется в покупке при создании счёта; nil — сквад по умолчанию
+func offerExternalSquad(customer *database.Customer, amount float64, months int, deviceLimit *int) *uuid.UUID {
+	var squad uuid.UUID
+	switch {
+	case isWinbackOfferPurchase(customer, amount, months, deviceLimit):
+		squad = config.WinbackExternalSquadUUID()
+	case isPromoTariffOfferPurchase(customer, amount, months, deviceLimit):
+		squad = config.PromoTariffExternalSquadUUID()
+	}
+	if squad == uuid.Nil {
+		return nil
 	}
+	return &squad
+}
 
-	return "", purchaseId, nil
+// purchaseExternalSquad внешний сквад для выдачи покупки: сохранённый в покупке, иначе по предложению,
+// действующему при оплате (счета, созданные до настройки сквада). uuid.Nil — EXTERNAL_SQUAD_UUID
+func purchaseExternalSquad(customer *database.Customer, purchase *database.Purchase) uuid.UUID {
+	if purchase.ExternalSquadUUID != nil {
+		return *purchase.ExternalSquadUUID
+	}
+	if squad := offerExternalSquad(customer, purchase.Amount, purchase.Month, purchase.DeviceLimit); squad != nil {
+		return *squad
+	}
+	return uuid.Nil
+}
+
+// nullableTag пустой тег хранится как NULL, чтобы клиент не попадал в сегменты рассылки
+func nullableTag(tag string) *string {
+	if tag == "" {
+		return nil
+	}
+	return &tag
 }
@@ -0,0 +1,196 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/yookasa"
+)
+
+func TestRefundClawbackDays(t *testing.T) {
+	tests := []struct {
+		name       string
+		periodDays int
+		amount     float64
+		refunded   float64
+		want       int
+	}{
+		{"no refund", 90, 300, 0, 0},
+		{"partial refund", 90, 300, 100, 30},
+		{"rounded partial refund", 30, 299, 50, 5},
+		{"full refund", 30, 299, 299, 30},
+		{"refund above amount", 30, 299, 500, 30},
+		{"free purchase", 30, 0, 10, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refundClawbackDays(tt.periodDays, tt.amount, tt.refunded); got != tt.want {
+				t.Errorf("refundClawbackDays() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecreateInvoiceCallback(t *testing.T) {
+	tariff := "PRO"
+	devices := 2
+	seats := 5
+	week := 7
+
+	tests := []struct {
+		name     string
+		purchase database.Purchase
+		want     string
+	}{
+		{"legacy pricing", database.Purchase{Month: 3, Amount: 1200}, "sell?month=3"},
+		{"tariff", database.Purchase{Month: 1, Amount: 299, TariffName: &tariff}, "sell?month=1&tariff=PRO"},
+		{"day plan", database.Purchase{Amount: 99, PeriodDays: &week, TariffName: &tariff}, "sell?month=7d&tariff=PRO"},
+		{"special offer", database.Purchase{Month: 1, Amount: 100, DeviceLimit: &devices}, "buy"},
+		{"team", database.Purchase{Month: 3, Amount: 2990, TeamSeats: &seats}, "team_seats?s=5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := recreateInvoiceCallback(&tt.purchase); got != tt.want {
+				t.Errorf("recreateInvoiceCallback() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvisionedDeviceLimit(t *testing.T) {
+	devices := 3
+	unknown := "REMOVED"
+
+	if got := provisionedDeviceLimit(&unknown, &devices); got == nil || *got != 3 {
+		t.Errorf("Expected offer device limit 3, got %v", got)
+	}
+	if got := provisionedDeviceLimit(&unknown, nil); got != nil {
+		t.Errorf("Expected no limit for unknown tariff, got %d", *got)
+	}
+	if got := provisionedDeviceLimit(nil, nil); got != nil {
+		t.Errorf("Expected no limit without tariff, got %d", *got)
+	}
+}
+
+func TestReminderPaymentURL(t *testing.T) {
+	cryptoURL := "https://t.me/CryptoBot?start=inv"
+	yookasaURL := "https://yoomoney.ru/checkout/payments/v2/contract?orderId=1"
+
+	tests := []struct {
+		name     string
+		purchase database.Purchase
+		want     string
+	}{
+		{"crypto", database.Purchase{InvoiceType: database.InvoiceTypeCrypto, CryptoInvoiceLink: &cryptoURL}, cryptoURL},
+		{"yookasa", database.Purchase{InvoiceType: database.InvoiceTypeYookasa, YookasaURL: &yookasaURL}, yookasaURL},
+		{"yookasa without url", database.Purchase{InvoiceType: database.InvoiceTypeYookasa}, ""},
+		{"stars", database.Purchase{InvoiceType: database.InvoiceTypeTelegram}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reminderPaymentURL(&tt.purchase); got != tt.want {
+				t.Errorf("reminderPaymentURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// testWinbackSquad WINBACK_EXTERNAL_SQUAD_UUID тестовой конфигурации, PROMO_TARIFF_EXTERNAL_SQUAD_UUID не задан
+var testWinbackSquad = uuid.MustParse("6f1c2d3e-4b5a-4c7d-8e9f-0a1b2c3d4e5f")
+
+func TestPurchaseExternalSquad(t *testing.T) {
+	sentAt := time.Now().Add(-time.Hour)
+	expiresAt := time.Now().Add(time.Hour)
+	price, months, devices := 199, 3, 2
+	customer := &database.Customer{
+		WinbackOfferSentAt:    &sentAt,
+		WinbackOfferExpiresAt: &expiresAt,
+		WinbackOfferPrice:     &price,
+		WinbackOfferMonths:    &months,
+		WinbackOfferDevices:   &devices,
+		PromoOfferPrice:       &price,
+		PromoOfferExpiresAt:   &expiresAt,
+		PromoOfferMonths:      &months,
+		PromoOfferDevices:     &devices,
+	}
+	promoOnly := &database.Customer{
+		PromoOfferPrice:     &price,
+		PromoOfferExpiresAt: &expiresAt,
+		PromoOfferMonths:    &months,
+		PromoOfferDevices:   &devices,
+	}
+	stored := uuid.MustParse("0d5e8f6a-1b2c-4d3e-9f8a-7b6c5d4e3f2a")
+	otherDevices := 5
+
+	tests := []struct {
+		name     string
+		customer *database.Customer
+		purchase database.Purchase
+		want     uuid.UUID
+	}{
+		{"winback offer", customer, database.Purchase{Amount: 199, Month: 3, DeviceLimit: &devices}, testWinbackSquad},
+		{"other devices", customer, database.Purchase{Amount: 199, Month: 3, DeviceLimit: &otherDevices}, uuid.Nil},
+		{"promo tariff without squad", promoOnly, database.Purchase{Amount: 199, Month: 3, DeviceLimit: &devices}, uuid.Nil},
+		{"no offer", &database.Customer{}, database.Purchase{Amount: 199, Month: 3, DeviceLimit: &devices}, uuid.Nil},
+		{"stored squad wins", customer, database.Purchase{Amount: 199, Month: 3, DeviceLimit: &devices, ExternalSquadUUID: &stored}, stored},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := purchaseExternalSquad(tt.customer, &tt.purchase); got != tt.want {
+				t.Errorf("purchaseExternalSquad() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseYookasaPayment(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		paid       bool
+		want       bool
+		wantCancel bool
+	}{
+		// Счёт в pending ещё можно оплатить после истечения: покупка остаётся в проверке инвойсов
+		{"pending stays payable", "pending", false, false, false},
+		{"paid after expiry", "succeeded", true, false, false},
+		{"cancelled by yookasa", "canceled", false, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cancelled := false
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tt.status
+				if strings.HasSuffix(r.URL.Path, "/cancel") {
+					cancelled = true
+					status = "canceled"
+				}
+				_ = json.NewEncoder(w).Encode(yookasa.Payment{ID: uuid.New(), Status: status, Paid: tt.paid})
+			}))
+			defer srv.Close()
+
+			s := PaymentService{yookasaClient: yookasa.NewClient(srv.URL, "shop", "secret")}
+			paymentID := uuid.New()
+			got := s.releaseYookasaPayment(context.Background(), &database.Purchase{ID: 1, YookasaID: &paymentID})
+			if got != tt.want {
+				t.Errorf("releaseYookasaPayment() = %v, want %v", got, tt.want)
+			}
+			if cancelled != tt.wantCancel {
+				t.Errorf("cancelled = %v, want %v", cancelled, tt.wantCancel)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+// Причины, по которым триал сейчас недоступен
+const (
+	TrialDeniedUsed     = "used"
+	TrialDeniedCooldown = "cooldown"
+	TrialDeniedActive   = "active"
+)
+
+var ErrTrialNotAvailable = errors.New("trial not available")
+
+// TrialEligibility результат проверки политики повторного триала
+type TrialEligibility struct {
+	Allowed bool
+	// Reason одна из TrialDenied*, пустая если триал доступен
+	Reason string
+	// AvailableAt когда триал станет доступен (только для TrialDeniedCooldown)
+	AvailableAt *time.Time
+}
+
+// CheckTrialEligibility проверяет, может ли клиент сейчас активировать триал по политике TRIAL_POLICY
+func (s PaymentService) CheckTrialEligibility(ctx context.Context, customer *database.Customer) (TrialEligibility, error) {
+	var lastPaidAt *time.Time
+	if config.TrialPolicy() == config.TrialPolicyAfterLapse && customer.TrialActivatedAt != nil {
+		purchase, err := s.purchaseRepository.FindLastPaidPurchaseByCustomer(ctx, customer.ID)
+		if err != nil {
+			return TrialEligibility{}, err
+		}
+		if purchase != nil {
+			lastPaidAt = purchase.PaidAt
+		}
+	}
+	return trialEligibility(config.TrialPolicy(), config.TrialCooldownMonths(), customer, lastPaidAt, clock.Now()), nil
+}
+
+// trialEligibility применяет политику повторного триала. lastPaidAt — время последней оплаты клиента,
+// нужно только для политики after_lapse. Повторная выдача админом снимает ограничения политики,
+// но не даёт триал поверх действующей подписки
+func trialEligibility(policy string, cooldownMonths int, c *database.Customer, lastPaidAt *time.Time, now time.Time) TrialEligibility {
+	active := c.ExpireAt != nil && c.ExpireAt.After(now)
+	if c.TrialRegrantedAt != nil {
+		if active {
+			return TrialEligibility{Reason: TrialDeniedActive}
+		}
+		return TrialEligibility{Allowed: true}
+	}
+
+	switch policy {
+	case config.TrialPolicyCooldown:
+		if active {
+			return TrialEligibility{Reason: TrialDeniedActive}
+		}
+		if c.TrialActivatedAt != nil {
+			availableAt := c.TrialActivatedAt.AddDate(0, cooldownMonths, 0)
+			if now.Before(availableAt) {
+				return TrialEligibility{Reason: TrialDeniedCooldown, AvailableAt: &availableAt}
+			}
+		}
+	case config.TrialPolicyAfterLapse:
+		if active {
+			return TrialEligibility{Reason: TrialDeniedActive}
+		}
+		if c.TrialActivatedAt != nil && (lastPaidAt == nil || !lastPaidAt.After(*c.TrialActivatedAt)) {
+			return TrialEligibility{Reason: TrialDeniedUsed}
+		}
+	default:
+		// Один триал навсегда: клиенты с подпиской без отметки о триале тоже считаются использовавшими его
+		if c.TrialActivatedAt != nil || c.SubscriptionLink != nil {
+			return TrialEligibility{Reason: TrialDeniedUsed}
+		}
+	}
+	return TrialEligibility{Allowed: true}
+}
@@ -0,0 +1,62 @@
+package payment
+
+import (
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+func TestTrialEligibility(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	ago := func(days int) *time.Time {
+		at := now.AddDate(0, 0, -days)
+		return &at
+	}
+	link := "https://sub.example.com/abc"
+	active := now.Add(24 * time.Hour)
+
+	tests := []struct {
+		name        string
+		policy      string
+		customer    database.Customer
+		lastPaidAt  *time.Time
+		wantAllowed bool
+		wantReason  string
+	}{
+		{"once: new customer", config.TrialPolicyOnce, database.Customer{}, nil, true, ""},
+		{"once: trial used", config.TrialPolicyOnce, database.Customer{TrialActivatedAt: ago(400), ExpireAt: ago(398)}, nil, false, TrialDeniedUsed},
+		{"once: paid without trial", config.TrialPolicyOnce, database.Customer{SubscriptionLink: &link, ExpireAt: ago(1)}, nil, false, TrialDeniedUsed},
+		{"once: regranted", config.TrialPolicyOnce, database.Customer{TrialActivatedAt: ago(400), ExpireAt: ago(398), TrialRegrantedAt: ago(0)}, nil, true, ""},
+		{"regranted over active subscription", config.TrialPolicyOnce, database.Customer{TrialActivatedAt: ago(400), ExpireAt: &active, TrialRegrantedAt: ago(0)}, nil, false, TrialDeniedActive},
+		{"cooldown: inside cooldown", config.TrialPolicyCooldown, database.Customer{TrialActivatedAt: ago(60), ExpireAt: ago(58)}, nil, false, TrialDeniedCooldown},
+		{"cooldown: cooldown passed", config.TrialPolicyCooldown, database.Customer{TrialActivatedAt: ago(200), ExpireAt: ago(198)}, nil, true, ""},
+		{"cooldown: active subscription", config.TrialPolicyCooldown, database.Customer{TrialActivatedAt: ago(200), ExpireAt: &active}, nil, false, TrialDeniedActive},
+		{"cooldown: paid without trial", config.TrialPolicyCooldown, database.Customer{SubscriptionLink: &link, ExpireAt: ago(1)}, nil, true, ""},
+		{"after lapse: never paid", config.TrialPolicyAfterLapse, database.Customer{TrialActivatedAt: ago(30), ExpireAt: ago(28)}, nil, false, TrialDeniedUsed},
+		{"after lapse: paid before trial", config.TrialPolicyAfterLapse, database.Customer{TrialActivatedAt: ago(30), ExpireAt: ago(28)}, ago(60), false, TrialDeniedUsed},
+		{"after lapse: paid period lapsed", config.TrialPolicyAfterLapse, database.Customer{TrialActivatedAt: ago(90), ExpireAt: ago(2)}, ago(32), true, ""},
+		{"after lapse: paid period running", config.TrialPolicyAfterLapse, database.Customer{TrialActivatedAt: ago(90), ExpireAt: &active}, ago(5), false, TrialDeniedActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trialEligibility(tt.policy, 6, &tt.customer, tt.lastPaidAt, now)
+			if got.Allowed != tt.wantAllowed || got.Reason != tt.wantReason {
+				t.Errorf("trialEligibility() = %+v, want allowed=%v reason=%q", got, tt.wantAllowed, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestTrialEligibilityCooldownAvailableAt(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	activatedAt := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	got := trialEligibility(config.TrialPolicyCooldown, 6, &database.Customer{TrialActivatedAt: &activatedAt}, nil, now)
+	want := time.Date(2025, 9, 1, 10, 0, 0, 0, time.UTC)
+	if got.AvailableAt == nil || !got.AvailableAt.Equal(want) {
+		t.Fatalf("Expected next trial at %s, got %v", want, got.AvailableAt)
+	}
+}
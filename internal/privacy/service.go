@@ -0,0 +1,83 @@
+package privacy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+var ErrAlreadyAnonymized = errors.New("customer data already anonymized")
+
+type repository interface {
+	Export(ctx context.Context, customer *database.Customer) (map[string]json.RawMessage, error)
+	Anonymize(ctx context.Context, customer *database.Customer, at time.Time) (bool, error)
+}
+
+type panelUsers interface {
+	DeleteUsersByTelegramID(ctx context.Context, telegramID int64) (int, error)
+}
+
+// Service выгрузка и удаление данных клиента по его запросу
+type Service struct {
+	repository repository
+	panel      panelUsers
+}
+
+func NewService(repository repository, panel panelUsers) *Service {
+	return &Service{repository: repository, panel: panel}
+}
+
+// Export собранные данные клиента, отдаётся клиенту или админу JSON-файлом
+type Export struct {
+	ExportedAt time.Time                  `json:"exported_at"`
+	TelegramID int64                      `json:"telegram_id"`
+	Data       map[string]json.RawMessage `json:"data"`
+}
+
+// Export выгружает всё, что бот хранит о клиенте, в JSON
+func (s *Service) Export(ctx context.Context, customer *database.Customer) ([]byte, error) {
+	data, err := s.repository.Export(ctx, customer)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(Export{ExportedAt: clock.Now(), TelegramID: customer.TelegramID, Data: data}, "", "  ")
+}
+
+// FileName имя файла выгрузки
+func FileName(customer *database.Customer) string {
+	return fmt.Sprintf("data_%d.json", customer.TelegramID)
+}
+
+// Erase обезличивает клиента: идентификаторы и служебные данные удаляются, покупки остаются для отчётности.
+// С deletePanelUser сначала удаляется пользователь в панели — если это не удалось, данные в боте не меняются,
+// чтобы запрос можно было повторить. Возвращает число удалённых пользователей панели
+func (s *Service) Erase(ctx context.Context, customer *database.Customer, deletePanelUser bool) (int, error) {
+	if database.IsAnonymized(customer) {
+		return 0, ErrAlreadyAnonymized
+	}
+
+	deleted := 0
+	if deletePanelUser {
+		var err error
+		if deleted, err = s.panel.DeleteUsersByTelegramID(ctx, customer.TelegramID); err != nil {
+			return 0, fmt.Errorf("delete panel user: %w", err)
+		}
+	}
+
+	anonymized, err := s.repository.Anonymize(ctx, customer, clock.Now())
+	if err != nil {
+		return deleted, err
+	}
+	if !anonymized {
+		return deleted, ErrAlreadyAnonymized
+	}
+	slog.InfoContext(ctx, "Customer data anonymized", "customerId", utils.MaskHalfInt64(customer.ID), "panelUsersDeleted", deleted)
+	return deleted, nil
+}
@@ -0,0 +1,100 @@
+package privacy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+type fakeRepository struct {
+	calls      *[]string
+	anonymized bool
+}
+
+func (r *fakeRepository) Export(ctx context.Context, customer *database.Customer) (map[string]json.RawMessage, error) {
+	return map[string]json.RawMessage{"customer": json.RawMessage(`{"id":7}`), "purchases": json.RawMessage(`[]`)}, nil
+}
+
+func (r *fakeRepository) Anonymize(ctx context.Context, customer *database.Customer, at time.Time) (bool, error) {
+	*r.calls = append(*r.calls, "anonymize")
+	return r.anonymized, nil
+}
+
+type fakePanel struct {
+	calls *[]string
+	err   error
+}
+
+func (p *fakePanel) DeleteUsersByTelegramID(ctx context.Context, telegramID int64) (int, error) {
+	*p.calls = append(*p.calls, "panel")
+	if p.err != nil {
+		return 0, p.err
+	}
+	return 1, nil
+}
+
+func TestErase(t *testing.T) {
+	tests := []struct {
+		name            string
+		customer        *database.Customer
+		deletePanelUser bool
+		panelErr        error
+		anonymized      bool
+		wantCalls       string
+		wantErr         error
+	}{
+		{"panel user deleted before anonymizing", &database.Customer{ID: 7, TelegramID: 42}, true, nil, true, "panel,anonymize", nil},
+		{"panel user kept", &database.Customer{ID: 7, TelegramID: 42}, false, nil, true, "anonymize", nil},
+		{"panel failure keeps data for retry", &database.Customer{ID: 7, TelegramID: 42}, true, errors.New("timeout"), true, "panel", nil},
+		{"already anonymized", &database.Customer{ID: 7, TelegramID: -7}, true, nil, true, "", ErrAlreadyAnonymized},
+		{"anonymized concurrently", &database.Customer{ID: 7, TelegramID: 42}, false, nil, false, "anonymize", ErrAlreadyAnonymized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			s := NewService(&fakeRepository{calls: &calls, anonymized: tt.anonymized}, &fakePanel{calls: &calls, err: tt.panelErr})
+
+			_, err := s.Erase(context.Background(), tt.customer, tt.deletePanelUser)
+
+			if got := strings.Join(calls, ","); got != tt.wantCalls {
+				t.Errorf("Expected calls %q, got %q", tt.wantCalls, got)
+			}
+			switch {
+			case tt.panelErr != nil:
+				if !errors.Is(err, tt.panelErr) {
+					t.Errorf("Expected panel error, got %v", err)
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	var calls []string
+	s := NewService(&fakeRepository{calls: &calls}, &fakePanel{calls: &calls})
+
+	content, err := s.Export(context.Background(), &database.Customer{ID: 7, TelegramID: 42})
+	if err != nil {
+		t.Fatalf("Export() returned error: %v", err)
+	}
+
+	var export Export
+	if err := json.Unmarshal(content, &export); err != nil {
+		t.Fatalf("Export is not valid JSON: %v", err)
+	}
+	var customer struct{ ID int64 }
+	if err := json.Unmarshal(export.Data["customer"], &customer); err != nil || customer.ID != 7 || export.TelegramID != 42 {
+		t.Fatalf("Unexpected export: %s", content)
+	}
+	if _, ok := export.Data["purchases"]; !ok {
+		t.Fatalf("Expected purchases section, got %s", content)
+	}
+}
@@ -0,0 +1,28 @@
+package promo
+
+import "context"
+
+// StartPrefix префикс deep link с промокодом: /start promo_<код> или /start promo_<код>__<канал>
+const StartPrefix = "promo_"
+
+// ChannelSeparator отделяет код от канала в deep link. Двойное подчёркивание, потому что
+// одиночное допустимо в самом коде
+const ChannelSeparator = "__"
+
+// ChannelLink канал активаций по ссылке без указанного канала
+const ChannelLink = "link"
+
+type channelKey struct{}
+
+// WithChannel помечает контекст активации промокода каналом, через который распространялась ссылка
+func WithChannel(ctx context.Context, channel string) context.Context {
+	return context.WithValue(ctx, channelKey{}, channel)
+}
+
+// channelFromContext возвращает канал активации или nil для ручного ввода
+func channelFromContext(ctx context.Context) *string {
+	if channel, ok := ctx.Value(channelKey{}).(string); ok && channel != "" {
+		return &channel
+	}
+	return nil
+}
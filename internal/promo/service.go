@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"remnawave-tg-shop-bot/internal/clock"
 	"remnawave-tg-shop-bot/internal/config"
 	"remnawave-tg-shop-bot/internal/database"
 	"remnawave-tg-shop-bot/internal/remnawave"
@@ -51,7 +52,7 @@ func (s *Service) ApplyPromoCode(ctx context.Context, customerID int64, telegram
 	// Find promo code
 	promo, err := s.promoRepo.FindByCode(ctx, code)
 	if err != nil {
-		slog.Error("Error finding promo code", "code", code, "error", err)
+		slog.ErrorContext(ctx, "Error finding promo code", "code", code, "error", err)
 		return &ApplyResult{Success: false, ErrorKey: "promo_error"}
 	}
 	if promo == nil {
@@ -64,7 +65,7 @@ func (s *Service) ApplyPromoCode(ctx context.Context, customerID int64, telegram
 	}
 
 	// Check expiration
-	if promo.ValidUntil != nil && time.Now().After(*promo.ValidUntil) {
+	if promo.ValidUntil != nil && clock.Now().After(*promo.ValidUntil) {
 		return &ApplyResult{Success: false, ErrorKey: "promo_expired"}
 	}
 
@@ -76,7 +77,7 @@ func (s *Service) ApplyPromoCode(ctx context.Context, customerID int64, telegram
 	// Check if already used by this customer
 	used, err := s.promoRepo.IsUsedByCustomer(ctx, promo.ID, customerID)
 	if err != nil {
-		slog.Error("Error checking promo usage", "promoID", promo.ID, "customerID", customerID, "error", err)
+		slog.ErrorContext(ctx, "Error checking promo usage", "promoID", promo.ID, "customerID", customerID, "error", err)
 		return &ApplyResult{Success: false, ErrorKey: "promo_error"}
 	}
 	if used {
@@ -98,32 +99,32 @@ func (s *Service) ApplyPromoCode(ctx context.Context, customerID int64, telegram
 		false,
 	)
 	if err != nil {
-		slog.Error("Error applying promo bonus", "telegramID", telegramID, "bonusDays", promo.BonusDays, "error", err)
+		slog.ErrorContext(ctx, "Error applying promo bonus", "telegramID", telegramID, "bonusDays", promo.BonusDays, "error", err)
 		return &ApplyResult{Success: false, ErrorKey: "promo_apply_error"}
 	}
 
 	// Record activation
-	if err := s.promoRepo.RecordActivation(ctx, promo.ID, customerID); err != nil {
-		slog.Error("Error recording promo activation", "promoID", promo.ID, "customerID", customerID, "error", err)
+	if err := s.promoRepo.RecordActivation(ctx, promo.ID, customerID, channelFromContext(ctx)); err != nil {
+		slog.ErrorContext(ctx, "Error recording promo activation", "promoID", promo.ID, "customerID", customerID, "error", err)
 		// Don't fail - bonus already applied
 	}
 
 	// Increment counter
 	if err := s.promoRepo.IncrementActivations(ctx, promo.ID); err != nil {
-		slog.Error("Error incrementing promo activations", "promoID", promo.ID, "error", err)
+		slog.ErrorContext(ctx, "Error incrementing promo activations", "promoID", promo.ID, "error", err)
 	}
 
 	// Update customer expire_at
 	if newExpire == nil {
-		slog.Error("Remnawave returned nil user after promo apply", "customerID", customerID)
+		slog.ErrorContext(ctx, "Remnawave returned nil user after promo apply", "customerID", customerID)
 		return &ApplyResult{Success: false, ErrorKey: "promo_apply_error"}
 	}
 
 	if err := s.customerRepo.UpdateExpireAt(ctx, customerID, newExpire.ExpireAt); err != nil {
-		slog.Error("Error updating customer expire_at", "customerID", customerID, "error", err)
+		slog.ErrorContext(ctx, "Error updating customer expire_at", "customerID", customerID, "error", err)
 	}
 
-	slog.Info("Promo code applied", "code", code, "customerID", customerID, "bonusDays", promo.BonusDays)
+	slog.InfoContext(ctx, "Promo code applied", "code", code, "customerID", customerID, "bonusDays", promo.BonusDays)
 
 	expireAt := newExpire.ExpireAt
 	return &ApplyResult{
@@ -161,6 +162,11 @@ func (s *Service) GetPromoByID(ctx context.Context, id int64) (*database.PromoCo
 	return s.promoRepo.FindByID(ctx, id)
 }
 
+// GetPromoByCode возвращает промокод по коду или nil, если его нет
+func (s *Service) GetPromoByCode(ctx context.Context, code string) (*database.PromoCode, error) {
+	return s.promoRepo.FindByCode(ctx, code)
+}
+
 func (s *Service) DeactivatePromo(ctx context.Context, promoID int64) error {
 	return s.promoRepo.SetActive(ctx, promoID, false)
 }
@@ -173,6 +179,11 @@ func (s *Service) DeletePromo(ctx context.Context, promoID int64) error {
 	return s.promoRepo.Delete(ctx, promoID)
 }
 
+// GetActivationChannels возвращает количество активаций промокода по каналам ссылок
+func (s *Service) GetActivationChannels(ctx context.Context, promoID int64) ([]database.PromoChannelCount, error) {
+	return s.promoRepo.CountActivationsByChannel(ctx, promoID)
+}
+
 func (s *Service) GetPromoActivations(ctx context.Context, promoID int64) ([]database.PromoCodeActivation, error) {
 	return s.promoRepo.GetActivationsByPromo(ctx, promoID)
 }
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"remnawave-tg-shop-bot/internal/clock"
 	"remnawave-tg-shop-bot/internal/database"
 )
 
@@ -85,7 +86,7 @@ func (s *TariffService) ApplyPromoTariffCode(ctx context.Context, customerID int
 	// Find promo tariff code
 	promo, err := s.promoTariffRepo.FindByCode(ctx, code)
 	if err != nil {
-		slog.Error("Error finding promo tariff code", "code", code, "error", err)
+		slog.ErrorContext(ctx, "Error finding promo tariff code", "code", code, "error", err)
 		return &TariffApplyResult{Success: false, ErrorKey: "promo_tariff_error"}
 	}
 	if promo == nil {
@@ -98,7 +99,7 @@ func (s *TariffService) ApplyPromoTariffCode(ctx context.Context, customerID int
 	}
 
 	// Check expiration (valid_until - дата истечения самого промокода)
-	if promo.ValidUntil != nil && time.Now().After(*promo.ValidUntil) {
+	if promo.ValidUntil != nil && clock.Now().After(*promo.ValidUntil) {
 		return &TariffApplyResult{Success: false, ErrorKey: "promo_tariff_expired"}
 	}
 
@@ -110,7 +111,7 @@ func (s *TariffService) ApplyPromoTariffCode(ctx context.Context, customerID int
 	// Check if already used by this customer
 	used, err := s.promoTariffRepo.IsUsedByCustomer(ctx, promo.ID, customerID)
 	if err != nil {
-		slog.Error("Error checking promo tariff usage", "promoID", promo.ID, "customerID", customerID, "error", err)
+		slog.ErrorContext(ctx, "Error checking promo tariff usage", "promoID", promo.ID, "customerID", customerID, "error", err)
 		return &TariffApplyResult{Success: false, ErrorKey: "promo_tariff_error"}
 	}
 	if used {
@@ -118,26 +119,26 @@ func (s *TariffService) ApplyPromoTariffCode(ctx context.Context, customerID int
 	}
 
 	// Calculate offer expiration
-	offerExpires := time.Now().Add(time.Duration(promo.ValidHours) * time.Hour)
+	offerExpires := clock.Now().Add(time.Duration(promo.ValidHours) * time.Hour)
 
 	// Save offer to customer
 	if err := s.customerRepo.UpdatePromoOffer(ctx, customerID, promo.Price, promo.Devices, promo.Months, offerExpires, promo.ID); err != nil {
-		slog.Error("Error saving promo offer to customer", "customerID", customerID, "error", err)
+		slog.ErrorContext(ctx, "Error saving promo offer to customer", "customerID", customerID, "error", err)
 		return &TariffApplyResult{Success: false, ErrorKey: "promo_tariff_error"}
 	}
 
 	// Record activation
-	if err := s.promoTariffRepo.RecordActivation(ctx, promo.ID, customerID); err != nil {
-		slog.Error("Error recording promo tariff activation", "promoID", promo.ID, "customerID", customerID, "error", err)
+	if err := s.promoTariffRepo.RecordActivation(ctx, promo.ID, customerID, channelFromContext(ctx)); err != nil {
+		slog.ErrorContext(ctx, "Error recording promo tariff activation", "promoID", promo.ID, "customerID", customerID, "error", err)
 		// Don't fail - offer already saved
 	}
 
 	// Increment counter
 	if err := s.promoTariffRepo.IncrementActivations(ctx, promo.ID); err != nil {
-		slog.Error("Error incrementing promo tariff activations", "promoID", promo.ID, "error", err)
+		slog.ErrorContext(ctx, "Error incrementing promo tariff activations", "promoID", promo.ID, "error", err)
 	}
 
-	slog.Info("Promo tariff code applied",
+	slog.InfoContext(ctx, "Promo tariff code applied",
 		"code", code,
 		"customerID", customerID,
 		"price", promo.Price,
@@ -213,3 +214,8 @@ func (s *TariffService) DeletePromoTariff(ctx context.Context, promoID int64) er
 func (s *TariffService) GetPromoTariffActivations(ctx context.Context, promoID int64) ([]database.PromoTariffActivation, error) {
 	return s.promoTariffRepo.GetActivationsByPromo(ctx, promoID)
 }
+
+// GetPromoTariffActivationChannels возвращает количество активаций промокода по каналам ссылок
+func (s *TariffService) GetPromoTariffActivationChannels(ctx context.Context, promoID int64) ([]database.PromoChannelCount, error) {
+	return s.promoTariffRepo.CountActivationsByChannel(ctx, promoID)
+}
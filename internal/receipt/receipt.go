@@ -0,0 +1,225 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/locale"
+)
+
+// Document данные квитанции об оплате
+type Document struct {
+	Number        string
+	IssuedAt      time.Time
+	Operator      config.ReceiptOperator
+	TelegramID    int64
+	Description   string
+	Amount        string
+	PaidAt        time.Time
+	PaymentMethod string
+	Lang          string
+}
+
+type labels struct {
+	Title         string
+	From          string
+	Seller        string
+	TaxID         string
+	Address       string
+	Contact       string
+	Buyer         string
+	Item          string
+	Qty           string
+	Sum           string
+	Total         string
+	PaidAt        string
+	PaymentMethod string
+	Footer        string
+	tariff        string
+	devices       string
+	service       string
+	serviceDays   string
+	team          string
+	methods       map[database.InvoiceType]string
+}
+
+var labelsByLang = map[string]labels{
+	"ru": {
+		Title:         "Квитанция об оплате",
+		From:          "от",
+		Seller:        "Продавец",
+		TaxID:         "ИНН",
+		Address:       "Адрес",
+		Contact:       "Контакты",
+		Buyer:         "Покупатель",
+		Item:          "Наименование",
+		Qty:           "Кол-во",
+		Sum:           "Сумма",
+		Total:         "Итого",
+		PaidAt:        "Дата оплаты",
+		PaymentMethod: "Способ оплаты",
+		Footer:        "Документ сформирован автоматически и подтверждает получение оплаты.",
+		service:       "Доступ к VPN-сервису на %d мес.",
+		serviceDays:   "Доступ к VPN-сервису на %d дн.",
+		team:          "Командная подписка на VPN-сервис: %d мест на %d мес.",
+		tariff:        "тариф %s",
+		devices:       "до %d устройств",
+		methods: map[database.InvoiceType]string{
+			database.InvoiceTypeYookasa:  "Банковская карта (ЮKassa)",
+			database.InvoiceTypeCrypto:   "Криптовалюта (CryptoPay)",
+			database.InvoiceTypeTelegram: "Telegram Stars",
+			database.InvoiceTypeTribute:  "Tribute",
+		},
+	},
+	"en": {
+		Title:         "Payment receipt",
+		From:          "dated",
+		Seller:        "Seller",
+		TaxID:         "Tax ID",
+		Address:       "Address",
+		Contact:       "Contacts",
+		Buyer:         "Buyer",
+		Item:          "Description",
+		Qty:           "Qty",
+		Sum:           "Amount",
+		Total:         "Total",
+		PaidAt:        "Paid at",
+		PaymentMethod: "Payment method",
+		Footer:        "This document was generated automatically and confirms that the payment was received.",
+		service:       "VPN service access for %d mo.",
+		serviceDays:   "VPN service access for %d days",
+		team:          "VPN team subscription: %d seats for %d mo.",
+		tariff:        "plan %s",
+		devices:       "up to %d devices",
+		methods: map[database.InvoiceType]string{
+			database.InvoiceTypeYookasa:  "Bank card (YooKassa)",
+			database.InvoiceTypeCrypto:   "Cryptocurrency (CryptoPay)",
+			database.InvoiceTypeTelegram: "Telegram Stars",
+			database.InvoiceTypeTribute:  "Tribute",
+		},
+	},
+}
+
+// labelsFor возвращает подписи для языка пользователя, как locale: ru-RU → ru, неизвестный язык → язык по умолчанию
+func labelsFor(lang string) labels {
+	for _, code := range []string{lang, config.DefaultLanguage()} {
+		base, _, _ := strings.Cut(strings.ToLower(code), "-")
+		if l, ok := labelsByLang[base]; ok {
+			return l
+		}
+	}
+	return labelsByLang["en"]
+}
+
+// NewDocument собирает квитанцию по оплаченной покупке
+func NewDocument(r *database.Receipt, p *database.Purchase, telegramID int64, lang string) Document {
+	l := labelsFor(lang)
+
+	var description string
+	if p.TeamSeats != nil {
+		description = fmt.Sprintf(l.team, *p.TeamSeats, p.Month)
+	} else {
+		parts := []string{fmt.Sprintf(l.service, p.Month)}
+		if period := p.Period(); period.IsDays() {
+			parts[0] = fmt.Sprintf(l.serviceDays, period.Days)
+		}
+		if p.TariffName != nil && *p.TariffName != "" {
+			parts = append(parts, fmt.Sprintf(l.tariff, *p.TariffName))
+		}
+		if p.DeviceLimit != nil && *p.DeviceLimit > 0 {
+			parts = append(parts, fmt.Sprintf(l.devices, *p.DeviceLimit))
+		}
+		description = strings.Join(parts, ", ")
+	}
+
+	paidAt := p.CreatedAt
+	if p.PaidAt != nil {
+		paidAt = *p.PaidAt
+	}
+
+	return Document{
+		Number:        r.Number,
+		IssuedAt:      r.IssuedAt,
+		Operator:      config.GetReceiptOperator(),
+		TelegramID:    telegramID,
+		Description:   description,
+		Amount:        FormatAmount(lang, p.Amount, p.Currency),
+		PaidAt:        paidAt,
+		PaymentMethod: l.methods[p.InvoiceType],
+		Lang:          lang,
+	}
+}
+
+// FormatAmount форматирует сумму в валюте покупки: рубли через locale, звёзды числом
+func FormatAmount(lang string, amount float64, currency string) string {
+	switch currency {
+	case "", "RUB":
+		return locale.FormatMoney(lang, amount)
+	case "STARS", "XTR":
+		return fmt.Sprintf("%d ⭐", int(amount))
+	default:
+		return fmt.Sprintf("%.2f %s", amount, currency)
+	}
+}
+
+// FileName возвращает имя файла квитанции
+func FileName(number string) string {
+	return "receipt-" + number + ".html"
+}
+
+var documentTemplate = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<title>{{.L.Title}} {{.Doc.Number}}</title>
+<style>
+body { font-family: Arial, sans-serif; max-width: 720px; margin: 32px auto; color: #222; }
+h1 { font-size: 22px; margin-bottom: 24px; }
+table { width: 100%; border-collapse: collapse; margin: 16px 0; }
+th, td { border: 1px solid #999; padding: 8px; text-align: left; }
+td.num { text-align: right; white-space: nowrap; }
+.muted { color: #666; font-size: 13px; }
+</style>
+</head>
+<body>
+<h1>{{.L.Title}} № {{.Doc.Number}} {{.L.From}} {{.IssuedAt}}</h1>
+<p><b>{{.L.Seller}}:</b> {{.Doc.Operator.Name}}{{if .Doc.Operator.TaxID}}<br>{{.L.TaxID}}: {{.Doc.Operator.TaxID}}{{end}}{{if .Doc.Operator.Address}}<br>{{.L.Address}}: {{.Doc.Operator.Address}}{{end}}{{if .Doc.Operator.Contact}}<br>{{.L.Contact}}: {{.Doc.Operator.Contact}}{{end}}</p>
+<p><b>{{.L.Buyer}}:</b> Telegram ID {{.Doc.TelegramID}}</p>
+<table>
+<tr><th>{{.L.Item}}</th><th>{{.L.Qty}}</th><th>{{.L.Sum}}</th></tr>
+<tr><td>{{.Doc.Description}}</td><td class="num">1</td><td class="num">{{.Doc.Amount}}</td></tr>
+<tr><td colspan="2"><b>{{.L.Total}}</b></td><td class="num"><b>{{.Doc.Amount}}</b></td></tr>
+</table>
+{{if .Doc.Operator.VATNote}}<p>{{.Doc.Operator.VATNote}}</p>{{end}}
+<p>{{.L.PaidAt}}: {{.PaidAt}}{{if .Doc.PaymentMethod}}<br>{{.L.PaymentMethod}}: {{.Doc.PaymentMethod}}{{end}}</p>
+<p class="muted">{{.L.Footer}}</p>
+</body>
+</html>
+`))
+
+// Render возвращает квитанцию в виде HTML-документа, который открывается в любом браузере и печатается в PDF
+func Render(doc Document) ([]byte, error) {
+	var buf bytes.Buffer
+	err := documentTemplate.Execute(&buf, struct {
+		Doc      Document
+		L        labels
+		Lang     string
+		IssuedAt string
+		PaidAt   string
+	}{
+		Doc:      doc,
+		L:        labelsFor(doc.Lang),
+		Lang:     doc.Lang,
+		IssuedAt: locale.FormatDate(doc.Lang, doc.IssuedAt),
+		PaidAt:   locale.FormatDateTime(doc.Lang, doc.PaidAt),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("render receipt %s: %w", doc.Number, err)
+	}
+	return buf.Bytes(), nil
+}
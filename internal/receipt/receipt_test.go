@@ -0,0 +1,97 @@
+package receipt
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+func TestNewDocumentDescription(t *testing.T) {
+	tariff := "Pro"
+	devices := 5
+	seats := 3
+	week := 7
+	paidAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := &database.Receipt{Number: "INV-000001", IssuedAt: paidAt}
+
+	tests := []struct {
+		name     string
+		purchase database.Purchase
+		want     string
+	}{
+		{
+			name:     "plain",
+			purchase: database.Purchase{Month: 1, Amount: 199, Currency: "RUB", PaidAt: &paidAt},
+			want:     "Доступ к VPN-сервису на 1 мес.",
+		},
+		{
+			name:     "tariff and devices",
+			purchase: database.Purchase{Month: 3, Amount: 499, Currency: "RUB", PaidAt: &paidAt, TariffName: &tariff, DeviceLimit: &devices},
+			want:     "Доступ к VPN-сервису на 3 мес., тариф Pro, до 5 устройств",
+		},
+		{
+			name:     "day plan",
+			purchase: database.Purchase{Amount: 99, Currency: "RUB", PaidAt: &paidAt, PeriodDays: &week, TariffName: &tariff},
+			want:     "Доступ к VPN-сервису на 7 дн., тариф Pro",
+		},
+		{
+			name:     "team",
+			purchase: database.Purchase{Month: 6, Amount: 2990, Currency: "RUB", PaidAt: &paidAt, TeamSeats: &seats},
+			want:     "Командная подписка на VPN-сервис: 3 мест на 6 мес.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := NewDocument(r, &tt.purchase, 42, "ru")
+			if doc.Description != tt.want {
+				t.Errorf("Description = %q, want %q", doc.Description, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	if got := FormatAmount("en", 150, "STARS"); got != "150 ⭐" {
+		t.Errorf("stars amount = %q", got)
+	}
+	if got := FormatAmount("en", 10, "USD"); got != "10.00 USD" {
+		t.Errorf("foreign amount = %q", got)
+	}
+}
+
+func TestRenderEscapesOperatorData(t *testing.T) {
+	doc := Document{
+		Number:      "INV-000007",
+		IssuedAt:    time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		Operator:    config.ReceiptOperator{Name: "ООО <Ромашка>", TaxID: "7700000000", VATNote: "Без НДС"},
+		TelegramID:  42,
+		Description: "Доступ к VPN-сервису на 1 мес.",
+		Amount:      "199 ₽",
+		PaidAt:      time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Lang:        "ru",
+	}
+
+	out, err := Render(doc)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	html := string(out)
+	for _, want := range []string{"Квитанция об оплате № INV-000007", "ООО &lt;Ромашка&gt;", "ИНН: 7700000000", "Без НДС", "Telegram ID 42"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("rendered receipt does not contain %q", want)
+		}
+	}
+	if strings.Contains(html, "Адрес:") {
+		t.Error("empty address should be omitted")
+	}
+}
+
+func TestFileName(t *testing.T) {
+	if got := FileName("INV-000001"); got != "receipt-INV-000001.html" {
+		t.Errorf("FileName = %q", got)
+	}
+}
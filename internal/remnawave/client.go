@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"remnawave-tg-shop-bot/internal/clock"
 	"remnawave-tg-shop-bot/internal/config"
 	"remnawave-tg-shop-bot/utils"
 	"strconv"
@@ -17,7 +18,8 @@ import (
 )
 
 type Client struct {
-	client *remapi.ClientExt
+	client  *remapi.ClientExt
+	breaker *circuitBreaker
 }
 
 type headerTransport struct {
@@ -45,19 +47,33 @@ func NewClient(baseURL, token, mode string) *Client {
 	local := mode == "local"
 	headers := config.RemnawaveHeaders()
 
+	transport := newResilientTransport(&headerTransport{
+		base:    http.DefaultTransport,
+		local:   local,
+		headers: headers,
+	}, ResilienceOptions{
+		AttemptTimeout:   time.Duration(config.GetRemnawaveTimeoutSeconds()) * time.Second,
+		Retries:          config.GetRemnawaveRetries(),
+		BackoffBase:      500 * time.Millisecond,
+		BreakerThreshold: config.GetRemnawaveBreakerThreshold(),
+		BreakerCooldown:  time.Duration(config.GetRemnawaveBreakerCooldownSeconds()) * time.Second,
+	})
+	// Общий бюджет на вызов вместе с повторами: медленная панель не держит выдачу подписки дольше этого времени
 	client := &http.Client{
-		Transport: &headerTransport{
-			base:    http.DefaultTransport,
-			local:   local,
-			headers: headers,
-		},
+		Transport: transport,
+		Timeout:   time.Duration(config.GetRemnawaveTimeoutBudgetSeconds()) * time.Second,
 	}
 
 	api, err := remapi.NewClient(baseURL, remapi.StaticToken{Token: token}, remapi.WithClient(client))
 	if err != nil {
 		panic(err)
 	}
-	return &Client{client: remapi.NewClientExt(api)}
+	return &Client{client: remapi.NewClientExt(api), breaker: transport.breaker}
+}
+
+// Health возвращает состояние связи с панелью по данным circuit breaker, без запроса к панели
+func (r *Client) Health() Health {
+	return r.breaker.health()
 }
 
 func (r *Client) Ping(ctx context.Context) error {
@@ -76,6 +92,13 @@ type UserInfo struct {
 	FirstConnectedAt *time.Time
 	ExpireAt         time.Time
 	Status           string
+	// UsedTrafficBytes и TrafficLimitBytes — расход и лимит трафика, лимит 0 означает безлимит
+	UsedTrafficBytes  int64
+	TrafficLimitBytes int64
+	// DeviceLimit лимит устройств (hwidDeviceLimit), nil если не задан
+	DeviceLimit *int
+	// SubscriptionURL ссылка подписки
+	SubscriptionURL string
 }
 
 // GetUserByUUID получает пользователя по UUID (subscription link) для проверки firstConnectedAt
@@ -92,15 +115,23 @@ func (r *Client) GetUserByUUID(ctx context.Context, userUUID uuid.UUID) (*UserIn
 	case *remapi.UserResponse:
 		user := v.GetResponse()
 		info := &UserInfo{
-			UUID:     user.UUID,
-			Username: user.Username,
-			ExpireAt: user.ExpireAt,
-			Status:   string(user.Status.Value),
+			UUID:            user.UUID,
+			Username:        user.Username,
+			ExpireAt:        user.ExpireAt,
+			Status:          string(user.Status.Value),
+			SubscriptionURL: user.SubscriptionUrl,
 		}
 		// Проверяем firstConnectedAt
 		if firstConnected, ok := user.FirstConnectedAt.Get(); ok {
 			info.FirstConnectedAt = &firstConnected
 		}
+		info.UsedTrafficBytes = int64(user.UsedTrafficBytes)
+		if limit, ok := user.TrafficLimitBytes.Get(); ok {
+			info.TrafficLimitBytes = int64(limit)
+		}
+		if devices, ok := user.HwidDeviceLimit.Get(); ok {
+			info.DeviceLimit = &devices
+		}
 		return info, nil
 	default:
 		return nil, errors.New("unknown response type")
@@ -137,20 +168,136 @@ func (r *Client) GetUserByTelegramID(ctx context.Context, telegramID int64) (*Us
 			user = &users[0]
 		}
 
-		info := &UserInfo{
-			UUID:     user.UUID,
-			Username: user.Username,
-			ExpireAt: user.ExpireAt,
-			Status:   string(user.Status.Value),
+		info := userInfoFromItem(user)
+		return &info, nil
+	default:
+		return nil, errors.New("unknown response type")
+	}
+}
+
+// userInfoFromItem UserInfo пользователя из списка, найденного по Telegram ID
+func userInfoFromItem(user *remapi.UsersResponseResponseItem) UserInfo {
+	info := UserInfo{
+		UUID:            user.UUID,
+		Username:        user.Username,
+		ExpireAt:        user.ExpireAt,
+		Status:          string(user.Status.Value),
+		SubscriptionURL: user.SubscriptionUrl,
+	}
+	// Проверяем firstConnectedAt
+	if firstConnected, ok := user.FirstConnectedAt.Get(); ok {
+		info.FirstConnectedAt = &firstConnected
+	}
+	info.UsedTrafficBytes = int64(user.UsedTrafficBytes)
+	if limit, ok := user.TrafficLimitBytes.Get(); ok {
+		info.TrafficLimitBytes = int64(limit)
+	}
+	if devices, ok := user.HwidDeviceLimit.Get(); ok {
+		info.DeviceLimit = &devices
+	}
+	return info
+}
+
+// RevokeSubscription перевыпускает подписку пользователя: панель генерирует новый shortUuid,
+// старая ссылка подписки перестаёт работать. Возвращает новую ссылку подписки
+func (r *Client) RevokeSubscription(ctx context.Context, telegramID int64) (string, error) {
+	user, err := r.GetUserByTelegramID(ctx, telegramID)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := r.client.UsersControllerRevokeUserSubscription(ctx, &remapi.RevokeUserSubscriptionBodyDto{},
+		remapi.UsersControllerRevokeUserSubscriptionParams{UUID: user.UUID.String()})
+	if err != nil {
+		return "", err
+	}
+
+	switch v := resp.(type) {
+	case *remapi.UsersControllerRevokeUserSubscriptionNotFound:
+		return "", errors.New("user not found")
+	case *remapi.UserResponse:
+		return v.GetResponse().SubscriptionUrl, nil
+	default:
+		return "", errors.New("unknown response type")
+	}
+}
+
+// DeleteUsersByTelegramID удаляет из панели всех пользователей с этим Telegram ID вместе с подписками.
+// Возвращает число удалённых пользователей, отсутствие пользователя ошибкой не считается
+func (r *Client) DeleteUsersByTelegramID(ctx context.Context, telegramID int64) (int, error) {
+	resp, err := r.client.UsersControllerGetUserByTelegramId(ctx, remapi.UsersControllerGetUserByTelegramIdParams{
+		TelegramId: strconv.FormatInt(telegramID, 10),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var users []remapi.UsersResponseResponseItem
+	switch v := resp.(type) {
+	case *remapi.UsersControllerGetUserByTelegramIdNotFound:
+		return 0, nil
+	case *remapi.UsersResponse:
+		users = v.GetResponse()
+	default:
+		return 0, errors.New("unknown response type")
+	}
+
+	deleted := 0
+	for _, user := range users {
+		resp, err := r.client.UsersControllerDeleteUser(ctx, remapi.UsersControllerDeleteUserParams{UUID: user.UUID.String()})
+		if err != nil {
+			return deleted, fmt.Errorf("delete user %s: %w", user.UUID, err)
 		}
-		// Проверяем firstConnectedAt
-		if firstConnected, ok := user.FirstConnectedAt.Get(); ok {
-			info.FirstConnectedAt = &firstConnected
+		switch resp.(type) {
+		case *remapi.DeleteResponse:
+			deleted++
+		case *remapi.UsersControllerDeleteUserNotFound:
+		default:
+			return deleted, errors.New("unknown response type")
 		}
-		return info, nil
+	}
+	return deleted, nil
+}
+
+// DisableUsersByTelegramID отключает в панели всех активных пользователей с этим Telegram ID. Подписка
+// перестаёт работать, но пользователь остаётся: продление снова его включает. Возвращает число отключённых
+// пользователей, отсутствие пользователя ошибкой не считается
+func (r *Client) DisableUsersByTelegramID(ctx context.Context, telegramID int64) (int, error) {
+	resp, err := r.client.UsersControllerGetUserByTelegramId(ctx, remapi.UsersControllerGetUserByTelegramIdParams{
+		TelegramId: strconv.FormatInt(telegramID, 10),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var users []remapi.UsersResponseResponseItem
+	switch v := resp.(type) {
+	case *remapi.UsersControllerGetUserByTelegramIdNotFound:
+		return 0, nil
+	case *remapi.UsersResponse:
+		users = v.GetResponse()
 	default:
-		return nil, errors.New("unknown response type")
+		return 0, errors.New("unknown response type")
+	}
+
+	disabled := 0
+	for _, user := range users {
+		if status, ok := user.Status.Get(); ok && status == remapi.UsersResponseResponseItemStatusDISABLED {
+			continue
+		}
+		resp, err := r.client.UsersControllerDisableUser(ctx, remapi.UsersControllerDisableUserParams{UUID: user.UUID.String()})
+		if err != nil {
+			return disabled, fmt.Errorf("disable user %s: %w", user.UUID, err)
+		}
+		switch resp.(type) {
+		case *remapi.UserResponse:
+			disabled++
+		case *remapi.UsersControllerDisableUserNotFound:
+		default:
+			return disabled, errors.New("unknown response type")
+		}
 	}
+	return disabled, nil
 }
 
 func (r *Client) GetUsers(ctx context.Context) (*[]remapi.GetAllUsersResponseDtoResponseUsersItem, error) {
@@ -209,6 +356,47 @@ func (r *Client) DecreaseSubscription(ctx context.Context, telegramId int64, tra
 	}
 }
 
+// ClawbackSubscription сокращает подписку на days дней при возврате платежа. В отличие от DecreaseSubscription
+// не оставляет минимальный срок и не включает пользователя: если сокращённый срок уже прошёл, подписка истекает сейчас
+func (r *Client) ClawbackSubscription(ctx context.Context, telegramID int64, days int) (*time.Time, error) {
+	resp, err := r.client.UsersControllerGetUserByTelegramId(ctx, remapi.UsersControllerGetUserByTelegramIdParams{TelegramId: strconv.FormatInt(telegramID, 10)})
+	if err != nil {
+		return nil, err
+	}
+
+	var existingUser *remapi.UsersResponseResponseItem
+	switch v := resp.(type) {
+	case *remapi.UsersControllerGetUserByTelegramIdNotFound:
+		return nil, errors.New("user in remnawave not found")
+	case *remapi.UsersResponse:
+		for _, panelUser := range v.GetResponse() {
+			if strings.Contains(panelUser.Username, fmt.Sprintf("_%d", telegramID)) {
+				existingUser = &panelUser
+			}
+		}
+		if existingUser == nil {
+			existingUser = &v.GetResponse()[0]
+		}
+	default:
+		return nil, errors.New("unknown response type")
+	}
+
+	newExpire := clawbackExpire(existingUser.ExpireAt, days, clock.Now().UTC())
+	updateUser, err := r.client.UsersControllerUpdateUser(ctx, &remapi.UpdateUserRequestDto{
+		UUID:     remapi.NewOptUUID(existingUser.UUID),
+		ExpireAt: remapi.NewOptDateTime(newExpire),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if value, ok := updateUser.(*remapi.UsersControllerUpdateUserInternalServerError); ok {
+		return nil, fmt.Errorf("%w while updating user. message: %s. code: %s", ErrServerError, value.GetMessage().Value, value.GetErrorCode().Value)
+	}
+
+	slog.InfoContext(ctx, "clawed back subscription", "telegramId", utils.MaskHalf(strconv.FormatInt(telegramID, 10)), "days", days, "expireAt", newExpire)
+	return &newExpire, nil
+}
+
 func (r *Client) CreateOrUpdateUser(ctx context.Context, customerId int64, telegramId int64, trafficLimit int, days int, isTrialUser bool) (*remapi.UserResponseResponse, error) {
 	return r.CreateOrUpdateUserWithDeviceLimit(ctx, customerId, telegramId, trafficLimit, days, isTrialUser, nil, false)
 }
@@ -250,7 +438,7 @@ func (r *Client) updateUser(ctx context.Context, existingUser *remapi.UsersRespo
 // forceDeviceLimit - если true, устанавливает лимит принудительно, игнорируя ResolveDeviceLimit
 func (r *Client) updateUserWithDeviceLimit(ctx context.Context, existingUser *remapi.UsersResponseResponseItem, trafficLimit int, days int, deviceLimit *int, forceDeviceLimit bool) (*remapi.UserResponseResponse, error) {
 
-	newExpire := getNewExpire(days, existingUser.ExpireAt)
+	newExpire := userExpireAt(ctx, getNewExpire(days, existingUser.ExpireAt))
 
 	resp, err := r.client.InternalSquadControllerGetInternalSquads(ctx)
 	if err != nil {
@@ -290,7 +478,7 @@ func (r *Client) updateUserWithDeviceLimit(ctx context.Context, existingUser *re
 		if forceDeviceLimit {
 			// Первая покупка — принудительно устанавливаем лимит
 			userUpdate.HwidDeviceLimit = remapi.NewOptNilInt(*deviceLimit)
-			slog.Debug("Force setting device limit (first purchase)", "deviceLimit", *deviceLimit)
+			slog.DebugContext(ctx, "Force setting device limit (first purchase)", "deviceLimit", *deviceLimit)
 		} else {
 			// Повторные покупки — используем ResolveDeviceLimit для защиты VIP
 			var currentLimit *int
@@ -303,18 +491,17 @@ func (r *Client) updateUserWithDeviceLimit(ctx context.Context, existingUser *re
 
 			if finalLimit != nil {
 				userUpdate.HwidDeviceLimit = remapi.NewOptNilInt(*finalLimit)
-				slog.Debug("Setting device limit", "currentLimit", currentLimit, "tariffLimit", *deviceLimit, "finalLimit", *finalLimit)
+				slog.DebugContext(ctx, "Setting device limit", "currentLimit", currentLimit, "tariffLimit", *deviceLimit, "finalLimit", *finalLimit)
 			}
 		}
 	}
 
-	externalSquad := config.ExternalSquadUUID()
+	externalSquad := userExternalSquad(ctx, false)
 	if externalSquad != uuid.Nil {
 		userUpdate.ExternalSquadUuid = remapi.NewOptNilUUID(externalSquad)
 	}
 
-	tag := config.RemnawaveTag()
-	if tag != "" {
+	if tag, ok := updateTag(ctx); ok && tag != "" {
 		userUpdate.Tag = remapi.NewOptNilString(tag)
 	}
 
@@ -326,16 +513,20 @@ func (r *Client) updateUserWithDeviceLimit(ctx context.Context, existingUser *re
 		username = ""
 	}
 
+	if err := saveExpireAt(ctx, newExpire); err != nil {
+		return nil, err
+	}
+
 	updateUser, err := r.client.UsersControllerUpdateUser(ctx, userUpdate)
 	if err != nil {
 		return nil, err
 	}
 	if value, ok := updateUser.(*remapi.UsersControllerUpdateUserInternalServerError); ok {
-		return nil, errors.New("error while updating user. message: " + value.GetMessage().Value + ". code: " + value.GetErrorCode().Value)
+		return nil, fmt.Errorf("%w while updating user. message: %s. code: %s", ErrServerError, value.GetMessage().Value, value.GetErrorCode().Value)
 	}
 
 	tgid, _ := existingUser.TelegramId.Get()
-	slog.Info("updated user", "telegramId", utils.MaskHalf(strconv.Itoa(tgid)), "username", utils.MaskHalf(username), "days", days)
+	slog.InfoContext(ctx, "updated user", "telegramId", utils.MaskHalf(strconv.Itoa(tgid)), "username", utils.MaskHalf(username), "days", days)
 	return &updateUser.(*remapi.UserResponse).Response, nil
 }
 
@@ -345,7 +536,7 @@ func (r *Client) createUser(ctx context.Context, customerId int64, telegramId in
 
 // createUserWithDeviceLimit создаёт нового пользователя с опциональным лимитом устройств
 func (r *Client) createUserWithDeviceLimit(ctx context.Context, customerId int64, telegramId int64, trafficLimit int, days int, isTrialUser bool, deviceLimit *int) (*remapi.UserResponseResponse, error) {
-	expireAt := time.Now().UTC().AddDate(0, 0, days)
+	expireAt := userExpireAt(ctx, time.Now().UTC().AddDate(0, 0, days))
 	username := generateUsername(customerId, telegramId)
 
 	resp, err := r.client.InternalSquadControllerGetInternalSquads(ctx)
@@ -373,10 +564,7 @@ func (r *Client) createUserWithDeviceLimit(ctx context.Context, customerId int64
 		}
 	}
 
-	externalSquad := config.ExternalSquadUUID()
-	if isTrialUser {
-		externalSquad = config.TrialExternalSquadUUID()
-	}
+	externalSquad := userExternalSquad(ctx, isTrialUser)
 
 	strategy := config.TrafficLimitResetStrategy()
 	if isTrialUser {
@@ -396,17 +584,13 @@ func (r *Client) createUserWithDeviceLimit(ctx context.Context, customerId int64
 	// Устанавливаем лимит устройств для нового пользователя (если указан тариф и не триал)
 	if deviceLimit != nil && !isTrialUser {
 		createUserRequestDto.HwidDeviceLimit = remapi.NewOptInt(*deviceLimit)
-		slog.Debug("Setting device limit for new user", "deviceLimit", *deviceLimit)
+		slog.DebugContext(ctx, "Setting device limit for new user", "deviceLimit", *deviceLimit)
 	}
 
 	if externalSquad != uuid.Nil {
 		createUserRequestDto.ExternalSquadUuid = remapi.NewOptNilUUID(externalSquad)
 	}
-	tag := config.RemnawaveTag()
-	if isTrialUser {
-		tag = config.TrialRemnawaveTag()
-	}
-	if tag != "" {
+	if tag := createTag(ctx, isTrialUser); tag != "" {
 		createUserRequestDto.Tag = remapi.NewOptNilString(tag)
 	}
 
@@ -418,11 +602,15 @@ func (r *Client) createUserWithDeviceLimit(ctx context.Context, customerId int64
 		tgUsername = ""
 	}
 
+	if err := saveExpireAt(ctx, expireAt); err != nil {
+		return nil, err
+	}
+
 	userCreate, err := r.client.UsersControllerCreateUser(ctx, &createUserRequestDto)
 	if err != nil {
 		return nil, err
 	}
-	slog.Info("created user", "telegramId", utils.MaskHalf(strconv.FormatInt(telegramId, 10)), "username", utils.MaskHalf(tgUsername), "days", days)
+	slog.InfoContext(ctx, "created user", "telegramId", utils.MaskHalf(strconv.FormatInt(telegramId, 10)), "username", utils.MaskHalf(tgUsername), "days", days)
 	return &userCreate.(*remapi.UserResponse).Response, nil
 }
 
@@ -446,6 +634,19 @@ func getNewExpire(daysToAdd int, currentExpire time.Time) time.Time {
 	return currentExpire.AddDate(0, 0, daysToAdd)
 }
 
+// clawbackExpire срок подписки после списания days дней. Срок не уходит в прошлое дальше текущего момента
+// и не продлевается, если подписка уже истекла
+func clawbackExpire(currentExpire time.Time, days int, now time.Time) time.Time {
+	newExpire := currentExpire.AddDate(0, 0, -days)
+	if !newExpire.Before(now) {
+		return newExpire
+	}
+	if currentExpire.Before(now) {
+		return currentExpire
+	}
+	return now
+}
+
 func getCreateStrategy(s string) remapi.CreateUserRequestDtoTrafficLimitStrategy {
 	switch s {
 	case "DAY":
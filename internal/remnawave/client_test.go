@@ -3,6 +3,7 @@ package remnawave
 import (
 	"testing"
 	"testing/quick"
+	"time"
 )
 
 // **Feature: tariff-system, Property 1: Disabled Limit Protection**
@@ -74,3 +75,24 @@ func TestResolveDeviceLimit_Scenarios(t *testing.T) {
 func intPtr(i int) *int {
 	return &i
 }
+
+func TestClawbackExpire(t *testing.T) {
+	now := time.Date(2025, 5, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		current time.Time
+		days    int
+		want    time.Time
+	}{
+		{"partial clawback keeps remaining days", now.AddDate(0, 0, 20), 5, now.AddDate(0, 0, 15)},
+		{"clawback landing in the past expires now", now.AddDate(0, 0, 3), 30, now},
+		{"already expired subscription is not extended", now.AddDate(0, 0, -2), 30, now.AddDate(0, 0, -2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clawbackExpire(tt.current, tt.days, now); !got.Equal(tt.want) {
+				t.Errorf("clawbackExpire() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package remnawave
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/v2/api"
+
+	"remnawave-tg-shop-bot/utils"
+)
+
+// statusDisabled статус отключённого пользователя панели. Отключённые пользователи дублями не считаются
+const statusDisabled = "DISABLED"
+
+// DuplicateGroup несколько включённых пользователей панели с одним Telegram ID (обычно после ручных правок в панели).
+// Users[0] — основной пользователь, который остаётся после объединения
+type DuplicateGroup struct {
+	TelegramID int64
+	Users      []UserInfo
+}
+
+// MergeResult итог объединения дублей
+type MergeResult struct {
+	Primary  UserInfo
+	Disabled int
+}
+
+// FindDuplicates ищет Telegram ID, к которым привязано несколько включённых пользователей панели
+func FindDuplicates(users []remapi.GetAllUsersResponseDtoResponseUsersItem) []DuplicateGroup {
+	byTelegramID := make(map[int64][]UserInfo)
+	var order []int64
+	for _, user := range users {
+		if user.TelegramId.Null || string(user.Status.Value) == statusDisabled {
+			continue
+		}
+		telegramID := int64(user.TelegramId.Value)
+		if _, exists := byTelegramID[telegramID]; !exists {
+			order = append(order, telegramID)
+		}
+		info := UserInfo{
+			UUID:             user.UUID,
+			Username:         user.Username,
+			ExpireAt:         user.ExpireAt,
+			Status:           string(user.Status.Value),
+			UsedTrafficBytes: int64(user.UsedTrafficBytes),
+			SubscriptionURL:  user.SubscriptionUrl,
+		}
+		if limit, ok := user.TrafficLimitBytes.Get(); ok {
+			info.TrafficLimitBytes = int64(limit)
+		}
+		byTelegramID[telegramID] = append(byTelegramID[telegramID], info)
+	}
+
+	var groups []DuplicateGroup
+	for _, telegramID := range order {
+		if users := byTelegramID[telegramID]; len(users) > 1 {
+			sortDuplicates(telegramID, users)
+			groups = append(groups, DuplicateGroup{TelegramID: telegramID, Users: users})
+		}
+	}
+	return groups
+}
+
+// sortDuplicates ставит первым основного пользователя: с самой поздней датой окончания, при равенстве —
+// созданного ботом (имя оканчивается на _<telegramId>), затем с большим расходом трафика
+func sortDuplicates(telegramID int64, users []UserInfo) {
+	botSuffix := "_" + strconv.FormatInt(telegramID, 10)
+	slices.SortStableFunc(users, func(a, b UserInfo) int {
+		if c := b.ExpireAt.Compare(a.ExpireAt); c != 0 {
+			return c
+		}
+		aBot, bBot := strings.HasSuffix(a.Username, botSuffix), strings.HasSuffix(b.Username, botSuffix)
+		if aBot != bBot {
+			if aBot {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(b.UsedTrafficBytes, a.UsedTrafficBytes)
+	})
+}
+
+// MergedTrafficLimit лимит трафика основного пользователя после объединения: к нему добавляется неизрасходованный
+// остаток дублей. Безлимит (0) у любого из пользователей даёт безлимит
+func MergedTrafficLimit(users []UserInfo) int64 {
+	var limit int64
+	for i, user := range users {
+		if user.TrafficLimitBytes == 0 {
+			return 0
+		}
+		if i == 0 {
+			limit = user.TrafficLimitBytes
+			continue
+		}
+		limit += max(user.TrafficLimitBytes-user.UsedTrafficBytes, 0)
+	}
+	return limit
+}
+
+// MergeDuplicates объединяет включённых пользователей панели с этим Telegram ID: основной пользователь
+// (с самой поздней датой окончания) получает остаток трафика дублей, дубли отключаются.
+// Сначала меняется лимит основного: при сбое отключения клиент не теряет трафик, но повтор добавит остаток
+// ещё не отключённых дублей повторно
+func (r *Client) MergeDuplicates(ctx context.Context, telegramID int64) (*MergeResult, error) {
+	resp, err := r.client.UsersControllerGetUserByTelegramId(ctx, remapi.UsersControllerGetUserByTelegramIdParams{
+		TelegramId: strconv.FormatInt(telegramID, 10),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var users []UserInfo
+	switch v := resp.(type) {
+	case *remapi.UsersControllerGetUserByTelegramIdNotFound:
+		return nil, errors.New("user not found")
+	case *remapi.UsersResponse:
+		for i, item := range v.GetResponse() {
+			if string(item.Status.Value) != statusDisabled {
+				users = append(users, userInfoFromItem(&v.GetResponse()[i]))
+			}
+		}
+	default:
+		return nil, errors.New("unknown response type")
+	}
+	if len(users) == 0 {
+		return nil, errors.New("no enabled users")
+	}
+	sortDuplicates(telegramID, users)
+	result := &MergeResult{Primary: users[0]}
+	if len(users) == 1 {
+		return result, nil
+	}
+
+	if limit := MergedTrafficLimit(users); limit != result.Primary.TrafficLimitBytes {
+		updated, err := r.client.UsersControllerUpdateUser(ctx, &remapi.UpdateUserRequestDto{
+			UUID:              remapi.NewOptUUID(result.Primary.UUID),
+			TrafficLimitBytes: remapi.NewOptInt(int(limit)),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("update traffic limit of %s: %w", result.Primary.UUID, err)
+		}
+		if _, ok := updated.(*remapi.UserResponse); !ok {
+			return nil, fmt.Errorf("update traffic limit of %s: unexpected response %T", result.Primary.UUID, updated)
+		}
+		result.Primary.TrafficLimitBytes = limit
+	}
+
+	for _, duplicate := range users[1:] {
+		resp, err := r.client.UsersControllerDisableUser(ctx, remapi.UsersControllerDisableUserParams{UUID: duplicate.UUID.String()})
+		if err != nil {
+			return result, fmt.Errorf("disable user %s: %w", duplicate.UUID, err)
+		}
+		switch resp.(type) {
+		case *remapi.UserResponse:
+			result.Disabled++
+		case *remapi.UsersControllerDisableUserNotFound:
+		default:
+			return result, fmt.Errorf("disable user %s: unexpected response %T", duplicate.UUID, resp)
+		}
+	}
+
+	slog.InfoContext(ctx, "Merged duplicate panel users", "telegramId", utils.MaskHalfInt64(telegramID),
+		"primary", result.Primary.UUID, "disabled", result.Disabled, "trafficLimitBytes", result.Primary.TrafficLimitBytes)
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package remnawave
+
+import (
+	"testing"
+	"time"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/v2/api"
+	"github.com/google/uuid"
+)
+
+func panelUser(telegramID int, username, status string, expireAt time.Time, used float64, limit int) remapi.GetAllUsersResponseDtoResponseUsersItem {
+	return remapi.GetAllUsersResponseDtoResponseUsersItem{
+		UUID:              uuid.New(),
+		Username:          username,
+		Status:            remapi.NewOptGetAllUsersResponseDtoResponseUsersItemStatus(remapi.GetAllUsersResponseDtoResponseUsersItemStatus(status)),
+		TelegramId:        remapi.NewNilInt(telegramID),
+		ExpireAt:          expireAt,
+		UsedTrafficBytes:  used,
+		TrafficLimitBytes: remapi.NewOptInt(limit),
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	now := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	users := []remapi.GetAllUsersResponseDtoResponseUsersItem{
+		panelUser(100, "manual", "ACTIVE", now.AddDate(0, 1, 0), 0, 0),
+		panelUser(100, "1_100", "ACTIVE", now.AddDate(0, 2, 0), 0, 0),
+		panelUser(200, "1_200", "ACTIVE", now, 0, 0),
+		panelUser(200, "old", "DISABLED", now.AddDate(1, 0, 0), 0, 0),
+		panelUser(300, "a", "EXPIRED", now, 10, 0),
+		panelUser(300, "3_300", "EXPIRED", now, 5, 0),
+		panelUser(300, "b", "LIMITED", now, 20, 0),
+	}
+	users = append(users, remapi.GetAllUsersResponseDtoResponseUsersItem{UUID: uuid.New(), TelegramId: remapi.NilInt{Null: true}})
+
+	groups := FindDuplicates(users)
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 groups (disabled users are not duplicates), got %+v", groups)
+	}
+	if groups[0].TelegramID != 100 || groups[0].Users[0].Username != "1_100" {
+		t.Errorf("Expected user with the latest expiry first, got %+v", groups[0])
+	}
+	if groups[1].TelegramID != 300 || groups[1].Users[0].Username != "3_300" || groups[1].Users[1].Username != "b" {
+		t.Errorf("Expected bot-created user, then most traffic on equal expiry, got %+v", groups[1].Users)
+	}
+}
+
+func TestMergedTrafficLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		users []UserInfo
+		want  int64
+	}{
+		{"adds unused remainder", []UserInfo{{TrafficLimitBytes: 100, UsedTrafficBytes: 30}, {TrafficLimitBytes: 50, UsedTrafficBytes: 20}}, 130},
+		{"overused duplicate adds nothing", []UserInfo{{TrafficLimitBytes: 100}, {TrafficLimitBytes: 50, UsedTrafficBytes: 70}}, 100},
+		{"unlimited primary", []UserInfo{{TrafficLimitBytes: 0}, {TrafficLimitBytes: 50}}, 0},
+		{"unlimited duplicate", []UserInfo{{TrafficLimitBytes: 100}, {TrafficLimitBytes: 0}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergedTrafficLimit(tt.users); got != tt.want {
+				t.Errorf("MergedTrafficLimit() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package remnawave
+
+import (
+	"context"
+	"time"
+)
+
+type expireAtContextKey struct{}
+
+type expireHookContextKey struct{}
+
+// WithExpireAt задаёт срок подписки для CreateOrUpdateUser вместо продления на days от текущего срока.
+// Повторная выдача с тем же сроком не продлевает подписку второй раз
+func WithExpireAt(ctx context.Context, expireAt time.Time) context.Context {
+	return context.WithValue(ctx, expireAtContextKey{}, expireAt)
+}
+
+// WithExpireHook вызывает save с новым сроком подписки до запроса в панель, чтобы его можно было
+// передать в WithExpireAt при повторе. Ошибка save отменяет запрос
+func WithExpireHook(ctx context.Context, save func(expireAt time.Time) error) context.Context {
+	return context.WithValue(ctx, expireHookContextKey{}, save)
+}
+
+// userExpireAt срок подписки пользователя: заданный через WithExpireAt, иначе рассчитанный
+func userExpireAt(ctx context.Context, calculated time.Time) time.Time {
+	if expireAt, ok := ctx.Value(expireAtContextKey{}).(time.Time); ok {
+		return expireAt
+	}
+	return calculated
+}
+
+// saveExpireAt передаёт новый срок в WithExpireHook перед запросом в панель
+func saveExpireAt(ctx context.Context, expireAt time.Time) error {
+	if save, ok := ctx.Value(expireHookContextKey{}).(func(time.Time) error); ok {
+		return save(expireAt)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package remnawave
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryWithSavedExpireDoesNotExtendTwice(t *testing.T) {
+	current := time.Now().UTC().AddDate(0, 0, 10)
+
+	var saved time.Time
+	ctx := WithExpireHook(context.Background(), func(expireAt time.Time) error {
+		saved = expireAt
+		return nil
+	})
+	first := userExpireAt(ctx, getNewExpire(30, current))
+	if err := saveExpireAt(ctx, first); err != nil {
+		t.Fatalf("saveExpireAt: %v", err)
+	}
+	if !saved.Equal(current.AddDate(0, 0, 30)) {
+		t.Fatalf("saved = %v, want %v", saved, current.AddDate(0, 0, 30))
+	}
+
+	// Панель применила продление, но ответ не дошёл: повтор видит уже продлённый срок
+	retry := userExpireAt(WithExpireAt(context.Background(), saved), getNewExpire(30, saved))
+	if !retry.Equal(saved) {
+		t.Errorf("retry expireAt = %v, want saved %v", retry, saved)
+	}
+}
+
+func TestSaveExpireAtError(t *testing.T) {
+	errSave := errors.New("db down")
+	ctx := WithExpireHook(context.Background(), func(time.Time) error { return errSave })
+	if err := saveExpireAt(ctx, time.Now()); !errors.Is(err, errSave) {
+		t.Errorf("saveExpireAt error = %v, want %v", err, errSave)
+	}
+	if err := saveExpireAt(context.Background(), time.Now()); err != nil {
+		t.Errorf("saveExpireAt without hook = %v, want nil", err)
+	}
+}
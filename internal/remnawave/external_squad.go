@@ -0,0 +1,31 @@
+package remnawave
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+type externalSquadContextKey struct{}
+
+// WithExternalSquad задаёт внешний сквад пользователя для CreateOrUpdateUser вместо EXTERNAL_SQUAD_UUID.
+// uuid.Nil оставляет сквад по умолчанию
+func WithExternalSquad(ctx context.Context, squad uuid.UUID) context.Context {
+	if squad == uuid.Nil {
+		return ctx
+	}
+	return context.WithValue(ctx, externalSquadContextKey{}, squad)
+}
+
+// userExternalSquad внешний сквад пользователя: заданный через WithExternalSquad, иначе сквад триала или EXTERNAL_SQUAD_UUID
+func userExternalSquad(ctx context.Context, isTrialUser bool) uuid.UUID {
+	if squad, ok := ctx.Value(externalSquadContextKey{}).(uuid.UUID); ok {
+		return squad
+	}
+	if isTrialUser {
+		return config.TrialExternalSquadUUID()
+	}
+	return config.ExternalSquadUUID()
+}
@@ -0,0 +1,77 @@
+package remnawave
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ProbeResult итог последней фоновой проверки панели для /healthcheck
+type ProbeResult struct {
+	CheckedAt     time.Time
+	LastSuccessAt time.Time
+	Latency       time.Duration
+	// LastError ошибка последней проверки, пусто — панель ответила
+	LastError string
+}
+
+// Prober раз в interval проверяет панель и хранит результат: /healthcheck отдаёт его без запроса к панели,
+// сколько бы раз его ни опрашивал балансировщик
+type Prober struct {
+	ping     func(ctx context.Context) error
+	interval time.Duration
+	timeout  time.Duration
+	now      func() time.Time
+
+	mu     sync.RWMutex
+	result ProbeResult
+}
+
+func NewProber(ping func(ctx context.Context) error, interval, timeout time.Duration) *Prober {
+	return &Prober{ping: ping, interval: interval, timeout: timeout, now: time.Now}
+}
+
+// Run проверяет панель сразу и затем раз в interval, пока не отменён ctx
+func (p *Prober) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.Probe(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Probe проверяет панель один раз и сохраняет результат. Проверка, прерванная отменой ctx, не сохраняется
+func (p *Prober) Probe(ctx context.Context) {
+	probeCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	started := p.now()
+	err := p.ping(probeCtx)
+	if ctx.Err() != nil {
+		return
+	}
+	latency := p.now().Sub(started)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.result.CheckedAt = started
+	p.result.Latency = latency
+	p.result.LastError = ""
+	if err != nil {
+		p.result.LastError = err.Error()
+		return
+	}
+	p.result.LastSuccessAt = started
+}
+
+// Result возвращает итог последней проверки. Нулевой CheckedAt — проверок ещё не было
+func (p *Prober) Result() ProbeResult {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.result
+}
@@ -0,0 +1,54 @@
+package remnawave
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestProberKeepsLastSuccess(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var pingErr error
+	p := NewProber(func(context.Context) error {
+		now = now.Add(150 * time.Millisecond)
+		return pingErr
+	}, time.Minute, time.Second)
+	p.now = func() time.Time { return now }
+
+	if got := p.Result(); !got.CheckedAt.IsZero() {
+		t.Fatalf("Expected no result before the first probe, got %+v", got)
+	}
+
+	p.Probe(context.Background())
+	first := p.Result()
+	if first.LastError != "" || first.Latency != 150*time.Millisecond || !first.LastSuccessAt.Equal(first.CheckedAt) {
+		t.Fatalf("Unexpected successful probe result: %+v", first)
+	}
+
+	now = now.Add(time.Minute)
+	pingErr = errors.New("connection refused")
+	p.Probe(context.Background())
+	second := p.Result()
+	if second.LastError != "connection refused" {
+		t.Errorf("Expected probe error, got %q", second.LastError)
+	}
+	if !second.CheckedAt.After(first.CheckedAt) || !second.LastSuccessAt.Equal(first.LastSuccessAt) {
+		t.Errorf("Expected last success to stay at the first probe, got %+v", second)
+	}
+}
+
+// Проверка, прерванная остановкой бота, не считается недоступностью панели
+func TestProberIgnoresCanceledProbe(t *testing.T) {
+	p := NewProber(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, time.Minute, time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.Probe(ctx)
+	if got := p.Result(); !got.CheckedAt.IsZero() || got.LastError != "" {
+		t.Errorf("Expected canceled probe to be ignored, got %+v", got)
+	}
+}
@@ -0,0 +1,334 @@
+package remnawave
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ogen-go/ogen/validate"
+
+	"remnawave-tg-shop-bot/internal/alert"
+)
+
+// ErrCircuitOpen возвращается без обращения к панели, пока circuit breaker открыт
+var ErrCircuitOpen = errors.New("remnawave circuit breaker is open: panel is unavailable")
+
+// ErrServerError панель ответила внутренней ошибкой
+var ErrServerError = errors.New("remnawave server error")
+
+// IsUnavailable определяет ошибки недоступности панели, после которых запрос имеет смысл повторить:
+// открытый circuit breaker, внутренняя ошибка панели или ответ 429/5xx
+func IsUnavailable(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrServerError) {
+		return true
+	}
+	var statusErr *validate.UnexpectedStatusCodeError
+	return errors.As(err, &statusErr) &&
+		(statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= http.StatusInternalServerError)
+}
+
+// BreakerState состояние circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// Health состояние связи с панелью для /healthcheck
+type Health struct {
+	State               BreakerState
+	ConsecutiveFailures int
+	LastError           string
+	LastFailureAt       time.Time
+	OpenedAt            time.Time
+}
+
+// ResilienceOptions настройки таймаутов, повторов и circuit breaker
+type ResilienceOptions struct {
+	// AttemptTimeout таймаут одной попытки запроса
+	AttemptTimeout time.Duration
+	// Retries количество повторов идемпотентных запросов при временных ошибках
+	Retries int
+	// BackoffBase задержка перед первым повтором, каждая следующая вдвое больше
+	BackoffBase time.Duration
+	// BreakerThreshold количество неудачных запросов подряд, после которого breaker открывается
+	BreakerThreshold int
+	// BreakerCooldown сколько breaker остаётся открытым до пробного запроса
+	BreakerCooldown time.Duration
+}
+
+const maxBackoff = 5 * time.Second
+
+type breakerOutcome int
+
+const (
+	outcomeSuccess breakerOutcome = iota
+	outcomeFailure
+	// outcomeIgnored запрос прерван вызывающей стороной, о здоровье панели он ничего не говорит
+	outcomeIgnored
+)
+
+// circuitBreaker после серии ошибок подряд отклоняет запросы без обращения к панели,
+// а по истечении cooldown пропускает один пробный запрос
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	onOpen    func(ctx context.Context, failures int, lastErr string)
+	onClose   func(ctx context.Context)
+
+	mu          sync.Mutex
+	state       BreakerState
+	failures    int
+	probing     bool
+	openedAt    time.Time
+	lastErr     string
+	lastFailure time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     BreakerClosed,
+	}
+}
+
+// allow проверяет, можно ли отправить запрос
+func (cb *circuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case BreakerOpen:
+		if cb.now().Sub(cb.openedAt) < cb.cooldown {
+			return ErrCircuitOpen
+		}
+		cb.state = BreakerHalfOpen
+		cb.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if cb.probing {
+			return ErrCircuitOpen
+		}
+		cb.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record учитывает результат запроса, пропущенного allow
+func (cb *circuitBreaker) record(ctx context.Context, outcome breakerOutcome, err error) {
+	cb.mu.Lock()
+	wasState := cb.state
+	cb.probing = false
+
+	switch outcome {
+	case outcomeIgnored:
+		cb.mu.Unlock()
+		return
+	case outcomeSuccess:
+		cb.failures = 0
+		cb.state = BreakerClosed
+		cb.mu.Unlock()
+		if wasState != BreakerClosed {
+			slog.InfoContext(ctx, "Remnawave circuit breaker closed")
+			if cb.onClose != nil {
+				cb.onClose(ctx)
+			}
+		}
+		return
+	}
+
+	cb.failures++
+	cb.lastFailure = cb.now()
+	if err != nil {
+		cb.lastErr = err.Error()
+	}
+	opened := false
+	if wasState == BreakerHalfOpen || (wasState == BreakerClosed && cb.failures >= cb.threshold) {
+		cb.state = BreakerOpen
+		cb.openedAt = cb.now()
+		opened = wasState == BreakerClosed
+	}
+	failures, lastErr := cb.failures, cb.lastErr
+	cb.mu.Unlock()
+
+	if opened {
+		slog.ErrorContext(ctx, "Remnawave circuit breaker opened", "failures", failures, "cooldown", cb.cooldown, "error", lastErr)
+		if cb.onOpen != nil {
+			cb.onOpen(ctx, failures, lastErr)
+		}
+	}
+}
+
+func (cb *circuitBreaker) health() Health {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	state := cb.state
+	// После cooldown следующий запрос станет пробным, панель уже не считается заведомо недоступной
+	if state == BreakerOpen && cb.now().Sub(cb.openedAt) >= cb.cooldown {
+		state = BreakerHalfOpen
+	}
+	return Health{
+		State:               state,
+		ConsecutiveFailures: cb.failures,
+		LastError:           cb.lastErr,
+		LastFailureAt:       cb.lastFailure,
+		OpenedAt:            cb.openedAt,
+	}
+}
+
+// resilientTransport ограничивает каждую попытку таймаутом, повторяет идемпотентные запросы
+// при временных ошибках с экспоненциальной задержкой и пропускает запросы через circuit breaker
+type resilientTransport struct {
+	base    http.RoundTripper
+	opts    ResilienceOptions
+	breaker *circuitBreaker
+}
+
+func newResilientTransport(base http.RoundTripper, opts ResilienceOptions) *resilientTransport {
+	breaker := newCircuitBreaker(opts.BreakerThreshold, opts.BreakerCooldown)
+	breaker.onOpen = func(ctx context.Context, failures int, lastErr string) {
+		alert.Notify(context.WithoutCancel(ctx), alert.SeverityCritical, alert.KeyRemnawaveUnavailable,
+			fmt.Sprintf("Remnawave недоступен: %d ошибок подряд, запросы к панели отклоняются на %s.\nПоследняя ошибка: %s",
+				failures, opts.BreakerCooldown, lastErr))
+	}
+	breaker.onClose = func(ctx context.Context) {
+		alert.Resolve(context.WithoutCancel(ctx), alert.KeyRemnawaveUnavailable, "Remnawave снова доступен")
+	}
+	return &resilientTransport{base: base, opts: opts, breaker: breaker}
+}
+
+func (t *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	if isIdempotent(req.Method) {
+		attempts += t.opts.Retries
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err := t.breaker.allow(); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.attempt(req, attempt)
+		outcome := classify(req.Context(), resp, err)
+		t.breaker.record(req.Context(), outcome, attemptError(resp, err))
+
+		if outcome != outcomeFailure && !isRetryableStatus(resp) || attempt >= attempts {
+			return resp, err
+		}
+
+		delay := backoff(t.opts.BackoffBase, attempt)
+		slog.WarnContext(req.Context(), "Retrying Remnawave request", "method", req.Method, "path", req.URL.Path,
+			"attempt", attempt, "maxAttempts", attempts, "delay", delay, "error", attemptError(resp, err))
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// attempt выполняет одну попытку с собственным таймаутом. Таймаут действует и на чтение тела ответа
+func (t *resilientTransport) attempt(req *http.Request, attempt int) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.opts.AttemptTimeout)
+	r := req.Clone(ctx)
+	if attempt > 1 && req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			cancel()
+			return nil, errors.New("remnawave: request body cannot be replayed for retry")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		r.Body = body
+	}
+
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// classify определяет, говорит ли результат попытки о недоступности панели
+func classify(ctx context.Context, resp *http.Response, err error) breakerOutcome {
+	if err != nil {
+		if ctx.Err() != nil {
+			return outcomeIgnored
+		}
+		return outcomeFailure
+	}
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return outcomeFailure
+	}
+	return outcomeSuccess
+}
+
+// isRetryableStatus 429: панель жива, но просит подождать — повторяем, не считая это сбоем
+func isRetryableStatus(resp *http.Response) bool {
+	return resp != nil && resp.StatusCode == http.StatusTooManyRequests
+}
+
+func attemptError(resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	if resp != nil && resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%w: status %d", ErrServerError, resp.StatusCode)
+	}
+	return nil
+}
+
+// isIdempotent повторяем только запросы, которые безопасно отправить дважды.
+// POST создаёт пользователя: повтор после таймаута мог бы создать дубликат
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+func backoff(base time.Duration, attempt int) time.Duration {
+	delay := base << (attempt - 1)
+	if delay <= 0 || delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,223 @@
+package remnawave
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testOptions() ResilienceOptions {
+	return ResilienceOptions{
+		AttemptTimeout:   200 * time.Millisecond,
+		Retries:          2,
+		BackoffBase:      time.Millisecond,
+		BreakerThreshold: 3,
+		BreakerCooldown:  time.Minute,
+	}
+}
+
+func newTestClient(opts ResilienceOptions) (*http.Client, *resilientTransport) {
+	transport := newResilientTransport(http.DefaultTransport, opts)
+	return &http.Client{Transport: transport}, transport
+}
+
+func TestResilientTransport_RetriesTransientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"days":30}` {
+			t.Errorf("Expected body to be replayed, got %q", body)
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client, transport := newTestClient(testOptions())
+	req, _ := http.NewRequest(http.MethodPatch, srv.URL, strings.NewReader(`{"days":30}`))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Errorf("Expected 200 ok, got %d %q", resp.StatusCode, body)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls.Load())
+	}
+	if h := transport.breaker.health(); h.State != BreakerClosed || h.ConsecutiveFailures != 0 {
+		t.Errorf("Expected breaker to be closed after success, got %+v", h)
+	}
+}
+
+func TestResilientTransport_DoesNotRetryPost(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	client, _ := newTestClient(testOptions())
+	resp, err := client.Post(srv.URL, "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("Expected error response to be returned, got %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %d", resp.StatusCode)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected POST to be sent once, got %d", calls.Load())
+	}
+}
+
+func TestResilientTransport_AttemptTimeout(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client, _ := newTestClient(testOptions())
+	started := time.Now()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected retry after slow attempt, got %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Expected slow attempt to be cut by timeout, took %s", elapsed)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected 2 attempts, got %d", calls.Load())
+	}
+}
+
+func TestResilientTransport_BreakerOpensAndFailsFast(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client, transport := newTestClient(testOptions())
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected last error response, got %v", err)
+	}
+	_ = resp.Body.Close()
+
+	h := transport.breaker.health()
+	if h.State != BreakerOpen || h.ConsecutiveFailures != 3 || h.LastError == "" {
+		t.Fatalf("Expected breaker to open after 3 failures, got %+v", h)
+	}
+
+	_, err = client.Get(srv.URL)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected no requests while breaker is open, got %d", calls.Load())
+	}
+}
+
+func TestCircuitBreaker_HalfOpenProbe(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	cb := newCircuitBreaker(2, 30*time.Second)
+	cb.now = func() time.Time { return now }
+	var opened, closed int
+	cb.onOpen = func(context.Context, int, string) { opened++ }
+	cb.onClose = func(context.Context) { closed++ }
+	ctx := context.Background()
+	failure := errors.New("dial tcp: connection refused")
+
+	for i := 0; i < 2; i++ {
+		if err := cb.allow(); err != nil {
+			t.Fatalf("Expected closed breaker to allow, got %v", err)
+		}
+		cb.record(ctx, outcomeFailure, failure)
+	}
+	if err := cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected open breaker to reject, got %v", err)
+	}
+
+	now = now.Add(31 * time.Second)
+	if h := cb.health(); h.State != BreakerHalfOpen {
+		t.Errorf("Expected half-open after cooldown, got %s", h.State)
+	}
+	if err := cb.allow(); err != nil {
+		t.Fatalf("Expected probe to be allowed, got %v", err)
+	}
+	if err := cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected only one probe at a time, got %v", err)
+	}
+
+	// Неудачная проба снова открывает breaker без повторного алерта
+	cb.record(ctx, outcomeFailure, failure)
+	if err := cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected breaker to reopen after failed probe, got %v", err)
+	}
+
+	now = now.Add(31 * time.Second)
+	if err := cb.allow(); err != nil {
+		t.Fatalf("Expected second probe to be allowed, got %v", err)
+	}
+	cb.record(ctx, outcomeSuccess, nil)
+	if h := cb.health(); h.State != BreakerClosed || h.ConsecutiveFailures != 0 {
+		t.Errorf("Expected breaker to close after successful probe, got %+v", h)
+	}
+	if opened != 1 || closed != 1 {
+		t.Errorf("Expected one open and one close notification, got %d/%d", opened, closed)
+	}
+}
+
+func TestCircuitBreaker_IgnoredOutcomeReleasesProbe(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	cb := newCircuitBreaker(1, time.Second)
+	cb.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_ = cb.allow()
+	cb.record(ctx, outcomeFailure, errors.New("timeout"))
+	now = now.Add(2 * time.Second)
+
+	if err := cb.allow(); err != nil {
+		t.Fatalf("Expected probe to be allowed, got %v", err)
+	}
+	cb.record(ctx, outcomeIgnored, context.Canceled)
+	if err := cb.allow(); err != nil {
+		t.Errorf("Expected cancelled probe to free the slot for the next one, got %v", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base := 500 * time.Millisecond
+	for attempt, want := range map[int]time.Duration{1: 500 * time.Millisecond, 2: time.Second, 3: 2 * time.Second, 5: maxBackoff, 70: maxBackoff} {
+		if got := backoff(base, attempt); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
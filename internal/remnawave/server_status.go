@@ -0,0 +1,126 @@
+package remnawave
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/v2/api"
+)
+
+// NodeStatus состояние ноды панели
+type NodeStatus struct {
+	Name        string
+	CountryCode string
+	// Online нода подключена к панели и Xray на ней запущен
+	Online   bool
+	Disabled bool
+	// UsersOnline пользователей на ноде сейчас
+	UsersOnline int
+	// TrafficUsedBytes и TrafficLimitBytes — расход и лимит трафика ноды, лимит 0 означает безлимит
+	TrafficUsedBytes  int64
+	TrafficLimitBytes int64
+	// Message последнее сообщение панели о состоянии ноды, например причина отключения
+	Message string
+}
+
+// ServerStatus сводка по панели для экрана статуса серверов
+type ServerStatus struct {
+	Nodes []NodeStatus
+	// OnlineNow и OnlineLastDay — пользователей онлайн сейчас и за последние сутки
+	OnlineNow     int
+	OnlineLastDay int
+	// BandwidthToday, BandwidthYesterday и BandwidthMonth — трафик всех нод в виде, отформатированном панелью
+	BandwidthToday     string
+	BandwidthYesterday string
+	BandwidthMonth     string
+	FetchedAt          time.Time
+}
+
+// OnlineNodes количество работающих нод, выключенные не учитываются
+func (s *ServerStatus) OnlineNodes() (online, total int) {
+	for _, node := range s.Nodes {
+		if node.Disabled {
+			continue
+		}
+		total++
+		if node.Online {
+			online++
+		}
+	}
+	return online, total
+}
+
+// ServerStatus получает ноды, число пользователей онлайн и трафик из панели
+func (r *Client) ServerStatus(ctx context.Context) (*ServerStatus, error) {
+	nodesResp, err := r.client.NodesControllerGetAllNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var status ServerStatus
+	switch v := nodesResp.(type) {
+	case *remapi.NodesResponse:
+		status.Nodes = nodeStatuses(v.GetResponse())
+	default:
+		return nil, errors.New("unknown response type")
+	}
+
+	statsResp, err := r.client.SystemControllerGetStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch v := statsResp.(type) {
+	case *remapi.GetStatsResponseDto:
+		online := v.GetResponse().OnlineStats
+		status.OnlineNow = int(online.OnlineNow)
+		status.OnlineLastDay = int(online.LastDay)
+	default:
+		return nil, errors.New("unknown response type")
+	}
+
+	bandwidthResp, err := r.client.SystemControllerGetBandwidthStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch v := bandwidthResp.(type) {
+	case *remapi.GetBandwidthStatsResponseDto:
+		bandwidth := v.GetResponse()
+		status.BandwidthToday = bandwidth.BandwidthLastTwoDays.Current
+		status.BandwidthYesterday = bandwidth.BandwidthLastTwoDays.Previous
+		status.BandwidthMonth = bandwidth.BandwidthCalendarMonth.Current
+	default:
+		return nil, errors.New("unknown response type")
+	}
+
+	status.FetchedAt = time.Now()
+	return &status, nil
+}
+
+// nodeStatuses переводит ноды из ответа панели; порядок — как в панели (viewPosition)
+func nodeStatuses(items []remapi.NodesResponseResponseItem) []NodeStatus {
+	sort.SliceStable(items, func(i, j int) bool { return items[i].ViewPosition < items[j].ViewPosition })
+	nodes := make([]NodeStatus, 0, len(items))
+	for _, item := range items {
+		node := NodeStatus{
+			Name:        item.Name,
+			CountryCode: item.CountryCode,
+			Online:      item.IsConnected && item.IsNodeOnline && item.IsXrayRunning,
+			Disabled:    item.IsDisabled,
+		}
+		if users, ok := item.UsersOnline.Get(); ok {
+			node.UsersOnline = users
+		}
+		if used, ok := item.TrafficUsedBytes.Get(); ok {
+			node.TrafficUsedBytes = int64(used)
+		}
+		if limit, ok := item.TrafficLimitBytes.Get(); ok {
+			node.TrafficLimitBytes = int64(limit)
+		}
+		if message, ok := item.LastStatusMessage.Get(); ok {
+			node.Message = message
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
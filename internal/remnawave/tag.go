@@ -0,0 +1,63 @@
+package remnawave
+
+import (
+	"context"
+	"strings"
+
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+// maxTagLength максимальная длина тега пользователя в панели
+const maxTagLength = 16
+
+type tagContextKey struct{}
+
+// WithTag задаёт тег пользователя в панели для CreateOrUpdateUser вместо REMNAWAVE_TAG
+func WithTag(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, tagContextKey{}, tag)
+}
+
+// TariffTag тег панели для тарифа: имя тарифа в верхнем регистре, недопустимые символы заменены на _.
+// Пустая строка, если в имени нет ни одной латинской буквы или цифры
+func TariffTag(name string) string {
+	var sb strings.Builder
+	meaningful := false
+	for _, r := range strings.ToUpper(strings.TrimSpace(name)) {
+		if sb.Len() == maxTagLength {
+			break
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			meaningful = true
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	if !meaningful {
+		return ""
+	}
+	return sb.String()
+}
+
+// createTag тег нового пользователя: заданный через WithTag, иначе тег триала или REMNAWAVE_TAG
+func createTag(ctx context.Context, isTrialUser bool) string {
+	if tag, ok := ctx.Value(tagContextKey{}).(string); ok {
+		return tag
+	}
+	if isTrialUser {
+		return config.TrialRemnawaveTag()
+	}
+	return config.RemnawaveTag()
+}
+
+// updateTag тег при обновлении пользователя. При синхронизации тегов продления без WithTag
+// (реферальные бонусы, промокоды) тег не меняют — ok=false
+func updateTag(ctx context.Context) (tag string, ok bool) {
+	if tag, ok := ctx.Value(tagContextKey{}).(string); ok {
+		return tag, true
+	}
+	if config.IsRemnawaveTagSyncEnabled() {
+		return "", false
+	}
+	return config.RemnawaveTag(), true
+}
@@ -0,0 +1,49 @@
+package remnawave
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestTariffTag(t *testing.T) {
+	cases := map[string]string{
+		"PRO":                   "PRO",
+		"start":                 "START",
+		" Family plus ":         "FAMILY_PLUS",
+		"vip-2":                 "VIP_2",
+		"Безлимит":              "",
+		"Тариф 3":               "______3",
+		"VERYLONGTARIFFNAME123": "VERYLONGTARIFFNA",
+	}
+	for name, want := range cases {
+		if got := TariffTag(name); got != want {
+			t.Errorf("TariffTag(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestContextTagOverridesConfig(t *testing.T) {
+	ctx := WithTag(context.Background(), "PRO")
+	if got := createTag(ctx, true); got != "PRO" {
+		t.Errorf("createTag must use context tag for trial users too, got %q", got)
+	}
+	if tag, ok := updateTag(ctx); !ok || tag != "PRO" {
+		t.Errorf("updateTag = %q, %v, want PRO, true", tag, ok)
+	}
+}
+
+func TestContextExternalSquadOverridesConfig(t *testing.T) {
+	squad := uuid.MustParse("6f1c2d3e-4b5a-4c7d-8e9f-0a1b2c3d4e5f")
+	ctx := WithExternalSquad(context.Background(), squad)
+	if got := userExternalSquad(ctx, false); got != squad {
+		t.Errorf("userExternalSquad = %s, want %s", got, squad)
+	}
+	if got := userExternalSquad(ctx, true); got != squad {
+		t.Errorf("userExternalSquad must use context squad for trial users too, got %s", got)
+	}
+	if WithExternalSquad(ctx, uuid.Nil) != ctx {
+		t.Error("WithExternalSquad(uuid.Nil) must keep context unchanged")
+	}
+}
@@ -0,0 +1,254 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"remnawave-tg-shop-bot/internal/alert"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/logging"
+)
+
+var (
+	ErrUnknownJob     = errors.New("unknown job")
+	ErrAlreadyRunning = errors.New("job is already running")
+)
+
+// Func тело задачи. Контекст ограничен таймаутом задачи и содержит request id запуска для логов
+type Func func(ctx context.Context) error
+
+// Job описание фоновой задачи
+type Job struct {
+	Name     string
+	Title    string // название для админки
+	Schedule string
+	// Timeout ограничивает один запуск. Ноль — без ограничения
+	Timeout time.Duration
+	// Jitter случайная задержка перед запуском по расписанию (от 0 до Jitter),
+	// чтобы задачи с одинаковым расписанием не обращались к внешним API одновременно
+	Jitter time.Duration
+	Run    Func
+}
+
+// Status состояние задачи и накопленные метрики запусков
+type Status struct {
+	Name         string
+	Title        string
+	Schedule     string
+	Running      bool
+	LastRun      time.Time
+	LastDuration time.Duration
+	LastError    string
+	Runs         int64
+	Failures     int64
+	Skipped      int64 // запуски по расписанию, пропущенные из-за незавершённого предыдущего
+	MaxDuration  time.Duration
+}
+
+type job struct {
+	Job
+	status Status
+}
+
+// Scheduler запускает фоновые задачи по cron-расписанию: перехватывает паники, ограничивает время запуска,
+// не допускает наложения запусков одной задачи и собирает метрики для админки
+type Scheduler struct {
+	cron *cron.Cron
+	now  func() time.Time
+	// stopped отменяется в Stop, чтобы не ждать задачи, которые ещё выдерживают jitter
+	stopped context.Context
+	stop    context.CancelFunc
+	// runCtx базовый контекст запусков, отменяется, если задачи не успели завершиться за время остановки
+	runCtx    context.Context
+	cancelRun context.CancelFunc
+	running   sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs []*job
+}
+
+func New() *Scheduler {
+	stopped, stop := context.WithCancel(context.Background())
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	return &Scheduler{
+		cron:      cron.New(cron.WithParser(config.CronParser), cron.WithLocation(config.Timezone())),
+		now:       time.Now,
+		stopped:   stopped,
+		stop:      stop,
+		runCtx:    runCtx,
+		cancelRun: cancelRun,
+	}
+}
+
+// Add регистрирует задачу
+func (s *Scheduler) Add(j Job) error {
+	registered := &job{Job: j, status: Status{Name: j.Name, Title: j.Title, Schedule: j.Schedule}}
+	if _, err := s.cron.AddFunc(j.Schedule, func() { s.scheduled(registered) }); err != nil {
+		return fmt.Errorf("add job %s: %w", j.Name, err)
+	}
+
+	s.mu.Lock()
+	s.jobs = append(s.jobs, registered)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop останавливает расписание и ждёт завершения выполняющихся задач
+func (s *Scheduler) Stop() {
+	_ = s.Shutdown(context.Background())
+}
+
+// Shutdown останавливает расписание и ждёт выполняющиеся задачи, включая запущенные вручную, до истечения ctx.
+// Задачам, не успевшим завершиться, отменяется контекст
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.stop()
+	s.mu.Unlock()
+	s.cron.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancelRun()
+		var running []string
+		for _, st := range s.Statuses() {
+			if st.Running {
+				running = append(running, st.Name)
+			}
+		}
+		return fmt.Errorf("jobs still running: %v", running)
+	}
+}
+
+// RunNow запускает задачу вне расписания в фоне, без задержки
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	var found *job
+	for _, j := range s.jobs {
+		if j.Name == name {
+			found = j
+			break
+		}
+	}
+	if found == nil {
+		s.mu.Unlock()
+		return ErrUnknownJob
+	}
+	if found.status.Running {
+		s.mu.Unlock()
+		return ErrAlreadyRunning
+	}
+	s.mu.Unlock()
+
+	go s.run(found)
+	return nil
+}
+
+// Statuses возвращает состояние задач в порядке регистрации
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		statuses = append(statuses, j.status)
+	}
+	return statuses
+}
+
+func (s *Scheduler) scheduled(j *job) {
+	if j.Jitter > 0 {
+		t := time.NewTimer(rand.N(j.Jitter))
+		defer t.Stop()
+		select {
+		case <-s.stopped.Done():
+			return
+		case <-t.C:
+		}
+	}
+	s.run(j)
+}
+
+func (s *Scheduler) run(j *job) {
+	s.mu.Lock()
+	// После остановки новые запуски не начинаются: Shutdown уже ждёт выполняющиеся
+	if s.stopped.Err() != nil {
+		s.mu.Unlock()
+		return
+	}
+	if j.status.Running {
+		j.status.Skipped++
+		s.mu.Unlock()
+		slog.Warn("Job is still running, skipping", "job", j.Name)
+		return
+	}
+	j.status.Running = true
+	j.status.Runs++
+	runID := fmt.Sprintf("job-%s-%d", j.Name, j.status.Runs)
+	s.running.Add(1)
+	s.mu.Unlock()
+	defer s.running.Done()
+
+	ctx := logging.WithRequestID(s.runCtx, runID)
+	slog.DebugContext(ctx, "Job started", "job", j.Name)
+
+	start := s.now()
+	err := s.call(ctx, j)
+	duration := s.now().Sub(start)
+
+	s.mu.Lock()
+	j.status.Running = false
+	j.status.LastRun = start
+	j.status.LastDuration = duration
+	if duration > j.status.MaxDuration {
+		j.status.MaxDuration = duration
+	}
+	j.status.LastError = ""
+	if err != nil {
+		j.status.LastError = err.Error()
+		j.status.Failures++
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		slog.ErrorContext(ctx, "Job failed", "job", j.Name, "duration", duration, "error", err)
+		return
+	}
+	slog.DebugContext(ctx, "Job finished", "job", j.Name, "duration", duration)
+}
+
+// call выполняет задачу, превращая панику в ошибку и алерт админу
+func (s *Scheduler) call(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "Panic in job", "job", j.Name, "panic", r)
+			alert.NotifyPanic(j.Name, r)
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+	return j.Run(ctx)
+}
@@ -0,0 +1,212 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func waitIdle(t *testing.T, s *Scheduler, name string) Status {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		for _, st := range s.Statuses() {
+			if st.Name == name && !st.Running && !st.LastRun.IsZero() {
+				return st
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", name)
+	return Status{}
+}
+
+func TestRunNowRecordsResult(t *testing.T) {
+	s := New()
+	if err := s.Add(Job{Name: "ok", Title: "OK", Schedule: "@every 1h", Timeout: time.Second, Run: func(ctx context.Context) error { return nil }}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := s.Add(Job{Name: "fail", Title: "Fail", Schedule: "0 * * * *", Run: func(ctx context.Context) error { return errors.New("boom") }}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	if err := s.RunNow("ok"); err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+	if err := s.RunNow("fail"); err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+
+	if st := waitIdle(t, s, "ok"); st.LastError != "" {
+		t.Errorf("Expected no error, got %q", st.LastError)
+	}
+	if st := waitIdle(t, s, "fail"); st.LastError != "boom" {
+		t.Errorf("Expected error boom, got %q", st.LastError)
+	}
+}
+
+func TestRunNowRecoversPanic(t *testing.T) {
+	s := New()
+	_ = s.Add(Job{Name: "panic", Title: "Panic", Schedule: "*/10 * * * * *", Run: func(ctx context.Context) error { panic("oops") }})
+
+	if err := s.RunNow("panic"); err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+	if st := waitIdle(t, s, "panic"); st.LastError != "panic: oops" {
+		t.Errorf("Expected panic error, got %q", st.LastError)
+	}
+}
+
+func TestRunNowSkipsRunningJob(t *testing.T) {
+	s := New()
+	release := make(chan struct{})
+	started := make(chan struct{})
+	_ = s.Add(Job{Name: "slow", Title: "Slow", Schedule: "0 * * * *", Run: func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}})
+
+	if err := s.RunNow("slow"); err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+	<-started
+	if err := s.RunNow("slow"); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("Expected ErrAlreadyRunning, got %v", err)
+	}
+	close(release)
+	waitIdle(t, s, "slow")
+}
+
+func TestRunNowUnknownJob(t *testing.T) {
+	s := New()
+	if err := s.RunNow("missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Expected ErrUnknownJob, got %v", err)
+	}
+}
+
+func TestAddInvalidSchedule(t *testing.T) {
+	s := New()
+	if err := s.Add(Job{Name: "bad", Title: "Bad", Schedule: "not a schedule", Run: func(ctx context.Context) error { return nil }}); err == nil {
+		t.Error("Expected error for invalid schedule")
+	}
+}
+
+func TestRunCountsMetrics(t *testing.T) {
+	s := New()
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	fail := true
+	_ = s.Add(Job{Name: "metrics", Title: "Metrics", Schedule: "0 * * * *", Run: func(ctx context.Context) error {
+		started <- struct{}{}
+		<-release
+		if fail {
+			return errors.New("first run fails")
+		}
+		return nil
+	}})
+	j := s.jobs[0]
+
+	go s.run(j)
+	<-started
+	// Запуск по расписанию во время выполнения пропускается
+	s.run(j)
+	release <- struct{}{}
+	waitIdle(t, s, "metrics")
+
+	fail = false
+	go s.run(j)
+	<-started
+	release <- struct{}{}
+	deadline := time.Now().Add(time.Second)
+	for s.Statuses()[0].Runs != 2 || s.Statuses()[0].Running {
+		if time.Now().After(deadline) {
+			t.Fatal("second run did not finish")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	st := s.Statuses()[0]
+	if st.Runs != 2 || st.Failures != 1 || st.Skipped != 1 {
+		t.Errorf("Expected 2 runs, 1 failure, 1 skipped, got %d, %d, %d", st.Runs, st.Failures, st.Skipped)
+	}
+	if st.LastError != "" {
+		t.Errorf("Expected last error to be cleared, got %q", st.LastError)
+	}
+}
+
+func TestRunAppliesTimeout(t *testing.T) {
+	s := New()
+	_ = s.Add(Job{Name: "timeout", Title: "Timeout", Schedule: "0 * * * *", Timeout: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+
+	_ = s.RunNow("timeout")
+	if st := waitIdle(t, s, "timeout"); st.LastError != context.DeadlineExceeded.Error() {
+		t.Errorf("Expected deadline exceeded, got %q", st.LastError)
+	}
+}
+
+func TestScheduledJitterCancelledOnStop(t *testing.T) {
+	s := New()
+	ran := false
+	_ = s.Add(Job{Name: "jitter", Title: "Jitter", Schedule: "0 * * * *", Jitter: time.Hour, Run: func(ctx context.Context) error {
+		ran = true
+		return nil
+	}})
+
+	done := make(chan struct{})
+	go func() {
+		s.scheduled(s.jobs[0])
+		close(done)
+	}()
+	s.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected jitter wait to stop")
+	}
+	if ran {
+		t.Error("Expected job not to run after stop")
+	}
+}
+
+func TestShutdownWaitsForManualRunAndCancelsOnTimeout(t *testing.T) {
+	s := New()
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	_ = s.Add(Job{Name: "stuck", Title: "Stuck", Schedule: "0 * * * *", Run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(cancelled)
+		return ctx.Err()
+	}})
+	s.Start()
+
+	if err := s.RunNow("stuck"); err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); err == nil || !strings.Contains(err.Error(), "stuck") {
+		t.Errorf("Expected timeout error naming the job, got %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("Expected job context to be cancelled after shutdown timeout")
+	}
+
+	if err := s.RunNow("stuck"); err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+	if st := waitIdle(t, s, "stuck"); st.Runs != 1 {
+		t.Errorf("Expected no new runs after shutdown, got %d", st.Runs)
+	}
+}
@@ -0,0 +1,152 @@
+package shutdown
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+// Виды отслеживаемой работы
+const (
+	KindUpdate    = "update"
+	KindPayment   = "payment"
+	KindBroadcast = "broadcast"
+)
+
+// Coordinator учитывает незавершённую работу, чтобы при остановке бота дождаться её,
+// а не обрывать выдачу оплаченной подписки на середине
+type Coordinator struct {
+	stopping chan struct{}
+
+	mu     sync.Mutex
+	begun  bool
+	active map[string]int
+	total  int
+	idle   chan struct{}
+}
+
+func New() *Coordinator {
+	return &Coordinator{
+		stopping: make(chan struct{}),
+		active:   make(map[string]int),
+	}
+}
+
+// Track отмечает начало работы вида kind. Возвращённую функцию нужно вызвать по завершении.
+// Работа принимается и во время остановки: отказ посреди уже оплаченной покупки хуже ожидания
+func (c *Coordinator) Track(kind string) func() {
+	c.mu.Lock()
+	c.active[kind]++
+	c.total++
+	c.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.active[kind]--
+			if c.active[kind] == 0 {
+				delete(c.active, kind)
+			}
+			c.total--
+			if c.total == 0 && c.idle != nil {
+				close(c.idle)
+				c.idle = nil
+			}
+		})
+	}
+}
+
+// Begin начинает остановку: закрывает канал Stopping, по которому долгие задачи сохраняют прогресс и выходят
+func (c *Coordinator) Begin() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.begun {
+		c.begun = true
+		close(c.stopping)
+	}
+}
+
+// Stopping закрывается, когда началась остановка
+func (c *Coordinator) Stopping() <-chan struct{} {
+	return c.stopping
+}
+
+// Wait ждёт завершения всей отслеживаемой работы или истечения ctx.
+// При таймауте возвращает ошибку со списком незавершённой работы
+func (c *Coordinator) Wait(ctx context.Context) error {
+	c.mu.Lock()
+	if c.total == 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	if c.idle == nil {
+		c.idle = make(chan struct{})
+	}
+	idle := c.idle
+	c.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("work still in progress: %s", formatActive(c.Active()))
+	}
+}
+
+// Active возвращает количество незавершённой работы по видам
+func (c *Coordinator) Active() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	active := make(map[string]int, len(c.active))
+	for kind, n := range c.active {
+		active[kind] = n
+	}
+	return active
+}
+
+func formatActive(active map[string]int) string {
+	parts := make([]string, 0, len(active))
+	for kind, n := range active {
+		parts = append(parts, fmt.Sprintf("%s=%d", kind, n))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+var defaultCoordinator = New()
+
+// Track отмечает начало работы в общем координаторе
+func Track(kind string) func() {
+	return defaultCoordinator.Track(kind)
+}
+
+// Begin начинает остановку общего координатора
+func Begin() {
+	defaultCoordinator.Begin()
+}
+
+// Stopping закрывается, когда началась остановка бота
+func Stopping() <-chan struct{} {
+	return defaultCoordinator.Stopping()
+}
+
+// Wait ждёт завершения работы, отмеченной в общем координаторе
+func Wait(ctx context.Context) error {
+	return defaultCoordinator.Wait(ctx)
+}
+
+// BotMiddleware учитывает обработку каждого обновления и отвязывает её от отмены контекста бота:
+// при остановке новые обновления не принимаются, а начатые доводятся до конца
+func BotMiddleware(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(ctx context.Context, b *bot.Bot, update *models.Update) {
+		defer Track(KindUpdate)()
+		next(context.WithoutCancel(ctx), b, update)
+	}
+}
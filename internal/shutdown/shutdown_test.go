@@ -0,0 +1,68 @@
+package shutdown
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCoordinator_WaitsForTrackedWork(t *testing.T) {
+	c := New()
+	donePayment := c.Track(KindPayment)
+	doneBroadcast := c.Track(KindBroadcast)
+
+	c.Begin()
+	select {
+	case <-c.Stopping():
+	default:
+		t.Fatal("Expected Stopping to be closed after Begin")
+	}
+
+	waited := make(chan error, 1)
+	go func() { waited <- c.Wait(context.Background()) }()
+
+	donePayment()
+	donePayment() // повторный вызов не должен уменьшать счётчик дважды
+	select {
+	case err := <-waited:
+		t.Fatalf("Expected Wait to block while broadcast is active, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	doneBroadcast()
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Errorf("Expected nil after all work finished, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Wait to return after all work finished")
+	}
+}
+
+func TestCoordinator_WaitTimeoutReportsActiveWork(t *testing.T) {
+	c := New()
+	defer c.Track(KindPayment)()
+	c.Track(KindUpdate)
+	c.Track(KindUpdate)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := c.Wait(ctx)
+	if err == nil {
+		t.Fatal("Expected timeout error")
+	}
+	if !strings.Contains(err.Error(), "payment=1, update=2") {
+		t.Errorf("Expected active work in error, got %v", err)
+	}
+}
+
+func TestCoordinator_WaitWithoutWork(t *testing.T) {
+	c := New()
+	c.Begin()
+	c.Begin()
+	if err := c.Wait(context.Background()); err != nil {
+		t.Errorf("Expected nil without tracked work, got %v", err)
+	}
+}
@@ -2,9 +2,14 @@ package sync
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/events"
 	"remnawave-tg-shop-bot/internal/remnawave"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type SyncService struct {
@@ -12,32 +17,52 @@ type SyncService struct {
 	customerRepository *database.CustomerRepository
 }
 
+// syncTimeout ограничивает одну синхронизацию: выгрузка пользователей панели и пакетная запись в БД
+const syncTimeout = 10 * time.Minute
+
 func NewSyncService(client *remnawave.Client, customerRepository *database.CustomerRepository) *SyncService {
 	return &SyncService{
 		client: client, customerRepository: customerRepository,
 	}
 }
 
-func (s SyncService) Sync() {
-	slog.Info("Starting sync")
-	ctx := context.Background()
+// Sync приводит клиентов в БД к пользователям панели. При отмене ctx клиенты не удаляются и не создаются
+func (s *SyncService) Sync(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+
+	slog.InfoContext(ctx, "Starting sync")
+	startedAt := time.Now()
 	var telegramIDs []int64
 	telegramIDsSet := make(map[int64]int64)
 	var mappedUsers []database.Customer
 	users, err := s.client.GetUsers(ctx)
 	if err != nil {
-		slog.Error("Error while getting users from remnawave", "error", err)
+		slog.ErrorContext(ctx, "Error while getting users from remnawave", "error", err)
 		return
 	}
 	if users == nil || len(*users) == 0 {
-		slog.Error("No users found in remnawave")
+		slog.ErrorContext(ctx, "No users found in remnawave")
 		return
 	}
 
+	// При нескольких пользователях с одним Telegram ID клиент получает данные основного (с самой поздней датой окончания)
+	duplicates := remnawave.FindDuplicates(*users)
+	primaryUUIDs := make(map[int64]uuid.UUID, len(duplicates))
+	for _, group := range duplicates {
+		primaryUUIDs[group.TelegramID] = group.Users[0].UUID
+	}
+	if len(duplicates) > 0 {
+		slog.WarnContext(ctx, "Found telegram ids with several enabled panel users, see /admin", "count", len(duplicates))
+	}
+
 	for _, user := range *users {
 		if user.TelegramId.Null {
 			continue
 		}
+		if primary, ok := primaryUUIDs[int64(user.TelegramId.Value)]; ok && primary != user.UUID {
+			continue
+		}
 		if _, exists := telegramIDsSet[int64(user.TelegramId.Value)]; exists {
 			continue
 		}
@@ -55,7 +80,7 @@ func (s SyncService) Sync() {
 
 	existingCustomers, err := s.customerRepository.FindByTelegramIds(ctx, telegramIDs)
 	if err != nil {
-		slog.Error("Error while searching users by telegram ids")
+		slog.ErrorContext(ctx, "Error while searching users by telegram ids")
 		return
 	}
 	existingMap := make(map[int64]database.Customer)
@@ -65,38 +90,94 @@ func (s SyncService) Sync() {
 
 	var toCreate []database.Customer
 	var toUpdate []database.Customer
+	restored := 0
 
 	for _, cust := range mappedUsers {
 		if existing, found := existingMap[cust.TelegramID]; found {
 			cust.ID = existing.ID
 			cust.CreatedAt = existing.CreatedAt
 			cust.Language = existing.Language
+			// Клиент снова появился в панели: обновление снимает пометку удаления
+			if existing.DeletedAt != nil {
+				restored++
+			}
 			toUpdate = append(toUpdate, cust)
 		} else {
 			toCreate = append(toCreate, cust)
 		}
 	}
 
-	err = s.customerRepository.DeleteByNotInTelegramIds(ctx, telegramIDs)
+	// После отмены БД не меняем, чтобы не оставить её обновлённой наполовину
+	if err := ctx.Err(); err != nil {
+		slog.WarnContext(ctx, "Sync cancelled before applying changes", "error", err)
+		return
+	}
+
+	// Клиентов, которых нет в панели, только помечаем удалёнными: история покупок сохраняется до очистки админом
+	deleted, err := s.customerRepository.SoftDeleteByNotInTelegramIds(ctx, telegramIDs, time.Now())
 	if err != nil {
-		slog.Error("Error while deleting users")
+		slog.ErrorContext(ctx, "Error while deleting users", "error", err)
+	} else {
+		slog.InfoContext(ctx, "Marked clients which not exist in panel as deleted", "count", deleted)
 	}
-	slog.Info("Deleted clients which not exist in panel")
 
+	created, updated := 0, 0
 	if len(toCreate) > 0 {
 		if err := s.customerRepository.CreateBatch(ctx, toCreate); err != nil {
-			slog.Error("Error while creating users")
+			slog.ErrorContext(ctx, "Error while creating users")
 		} else {
-			slog.Info("Created clients", "count", len(toCreate))
+			created = len(toCreate)
+			slog.InfoContext(ctx, "Created clients", "count", len(toCreate))
 		}
 	}
 
 	if len(toUpdate) > 0 {
 		if err := s.customerRepository.UpdateBatch(ctx, toUpdate); err != nil {
-			slog.Error("Error while updating users")
+			slog.ErrorContext(ctx, "Error while updating users")
 		} else {
-			slog.Info("Updated clients", "count", len(toUpdate))
+			updated = len(toUpdate)
+			slog.InfoContext(ctx, "Updated clients", "count", len(toUpdate), "restored", restored)
 		}
 	}
-	slog.Info("Synchronization completed")
+	slog.InfoContext(ctx, "Synchronization completed")
+	events.Publish(ctx, events.SyncCompleted(events.Sync{
+		Users:           len(mappedUsers),
+		Created:         created,
+		Updated:         updated,
+		DurationSeconds: time.Since(startedAt).Seconds(),
+	}))
+}
+
+// FindDuplicates возвращает Telegram ID, к которым в панели привязано несколько включённых пользователей
+func (s *SyncService) FindDuplicates(ctx context.Context) ([]remnawave.DuplicateGroup, error) {
+	users, err := s.client.GetUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return remnawave.FindDuplicates(*users), nil
+}
+
+// MergeDuplicates объединяет пользователей панели с этим Telegram ID (см. remnawave.Client.MergeDuplicates)
+// и сразу переводит клиента на основного пользователя, не дожидаясь синхронизации
+func (s *SyncService) MergeDuplicates(ctx context.Context, telegramID int64) (*remnawave.MergeResult, error) {
+	result, err := s.client.MergeDuplicates(ctx, telegramID)
+	if err != nil {
+		return result, err
+	}
+
+	customer, err := s.customerRepository.FindByTelegramId(ctx, telegramID)
+	if err != nil {
+		return result, fmt.Errorf("find customer: %w", err)
+	}
+	if customer == nil {
+		return result, nil
+	}
+	err = s.customerRepository.UpdateFields(ctx, customer.ID, map[string]interface{}{
+		"expire_at":         result.Primary.ExpireAt,
+		"subscription_link": result.Primary.SubscriptionURL,
+	})
+	if err != nil {
+		return result, fmt.Errorf("update customer: %w", err)
+	}
+	return result, nil
 }
@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/utils"
+)
+
+// trialCleanupBatchSize ограничивает количество пользователей панели, очищаемых за запуск
+const trialCleanupBatchSize = 500
+
+type trialCleanupRepository interface {
+	FindExpired(ctx context.Context, expiredBefore time.Time, limit int) ([]database.Customer, error)
+	MarkCleaned(ctx context.Context, customerID int64, at time.Time) error
+}
+
+type trialCleanupPanel interface {
+	DisableUsersByTelegramID(ctx context.Context, telegramID int64) (int, error)
+	DeleteUsersByTelegramID(ctx context.Context, telegramID int64) (int, error)
+}
+
+// TrialCleanupService отключает или удаляет в панели пользователей, чей триал истёк больше TRIAL_CLEANUP_AFTER_DAYS
+// дней назад и которые ни разу не платили. Покупка выдаёт подписку заново: отключённый пользователь включается,
+// удалённый создаётся
+type TrialCleanupService struct {
+	repository trialCleanupRepository
+	panel      trialCleanupPanel
+}
+
+func NewTrialCleanupService(repository trialCleanupRepository, panel trialCleanupPanel) *TrialCleanupService {
+	return &TrialCleanupService{repository: repository, panel: panel}
+}
+
+// Run очищает пользователей панели с давно истёкшим триалом. Ошибка панели по одному клиенту не останавливает
+// остальных: клиент не отмечается и будет выбран при следующем запуске
+func (s *TrialCleanupService) Run(ctx context.Context) error {
+	if !config.IsTrialCleanupEnabled() {
+		return nil
+	}
+
+	now := clock.Now()
+	expiredBefore := now.AddDate(0, 0, -config.TrialCleanupAfterDays())
+	customers, err := s.repository.FindExpired(ctx, expiredBefore, trialCleanupBatchSize)
+	if err != nil {
+		return err
+	}
+
+	mode := config.TrialCleanupMode()
+	cleaned := 0
+	for _, c := range customers {
+		if err := s.clean(ctx, mode, c.TelegramID); err != nil {
+			slog.WarnContext(ctx, "Failed to clean up expired trial user", "customerId", utils.MaskHalfInt64(c.ID), "mode", mode, "error", err)
+			continue
+		}
+		if err := s.repository.MarkCleaned(ctx, c.ID, now); err != nil {
+			return fmt.Errorf("mark customer %d cleaned: %w", c.ID, err)
+		}
+		cleaned++
+	}
+	if cleaned > 0 {
+		slog.InfoContext(ctx, "Expired trial users cleaned up", "mode", mode, "cleaned", cleaned, "expiredBefore", expiredBefore)
+	}
+	return nil
+}
+
+func (s *TrialCleanupService) clean(ctx context.Context, mode string, telegramID int64) error {
+	if mode == config.TrialCleanupDelete {
+		_, err := s.panel.DeleteUsersByTelegramID(ctx, telegramID)
+		return err
+	}
+	_, err := s.panel.DisableUsersByTelegramID(ctx, telegramID)
+	return err
+}
@@ -0,0 +1,359 @@
+package team
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"time"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/v2/api"
+	"remnawave-tg-shop-bot/internal/clock"
+	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/database"
+	"remnawave-tg-shop-bot/internal/remnawave"
+	"remnawave-tg-shop-bot/utils"
+)
+
+var (
+	ErrSeatNotFound  = errors.New("team seat not found")
+	ErrSeatTaken     = errors.New("team seat already taken")
+	ErrAlreadyMember = errors.New("customer already holds a seat in this team")
+	ErrTeamExpired   = errors.New("team subscription expired")
+	ErrNotOwner      = errors.New("customer is not the team owner")
+)
+
+// StartPrefix префикс параметра /start в ссылке-приглашении: /start team_<код>
+const StartPrefix = "team_"
+
+// codeAlphabet без похожих символов (0/O, 1/I), чтобы код можно было продиктовать
+const codeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const codeLength = 10
+
+type teamRepository interface {
+	CreateWithSeats(ctx context.Context, team *database.Team, codes []string) (*database.Team, error)
+	FindByID(ctx context.Context, id int64) (*database.Team, error)
+	FindByPurchaseID(ctx context.Context, purchaseID int64) (*database.Team, error)
+	FindByOwner(ctx context.Context, ownerCustomerID int64) ([]database.Team, error)
+	CountTakenSeats(ctx context.Context, teamID int64) (int, error)
+	FindSeats(ctx context.Context, teamID int64) ([]database.TeamSeat, error)
+	FindSeatByID(ctx context.Context, id int64) (*database.TeamSeat, error)
+	FindSeatByCode(ctx context.Context, code string) (*database.TeamSeat, error)
+	IsMember(ctx context.Context, teamID, customerID int64) (bool, error)
+	ClaimSeat(ctx context.Context, seatID, customerID int64, redeemedAt time.Time) (bool, error)
+	ReleaseSeat(ctx context.Context, seatID int64, code string) error
+	Expire(ctx context.Context, teamID int64, at time.Time) error
+}
+
+type customerRepository interface {
+	FindById(ctx context.Context, id int64) (*database.Customer, error)
+	UpdateFields(ctx context.Context, id int64, updates map[string]interface{}) error
+}
+
+type remnawaveClient interface {
+	CreateOrUpdateUser(ctx context.Context, customerId int64, telegramId int64, trafficLimit int, days int, isTrialUser bool) (*remapi.UserResponseResponse, error)
+	ClawbackSubscription(ctx context.Context, telegramID int64, days int) (*time.Time, error)
+}
+
+type Service struct {
+	teamRepo        teamRepository
+	customerRepo    customerRepository
+	remnawaveClient remnawaveClient
+}
+
+func NewService(
+	teamRepo *database.TeamRepository,
+	customerRepo *database.CustomerRepository,
+	remnawaveClient *remnawave.Client,
+) *Service {
+	return &Service{
+		teamRepo:        teamRepo,
+		customerRepo:    customerRepo,
+		remnawaveClient: remnawaveClient,
+	}
+}
+
+// RedeemResult результат активации места
+type RedeemResult struct {
+	Team      *database.Team
+	Days      int
+	NewExpire time.Time
+}
+
+// RevokeResult результат отзыва места
+type RevokeResult struct {
+	Seat *database.TeamSeat
+	// Member клиент, занимавший место. nil если место было свободно
+	Member *database.Customer
+	// Days сколько дней подписки списано у участника
+	Days int
+}
+
+// CancelResult результат отмены команды по возврату оплаты
+type CancelResult struct {
+	Team *database.Team
+	// Members участники, у которых списаны оставшиеся дни команды
+	Members []*database.Customer
+	// Days сколько дней подписки списано у каждого участника
+	Days int
+}
+
+// InviteLink возвращает ссылку-приглашение на место
+func InviteLink(code string) string {
+	return fmt.Sprintf("%s?start=%s%s", config.BotURL(), StartPrefix, code)
+}
+
+// CreateForPurchase создаёт команду по оплаченной покупке. Срок команды отсчитывается от момента оплаты,
+// повторный вызов для той же покупки возвращает уже созданную команду
+func (s *Service) CreateForPurchase(ctx context.Context, purchase *database.Purchase) (*database.Team, error) {
+	if purchase.TeamSeats == nil || *purchase.TeamSeats <= 0 {
+		return nil, fmt.Errorf("purchase %d is not a team purchase", purchase.ID)
+	}
+	seats := *purchase.TeamSeats
+
+	codes := make([]string, 0, seats)
+	for i := 0; i < seats; i++ {
+		code, err := newCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	now := clock.Now()
+	return s.teamRepo.CreateWithSeats(ctx, &database.Team{
+		OwnerCustomerID: purchase.CustomerID,
+		PurchaseID:      purchase.ID,
+		Seats:           seats,
+		Months:          purchase.Month,
+		ExpiresAt:       now.AddDate(0, 0, purchase.Month*config.DaysInMonth()),
+	}, codes)
+}
+
+// Redeem занимает место по коду приглашения и продлевает подписку участника до конца срока команды
+func (s *Service) Redeem(ctx context.Context, customer *database.Customer, code string) (*RedeemResult, error) {
+	seat, err := s.teamRepo.FindSeatByCode(ctx, strings.ToUpper(strings.TrimSpace(code)))
+	if err != nil {
+		return nil, err
+	}
+	if seat == nil {
+		return nil, ErrSeatNotFound
+	}
+	if !seat.IsFree() {
+		return nil, ErrSeatTaken
+	}
+
+	team, err := s.teamRepo.FindByID(ctx, seat.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	if team == nil {
+		return nil, ErrSeatNotFound
+	}
+
+	now := clock.Now()
+	days := remainingDays(team.ExpiresAt, now)
+	if days == 0 {
+		return nil, ErrTeamExpired
+	}
+
+	member, err := s.teamRepo.IsMember(ctx, team.ID, customer.ID)
+	if err != nil {
+		return nil, err
+	}
+	if member {
+		return nil, ErrAlreadyMember
+	}
+
+	claimed, err := s.teamRepo.ClaimSeat(ctx, seat.ID, customer.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrSeatTaken
+	}
+
+	user, err := s.remnawaveClient.CreateOrUpdateUser(ctx, customer.ID, customer.TelegramID, config.TrafficLimit(), days, false)
+	if err != nil {
+		// Подписка не выдана — возвращаем место, чтобы ссылкой можно было воспользоваться снова
+		if releaseErr := s.teamRepo.ReleaseSeat(ctx, seat.ID, seat.Code); releaseErr != nil {
+			slog.ErrorContext(ctx, "Error releasing team seat after failed redeem", "error", releaseErr, "seatId", seat.ID)
+		}
+		return nil, fmt.Errorf("extend member subscription: %w", err)
+	}
+
+	if err := s.customerRepo.UpdateFields(ctx, customer.ID, map[string]interface{}{
+		"subscription_link": user.SubscriptionUrl,
+		"expire_at":         user.ExpireAt,
+	}); err != nil {
+		slog.ErrorContext(ctx, "Error updating customer after team redeem", "error", err, "customerId", utils.MaskHalfInt64(customer.ID))
+	}
+
+	slog.InfoContext(ctx, "Team seat redeemed", "teamId", team.ID, "seatId", seat.ID, "customerId", utils.MaskHalfInt64(customer.ID), "days", days)
+	return &RedeemResult{Team: team, Days: days, NewExpire: user.ExpireAt}, nil
+}
+
+// Revoke освобождает место команды по запросу владельца: у участника списываются оставшиеся дни команды,
+// а место получает новый код, старая ссылка перестаёт работать
+func (s *Service) Revoke(ctx context.Context, ownerCustomerID, seatID int64) (*RevokeResult, error) {
+	seat, team, err := s.ownedSeat(ctx, ownerCustomerID, seatID)
+	if err != nil {
+		return nil, err
+	}
+
+	member, days, err := s.releaseSeat(ctx, team, seat)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Team seat revoked", "teamId", team.ID, "seatId", seat.ID, "days", days)
+	return &RevokeResult{Seat: seat, Member: member, Days: days}, nil
+}
+
+// CancelForPurchase отменяет команду по полностью возвращённой покупке: у участников списываются оставшиеся дни,
+// места освобождаются, а срок команды истекает, чтобы неиспользованные ссылки перестали работать.
+// Повторный вызов продолжает с ещё занятых мест. Возвращает nil, если команда по покупке не создавалась
+func (s *Service) CancelForPurchase(ctx context.Context, purchaseID int64) (*CancelResult, error) {
+	team, err := s.teamRepo.FindByPurchaseID(ctx, purchaseID)
+	if err != nil || team == nil {
+		return nil, err
+	}
+	seats, err := s.teamRepo.FindSeats(ctx, team.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CancelResult{Team: team}
+	for i := range seats {
+		if seats[i].IsFree() {
+			continue
+		}
+		member, days, err := s.releaseSeat(ctx, team, &seats[i])
+		if err != nil {
+			return nil, err
+		}
+		if member != nil {
+			result.Members = append(result.Members, member)
+			result.Days = days
+		}
+	}
+
+	if err := s.teamRepo.Expire(ctx, team.ID, clock.Now()); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Team cancelled after refund", "teamId", team.ID, "members", len(result.Members), "days", result.Days)
+	return result, nil
+}
+
+// releaseSeat списывает у участника оставшиеся дни команды и освобождает место с новым кодом.
+// Место освобождается только после списания: при ошибке панели повторная попытка не потеряет участника
+func (s *Service) releaseSeat(ctx context.Context, team *database.Team, seat *database.TeamSeat) (*database.Customer, int, error) {
+	var member *database.Customer
+	var days int
+	if !seat.IsFree() {
+		var err error
+		member, err = s.customerRepo.FindById(ctx, *seat.MemberCustomerID)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		remaining := remainingDays(team.ExpiresAt, clock.Now())
+		if member != nil && remaining > 0 {
+			expireAt, err := s.remnawaveClient.ClawbackSubscription(ctx, member.TelegramID, remaining)
+			if err != nil {
+				return nil, 0, fmt.Errorf("clawback member subscription: %w", err)
+			}
+			if err := s.customerRepo.UpdateFields(ctx, member.ID, map[string]interface{}{
+				"expire_at": expireAt,
+			}); err != nil {
+				slog.ErrorContext(ctx, "Error updating customer after team revoke", "error", err, "customerId", utils.MaskHalfInt64(member.ID))
+			}
+			days = remaining
+		}
+	}
+
+	code, err := newCode()
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := s.teamRepo.ReleaseSeat(ctx, seat.ID, code); err != nil {
+		return nil, 0, err
+	}
+	seat.Code = code
+	seat.MemberCustomerID = nil
+	seat.RedeemedAt = nil
+	return member, days, nil
+}
+
+// OwnedTeams возвращает команды владельца
+func (s *Service) OwnedTeams(ctx context.Context, ownerCustomerID int64) ([]database.Team, error) {
+	return s.teamRepo.FindByOwner(ctx, ownerCustomerID)
+}
+
+// OwnedTeam возвращает команду и её места, если команда принадлежит владельцу
+func (s *Service) OwnedTeam(ctx context.Context, ownerCustomerID, teamID int64) (*database.Team, []database.TeamSeat, error) {
+	team, err := s.teamRepo.FindByID(ctx, teamID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if team == nil || team.OwnerCustomerID != ownerCustomerID {
+		return nil, nil, ErrNotOwner
+	}
+	seats, err := s.teamRepo.FindSeats(ctx, team.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return team, seats, nil
+}
+
+// OwnedSeat возвращает место и его команду, если команда принадлежит владельцу
+func (s *Service) OwnedSeat(ctx context.Context, ownerCustomerID, seatID int64) (*database.TeamSeat, *database.Team, error) {
+	return s.ownedSeat(ctx, ownerCustomerID, seatID)
+}
+
+// CountTakenSeats возвращает количество занятых мест команды
+func (s *Service) CountTakenSeats(ctx context.Context, teamID int64) (int, error) {
+	return s.teamRepo.CountTakenSeats(ctx, teamID)
+}
+
+func (s *Service) ownedSeat(ctx context.Context, ownerCustomerID, seatID int64) (*database.TeamSeat, *database.Team, error) {
+	seat, err := s.teamRepo.FindSeatByID(ctx, seatID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if seat == nil {
+		return nil, nil, ErrSeatNotFound
+	}
+	team, err := s.teamRepo.FindByID(ctx, seat.TeamID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if team == nil || team.OwnerCustomerID != ownerCustomerID {
+		return nil, nil, ErrNotOwner
+	}
+	return seat, team, nil
+}
+
+// remainingDays возвращает количество дней до окончания срока команды с округлением вверх
+func remainingDays(expiresAt, now time.Time) int {
+	if !expiresAt.After(now) {
+		return 0
+	}
+	return int(math.Ceil(expiresAt.Sub(now).Hours() / 24))
+}
+
+func newCode() (string, error) {
+	b := make([]byte, codeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate team invite code: %w", err)
+	}
+	for i := range b {
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,182 @@
+package team
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	remapi "github.com/Jolymmiles/remnawave-api-go/v2/api"
+	"remnawave-tg-shop-bot/internal/database"
+)
+
+func TestRemainingDays(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		expiresAt time.Time
+		want      int
+	}{
+		{now.AddDate(0, 0, 30), 30},
+		{now.Add(29*24*time.Hour + time.Hour), 30},
+		{now.Add(time.Minute), 1},
+		{now, 0},
+		{now.Add(-time.Hour), 0},
+	}
+
+	for _, tt := range tests {
+		if got := remainingDays(tt.expiresAt, now); got != tt.want {
+			t.Errorf("remainingDays(%s) = %d, want %d", tt.expiresAt.Sub(now), got, tt.want)
+		}
+	}
+}
+
+func TestNewCode(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		code, err := newCode()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(code) != codeLength {
+			t.Errorf("Expected code of length %d, got %q", codeLength, code)
+		}
+		for _, r := range code {
+			if !strings.ContainsRune(codeAlphabet, r) {
+				t.Errorf("Unexpected character %q in code %q", r, code)
+			}
+		}
+		if seen[code] {
+			t.Errorf("Duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+}
+
+type teamRepoMock struct {
+	teamRepository
+	team     *database.Team
+	seats    []database.TeamSeat
+	released []int64
+	expired  *time.Time
+}
+
+func (m *teamRepoMock) FindByPurchaseID(ctx context.Context, purchaseID int64) (*database.Team, error) {
+	if m.team == nil || m.team.PurchaseID != purchaseID {
+		return nil, nil
+	}
+	return m.team, nil
+}
+
+func (m *teamRepoMock) FindSeats(ctx context.Context, teamID int64) ([]database.TeamSeat, error) {
+	return append([]database.TeamSeat(nil), m.seats...), nil
+}
+
+func (m *teamRepoMock) ReleaseSeat(ctx context.Context, seatID int64, code string) error {
+	m.released = append(m.released, seatID)
+	for i := range m.seats {
+		if m.seats[i].ID == seatID {
+			m.seats[i].MemberCustomerID = nil
+			m.seats[i].Code = code
+		}
+	}
+	return nil
+}
+
+func (m *teamRepoMock) Expire(ctx context.Context, teamID int64, at time.Time) error {
+	m.expired = &at
+	return nil
+}
+
+type customerRepoMock struct {
+	customers map[int64]*database.Customer
+	updated   map[int64]map[string]interface{}
+}
+
+func (m *customerRepoMock) FindById(ctx context.Context, id int64) (*database.Customer, error) {
+	return m.customers[id], nil
+}
+
+func (m *customerRepoMock) UpdateFields(ctx context.Context, id int64, updates map[string]interface{}) error {
+	if m.updated == nil {
+		m.updated = make(map[int64]map[string]interface{})
+	}
+	m.updated[id] = updates
+	return nil
+}
+
+type remnawaveMock struct {
+	clawed map[int64]int
+	err    error
+}
+
+func (m *remnawaveMock) CreateOrUpdateUser(ctx context.Context, customerId int64, telegramId int64, trafficLimit int, days int, isTrialUser bool) (*remapi.UserResponseResponse, error) {
+	return nil, errors.New("unexpected call")
+}
+
+func (m *remnawaveMock) ClawbackSubscription(ctx context.Context, telegramID int64, days int) (*time.Time, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.clawed == nil {
+		m.clawed = make(map[int64]int)
+	}
+	m.clawed[telegramID] += days
+	expireAt := time.Now()
+	return &expireAt, nil
+}
+
+func TestCancelForPurchase(t *testing.T) {
+	memberID := int64(7)
+	repo := &teamRepoMock{
+		team: &database.Team{ID: 1, PurchaseID: 10, ExpiresAt: time.Now().AddDate(0, 0, 10)},
+		seats: []database.TeamSeat{
+			{ID: 100, TeamID: 1, Code: "TAKEN", MemberCustomerID: &memberID},
+			{ID: 101, TeamID: 1, Code: "FREE"},
+		},
+	}
+	customers := &customerRepoMock{customers: map[int64]*database.Customer{memberID: {ID: memberID, TelegramID: 70}}}
+	panel := &remnawaveMock{err: errors.New("panel unavailable")}
+	s := &Service{teamRepo: repo, customerRepo: customers, remnawaveClient: panel}
+
+	// Панель недоступна: место участника не освобождается, команда не истекает
+	if _, err := s.CancelForPurchase(context.Background(), 10); err == nil {
+		t.Fatal("Expected error when member subscription cannot be clawed back")
+	}
+	if len(repo.released) != 0 || repo.expired != nil {
+		t.Fatalf("Expected team to stay intact after failed cancel, released %v, expired %v", repo.released, repo.expired)
+	}
+
+	panel.err = nil
+	result, err := s.CancelForPurchase(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Members) != 1 || result.Members[0].ID != memberID || result.Days != 10 {
+		t.Errorf("Expected member %d to lose 10 days, got %+v", memberID, result)
+	}
+	if panel.clawed[70] != 10 {
+		t.Errorf("Expected 10 days clawed back from member subscription, got %d", panel.clawed[70])
+	}
+	if _, ok := customers.updated[memberID]["expire_at"]; !ok {
+		t.Errorf("Expected member expire_at to be updated, got %v", customers.updated)
+	}
+	if len(repo.released) != 1 || repo.released[0] != 100 || repo.seats[0].Code == "TAKEN" {
+		t.Errorf("Expected taken seat to be released with a new code, got %v %+v", repo.released, repo.seats[0])
+	}
+	if repo.expired == nil {
+		t.Error("Expected team to be expired so free invite links stop working")
+	}
+
+	// Повторный вызов не списывает дни второй раз
+	if _, err := s.CancelForPurchase(context.Background(), 10); err != nil {
+		t.Fatalf("Unexpected error on repeated cancel: %v", err)
+	}
+	if panel.clawed[70] != 10 {
+		t.Errorf("Expected repeated cancel to keep member days, got %d", panel.clawed[70])
+	}
+
+	if result, err := s.CancelForPurchase(context.Background(), 11); err != nil || result != nil {
+		t.Errorf("Expected nil result for purchase without team, got %+v, %v", result, err)
+	}
+}
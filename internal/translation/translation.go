@@ -2,11 +2,16 @@ package translation
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+
+	"remnawave-tg-shop-bot/utils"
 )
 
 type Translation map[string]string
@@ -14,7 +19,23 @@ type Translation map[string]string
 type Manager struct {
 	translations    map[string]Translation
 	defaultLanguage string
-	mu              sync.RWMutex
+	// fallbacks запасной язык для языка клиента (uk → ru): проверяется перед языком по умолчанию
+	fallbacks map[string]string
+	// dir каталог переводов, modTimes — время изменения загруженных файлов: Refresh подхватывает новые и изменённые
+	dir      string
+	modTimes map[string]time.Time
+	mu       sync.RWMutex
+}
+
+// LocaleCompleteness полнота перевода одного языка относительно всех ключей, известных боту
+type LocaleCompleteness struct {
+	Language string
+	// Total количество ключей во всех языках вместе
+	Total int
+	// Missing ключи, которых нет в языке или у которых пустой перевод, по алфавиту
+	Missing []string
+	// Fallbacks языки, из которых берутся недостающие строки, по порядку
+	Fallbacks []string
 }
 
 var (
@@ -27,6 +48,7 @@ func GetInstance() *Manager {
 		instance = &Manager{
 			translations:    make(map[string]Translation),
 			defaultLanguage: "en",
+			modTimes:        make(map[string]time.Time),
 		}
 	})
 	return instance
@@ -39,6 +61,7 @@ func (tm *Manager) InitTranslations(translationsDir string, defaultLanguage stri
 	if defaultLanguage != "" {
 		tm.defaultLanguage = defaultLanguage
 	}
+	tm.dir = translationsDir
 
 	files, err := os.ReadDir(translationsDir)
 	if err != nil {
@@ -49,36 +72,137 @@ func (tm *Manager) InitTranslations(translationsDir string, defaultLanguage stri
 		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
 			continue
 		}
+		if err := tm.loadFile(file); err != nil {
+			return err
+		}
+	}
 
-		langCode := strings.TrimSuffix(file.Name(), ".json")
-		filePath := filepath.Join(translationsDir, file.Name())
+	if _, exists := tm.translations[tm.defaultLanguage]; !exists {
+		return fmt.Errorf("default language %s translation not found", tm.defaultLanguage)
+	}
 
-		content, err := os.ReadFile(filePath)
-		if err != nil {
-			return fmt.Errorf("failed to read translation file %s: %w", file.Name(), err)
-		}
+	return nil
+}
 
-		var translation Translation
-		if err := json.Unmarshal(content, &translation); err != nil {
-			return fmt.Errorf("failed to parse translation file %s: %w", file.Name(), err)
-		}
+// loadFile загружает файл перевода <язык>.json. Вызывается под tm.mu
+func (tm *Manager) loadFile(file os.DirEntry) error {
+	langCode := strings.ToLower(strings.TrimSuffix(file.Name(), ".json"))
+	filePath := filepath.Join(tm.dir, file.Name())
 
-		tm.translations[langCode] = translation
+	info, err := file.Info()
+	if err != nil {
+		return fmt.Errorf("failed to stat translation file %s: %w", file.Name(), err)
 	}
 
-	if _, exists := tm.translations[tm.defaultLanguage]; !exists {
-		return fmt.Errorf("default language %s translation not found", tm.defaultLanguage)
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read translation file %s: %w", file.Name(), err)
+	}
+
+	var translation Translation
+	if err := json.Unmarshal(content, &translation); err != nil {
+		return fmt.Errorf("failed to parse translation file %s: %w", file.Name(), err)
 	}
 
+	if tm.modTimes == nil {
+		tm.modTimes = make(map[string]time.Time)
+	}
+	tm.translations[langCode] = translation
+	tm.modTimes[langCode] = info.ModTime()
 	return nil
 }
 
+// Refresh подхватывает добавленные и изменённые файлы переводов без перезапуска. Файл с ошибкой пропускается,
+// прежний перевод этого языка остаётся. Возвращает загруженные языки
+func (tm *Manager) Refresh() ([]string, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if tm.dir == "" {
+		return nil, nil
+	}
+	files, err := os.ReadDir(tm.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read translation directory: %w", err)
+	}
+
+	var loaded []string
+	var errs []error
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		langCode := strings.ToLower(strings.TrimSuffix(file.Name(), ".json"))
+		info, err := file.Info()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if modTime, ok := tm.modTimes[langCode]; ok && modTime.Equal(info.ModTime()) {
+			continue
+		}
+		if err := tm.loadFile(file); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		loaded = append(loaded, langCode)
+	}
+	return loaded, errors.Join(errs...)
+}
+
+// SetFallbacks задаёт запасные языки: для ключа — язык клиента, для значения — язык, строки которого
+// показываются вместо недостающих (перед языком по умолчанию)
+func (tm *Manager) SetFallbacks(fallbacks map[string]string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.fallbacks = fallbacks
+}
+
+// Languages коды загруженных языков по алфавиту
+func (tm *Manager) Languages() []string {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	return tm.languages()
+}
+
+func (tm *Manager) languages() []string {
+	languages := make([]string, 0, len(tm.translations))
+	for langCode := range tm.translations {
+		languages = append(languages, langCode)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// languageChain порядок поиска перевода: язык клиента, его запасной язык из LANGUAGE_FALLBACKS (uk → ru)
+// со своими запасными, язык без региона (pt-br → pt), язык по умолчанию. Вызывается под tm.mu
+func (tm *Manager) languageChain(langCode string) []string {
+	var chain []string
+	seen := make(map[string]bool)
+	var visit func(code string)
+	visit = func(code string) {
+		if code == "" || seen[code] {
+			return
+		}
+		seen[code] = true
+		chain = append(chain, code)
+		visit(tm.fallbacks[code])
+		if base, _, ok := strings.Cut(code, "-"); ok {
+			visit(base)
+		}
+	}
+	visit(strings.ToLower(strings.ReplaceAll(langCode, "_", "-")))
+	visit(tm.defaultLanguage)
+	return chain
+}
+
 func (tm *Manager) GetText(langCode, key string) string {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
 
-	if translation, exists := tm.translations[langCode]; exists {
-		if text, exists := translation[key]; exists && text != "" {
+	for _, code := range tm.languageChain(langCode) {
+		if text := tm.translations[code][key]; text != "" {
 			return text
 		}
 	}
@@ -89,15 +213,53 @@ func (tm *Manager) GetText(langCode, key string) string {
 		}
 	}
 
+	// Строку не перевели на язык по умолчанию: лучше показать её на другом языке, чем ключ
+	for _, code := range tm.languages() {
+		if text := tm.translations[code][key]; text != "" {
+			return text
+		}
+	}
+
 	return key
 }
 
+// Completeness сравнивает загруженные языки: для каждого — ключи, которые есть в других языках,
+// но не переведены в нём, и языки, из которых показываются недостающие строки
+func (tm *Manager) Completeness() []LocaleCompleteness {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	keys := make(map[string]bool)
+	for _, translation := range tm.translations {
+		for key := range translation {
+			keys[key] = true
+		}
+	}
+
+	languages := tm.languages()
+	report := make([]LocaleCompleteness, 0, len(languages))
+	for _, code := range languages {
+		item := LocaleCompleteness{Language: code, Total: len(keys)}
+		for key := range keys {
+			if tm.translations[code][key] == "" {
+				item.Missing = append(item.Missing, key)
+			}
+		}
+		sort.Strings(item.Missing)
+		item.Fallbacks = tm.languageChain(code)[1:]
+		report = append(report, item)
+	}
+	return report
+}
+
+// GetTextTemplate подставляет data в перевод. Переводы отправляются с ParseModeHTML, поэтому значения
+// экранируются; готовую разметку бота передают как utils.RawHTML
 func (tm *Manager) GetTextTemplate(langCode, key string, data map[string]interface{}) string {
 	text := tm.GetText(langCode, key)
 	
 	for k, v := range data {
 		placeholder := fmt.Sprintf("{{.%s}}", k)
-		text = strings.ReplaceAll(text, placeholder, fmt.Sprintf("%v", v))
+		text = strings.ReplaceAll(text, placeholder, utils.HTMLValue(v))
 	}
 	
 	return text
@@ -0,0 +1,134 @@
+package translation
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"remnawave-tg-shop-bot/utils"
+)
+
+func newTestManager() *Manager {
+	return &Manager{
+		translations: map[string]Translation{
+			"ru": {"greeting": "Привет, <b>{{.name}}</b>! Осталось {{.days}} дн.\n{{.list}}"},
+		},
+		defaultLanguage: "ru",
+	}
+}
+
+// Имя пользователя с тегами показывается текстом и не ломает разметку перевода
+func TestGetTextTemplateEscapesValues(t *testing.T) {
+	got := newTestManager().GetTextTemplate("ru", "greeting", map[string]interface{}{
+		"name": `</b><a href="https://evil.example">Поддержка</a>`,
+		"days": 3,
+		"list": "a & b",
+	})
+	want := "Привет, <b>&lt;/b&gt;&lt;a href=&quot;https://evil.example&quot;&gt;Поддержка&lt;/a&gt;</b>! Осталось 3 дн.\na &amp; b"
+	if got != want {
+		t.Errorf("GetTextTemplate() = %q, want %q", got, want)
+	}
+}
+
+func TestGetTextTemplateKeepsRawHTML(t *testing.T) {
+	got := newTestManager().GetTextTemplate("ru", "greeting", map[string]interface{}{
+		"name": "Иван",
+		"days": 1,
+		"list": utils.RawHTML("🟢 <i>Frankfurt</i>"),
+	})
+	if want := "Привет, <b>Иван</b>! Осталось 1 дн.\n🟢 <i>Frankfurt</i>"; got != want {
+		t.Errorf("GetTextTemplate() = %q, want %q", got, want)
+	}
+}
+
+func newFallbackManager() *Manager {
+	return &Manager{
+		translations: map[string]Translation{
+			"ru": {"greeting": "Привет", "buy": "Купить", "ru_only": "Только русский"},
+			"en": {"greeting": "Hello", "buy": "Buy", "en_only": "English only"},
+			"uk": {"greeting": "Вітаю", "buy": ""},
+		},
+		defaultLanguage: "en",
+		fallbacks:       map[string]string{"uk": "ru", "be": "ru"},
+	}
+}
+
+func TestGetTextFallbacks(t *testing.T) {
+	tm := newFallbackManager()
+	tests := []struct {
+		lang, key, want string
+	}{
+		{"uk", "greeting", "Вітаю"},
+		// Пустой перевод берётся из запасного языка, а не из языка по умолчанию
+		{"uk", "buy", "Купить"},
+		{"uk-UA", "greeting", "Вітаю"},
+		{"be", "greeting", "Привет"},
+		{"ru-RU", "buy", "Купить"},
+		{"de", "greeting", "Hello"},
+		// Строки нет в языке по умолчанию: показывается перевод на другом языке, а не ключ
+		{"de", "ru_only", "Только русский"},
+		{"uk", "en_only", "English only"},
+		{"de", "unknown_key", "unknown_key"},
+	}
+	for _, tt := range tests {
+		if got := tm.GetText(tt.lang, tt.key); got != tt.want {
+			t.Errorf("GetText(%q, %q) = %q, want %q", tt.lang, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestCompleteness(t *testing.T) {
+	report := newFallbackManager().Completeness()
+	if len(report) != 3 {
+		t.Fatalf("Completeness() = %+v, expected 3 languages", report)
+	}
+	want := map[string]struct {
+		missing   string
+		fallbacks string
+	}{
+		"en": {"[ru_only]", "[]"},
+		"ru": {"[en_only]", "[en]"},
+		"uk": {"[buy en_only ru_only]", "[ru en]"},
+	}
+	for _, item := range report {
+		if item.Total != 4 {
+			t.Errorf("%s: Total = %d, want 4", item.Language, item.Total)
+		}
+		if got := fmt.Sprint(item.Missing); got != want[item.Language].missing {
+			t.Errorf("%s: Missing = %s, want %s", item.Language, got, want[item.Language].missing)
+		}
+		if got := fmt.Sprint(item.Fallbacks); got != want[item.Language].fallbacks {
+			t.Errorf("%s: Fallbacks = %s, want %s", item.Language, got, want[item.Language].fallbacks)
+		}
+	}
+}
+
+func TestRefreshLoadsNewLocaleFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ru.json"), []byte(`{"greeting": "Привет"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tm := &Manager{translations: map[string]Translation{}}
+	if err := tm.InitTranslations(dir, "ru"); err != nil {
+		t.Fatalf("InitTranslations() returned error: %v", err)
+	}
+
+	if loaded, err := tm.Refresh(); err != nil || len(loaded) != 0 {
+		t.Errorf("Refresh() without changes = %v, %v; expected nothing loaded", loaded, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "uk.json"), []byte(`{"greeting": "Вітаю"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "de.json"), []byte(`{broken`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := tm.Refresh()
+	if err == nil || fmt.Sprint(loaded) != "[uk]" {
+		t.Errorf("Refresh() = %v, %v; expected uk loaded and an error for de", loaded, err)
+	}
+	if got := tm.GetText("uk", "greeting"); got != "Вітаю" {
+		t.Errorf("GetText(uk) after Refresh() = %q", got)
+	}
+}
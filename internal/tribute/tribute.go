@@ -9,7 +9,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"log/slog"
 	"net/http"
 	"remnawave-tg-shop-bot/internal/config"
@@ -43,7 +42,7 @@ func (c *Client) WebHookHandler() http.Handler {
 		defer cancel()
 		body, err := io.ReadAll(r.Body)
 		if err != nil {
-			slog.Error("webhook: read body error", "error", err)
+			slog.ErrorContext(ctx, "webhook: read body error", "error", err)
 			http.Error(w, "invalid body", http.StatusBadRequest)
 			return
 		}
@@ -61,40 +60,40 @@ func (c *Client) WebHookHandler() http.Handler {
 		expected := hex.EncodeToString(mac.Sum(nil))
 
 		if !hmac.Equal([]byte(expected), []byte(signature)) {
-			log.Printf("webhook: bad signature (expected %s)", expected)
+			slog.WarnContext(r.Context(), "webhook: bad signature")
 			http.Error(w, "invalid signature", http.StatusUnauthorized)
 			return
 		}
 
 		var wh SubscriptionWebhook
 		if err := json.Unmarshal(body, &wh); err != nil {
-			slog.Error("webhook: unmarshal error", "error", err, "payload", string(body))
+			slog.ErrorContext(ctx, "webhook: unmarshal error", "error", err, "payload", string(body))
 			http.Error(w, "invalid json", http.StatusBadRequest)
 			return
 		}
 
 		switch wh.Name {
 		case NewSubscription:
-			err := c.newSubscriptionHandler(ctx, wh)
+			err := c.newSubscriptionHandler(ctx, wh, body)
 			if err != nil {
-				slog.Error("webhook: new subscription error", "error", err, "payload", string(body))
+				slog.ErrorContext(ctx, "webhook: new subscription error", "error", err, "payload", string(body))
 				http.Error(w, "internal server error", http.StatusInternalServerError)
 				return
 			}
 		case CancelledSubscription:
 			err := c.cancelSubscriptionHandler(ctx, wh)
 			if errors.Is(err, payment.ErrCustomerNotFound) {
-				slog.Warn("webhook: customer not found", "telegram_id", wh.Payload.TelegramUserID)
+				slog.WarnContext(ctx, "webhook: customer not found", "telegram_id", wh.Payload.TelegramUserID)
 				w.WriteHeader(http.StatusOK)
 				return
 			}
 			if err != nil {
-				slog.Error("webhook: cancel subscription error", "error", err, "payload", string(body))
+				slog.ErrorContext(ctx, "webhook: cancel subscription error", "error", err, "payload", string(body))
 				http.Error(w, "internal server error", http.StatusInternalServerError)
 				return
 			}
 		case TestHook:
-			slog.Info("Tribute webhook working")
+			slog.InfoContext(ctx, "Tribute webhook working")
 		}
 		w.WriteHeader(http.StatusOK)
 	})
@@ -104,7 +103,7 @@ func (c *Client) cancelSubscriptionHandler(ctx context.Context, wh SubscriptionW
 	return c.paymentService.CancelTributePurchase(ctx, wh.Payload.TelegramUserID)
 }
 
-func (c *Client) newSubscriptionHandler(ctx context.Context, wh SubscriptionWebhook) error {
+func (c *Client) newSubscriptionHandler(ctx context.Context, wh SubscriptionWebhook, body []byte) error {
 	months := convertPeriodToMonths(wh.Payload.Period)
 
 	customer, err := c.customerRepository.FindByTelegramId(ctx, wh.Payload.TelegramUserID)
@@ -122,9 +121,9 @@ func (c *Client) newSubscriptionHandler(ctx context.Context, wh SubscriptionWebh
 	if tariff != nil {
 		tariffName = &tariff.Name
 		deviceLimit = &tariff.Devices
-		slog.Info("Tribute webhook matched tariff", "subscriptionName", wh.Payload.SubscriptionName, "tariff", tariff.Name, "devices", tariff.Devices)
+		slog.InfoContext(ctx, "Tribute webhook matched tariff", "subscriptionName", wh.Payload.SubscriptionName, "tariff", tariff.Name, "devices", tariff.Devices)
 	} else {
-		slog.Info("Tribute webhook no tariff match, using default", "subscriptionName", wh.Payload.SubscriptionName)
+		slog.InfoContext(ctx, "Tribute webhook no tariff match, using default", "subscriptionName", wh.Payload.SubscriptionName)
 	}
 
 	_, purchaseId, err := c.paymentService.CreatePurchaseWithTariffAndDeviceLimit(ctx, float64(wh.Payload.Amount), months, customer, database.InvoiceTypeTribute, tariffName, deviceLimit)
@@ -132,7 +131,7 @@ func (c *Client) newSubscriptionHandler(ctx context.Context, wh SubscriptionWebh
 		return err
 	}
 
-	err = c.paymentService.ProcessPurchaseById(ctx, purchaseId)
+	err = c.paymentService.FulfillPurchase(ctx, purchaseId, database.InvoiceTypeTribute, body)
 	if err != nil {
 		return err
 	}
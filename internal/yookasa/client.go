@@ -7,9 +7,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"remnawave-tg-shop-bot/internal/config"
+	"remnawave-tg-shop-bot/internal/locale"
+	"remnawave-tg-shop-bot/internal/logging"
+	"remnawave-tg-shop-bot/utils"
 	"strconv"
 	"time"
 
@@ -49,25 +53,20 @@ func (c *Client) CreateInvoice(ctx context.Context, amount int, month int, custo
 // tariffName - название тарифа для сохранения в метаданных (для рекуррентных платежей)
 // recurringAmount - сумма для автопродления (может отличаться от текущего платежа)
 func (c *Client) CreateInvoiceWithSave(ctx context.Context, amount int, month int, customerId int64, purchaseId int64, savePaymentMethod bool, tariffName string, recurringAmount int) (*Payment, error) {
+	return c.CreatePeriodInvoice(ctx, amount, config.MonthsPeriod(month), customerId, purchaseId, savePaymentMethod, tariffName, recurringAmount)
+}
+
+// CreatePeriodInvoice как CreateInvoiceWithSave, но на произвольный период, в том числе посуточный план тарифа
+func (c *Client) CreatePeriodInvoice(ctx context.Context, amount int, period config.Period, customerId int64, purchaseId int64, savePaymentMethod bool, tariffName string, recurringAmount int) (*Payment, error) {
 	rub := Amount{
 		Value:    strconv.Itoa(amount),
 		Currency: "RUB",
 	}
 
-	var monthString string
-	switch month {
-	case 1:
-		monthString = "месяц"
-	case 3, 4:
-		monthString = "месяца"
-	default:
-		monthString = "месяцев"
-	}
-
-	description := fmt.Sprintf("Подписка на %d %s", month, monthString)
+	description := "Подписка на " + PeriodText(period)
 	receipt := &Receipt{
 		Customer: &Customer{
-			Email: config.YookasaEmail(),
+			Email: receiptEmail(ctx),
 		},
 		Items: []Item{
 			{
@@ -86,12 +85,20 @@ func (c *Client) CreateInvoiceWithSave(ctx context.Context, amount int, month in
 		"purchaseId": purchaseId,
 		"username":   ctx.Value("username"),
 	}
+	// Идентификатор апдейта, создавшего платёж — для корреляции логов при обработке оплаты
+	if requestID := logging.RequestID(ctx); requestID != "" {
+		metaData["request_id"] = requestID
+	}
 
 	// Добавляем данные для рекуррентных платежей если включено сохранение
 	if savePaymentMethod {
 		metaData["recurring_enabled"] = true
 		metaData["recurring_tariff_name"] = tariffName
-		metaData["recurring_months"] = month
+		if period.IsDays() {
+			metaData["recurring_days"] = period.Days
+		} else {
+			metaData["recurring_months"] = period.Months
+		}
 		metaData["recurring_amount"] = recurringAmount
 	}
 
@@ -116,6 +123,24 @@ func (c *Client) CreateInvoiceWithSave(ctx context.Context, amount int, month in
 	return payment, nil
 }
 
+// PeriodText срок подписки в описании платежа: "3 месяца", "7 дней"
+func PeriodText(period config.Period) string {
+	if period.IsDays() {
+		return locale.FormatDays("ru", period.Days)
+	}
+
+	var monthString string
+	switch period.Months {
+	case 1:
+		monthString = "месяц"
+	case 3, 4:
+		monthString = "месяца"
+	default:
+		monthString = "месяцев"
+	}
+	return fmt.Sprintf("%d %s", period.Months, monthString)
+}
+
 // CreateRecurringPayment создаёт автоплатёж по сохранённому способу оплаты (payment_method_id)
 // Не требует подтверждения пользователя - деньги списываются автоматически
 func (c *Client) CreateRecurringPayment(ctx context.Context, paymentMethodID uuid.UUID, amount int, months int, customerId int64, description string) (*Payment, error) {
@@ -126,7 +151,7 @@ func (c *Client) CreateRecurringPayment(ctx context.Context, paymentMethodID uui
 
 	receipt := &Receipt{
 		Customer: &Customer{
-			Email: config.YookasaEmail(),
+			Email: receiptEmail(ctx),
 		},
 		Items: []Item{
 			{
@@ -174,7 +199,7 @@ func (c *Client) CreatePayment(ctx context.Context, request PaymentRequest, idem
 		return nil, fmt.Errorf("failed to marshal payment request: %w", err)
 	}
 
-	log.Printf("YooKassa CreatePayment request: %s", string(reqBody))
+	slog.InfoContext(ctx, "YooKassa CreatePayment request", "body", string(reqBody))
 
 	req, err := http.NewRequestWithContext(ctx, "POST", paymentURL, bytes.NewBuffer(reqBody))
 	if err != nil {
@@ -244,8 +269,10 @@ func (c *Client) GetPayment(ctx context.Context, paymentID uuid.UUID) (*Payment,
 			resp.StatusCode == http.StatusServiceUnavailable ||
 			resp.StatusCode == http.StatusGatewayTimeout {
 			retryDelay := baseDelay * time.Duration(1<<attempt)
-			log.Printf("Received %d from YooKassa. Retrying in %v... (attempt %d/%d)", resp.StatusCode, retryDelay, attempt+1, maxRetries)
-			time.Sleep(retryDelay)
+			slog.WarnContext(ctx, "Retrying YooKassa request", "status", resp.StatusCode, "delay", retryDelay, "attempt", attempt+1, "maxRetries", maxRetries)
+			if err := utils.Sleep(ctx, retryDelay); err != nil {
+				return nil, err
+			}
 			continue
 		}
 
@@ -254,3 +281,94 @@ func (c *Client) GetPayment(ctx context.Context, paymentID uuid.UUID) (*Payment,
 
 	return nil, fmt.Errorf("exceeded maximum retries due to server errors")
 }
+
+// CancelPayment отменяет платёж в статусе waiting_for_capture.
+// Платежи в статусе pending ЮKassa отменяет сама по истечении срока
+func (c *Client) CancelPayment(ctx context.Context, paymentID uuid.UUID) (*Payment, error) {
+	cancelURL := fmt.Sprintf("%s/payments/%s/cancel", c.baseURL, paymentID)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", cancelURL, bytes.NewBufferString("{}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", c.authHeader)
+	req.Header.Set("Idempotence-Key", "cancel-"+paymentID.String())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error while reading cancel resp: %w", err)
+		}
+		return nil, fmt.Errorf("API return error. Status: %d, Body: %s", resp.StatusCode, string(body))
+	}
+
+	var payment Payment
+	if err := json.NewDecoder(resp.Body).Decode(&payment); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &payment, nil
+}
+
+// ListSucceededPayments возвращает успешные платежи, подтверждённые в интервале [from, to)
+func (c *Client) ListSucceededPayments(ctx context.Context, from, to time.Time) ([]Payment, error) {
+	var payments []Payment
+	cursor := ""
+	for {
+		q := url.Values{}
+		q.Set("status", "succeeded")
+		q.Set("captured_at.gte", from.UTC().Format(time.RFC3339))
+		q.Set("captured_at.lt", to.UTC().Format(time.RFC3339))
+		q.Set("limit", "100")
+		if cursor != "" {
+			q.Set("cursor", cursor)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/payments?"+q.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", c.authHeader)
+
+		page, err := c.doListRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, page.Items...)
+
+		if page.NextCursor == "" {
+			return payments, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func (c *Client) doListRequest(req *http.Request) (*PaymentList, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error while reading list resp: %w", err)
+		}
+		return nil, fmt.Errorf("API return error. Status: %d, Body: %s", resp.StatusCode, string(body))
+	}
+
+	var page PaymentList
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &page, nil
+}
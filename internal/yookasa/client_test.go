@@ -3,10 +3,12 @@ package yookasa
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"testing/quick"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -294,3 +296,52 @@ func TestSavePaymentMethodFlagExamples(t *testing.T) {
 		})
 	}
 }
+
+func TestListSucceededPaymentsFollowsCursor(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		q := r.URL.Query()
+		if q.Get("status") != "succeeded" || q.Get("captured_at.gte") != "2025-01-01T00:00:00Z" {
+			t.Errorf("Unexpected query: %s", r.URL.RawQuery)
+		}
+		page := PaymentList{Items: []Payment{{ID: uuid.New(), Status: "succeeded"}}}
+		if q.Get("cursor") == "" {
+			page.NextCursor = "next"
+		}
+		_ = json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "shop", "secret")
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	payments, err := client.ListSucceededPayments(context.Background(), from, from.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(payments) != 2 || requests != 2 {
+		t.Errorf("Expected 2 payments from 2 pages, got %d payments from %d requests", len(payments), requests)
+	}
+}
+
+func TestGetPaymentStopsRetryingOnCancel(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "shop", "secret")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err := client.GetPayment(ctx, uuid.New())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if time.Since(started) > time.Second || requests != 1 {
+		t.Errorf("Retries must stop on cancel, got %d requests in %s", requests, time.Since(started))
+	}
+}
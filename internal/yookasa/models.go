@@ -1,6 +1,7 @@
 package yookasa
 
 import (
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,6 +23,8 @@ type Payment struct {
 	Test              bool                `json:"test,omitempty"`
 	RedirectURL       string              `json:"redirect_url,omitempty"`
 	CancellationDetails *CancellationDetails `json:"cancellation_details,omitempty"`
+	RefundedAmount      *Amount              `json:"refunded_amount,omitempty"`
+	CapturedAt          *time.Time           `json:"captured_at,omitempty"`
 }
 
 // CancellationDetails содержит информацию о причине отмены платежа
@@ -38,6 +41,11 @@ func (p *Payment) IsSucceeded() bool {
 	return p.Status == "succeeded"
 }
 
+// IsWaitingForCapture проверяет, ожидает ли платёж подтверждения (только такие платежи можно отменить через API)
+func (p *Payment) IsWaitingForCapture() bool {
+	return p.Status == "waiting_for_capture"
+}
+
 // IsPermissionRevoked проверяет, был ли платёж отклонён из-за отзыва разрешения на автоплатежи
 func (p *Payment) IsPermissionRevoked() bool {
 	if p.CancellationDetails == nil {
@@ -46,6 +54,18 @@ func (p *Payment) IsPermissionRevoked() bool {
 	return p.CancellationDetails.Reason == "permission_revoked"
 }
 
+// RefundedValue возвращает общую сумму возвратов по платежу (0 если возвратов не было)
+func (p *Payment) RefundedValue() float64 {
+	if p.RefundedAmount == nil {
+		return 0
+	}
+	value, err := strconv.ParseFloat(p.RefundedAmount.Value, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
 // IsPaymentMethodSaved проверяет, был ли способ оплаты сохранён для рекуррентных платежей
 func (p *Payment) IsPaymentMethodSaved() bool {
 	return p.PaymentMethod.Saved
@@ -56,6 +76,23 @@ func (p *Payment) GetPaymentMethodID() uuid.UUID {
 	return p.PaymentMethod.ID
 }
 
+// SavedMethodDetails возвращает тип карты, её последние 4 цифры и название способа оплаты.
+// Неизвестные ЮKassa поля — nil
+func (p *Payment) SavedMethodDetails() (cardType, last4, title *string) {
+	if card := p.PaymentMethod.Card; card != nil {
+		if card.CardType != "" {
+			cardType = &card.CardType
+		}
+		if card.Last4 != "" {
+			last4 = &card.Last4
+		}
+	}
+	if p.PaymentMethod.Title != "" {
+		title = &p.PaymentMethod.Title
+	}
+	return cardType, last4, title
+}
+
 type PaymentRequest struct {
 	Amount            Amount             `json:"amount"`
 	Confirmation      *ConfirmationType  `json:"confirmation,omitempty"`
@@ -130,4 +167,51 @@ type PaymentType struct {
 	Type  string    `json:"type,omitempty"`
 	ID    uuid.UUID `json:"id,omitempty"`
 	Saved bool      `json:"saved,omitempty"`
+	// Title название способа оплаты для показа пользователю (например, "Bank card *4444")
+	Title string `json:"title,omitempty"`
+	// Card данные карты, только для type = bank_card
+	Card *Card `json:"card,omitempty"`
+}
+
+// Card маскированные данные банковской карты
+type Card struct {
+	First6   string `json:"first6,omitempty"`
+	Last4    string `json:"last4,omitempty"`
+	CardType string `json:"card_type,omitempty"` // Visa, MasterCard, Mir и т.д.
+}
+
+// PaymentList страница списка платежей
+type PaymentList struct {
+	Items      []Payment `json:"items"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// IsRecurring проверяет, создан ли платёж автопродлением (такие платежи не связаны с покупкой)
+func (p *Payment) IsRecurring() bool {
+	return p.Metadata["recurring_payment"] == "true"
+}
+
+// События HTTP-уведомлений ЮKassa
+const (
+	EventRefundSucceeded = "refund.succeeded"
+	EventPaymentCanceled = "payment.canceled"
+)
+
+// Notification HTTP-уведомление ЮKassa о смене статуса объекта. Для событий платежа
+// в Object.ID приходит ID платежа
+type Notification struct {
+	Type   string `json:"type"`
+	Event  string `json:"event"`
+	Object Refund `json:"object"`
+}
+
+// Refund возврат платежа. Приходит в уведомлении refund.succeeded, в том числе для возвратов,
+// сделанных из личного кабинета ЮKassa или по chargeback
+type Refund struct {
+	ID          string    `json:"id"`
+	PaymentID   uuid.UUID `json:"payment_id"`
+	Status      string    `json:"status"`
+	Amount      Amount    `json:"amount"`
+	CreatedAt   time.Time `json:"created_at"`
+	Description string    `json:"description,omitempty"`
 }
@@ -0,0 +1,46 @@
+package yookasa
+
+import (
+	"context"
+	"net/mail"
+	"strings"
+
+	"remnawave-tg-shop-bot/internal/config"
+)
+
+// maxReceiptEmailLength наибольшая длина адреса по RFC 5321
+const maxReceiptEmailLength = 254
+
+type receiptEmailContextKey struct{}
+
+// WithReceiptEmail задаёт email клиента для чека создаваемого платежа вместо YOOKASA_EMAIL. Пустой email игнорируется
+func WithReceiptEmail(ctx context.Context, email string) context.Context {
+	return context.WithValue(ctx, receiptEmailContextKey{}, email)
+}
+
+// receiptEmail email для чека: заданный через WithReceiptEmail, иначе YOOKASA_EMAIL
+func receiptEmail(ctx context.Context) string {
+	if email, ok := ctx.Value(receiptEmailContextKey{}).(string); ok && email != "" {
+		return email
+	}
+	return config.YookasaEmail()
+}
+
+// NormalizeReceiptEmail проверяет адрес, введённый клиентом, и возвращает его в нижнем регистре.
+// Принимается только голый адрес (user@example.com) с точкой в домене, без имени и угловых скобок
+func NormalizeReceiptEmail(input string) (string, bool) {
+	email := strings.ToLower(strings.TrimSpace(input))
+	if email == "" || len(email) > maxReceiptEmailLength {
+		return "", false
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email {
+		return "", false
+	}
+	at := strings.LastIndex(email, "@")
+	domain := email[at+1:]
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") {
+		return "", false
+	}
+	return email, true
+}
@@ -0,0 +1,66 @@
+package yookasa
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNormalizeReceiptEmail(t *testing.T) {
+	valid := map[string]string{
+		"user@example.com":         "user@example.com",
+		"  User.Name@Example.COM ": "user.name@example.com",
+		"a+tag@mail.example.org":   "a+tag@mail.example.org",
+	}
+	for input, want := range valid {
+		got, ok := NormalizeReceiptEmail(input)
+		if !ok || got != want {
+			t.Errorf("NormalizeReceiptEmail(%q) = %q, %v; want %q", input, got, ok, want)
+		}
+	}
+
+	for _, input := range []string{
+		"",
+		"user",
+		"user@localhost",
+		"user@example.",
+		"@example.com",
+		"Name <user@example.com>",
+		"user@example.com, other@example.com",
+		strings.Repeat("a", 250) + "@example.com",
+	} {
+		if got, ok := NormalizeReceiptEmail(input); ok {
+			t.Errorf("NormalizeReceiptEmail(%q) = %q, expected rejection", input, got)
+		}
+	}
+}
+
+func TestReceiptEmailFallsBackToOperatorEmail(t *testing.T) {
+	var captured PaymentRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&captured)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Payment{ID: uuid.New(), Status: "pending"})
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "shop", "secret")
+
+	if _, err := client.CreateInvoice(WithReceiptEmail(context.Background(), "client@example.com"), 100, 1, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if captured.Receipt == nil || captured.Receipt.Customer.Email != "client@example.com" {
+		t.Fatalf("Expected receipt for the customer email, got %+v", captured.Receipt)
+	}
+
+	if _, err := client.CreateRecurringPayment(WithReceiptEmail(context.Background(), ""), uuid.New(), 100, 1, 1, "renewal"); err != nil {
+		t.Fatal(err)
+	}
+	if captured.Receipt == nil || captured.Receipt.Customer.Email != receiptEmail(context.Background()) {
+		t.Fatalf("Expected receipt for the operator email, got %+v", captured.Receipt)
+	}
+}
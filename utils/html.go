@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RawHTML уже готовый HTML: EscapeHTML, FormatHTML и шаблоны переводов вставляют его без экранирования.
+// Только для разметки, собранной ботом, — пользовательский ввод и данные панели так не помечаются
+type RawHTML string
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// EscapeHTML экранирует значение для сообщения с ParseModeHTML: имя пользователя, промокод или имя ноды
+// отображаются как текст, а не как теги
+func EscapeHTML(s string) string {
+	return htmlEscaper.Replace(s)
+}
+
+// HTMLValue значение для подстановки в HTML: RawHTML как есть, всё остальное — текстом через EscapeHTML
+func HTMLValue(v interface{}) string {
+	if raw, ok := v.(RawHTML); ok {
+		return string(raw)
+	}
+	return EscapeHTML(fmt.Sprint(v))
+}
+
+// FormatHTML как fmt.Sprintf, но аргументы-строки и ошибки экранируются. Формат — разметка бота и не экранируется,
+// аргументы RawHTML вставляются как есть, остальные форматируются по глаголу формата
+func FormatHTML(format string, args ...interface{}) string {
+	escaped := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case RawHTML:
+			escaped[i] = string(v)
+		case string:
+			escaped[i] = EscapeHTML(v)
+		case error:
+			escaped[i] = EscapeHTML(v.Error())
+		default:
+			escaped[i] = arg
+		}
+	}
+	return fmt.Sprintf(format, escaped...)
+}
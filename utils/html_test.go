@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEscapeHTML(t *testing.T) {
+	tests := map[string]string{
+		"Иван":                                 "Иван",
+		"<b>admin</b>":                         "&lt;b&gt;admin&lt;/b&gt;",
+		`<a href="tg://user?id=1">Support</a>`: "&lt;a href=&quot;tg://user?id=1&quot;&gt;Support&lt;/a&gt;",
+		"Tom & Jerry":                          "Tom &amp; Jerry",
+		"&lt;":                                 "&amp;lt;",
+	}
+	for in, want := range tests {
+		if got := EscapeHTML(in); got != want {
+			t.Errorf("EscapeHTML(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFormatHTMLEscapesArguments(t *testing.T) {
+	username := `<a href="https://evil.example">Поддержка</a>`
+	got := FormatHTML("👤 <b>%s</b>, код <code>%s</code>, %d дн., %v", username, "PROMO_1", 7, errors.New("bad <input>"))
+	want := "👤 <b>&lt;a href=&quot;https://evil.example&quot;&gt;Поддержка&lt;/a&gt;</b>, код <code>PROMO_1</code>, 7 дн., bad &lt;input&gt;"
+	if got != want {
+		t.Errorf("FormatHTML() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatHTMLKeepsRawHTML(t *testing.T) {
+	got := FormatHTML("%s\n%s", RawHTML("<b>Отчёт</b>"), "<b>")
+	if want := "<b>Отчёт</b>\n&lt;b&gt;"; got != want {
+		t.Errorf("FormatHTML() = %q, want %q", got, want)
+	}
+}
+
+func TestHTMLValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{"<i>name</i>", "&lt;i&gt;name&lt;/i&gt;"},
+		{RawHTML("<i>name</i>"), "<i>name</i>"},
+		{42, "42"},
+	}
+	for _, tt := range tests {
+		if got := HTMLValue(tt.value); got != tt.want {
+			t.Errorf("HTMLValue(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	// MaxMessageLength лимит длины текста сообщения Telegram (в UTF-16 символах)
+	MaxMessageLength = 4096
+	// MaxMessageEntities лимит количества сущностей форматирования в одном сообщении Telegram
+	MaxMessageEntities = 100
+)
+
+// htmlEntityTag открывающий HTML тег — каждый превращается в отдельную сущность сообщения
+var htmlEntityTag = regexp.MustCompile(`<[a-zA-Z]`)
+
+// SplitMessage разбивает HTML текст на части, укладывающиеся в лимиты Telegram на длину и количество сущностей
+func SplitMessage(text string) []string {
+	return SplitMessageLimit(text, MaxMessageLength, MaxMessageEntities)
+}
+
+// SplitMessageLimit разбивает текст по строкам так, чтобы в каждой части было не больше maxLength
+// UTF-16 символов и maxEntities HTML тегов. Теги в отчётах бота не переносятся между строками,
+// поэтому разрез по строке их не ломает. Строка длиннее лимита режется по пробелу, а если его нет — по символу.
+// Всегда возвращает хотя бы одну часть
+func SplitMessageLimit(text string, maxLength, maxEntities int) []string {
+	var chunks, current []string
+	length, entities := 0, 0
+	flush := func() {
+		if chunk := strings.Trim(strings.Join(current, "\n"), "\n"); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current, length, entities = nil, 0, 0
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		for _, part := range splitLongLine(line, maxLength) {
+			partLength := utf16Length(part)
+			partEntities := len(htmlEntityTag.FindAllStringIndex(part, -1))
+			if len(current) > 0 && (length+1+partLength > maxLength || entities+partEntities > maxEntities) {
+				flush()
+			}
+			if len(current) > 0 {
+				length++
+			}
+			current = append(current, part)
+			length += partLength
+			entities += partEntities
+		}
+	}
+	flush()
+
+	if len(chunks) == 0 {
+		return []string{text}
+	}
+	return chunks
+}
+
+func splitLongLine(line string, maxLength int) []string {
+	var parts []string
+	for utf16Length(line) > maxLength {
+		cut, lastSpace, n := 0, 0, 0
+		for i, r := range line {
+			n += utf16.RuneLen(r)
+			if n > maxLength {
+				cut = i
+				break
+			}
+			if r == ' ' {
+				lastSpace = i
+			}
+		}
+		if lastSpace > 0 {
+			cut = lastSpace
+		}
+		if cut == 0 {
+			_, cut = utf8.DecodeRuneInString(line)
+		}
+		parts = append(parts, line[:cut])
+		line = strings.TrimLeft(line[cut:], " ")
+	}
+	return append(parts, line)
+}
+
+func utf16Length(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// SendLongMessage отправляет текст одним или несколькими сообщениями, если он не укладывается в лимиты Telegram.
+// Клавиатура прикрепляется к последней части, она же возвращается
+func SendLongMessage(ctx context.Context, b *bot.Bot, params *bot.SendMessageParams) (*models.Message, error) {
+	chunks := SplitMessage(params.Text)
+	var msg *models.Message
+	for i, chunk := range chunks {
+		p := *params
+		p.Text = chunk
+		if i < len(chunks)-1 {
+			p.ReplyMarkup = nil
+		}
+		var err error
+		msg, err = b.SendMessage(ctx, &p)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitMessageShortTextIsSingleChunk(t *testing.T) {
+	text := "<b>Отчёт</b>\n\nвсё хорошо"
+	chunks := SplitMessage(text)
+	if len(chunks) != 1 || chunks[0] != text {
+		t.Fatalf("expected text unchanged, got %q", chunks)
+	}
+}
+
+func TestSplitMessageSplitsByLines(t *testing.T) {
+	lines := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		lines = append(lines, strings.Repeat("a", 9))
+	}
+	chunks := SplitMessageLimit(strings.Join(lines, "\n"), 30, 100)
+
+	if len(chunks) != 4 {
+		t.Fatalf("expected 4 chunks, got %d: %q", len(chunks), chunks)
+	}
+	for _, c := range chunks {
+		if len(c) > 30 {
+			t.Errorf("chunk exceeds limit: %q", c)
+		}
+		if strings.HasPrefix(c, "\n") || strings.HasSuffix(c, "\n") {
+			t.Errorf("chunk has leading or trailing newline: %q", c)
+		}
+	}
+	if got := strings.Join(chunks, "\n"); got != strings.Join(lines, "\n") {
+		t.Errorf("chunks lost content: %q", got)
+	}
+}
+
+func TestSplitMessageRespectsEntityLimit(t *testing.T) {
+	text := strings.Repeat("<b>x</b>\n", 5)
+	chunks := SplitMessageLimit(text, 4096, 2)
+	if len(chunks) != 3 {
+		t.Fatalf("expected 3 chunks, got %d: %q", len(chunks), chunks)
+	}
+}
+
+func TestSplitMessageLongLineCutsAtSpace(t *testing.T) {
+	chunks := SplitMessageLimit("hello world again", 12, 100)
+	want := []string{"hello world", "again"}
+	if len(chunks) != len(want) {
+		t.Fatalf("expected %q, got %q", want, chunks)
+	}
+	for i := range want {
+		if chunks[i] != want[i] {
+			t.Errorf("chunk %d: expected %q, got %q", i, want[i], chunks[i])
+		}
+	}
+}
+
+func TestSplitMessageCountsUTF16(t *testing.T) {
+	// эмодзи занимает два UTF-16 символа, поэтому в лимит 4 помещается только два
+	chunks := SplitMessageLimit("😀😀😀", 4, 100)
+	if len(chunks) != 2 || chunks[0] != "😀😀" || chunks[1] != "😀" {
+		t.Fatalf("unexpected chunks %q", chunks)
+	}
+}
+
+func TestSplitMessageEmptyText(t *testing.T) {
+	if chunks := SplitMessage(""); len(chunks) != 1 || chunks[0] != "" {
+		t.Fatalf("expected single empty chunk, got %q", chunks)
+	}
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// Sleep ждёт d или отмены ctx. Возвращает ctx.Err(), если ожидание прервано
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// TelegramSendInterval пауза между сообщениями массовой отправки: ~28 сообщений в секунду при лимите Telegram ~30
+const TelegramSendInterval = 35 * time.Millisecond
+
+// ThrottleSend выдерживает паузу после очередного сообщения массовой отправки, чтобы не упереться в лимит Telegram.
+// Возвращает ctx.Err(), если ожидание прервано
+func ThrottleSend(ctx context.Context) error {
+	return Sleep(ctx, TelegramSendInterval)
+}